@@ -30,6 +30,13 @@ import (
 // +genclient
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Strategy",type="string",JSONPath=".spec.scalingStrategy"
+// +kubebuilder:printcolumn:name="Actual",type="integer",JSONPath=".status.actualScale"
+// +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".status.desiredScale"
+// +kubebuilder:printcolumn:name="Metric",type="string",JSONPath=".status.currentMetrics[0].name"
+// +kubebuilder:printcolumn:name="Average",type="string",JSONPath=".status.currentMetrics[0].averageValue"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".status.currentMetrics[0].targetValue"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // PodAutoscaler is the Schema for the podautoscalers API, a resource to scale Kubernetes pods based on observed metrics.
@@ -64,11 +71,14 @@ type PodAutoscalerSpec struct {
 	MaxReplicas int32 `json:"maxReplicas"`
 
 	// MetricsSources defines a list of sources from which metrics are collected to make scaling decisions.
+	// Each source recommends a replica count and the target is scaled to the highest one. The target metrics of the
+	// sources must be unique.
 	// +kubebuilder:validation:MinItems=1
 	MetricsSources []MetricSource `json:"metricsSources,omitempty"`
 
-	// ScalingStrategy defines the strategy to use for scaling.
-	// +kubebuilder:validation:Enum={HPA,KPA,APA}
+	// ScalingStrategy defines the strategy to use for scaling: HPA, KPA, APA or a strategy registered in the
+	// scaler package. An unknown strategy is reported with the InvalidStrategy condition.
+	// +kubebuilder:validation:MinLength=1
 	ScalingStrategy ScalingStrategyType `json:"scalingStrategy"`
 }
 
@@ -93,6 +103,14 @@ const (
 	POD MetricSourceType = "pod"
 	// DOMAIN only need to access specified domain
 	DOMAIN MetricSourceType = "domain"
+	// CUSTOM queries the per-pod metric from the custom metrics API (custom.metrics.k8s.io), e.g. prometheus-adapter
+	CUSTOM MetricSourceType = "custom"
+	// OBJECT queries the metric describing the scale target from the custom metrics API (custom.metrics.k8s.io)
+	OBJECT MetricSourceType = "object"
+	// EXTERNAL queries the metric from the external metrics API (external.metrics.k8s.io)
+	EXTERNAL MetricSourceType = "external"
+	// REDIS reads the metric of the scale target from a Redis key, e.g. the requests of a model pending at the gateway
+	REDIS MetricSourceType = "redis"
 )
 
 type ProtocolType string
@@ -105,7 +123,7 @@ const (
 // MetricSource defines an endpoint and path from which metrics are collected.
 type MetricSource struct {
 	// access an endpoint or scan a list of k8s pod
-	// +kubebuilder:validation:Enum={pod,domain}
+	// +kubebuilder:validation:Enum={pod,domain,custom,object,external,redis}
 	MetricSourceType MetricSourceType `json:"metricSourceType"`
 	// http or https
 	// +kubebuilder:validation:Enum={http,https}
@@ -120,6 +138,11 @@ type MetricSource struct {
 	TargetMetric string `json:"targetMetric"`
 	// TargetValue sets the desired threshold for the metric (e.g., 50 for 50% utilization).
 	TargetValue string `json:"targetValue"`
+	// KeyPattern is the Redis key read by a redis source, where {model} is replaced by the model.aibrix.ai/name label
+	// of the PodAutoscaler or of its scale target, e.g. pending:{model}, the default, counts the requests of the
+	// model pending at the gateway. Meaningless for the other sources.
+	// +optional
+	KeyPattern string `json:"keyPattern,omitempty"`
 }
 
 // PodAutoscalerStatus defines the observed state of PodAutoscaler
@@ -128,6 +151,11 @@ type PodAutoscalerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// ObservedGeneration is the generation of the PodAutoscaler observed by the last successful reconcile, the
+	// status reflects the latest spec when it equals metadata.generation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// LastScaleTime is the last time the PodAutoscaler scaled the number of pods,
 	// used by the autoscaler to control how often the number of pods is changed.
 	// +optional
@@ -144,6 +172,79 @@ type PodAutoscalerStatus struct {
 	// Conditions is the set of conditions required for this autoscaler to scale its target,
 	// and indicates whether or not those conditions are met.
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// CurrentMetrics is the last observed value of each metric source and the replicas it recommended.
+	// +optional
+	CurrentMetrics []MetricStatus `json:"currentMetrics,omitempty"`
+
+	// LastDecision is the last scaling decision of the autoscaler, with the explanation of how the metrics and the
+	// scaling rules led to it.
+	// +optional
+	LastDecision *ScalingDecision `json:"lastDecision,omitempty"`
+
+	// ScalingState is the state of the KPA kept across the restarts of the controller, so that a restart neither
+	// drops the burst protection of the panic mode nor scales down within the scale down stabilization window.
+	// +optional
+	ScalingState *ScalingState `json:"scalingState,omitempty"`
+}
+
+// ScalingState is the state of the scaling algorithm persisted in the status of the PodAutoscaler.
+type ScalingState struct {
+	// PanicTime is when the panic threshold was last exceeded, unset out of panic mode.
+	// +optional
+	PanicTime *metav1.Time `json:"panicTime,omitempty"`
+	// MaxPanicReplicas is the highest replica count recommended in the panic mode, which is held until it exits.
+	// +optional
+	MaxPanicReplicas int32 `json:"maxPanicReplicas,omitempty"`
+	// Recommendations are the recent recommendations still considered by the scale down stabilization, oldest
+	// first. Only the recommendations which bound a later scale down are kept, at most 10.
+	// +optional
+	Recommendations []ScalingRecommendation `json:"recommendations,omitempty"`
+}
+
+// ScalingRecommendation is a replica count recommended by the scaling algorithm.
+type ScalingRecommendation struct {
+	// Time is when the recommendation was made.
+	Time metav1.Time `json:"time"`
+	// Replicas is the replica count recommended.
+	Replicas int32 `json:"replicas"`
+}
+
+// ScalingDecision describes a scaling decision of the PodAutoscaler.
+type ScalingDecision struct {
+	// Time is when the decision was first made, it is kept while the decision and its explanation are unchanged.
+	Time metav1.Time `json:"time"`
+	// DesiredReplicas is the replica count decided.
+	DesiredReplicas int32 `json:"desiredReplicas"`
+	// Message explains the decision, e.g. "concurrency avg 18.2 over 60s vs target 10 → raw 11, capped by
+	// maxScaleUp 2x to 8".
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// MetricStatus describes the last observed value of a metric source.
+type MetricStatus struct {
+	// Name is the target metric of the source.
+	Name string `json:"name"`
+	// CurrentValue is the metric value the scaling algorithm computed its recommendation from.
+	// +optional
+	CurrentValue string `json:"currentValue,omitempty"`
+	// DesiredReplicas is the replica count recommended by the metric.
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+	// AverageValue is CurrentValue averaged over the ReadyPods of the target.
+	// +optional
+	AverageValue string `json:"averageValue,omitempty"`
+	// TargetValue is the target value of the metric source.
+	// +optional
+	TargetValue string `json:"targetValue,omitempty"`
+	// ReadyPods is the number of ready pods of the target the metric was evaluated over.
+	// +optional
+	ReadyPods int32 `json:"readyPods,omitempty"`
+	// LastEvaluationTime is when the metric was last evaluated. It is only written along with a change of the
+	// other fields, an evaluation observing the same values does not update the status.
+	// +optional
+	LastEvaluationTime *metav1.Time `json:"lastEvaluationTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -169,7 +270,113 @@ const (
 	QPS = "qps"
 )
 
-// GetPaMetricSources Currently, we don't support metric resources that are more than one yet.
+// Condition types of a PodAutoscaler.
+const (
+	// AbleToScale indicates whether the autoscaler is able to fetch and update the scale of its target.
+	AbleToScale = "AbleToScale"
+	// ScalingActive indicates whether the autoscaler is able to compute the desired scale from the metrics of its
+	// target. While the metrics are unavailable the target keeps its current replicas.
+	ScalingActive = "ScalingActive"
+	// ScalingDisabled indicates that the autoscaler has stopped managing the scale of its target,
+	// e.g. because the target was manually scaled to zero replicas.
+	ScalingDisabled = "ScalingDisabled"
+	// NoReadyPods indicates that the target has replicas but none of its pods is ready, e.g. because all of them
+	// are crash-looping. The reason reports the NoReadyPodsPolicy applied to the target.
+	NoReadyPods = "NoReadyPods"
+	// DeprecatedConfiguration indicates that the PodAutoscaler is configured with deprecated or unknown
+	// annotations. The message lists the keys and the replacements of the deprecated ones.
+	DeprecatedConfiguration = "DeprecatedConfiguration"
+	// ReconcileTimeout indicates that the last reconcile exceeded its deadline. The message reports the phase in
+	// progress when the deadline expired: scale_lookup, metric_fetch or actuation.
+	ReconcileTimeout = "ReconcileTimeout"
+	// Active indicates whether a scale-to-zero KPA target is receiving traffic. The transition to false records
+	// when the target was last active, the target is scaled to zero once it stays inactive for the retention period.
+	Active = "Active"
+	// Panicking indicates whether a KPA target is in panic mode: a burst of its panic window metric crossed the panic
+	// threshold, so the target is scaled on the panic window and not scaled down until the burst is absorbed.
+	Panicking = "Panicking"
+	// InvalidStrategy indicates that the scaling strategy of the PodAutoscaler is neither HPA nor a strategy
+	// registered in the scaler package. The target is not scaled until the strategy is fixed.
+	InvalidStrategy = "InvalidStrategy"
+	// InvalidHPAMetrics indicates that a metric source or a scaling annotation of a PodAutoscaler of the HPA strategy
+	// cannot be represented by the HPA it generates. The message lists them, the HPA is not created or updated until
+	// they are fixed.
+	InvalidHPAMetrics = "InvalidHPAMetrics"
+	// ConfigInvalid indicates that the annotations tuning the scaling algorithm of a KPA PodAutoscaler are invalid or
+	// out of range. The message lists them, the target keeps its replicas until they are fixed.
+	ConfigInvalid = "ConfigInvalid"
+	// PDBConstrained indicates whether a scale down of the target was clamped to the replicas required by a
+	// PodDisruptionBudget selecting its pods. The message names the PodDisruptionBudget.
+	PDBConstrained = "PDBConstrained"
+	// ScalingLimited indicates whether the replicas recommended by the metrics were clamped to the MinReplicas or
+	// MaxReplicas of the PodAutoscaler. The message reports the recommendation before the clamp.
+	ScalingLimited = "ScalingLimited"
+	// RateLimited indicates whether the replicas recommended by the metrics were truncated by the max scale up or
+	// scale down rate of the PodAutoscaler. The message reports the recommendation before the truncation.
+	RateLimited = "RateLimited"
+	// QuotaLimited indicates whether a scale up of the target was clamped so that the total replicas of the
+	// PodAutoscalers sharing a replica quota, of the namespace or of the cluster, stay within it. The message names
+	// the quota.
+	QuotaLimited = "QuotaLimited"
+	// RecommendationPublished indicates whether the last recommendation of a PodAutoscaler in the External
+	// actuation mode was published to its RecommendationSink.
+	RecommendationPublished = "RecommendationPublished"
+	// ImportCompleted indicates whether the HPA named by the import-from-hpa annotation was imported into the
+	// PodAutoscaler. The message lists the HPA settings which could not be represented and were skipped.
+	ImportCompleted = "ImportCompleted"
+	// AutoscalingIneffective indicates whether the metric of the target consistently missed its target value one
+	// stable window after the recent scaling decisions. The reason reports whether the target was under- or
+	// over-provisioned, which suggests a review of the scaling parameters.
+	AutoscalingIneffective = "AutoscalingIneffective"
+	// MetricsDegraded indicates whether some metric sources of the PodAutoscaler could not be read, e.g. because the
+	// Redis of a redis source is unavailable, in which case the target is scaled on the other sources. The message
+	// lists the failed sources.
+	MetricsDegraded = "MetricsDegraded"
+)
+
+// NoReadyPodsPolicy defines how the autoscaler sizes a target whose pods are all unready, in which case
+// no metrics are available to drive the scaling algorithm.
+type NoReadyPodsPolicy string
+
+const (
+	// NoReadyPodsHold keeps the current replicas of the target.
+	NoReadyPodsHold NoReadyPodsPolicy = "Hold"
+	// NoReadyPodsScaleToMin scales the target down to its minimum replicas.
+	NoReadyPodsScaleToMin NoReadyPodsPolicy = "ScaleToMin"
+	// NoReadyPodsScaleToMax scales the target up to its maximum replicas.
+	NoReadyPodsScaleToMax NoReadyPodsPolicy = "ScaleToMax"
+)
+
+// ActuationMode defines how the autoscaler applies the desired replicas to the scale target.
+type ActuationMode string
+
+const (
+	// ActuationModeScaleSubresource updates the replicas through the scale of the target, which works for
+	// every kind exposing a scale subresource.
+	ActuationModeScaleSubresource ActuationMode = "ScaleSubresource"
+	// ActuationModeDirectPatch patches spec.replicas of a Deployment, StatefulSet or ReplicaSet, for clusters
+	// where the controller is not allowed to update the scale subresource.
+	ActuationModeDirectPatch ActuationMode = "DirectPatch"
+	// ActuationModeExternal leaves the replicas of the target unchanged and publishes the recommendations to a
+	// RecommendationSink, for the clusters where an external pipeline, e.g. GitOps, applies them.
+	ActuationModeExternal ActuationMode = "External"
+)
+
+// RecommendationSink defines where the recommendations of a PodAutoscaler in the External actuation mode are
+// published.
+type RecommendationSink string
+
+const (
+	// RecommendationSinkAnnotation writes the last recommendation to an annotation of the PodAutoscaler.
+	RecommendationSinkAnnotation RecommendationSink = "Annotation"
+	// RecommendationSinkWebhook posts the recommendations to an HTTP endpoint, signed with an HMAC key.
+	RecommendationSinkWebhook RecommendationSink = "Webhook"
+	// RecommendationSinkRedisStream appends the recommendations to a Redis stream.
+	RecommendationSinkRedisStream RecommendationSink = "RedisStream"
+)
+
+// GetPaMetricSources returns the metric source of a PodAutoscaler with a single source. The scaling context of each
+// source of a PodAutoscaler with several sources is built from a copy holding only that source.
 func GetPaMetricSources(pa PodAutoscaler) (MetricSource, error) {
 	if len(pa.Spec.MetricsSources) != 1 {
 		return MetricSource{}, fmt.Errorf("for now we only support one MetricsSource")
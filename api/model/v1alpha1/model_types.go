@@ -0,0 +1,139 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ModelSpec defines the desired state of Model
+type ModelSpec struct {
+	// ModelName is the name of the model, i.e. the value of the model.aibrix.ai/name label of its serving pods
+	// and workloads. Defaults to the name of the Model.
+	// +optional
+	ModelName string `json:"modelName,omitempty"`
+}
+
+// ModelAutoscalerStatus summarizes the PodAutoscaler of a model.
+type ModelAutoscalerStatus struct {
+	// Name is the name of the PodAutoscaler.
+	Name string `json:"name"`
+	// DesiredScale is the number of replicas the PodAutoscaler computed for the model.
+	// +optional
+	DesiredScale int32 `json:"desiredScale,omitempty"`
+	// ActualScale is the number of replicas the scale target of the PodAutoscaler runs.
+	// +optional
+	ActualScale int32 `json:"actualScale,omitempty"`
+	// Conditions are the conditions of the PodAutoscaler that tell whether it scales the model.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ModelAdapterSummary summarizes a ModelAdapter whose base model is the model.
+type ModelAdapterSummary struct {
+	// Name is the name of the ModelAdapter.
+	Name string `json:"name"`
+	// Phase is the phase of the ModelAdapter.
+	// +optional
+	Phase ModelAdapterPhase `json:"phase,omitempty"`
+	// Available tells whether the ModelAdapter is loaded on a pod and ready to serve.
+	Available bool `json:"available"`
+	// Instances is the number of pods the ModelAdapter is loaded on.
+	// +optional
+	Instances int32 `json:"instances,omitempty"`
+}
+
+// ModelStatus defines the observed state of Model
+type ModelStatus struct {
+	// ObservedGeneration is the generation of the Model the status was computed for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// TotalPods is the number of the serving pods of the model that are not terminated.
+	// +optional
+	TotalPods int32 `json:"totalPods,omitempty"`
+	// ReadyPods is the number of the serving pods of the model that are ready.
+	// +optional
+	ReadyPods int32 `json:"readyPods,omitempty"`
+	// RoutablePods is the number of the ready serving pods the gateway can route requests to,
+	// i.e. with an IP address and not terminating.
+	// +optional
+	RoutablePods int32 `json:"routablePods,omitempty"`
+	// Autoscaler summarizes the PodAutoscaler labeled with the model, if any.
+	// +optional
+	Autoscaler *ModelAutoscalerStatus `json:"autoscaler,omitempty"`
+	// Adapters summarizes the ModelAdapters whose base model is the model.
+	// +optional
+	Adapters []ModelAdapterSummary `json:"adapters,omitempty"`
+	// Conditions represents the observation of the model's current state.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types of a Model.
+const (
+	// ModelConditionServing tells whether the gateway can route requests of the model to one of its pods.
+	ModelConditionServing = "Serving"
+	// ModelConditionAutoscaling tells whether a PodAutoscaler is able to scale the pods of the model.
+	ModelConditionAutoscaling = "Autoscaling"
+	// ModelConditionAdaptersReady tells whether all the ModelAdapters of the model are available.
+	ModelConditionAdaptersReady = "AdaptersReady"
+)
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyPods`
+// +kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.totalPods`
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.autoscaler.desiredScale`
+// +kubebuilder:printcolumn:name="Serving",type=string,JSONPath=`.status.conditions[?(@.type=="Serving")].status`
+// +kubebuilder:printcolumn:name="Adapters",type=string,JSONPath=`.status.conditions[?(@.type=="AdaptersReady")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Model is the Schema for the models API. Its status aggregates the state of the serving pods, the PodAutoscaler
+// and the ModelAdapters of a model.
+type Model struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ModelSpec   `json:"spec,omitempty"`
+	Status ModelStatus `json:"status,omitempty"`
+}
+
+// GetModelName returns the name of the model of the Model, which defaults to the name of the Model.
+func (m *Model) GetModelName() string {
+	if m.Spec.ModelName != "" {
+		return m.Spec.ModelName
+	}
+	return m.Name
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ModelList contains a list of Model
+type ModelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Model `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Model{}, &ModelList{})
+}
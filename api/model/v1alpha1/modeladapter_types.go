@@ -47,16 +47,49 @@ type ModelAdapterSpec struct {
 	// +optional
 	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
 
-	// Replicas is the desired number of replicas of model adapter
+	// Replicas is the desired number of replicas of model adapter, the pods matching PodSelector it is loaded on
 	// +optional
 	// +kubebuilder:default=1
 	Replicas *int32 `json:"replicas,omitempty"`
 
+	// Placement is how the pods the ModelAdapter is loaded on are chosen
+	// +optional
+	Placement *ModelAdapterPlacement `json:"placement,omitempty"`
+
+	// RolloutPercent is the percentage of traffic routed to the new artifact after ArtifactURL changes, the rest
+	// is served by the previous artifact. Both stay loaded until the rollout reaches 100. When unset, the controller
+	// raises the percentage over time.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	RolloutPercent *int32 `json:"rolloutPercent,omitempty"`
+
 	// Additional fields can be added here to customize the scheduling and deployment
 	// +optional
 	AdditionalConfig map[string]string `json:"additionalConfig,omitempty"`
 }
 
+// ModelAdapterPlacementStrategy selects the pods a ModelAdapter is loaded on among the pods matching its selector.
+// +kubebuilder:validation:Enum=Spread;BinPack
+type ModelAdapterPlacementStrategy string
+
+const (
+	// ModelAdapterPlacementSpread loads the ModelAdapter on the pods with the fewest adapters loaded
+	ModelAdapterPlacementSpread ModelAdapterPlacementStrategy = "Spread"
+	// ModelAdapterPlacementBinPack loads the ModelAdapter on the pods which already host other adapters
+	ModelAdapterPlacementBinPack ModelAdapterPlacementStrategy = "BinPack"
+)
+
+// ModelAdapterPlacement is how the pods a ModelAdapter is loaded on are chosen. The chosen pods keep the
+// ModelAdapter as long as they stay ready and match the selector, the pods replacing the ones removed are chosen
+// by the strategy.
+type ModelAdapterPlacement struct {
+	// Strategy selects the pods the ModelAdapter is loaded on
+	// +optional
+	// +kubebuilder:default=Spread
+	Strategy ModelAdapterPlacementStrategy `json:"strategy,omitempty"`
+}
+
 // ModelAdapterPhase is a string representation of the ModelAdapter lifecycle phase.
 type ModelAdapterPhase string
 
@@ -94,6 +127,80 @@ type ModelAdapterStatus struct {
 	// Instances lists all pod instances of ModelAdapter
 	// +optional
 	Instances []string `json:"instances,omitempty"`
+	// ArtifactURL is the artifact loaded under the name of the ModelAdapter
+	// +optional
+	ArtifactURL string `json:"artifactURL,omitempty"`
+	// Rollout is the traffic shifting to a new artifact, set while the previous and the new artifact are both loaded
+	// +optional
+	Rollout *ModelAdapterRollout `json:"rollout,omitempty"`
+	// InstanceStatuses is the load state of the ModelAdapter on each pod of Instances
+	// +listType=map
+	// +listMapKey=podName
+	// +optional
+	InstanceStatuses []ModelAdapterInstanceStatus `json:"instanceStatuses,omitempty"`
+	// DesiredInstances is the number of pods selected to load the ModelAdapter
+	// +optional
+	DesiredInstances int32 `json:"desiredInstances,omitempty"`
+	// LoadedInstances is the number of pods which loaded the ModelAdapter
+	// +optional
+	LoadedInstances int32 `json:"loadedInstances,omitempty"`
+	// Evictions are the latest removals of pods from Instances, the oldest first
+	// +optional
+	Evictions []ModelAdapterEviction `json:"evictions,omitempty"`
+}
+
+// ModelAdapterEviction is the removal of a pod from the instances of a ModelAdapter.
+type ModelAdapterEviction struct {
+	// PodName is the name of the pod
+	PodName string `json:"podName"`
+	// Reason is why the pod was removed: PodDeleted, PodNotReady, SelectorMismatch or ScaledDown
+	Reason string `json:"reason"`
+	// Message details Reason
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Time is when the pod was removed
+	Time metav1.Time `json:"time"`
+}
+
+// ModelAdapterInstancePhase is the load state of a ModelAdapter on a pod.
+type ModelAdapterInstancePhase string
+
+const (
+	// ModelAdapterInstanceLoading means the pod is selected and the ModelAdapter is not loaded yet
+	ModelAdapterInstanceLoading ModelAdapterInstancePhase = "Loading"
+	// ModelAdapterInstanceLoaded means the pod serves the ModelAdapter
+	ModelAdapterInstanceLoaded ModelAdapterInstancePhase = "Loaded"
+	// ModelAdapterInstanceFailed means the last loading of the ModelAdapter on the pod failed, it is retried
+	ModelAdapterInstanceFailed ModelAdapterInstancePhase = "Failed"
+)
+
+// ModelAdapterInstanceStatus is the load state of a ModelAdapter on one of its pods.
+type ModelAdapterInstanceStatus struct {
+	// PodName is the name of the pod
+	PodName string `json:"podName"`
+	// PodIP is the IP of the pod the ModelAdapter is loaded through
+	// +optional
+	PodIP string `json:"podIP,omitempty"`
+	// Phase is the load state of the ModelAdapter on the pod
+	Phase ModelAdapterInstancePhase `json:"phase"`
+	// LastTransitionTime is the last time Phase changed
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Message explains Phase, e.g. the error of the last loading
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ModelAdapterRollout is the traffic shifting of a ModelAdapter from the artifact loaded under its name to the
+// artifact of the spec, which is loaded under a versioned name until the rollout completes.
+type ModelAdapterRollout struct {
+	// Version is the name the new artifact is loaded under during the rollout
+	Version string `json:"version"`
+	// Percent is the percentage of traffic routed to Version
+	Percent int32 `json:"percent"`
+	// LastStepTime is the last time Percent changed
+	// +optional
+	LastStepTime metav1.Time `json:"lastStepTime,omitempty"`
 }
 
 type ModelAdapterConditionType string
@@ -110,6 +217,10 @@ const (
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Loaded",type="integer",JSONPath=".status.loadedInstances"
+// +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".status.desiredInstances"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // ModelAdapter is the Schema for the modeladapters API
 type ModelAdapter struct {
@@ -49,6 +49,9 @@ import (
 	"github.com/vllm-project/aibrix/pkg/cache"
 	"github.com/vllm-project/aibrix/pkg/config"
 	"github.com/vllm-project/aibrix/pkg/controller"
+	"github.com/vllm-project/aibrix/pkg/controller/modeladapter"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler"
+	"github.com/vllm-project/aibrix/pkg/controller/util/events"
 	apiwebhook "github.com/vllm-project/aibrix/pkg/webhook"
 	//+kubebuilder:scaffold:imports
 )
@@ -85,6 +88,11 @@ func RegisterSchemas(scheme *runtime.Scheme) error {
 		utilruntime.Must(modelv1alpha1.AddToScheme(scheme))
 	}
 
+	if features.IsControllerEnabled(features.ModelController) {
+		utilruntime.Must(modelv1alpha1.AddToScheme(scheme))
+		utilruntime.Must(autoscalingv1alpha1.AddToScheme(scheme))
+	}
+
 	if features.IsControllerEnabled(features.DistributedInferenceController) {
 		utilruntime.Must(orchestrationv1alpha1.AddToScheme(scheme))
 		utilruntime.Must(rayclusterv1.AddToScheme(scheme))
@@ -110,6 +118,16 @@ func main() {
 	var controllers string
 	var enableRuntimeSidecar bool
 	var debugMode bool
+	var eventRateLimitInterval time.Duration
+	var orphanSweepInterval time.Duration
+	var podAutoscalerSyncPeriod time.Duration
+	var podAutoscalerMaxConcurrentReconciles int
+	var podAutoscalerReconcileTimeout time.Duration
+	var podAutoscalerClusterMaxReplicas int
+	var orphanSweepDryRun bool
+	var recommendationRedisAddr string
+	var metricsRedisAddr string
+	var modelAdapterUnloadGracePeriod time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -135,6 +153,28 @@ func main() {
 		"If set, Runtime management API will be enabled for the metrics, model adapter and model downloading interactions, control plane will not talk to engine directly anymore")
 	flag.BoolVar(&debugMode, "debug-mode", false,
 		"If set, control plane will talk to localhost nodePort for testing purpose")
+	flag.DurationVar(&eventRateLimitInterval, "event-rate-limit-interval", events.DefaultRateLimitInterval,
+		"event-rate-limit-interval is how often an identical warning event of an object is emitted at most, 0 disables the limit.")
+	flag.DurationVar(&podAutoscalerSyncPeriod, "podautoscaler-sync-period", podautoscaler.DefaultSyncPeriod,
+		"podautoscaler-sync-period is how often a KPA or APA PodAutoscaler re-evaluates its metrics, the sync-period annotation of a PodAutoscaler overrides it.")
+	flag.IntVar(&podAutoscalerMaxConcurrentReconciles, "podautoscaler-max-concurrent-reconciles", podautoscaler.DefaultMaxConcurrentReconciles,
+		"podautoscaler-max-concurrent-reconciles is the number of PodAutoscalers reconciled concurrently, so that a slow metric scrape does not hold up the others.")
+	flag.DurationVar(&podAutoscalerReconcileTimeout, "podautoscaler-reconcile-timeout", podautoscaler.DefaultReconcileTimeout,
+		"podautoscaler-reconcile-timeout bounds the calls of a PodAutoscaler reconcile to the API server and to the metric sources, raise it for slow metric backends or targets with many pods.")
+	flag.IntVar(&podAutoscalerClusterMaxReplicas, "podautoscaler-cluster-max-replicas", 0,
+		"podautoscaler-cluster-max-replicas caps the total replicas of the PodAutoscalers of the cluster, their scale ups are clamped to stay within it. 0 disables the cap.")
+	flag.DurationVar(&orphanSweepInterval, "orphan-sweep-interval", podautoscaler.DefaultOrphanSweepInterval,
+		"orphan-sweep-interval is how often the generated resources no object tracks any more are deleted or adopted after the startup sweep.")
+	flag.BoolVar(&orphanSweepDryRun, "orphan-sweep-dry-run", false,
+		"If set, the sweeps of orphaned generated resources only log what they would delete or adopt.")
+	flag.StringVar(&recommendationRedisAddr, "recommendation-redis-addr", "",
+		"recommendation-redis-addr is the comma-separated host:port of the Redis the PodAutoscalers with the RedisStream recommendation sink publish to, empty disables the sink. "+
+			"The mode, credentials and TLS settings of the REDIS_* environment variables apply.")
+	flag.StringVar(&metricsRedisAddr, "metrics-redis-addr", "",
+		"metrics-redis-addr is the comma-separated host:port of the Redis the redis metric sources of the PodAutoscalers are read from, e.g. the Redis of the gateway, empty disables the sources. "+
+			"The mode, credentials and TLS settings of the REDIS_* environment variables apply.")
+	flag.DurationVar(&modelAdapterUnloadGracePeriod, "model-adapter-unload-grace-period", modeladapter.DefaultUnloadGracePeriod,
+		"model-adapter-unload-grace-period is how long the deletion of a ModelAdapter retries the pods failing to unload it before it proceeds anyway.")
 
 	// Initialize the klog
 	klog.InitFlags(flag.CommandLine)
@@ -174,6 +214,16 @@ func main() {
 	}
 
 	runtimeConfig := config.NewRuntimeConfig(enableRuntimeSidecar, debugMode)
+	runtimeConfig.EventRateLimitInterval = eventRateLimitInterval
+	runtimeConfig.OrphanSweepInterval = orphanSweepInterval
+	runtimeConfig.PodAutoscalerSyncPeriod = podAutoscalerSyncPeriod
+	runtimeConfig.PodAutoscalerMaxConcurrentReconciles = podAutoscalerMaxConcurrentReconciles
+	runtimeConfig.PodAutoscalerReconcileTimeout = podAutoscalerReconcileTimeout
+	runtimeConfig.PodAutoscalerClusterMaxReplicas = int32(podAutoscalerClusterMaxReplicas)
+	runtimeConfig.OrphanSweepDryRun = orphanSweepDryRun
+	runtimeConfig.RecommendationRedisAddr = recommendationRedisAddr
+	runtimeConfig.MetricsRedisAddr = metricsRedisAddr
+	runtimeConfig.ModelAdapterUnloadGracePeriod = modelAdapterUnloadGracePeriod
 
 	webhookServer := webhook.NewServer(webhook.Options{
 		TLSOpts: tlsOpts,
@@ -286,4 +336,9 @@ func setupControllers(mgr ctrl.Manager, runtimeConfig config.RuntimeConfig, cert
 		setupLog.Error(err, "unable to create webhook", "webhook", "Model")
 		os.Exit(1)
 	}
+
+	if err := apiwebhook.SetupPodAutoscalerWebhook(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "PodAutoscaler")
+		os.Exit(1)
+	}
 }
@@ -28,13 +28,15 @@ import (
 )
 
 func main() {
-	redisClient := utils.GetRedisClient()
+	redisClient, err := utils.GetRedisClient()
+	if err != nil {
+		klog.Fatalf("Error connecting to redis: %v", err)
+	}
 
 	klog.Info("starting cache")
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 	var config *rest.Config
-	var err error
 
 	// ref: https://github.com/kubernetes-sigs/controller-runtime/issues/878#issuecomment-1002204308
 	kubeConfig := flag.Lookup("kubeconfig").Value.String()
@@ -52,6 +54,6 @@ func main() {
 	cache.NewCache(config, stopCh, redisClient)
 
 	klog.Info("Starting listening on port 8090")
-	srv := metadata.NewHTTPServer(":8090", redisClient)
+	srv := metadata.NewHTTPServer(":8090", redisClient, stopCh)
 	klog.Fatal(srv.ListenAndServe())
 }
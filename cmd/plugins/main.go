@@ -17,14 +17,18 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -34,28 +38,47 @@ import (
 	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	"github.com/vllm-project/aibrix/pkg/cache"
 	"github.com/vllm-project/aibrix/pkg/plugins/gateway"
+	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
 	"github.com/vllm-project/aibrix/pkg/utils"
 	healthPb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 var (
-	grpc_port int
+	grpc_port            int
+	admin_port           int
+	queue_heartbeat_port int
+	failover_port        int
+	routing_algorithm    string
 )
 
 func main() {
 	flag.IntVar(&grpc_port, "port", 50052, "gRPC port")
+	flag.IntVar(&admin_port, "admin-port", 8081, "admin http port serving the load summary to federated gateways and the gateway metrics, 0 to disable")
+	flag.IntVar(&queue_heartbeat_port, "queue-heartbeat-port", 0, "http port of the proxy streaming heartbeats to the requests waiting in the admission queue, reached by envoy at the POD_IP address, 0 to disable")
+	flag.IntVar(&failover_port, "failover-port", 0, "http port of the proxy failing the routed requests over to the next pod when their pod can not be reached, reached by envoy at the POD_IP address, 0 to disable")
+	flag.StringVar(&routing_algorithm, "routing-algorithm", utils.LoadEnv(gateway.EnvRoutingAlgorithm, ""), "routing strategy of the requests without a routing-strategy header, one of "+strings.Join(routing.Strategies(), ", ")+", empty to leave them to envoy")
 	klog.InitFlags(flag.CommandLine)
 	defer klog.Flush()
 	flag.Parse()
 
-	// Connect to Redis
-	redisClient := utils.GetRedisClient()
+	// Connect to Redis, the gateway starts without it and reconnects lazily once it is available, the readiness
+	// probe failing meanwhile.
+	redisConfig, err := utils.LoadRedisConfig()
+	if err != nil {
+		klog.Fatalf("Invalid redis configuration: %v", err)
+	}
+	redisClient, err := utils.NewRedisClient(redisConfig)
+	if err != nil {
+		klog.Fatalf("Error creating redis client: %v", err)
+	}
+	if err := utils.ConnectRedis(context.Background(), redisClient, redisConfig); err != nil {
+		klog.Errorf("Starting without redis: %v", err)
+	}
 
 	fmt.Println("starting cache")
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 	var config *rest.Config
-	var err error
 
 	// ref: https://github.com/kubernetes-sigs/controller-runtime/issues/878#issuecomment-1002204308
 	kubeConfig := flag.Lookup("kubeconfig").Value.String()
@@ -71,7 +94,22 @@ func main() {
 		panic(err)
 	}
 
-	cache.NewCache(config, stopCh, redisClient)
+	c := cache.NewCache(config, stopCh, redisClient)
+	if err := routing.Init(c, routing.Algorithms(routing_algorithm)); err != nil {
+		klog.Fatalf("Error initializing the routers: %v", err)
+	}
+	if admin_port != 0 {
+		mux := http.NewServeMux()
+		mux.Handle(cache.LoadSummaryPath, c.LoadSummaryHandler(utils.LoadEnv("AIBRIX_CLUSTER_NAME", ""), utils.LoadEnv("AIBRIX_FEDERATION_ENDPOINT", "")))
+		mux.Handle(cache.ModelHealthPath, c.ModelHealthHandler())
+		mux.Handle("/metrics", promhttp.Handler())
+		go func() {
+			klog.Infof("starting admin server on port :%d", admin_port)
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", admin_port), mux); err != nil {
+				klog.Errorf("admin server stopped: %v", err)
+			}
+		}()
+	}
 
 	// Connect to K8s cluster
 	k8sClient, err := kubernetes.NewForConfig(config)
@@ -79,6 +117,11 @@ func main() {
 		klog.Fatalf("Error creating kubernetes client: %v", err)
 	}
 
+	shutdownTracing, err := gateway.InitTracing(context.Background())
+	if err != nil {
+		klog.Fatalf("Error initializing tracing: %v", err)
+	}
+
 	// grpc server init
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpc_port))
 	if err != nil {
@@ -87,8 +130,40 @@ func main() {
 
 	s := grpc.NewServer()
 
-	extProcPb.RegisterExternalProcessorServer(s, gateway.NewServer(redisClient, k8sClient))
-	healthPb.RegisterHealthServer(s, gateway.NewHealthCheckServer())
+	gatewayServer := gateway.NewServer(redisClient, k8sClient, c)
+	extProcPb.RegisterExternalProcessorServer(s, gatewayServer)
+	if queue_heartbeat_port != 0 {
+		podIP := utils.LoadEnv("POD_IP", "")
+		if podIP == "" {
+			klog.Fatal("POD_IP is required by the queue heartbeat proxy")
+		}
+		heartbeats := gatewayServer.EnableQueueHeartbeats(net.JoinHostPort(podIP, fmt.Sprint(queue_heartbeat_port)))
+		go func() {
+			klog.Infof("starting queue heartbeat server on port :%d", queue_heartbeat_port)
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", queue_heartbeat_port), heartbeats); err != nil {
+				klog.Errorf("queue heartbeat server stopped: %v", err)
+			}
+		}()
+	}
+	if failover_port != 0 {
+		podIP := utils.LoadEnv("POD_IP", "")
+		if podIP == "" {
+			klog.Fatal("POD_IP is required by the failover proxy")
+		}
+		failover := gatewayServer.EnableFailover(net.JoinHostPort(podIP, fmt.Sprint(failover_port)))
+		go func() {
+			klog.Infof("starting failover server on port :%d", failover_port)
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", failover_port), failover); err != nil {
+				klog.Errorf("failover server stopped: %v", err)
+			}
+		}()
+	}
+	healthPb.RegisterHealthServer(s, gateway.NewHealthCheckServer(func(ctx context.Context) error {
+		return utils.CheckRedisHealth(ctx, redisClient)
+	}, func(ctx context.Context) error {
+		// the gateway is ready once the router of the default routing strategy is built.
+		return routing.Ready()
+	}))
 
 	klog.Info("starting gRPC server on port :50052")
 
@@ -101,6 +176,14 @@ func main() {
 		klog.Infof("caught sig: %+v", sig)
 		klog.Info("Wait for 1 second to finish processing")
 		time.Sleep(1 * time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := gatewayServer.Shutdown(ctx); err != nil {
+			klog.Errorf("failed to flush accounting records: %v", err)
+		}
+		if err := shutdownTracing(ctx); err != nil {
+			klog.Errorf("failed to flush traces: %v", err)
+		}
+		cancel()
 		os.Exit(0)
 	}()
 
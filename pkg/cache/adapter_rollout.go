@@ -0,0 +1,88 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+// AdapterRollout is the traffic shifting of a model adapter to a new artifact, which the engines serve under a
+// versioned name while the previous artifact keeps the name of the model adapter.
+type AdapterRollout struct {
+	// Version is the name the new artifact is served under.
+	Version string
+	// Percent is the percentage of traffic routed to Version.
+	Percent int32
+}
+
+// addModelAdapterLocked registers the pods of the model adapter under its name and, during a rollout, under the
+// versioned name of the new artifact.
+func (c *Cache) addModelAdapterLocked(model *modelv1alpha1.ModelAdapter) {
+	if c.modelAdapters == nil {
+		c.modelAdapters = map[string]*modelv1alpha1.ModelAdapter{}
+	}
+	c.modelAdapters[model.Name] = model
+	for _, pod := range model.Status.Instances {
+		c.addPodAndModelMappingLocked(pod, model.Name)
+	}
+
+	rollout := model.Status.Rollout
+	if rollout == nil {
+		return
+	}
+	for _, pod := range model.Status.Instances {
+		c.addPodAndModelMappingLocked(pod, rollout.Version)
+	}
+	if c.adapterRollouts == nil {
+		c.adapterRollouts = map[string]AdapterRollout{}
+	}
+	c.adapterRollouts[model.Name] = AdapterRollout{Version: rollout.Version, Percent: rollout.Percent}
+}
+
+func (c *Cache) deleteModelAdapterLocked(model *modelv1alpha1.ModelAdapter) {
+	delete(c.modelAdapters, model.Name)
+	for _, pod := range model.Status.Instances {
+		c.deletePodAndModelMapping(pod, model.Name)
+	}
+
+	rollout := model.Status.Rollout
+	if rollout == nil {
+		return
+	}
+	for _, pod := range model.Status.Instances {
+		c.deletePodAndModelMapping(pod, rollout.Version)
+	}
+	delete(c.adapterRollouts, model.Name)
+}
+
+// GetModelAdapter returns the model adapter of the name, false if the model is not a model adapter.
+func (c *Cache) GetModelAdapter(modelName string) (*modelv1alpha1.ModelAdapter, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	model, ok := c.modelAdapters[modelName]
+	return model, ok
+}
+
+// GetModelAdapterRollout returns the rollout of the model adapter, if it is shifting traffic to a new artifact.
+func (c *Cache) GetModelAdapterRollout(modelName string) (AdapterRollout, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rollout, ok := c.adapterRollouts[modelName]
+	return rollout, ok
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+func newTestModelAdapter(name string, instances []string, rollout *modelv1alpha1.ModelAdapterRollout) *modelv1alpha1.ModelAdapter {
+	return &modelv1alpha1.ModelAdapter{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     modelv1alpha1.ModelAdapterStatus{Instances: instances, Rollout: rollout},
+	}
+}
+
+var _ = Describe("Adapter rollout", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = newTraceCache()
+		c.Pods = map[string]*v1.Pod{}
+		c.PodToModelMapping = map[string]map[string]struct{}{}
+		c.ModelToPodMapping = map[string]map[string]*v1.Pod{}
+		c.addPod(newAutoscaledPod("llama-7b-a", "llama-7b", "llama-7b"))
+	})
+
+	It("should register the versioned name of the new artifact during a rollout", func() {
+		adapter := newTestModelAdapter("lora-1", []string{"llama-7b-a"}, nil)
+		c.addModelAdapter(adapter)
+		_, ok := c.GetModelAdapterRollout("lora-1")
+		Expect(ok).To(BeFalse())
+		Expect(c.ModelToPodMapping).To(HaveKey("lora-1"))
+
+		rolling := adapter.DeepCopy()
+		rolling.Status.Rollout = &modelv1alpha1.ModelAdapterRollout{Version: "lora-1-5f3a9c1e", Percent: 20}
+		c.updateModelAdapter(adapter, rolling)
+		rollout, ok := c.GetModelAdapterRollout("lora-1")
+		Expect(ok).To(BeTrue())
+		Expect(rollout).To(Equal(AdapterRollout{Version: "lora-1-5f3a9c1e", Percent: 20}))
+		Expect(c.ModelToPodMapping["lora-1"]).To(HaveKey("llama-7b-a"))
+		Expect(c.ModelToPodMapping["lora-1-5f3a9c1e"]).To(HaveKey("llama-7b-a"))
+		Expect(c.PodToModelMapping["llama-7b-a"]).To(HaveKey("lora-1-5f3a9c1e"))
+
+		shifted := rolling.DeepCopy()
+		shifted.Status.Rollout.Percent = 60
+		c.updateModelAdapter(rolling, shifted)
+		rollout, _ = c.GetModelAdapterRollout("lora-1")
+		Expect(rollout.Percent).To(Equal(int32(60)))
+
+		completed := shifted.DeepCopy()
+		completed.Status.Rollout = nil
+		c.updateModelAdapter(shifted, completed)
+		_, ok = c.GetModelAdapterRollout("lora-1")
+		Expect(ok).To(BeFalse())
+		Expect(c.ModelToPodMapping).To(HaveKey("lora-1"))
+		Expect(c.ModelToPodMapping).NotTo(HaveKey("lora-1-5f3a9c1e"))
+		Expect(c.PodToModelMapping["llama-7b-a"]).NotTo(HaveKey("lora-1-5f3a9c1e"))
+	})
+
+	It("should forget the rollout of a deleted model adapter", func() {
+		adapter := newTestModelAdapter("lora-1", []string{"llama-7b-a"},
+			&modelv1alpha1.ModelAdapterRollout{Version: "lora-1-5f3a9c1e", Percent: 40})
+		c.addModelAdapter(adapter)
+		_, ok := c.GetModelAdapterRollout("lora-1")
+		Expect(ok).To(BeTrue())
+
+		c.deleteModelAdapter(adapter)
+		_, ok = c.GetModelAdapterRollout("lora-1")
+		Expect(ok).To(BeFalse())
+		Expect(c.ModelToPodMapping).NotTo(HaveKey("lora-1"))
+		Expect(c.ModelToPodMapping).NotTo(HaveKey("lora-1-5f3a9c1e"))
+		Expect(c.ModelToPodMapping).To(HaveKey("llama-7b"))
+	})
+
+	It("should keep the model adapters no pod loaded", func() {
+		adapter := newTestModelAdapter("lora-1", []string{"llama-7b-a"}, nil)
+		c.addModelAdapter(adapter)
+		unloaded := adapter.DeepCopy()
+		unloaded.Status.Instances = nil
+		c.updateModelAdapter(adapter, unloaded)
+		Expect(c.ModelToPodMapping).NotTo(HaveKey("lora-1"))
+		Expect(c.CheckModelExists("lora-1")).To(BeTrue())
+		model, ok := c.GetModelAdapter("lora-1")
+		Expect(ok).To(BeTrue())
+		Expect(model).To(Equal(unloaded))
+
+		c.deleteModelAdapter(unloaded)
+		Expect(c.CheckModelExists("lora-1")).To(BeFalse())
+		_, ok = c.GetModelAdapter("lora-1")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should list the models of the pods and the model adapters", func() {
+		c.addModelAdapter(newTestModelAdapter("lora-2", nil, nil))
+		c.addModelAdapter(newTestModelAdapter("lora-1", []string{"llama-7b-a"}, nil))
+		Expect(c.GetModels()).To(Equal([]string{"llama-7b", "lora-1", "lora-2"}))
+	})
+
+	It("should tell the models missing from the cache apart", func() {
+		_, err := c.GetPodsForModel("mistral-7b")
+		Expect(errors.Is(err, ErrModelNotFound)).To(BeTrue())
+		_, err = c.GetTenantPodsForModel("mistral-7b", "")
+		Expect(errors.Is(err, ErrModelNotFound)).To(BeTrue())
+
+		pods, err := c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pods).To(HaveKey("llama-7b-a"))
+	})
+})
@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+const (
+	// annotations of the PodAutoscaler describing how long it waits before acting on a load change.
+	kpaStableWindowAnnotation  = "kpa.autoscaling.aibrix.ai/stable-window"
+	apaWindowAnnotation        = "apa.autoscaling.aibrix.ai/window"
+	defaultStabilizationWindow = 60 * time.Second
+)
+
+// ModelAutoscalerState is the state of the PodAutoscaler managing the workload serving a model.
+type ModelAutoscalerState struct {
+	Name         string
+	DesiredScale int32
+	MaxReplicas  int32
+	// StabilizationWindow is the time the autoscaler needs to react to a load change.
+	StabilizationWindow time.Duration
+}
+
+// AtMaxScale returns whether the autoscaler can not add more replicas.
+func (s ModelAutoscalerState) AtMaxScale() bool {
+	return s.MaxReplicas > 0 && s.DesiredScale >= s.MaxReplicas
+}
+
+// workloadKey identifies the scale target of a PodAutoscaler, e.g. default/Deployment/llama-7b.
+func workloadKey(namespace, kind, name string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, kind, name)
+}
+
+// getWorkloadKeyForPod returns the key of the workload owning the pod. Pods of deployments are
+// owned by a replicaset whose name is the deployment name suffixed with the pod template hash.
+func getWorkloadKeyForPod(pod *v1.Pod) (string, bool) {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		switch owner.Kind {
+		case "ReplicaSet":
+			hash, ok := pod.Labels["pod-template-hash"]
+			if !ok {
+				return workloadKey(pod.Namespace, owner.Kind, owner.Name), true
+			}
+			return workloadKey(pod.Namespace, "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)), true
+		default:
+			return workloadKey(pod.Namespace, owner.Kind, owner.Name), true
+		}
+	}
+	return "", false
+}
+
+func (c *Cache) addPodAutoscaler(obj interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pa := obj.(*autoscalingv1alpha1.PodAutoscaler)
+	c.PodAutoscalers[workloadKey(pa.Namespace, pa.Spec.ScaleTargetRef.Kind, pa.Spec.ScaleTargetRef.Name)] = pa
+	klog.V(4).Infof("PODAUTOSCALER CREATED: %s/%s", pa.Namespace, pa.Name)
+}
+
+func (c *Cache) updatePodAutoscaler(oldObj interface{}, newObj interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	oldPa := oldObj.(*autoscalingv1alpha1.PodAutoscaler)
+	newPa := newObj.(*autoscalingv1alpha1.PodAutoscaler)
+	delete(c.PodAutoscalers, workloadKey(oldPa.Namespace, oldPa.Spec.ScaleTargetRef.Kind, oldPa.Spec.ScaleTargetRef.Name))
+	workload := workloadKey(newPa.Namespace, newPa.Spec.ScaleTargetRef.Kind, newPa.Spec.ScaleTargetRef.Name)
+	c.PodAutoscalers[workload] = newPa
+
+	// track the scale-up decisions to measure how long the new pods take to become routable.
+	if delta := newPa.Status.DesiredScale - oldPa.Status.DesiredScale; delta > 0 {
+		decidedAt := time.Now()
+		if newPa.Status.LastScaleTime != nil {
+			decidedAt = newPa.Status.LastScaleTime.Time
+		}
+		c.observeScaleUpLocked(workload, delta, decidedAt)
+	} else if delta < 0 {
+		c.observeScaleDownLocked(workload, -delta)
+	}
+	klog.V(4).Infof("PODAUTOSCALER UPDATED: %s/%s desiredScale=%d", newPa.Namespace, newPa.Name, newPa.Status.DesiredScale)
+}
+
+func (c *Cache) deletePodAutoscaler(obj interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pa, ok := obj.(*autoscalingv1alpha1.PodAutoscaler)
+	if !ok {
+		return
+	}
+	workload := workloadKey(pa.Namespace, pa.Spec.ScaleTargetRef.Kind, pa.Spec.ScaleTargetRef.Name)
+	delete(c.PodAutoscalers, workload)
+	delete(c.pendingScaleUps, workload)
+	klog.V(4).Infof("PODAUTOSCALER DELETED: %s/%s", pa.Namespace, pa.Name)
+}
+
+// GetModelAutoscalerState returns the state of the PodAutoscaler managing the pods of the model.
+// When the model is served by several autoscaled workloads, the one with the most headroom is returned.
+func (c *Cache) GetModelAutoscalerState(modelName string) (ModelAutoscalerState, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var res ModelAutoscalerState
+	found := false
+	seen := map[string]struct{}{}
+	for _, pod := range c.ModelToPodMapping[modelName] {
+		key, ok := getWorkloadKeyForPod(pod)
+		if !ok {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		pa, ok := c.PodAutoscalers[key]
+		if !ok {
+			continue
+		}
+		state := ModelAutoscalerState{
+			Name:                pa.Name,
+			DesiredScale:        pa.Status.DesiredScale,
+			MaxReplicas:         pa.Spec.MaxReplicas,
+			StabilizationWindow: getStabilizationWindow(pa),
+		}
+		if !found || state.MaxReplicas-state.DesiredScale > res.MaxReplicas-res.DesiredScale {
+			res = state
+			found = true
+		}
+	}
+	return res, found
+}
+
+func getStabilizationWindow(pa *autoscalingv1alpha1.PodAutoscaler) time.Duration {
+	var annotation string
+	switch pa.Spec.ScalingStrategy {
+	case autoscalingv1alpha1.KPA:
+		annotation = kpaStableWindowAnnotation
+	case autoscalingv1alpha1.APA:
+		annotation = apaWindowAnnotation
+	default:
+		return defaultStabilizationWindow
+	}
+	value, ok := pa.Annotations[annotation]
+	if !ok {
+		return defaultStabilizationWindow
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return defaultStabilizationWindow
+	}
+	return window
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+func newAutoscaledPod(name, model, replicaSet string) *v1.Pod {
+	controller := true
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{modelIdentifier: model, "pod-template-hash": "5d8f7c"},
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: replicaSet + "-5d8f7c", Controller: &controller},
+			},
+		},
+	}
+}
+
+func newTestPodAutoscaler(name, deployment string, desired, maxReplicas int32, annotations map[string]string) *autoscalingv1alpha1.PodAutoscaler {
+	return &autoscalingv1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+		Spec: autoscalingv1alpha1.PodAutoscalerSpec{
+			ScaleTargetRef:  v1.ObjectReference{Kind: "Deployment", Name: deployment},
+			MaxReplicas:     maxReplicas,
+			ScalingStrategy: autoscalingv1alpha1.KPA,
+		},
+		Status: autoscalingv1alpha1.PodAutoscalerStatus{DesiredScale: desired},
+	}
+}
+
+var _ = Describe("Autoscaler state", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = newTraceCache()
+		c.Pods = map[string]*v1.Pod{}
+		c.PodToModelMapping = map[string]map[string]struct{}{}
+		c.ModelToPodMapping = map[string]map[string]*v1.Pod{}
+		c.PodAutoscalers = map[string]*autoscalingv1alpha1.PodAutoscaler{}
+		c.addPod(newAutoscaledPod("llama-7b-a", "llama-7b", "llama-7b"))
+		c.addPod(newAutoscaledPod("llama-7b-b", "llama-7b", "llama-7b"))
+	})
+
+	It("should map the model to the autoscaler of its deployment", func() {
+		_, ok := c.GetModelAutoscalerState("llama-7b")
+		Expect(ok).To(BeFalse())
+
+		c.addPodAutoscaler(newTestPodAutoscaler("llama-7b-kpa", "llama-7b", 4, 4,
+			map[string]string{kpaStableWindowAnnotation: "30s"}))
+		state, ok := c.GetModelAutoscalerState("llama-7b")
+		Expect(ok).To(BeTrue())
+		Expect(state.Name).To(Equal("llama-7b-kpa"))
+		Expect(state.AtMaxScale()).To(BeTrue())
+		Expect(state.StabilizationWindow).To(Equal(30 * time.Second))
+
+		_, ok = c.GetModelAutoscalerState("llama-70b")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should follow updates and deletes of the autoscaler", func() {
+		pa := newTestPodAutoscaler("llama-7b-kpa", "llama-7b", 4, 4, nil)
+		c.addPodAutoscaler(pa)
+
+		updated := pa.DeepCopy()
+		updated.Status.DesiredScale = 2
+		c.updatePodAutoscaler(pa, updated)
+		state, ok := c.GetModelAutoscalerState("llama-7b")
+		Expect(ok).To(BeTrue())
+		Expect(state.AtMaxScale()).To(BeFalse())
+		Expect(state.StabilizationWindow).To(Equal(defaultStabilizationWindow))
+
+		c.deletePodAutoscaler(updated)
+		_, ok = c.GetModelAutoscalerState("llama-7b")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should report the workload with the most headroom", func() {
+		c.addPod(newAutoscaledPod("llama-7b-canary-a", "llama-7b", "llama-7b-canary"))
+		c.addPodAutoscaler(newTestPodAutoscaler("llama-7b-kpa", "llama-7b", 4, 4, nil))
+		c.addPodAutoscaler(newTestPodAutoscaler("llama-7b-canary-kpa", "llama-7b-canary", 1, 2, nil))
+
+		state, ok := c.GetModelAutoscalerState("llama-7b")
+		Expect(ok).To(BeTrue())
+		Expect(state.Name).To(Equal("llama-7b-canary-kpa"))
+		Expect(state.AtMaxScale()).To(BeFalse())
+	})
+})
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -30,6 +31,8 @@ import (
 	"github.com/redis/go-redis/v9"
 	crdinformers "github.com/vllm-project/aibrix/pkg/client/informers/externalversions"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -39,6 +42,7 @@ import (
 
 	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	dto "github.com/prometheus/client_model/go"
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
 	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
 	v1alpha1 "github.com/vllm-project/aibrix/pkg/client/clientset/versioned"
 	v1alpha1scheme "github.com/vllm-project/aibrix/pkg/client/clientset/versioned/scheme"
@@ -52,7 +56,7 @@ var once sync.Once
 // type global
 type Cache struct {
 	mu                sync.RWMutex
-	redisClient       *redis.Client
+	redisClient       redis.UniversalClient
 	prometheusApi     prometheusv1.API
 	initialized       bool
 	subscribers       []metrics.MetricSubscriber
@@ -61,11 +65,34 @@ type Cache struct {
 	Pods              map[string]*v1.Pod
 	PodMetrics        map[string]map[string]metrics.MetricValue            // pod_name: map[metric_name]metric_val
 	PodModelMetrics   map[string]map[string]map[string]metrics.MetricValue // pod_name: map[model_name]map[metric_name]metric_val
+	podMetricTimes    map[string]map[string]time.Time                      // pod_name: map[model_name/metric_name]scrape_time
+	podScrapeTimes    map[string]time.Time                                 // pod_name: time of the last successful scrape
+	podSnapshots      map[string]*PodSnapshot                              // pod_name: metrics committed at the end of the last scrape
+	podMetricTTL      time.Duration                                        // freshness of the pod metrics, 0 if unchecked
+	metricWindows     *podMetricWindows                                    // pod_name: recent samples of the metrics, nil if disabled
+	histogramCounters map[string]map[string]histogramCounters              // pod_name: map[model_name/metric_name]counters at the last scrape
+	histogramDeltas   map[string]map[string]HistogramDelta                 // pod_name: map[model_name/metric_name]increase over the last two scrapes
 	PodToModelMapping map[string]map[string]struct{}                       // pod_name: map[model_name]struct{}
 	ModelToPodMapping map[string]map[string]*v1.Pod                        // model_name: map[pod_name]*v1.Pod
+	podIndex          podIndex                                             // dimension: label_value: map[pod_name]*v1.Pod
+	podPorts          map[string]int32                                     // pod_name: port of the model server
 	requestTrace      *sync.Map                                            // model_name: RequestTrace
 	numRequestsTraces int32                                                // counter for requestTrace
 	pendingRequests   *sync.Map                                            // model_name: *int32
+	remoteSummaries   map[string]remoteLoadSummary                         // remote/peer: LoadSummary
+	PodAutoscalers    map[string]*autoscalingv1alpha1.PodAutoscaler        // namespace/kind/name of the scale target: PodAutoscaler
+	pendingScaleUps   map[string][]time.Time                               // namespace/kind/name of the scale target: scale-up decision per pending replica
+	podReadyLatencies map[string]*latencyHistory                           // model_name: pod ready latency history
+	podFailures       map[string]podFailure                                // pod_name: failure of a pod in a back-off
+	adapterRollouts   map[string]AdapterRollout                            // model_name: rollout of a new adapter artifact
+	modelAdapters     map[string]*modelv1alpha1.ModelAdapter               // model_name: ModelAdapter
+	routingShares     *routingShareTracker                                 // model_name: routed requests per pod
+	concurrencyLimits *concurrencyLimiter                                  // pod_name: adaptive concurrency limit, nil if disabled
+	podQuarantine     *podQuarantine                                       // pod_name: consecutive connection failures
+	scraper           *metrics.Scraper                                     // scrapes the pod metrics, with the configuration of the pod annotations
+
+	modelEndpointSlices map[string]map[string]*discoveryv1.EndpointSlice // model_name: namespace/name: EndpointSlice
+	modelEndpoints      map[string]map[string]int32                      // model_name: pod_name: port of the routable endpoints
 }
 
 type Block struct {
@@ -77,11 +104,14 @@ const (
 	modelIdentifier                       = "model.aibrix.ai/name"
 	nodeType                              = "ray.io/node-type"
 	nodeWorker                            = "worker"
-	podPort                               = 8000
 	defaultPodMetricRefreshIntervalInMS   = 50
 	expireWriteRequestTraceIntervalInMins = 10
 )
 
+// ErrModelNotFound is returned for a model neither a pod nor a model adapter serves, e.g. because the model name
+// of the request is wrong. Callers tell it apart with errors.Is to answer with a not found error.
+var ErrModelNotFound = errors.New("model does not exist in the cache")
+
 var (
 	instance                Cache
 	counterGaugeMetricNames = []string{
@@ -151,7 +181,7 @@ func GetCache() (*Cache, error) {
 	return &instance, nil
 }
 
-func NewCache(config *rest.Config, stopCh <-chan struct{}, redisClient *redis.Client) *Cache {
+func NewCache(config *rest.Config, stopCh <-chan struct{}, redisClient redis.UniversalClient) *Cache {
 	once.Do(func() {
 		if err := v1alpha1scheme.AddToScheme(scheme.Scheme); err != nil {
 			panic(err)
@@ -171,13 +201,15 @@ func NewCache(config *rest.Config, stopCh <-chan struct{}, redisClient *redis.Cl
 		crdFactory := crdinformers.NewSharedInformerFactoryWithOptions(crdClientSet, 0)
 
 		podInformer := factory.Core().V1().Pods().Informer()
+		endpointSliceInformer := factory.Discovery().V1().EndpointSlices().Informer()
 		modelInformer := crdFactory.Model().V1alpha1().ModelAdapters().Informer()
+		podAutoscalerInformer := crdFactory.Autoscaling().V1alpha1().PodAutoscalers().Informer()
 
 		defer runtime.HandleCrash()
 		factory.Start(stopCh)
 		crdFactory.Start(stopCh)
 
-		if !cache.WaitForCacheSync(stopCh, podInformer.HasSynced, modelInformer.HasSynced) {
+		if !cache.WaitForCacheSync(stopCh, podInformer.HasSynced, endpointSliceInformer.HasSynced, modelInformer.HasSynced, podAutoscalerInformer.HasSynced) {
 			runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 			return
 		}
@@ -204,12 +236,34 @@ func NewCache(config *rest.Config, stopCh <-chan struct{}, redisClient *redis.Cl
 			redisClient:       redisClient,
 			prometheusApi:     prometheusApi,
 			Pods:              map[string]*v1.Pod{},
+			podPorts:          map[string]int32{},
 			PodMetrics:        map[string]map[string]metrics.MetricValue{},
 			PodModelMetrics:   map[string]map[string]map[string]metrics.MetricValue{},
+			podMetricTimes:    map[string]map[string]time.Time{},
+			podScrapeTimes:    map[string]time.Time{},
+			podSnapshots:      map[string]*PodSnapshot{},
+			podMetricTTL:      getPodMetricTTL(),
+			metricWindows:     newPodMetricWindows(getPodMetricWindowSamples()),
 			PodToModelMapping: map[string]map[string]struct{}{},
 			ModelToPodMapping: map[string]map[string]*v1.Pod{},
 			requestTrace:      &sync.Map{},
 			pendingRequests:   &sync.Map{},
+			remoteSummaries:   map[string]remoteLoadSummary{},
+			PodAutoscalers:    map[string]*autoscalingv1alpha1.PodAutoscaler{},
+			pendingScaleUps:   map[string][]time.Time{},
+			podReadyLatencies: map[string]*latencyHistory{},
+			podFailures:       map[string]podFailure{},
+			adapterRollouts:   map[string]AdapterRollout{},
+			modelAdapters:     map[string]*modelv1alpha1.ModelAdapter{},
+			routingShares:     newRoutingShareTracker(getRoutingImbalanceConfig()),
+			concurrencyLimits: newConcurrencyLimiterFromEnv(),
+			podQuarantine:     newPodQuarantineFromEnv(),
+			scraper: metrics.NewScraper(func(ctx context.Context, namespace, name string) (*v1.Secret, error) {
+				return k8sClientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+			}),
+
+			modelEndpointSlices: map[string]map[string]*discoveryv1.EndpointSlice{},
+			modelEndpoints:      map[string]map[string]int32{},
 		}
 		if _, err := podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 			AddFunc:    instance.addPod,
@@ -219,6 +273,14 @@ func NewCache(config *rest.Config, stopCh <-chan struct{}, redisClient *redis.Cl
 			panic(err)
 		}
 
+		if _, err = endpointSliceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    instance.addEndpointSlice,
+			UpdateFunc: instance.updateEndpointSlice,
+			DeleteFunc: instance.deleteEndpointSlice,
+		}); err != nil {
+			panic(err)
+		}
+
 		if _, err = modelInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 			AddFunc:    instance.addModelAdapter,
 			UpdateFunc: instance.updateModelAdapter,
@@ -227,6 +289,14 @@ func NewCache(config *rest.Config, stopCh <-chan struct{}, redisClient *redis.Cl
 			panic(err)
 		}
 
+		if _, err = podAutoscalerInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    instance.addPodAutoscaler,
+			UpdateFunc: instance.updatePodAutoscaler,
+			DeleteFunc: instance.deletePodAutoscaler,
+		}); err != nil {
+			panic(err)
+		}
+
 		ticker := time.NewTicker(podMetricRefreshInterval)
 		go func() {
 			for {
@@ -242,6 +312,9 @@ func NewCache(config *rest.Config, stopCh <-chan struct{}, redisClient *redis.Cl
 			}
 		}()
 
+		instance.startFederation(stopCh)
+		instance.startRoutingShareEvaluation(stopCh)
+
 		tickerOffset := time.Duration(time.Now().UnixNano()) % RequestTraceWriteInterval
 		var traceAlignmentTimer *time.Timer
 		// TODO: Using ticker may be a problem if writeRequestTraceToStorage takes too long.
@@ -306,7 +379,10 @@ func (c *Cache) addPod(obj interface{}) {
 	}
 
 	c.Pods[pod.Name] = pod
+	c.podIndex.addPod(pod)
+	c.setPodPortLocked(pod)
 	c.addPodAndModelMappingLocked(pod.Name, modelName)
+	c.observePodFailureLocked(pod, time.Now())
 	klog.V(4).Infof("POD CREATED: %s/%s", pod.Namespace, pod.Name)
 	c.debugInfoLocked()
 }
@@ -327,9 +403,18 @@ func (c *Cache) updatePod(oldObj interface{}, newObj interface{}) {
 
 	// Remove old mappings if present
 	if oldOk {
+		if indexed, ok := c.Pods[oldPod.Name]; ok {
+			c.podIndex.deletePod(indexed)
+		}
 		delete(c.Pods, oldPod.Name)
+		delete(c.podPorts, oldPod.Name)
 		c.deletePodAndModelMapping(oldPod.Name, oldModelName)
 	}
+	// the metrics scraped from the old address of a restarted pod, or of a pod no longer serving a model, are not
+	// the metrics of the pod anymore.
+	if !newOk || oldPod.Status.PodIP != newPod.Status.PodIP {
+		c.evictPodMetricsLocked(oldPod.Name)
+	}
 
 	// ignore worker pods
 	nodeType, ok := oldPod.Labels[nodeType]
@@ -348,7 +433,15 @@ func (c *Cache) updatePod(oldObj interface{}, newObj interface{}) {
 	// Add new mappings if present
 	if newOk {
 		c.Pods[newPod.Name] = newPod
+		c.podIndex.addPod(newPod)
+		c.setPodPortLocked(newPod)
 		c.addPodAndModelMappingLocked(newPod.Name, newModelName)
+		if !isPodRoutable(oldPod) && isPodRoutable(newPod) {
+			c.observePodReadyLocked(newPod, getPodReadyTime(newPod, time.Now()))
+		}
+		c.observePodFailureLocked(newPod, time.Now())
+	} else {
+		delete(c.podFailures, oldPod.Name)
 	}
 
 	klog.V(4).Infof("POD UPDATED: %s/%s %s", newPod.Namespace, newPod.Name, newPod.Status.Phase)
@@ -359,8 +452,16 @@ func (c *Cache) deletePod(obj interface{}) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	pod := obj.(*v1.Pod)
-	_, ok := pod.Labels[modelIdentifier]
+	// the pods deleted while the informer was disconnected are delivered as tombstones.
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		klog.Errorf("unexpected object of a deleted pod: %T", obj)
+		return
+	}
+	_, ok = pod.Labels[modelIdentifier]
 	if !ok {
 		return
 	}
@@ -371,22 +472,53 @@ func (c *Cache) deletePod(obj interface{}) {
 			c.deletePodAndModelMapping(pod.Name, modelName)
 		}
 	}
+	if indexed, ok := c.Pods[pod.Name]; ok {
+		c.podIndex.deletePod(indexed)
+	}
 	delete(c.Pods, pod.Name)
-	delete(c.PodMetrics, pod.Name)
-	delete(c.PodModelMetrics, pod.Name)
+	delete(c.podPorts, pod.Name)
+	c.evictPodMetricsLocked(pod.Name)
+	c.concurrencyLimits.deletePod(pod.Name)
+	c.podQuarantine.deletePod(pod.Name)
+	delete(c.podFailures, pod.Name)
 
 	klog.V(4).Infof("POD DELETED: %s/%s", pod.Namespace, pod.Name)
 	c.debugInfoLocked()
 }
 
+// evictPodMetricsLocked removes the metrics scraped from the pod, and their scrape times.
+func (c *Cache) evictPodMetricsLocked(podName string) {
+	delete(c.PodMetrics, podName)
+	delete(c.PodModelMetrics, podName)
+	delete(c.podMetricTimes, podName)
+	delete(c.podScrapeTimes, podName)
+	delete(c.podSnapshots, podName)
+	delete(c.histogramCounters, podName)
+	delete(c.histogramDeltas, podName)
+	c.metricWindows.deletePod(podName)
+}
+
+// setPodPortLocked resolves the port of the model server of the pod once per pod update, so that the routers and
+// the metric scraping don't parse the pod spec on every request. A pod without a resolvable port is not routable.
+func (c *Cache) setPodPortLocked(pod *v1.Pod) {
+	port, err := utils.GetPodPort(pod)
+	if err != nil {
+		klog.Warningf("skipping pod %s/%s without a resolvable port: %v", pod.Namespace, pod.Name, err)
+		delete(c.podPorts, pod.Name)
+		return
+	}
+	if c.podPorts == nil {
+		c.podPorts = map[string]int32{}
+	}
+	c.podPorts[pod.Name] = port
+}
+
 func (c *Cache) addModelAdapter(obj interface{}) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	model := obj.(*modelv1alpha1.ModelAdapter)
-	for _, pod := range model.Status.Instances {
-		c.addPodAndModelMappingLocked(pod, model.Name)
-	}
+	c.addModelAdapterLocked(model)
 
 	klog.V(4).Infof("MODELADAPTER CREATED: %s/%s", model.Namespace, model.Name)
 	c.debugInfoLocked()
@@ -399,13 +531,8 @@ func (c *Cache) updateModelAdapter(oldObj interface{}, newObj interface{}) {
 	oldModel := oldObj.(*modelv1alpha1.ModelAdapter)
 	newModel := newObj.(*modelv1alpha1.ModelAdapter)
 
-	for _, pod := range oldModel.Status.Instances {
-		c.deletePodAndModelMapping(pod, oldModel.Name)
-	}
-
-	for _, pod := range newModel.Status.Instances {
-		c.addPodAndModelMappingLocked(pod, newModel.Name)
-	}
+	c.deleteModelAdapterLocked(oldModel)
+	c.addModelAdapterLocked(newModel)
 
 	klog.V(4).Infof("MODELADAPTER UPDATED. %s/%s %s", oldModel.Namespace, oldModel.Name, newModel.Status.Phase)
 	c.debugInfoLocked()
@@ -416,9 +543,7 @@ func (c *Cache) deleteModelAdapter(obj interface{}) {
 	defer c.mu.Unlock()
 
 	model := obj.(*modelv1alpha1.ModelAdapter)
-	for _, pod := range model.Status.Instances {
-		c.deletePodAndModelMapping(pod, model.Name)
-	}
+	c.deleteModelAdapterLocked(model)
 
 	klog.V(4).Infof("MODELADAPTER DELETED: %s/%s", model.Namespace, model.Name)
 	c.debugInfoLocked()
@@ -523,33 +648,72 @@ func (c *Cache) GetPod(podName string) (*v1.Pod, error) {
 	return pod, nil
 }
 
+// GetPodPort returns the resolved port of the model server of the pod, false if the pod is not tracked or has no
+// resolvable port.
+func (c *Cache) GetPodPort(podName string) (int32, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	port, ok := c.podPorts[podName]
+	return port, ok
+}
+
+// GetPods returns a copy of the pods tracked by the cache, which the caller may iterate without holding the lock.
+// The terminating pods are not routable and left out.
 func (c *Cache) GetPods() map[string]*v1.Pod {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return c.Pods
+	pods := make(map[string]*v1.Pod, len(c.Pods))
+	for name, pod := range c.Pods {
+		if utils.IsPodTerminating(pod) {
+			continue
+		}
+		pods[name] = pod
+	}
+	return pods
 }
 
 func (c *Cache) GetPodsForModel(modelName string) (map[string]*v1.Pod, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	podsMap, ok := c.ModelToPodMapping[modelName]
-	if !ok {
-		return nil, fmt.Errorf("model does not exist in the cache: %s", modelName)
+	if _, ok := c.ModelToPodMapping[modelName]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrModelNotFound, modelName)
 	}
 
+	pods, err := c.queryLocked(map[string]string{DimensionModel: modelName})
+	if err != nil {
+		return nil, err
+	}
+	// a model behind a Service is only routed to the ready endpoints of the Service.
+	pods = c.filterEndpointPodsLocked(modelName, pods)
+	// the pods serving as many requests as their adaptive concurrency limit allows are not routable.
+	pods = c.concurrencyLimits.filterPods(pods)
+	// the quarantined pods are only routed to when all the pods are quarantined.
+	pods = c.podQuarantine.filterPods(pods, time.Now())
+	podsMap := make(map[string]*v1.Pod, len(pods))
+	for _, pod := range pods {
+		podsMap[pod.Name] = pod
+	}
 	return podsMap, nil
 }
 
+// GetModels returns the sorted names of the models served by a pod, including the model adapters no pod loaded yet.
 func (c *Cache) GetModels() []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	models := []string{}
+	models := make([]string, 0, len(c.ModelToPodMapping)+len(c.modelAdapters))
 	for model := range c.ModelToPodMapping {
 		models = append(models, model)
 	}
+	for model := range c.modelAdapters {
+		if _, ok := c.ModelToPodMapping[model]; !ok {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
 
 	return models
 }
@@ -566,30 +730,41 @@ func (c *Cache) GetModelsForPod(podName string) (map[string]struct{}, error) {
 	return models, nil
 }
 
+// GetModelAdapterCountForPod returns the number of model adapters placed on the pod.
+func (c *Cache) GetModelAdapterCountForPod(podName string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	count := 0
+	for modelName := range c.PodToModelMapping[podName] {
+		if _, ok := c.modelAdapters[modelName]; ok {
+			count++
+		}
+	}
+	return count
+}
+
 func (c *Cache) CheckModelExists(modelName string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	_, ok := c.ModelToPodMapping[modelName]
+	if !ok {
+		// a model adapter no pod loaded yet is served by the pods of its base model.
+		_, ok = c.modelAdapters[modelName]
+	}
 
 	return ok
 }
 
+// GetPodMetric returns the metric of the pod. A metric scraped per model, e.g. the running requests of a pod serving
+// several models, is aggregated across the models of the pod, see GetPodModelMetric for the metric of one model.
 func (c *Cache) GetPodMetric(podName, metricName string) (metrics.MetricValue, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	podMetrics, ok := c.PodMetrics[podName]
-	if !ok {
-		return nil, fmt.Errorf("pod does not exist in the podMetrics cache")
-	}
-
-	metricVal, ok := podMetrics[metricName]
-	if !ok {
-		return nil, fmt.Errorf("no metric available for %v", metricName)
-	}
-
-	return metricVal, nil
+	return lookupPodMetric(c.PodMetrics[podName], c.PodModelMetrics[podName], c.podMetricTimes[podName], c.podMetricTTL,
+		podName, metricName, time.Now())
 }
 
 func (c *Cache) GetPodModelMetric(podName, modelName string, metricName string) (metrics.MetricValue, error) {
@@ -610,6 +785,9 @@ func (c *Cache) GetPodModelMetric(podName, modelName string, metricName string)
 	if !ok {
 		return nil, fmt.Errorf("no metric available for %v", metricName)
 	}
+	if err := c.checkMetricFreshnessLocked(podName, modelName, metricName, time.Now()); err != nil {
+		return nil, err
+	}
 
 	return metricVal, nil
 }
@@ -633,6 +811,9 @@ func (c *Cache) updatePodRecordLocked(podName string, modelName string, metricNa
 	} else {
 		return fmt.Errorf("scope %v is not supported", scope)
 	}
+	now := time.Now()
+	c.setMetricTimeLocked(podName, modelName, metricName, now)
+	c.appendMetricSampleLocked(podName, modelName, metricName, metricValue, now)
 	return nil
 }
 
@@ -655,7 +836,7 @@ func (c *Cache) queryUpdatePromQLMetricsLocked(metric metrics.Metric, queryLabel
 	if err != nil {
 		return fmt.Errorf("failed to update metrics %s from prometheus %s: %v", metricName, podName, err)
 	}
-	klog.V(5).InfoS("Successfully parsed metrics from prometheus", "metric", metricName, "model", modelName, "PodName", podName, "Port", podPort, "metricValue", metricValue)
+	klog.V(5).InfoS("Successfully parsed metrics from prometheus", "metric", metricName, "model", modelName, "PodName", podName, "metricValue", metricValue)
 	return nil
 }
 
@@ -680,17 +861,17 @@ func (c *Cache) updateSimpleMetricFromRawMetricsLocked(pod *v1.Pod, allMetrics m
 
 			metricValue, err := metrics.GetCounterGaugeValue(familyMetric, metricFamily.GetType())
 			if err != nil {
-				klog.V(4).Infof("failed to parse metrics %s from pod %s %s %d: %v", metricName, podName, pod.Status.PodIP, podPort, err)
+				klog.V(4).Infof("failed to parse metrics %s from pod %s %s %d: %v", metricName, podName, pod.Status.PodIP, c.podPorts[pod.Name], err)
 				continue
 			}
 
 			err = c.updatePodRecordLocked(podName, modelName, metricName, scope, &metrics.SimpleMetricValue{Value: metricValue})
 			if err != nil {
-				klog.V(4).Infof("Failed to update metrics %s from pod %s %s %d: %v", metricName, podName, pod.Status.PodIP, podPort, err)
+				klog.V(4).Infof("Failed to update metrics %s from pod %s %s %d: %v", metricName, podName, pod.Status.PodIP, c.podPorts[pod.Name], err)
 				continue
 			}
 
-			klog.V(5).InfoS("Successfully parsed metrics", "metric", metricName, "model", modelName, "PodIP", pod.Status.PodIP, "Port", podPort, "metricValue", metricValue)
+			klog.V(5).InfoS("Successfully parsed metrics", "metric", metricName, "model", modelName, "PodIP", pod.Status.PodIP, "Port", c.podPorts[pod.Name], "metricValue", metricValue)
 		}
 	}
 }
@@ -714,7 +895,7 @@ func (c *Cache) updateHistogramMetricFromRawMetricsLocked(pod *v1.Pod, allMetric
 			modelName, _ := metrics.GetLabelValueForKey(familyMetric, "model_name")
 			metricValue, err := metrics.GetHistogramValue(familyMetric)
 			if err != nil {
-				klog.V(4).Infof("failed to parse metrics %s from pod %s %s %d: %v", metricName, pod.Name, pod.Status.PodIP, podPort, err)
+				klog.V(4).Infof("failed to parse metrics %s from pod %s %s %d: %v", metricName, pod.Name, pod.Status.PodIP, c.podPorts[pod.Name], err)
 				continue
 			}
 
@@ -725,11 +906,12 @@ func (c *Cache) updateHistogramMetricFromRawMetricsLocked(pod *v1.Pod, allMetric
 			}
 			err = c.updatePodRecordLocked(podName, modelName, metricName, scope, histogramValue)
 			if err != nil {
-				klog.V(4).Infof("Failed to update metrics %s from pod %s %s %d: %v", metricName, podName, pod.Status.PodIP, podPort, err)
+				klog.V(4).Infof("Failed to update metrics %s from pod %s %s %d: %v", metricName, podName, pod.Status.PodIP, c.podPorts[pod.Name], err)
 				continue
 			}
+			c.updateHistogramDeltaLocked(podName, modelName, metricName, histogramValue)
 
-			klog.V(5).InfoS("Successfully parsed metrics", "metric", metricName, "model", modelName, "PodIP", pod.Status.PodIP, "Port", podPort, "metricValue", metricValue)
+			klog.V(5).InfoS("Successfully parsed metrics", "metric", metricName, "model", modelName, "PodIP", pod.Status.PodIP, "Port", c.podPorts[pod.Name], "metricValue", metricValue)
 
 		}
 	}
@@ -756,11 +938,11 @@ func (c *Cache) updateQueryLabelMetricFromRawMetricsLocked(pod *v1.Pod, allMetri
 			labelValue, _ := metrics.GetLabelValueForKey(familyMetric, labelMetricName)
 			err := c.updatePodRecordLocked(podName, modelName, labelMetricName, scope, &metrics.LabelValueMetricValue{Value: labelValue})
 			if err != nil {
-				klog.V(4).Infof("Failed to update metrics %s from pod %s %s %d: %v", labelMetricName, podName, pod.Status.PodIP, podPort, err)
+				klog.V(4).Infof("Failed to update metrics %s from pod %s %s %d: %v", labelMetricName, podName, pod.Status.PodIP, c.podPorts[pod.Name], err)
 				continue
 			}
 
-			klog.V(5).InfoS("Successfully parsed metrics", "metric", labelMetricName, "model", modelName, "PodIP", pod.Status.PodIP, "Port", podPort, "metricValue", labelValue)
+			klog.V(5).InfoS("Successfully parsed metrics", "metric", labelMetricName, "model", modelName, "PodIP", pod.Status.PodIP, "Port", c.podPorts[pod.Name], "metricValue", labelValue)
 		}
 	}
 }
@@ -770,7 +952,7 @@ func (c *Cache) updateMetricFromPromQLLocked(pod *v1.Pod) {
 
 	for _, metricName := range prometheusMetricNames {
 		queryLabels := map[string]string{
-			"instance": fmt.Sprintf("%s:%d", pod.Status.PodIP, podPort),
+			"instance": fmt.Sprintf("%s:%d", pod.Status.PodIP, c.podPorts[pod.Name]),
 		}
 		metric, ok := metrics.Metrics[metricName]
 		if !ok {
@@ -814,6 +996,10 @@ func (c *Cache) updatePodMetrics() {
 
 	for _, pod := range readyPods {
 		podName := pod.Name
+		podPort, ok := c.podPorts[podName]
+		if !ok {
+			continue
+		}
 		if len(c.PodMetrics[podName]) == 0 {
 			c.PodMetrics[podName] = map[string]metrics.MetricValue{}
 		}
@@ -822,10 +1008,14 @@ func (c *Cache) updatePodMetrics() {
 		}
 
 		// We should use the primary container port. In the future, we can decide whether to use sidecar container's port
-		url := fmt.Sprintf("http://%s:%d/metrics", pod.Status.PodIP, podPort)
-		allMetrics, err := metrics.ParseMetricsURL(url)
+		allMetrics, err := c.scrapePodMetrics(pod, podPort)
 		if err != nil {
 			klog.V(4).Infof("Error parsing metric families: %v\n", err)
+		} else {
+			if c.podScrapeTimes == nil {
+				c.podScrapeTimes = map[string]time.Time{}
+			}
+			c.podScrapeTimes[podName] = time.Now()
 		}
 
 		// parse counterGaugeMetricsNames
@@ -839,11 +1029,35 @@ func (c *Cache) updatePodMetrics() {
 
 		if c.prometheusApi == nil {
 			klog.V(4).InfoS("Prometheus api is not initialized, PROMETHEUS_ENDPOINT is not configured, skip fetching prometheus metrics")
-			continue
+		} else {
+			// parse prometheus metrics
+			c.updateMetricFromPromQLLocked(pod)
 		}
-		// parse prometheus metrics
-		c.updateMetricFromPromQLLocked(pod)
+
+		c.commitPodSnapshotLocked(podName)
+	}
+}
+
+// defaultScraper scrapes the pods of the caches built without a scraper, which can't read the Secrets of the tokens.
+var defaultScraper = metrics.NewScraper(nil)
+
+// scrapePodMetrics scrapes the metrics endpoint of the pod on the port, with the scheme, credentials and headers of
+// the annotations of the pod.
+func (c *Cache) scrapePodMetrics(pod *v1.Pod, port int32) (map[string]*dto.MetricFamily, error) {
+	config, err := metrics.ScrapeConfigForPod(pod, "http")
+	if err != nil {
+		return map[string]*dto.MetricFamily{}, err
+	}
+	scraper := c.scraper
+	if scraper == nil {
+		scraper = defaultScraper
+	}
+	body, err := scraper.Get(context.Background(), config, fmt.Sprintf("%s:%d", pod.Status.PodIP, port), "metrics")
+	if err != nil {
+		// a pod rejecting the credentials is not scraped again before metrics.ScrapeAuthFailureBackoff.
+		return map[string]*dto.MetricFamily{}, err
 	}
+	return metrics.ParseMetricFamilies(body)
 }
 
 func (c *Cache) updateModelMetrics() {
@@ -0,0 +1,315 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	defaultConcurrencyLimitMin              = 1
+	defaultConcurrencyLimitMax              = 128
+	defaultConcurrencyLimitInitial          = 16
+	defaultConcurrencyLimitLatencyTolerance = 2.0
+	defaultConcurrencyLimitBackoff          = 0.5
+
+	// concurrencyLimitBaselineWindow is how long the lowest time to first token of a pod holds as its baseline, so
+	// the baseline follows a pod whose latency settles at a new level, e.g. after the engine was reconfigured.
+	concurrencyLimitBaselineWindow = time.Minute
+)
+
+var podConcurrencyLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "aibrix_gateway_pod_concurrency_limit",
+	Help: "Adaptive limit of the requests the gateway routes to a pod concurrently.",
+}, []string{"pod"})
+
+func init() {
+	prometheus.MustRegister(podConcurrencyLimit)
+}
+
+// concurrencyLimitConfig configures the adaptive concurrency limit of the pods.
+type concurrencyLimitConfig struct {
+	min     float64
+	max     float64
+	initial float64
+	// tolerance is the ratio of the time to first token to the baseline of the pod above which the latency of the
+	// pod is degraded.
+	tolerance float64
+	// backoff is the factor a degraded latency or a failed request multiplies the limit by.
+	backoff float64
+}
+
+// getConcurrencyLimitConfig reads the adaptive concurrency limit from the environment, it returns false unless
+// AIBRIX_CONCURRENCY_LIMIT_ENABLED is set.
+func getConcurrencyLimitConfig() (concurrencyLimitConfig, bool) {
+	config := concurrencyLimitConfig{
+		min:       defaultConcurrencyLimitMin,
+		max:       defaultConcurrencyLimitMax,
+		tolerance: defaultConcurrencyLimitLatencyTolerance,
+		backoff:   defaultConcurrencyLimitBackoff,
+	}
+	if enabled, err := strconv.ParseBool(utils.LoadEnv("AIBRIX_CONCURRENCY_LIMIT_ENABLED", "false")); err != nil || !enabled {
+		return config, false
+	}
+	if value := utils.LoadEnv("AIBRIX_CONCURRENCY_LIMIT_MIN", ""); value != "" {
+		if intValue, err := strconv.Atoi(value); err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_CONCURRENCY_LIMIT_MIN: %s, falling back to default", value)
+		} else {
+			config.min = float64(intValue)
+		}
+	}
+	if value := utils.LoadEnv("AIBRIX_CONCURRENCY_LIMIT_MAX", ""); value != "" {
+		if intValue, err := strconv.Atoi(value); err != nil || float64(intValue) < config.min {
+			klog.Infof("invalid AIBRIX_CONCURRENCY_LIMIT_MAX: %s, falling back to default", value)
+		} else {
+			config.max = float64(intValue)
+		}
+	}
+	if config.max < config.min {
+		config.max = config.min
+	}
+	if value := utils.LoadEnv("AIBRIX_CONCURRENCY_LIMIT_LATENCY_TOLERANCE", ""); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err != nil || floatValue <= 1 {
+			klog.Infof("invalid AIBRIX_CONCURRENCY_LIMIT_LATENCY_TOLERANCE: %s, falling back to default", value)
+		} else {
+			config.tolerance = floatValue
+		}
+	}
+	if value := utils.LoadEnv("AIBRIX_CONCURRENCY_LIMIT_BACKOFF", ""); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err != nil || floatValue <= 0 || floatValue >= 1 {
+			klog.Infof("invalid AIBRIX_CONCURRENCY_LIMIT_BACKOFF: %s, falling back to default", value)
+		} else {
+			config.backoff = floatValue
+		}
+	}
+	config.initial = math.Max(config.min, math.Min(config.max, defaultConcurrencyLimitInitial))
+	return config, true
+}
+
+// podConcurrency is the adaptive concurrency limit of a pod.
+type podConcurrency struct {
+	limit    float64
+	inflight int
+	// baseline is the lowest time to first token of the pod within the previous baseline window, and windowMin the
+	// lowest one within the current window, which started at windowStart.
+	baseline    time.Duration
+	windowMin   time.Duration
+	windowStart time.Time
+	// decreasedAt is when the limit was last cut. The requests already in flight by then observed the load before
+	// the cut, they do not cut the limit again.
+	decreasedAt time.Time
+}
+
+// baselineAt returns the time to first token of the pod when it is not overloaded, the lowest one within the
+// current and the previous baseline window. It returns 0 if the pod has no samples within them.
+func (p *podConcurrency) baselineAt(now time.Time) time.Duration {
+	if elapsed := now.Sub(p.windowStart); elapsed >= 2*concurrencyLimitBaselineWindow {
+		p.baseline, p.windowMin, p.windowStart = 0, 0, now
+	} else if elapsed >= concurrencyLimitBaselineWindow {
+		p.baseline, p.windowMin, p.windowStart = p.windowMin, 0, now
+	}
+	if p.baseline == 0 || (p.windowMin > 0 && p.windowMin < p.baseline) {
+		return p.windowMin
+	}
+	return p.baseline
+}
+
+// allowed returns the requests the pod may serve concurrently.
+func (p *podConcurrency) allowed() int {
+	return int(p.limit)
+}
+
+// limitedRequest is a request in flight on a pod.
+type limitedRequest struct {
+	pod        string
+	start      time.Time
+	firstToken bool
+}
+
+// concurrencyLimiter adapts the concurrency limit of every pod from its time to first token and failed requests:
+// the limit grows by one per limit of requests served under the latency tolerance of the baseline, and is
+// multiplied by the backoff once per round trip when the latency degrades or requests fail.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	config   concurrencyLimitConfig
+	pods     map[string]*podConcurrency // pod_name: concurrency limit
+	requests map[string]*limitedRequest // request_id: request in flight
+}
+
+func newConcurrencyLimiter(config concurrencyLimitConfig) *concurrencyLimiter {
+	return &concurrencyLimiter{config: config, pods: map[string]*podConcurrency{}, requests: map[string]*limitedRequest{}}
+}
+
+// newConcurrencyLimiterFromEnv returns nil if the adaptive concurrency limit is disabled.
+func newConcurrencyLimiterFromEnv() *concurrencyLimiter {
+	config, enabled := getConcurrencyLimitConfig()
+	if !enabled {
+		return nil
+	}
+	klog.InfoS("adaptive concurrency limit enabled", "min", config.min, "max", config.max,
+		"latencyTolerance", config.tolerance, "backoff", config.backoff)
+	return newConcurrencyLimiter(config)
+}
+
+func (l *concurrencyLimiter) getPodLocked(podName string) *podConcurrency {
+	pod, ok := l.pods[podName]
+	if !ok {
+		pod = &podConcurrency{limit: l.config.initial}
+		l.pods[podName] = pod
+		podConcurrencyLimit.WithLabelValues(podName).Set(float64(pod.allowed()))
+	}
+	return pod
+}
+
+// start records the request routed to the pod.
+func (l *concurrencyLimiter) start(requestID, podName string, now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.requests[requestID]; ok {
+		return
+	}
+	l.requests[requestID] = &limitedRequest{pod: podName, start: now}
+	l.getPodLocked(podName).inflight++
+}
+
+// observeFirstToken samples the time to first token of the request, only its first call counts.
+func (l *concurrencyLimiter) observeFirstToken(requestID string, now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	req, ok := l.requests[requestID]
+	if !ok || req.firstToken {
+		return
+	}
+	req.firstToken = true
+	pod, ok := l.pods[req.pod]
+	if !ok {
+		return
+	}
+
+	latency := now.Sub(req.start)
+	if baseline := pod.baselineAt(now); baseline > 0 && float64(latency) > float64(baseline)*l.config.tolerance {
+		l.decreaseLocked(req.pod, pod, req.start, now, "latency degraded")
+	} else if float64(pod.inflight) >= pod.limit/2 {
+		// only a limit the pod is close to is known to hold, an idle pod does not probe a higher one.
+		l.setLimitLocked(req.pod, pod, pod.limit+1/pod.limit)
+	}
+	if pod.windowMin == 0 || latency < pod.windowMin {
+		pod.windowMin = latency
+	}
+}
+
+// done releases the request, a failed one cuts the limit of its pod.
+func (l *concurrencyLimiter) done(requestID string, failed bool, now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	req, ok := l.requests[requestID]
+	if !ok {
+		return
+	}
+	delete(l.requests, requestID)
+	pod, ok := l.pods[req.pod]
+	if !ok || pod.inflight == 0 {
+		return
+	}
+	pod.inflight--
+	if failed {
+		l.decreaseLocked(req.pod, pod, req.start, now, "request failed")
+	}
+}
+
+func (l *concurrencyLimiter) decreaseLocked(podName string, pod *podConcurrency, start, now time.Time, reason string) {
+	if start.Before(pod.decreasedAt) {
+		return
+	}
+	pod.decreasedAt = now
+	l.setLimitLocked(podName, pod, pod.limit*l.config.backoff)
+	klog.V(4).InfoS("concurrency limit decreased", "pod", podName, "reason", reason, "limit", pod.allowed(),
+		"inflight", pod.inflight, "baseline", pod.baselineAt(now))
+}
+
+func (l *concurrencyLimiter) setLimitLocked(podName string, pod *podConcurrency, limit float64) {
+	limit = math.Max(l.config.min, math.Min(l.config.max, limit))
+	previous := pod.allowed()
+	pod.limit = limit
+	if pod.allowed() != previous {
+		podConcurrencyLimit.WithLabelValues(podName).Set(float64(pod.allowed()))
+	}
+}
+
+// filterPods drops the pods which serve as many requests as their limit allows.
+func (l *concurrencyLimiter) filterPods(pods []*v1.Pod) []*v1.Pod {
+	if l == nil {
+		return pods
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	res := make([]*v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if state, ok := l.pods[pod.Name]; ok && state.inflight >= state.allowed() {
+			continue
+		}
+		res = append(res, pod)
+	}
+	return res
+}
+
+// deletePod forgets the concurrency limit of a deleted pod, its requests in flight are released by their end.
+func (l *concurrencyLimiter) deletePod(podName string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.pods[podName]; ok {
+		delete(l.pods, podName)
+		podConcurrencyLimit.DeleteLabelValues(podName)
+	}
+}
+
+// AddPodRequest counts the request routed to the pod against the adaptive concurrency limit of the pod.
+func (c *Cache) AddPodRequest(requestID, podName string) {
+	c.concurrencyLimits.start(requestID, podName, time.Now())
+}
+
+// ObservePodFirstToken samples the time to first token of the request for the concurrency limit of its pod.
+func (c *Cache) ObservePodFirstToken(requestID string) {
+	c.concurrencyLimits.observeFirstToken(requestID, time.Now())
+}
+
+// DonePodRequest releases the request from the concurrency limit of its pod, a failed request cuts the limit. It
+// is a no-op for a request which was already released.
+func (c *Cache) DonePodRequest(requestID string, failed bool) {
+	c.concurrencyLimits.done(requestID, failed, time.Now())
+}
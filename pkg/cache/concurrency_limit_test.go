@@ -0,0 +1,164 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Concurrency limit", func() {
+	var l *concurrencyLimiter
+	var now time.Time
+	var requests int
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	baseTTFT := 100 * time.Millisecond
+
+	// serve replays a round trip of the pod at its limit: the gateway routes requests to the pod until it is
+	// filtered out, and the time to first token of the requests grows linearly once they exceed the capacity of
+	// the pod. It returns the requests served.
+	serve := func(podName string, capacity int) int {
+		pod := newAutoscaledPod(podName, "llama-7b", "llama-7b")
+		var ids []string
+		for len(l.filterPods([]*v1.Pod{pod})) > 0 {
+			requests++
+			id := fmt.Sprintf("request-%d", requests)
+			l.start(id, podName, now)
+			ids = append(ids, id)
+		}
+		now = now.Add(time.Duration(float64(baseTTFT) * math.Max(1, float64(len(ids))/float64(capacity))))
+		for _, id := range ids {
+			l.observeFirstToken(id, now)
+		}
+		for _, id := range ids {
+			l.done(id, false, now)
+		}
+		return len(ids)
+	}
+	limit := func(podName string) int {
+		return l.pods[podName].allowed()
+	}
+
+	BeforeEach(func() {
+		l = newConcurrencyLimiter(concurrencyLimitConfig{min: 1, max: 128, initial: 16, tolerance: 2, backoff: 0.5})
+		now = base
+		requests = 0
+	})
+
+	It("should converge to the latency tolerance of the pod", func() {
+		for round := 0; round < 30; round++ {
+			serve("llama-7b-0", 20)
+		}
+		// the limit probes up to the load doubling the time to first token and backs off by half.
+		for round := 0; round < 100; round++ {
+			served := serve("llama-7b-0", 20)
+			Expect(served).To(BeNumerically(">=", 20))
+			Expect(served).To(BeNumerically("<=", 41))
+			Expect(limit("llama-7b-0")).To(BeNumerically(">=", 20))
+			Expect(limit("llama-7b-0")).To(BeNumerically("<=", 41))
+		}
+	})
+
+	It("should back off once per round trip and recover with the pod", func() {
+		for round := 0; round < 30; round++ {
+			serve("llama-7b-0", 20)
+		}
+
+		// the capacity of the pod drops, every round trip halves the limit until the latency is tolerated.
+		limits := []int{}
+		for round := 0; round < 4; round++ {
+			serve("llama-7b-0", 4)
+			limits = append(limits, limit("llama-7b-0"))
+		}
+		for i := 1; i < len(limits); i++ {
+			Expect(limits[i]).To(BeNumerically(">=", limits[i-1]/2))
+		}
+		for round := 0; round < 50; round++ {
+			serve("llama-7b-0", 4)
+			Expect(limit("llama-7b-0")).To(BeNumerically("<=", 9))
+			Expect(limit("llama-7b-0")).To(BeNumerically(">=", 4))
+		}
+
+		// the capacity recovers, the limit grows back by one per round trip.
+		rounds := 0
+		for limit("llama-7b-0") < 20 {
+			serve("llama-7b-0", 20)
+			rounds++
+			Expect(rounds).To(BeNumerically("<", 20))
+		}
+	})
+
+	It("should cut the limit once per round trip of failed requests", func() {
+		pod := l.getPodLocked("llama-7b-0")
+		for i := 0; i < 16; i++ {
+			l.start(fmt.Sprintf("request-%d", i), "llama-7b-0", base)
+		}
+		for i := 0; i < 16; i++ {
+			l.done(fmt.Sprintf("request-%d", i), true, base.Add(time.Second))
+		}
+		Expect(pod.allowed()).To(Equal(8))
+		Expect(pod.inflight).To(BeZero())
+
+		// the failures of the next round trips cut the limit again, down to the min.
+		for round := 2; round < 10; round++ {
+			at := base.Add(time.Duration(round) * time.Second)
+			l.start("failed", "llama-7b-0", at)
+			l.done("failed", true, at.Add(time.Second))
+		}
+		Expect(pod.allowed()).To(Equal(1))
+		Expect(l.requests).To(BeEmpty())
+	})
+
+	It("should exclude the pods at their limit", func() {
+		l = newConcurrencyLimiter(concurrencyLimitConfig{min: 1, max: 2, initial: 2, tolerance: 2, backoff: 0.5})
+		pods := []*v1.Pod{newAutoscaledPod("llama-7b-0", "llama-7b", "llama-7b"), newAutoscaledPod("llama-7b-1", "llama-7b", "llama-7b")}
+		l.start("request-0", "llama-7b-0", base)
+		Expect(l.filterPods(pods)).To(HaveLen(2))
+		l.start("request-1", "llama-7b-0", base)
+		filtered := l.filterPods(pods)
+		Expect(filtered).To(HaveLen(1))
+		Expect(filtered[0].Name).To(Equal("llama-7b-1"))
+
+		// a request is released once, whichever end of the request comes first.
+		l.done("request-1", false, base)
+		l.done("request-1", true, base)
+		Expect(l.filterPods(pods)).To(HaveLen(2))
+		Expect(l.pods["llama-7b-0"].allowed()).To(Equal(2))
+
+		// a disabled limiter routes to every pod.
+		var disabled *concurrencyLimiter
+		disabled.start("request-2", "llama-7b-0", base)
+		Expect(disabled.filterPods(pods)).To(HaveLen(2))
+	})
+
+	It("should export the limit of the pods", func() {
+		for round := 0; round < 5; round++ {
+			serve("llama-7b-0", 20)
+		}
+		Expect(testutil.ToFloat64(podConcurrencyLimit.WithLabelValues("llama-7b-0"))).To(BeNumerically("==", limit("llama-7b-0")))
+
+		l.deletePod("llama-7b-0")
+		Expect(l.pods).NotTo(HaveKey("llama-7b-0"))
+		Expect(testutil.CollectAndCount(podConcurrencyLimit)).To(BeZero())
+	})
+})
@@ -0,0 +1,185 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const defaultModelPortName = "serve"
+
+// modelPortName is the name of the service port the requests of a model are routed to.
+var modelPortName = utils.LoadEnv("AIBRIX_MODEL_PORT_NAME", defaultModelPortName)
+
+// The backends of a model are discovered from the EndpointSlices of its Service, when the Service carries the model
+// label which the EndpointSlice controller mirrors onto its slices. Only the ready and not terminating endpoints are
+// routable, so the gateway follows the readiness the Service sees and the named port of the model. A model without
+// such a Service, e.g. a LoRA adapter, falls back to the pods labelled with the model.
+
+func (c *Cache) addEndpointSlice(obj interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slice := obj.(*discoveryv1.EndpointSlice)
+	c.addEndpointSliceLocked(slice)
+
+	klog.V(4).Infof("ENDPOINTSLICE CREATED: %s/%s", slice.Namespace, slice.Name)
+}
+
+func (c *Cache) updateEndpointSlice(oldObj interface{}, newObj interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	oldSlice := oldObj.(*discoveryv1.EndpointSlice)
+	newSlice := newObj.(*discoveryv1.EndpointSlice)
+
+	c.deleteEndpointSliceLocked(oldSlice)
+	c.addEndpointSliceLocked(newSlice)
+
+	klog.V(4).Infof("ENDPOINTSLICE UPDATED: %s/%s", newSlice.Namespace, newSlice.Name)
+}
+
+func (c *Cache) deleteEndpointSlice(obj interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if slice, ok = tombstone.Obj.(*discoveryv1.EndpointSlice); !ok {
+			return
+		}
+	}
+	c.deleteEndpointSliceLocked(slice)
+
+	klog.V(4).Infof("ENDPOINTSLICE DELETED: %s/%s", slice.Namespace, slice.Name)
+}
+
+func (c *Cache) addEndpointSliceLocked(slice *discoveryv1.EndpointSlice) {
+	modelName, ok := slice.Labels[modelIdentifier]
+	if !ok {
+		return
+	}
+	if c.modelEndpointSlices == nil {
+		c.modelEndpointSlices = map[string]map[string]*discoveryv1.EndpointSlice{}
+	}
+	slices, ok := c.modelEndpointSlices[modelName]
+	if !ok {
+		slices = map[string]*discoveryv1.EndpointSlice{}
+		c.modelEndpointSlices[modelName] = slices
+	}
+	slices[slice.Namespace+"/"+slice.Name] = slice
+	c.updateModelEndpointsLocked(modelName)
+}
+
+func (c *Cache) deleteEndpointSliceLocked(slice *discoveryv1.EndpointSlice) {
+	modelName, ok := slice.Labels[modelIdentifier]
+	if !ok {
+		return
+	}
+	slices := c.modelEndpointSlices[modelName]
+	delete(slices, slice.Namespace+"/"+slice.Name)
+	if len(slices) == 0 {
+		delete(c.modelEndpointSlices, modelName)
+	}
+	c.updateModelEndpointsLocked(modelName)
+}
+
+// updateModelEndpointsLocked rebuilds the routable pods of the model from its EndpointSlices, pod_name: port.
+func (c *Cache) updateModelEndpointsLocked(modelName string) {
+	slices, ok := c.modelEndpointSlices[modelName]
+	if !ok {
+		delete(c.modelEndpoints, modelName)
+		return
+	}
+
+	endpoints := map[string]int32{}
+	for _, slice := range slices {
+		port, ok := resolveEndpointPort(slice.Ports, modelPortName)
+		if !ok {
+			klog.V(4).InfoS("no port of the model on the endpoint slice", "model", modelName, "slice", slice.Name, "portName", modelPortName)
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" || !isEndpointRoutable(endpoint) {
+				continue
+			}
+			endpoints[endpoint.TargetRef.Name] = port
+		}
+	}
+	if c.modelEndpoints == nil {
+		c.modelEndpoints = map[string]map[string]int32{}
+	}
+	c.modelEndpoints[modelName] = endpoints
+}
+
+// isEndpointRoutable reports whether the endpoint accepts new requests. A nil ready condition means ready, and a
+// terminating endpoint is never routable even if it still serves its in-flight requests.
+func isEndpointRoutable(endpoint discoveryv1.Endpoint) bool {
+	conditions := endpoint.Conditions
+	if conditions.Terminating != nil && *conditions.Terminating {
+		return false
+	}
+	return conditions.Ready == nil || *conditions.Ready
+}
+
+// resolveEndpointPort returns the port of the given name, or the only port of the slice if it has a single one.
+func resolveEndpointPort(ports []discoveryv1.EndpointPort, name string) (int32, bool) {
+	for _, port := range ports {
+		if port.Port != nil && port.Name != nil && *port.Name == name {
+			return *port.Port, true
+		}
+	}
+	if len(ports) == 1 && ports[0].Port != nil {
+		return *ports[0].Port, true
+	}
+	return 0, false
+}
+
+// filterEndpointPodsLocked keeps the pods which are routable endpoints of the model's Service. The pods are returned
+// as is if the model has no Service.
+func (c *Cache) filterEndpointPodsLocked(modelName string, pods []*v1.Pod) []*v1.Pod {
+	endpoints, ok := c.modelEndpoints[modelName]
+	if !ok {
+		return pods
+	}
+	res := make([]*v1.Pod, 0, len(endpoints))
+	for _, pod := range pods {
+		if _, ok := endpoints[pod.Name]; ok {
+			res = append(res, pod)
+		}
+	}
+	return res
+}
+
+// GetEndpointPort returns the port the requests of the model are routed to on the pod, resolved from the named port
+// of the model's Service. It returns false if the model has no Service or the pod is not a routable endpoint of it.
+func (c *Cache) GetEndpointPort(modelName, podName string) (int32, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	port, ok := c.modelEndpoints[modelName][podName]
+	return port, ok
+}
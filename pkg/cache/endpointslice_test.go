@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func newTestEndpoint(podName string, ready, terminating *bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses:  []string{"10.0.0.1"},
+		Conditions: discoveryv1.EndpointConditions{Ready: ready, Terminating: terminating},
+		TargetRef:  &v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: podName},
+	}
+}
+
+func newTestEndpointSlice(name, model string, ports []discoveryv1.EndpointPort, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{modelIdentifier: model, discoveryv1.LabelServiceName: model},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       ports,
+		Endpoints:   endpoints,
+	}
+}
+
+var _ = Describe("EndpointSlice discovery", func() {
+	var c *Cache
+	servePorts := []discoveryv1.EndpointPort{
+		{Name: ptr.To("metrics"), Port: ptr.To(int32(8080))},
+		{Name: ptr.To(defaultModelPortName), Port: ptr.To(int32(8001))},
+	}
+
+	podNames := func(pods map[string]*v1.Pod) []string {
+		names := []string{}
+		for name := range pods {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		c = newTraceCache()
+		c.Pods = map[string]*v1.Pod{}
+		c.PodToModelMapping = map[string]map[string]struct{}{}
+		c.ModelToPodMapping = map[string]map[string]*v1.Pod{}
+		for _, name := range []string{"llama-7b-0", "llama-7b-1", "llama-7b-2", "llama-7b-3"} {
+			c.addPod(newAutoscaledPod(name, "llama-7b", "llama-7b"))
+		}
+	})
+
+	It("should fall back to the pods of the model without a Service", func() {
+		pods, err := c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pods).To(HaveLen(4))
+		_, ok := c.GetEndpointPort("llama-7b", "llama-7b-0")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should only route to the ready and not terminating endpoints", func() {
+		slice := newTestEndpointSlice("llama-7b-abcde", "llama-7b", servePorts,
+			newTestEndpoint("llama-7b-0", ptr.To(true), ptr.To(false)),
+			newTestEndpoint("llama-7b-1", ptr.To(false), ptr.To(false)),
+			newTestEndpoint("llama-7b-2", ptr.To(false), ptr.To(true)),
+			newTestEndpoint("llama-7b-3", nil, nil),
+		)
+		c.addEndpointSlice(slice)
+
+		pods, err := c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(podNames(pods)).To(ConsistOf("llama-7b-0", "llama-7b-3"))
+
+		port, ok := c.GetEndpointPort("llama-7b", "llama-7b-0")
+		Expect(ok).To(BeTrue())
+		Expect(port).To(Equal(int32(8001)))
+		_, ok = c.GetEndpointPort("llama-7b", "llama-7b-2")
+		Expect(ok).To(BeFalse())
+
+		// a terminating endpoint is not routable even while it is still ready.
+		updated := slice.DeepCopy()
+		updated.Endpoints[0].Conditions.Terminating = ptr.To(true)
+		updated.Endpoints[1].Conditions.Ready = ptr.To(true)
+		c.updateEndpointSlice(slice, updated)
+		pods, err = c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(podNames(pods)).To(ConsistOf("llama-7b-1", "llama-7b-3"))
+	})
+
+	It("should merge the endpoints of all slices of the Service", func() {
+		c.addEndpointSlice(newTestEndpointSlice("llama-7b-abcde", "llama-7b", servePorts,
+			newTestEndpoint("llama-7b-0", ptr.To(true), nil)))
+		second := newTestEndpointSlice("llama-7b-fghij", "llama-7b", servePorts,
+			newTestEndpoint("llama-7b-1", ptr.To(true), nil))
+		c.addEndpointSlice(second)
+
+		pods, err := c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(podNames(pods)).To(ConsistOf("llama-7b-0", "llama-7b-1"))
+
+		c.deleteEndpointSlice(second)
+		pods, err = c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(podNames(pods)).To(ConsistOf("llama-7b-0"))
+	})
+
+	It("should resolve the single unnamed port and skip the slices without the model port", func() {
+		c.addEndpointSlice(newTestEndpointSlice("llama-7b-abcde", "llama-7b",
+			[]discoveryv1.EndpointPort{{Port: ptr.To(int32(8000))}},
+			newTestEndpoint("llama-7b-0", ptr.To(true), nil)))
+		c.addEndpointSlice(newTestEndpointSlice("llama-7b-fghij", "llama-7b",
+			[]discoveryv1.EndpointPort{{Name: ptr.To("metrics"), Port: ptr.To(int32(8080))}, {Name: ptr.To("grpc"), Port: ptr.To(int32(9000))}},
+			newTestEndpoint("llama-7b-1", ptr.To(true), nil)))
+
+		port, ok := c.GetEndpointPort("llama-7b", "llama-7b-0")
+		Expect(ok).To(BeTrue())
+		Expect(port).To(Equal(int32(8000)))
+		_, ok = c.GetEndpointPort("llama-7b", "llama-7b-1")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should fall back to the pods once the Service is deleted", func() {
+		slice := newTestEndpointSlice("llama-7b-abcde", "llama-7b", servePorts,
+			newTestEndpoint("llama-7b-0", ptr.To(false), nil))
+		c.addEndpointSlice(slice)
+		pods, err := c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pods).To(BeEmpty())
+
+		c.deleteEndpointSlice(slice)
+		pods, err = c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pods).To(HaveLen(4))
+	})
+})
@@ -0,0 +1,299 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// LoadSummaryVersion is the version of the load summary format exchanged between federated gateways.
+	LoadSummaryVersion = "v1"
+	// LoadSummaryPath is the admin endpoint path serving the local load summary.
+	LoadSummaryPath = "/v1/federation/load-summary"
+	// RemoteKeyPrefix namespaces the summaries of remote peers in the cache.
+	RemoteKeyPrefix = "remote/"
+
+	federationPeersRedisKey            = "aibrix:federation-peers"
+	defaultFederationIntervalInSeconds = 5
+	// summaries of a peer which have not been refreshed for staleFederationIntervals are ignored.
+	staleFederationIntervals = 3
+)
+
+var federationInterval = getFederationInterval()
+
+func getFederationInterval() time.Duration {
+	value := utils.LoadEnv("AIBRIX_FEDERATION_INTERVAL_SECONDS", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_FEDERATION_INTERVAL_SECONDS: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_FEDERATION_INTERVAL_SECONDS env value for federation interval: %d s", intValue)
+			return time.Duration(intValue) * time.Second
+		}
+	}
+	klog.Infof("using default federation interval: %d s", defaultFederationIntervalInSeconds)
+	return defaultFederationIntervalInSeconds * time.Second
+}
+
+// ModelLoadSummary is the compact load of a model in a cluster.
+type ModelLoadSummary struct {
+	RoutablePods   int     `json:"routablePods"`
+	InFlight       int32   `json:"inFlight"`
+	P95TTFTSeconds float64 `json:"p95TTFTSeconds"`
+}
+
+// LoadSummary is the per model load summary a gateway exposes to its federated peers.
+type LoadSummary struct {
+	Version string `json:"version"`
+	Cluster string `json:"cluster"`
+	// Endpoint is the public gateway endpoint of the cluster which spilled over requests are sent to.
+	Endpoint  string                      `json:"endpoint"`
+	Timestamp time.Time                   `json:"timestamp"`
+	Models    map[string]ModelLoadSummary `json:"models"`
+}
+
+// RemoteModelLoad is the load of a model in a remote cluster as last fetched from the peer.
+type RemoteModelLoad struct {
+	ModelLoadSummary
+	Peer     string
+	Cluster  string
+	Endpoint string
+}
+
+// GetLoadSummary builds the load summary of the local cluster.
+func (c *Cache) GetLoadSummary(cluster, endpoint string) LoadSummary {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	summary := LoadSummary{
+		Version:   LoadSummaryVersion,
+		Cluster:   cluster,
+		Endpoint:  endpoint,
+		Timestamp: time.Now(),
+		Models:    make(map[string]ModelLoadSummary, len(c.ModelToPodMapping)),
+	}
+	for model, pods := range c.ModelToPodMapping {
+		readyPods := utils.FilterReadyPods(pods)
+		summary.Models[model] = ModelLoadSummary{
+			RoutablePods:   len(readyPods),
+			InFlight:       c.GetPendingRequestCount(model),
+			P95TTFTSeconds: c.getP95TTFTLocked(model, readyPods),
+		}
+	}
+	return summary
+}
+
+// GetPendingRequestCount returns the number of in-flight requests of the model through this gateway.
+func (c *Cache) GetPendingRequestCount(model string) int32 {
+	if c.pendingRequests == nil {
+		return 0
+	}
+	if pCounter, ok := c.pendingRequests.Load(model); ok {
+		return atomic.LoadInt32(pCounter.(*int32))
+	}
+	return 0
+}
+
+// getP95TTFTLocked merges the time to first token histograms of the given pods and returns their P95.
+func (c *Cache) getP95TTFTLocked(model string, pods []*v1.Pod) float64 {
+	merged := &metrics.HistogramMetricValue{Buckets: map[string]float64{}}
+	now := time.Now()
+	for _, pod := range pods {
+		value, ok := c.PodModelMetrics[pod.Name][model][metrics.TimeToFirstTokenSeconds]
+		if !ok || value.GetHistogramValue() == nil {
+			continue
+		}
+		if c.checkMetricFreshnessLocked(pod.Name, model, metrics.TimeToFirstTokenSeconds, now) != nil {
+			continue
+		}
+		histogram := value.GetHistogramValue()
+		merged.Sum += histogram.Sum
+		merged.Count += histogram.Count
+		for bucket, count := range histogram.Buckets {
+			merged.Buckets[bucket] += count
+		}
+	}
+	if merged.Count == 0 {
+		return 0
+	}
+	p95, err := merged.GetPercentile(95)
+	if err != nil {
+		klog.V(4).Infof("failed to compute p95 ttft for model %s: %v", model, err)
+		return 0
+	}
+	return p95
+}
+
+// StoreRemoteLoadSummary stores the summary fetched from a peer under the remote namespace.
+func (c *Cache) StoreRemoteLoadSummary(peer string, summary LoadSummary) error {
+	if summary.Version != LoadSummaryVersion {
+		return fmt.Errorf("unsupported load summary version %q from peer %s, expected %q", summary.Version, peer, LoadSummaryVersion)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.remoteSummaries == nil {
+		c.remoteSummaries = map[string]remoteLoadSummary{}
+	}
+	c.remoteSummaries[RemoteKeyPrefix+peer] = remoteLoadSummary{LoadSummary: summary, receivedAt: time.Now()}
+	return nil
+}
+
+// GetRemoteModelLoads returns the load of the model in all peers which have a fresh summary.
+func (c *Cache) GetRemoteModelLoads(model string) []RemoteModelLoad {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var loads []RemoteModelLoad
+	for key, summary := range c.remoteSummaries {
+		if time.Since(summary.receivedAt) > staleFederationIntervals*federationInterval {
+			continue
+		}
+		load, ok := summary.Models[model]
+		if !ok {
+			continue
+		}
+		loads = append(loads, RemoteModelLoad{
+			ModelLoadSummary: load,
+			Peer:             strings.TrimPrefix(key, RemoteKeyPrefix),
+			Cluster:          summary.Cluster,
+			Endpoint:         summary.Endpoint,
+		})
+	}
+	return loads
+}
+
+type remoteLoadSummary struct {
+	LoadSummary
+	receivedAt time.Time
+}
+
+// LoadSummaryHandler serves the local load summary to federated peers.
+func (c *Cache) LoadSummaryHandler(cluster, endpoint string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.GetLoadSummary(cluster, endpoint)); err != nil {
+			klog.ErrorS(err, "failed to encode load summary")
+		}
+	})
+}
+
+// getFederationPeers returns the admin endpoints of the peers from AIBRIX_FEDERATION_PEERS and redis.
+func getFederationPeers(ctx context.Context, redisClient redis.UniversalClient) []string {
+	peers := map[string]struct{}{}
+	if value, exists := utils.CheckEnvExists("AIBRIX_FEDERATION_PEERS"); exists {
+		for _, peer := range strings.Split(value, ",") {
+			if peer = strings.TrimSpace(peer); peer != "" {
+				peers[peer] = struct{}{}
+			}
+		}
+	}
+	if redisClient != nil {
+		members, err := redisClient.SMembers(ctx, federationPeersRedisKey).Result()
+		if err != nil {
+			klog.V(4).Infof("failed to load federation peers from redis: %v", err)
+		}
+		for _, peer := range members {
+			peers[peer] = struct{}{}
+		}
+	}
+
+	res := make([]string, 0, len(peers))
+	for peer := range peers {
+		res = append(res, peer)
+	}
+	return res
+}
+
+// fetchLoadSummary fetches the load summary of a single peer.
+func fetchLoadSummary(ctx context.Context, client *http.Client, peer string) (LoadSummary, error) {
+	var summary LoadSummary
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(peer, "/")+LoadSummaryPath, nil)
+	if err != nil {
+		return summary, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return summary, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			klog.Error(err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return summary, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// syncRemoteLoadSummaries fetches the summaries of all peers. A failing peer keeps its last summary
+// until it becomes stale so that transient errors do not flap spillover decisions.
+func (c *Cache) syncRemoteLoadSummaries(ctx context.Context, client *http.Client, peers []string) {
+	for _, peer := range peers {
+		summary, err := fetchLoadSummary(ctx, client, peer)
+		if err != nil {
+			klog.V(4).Infof("failed to fetch load summary from peer %s: %v", peer, err)
+			continue
+		}
+		if err := c.StoreRemoteLoadSummary(peer, summary); err != nil {
+			klog.Warning(err)
+		}
+	}
+}
+
+// startFederation periodically syncs the load summaries of the federated peers until stopCh is closed.
+func (c *Cache) startFederation(stopCh <-chan struct{}) {
+	client := &http.Client{Timeout: federationInterval}
+	ticker := time.NewTicker(federationInterval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), federationInterval)
+				c.syncRemoteLoadSummaries(ctx, client, getFederationPeers(ctx, c.redisClient))
+				cancel()
+			case <-stopCh:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
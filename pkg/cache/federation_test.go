@@ -0,0 +1,126 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newFederatedCache(model string, readyPods int) *Cache {
+	c := newTraceCache()
+	c.Pods = map[string]*v1.Pod{}
+	c.PodToModelMapping = map[string]map[string]struct{}{}
+	c.ModelToPodMapping = map[string]map[string]*v1.Pod{}
+	c.remoteSummaries = map[string]remoteLoadSummary{}
+	for i := 0; i < readyPods; i++ {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: model + "-" + string(rune('a'+i))},
+			Status: v1.PodStatus{
+				PodIP:      "10.0.0." + string(rune('1'+i)),
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		}
+		c.Pods[pod.Name] = pod
+		c.addPodAndModelMappingLocked(pod.Name, model)
+	}
+	return c
+}
+
+var _ = Describe("Federation", func() {
+	var gatewayA, gatewayB *Cache
+	var serverA, serverB *httptest.Server
+	client := &http.Client{Timeout: time.Second}
+
+	BeforeEach(func() {
+		gatewayA = newFederatedCache("llama-7b", 2)
+		gatewayB = newFederatedCache("llama-7b", 1)
+		gatewayA.AddRequestCount("req-1", "llama-7b")
+		gatewayA.AddRequestCount("req-2", "llama-7b")
+
+		serverA = httptest.NewServer(gatewayA.LoadSummaryHandler("cluster-a", "http://gateway-a"))
+		serverB = httptest.NewServer(gatewayB.LoadSummaryHandler("cluster-b", "http://gateway-b"))
+	})
+
+	AfterEach(func() {
+		serverA.Close()
+		serverB.Close()
+	})
+
+	It("should exchange load summaries between two gateways", func() {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			gatewayA.syncRemoteLoadSummaries(context.Background(), client, []string{serverB.URL})
+		}()
+		go func() {
+			defer wg.Done()
+			gatewayB.syncRemoteLoadSummaries(context.Background(), client, []string{serverA.URL})
+		}()
+		wg.Wait()
+
+		loadsOfB := gatewayA.GetRemoteModelLoads("llama-7b")
+		Expect(loadsOfB).To(HaveLen(1))
+		Expect(loadsOfB[0].Cluster).To(Equal("cluster-b"))
+		Expect(loadsOfB[0].Endpoint).To(Equal("http://gateway-b"))
+		Expect(loadsOfB[0].Peer).To(Equal(serverB.URL))
+		Expect(loadsOfB[0].RoutablePods).To(Equal(1))
+		Expect(loadsOfB[0].InFlight).To(Equal(int32(0)))
+
+		loadsOfA := gatewayB.GetRemoteModelLoads("llama-7b")
+		Expect(loadsOfA).To(HaveLen(1))
+		Expect(loadsOfA[0].Cluster).To(Equal("cluster-a"))
+		Expect(loadsOfA[0].RoutablePods).To(Equal(2))
+		Expect(loadsOfA[0].InFlight).To(Equal(int32(2)))
+
+		Expect(gatewayA.remoteSummaries).To(HaveKey(RemoteKeyPrefix + serverB.URL))
+		Expect(gatewayA.GetRemoteModelLoads("unknown-model")).To(BeEmpty())
+	})
+
+	It("should keep the last summary when a peer becomes unreachable", func() {
+		gatewayA.syncRemoteLoadSummaries(context.Background(), client, []string{serverB.URL})
+		Expect(gatewayA.GetRemoteModelLoads("llama-7b")).To(HaveLen(1))
+
+		serverB.Close()
+		gatewayA.syncRemoteLoadSummaries(context.Background(), client, []string{serverB.URL, "http://127.0.0.1:1"})
+		Expect(gatewayA.GetRemoteModelLoads("llama-7b")).To(HaveLen(1))
+	})
+
+	It("should ignore stale summaries", func() {
+		gatewayA.syncRemoteLoadSummaries(context.Background(), client, []string{serverB.URL})
+		key := RemoteKeyPrefix + serverB.URL
+		summary := gatewayA.remoteSummaries[key]
+		summary.receivedAt = time.Now().Add(-(staleFederationIntervals + 1) * federationInterval)
+		gatewayA.remoteSummaries[key] = summary
+
+		Expect(gatewayA.GetRemoteModelLoads("llama-7b")).To(BeEmpty())
+	})
+
+	It("should reject unsupported summary versions", func() {
+		err := gatewayA.StoreRemoteLoadSummary("peer", LoadSummary{Version: "v0"})
+		Expect(err).To(HaveOccurred())
+		Expect(gatewayA.remoteSummaries).To(BeEmpty())
+	})
+})
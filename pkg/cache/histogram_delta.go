@@ -0,0 +1,77 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+)
+
+// HistogramDelta is the increase of the sum and the count of a histogram metric of a pod between its last two
+// scrapes, e.g. the total and the number of the times to first token of the requests the pod served in between.
+type HistogramDelta struct {
+	Sum   float64
+	Count float64
+}
+
+// Mean returns the average of the observations between the two scrapes, 0 if there is none.
+func (d HistogramDelta) Mean() float64 {
+	if d.Count <= 0 {
+		return 0
+	}
+	return d.Sum / d.Count
+}
+
+// histogramCounters is the sum and the count of a histogram metric at a scrape.
+type histogramCounters struct {
+	sum   float64
+	count float64
+}
+
+// updateHistogramDeltaLocked records the sum and the count of the scraped histogram metric of the pod, and their
+// increase since the previous scrape. The first scrape of a histogram has no delta. A sum or a count lower than at
+// the previous scrape is a reset of the counters, e.g. by a restart of the model server, and its delta mixes the
+// counters of two processes: it is discarded, and the next scrape has a delta again.
+func (c *Cache) updateHistogramDeltaLocked(podName, modelName, metricName string, value *metrics.HistogramMetricValue) {
+	if c.histogramCounters == nil {
+		c.histogramCounters = map[string]map[string]histogramCounters{}
+	}
+	if c.histogramCounters[podName] == nil {
+		c.histogramCounters[podName] = map[string]histogramCounters{}
+	}
+	if c.histogramDeltas == nil {
+		c.histogramDeltas = map[string]map[string]HistogramDelta{}
+	}
+	if c.histogramDeltas[podName] == nil {
+		c.histogramDeltas[podName] = map[string]HistogramDelta{}
+	}
+
+	key := metricTimeKey(modelName, metricName)
+	previous, ok := c.histogramCounters[podName][key]
+	c.histogramCounters[podName][key] = histogramCounters{sum: value.Sum, count: value.Count}
+	switch {
+	case !ok:
+		delete(c.histogramDeltas[podName], key)
+	case value.Count < previous.count || value.Sum < previous.sum:
+		klog.V(4).InfoS("histogram counters reset, discarding their delta", "pod", podName, "model", modelName, "metric", metricName,
+			"previousCount", previous.count, "count", value.Count)
+		delete(c.histogramDeltas[podName], key)
+	default:
+		c.histogramDeltas[podName][key] = HistogramDelta{Sum: value.Sum - previous.sum, Count: value.Count - previous.count}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+)
+
+// scrapeHistogramPod records a scrape of p1 exposing the time to first token histogram of llama with the given sum
+// and count, and commits it.
+func scrapeHistogramPod(c *Cache, sum, count float64) {
+	body := fmt.Sprintf(`# TYPE vllm:time_to_first_token_seconds histogram
+vllm:time_to_first_token_seconds_bucket{model_name="llama",le="+Inf"} %v
+vllm:time_to_first_token_seconds_sum{model_name="llama"} %v
+vllm:time_to_first_token_seconds_count{model_name="llama"} %v
+`, count, sum, count)
+	families, err := metrics.ParseMetricFamilies([]byte(body))
+	Expect(err).NotTo(HaveOccurred())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updateHistogramMetricFromRawMetricsLocked(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p1"}}, families)
+	c.commitPodSnapshotLocked("p1")
+}
+
+var _ = Describe("Histogram deltas", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = newSnapshotCache()
+	})
+
+	It("should serve the increase of the histogram over the last two scrapes", func() {
+		scrapeHistogramPod(c, 10, 5)
+		_, err := c.GetPodSnapshot("p1").PodModelHistogramDelta("llama", metrics.TimeToFirstTokenSeconds)
+		Expect(err).To(HaveOccurred())
+
+		scrapeHistogramPod(c, 16, 8)
+		delta, err := c.GetPodSnapshot("p1").PodModelHistogramDelta("llama", metrics.TimeToFirstTokenSeconds)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(Equal(HistogramDelta{Sum: 6, Count: 3}))
+		Expect(delta.Mean()).To(Equal(2.0))
+
+		// a pod idle between the scrapes served no request.
+		scrapeHistogramPod(c, 16, 8)
+		delta, err = c.GetPodSnapshot("p1").PodModelHistogramDelta("llama", metrics.TimeToFirstTokenSeconds)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta.Count).To(BeZero())
+		Expect(delta.Mean()).To(BeZero())
+
+		_, err = c.GetPodSnapshot("p1").PodModelHistogramDelta("mistral", metrics.TimeToFirstTokenSeconds)
+		Expect(err).To(HaveOccurred())
+		_, err = c.GetPodSnapshot("p1").PodModelHistogramDelta("llama", metrics.E2ERequestLatencySeconds)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should discard the first delta after a reset of the counters", func() {
+		scrapeHistogramPod(c, 10, 5)
+		scrapeHistogramPod(c, 20, 10)
+
+		// the model server restarted, its counters start over.
+		scrapeHistogramPod(c, 1, 1)
+		_, err := c.GetPodSnapshot("p1").PodModelHistogramDelta("llama", metrics.TimeToFirstTokenSeconds)
+		Expect(err).To(HaveOccurred())
+
+		scrapeHistogramPod(c, 4, 3)
+		delta, err := c.GetPodSnapshot("p1").PodModelHistogramDelta("llama", metrics.TimeToFirstTokenSeconds)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(Equal(HistogramDelta{Sum: 3, Count: 2}))
+
+		// a lower sum alone is a reset too, e.g. a restart followed by more requests than before it.
+		scrapeHistogramPod(c, 2, 5)
+		_, err = c.GetPodSnapshot("p1").PodModelHistogramDelta("llama", metrics.TimeToFirstTokenSeconds)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should keep a snapshot unchanged by the following scrapes", func() {
+		scrapeHistogramPod(c, 10, 5)
+		scrapeHistogramPod(c, 16, 8)
+		snapshot := c.GetPodSnapshot("p1")
+
+		scrapeHistogramPod(c, 26, 9)
+		delta, err := snapshot.PodModelHistogramDelta("llama", metrics.TimeToFirstTokenSeconds)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(Equal(HistogramDelta{Sum: 6, Count: 3}))
+	})
+
+	It("should forget the counters of an evicted pod", func() {
+		scrapeHistogramPod(c, 10, 5)
+		c.mu.Lock()
+		c.evictPodMetricsLocked("p1")
+		c.PodMetrics = map[string]map[string]metrics.MetricValue{"p1": {}}
+		c.PodModelMetrics = map[string]map[string]map[string]metrics.MetricValue{"p1": {}}
+		c.mu.Unlock()
+		Expect(c.histogramCounters).NotTo(HaveKey("p1"))
+
+		// the pod scraped again starts a new series.
+		scrapeHistogramPod(c, 16, 8)
+		_, err := c.GetPodSnapshot("p1").PodModelHistogramDelta("llama", metrics.TimeToFirstTokenSeconds)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const defaultPodMetricTTLInMS = 10000
+
+// ErrMetricStale is returned for a pod metric last scraped longer than the freshness TTL ago, e.g. because the pod
+// is wedged and its metrics endpoint stopped answering. Callers treat a stale metric as a missing one.
+var ErrMetricStale = errors.New("metric is stale")
+
+// getPodMetricTTL returns the freshness TTL of the pod metrics, 0 disables the staleness check.
+func getPodMetricTTL() time.Duration {
+	value := utils.LoadEnv("AIBRIX_POD_METRIC_TTL_MS", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue < 0 {
+			klog.Infof("invalid AIBRIX_POD_METRIC_TTL_MS: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_POD_METRIC_TTL_MS env value for pod metrics freshness: %d ms", intValue)
+			return time.Duration(intValue) * time.Millisecond
+		}
+	}
+	return defaultPodMetricTTLInMS * time.Millisecond
+}
+
+// metricTimeKey is the key of the scrape time of a metric of the pod, the model is empty for the pod scope metrics.
+func metricTimeKey(modelName, metricName string) string {
+	if modelName == "" {
+		return metricName
+	}
+	return modelName + "/" + metricName
+}
+
+// setMetricTimeLocked records the time the metric of the pod was scraped.
+func (c *Cache) setMetricTimeLocked(podName, modelName, metricName string, now time.Time) {
+	if c.podMetricTimes == nil {
+		c.podMetricTimes = map[string]map[string]time.Time{}
+	}
+	if c.podMetricTimes[podName] == nil {
+		c.podMetricTimes[podName] = map[string]time.Time{}
+	}
+	c.podMetricTimes[podName][metricTimeKey(modelName, metricName)] = now
+}
+
+// checkMetricFreshnessLocked returns ErrMetricStale if the metric of the pod was not scraped within the TTL, a
+// metric without scrape time was never scraped.
+func (c *Cache) checkMetricFreshnessLocked(podName, modelName, metricName string, now time.Time) error {
+	return checkMetricFreshness(c.podMetricTimes[podName], c.podMetricTTL, podName, modelName, metricName, now)
+}
+
+// checkMetricFreshness checks the freshness of the metric of the pod against the scrape times of the metrics of the
+// pod.
+func checkMetricFreshness(metricTimes map[string]time.Time, ttl time.Duration, podName, modelName, metricName string, now time.Time) error {
+	if ttl <= 0 {
+		return nil
+	}
+	scrapeTime, ok := metricTimes[metricTimeKey(modelName, metricName)]
+	if !ok {
+		return fmt.Errorf("%w: %v of pod %v was never scraped", ErrMetricStale, metricName, podName)
+	}
+	if age := now.Sub(scrapeTime); age > ttl {
+		return fmt.Errorf("%w: %v of pod %v was scraped %v ago", ErrMetricStale, metricName, podName, age.Truncate(time.Millisecond))
+	}
+	return nil
+}
+
+// LastScrapeTime returns the time the metrics endpoint of the pod was last scraped successfully, false if it never
+// was.
+func (c *Cache) LastScrapeTime(podName string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	scrapeTime, ok := c.podScrapeTimes[podName]
+	return scrapeTime, ok
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+)
+
+var _ = Describe("Metric staleness", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = newTraceCache()
+		c.podMetricTTL = 10 * time.Second
+		c.PodMetrics = map[string]map[string]metrics.MetricValue{"p1": {}}
+		c.PodModelMetrics = map[string]map[string]map[string]metrics.MetricValue{"p1": {}}
+		Expect(c.updatePodRecordLocked("p1", "", metrics.GPUCacheUsagePerc, metrics.PodMetricScope, &metrics.SimpleMetricValue{Value: 0.5})).To(Succeed())
+		Expect(c.updatePodRecordLocked("p1", "llama", metrics.NumRequestsWaiting, metrics.PodModelMetricScope, &metrics.SimpleMetricValue{Value: 2})).To(Succeed())
+	})
+
+	It("should serve the metrics scraped within the TTL", func() {
+		value, err := c.GetPodMetric("p1", metrics.GPUCacheUsagePerc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(0.5))
+
+		value, err = c.GetPodModelMetric("p1", "llama", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(2.0))
+	})
+
+	It("should report the metrics scraped before the TTL as stale", func() {
+		c.setMetricTimeLocked("p1", "", metrics.GPUCacheUsagePerc, time.Now().Add(-time.Minute))
+		c.setMetricTimeLocked("p1", "llama", metrics.NumRequestsWaiting, time.Now().Add(-time.Minute))
+
+		_, err := c.GetPodMetric("p1", metrics.GPUCacheUsagePerc)
+		Expect(errors.Is(err, ErrMetricStale)).To(BeTrue())
+		_, err = c.GetPodModelMetric("p1", "llama", metrics.NumRequestsWaiting)
+		Expect(errors.Is(err, ErrMetricStale)).To(BeTrue())
+
+		// a fresh scrape makes the metric usable again.
+		Expect(c.updatePodRecordLocked("p1", "llama", metrics.NumRequestsWaiting, metrics.PodModelMetricScope, &metrics.SimpleMetricValue{Value: 3})).To(Succeed())
+		value, err := c.GetPodModelMetric("p1", "llama", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(3.0))
+	})
+
+	It("should report the metrics never scraped as stale", func() {
+		c.PodModelMetrics["p1"]["llama"][metrics.NumRequestsRunning] = &metrics.SimpleMetricValue{Value: 1}
+
+		_, err := c.GetPodModelMetric("p1", "llama", metrics.NumRequestsRunning)
+		Expect(errors.Is(err, ErrMetricStale)).To(BeTrue())
+	})
+
+	It("should not check the freshness with a TTL of 0", func() {
+		c.podMetricTTL = 0
+		c.setMetricTimeLocked("p1", "llama", metrics.NumRequestsWaiting, time.Now().Add(-time.Hour))
+
+		_, err := c.GetPodModelMetric("p1", "llama", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should track the last scrape time of the pods", func() {
+		_, ok := c.LastScrapeTime("p1")
+		Expect(ok).To(BeFalse())
+
+		scrapeTime := time.Now()
+		c.Pods = map[string]*v1.Pod{"p1": newAutoscaledPod("p1", "llama", "llama")}
+		c.podScrapeTimes = map[string]time.Time{"p1": scrapeTime}
+		lastScrapeTime, ok := c.LastScrapeTime("p1")
+		Expect(ok).To(BeTrue())
+		Expect(lastScrapeTime).To(Equal(scrapeTime))
+
+		c.ModelToPodMapping = map[string]map[string]*v1.Pod{}
+		c.PodToModelMapping = map[string]map[string]struct{}{}
+		c.deletePod(c.Pods["p1"])
+		_, ok = c.LastScrapeTime("p1")
+		Expect(ok).To(BeFalse())
+		_, err := c.GetPodMetric("p1", metrics.GPUCacheUsagePerc)
+		Expect(err).To(HaveOccurred())
+	})
+})
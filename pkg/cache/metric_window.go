@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// defaultPodMetricWindowSamples keeps a minute of samples at the default refresh interval of the pod metrics.
+	defaultPodMetricWindowSamples = 1200
+
+	// podMetricWindowMaxAge is the age beyond which a sample is out of every window, however few samples the pod has.
+	podMetricWindowMaxAge = 5 * time.Minute
+)
+
+// getPodMetricWindowSamples returns the number of recent samples kept per metric of a pod, 0 disables the windows.
+func getPodMetricWindowSamples() int {
+	value := utils.LoadEnv("AIBRIX_POD_METRIC_WINDOW_SAMPLES", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue < 0 {
+			klog.Infof("invalid AIBRIX_POD_METRIC_WINDOW_SAMPLES: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_POD_METRIC_WINDOW_SAMPLES env value for pod metric windows: %d", intValue)
+			return intValue
+		}
+	}
+	return defaultPodMetricWindowSamples
+}
+
+// metricSample is a value of a metric of a pod and the time it was scraped.
+type metricSample struct {
+	at    time.Time
+	value float64
+}
+
+// metricRing is a ring buffer of the recent samples of a metric of a pod, the newest sample overwrites the oldest
+// once it is full.
+type metricRing struct {
+	samples []metricSample
+	// next is the slot of the next sample, count the number of samples in the ring.
+	next  int
+	count int
+}
+
+func (r *metricRing) append(sample metricSample) {
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+	if r.count < len(r.samples) {
+		r.count++
+	}
+}
+
+// podMetricWindow holds the rings of the metrics of a pod. Its own lock serializes the scrape of the pod with the
+// reads of the routers, so that the reads never wait for the cache lock held by the scrapes of all the pods.
+type podMetricWindow struct {
+	mu    sync.RWMutex
+	rings map[string]*metricRing // model_name/metric_name: samples
+}
+
+// podMetricWindows keeps the recent samples of the numeric metrics of the pods, so that the consumers read metrics
+// smoothed over a trailing window rather than the last scraped sample. It is nil if disabled.
+type podMetricWindows struct {
+	size int
+	pods sync.Map // pod_name: *podMetricWindow
+}
+
+func newPodMetricWindows(size int) *podMetricWindows {
+	if size <= 0 {
+		return nil
+	}
+	return &podMetricWindows{size: size}
+}
+
+// append adds the sample of the metric of the pod to its ring.
+func (w *podMetricWindows) append(podName, modelName, metricName string, sample metricSample) {
+	if w == nil {
+		return
+	}
+	value, _ := w.pods.LoadOrStore(podName, &podMetricWindow{rings: map[string]*metricRing{}})
+	window := value.(*podMetricWindow)
+
+	key := metricTimeKey(modelName, metricName)
+	window.mu.Lock()
+	defer window.mu.Unlock()
+	ring, ok := window.rings[key]
+	if !ok {
+		ring = &metricRing{samples: make([]metricSample, w.size)}
+		window.rings[key] = ring
+	}
+	ring.append(sample)
+}
+
+// deletePod drops the samples of the pod.
+func (w *podMetricWindows) deletePod(podName string) {
+	if w == nil {
+		return
+	}
+	w.pods.Delete(podName)
+}
+
+// aggregate returns the average, the max and the number of the samples of the metric of the pod scraped within the
+// window ending at now. The window is capped to podMetricWindowMaxAge.
+func (w *podMetricWindows) aggregate(podName, modelName, metricName string, window time.Duration, now time.Time) (avg, maxValue float64, count int, err error) {
+	if w == nil {
+		return 0, 0, 0, fmt.Errorf("pod metric windows are disabled")
+	}
+	value, ok := w.pods.Load(podName)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("pod does not exist in the podMetrics cache")
+	}
+	podWindow := value.(*podMetricWindow)
+	if window > podMetricWindowMaxAge {
+		window = podMetricWindowMaxAge
+	}
+	since := now.Add(-window)
+
+	podWindow.mu.RLock()
+	defer podWindow.mu.RUnlock()
+	ring, ok := podWindow.rings[metricTimeKey(modelName, metricName)]
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("no metric available for %v", metricName)
+	}
+	var sum float64
+	for i := 0; i < ring.count; i++ {
+		// from the newest sample backwards, the samples are in scrape order.
+		sample := ring.samples[(ring.next-1-i+len(ring.samples))%len(ring.samples)]
+		if sample.at.Before(since) {
+			break
+		}
+		if count == 0 || sample.value > maxValue {
+			maxValue = sample.value
+		}
+		sum += sample.value
+		count++
+	}
+	if count == 0 {
+		return 0, 0, 0, fmt.Errorf("%w: no sample of %v of pod %v within %v", ErrMetricStale, metricName, podName, window)
+	}
+	return sum / float64(count), maxValue, count, nil
+}
+
+// appendMetricSampleLocked adds the scraped value of a metric of the pod to its window, only the counter and gauge
+// values are windowed.
+func (c *Cache) appendMetricSampleLocked(podName, modelName, metricName string, metricValue metrics.MetricValue, now time.Time) {
+	if simpleValue, ok := metricValue.(*metrics.SimpleMetricValue); ok {
+		c.metricWindows.append(podName, modelName, metricName, metricSample{at: now, value: simpleValue.Value})
+	}
+}
+
+// GetPodMetricWindowAvg returns the average of the pod scope metric over the samples scraped within the trailing
+// window, and the number of these samples. A pod scraped for less than the window, e.g. a pod that just started,
+// averages the samples it has, callers needing a full window check the count against the refresh interval. No sample
+// within the window is an ErrMetricStale error.
+func (c *Cache) GetPodMetricWindowAvg(podName, metricName string, window time.Duration) (float64, int, error) {
+	avg, _, count, err := c.metricWindows.aggregate(podName, "", metricName, window, time.Now())
+	return avg, count, err
+}
+
+// GetPodMetricWindowMax returns the max of the pod scope metric over the samples scraped within the trailing window,
+// and the number of these samples, like GetPodMetricWindowAvg.
+func (c *Cache) GetPodMetricWindowMax(podName, metricName string, window time.Duration) (float64, int, error) {
+	_, maxValue, count, err := c.metricWindows.aggregate(podName, "", metricName, window, time.Now())
+	return maxValue, count, err
+}
+
+// GetPodModelMetricWindowAvg returns the average of the metric of the model of the pod over the samples scraped
+// within the trailing window, and the number of these samples, like GetPodMetricWindowAvg.
+func (c *Cache) GetPodModelMetricWindowAvg(podName, modelName, metricName string, window time.Duration) (float64, int, error) {
+	avg, _, count, err := c.metricWindows.aggregate(podName, modelName, metricName, window, time.Now())
+	return avg, count, err
+}
+
+// GetPodModelMetricWindowMax returns the max of the metric of the model of the pod over the samples scraped within
+// the trailing window, and the number of these samples, like GetPodMetricWindowAvg.
+func (c *Cache) GetPodModelMetricWindowMax(podName, modelName, metricName string, window time.Duration) (float64, int, error) {
+	_, maxValue, count, err := c.metricWindows.aggregate(podName, modelName, metricName, window, time.Now())
+	return maxValue, count, err
+}
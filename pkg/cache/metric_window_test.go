@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+)
+
+var _ = Describe("Pod metric windows", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = newSnapshotCache()
+		c.metricWindows = newPodMetricWindows(4)
+	})
+
+	It("should aggregate the samples of the scrapes instead of the last one", func() {
+		for _, value := range []float64{1, 2, 6} {
+			scrapeSnapshotPod(c, value)
+		}
+
+		avg, count, err := c.GetPodModelMetricWindowAvg("p1", "llama", metrics.NumRequestsWaiting, 30*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(avg).To(Equal(3.0))
+		Expect(count).To(Equal(3))
+		maxValue, count, err := c.GetPodModelMetricWindowMax("p1", "llama", metrics.NumRequestsWaiting, 30*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(maxValue).To(Equal(6.0))
+		Expect(count).To(Equal(3))
+		avg, _, err = c.GetPodMetricWindowAvg("p1", metrics.GPUCacheUsagePerc, 30*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(avg).To(Equal(3.0))
+
+		// the last sample is still served as is.
+		value, err := c.GetPodModelMetric("p1", "llama", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(6.0))
+	})
+
+	It("should keep a bounded number of samples", func() {
+		for value := 1; value <= 6; value++ {
+			scrapeSnapshotPod(c, float64(value))
+		}
+
+		avg, count, err := c.GetPodModelMetricWindowAvg("p1", "llama", metrics.NumRequestsWaiting, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(avg).To(Equal(4.5))
+		Expect(count).To(Equal(4))
+	})
+
+	It("should age the samples out of the window", func() {
+		now := time.Now()
+		for _, sample := range []metricSample{
+			{at: now.Add(-podMetricWindowMaxAge - time.Minute), value: 100},
+			{at: now.Add(-time.Minute), value: 10},
+			{at: now.Add(-10 * time.Second), value: 2},
+		} {
+			c.metricWindows.append("p1", "llama", metrics.NumRequestsWaiting, sample)
+		}
+
+		avg, count, err := c.GetPodModelMetricWindowAvg("p1", "llama", metrics.NumRequestsWaiting, 30*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(avg).To(Equal(2.0))
+		Expect(count).To(Equal(1))
+
+		// the window is capped, the samples older than the max age are never aggregated.
+		maxValue, count, err := c.GetPodModelMetricWindowMax("p1", "llama", metrics.NumRequestsWaiting, time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(maxValue).To(Equal(10.0))
+		Expect(count).To(Equal(2))
+
+		_, _, err = c.GetPodModelMetricWindowAvg("p1", "llama", metrics.NumRequestsWaiting, 5*time.Second)
+		Expect(errors.Is(err, ErrMetricStale)).To(BeTrue())
+	})
+
+	It("should report the metrics of the pods never scraped or evicted as missing", func() {
+		_, _, err := c.GetPodModelMetricWindowAvg("p1", "llama", metrics.NumRequestsWaiting, time.Minute)
+		Expect(err).To(MatchError("pod does not exist in the podMetrics cache"))
+
+		scrapeSnapshotPod(c, 1)
+		_, _, err = c.GetPodModelMetricWindowAvg("p1", "llama", metrics.AvgPromptThroughputToksPerS, time.Minute)
+		Expect(err).To(HaveOccurred())
+
+		c.mu.Lock()
+		c.evictPodMetricsLocked("p1")
+		c.mu.Unlock()
+		_, _, err = c.GetPodModelMetricWindowAvg("p1", "llama", metrics.NumRequestsWaiting, time.Minute)
+		Expect(err).To(MatchError("pod does not exist in the podMetrics cache"))
+
+		// the windows are disabled.
+		c = newSnapshotCache()
+		scrapeSnapshotPod(c, 1)
+		_, _, err = c.GetPodModelMetricWindowAvg("p1", "llama", metrics.NumRequestsWaiting, time.Minute)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+// BenchmarkPodMetricWindowReads compares the concurrent reads of the routers of the last sample and of the window
+// average of a metric, while the pod is scraped.
+func BenchmarkPodMetricWindowReads(b *testing.B) {
+	c := newSnapshotCache()
+	c.metricWindows = newPodMetricWindows(defaultPodMetricWindowSamples)
+	for i := 0; i < defaultPodMetricWindowSamples; i++ {
+		scrapeSnapshotPod(c, float64(i))
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				scrapeSnapshotPod(c, float64(i))
+			}
+		}
+	}()
+
+	b.Run("get-pod-model-metric", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _ = c.GetPodModelMetric("p1", "llama", metrics.NumRequestsWaiting)
+			}
+		})
+	})
+	b.Run("get-pod-model-metric-window-avg", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, _, _ = c.GetPodModelMetricWindowAvg("p1", "llama", metrics.NumRequestsWaiting, 30*time.Second)
+			}
+		})
+	})
+}
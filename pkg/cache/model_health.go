@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// ModelHealthPath is the admin path serving the health of the models.
+	ModelHealthPath = "/v1/models/health"
+
+	defaultModelHardDownInSeconds = 60
+)
+
+// podFailureReasons are the waiting reasons of a container which does not recover by itself.
+var podFailureReasons = map[string]struct{}{
+	"CrashLoopBackOff": {},
+	"ImagePullBackOff": {},
+	"ErrImagePull":     {},
+}
+
+var modelHardDownDelay = getModelHardDownDelay()
+
+func getModelHardDownDelay() time.Duration {
+	value := utils.LoadEnv("AIBRIX_MODEL_HARD_DOWN_SECONDS", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue < 0 {
+			klog.Infof("invalid AIBRIX_MODEL_HARD_DOWN_SECONDS: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_MODEL_HARD_DOWN_SECONDS env value for model hard down delay: %d s", intValue)
+			return time.Duration(intValue) * time.Second
+		}
+	}
+	klog.Infof("using default model hard down delay: %d s", defaultModelHardDownInSeconds)
+	return defaultModelHardDownInSeconds * time.Second
+}
+
+// podFailure is a pod failing since the given time.
+type podFailure struct {
+	since  time.Time
+	reason string
+}
+
+// ModelHealth is the health of a model, hard down when all its pods failed for longer than the hard down delay.
+type ModelHealth struct {
+	Model        string     `json:"model"`
+	HardDown     bool       `json:"hard_down"`
+	Reason       string     `json:"reason,omitempty"`
+	Pods         int        `json:"pods"`
+	FailingPods  int        `json:"failing_pods"`
+	FailingSince *time.Time `json:"failing_since,omitempty"`
+}
+
+// getPodFailureReason returns the waiting reason of the first container of the pod which does not recover by
+// itself.
+func getPodFailureReason(pod *v1.Pod) (string, bool) {
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.State.Waiting == nil {
+				continue
+			}
+			if _, ok := podFailureReasons[status.State.Waiting.Reason]; ok {
+				return status.State.Waiting.Reason, true
+			}
+		}
+	}
+	return "", false
+}
+
+// hasCrashed returns whether a container of the pod terminated before, e.g. a container restarted by its back-off.
+func hasCrashed(pod *v1.Pod) bool {
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.RestartCount > 0 && status.LastTerminationState.Terminated != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// observePodFailureLocked records whether the pod is failing at the given time. A crashing container is running
+// between its back-offs, the pod is still failing until it becomes routable.
+func (c *Cache) observePodFailureLocked(pod *v1.Pod, now time.Time) {
+	if isPodRoutable(pod) {
+		delete(c.podFailures, pod.Name)
+		return
+	}
+	reason, failing := getPodFailureReason(pod)
+	if failure, ok := c.podFailures[pod.Name]; ok {
+		if failing {
+			failure.reason = reason
+			c.podFailures[pod.Name] = failure
+		} else if !hasCrashed(pod) {
+			delete(c.podFailures, pod.Name)
+		}
+		return
+	}
+	if !failing {
+		return
+	}
+	if c.podFailures == nil {
+		c.podFailures = map[string]podFailure{}
+	}
+	c.podFailures[pod.Name] = podFailure{since: now, reason: reason}
+}
+
+// getModelHealthLocked returns the health of the model at the given time.
+func (c *Cache) getModelHealthLocked(modelName string, now time.Time) ModelHealth {
+	health := ModelHealth{Model: modelName}
+	var since time.Time
+	reasons := map[string]struct{}{}
+	for podName := range c.ModelToPodMapping[modelName] {
+		health.Pods++
+		failure, ok := c.podFailures[podName]
+		if !ok {
+			continue
+		}
+		health.FailingPods++
+		reasons[failure.reason] = struct{}{}
+		// all the pods have been failing since the last one started to fail.
+		if failure.since.After(since) {
+			since = failure.since
+		}
+	}
+	if health.Pods == 0 || health.FailingPods < health.Pods {
+		return health
+	}
+
+	sorted := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		sorted = append(sorted, reason)
+	}
+	sort.Strings(sorted)
+	health.Reason = strings.Join(sorted, ",")
+	health.FailingSince = &since
+	health.HardDown = now.Sub(since) >= modelHardDownDelay
+	return health
+}
+
+// IsModelHardDown returns whether all the pods of the model have been failing for longer than the hard down delay,
+// with the reasons they fail. The model recovers as soon as one of its pods is not failing.
+func (c *Cache) IsModelHardDown(modelName string, now time.Time) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	health := c.getModelHealthLocked(modelName, now)
+	return health.Reason, health.HardDown
+}
+
+// GetModelHealth returns the health of the models served by pods, sorted by name.
+func (c *Cache) GetModelHealth(now time.Time) []ModelHealth {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	health := make([]ModelHealth, 0, len(c.ModelToPodMapping))
+	for modelName := range c.ModelToPodMapping {
+		health = append(health, c.getModelHealthLocked(modelName, now))
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Model < health[j].Model })
+	return health
+}
+
+// ModelHealthHandler serves the health of the models, with the reasons of the models which are hard down.
+func (c *Cache) ModelHealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.GetModelHealth(time.Now())); err != nil {
+			klog.ErrorS(err, "failed to encode model health")
+		}
+	})
+}
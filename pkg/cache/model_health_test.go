@@ -0,0 +1,142 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+// waitingPod returns a copy of the pod the container of which waits for the given reason.
+func waitingPod(pod *v1.Pod, reason string) *v1.Pod {
+	waiting := pod.DeepCopy()
+	waiting.Status.PodIP = "10.0.0.1"
+	waiting.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:         "vllm",
+		RestartCount: 3,
+		State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason}},
+		LastTerminationState: v1.ContainerState{
+			Terminated: &v1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"},
+		},
+	}}
+	return waiting
+}
+
+// restartedPod returns a copy of the crashing pod the container of which was restarted by its back-off.
+func restartedPod(pod *v1.Pod) *v1.Pod {
+	running := pod.DeepCopy()
+	running.Status.ContainerStatuses[0].State = v1.ContainerState{Running: &v1.ContainerStateRunning{}}
+	return running
+}
+
+var _ = Describe("Model health", func() {
+	var c *Cache
+	var pods []*v1.Pod
+
+	BeforeEach(func() {
+		c = newIndexedCache()
+		pods = []*v1.Pod{
+			newAutoscaledPod("llama-7b-0", "llama-7b", "llama-7b"),
+			newAutoscaledPod("llama-7b-1", "llama-7b", "llama-7b"),
+		}
+		for _, pod := range pods {
+			c.addPod(pod)
+		}
+	})
+
+	// crash replays all the pods of the model entering a back-off.
+	crash := func(reason string) {
+		for i, pod := range pods {
+			crashing := waitingPod(pod, reason)
+			c.updatePod(pod, crashing)
+			pods[i] = crashing
+		}
+	}
+
+	It("should be hard down once all the pods crashed for longer than the delay", func() {
+		crash("CrashLoopBackOff")
+
+		_, down := c.IsModelHardDown("llama-7b", time.Now())
+		Expect(down).To(BeFalse())
+
+		reason, down := c.IsModelHardDown("llama-7b", time.Now().Add(modelHardDownDelay))
+		Expect(down).To(BeTrue())
+		Expect(reason).To(Equal("CrashLoopBackOff"))
+
+		// the containers running between their back-offs are still failing.
+		restarted := restartedPod(pods[0])
+		c.updatePod(pods[0], restarted)
+		pods[0] = restarted
+		_, down = c.IsModelHardDown("llama-7b", time.Now().Add(modelHardDownDelay))
+		Expect(down).To(BeTrue())
+	})
+
+	It("should recover as soon as a pod becomes ready", func() {
+		crash("CrashLoopBackOff")
+		c.updatePod(pods[1], readyPod(pods[1], time.Now()))
+
+		_, down := c.IsModelHardDown("llama-7b", time.Now().Add(modelHardDownDelay))
+		Expect(down).To(BeFalse())
+	})
+
+	It("should not be hard down while a pod is only pending", func() {
+		c.updatePod(pods[0], waitingPod(pods[0], "ImagePullBackOff"))
+
+		health := c.GetModelHealth(time.Now().Add(modelHardDownDelay))
+		Expect(health).To(Equal([]ModelHealth{{Model: "llama-7b", Pods: 2, FailingPods: 1}}))
+	})
+
+	It("should recover when the crashing pods are deleted", func() {
+		crash("ImagePullBackOff")
+		for _, pod := range pods {
+			c.deletePod(pod)
+		}
+		c.addPod(newAutoscaledPod("llama-7b-2", "llama-7b", "llama-7b"))
+
+		_, down := c.IsModelHardDown("llama-7b", time.Now().Add(modelHardDownDelay))
+		Expect(down).To(BeFalse())
+		Expect(c.podFailures).To(BeEmpty())
+	})
+
+	It("should serve the health of the models", func() {
+		crash("CrashLoopBackOff")
+		c.updatePod(pods[1], waitingPod(pods[1], "ImagePullBackOff"))
+		c.podFailures["llama-7b-0"] = podFailure{since: time.Now().Add(-2 * modelHardDownDelay), reason: "CrashLoopBackOff"}
+		c.podFailures["llama-7b-1"] = podFailure{since: time.Now().Add(-modelHardDownDelay), reason: "ImagePullBackOff"}
+
+		rec := httptest.NewRecorder()
+		c.ModelHealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ModelHealthPath, nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		var health []ModelHealth
+		Expect(json.Unmarshal(rec.Body.Bytes(), &health)).To(Succeed())
+		Expect(health).To(HaveLen(1))
+		Expect(health[0].HardDown).To(BeTrue())
+		Expect(health[0].Reason).To(Equal("CrashLoopBackOff,ImagePullBackOff"))
+		Expect(health[0].FailingPods).To(Equal(2))
+
+		rec = httptest.NewRecorder()
+		c.ModelHealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ModelHealthPath, nil))
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+var _ = Describe("Pod eviction", func() {
+	var c *Cache
+	var server *httptest.Server
+	var pod *v1.Pod
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "# TYPE vllm:num_requests_waiting gauge")
+			fmt.Fprintln(w, `vllm:num_requests_waiting{model_name="llama-7b"} 3`)
+		}))
+		host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+
+		c = newIndexedCache()
+		c.PodMetrics = map[string]map[string]metrics.MetricValue{}
+		c.PodModelMetrics = map[string]map[string]map[string]metrics.MetricValue{}
+		pod = newAutoscaledPod("llama-7b-a", "llama-7b", "llama-7b")
+		pod.Labels[utils.PodPortIdentifier] = port
+		pod.Status = v1.PodStatus{
+			PodIP:      host,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		}
+		c.addPod(pod)
+		c.updatePodMetrics()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should evict the metrics and the pod once it is deleted", func() {
+		value, err := c.GetPodModelMetric("llama-7b-a", "llama-7b", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(3.0))
+		_, ok := c.LastScrapeTime("llama-7b-a")
+		Expect(ok).To(BeTrue())
+
+		// a terminating pod is not routable anymore.
+		terminating := pod.DeepCopy()
+		terminating.DeletionTimestamp = &metav1.Time{}
+		c.updatePod(pod, terminating)
+		pods, err := c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pods).To(BeEmpty())
+		Expect(c.GetPods()).To(BeEmpty())
+		queried, err := c.Query(map[string]string{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(queried).To(BeEmpty())
+
+		// the deletion is delivered as a tombstone after the informer missed it.
+		c.deletePod(cache.DeletedFinalStateUnknown{Key: "default/llama-7b-a", Obj: terminating})
+		_, err = c.GetPodModelMetric("llama-7b-a", "llama-7b", metrics.NumRequestsWaiting)
+		Expect(err).To(MatchError("pod does not exist in the podMetrics cache"))
+		_, ok = c.LastScrapeTime("llama-7b-a")
+		Expect(ok).To(BeFalse())
+		_, err = c.GetPodsForModel("llama-7b")
+		Expect(err).To(HaveOccurred())
+		Expect(c.Pods).To(BeEmpty())
+	})
+
+	It("should evict the metrics of the old address of a restarted pod", func() {
+		restarted := pod.DeepCopy()
+		restarted.Status.PodIP = "10.0.0.2"
+		c.updatePod(pod, restarted)
+
+		_, err := c.GetPodModelMetric("llama-7b-a", "llama-7b", metrics.NumRequestsWaiting)
+		Expect(err).To(HaveOccurred())
+		_, ok := c.LastScrapeTime("llama-7b-a")
+		Expect(ok).To(BeFalse())
+		pods, err := c.GetPodsForModel("llama-7b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pods["llama-7b-a"].Status.PodIP).To(Equal("10.0.0.2"))
+	})
+
+	It("should keep the metrics of a pod updated in place", func() {
+		updated := pod.DeepCopy()
+		updated.Labels["app"] = "vllm"
+		c.updatePod(pod, updated)
+
+		value, err := c.GetPodModelMetric("llama-7b-a", "llama-7b", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(3.0))
+	})
+})
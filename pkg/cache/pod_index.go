@@ -0,0 +1,137 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// Dimensions pods can be queried by. The model dimension includes the LoRA adapters loaded on a pod,
+// the other dimensions are read from the pod labels in podIndexLabels.
+const (
+	DimensionModel   = "model"
+	DimensionEngine  = "engine"
+	DimensionVersion = "version"
+	DimensionZone    = "zone"
+	DimensionRole    = "role"
+	DimensionTenant  = "tenant"
+)
+
+var podIndexLabels = map[string]string{
+	DimensionEngine:  "model.aibrix.ai/engine",
+	DimensionVersion: "model.aibrix.ai/version",
+	DimensionZone:    "topology.kubernetes.io/zone",
+	DimensionRole:    "model.aibrix.ai/role",
+	DimensionTenant:  tenantReservationIdentifier,
+}
+
+// podIndex maps the label value of every indexed dimension to its pods, dimension: value: pod_name: *v1.Pod.
+type podIndex map[string]map[string]map[string]*v1.Pod
+
+func (i *podIndex) addPod(pod *v1.Pod) {
+	if *i == nil {
+		*i = podIndex{}
+	}
+	for dimension, label := range podIndexLabels {
+		value, ok := pod.Labels[label]
+		if !ok {
+			continue
+		}
+		values, ok := (*i)[dimension]
+		if !ok {
+			values = map[string]map[string]*v1.Pod{}
+			(*i)[dimension] = values
+		}
+		pods, ok := values[value]
+		if !ok {
+			pods = map[string]*v1.Pod{}
+			values[value] = pods
+		}
+		pods[pod.Name] = pod
+	}
+}
+
+func (i podIndex) deletePod(pod *v1.Pod) {
+	for dimension, label := range podIndexLabels {
+		value, ok := pod.Labels[label]
+		if !ok {
+			continue
+		}
+		pods := i[dimension][value]
+		delete(pods, pod.Name)
+		if len(pods) == 0 {
+			delete(i[dimension], value)
+		}
+	}
+}
+
+// Query returns the pods matching all filters, e.g. {"model": "llama3", "version": "canary", "role": "decode"}.
+// An empty filter returns all pods. The terminating pods are not routable and left out. The returned slice is a
+// copy owned by the caller.
+func (c *Cache) Query(filters map[string]string) ([]*v1.Pod, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.queryLocked(filters)
+}
+
+func (c *Cache) queryLocked(filters map[string]string) ([]*v1.Pod, error) {
+	if len(filters) == 0 {
+		res := make([]*v1.Pod, 0, len(c.Pods))
+		for _, pod := range c.Pods {
+			if utils.IsPodTerminating(pod) {
+				continue
+			}
+			res = append(res, pod)
+		}
+		return res, nil
+	}
+
+	sets := make([]map[string]*v1.Pod, 0, len(filters))
+	for dimension, value := range filters {
+		if dimension == DimensionModel {
+			sets = append(sets, c.ModelToPodMapping[value])
+			continue
+		}
+		if _, ok := podIndexLabels[dimension]; !ok {
+			return nil, fmt.Errorf("unknown pod dimension: %s", dimension)
+		}
+		sets = append(sets, c.podIndex[dimension][value])
+	}
+
+	// walk the smallest set and probe the others, so the cost is bound by the most selective filter.
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+	res := make([]*v1.Pod, 0, len(sets[0]))
+	for name, pod := range sets[0] {
+		matched := true
+		for _, set := range sets[1:] {
+			if _, ok := set[name]; !ok {
+				matched = false
+				break
+			}
+		}
+		if matched && !utils.IsPodTerminating(pod) {
+			res = append(res, pod)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newIndexedPod(name, model, version, zone, role string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				modelIdentifier:                  model,
+				podIndexLabels[DimensionEngine]:  "vllm",
+				podIndexLabels[DimensionVersion]: version,
+				podIndexLabels[DimensionZone]:    zone,
+				podIndexLabels[DimensionRole]:    role,
+			},
+		},
+	}
+}
+
+func newIndexedCache() *Cache {
+	return &Cache{
+		Pods:              map[string]*v1.Pod{},
+		PodToModelMapping: map[string]map[string]struct{}{},
+		ModelToPodMapping: map[string]map[string]*v1.Pod{},
+	}
+}
+
+func podNames(pods []*v1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+var _ = Describe("Pod index", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = newIndexedCache()
+		c.addPod(newIndexedPod("llama3-stable-a", "llama3", "stable", "us-east-1a", "decode"))
+		c.addPod(newIndexedPod("llama3-stable-b", "llama3", "stable", "us-east-1b", "prefill"))
+		c.addPod(newIndexedPod("llama3-canary-a", "llama3", "canary", "us-east-1a", "decode"))
+		c.addPod(newIndexedPod("llama3-canary-b", "llama3", "canary", "us-east-1b", "decode"))
+		c.addPod(newIndexedPod("mistral-canary-a", "mistral", "canary", "us-east-1a", "decode"))
+	})
+
+	It("should intersect the filtered dimensions", func() {
+		pods, err := c.Query(map[string]string{
+			DimensionModel:   "llama3",
+			DimensionVersion: "canary",
+			DimensionZone:    "us-east-1a",
+			DimensionRole:    "decode",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(podNames(pods)).To(ConsistOf("llama3-canary-a"))
+
+		pods, _ = c.Query(map[string]string{DimensionVersion: "canary", DimensionRole: "decode"})
+		Expect(podNames(pods)).To(ConsistOf("llama3-canary-a", "llama3-canary-b", "mistral-canary-a"))
+
+		pods, _ = c.Query(map[string]string{DimensionModel: "llama3", DimensionZone: "eu-west-1a"})
+		Expect(pods).To(BeEmpty())
+
+		pods, _ = c.Query(nil)
+		Expect(pods).To(HaveLen(5))
+
+		_, err = c.Query(map[string]string{"gpu": "a100"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should include the LoRA adapters in the model dimension", func() {
+		c.addPodAndModelMappingLocked("llama3-canary-a", "llama3-lora-sql")
+		pods, err := c.Query(map[string]string{DimensionModel: "llama3-lora-sql", DimensionVersion: "canary"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(podNames(pods)).To(ConsistOf("llama3-canary-a"))
+	})
+
+	It("should follow pod updates and deletions", func() {
+		old := c.Pods["llama3-canary-b"]
+		promoted := newIndexedPod("llama3-canary-b", "llama3", "stable", "us-east-1b", "decode")
+		c.updatePod(old, promoted)
+		pods, _ := c.Query(map[string]string{DimensionVersion: "canary", DimensionModel: "llama3"})
+		Expect(podNames(pods)).To(ConsistOf("llama3-canary-a"))
+		pods, _ = c.Query(map[string]string{DimensionVersion: "stable"})
+		Expect(podNames(pods)).To(ConsistOf("llama3-stable-a", "llama3-stable-b", "llama3-canary-b"))
+
+		c.deletePod(promoted)
+		pods, _ = c.Query(map[string]string{DimensionVersion: "stable"})
+		Expect(podNames(pods)).To(ConsistOf("llama3-stable-a", "llama3-stable-b"))
+		Expect(c.podIndex[DimensionZone]["us-east-1b"]).To(HaveLen(1))
+
+		c.deletePod(c.Pods["llama3-stable-b"])
+		Expect(c.podIndex[DimensionZone]).ToNot(HaveKey("us-east-1b"))
+	})
+
+	It("should return copies from GetPodsForModel", func() {
+		pods, err := c.GetPodsForModel("llama3")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods).To(HaveLen(4))
+		delete(pods, "llama3-stable-a")
+		pods, _ = c.GetPodsForModel("llama3")
+		Expect(pods).To(HaveKey("llama3-stable-a"))
+
+		_, err = c.GetPodsForModel("llama2")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should answer consistently under concurrent updates", func() {
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				name := fmt.Sprintf("flapping-%d", w)
+				pod := newIndexedPod(name, "llama3", "canary", "us-east-1a", "decode")
+				c.addPod(pod)
+				for i := 0; i < 200; i++ {
+					version := "stable"
+					if i%2 == 1 {
+						version = "canary"
+					}
+					updated := newIndexedPod(name, "llama3", version, "us-east-1a", "decode")
+					c.updatePod(pod, updated)
+					pod = updated
+				}
+				c.deletePod(pod)
+			}(w)
+		}
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					pods, err := c.Query(map[string]string{DimensionModel: "llama3", DimensionVersion: "canary"})
+					Expect(err).ToNot(HaveOccurred())
+					for _, pod := range pods {
+						Expect(pod.Labels[podIndexLabels[DimensionVersion]]).To(Equal("canary"))
+						Expect(pod.Labels[modelIdentifier]).To(Equal("llama3"))
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		pods, _ := c.Query(map[string]string{DimensionModel: "llama3", DimensionVersion: "canary"})
+		Expect(podNames(pods)).To(ConsistOf("llama3-canary-a", "llama3-canary-b"))
+	})
+})
+
+// scanPods is the filtering by a full scan the index replaces.
+func scanPods(c *Cache, model, version, zone, role string) []*v1.Pod {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := []*v1.Pod{}
+	for _, pod := range c.Pods {
+		if pod.Labels[modelIdentifier] == model &&
+			pod.Labels[podIndexLabels[DimensionVersion]] == version &&
+			pod.Labels[podIndexLabels[DimensionZone]] == zone &&
+			pod.Labels[podIndexLabels[DimensionRole]] == role {
+			res = append(res, pod)
+		}
+	}
+	return res
+}
+
+func newBenchmarkCache(size int) *Cache {
+	c := newIndexedCache()
+	zones := []string{"us-east-1a", "us-east-1b", "us-east-1c"}
+	roles := []string{"prefill", "decode"}
+	for i := 0; i < size; i++ {
+		version := "stable"
+		if i%10 == 0 {
+			version = "canary"
+		}
+		model := fmt.Sprintf("model-%d", i%20)
+		c.addPod(newIndexedPod(fmt.Sprintf("pod-%d", i), model, version, zones[i%len(zones)], roles[i%len(roles)]))
+	}
+	return c
+}
+
+func BenchmarkPodQuery(b *testing.B) {
+	for _, size := range []int{1000, 10000} {
+		c := newBenchmarkCache(size)
+		filters := map[string]string{
+			DimensionModel:   "model-0",
+			DimensionVersion: "canary",
+			DimensionZone:    "us-east-1a",
+			DimensionRole:    "prefill",
+		}
+		b.Run(fmt.Sprintf("scan-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scanPods(c, "model-0", "canary", "us-east-1a", "prefill")
+			}
+		})
+		b.Run(fmt.Sprintf("index-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = c.Query(filters)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	defaultPodQuarantineFailures = 3
+	defaultPodQuarantinePeriod   = 30 * time.Second
+)
+
+var podQuarantines = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_gateway_pod_quarantines_total",
+	Help: "Number of times the gateway quarantined a pod after consecutive failures to connect to it.",
+}, []string{"pod"})
+
+func init() {
+	prometheus.MustRegister(podQuarantines)
+}
+
+// podConnectFailures is the connection failures of a pod since its last successful connection.
+type podConnectFailures struct {
+	consecutive int
+	// quarantinedUntil is the end of the quarantine of the pod, zero if it is not quarantined.
+	quarantinedUntil time.Time
+}
+
+// podQuarantine counts the consecutive failures of the gateway to connect to the pods, and quarantines a pod for
+// the quarantine period once it failed maxFailures times in a row, e.g. because its container was just killed. A
+// quarantined pod is not routed to while the other pods of its model are routable.
+type podQuarantine struct {
+	mu          sync.Mutex
+	maxFailures int
+	period      time.Duration
+	pods        map[string]*podConnectFailures // pod_name: connection failures
+}
+
+func newPodQuarantine(maxFailures int, period time.Duration) *podQuarantine {
+	return &podQuarantine{maxFailures: maxFailures, period: period, pods: map[string]*podConnectFailures{}}
+}
+
+// newPodQuarantineFromEnv reads the quarantine from AIBRIX_POD_QUARANTINE_FAILURES and
+// AIBRIX_POD_QUARANTINE_SECONDS.
+func newPodQuarantineFromEnv() *podQuarantine {
+	maxFailures, period := defaultPodQuarantineFailures, defaultPodQuarantinePeriod
+	if value := utils.LoadEnv("AIBRIX_POD_QUARANTINE_FAILURES", ""); value != "" {
+		if intValue, err := strconv.Atoi(value); err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_POD_QUARANTINE_FAILURES: %s, falling back to default", value)
+		} else {
+			maxFailures = intValue
+		}
+	}
+	if value := utils.LoadEnv("AIBRIX_POD_QUARANTINE_SECONDS", ""); value != "" {
+		if intValue, err := strconv.Atoi(value); err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_POD_QUARANTINE_SECONDS: %s, falling back to default", value)
+		} else {
+			period = time.Duration(intValue) * time.Second
+		}
+	}
+	return newPodQuarantine(maxFailures, period)
+}
+
+// fail records a failure to connect to the pod, it returns whether the failure quarantined the pod.
+func (q *podQuarantine) fail(podName string, now time.Time) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pod, ok := q.pods[podName]
+	if !ok {
+		pod = &podConnectFailures{}
+		q.pods[podName] = pod
+	}
+	pod.consecutive++
+	if pod.consecutive < q.maxFailures || now.Before(pod.quarantinedUntil) {
+		return false
+	}
+	pod.consecutive = 0
+	pod.quarantinedUntil = now.Add(q.period)
+	podQuarantines.WithLabelValues(podName).Inc()
+	klog.InfoS("pod quarantined after consecutive connection failures", "pod", podName, "failures", q.maxFailures, "until", pod.quarantinedUntil)
+	return true
+}
+
+// succeed records a successful connection to the pod, which resets its failures.
+func (q *podQuarantine) succeed(podName string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pods, podName)
+}
+
+// filterPods drops the quarantined pods, unless all the pods are quarantined.
+func (q *podQuarantine) filterPods(pods []*v1.Pod, now time.Time) []*v1.Pod {
+	if q == nil {
+		return pods
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pods) == 0 {
+		return pods
+	}
+	res := make([]*v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if state, ok := q.pods[pod.Name]; ok && now.Before(state.quarantinedUntil) {
+			continue
+		}
+		res = append(res, pod)
+	}
+	if len(res) == 0 {
+		return pods
+	}
+	return res
+}
+
+// deletePod forgets the failures of a deleted pod.
+func (q *podQuarantine) deletePod(podName string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pods, podName)
+	podQuarantines.DeleteLabelValues(podName)
+}
+
+// RecordPodConnectFailure records a failure of the gateway to connect to the pod, it returns whether the pod was
+// quarantined by the failure.
+func (c *Cache) RecordPodConnectFailure(podName string) bool {
+	return c.podQuarantine.fail(podName, time.Now())
+}
+
+// RecordPodConnectSuccess records a successful connection of the gateway to the pod.
+func (c *Cache) RecordPodConnectSuccess(podName string) {
+	c.podQuarantine.succeed(podName)
+}
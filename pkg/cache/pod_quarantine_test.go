@@ -0,0 +1,66 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Pod quarantine", func() {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pods := []*v1.Pod{newAutoscaledPod("llama-7b-0", "llama-7b", "llama-7b"), newAutoscaledPod("llama-7b-1", "llama-7b", "llama-7b")}
+
+	It("should quarantine a pod after consecutive connection failures", func() {
+		q := newPodQuarantine(3, 30*time.Second)
+		Expect(q.fail("llama-7b-0", base)).To(BeFalse())
+		Expect(q.fail("llama-7b-0", base)).To(BeFalse())
+		// a successful connection resets the failures.
+		q.succeed("llama-7b-0")
+		Expect(q.fail("llama-7b-0", base)).To(BeFalse())
+		Expect(q.fail("llama-7b-0", base)).To(BeFalse())
+		Expect(q.filterPods(pods, base)).To(HaveLen(2))
+
+		Expect(q.fail("llama-7b-0", base)).To(BeTrue())
+		filtered := q.filterPods(pods, base)
+		Expect(filtered).To(HaveLen(1))
+		Expect(filtered[0].Name).To(Equal("llama-7b-1"))
+		Expect(testutil.ToFloat64(podQuarantines.WithLabelValues("llama-7b-0"))).To(BeNumerically("==", 1))
+
+		// the pod is routed to again after the quarantine period.
+		Expect(q.filterPods(pods, base.Add(30*time.Second))).To(HaveLen(2))
+
+		q.deletePod("llama-7b-0")
+		Expect(q.pods).To(BeEmpty())
+	})
+
+	It("should keep the pods when all of them are quarantined", func() {
+		q := newPodQuarantine(1, time.Minute)
+		Expect(q.fail("llama-7b-0", base)).To(BeTrue())
+		Expect(q.fail("llama-7b-1", base)).To(BeTrue())
+		Expect(q.filterPods(pods, base)).To(HaveLen(2))
+
+		// a disabled quarantine routes to every pod.
+		var disabled *podQuarantine
+		Expect(disabled.fail("llama-7b-0", base)).To(BeFalse())
+		Expect(disabled.filterPods(pods, base)).To(HaveLen(2))
+	})
+})
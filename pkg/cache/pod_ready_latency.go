@@ -0,0 +1,223 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// podReadyLatencyHistorySize bounds the pod ready latencies kept per model.
+	podReadyLatencyHistorySize = 64
+	// pending scale-ups which are not followed by a ready pod within maxPendingScaleUpAge are dropped,
+	// e.g. when the new pods never become ready.
+	maxPendingScaleUpAge             = time.Hour
+	defaultRetryAfterQueueStepInMsec = 500
+)
+
+var retryAfterQueueStep = getRetryAfterQueueStep()
+
+func getRetryAfterQueueStep() time.Duration {
+	value := utils.LoadEnv("AIBRIX_RETRY_AFTER_QUEUE_STEP_MS", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue < 0 {
+			klog.Infof("invalid AIBRIX_RETRY_AFTER_QUEUE_STEP_MS: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_RETRY_AFTER_QUEUE_STEP_MS env value for retry after queue step: %d ms", intValue)
+			return time.Duration(intValue) * time.Millisecond
+		}
+	}
+	klog.Infof("using default retry after queue step: %d ms", defaultRetryAfterQueueStepInMsec)
+	return defaultRetryAfterQueueStepInMsec * time.Millisecond
+}
+
+// latencyHistory is a bounded ring buffer of the most recent latencies.
+type latencyHistory struct {
+	samples []time.Duration
+	next    int
+}
+
+func (h *latencyHistory) add(latency time.Duration) {
+	if len(h.samples) < podReadyLatencyHistorySize {
+		h.samples = append(h.samples, latency)
+		return
+	}
+	h.samples[h.next] = latency
+	h.next = (h.next + 1) % podReadyLatencyHistorySize
+}
+
+// percentile returns the nearest-rank percentile of the samples.
+func (h *latencyHistory) percentile(p float64) time.Duration {
+	if len(h.samples) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(h.samples))
+	copy(sorted, h.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// observeScaleUpLocked records that the autoscaler of a workload decided to add replicas at the given time.
+func (c *Cache) observeScaleUpLocked(workload string, replicas int32, decidedAt time.Time) {
+	if c.pendingScaleUps == nil {
+		c.pendingScaleUps = map[string][]time.Time{}
+	}
+	for i := int32(0); i < replicas; i++ {
+		c.pendingScaleUps[workload] = append(c.pendingScaleUps[workload], decidedAt)
+	}
+}
+
+// observeScaleDownLocked drops the most recent pending scale-ups of a workload which were reverted.
+func (c *Cache) observeScaleDownLocked(workload string, replicas int32) {
+	pending := c.pendingScaleUps[workload]
+	if int(replicas) >= len(pending) {
+		delete(c.pendingScaleUps, workload)
+		return
+	}
+	c.pendingScaleUps[workload] = pending[:len(pending)-int(replicas)]
+}
+
+// observePodReadyLocked matches a pod becoming routable with the oldest pending scale-up of its workload
+// and records the latency for the model of the pod.
+func (c *Cache) observePodReadyLocked(pod *v1.Pod, readyAt time.Time) {
+	modelName, ok := pod.Labels[modelIdentifier]
+	if !ok {
+		return
+	}
+	workload, ok := getWorkloadKeyForPod(pod)
+	if !ok {
+		return
+	}
+
+	pending := c.pendingScaleUps[workload]
+	for len(pending) > 0 && readyAt.Sub(pending[0]) > maxPendingScaleUpAge {
+		pending = pending[1:]
+	}
+	if len(pending) == 0 {
+		delete(c.pendingScaleUps, workload)
+		return
+	}
+	decidedAt := pending[0]
+	if len(pending) == 1 {
+		delete(c.pendingScaleUps, workload)
+	} else {
+		c.pendingScaleUps[workload] = pending[1:]
+	}
+	if readyAt.Before(decidedAt) {
+		// the pod was started before the scale-up decision, e.g. a restart.
+		return
+	}
+
+	if c.podReadyLatencies == nil {
+		c.podReadyLatencies = map[string]*latencyHistory{}
+	}
+	history, ok := c.podReadyLatencies[modelName]
+	if !ok {
+		history = &latencyHistory{}
+		c.podReadyLatencies[modelName] = history
+	}
+	history.add(readyAt.Sub(decidedAt))
+	klog.V(4).InfoS("observed pod ready latency", "model", modelName, "pod", pod.Name, "latency", readyAt.Sub(decidedAt))
+}
+
+// isPodRoutable returns whether the gateway routes requests to the pod.
+func isPodRoutable(pod *v1.Pod) bool {
+	return pod.Status.PodIP != "" && !utils.IsPodTerminating(pod) && utils.IsPodReady(pod)
+}
+
+// getPodReadyTime returns when the pod became ready, falling back to now if the transition time is unknown.
+func getPodReadyTime(pod *v1.Pod, now time.Time) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady && !condition.LastTransitionTime.IsZero() {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return now
+}
+
+// GetPodReadyLatency returns the P50 and P90 of the time from a scale-up decision to a routable pod of the model.
+func (c *Cache) GetPodReadyLatency(modelName string) (time.Duration, time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	history, ok := c.podReadyLatencies[modelName]
+	if !ok || len(history.samples) == 0 {
+		return 0, 0, false
+	}
+	return history.percentile(50), history.percentile(90), true
+}
+
+// EstimateRetryAfter estimates when a request of the model rejected at the given time can be served.
+// While a scale-up is in flight the estimate is anchored to its decision, so that all requests rejected during
+// the same wake-up are pointed at the same point in time, otherwise a new scale-up is expected to take the
+// median latency. The estimate grows with the position of the request in the queue of the model.
+func (c *Cache) EstimateRetryAfter(modelName string, now time.Time) (time.Duration, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	history, ok := c.podReadyLatencies[modelName]
+	if !ok || len(history.samples) == 0 {
+		return 0, false
+	}
+
+	wakeUp := history.percentile(50)
+	pendingReplicas := 0
+	var oldestDecision time.Time
+	seen := map[string]struct{}{}
+	for _, pod := range c.ModelToPodMapping[modelName] {
+		workload, ok := getWorkloadKeyForPod(pod)
+		if !ok {
+			continue
+		}
+		if _, ok := seen[workload]; ok {
+			continue
+		}
+		seen[workload] = struct{}{}
+		pending := c.pendingScaleUps[workload]
+		pendingReplicas += len(pending)
+		if len(pending) > 0 && (oldestDecision.IsZero() || pending[0].Before(oldestDecision)) {
+			oldestDecision = pending[0]
+		}
+	}
+	if !oldestDecision.IsZero() {
+		// the conservative P90 makes retries land after the pods are ready rather than before.
+		wakeUp = oldestDecision.Add(history.percentile(90)).Sub(now)
+		if wakeUp < 0 {
+			wakeUp = 0
+		}
+	}
+
+	capacity := len(utils.FilterReadyPods(c.ModelToPodMapping[modelName])) + pendingReplicas
+	if capacity < 1 {
+		capacity = 1
+	}
+	queuePosition := int(c.GetPendingRequestCount(modelName)) / capacity
+	return wakeUp + time.Duration(queuePosition)*retryAfterQueueStep, true
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+// readyPod returns a copy of the pod which became routable at the given time.
+func readyPod(pod *v1.Pod, at time.Time) *v1.Pod {
+	ready := pod.DeepCopy()
+	ready.Status.PodIP = "10.0.0.1"
+	ready.Status.Conditions = []v1.PodCondition{
+		{Type: v1.PodReady, Status: v1.ConditionTrue, LastTransitionTime: metav1.NewTime(at)},
+	}
+	return ready
+}
+
+var _ = Describe("Pod ready latency", func() {
+	var c *Cache
+	var pa *autoscalingv1alpha1.PodAutoscaler
+	var podIndex int
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// scaleUp replays a scale-up decision of the autoscaler at the given time.
+	scaleUp := func(at time.Time) {
+		updated := pa.DeepCopy()
+		updated.Status.DesiredScale++
+		updated.Status.LastScaleTime = &metav1.Time{Time: at}
+		c.updatePodAutoscaler(pa, updated)
+		pa = updated
+	}
+	// startPod replays a new pod of the deployment becoming routable at the given time.
+	startPod := func(at time.Time) {
+		pod := newAutoscaledPod(fmt.Sprintf("llama-7b-%d", podIndex), "llama-7b", "llama-7b")
+		podIndex++
+		c.addPod(pod)
+		c.updatePod(pod, readyPod(pod, at))
+	}
+
+	BeforeEach(func() {
+		c = newTraceCache()
+		c.Pods = map[string]*v1.Pod{}
+		c.PodToModelMapping = map[string]map[string]struct{}{}
+		c.ModelToPodMapping = map[string]map[string]*v1.Pod{}
+		c.PodAutoscalers = map[string]*autoscalingv1alpha1.PodAutoscaler{}
+		c.pendingScaleUps = map[string][]time.Time{}
+		c.podReadyLatencies = map[string]*latencyHistory{}
+		pa = newTestPodAutoscaler("llama-7b-kpa", "llama-7b", 0, 1000, nil)
+		c.addPodAutoscaler(pa)
+		podIndex = 0
+	})
+
+	It("should converge to the latency of the recent scale-ups", func() {
+		_, _, ok := c.GetPodReadyLatency("llama-7b")
+		Expect(ok).To(BeFalse())
+
+		latencies := []time.Duration{25 * time.Second, 30 * time.Second, 35 * time.Second}
+		at := base
+		for i := 0; i < 99; i++ {
+			scaleUp(at)
+			startPod(at.Add(latencies[i%len(latencies)]))
+			at = at.Add(10 * time.Minute)
+		}
+		p50, p90, ok := c.GetPodReadyLatency("llama-7b")
+		Expect(ok).To(BeTrue())
+		Expect(p50).To(Equal(30 * time.Second))
+		Expect(p90).To(Equal(35 * time.Second))
+		Expect(c.podReadyLatencies["llama-7b"].samples).To(HaveLen(podReadyLatencyHistorySize))
+		Expect(c.pendingScaleUps).To(BeEmpty())
+
+		// the pods become slower, e.g. a larger image, the history forgets the old latencies.
+		for i := 0; i < podReadyLatencyHistorySize; i++ {
+			scaleUp(at)
+			startPod(at.Add(time.Minute))
+			at = at.Add(10 * time.Minute)
+		}
+		p50, p90, _ = c.GetPodReadyLatency("llama-7b")
+		Expect(p50).To(Equal(time.Minute))
+		Expect(p90).To(Equal(time.Minute))
+	})
+
+	It("should anchor the retry after estimate to the pending scale-up", func() {
+		for i := 0; i < 10; i++ {
+			at := base.Add(time.Duration(i) * time.Hour)
+			scaleUp(at)
+			startPod(at.Add(40 * time.Second))
+		}
+
+		// the model is cold again, a scale-up is pending and its pod is not ready yet.
+		decidedAt := base.Add(24 * time.Hour)
+		scaleUp(decidedAt)
+		c.addPod(newAutoscaledPod("llama-7b-cold", "llama-7b", "llama-7b"))
+
+		retryAfter, ok := c.EstimateRetryAfter("llama-7b", decidedAt.Add(10*time.Second))
+		Expect(ok).To(BeTrue())
+		Expect(retryAfter).To(Equal(30 * time.Second))
+		retryAfter, _ = c.EstimateRetryAfter("llama-7b", decidedAt.Add(25*time.Second))
+		Expect(retryAfter).To(Equal(15*time.Second), "requests rejected later are pointed at the same wake-up")
+		retryAfter, _ = c.EstimateRetryAfter("llama-7b", decidedAt.Add(time.Minute))
+		Expect(retryAfter).To(Equal(time.Duration(0)), "the pod is late")
+
+		// 10 ready pods and 1 pending replica serve 22 queued requests, the request is 2 steps behind.
+		for i := 0; i < 22; i++ {
+			c.AddRequestCount(fmt.Sprintf("req-%d", i), "llama-7b")
+		}
+		retryAfter, _ = c.EstimateRetryAfter("llama-7b", decidedAt.Add(10*time.Second))
+		Expect(retryAfter).To(Equal(30*time.Second + 2*retryAfterQueueStep))
+
+		_, ok = c.EstimateRetryAfter("llama-70b", decidedAt)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should only measure pods of pending scale-ups", func() {
+		// a pod becoming ready without a scale-up decision, e.g. a restart, is not measured.
+		startPod(base.Add(time.Minute))
+		_, _, ok := c.GetPodReadyLatency("llama-7b")
+		Expect(ok).To(BeFalse())
+
+		// a reverted scale-up is not waited for.
+		scaleUp(base)
+		reverted := pa.DeepCopy()
+		reverted.Status.DesiredScale--
+		c.updatePodAutoscaler(pa, reverted)
+		pa = reverted
+		Expect(c.pendingScaleUps).To(BeEmpty())
+
+		// a pod which never became ready is forgotten.
+		scaleUp(base)
+		startPod(base.Add(maxPendingScaleUpAge + time.Minute))
+		_, _, ok = c.GetPodReadyLatency("llama-7b")
+		Expect(ok).To(BeFalse())
+	})
+})
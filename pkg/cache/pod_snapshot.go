@@ -0,0 +1,194 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+)
+
+// PodSnapshot is an immutable copy of the metrics of a pod, committed at the end of each scrape of the pod. A router
+// reading several metrics of a pod from one snapshot reads them from the same scrape, while two calls of
+// GetPodModelMetric may straddle a scrape and mix the metrics of two scrapes.
+type PodSnapshot struct {
+	podName         string
+	scrapeTime      time.Time
+	podMetrics      map[string]metrics.MetricValue            // metric_name: metric_val
+	modelMetrics    map[string]map[string]metrics.MetricValue // model_name: map[metric_name]metric_val
+	metricTimes     map[string]time.Time                      // model_name/metric_name: scrape_time
+	metricTTL       time.Duration                             // freshness of the metrics, 0 if unchecked
+	histogramDeltas map[string]HistogramDelta                 // model_name/metric_name: increase over the last two scrapes
+}
+
+// NewPodSnapshot returns a snapshot of the given metrics of the pod, whose freshness is not checked. It is meant for
+// the fake caches of the routers tests, the cache commits its own snapshots.
+func NewPodSnapshot(podName string, podMetrics map[string]metrics.MetricValue, modelMetrics map[string]map[string]metrics.MetricValue) PodSnapshot {
+	return PodSnapshot{
+		podName:      podName,
+		scrapeTime:   time.Now(),
+		podMetrics:   podMetrics,
+		modelMetrics: modelMetrics,
+	}
+}
+
+// PodName returns the name of the pod of the snapshot.
+func (s PodSnapshot) PodName() string {
+	return s.podName
+}
+
+// ScrapeTime returns the time the metrics endpoint of the pod was last scraped successfully before the snapshot was
+// committed, false if it never was.
+func (s PodSnapshot) ScrapeTime() (time.Time, bool) {
+	return s.scrapeTime, !s.scrapeTime.IsZero()
+}
+
+// PodMetric returns the metric of the pod of the snapshot, with the errors of GetPodMetric.
+func (s PodSnapshot) PodMetric(metricName string) (metrics.MetricValue, error) {
+	return lookupPodMetric(s.podMetrics, s.modelMetrics, s.metricTimes, s.metricTTL, s.podName, metricName, time.Now())
+}
+
+// lookupPodMetric returns the pod scope metric, or the metric of the models of the pod aggregated across the models
+// with metrics.SumMetricValues, e.g. the running requests of all the models of the pod.
+func lookupPodMetric(podMetrics map[string]metrics.MetricValue, modelMetrics map[string]map[string]metrics.MetricValue,
+	metricTimes map[string]time.Time, ttl time.Duration, podName, metricName string, now time.Time) (metrics.MetricValue, error) {
+	if podMetrics == nil && modelMetrics == nil {
+		return nil, fmt.Errorf("pod does not exist in the podMetrics cache")
+	}
+
+	if metricVal, ok := podMetrics[metricName]; ok {
+		if err := checkMetricFreshness(metricTimes, ttl, podName, "", metricName, now); err != nil {
+			return nil, err
+		}
+		return metricVal, nil
+	}
+
+	var values []metrics.MetricValue
+	for modelName, modelValues := range modelMetrics {
+		metricVal, ok := modelValues[metricName]
+		if !ok {
+			continue
+		}
+		if err := checkMetricFreshness(metricTimes, ttl, podName, modelName, metricName, now); err != nil {
+			return nil, err
+		}
+		values = append(values, metricVal)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no metric available for %v", metricName)
+	}
+	return metrics.SumMetricValues(values)
+}
+
+// PodModelMetric returns the metric of the model of the snapshot, with the errors of GetPodModelMetric.
+func (s PodSnapshot) PodModelMetric(modelName, metricName string) (metrics.MetricValue, error) {
+	if s.modelMetrics == nil {
+		return nil, fmt.Errorf("pod does not exist in the podMetrics cache")
+	}
+
+	modelMetrics, ok := s.modelMetrics[modelName]
+	if !ok {
+		return nil, fmt.Errorf("model does not exist in the podMetrics cache")
+	}
+
+	metricVal, ok := modelMetrics[metricName]
+	if !ok {
+		return nil, fmt.Errorf("no metric available for %v", metricName)
+	}
+	if err := checkMetricFreshness(s.metricTimes, s.metricTTL, s.podName, modelName, metricName, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return metricVal, nil
+}
+
+// PodModelHistogramDelta returns the increase of the sum and the count of the histogram metric of the model between
+// the last two scrapes of the pod. A histogram scraped once, or whose counters were reset at the last scrape, has no
+// delta yet.
+func (s PodSnapshot) PodModelHistogramDelta(modelName, metricName string) (HistogramDelta, error) {
+	delta, ok := s.histogramDeltas[metricTimeKey(modelName, metricName)]
+	if !ok {
+		return HistogramDelta{}, fmt.Errorf("no delta available for %v", metricName)
+	}
+	if err := checkMetricFreshness(s.metricTimes, s.metricTTL, s.podName, modelName, metricName, time.Now()); err != nil {
+		return HistogramDelta{}, err
+	}
+	return delta, nil
+}
+
+// WithHistogramDelta returns a copy of the snapshot with the delta of the histogram metric of the model, like
+// NewPodSnapshot it is meant for the fake caches of the routers tests.
+func (s PodSnapshot) WithHistogramDelta(modelName, metricName string, delta HistogramDelta) PodSnapshot {
+	deltas := make(map[string]HistogramDelta, len(s.histogramDeltas)+1)
+	for key, value := range s.histogramDeltas {
+		deltas[key] = value
+	}
+	deltas[metricTimeKey(modelName, metricName)] = delta
+	s.histogramDeltas = deltas
+	return s
+}
+
+// GetPodSnapshot returns the metrics of the pod committed at the end of its last scrape. The snapshot of a pod never
+// scraped is empty, and its reads fail as for a pod missing from the cache.
+func (c *Cache) GetPodSnapshot(podName string) PodSnapshot {
+	c.mu.RLock()
+	snapshot, ok := c.podSnapshots[podName]
+	c.mu.RUnlock()
+
+	if !ok {
+		return PodSnapshot{podName: podName}
+	}
+	return *snapshot
+}
+
+// commitPodSnapshotLocked copies the metrics of the pod into a new snapshot. The metric values are replaced rather
+// than updated by the scrapes, so the snapshot shares them and only copies the maps holding them.
+func (c *Cache) commitPodSnapshotLocked(podName string) {
+	podMetrics := make(map[string]metrics.MetricValue, len(c.PodMetrics[podName]))
+	for metricName, metricVal := range c.PodMetrics[podName] {
+		podMetrics[metricName] = metricVal
+	}
+	modelMetrics := make(map[string]map[string]metrics.MetricValue, len(c.PodModelMetrics[podName]))
+	for modelName, values := range c.PodModelMetrics[podName] {
+		modelMetrics[modelName] = make(map[string]metrics.MetricValue, len(values))
+		for metricName, metricVal := range values {
+			modelMetrics[modelName][metricName] = metricVal
+		}
+	}
+	metricTimes := make(map[string]time.Time, len(c.podMetricTimes[podName]))
+	for key, scrapeTime := range c.podMetricTimes[podName] {
+		metricTimes[key] = scrapeTime
+	}
+	histogramDeltas := make(map[string]HistogramDelta, len(c.histogramDeltas[podName]))
+	for key, delta := range c.histogramDeltas[podName] {
+		histogramDeltas[key] = delta
+	}
+
+	if c.podSnapshots == nil {
+		c.podSnapshots = map[string]*PodSnapshot{}
+	}
+	c.podSnapshots[podName] = &PodSnapshot{
+		podName:         podName,
+		scrapeTime:      c.podScrapeTimes[podName],
+		podMetrics:      podMetrics,
+		modelMetrics:    modelMetrics,
+		metricTimes:     metricTimes,
+		metricTTL:       c.podMetricTTL,
+		histogramDeltas: histogramDeltas,
+	}
+}
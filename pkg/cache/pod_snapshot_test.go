@@ -0,0 +1,208 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+)
+
+// newSnapshotCache returns a cache holding the pod p1 serving the model llama.
+func newSnapshotCache() *Cache {
+	c := newTraceCache()
+	c.podMetricTTL = 10 * time.Second
+	c.PodMetrics = map[string]map[string]metrics.MetricValue{"p1": {}}
+	c.PodModelMetrics = map[string]map[string]map[string]metrics.MetricValue{"p1": {}}
+	return c
+}
+
+// scrapeSnapshotPod records a scrape of p1 whose two metrics of llama share the same value, and commits it.
+func scrapeSnapshotPod(c *Cache, value float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_ = c.updatePodRecordLocked("p1", "", metrics.GPUCacheUsagePerc, metrics.PodMetricScope, &metrics.SimpleMetricValue{Value: value})
+	_ = c.updatePodRecordLocked("p1", "llama", metrics.NumRequestsRunning, metrics.PodModelMetricScope, &metrics.SimpleMetricValue{Value: value})
+	_ = c.updatePodRecordLocked("p1", "llama", metrics.NumRequestsWaiting, metrics.PodModelMetricScope, &metrics.SimpleMetricValue{Value: value})
+	c.commitPodSnapshotLocked("p1")
+}
+
+var _ = Describe("Pod snapshot", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = newSnapshotCache()
+	})
+
+	It("should serve the metrics committed by the last scrape", func() {
+		scrapeSnapshotPod(c, 1)
+
+		snapshot := c.GetPodSnapshot("p1")
+		Expect(snapshot.PodName()).To(Equal("p1"))
+		value, err := snapshot.PodMetric(metrics.GPUCacheUsagePerc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(1.0))
+		value, err = snapshot.PodModelMetric("llama", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(1.0))
+
+		_, err = snapshot.PodModelMetric("mistral", metrics.NumRequestsWaiting)
+		Expect(err).To(HaveOccurred())
+		_, err = snapshot.PodModelMetric("llama", metrics.AvgPromptThroughputToksPerS)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not change after the following scrapes", func() {
+		scrapeSnapshotPod(c, 1)
+		snapshot := c.GetPodSnapshot("p1")
+
+		// an uncommitted update is not visible in the snapshots.
+		c.mu.Lock()
+		Expect(c.updatePodRecordLocked("p1", "llama", metrics.NumRequestsWaiting, metrics.PodModelMetricScope, &metrics.SimpleMetricValue{Value: 2})).To(Succeed())
+		c.mu.Unlock()
+		value, err := c.GetPodSnapshot("p1").PodModelMetric("llama", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(1.0))
+
+		scrapeSnapshotPod(c, 3)
+		value, err = snapshot.PodModelMetric("llama", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(1.0))
+		value, err = c.GetPodSnapshot("p1").PodModelMetric("llama", metrics.NumRequestsWaiting)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(3.0))
+	})
+
+	It("should report the metrics of the pods never scraped or evicted as missing", func() {
+		_, err := c.GetPodSnapshot("p1").PodModelMetric("llama", metrics.NumRequestsWaiting)
+		Expect(err).To(MatchError("pod does not exist in the podMetrics cache"))
+
+		scrapeSnapshotPod(c, 1)
+		c.mu.Lock()
+		c.evictPodMetricsLocked("p1")
+		c.mu.Unlock()
+		_, err = c.GetPodSnapshot("p1").PodMetric(metrics.GPUCacheUsagePerc)
+		Expect(err).To(MatchError("pod does not exist in the podMetrics cache"))
+	})
+
+	It("should report the metrics scraped before the TTL as stale", func() {
+		c.mu.Lock()
+		Expect(c.updatePodRecordLocked("p1", "llama", metrics.NumRequestsWaiting, metrics.PodModelMetricScope, &metrics.SimpleMetricValue{Value: 1})).To(Succeed())
+		c.setMetricTimeLocked("p1", "llama", metrics.NumRequestsWaiting, time.Now().Add(-time.Minute))
+		c.commitPodSnapshotLocked("p1")
+		c.mu.Unlock()
+
+		_, err := c.GetPodSnapshot("p1").PodModelMetric("llama", metrics.NumRequestsWaiting)
+		Expect(errors.Is(err, ErrMetricStale)).To(BeTrue())
+	})
+
+	It("should aggregate the metrics of the models of the pod", func() {
+		c.mu.Lock()
+		Expect(c.updatePodRecordLocked("p1", "llama", metrics.NumRequestsRunning, metrics.PodModelMetricScope, &metrics.SimpleMetricValue{Value: 2})).To(Succeed())
+		Expect(c.updatePodRecordLocked("p1", "llama-lora", metrics.NumRequestsRunning, metrics.PodModelMetricScope, &metrics.SimpleMetricValue{Value: 3})).To(Succeed())
+		Expect(c.updatePodRecordLocked("p1", "llama", metrics.E2ERequestLatencySeconds, metrics.PodModelMetricScope,
+			&metrics.HistogramMetricValue{Sum: 4, Count: 2, Buckets: map[string]float64{"1": 1, "+Inf": 2}})).To(Succeed())
+		Expect(c.updatePodRecordLocked("p1", "llama-lora", metrics.E2ERequestLatencySeconds, metrics.PodModelMetricScope,
+			&metrics.HistogramMetricValue{Sum: 2, Count: 2, Buckets: map[string]float64{"1": 2, "+Inf": 2}})).To(Succeed())
+		c.commitPodSnapshotLocked("p1")
+		c.mu.Unlock()
+
+		for _, get := range []func(string) (metrics.MetricValue, error){
+			c.GetPodSnapshot("p1").PodMetric,
+			func(metricName string) (metrics.MetricValue, error) { return c.GetPodMetric("p1", metricName) },
+		} {
+			value, err := get(metrics.NumRequestsRunning)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.GetSimpleValue()).To(Equal(5.0))
+			value, err = get(metrics.E2ERequestLatencySeconds)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.GetHistogramValue()).To(Equal(&metrics.HistogramMetricValue{Sum: 6, Count: 4, Buckets: map[string]float64{"1": 3, "+Inf": 4}}))
+			Expect(value.GetHistogramValue().GetMean()).To(Equal(1.5))
+		}
+
+		value, err := c.GetPodModelMetric("p1", "llama-lora", metrics.NumRequestsRunning)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value.GetSimpleValue()).To(Equal(3.0))
+	})
+
+	It("should never mix the metrics of two scrapes", func() {
+		scrapeSnapshotPod(c, 0)
+
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					scrapeSnapshotPod(c, float64(i))
+				}
+			}
+		}()
+
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					snapshot := c.GetPodSnapshot("p1")
+					running, err := snapshot.PodModelMetric("llama", metrics.NumRequestsRunning)
+					Expect(err).NotTo(HaveOccurred())
+					waiting, err := snapshot.PodModelMetric("llama", metrics.NumRequestsWaiting)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(running.GetSimpleValue()).To(Equal(waiting.GetSimpleValue()))
+				}
+			}()
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		close(stop)
+		wg.Wait()
+	})
+})
+
+// BenchmarkPodMetricReads compares the two metric reads of a router from one snapshot with two GetPodModelMetric
+// calls.
+func BenchmarkPodMetricReads(b *testing.B) {
+	c := newSnapshotCache()
+	scrapeSnapshotPod(c, 1)
+
+	b.Run("get-pod-model-metric", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = c.GetPodModelMetric("p1", "llama", metrics.NumRequestsRunning)
+			_, _ = c.GetPodModelMetric("p1", "llama", metrics.NumRequestsWaiting)
+		}
+	})
+	b.Run("get-pod-snapshot", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			snapshot := c.GetPodSnapshot("p1")
+			_, _ = snapshot.PodModelMetric("llama", metrics.NumRequestsRunning)
+			_, _ = snapshot.PodModelMetric("llama", metrics.NumRequestsWaiting)
+		}
+	})
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// routingShareBuckets is the number of evaluation intervals covered by the rolling window of routed requests.
+	routingShareBuckets = 6
+	// routingImbalanceMinRequests is the number of routed requests per pod a window needs before its imbalance
+	// counts towards a warning, the share of a few requests is skewed by chance.
+	routingImbalanceMinRequests = 5
+
+	defaultRoutingImbalanceIntervalInSec = 10
+	defaultRoutingImbalanceThreshold     = 2.0
+	defaultRoutingImbalanceWindows       = 3
+)
+
+var (
+	routingShare = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aibrix_gateway_routing_share",
+		Help: "Share of the requests of a model routed to a pod within the rolling window.",
+	}, []string{"model", "pod"})
+	routingImbalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aibrix_gateway_routing_imbalance",
+		Help: "Ratio of the most routed requests of a pod to the mean per ready pod of a model within the rolling window, 1 is uniform.",
+	}, []string{"model"})
+)
+
+func init() {
+	prometheus.MustRegister(routingShare, routingImbalance)
+}
+
+// routingImbalanceConfig configures the detection of a model whose routed requests concentrate on a few pods.
+type routingImbalanceConfig struct {
+	// interval is the width of a bucket of the rolling window, and how often the imbalance is computed.
+	interval time.Duration
+	// threshold is the max to mean ratio above which a window is imbalanced.
+	threshold float64
+	// windows is the number of consecutive imbalanced windows that log a warning.
+	windows int
+}
+
+func getRoutingImbalanceConfig() routingImbalanceConfig {
+	config := routingImbalanceConfig{
+		interval:  defaultRoutingImbalanceIntervalInSec * time.Second,
+		threshold: defaultRoutingImbalanceThreshold,
+		windows:   defaultRoutingImbalanceWindows,
+	}
+	if value := utils.LoadEnv("AIBRIX_ROUTING_IMBALANCE_INTERVAL_SEC", ""); value != "" {
+		if intValue, err := strconv.Atoi(value); err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_ROUTING_IMBALANCE_INTERVAL_SEC: %s, falling back to default", value)
+		} else {
+			config.interval = time.Duration(intValue) * time.Second
+		}
+	}
+	if value := utils.LoadEnv("AIBRIX_ROUTING_IMBALANCE_THRESHOLD", ""); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err != nil || floatValue < 1 {
+			klog.Infof("invalid AIBRIX_ROUTING_IMBALANCE_THRESHOLD: %s, falling back to default", value)
+		} else {
+			config.threshold = floatValue
+		}
+	}
+	if value := utils.LoadEnv("AIBRIX_ROUTING_IMBALANCE_WINDOWS", ""); value != "" {
+		if intValue, err := strconv.Atoi(value); err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_ROUTING_IMBALANCE_WINDOWS: %s, falling back to default", value)
+		} else {
+			config.windows = intValue
+		}
+	}
+	return config
+}
+
+// routingWindow counts the requests of a model routed to each pod within the rolling window. The totals are
+// updated as requests are recorded and buckets expire, so the memory is bounded by the buckets and the pods.
+type routingWindow struct {
+	buckets [routingShareBuckets]map[string]int // pod_name: routed requests within an interval
+	current int64                               // interval of the newest bucket
+	totals  map[string]int                      // pod_name: routed requests within the window
+	total   int
+	// algorithm is the routing algorithm of the last recorded request.
+	algorithm string
+	// streak is the number of consecutive imbalanced windows.
+	streak int
+	// pods are the pods exported in the routing share metric.
+	pods map[string]struct{}
+}
+
+// advance moves the window to the given interval, expiring the buckets which fall out of it.
+func (w *routingWindow) advance(interval int64) {
+	if interval <= w.current {
+		return
+	}
+	for i := w.current + 1; i <= interval && i <= w.current+routingShareBuckets; i++ {
+		bucket := &w.buckets[i%routingShareBuckets]
+		for pod, count := range *bucket {
+			w.totals[pod] -= count
+			w.total -= count
+			if w.totals[pod] <= 0 {
+				delete(w.totals, pod)
+			}
+		}
+		*bucket = nil
+	}
+	w.current = interval
+}
+
+// routingShareTracker keeps the rolling window of routed requests of every model.
+type routingShareTracker struct {
+	mu     sync.Mutex
+	config routingImbalanceConfig
+	models map[string]*routingWindow
+}
+
+func newRoutingShareTracker(config routingImbalanceConfig) *routingShareTracker {
+	return &routingShareTracker{config: config, models: map[string]*routingWindow{}}
+}
+
+func (t *routingShareTracker) intervalOf(now time.Time) int64 {
+	return now.UnixNano() / int64(t.config.interval)
+}
+
+// record counts a request of the model routed to the pod by the algorithm.
+func (t *routingShareTracker) record(modelName, podName, algorithm string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	interval := t.intervalOf(now)
+	window, ok := t.models[modelName]
+	if !ok {
+		window = &routingWindow{current: interval, totals: map[string]int{}, pods: map[string]struct{}{}}
+		t.models[modelName] = window
+	}
+	window.advance(interval)
+	bucket := &window.buckets[interval%routingShareBuckets]
+	if *bucket == nil {
+		*bucket = map[string]int{}
+	}
+	(*bucket)[podName]++
+	window.totals[podName]++
+	window.total++
+	window.algorithm = algorithm
+}
+
+// routingShareStats is the routing share of a model within the rolling window.
+type routingShareStats struct {
+	// shares is the share of the routed requests per pod, the ready pods without requests included.
+	shares map[string]float64
+	// imbalance is the max to mean ratio of the routed requests per pod.
+	imbalance float64
+	total     int
+	algorithm string
+	// streak is the number of consecutive imbalanced windows, including this one.
+	streak int
+	// warn is set every time the streak reaches a multiple of the configured number of windows.
+	warn bool
+	// stale are the pods whose share is no longer reported.
+	stale []string
+}
+
+// evaluate computes the routing share of the model across its ready pods and the pods it routed requests to.
+// It returns false once the window of the model has no routed requests, in which case the window is dropped.
+func (t *routingShareTracker) evaluate(modelName string, readyPods []string, now time.Time) (routingShareStats, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	window, ok := t.models[modelName]
+	if !ok {
+		return routingShareStats{}, false
+	}
+	window.advance(t.intervalOf(now))
+
+	stats := routingShareStats{shares: map[string]float64{}, total: window.total, algorithm: window.algorithm}
+	pods := map[string]struct{}{}
+	if window.total > 0 {
+		for _, pod := range readyPods {
+			pods[pod] = struct{}{}
+		}
+		for pod := range window.totals {
+			pods[pod] = struct{}{}
+		}
+	}
+	for pod := range window.pods {
+		if _, ok := pods[pod]; !ok {
+			stats.stale = append(stats.stale, pod)
+		}
+	}
+	window.pods = pods
+	if window.total == 0 {
+		delete(t.models, modelName)
+		return stats, false
+	}
+
+	maxCount := 0
+	for pod := range pods {
+		count := window.totals[pod]
+		if count > maxCount {
+			maxCount = count
+		}
+		stats.shares[pod] = float64(count) / float64(window.total)
+	}
+	mean := float64(window.total) / float64(len(pods))
+	stats.imbalance = float64(maxCount) / mean
+
+	if window.total >= routingImbalanceMinRequests*len(pods) && stats.imbalance > t.config.threshold {
+		window.streak++
+	} else {
+		window.streak = 0
+	}
+	stats.streak = window.streak
+	stats.warn = window.streak > 0 && window.streak%t.config.windows == 0
+	return stats, true
+}
+
+// RecordRouting counts a request of the model routed to the pod by the routing algorithm.
+func (c *Cache) RecordRouting(modelName, podName, algorithm string) {
+	c.routingShares.record(modelName, podName, algorithm, time.Now())
+}
+
+// evaluateRoutingShares exports the routing share and imbalance of every model with routed requests, and logs a
+// warning for the models whose imbalance exceeded the threshold for the configured number of consecutive windows.
+func (c *Cache) evaluateRoutingShares(now time.Time) {
+	c.routingShares.mu.Lock()
+	models := make([]string, 0, len(c.routingShares.models))
+	for model := range c.routingShares.models {
+		models = append(models, model)
+	}
+	c.routingShares.mu.Unlock()
+
+	for _, model := range models {
+		c.mu.RLock()
+		readyPods := readyPodNames(c.ModelToPodMapping[model])
+		c.mu.RUnlock()
+
+		stats, ok := c.routingShares.evaluate(model, readyPods, now)
+		for _, pod := range stats.stale {
+			routingShare.DeleteLabelValues(model, pod)
+		}
+		if !ok {
+			routingImbalance.DeleteLabelValues(model)
+			continue
+		}
+		for pod, share := range stats.shares {
+			routingShare.WithLabelValues(model, pod).Set(share)
+		}
+		routingImbalance.WithLabelValues(model).Set(stats.imbalance)
+
+		if stats.warn {
+			config := c.routingShares.config
+			klog.InfoS("routing imbalance detected", "model", model, "algorithm", stats.algorithm,
+				"imbalance", stats.imbalance, "threshold", config.threshold, "consecutiveWindows", stats.streak,
+				"window", config.interval*routingShareBuckets, "requests", stats.total, "shares", stats.shares)
+		}
+	}
+}
+
+// startRoutingShareEvaluation computes the routing share of the models every interval until stopCh is closed.
+func (c *Cache) startRoutingShareEvaluation(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(c.routingShares.config.interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				c.evaluateRoutingShares(time.Now())
+			case <-stopCh:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// readyPodNames returns the names of the ready pods.
+func readyPodNames(pods map[string]*v1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range utils.FilterReadyPods(pods) {
+		names = append(names, pod.Name)
+	}
+	return names
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Routing share", func() {
+	var c *Cache
+	pods := []string{"llama-7b-0", "llama-7b-1", "llama-7b-2", "llama-7b-3"}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// route replays a window of 40 routed requests, the first skewed ones to the first pod and the others
+	// round robin across the pods.
+	route := func(window int, skewed int) {
+		at := base.Add(time.Duration(window) * 10 * time.Second)
+		for i := 0; i < 40; i++ {
+			pod := pods[i%len(pods)]
+			if i < skewed {
+				pod = pods[0]
+			}
+			c.routingShares.record("llama-7b", pod, "least-request", at)
+		}
+	}
+	evaluate := func(window int) routingShareStats {
+		stats, ok := c.routingShares.evaluate("llama-7b", pods, base.Add(time.Duration(window)*10*time.Second+9*time.Second))
+		Expect(ok).To(BeTrue())
+		return stats
+	}
+
+	BeforeEach(func() {
+		c = newTraceCache()
+		c.ModelToPodMapping = map[string]map[string]*v1.Pod{"llama-7b": {}}
+		for _, name := range pods {
+			c.ModelToPodMapping["llama-7b"][name] = readyPod(newAutoscaledPod(name, "llama-7b", "llama-7b"), base)
+		}
+		c.routingShares = newRoutingShareTracker(routingImbalanceConfig{interval: 10 * time.Second, threshold: 2, windows: 3})
+	})
+
+	It("should report a uniform routing as balanced", func() {
+		for window := 0; window < 10; window++ {
+			route(window, 0)
+			stats := evaluate(window)
+			Expect(stats.imbalance).To(BeNumerically("~", 1, 1e-9))
+			Expect(stats.shares).To(HaveLen(4))
+			Expect(stats.shares["llama-7b-3"]).To(BeNumerically("~", 0.25, 1e-9))
+			Expect(stats.streak).To(Equal(0))
+			Expect(stats.warn).To(BeFalse())
+		}
+	})
+
+	It("should warn once the routing is skewed for consecutive windows", func() {
+		warnings := 0
+		for window := 0; window < 6; window++ {
+			route(window, 40)
+			stats := evaluate(window)
+			// all requests on one of 4 pods is 4 times the mean.
+			Expect(stats.imbalance).To(BeNumerically("~", 4, 1e-9))
+			Expect(stats.shares["llama-7b-0"]).To(BeNumerically("~", 1, 1e-9))
+			Expect(stats.shares["llama-7b-1"]).To(BeZero())
+			Expect(stats.algorithm).To(Equal("least-request"))
+			Expect(stats.streak).To(Equal(window + 1))
+			if stats.warn {
+				warnings++
+			}
+		}
+		// the warning repeats every 3 imbalanced windows.
+		Expect(warnings).To(Equal(2))
+	})
+
+	It("should reset the streak once the routing is balanced again", func() {
+		for window := 0; window < 2; window++ {
+			route(window, 40)
+			Expect(evaluate(window).streak).To(Equal(window + 1))
+		}
+		// the skewed requests stay in the rolling window until they expire.
+		window := 2
+		for ; window < 2+routingShareBuckets; window++ {
+			route(window, 0)
+			if evaluate(window).streak == 0 {
+				break
+			}
+		}
+		Expect(window).To(BeNumerically("<", 2+routingShareBuckets))
+		route(window+routingShareBuckets, 0)
+		Expect(evaluate(window + routingShareBuckets).imbalance).To(BeNumerically("~", 1, 1e-9))
+	})
+
+	It("should not warn about a window with too few requests", func() {
+		c.routingShares.record("llama-7b", pods[0], "random", base)
+		stats := evaluate(0)
+		Expect(stats.imbalance).To(BeNumerically("~", 4, 1e-9))
+		Expect(stats.streak).To(Equal(0))
+	})
+
+	It("should bound the window to its buckets", func() {
+		for window := 0; window < 100; window++ {
+			route(window, 10)
+		}
+		window := c.routingShares.models["llama-7b"]
+		Expect(window.total).To(Equal(40 * routingShareBuckets))
+		buckets := 0
+		for _, bucket := range window.buckets {
+			if bucket != nil {
+				buckets++
+			}
+		}
+		Expect(buckets).To(Equal(routingShareBuckets))
+
+		// the window is dropped once its requests expired.
+		_, ok := c.routingShares.evaluate("llama-7b", pods, base.Add(200*10*time.Second))
+		Expect(ok).To(BeFalse())
+		Expect(c.routingShares.models).NotTo(HaveKey("llama-7b"))
+	})
+
+	It("should export the routing share and imbalance", func() {
+		route(0, 20)
+		c.evaluateRoutingShares(base.Add(9 * time.Second))
+		// 20 skewed and 20 round robin requests, 25 of them on the first pod.
+		Expect(testutil.ToFloat64(routingShare.WithLabelValues("llama-7b", "llama-7b-0"))).To(BeNumerically("~", 25.0/40, 1e-9))
+		Expect(testutil.ToFloat64(routingShare.WithLabelValues("llama-7b", "llama-7b-1"))).To(BeNumerically("~", 5.0/40, 1e-9))
+		Expect(testutil.ToFloat64(routingImbalance.WithLabelValues("llama-7b"))).To(BeNumerically("~", 2.5, 1e-9))
+
+		// a deleted pod is no longer reported once its requests expired.
+		delete(c.ModelToPodMapping["llama-7b"], "llama-7b-3")
+		for _, pod := range pods[:3] {
+			c.routingShares.record("llama-7b", pod, "least-request", base.Add(routingShareBuckets*10*time.Second))
+		}
+		c.evaluateRoutingShares(base.Add(routingShareBuckets*10*time.Second + 9*time.Second))
+		Expect(testutil.CollectAndCount(routingShare)).To(Equal(3))
+
+		c.evaluateRoutingShares(base.Add(200 * 10 * time.Second))
+		Expect(testutil.CollectAndCount(routingShare)).To(BeZero())
+		Expect(testutil.CollectAndCount(routingImbalance)).To(BeZero())
+	})
+})
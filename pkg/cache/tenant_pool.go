@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// tenantReservationIdentifier is the label of the pods reserved for the traffic of a tenant, e.g.
+// tenant.aibrix.ai/reserved-for: acme. The pods without the label are shared by all tenants.
+const tenantReservationIdentifier = "tenant.aibrix.ai/reserved-for"
+
+// TenantPods are the routable pods of a model for a tenant. The pods reserved for the other tenants are never part
+// of them.
+type TenantPods struct {
+	// Reserved are the routable pods reserved for the tenant.
+	Reserved map[string]*v1.Pod
+	// Shared are the routable pods not reserved for any tenant.
+	Shared map[string]*v1.Pod
+	// Reservations is the number of pods of the model reserved for the tenant, routable or not.
+	Reservations int
+}
+
+// GetTenantPodsForModel returns the routable pods of the model split between the pods reserved for the tenant and
+// the shared ones, as GetPodsForModel filters them. A request without a tenant is only served by the shared pods.
+func (c *Cache) GetTenantPodsForModel(modelName, tenant string) (TenantPods, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.ModelToPodMapping[modelName]; !ok {
+		return TenantPods{}, fmt.Errorf("%w: %s", ErrModelNotFound, modelName)
+	}
+
+	pods, err := c.queryLocked(map[string]string{DimensionModel: modelName})
+	if err != nil {
+		return TenantPods{}, err
+	}
+	var reserved, shared []*v1.Pod
+	if tenant != "" {
+		reserved, err = c.queryLocked(map[string]string{DimensionModel: modelName, DimensionTenant: tenant})
+		if err != nil {
+			return TenantPods{}, err
+		}
+	}
+	for _, pod := range pods {
+		if _, ok := pod.Labels[tenantReservationIdentifier]; !ok {
+			shared = append(shared, pod)
+		}
+	}
+	// a model behind a Service is only routed to the ready endpoints of the Service.
+	reserved = c.filterEndpointPodsLocked(modelName, reserved)
+	shared = c.filterEndpointPodsLocked(modelName, shared)
+
+	// the pods serving as many requests as their adaptive concurrency limit allows are not routable, nor the
+	// quarantined ones while others are.
+	now := time.Now()
+	return TenantPods{
+		Reserved:     toPodMap(c.podQuarantine.filterPods(c.concurrencyLimits.filterPods(reserved), now)),
+		Shared:       toPodMap(c.podQuarantine.filterPods(c.concurrencyLimits.filterPods(shared), now)),
+		Reservations: len(reserved),
+	}, nil
+}
+
+func toPodMap(pods []*v1.Pod) map[string]*v1.Pod {
+	podsMap := make(map[string]*v1.Pod, len(pods))
+	for _, pod := range pods {
+		podsMap[pod.Name] = pod
+	}
+	return podsMap
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func newReservedPod(name, model, tenant string) *v1.Pod {
+	pod := newIndexedPod(name, model, "stable", "us-east-1a", "decode")
+	if tenant != "" {
+		pod.Labels[tenantReservationIdentifier] = tenant
+	}
+	return pod
+}
+
+func podMapNames(pods map[string]*v1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for name := range pods {
+		names = append(names, name)
+	}
+	return names
+}
+
+var _ = Describe("Tenant pools", func() {
+	var c *Cache
+
+	BeforeEach(func() {
+		c = newIndexedCache()
+		c.addPod(newReservedPod("llama3-acme-a", "llama3", "acme"))
+		c.addPod(newReservedPod("llama3-acme-b", "llama3", "acme"))
+		c.addPod(newReservedPod("llama3-globex", "llama3", "globex"))
+		c.addPod(newReservedPod("llama3-shared", "llama3", ""))
+		c.addPod(newReservedPod("mistral-acme", "mistral", "acme"))
+	})
+
+	It("should split the reserved and the shared pods of the tenant", func() {
+		pods, err := c.GetTenantPodsForModel("llama3", "acme")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(podMapNames(pods.Reserved)).To(ConsistOf("llama3-acme-a", "llama3-acme-b"))
+		Expect(podMapNames(pods.Shared)).To(ConsistOf("llama3-shared"))
+		Expect(pods.Reservations).To(Equal(2))
+
+		pods, err = c.GetTenantPodsForModel("mistral", "globex")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods.Reserved).To(BeEmpty())
+		Expect(pods.Shared).To(BeEmpty())
+		Expect(pods.Reservations).To(BeZero())
+
+		_, err = c.GetTenantPodsForModel("llama2", "acme")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should never return the pods reserved for other tenants", func() {
+		pods, err := c.GetTenantPodsForModel("llama3", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods.Reserved).To(BeEmpty())
+		Expect(podMapNames(pods.Shared)).To(ConsistOf("llama3-shared"))
+
+		pods, _ = c.GetTenantPodsForModel("llama3", "initech")
+		Expect(pods.Reserved).To(BeEmpty())
+		Expect(podMapNames(pods.Shared)).To(ConsistOf("llama3-shared"))
+	})
+
+	It("should follow the reservation label of the pods", func() {
+		old := c.Pods["llama3-acme-b"]
+		c.updatePod(old, newReservedPod("llama3-acme-b", "llama3", ""))
+		pods, _ := c.GetTenantPodsForModel("llama3", "acme")
+		Expect(podMapNames(pods.Reserved)).To(ConsistOf("llama3-acme-a"))
+		Expect(podMapNames(pods.Shared)).To(ConsistOf("llama3-shared", "llama3-acme-b"))
+
+		c.deletePod(c.Pods["llama3-acme-a"])
+		pods, _ = c.GetTenantPodsForModel("llama3", "acme")
+		Expect(pods.Reservations).To(BeZero())
+	})
+})
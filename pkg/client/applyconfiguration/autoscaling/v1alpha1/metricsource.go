@@ -31,6 +31,7 @@ type MetricSourceApplyConfiguration struct {
 	Port             *string                    `json:"port,omitempty"`
 	TargetMetric     *string                    `json:"targetMetric,omitempty"`
 	TargetValue      *string                    `json:"targetValue,omitempty"`
+	KeyPattern       *string                    `json:"keyPattern,omitempty"`
 }
 
 // MetricSourceApplyConfiguration constructs a declarative configuration of the MetricSource type for use with
@@ -94,3 +95,11 @@ func (b *MetricSourceApplyConfiguration) WithTargetValue(value string) *MetricSo
 	b.TargetValue = &value
 	return b
 }
+
+// WithKeyPattern sets the KeyPattern field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the KeyPattern field is set to the value of the last call.
+func (b *MetricSourceApplyConfiguration) WithKeyPattern(value string) *MetricSourceApplyConfiguration {
+	b.KeyPattern = &value
+	return b
+}
@@ -25,13 +25,15 @@ import (
 // ModelAdapterSpecApplyConfiguration represents a declarative configuration of the ModelAdapterSpec type for use
 // with apply.
 type ModelAdapterSpecApplyConfiguration struct {
-	BaseModel            *string                             `json:"baseModel,omitempty"`
-	PodSelector          *v1.LabelSelectorApplyConfiguration `json:"podSelector,omitempty"`
-	SchedulerName        *string                             `json:"schedulerName,omitempty"`
-	ArtifactURL          *string                             `json:"artifactURL,omitempty"`
-	CredentialsSecretRef *corev1.LocalObjectReference        `json:"credentialsSecretRef,omitempty"`
-	Replicas             *int32                              `json:"replicas,omitempty"`
-	AdditionalConfig     map[string]string                   `json:"additionalConfig,omitempty"`
+	BaseModel            *string                                  `json:"baseModel,omitempty"`
+	PodSelector          *v1.LabelSelectorApplyConfiguration      `json:"podSelector,omitempty"`
+	SchedulerName        *string                                  `json:"schedulerName,omitempty"`
+	ArtifactURL          *string                                  `json:"artifactURL,omitempty"`
+	CredentialsSecretRef *corev1.LocalObjectReference             `json:"credentialsSecretRef,omitempty"`
+	Replicas             *int32                                   `json:"replicas,omitempty"`
+	Placement            *ModelAdapterPlacementApplyConfiguration `json:"placement,omitempty"`
+	RolloutPercent       *int32                                   `json:"rolloutPercent,omitempty"`
+	AdditionalConfig     map[string]string                        `json:"additionalConfig,omitempty"`
 }
 
 // ModelAdapterSpecApplyConfiguration constructs a declarative configuration of the ModelAdapterSpec type for use with
@@ -88,6 +90,22 @@ func (b *ModelAdapterSpecApplyConfiguration) WithReplicas(value int32) *ModelAda
 	return b
 }
 
+// WithPlacement sets the Placement field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Placement field is set to the value of the last call.
+func (b *ModelAdapterSpecApplyConfiguration) WithPlacement(value *ModelAdapterPlacementApplyConfiguration) *ModelAdapterSpecApplyConfiguration {
+	b.Placement = value
+	return b
+}
+
+// WithRolloutPercent sets the RolloutPercent field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the RolloutPercent field is set to the value of the last call.
+func (b *ModelAdapterSpecApplyConfiguration) WithRolloutPercent(value int32) *ModelAdapterSpecApplyConfiguration {
+	b.RolloutPercent = &value
+	return b
+}
+
 // WithAdditionalConfig puts the entries into the AdditionalConfig field in the declarative configuration
 // and returns the receiver, so that objects can be build by chaining "With" function invocations.
 // If called multiple times, the entries provided by each call will be put on the AdditionalConfig field,
@@ -25,9 +25,15 @@ import (
 // ModelAdapterStatusApplyConfiguration represents a declarative configuration of the ModelAdapterStatus type for use
 // with apply.
 type ModelAdapterStatusApplyConfiguration struct {
-	Phase      *v1alpha1.ModelAdapterPhase      `json:"phase,omitempty"`
-	Conditions []v1.ConditionApplyConfiguration `json:"conditions,omitempty"`
-	Instances  []string                         `json:"instances,omitempty"`
+	Phase            *v1alpha1.ModelAdapterPhase                    `json:"phase,omitempty"`
+	Conditions       []v1.ConditionApplyConfiguration               `json:"conditions,omitempty"`
+	Instances        []string                                       `json:"instances,omitempty"`
+	ArtifactURL      *string                                        `json:"artifactURL,omitempty"`
+	Rollout          *ModelAdapterRolloutApplyConfiguration         `json:"rollout,omitempty"`
+	InstanceStatuses []ModelAdapterInstanceStatusApplyConfiguration `json:"instanceStatuses,omitempty"`
+	DesiredInstances *int32                                         `json:"desiredInstances,omitempty"`
+	LoadedInstances  *int32                                         `json:"loadedInstances,omitempty"`
+	Evictions        []ModelAdapterEvictionApplyConfiguration       `json:"evictions,omitempty"`
 }
 
 // ModelAdapterStatusApplyConfiguration constructs a declarative configuration of the ModelAdapterStatus type for use with
@@ -66,3 +72,61 @@ func (b *ModelAdapterStatusApplyConfiguration) WithInstances(values ...string) *
 	}
 	return b
 }
+
+// WithArtifactURL sets the ArtifactURL field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ArtifactURL field is set to the value of the last call.
+func (b *ModelAdapterStatusApplyConfiguration) WithArtifactURL(value string) *ModelAdapterStatusApplyConfiguration {
+	b.ArtifactURL = &value
+	return b
+}
+
+// WithRollout sets the Rollout field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Rollout field is set to the value of the last call.
+func (b *ModelAdapterStatusApplyConfiguration) WithRollout(value *ModelAdapterRolloutApplyConfiguration) *ModelAdapterStatusApplyConfiguration {
+	b.Rollout = value
+	return b
+}
+
+// WithInstanceStatuses adds the given value to the InstanceStatuses field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the InstanceStatuses field.
+func (b *ModelAdapterStatusApplyConfiguration) WithInstanceStatuses(values ...*ModelAdapterInstanceStatusApplyConfiguration) *ModelAdapterStatusApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithInstanceStatuses")
+		}
+		b.InstanceStatuses = append(b.InstanceStatuses, *values[i])
+	}
+	return b
+}
+
+// WithDesiredInstances sets the DesiredInstances field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the DesiredInstances field is set to the value of the last call.
+func (b *ModelAdapterStatusApplyConfiguration) WithDesiredInstances(value int32) *ModelAdapterStatusApplyConfiguration {
+	b.DesiredInstances = &value
+	return b
+}
+
+// WithLoadedInstances sets the LoadedInstances field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the LoadedInstances field is set to the value of the last call.
+func (b *ModelAdapterStatusApplyConfiguration) WithLoadedInstances(value int32) *ModelAdapterStatusApplyConfiguration {
+	b.LoadedInstances = &value
+	return b
+}
+
+// WithEvictions adds the given value to the Evictions field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Evictions field.
+func (b *ModelAdapterStatusApplyConfiguration) WithEvictions(values ...*ModelAdapterEvictionApplyConfiguration) *ModelAdapterStatusApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithEvictions")
+		}
+		b.Evictions = append(b.Evictions, *values[i])
+	}
+	return b
+}
@@ -37,20 +37,46 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 	// Group=autoscaling, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithKind("MetricSource"):
 		return &autoscalingv1alpha1.MetricSourceApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("MetricStatus"):
+		return &autoscalingv1alpha1.MetricStatusApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("PodAutoscaler"):
 		return &autoscalingv1alpha1.PodAutoscalerApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("PodAutoscalerSpec"):
 		return &autoscalingv1alpha1.PodAutoscalerSpecApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("PodAutoscalerStatus"):
 		return &autoscalingv1alpha1.PodAutoscalerStatusApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ScalingDecision"):
+		return &autoscalingv1alpha1.ScalingDecisionApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ScalingRecommendation"):
+		return &autoscalingv1alpha1.ScalingRecommendationApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ScalingState"):
+		return &autoscalingv1alpha1.ScalingStateApplyConfiguration{}
 
 		// Group=model, Version=v1alpha1
+	case modelv1alpha1.SchemeGroupVersion.WithKind("Model"):
+		return &applyconfigurationmodelv1alpha1.ModelApplyConfiguration{}
 	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAdapter"):
 		return &applyconfigurationmodelv1alpha1.ModelAdapterApplyConfiguration{}
+	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAdapterEviction"):
+		return &applyconfigurationmodelv1alpha1.ModelAdapterEvictionApplyConfiguration{}
+	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAdapterInstanceStatus"):
+		return &applyconfigurationmodelv1alpha1.ModelAdapterInstanceStatusApplyConfiguration{}
+	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAdapterPlacement"):
+		return &applyconfigurationmodelv1alpha1.ModelAdapterPlacementApplyConfiguration{}
+	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAdapterRollout"):
+		return &applyconfigurationmodelv1alpha1.ModelAdapterRolloutApplyConfiguration{}
 	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAdapterSpec"):
 		return &applyconfigurationmodelv1alpha1.ModelAdapterSpecApplyConfiguration{}
 	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAdapterStatus"):
 		return &applyconfigurationmodelv1alpha1.ModelAdapterStatusApplyConfiguration{}
+	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAdapterSummary"):
+		return &applyconfigurationmodelv1alpha1.ModelAdapterSummaryApplyConfiguration{}
+	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelAutoscalerStatus"):
+		return &applyconfigurationmodelv1alpha1.ModelAutoscalerStatusApplyConfiguration{}
+	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelSpec"):
+		return &applyconfigurationmodelv1alpha1.ModelSpecApplyConfiguration{}
+	case modelv1alpha1.SchemeGroupVersion.WithKind("ModelStatus"):
+		return &applyconfigurationmodelv1alpha1.ModelStatusApplyConfiguration{}
 
 		// Group=orchestration, Version=v1alpha1
 	case orchestrationv1alpha1.SchemeGroupVersion.WithKind("RayClusterFleet"):
@@ -27,6 +27,7 @@ import (
 
 type ModelV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	ModelsGetter
 	ModelAdaptersGetter
 }
 
@@ -35,6 +36,10 @@ type ModelV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *ModelV1alpha1Client) Models(namespace string) ModelInterface {
+	return newModels(c, namespace)
+}
+
 func (c *ModelV1alpha1Client) ModelAdapters(namespace string) ModelAdapterInterface {
 	return newModelAdapters(c, namespace)
 }
@@ -23,6 +23,8 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// Models returns a ModelInformer.
+	Models() ModelInformer
 	// ModelAdapters returns a ModelAdapterInformer.
 	ModelAdapters() ModelAdapterInformer
 }
@@ -38,6 +40,11 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// Models returns a ModelInformer.
+func (v *version) Models() ModelInformer {
+	return &modelInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // ModelAdapters returns a ModelAdapterInformer.
 func (v *version) ModelAdapters() ModelAdapterInformer {
 	return &modelAdapterInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
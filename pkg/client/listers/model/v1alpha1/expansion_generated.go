@@ -17,6 +17,14 @@ limitations under the License.
 
 package v1alpha1
 
+// ModelListerExpansion allows custom methods to be added to
+// ModelLister.
+type ModelListerExpansion interface{}
+
+// ModelNamespaceListerExpansion allows custom methods to be added to
+// ModelNamespaceLister.
+type ModelNamespaceListerExpansion interface{}
+
 // ModelAdapterListerExpansion allows custom methods to be added to
 // ModelAdapterLister.
 type ModelAdapterListerExpansion interface{}
@@ -16,9 +16,47 @@ limitations under the License.
 
 package config
 
+import (
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
 type RuntimeConfig struct {
 	EnableRuntimeSidecar bool
 	DebugMode            bool
+	// EventRateLimitInterval is how often the controllers emit an identical warning event of an object at most,
+	// zero disables the limit.
+	EventRateLimitInterval time.Duration
+	// PodAutoscalerSyncPeriod is how often a KPA or APA PodAutoscaler re-evaluates its metrics, zero falls back to
+	// the default of the controller.
+	PodAutoscalerSyncPeriod time.Duration
+	// PodAutoscalerMaxConcurrentReconciles is the number of PodAutoscalers reconciled concurrently, zero falls back
+	// to the default of the controller.
+	PodAutoscalerMaxConcurrentReconciles int
+	// PodAutoscalerReconcileTimeout bounds the calls of a PodAutoscaler reconcile to the API server and to the pods,
+	// zero falls back to the default of the controller.
+	PodAutoscalerReconcileTimeout time.Duration
+	// OrphanSweepInterval is how often the controllers sweep the resources they generated for orphans after the
+	// startup sweep, zero falls back to the default of the controller.
+	OrphanSweepInterval time.Duration
+	// OrphanSweepDryRun only logs the orphans the sweeps would delete or adopt.
+	OrphanSweepDryRun bool
+	// RecommendationRedisAddr is the address of the Redis the RedisStream recommendation sinks append to, empty
+	// disables the sink.
+	RecommendationRedisAddr string
+	// MetricsRedisAddr is the address of the Redis the redis metric sources are read from, e.g. the Redis of the
+	// gateway counting the pending requests of the models, empty disables the sources.
+	MetricsRedisAddr string
+	// PodAutoscalerClusterMaxReplicas caps the total replicas of the PodAutoscalers of the cluster, zero disables the
+	// cap.
+	PodAutoscalerClusterMaxReplicas int32
+	// ModelAdapterUnloadGracePeriod is how long the deletion of a ModelAdapter waits for its pods to confirm the
+	// unloading, zero falls back to the default of the controller.
+	ModelAdapterUnloadGracePeriod time.Duration
+	// Clock times the metric samples and the scaling decisions of the PodAutoscalers, the real clock if nil. The
+	// integration tests step a fake clock through the scaling windows.
+	Clock clock.PassiveClock
 }
 
 // NewRuntimeConfig creates a new RuntimeConfig with specified settings.
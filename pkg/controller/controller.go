@@ -19,6 +19,7 @@ package controller
 import (
 	"github.com/vllm-project/aibrix/pkg/config"
 	"github.com/vllm-project/aibrix/pkg/controller/kvcache"
+	"github.com/vllm-project/aibrix/pkg/controller/model"
 	"github.com/vllm-project/aibrix/pkg/controller/modeladapter"
 	"github.com/vllm-project/aibrix/pkg/controller/modelrouter"
 	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler"
@@ -59,6 +60,10 @@ func Initialize() {
 	if features.IsControllerEnabled(features.KVCacheController) {
 		controllerAddFuncs = append(controllerAddFuncs, kvcache.Add)
 	}
+
+	if features.IsControllerEnabled(features.ModelController) {
+		controllerAddFuncs = append(controllerAddFuncs, model.Add)
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
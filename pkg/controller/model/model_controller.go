@@ -0,0 +1,240 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/config"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	modelIdentifier = "model.aibrix.ai/name"
+
+	// defaultStatusUpdateInterval is the least time between two status updates of a Model. The pods, the
+	// PodAutoscaler and the adapters of a busy model change many times a second, their changes within the
+	// interval are coalesced into one update.
+	defaultStatusUpdateInterval = 5 * time.Second
+)
+
+var (
+	controllerName = "model-controller"
+)
+
+// Add creates a new Model Controller and adds it to the Manager with default RBAC.
+// The Manager will set fields on the Controller and Start it when the Manager is Started.
+func Add(mgr manager.Manager, runtimeConfig config.RuntimeConfig) error {
+	r := newReconciler(mgr)
+	return add(mgr, r)
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) *ModelReconciler {
+	return &ModelReconciler{
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		clock:                clock.RealClock{},
+		statusUpdateInterval: defaultStatusUpdateInterval,
+		lastStatusUpdates:    map[types.NamespacedName]time.Time{},
+	}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r *ModelReconciler) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(&modelv1alpha1.Model{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.lookupModelsOfPod),
+			builder.WithPredicates(podServingStateChanged())).
+		Watches(&autoscalingv1alpha1.PodAutoscaler{}, handler.EnqueueRequestsFromMapFunc(r.lookupModelsOfPodAutoscaler)).
+		Watches(&modelv1alpha1.ModelAdapter{}, handler.EnqueueRequestsFromMapFunc(r.lookupModelsOfModelAdapter)).
+		Complete(r)
+
+	klog.V(4).InfoS("Finished to add model-controller")
+	return err
+}
+
+// podServingStateChanged filters the events of the pods of a model down to the ones that change its pod counts,
+// which skips e.g. the frequent status updates of the containers of a running pod.
+func podServingStateChanged() predicate.Predicate {
+	hasModel := func(obj client.Object) bool {
+		return obj.GetLabels()[modelIdentifier] != ""
+	}
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return hasModel(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPod, okOld := e.ObjectOld.(*corev1.Pod)
+			newPod, okNew := e.ObjectNew.(*corev1.Pod)
+			if !okOld || !okNew || (!hasModel(oldPod) && !hasModel(newPod)) {
+				return false
+			}
+			return oldPod.Labels[modelIdentifier] != newPod.Labels[modelIdentifier] ||
+				oldPod.Status.Phase != newPod.Status.Phase ||
+				oldPod.Status.PodIP != newPod.Status.PodIP ||
+				utils.IsPodReady(oldPod) != utils.IsPodReady(newPod) ||
+				utils.IsPodTerminating(oldPod) != utils.IsPodTerminating(newPod)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return hasModel(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return hasModel(e.Object)
+		},
+	}
+}
+
+func (r *ModelReconciler) lookupModelsOfPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.lookupModels(ctx, obj.GetNamespace(), obj.GetLabels()[modelIdentifier])
+}
+
+func (r *ModelReconciler) lookupModelsOfPodAutoscaler(ctx context.Context, obj client.Object) []reconcile.Request {
+	pa, ok := obj.(*autoscalingv1alpha1.PodAutoscaler)
+	if !ok {
+		return nil
+	}
+	return r.lookupModels(ctx, pa.Namespace, pa.Labels[modelIdentifier])
+}
+
+func (r *ModelReconciler) lookupModelsOfModelAdapter(ctx context.Context, obj client.Object) []reconcile.Request {
+	adapter, ok := obj.(*modelv1alpha1.ModelAdapter)
+	if !ok || adapter.Spec.BaseModel == nil {
+		return nil
+	}
+	return r.lookupModels(ctx, adapter.Namespace, *adapter.Spec.BaseModel)
+}
+
+// lookupModels returns the requests of the Models of the model in the namespace.
+func (r *ModelReconciler) lookupModels(ctx context.Context, namespace, modelName string) []reconcile.Request {
+	if modelName == "" {
+		return nil
+	}
+	modelList := &modelv1alpha1.ModelList{}
+	if err := r.List(ctx, modelList, client.InNamespace(namespace)); err != nil {
+		klog.ErrorS(err, "unable to list models in namespace", "namespace", namespace)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, model := range modelList.Items {
+		if model.GetModelName() == modelName {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: model.Namespace, Name: model.Name}})
+		}
+	}
+	return requests
+}
+
+var _ reconcile.Reconciler = &ModelReconciler{}
+
+// ModelReconciler reconciles a Model object, whose status aggregates the state of the serving pods, the
+// PodAutoscaler and the ModelAdapters of its model.
+type ModelReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	clock clock.Clock
+	// statusUpdateInterval is the least time between two status updates of a Model.
+	statusUpdateInterval time.Duration
+	// lastStatusUpdates is the time of the last status update of each Model.
+	lastStatusUpdates map[types.NamespacedName]time.Time
+	mu                sync.Mutex
+}
+
+//+kubebuilder:rbac:groups=model.aibrix.ai,resources=models,verbs=get;list;watch
+//+kubebuilder:rbac:groups=model.aibrix.ai,resources=models/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=model.aibrix.ai,resources=modeladapters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=autoscaling.aibrix.ai,resources=podautoscalers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+
+// Reconcile aggregates the status of a Model. A Model whose status was updated less than the status update
+// interval ago is requeued for the end of the interval instead, so that the changes in between are written at once.
+func (r *ModelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	model := &modelv1alpha1.Model{}
+	if err := r.Get(ctx, req.NamespacedName, model); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.forgetStatusUpdate(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	status, err := r.aggregateStatus(ctx, model)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if apiequality.Semantic.DeepEqual(status, model.Status) {
+		return ctrl.Result{}, nil
+	}
+
+	if wait := r.statusUpdateWait(req.NamespacedName); wait > 0 {
+		klog.V(4).InfoS("Delaying the status update of the model", "model", req.NamespacedName, "after", wait)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	original := model.DeepCopy()
+	model.Status = status
+	if err := utils.PatchStatus(ctx, r.Client, original, model); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.recordStatusUpdate(req.NamespacedName)
+	return ctrl.Result{}, nil
+}
+
+// statusUpdateWait returns how long the status of the Model has to wait before its next update.
+func (r *ModelReconciler) statusUpdateWait(key types.NamespacedName) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last, ok := r.lastStatusUpdates[key]
+	if !ok {
+		return 0
+	}
+	return r.statusUpdateInterval - r.clock.Since(last)
+}
+
+func (r *ModelReconciler) recordStatusUpdate(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastStatusUpdates[key] = r.clock.Now()
+}
+
+func (r *ModelReconciler) forgetStatusUpdate(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.lastStatusUpdates, key)
+}
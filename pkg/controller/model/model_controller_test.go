@@ -0,0 +1,324 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+const testNamespace = "default"
+
+func newTestReconciler(t *testing.T, clock *clocktesting.FakeClock, objs ...client.Object) *ModelReconciler {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, modelv1alpha1.AddToScheme(scheme))
+	require.NoError(t, autoscalingv1alpha1.AddToScheme(scheme))
+
+	return &ModelReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&modelv1alpha1.Model{}).
+			Build(),
+		Scheme:               scheme,
+		clock:                clock,
+		statusUpdateInterval: defaultStatusUpdateInterval,
+		lastStatusUpdates:    map[types.NamespacedName]time.Time{},
+	}
+}
+
+func newTestModel(name string) *modelv1alpha1.Model {
+	return &modelv1alpha1.Model{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Generation: 1},
+	}
+}
+
+func newTestPod(name, modelName string, ready bool, podIP string) *corev1.Pod {
+	readyStatus := corev1.ConditionFalse
+	if ready {
+		readyStatus = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Labels: map[string]string{modelIdentifier: modelName}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			PodIP:      podIP,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: readyStatus}},
+		},
+	}
+}
+
+func newTestPodAutoscaler(name string, labels map[string]string, targetName string, conditions ...metav1.Condition) *autoscalingv1alpha1.PodAutoscaler {
+	return &autoscalingv1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, Labels: labels},
+		Spec: autoscalingv1alpha1.PodAutoscalerSpec{
+			ScaleTargetRef: corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: targetName},
+		},
+		Status: autoscalingv1alpha1.PodAutoscalerStatus{DesiredScale: 3, ActualScale: 2, Conditions: conditions},
+	}
+}
+
+func newTestAdapter(name, baseModel string, ready bool, instances ...string) *modelv1alpha1.ModelAdapter {
+	adapter := &modelv1alpha1.ModelAdapter{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       modelv1alpha1.ModelAdapterSpec{BaseModel: ptr.To(baseModel)},
+		Status:     modelv1alpha1.ModelAdapterStatus{Phase: modelv1alpha1.ModelAdapterScheduled, Instances: instances},
+	}
+	if ready {
+		adapter.Status.Phase = modelv1alpha1.ModelAdapterRunning
+		adapter.Status.Conditions = []metav1.Condition{{Type: string(modelv1alpha1.ModelAdapterConditionReady), Status: metav1.ConditionTrue}}
+	}
+	return adapter
+}
+
+func TestAggregateStatus(t *testing.T) {
+	terminating := newTestPod("terminating", "llama", true, "10.0.0.4")
+	terminating.DeletionTimestamp = ptr.To(metav1.Now())
+	terminating.Finalizers = []string{"test"}
+	succeeded := newTestPod("succeeded", "llama", false, "")
+	succeeded.Status.Phase = corev1.PodSucceeded
+
+	testCases := []struct {
+		name               string
+		model              *modelv1alpha1.Model
+		objs               []client.Object
+		expectedPods       [3]int32 // total, ready, routable
+		expectedAutoscaler *modelv1alpha1.ModelAutoscalerStatus
+		expectedAdapters   []modelv1alpha1.ModelAdapterSummary
+		expectedConditions map[string]string // type to reason
+	}{
+		{
+			name:         "model without pods, autoscaler or adapters",
+			model:        newTestModel("llama"),
+			expectedPods: [3]int32{0, 0, 0},
+			expectedConditions: map[string]string{
+				modelv1alpha1.ModelConditionServing:       NoRoutablePodsReason,
+				modelv1alpha1.ModelConditionAutoscaling:   NoPodAutoscalerReason,
+				modelv1alpha1.ModelConditionAdaptersReady: NoAdaptersReason,
+			},
+		},
+		{
+			name:  "pods of the model are counted by their state",
+			model: newTestModel("llama"),
+			objs: []client.Object{
+				newTestPod("routable", "llama", true, "10.0.0.1"),
+				newTestPod("no-ip", "llama", true, ""),
+				newTestPod("unready", "llama", false, "10.0.0.3"),
+				terminating,
+				succeeded,
+				newTestPod("other-model", "mistral", true, "10.0.0.5"),
+			},
+			expectedPods: [3]int32{4, 3, 1},
+			expectedConditions: map[string]string{
+				modelv1alpha1.ModelConditionServing:       PodsRoutableReason,
+				modelv1alpha1.ModelConditionAutoscaling:   NoPodAutoscalerReason,
+				modelv1alpha1.ModelConditionAdaptersReady: NoAdaptersReason,
+			},
+		},
+		{
+			name: "spec.modelName selects the pods instead of the name",
+			model: func() *modelv1alpha1.Model {
+				model := newTestModel("llama-model")
+				model.Spec.ModelName = "llama"
+				return model
+			}(),
+			objs:         []client.Object{newTestPod("routable", "llama", true, "10.0.0.1")},
+			expectedPods: [3]int32{1, 1, 1},
+			expectedConditions: map[string]string{
+				modelv1alpha1.ModelConditionServing: PodsRoutableReason,
+			},
+		},
+		{
+			name:  "autoscaler labeled with the model",
+			model: newTestModel("llama"),
+			objs: []client.Object{
+				newTestPodAutoscaler("llama-pa", map[string]string{modelIdentifier: "llama"}, "llama",
+					metav1.Condition{Type: autoscalingv1alpha1.AbleToScale, Status: metav1.ConditionTrue, Reason: "SucceededGetScale"}),
+				newTestPodAutoscaler("mistral-pa", map[string]string{modelIdentifier: "mistral"}, "mistral"),
+			},
+			expectedAutoscaler: &modelv1alpha1.ModelAutoscalerStatus{
+				Name: "llama-pa", DesiredScale: 3, ActualScale: 2,
+				Conditions: []metav1.Condition{{Type: autoscalingv1alpha1.AbleToScale, Status: metav1.ConditionTrue, Reason: "SucceededGetScale"}},
+			},
+			expectedConditions: map[string]string{
+				modelv1alpha1.ModelConditionAutoscaling: ScalingActiveReason,
+			},
+		},
+		{
+			name:  "unlabeled autoscaler of the deployment of the model",
+			model: newTestModel("llama"),
+			objs: []client.Object{
+				&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "llama-deploy", Namespace: testNamespace, Labels: map[string]string{modelIdentifier: "llama"}}},
+				newTestPodAutoscaler("deploy-pa", nil, "llama-deploy"),
+			},
+			expectedConditions: map[string]string{
+				modelv1alpha1.ModelConditionAutoscaling: NoPodAutoscalerReason,
+			},
+		},
+		{
+			name:  "autoscaler with scaling disabled",
+			model: newTestModel("llama"),
+			objs: []client.Object{
+				newTestPodAutoscaler("llama-pa", map[string]string{modelIdentifier: "llama"}, "llama",
+					metav1.Condition{Type: autoscalingv1alpha1.ScalingDisabled, Status: metav1.ConditionTrue, Reason: "ScaledToZero"}),
+			},
+			expectedAutoscaler: &modelv1alpha1.ModelAutoscalerStatus{
+				Name: "llama-pa", DesiredScale: 3, ActualScale: 2,
+				Conditions: []metav1.Condition{{Type: autoscalingv1alpha1.ScalingDisabled, Status: metav1.ConditionTrue, Reason: "ScaledToZero"}},
+			},
+			expectedConditions: map[string]string{
+				modelv1alpha1.ModelConditionAutoscaling: ScalingDisabledReason,
+			},
+		},
+		{
+			name:  "adapters of the model",
+			model: newTestModel("llama"),
+			objs: []client.Object{
+				newTestAdapter("sql-lora", "llama", true, "pod-1", "pod-2"),
+				newTestAdapter("chat-lora", "llama", true, "pod-1"),
+				newTestAdapter("mistral-lora", "mistral", false),
+			},
+			expectedAdapters: []modelv1alpha1.ModelAdapterSummary{
+				{Name: "chat-lora", Phase: modelv1alpha1.ModelAdapterRunning, Available: true, Instances: 1},
+				{Name: "sql-lora", Phase: modelv1alpha1.ModelAdapterRunning, Available: true, Instances: 2},
+			},
+			expectedConditions: map[string]string{
+				modelv1alpha1.ModelConditionAdaptersReady: AdaptersAvailableReason,
+			},
+		},
+		{
+			name:  "adapter of the model not available",
+			model: newTestModel("llama"),
+			objs: []client.Object{
+				newTestAdapter("sql-lora", "llama", true, "pod-1"),
+				newTestAdapter("chat-lora", "llama", false),
+			},
+			expectedAdapters: []modelv1alpha1.ModelAdapterSummary{
+				{Name: "chat-lora", Phase: modelv1alpha1.ModelAdapterScheduled, Available: false},
+				{Name: "sql-lora", Phase: modelv1alpha1.ModelAdapterRunning, Available: true, Instances: 1},
+			},
+			expectedConditions: map[string]string{
+				modelv1alpha1.ModelConditionAdaptersReady: AdaptersUnavailableReason,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestReconciler(t, clocktesting.NewFakeClock(time.Now()), append(tc.objs, tc.model)...)
+
+			status, err := r.aggregateStatus(context.Background(), tc.model)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedPods, [3]int32{status.TotalPods, status.ReadyPods, status.RoutablePods})
+			assert.Equal(t, tc.expectedAutoscaler, status.Autoscaler)
+			assert.Equal(t, tc.expectedAdapters, status.Adapters)
+			assert.Equal(t, tc.model.Generation, status.ObservedGeneration)
+			for conditionType, reason := range tc.expectedConditions {
+				cond := meta.FindStatusCondition(status.Conditions, conditionType)
+				require.NotNil(t, cond, conditionType)
+				assert.Equal(t, reason, cond.Reason, conditionType)
+			}
+		})
+	}
+}
+
+func TestReconcileCoalescesStatusUpdates(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktesting.NewFakeClock(time.Now())
+	model := newTestModel("llama")
+	pod := newTestPod("pod-1", "llama", false, "10.0.0.1")
+	r := newTestReconciler(t, clock, model, pod)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: "llama"}}
+	getModel := func() *modelv1alpha1.Model {
+		got := &modelv1alpha1.Model{}
+		require.NoError(t, r.Get(ctx, req.NamespacedName, got))
+		return got
+	}
+
+	// the first status is written at once
+	result, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, int32(0), getModel().Status.ReadyPods)
+	assert.True(t, meta.IsStatusConditionFalse(getModel().Status.Conditions, modelv1alpha1.ModelConditionServing))
+
+	// an unchanged status is not written again
+	result, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+
+	// a change within the interval is delayed to its end
+	pod.Status.Conditions[0].Status = corev1.ConditionTrue
+	require.NoError(t, r.Status().Update(ctx, pod))
+	clock.Step(2 * time.Second)
+	result, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, defaultStatusUpdateInterval-2*time.Second, result.RequeueAfter)
+	assert.Equal(t, int32(0), getModel().Status.ReadyPods)
+
+	// and written once the interval has passed
+	clock.Step(defaultStatusUpdateInterval)
+	result, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assert.Equal(t, int32(1), getModel().Status.ReadyPods)
+	assert.True(t, meta.IsStatusConditionTrue(getModel().Status.Conditions, modelv1alpha1.ModelConditionServing))
+
+	// a deleted model is forgotten
+	require.NoError(t, r.Delete(ctx, getModel()))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.NotContains(t, r.lastStatusUpdates, req.NamespacedName)
+}
+
+func TestLookupModels(t *testing.T) {
+	renamed := newTestModel("llama-model")
+	renamed.Spec.ModelName = "llama"
+	r := newTestReconciler(t, clocktesting.NewFakeClock(time.Now()), newTestModel("llama"), renamed, newTestModel("mistral"))
+	ctx := context.Background()
+	llamaRequests := []ctrl.Request{
+		{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: "llama"}},
+		{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: "llama-model"}},
+	}
+
+	assert.ElementsMatch(t, llamaRequests, r.lookupModelsOfPod(ctx, newTestPod("pod-1", "llama", true, "")))
+	assert.ElementsMatch(t, llamaRequests, r.lookupModelsOfPodAutoscaler(ctx, newTestPodAutoscaler("llama-pa", map[string]string{modelIdentifier: "llama"}, "llama")))
+	assert.ElementsMatch(t, llamaRequests, r.lookupModelsOfModelAdapter(ctx, newTestAdapter("sql-lora", "llama", true)))
+	assert.Empty(t, r.lookupModelsOfPodAutoscaler(ctx, newTestPodAutoscaler("deploy-pa", nil, "llama-deploy")))
+	assert.Empty(t, r.lookupModelsOfModelAdapter(ctx, &modelv1alpha1.ModelAdapter{ObjectMeta: metav1.ObjectMeta{Name: "no-base", Namespace: testNamespace}}))
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// Reasons of the Serving condition.
+	PodsRoutableReason   = "PodsRoutable"
+	NoRoutablePodsReason = "NoRoutablePods"
+
+	// Reasons of the Autoscaling condition.
+	ScalingActiveReason   = "ScalingActive"
+	ScalingDisabledReason = "ScalingDisabled"
+	UnableToScaleReason   = "UnableToScale"
+	NoPodAutoscalerReason = "NoPodAutoscaler"
+
+	// Reasons of the AdaptersReady condition.
+	AdaptersAvailableReason   = "AdaptersAvailable"
+	AdaptersUnavailableReason = "AdaptersUnavailable"
+	NoAdaptersReason          = "NoAdapters"
+)
+
+// podAutoscalerConditionTypes are the conditions of a PodAutoscaler that are copied into the status of its Model.
+var podAutoscalerConditionTypes = []string{
+	autoscalingv1alpha1.AbleToScale,
+	autoscalingv1alpha1.ScalingDisabled,
+	autoscalingv1alpha1.NoReadyPods,
+}
+
+// aggregateStatus computes the status of the Model from its serving pods, its PodAutoscaler and its ModelAdapters.
+// The conditions keep their last transition time as long as their status does not change.
+func (r *ModelReconciler) aggregateStatus(ctx context.Context, model *modelv1alpha1.Model) (modelv1alpha1.ModelStatus, error) {
+	modelName := model.GetModelName()
+	status := modelv1alpha1.ModelStatus{
+		ObservedGeneration: model.Generation,
+	}
+	for i := range model.Status.Conditions {
+		status.Conditions = append(status.Conditions, *model.Status.Conditions[i].DeepCopy())
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(model.Namespace), client.MatchingLabels{modelIdentifier: modelName}); err != nil {
+		return status, fmt.Errorf("unable to list the pods of model %s: %w", modelName, err)
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		status.TotalPods++
+		if !utils.IsPodReady(pod) {
+			continue
+		}
+		status.ReadyPods++
+		if pod.Status.PodIP != "" && !utils.IsPodTerminating(pod) {
+			status.RoutablePods++
+		}
+	}
+	servingStatus, servingReason := metav1.ConditionFalse, NoRoutablePodsReason
+	if status.RoutablePods > 0 {
+		servingStatus, servingReason = metav1.ConditionTrue, PodsRoutableReason
+	}
+	setCondition(&status, modelv1alpha1.ModelConditionServing, servingStatus, servingReason,
+		fmt.Sprintf("%d of %d pods of the model are routable", status.RoutablePods, status.TotalPods))
+
+	pa, err := r.podAutoscalerOfModel(ctx, model.Namespace, modelName)
+	if err != nil {
+		return status, err
+	}
+	setAutoscalerStatus(&status, pa)
+
+	adapterList := &modelv1alpha1.ModelAdapterList{}
+	if err := r.List(ctx, adapterList, client.InNamespace(model.Namespace)); err != nil {
+		return status, fmt.Errorf("unable to list the adapters of model %s: %w", modelName, err)
+	}
+	setAdaptersStatus(&status, modelName, adapterList.Items)
+
+	return status, nil
+}
+
+// setAutoscalerStatus summarizes the PodAutoscaler of the model, nil if the model has none.
+func setAutoscalerStatus(status *modelv1alpha1.ModelStatus, pa *autoscalingv1alpha1.PodAutoscaler) {
+	if pa == nil {
+		setCondition(status, modelv1alpha1.ModelConditionAutoscaling, metav1.ConditionFalse, NoPodAutoscalerReason,
+			"no PodAutoscaler scales the pods of the model")
+		return
+	}
+
+	status.Autoscaler = &modelv1alpha1.ModelAutoscalerStatus{
+		Name:         pa.Name,
+		DesiredScale: pa.Status.DesiredScale,
+		ActualScale:  pa.Status.ActualScale,
+	}
+	for _, conditionType := range podAutoscalerConditionTypes {
+		if cond := meta.FindStatusCondition(pa.Status.Conditions, conditionType); cond != nil {
+			status.Autoscaler.Conditions = append(status.Autoscaler.Conditions, *cond.DeepCopy())
+		}
+	}
+
+	if cond := meta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ScalingDisabled); cond != nil && cond.Status == metav1.ConditionTrue {
+		setCondition(status, modelv1alpha1.ModelConditionAutoscaling, metav1.ConditionFalse, ScalingDisabledReason,
+			fmt.Sprintf("PodAutoscaler %s: %s", pa.Name, cond.Message))
+		return
+	}
+	if cond := meta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.AbleToScale); cond != nil && cond.Status == metav1.ConditionFalse {
+		setCondition(status, modelv1alpha1.ModelConditionAutoscaling, metav1.ConditionFalse, UnableToScaleReason,
+			fmt.Sprintf("PodAutoscaler %s: %s", pa.Name, cond.Message))
+		return
+	}
+	setCondition(status, modelv1alpha1.ModelConditionAutoscaling, metav1.ConditionTrue, ScalingActiveReason,
+		fmt.Sprintf("PodAutoscaler %s scales the model from %d to %d replicas", pa.Name, pa.Status.ActualScale, pa.Status.DesiredScale))
+}
+
+// setAdaptersStatus summarizes the ModelAdapters whose base model is the model, sorted by name. An adapter is
+// available once its Ready condition is true.
+func setAdaptersStatus(status *modelv1alpha1.ModelStatus, modelName string, adapters []modelv1alpha1.ModelAdapter) {
+	var unavailable []string
+	for i := range adapters {
+		adapter := &adapters[i]
+		if adapter.Spec.BaseModel == nil || *adapter.Spec.BaseModel != modelName {
+			continue
+		}
+		summary := modelv1alpha1.ModelAdapterSummary{
+			Name:      adapter.Name,
+			Phase:     adapter.Status.Phase,
+			Available: meta.IsStatusConditionTrue(adapter.Status.Conditions, string(modelv1alpha1.ModelAdapterConditionReady)),
+			Instances: int32(len(adapter.Status.Instances)),
+		}
+		if !summary.Available {
+			unavailable = append(unavailable, adapter.Name)
+		}
+		status.Adapters = append(status.Adapters, summary)
+	}
+	sort.Slice(status.Adapters, func(i, j int) bool {
+		return status.Adapters[i].Name < status.Adapters[j].Name
+	})
+	sort.Strings(unavailable)
+
+	switch {
+	case len(status.Adapters) == 0:
+		setCondition(status, modelv1alpha1.ModelConditionAdaptersReady, metav1.ConditionTrue, NoAdaptersReason,
+			"no adapter targets the model")
+	case len(unavailable) == 0:
+		setCondition(status, modelv1alpha1.ModelConditionAdaptersReady, metav1.ConditionTrue, AdaptersAvailableReason,
+			fmt.Sprintf("all %d adapters of the model are available", len(status.Adapters)))
+	default:
+		setCondition(status, modelv1alpha1.ModelConditionAdaptersReady, metav1.ConditionFalse, AdaptersUnavailableReason,
+			fmt.Sprintf("adapters %s of the model are not available", strings.Join(unavailable, ", ")))
+	}
+}
+
+func setCondition(status *modelv1alpha1.ModelStatus, conditionType string, conditionStatus metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		ObservedGeneration: status.ObservedGeneration,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// podAutoscalerOfModel returns the PodAutoscaler labeled with the model in the namespace, the first one by name if
+// there are several, nil if there is none.
+func (r *ModelReconciler) podAutoscalerOfModel(ctx context.Context, namespace, modelName string) (*autoscalingv1alpha1.PodAutoscaler, error) {
+	paList := &autoscalingv1alpha1.PodAutoscalerList{}
+	if err := r.List(ctx, paList, client.InNamespace(namespace), client.MatchingLabels{modelIdentifier: modelName}); err != nil {
+		return nil, fmt.Errorf("unable to list the pod autoscalers of model %s: %w", modelName, err)
+	}
+	sort.Slice(paList.Items, func(i, j int) bool {
+		return paList.Items[i].Name < paList.Items[j].Name
+	})
+	if len(paList.Items) == 0 {
+		return nil, nil
+	}
+	return &paList.Items[0], nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modeladapter
+
+import (
+	"fmt"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// setInstancePhase records the load state of the model adapter on the pod. The transition time only changes with
+// the phase, so that a loading failing again keeps the time it started failing.
+func setInstancePhase(instance *modelv1alpha1.ModelAdapter, pod *corev1.Pod, phase modelv1alpha1.ModelAdapterInstancePhase, message string) {
+	for i := range instance.Status.InstanceStatuses {
+		status := &instance.Status.InstanceStatuses[i]
+		if status.PodName != pod.Name {
+			continue
+		}
+		if status.Phase != phase {
+			status.Phase = phase
+			status.LastTransitionTime = metav1.Now()
+		}
+		status.PodIP = pod.Status.PodIP
+		status.Message = message
+		return
+	}
+	instance.Status.InstanceStatuses = append(instance.Status.InstanceStatuses, modelv1alpha1.ModelAdapterInstanceStatus{
+		PodName:            pod.Name,
+		PodIP:              pod.Status.PodIP,
+		Phase:              phase,
+		LastTransitionTime: metav1.Now(),
+		Message:            message,
+	})
+}
+
+// syncInstanceStatuses drops the load states of the pods no longer in the instances of the model adapter, and counts
+// the desired and loaded instances.
+func syncInstanceStatuses(status *modelv1alpha1.ModelAdapterStatus) {
+	statuses := status.InstanceStatuses[:0]
+	var loaded int32
+	for _, instanceStatus := range status.InstanceStatuses {
+		if !StringInSlice(status.Instances, instanceStatus.PodName) {
+			continue
+		}
+		statuses = append(statuses, instanceStatus)
+		if instanceStatus.Phase == modelv1alpha1.ModelAdapterInstanceLoaded {
+			loaded++
+		}
+	}
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	status.InstanceStatuses = statuses
+	status.DesiredInstances = int32(len(status.Instances))
+	status.LoadedInstances = loaded
+}
+
+// loadedInstances returns the statuses of the pods of the instances which loaded the model adapter, in the order of
+// the instances. A pod is loaded once its load call succeeded, and leaves the instances when it becomes unready.
+func loadedInstances(instance *modelv1alpha1.ModelAdapter) []modelv1alpha1.ModelAdapterInstanceStatus {
+	var loaded []modelv1alpha1.ModelAdapterInstanceStatus
+	for _, podName := range instance.Status.Instances {
+		for _, status := range instance.Status.InstanceStatuses {
+			if status.PodName == podName && status.Phase == modelv1alpha1.ModelAdapterInstanceLoaded && status.PodIP != "" {
+				loaded = append(loaded, status)
+			}
+		}
+	}
+	return loaded
+}
+
+// newReadyCondition returns the Ready condition of the model adapter, which is true once every selected pod loaded it.
+func newReadyCondition(instance *modelv1alpha1.ModelAdapter) metav1.Condition {
+	status := instance.Status
+	if status.DesiredInstances > 0 && status.LoadedInstances == status.DesiredInstances {
+		return NewCondition(string(modelv1alpha1.ModelAdapterConditionReady), metav1.ConditionTrue, ModelAdapterAvailable,
+			fmt.Sprintf("ModelAdapter %s is loaded on %d/%d pods", klog.KObj(instance), status.LoadedInstances, status.DesiredInstances))
+	}
+	return NewCondition(string(modelv1alpha1.ModelAdapterConditionReady), metav1.ConditionFalse, ModelAdapterUnavailable,
+		fmt.Sprintf("ModelAdapter %s is loaded on %d/%d pods", klog.KObj(instance), status.LoadedInstances, status.DesiredInstances))
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modeladapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+func newInstanceStatusPod(name, ip string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+}
+
+func TestSetInstancePhase(t *testing.T) {
+	instance := &modelv1alpha1.ModelAdapter{}
+	pod := newInstanceStatusPod("pod-1", "10.0.0.1")
+
+	setInstancePhase(instance, pod, modelv1alpha1.ModelAdapterInstanceFailed, "connection refused")
+	assert.Len(t, instance.Status.InstanceStatuses, 1)
+	failedSince := metav1.NewTime(time.Now().Add(-time.Minute))
+	instance.Status.InstanceStatuses[0].LastTransitionTime = failedSince
+
+	// a loading failing again keeps the time it started failing.
+	setInstancePhase(instance, pod, modelv1alpha1.ModelAdapterInstanceFailed, "timeout")
+	status := instance.Status.InstanceStatuses[0]
+	assert.Equal(t, failedSince, status.LastTransitionTime)
+	assert.Equal(t, "timeout", status.Message)
+
+	setInstancePhase(instance, pod, modelv1alpha1.ModelAdapterInstanceLoaded, "")
+	status = instance.Status.InstanceStatuses[0]
+	assert.Len(t, instance.Status.InstanceStatuses, 1)
+	assert.Equal(t, modelv1alpha1.ModelAdapterInstanceLoaded, status.Phase)
+	assert.Equal(t, "10.0.0.1", status.PodIP)
+	assert.True(t, status.LastTransitionTime.After(failedSince.Time))
+}
+
+func TestSyncInstanceStatuses(t *testing.T) {
+	status := modelv1alpha1.ModelAdapterStatus{
+		Instances: []string{"pod-1", "pod-2", "pod-3"},
+		InstanceStatuses: []modelv1alpha1.ModelAdapterInstanceStatus{
+			{PodName: "pod-1", Phase: modelv1alpha1.ModelAdapterInstanceLoaded},
+			{PodName: "pod-2", Phase: modelv1alpha1.ModelAdapterInstanceFailed},
+			{PodName: "pod-3", Phase: modelv1alpha1.ModelAdapterInstanceLoaded},
+		},
+	}
+	syncInstanceStatuses(&status)
+	assert.Equal(t, int32(3), status.DesiredInstances)
+	assert.Equal(t, int32(2), status.LoadedInstances)
+
+	// the load states shrink with the instances.
+	status.Instances = []string{"pod-2"}
+	syncInstanceStatuses(&status)
+	assert.Equal(t, []modelv1alpha1.ModelAdapterInstanceStatus{{PodName: "pod-2", Phase: modelv1alpha1.ModelAdapterInstanceFailed}}, status.InstanceStatuses)
+	assert.Equal(t, int32(1), status.DesiredInstances)
+	assert.Equal(t, int32(0), status.LoadedInstances)
+
+	status.Instances = nil
+	syncInstanceStatuses(&status)
+	assert.Nil(t, status.InstanceStatuses)
+	assert.Equal(t, int32(0), status.DesiredInstances)
+}
+
+func TestNewReadyCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		desired  int32
+		loaded   int32
+		expected metav1.ConditionStatus
+	}{
+		{name: "no selected pod", expected: metav1.ConditionFalse},
+		{name: "partially loaded", desired: 2, loaded: 1, expected: metav1.ConditionFalse},
+		{name: "loaded on every pod", desired: 2, loaded: 2, expected: metav1.ConditionTrue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &modelv1alpha1.ModelAdapter{Status: modelv1alpha1.ModelAdapterStatus{DesiredInstances: tt.desired, LoadedInstances: tt.loaded}}
+			condition := newReadyCondition(instance)
+			assert.Equal(t, string(modelv1alpha1.ModelAdapterConditionReady), condition.Type)
+			assert.Equal(t, tt.expected, condition.Status)
+		})
+	}
+}
+
+func TestUpdateStatusInstances(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, modelv1alpha1.AddToScheme(scheme))
+	instance := &modelv1alpha1.ModelAdapter{ObjectMeta: metav1.ObjectMeta{Name: "lora-1", Namespace: "default"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(instance).WithStatusSubresource(instance).Build()
+	r := &ModelAdapterReconciler{Client: c}
+	ctx := context.TODO()
+	key := types.NamespacedName{Namespace: "default", Name: "lora-1"}
+
+	original := instance.DeepCopy()
+	instance.Status.Instances = []string{"pod-1", "pod-2"}
+	setInstancePhase(instance, newInstanceStatusPod("pod-1", "10.0.0.1"), modelv1alpha1.ModelAdapterInstanceLoaded, "")
+	setInstancePhase(instance, newInstanceStatusPod("pod-2", "10.0.0.2"), modelv1alpha1.ModelAdapterInstanceLoaded, "")
+	assert.NoError(t, r.updateStatus(ctx, original, instance))
+
+	persisted := &modelv1alpha1.ModelAdapter{}
+	assert.NoError(t, c.Get(ctx, key, persisted))
+	assert.Equal(t, int32(2), persisted.Status.LoadedInstances)
+	assert.True(t, meta.IsStatusConditionTrue(persisted.Status.Conditions, string(modelv1alpha1.ModelAdapterConditionReady)))
+
+	// a pod going away shrinks the load states, the adapter is ready on the remaining pod.
+	instance.Status.Instances = RemoveInstanceFromList(instance.Status.Instances, "pod-2")
+	assert.NoError(t, r.updateStatus(ctx, original, instance))
+	assert.NoError(t, c.Get(ctx, key, persisted))
+	assert.Len(t, persisted.Status.InstanceStatuses, 1)
+	assert.Equal(t, "pod-1", persisted.Status.InstanceStatuses[0].PodName)
+	assert.Equal(t, int32(1), persisted.Status.DesiredInstances)
+	assert.True(t, meta.IsStatusConditionTrue(persisted.Status.Conditions, string(modelv1alpha1.ModelAdapterConditionReady)))
+
+	// a pod failing to load makes the adapter unready.
+	instance.Status.Instances = append(instance.Status.Instances, "pod-3")
+	setInstancePhase(instance, newInstanceStatusPod("pod-3", "10.0.0.3"), modelv1alpha1.ModelAdapterInstanceFailed, "connection refused")
+	assert.NoError(t, r.updateStatus(ctx, original, instance))
+	assert.NoError(t, c.Get(ctx, key, persisted))
+	assert.Equal(t, int32(1), persisted.Status.LoadedInstances)
+	assert.Equal(t, int32(2), persisted.Status.DesiredInstances)
+	assert.False(t, meta.IsStatusConditionTrue(persisted.Status.Conditions, string(modelv1alpha1.ModelAdapterConditionReady)))
+}
@@ -31,13 +31,14 @@ import (
 	"github.com/vllm-project/aibrix/pkg/cache"
 	"github.com/vllm-project/aibrix/pkg/config"
 	"github.com/vllm-project/aibrix/pkg/controller/modeladapter/scheduling"
+	"github.com/vllm-project/aibrix/pkg/controller/util/events"
 	"github.com/vllm-project/aibrix/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	corelisters "k8s.io/client-go/listers/core/v1"
@@ -49,7 +50,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -77,6 +77,18 @@ const (
 	ValidationFailedReason = "ValidationFailed"
 	// StableInstanceFoundReason is added if there's stale pod and instance has been deleted successfully.
 	StableInstanceFoundReason = "StableInstanceFound"
+	// NoActivePodsReason is added in a model adapter when no ready pod of its base model matches its pod selector.
+	NoActivePodsReason = "NoActivePods"
+
+	// Events of the deletion:
+
+	// UnloadedReason is emitted for a pod which confirmed the unloading of a deleted model adapter.
+	UnloadedReason = "Unloaded"
+	// FailedUnloadReason is emitted for a pod which failed to unload a deleted model adapter.
+	FailedUnloadReason = "FailedUnload"
+	// UnloadGracePeriodExceededReason is emitted when a deleted model adapter is released although some pods failed
+	// to unload it within the grace period.
+	UnloadGracePeriodExceededReason = "UnloadGracePeriodExceeded"
 
 	// Available:
 
@@ -99,12 +111,20 @@ const (
 )
 
 var (
-	controllerKind                     = modelv1alpha1.GroupVersion.WithKind("ModelAdapter")
-	controllerName                     = "model-adapter-controller"
-	defaultModelAdapterSchedulerPolicy = "leastAdapters"
-	defaultRequeueDuration             = 3 * time.Second
+	controllerKind         = modelv1alpha1.GroupVersion.WithKind("ModelAdapter")
+	controllerName         = "model-adapter-controller"
+	defaultRequeueDuration = 3 * time.Second
+	// pendingRequeueDuration requeues a model adapter without an active pod, in case no event of the pods of its
+	// base model is received once they are ready.
+	pendingRequeueDuration = 30 * time.Second
+	// unloadTimeout bounds an unloading request, so that an unreachable pod doesn't hold up the reconciles.
+	unloadTimeout = 10 * time.Second
 )
 
+// DefaultUnloadGracePeriod is how long the deletion of a model adapter waits for its pods to confirm the unloading
+// before the model adapter is released anyway.
+const DefaultUnloadGracePeriod = 5 * time.Minute
+
 type URLConfig struct {
 	BaseURL          string
 	ListModelsURL    string
@@ -152,10 +172,14 @@ func newReconciler(mgr manager.Manager, runtimeConfig config.RuntimeConfig) (rec
 		klog.Fatal(err)
 	}
 
-	// TODO: policy should be configured by users
-	scheduler, err := scheduling.NewScheduler(defaultModelAdapterSchedulerPolicy, c)
-	if err != nil {
-		return nil, err
+	// the placement strategy of a model adapter selects the scheduler choosing its pods.
+	schedulers := make(map[modelv1alpha1.ModelAdapterPlacementStrategy]scheduling.Scheduler, len(placementSchedulerPolicies))
+	for strategy, policy := range placementSchedulerPolicies {
+		scheduler, err := scheduling.NewScheduler(policy, c)
+		if err != nil {
+			return nil, err
+		}
+		schedulers[strategy] = scheduler
 	}
 
 	reconciler := &ModelAdapterReconciler{
@@ -164,56 +188,13 @@ func newReconciler(mgr manager.Manager, runtimeConfig config.RuntimeConfig) (rec
 		PodLister:           podLister,
 		ServiceLister:       serviceLister,
 		EndpointSliceLister: endpointSliceLister,
-		Recorder:            mgr.GetEventRecorderFor(controllerName),
-		scheduler:           scheduler,
+		Recorder:            events.NewRateLimitedRecorder(mgr.GetEventRecorderFor(controllerName), runtimeConfig.EventRateLimitInterval),
+		schedulers:          schedulers,
 		RuntimeConfig:       runtimeConfig,
 	}
 	return reconciler, nil
 }
 
-func podWithLabelFilter(labelKey, labelValue, modelIdKey string) predicate.Predicate {
-	hasLabelAndModelIdentifier := func(labels map[string]string, labelKey, labelValue, modelIdentifierKey string) bool {
-		if _, exists := labels[modelIdentifierKey]; !exists {
-			return false
-		}
-		return labels[labelKey] == labelValue
-	}
-
-	return predicate.Funcs{
-		CreateFunc: func(e event.CreateEvent) bool {
-			return hasLabelAndModelIdentifier(e.Object.GetLabels(), labelKey, labelValue, modelIdKey)
-		},
-		UpdateFunc: func(e event.UpdateEvent) bool {
-			return hasLabelAndModelIdentifier(e.ObjectNew.GetLabels(), labelKey, labelValue, modelIdKey)
-		},
-		DeleteFunc: func(e event.DeleteEvent) bool {
-			return hasLabelAndModelIdentifier(e.Object.GetLabels(), labelKey, labelValue, modelIdKey)
-		},
-		GenericFunc: func(e event.GenericEvent) bool {
-			return hasLabelAndModelIdentifier(e.Object.GetLabels(), labelKey, labelValue, modelIdKey)
-		},
-	}
-}
-
-func lookupLinkedModelAdapterInNamespace(c client.Client) handler.MapFunc {
-	return func(ctx context.Context, a client.Object) []reconcile.Request {
-		modelAdapterList := &modelv1alpha1.ModelAdapterList{}
-		if err := c.List(ctx, modelAdapterList, client.InNamespace(a.GetNamespace())); err != nil {
-			klog.ErrorS(err, "unable to list model adapters in namespace", "namespace", a.GetNamespace())
-			return []reconcile.Request{}
-		}
-
-		requests := make([]reconcile.Request, 0, len(modelAdapterList.Items))
-		for _, modelAdapter := range modelAdapterList.Items {
-			// Originally, we think it's better to check model adapter.Status.Instances, if it includes the pod name, then we put the model adapter into the queue
-			// However, there's other cases like pending model adapter needs to wait for new pods to be scheduled immediately, so we should reconcile all adapters if there're new pods added
-			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: a.GetNamespace(), Name: modelAdapter.GetName()}})
-		}
-
-		return requests
-	}
-}
-
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	// use the builder fashion. If we need more fine grain control later, we can switch to `controller.New()`
@@ -226,7 +207,7 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		))).
 		Owns(&corev1.Service{}).
 		Owns(&discoveryv1.EndpointSlice{}).
-		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(lookupLinkedModelAdapterInNamespace(mgr.GetClient())),
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(lookupModelAdaptersForPod(mgr.GetClient())),
 			builder.WithPredicates(podWithLabelFilter(ModelAdapterPodTemplateLabelKey, ModelAdapterPodTemplateLabelValue, ModelIdentifierKey))).
 		Complete(r)
 
@@ -239,9 +220,10 @@ var _ reconcile.Reconciler = &ModelAdapterReconciler{}
 // ModelAdapterReconciler reconciles a ModelAdapter object
 type ModelAdapterReconciler struct {
 	client.Client
-	Scheme    *runtime.Scheme
-	Recorder  record.EventRecorder
-	scheduler scheduling.Scheduler
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// schedulers choose the pods of the model adapters by placement strategy
+	schedulers map[modelv1alpha1.ModelAdapterPlacementStrategy]scheduling.Scheduler
 	// PodLister is able to list/get pods from a shared informer's cache store
 	PodLister corelisters.PodLister
 	// ServiceLister is able to list/get services from a shared informer's cache store
@@ -300,10 +282,16 @@ func (r *ModelAdapterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		// the object is being deleted
 		if controllerutil.ContainsFinalizer(modelAdapter, ModelAdapterFinalizer) {
 			// the finalizer is present, so let's unload lora from those inference engines
-			// note: the base model pod could be deleted as well, so here we do best effort offloading
-			// we do not need to reconcile the object if it encounters the unloading error.
+			// note: the base model pod could be deleted as well, those pods are skipped. The pods failing to unload
+			// are retried with backoff until the grace period expires, so that a dead pod doesn't block the deletion.
 			if err := r.unloadModelAdapter(ctx, modelAdapter); err != nil {
-				return ctrl.Result{}, err
+				gracePeriod := r.unloadGracePeriod()
+				if time.Since(modelAdapter.DeletionTimestamp.Time) < gracePeriod {
+					return ctrl.Result{}, err
+				}
+				klog.ErrorS(err, "Releasing ModelAdapter after the unload grace period", "ModelAdapter", klog.KObj(modelAdapter), "gracePeriod", gracePeriod)
+				r.Recorder.Eventf(modelAdapter, corev1.EventTypeWarning, UnloadGracePeriodExceededReason,
+					"Removing the finalizer after the unload grace period of %v: %v", gracePeriod, err)
 			}
 			if ok := controllerutil.RemoveFinalizer(modelAdapter, ModelAdapterFinalizer); !ok {
 				klog.Error("Failed to remove finalizer for ModelAdapter")
@@ -322,12 +310,15 @@ func (r *ModelAdapterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 }
 
 func (r *ModelAdapterReconciler) DoReconcile(ctx context.Context, req ctrl.Request, instance *modelv1alpha1.ModelAdapter) (ctrl.Result, error) {
+	// original is the status last persisted, the status writes patch the changes made since.
+	original := instance.DeepCopy()
+
 	// Let's set the initial status when no status is available
 	if instance.Status.Conditions == nil || len(instance.Status.Conditions) == 0 {
 		instance.Status.Phase = modelv1alpha1.ModelAdapterPending
 		condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeInitialized), metav1.ConditionUnknown,
 			ModelAdapterInitializedReason, "Starting reconciliation")
-		if err := r.updateStatus(ctx, instance, condition); err != nil {
+		if err := r.updateStatus(ctx, original, instance, condition); err != nil {
 			return reconcile.Result{}, err
 		} else {
 			return reconcile.Result{Requeue: true}, nil
@@ -336,160 +327,126 @@ func (r *ModelAdapterReconciler) DoReconcile(ctx context.Context, req ctrl.Reque
 
 	oldInstance := instance.DeepCopy()
 
-	// Step 1: Schedule Pod for ModelAdapter
-	selectedPod := &corev1.Pod{}
-	existPods := false
-	var err error
-	if instance.Status.Instances != nil && len(instance.Status.Instances) != 0 {
-		// model adapter has already been scheduled to some pods
-		// check the scheduled pod first, verify the mapping is still valid.
-		// TODO: this needs to be changed once we support multiple lora adapters
-		selectedPodName := instance.Status.Instances[0]
-		if err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: selectedPodName}, selectedPod); err != nil && apierrors.IsNotFound(err) {
-			klog.ErrorS(err, "Selected pod has been deleted and it should be removed from model adapter instance list", "modelAdapter", klog.KObj(instance))
-			// instance.Status.Instances has been outdated, and we need to clear the pod list
-			// after the pod list is cleaned up, let's reconcile the instance object again in the next loop
-			return ctrl.Result{}, r.clearModelAdapterInstanceList(ctx, instance, selectedPodName)
-		} else if err != nil {
-			// failed to fetch the pod, let's requeue
-			return ctrl.Result{RequeueAfter: defaultRequeueDuration}, err
-		} else {
-			// compare instance and model adapter labels.
-			selector, err := metav1.LabelSelectorAsSelector(instance.Spec.PodSelector)
-			if err != nil {
-				// TODO: this should barely happen, let's move this logic to earlier validation logics.
-				return ctrl.Result{}, fmt.Errorf("failed to convert pod selector: %v", err)
-			}
-			if !selector.Matches(labels.Set(selectedPod.Labels)) {
-				klog.Warning("current assigned pod selector doesn't match model adapter selector")
-				return ctrl.Result{}, r.clearModelAdapterInstanceList(ctx, instance, selectedPodName)
-			}
-
-			// base model pod could be unhealthy or in termination, let's clean up the instances.
-			if !utils.IsPodReady(selectedPod) || utils.IsPodTerminating(selectedPod) {
-				klog.Warningf("current assigned pod %s/%s is not ready, remove it and reschedule the adapter", selectedPod.Namespace, selectedPod.Name)
-				return ctrl.Result{}, r.clearModelAdapterInstanceList(ctx, instance, selectedPodName)
-			}
-
-			existPods = true
-		}
+	// Step 1: Place ModelAdapter on its replicas
+	changed, err := r.reconcilePlacement(ctx, instance)
+	if err != nil {
+		klog.ErrorS(err, "Failed to schedule Pod for ModelAdapter", "modelAdapter", klog.KObj(instance))
+		return ctrl.Result{}, err
 	}
 
-	if !existPods {
-		// TODO: as we plan to support lora replicas, it needs some corresponding changes.
-		// it should return a list of pods in future, otherwise, it should be invoked by N times.
-		activePods, err := r.getActivePodsForModelAdapter(ctx, instance)
-		if err != nil {
+	pending := false
+	if len(instance.Status.Instances) == 0 {
+		// the model adapter is loaded once the base model deployment comes up.
+		klog.Warningf("no active pods found for model adapter %v", klog.KObj(instance))
+		pending = true
+		instance.Status.Phase = modelv1alpha1.ModelAdapterPending
+		condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeScheduled), metav1.ConditionFalse,
+			NoActivePodsReason, fmt.Sprintf("No ready pod matches the pod selector of ModelAdapter %s", klog.KObj(instance)))
+		if err := r.updateStatus(ctx, original, instance, condition); err != nil {
+			klog.InfoS("Got error when updating status", "error", err, "ModelAdapter", instance)
 			return ctrl.Result{}, err
 		}
-		if len(activePods) != 0 {
-			selectedPod, err = r.schedulePod(ctx, instance, activePods)
-			if err != nil {
-				klog.ErrorS(err, "Failed to schedule Pod for ModelAdapter", "modelAdapter", klog.KObj(instance))
-				return ctrl.Result{}, err
-			}
-
-			instance.Status.Phase = modelv1alpha1.ModelAdapterScheduled
-			instance.Status.Instances = append(instance.Status.Instances, selectedPod.Name)
-			condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeScheduled), metav1.ConditionTrue,
-				"Scheduled", fmt.Sprintf("ModelAdapter %s has been allocated to pod %s/%s", klog.KObj(instance), selectedPod.GetNamespace(), selectedPod.GetName()))
-			if err := r.updateStatus(ctx, instance, condition); err != nil {
-				klog.InfoS("Got error when updating status", "error", err, "ModelAdapter", instance)
-				return ctrl.Result{}, err
-			}
-
-			return ctrl.Result{Requeue: true}, nil
-		} else {
-			klog.Warningf("no active pods found for model adapter %v", klog.KObj(instance))
+	} else if changed {
+		instance.Status.Phase = modelv1alpha1.ModelAdapterScheduled
+		if err := r.updateStatus(ctx, original, instance, newPlacementCondition(instance)); err != nil {
+			klog.InfoS("Got error when updating status", "error", err, "ModelAdapter", instance)
+			return ctrl.Result{}, err
 		}
+
+		return ctrl.Result{Requeue: true}, nil
 	}
 
 	// Step 2: Reconcile Loading
 	if err := r.reconcileLoading(ctx, instance); err != nil {
-		// retry any of the failure.
+		// record the failure of the pod, the error retries the loading with an exponential backoff.
 		instance.Status.Phase = modelv1alpha1.ModelAdapterBound
 		condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeBound), metav1.ConditionFalse,
-			ModelAdapterLoadingErrorReason, fmt.Sprintf("ModelAdapter %s is loaded", klog.KObj(instance)))
-		if err := r.updateStatus(ctx, instance, condition); err != nil {
+			ModelAdapterLoadingErrorReason, fmt.Sprintf("ModelAdapter %s failed to load: %v", klog.KObj(instance), err))
+		if err := r.updateStatus(ctx, original, instance, condition); err != nil {
 			klog.InfoS("Got error when updating status", "cluster name", req.Name, "error", err, "ModelAdapter", instance)
 			return ctrl.Result{}, err
 		}
 
+		return ctrl.Result{}, err
+	}
+
+	// Step 3: Reconcile Rollout
+	rolloutResult, err := r.reconcileRollout(ctx, instance)
+	if err != nil {
+		instance.Status.Phase = modelv1alpha1.ModelAdapterBound
+		condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeBound), metav1.ConditionFalse,
+			ModelAdapterLoadingErrorReason, fmt.Sprintf("ModelAdapter %s failed to roll out artifact %s", klog.KObj(instance), instance.Spec.ArtifactURL))
+		if err := r.updateStatus(ctx, original, instance, condition); err != nil {
+			klog.InfoS("Got error when updating status", "error", err, "ModelAdapter", instance)
+			return ctrl.Result{}, err
+		}
+
 		return ctrl.Result{RequeueAfter: defaultRequeueDuration}, err
 	}
 
-	// Step 3: Reconcile Service
-	if ctrlResult, err := r.reconcileService(ctx, instance); err != nil {
+	// Step 4: Reconcile Service
+	if ctrlResult, err := r.reconcileService(ctx, original, instance); err != nil {
 		instance.Status.Phase = modelv1alpha1.ModelAdapterResourceCreated
 		condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeResourceCreated), metav1.ConditionFalse,
 			FailedServiceCreateReason, "service creation failure")
-		if err := r.updateStatus(ctx, instance, condition); err != nil {
+		if err := r.updateStatus(ctx, original, instance, condition); err != nil {
 			klog.InfoS("Got error when updating status", req.Name, "error", err, "ModelAdapter", instance)
 			return ctrl.Result{}, err
 		}
 		return ctrlResult, err
 	}
 
-	// Step 4: Reconcile EndpointSlice
-	if ctrlResult, err := r.reconcileEndpointSlice(ctx, instance); err != nil {
+	// Step 5: Reconcile EndpointSlice
+	if ctrlResult, err := r.reconcileEndpointSlice(ctx, original, instance); err != nil {
 		instance.Status.Phase = modelv1alpha1.ModelAdapterResourceCreated
 		condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeResourceCreated), metav1.ConditionFalse,
 			FailedEndpointSliceCreateReason, "endpointslice creation failure")
-		if err := r.updateStatus(ctx, instance, condition); err != nil {
+		if err := r.updateStatus(ctx, original, instance, condition); err != nil {
 			klog.InfoS("Got error when updating status", "error", err, "ModelAdapter", instance)
 			return ctrl.Result{}, err
 		}
 		return ctrlResult, err
 	}
 
-	// Check if we need to update the status.
-	if r.inconsistentModelAdapterStatus(oldInstance.Status, instance.Status) {
-		condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionReady), metav1.ConditionTrue,
-			ModelAdapterAvailable, fmt.Sprintf("ModelAdapter %s is ready", klog.KObj(instance)))
-		if err = r.updateStatus(ctx, instance, condition); err != nil {
+	// Check if we need to update the status, the Ready condition follows the loaded instances.
+	if !pending && r.inconsistentModelAdapterStatus(oldInstance.Status, instance.Status) {
+		if err = r.updateStatus(ctx, original, instance); err != nil {
 			return reconcile.Result{}, fmt.Errorf("update modelAdapter status error: %v", err)
 		}
 	}
 
-	return ctrl.Result{}, nil
+	if pending {
+		return ctrl.Result{RequeueAfter: pendingRequeueDuration}, nil
+	}
+	// the missing replicas are placed once more pods of the base model are ready.
+	if len(instance.Status.Instances) < desiredReplicas(instance) &&
+		(rolloutResult.RequeueAfter == 0 || rolloutResult.RequeueAfter > pendingRequeueDuration) {
+		rolloutResult.RequeueAfter = pendingRequeueDuration
+	}
+	return rolloutResult, nil
 }
 
-func (r *ModelAdapterReconciler) updateStatus(ctx context.Context, instance *modelv1alpha1.ModelAdapter, conditions ...metav1.Condition) error {
+// updateStatus sets the conditions and patches the status of the instance with the changes since original, which
+// is refreshed once the status is persisted. The load states of the pods removed from the instances are dropped and
+// the Ready condition is derived from the loaded instances, the patch is skipped when nothing changed.
+func (r *ModelAdapterReconciler) updateStatus(ctx context.Context, original, instance *modelv1alpha1.ModelAdapter, conditions ...metav1.Condition) error {
+	syncInstanceStatuses(&instance.Status)
 	changed := false
 	for _, condition := range conditions {
 		if meta.SetStatusCondition(&instance.Status.Conditions, condition) {
 			changed = true
 		}
 	}
+	if meta.SetStatusCondition(&instance.Status.Conditions, newReadyCondition(instance)) {
+		changed = true
+	}
 	// TODO: sort the conditions based on LastTransitionTime if needed.
 	klog.InfoS("model adapter reconcile", "Update CR status", instance.Name, "changed", changed, "status", instance.Status, "conditions", conditions)
-	return r.Status().Update(ctx, instance)
-}
-
-func (r *ModelAdapterReconciler) clearModelAdapterInstanceList(ctx context.Context, instance *modelv1alpha1.ModelAdapter, stalePodName string) error {
-	instance.Status.Instances = RemoveInstanceFromList(instance.Status.Instances, stalePodName)
-	// remove instance means the lora has not targets at this moment.
-	instance.Status.Phase = modelv1alpha1.ModelAdapterPending
-	condition := NewCondition(string(modelv1alpha1.ModelAdapterFailed), metav1.ConditionTrue,
-		StableInstanceFoundReason,
-		fmt.Sprintf("Pod (%s/%s) is stale or invalid for model adapter (%s/%s), clean up the list", instance.GetNamespace(), stalePodName, instance.GetNamespace(), instance.Name))
-
-	// We also need to update the scheduling and ready status to false
-	// When the pod get migrated, we need to update the status with latest LastTransitionTime.
-	// However, meta.SetStatusCondition won't update the status unless there's other change like Status as well.
-	// Here it also help trigger time update in later updates.
-	scheduleCondition := meta.FindStatusCondition(instance.Status.Conditions, string(modelv1alpha1.ModelAdapterConditionTypeScheduled))
-	scheduleCondition.Status = metav1.ConditionFalse
-	scheduleCondition.LastTransitionTime = metav1.Now()
-
-	readyCondition := meta.FindStatusCondition(instance.Status.Conditions, string(modelv1alpha1.ModelAdapterConditionReady))
-	readyCondition.Status = metav1.ConditionFalse
-	readyCondition.LastTransitionTime = metav1.Now()
-
-	if err := r.updateStatus(ctx, instance, condition, *scheduleCondition, *readyCondition); err != nil {
+	if err := utils.PatchStatus(ctx, r.Client, original, instance); err != nil {
 		return err
 	}
-
+	original.Status = *instance.Status.DeepCopy()
+	original.ResourceVersion = instance.ResourceVersion
 	return nil
 }
 
@@ -517,20 +474,21 @@ func (r *ModelAdapterReconciler) getActivePodsForModelAdapter(ctx context.Contex
 	return activePods, nil
 }
 
-// schedulePod picks a valid pod to schedule the model adapter
-func (r *ModelAdapterReconciler) schedulePod(ctx context.Context, instance *modelv1alpha1.ModelAdapter, activePods []corev1.Pod) (*corev1.Pod, error) {
-	// Implement your scheduling logic here to select a Pod based on the instance.Spec.PodSelector
-	// For the sake of example, we will just list the Pods matching the selector and pick the first one
-	return r.scheduler.SelectPod(ctx, instance.Name, activePods)
-}
-
+// reconcileLoading loads the model adapter on the pods of its instances, the pods which failed to load it are retried
+// with an exponential backoff while the others are loaded.
 func (r *ModelAdapterReconciler) reconcileLoading(ctx context.Context, instance *modelv1alpha1.ModelAdapter) error {
-	if len(instance.Status.Instances) == 0 {
-		return nil
+	var errs []error
+	for _, podName := range instance.Status.Instances {
+		if err := r.reconcileLoadingOnPod(ctx, instance, podName); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
 
+// reconcileLoadingOnPod loads the model adapter on the pod, unless the pod already serves it.
+func (r *ModelAdapterReconciler) reconcileLoadingOnPod(ctx context.Context, instance *modelv1alpha1.ModelAdapter, podName string) error {
 	targetPod := &corev1.Pod{}
-	podName := instance.Status.Instances[0]
 	err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: podName}, targetPod)
 	if err != nil && apierrors.IsNotFound(err) {
 		return fmt.Errorf("pod %s/%s can not be found, skip loading", instance.GetName(), podName)
@@ -546,30 +504,43 @@ func (r *ModelAdapterReconciler) reconcileLoading(ctx context.Context, instance
 	urls := BuildURLs(targetPod.Status.PodIP, r.RuntimeConfig)
 
 	// Check if the model is already loaded
-	exists, err := r.modelAdapterExists(urls.ListModelsURL, instance)
+	exists, err := r.modelAdapterExists(urls.ListModelsURL, instance, instance.Name)
 	if err != nil {
-		return err
+		setInstancePhase(instance, targetPod, modelv1alpha1.ModelAdapterInstanceFailed, fmt.Sprintf("Failed to list the models: %v", err))
+		return fmt.Errorf("pod %s: %w", podName, err)
 	}
 	if exists {
 		klog.V(4).Info("LoRA model has been registered previously, skipping registration")
+		setInstancePhase(instance, targetPod, modelv1alpha1.ModelAdapterInstanceLoaded, "")
 		return nil
 	}
 
-	// Load the Model adapter
-	err = r.loadModelAdapter(urls.LoadAdapterURL, instance)
+	// Load the Model adapter, during a rollout the name of the model adapter keeps serving the previous artifact.
+	err = r.loadModelAdapter(urls.LoadAdapterURL, instance, instance.Name, loadedArtifactURL(instance))
 	if err != nil {
-		return err
+		setInstancePhase(instance, targetPod, modelv1alpha1.ModelAdapterInstanceFailed, fmt.Sprintf("Failed to load: %v", err))
+		return fmt.Errorf("pod %s: %w", podName, err)
 	}
+	setInstancePhase(instance, targetPod, modelv1alpha1.ModelAdapterInstanceLoaded, "")
 
 	return nil
 }
 
 // Separate method to check if the model already exists
-func (r *ModelAdapterReconciler) modelAdapterExists(url string, instance *modelv1alpha1.ModelAdapter) (bool, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (r *ModelAdapterReconciler) modelAdapterExists(url string, instance *modelv1alpha1.ModelAdapter, loraName string) (bool, error) {
+	models, err := r.listModels(url, instance)
 	if err != nil {
 		return false, err
 	}
+	return containsModel(models, loraName), nil
+}
+
+// listModels returns the models served by the engine, the base model and the loaded adapters.
+func (r *ModelAdapterReconciler) listModels(url string, instance *modelv1alpha1.ModelAdapter) ([]map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
 	// Check if "api-key" exists in the map and set the Authorization header accordingly
 	if token, ok := instance.Spec.AdditionalConfig["api-key"]; ok {
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
@@ -578,7 +549,7 @@ func (r *ModelAdapterReconciler) modelAdapterExists(url string, instance *modelv
 	c := &http.Client{}
 	resp, err := c.Do(req)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -588,38 +559,36 @@ func (r *ModelAdapterReconciler) modelAdapterExists(url string, instance *modelv
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("failed to get models: %s", body)
+		return nil, fmt.Errorf("failed to get models: %s", body)
 	}
 
 	var response map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return false, err
+		return nil, err
 	}
 
 	data, ok := response["data"].([]interface{})
 	if !ok {
-		return false, errors.New("invalid data format")
+		return nil, errors.New("invalid data format")
 	}
 
+	models := make([]map[string]interface{}, 0, len(data))
 	for _, item := range data {
 		model, ok := item.(map[string]interface{})
 		if !ok {
 			continue
 		}
-		if model["id"] == instance.Name {
-			return true, nil
-		}
+		models = append(models, model)
 	}
 
-	return false, nil
+	return models, nil
 }
 
 // Separate method to load the LoRA adapter
-func (r *ModelAdapterReconciler) loadModelAdapter(url string, instance *modelv1alpha1.ModelAdapter) error {
-	artifactURL := instance.Spec.ArtifactURL
-	if strings.HasPrefix(instance.Spec.ArtifactURL, "huggingface://") {
+func (r *ModelAdapterReconciler) loadModelAdapter(url string, instance *modelv1alpha1.ModelAdapter, loraName, artifactURL string) error {
+	if strings.HasPrefix(artifactURL, "huggingface://") {
 		var err error
-		artifactURL, err = extractHuggingFacePath(instance.Spec.ArtifactURL)
+		artifactURL, err = extractHuggingFacePath(artifactURL)
 		if err != nil {
 			// Handle error, e.g., log it and return
 			klog.ErrorS(err, "Invalid artifact URL", "artifactURL", artifactURL)
@@ -629,7 +598,7 @@ func (r *ModelAdapterReconciler) loadModelAdapter(url string, instance *modelv1a
 	// TODO: extend to other artifacts
 
 	payload := map[string]string{
-		"lora_name": instance.Name,
+		"lora_name": loraName,
 		"lora_path": artifactURL,
 	}
 	payloadBytes, err := json.Marshal(payload)
@@ -660,46 +629,91 @@ func (r *ModelAdapterReconciler) loadModelAdapter(url string, instance *modelv1a
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
+		// the adapter was loaded since the models of the engine were listed.
+		if isAdapterAlreadyLoaded(resp.StatusCode, body) {
+			klog.V(4).InfoS("LoRA adapter has already been loaded", "loraName", loraName)
+			return nil
+		}
 		return fmt.Errorf("failed to load LoRA adapter: %s", body)
 	}
 
 	return nil
 }
 
-// unloadModelAdapter unloads the loras from inference engines
-// base model pod could be deleted, in this case, we just do optimistic unloading. It only returns some necessary errors and http errors should not be returned.
+// unloadModelAdapter unloads the loras from the inference engines of the pods recorded in the status. The pods
+// deleted since, or without an IP, are skipped. It returns the errors of the pods which failed to confirm the
+// unloading, so that the deletion is retried.
 func (r *ModelAdapterReconciler) unloadModelAdapter(ctx context.Context, instance *modelv1alpha1.ModelAdapter) error {
 	if len(instance.Status.Instances) == 0 {
 		klog.Warningf("model adapter %s/%s has not been deployed to any pods yet, skip unloading", instance.GetNamespace(), instance.GetName())
 		return nil
 	}
 
-	// TODO:(jiaxin.shan) Support multiple instances
+	var errs []error
+	for _, podName := range instance.Status.Instances {
+		targetPod := &corev1.Pod{}
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: instance.Namespace,
+			Name:      podName,
+		}, targetPod); err != nil {
+			if apierrors.IsNotFound(err) {
+				klog.Warningf("Failed to find lora Pod instance %s/%s from apiserver, skip unloading", instance.GetNamespace(), podName)
+				continue
+			}
+			klog.Warning("Error getting Pod from lora instance list", err)
+			errs = append(errs, fmt.Errorf("pod %s: %w", podName, err))
+			continue
+		}
+		if targetPod.Status.PodIP == "" {
+			klog.Warningf("lora Pod instance %s/%s has no IP, skip unloading", instance.GetNamespace(), podName)
+			continue
+		}
 
-	podName := instance.Status.Instances[0]
-	targetPod := &corev1.Pod{}
-	if err := r.Get(ctx, types.NamespacedName{
-		Namespace: instance.Namespace,
-		Name:      podName,
-	}, targetPod); err != nil {
-		if apierrors.IsNotFound(err) {
-			klog.Warningf("Failed to find lora Pod instance %s/%s from apiserver, skip unloading", instance.GetNamespace(), podName)
-			return nil
+		urls := BuildURLs(targetPod.Status.PodIP, r.RuntimeConfig)
+		err := r.confirmUnloadAdapter(urls.UnloadAdapterURL, instance, instance.Name)
+		// the new artifact of an ongoing rollout is loaded under its versioned name.
+		if err == nil && instance.Status.Rollout != nil {
+			err = r.confirmUnloadAdapter(urls.UnloadAdapterURL, instance, instance.Status.Rollout.Version)
 		}
-		klog.Warning("Error getting Pod from lora instance list", err)
-		return err
+		if err != nil {
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning, FailedUnloadReason, "Failed to unload from pod %s: %v", podName, err)
+			errs = append(errs, fmt.Errorf("pod %s: %w", podName, err))
+			continue
+		}
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, UnloadedReason, "Unloaded from pod %s", podName)
 	}
+	return errors.Join(errs...)
+}
+
+// unloadGracePeriod returns how long the deletion of a model adapter waits for its pods to confirm the unloading.
+func (r *ModelAdapterReconciler) unloadGracePeriod() time.Duration {
+	if r.RuntimeConfig.ModelAdapterUnloadGracePeriod > 0 {
+		return r.RuntimeConfig.ModelAdapterUnloadGracePeriod
+	}
+	return DefaultUnloadGracePeriod
+}
 
+// unloadAdapterVersion unloads the adapter loaded under loraName on a best effort basis, the base model pod may be
+// gone already. The unloading errors are logged rather than returned.
+func (r *ModelAdapterReconciler) unloadAdapterVersion(url string, instance *modelv1alpha1.ModelAdapter, loraName string) error {
+	if err := r.confirmUnloadAdapter(url, instance, loraName); err != nil {
+		klog.Warningf("failed to unload LoRA adapter %s: %v", loraName, err)
+	}
+	return nil
+}
+
+// confirmUnloadAdapter unloads the adapter loaded under loraName, an adapter the engine doesn't know is unloaded
+// already.
+func (r *ModelAdapterReconciler) confirmUnloadAdapter(url string, instance *modelv1alpha1.ModelAdapter, loraName string) error {
 	payload := map[string]string{
-		"lora_name": instance.Name,
+		"lora_name": loraName,
 	}
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
 
-	urls := BuildURLs(targetPod.Status.PodIP, r.RuntimeConfig)
-	req, err := http.NewRequest("POST", urls.UnloadAdapterURL, bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return err
 	}
@@ -709,10 +723,10 @@ func (r *ModelAdapterReconciler) unloadModelAdapter(ctx context.Context, instanc
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
-	httpClient := &http.Client{}
+	httpClient := &http.Client{Timeout: unloadTimeout}
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil
+		return err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -722,111 +736,88 @@ func (r *ModelAdapterReconciler) unloadModelAdapter(ctx context.Context, instanc
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
-		klog.Warningf("failed to unload LoRA adapter: %s", body)
+		if isAdapterNotLoaded(resp.StatusCode, body) {
+			klog.V(4).InfoS("LoRA adapter is not loaded", "loraName", loraName)
+			return nil
+		}
+		return fmt.Errorf("failed to unload LoRA adapter: %s", body)
 	}
 
 	return nil
 }
 
-func (r *ModelAdapterReconciler) reconcileService(ctx context.Context, instance *modelv1alpha1.ModelAdapter) (ctrl.Result, error) {
+// reconcileService keeps the headless service of the model adapter, the service is recreated when it is outdated.
+func (r *ModelAdapterReconciler) reconcileService(ctx context.Context, original, instance *modelv1alpha1.ModelAdapter) (ctrl.Result, error) {
+	svc := buildModelAdapterService(instance)
+
 	// Retrieve the Service from the Kubernetes cluster with the name and namespace.
 	found := &corev1.Service{}
 	err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}, found)
-	if err != nil && apierrors.IsNotFound(err) {
-		// Service does not exist, create a new one
-		svc := buildModelAdapterService(instance)
-		// Set the owner reference
-		if err := ctrl.SetControllerReference(instance, svc, r.Scheme); err != nil {
-			klog.Error(err, "Failed to set controller reference to modelAdapter")
-			return ctrl.Result{}, err
-		}
-
-		// create service
-		klog.InfoS("Creating a new service", "service", klog.KObj(svc))
-		if err = r.Create(ctx, svc); err != nil {
-			klog.ErrorS(err, "Failed to create new service resource for ModelAdapter", "service", klog.KObj(svc))
-			condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeResourceCreated), metav1.ConditionFalse,
-				FailedServiceCreateReason, fmt.Sprintf("Failed to create Service for the modeladapter (%s): (%s)", klog.KObj(instance), err))
-			if err := r.updateStatus(ctx, instance, condition); err != nil {
-				return ctrl.Result{}, err
-			}
-			return ctrl.Result{}, err
-		}
-	} else if err != nil {
+	if err != nil && !apierrors.IsNotFound(err) {
 		klog.ErrorS(err, "Failed to get Service")
 		return ctrl.Result{}, err
 	}
-	// TODO: add `else` logic let's compare the service major fields and update to the target state.
-
-	// TODO: Now, we are using the name comparison which is not enough,
-	// compare the object difference in future.
-	return ctrl.Result{}, nil
-}
-
-func (r *ModelAdapterReconciler) reconcileEndpointSlice(ctx context.Context, instance *modelv1alpha1.ModelAdapter) (ctrl.Result, error) {
-	// check if the endpoint slice already exists, if not create a new one.
-	found := &discoveryv1.EndpointSlice{}
-	// instance could be clean up in earlier reconciliation, we need to clean up the instance from endpoint list.
-	if len(instance.Status.Instances) == 0 {
-		klog.Warningf("model adapter %s has not been deployed to any pods yet or being deleted, skip creating endpointslice", klog.KObj(instance))
-
-		// reset endpoint slice
-		if err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}, found); err != nil {
-			if apierrors.IsNotFound(err) {
-				klog.Warningf("Failed to fetch the endpoint slice %s", klog.KObj(instance))
-			}
-			return ctrl.Result{}, err
+	if err == nil {
+		if !modelAdapterServiceOutdated(instance, found, svc) {
+			return ctrl.Result{}, nil
 		}
-		found.Endpoints = []discoveryv1.Endpoint{}
-		if err := r.Update(ctx, found); err != nil {
-			klog.ErrorS(err, "Failed to update EndpointSlice after clearing endpoints", "EndpointSlice", found.Name)
+		klog.InfoS("Recreating outdated service", "service", klog.KObj(found))
+		if err := r.Delete(ctx, found, client.Preconditions{UID: &found.UID}); err != nil && !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "Failed to delete outdated service", "service", klog.KObj(found))
 			return ctrl.Result{}, err
 		}
+	}
 
-		return ctrl.Result{}, nil
+	// Service does not exist, create a new one
+	// Set the owner reference
+	if err := ctrl.SetControllerReference(instance, svc, r.Scheme); err != nil {
+		klog.Error(err, "Failed to set controller reference to modelAdapter")
+		return ctrl.Result{}, err
 	}
 
-	// TODO: do necessary refactor to support multiple lora instance
-	podName := instance.Status.Instances[0]
-	pod := &corev1.Pod{}
-	if err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: podName}, pod); err != nil {
-		if !apierrors.IsNotFound(err) {
-			klog.Warning("Error getting Pod from lora instance list", err)
+	// create service
+	klog.InfoS("Creating a new service", "service", klog.KObj(svc))
+	if err = r.Create(ctx, svc); err != nil {
+		klog.ErrorS(err, "Failed to create new service resource for ModelAdapter", "service", klog.KObj(svc))
+		condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeResourceCreated), metav1.ConditionFalse,
+			FailedServiceCreateReason, fmt.Sprintf("Failed to create Service for the modeladapter (%s): (%s)", klog.KObj(instance), err))
+		if err := r.updateStatus(ctx, original, instance, condition); err != nil {
 			return ctrl.Result{}, err
 		}
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
 
-		klog.Warningf("pod %s/%s has been deleted, let's clean up the endpoint slice", instance.GetNamespace(), podName)
-		// TODO: do necessary refactor to support multiple lora instance
-		// the tricky thing is we do not know the pod map to pod ip mapping. instance only save pods, endpointslice only save ips
-		if err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}, found); err != nil {
-			if apierrors.IsNotFound(err) {
-				// this should barely happen, in this case, there's no need to move forward
-				klog.Warningf("Endpoint slice %s doesn't exist", klog.KObj(instance))
-				return ctrl.Result{}, nil
-			}
-			return ctrl.Result{}, err
-		}
+// reconcileEndpointSlice keeps the endpoints of the model adapter on the pods of its instances which loaded it.
+func (r *ModelAdapterReconciler) reconcileEndpointSlice(ctx context.Context, original, instance *modelv1alpha1.ModelAdapter) (ctrl.Result, error) {
+	loaded := loadedInstances(instance)
+	eps := buildModelAdapterEndpointSlice(instance, loaded...)
 
-		// reset endpoint slice
-		found.Endpoints = []discoveryv1.Endpoint{}
-		if err := r.Update(ctx, found); err != nil {
-			klog.ErrorS(err, "Failed to update EndpointSlice after clearing endpoints", "EndpointSlice", found.Name)
+	// check if the endpoint slice already exists, if not create a new one.
+	found := &discoveryv1.EndpointSlice{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}, found)
+	if err != nil && !apierrors.IsNotFound(err) {
+		klog.ErrorS(err, "Failed to get EndpointSlice")
+		return ctrl.Result{}, err
+	}
+	exists := err == nil
+	if exists && !metav1.IsControlledBy(found, instance) {
+		// the endpoint slice was left over by a previous model adapter of the same name.
+		klog.InfoS("Recreating EndpointSlice of a previous ModelAdapter", "endpointslice", klog.KObj(found))
+		if err := r.Delete(ctx, found, client.Preconditions{UID: &found.UID}); err != nil && !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "Failed to delete EndpointSlice", "endpointslice", klog.KObj(found))
 			return ctrl.Result{}, err
 		}
-
-		return ctrl.Result{}, nil
+		exists = false
 	}
-
-	// pod object fetched, let's check existence of endpoint slice
-	err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: instance.Name}, found)
-	if err != nil {
-		if !apierrors.IsNotFound(err) {
-			klog.ErrorS(err, "Failed to get EndpointSlice")
-			return ctrl.Result{}, err
+	if !exists {
+		if len(loaded) == 0 {
+			klog.V(4).InfoS("ModelAdapter has not been loaded on any pods yet, skip creating endpointslice", "modelAdapter", klog.KObj(instance))
+			return ctrl.Result{}, nil
 		}
 
 		// EndpointSlice does not exist, create it
-		eps := buildModelAdapterEndpointSlice(instance, pod)
 		// Set the owner reference
 		if err := ctrl.SetControllerReference(instance, eps, r.Scheme); err != nil {
 			klog.Error(err, "Failed to set controller reference to modelAdapter")
@@ -840,88 +831,37 @@ func (r *ModelAdapterReconciler) reconcileEndpointSlice(ctx context.Context, ins
 			instance.Status.Phase = modelv1alpha1.ModelAdapterFailed
 			condition := NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeResourceCreated), metav1.ConditionFalse,
 				FailedEndpointSliceCreateReason, fmt.Sprintf("Failed to create EndpointSlice for the custom resource (%s): (%s)", instance.Name, err))
-			if err := r.updateStatus(ctx, instance, condition); err != nil {
+			if err := r.updateStatus(ctx, original, instance, condition); err != nil {
 				return ctrl.Result{}, err
 			}
 			return ctrl.Result{}, err
 		}
 		instance.Status.Phase = modelv1alpha1.ModelAdapterRunning
-	} else {
-		// Existing EndpointSlice Found. Check if the Pod IP is already in the EndpointSlice
-		podIP := pod.Status.PodIP
-		alreadyExists := false
-		for _, endpoint := range found.Endpoints {
-			for _, address := range endpoint.Addresses {
-				if address == podIP {
-					alreadyExists = true
-					break
-				}
-			}
-			if alreadyExists {
-				break
-			}
-		}
-
-		// Append the Pod IP to the EndpointSlice if it doesn't exist
-		if !alreadyExists {
-			// TODO: come back when we start to support multi-instance.
-			//found.Endpoints = append(found.Endpoints, discoveryv1.Endpoint{
-			//	Addresses: []string{podIP},
-			//})
-
-			// override the endpoint with only one pod id.
-			// TODO: We need to refactor the logic once we start to support multi instances later
-			found.Endpoints = []discoveryv1.Endpoint{
-				{
-					Addresses: []string{podIP},
-				},
-			}
-
-			if err := r.Update(ctx, found); err != nil {
-				klog.ErrorS(err, "Failed to update EndpointSlice", "EndpointSlice", found.Name)
-				return ctrl.Result{}, err
-			}
-			instance.Status.Phase = modelv1alpha1.ModelAdapterRunning
-			klog.InfoS("Successfully updated EndpointSlice", "EndpointSlice", found.Name)
-		} else {
-			// pod has been deleted, and we should remove the pod name from the list
-			if pod.DeletionTimestamp != nil {
-				var updatedEndpoints []discoveryv1.Endpoint
-				podIP := pod.Status.PodIP
-
-				for _, endpoint := range found.Endpoints {
-					shouldRemove := false
-					var newAddresses []string
-
-					for _, address := range endpoint.Addresses {
-						if address == podIP {
-							shouldRemove = true
-						} else {
-							newAddresses = append(newAddresses, address)
-						}
-					}
-
-					if !shouldRemove || len(newAddresses) > 0 {
-						endpoint.Addresses = newAddresses
-						updatedEndpoints = append(updatedEndpoints, endpoint)
-					}
-				}
-
-				found.Endpoints = updatedEndpoints
-				if err := r.Update(ctx, found); err != nil {
-					klog.ErrorS(err, "Failed to update EndpointSlice after removing PodIP", "EndpointSlice", found.Name)
-					return ctrl.Result{}, err
-				}
+		return ctrl.Result{}, nil
+	}
 
-				instance.Status.Phase = modelv1alpha1.ModelAdapterFailed
-				klog.InfoS("Successfully removed Pod IP from EndpointSlice", "PodIP", podIP, "EndpointSlice", found.Name)
-			} else {
-				klog.V(4).InfoS("Pod IP already exists in EndpointSlice", "PodName", pod.Name, "PodIP", podIP)
-				instance.Status.Phase = modelv1alpha1.ModelAdapterRunning
-			}
+	// Existing EndpointSlice Found, the endpoints follow the loaded instances.
+	if !equality.Semantic.DeepEqual(found.Endpoints, eps.Endpoints) ||
+		!equality.Semantic.DeepEqual(found.Ports, eps.Ports) ||
+		found.Labels[discoveryv1.LabelServiceName] != instance.Name ||
+		found.Labels[discoveryv1.LabelManagedBy] != endpointSliceManagedBy {
+		found.Endpoints = eps.Endpoints
+		found.Ports = eps.Ports
+		if found.Labels == nil {
+			found.Labels = map[string]string{}
+		}
+		for key, value := range eps.Labels {
+			found.Labels[key] = value
+		}
+		if err := r.Update(ctx, found); err != nil {
+			klog.ErrorS(err, "Failed to update EndpointSlice", "EndpointSlice", found.Name)
+			return ctrl.Result{}, err
 		}
+		klog.InfoS("Successfully updated EndpointSlice", "EndpointSlice", found.Name, "endpoints", len(eps.Endpoints))
+	}
+	if len(loaded) > 0 {
+		instance.Status.Phase = modelv1alpha1.ModelAdapterRunning
 	}
-
 	return ctrl.Result{}, nil
 }
 
@@ -930,6 +870,12 @@ func (r *ModelAdapterReconciler) inconsistentModelAdapterStatus(oldStatus, newSt
 	if oldStatus.Phase != newStatus.Phase || !equalStringSlices(oldStatus.Instances, newStatus.Instances) {
 		return true
 	}
+	if oldStatus.ArtifactURL != newStatus.ArtifactURL || !equality.Semantic.DeepEqual(oldStatus.Rollout, newStatus.Rollout) {
+		return true
+	}
+	if !equality.Semantic.DeepEqual(oldStatus.InstanceStatuses, newStatus.InstanceStatuses) {
+		return true
+	}
 
 	return false
 }
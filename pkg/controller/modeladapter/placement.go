@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modeladapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/modeladapter/scheduling"
+	"github.com/vllm-project/aibrix/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Reasons of the evictions of pods from the instances of a model adapter:
+	// PodDeletedReason is recorded for a pod which was deleted.
+	PodDeletedReason = "PodDeleted"
+	// PodNotReadyReason is recorded for a pod which is not ready or is terminating.
+	PodNotReadyReason = "PodNotReady"
+	// SelectorMismatchReason is recorded for a pod which no longer matches the pod selector of the model adapter.
+	SelectorMismatchReason = "SelectorMismatch"
+	// ScaledDownReason is recorded for a pod removed when the replicas of the model adapter decreased.
+	ScaledDownReason = "ScaledDown"
+
+	// EvictedReason is emitted for a pod removed from the instances.
+	EvictedReason = "Evicted"
+	// InsufficientActivePodsReason is added in a model adapter when fewer ready pods than its replicas match its pod
+	// selector.
+	InsufficientActivePodsReason = "InsufficientActivePods"
+
+	// maxEvictions is how many evictions are kept in the status of a model adapter.
+	maxEvictions = 10
+)
+
+// placementSchedulerPolicies are the scheduler policies choosing the pods of the placement strategies.
+var placementSchedulerPolicies = map[modelv1alpha1.ModelAdapterPlacementStrategy]string{
+	modelv1alpha1.ModelAdapterPlacementSpread:  "leastAdapters",
+	modelv1alpha1.ModelAdapterPlacementBinPack: "binPack",
+}
+
+// placementStrategy returns the placement strategy of the model adapter, Spread by default.
+func placementStrategy(instance *modelv1alpha1.ModelAdapter) modelv1alpha1.ModelAdapterPlacementStrategy {
+	if instance.Spec.Placement == nil || instance.Spec.Placement.Strategy == "" {
+		return modelv1alpha1.ModelAdapterPlacementSpread
+	}
+	return instance.Spec.Placement.Strategy
+}
+
+// desiredReplicas returns the number of pods the model adapter is loaded on, 1 by default.
+func desiredReplicas(instance *modelv1alpha1.ModelAdapter) int {
+	if instance.Spec.Replicas == nil {
+		return 1
+	}
+	return int(*instance.Spec.Replicas)
+}
+
+// reconcilePlacement keeps the model adapter on its desired number of pods, and returns whether the instances
+// changed. The pods of the instances keep the model adapter as long as they are ready and match the pod selector,
+// so that they don't load it again. The other pods, and the extra pods when the replicas decreased, are evicted
+// and replaced by pods chosen by the placement strategy.
+func (r *ModelAdapterReconciler) reconcilePlacement(ctx context.Context, instance *modelv1alpha1.ModelAdapter) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(instance.Spec.PodSelector)
+	if err != nil {
+		// TODO: this should barely happen, let's move this logic to earlier validation logics.
+		return false, fmt.Errorf("failed to convert pod selector: %v", err)
+	}
+
+	changed := false
+	var kept []*corev1.Pod
+	for _, podName := range append([]string(nil), instance.Status.Instances...) {
+		pod := &corev1.Pod{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: podName}, pod); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return changed, err
+			}
+			r.evictInstance(instance, podName, PodDeletedReason, "The pod was deleted")
+			changed = true
+			continue
+		}
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			// the pod keeps serving its base model, unload the model adapter from it.
+			r.unloadFromPod(instance, pod)
+			r.evictInstance(instance, podName, SelectorMismatchReason, "The pod no longer matches the pod selector")
+			changed = true
+			continue
+		}
+		// base model pod could be unhealthy or in termination, it is replaced.
+		if !utils.IsPodReady(pod) || utils.IsPodTerminating(pod) {
+			r.evictInstance(instance, podName, PodNotReadyReason, "The pod is not ready or terminating")
+			changed = true
+			continue
+		}
+		kept = append(kept, pod)
+	}
+
+	desired := desiredReplicas(instance)
+	for len(kept) > desired {
+		// the pods chosen last are removed first.
+		pod := kept[len(kept)-1]
+		kept = kept[:len(kept)-1]
+		r.unloadFromPod(instance, pod)
+		r.evictInstance(instance, pod.Name, ScaledDownReason, fmt.Sprintf("The replicas decreased to %d", desired))
+		changed = true
+	}
+	if len(kept) == desired {
+		return changed, nil
+	}
+
+	activePods, err := r.getActivePodsForModelAdapter(ctx, instance)
+	if err != nil {
+		return changed, err
+	}
+	var candidates []corev1.Pod
+	for _, pod := range activePods {
+		if !StringInSlice(instance.Status.Instances, pod.Name) {
+			candidates = append(candidates, pod)
+		}
+	}
+	strategy := placementStrategy(instance)
+	selected, err := scheduling.SelectPods(ctx, r.schedulerFor(strategy), instance.Name, candidates, desired-len(kept))
+	if err != nil {
+		return changed, fmt.Errorf("failed to schedule pods: %w", err)
+	}
+	for _, pod := range selected {
+		klog.InfoS("Placing ModelAdapter on pod", "modelAdapter", klog.KObj(instance), "pod", klog.KObj(pod), "strategy", strategy)
+		instance.Status.Instances = append(instance.Status.Instances, pod.Name)
+		setInstancePhase(instance, pod, modelv1alpha1.ModelAdapterInstanceLoading, fmt.Sprintf("Selected by the %s placement", strategy))
+		changed = true
+	}
+	return changed, nil
+}
+
+// schedulerFor returns the scheduler choosing the pods of the placement strategy.
+func (r *ModelAdapterReconciler) schedulerFor(strategy modelv1alpha1.ModelAdapterPlacementStrategy) scheduling.Scheduler {
+	if scheduler, ok := r.schedulers[strategy]; ok {
+		return scheduler
+	}
+	return r.schedulers[modelv1alpha1.ModelAdapterPlacementSpread]
+}
+
+// newPlacementCondition returns the Scheduled condition of a model adapter placed on some pods.
+func newPlacementCondition(instance *modelv1alpha1.ModelAdapter) metav1.Condition {
+	placed, desired := len(instance.Status.Instances), desiredReplicas(instance)
+	message := fmt.Sprintf("ModelAdapter %s is placed on %d/%d pods with the %s strategy: %s", klog.KObj(instance),
+		placed, desired, placementStrategy(instance), strings.Join(instance.Status.Instances, ", "))
+	if placed < desired {
+		return NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeScheduled), metav1.ConditionFalse,
+			InsufficientActivePodsReason, message)
+	}
+	return NewCondition(string(modelv1alpha1.ModelAdapterConditionTypeScheduled), metav1.ConditionTrue, "Scheduled", message)
+}
+
+// evictInstance removes the pod from the instances of the model adapter and records why.
+func (r *ModelAdapterReconciler) evictInstance(instance *modelv1alpha1.ModelAdapter, podName, reason, message string) {
+	klog.InfoS("Evicting pod from ModelAdapter instances", "modelAdapter", klog.KObj(instance), "pod", podName, "reason", reason)
+	instance.Status.Instances = RemoveInstanceFromList(instance.Status.Instances, podName)
+	evictions := append(instance.Status.Evictions, modelv1alpha1.ModelAdapterEviction{
+		PodName: podName,
+		Reason:  reason,
+		Message: message,
+		Time:    metav1.Now(),
+	})
+	if len(evictions) > maxEvictions {
+		evictions = evictions[len(evictions)-maxEvictions:]
+	}
+	instance.Status.Evictions = evictions
+	r.Recorder.Eventf(instance, corev1.EventTypeNormal, EvictedReason, "Removed pod %s from the instances: %s", podName, message)
+}
+
+// unloadFromPod unloads the model adapter, and the new artifact of an ongoing rollout, from a pod which keeps
+// serving its base model. The unloading is best effort.
+func (r *ModelAdapterReconciler) unloadFromPod(instance *modelv1alpha1.ModelAdapter, pod *corev1.Pod) {
+	if pod.Status.PodIP == "" {
+		return
+	}
+	url := BuildURLs(pod.Status.PodIP, r.RuntimeConfig).UnloadAdapterURL
+	_ = r.unloadAdapterVersion(url, instance, instance.Name)
+	if instance.Status.Rollout != nil {
+		_ = r.unloadAdapterVersion(url, instance, instance.Status.Rollout.Version)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modeladapter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/modeladapter/scheduling"
+)
+
+// fakeAdapterCountScheduler selects the pod with the fewest adapters, or the most with binPack, by the counts of
+// the pods.
+type fakeAdapterCountScheduler struct {
+	counts  map[string]int
+	binPack bool
+}
+
+func (s fakeAdapterCountScheduler) SelectPod(ctx context.Context, model string, pods []corev1.Pod) (*corev1.Pod, error) {
+	selected := pods[0]
+	for _, pod := range pods[1:] {
+		better := s.counts[pod.Name] < s.counts[selected.Name]
+		if s.binPack {
+			better = s.counts[pod.Name] > s.counts[selected.Name]
+		}
+		if better {
+			selected = pod
+		}
+	}
+	return &selected, nil
+}
+
+func newPlacementPod(name string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{ModelIdentifierKey: "llama"}},
+		Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+	}
+}
+
+func newPlacementTest(t *testing.T, pods ...*corev1.Pod) (*ModelAdapterReconciler, client.Client) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+	assert.NoError(t, modelv1alpha1.AddToScheme(scheme))
+	objects := make([]client.Object, 0, len(pods))
+	for _, pod := range pods {
+		objects = append(objects, pod)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	counts := map[string]int{"pod-1": 3, "pod-2": 0, "pod-3": 1, "pod-4": 2}
+	r := &ModelAdapterReconciler{
+		Client:   c,
+		Recorder: record.NewFakeRecorder(100),
+		schedulers: map[modelv1alpha1.ModelAdapterPlacementStrategy]scheduling.Scheduler{
+			modelv1alpha1.ModelAdapterPlacementSpread:  fakeAdapterCountScheduler{counts: counts},
+			modelv1alpha1.ModelAdapterPlacementBinPack: fakeAdapterCountScheduler{counts: counts, binPack: true},
+		},
+	}
+	return r, c
+}
+
+func newPlacementAdapter(replicas int32, strategy modelv1alpha1.ModelAdapterPlacementStrategy) *modelv1alpha1.ModelAdapter {
+	return &modelv1alpha1.ModelAdapter{
+		ObjectMeta: metav1.ObjectMeta{Name: "lora-1", Namespace: "default"},
+		Spec: modelv1alpha1.ModelAdapterSpec{
+			PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{ModelIdentifierKey: "llama"}},
+			Replicas:    ptr.To(replicas),
+			Placement:   &modelv1alpha1.ModelAdapterPlacement{Strategy: strategy},
+		},
+	}
+}
+
+func TestReconcilePlacementStrategies(t *testing.T) {
+	tests := []struct {
+		strategy modelv1alpha1.ModelAdapterPlacementStrategy
+		expected []string
+	}{
+		{strategy: modelv1alpha1.ModelAdapterPlacementSpread, expected: []string{"pod-2", "pod-3"}},
+		{strategy: modelv1alpha1.ModelAdapterPlacementBinPack, expected: []string{"pod-1", "pod-4"}},
+		{strategy: "", expected: []string{"pod-2", "pod-3"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			r, _ := newPlacementTest(t, newPlacementPod("pod-1", true), newPlacementPod("pod-2", true),
+				newPlacementPod("pod-3", true), newPlacementPod("pod-4", true))
+			instance := newPlacementAdapter(2, tt.strategy)
+
+			changed, err := r.reconcilePlacement(context.TODO(), instance)
+			assert.NoError(t, err)
+			assert.True(t, changed)
+			assert.Equal(t, tt.expected, instance.Status.Instances)
+			for _, status := range instance.Status.InstanceStatuses {
+				assert.Equal(t, modelv1alpha1.ModelAdapterInstanceLoading, status.Phase)
+			}
+			assert.Equal(t, metav1.ConditionTrue, newPlacementCondition(instance).Status)
+
+			// the placement is stable once the replicas are placed.
+			changed, err = r.reconcilePlacement(context.TODO(), instance)
+			assert.NoError(t, err)
+			assert.False(t, changed)
+		})
+	}
+}
+
+func TestReconcilePlacementReplacesInvalidPods(t *testing.T) {
+	notReady := newPlacementPod("pod-3", false)
+	relabeled := newPlacementPod("pod-4", true)
+	relabeled.Labels = map[string]string{ModelIdentifierKey: "mistral"}
+	r, _ := newPlacementTest(t, newPlacementPod("pod-1", true), newPlacementPod("pod-2", true), notReady, relabeled)
+	instance := newPlacementAdapter(3, modelv1alpha1.ModelAdapterPlacementSpread)
+	// pod-5 was deleted.
+	instance.Status.Instances = []string{"pod-5", "pod-3", "pod-4", "pod-1"}
+	setInstancePhase(instance, newPlacementPod("pod-1", true), modelv1alpha1.ModelAdapterInstanceLoaded, "")
+
+	changed, err := r.reconcilePlacement(context.TODO(), instance)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	// pod-1 keeps the loaded model adapter, pod-2 is the only other ready pod.
+	assert.Equal(t, []string{"pod-1", "pod-2"}, instance.Status.Instances)
+	assert.Equal(t, modelv1alpha1.ModelAdapterInstanceLoaded, instance.Status.InstanceStatuses[0].Phase)
+
+	var reasons []string
+	for _, eviction := range instance.Status.Evictions {
+		reasons = append(reasons, eviction.PodName+"/"+eviction.Reason)
+	}
+	assert.Equal(t, []string{"pod-5/PodDeleted", "pod-3/PodNotReady", "pod-4/SelectorMismatch"}, reasons)
+
+	condition := newPlacementCondition(instance)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, InsufficientActivePodsReason, condition.Reason)
+}
+
+func TestReconcilePlacementScaleDown(t *testing.T) {
+	r, _ := newPlacementTest(t, newPlacementPod("pod-1", true), newPlacementPod("pod-2", true), newPlacementPod("pod-3", true))
+	instance := newPlacementAdapter(1, modelv1alpha1.ModelAdapterPlacementSpread)
+	instance.Status.Instances = []string{"pod-1", "pod-2", "pod-3"}
+
+	changed, err := r.reconcilePlacement(context.TODO(), instance)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"pod-1"}, instance.Status.Instances)
+	assert.Len(t, instance.Status.Evictions, 2)
+	assert.Equal(t, "pod-3", instance.Status.Evictions[0].PodName)
+	assert.Equal(t, ScaledDownReason, instance.Status.Evictions[0].Reason)
+}
+
+func TestEvictInstanceKeepsLatestEvictions(t *testing.T) {
+	r := &ModelAdapterReconciler{Recorder: record.NewFakeRecorder(100)}
+	instance := newPlacementAdapter(1, modelv1alpha1.ModelAdapterPlacementSpread)
+	for i := 0; i < maxEvictions+2; i++ {
+		podName := fmt.Sprintf("pod-%d", i)
+		instance.Status.Instances = append(instance.Status.Instances, podName)
+		r.evictInstance(instance, podName, PodDeletedReason, "The pod was deleted")
+	}
+	assert.Empty(t, instance.Status.Instances)
+	assert.Len(t, instance.Status.Evictions, maxEvictions)
+	assert.Equal(t, "pod-2", instance.Status.Evictions[0].PodName)
+}
+
+func TestLoadedInstances(t *testing.T) {
+	instance := &modelv1alpha1.ModelAdapter{Status: modelv1alpha1.ModelAdapterStatus{
+		Instances: []string{"pod-2", "pod-1", "pod-3"},
+		InstanceStatuses: []modelv1alpha1.ModelAdapterInstanceStatus{
+			{PodName: "pod-1", PodIP: "10.0.0.1", Phase: modelv1alpha1.ModelAdapterInstanceLoaded},
+			{PodName: "pod-2", PodIP: "10.0.0.2", Phase: modelv1alpha1.ModelAdapterInstanceLoaded},
+			{PodName: "pod-3", PodIP: "10.0.0.3", Phase: modelv1alpha1.ModelAdapterInstanceLoading},
+		},
+	}}
+	var podIPs []string
+	for _, status := range loadedInstances(instance) {
+		podIPs = append(podIPs, status.PodIP)
+	}
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.1"}, podIPs)
+}
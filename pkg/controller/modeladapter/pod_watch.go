@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modeladapter
+
+import (
+	"context"
+	"maps"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// podWithLabelFilter passes the events of the base model pods enabling the model adapters. The updates are only
+// passed when they may change the placement of the model adapters, see podPlacementChanged, so that the status
+// updates of the pods during a rollout of the base model don't reconcile the model adapters over and over.
+func podWithLabelFilter(labelKey, labelValue, modelIdKey string) predicate.Predicate {
+	hasLabelAndModelIdentifier := func(labels map[string]string, labelKey, labelValue, modelIdentifierKey string) bool {
+		if _, exists := labels[modelIdentifierKey]; !exists {
+			return false
+		}
+		return labels[labelKey] == labelValue
+	}
+
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return hasLabelAndModelIdentifier(e.Object.GetLabels(), labelKey, labelValue, modelIdKey)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			// a pod losing the labels is passed as well, the model adapters placed on it are moved.
+			if !hasLabelAndModelIdentifier(e.ObjectOld.GetLabels(), labelKey, labelValue, modelIdKey) &&
+				!hasLabelAndModelIdentifier(e.ObjectNew.GetLabels(), labelKey, labelValue, modelIdKey) {
+				return false
+			}
+			oldPod, oldOk := e.ObjectOld.(*corev1.Pod)
+			newPod, newOk := e.ObjectNew.(*corev1.Pod)
+			if !oldOk || !newOk {
+				return true
+			}
+			return podPlacementChanged(oldPod, newPod)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return hasLabelAndModelIdentifier(e.Object.GetLabels(), labelKey, labelValue, modelIdKey)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return hasLabelAndModelIdentifier(e.Object.GetLabels(), labelKey, labelValue, modelIdKey)
+		},
+	}
+}
+
+// podPlacementChanged returns whether the update of the pod may change where the model adapters are placed: its
+// labels, its readiness, its IP or its termination changed. The other updates, e.g. the restarts of its containers
+// or its other conditions, are ignored.
+func podPlacementChanged(oldPod, newPod *corev1.Pod) bool {
+	return !maps.Equal(oldPod.Labels, newPod.Labels) ||
+		utils.IsPodReady(oldPod) != utils.IsPodReady(newPod) ||
+		oldPod.Status.PodIP != newPod.Status.PodIP ||
+		utils.IsPodTerminating(oldPod) != utils.IsPodTerminating(newPod)
+}
+
+// lookupModelAdaptersForPod enqueues the model adapters a pod is relevant to: the model adapters whose pod selector
+// and base model match the pod, which may be placed on it, e.g. the pending ones waiting for the base model to scale
+// up, and the model adapters placed on it, which replace it once it is gone or no longer matches.
+func lookupModelAdaptersForPod(c client.Client) handler.MapFunc {
+	return func(ctx context.Context, a client.Object) []reconcile.Request {
+		modelAdapterList := &modelv1alpha1.ModelAdapterList{}
+		if err := c.List(ctx, modelAdapterList, client.InNamespace(a.GetNamespace())); err != nil {
+			klog.ErrorS(err, "unable to list model adapters in namespace", "namespace", a.GetNamespace())
+			return []reconcile.Request{}
+		}
+
+		var requests []reconcile.Request
+		for i := range modelAdapterList.Items {
+			modelAdapter := &modelAdapterList.Items[i]
+			if !StringInSlice(modelAdapter.Status.Instances, a.GetName()) && !modelAdapterMatchesPod(modelAdapter, a) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: a.GetNamespace(), Name: modelAdapter.GetName()}})
+		}
+		return requests
+	}
+}
+
+// modelAdapterMatchesPod returns whether the model adapter may be placed on the pod: the pod matches its pod
+// selector and serves its base model, if it names one.
+func modelAdapterMatchesPod(modelAdapter *modelv1alpha1.ModelAdapter, pod client.Object) bool {
+	if modelAdapter.Spec.BaseModel != nil && pod.GetLabels()[ModelIdentifierKey] != *modelAdapter.Spec.BaseModel {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(modelAdapter.Spec.PodSelector)
+	if err != nil {
+		// the reconcile of the model adapter reports its invalid selector.
+		return true
+	}
+	return selector.Matches(labels.Set(pod.GetLabels()))
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modeladapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+func newWatchedPod(name, model string) *corev1.Pod {
+	pod := newPlacementPod(name, true)
+	pod.Labels = map[string]string{ModelIdentifierKey: model, ModelAdapterPodTemplateLabelKey: ModelAdapterPodTemplateLabelValue}
+	pod.Status.PodIP = "10.0.0.1"
+	return pod
+}
+
+func TestPodWithLabelFilterUpdates(t *testing.T) {
+	filter := podWithLabelFilter(ModelAdapterPodTemplateLabelKey, ModelAdapterPodTemplateLabelValue, ModelIdentifierKey)
+	tests := []struct {
+		name     string
+		update   func(pod *corev1.Pod)
+		expected bool
+	}{
+		{name: "container restarted", update: func(pod *corev1.Pod) {
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "vllm", RestartCount: 1}}
+		}, expected: false},
+		{name: "other condition", update: func(pod *corev1.Pod) {
+			pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue})
+		}, expected: false},
+		{name: "not ready", update: func(pod *corev1.Pod) {
+			pod.Status.Conditions[0].Status = corev1.ConditionFalse
+		}, expected: true},
+		{name: "ip changed", update: func(pod *corev1.Pod) {
+			pod.Status.PodIP = "10.0.0.2"
+		}, expected: true},
+		{name: "terminating", update: func(pod *corev1.Pod) {
+			pod.DeletionTimestamp = ptr.To(metav1.Now())
+		}, expected: true},
+		{name: "labels changed", update: func(pod *corev1.Pod) {
+			pod.Labels["app"] = "llama"
+		}, expected: true},
+		{name: "adapters disabled", update: func(pod *corev1.Pod) {
+			delete(pod.Labels, ModelAdapterPodTemplateLabelKey)
+		}, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldPod := newWatchedPod("pod-1", "llama")
+			newPod := oldPod.DeepCopy()
+			tt.update(newPod)
+			assert.Equal(t, tt.expected, filter.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: newPod}))
+		})
+	}
+
+	// the pods not enabling the model adapters are ignored.
+	oldPod := newPlacementPod("pod-1", false)
+	newPod := newPlacementPod("pod-1", true)
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: newPod}))
+	assert.False(t, filter.Create(event.CreateEvent{Object: newPod}))
+	assert.True(t, filter.Create(event.CreateEvent{Object: newWatchedPod("pod-2", "llama")}))
+}
+
+func TestLookupModelAdaptersForPod(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, modelv1alpha1.AddToScheme(scheme))
+
+	matching := newPlacementAdapter(1, "")
+	matching.Name = "lora-matching"
+	otherModel := newPlacementAdapter(1, "")
+	otherModel.Name = "lora-other-model"
+	otherModel.Spec.PodSelector = &metav1.LabelSelector{MatchLabels: map[string]string{ModelIdentifierKey: "mistral"}}
+	otherBaseModel := newPlacementAdapter(1, "")
+	otherBaseModel.Name = "lora-other-base-model"
+	otherBaseModel.Spec.BaseModel = ptr.To("mistral")
+	placed := otherModel.DeepCopy()
+	placed.Name = "lora-placed"
+	placed.Status.Instances = []string{"pod-1"}
+	otherNamespace := newPlacementAdapter(1, "")
+	otherNamespace.Name = "lora-other-namespace"
+	otherNamespace.Namespace = "other"
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(matching, otherModel, otherBaseModel, placed, otherNamespace).Build()
+	requests := lookupModelAdaptersForPod(c)(context.TODO(), newWatchedPod("pod-1", "llama"))
+
+	var names []string
+	for _, request := range requests {
+		assert.Equal(t, "default", request.Namespace)
+		names = append(names, request.Name)
+	}
+	assert.ElementsMatch(t, []string{"lora-matching", "lora-placed"}, names)
+
+	// a pod of no model adapter enqueues nothing.
+	assert.Empty(t, lookupModelAdaptersForPod(c)(context.TODO(), newWatchedPod("pod-2", "qwen")))
+}
@@ -20,21 +20,28 @@ import (
 	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/ptr"
 )
 
-func buildModelAdapterEndpointSlice(instance *modelv1alpha1.ModelAdapter, pod *corev1.Pod) *discoveryv1.EndpointSlice {
+const (
+	// endpointSliceManagedBy marks the endpoint slices of the model adapters, so that the endpoint slice controller of
+	// kubernetes leaves them to the model adapter controller.
+	endpointSliceManagedBy = "modeladapter.aibrix.ai"
+
+	// adapterNameLabelKey labels the service with the name the model adapter is served by.
+	adapterNameLabelKey = "adapter.model.aibrix.ai/name"
+)
+
+func buildModelAdapterEndpointSlice(instance *modelv1alpha1.ModelAdapter, loaded ...modelv1alpha1.ModelAdapterInstanceStatus) *discoveryv1.EndpointSlice {
 	serviceLabels := map[string]string{
-		"kubernetes.io/service-name": instance.Name,
+		discoveryv1.LabelServiceName: instance.Name,
+		discoveryv1.LabelManagedBy:   endpointSliceManagedBy,
 	}
 
-	addresses := []discoveryv1.Endpoint{
-		{
-			Addresses: []string{pod.Status.PodIP},
-		},
-	}
+	addresses := buildModelAdapterEndpoints(instance, loaded)
 
 	ports := []discoveryv1.EndpointPort{
 		{
@@ -60,12 +67,29 @@ func buildModelAdapterEndpointSlice(instance *modelv1alpha1.ModelAdapter, pod *c
 	}
 }
 
+// buildModelAdapterEndpoints returns an endpoint per pod which loaded the model adapter, referencing the pod.
+func buildModelAdapterEndpoints(instance *modelv1alpha1.ModelAdapter, loaded []modelv1alpha1.ModelAdapterInstanceStatus) []discoveryv1.Endpoint {
+	endpoints := make([]discoveryv1.Endpoint, 0, len(loaded))
+	for _, status := range loaded {
+		endpoints = append(endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{status.PodIP},
+			Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(true)},
+			TargetRef: &corev1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: instance.Namespace,
+				Name:      status.PodName,
+			},
+		})
+	}
+	return endpoints
+}
+
 func buildModelAdapterService(instance *modelv1alpha1.ModelAdapter) *corev1.Service {
 	labels := map[string]string{
-		"adapter.model.aibrix.ai/name": instance.Name,
+		adapterNameLabelKey: instance.Name,
 	}
 	if instance.Spec.BaseModel != nil {
-		labels["model.aibrix.ai/name"] = *instance.Spec.BaseModel
+		labels[ModelIdentifierKey] = *instance.Spec.BaseModel
 	}
 
 	ports := []corev1.ServicePort{
@@ -99,3 +123,14 @@ func buildModelAdapterService(instance *modelv1alpha1.ModelAdapter) *corev1.Serv
 		},
 	}
 }
+
+// modelAdapterServiceOutdated returns whether the service no longer serves the model adapter as built, e.g. when it
+// was left over by a previous model adapter of the same name or the names it is labeled with changed. The cluster IP
+// of a service is immutable, so the outdated service is recreated rather than updated.
+func modelAdapterServiceOutdated(instance *modelv1alpha1.ModelAdapter, found, desired *corev1.Service) bool {
+	return !metav1.IsControlledBy(found, instance) ||
+		found.Spec.ClusterIP != desired.Spec.ClusterIP ||
+		found.Labels[adapterNameLabelKey] != desired.Labels[adapterNameLabelKey] ||
+		found.Labels[ModelIdentifierKey] != desired.Labels[ModelIdentifierKey] ||
+		!equality.Semantic.DeepEqual(found.Spec.Ports, desired.Spec.Ports)
+}
@@ -36,26 +36,29 @@ func TestBuildModelAdapterEndpointSlice(t *testing.T) {
 		},
 	}
 
-	// Mock input for Pod
-	pod := &corev1.Pod{
-		Status: corev1.PodStatus{
-			PodIP: "192.168.1.1",
-		},
+	// Mock input for the loaded instance
+	loaded := modelv1alpha1.ModelAdapterInstanceStatus{
+		PodName: "pod-1",
+		PodIP:   "192.168.1.1",
+		Phase:   modelv1alpha1.ModelAdapterInstanceLoaded,
 	}
 
 	// Call the function to test
-	endpointSlice := buildModelAdapterEndpointSlice(instance, pod)
+	endpointSlice := buildModelAdapterEndpointSlice(instance, loaded)
 
 	// Check EndpointSlice metadata
 	assert.Equal(t, "test-instance", endpointSlice.Name)
 	assert.Equal(t, "default", endpointSlice.Namespace)
 	assert.Equal(t, map[string]string{
-		"kubernetes.io/service-name": "test-instance",
+		"kubernetes.io/service-name":             "test-instance",
+		"endpointslice.kubernetes.io/managed-by": "modeladapter.aibrix.ai",
 	}, endpointSlice.Labels)
 
 	// Check addresses
 	assert.Len(t, endpointSlice.Endpoints, 1)
 	assert.Equal(t, "192.168.1.1", endpointSlice.Endpoints[0].Addresses[0])
+	assert.True(t, *endpointSlice.Endpoints[0].Conditions.Ready)
+	assert.Equal(t, &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "pod-1"}, endpointSlice.Endpoints[0].TargetRef)
 
 	// Check ports
 	assert.Len(t, endpointSlice.Ports, 1)
@@ -101,3 +104,38 @@ func TestBuildModelAdapterService(t *testing.T) {
 	assert.Len(t, service.OwnerReferences, 1)
 	assert.Equal(t, instance.Name, service.OwnerReferences[0].Name)
 }
+
+func TestModelAdapterServiceOutdated(t *testing.T) {
+	instance := &modelv1alpha1.ModelAdapter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-instance",
+			Namespace: "default",
+			UID:       "uid-1",
+		},
+		Spec: modelv1alpha1.ModelAdapterSpec{
+			BaseModel: ptr.To[string]("test-model"),
+		},
+	}
+	desired := buildModelAdapterService(instance)
+
+	tests := []struct {
+		name     string
+		mutate   func(svc *corev1.Service)
+		expected bool
+	}{
+		{name: "up to date", mutate: func(svc *corev1.Service) {}, expected: false},
+		{name: "extra labels", mutate: func(svc *corev1.Service) { svc.Labels["team"] = "a" }, expected: false},
+		{name: "previous model adapter", mutate: func(svc *corev1.Service) { svc.OwnerReferences[0].UID = "uid-0" }, expected: true},
+		{name: "renamed base model", mutate: func(svc *corev1.Service) { svc.Labels[ModelIdentifierKey] = "other-model" }, expected: true},
+		{name: "renamed adapter", mutate: func(svc *corev1.Service) { svc.Labels[adapterNameLabelKey] = "other-adapter" }, expected: true},
+		{name: "not headless", mutate: func(svc *corev1.Service) { svc.Spec.ClusterIP = "10.0.0.1" }, expected: true},
+		{name: "different port", mutate: func(svc *corev1.Service) { svc.Spec.Ports[0].Port = 8080 }, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := buildModelAdapterService(instance)
+			tt.mutate(found)
+			assert.Equal(t, tt.expected, modelAdapterServiceOutdated(instance, found, desired))
+		})
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modeladapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ModelAdapterMaxAdaptersAnnotationKey is the number of adapters the engine of a pod can hold at once, e.g. the
+// --max-cpu-loras of vLLM. A rollout keeps two artifacts of a model adapter loaded only if the pod has room for both.
+const ModelAdapterMaxAdaptersAnnotationKey = "adapter.model.aibrix.ai/max-adapters"
+
+var (
+	defaultRolloutStepPercent  int32 = 20
+	defaultRolloutStepInterval       = 1 * time.Minute
+)
+
+// reconcileRollout shifts the traffic of the model adapter to a new artifact. The new artifact is loaded under a
+// versioned name next to the previous one on every pod of the instances and the gateway splits the traffic between
+// both by the rollout percentage. Once all traffic is on the new artifact, it replaces the previous one under the
+// name of the model adapter and the versioned name is unloaded.
+func (r *ModelAdapterReconciler) reconcileRollout(ctx context.Context, instance *modelv1alpha1.ModelAdapter) (ctrl.Result, error) {
+	if len(instance.Status.Instances) == 0 {
+		return ctrl.Result{}, nil
+	}
+	if instance.Status.ArtifactURL == "" {
+		// reconcileLoading loaded the artifact of the spec.
+		instance.Status.ArtifactURL = instance.Spec.ArtifactURL
+	}
+	rollout := instance.Status.Rollout
+	if instance.Status.ArtifactURL == instance.Spec.ArtifactURL && rollout == nil {
+		return ctrl.Result{}, nil
+	}
+
+	var targetPods []*corev1.Pod
+	for _, podName := range instance.Status.Instances {
+		targetPod := &corev1.Pod{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: podName}, targetPod); err != nil {
+			return ctrl.Result{}, err
+		}
+		if targetPod.DeletionTimestamp == nil {
+			targetPods = append(targetPods, targetPod)
+		}
+	}
+	if len(targetPods) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if instance.Status.ArtifactURL == instance.Spec.ArtifactURL {
+		// the rollout completed or the artifact was reverted, the versioned name serves no traffic anymore.
+		klog.InfoS("Unloading the versioned model adapter", "modelAdapter", klog.KObj(instance), "version", rollout.Version)
+		for _, targetPod := range targetPods {
+			if err := r.unloadAdapterVersion(BuildURLs(targetPod.Status.PodIP, r.RuntimeConfig).UnloadAdapterURL, instance, rollout.Version); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		instance.Status.Rollout = nil
+		return ctrl.Result{}, nil
+	}
+
+	version := versionedAdapterName(instance.Name, instance.Spec.ArtifactURL)
+	if rollout != nil && rollout.Version != version {
+		// the artifact changed again during the rollout, the latest one replaces the one being rolled out.
+		for _, targetPod := range targetPods {
+			if err := r.unloadAdapterVersion(BuildURLs(targetPod.Status.PodIP, r.RuntimeConfig).UnloadAdapterURL, instance, rollout.Version); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		instance.Status.Rollout = nil
+		rollout = nil
+	}
+
+	if rollout != nil && rollout.Percent >= 100 {
+		// the previous artifact serves no traffic, replace it under the name of the model adapter.
+		if err := r.replaceLoadedArtifact(targetPods, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: defaultRequeueDuration}, nil
+	}
+
+	// the pods missing the new artifact, all pods must have room for both artifacts to shift the traffic.
+	var missingPods []*corev1.Pod
+	for _, targetPod := range targetPods {
+		models, err := r.listModels(BuildURLs(targetPod.Status.PodIP, r.RuntimeConfig).ListModelsURL, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if containsModel(models, version) {
+			continue
+		}
+		if rollout == nil && !hasRoomForAdapter(targetPod, countLoadedAdapters(models)) {
+			klog.InfoS("No room to load both artifacts of the model adapter, replacing the loaded artifact",
+				"modelAdapter", klog.KObj(instance), "pod", klog.KObj(targetPod))
+			return ctrl.Result{}, r.replaceLoadedArtifact(targetPods, instance)
+		}
+		missingPods = append(missingPods, targetPod)
+	}
+	for _, targetPod := range missingPods {
+		if err := r.loadModelAdapter(BuildURLs(targetPod.Status.PodIP, r.RuntimeConfig).LoadAdapterURL, instance, version, instance.Spec.ArtifactURL); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	percent, requeueAfter := nextRolloutPercent(instance, time.Now())
+	if rollout == nil || rollout.Percent != percent {
+		klog.InfoS("Shifting traffic to the new model adapter artifact", "modelAdapter", klog.KObj(instance), "version", version, "percent", percent)
+		instance.Status.Rollout = &modelv1alpha1.ModelAdapterRollout{Version: version, Percent: percent, LastStepTime: metav1.Now()}
+	}
+	if percent >= 100 {
+		// give the gateway time to move the traffic off the previous artifact before it is replaced.
+		requeueAfter = defaultRequeueDuration
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// replaceLoadedArtifact loads the artifact of the spec under the name of the model adapter in place of the
+// previous artifact, on every pod.
+func (r *ModelAdapterReconciler) replaceLoadedArtifact(targetPods []*corev1.Pod, instance *modelv1alpha1.ModelAdapter) error {
+	for _, targetPod := range targetPods {
+		urls := BuildURLs(targetPod.Status.PodIP, r.RuntimeConfig)
+		if err := r.unloadAdapterVersion(urls.UnloadAdapterURL, instance, instance.Name); err != nil {
+			return err
+		}
+		// if loading fails, reconcileLoading loads the previous artifact again in the next loop.
+		if err := r.loadModelAdapter(urls.LoadAdapterURL, instance, instance.Name, instance.Spec.ArtifactURL); err != nil {
+			return err
+		}
+	}
+	instance.Status.ArtifactURL = instance.Spec.ArtifactURL
+	return nil
+}
+
+// versionedAdapterName returns the name an artifact of the model adapter is loaded under while traffic shifts to it.
+func versionedAdapterName(name, artifactURL string) string {
+	sum := sha256.Sum256([]byte(artifactURL))
+	return fmt.Sprintf("%s-%s", name, hex.EncodeToString(sum[:4]))
+}
+
+// loadedArtifactURL returns the artifact served under the name of the model adapter.
+func loadedArtifactURL(instance *modelv1alpha1.ModelAdapter) string {
+	if instance.Status.ArtifactURL != "" {
+		return instance.Status.ArtifactURL
+	}
+	return instance.Spec.ArtifactURL
+}
+
+// nextRolloutPercent returns the percentage of traffic the new artifact serves and how long until it changes.
+// A percentage set in the spec is followed as is, otherwise it grows by a step every interval.
+func nextRolloutPercent(instance *modelv1alpha1.ModelAdapter, now time.Time) (int32, time.Duration) {
+	if instance.Spec.RolloutPercent != nil {
+		return *instance.Spec.RolloutPercent, 0
+	}
+	rollout := instance.Status.Rollout
+	if rollout == nil {
+		return defaultRolloutStepPercent, defaultRolloutStepInterval
+	}
+	if elapsed := now.Sub(rollout.LastStepTime.Time); elapsed < defaultRolloutStepInterval {
+		return rollout.Percent, defaultRolloutStepInterval - elapsed
+	}
+	percent := rollout.Percent + defaultRolloutStepPercent
+	if percent >= 100 {
+		return 100, 0
+	}
+	return percent, defaultRolloutStepInterval
+}
+
+// hasRoomForAdapter returns whether the engine of the pod can load another adapter. Pods without the
+// max-adapters annotation are not limited.
+func hasRoomForAdapter(pod *corev1.Pod, loadedAdapters int) bool {
+	value, ok := pod.Annotations[ModelAdapterMaxAdaptersAnnotationKey]
+	if !ok {
+		return true
+	}
+	maxAdapters, err := strconv.Atoi(value)
+	if err != nil || maxAdapters <= 0 {
+		klog.Warningf("invalid %s annotation on pod %s: %s, ignoring", ModelAdapterMaxAdaptersAnnotationKey, klog.KObj(pod), value)
+		return true
+	}
+	return loadedAdapters < maxAdapters
+}
+
+func containsModel(models []map[string]interface{}, name string) bool {
+	for _, model := range models {
+		if model["id"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// countLoadedAdapters returns the number of adapters among the models listed by the engine, they are the models
+// with a parent base model.
+func countLoadedAdapters(models []map[string]interface{}) int {
+	count := 0
+	for _, model := range models {
+		if parent, ok := model["parent"].(string); ok && parent != "" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modeladapter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+func TestVersionedAdapterName(t *testing.T) {
+	v1 := versionedAdapterName("lora-1", "huggingface://org/lora-v1")
+	v2 := versionedAdapterName("lora-1", "huggingface://org/lora-v2")
+
+	assert.True(t, strings.HasPrefix(v1, "lora-1-"))
+	assert.Equal(t, v1, versionedAdapterName("lora-1", "huggingface://org/lora-v1"), "the name is stable across reconciles")
+	assert.NotEqual(t, v1, v2)
+	assert.NotEqual(t, "lora-1", v1)
+}
+
+func TestLoadedArtifactURL(t *testing.T) {
+	instance := &modelv1alpha1.ModelAdapter{Spec: modelv1alpha1.ModelAdapterSpec{ArtifactURL: "s3://bucket/v2"}}
+	assert.Equal(t, "s3://bucket/v2", loadedArtifactURL(instance), "adapters loaded before the status was recorded serve the spec")
+
+	instance.Status.ArtifactURL = "s3://bucket/v1"
+	assert.Equal(t, "s3://bucket/v1", loadedArtifactURL(instance), "the previous artifact keeps the name during a rollout")
+}
+
+func TestNextRolloutPercent(t *testing.T) {
+	now := time.Now()
+	rolloutAt := func(percent int32, lastStep time.Time) *modelv1alpha1.ModelAdapter {
+		return &modelv1alpha1.ModelAdapter{Status: modelv1alpha1.ModelAdapterStatus{
+			Rollout: &modelv1alpha1.ModelAdapterRollout{Version: "lora-1-abcd", Percent: percent, LastStepTime: metav1.NewTime(lastStep)},
+		}}
+	}
+
+	tests := []struct {
+		name          string
+		instance      *modelv1alpha1.ModelAdapter
+		expected      int32
+		expectedAfter time.Duration
+	}{
+		{
+			name:          "rollout starts with a step",
+			instance:      &modelv1alpha1.ModelAdapter{},
+			expected:      defaultRolloutStepPercent,
+			expectedAfter: defaultRolloutStepInterval,
+		},
+		{
+			name:          "percent holds within the interval",
+			instance:      rolloutAt(40, now.Add(-20*time.Second)),
+			expected:      40,
+			expectedAfter: defaultRolloutStepInterval - 20*time.Second,
+		},
+		{
+			name:          "percent grows by a step after the interval",
+			instance:      rolloutAt(40, now.Add(-defaultRolloutStepInterval)),
+			expected:      40 + defaultRolloutStepPercent,
+			expectedAfter: defaultRolloutStepInterval,
+		},
+		{
+			name:     "percent is capped at 100",
+			instance: rolloutAt(90, now.Add(-2*defaultRolloutStepInterval)),
+			expected: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			percent, after := nextRolloutPercent(tt.instance, now)
+			assert.Equal(t, tt.expected, percent)
+			assert.Equal(t, tt.expectedAfter, after)
+		})
+	}
+
+	t.Run("spec percent is followed as is", func(t *testing.T) {
+		instance := rolloutAt(40, now.Add(-2*defaultRolloutStepInterval))
+		instance.Spec.RolloutPercent = ptr.To(int32(10))
+		percent, after := nextRolloutPercent(instance, now)
+		assert.Equal(t, int32(10), percent)
+		assert.Equal(t, time.Duration(0), after)
+	})
+}
+
+func TestHasRoomForAdapter(t *testing.T) {
+	pod := func(annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "llama-7b", Namespace: "default", Annotations: annotations}}
+	}
+
+	assert.True(t, hasRoomForAdapter(pod(nil), 10), "pods without the annotation are not limited")
+	assert.True(t, hasRoomForAdapter(pod(map[string]string{ModelAdapterMaxAdaptersAnnotationKey: "2"}), 1))
+	assert.False(t, hasRoomForAdapter(pod(map[string]string{ModelAdapterMaxAdaptersAnnotationKey: "2"}), 2))
+	assert.True(t, hasRoomForAdapter(pod(map[string]string{ModelAdapterMaxAdaptersAnnotationKey: "many"}), 2))
+}
+
+func TestLoadedModels(t *testing.T) {
+	models := []map[string]interface{}{
+		{"id": "llama-7b"},
+		{"id": "lora-1", "parent": "llama-7b"},
+		{"id": "lora-1-abcd", "parent": "llama-7b"},
+	}
+
+	assert.True(t, containsModel(models, "lora-1-abcd"))
+	assert.False(t, containsModel(models, "lora-2"))
+	assert.Equal(t, 2, countLoadedAdapters(models), "the base model is not an adapter")
+}
@@ -42,18 +42,15 @@ func (r binPackScheduler) SelectPod(ctx context.Context, model string, pods []v1
 	podRemainCapMin := math.MaxInt
 
 	for _, pod := range pods {
-		models, err := r.cache.GetModelsForPod(pod.Name)
-		if err != nil {
-			return nil, err
-		}
+		count := r.cache.GetModelAdapterCountForPod(pod.Name)
 		podCap := 10 // todo: replace mock data
-		if len(models) >= podCap {
+		if count >= podCap {
 			continue
 		}
 
-		if podCap-len(models) < podRemainCapMin {
+		if podCap-count < podRemainCapMin {
 			selectedPod = pod
-			podRemainCapMin = podCap - len(models)
+			podRemainCapMin = podCap - count
 		}
 	}
 
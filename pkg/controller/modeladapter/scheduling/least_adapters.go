@@ -38,18 +38,13 @@ func NewLeastAdapters(c *cache.Cache) Scheduler {
 func (r leastAdapters) SelectPod(ctx context.Context, model string, pods []v1.Pod) (*v1.Pod, error) {
 	selectedPod := v1.Pod{}
 	modelAdapterCountMin := math.MaxInt
-
 	for _, pod := range pods {
-		models, err := r.cache.GetModelsForPod(pod.Name)
-		if err != nil {
-			return nil, err
-		}
-		if len(models) < modelAdapterCountMin {
+		count := r.cache.GetModelAdapterCountForPod(pod.Name)
+		if count < modelAdapterCountMin {
 			selectedPod = pod
-			modelAdapterCountMin = len(models)
+			modelAdapterCountMin = count
 		}
 	}
-
 	klog.InfoS("pod selected with least model adapters", "pod", klog.KObj(&selectedPod))
 	return &selectedPod, nil
 }
@@ -19,6 +19,7 @@ package scheduling
 import (
 	"context"
 	"errors"
+	"slices"
 
 	v1 "k8s.io/api/core/v1"
 
@@ -47,3 +48,23 @@ func NewScheduler(policyName string, c *cache.Cache) (Scheduler, error) {
 		return nil, errors.New("unknown scheduler policy")
 	}
 }
+
+// SelectPods returns at most n pods to schedule the model adapter on, selected one after the other by the scheduler
+// among the pods not selected yet. Fewer pods are returned when the scheduler finds no pod with room for the model
+// adapter.
+func SelectPods(ctx context.Context, s Scheduler, model string, pods []v1.Pod, n int) ([]*v1.Pod, error) {
+	candidates := slices.Clone(pods)
+	var selected []*v1.Pod
+	for len(selected) < n && len(candidates) > 0 {
+		pod, err := s.SelectPod(ctx, model, candidates)
+		if err != nil {
+			return nil, err
+		}
+		if pod == nil || pod.Name == "" {
+			break
+		}
+		selected = append(selected, pod)
+		candidates = slices.DeleteFunc(candidates, func(candidate v1.Pod) bool { return candidate.Name == pod.Name })
+	}
+	return selected, nil
+}
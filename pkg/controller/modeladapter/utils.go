@@ -19,6 +19,7 @@ package modeladapter
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
@@ -107,6 +108,21 @@ func NewCondition(condType string, status metav1.ConditionStatus, reason, msg st
 	}
 }
 
+// isAdapterAlreadyLoaded returns whether the engine rejected the loading of an adapter because an adapter of the same
+// name is already loaded, e.g. vLLM answers "The lora adapter 'x' has already been loaded." with a 400.
+func isAdapterAlreadyLoaded(statusCode int, body []byte) bool {
+	return statusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(string(body)), "already been loaded")
+}
+
+// isAdapterNotLoaded returns whether the engine rejected the unloading of an adapter because no adapter of the name is
+// loaded, e.g. vLLM answers "The lora adapter 'x' cannot be found." with a 404, or a 400 in older versions.
+func isAdapterNotLoaded(statusCode int, body []byte) bool {
+	if statusCode == http.StatusNotFound {
+		return true
+	}
+	return statusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(string(body)), "cannot be found")
+}
+
 func BuildURLs(podIP string, config config.RuntimeConfig) URLConfig {
 	var host string
 	if config.DebugMode {
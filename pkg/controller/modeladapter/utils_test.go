@@ -17,12 +17,22 @@ limitations under the License.
 package modeladapter
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
 	"github.com/vllm-project/aibrix/pkg/config"
 )
 
@@ -199,3 +209,106 @@ func TestBuildURLs(t *testing.T) {
 		})
 	}
 }
+
+func TestIsAdapterAlreadyLoaded(t *testing.T) {
+	assert.True(t, isAdapterAlreadyLoaded(http.StatusBadRequest, []byte(`{"message": "The lora adapter 'lora-1' has already been loaded."}`)))
+	assert.False(t, isAdapterAlreadyLoaded(http.StatusBadRequest, []byte("No adapter found for lora-1")))
+	assert.False(t, isAdapterAlreadyLoaded(http.StatusInternalServerError, []byte("has already been loaded")))
+}
+
+func TestLoadModelAdapterIdempotent(t *testing.T) {
+	loaded := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		name := payload["lora_name"]
+		if loaded[name] {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "The lora adapter '%s' has already been loaded.", name)
+			return
+		}
+		if req.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		loaded[name] = true
+	}))
+	defer server.Close()
+
+	r := &ModelAdapterReconciler{}
+	instance := &modelv1alpha1.ModelAdapter{Spec: modelv1alpha1.ModelAdapterSpec{AdditionalConfig: map[string]string{"api-key": "token"}}}
+	assert.NoError(t, r.loadModelAdapter(server.URL, instance, "lora-1", "s3://bucket/lora-1"))
+	// loading an adapter which is already loaded does not fail.
+	assert.NoError(t, r.loadModelAdapter(server.URL, instance, "lora-1", "s3://bucket/lora-1"))
+
+	assert.Error(t, r.loadModelAdapter(server.URL, &modelv1alpha1.ModelAdapter{}, "lora-2", "s3://bucket/lora-2"))
+}
+
+func TestIsAdapterNotLoaded(t *testing.T) {
+	assert.True(t, isAdapterNotLoaded(http.StatusNotFound, []byte(`{"message": "The lora adapter 'lora-1' cannot be found."}`)))
+	assert.True(t, isAdapterNotLoaded(http.StatusBadRequest, []byte("The lora adapter 'lora-1' cannot be found.")))
+	assert.False(t, isAdapterNotLoaded(http.StatusBadRequest, []byte("invalid request")))
+	assert.False(t, isAdapterNotLoaded(http.StatusInternalServerError, []byte("cannot be found")))
+}
+
+func TestConfirmUnloadAdapter(t *testing.T) {
+	loaded := map[string]bool{"lora-1": true}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		name := payload["lora_name"]
+		if name == "lora-broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !loaded[name] {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, "The lora adapter '%s' cannot be found.", name)
+			return
+		}
+		delete(loaded, name)
+	}))
+	defer server.Close()
+
+	r := &ModelAdapterReconciler{}
+	instance := &modelv1alpha1.ModelAdapter{}
+	assert.NoError(t, r.confirmUnloadAdapter(server.URL, instance, "lora-1"))
+	assert.False(t, loaded["lora-1"])
+	// unloading an adapter which is not loaded does not fail.
+	assert.NoError(t, r.confirmUnloadAdapter(server.URL, instance, "lora-1"))
+
+	assert.Error(t, r.confirmUnloadAdapter(server.URL, instance, "lora-broken"))
+	// the best effort unloading ignores the failure.
+	assert.NoError(t, r.unloadAdapterVersion(server.URL, instance, "lora-broken"))
+}
+
+func TestUnloadModelAdapterSkipsUnreachablePods(t *testing.T) {
+	// the pod without an IP doesn't run the engine anymore, and the deleted pod is gone with its adapters.
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-1", Namespace: "default"}}
+	recorder := record.NewFakeRecorder(10)
+	r := &ModelAdapterReconciler{
+		Client:   fake.NewClientBuilder().WithObjects(pod).Build(),
+		Recorder: recorder,
+	}
+	instance := &modelv1alpha1.ModelAdapter{
+		ObjectMeta: metav1.ObjectMeta{Name: "lora-1", Namespace: "default"},
+		Status:     modelv1alpha1.ModelAdapterStatus{Instances: []string{"pod-1", "pod-2"}},
+	}
+
+	assert.NoError(t, r.unloadModelAdapter(context.TODO(), instance))
+	assert.Empty(t, recorder.Events)
+}
+
+func TestUnloadGracePeriod(t *testing.T) {
+	r := &ModelAdapterReconciler{}
+	assert.Equal(t, DefaultUnloadGracePeriod, r.unloadGracePeriod())
+
+	r.RuntimeConfig.ModelAdapterUnloadGracePeriod = time.Minute
+	assert.Equal(t, time.Minute, r.unloadGracePeriod())
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+// directPatchKinds are the kinds whose spec.replicas can be patched directly in the DirectPatch actuation mode.
+var directPatchKinds = map[schema.GroupKind]struct{}{
+	{Group: "apps", Kind: "Deployment"}:  {},
+	{Group: "apps", Kind: "StatefulSet"}: {},
+	{Group: "apps", Kind: "ReplicaSet"}:  {},
+}
+
+// fieldOwner is the field manager of the replicas the PodAutoscaler controller writes to the scale targets.
+const fieldOwner = "aibrix-podautoscaler"
+
+// updateScale applies the replicas to the target in the given actuation mode.
+func (r *PodAutoscalerReconciler) updateScale(ctx context.Context, namespace string, targetGR schema.GroupResource, scale *unstructured.Unstructured, replicas int32, mode autoscalingv1alpha1.ActuationMode) error {
+	if mode == autoscalingv1alpha1.ActuationModeDirectPatch {
+		return r.patchReplicas(ctx, scale, replicas)
+	}
+	return r.updateScaleSubresource(ctx, targetGR, scale, replicas)
+}
+
+// patchReplicas applies a strategic merge patch of spec.replicas to the target. The patch is guarded by the
+// resource version the replicas were computed from, a conflicting write refreshes the target and retries.
+func (r *PodAutoscalerReconciler) patchReplicas(ctx context.Context, target *unstructured.Unstructured, replicas int32) error {
+	gvk := target.GroupVersionKind()
+	if _, ok := directPatchKinds[gvk.GroupKind()]; !ok {
+		return fmt.Errorf("actuation mode %s does not support %s, use %s instead",
+			autoscalingv1alpha1.ActuationModeDirectPatch, gvk.GroupKind(), autoscalingv1alpha1.ActuationModeScaleSubresource)
+	}
+
+	resourceVersion := target.GetResourceVersion()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetNamespace(target.GetNamespace())
+		obj.SetName(target.GetName())
+		if resourceVersion == "" {
+			if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return err
+			}
+			resourceVersion = obj.GetResourceVersion()
+		}
+
+		patch := fmt.Sprintf(`{"metadata":{"resourceVersion":%q},"spec":{"replicas":%d}}`, resourceVersion, replicas)
+		err := r.Patch(ctx, obj, client.RawPatch(types.StrategicMergePatchType, []byte(patch)), client.FieldOwner(fieldOwner))
+		if apierrors.IsConflict(err) {
+			// refresh the resource version on the next attempt
+			resourceVersion = ""
+		}
+		return err
+	})
+}
+
+// newScaleSubresource returns an empty autoscaling/v1 Scale of the target.
+func newScaleSubresource(target *unstructured.Unstructured) *unstructured.Unstructured {
+	scale := &unstructured.Unstructured{}
+	scale.SetGroupVersionKind(autoscalingv1.SchemeGroupVersion.WithKind("Scale"))
+	scale.SetNamespace(target.GetNamespace())
+	scale.SetName(target.GetName())
+	return scale
+}
+
+// updateScaleSubresource sets the replicas of the target through its scale subresource, which the built-in
+// workloads and the custom resources enabling the subresource implement. The update is guarded by the resource
+// version the replicas were computed from, a conflicting write refreshes the scale and retries.
+func (r *PodAutoscalerReconciler) updateScaleSubresource(ctx context.Context, targetGR schema.GroupResource, target *unstructured.Unstructured, replicas int32) error {
+	scale := newScaleSubresource(target)
+	scale.SetResourceVersion(target.GetResourceVersion())
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if scale.GetResourceVersion() == "" {
+			scale = newScaleSubresource(target)
+			if err := r.SubResource("scale").Get(ctx, target, scale); err != nil {
+				return fmt.Errorf("failed to get the scale subresource of %s %s: %w", targetGR, target.GetName(), err)
+			}
+		}
+		if err := unstructured.SetNestedField(scale.Object, int64(replicas), "spec", "replicas"); err != nil {
+			return err
+		}
+		err := r.SubResource("scale").Update(ctx, target, client.WithSubResourceBody(scale), client.FieldOwner(fieldOwner))
+		if apierrors.IsConflict(err) {
+			// refresh the scale on the next attempt
+			scale.SetResourceVersion("")
+		}
+		return err
+	})
+}
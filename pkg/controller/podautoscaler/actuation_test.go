@@ -0,0 +1,245 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func TestReconcileActuationModes(t *testing.T) {
+	minReplicas := int32(3)
+	testCases := []struct {
+		name      string
+		mode      string
+		conflicts int
+	}{
+		{name: "default scale subresource"},
+		{name: "scale subresource", mode: string(autoscalingv1alpha1.ActuationModeScaleSubresource)},
+		{name: "direct patch", mode: string(autoscalingv1alpha1.ActuationModeDirectPatch)},
+		{name: "direct patch retries on conflict", mode: string(autoscalingv1alpha1.ActuationModeDirectPatch), conflicts: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var annotations map[string]string
+			if tc.mode != "" {
+				annotations = map[string]string{scalingcontext.ActuationModeLabel: tc.mode}
+			}
+			var updates, patches int
+			funcs := interceptor.Funcs{
+				SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+					if subResourceName == "scale" {
+						updates++
+					}
+					return updateTestScaleSubresource(ctx, c, subResourceName, obj, opts...)
+				},
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					patches++
+					if patch.Type() != types.StrategicMergePatchType {
+						t.Errorf("expected a strategic merge patch, got %s", patch.Type())
+					}
+					if patches <= tc.conflicts {
+						return apierrors.NewConflict(appsv1.Resource("deployments"), obj.GetName(), errors.New("the object has been modified"))
+					}
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}
+			r, recorder := newTestReconcilerWithInterceptor(t, funcs, newTestDeployment(1), newTestPodAutoscaler(&minReplicas, 10, annotations))
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != minReplicas {
+				t.Errorf("expected %d replicas, got %d", minReplicas, replicas)
+			}
+			if tc.mode == string(autoscalingv1alpha1.ActuationModeDirectPatch) {
+				if updates != 0 || patches != tc.conflicts+1 {
+					t.Errorf("expected %d patches and no scale updates of the target, got %d patches and %d updates", tc.conflicts+1, patches, updates)
+				}
+			} else if updates != 1 || patches != 0 {
+				t.Errorf("expected one scale update and no patches of the target, got %d updates and %d patches", updates, patches)
+			}
+			if count := countEvents(recorder, "SuccessfulRescale"); count != 1 {
+				t.Errorf("expected one SuccessfulRescale event, got %d", count)
+			}
+			pa := getTestPodAutoscaler(t, r)
+			if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.AbleToScale) {
+				t.Errorf("expected AbleToScale to be true")
+			}
+		})
+	}
+}
+
+func TestReconcileDirectPatchConflictsExhausted(t *testing.T) {
+	minReplicas := int32(3)
+	funcs := interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return apierrors.NewConflict(appsv1.Resource("deployments"), obj.GetName(), errors.New("the object has been modified"))
+		},
+	}
+	annotations := map[string]string{scalingcontext.ActuationModeLabel: string(autoscalingv1alpha1.ActuationModeDirectPatch)}
+	r, recorder := newTestReconcilerWithInterceptor(t, funcs, newTestDeployment(1), newTestPodAutoscaler(&minReplicas, 10, annotations))
+	if err := reconcileTestPodAutoscaler(t, r); err == nil {
+		t.Fatalf("expected reconcile to fail")
+	}
+
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 1 {
+		t.Errorf("expected the target to keep 1 replica, got %d", replicas)
+	}
+	if count := countEvents(recorder, "FailedRescale"); count != 1 {
+		t.Errorf("expected one FailedRescale event, got %d", count)
+	}
+	pa := getTestPodAutoscaler(t, r)
+	if cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.AbleToScale); cond == nil ||
+		cond.Status != metav1.ConditionFalse || cond.Reason != "FailedUpdateScale" {
+		t.Errorf("expected AbleToScale to be false with reason FailedUpdateScale, got %+v", cond)
+	}
+}
+
+func TestPatchReplicasUnsupportedKind(t *testing.T) {
+	r, _ := newTestReconciler(t)
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(schema.GroupVersionKind{Group: "orchestration.aibrix.ai", Version: "v1alpha1", Kind: "RayClusterFleet"})
+	target.SetNamespace(testNamespace)
+	target.SetName("fleet")
+
+	err := r.patchReplicas(context.Background(), target, 3)
+	if err == nil || !strings.Contains(err.Error(), "does not support RayClusterFleet.orchestration.aibrix.ai") {
+		t.Errorf("expected an unsupported kind error, got %v", err)
+	}
+}
+
+// newTestScalableResource creates a custom resource implementing the scale subresource, selecting the test pods.
+func newTestScalableResource(replicas int64) *unstructured.Unstructured {
+	target := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": testDeployName}},
+		},
+	}}
+	target.SetGroupVersionKind(schema.GroupVersionKind{Group: "serving.example.com", Version: "v1", Kind: "ModelServer"})
+	target.SetNamespace(testNamespace)
+	target.SetName(testDeployName)
+	return target
+}
+
+func TestReconcileKPAScaleSubresource(t *testing.T) {
+	testCases := []struct {
+		name   string
+		custom bool
+	}{
+		{name: "deployment"},
+		{name: "custom resource", custom: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := newTestAPAObjects(3, "8000", nil)
+			pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+			pa.Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+			targetGVK := appsv1.SchemeGroupVersion.WithKind("Deployment")
+			if tc.custom {
+				custom := newTestScalableResource(3)
+				targetGVK = custom.GroupVersionKind()
+				objs[0] = custom
+				pa.Spec.ScaleTargetRef.APIVersion = targetGVK.GroupVersion().String()
+				pa.Spec.ScaleTargetRef.Kind = targetGVK.Kind
+			}
+			var scaleUpdates int
+			funcs := interceptor.Funcs{
+				SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+					if subResourceName == "scale" {
+						scaleUpdates++
+						updateOptions := client.SubResourceUpdateOptions{}
+						updateOptions.ApplyOptions(opts)
+						if updateOptions.FieldManager != fieldOwner {
+							t.Errorf("expected the field manager %s, got %q", fieldOwner, updateOptions.FieldManager)
+						}
+					}
+					return updateTestScaleSubresource(ctx, c, subResourceName, obj, opts...)
+				},
+			}
+			r, _ := newTestReconcilerWithInterceptor(t, funcs, objs...)
+			// the kind of the target is resolved through the preferred versions of its group.
+			mapper := apimeta.NewDefaultRESTMapper([]schema.GroupVersion{appsv1.SchemeGroupVersion, targetGVK.GroupVersion()})
+			mapper.Add(targetGVK, apimeta.RESTScopeNamespace)
+			r.Mapper = mapper
+			// the 20 requests of the 3 pods need 5 pods at the target of 4.
+			fetcher := metrics.NewFakeMetricFetcher()
+			fetcher.SetPodMetric("test-pod-0", 8)
+			fetcher.SetPodMetric("test-pod-1", 8)
+			fetcher.SetPodMetric("test-pod-2", 4)
+			r.metricFetcher = fetcher
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			target := &unstructured.Unstructured{}
+			target.SetGroupVersionKind(targetGVK)
+			if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testDeployName}, target); err != nil {
+				t.Fatalf("failed to get the target: %v", err)
+			}
+			if replicas, _, _ := unstructured.NestedInt64(target.Object, "spec", "replicas"); replicas != 5 {
+				t.Errorf("expected the target to be scaled from 3 to 5 replicas, got %d", replicas)
+			}
+			if scaleUpdates != 1 {
+				t.Errorf("expected one update of the scale subresource, got %d", scaleUpdates)
+			}
+		})
+	}
+}
+
+func TestUpdateScaleSubresourceRetriesOnConflict(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestDeployment(1))
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testDeployName}, target); err != nil {
+		t.Fatalf("failed to get the target: %v", err)
+	}
+	// the target is written after its replicas were read, the stale scale update conflicts and is retried.
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testDeployName}, deploy); err != nil {
+		t.Fatalf("failed to get Deployment: %v", err)
+	}
+	deploy.Labels = map[string]string{"touched": "true"}
+	if err := r.Update(context.Background(), deploy); err != nil {
+		t.Fatalf("failed to update Deployment: %v", err)
+	}
+
+	if err := r.updateScaleSubresource(context.Background(), appsv1.Resource("deployments"), target, 4); err != nil {
+		t.Fatalf("updateScaleSubresource failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 4 {
+		t.Errorf("expected 4 replicas, got %d", replicas)
+	}
+}
@@ -0,0 +1,288 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// DefaultOutOfOrderTolerance is how late a sample may arrive, compared to the newest one recorded, and still be
+// aggregated into its bucket. Scrapes of the pods of a deployment finish in any order, so their samples are
+// recorded slightly out of order.
+const DefaultOutOfOrderTolerance = 5 * time.Second
+
+// WindowAggregation is how the average of a SlidingWindow weighs its samples.
+type WindowAggregation string
+
+const (
+	// SampleWeighted averages the samples of the window, each sample weighs the same regardless of the time between
+	// the samples.
+	SampleWeighted WindowAggregation = "sample-weighted"
+	// TimeWeighted averages the value of the window over time: a sample stands for the time since the previous
+	// sample, the first sample of the window for the time since the start of the window at most. The time not
+	// covered by any sample, before the first sample recorded or after the newest one, is not averaged, so that a
+	// sparse sampling does not bias the average towards zero.
+	TimeWeighted WindowAggregation = "time-weighted"
+)
+
+// bucket aggregates the samples recorded within one granularity interval.
+type bucket struct {
+	// index identifies the interval of the bucket, a bucket whose index is out of the window is stale.
+	index int64
+	// prev is the index of the newest bucket recorded before the bucket, the start of the time its samples stand for.
+	prev  int64
+	sum   float64
+	max   float64
+	count int
+}
+
+func (b *bucket) record(value float64) {
+	if b.count == 0 || value > b.max {
+		b.max = value
+	}
+	b.sum += value
+	b.count++
+}
+
+// SlidingWindow aggregates the samples of the last {duration} in a ring of per-granularity buckets. Unlike
+// TimeWindow, it is aggregated relative to the time of the query: the samples older than the window age out even
+// when no sample was recorded since, so that a gap in the scrapes does not keep a stale value alive.
+// SlidingWindow is not safe for concurrent use.
+type SlidingWindow struct {
+	buckets     []bucket
+	duration    time.Duration
+	granularity time.Duration
+	tolerance   time.Duration
+	aggregation WindowAggregation
+	// newest is the index of the newest bucket recorded, valid once recorded is set.
+	newest   int64
+	recorded bool
+}
+
+// NewSlidingWindow creates a sample-weighted SlidingWindow of the given duration, aggregating the samples per
+// granularity.
+func NewSlidingWindow(duration, granularity time.Duration) *SlidingWindow {
+	return NewSlidingWindowWithAggregation(duration, granularity, SampleWeighted)
+}
+
+// NewSlidingWindowWithAggregation creates a SlidingWindow of the given duration whose average weighs the samples
+// following the given aggregation.
+func NewSlidingWindowWithAggregation(duration, granularity time.Duration, aggregation WindowAggregation) *SlidingWindow {
+	w := &SlidingWindow{granularity: granularity, tolerance: DefaultOutOfOrderTolerance, aggregation: aggregation}
+	w.Resize(duration)
+	return w
+}
+
+// Duration returns the time range the window aggregates.
+func (w *SlidingWindow) Duration() time.Duration {
+	return w.duration
+}
+
+func (w *SlidingWindow) bucketIndex(t time.Time) int64 {
+	return t.UnixNano() / int64(w.granularity)
+}
+
+func (w *SlidingWindow) slot(index int64) *bucket {
+	size := int64(len(w.buckets))
+	return &w.buckets[((index%size)+size)%size]
+}
+
+// Record aggregates a sample into the bucket of its time. A sample older than the newest one is still aggregated
+// if it is late by at most the out-of-order tolerance and its bucket is still in the window, otherwise it is
+// dropped. It returns whether the sample was recorded.
+func (w *SlidingWindow) Record(t time.Time, value float64) bool {
+	index := w.bucketIndex(t)
+	if w.recorded && index < w.newest {
+		late := time.Duration(w.newest-index) * w.granularity
+		if late > w.tolerance || w.newest-index >= int64(len(w.buckets)) {
+			return false
+		}
+	}
+	// a sample late into a new bucket only stands for the interval of its bucket.
+	prev := index - 1
+	if w.recorded && w.newest < index {
+		prev = w.newest
+	}
+	if !w.recorded || index > w.newest {
+		w.newest = index
+		w.recorded = true
+	}
+
+	b := w.slot(index)
+	if b.index != index || b.count == 0 {
+		// the slot holds a bucket that aged out of the window.
+		*b = bucket{index: index, prev: prev}
+	}
+	b.record(value)
+	return true
+}
+
+// aggregate returns the sum, the count and the max of the samples within the window ending at now. The samples
+// of the last {duration} are aggregated, a sample exactly {duration} old included. If the clock of the caller is
+// behind the newest sample, the window ends at the newest sample instead, so that a clock skew does not hide the
+// recent samples.
+func (w *SlidingWindow) aggregate(now time.Time) (sum, maxValue float64, count int) {
+	return w.aggregateOver(now, w.duration)
+}
+
+// bounds returns the indexes of the first and the last bucket of the last {duration} of the window ending at now,
+// the duration is capped to the one of the window.
+func (w *SlidingWindow) bounds(now time.Time, duration time.Duration) (start, end int64) {
+	end = w.bucketIndex(now)
+	if end < w.newest {
+		end = w.newest
+	}
+	size := int64(math.Ceil(float64(duration)/float64(w.granularity))) + 1
+	if size > int64(len(w.buckets)) {
+		size = int64(len(w.buckets))
+	}
+	return end - size + 1, end
+}
+
+// aggregateOver aggregates the samples of the last {duration} like aggregate, the duration is capped to the one of
+// the window.
+func (w *SlidingWindow) aggregateOver(now time.Time, duration time.Duration) (sum, maxValue float64, count int) {
+	if !w.recorded {
+		return 0, 0, 0
+	}
+	start, end := w.bounds(now, duration)
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.count == 0 || b.index < start || b.index > end {
+			continue
+		}
+		if count == 0 || b.max > maxValue {
+			maxValue = b.max
+		}
+		sum += b.sum
+		count += b.count
+	}
+	return sum, maxValue, count
+}
+
+// timeWeightedAverageOver returns the average over time of the samples of the last {duration} of the window ending
+// at now. The samples of a bucket are averaged first, then the bucket weighs the number of intervals since the
+// previous bucket recorded, within the window.
+func (w *SlidingWindow) timeWeightedAverageOver(now time.Time, duration time.Duration) (avg float64, count int) {
+	if !w.recorded {
+		return 0, 0
+	}
+	start, end := w.bounds(now, duration)
+	var sum float64
+	var weights, prev int64
+	for index := start; index <= end; index++ {
+		b := w.slot(index)
+		if b.count == 0 || b.index != index {
+			continue
+		}
+		if count == 0 {
+			// the first bucket stands for the time since the previous one, which may be before the window.
+			prev = max(b.prev, start-1)
+		}
+		weight := b.index - prev
+		sum += b.sum / float64(b.count) * float64(weight)
+		weights += weight
+		count += b.count
+		prev = b.index
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return sum / float64(weights), count
+}
+
+// averageOver returns the average of the last {duration} of the window ending at now, following the aggregation of
+// the window.
+func (w *SlidingWindow) averageOver(now time.Time, duration time.Duration) (float64, error) {
+	if w.aggregation == TimeWeighted {
+		avg, count := w.timeWeightedAverageOver(now, duration)
+		if count == 0 {
+			return 0, errors.New("no data available")
+		}
+		return avg, nil
+	}
+	sum, _, count := w.aggregateOver(now, duration)
+	if count == 0 {
+		return 0, errors.New("no data available")
+	}
+	return sum / float64(count), nil
+}
+
+// WindowAverage returns the average of the samples within the window ending at now.
+func (w *SlidingWindow) WindowAverage(now time.Time) (float64, error) {
+	return w.averageOver(now, w.duration)
+}
+
+// WindowAverageOver returns the average of the samples of the last {duration} of the window ending at now, so that
+// a single history of samples serves the aggregates over several durations.
+func (w *SlidingWindow) WindowAverageOver(now time.Time, duration time.Duration) (float64, error) {
+	return w.averageOver(now, duration)
+}
+
+// WindowMax returns the max of the samples within the window ending at now.
+func (w *SlidingWindow) WindowMax(now time.Time) (float64, error) {
+	_, maxValue, count := w.aggregate(now)
+	if count == 0 {
+		return 0, errors.New("no data available")
+	}
+	return maxValue, nil
+}
+
+// Resize changes the duration of the window. The samples recorded within the new duration of the newest one are
+// kept, so that a resized window does not start empty.
+func (w *SlidingWindow) Resize(duration time.Duration) {
+	if duration == w.duration && w.buckets != nil {
+		return
+	}
+	// the window covers both ends of its duration: one bucket more than the intervals it spans.
+	size := int(math.Ceil(float64(duration)/float64(w.granularity))) + 1
+	old := w.buckets
+	w.buckets = make([]bucket, size)
+	w.duration = duration
+	for i := range old {
+		b := old[i]
+		if b.count == 0 || b.index <= w.newest-int64(size) {
+			continue
+		}
+		*w.slot(b.index) = b
+	}
+}
+
+func (w *SlidingWindow) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("SlidingWindow(duration=%v, granularity=%v, buckets=[", w.duration, w.granularity))
+	first := true
+	if w.recorded {
+		for index := w.newest - int64(len(w.buckets)) + 1; index <= w.newest; index++ {
+			b := w.slot(index)
+			if b.count == 0 || b.index != index {
+				continue
+			}
+			if !first {
+				sb.WriteString(", ")
+			}
+			first = false
+			sb.WriteString(fmt.Sprintf("{%d, %.2f}", b.index, b.sum/float64(b.count)))
+		}
+	}
+	sb.WriteString("])")
+	return sb.String()
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregation
+
+import (
+	"testing"
+	"time"
+)
+
+func expectWindow(t *testing.T, w *SlidingWindow, now time.Time, avg, max float64) {
+	t.Helper()
+	if got, err := w.WindowAverage(now); err != nil || got != avg {
+		t.Errorf("Expected average %.2f, got %.2f err: %v, window: %v", avg, got, err, w)
+	}
+	if got, err := w.WindowMax(now); err != nil || got != max {
+		t.Errorf("Expected max %.2f, got %.2f err: %v, window: %v", max, got, err, w)
+	}
+}
+
+func TestSlidingWindowRingWrapAround(t *testing.T) {
+	w := NewSlidingWindow(5*time.Second, time.Second)
+	start := time.Unix(1000, 0)
+	for i := 0; i < 20; i++ {
+		w.Record(start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	now := start.Add(19 * time.Second)
+	// the samples of the last 5 seconds, both ends included: 14..19.
+	expectWindow(t, w, now, 16.5, 19)
+
+	// several samples within the same bucket are averaged as samples, not as buckets.
+	w.Record(now.Add(300*time.Millisecond), 27)
+	expectWindow(t, w, now, 18, 27)
+}
+
+func TestSlidingWindowGap(t *testing.T) {
+	w := NewSlidingWindow(5*time.Second, time.Second)
+	start := time.Unix(1000, 0)
+	w.Record(start, 10)
+	w.Record(start.Add(time.Second), 20)
+	expectWindow(t, w, start.Add(5*time.Second), 15, 20)
+
+	// the samples age out relative to the query even if nothing was recorded since.
+	expectWindow(t, w, start.Add(6*time.Second), 20, 20)
+	if _, err := w.WindowAverage(start.Add(7 * time.Second)); err == nil {
+		t.Errorf("Expected no data once every sample aged out, window: %v", w)
+	}
+
+	// a stale bucket reused by the ring after a gap does not leak its old samples.
+	w.Record(start.Add(12*time.Second), 30)
+	expectWindow(t, w, start.Add(12*time.Second), 30, 30)
+}
+
+func TestSlidingWindowOutOfOrder(t *testing.T) {
+	w := NewSlidingWindow(30*time.Second, time.Second)
+	start := time.Unix(1000, 0)
+	w.Record(start.Add(10*time.Second), 10)
+	if !w.Record(start.Add(8*time.Second), 20) {
+		t.Errorf("Expected a sample late within the tolerance to be recorded")
+	}
+	if w.Record(start, 100) {
+		t.Errorf("Expected a sample late beyond the tolerance to be dropped")
+	}
+	expectWindow(t, w, start.Add(10*time.Second), 15, 20)
+}
+
+func TestSlidingWindowClockSkew(t *testing.T) {
+	w := NewSlidingWindow(5*time.Second, time.Second)
+	start := time.Unix(1000, 0)
+	w.Record(start, 10)
+	w.Record(start.Add(3*time.Second), 20)
+
+	// a query with a clock behind the newest sample still sees the recent samples.
+	expectWindow(t, w, start.Add(-10*time.Second), 15, 20)
+}
+
+func TestSlidingWindowResize(t *testing.T) {
+	w := NewSlidingWindow(10*time.Second, time.Second)
+	start := time.Unix(1000, 0)
+	for i := 0; i <= 10; i++ {
+		w.Record(start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	now := start.Add(10 * time.Second)
+	expectWindow(t, w, now, 5, 10)
+
+	// shrinking keeps the samples within the new duration.
+	w.Resize(4 * time.Second)
+	if w.Duration() != 4*time.Second {
+		t.Errorf("Expected duration 4s, got %v", w.Duration())
+	}
+	expectWindow(t, w, now, 8, 10)
+
+	// growing keeps what was retained and aggregates the new samples over the longer window.
+	w.Resize(20 * time.Second)
+	for i := 11; i <= 15; i++ {
+		w.Record(start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	expectWindow(t, w, start.Add(15*time.Second), 10.5, 15)
+}
+
+func TestSlidingWindowAverageOver(t *testing.T) {
+	w := NewSlidingWindow(10*time.Second, time.Second)
+	start := time.Unix(1000, 0)
+	for i := 0; i <= 10; i++ {
+		w.Record(start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+	now := start.Add(10 * time.Second)
+
+	// the samples 8, 9 and 10 of the last 2s.
+	if avg, err := w.WindowAverageOver(now, 2*time.Second); err != nil || avg != 9 {
+		t.Errorf("Expected the average over 2s to be 9, got %v (%v)", avg, err)
+	}
+	// a duration longer than the window is capped to it.
+	if avg, err := w.WindowAverageOver(now, time.Minute); err != nil || avg != 5 {
+		t.Errorf("Expected the average over 1m to be 5, got %v (%v)", avg, err)
+	}
+	// the shorter duration ages out first.
+	if _, err := w.WindowAverageOver(start.Add(15*time.Second), 2*time.Second); err == nil {
+		t.Errorf("Expected no data within the last 2s")
+	}
+}
+
+func expectAverage(t *testing.T, w *SlidingWindow, now time.Time, avg float64) {
+	t.Helper()
+	if got, err := w.WindowAverage(now); err != nil || got != avg {
+		t.Errorf("Expected %s average %.2f, got %.2f err: %v, window: %v", w.aggregation, avg, got, err, w)
+	}
+}
+
+func TestSlidingWindowAggregationDense(t *testing.T) {
+	sampled := NewSlidingWindow(10*time.Second, time.Second)
+	timed := NewSlidingWindowWithAggregation(10*time.Second, time.Second, TimeWeighted)
+	start := time.Unix(1000, 0)
+	for i := 0; i <= 20; i++ {
+		sampled.Record(start.Add(time.Duration(i)*time.Second), float64(i))
+		timed.Record(start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+
+	// a sample per bucket: every sample stands for the same time, both aggregations agree.
+	now := start.Add(20 * time.Second)
+	expectAverage(t, sampled, now, 15)
+	expectAverage(t, timed, now, 15)
+}
+
+func TestSlidingWindowAggregationSparse(t *testing.T) {
+	sampled := NewSlidingWindow(time.Minute, time.Second)
+	timed := NewSlidingWindowWithAggregation(time.Minute, time.Second, TimeWeighted)
+	start := time.Unix(1000, 0)
+	for _, sample := range []struct {
+		offset time.Duration
+		value  float64
+	}{{0, 100}, {20 * time.Second, 10}, {30 * time.Second, 40}, {60 * time.Second, 10}} {
+		sampled.Record(start.Add(sample.offset), sample.value)
+		timed.Record(start.Add(sample.offset), sample.value)
+	}
+
+	// the window starts after the first sample: 10 stands for the 20s before it, 40 for 10s and the newest 10 for 30s.
+	now := start.Add(61 * time.Second)
+	expectAverage(t, sampled, now, 20)
+	expectAverage(t, timed, now, 15)
+}
+
+func TestSlidingWindowAggregationBursty(t *testing.T) {
+	sampled := NewSlidingWindow(time.Minute, time.Second)
+	timed := NewSlidingWindowWithAggregation(time.Minute, time.Second, TimeWeighted)
+	start := time.Unix(1000, 0)
+	record := func(offset time.Duration, value float64) {
+		sampled.Record(start.Add(offset), value)
+		timed.Record(start.Add(offset), value)
+	}
+	for _, offset := range []time.Duration{0, 30 * time.Second, 50 * time.Second} {
+		record(offset, 10)
+	}
+	// a burst of scrapes within the last 10s.
+	for i := 51; i <= 60; i++ {
+		record(time.Duration(i)*time.Second, 70)
+	}
+
+	// the burst is only a sixth of the window, the sample-weighted average is dominated by its many samples.
+	now := start.Add(61 * time.Second)
+	expectAverage(t, sampled, now, 60)
+	expectAverage(t, timed, now, 20)
+}
+
+// TestSlidingWindowSparseZeroBias checks that samples scraped every 15s into a 60s window average to their value:
+// the buckets without a sample must not count as zeros, neither before the window is full nor between the samples.
+func TestSlidingWindowSparseZeroBias(t *testing.T) {
+	start := time.Unix(1000, 0)
+	for _, aggregation := range []WindowAggregation{SampleWeighted, TimeWeighted} {
+		w := NewSlidingWindowWithAggregation(time.Minute, time.Second, aggregation)
+		for offset := time.Duration(0); offset <= 3*time.Minute; offset += time.Second {
+			if offset%(15*time.Second) == 0 {
+				w.Record(start.Add(offset), 12)
+			}
+			expectAverage(t, w, start.Add(offset), 12)
+		}
+	}
+}
@@ -68,11 +68,16 @@ func (w *window) Record(value float64, index int) {
 		w.length--
 	}
 
-	// Add the new value to the valueList.
-	if w.length < w.Size() { // Ensure we do not exceed the buffer
-		w.valueList[w.index(w.first+w.length)] = entry{value: value, index: index}
-		w.length++
+	// Several values recorded within the same index may fill up the buffer, evict the oldest
+	// entry in that case so that the latest value is never dropped.
+	if w.length == w.Size() {
+		w.first = w.index(w.first + 1)
+		w.length--
 	}
+
+	// Add the new value to the valueList.
+	w.valueList[w.index(w.first+w.length)] = entry{value: value, index: index}
+	w.length++
 }
 
 func (w *window) Max() (float64, error) {
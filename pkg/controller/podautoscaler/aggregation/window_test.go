@@ -130,3 +130,18 @@ func TestDelayWindow(t *testing.T) {
 		t.Errorf("Expected delayed count to match original, got %f", delayedPodCount)
 	}
 }
+
+func TestWindowFullWithinSameIndex(t *testing.T) {
+	win := newWindow(2)
+	win.Record(5, 0)
+	win.Record(5, 0)
+	// The buffer is full, the latest value must evict the oldest one instead of being dropped.
+	win.Record(10, 1)
+
+	if max, err := win.Max(); err != nil || max != 10 {
+		t.Errorf("Expected max 10 after recording into a full window, got %f err: %v", max, err)
+	}
+	if min, err := win.Min(); err != nil || min != 5 {
+		t.Errorf("Expected min 5 after recording into a full window, got %f err: %v", min, err)
+	}
+}
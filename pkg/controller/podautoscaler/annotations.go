@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+// deprecatedAnnotations maps the annotations which are still honored but being replaced, e.g. by spec fields,
+// to their replacement. Annotations are added here as their replacement becomes available.
+var deprecatedAnnotations = map[string]string{}
+
+// knownAnnotations are all annotations read under the autoscaling prefixes.
+var knownAnnotations = func() map[string]struct{} {
+	known := map[string]struct{}{}
+	for _, keys := range [][]string{scalingcontext.Annotations, scaler.KPAAnnotations, scaler.APAAnnotations} {
+		for _, key := range keys {
+			known[key] = struct{}{}
+		}
+	}
+	return known
+}()
+
+// isAutoscalingAnnotation returns whether the annotation is under one of the autoscaling prefixes,
+// e.g. "autoscaling.aibrix.ai/" or "kpa.autoscaling.aibrix.ai/".
+func isAutoscalingAnnotation(key string) bool {
+	domain, _, found := strings.Cut(key, "/")
+	if !found {
+		return false
+	}
+	prefix := strings.TrimSuffix(scalingcontext.AutoscalingLabelPrefix, "/")
+	return domain == prefix || strings.HasSuffix(domain, "."+prefix)
+}
+
+// validateAnnotations returns the sorted deprecated and unknown autoscaling annotations.
+func validateAnnotations(annotations map[string]string) ([]string, []string) {
+	var deprecated, unknown []string
+	for key := range annotations {
+		if _, ok := deprecatedAnnotations[key]; ok {
+			deprecated = append(deprecated, key)
+			continue
+		}
+		if _, ok := knownAnnotations[key]; !ok && isAutoscalingAnnotation(key) {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(deprecated)
+	sort.Strings(unknown)
+	return deprecated, unknown
+}
+
+// checkAnnotations warns about deprecated and unknown annotations of the PodAutoscaler with an event and the
+// DeprecatedConfiguration condition. The condition records the generation and the keys warned about, so that
+// the event is only emitted again when the PodAutoscaler or its annotations change.
+func (r *PodAutoscalerReconciler) checkAnnotations(pa *autoscalingv1alpha1.PodAutoscaler) {
+	deprecated, unknown := validateAnnotations(pa.Annotations)
+	if len(deprecated) == 0 && len(unknown) == 0 {
+		apimeta.RemoveStatusCondition(&pa.Status.Conditions, autoscalingv1alpha1.DeprecatedConfiguration)
+		return
+	}
+
+	var warnings []string
+	reason := "UnknownAnnotations"
+	if len(deprecated) > 0 {
+		reason = "DeprecatedAnnotations"
+		replacements := make([]string, 0, len(deprecated))
+		for _, key := range deprecated {
+			replacements = append(replacements, fmt.Sprintf("%s (use %s)", key, deprecatedAnnotations[key]))
+		}
+		warnings = append(warnings, "deprecated annotations: "+strings.Join(replacements, ", "))
+	}
+	if len(unknown) > 0 {
+		warnings = append(warnings, "unknown annotations: "+strings.Join(unknown, ", "))
+	}
+	message := strings.Join(warnings, "; ")
+
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.DeprecatedConfiguration)
+	if cond != nil && cond.ObservedGeneration == pa.Generation && cond.Message == message {
+		return
+	}
+	r.EventRecorder.Event(pa, corev1.EventTypeWarning, autoscalingv1alpha1.DeprecatedConfiguration, message)
+	apimeta.SetStatusCondition(&pa.Status.Conditions, metav1.Condition{
+		Type:               autoscalingv1alpha1.DeprecatedConfiguration,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: pa.Generation,
+	})
+}
+
+// setConfigInvalid reports the invalid scaling parameters of the PA with the ConfigInvalid condition and an event,
+// emitted once per distinct error, as the annotations change without bumping the generation.
+func (r *PodAutoscalerReconciler) setConfigInvalid(ctx context.Context, paStatusOriginal *autoscalingv1alpha1.PodAutoscalerStatus, pa *autoscalingv1alpha1.PodAutoscaler, err error) error {
+	klog.ErrorS(err, "Invalid scaling configuration", "PodAutoscaler", klog.KObj(pa))
+	message := fmt.Sprintf("the scaling configuration is invalid: %s", strings.ReplaceAll(err.Error(), "\n", "; "))
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ConfigInvalid)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Message != message {
+		r.EventRecorder.Event(pa, corev1.EventTypeWarning, "ConfigInvalid", message)
+	}
+	apimeta.SetStatusCondition(&pa.Status.Conditions, metav1.Condition{
+		Type:               autoscalingv1alpha1.ConfigInvalid,
+		Status:             metav1.ConditionTrue,
+		Reason:             "InvalidAnnotation",
+		Message:            message,
+		ObservedGeneration: pa.Generation,
+	})
+	return r.updateStatusIfNeeded(ctx, paStatusOriginal, pa)
+}
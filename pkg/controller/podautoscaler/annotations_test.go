@@ -0,0 +1,139 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func TestReconcileWarnsAboutAnnotationsOncePerGeneration(t *testing.T) {
+	deprecatedAnnotations["autoscaling.aibrix.ai/max-scale-up-rate"] = "spec.maxScaleUpRate"
+	defer delete(deprecatedAnnotations, "autoscaling.aibrix.ai/max-scale-up-rate")
+
+	annotations := map[string]string{
+		"autoscaling.aibrix.ai/max-scale-up-rate": "3",
+		"autoscaling.aibrix.ai/scale-to-zer":      "true",
+		"kpa.autoscaling.aibrix.ai/stable-window": "30s",
+		"app.kubernetes.io/name":                  "test",
+	}
+	pa := newTestPodAutoscaler(nil, 10, annotations)
+	pa.Generation = 1
+	r, recorder := newTestReconciler(t, newTestDeployment(0), pa)
+
+	for i := 0; i < 3; i++ {
+		if err := reconcileTestPodAutoscaler(t, r); err != nil {
+			t.Fatalf("reconcile #%d failed: %v", i, err)
+		}
+	}
+	if count := countEvents(recorder, autoscalingv1alpha1.DeprecatedConfiguration); count != 1 {
+		t.Errorf("expected exactly one DeprecatedConfiguration event over repeated reconciles, got %d", count)
+	}
+	cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.DeprecatedConfiguration)
+	expected := "deprecated annotations: autoscaling.aibrix.ai/max-scale-up-rate (use spec.maxScaleUpRate); " +
+		"unknown annotations: autoscaling.aibrix.ai/scale-to-zer"
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "DeprecatedAnnotations" || cond.Message != expected {
+		t.Fatalf("expected DeprecatedConfiguration=True listing the keys, got %+v", cond)
+	}
+
+	// the deprecated annotation is still honored.
+	ctx := scalingcontext.NewBaseScalingContext()
+	if err := ctx.UpdateByPaTypes(getTestPodAutoscaler(t, r)); err != nil {
+		t.Fatalf("failed to resolve the scaling context: %v", err)
+	}
+	if ctx.MaxScaleUpRate != 3 {
+		t.Errorf("expected the deprecated annotation to set the max scale up rate to 3, got %v", ctx.MaxScaleUpRate)
+	}
+
+	// a new generation warns again.
+	updated := getTestPodAutoscaler(t, r)
+	updated.Generation = 2
+	if err := r.Update(context.Background(), updated); err != nil {
+		t.Fatalf("failed to update PodAutoscaler: %v", err)
+	}
+	_ = reconcileTestPodAutoscaler(t, r)
+	_ = reconcileTestPodAutoscaler(t, r)
+	if count := countEvents(recorder, autoscalingv1alpha1.DeprecatedConfiguration); count != 1 {
+		t.Errorf("expected one DeprecatedConfiguration event for the new generation, got %d", count)
+	}
+
+	// fixing the annotations clears the condition.
+	fixed := getTestPodAutoscaler(t, r)
+	fixed.Annotations = map[string]string{scalingcontext.ScaleToZeroLabel: "false"}
+	if err := r.Update(context.Background(), fixed); err != nil {
+		t.Fatalf("failed to update PodAutoscaler: %v", err)
+	}
+	_ = reconcileTestPodAutoscaler(t, r)
+	if cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.DeprecatedConfiguration); cond != nil {
+		t.Errorf("expected DeprecatedConfiguration to be removed, got %+v", cond)
+	}
+	if count := countEvents(recorder, autoscalingv1alpha1.DeprecatedConfiguration); count != 0 {
+		t.Errorf("expected no DeprecatedConfiguration event, got %d", count)
+	}
+}
+
+func TestReconcileReportsInvalidKPAConfig(t *testing.T) {
+	pa := newTestPodAutoscaler(nil, 10, map[string]string{
+		"kpa.autoscaling.aibrix.ai/panic-threshold": "0.5",
+		"kpa.autoscaling.aibrix.ai/stable-window":   "forever",
+	})
+	r, recorder := newTestReconciler(t, newTestDeployment(0), pa)
+
+	for i := 0; i < 2; i++ {
+		if err := reconcileTestPodAutoscaler(t, r); err != nil {
+			t.Fatalf("reconcile #%d failed: %v", i, err)
+		}
+	}
+	pa = getTestPodAutoscaler(t, r)
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ConfigInvalid)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "InvalidAnnotation" ||
+		!strings.Contains(cond.Message, "kpa.autoscaling.aibrix.ai/panic-threshold") ||
+		!strings.Contains(cond.Message, "kpa.autoscaling.aibrix.ai/stable-window") {
+		t.Fatalf("expected ConfigInvalid=True listing both annotations, got %+v", cond)
+	}
+	if count := countEvents(recorder, "ConfigInvalid"); count != 1 {
+		t.Errorf("expected one ConfigInvalid event over repeated reconciles, got %d", count)
+	}
+	// the target is not evaluated with the invalid configuration.
+	if cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ScalingDisabled); cond != nil {
+		t.Errorf("expected the target not to be evaluated, got %+v", cond)
+	}
+
+	// the fixed annotations take effect on the next reconcile.
+	pa.Annotations["kpa.autoscaling.aibrix.ai/panic-threshold"] = "3"
+	pa.Annotations["kpa.autoscaling.aibrix.ai/stable-window"] = "90s"
+	if err := r.Update(context.Background(), pa); err != nil {
+		t.Fatalf("failed to update PodAutoscaler: %v", err)
+	}
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	pa = getTestPodAutoscaler(t, r)
+	if cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ConfigInvalid); cond != nil {
+		t.Errorf("expected the ConfigInvalid condition to be removed, got %+v", cond)
+	}
+	if cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ScalingDisabled); cond == nil {
+		t.Errorf("expected the target to be evaluated once the configuration is fixed")
+	}
+}
@@ -17,18 +17,93 @@ limitations under the License.
 package common
 
 import (
+	"fmt"
+	"math"
 	"strconv"
 
 	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/klog/v2"
 )
 
 const (
 	AutoscalingLabelPrefix = "autoscaling.aibrix.ai/"
-	maxScaleUpRateLabel    = AutoscalingLabelPrefix + "max-scale-up-rate"
-	maxScaleDownRateLabel  = AutoscalingLabelPrefix + "max-scale-down-rate"
+	// MaxScaleUpRateLabel is how many times the replicas can grow in one scaling step, e.g. "2".
+	MaxScaleUpRateLabel = AutoscalingLabelPrefix + "max-scale-up-rate"
+	// MaxScaleDownRateLabel is how many times the replicas can shrink in one scaling step, e.g. "2".
+	MaxScaleDownRateLabel = AutoscalingLabelPrefix + "max-scale-down-rate"
+	// ScaleToZeroLabel enables scale-to-zero for a PodAutoscaler. When set to "true", zero replicas
+	// is a valid state managed by the autoscaler instead of a signal that autoscaling has been disabled.
+	ScaleToZeroLabel = AutoscalingLabelPrefix + "scale-to-zero"
+	// NoReadyPodsPolicyLabel selects the NoReadyPodsPolicy applied when the target has replicas but no ready pods.
+	NoReadyPodsPolicyLabel = AutoscalingLabelPrefix + "no-ready-pods-policy"
+	// NoReadyPodsGracePeriodLabel is how long the target must have no ready pods before the policy applies,
+	// so that brief readiness flaps do not resize the target.
+	NoReadyPodsGracePeriodLabel = AutoscalingLabelPrefix + "no-ready-pods-grace-period"
+	// ActuationModeLabel selects the ActuationMode used to apply the desired replicas to the target.
+	ActuationModeLabel = AutoscalingLabelPrefix + "actuation-mode"
+	// ScaleDownStabilizationWindowLabel is how far back the KPA recommendations are considered when scaling down,
+	// e.g. "300s". The highest recommendation within the window is used, "0s" scales down immediately.
+	ScaleDownStabilizationWindowLabel = AutoscalingLabelPrefix + "scale-down-stabilization-window"
+	// ScaleToZeroRetentionPeriodLabel is how long a scale-to-zero KPA target keeps one replica after its traffic
+	// stopped, e.g. "5m". "0s" scales to zero as soon as the scaling algorithm recommends it.
+	ScaleToZeroRetentionPeriodLabel = AutoscalingLabelPrefix + "scale-to-zero-retention-period"
+	// ActivationRequestedAtLabel is set to an RFC3339 timestamp, e.g. by the gateway when a request arrives for a
+	// model without replicas, to scale a KPA target from zero before its metrics report traffic.
+	ActivationRequestedAtLabel = AutoscalingLabelPrefix + "activation-requested-at"
+	// SyncPeriodLabel is how often a KPA or APA PodAutoscaler re-evaluates its metrics, e.g. "30s". It overrides
+	// the sync period of the controller.
+	SyncPeriodLabel = AutoscalingLabelPrefix + "sync-period"
+	// RecommendationSinkLabel selects the RecommendationSink of a PodAutoscaler in the External actuation mode.
+	RecommendationSinkLabel = AutoscalingLabelPrefix + "recommendation-sink"
+	// RecommendationWebhookURLLabel is the endpoint the Webhook sink posts the recommendations to.
+	RecommendationWebhookURLLabel = AutoscalingLabelPrefix + "recommendation-webhook-url"
+	// RecommendationWebhookSecretLabel names the Secret, in the namespace of the PodAutoscaler, holding the HMAC
+	// key the Webhook sink signs the recommendations with under the "hmac-key" key.
+	RecommendationWebhookSecretLabel = AutoscalingLabelPrefix + "recommendation-webhook-secret"
+	// RecommendationRedisStreamLabel is the Redis stream the RedisStream sink appends the recommendations to.
+	RecommendationRedisStreamLabel = AutoscalingLabelPrefix + "recommendation-redis-stream"
+	// RecommendationLabel is written by the Annotation sink with the last recommendation of the PodAutoscaler.
+	RecommendationLabel = AutoscalingLabelPrefix + "recommendation"
+	// ImportFromHPALabel names an HPA, in the namespace of the PodAutoscaler, whose replicas range, metrics and
+	// behavior are imported into the PodAutoscaler once. The fields already set on the PodAutoscaler win.
+	ImportFromHPALabel = AutoscalingLabelPrefix + "import-from-hpa"
+	// ImportModeLabel selects what becomes of the imported HPA: "copy", the default, leaves it in place, and
+	// "takeover" deletes it so that it stops scaling the target.
+	ImportModeLabel = AutoscalingLabelPrefix + "import-mode"
+	// ImportedByLabel is set on an HPA imported in the copy mode to the name of the PodAutoscaler which imported it.
+	ImportedByLabel = AutoscalingLabelPrefix + "imported-by"
+	// IneffectiveScalingThresholdLabel is the mean relative error of the metric per replica against its target value,
+	// one stable window after the scaling decisions, above which the scaling is reported ineffective, e.g. "0.5".
+	IneffectiveScalingThresholdLabel = AutoscalingLabelPrefix + "ineffective-scaling-threshold"
+	// IneffectiveScalingDecisionsLabel is how many of the last scaling decisions the mean error is computed over,
+	// e.g. "5".
+	IneffectiveScalingDecisionsLabel = AutoscalingLabelPrefix + "ineffective-scaling-decisions"
 )
 
+// Annotations are the annotations read by the controller and the base scaling context.
+var Annotations = []string{
+	MaxScaleUpRateLabel,
+	MaxScaleDownRateLabel,
+	ScaleToZeroLabel,
+	NoReadyPodsPolicyLabel,
+	NoReadyPodsGracePeriodLabel,
+	ActuationModeLabel,
+	ScaleDownStabilizationWindowLabel,
+	ScaleToZeroRetentionPeriodLabel,
+	ActivationRequestedAtLabel,
+	SyncPeriodLabel,
+	RecommendationSinkLabel,
+	RecommendationWebhookURLLabel,
+	RecommendationWebhookSecretLabel,
+	RecommendationRedisStreamLabel,
+	RecommendationLabel,
+	ImportFromHPALabel,
+	ImportModeLabel,
+	IneffectiveScalingThresholdLabel,
+	IneffectiveScalingDecisionsLabel,
+}
+
 // ScalingContext defines the generalized common that holds all necessary data for scaling calculations.
 type ScalingContext interface {
 	GetTargetValue() float64
@@ -78,7 +153,7 @@ func (b *BaseScalingContext) UpdateByPaTypes(pa *autoscalingv1alpha1.PodAutoscal
 
 	b.ScalingMetric = source.TargetMetric
 	// parse target value
-	targetValue, err := strconv.ParseFloat(source.TargetValue, 64)
+	targetValue, err := ParseTargetValue(source.TargetValue)
 	if err != nil {
 		klog.ErrorS(err, "Failed to parse target value", "targetValue", source.TargetValue)
 		return err
@@ -87,14 +162,14 @@ func (b *BaseScalingContext) UpdateByPaTypes(pa *autoscalingv1alpha1.PodAutoscal
 
 	for key, value := range pa.Annotations {
 		switch key {
-		case maxScaleUpRateLabel:
-			v, err := strconv.ParseFloat(value, 64)
+		case MaxScaleUpRateLabel:
+			v, err := ParseScaleRate(key, value)
 			if err != nil {
 				return err
 			}
 			b.MaxScaleUpRate = v
-		case maxScaleDownRateLabel:
-			v, err := strconv.ParseFloat(value, 64)
+		case MaxScaleDownRateLabel:
+			v, err := ParseScaleRate(key, value)
 			if err != nil {
 				return err
 			}
@@ -104,6 +179,31 @@ func (b *BaseScalingContext) UpdateByPaTypes(pa *autoscalingv1alpha1.PodAutoscal
 	return nil
 }
 
+// ParseScaleRate parses a max scale rate, a finite factor of at least 1: a rate of 1 leaves the replicas unchanged.
+func ParseScaleRate(key, value string) (float64, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 1 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid %s %q: the rate must be a finite number of at least 1", key, value)
+	}
+	return v, nil
+}
+
+// ParseTargetValue parses a metric target value, which is either a plain number or
+// a kubernetes quantity with unit suffix, e.g. "500m" is converted to 0.5.
+func ParseTargetValue(value string) (float64, error) {
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		return v, nil
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid target value %q: %v", value, err)
+	}
+	return quantity.AsApproximateFloat64(), nil
+}
+
 func (b *BaseScalingContext) SetCurrentUsePerPod(value float64) {
 	b.currentUsePerPod = value
 }
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+// The stages of a reconcile whose failures are counted by podAutoscalerErrors.
+const (
+	stageGetScale     = "get_scale"
+	stageFetchMetrics = "fetch_metrics"
+	stageUpdateScale  = "update_scale"
+	stageUpdateStatus = "update_status"
+)
+
+var (
+	podAutoscalerDesiredReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aibrix_podautoscaler_desired_replicas",
+		Help: "Replicas of the scale target recommended by the last decision of a PodAutoscaler.",
+	}, []string{"namespace", "name"})
+
+	podAutoscalerActualReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aibrix_podautoscaler_actual_replicas",
+		Help: "Replicas of the scale target observed by the last reconcile of a PodAutoscaler.",
+	}, []string{"namespace", "name"})
+
+	podAutoscalerMetricValue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "aibrix_podautoscaler_metric_value",
+		Help: "Value of a metric of a PodAutoscaler observed over its stable or panic window, only KPA has a panic window.",
+	}, []string{"namespace", "name", "metric", "window"})
+
+	podAutoscalerRescales = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aibrix_podautoscaler_rescales_total",
+		Help: "Number of rescales applied to the scale targets, by direction, up or down, and scaling strategy.",
+	}, []string{"direction", "strategy"})
+
+	podAutoscalerMetricScrapeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aibrix_podautoscaler_metric_scrape_duration_seconds",
+		Help:    "Duration of the collection of the metrics of a metric source, e.g. the scrape of the pods, by source type.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"source"})
+
+	podAutoscalerReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aibrix_podautoscaler_reconcile_duration_seconds",
+		Help:    "Duration of the reconciles of the PodAutoscalers, by scaling strategy.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"strategy"})
+
+	podAutoscalerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aibrix_podautoscaler_errors_total",
+		Help: "Number of failed PodAutoscaler reconciles, by the failed stage: get_scale, fetch_metrics, update_scale or update_status.",
+	}, []string{"stage"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(podAutoscalerDesiredReplicas, podAutoscalerActualReplicas, podAutoscalerMetricValue,
+		podAutoscalerRescales, podAutoscalerMetricScrapeDuration, podAutoscalerReconcileDuration, podAutoscalerErrors)
+}
+
+// recordReplicas records the observed and the recommended replicas of the scale target of the PodAutoscaler.
+func recordReplicas(pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas, desiredReplicas int32) {
+	podAutoscalerActualReplicas.WithLabelValues(pa.Namespace, pa.Name).Set(float64(currentReplicas))
+	podAutoscalerDesiredReplicas.WithLabelValues(pa.Namespace, pa.Name).Set(float64(desiredReplicas))
+}
+
+// recordMetricValues records the metric values the scaler of the metric observed.
+func recordMetricValues(pa *autoscalingv1alpha1.PodAutoscaler, metricName string, result scaler.ScaleResult) {
+	podAutoscalerMetricValue.WithLabelValues(pa.Namespace, pa.Name, metricName, "stable").Set(result.StableValue)
+	if pa.Spec.ScalingStrategy == autoscalingv1alpha1.KPA {
+		podAutoscalerMetricValue.WithLabelValues(pa.Namespace, pa.Name, metricName, "panic").Set(result.PanicValue)
+	}
+}
+
+// recordRescale counts a rescale of the scale target from the current to the desired replicas.
+func recordRescale(pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas, desiredReplicas int32) {
+	direction := "up"
+	if desiredReplicas < currentReplicas {
+		direction = "down"
+	}
+	podAutoscalerRescales.WithLabelValues(direction, string(pa.Spec.ScalingStrategy)).Inc()
+}
+
+// recordReconcileDuration records the duration of a reconcile of a PodAutoscaler of the strategy from start to end.
+func recordReconcileDuration(strategy autoscalingv1alpha1.ScalingStrategyType, start, end time.Time) {
+	podAutoscalerReconcileDuration.WithLabelValues(string(strategy)).Observe(end.Sub(start).Seconds())
+}
+
+// deleteRemovedMetricValues deletes the metric values of the metric of the PodAutoscaler, once the metric is removed.
+func deleteRemovedMetricValues(namespace, name, metricName string) {
+	podAutoscalerMetricValue.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "name": name, "metric": metricName})
+}
+
+// deletePodAutoscalerMetrics deletes the series of a deleted PodAutoscaler, so that they don't grow without bound.
+func deletePodAutoscalerMetrics(pa types.NamespacedName) {
+	podAutoscalerDesiredReplicas.DeleteLabelValues(pa.Namespace, pa.Name)
+	podAutoscalerActualReplicas.DeleteLabelValues(pa.Namespace, pa.Name)
+	podAutoscalerMetricValue.DeletePartialMatch(prometheus.Labels{"namespace": pa.Namespace, "name": pa.Name})
+}
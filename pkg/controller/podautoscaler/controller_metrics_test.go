@@ -0,0 +1,83 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func TestReconcileRecordsControllerMetrics(t *testing.T) {
+	objs := newTestAPAObjects(3, "8000", nil)
+	pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+	pa.Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+	r, _ := newTestReconciler(t, objs...)
+	// the 20 requests of the 3 pods need 5 pods at the target of 4.
+	fetcher := metrics.NewFakeMetricFetcher()
+	fetcher.SetPodMetric("test-pod-0", 8)
+	fetcher.SetPodMetric("test-pod-1", 8)
+	fetcher.SetPodMetric("test-pod-2", 4)
+	r.metricFetcher = fetcher
+
+	rescales := podAutoscalerRescales.WithLabelValues("up", string(autoscalingv1alpha1.KPA))
+	before := testutil.ToFloat64(rescales)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if delta := testutil.ToFloat64(rescales) - before; delta != 1 {
+		t.Errorf("expected one scale up to be counted, got %v", delta)
+	}
+	if replicas := testutil.ToFloat64(podAutoscalerActualReplicas.WithLabelValues(testNamespace, testPaName)); replicas != 3 {
+		t.Errorf("expected 3 actual replicas, got %v", replicas)
+	}
+	if replicas := testutil.ToFloat64(podAutoscalerDesiredReplicas.WithLabelValues(testNamespace, testPaName)); replicas != 5 {
+		t.Errorf("expected 5 desired replicas, got %v", replicas)
+	}
+	for _, window := range []string{"stable", "panic"} {
+		if value := testutil.ToFloat64(podAutoscalerMetricValue.WithLabelValues(testNamespace, testPaName, "test_metric", window)); value != 20 {
+			t.Errorf("expected a %s value of 20, got %v", window, value)
+		}
+	}
+
+	// the series are dropped with the PodAutoscaler.
+	r.deleteStaleScalerInCache(types.NamespacedName{Namespace: testNamespace, Name: testPaName})
+	if podAutoscalerDesiredReplicas.DeleteLabelValues(testNamespace, testPaName) || podAutoscalerActualReplicas.DeleteLabelValues(testNamespace, testPaName) {
+		t.Errorf("expected the replicas of the deleted PodAutoscaler to be removed")
+	}
+	if count := podAutoscalerMetricValue.DeletePartialMatch(map[string]string{"namespace": testNamespace, "name": testPaName}); count != 0 {
+		t.Errorf("expected the metric values of the deleted PodAutoscaler to be removed, got %d series", count)
+	}
+}
+
+func TestReconcileCountsErrorsByStage(t *testing.T) {
+	pa := newTestPodAutoscaler(nil, 10, nil)
+	// the scale target does not exist.
+	r, _ := newTestReconciler(t, pa)
+	errs := podAutoscalerErrors.WithLabelValues(stageGetScale)
+	before := testutil.ToFloat64(errs)
+	if err := reconcileTestPodAutoscaler(t, r); err == nil {
+		t.Fatalf("expected the reconcile to fail without the scale target")
+	}
+	if delta := testutil.ToFloat64(errs) - before; delta != 1 {
+		t.Errorf("expected one get_scale error to be counted, got %v", delta)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+// maxDecisionMessageLength caps the explanation of a scaling decision, which is written to the events, the logs and
+// the status of the PodAutoscaler.
+const maxDecisionMessageLength = 512
+
+// decisionExplanation is the structured explanation of a scaling decision. A decision made on the metrics starts from
+// the recommendation of the metric which drove it, any other decision from its reason, and both go on with the steps
+// of the decision pipeline which adjusted the replica count.
+type decisionExplanation struct {
+	// metric is the metric which drove the decision, empty for a decision made without the metrics.
+	metric string
+	// reason explains a decision made without the metrics, e.g. "no ready pods, ScaleToMin policy".
+	reason string
+	scaler.ScaleExplanation
+}
+
+// explainMetric starts the explanation of a decision from the recommendation of the metric which drove it. The
+// recommendation of a scaler which does not explain it, e.g. a registered strategy, is reported as is.
+func explainMetric(metric string, result scaler.ScaleResult) *decisionExplanation {
+	recommendation := result.Explanation
+	if recommendation.TargetValue == 0 && len(recommendation.Adjustments) == 0 {
+		return explainReason(fmt.Sprintf("%s recommendation", metric), result.DesiredPodCount)
+	}
+	recommendation.Adjustments = append([]scaler.ScaleAdjustment(nil), recommendation.Adjustments...)
+	return &decisionExplanation{metric: metric, ScaleExplanation: recommendation}
+}
+
+// explainReason starts the explanation of a decision made without the metrics.
+func explainReason(reason string, replicas int32) *decisionExplanation {
+	return &decisionExplanation{reason: reason, ScaleExplanation: scaler.ScaleExplanation{RawPodCount: replicas}}
+}
+
+// String formats the explanation into a single line, e.g.
+// "concurrency avg 18.2 over 60s vs target 10 → raw 11, capped by maxScaleUp 2x to 8, stabilization kept 9".
+func (e *decisionExplanation) String() string {
+	if e == nil {
+		return ""
+	}
+	var b strings.Builder
+	if e.metric != "" {
+		fmt.Fprintf(&b, "%s avg %s", e.metric, formatDecisionValue(e.Value))
+		if e.Window > 0 {
+			fmt.Fprintf(&b, " over %s", formatDecisionWindow(e.Window))
+		}
+		fmt.Fprintf(&b, " vs target %s → raw %d", formatDecisionValue(e.TargetValue), e.RawPodCount)
+	} else {
+		fmt.Fprintf(&b, "%s → %d", e.reason, e.RawPodCount)
+	}
+	for _, adjustment := range e.Adjustments {
+		b.WriteString(", ")
+		if adjustment.Kind == scaler.AdjustmentKept {
+			fmt.Fprintf(&b, "%s kept %d", adjustment.Rule, adjustment.PodCount)
+		} else {
+			fmt.Fprintf(&b, "%s by %s to %d", adjustment.Kind, adjustment.Rule, adjustment.PodCount)
+		}
+	}
+	return truncateDecisionMessage(b.String())
+}
+
+// formatDecisionValue formats a metric value with at most two decimals.
+func formatDecisionValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// formatDecisionWindow formats a metric window in seconds, e.g. "60s" rather than "1m0s".
+func formatDecisionWindow(window time.Duration) string {
+	return strconv.FormatFloat(window.Seconds(), 'f', -1, 64) + "s"
+}
+
+// truncateDecisionMessage caps the message to maxDecisionMessageLength bytes without splitting a character.
+func truncateDecisionMessage(message string) string {
+	if len(message) <= maxDecisionMessageLength {
+		return message
+	}
+	const ellipsis = "..."
+	end := maxDecisionMessageLength - len(ellipsis)
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end] + ellipsis
+}
+
+// setLastDecision records the scaling decision in the status of the PA. The time of the decision is kept while the
+// decision and its explanation are unchanged.
+func setLastDecision(pa *autoscalingv1alpha1.PodAutoscaler, desiredReplicas int32, message string, now time.Time) {
+	last := pa.Status.LastDecision
+	if last != nil && last.DesiredReplicas == desiredReplicas && last.Message == message {
+		return
+	}
+	pa.Status.LastDecision = &autoscalingv1alpha1.ScalingDecision{
+		Time:            metav1.NewTime(now),
+		DesiredReplicas: desiredReplicas,
+		Message:         message,
+	}
+}
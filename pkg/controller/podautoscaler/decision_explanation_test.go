@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+func TestReconcileDecisionExplanation(t *testing.T) {
+	testCases := []struct {
+		name             string
+		replicas         int32
+		maxReplicas      int32
+		metric           float64
+		expectedReplicas int32
+		expectedMessage  string
+	}{
+		{
+			// the single ready pod can only double.
+			name:             "capped by the scale up rate",
+			replicas:         1,
+			maxReplicas:      10,
+			metric:           40,
+			expectedReplicas: 2,
+			expectedMessage:  "test_metric avg 40 over 60s vs target 4 → raw 10, capped by maxScaleUp 2x to 2",
+		},
+		{
+			name:             "capped by maxReplicas",
+			replicas:         1,
+			maxReplicas:      1,
+			metric:           8,
+			expectedReplicas: 1,
+			expectedMessage:  "test_metric avg 8 over 60s vs target 4 → raw 2, capped by maxReplicas to 1",
+		},
+		{
+			// a new KPA scaler of several pods starts in panic mode, which does not scale down.
+			name:             "kept by the panic mode",
+			replicas:         2,
+			maxReplicas:      10,
+			metric:           1,
+			expectedReplicas: 2,
+			expectedMessage:  "test_metric avg 2 over 60s vs target 4 → raw 1, panic mode kept 2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := newTestAPAObjects(tc.replicas, "8000", nil)
+			pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+			pa.Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+			pa.Spec.MaxReplicas = tc.maxReplicas
+			r, recorder := newTestReconciler(t, objs...)
+			fetcher := metrics.NewFakeMetricFetcher()
+			for i := int32(0); i < tc.replicas; i++ {
+				fetcher.SetPodMetric(fmt.Sprintf("test-pod-%d", i), tc.metric)
+			}
+			r.metricFetcher = fetcher
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			decision := getTestPodAutoscaler(t, r).Status.LastDecision
+			if decision == nil {
+				t.Fatalf("expected the last decision to be recorded")
+			}
+			if decision.DesiredReplicas != tc.expectedReplicas || decision.Message != tc.expectedMessage {
+				t.Errorf("expected the decision of %d replicas %q, got %d replicas %q",
+					tc.expectedReplicas, tc.expectedMessage, decision.DesiredReplicas, decision.Message)
+			}
+			rescaled := false
+			for len(recorder.Events) > 0 {
+				if e := <-recorder.Events; strings.Contains(e, "SuccessfulRescale") {
+					rescaled = true
+					if !strings.HasSuffix(e, "decision: "+tc.expectedMessage) {
+						t.Errorf("expected the SuccessfulRescale event to explain the decision, got %q", e)
+					}
+				}
+			}
+			if rescaled != (tc.expectedReplicas != tc.replicas) {
+				t.Errorf("expected a SuccessfulRescale event only when the target is rescaled, got %t", rescaled)
+			}
+
+			// the time of an unchanged decision is kept.
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			if next := getTestPodAutoscaler(t, r).Status.LastDecision; next == nil || next.Message != tc.expectedMessage || !next.Time.Equal(&decision.Time) {
+				t.Errorf("expected the decision %+v to be kept, got %+v", decision, next)
+			}
+		})
+	}
+}
+
+func TestDecisionExplanationString(t *testing.T) {
+	pipeline := explainMetric("concurrency", scaler.ScaleResult{DesiredPodCount: 8, Explanation: scaler.ScaleExplanation{
+		Value:       18.2,
+		Window:      60 * time.Second,
+		TargetValue: 10,
+		RawPodCount: 11,
+		Adjustments: []scaler.ScaleAdjustment{{Rule: "maxScaleUp 2x", Kind: scaler.AdjustmentCapped, PodCount: 8}},
+	}})
+	pipeline.Adjust("maxReplicas", 8, 8)
+	pipeline.Keep("stabilization", 8, 9)
+	pipeline.Adjust("PodDisruptionBudget", 9, 10)
+
+	rounded := explainMetric("kv_cache_usage", scaler.ScaleResult{DesiredPodCount: 3,
+		Explanation: scaler.ScaleExplanation{Value: 1.23456, Window: 90 * time.Second, TargetValue: 0.5, RawPodCount: 3}})
+	rounded.Adjust("minReplicas", 3, 4)
+
+	testCases := []struct {
+		name        string
+		explanation *decisionExplanation
+		expected    string
+	}{
+		{
+			name:        "pipeline",
+			explanation: pipeline,
+			expected: "concurrency avg 18.2 over 60s vs target 10 → raw 11, capped by maxScaleUp 2x to 8, " +
+				"stabilization kept 9, raised by PodDisruptionBudget to 10",
+		},
+		{
+			name:        "rounded value",
+			explanation: rounded,
+			expected:    "kv_cache_usage avg 1.23 over 90s vs target 0.5 → raw 3, raised by minReplicas to 4",
+		},
+		{
+			name:        "unexplained recommendation",
+			explanation: explainMetric("test_metric", scaler.ScaleResult{DesiredPodCount: 5}),
+			expected:    "test_metric recommendation → 5",
+		},
+		{
+			name:        "without metrics",
+			explanation: explainReason("no ready pods, ScaleToMin policy", 1),
+			expected:    "no ready pods, ScaleToMin policy → 1",
+		},
+		{
+			name:     "no decision",
+			expected: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.explanation.String(); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+
+	// the explanation is capped without splitting a character.
+	long := explainReason(strings.Repeat("→", maxDecisionMessageLength), 1).String()
+	if len(long) > maxDecisionMessageLength || !strings.HasSuffix(long, "...") || !utf8.ValidString(long) {
+		t.Errorf("expected the explanation to be capped to %d bytes, got %d bytes %q", maxDecisionMessageLength, len(long), long)
+	}
+}
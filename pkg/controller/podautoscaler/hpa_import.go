@@ -0,0 +1,364 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+// Modes of the import of an HPA, selected by the import-mode annotation.
+const (
+	// hpaImportCopy leaves the imported HPA in place, it keeps scaling the target until it is removed.
+	hpaImportCopy = "copy"
+	// hpaImportTakeover deletes the imported HPA once its settings are imported.
+	hpaImportTakeover = "takeover"
+)
+
+// hpaImport is the outcome of the translation of an HPA into a PodAutoscaler.
+type hpaImport struct {
+	// imported lists the settings copied into the PodAutoscaler.
+	imported []string
+	// kept lists the settings of the HPA the PodAutoscaler already sets.
+	kept []string
+	// skipped lists the settings of the HPA the PodAutoscaler cannot represent, with the reason.
+	skipped []string
+}
+
+// message summarizes the import for the ImportCompleted condition.
+func (i *hpaImport) message(hpaName string) string {
+	message := fmt.Sprintf("imported HPA %s", hpaName)
+	if len(i.imported) > 0 {
+		message += ": " + strings.Join(i.imported, ", ")
+	}
+	if len(i.kept) > 0 {
+		message += "; kept the PodAutoscaler " + strings.Join(i.kept, ", ")
+	}
+	if len(i.skipped) > 0 {
+		message += "; skipped " + strings.Join(i.skipped, ", ")
+	}
+	return message
+}
+
+// getHPAImportMode returns the import mode of the PodAutoscaler, defaulting to copy.
+func getHPAImportMode(pa *autoscalingv1alpha1.PodAutoscaler) (string, error) {
+	value, ok := pa.Annotations[scalingcontext.ImportModeLabel]
+	if !ok || value == "" {
+		return hpaImportCopy, nil
+	}
+	switch mode := strings.ToLower(value); mode {
+	case hpaImportCopy, hpaImportTakeover:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid %s annotation %q, valid modes are %s and %s",
+		scalingcontext.ImportModeLabel, value, hpaImportCopy, hpaImportTakeover)
+}
+
+// importFromHPA imports the HPA named by the import-from-hpa annotation into the PodAutoscaler, once. It returns
+// whether the PodAutoscaler was updated, in which case it is reconciled again with the imported configuration.
+// An import which cannot proceed is reported with the ImportCompleted condition and retried on the next reconcile,
+// the PodAutoscaler is reconciled meanwhile with its own configuration.
+func (r *PodAutoscalerReconciler) importFromHPA(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) (bool, error) {
+	name := pa.Annotations[scalingcontext.ImportFromHPALabel]
+	if name == "" || apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ImportCompleted) {
+		return false, nil
+	}
+	paStatusOriginal := pa.Status.DeepCopy()
+	failImport := func(reason, message string, args ...interface{}) (bool, error) {
+		cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ImportCompleted)
+		if cond == nil || cond.Reason != reason {
+			r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, "ImportFailed", message, args...)
+		}
+		setCondition(pa, autoscalingv1alpha1.ImportCompleted, metav1.ConditionFalse, reason, message, args...)
+		return false, r.updateStatusIfNeeded(ctx, paStatusOriginal, pa)
+	}
+
+	mode, err := getHPAImportMode(pa)
+	if err != nil {
+		return failImport("InvalidImportMode", "%v", err)
+	}
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: pa.Namespace, Name: name}, hpa); err != nil {
+		if apierrors.IsNotFound(err) {
+			return failImport("HPANotFound", "the HPA %s to import was not found", name)
+		}
+		return false, err
+	}
+	if isManagedHPA(hpa) {
+		return failImport("ManagedHPA", "the HPA %s is generated by a PodAutoscaler and cannot be imported", name)
+	}
+	if !sameScaleTarget(hpa.Spec.ScaleTargetRef, pa.Spec.ScaleTargetRef) {
+		return failImport("ScaleTargetMismatch", "the HPA %s scales %s %s, not the target of the PodAutoscaler",
+			name, hpa.Spec.ScaleTargetRef.Kind, hpa.Spec.ScaleTargetRef.Name)
+	}
+
+	result := translateHPA(hpa, pa)
+	if err := r.Update(ctx, pa); err != nil {
+		klog.ErrorS(err, "Failed to update the PodAutoscaler with the imported HPA", "PodAutoscaler", klog.KObj(pa), "HPA", name)
+		return false, err
+	}
+	klog.InfoS("Imported HPA into the PodAutoscaler", "PodAutoscaler", klog.KObj(pa), "HPA", name, "mode", mode,
+		"imported", result.imported, "kept", result.kept, "skipped", result.skipped)
+
+	switch mode {
+	case hpaImportTakeover:
+		if err := r.Delete(ctx, hpa); err != nil && !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "Failed to delete the imported HPA", "HPA", klog.KObj(hpa))
+			return false, err
+		}
+		r.EventRecorder.Eventf(pa, corev1.EventTypeNormal, "HPADeleted", "Deleted HPA %s after importing it", name)
+	default:
+		if hpa.Annotations[scalingcontext.ImportedByLabel] != pa.Name {
+			if hpa.Annotations == nil {
+				hpa.Annotations = map[string]string{}
+			}
+			hpa.Annotations[scalingcontext.ImportedByLabel] = pa.Name
+			if err := r.Update(ctx, hpa); err != nil {
+				klog.ErrorS(err, "Failed to annotate the imported HPA", "HPA", klog.KObj(hpa))
+				return false, err
+			}
+		}
+	}
+
+	message := result.message(name)
+	r.EventRecorder.Event(pa, corev1.EventTypeNormal, "ImportCompleted", message)
+	setCondition(pa, autoscalingv1alpha1.ImportCompleted, metav1.ConditionTrue, "Imported", "%s", message)
+	return true, r.updateStatusIfNeeded(ctx, paStatusOriginal, pa)
+}
+
+// sameScaleTarget returns whether the HPA scales the target of the PodAutoscaler.
+func sameScaleTarget(hpaTarget autoscalingv2.CrossVersionObjectReference, paTarget corev1.ObjectReference) bool {
+	hpaGroup := schema.FromAPIVersionAndKind(hpaTarget.APIVersion, hpaTarget.Kind).Group
+	paGroup := schema.FromAPIVersionAndKind(paTarget.APIVersion, paTarget.Kind).Group
+	return hpaGroup == paGroup && hpaTarget.Kind == paTarget.Kind && hpaTarget.Name == paTarget.Name
+}
+
+// translateHPA copies the replicas range, the metrics and the behavior of the HPA the PodAutoscaler does not set
+// yet into the PodAutoscaler.
+func translateHPA(hpa *autoscalingv2.HorizontalPodAutoscaler, pa *autoscalingv1alpha1.PodAutoscaler) *hpaImport {
+	result := &hpaImport{}
+
+	if pa.Spec.MinReplicas != nil {
+		result.kept = append(result.kept, "minReplicas")
+	} else if hpa.Spec.MinReplicas != nil {
+		minReplicas := *hpa.Spec.MinReplicas
+		pa.Spec.MinReplicas = &minReplicas
+		result.imported = append(result.imported, "minReplicas")
+	}
+	if pa.Spec.MaxReplicas != 0 {
+		result.kept = append(result.kept, "maxReplicas")
+	} else {
+		pa.Spec.MaxReplicas = hpa.Spec.MaxReplicas
+		result.imported = append(result.imported, "maxReplicas")
+	}
+
+	if len(pa.Spec.MetricsSources) > 0 {
+		result.kept = append(result.kept, "metricsSources")
+	} else {
+		seen := map[string]struct{}{}
+		for _, metric := range hpa.Spec.Metrics {
+			source, err := translateHPAMetric(metric, hpa, pa.Spec.ScalingStrategy)
+			if err != nil {
+				result.skipped = append(result.skipped, err.Error())
+				continue
+			}
+			// the target metrics of the sources must be unique.
+			if _, ok := seen[source.TargetMetric]; ok {
+				result.skipped = append(result.skipped, fmt.Sprintf("%s metric %s (duplicate)", metric.Type, source.TargetMetric))
+				continue
+			}
+			seen[source.TargetMetric] = struct{}{}
+			pa.Spec.MetricsSources = append(pa.Spec.MetricsSources, source)
+			result.imported = append(result.imported, fmt.Sprintf("%s metric %s", metric.Type, source.TargetMetric))
+		}
+	}
+
+	if hpa.Spec.Behavior != nil {
+		translateHPABehavior(hpa.Spec.Behavior, pa, result)
+	}
+	return result
+}
+
+// translateHPAMetric converts the metric spec of an HPA to a metric source of a PodAutoscaler. The error explains
+// why the metric cannot be represented.
+func translateHPAMetric(metric autoscalingv2.MetricSpec, hpa *autoscalingv2.HorizontalPodAutoscaler, strategy autoscalingv1alpha1.ScalingStrategyType) (autoscalingv1alpha1.MetricSource, error) {
+	source := autoscalingv1alpha1.MetricSource{ProtocolType: autoscalingv1alpha1.HTTP}
+	switch metric.Type {
+	case autoscalingv2.ResourceMetricSourceType:
+		if metric.Resource == nil {
+			break
+		}
+		name := string(metric.Resource.Name)
+		// the PodAutoscaler only represents the resource metrics by the HPA it generates.
+		if strategy != autoscalingv1alpha1.HPA {
+			return source, fmt.Errorf("Resource metric %s (only supported by the HPA strategy)", name)
+		}
+		target := metric.Resource.Target
+		source.MetricSourceType = autoscalingv1alpha1.POD
+		source.TargetMetric = name
+		switch {
+		case name == autoscalingv1alpha1.CPU && target.Type == autoscalingv2.UtilizationMetricType && target.AverageUtilization != nil:
+			source.TargetValue = strconv.Itoa(int(*target.AverageUtilization))
+			return source, nil
+		case name == autoscalingv1alpha1.Memory && target.Type == autoscalingv2.AverageValueMetricType && target.AverageValue != nil:
+			// the memory target of the PodAutoscaler is in MiB.
+			source.TargetValue = strconv.FormatInt(target.AverageValue.Value()/(1024*1024), 10)
+			return source, nil
+		}
+		return source, fmt.Errorf("Resource metric %s (%s target)", name, target.Type)
+
+	case autoscalingv2.PodsMetricSourceType:
+		if metric.Pods == nil {
+			break
+		}
+		name := metric.Pods.Metric.Name
+		if metric.Pods.Metric.Selector != nil {
+			return source, fmt.Errorf("Pods metric %s (label selector)", name)
+		}
+		if metric.Pods.Target.AverageValue == nil {
+			return source, fmt.Errorf("Pods metric %s (%s target)", name, metric.Pods.Target.Type)
+		}
+		target := metric.Pods.Target.AverageValue.AsApproximateFloat64()
+		source.MetricSourceType = autoscalingv1alpha1.CUSTOM
+		if strategy == autoscalingv1alpha1.HPA {
+			// the HPA generated by the PodAutoscaler only takes whole pods metric targets.
+			if target != math.Trunc(target) {
+				return source, fmt.Errorf("Pods metric %s (fractional target)", name)
+			}
+			source.MetricSourceType = autoscalingv1alpha1.POD
+		}
+		source.TargetMetric = name
+		source.TargetValue = formatTargetValue(target)
+		return source, nil
+
+	case autoscalingv2.ObjectMetricSourceType:
+		if metric.Object == nil {
+			break
+		}
+		name := metric.Object.Metric.Name
+		// the object sources of the PodAutoscaler read the metric describing the scale target.
+		if strategy == autoscalingv1alpha1.HPA || metric.Object.Metric.Selector != nil ||
+			!sameDescribedObject(metric.Object.DescribedObject, hpa.Spec.ScaleTargetRef) {
+			return source, fmt.Errorf("Object metric %s (only the metric of the scale target without label selector)", name)
+		}
+		if metric.Object.Target.Type != autoscalingv2.ValueMetricType || metric.Object.Target.Value == nil {
+			return source, fmt.Errorf("Object metric %s (%s target)", name, metric.Object.Target.Type)
+		}
+		source.MetricSourceType = autoscalingv1alpha1.OBJECT
+		source.TargetMetric = name
+		source.TargetValue = formatTargetValue(metric.Object.Target.Value.AsApproximateFloat64())
+		return source, nil
+
+	case autoscalingv2.ExternalMetricSourceType:
+		if metric.External == nil {
+			break
+		}
+		name := metric.External.Metric.Name
+		if strategy == autoscalingv1alpha1.HPA || metric.External.Metric.Selector != nil {
+			return source, fmt.Errorf("External metric %s (only without label selector)", name)
+		}
+		if metric.External.Target.Type != autoscalingv2.ValueMetricType || metric.External.Target.Value == nil {
+			return source, fmt.Errorf("External metric %s (%s target)", name, metric.External.Target.Type)
+		}
+		source.MetricSourceType = autoscalingv1alpha1.EXTERNAL
+		source.TargetMetric = name
+		source.TargetValue = formatTargetValue(metric.External.Target.Value.AsApproximateFloat64())
+		return source, nil
+	}
+	return source, fmt.Errorf("%s metric", metric.Type)
+}
+
+// sameDescribedObject returns whether the object described by an Object metric is the scale target of the HPA.
+func sameDescribedObject(object, target autoscalingv2.CrossVersionObjectReference) bool {
+	return object.Kind == target.Kind && object.Name == target.Name
+}
+
+// translateHPABehavior copies the scale down stabilization window and the single percent policies of the HPA
+// behavior into the annotations the PodAutoscaler does not set yet. The scaling rates of the PodAutoscaler apply per
+// scaling step rather than per policy period.
+func translateHPABehavior(behavior *autoscalingv2.HorizontalPodAutoscalerBehavior, pa *autoscalingv1alpha1.PodAutoscaler, result *hpaImport) {
+	setAnnotation := func(key, value, setting string) {
+		if _, ok := pa.Annotations[key]; ok {
+			result.kept = append(result.kept, setting)
+			return
+		}
+		if pa.Annotations == nil {
+			pa.Annotations = map[string]string{}
+		}
+		pa.Annotations[key] = value
+		result.imported = append(result.imported, setting)
+	}
+
+	if rules := behavior.ScaleUp; rules != nil {
+		if rules.StabilizationWindowSeconds != nil && *rules.StabilizationWindowSeconds > 0 {
+			result.skipped = append(result.skipped, "scaleUp stabilization window")
+		}
+		if percent, ok := singlePercentPolicy(rules); ok {
+			setAnnotation(scalingcontext.MaxScaleUpRateLabel, formatRate(1+float64(percent)/100), "scaleUp rate")
+		} else if len(rules.Policies) > 0 {
+			result.skipped = append(result.skipped, "scaleUp policies")
+		}
+	}
+	if rules := behavior.ScaleDown; rules != nil {
+		if rules.StabilizationWindowSeconds != nil {
+			setAnnotation(scalingcontext.ScaleDownStabilizationWindowLabel,
+				fmt.Sprintf("%ds", *rules.StabilizationWindowSeconds), "scaleDown stabilization window")
+		}
+		// a decrease of 100% or more is no limit.
+		if percent, ok := singlePercentPolicy(rules); ok && percent < 100 {
+			setAnnotation(scalingcontext.MaxScaleDownRateLabel, formatRate(100/float64(100-percent)), "scaleDown rate")
+		} else if len(rules.Policies) > 0 {
+			result.skipped = append(result.skipped, "scaleDown policies")
+		}
+	}
+}
+
+// singlePercentPolicy returns the value of the only policy of the rules, if it is a percent policy.
+func singlePercentPolicy(rules *autoscalingv2.HPAScalingRules) (int32, bool) {
+	if rules.SelectPolicy != nil && *rules.SelectPolicy == autoscalingv2.DisabledPolicySelect {
+		return 0, false
+	}
+	if len(rules.Policies) != 1 || rules.Policies[0].Type != autoscalingv2.PercentScalingPolicy {
+		return 0, false
+	}
+	return rules.Policies[0].Value, true
+}
+
+// formatTargetValue formats a metric target as a plain number, which every scaling strategy parses.
+func formatTargetValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatRate formats a scaling rate, rounded to two decimals.
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(math.Round(rate*100)/100, 'f', -1, 64)
+}
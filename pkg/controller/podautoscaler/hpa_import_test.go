@@ -0,0 +1,320 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+// newTestImportedHPA creates an HPA scaling the test deployment, to import into the PodAutoscaler under test.
+func newTestImportedHPA(metrics ...autoscalingv2.MetricSpec) *autoscalingv2.HorizontalPodAutoscaler {
+	minReplicas := int32(2)
+	stabilizationWindow := int32(120)
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy-hpa", Namespace: testNamespace},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: testDeployName},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    6,
+			Metrics:        metrics,
+			Behavior: &autoscalingv2.HorizontalPodAutoscalerBehavior{
+				ScaleUp: &autoscalingv2.HPAScalingRules{
+					Policies: []autoscalingv2.HPAScalingPolicy{{Type: autoscalingv2.PercentScalingPolicy, Value: 100, PeriodSeconds: 15}},
+				},
+				ScaleDown: &autoscalingv2.HPAScalingRules{
+					StabilizationWindowSeconds: &stabilizationWindow,
+					Policies: []autoscalingv2.HPAScalingPolicy{
+						{Type: autoscalingv2.PodsScalingPolicy, Value: 1, PeriodSeconds: 60},
+						{Type: autoscalingv2.PercentScalingPolicy, Value: 10, PeriodSeconds: 60},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newTestPodsMetric(name, averageValue string) autoscalingv2.MetricSpec {
+	value := resource.MustParse(averageValue)
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.PodsMetricSourceType,
+		Pods: &autoscalingv2.PodsMetricSource{
+			Metric: autoscalingv2.MetricIdentifier{Name: name},
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &value},
+		},
+	}
+}
+
+func TestTranslateHPAMetrics(t *testing.T) {
+	cpuUtilization := int32(60)
+	memory := resource.MustParse("512Mi")
+	queueLength := resource.MustParse("30")
+	cpuMetric := autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name:   corev1.ResourceCPU,
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &cpuUtilization},
+		},
+	}
+	memoryMetric := autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name:   corev1.ResourceMemory,
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &memory},
+		},
+	}
+	externalMetric := autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ExternalMetricSourceType,
+		External: &autoscalingv2.ExternalMetricSource{
+			Metric: autoscalingv2.MetricIdentifier{Name: "queue_length"},
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &queueLength},
+		},
+	}
+	selectedMetric := newTestPodsMetric("selected_requests", "10")
+	selectedMetric.Pods.Metric.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"model": "llama"}}
+	containerMetric := autoscalingv2.MetricSpec{Type: autoscalingv2.ContainerResourceMetricSourceType}
+
+	testCases := []struct {
+		name          string
+		strategy      autoscalingv1alpha1.ScalingStrategyType
+		metrics       []autoscalingv2.MetricSpec
+		expectSources []autoscalingv1alpha1.MetricSource
+		expectSkipped int
+	}{
+		{
+			name:     "representable by the KPA",
+			strategy: autoscalingv1alpha1.KPA,
+			metrics:  []autoscalingv2.MetricSpec{newTestPodsMetric("requests", "500m"), externalMetric},
+			expectSources: []autoscalingv1alpha1.MetricSource{
+				{MetricSourceType: autoscalingv1alpha1.CUSTOM, ProtocolType: autoscalingv1alpha1.HTTP, TargetMetric: "requests", TargetValue: "0.5"},
+				{MetricSourceType: autoscalingv1alpha1.EXTERNAL, ProtocolType: autoscalingv1alpha1.HTTP, TargetMetric: "queue_length", TargetValue: "30"},
+			},
+		},
+		{
+			name:     "representable by the HPA",
+			strategy: autoscalingv1alpha1.HPA,
+			metrics:  []autoscalingv2.MetricSpec{cpuMetric, memoryMetric, newTestPodsMetric("requests", "10")},
+			expectSources: []autoscalingv1alpha1.MetricSource{
+				{MetricSourceType: autoscalingv1alpha1.POD, ProtocolType: autoscalingv1alpha1.HTTP, TargetMetric: "cpu", TargetValue: "60"},
+				{MetricSourceType: autoscalingv1alpha1.POD, ProtocolType: autoscalingv1alpha1.HTTP, TargetMetric: "memory", TargetValue: "512"},
+				{MetricSourceType: autoscalingv1alpha1.POD, ProtocolType: autoscalingv1alpha1.HTTP, TargetMetric: "requests", TargetValue: "10"},
+			},
+		},
+		{
+			name:          "unrepresentable",
+			strategy:      autoscalingv1alpha1.KPA,
+			metrics:       []autoscalingv2.MetricSpec{cpuMetric, selectedMetric, containerMetric},
+			expectSkipped: 3,
+		},
+		{
+			name:          "unrepresentable by the HPA",
+			strategy:      autoscalingv1alpha1.HPA,
+			metrics:       []autoscalingv2.MetricSpec{externalMetric, newTestPodsMetric("requests", "500m")},
+			expectSkipped: 2,
+		},
+		{
+			name:     "duplicate",
+			strategy: autoscalingv1alpha1.KPA,
+			metrics:  []autoscalingv2.MetricSpec{newTestPodsMetric("requests", "10"), newTestPodsMetric("requests", "20")},
+			expectSources: []autoscalingv1alpha1.MetricSource{
+				{MetricSourceType: autoscalingv1alpha1.CUSTOM, ProtocolType: autoscalingv1alpha1.HTTP, TargetMetric: "requests", TargetValue: "10"},
+			},
+			expectSkipped: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pa := newTestPodAutoscaler(nil, 0, nil)
+			pa.Spec.MetricsSources = nil
+			pa.Spec.ScalingStrategy = tc.strategy
+			hpa := newTestImportedHPA(tc.metrics...)
+			hpa.Spec.Behavior = nil
+
+			result := translateHPA(hpa, pa)
+			if !reflect.DeepEqual(pa.Spec.MetricsSources, tc.expectSources) {
+				t.Errorf("expected metric sources %+v, got %+v", tc.expectSources, pa.Spec.MetricsSources)
+			}
+			if len(result.skipped) != tc.expectSkipped {
+				t.Errorf("expected %d skipped metrics, got %v", tc.expectSkipped, result.skipped)
+			}
+			if pa.Spec.MinReplicas == nil || *pa.Spec.MinReplicas != 2 || pa.Spec.MaxReplicas != 6 {
+				t.Errorf("expected the replicas range <2, 6>, got <%v, %d>", pa.Spec.MinReplicas, pa.Spec.MaxReplicas)
+			}
+		})
+	}
+}
+
+func TestTranslateHPAKeepsPodAutoscalerSettings(t *testing.T) {
+	minReplicas := int32(1)
+	pa := newTestPodAutoscaler(&minReplicas, 10, map[string]string{scalingcontext.ScaleDownStabilizationWindowLabel: "30s"})
+	hpa := newTestImportedHPA(newTestPodsMetric("requests", "10"))
+
+	result := translateHPA(hpa, pa)
+	if *pa.Spec.MinReplicas != 1 || pa.Spec.MaxReplicas != 10 {
+		t.Errorf("expected the replicas range <1, 10> of the PodAutoscaler, got <%d, %d>", *pa.Spec.MinReplicas, pa.Spec.MaxReplicas)
+	}
+	if len(pa.Spec.MetricsSources) != 1 || pa.Spec.MetricsSources[0].TargetMetric != "test_metric" {
+		t.Errorf("expected the metric sources of the PodAutoscaler, got %+v", pa.Spec.MetricsSources)
+	}
+	if window := pa.Annotations[scalingcontext.ScaleDownStabilizationWindowLabel]; window != "30s" {
+		t.Errorf("expected the stabilization window of the PodAutoscaler, got %q", window)
+	}
+	// the single percent scale up policy is imported, the two scale down policies are not.
+	if rate := pa.Annotations[scalingcontext.MaxScaleUpRateLabel]; rate != "2" {
+		t.Errorf("expected the imported max scale up rate 2, got %q", rate)
+	}
+	if _, ok := pa.Annotations[scalingcontext.MaxScaleDownRateLabel]; ok {
+		t.Errorf("expected no max scale down rate, got %q", pa.Annotations[scalingcontext.MaxScaleDownRateLabel])
+	}
+	if len(result.kept) != 4 || len(result.skipped) != 1 {
+		t.Errorf("expected 4 kept and 1 skipped settings, got kept %v, skipped %v", result.kept, result.skipped)
+	}
+}
+
+func TestReconcileImportFromHPA(t *testing.T) {
+	testCases := []struct {
+		name             string
+		mode             string
+		expectHPADeleted bool
+	}{
+		{name: "copy"},
+		{name: "takeover", mode: "takeover", expectHPADeleted: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{scalingcontext.ImportFromHPALabel: "legacy-hpa"}
+			if tc.mode != "" {
+				annotations[scalingcontext.ImportModeLabel] = tc.mode
+			}
+			pa := newTestPodAutoscaler(nil, 0, annotations)
+			pa.Spec.MetricsSources = nil
+			r, recorder := newTestReconciler(t, newTestDeployment(1), pa, newTestImportedHPA(newTestPodsMetric("requests", "10")))
+
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			pa = getTestPodAutoscaler(t, r)
+			if pa.Spec.MinReplicas == nil || *pa.Spec.MinReplicas != 2 || pa.Spec.MaxReplicas != 6 {
+				t.Errorf("expected the imported replicas range <2, 6>, got <%v, %d>", pa.Spec.MinReplicas, pa.Spec.MaxReplicas)
+			}
+			if len(pa.Spec.MetricsSources) != 1 || pa.Spec.MetricsSources[0].TargetMetric != "requests" {
+				t.Errorf("expected the imported requests metric, got %+v", pa.Spec.MetricsSources)
+			}
+			if window := pa.Annotations[scalingcontext.ScaleDownStabilizationWindowLabel]; window != "120s" {
+				t.Errorf("expected the imported stabilization window 120s, got %q", window)
+			}
+			cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ImportCompleted)
+			if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, "skipped scaleDown policies") {
+				t.Errorf("expected the ImportCompleted condition listing the skipped settings, got %+v", cond)
+			}
+			if count := countEvents(recorder, "ImportCompleted"); count != 1 {
+				t.Errorf("expected one ImportCompleted event, got %d", count)
+			}
+
+			hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+			err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "legacy-hpa"}, hpa)
+			if tc.expectHPADeleted {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("expected the HPA to be deleted, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get HPA: %v", err)
+			}
+			if importedBy := hpa.Annotations[scalingcontext.ImportedByLabel]; importedBy != testPaName {
+				t.Errorf("expected the HPA to be annotated as imported by %s, got %q", testPaName, importedBy)
+			}
+
+			// the import is done once, the PodAutoscaler edited afterwards is not overwritten.
+			pa.Spec.MaxReplicas = 8
+			if err := r.Update(context.Background(), pa); err != nil {
+				t.Fatalf("failed to update PodAutoscaler: %v", err)
+			}
+			if imported, err := r.importFromHPA(context.Background(), pa); imported || err != nil {
+				t.Errorf("expected no second import, got %v, %v", imported, err)
+			}
+		})
+	}
+}
+
+func TestImportFromHPAFailures(t *testing.T) {
+	mismatched := newTestImportedHPA()
+	mismatched.Spec.ScaleTargetRef.Name = "other-deployment"
+	managed := newTestImportedHPA()
+	managed.Labels = map[string]string{HPAManagedByLabelKey: HPAManagedByLabelValue}
+
+	testCases := []struct {
+		name         string
+		hpa          *autoscalingv2.HorizontalPodAutoscaler
+		mode         string
+		expectReason string
+	}{
+		{name: "not found", expectReason: "HPANotFound"},
+		{name: "scale target mismatch", hpa: mismatched, expectReason: "ScaleTargetMismatch"},
+		{name: "managed", hpa: managed, expectReason: "ManagedHPA"},
+		{name: "invalid mode", hpa: newTestImportedHPA(), mode: "move", expectReason: "InvalidImportMode"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{scalingcontext.ImportFromHPALabel: "legacy-hpa"}
+			if tc.mode != "" {
+				annotations[scalingcontext.ImportModeLabel] = tc.mode
+			}
+			objs := []client.Object{newTestPodAutoscaler(nil, 10, annotations)}
+			if tc.hpa != nil {
+				objs = append(objs, tc.hpa)
+			}
+			r, recorder := newTestReconciler(t, objs...)
+
+			// the failure is reported once, and the import is retried on the next reconcile.
+			for i := 0; i < 2; i++ {
+				if imported, err := r.importFromHPA(context.Background(), getTestPodAutoscaler(t, r)); imported || err != nil {
+					t.Fatalf("expected no import, got %v, %v", imported, err)
+				}
+			}
+			pa := getTestPodAutoscaler(t, r)
+			cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ImportCompleted)
+			if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != tc.expectReason {
+				t.Errorf("expected the ImportCompleted condition False with reason %s, got %+v", tc.expectReason, cond)
+			}
+			if pa.Spec.MinReplicas != nil || pa.Spec.MaxReplicas != 10 {
+				t.Errorf("expected the PodAutoscaler unchanged, got <%v, %d>", pa.Spec.MinReplicas, pa.Spec.MaxReplicas)
+			}
+			if count := countEvents(recorder, "ImportFailed"); count != 1 {
+				t.Errorf("expected one ImportFailed event, got %d", count)
+			}
+		})
+	}
+}
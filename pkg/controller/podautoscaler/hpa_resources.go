@@ -17,40 +17,68 @@ limitations under the License.
 package podautoscaler
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	pav1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
 )
 
-var (
-	controllerKind = pav1.GroupVersion.WithKind("PodAutoscaler") // Define the resource type for the controller
+const (
+	// hpaScalingPolicyPeriodSeconds is the period of the scaling policies of the generated HPAs, the default period
+	// of the policies of the API server.
+	hpaScalingPolicyPeriodSeconds = 15
+	// hpaMaxStabilizationWindow is the longest stabilization window an HPA accepts.
+	hpaMaxStabilizationWindow = time.Hour
+
+	// HPAManagedByLabelKey and HPAManagedByLabelValue mark the HPAs generated by the PodAutoscaler controller,
+	// a pre-existing HPA is only adopted if it carries the label.
+	HPAManagedByLabelKey   = "app.kubernetes.io/managed-by"
+	HPAManagedByLabelValue = "aibrix"
 )
 
-// MakeHPA creates an HPA resource from a PodAutoscaler resource.
-func makeHPA(pa *pav1.PodAutoscaler) *autoscalingv2.HorizontalPodAutoscaler {
+// hpaName returns the name of the HPA generated for the PodAutoscaler.
+func hpaName(pa *pav1.PodAutoscaler) string {
+	return fmt.Sprintf("%s-hpa", pa.Name)
+}
+
+// isManagedHPA returns whether the HPA is labeled as generated by the PodAutoscaler controller.
+func isManagedHPA(hpa *autoscalingv2.HorizontalPodAutoscaler) bool {
+	return hpa.Labels[HPAManagedByLabelKey] == HPAManagedByLabelValue
+}
+
+// MakeHPA creates an HPA resource from a PodAutoscaler resource. The PodAutoscaler is set as its controller
+// owner by the reconciler. An error is returned if a metric source or a scaling annotation of the PodAutoscaler
+// cannot be represented by the HPA, so that the HPA never scales on metrics other than the configured ones.
+func makeHPA(pa *pav1.PodAutoscaler) (*autoscalingv2.HorizontalPodAutoscaler, error) {
 	minReplicas, maxReplicas := pa.Spec.MinReplicas, pa.Spec.MaxReplicas
 	// TODO: add some validation logics, has to be larger than minReplicas
 	if maxReplicas == 0 {
 		maxReplicas = math.MaxInt32 // Set default to no upper limit if not specified
 	}
+	labels := make(map[string]string, len(pa.Labels)+1)
+	for k, v := range pa.Labels {
+		labels[k] = v
+	}
+	labels[HPAManagedByLabelKey] = HPAManagedByLabelValue
 	hpa := &autoscalingv2.HorizontalPodAutoscaler{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        fmt.Sprintf("%s-hpa", pa.Name),
+			Name:        hpaName(pa),
 			Namespace:   pa.Namespace,
-			Labels:      pa.Labels,
+			Labels:      labels,
 			Annotations: pa.Annotations,
-			OwnerReferences: []metav1.OwnerReference{
-				*metav1.NewControllerRef(pa, controllerKind),
-			},
 		},
 		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
 			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
@@ -64,60 +92,281 @@ func makeHPA(pa *pav1.PodAutoscaler) *autoscalingv2.HorizontalPodAutoscaler {
 	if minReplicas != nil && *minReplicas > 0 {
 		hpa.Spec.MinReplicas = minReplicas
 	}
-	source, err := pav1.GetPaMetricSources(*pa)
+	if len(pa.Spec.MetricsSources) == 0 {
+		return nil, fmt.Errorf("no metric sources")
+	}
+
+	// the HPA scales to the highest replica count recommended by its metrics.
+	var errs []error
+	for _, source := range pa.Spec.MetricsSources {
+		metric, err := makeHPAMetricSpec(source, hpa.Spec.ScaleTargetRef)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		hpa.Spec.Metrics = append(hpa.Spec.Metrics, metric)
+	}
+	behavior, err := makeHPABehavior(pa)
 	if err != nil {
-		klog.ErrorS(err, "Failed to GetPaMetricSources")
-		return nil
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	hpa.Spec.Behavior = behavior
+	return hpa, nil
+}
+
+// hpaMinReplicas returns the min replicas of the HPA spec, 1 if not set as defaulted by the API server.
+func hpaMinReplicas(spec *autoscalingv2.HorizontalPodAutoscalerSpec) int32 {
+	if spec.MinReplicas == nil {
+		return 1
+	}
+	return *spec.MinReplicas
+}
+
+// mergeHPA applies the labels, annotations and spec fields the PodAutoscaler controller manages from the desired
+// HPA to the existing one, and returns whether the existing HPA changed. The labels and annotations added by other
+// tooling, and the spec fields the controller does not set, like the scaling behavior defaulted by the API server
+// for the rules without annotations, are preserved.
+func mergeHPA(existing, desired *autoscalingv2.HorizontalPodAutoscaler) bool {
+	changed := false
+	mergeMap := func(existing *map[string]string, desired map[string]string) {
+		for k, v := range desired {
+			if value, ok := (*existing)[k]; ok && value == v {
+				continue
+			}
+			if *existing == nil {
+				*existing = make(map[string]string, len(desired))
+			}
+			(*existing)[k] = v
+			changed = true
+		}
 	}
+	mergeMap(&existing.Labels, desired.Labels)
+	mergeMap(&existing.Annotations, desired.Annotations)
 
-	if targetValue, err := strconv.ParseFloat(source.TargetValue, 64); err != nil {
-		klog.ErrorS(err, "Failed to parse target value")
+	if existing.Spec.ScaleTargetRef != desired.Spec.ScaleTargetRef {
+		existing.Spec.ScaleTargetRef = desired.Spec.ScaleTargetRef
+		changed = true
+	}
+	if hpaMinReplicas(&existing.Spec) != hpaMinReplicas(&desired.Spec) {
+		existing.Spec.MinReplicas = desired.Spec.MinReplicas
+		changed = true
+	}
+	if existing.Spec.MaxReplicas != desired.Spec.MaxReplicas {
+		existing.Spec.MaxReplicas = desired.Spec.MaxReplicas
+		changed = true
+	}
+	if !apiequality.Semantic.DeepEqual(existing.Spec.Metrics, desired.Spec.Metrics) {
+		existing.Spec.Metrics = desired.Spec.Metrics
+		changed = true
+	}
+	if desired.Spec.Behavior != nil {
+		if existing.Spec.Behavior == nil {
+			existing.Spec.Behavior = &autoscalingv2.HorizontalPodAutoscalerBehavior{}
+		}
+		if mergeHPAScalingRules(&existing.Spec.Behavior.ScaleUp, desired.Spec.Behavior.ScaleUp) {
+			changed = true
+		}
+		if mergeHPAScalingRules(&existing.Spec.Behavior.ScaleDown, desired.Spec.Behavior.ScaleDown) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// mergeHPAScalingRules applies the fields of the desired scaling rules set from the annotations of the PodAutoscaler
+// to the existing rules, and returns whether they changed.
+func mergeHPAScalingRules(existing **autoscalingv2.HPAScalingRules, desired *autoscalingv2.HPAScalingRules) bool {
+	if desired == nil {
+		return false
+	}
+	if *existing == nil {
+		*existing = desired.DeepCopy()
+		return true
+	}
+	changed := false
+	if desired.StabilizationWindowSeconds != nil &&
+		!apiequality.Semantic.DeepEqual((*existing).StabilizationWindowSeconds, desired.StabilizationWindowSeconds) {
+		(*existing).StabilizationWindowSeconds = desired.StabilizationWindowSeconds
+		changed = true
+	}
+	if desired.SelectPolicy != nil && !apiequality.Semantic.DeepEqual((*existing).SelectPolicy, desired.SelectPolicy) {
+		(*existing).SelectPolicy = desired.SelectPolicy
+		changed = true
+	}
+	if desired.Policies != nil && !apiequality.Semantic.DeepEqual((*existing).Policies, desired.Policies) {
+		(*existing).Policies = desired.Policies
+		changed = true
+	}
+	return changed
+}
+
+// makeHPAMetricSpec converts a metric source of a PodAutoscaler to the metric spec of an HPA:
+//   - the cpu and memory of the pods are resource metrics. A target with a % suffix is an average utilization of
+//     the requests, a quantity with a unit, e.g. 500m or 2Gi, an average value. A plain number is a cpu utilization
+//     or a memory in MiB.
+//   - the other metrics of the pods, scraped or read from the custom metrics API, are pods metrics.
+//   - the object metrics describe the scale target, and the external metrics are read from the external metrics API.
+//
+// The domain sources are scraped by the controller, which the HPA cannot do, so they are rejected.
+func makeHPAMetricSpec(source pav1.MetricSource, scaleTargetRef autoscalingv2.CrossVersionObjectReference) (autoscalingv2.MetricSpec, error) {
+	klog.V(4).InfoS("Creating HPA metric", "metric", source.TargetMetric, "source", source.MetricSourceType, "target", source.TargetValue)
+	name := source.TargetMetric
+	switch source.MetricSourceType {
+	case pav1.POD, pav1.CUSTOM:
+		if source.MetricSourceType == pav1.POD {
+			switch strings.ToLower(name) {
+			case pav1.CPU:
+				return makeHPAResourceMetricSpec(corev1.ResourceCPU, source.TargetValue)
+			case pav1.Memory:
+				return makeHPAResourceMetricSpec(corev1.ResourceMemory, source.TargetValue)
+			}
+		}
+		target, err := parseHPATargetQuantity(source)
+		if err != nil {
+			return autoscalingv2.MetricSpec{}, err
+		}
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: name},
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &target},
+			},
+		}, nil
+
+	case pav1.OBJECT:
+		target, err := parseHPATargetQuantity(source)
+		if err != nil {
+			return autoscalingv2.MetricSpec{}, err
+		}
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ObjectMetricSourceType,
+			Object: &autoscalingv2.ObjectMetricSource{
+				DescribedObject: scaleTargetRef,
+				Metric:          autoscalingv2.MetricIdentifier{Name: name},
+				Target:          autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &target},
+			},
+		}, nil
+
+	case pav1.EXTERNAL:
+		target, err := parseHPATargetQuantity(source)
+		if err != nil {
+			return autoscalingv2.MetricSpec{}, err
+		}
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ExternalMetricSourceType,
+			External: &autoscalingv2.ExternalMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: name},
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &target},
+			},
+		}, nil
+	}
+	return autoscalingv2.MetricSpec{}, fmt.Errorf("metric %s: %s sources cannot be read by the HPA", name, source.MetricSourceType)
+}
+
+// makeHPAResourceMetricSpec converts the target of a cpu or memory metric source to a resource metric.
+func makeHPAResourceMetricSpec(name corev1.ResourceName, value string) (autoscalingv2.MetricSpec, error) {
+	metric := autoscalingv2.MetricSpec{
+		Type:     autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{Name: name},
+	}
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		utilization, err := strconv.ParseInt(percent, 10, 32)
+		if err != nil || utilization <= 0 {
+			return metric, fmt.Errorf("metric %s: invalid utilization target %q, it must be a positive whole percentage", name, value)
+		}
+		metric.Resource.Target = autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: ptr.To(int32(utilization))}
+		return metric, nil
+	}
+
+	var averageValue *resource.Quantity
+	if targetValue, err := strconv.ParseFloat(value, 64); err == nil {
+		if targetValue <= 0 || math.IsInf(targetValue, 0) || math.IsNaN(targetValue) {
+			return metric, fmt.Errorf("metric %s: invalid target %q, it must be positive", name, value)
+		}
+		// a plain number is the cpu utilization, or the memory in MiB.
+		if name == corev1.ResourceCPU {
+			metric.Resource.Target = autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: ptr.To(int32(math.Ceil(targetValue))),
+			}
+			return metric, nil
+		}
+		averageValue = resource.NewQuantity(int64(math.Round(targetValue*1024*1024)), resource.BinarySI)
 	} else {
-		klog.V(4).InfoS("Creating HPA", "metric", source.TargetMetric, "target", targetValue)
-
-		switch strings.ToLower(source.TargetMetric) {
-		case pav1.CPU:
-			cpu := int32(math.Ceil(targetValue))
-			hpa.Spec.Metrics = []autoscalingv2.MetricSpec{{
-				Type: autoscalingv2.ResourceMetricSourceType,
-				Resource: &autoscalingv2.ResourceMetricSource{
-					Name: corev1.ResourceCPU,
-					Target: autoscalingv2.MetricTarget{
-						Type:               autoscalingv2.UtilizationMetricType,
-						AverageUtilization: &cpu,
-					},
-				},
-			}}
-
-		case pav1.Memory:
-			memory := resource.NewQuantity(int64(targetValue)*1024*1024, resource.BinarySI)
-			hpa.Spec.Metrics = []autoscalingv2.MetricSpec{{
-				Type: autoscalingv2.ResourceMetricSourceType,
-				Resource: &autoscalingv2.ResourceMetricSource{
-					Name: corev1.ResourceMemory,
-					Target: autoscalingv2.MetricTarget{
-						Type:         autoscalingv2.AverageValueMetricType,
-						AverageValue: memory,
-					},
-				},
-			}}
-
-		default:
-			targetQuantity := resource.NewQuantity(int64(targetValue), resource.DecimalSI)
-			hpa.Spec.Metrics = []autoscalingv2.MetricSpec{{
-				Type: autoscalingv2.PodsMetricSourceType,
-				Pods: &autoscalingv2.PodsMetricSource{
-					Metric: autoscalingv2.MetricIdentifier{
-						Name: source.TargetMetric,
-					},
-					Target: autoscalingv2.MetricTarget{
-						Type:         autoscalingv2.AverageValueMetricType,
-						AverageValue: targetQuantity,
-					},
-				},
-			}}
-		}
-	}
-
-	return hpa
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil || quantity.Sign() <= 0 {
+			return metric, fmt.Errorf("metric %s: invalid target %q, it must be a percentage, a number or a positive quantity", name, value)
+		}
+		averageValue = &quantity
+	}
+	metric.Resource.Target = autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: averageValue}
+	return metric, nil
+}
+
+// parseHPATargetQuantity parses the target value of a metric source as the quantity of an HPA metric target,
+// fractional targets are kept as milli quantities.
+func parseHPATargetQuantity(source pav1.MetricSource) (resource.Quantity, error) {
+	quantity, err := resource.ParseQuantity(source.TargetValue)
+	if err != nil || quantity.Sign() <= 0 {
+		return resource.Quantity{}, fmt.Errorf("metric %s: invalid target %q, it must be a positive number or quantity", source.TargetMetric, source.TargetValue)
+	}
+	return quantity, nil
+}
+
+// makeHPABehavior derives the scaling behavior of the HPA from the annotations the other strategies scale with: the
+// max scale up and scale down rates become percent policies per hpaScalingPolicyPeriodSeconds, and the scale down
+// stabilization window the stabilization window of the scale down rules. Without the annotations the behavior is
+// left to the defaults of the API server.
+func makeHPABehavior(pa *pav1.PodAutoscaler) (*autoscalingv2.HorizontalPodAutoscalerBehavior, error) {
+	behavior := &autoscalingv2.HorizontalPodAutoscalerBehavior{}
+	if value, ok := pa.Annotations[scalingcontext.MaxScaleUpRateLabel]; ok {
+		rate, err := scalingcontext.ParseScaleRate(scalingcontext.MaxScaleUpRateLabel, value)
+		if err != nil {
+			return nil, err
+		}
+		// the replicas increase by up to rate-1 times per period.
+		behavior.ScaleUp = makeHPAPercentRules((rate - 1) * 100)
+	}
+	if value, ok := pa.Annotations[scalingcontext.MaxScaleDownRateLabel]; ok {
+		rate, err := scalingcontext.ParseScaleRate(scalingcontext.MaxScaleDownRateLabel, value)
+		if err != nil {
+			return nil, err
+		}
+		// the replicas decrease to no less than 1/rate per period.
+		behavior.ScaleDown = makeHPAPercentRules(100 - 100/rate)
+	}
+	if value, ok := pa.Annotations[scalingcontext.ScaleDownStabilizationWindowLabel]; ok {
+		window, err := time.ParseDuration(value)
+		if err != nil || window < 0 || window > hpaMaxStabilizationWindow {
+			return nil, fmt.Errorf("invalid %s %q: the HPA takes a duration of at most %s",
+				scalingcontext.ScaleDownStabilizationWindowLabel, value, hpaMaxStabilizationWindow)
+		}
+		if behavior.ScaleDown == nil {
+			behavior.ScaleDown = &autoscalingv2.HPAScalingRules{}
+		}
+		behavior.ScaleDown.StabilizationWindowSeconds = ptr.To(int32(window.Seconds()))
+	}
+	if behavior.ScaleUp == nil && behavior.ScaleDown == nil {
+		return nil, nil
+	}
+	return behavior, nil
+}
+
+// makeHPAPercentRules returns the scaling rules of a single percent policy, a percent which rounds to zero disables
+// the scaling in the direction as a rate of 1 does.
+func makeHPAPercentRules(percent float64) *autoscalingv2.HPAScalingRules {
+	value := int32(math.Round(percent))
+	if value <= 0 {
+		return &autoscalingv2.HPAScalingRules{SelectPolicy: ptr.To(autoscalingv2.DisabledPolicySelect)}
+	}
+	return &autoscalingv2.HPAScalingRules{
+		Policies: []autoscalingv2.HPAScalingPolicy{{
+			Type:          autoscalingv2.PercentScalingPolicy,
+			Value:         value,
+			PeriodSeconds: hpaScalingPolicyPeriodSeconds,
+		}},
+	}
 }
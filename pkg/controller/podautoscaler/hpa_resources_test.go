@@ -0,0 +1,481 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func TestReconcileHPAUpdatesOnlyOnDrift(t *testing.T) {
+	updates := 0
+	funcs := interceptor.Funcs{
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if _, ok := obj.(*autoscalingv2.HorizontalPodAutoscaler); ok {
+				updates++
+			}
+			return c.Update(ctx, obj, opts...)
+		},
+	}
+	r, _ := newTestReconcilerWithInterceptor(t, funcs, newTestDeployment(1), newTestHPAPodAutoscaler())
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	// another tool annotates the HPA.
+	hpa, err := getTestHPA(r)
+	if err != nil {
+		t.Fatalf("failed to get HPA: %v", err)
+	}
+	hpa.Annotations = map[string]string{"example.com/owner": "team-a"}
+	if err := r.Update(context.Background(), hpa); err != nil {
+		t.Fatalf("failed to update HPA: %v", err)
+	}
+	updates = 0
+
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if updates != 0 {
+		t.Errorf("expected no HPA update without a spec change, got %d", updates)
+	}
+
+	pa := getTestPodAutoscaler(t, r)
+	pa.Spec.MaxReplicas = 20
+	if err := r.Update(context.Background(), pa); err != nil {
+		t.Fatalf("failed to update PodAutoscaler: %v", err)
+	}
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if updates != 1 {
+		t.Errorf("expected one HPA update after the spec change, got %d", updates)
+	}
+	if hpa, err = getTestHPA(r); err != nil {
+		t.Fatalf("failed to get HPA: %v", err)
+	}
+	if hpa.Spec.MaxReplicas != 20 {
+		t.Errorf("expected max replicas 20, got %d", hpa.Spec.MaxReplicas)
+	}
+	if hpa.Annotations["example.com/owner"] != "team-a" {
+		t.Errorf("expected the annotation of the other tool to be preserved, got %v", hpa.Annotations)
+	}
+}
+
+func TestMakeHPAMetricSpec(t *testing.T) {
+	scaleTargetRef := autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: testDeployName}
+	utilization := func(name corev1.ResourceName, value int32) autoscalingv2.MetricSpec {
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name:   name,
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &value},
+			},
+		}
+	}
+	averageValue := func(name corev1.ResourceName, value string) autoscalingv2.MetricSpec {
+		quantity := resource.MustParse(value)
+		return autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name:   name,
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &quantity},
+			},
+		}
+	}
+	targetValue := resource.MustParse("30")
+	testCases := []struct {
+		name         string
+		sourceType   autoscalingv1alpha1.MetricSourceType
+		targetMetric string
+		targetValue  string
+		expected     autoscalingv2.MetricSpec
+		expectErr    bool
+	}{
+		{
+			name:         "cpu utilization",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "cpu",
+			targetValue:  "60%",
+			expected:     utilization(corev1.ResourceCPU, 60),
+		},
+		{
+			name:         "cpu utilization as a plain number",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "cpu",
+			targetValue:  "59.5",
+			expected:     utilization(corev1.ResourceCPU, 60),
+		},
+		{
+			name:         "cpu value",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "cpu",
+			targetValue:  "500m",
+			expected:     averageValue(corev1.ResourceCPU, "500m"),
+		},
+		{
+			name:         "memory utilization",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "memory",
+			targetValue:  "80%",
+			expected:     utilization(corev1.ResourceMemory, 80),
+		},
+		{
+			name:         "memory in MiB",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "memory",
+			targetValue:  "512",
+			expected:     averageValue(corev1.ResourceMemory, "512Mi"),
+		},
+		{
+			name:         "memory value",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "memory",
+			targetValue:  "2Gi",
+			expected:     averageValue(corev1.ResourceMemory, "2Gi"),
+		},
+		{
+			name:         "scraped pods metric",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "vllm:num_requests_waiting",
+			targetValue:  "0.5",
+			expected:     newTestPodsMetric("vllm:num_requests_waiting", "500m"),
+		},
+		{
+			name:         "custom pods metric",
+			sourceType:   autoscalingv1alpha1.CUSTOM,
+			targetMetric: "queue_length",
+			targetValue:  "30",
+			expected:     newTestPodsMetric("queue_length", "30"),
+		},
+		{
+			name:         "object metric",
+			sourceType:   autoscalingv1alpha1.OBJECT,
+			targetMetric: "requests_per_second",
+			targetValue:  "30",
+			expected: autoscalingv2.MetricSpec{
+				Type: autoscalingv2.ObjectMetricSourceType,
+				Object: &autoscalingv2.ObjectMetricSource{
+					DescribedObject: scaleTargetRef,
+					Metric:          autoscalingv2.MetricIdentifier{Name: "requests_per_second"},
+					Target:          autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &targetValue},
+				},
+			},
+		},
+		{
+			name:         "external metric",
+			sourceType:   autoscalingv1alpha1.EXTERNAL,
+			targetMetric: "queue_messages_ready",
+			targetValue:  "30",
+			expected: autoscalingv2.MetricSpec{
+				Type: autoscalingv2.ExternalMetricSourceType,
+				External: &autoscalingv2.ExternalMetricSource{
+					Metric: autoscalingv2.MetricIdentifier{Name: "queue_messages_ready"},
+					Target: autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &targetValue},
+				},
+			},
+		},
+		{
+			name:         "domain metric",
+			sourceType:   autoscalingv1alpha1.DOMAIN,
+			targetMetric: "queue_length",
+			targetValue:  "30",
+			expectErr:    true,
+		},
+		{
+			name:         "fractional utilization",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "cpu",
+			targetValue:  "60.5%",
+			expectErr:    true,
+		},
+		{
+			name:         "negative target",
+			sourceType:   autoscalingv1alpha1.CUSTOM,
+			targetMetric: "queue_length",
+			targetValue:  "-1",
+			expectErr:    true,
+		},
+		{
+			name:         "unparseable target",
+			sourceType:   autoscalingv1alpha1.POD,
+			targetMetric: "memory",
+			targetValue:  "a lot",
+			expectErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := autoscalingv1alpha1.MetricSource{
+				MetricSourceType: tc.sourceType,
+				TargetMetric:     tc.targetMetric,
+				TargetValue:      tc.targetValue,
+			}
+			metric, err := makeHPAMetricSpec(source, scaleTargetRef)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", metric)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !apiequality.Semantic.DeepEqual(metric, tc.expected) {
+				t.Errorf("expected metric %+v, got %+v", tc.expected, metric)
+			}
+		})
+	}
+}
+
+func TestMakeHPABehavior(t *testing.T) {
+	percentRules := func(value int32) *autoscalingv2.HPAScalingRules {
+		return &autoscalingv2.HPAScalingRules{Policies: []autoscalingv2.HPAScalingPolicy{
+			{Type: autoscalingv2.PercentScalingPolicy, Value: value, PeriodSeconds: hpaScalingPolicyPeriodSeconds},
+		}}
+	}
+	window := int32(120)
+	disabled := autoscalingv2.DisabledPolicySelect
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    *autoscalingv2.HorizontalPodAutoscalerBehavior
+		expectErr   bool
+	}{
+		{
+			name: "defaulted by the API server",
+		},
+		{
+			name: "rates and stabilization window",
+			annotations: map[string]string{
+				scalingcontext.MaxScaleUpRateLabel:               "3",
+				scalingcontext.MaxScaleDownRateLabel:             "2",
+				scalingcontext.ScaleDownStabilizationWindowLabel: "2m",
+			},
+			expected: &autoscalingv2.HorizontalPodAutoscalerBehavior{
+				ScaleUp: percentRules(200),
+				ScaleDown: &autoscalingv2.HPAScalingRules{
+					StabilizationWindowSeconds: &window,
+					Policies:                   percentRules(50).Policies,
+				},
+			},
+		},
+		{
+			name:        "rate of one",
+			annotations: map[string]string{scalingcontext.MaxScaleDownRateLabel: "1"},
+			expected: &autoscalingv2.HorizontalPodAutoscalerBehavior{
+				ScaleDown: &autoscalingv2.HPAScalingRules{SelectPolicy: &disabled},
+			},
+		},
+		{
+			name:        "invalid rate",
+			annotations: map[string]string{scalingcontext.MaxScaleUpRateLabel: "0.5"},
+			expectErr:   true,
+		},
+		{
+			name:        "stabilization window beyond the HPA limit",
+			annotations: map[string]string{scalingcontext.ScaleDownStabilizationWindowLabel: "2h"},
+			expectErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pa := newTestHPAPodAutoscaler()
+			pa.Annotations = tc.annotations
+			behavior, err := makeHPABehavior(pa)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", behavior)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(behavior, tc.expected) {
+				t.Errorf("expected behavior %+v, got %+v", tc.expected, behavior)
+			}
+		})
+	}
+}
+
+func TestReconcileHPARejectsUntranslatableMetrics(t *testing.T) {
+	pa := newTestHPAPodAutoscaler()
+	pa.Generation = 1
+	pa.Spec.MetricsSources[0].MetricSourceType = autoscalingv1alpha1.DOMAIN
+	r, recorder := newTestReconciler(t, newTestDeployment(1), pa)
+
+	for i := 0; i < 2; i++ {
+		if err := reconcileTestPodAutoscaler(t, r); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+	}
+	if _, err := getTestHPA(r); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no HPA for untranslatable metrics, got %v", err)
+	}
+	pa = getTestPodAutoscaler(t, r)
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.InvalidHPAMetrics)
+	if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, "domain sources cannot be read by the HPA") {
+		t.Fatalf("expected the InvalidHPAMetrics condition, got %+v", cond)
+	}
+	if count := countEvents(recorder, "InvalidHPAMetrics"); count != 1 {
+		t.Errorf("expected one InvalidHPAMetrics event, got %d", count)
+	}
+
+	// the HPA is generated with the scaling behavior once the metric source is fixed.
+	pa.Generation = 2
+	pa.Spec.MetricsSources[0].MetricSourceType = autoscalingv1alpha1.CUSTOM
+	pa.Annotations = map[string]string{scalingcontext.MaxScaleUpRateLabel: "2"}
+	if err := r.Update(context.Background(), pa); err != nil {
+		t.Fatalf("failed to update PodAutoscaler: %v", err)
+	}
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	hpa, err := getTestHPA(r)
+	if err != nil {
+		t.Fatalf("failed to get HPA: %v", err)
+	}
+	if expected := []autoscalingv2.MetricSpec{newTestPodsMetric("test_metric", "1")}; !apiequality.Semantic.DeepEqual(hpa.Spec.Metrics, expected) {
+		t.Errorf("expected metrics %+v, got %+v", expected, hpa.Spec.Metrics)
+	}
+	if hpa.Spec.Behavior == nil || hpa.Spec.Behavior.ScaleUp == nil || hpa.Spec.Behavior.ScaleUp.Policies[0].Value != 100 {
+		t.Errorf("expected a scale up policy of 100%%, got %+v", hpa.Spec.Behavior)
+	}
+	if cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.InvalidHPAMetrics); cond != nil {
+		t.Errorf("expected the InvalidHPAMetrics condition to be removed, got %+v", cond)
+	}
+}
+
+// newTestHPAPodAutoscaler creates a PodAutoscaler delegating the scaling of the test deployment to an HPA.
+func newTestHPAPodAutoscaler() *autoscalingv1alpha1.PodAutoscaler {
+	pa := newTestPodAutoscaler(nil, 10, nil)
+	pa.UID = "test-pa-uid"
+	pa.Spec.ScalingStrategy = autoscalingv1alpha1.HPA
+	return pa
+}
+
+func getTestHPA(r *PodAutoscalerReconciler) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testPaName + "-hpa"}, hpa)
+	return hpa, err
+}
+
+func TestReconcileHPAOwnership(t *testing.T) {
+	r, recorder := newTestReconciler(t, newTestDeployment(0), newTestHPAPodAutoscaler())
+
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	hpa, err := getTestHPA(r)
+	if err != nil {
+		t.Fatalf("failed to get HPA: %v", err)
+	}
+	pa := getTestPodAutoscaler(t, r)
+	if !metav1.IsControlledBy(hpa, pa) {
+		t.Errorf("expected the PodAutoscaler to be the controller owner of the HPA, got %+v", hpa.OwnerReferences)
+	}
+	if !isManagedHPA(hpa) {
+		t.Errorf("expected the HPA to be labeled as managed by aibrix, got %v", hpa.Labels)
+	}
+
+	// switching the scaling strategy hands the scale target over to the KPA.
+	pa.Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+	if err := r.Update(context.Background(), pa); err != nil {
+		t.Fatalf("failed to update PodAutoscaler: %v", err)
+	}
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if _, err := getTestHPA(r); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the HPA to be deleted, got %v", err)
+	}
+	if count := countEvents(recorder, "HPADeleted"); count != 1 {
+		t.Errorf("expected one HPADeleted event, got %d", count)
+	}
+}
+
+func TestReconcileHPAExisting(t *testing.T) {
+	testCases := []struct {
+		name            string
+		labels          map[string]string
+		expectAdopted   bool
+		expectConflicts int
+	}{
+		{
+			name:            "created by the user",
+			expectConflicts: 1,
+		},
+		{
+			name:          "managed by aibrix",
+			labels:        map[string]string{HPAManagedByLabelKey: HPAManagedByLabelValue},
+			expectAdopted: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			existing := &autoscalingv2.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: testPaName + "-hpa", Namespace: testNamespace, Labels: tc.labels},
+				Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+					ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: testDeployName},
+					MaxReplicas:    5,
+				},
+			}
+			r, recorder := newTestReconciler(t, newTestDeployment(1), newTestHPAPodAutoscaler(), existing)
+
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			hpa, err := getTestHPA(r)
+			if err != nil {
+				t.Fatalf("failed to get HPA: %v", err)
+			}
+			if adopted := metav1.IsControlledBy(hpa, getTestPodAutoscaler(t, r)); adopted != tc.expectAdopted {
+				t.Errorf("expected adopted %v, got %v", tc.expectAdopted, adopted)
+			}
+			expectedMaxReplicas := int32(5)
+			if tc.expectAdopted {
+				expectedMaxReplicas = 10
+			}
+			if hpa.Spec.MaxReplicas != expectedMaxReplicas {
+				t.Errorf("expected max replicas %d, got %d", expectedMaxReplicas, hpa.Spec.MaxReplicas)
+			}
+			if count := countEvents(recorder, "HPAConflict"); count != tc.expectConflicts {
+				t.Errorf("expected %d HPAConflict events, got %d", tc.expectConflicts, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+func (r *PodAutoscalerReconciler) getScaler(metricKey metrics.NamespaceNameMetric) (scaler.Scaler, bool) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	autoScaler, ok := r.AutoscalerMap[metricKey]
+	return autoScaler, ok
+}
+
+func (r *PodAutoscalerReconciler) setScaler(metricKey metrics.NamespaceNameMetric, autoScaler scaler.Scaler) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	r.AutoscalerMap[metricKey] = autoScaler
+}
+
+// deleteRemovedMetricScalers drops the scalers of the PodAutoscaler which do not belong to its metric targets, e.g.
+// after a metric source was removed from its spec.
+func (r *PodAutoscalerReconciler) deleteRemovedMetricScalers(pa *autoscalingv1alpha1.PodAutoscaler, targets []metricTarget) {
+	keep := make(map[metrics.NamespaceNameMetric]struct{}, len(targets))
+	for _, target := range targets {
+		keep[target.key] = struct{}{}
+	}
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	for metricKey := range r.AutoscalerMap {
+		if metricKey.PaNamespace != pa.Namespace || metricKey.PaName != pa.Name {
+			continue
+		}
+		if _, ok := keep[metricKey]; !ok {
+			klog.InfoS("Delete the scaler of a removed metric", "PodAutoscaler", klog.KObj(pa), "metric", metricKey.MetricName)
+			delete(r.AutoscalerMap, metricKey)
+			deleteRemovedMetricValues(pa.Namespace, pa.Name, metricKey.MetricName)
+		}
+	}
+}
+
+// trackedPodAutoscalers returns the PodAutoscalers which have in-memory state.
+func (r *PodAutoscalerReconciler) trackedPodAutoscalers() map[types.NamespacedName]struct{} {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	tracked := make(map[types.NamespacedName]struct{}, len(r.AutoscalerMap)+len(r.recommendations)+len(r.scaleDecisions)+len(r.metricsFailures))
+	for metricKey := range r.AutoscalerMap {
+		tracked[types.NamespacedName{Namespace: metricKey.PaNamespace, Name: metricKey.PaName}] = struct{}{}
+	}
+	for key := range r.recommendations {
+		tracked[key] = struct{}{}
+	}
+	for key := range r.scaleDecisions {
+		tracked[key] = struct{}{}
+	}
+	for key := range r.metricsFailures {
+		tracked[key] = struct{}{}
+	}
+	return tracked
+}
+
+// collectGarbage drops the in-memory state of the PodAutoscalers which are no longer listed. The NotFound branch of
+// Reconcile already cleans up after a deletion, the janitor catches the deletions whose event was missed, e.g. while
+// the reconcile queue was backed up. The state is only dropped once the object has been missing for a resync
+// interval, so that a PodAutoscaler created after the list snapshot is not dropped.
+func (r *PodAutoscalerReconciler) collectGarbage(ctx context.Context, now time.Time) error {
+	tracked := r.trackedPodAutoscalers()
+	if len(tracked) == 0 {
+		r.missingSince = nil
+		return nil
+	}
+
+	podAutoscalerList := &autoscalingv1alpha1.PodAutoscalerList{}
+	if err := r.List(ctx, podAutoscalerList); err != nil {
+		return err
+	}
+	for _, pa := range podAutoscalerList.Items {
+		delete(tracked, types.NamespacedName{Namespace: pa.Namespace, Name: pa.Name})
+	}
+
+	missingSince := make(map[types.NamespacedName]time.Time, len(tracked))
+	for key := range tracked {
+		since, ok := r.missingSince[key]
+		if !ok {
+			missingSince[key] = now
+			continue
+		}
+		if now.Sub(since) < r.resyncInterval {
+			missingSince[key] = since
+			continue
+		}
+		klog.InfoS("Collect the in-memory state of a deleted PodAutoscaler", "PodAutoscaler", key, "missingSince", since)
+		r.deleteStaleScalerInCache(key)
+	}
+	r.missingSince = missingSince
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	goruntime "runtime"
+	"testing"
+	"time"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+func TestCollectGarbageOfDeletedPodAutoscalers(t *testing.T) {
+	baseline := goruntime.NumGoroutine()
+	r, _ := newTestReconciler(t)
+	r.resyncInterval = 10 * time.Second
+	now := time.Now()
+
+	var pas []*autoscalingv1alpha1.PodAutoscaler
+	for i := 0; i < 100; i++ {
+		pa := newTestPodAutoscaler(nil, 10, nil)
+		pa.Name = fmt.Sprintf("test-pa-%d", i)
+		if err := r.Create(context.Background(), pa); err != nil {
+			t.Fatalf("failed to create PodAutoscaler: %v", err)
+		}
+		autoScaler, err := scaler.NewKpaAutoscalerWithFetcher(1, pa, now, metrics.NewFakeMetricFetcher())
+		if err != nil {
+			t.Fatalf("failed to create scaler: %v", err)
+		}
+		metricKey, _, err := metrics.NewNamespaceNameMetric(pa)
+		if err != nil {
+			t.Fatalf("failed to create metric key: %v", err)
+		}
+		r.setScaler(metricKey, autoScaler)
+		r.stabilizeRecommendation(pa, 2, 1, now)
+		pas = append(pas, pa)
+	}
+
+	// the state of the listed PodAutoscalers is kept.
+	if err := r.collectGarbage(context.Background(), now); err != nil {
+		t.Fatalf("collect garbage failed: %v", err)
+	}
+	if len(r.AutoscalerMap) != 100 || len(r.recommendations) != 100 {
+		t.Fatalf("expected the state of 100 PodAutoscalers, got %d scalers and %d recommendations",
+			len(r.AutoscalerMap), len(r.recommendations))
+	}
+
+	// the deletions are missed, e.g. the reconcile queue was backed up.
+	for _, pa := range pas {
+		if err := r.Delete(context.Background(), pa); err != nil {
+			t.Fatalf("failed to delete PodAutoscaler: %v", err)
+		}
+	}
+	if err := r.collectGarbage(context.Background(), now.Add(time.Second)); err != nil {
+		t.Fatalf("collect garbage failed: %v", err)
+	}
+	if len(r.AutoscalerMap) != 100 {
+		t.Fatalf("expected the state to be kept until missing for a resync, got %d scalers", len(r.AutoscalerMap))
+	}
+	if err := r.collectGarbage(context.Background(), now.Add(time.Second+r.resyncInterval)); err != nil {
+		t.Fatalf("collect garbage failed: %v", err)
+	}
+	if len(r.AutoscalerMap) != 0 || len(r.recommendations) != 0 || len(r.missingSince) != 0 {
+		t.Errorf("expected all in-memory state to be dropped, got %d scalers, %d recommendations and %d missing",
+			len(r.AutoscalerMap), len(r.recommendations), len(r.missingSince))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for goruntime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count := goruntime.NumGoroutine(); count > baseline {
+		t.Errorf("expected the goroutines to return to %d, got %d", baseline, count)
+	}
+}
+
+func TestCollectGarbageKeepsRecreatedPodAutoscaler(t *testing.T) {
+	pa := newTestPodAutoscaler(nil, 10, nil)
+	r, _ := newTestReconciler(t)
+	r.resyncInterval = 10 * time.Second
+	now := time.Now()
+	r.stabilizeRecommendation(pa, 2, 1, now)
+
+	// the PodAutoscaler is missing from a first list, and created before the next one.
+	if err := r.collectGarbage(context.Background(), now); err != nil {
+		t.Fatalf("collect garbage failed: %v", err)
+	}
+	if err := r.Create(context.Background(), pa); err != nil {
+		t.Fatalf("failed to create PodAutoscaler: %v", err)
+	}
+	if err := r.collectGarbage(context.Background(), now.Add(r.resyncInterval)); err != nil {
+		t.Fatalf("collect garbage failed: %v", err)
+	}
+	if len(r.recommendations) != 1 || len(r.missingSince) != 0 {
+		t.Errorf("expected the state of the recreated PodAutoscaler to be kept, got %d recommendations and %d missing",
+			len(r.recommendations), len(r.missingSince))
+	}
+}
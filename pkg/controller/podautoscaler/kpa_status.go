@@ -0,0 +1,70 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+// setPanickingCondition reports whether a KPA target is in panic mode, the other strategies never panic.
+func setPanickingCondition(pa *autoscalingv1alpha1.PodAutoscaler, panicking bool) {
+	if pa.Spec.ScalingStrategy != autoscalingv1alpha1.KPA {
+		return
+	}
+	if panicking {
+		setCondition(pa, autoscalingv1alpha1.Panicking, metav1.ConditionTrue, "PanicThresholdExceeded",
+			"the load over the panic window exceeded the panic threshold, the target is not scaled down until the burst is absorbed")
+		return
+	}
+	setCondition(pa, autoscalingv1alpha1.Panicking, metav1.ConditionFalse, "StableMode",
+		"the target is scaled on the stable window")
+}
+
+// reportRateLimit reports in the RateLimited condition whether the scale rate limits truncated the recommendation of
+// the metrics, with an event when the target starts being rate limited.
+func (r *PodAutoscalerReconciler) reportRateLimit(pa *autoscalingv1alpha1.PodAutoscaler, result scaler.ScaleResult) {
+	if result.RateLimit == "" {
+		if apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.RateLimited) != nil {
+			setCondition(pa, autoscalingv1alpha1.RateLimited, metav1.ConditionFalse, "WithinRate",
+				"the desired count of %d replicas is within the scale rate limits", result.DesiredPodCount)
+		}
+		return
+	}
+
+	reason := "ScaleUpRateExceeded"
+	if strings.HasPrefix(result.RateLimit, "maxScaleDown") {
+		reason = "ScaleDownRateExceeded"
+	}
+	klog.InfoS("Scaling adjustment: the scale rate limit truncated the recommended replicas", "PodAutoscaler", klog.KObj(pa),
+		"rateLimit", result.RateLimit, "recommendedReplicas", result.RecommendedPodCount, "adjustedTo", result.DesiredPodCount)
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.RateLimited)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != reason {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeNormal, "RateLimited",
+			"recommended %d replicas truncated to %d replicas by %s", result.RecommendedPodCount, result.DesiredPodCount, result.RateLimit)
+	}
+	setCondition(pa, autoscalingv1alpha1.RateLimited, metav1.ConditionTrue, reason,
+		"the desired replica count is truncated to %d replicas by %s, the metrics recommend %d replicas",
+		result.DesiredPodCount, result.RateLimit, result.RecommendedPodCount)
+}
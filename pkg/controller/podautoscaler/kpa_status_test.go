@@ -0,0 +1,131 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func TestReconcileKPAPanicking(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strategy autoscalingv1alpha1.ScalingStrategyType
+		metric   float64
+		// expectedPanicking is empty if the condition is not expected.
+		expectedPanicking metav1.ConditionStatus
+	}{
+		// 16 on a single pod is 4 times the target, above the default panic threshold of 2.
+		{name: "burst", strategy: autoscalingv1alpha1.KPA, metric: 16, expectedPanicking: metav1.ConditionTrue},
+		{name: "stable", strategy: autoscalingv1alpha1.KPA, metric: 4, expectedPanicking: metav1.ConditionFalse},
+		{name: "APA never panics", strategy: autoscalingv1alpha1.APA, metric: 16},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := newTestAPAObjects(1, "8000", nil)
+			objs[1].(*autoscalingv1alpha1.PodAutoscaler).Spec.ScalingStrategy = tc.strategy
+			r, _ := newTestReconciler(t, objs...)
+			fetcher := metrics.NewFakeMetricFetcher()
+			fetcher.SetPodMetric("test-pod-0", tc.metric)
+			r.metricFetcher = fetcher
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.Panicking)
+			if tc.expectedPanicking == "" {
+				if cond != nil {
+					t.Fatalf("expected no Panicking condition, got %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != tc.expectedPanicking {
+				t.Fatalf("expected Panicking=%s, got %+v", tc.expectedPanicking, cond)
+			}
+		})
+	}
+}
+
+func TestReconcileRateLimited(t *testing.T) {
+	testCases := []struct {
+		name             string
+		annotations      map[string]string
+		expectedReplicas int32
+		expectedLimited  bool
+	}{
+		{
+			// the 40 requests of the 3 pods need 10 pods at the target of 4, the 3 pods can only double.
+			name:             "truncated by the scale up rate",
+			expectedReplicas: 6,
+			expectedLimited:  true,
+		},
+		{
+			name:             "within the scale up rate",
+			annotations:      map[string]string{scalingcontext.MaxScaleUpRateLabel: "4"},
+			expectedReplicas: 10,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := newTestAPAObjects(3, "8000", tc.annotations)
+			pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+			pa.Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+			pa.Spec.MaxReplicas = 20
+			r, recorder := newTestReconciler(t, objs...)
+			fetcher := metrics.NewFakeMetricFetcher()
+			for i, metric := range []float64{16, 16, 8} {
+				fetcher.SetPodMetric(fmt.Sprintf("test-pod-%d", i), metric)
+			}
+			r.metricFetcher = fetcher
+			// the event is only recorded once while the target stays rate limited.
+			for i := 0; i < 2; i++ {
+				if err := reconcileTestPodAutoscaler(t, r); err != nil {
+					t.Fatalf("reconcile failed: %v", err)
+				}
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.RateLimited)
+			if !tc.expectedLimited {
+				if cond != nil {
+					t.Errorf("expected no RateLimited condition, got %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "ScaleUpRateExceeded" {
+				t.Fatalf("expected RateLimited to be true with reason ScaleUpRateExceeded, got %+v", cond)
+			}
+			if !strings.Contains(cond.Message, "maxScaleUp 2x, the metrics recommend 10 replicas") {
+				t.Errorf("expected the message to report the recommendation, got %q", cond.Message)
+			}
+			if count := countEvents(recorder, "RateLimited"); count != 1 {
+				t.Errorf("expected 1 RateLimited event, got %d", count)
+			}
+		})
+	}
+}
@@ -54,28 +54,34 @@ type KPAMetricsClient struct {
 	// are collected and processed within the sliding window.
 	granularity time.Duration
 	// the difference between stable and panic metrics is the time window range
-	panicWindow  *aggregation.TimeWindow
-	stableWindow *aggregation.TimeWindow
+	panicWindow  *aggregation.SlidingWindow
+	stableWindow *aggregation.SlidingWindow
+	// scrapeLog summarizes the scrapes of the pods.
+	scrapeLog scrapeLogger
 }
 
 var _ MetricClient = (*KPAMetricsClient)(nil)
 
-// NewKPAMetricsClient initializes and returns a KPAMetricsClient with specified durations.
+// NewKPAMetricsClient initializes and returns a KPAMetricsClient with specified durations. The windows are
+// time-weighted, like the Knative autoscaler averages the metrics over time, so that the averages do not depend on
+// how often the metrics are scraped.
 func NewKPAMetricsClient(fetcher MetricFetcher, stableDuration time.Duration, panicDuration time.Duration) *KPAMetricsClient {
 	client := &KPAMetricsClient{
 		fetcher:        fetcher,
 		stableDuration: stableDuration,
 		panicDuration:  panicDuration,
 		granularity:    paGranularity,
-		panicWindow:    aggregation.NewTimeWindow(panicDuration, paGranularity),
-		stableWindow:   aggregation.NewTimeWindow(stableDuration, paGranularity),
+		panicWindow:    aggregation.NewSlidingWindowWithAggregation(panicDuration, paGranularity, aggregation.TimeWeighted),
+		stableWindow:   aggregation.NewSlidingWindowWithAggregation(stableDuration, paGranularity, aggregation.TimeWeighted),
 	}
 	return client
 }
 
 func (c *KPAMetricsClient) UpdateMetricIntoWindow(now time.Time, metricValue float64) error {
 	c.panicWindow.Record(now, metricValue)
-	c.stableWindow.Record(now, metricValue)
+	if !c.stableWindow.Record(now, metricValue) {
+		klog.V(4).InfoS("Drop late metric sample", "timestamp", now, "metricValue", metricValue)
+	}
 	return nil
 }
 
@@ -111,14 +117,14 @@ func (c *KPAMetricsClient) StableAndPanicMetrics(
 	c.collectionsMutex.RLock()
 	defer c.collectionsMutex.RUnlock()
 
-	panicValue, err := c.panicWindow.Avg()
+	panicValue, err := c.panicWindow.WindowAverage(now)
 	if err != nil {
 		return -1, -1, err
 	}
 
 	klog.V(4).InfoS("Get panicWindow", "metricKey", metricKey, "panicValue", panicValue, "panicWindow", c.panicWindow)
 
-	stableValue, err := c.stableWindow.Avg()
+	stableValue, err := c.stableWindow.WindowAverage(now)
 	if err != nil {
 		return -1, -1, err
 	}
@@ -127,12 +133,46 @@ func (c *KPAMetricsClient) StableAndPanicMetrics(
 	return stableValue, panicValue, nil
 }
 
+// DirectionalStableMetrics returns the averages of the stable metrics over the last {upDuration} and the last
+// {downDuration}, both aggregated from the samples of the stable window, which must cover the longer of the two.
+func (c *KPAMetricsClient) DirectionalStableMetrics(
+	metricKey NamespaceNameMetric, now time.Time, upDuration, downDuration time.Duration) (float64, float64, error) {
+	c.collectionsMutex.RLock()
+	defer c.collectionsMutex.RUnlock()
+
+	upValue, err := c.stableWindow.WindowAverageOver(now, upDuration)
+	if err != nil {
+		return -1, -1, err
+	}
+	downValue, err := c.stableWindow.WindowAverageOver(now, downDuration)
+	if err != nil {
+		return -1, -1, err
+	}
+	klog.V(4).InfoS("Get directional stable metrics", "metricKey", metricKey, "upValue", upValue, "upWindow", upDuration,
+		"downValue", downValue, "downWindow", downDuration)
+	return upValue, downValue, nil
+}
+
+// ResizeWindows changes the time ranges of the stable and panic metrics, keeping the samples still within them.
+func (c *KPAMetricsClient) ResizeWindows(stableDuration, panicDuration time.Duration) {
+	c.collectionsMutex.Lock()
+	defer c.collectionsMutex.Unlock()
+
+	c.stableDuration = stableDuration
+	c.panicDuration = panicDuration
+	c.stableWindow.Resize(stableDuration)
+	c.panicWindow.Resize(panicDuration)
+}
+
 func (c *KPAMetricsClient) GetPodContainerMetric(ctx context.Context, pod corev1.Pod, source autoscalingv1alpha1.MetricSource) (PodMetricsInfo, time.Time, error) {
 	return GetPodContainerMetric(ctx, c.fetcher, pod, source)
 }
 
 func (c *KPAMetricsClient) GetMetricsFromPods(ctx context.Context, pods []corev1.Pod, source autoscalingv1alpha1.MetricSource) ([]float64, error) {
-	return GetMetricsFromPods(ctx, c.fetcher, pods, source)
+	now := time.Now()
+	metricValues, summary, err := GetMetricsFromPods(ctx, c.fetcher, pods, source, now)
+	c.scrapeLog.log(source, summary, now)
+	return metricValues, err
 }
 
 func (c *KPAMetricsClient) GetMetricFromSource(ctx context.Context, source autoscalingv1alpha1.MetricSource) (float64, error) {
@@ -155,24 +195,29 @@ type APAMetricsClient struct {
 	// are collected and processed within the sliding window.
 	granularity time.Duration
 	// stable time window
-	window *aggregation.TimeWindow
+	window *aggregation.SlidingWindow
+	// scrapeLog summarizes the scrapes of the pods.
+	scrapeLog scrapeLogger
 }
 
 var _ MetricClient = (*APAMetricsClient)(nil)
 
-// NewAPAMetricsClient initializes and returns a KPAMetricsClient with specified durations.
+// NewAPAMetricsClient initializes and returns a KPAMetricsClient with specified durations. The window is
+// sample-weighted.
 func NewAPAMetricsClient(fetcher MetricFetcher, duration time.Duration) *APAMetricsClient {
 	client := &APAMetricsClient{
 		fetcher:     fetcher,
 		duration:    duration,
 		granularity: paGranularity,
-		window:      aggregation.NewTimeWindow(duration, paGranularity),
+		window:      aggregation.NewSlidingWindow(duration, paGranularity),
 	}
 	return client
 }
 
 func (c *APAMetricsClient) UpdateMetricIntoWindow(now time.Time, metricValue float64) error {
-	c.window.Record(now, metricValue)
+	if !c.window.Record(now, metricValue) {
+		klog.V(4).InfoS("Drop late metric sample", "timestamp", now, "metricValue", metricValue)
+	}
 	return nil
 }
 
@@ -204,7 +249,7 @@ func (c *APAMetricsClient) GetMetricValue(
 	c.collectionsMutex.RLock()
 	defer c.collectionsMutex.RUnlock()
 
-	metricValue, err := c.window.Avg()
+	metricValue, err := c.window.WindowAverage(now)
 	if err != nil {
 		return -1, err
 	}
@@ -214,12 +259,24 @@ func (c *APAMetricsClient) GetMetricValue(
 	return metricValue, nil
 }
 
+// ResizeWindow changes the time range of the metrics, keeping the samples still within it.
+func (c *APAMetricsClient) ResizeWindow(duration time.Duration) {
+	c.collectionsMutex.Lock()
+	defer c.collectionsMutex.Unlock()
+
+	c.duration = duration
+	c.window.Resize(duration)
+}
+
 func (c *APAMetricsClient) GetPodContainerMetric(ctx context.Context, pod corev1.Pod, source autoscalingv1alpha1.MetricSource) (PodMetricsInfo, time.Time, error) {
 	return GetPodContainerMetric(ctx, c.fetcher, pod, source)
 }
 
 func (c *APAMetricsClient) GetMetricsFromPods(ctx context.Context, pods []corev1.Pod, source autoscalingv1alpha1.MetricSource) ([]float64, error) {
-	return GetMetricsFromPods(ctx, c.fetcher, pods, source)
+	now := time.Now()
+	metricValues, summary, err := GetMetricsFromPods(ctx, c.fetcher, pods, source, now)
+	c.scrapeLog.log(source, summary, now)
+	return metricValues, err
 }
 
 func (c *APAMetricsClient) GetMetricFromSource(ctx context.Context, source autoscalingv1alpha1.MetricSource) (float64, error) {
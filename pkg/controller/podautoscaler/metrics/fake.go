@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+// FakeMetricFetcher implements MetricFetcher with preset metric values, for the tests of the autoscalers which
+// should not depend on a pod /metrics endpoint.
+type FakeMetricFetcher struct {
+	mu           sync.RWMutex
+	podValues    map[string]float64 // pod_name: metric value
+	podErrors    map[string]error   // pod_name: scrape error
+	sourceValues map[string]float64 // endpoint: metric value
+}
+
+var _ MetricFetcher = (*FakeMetricFetcher)(nil)
+
+func NewFakeMetricFetcher() *FakeMetricFetcher {
+	return &FakeMetricFetcher{
+		podValues:    map[string]float64{},
+		podErrors:    map[string]error{},
+		sourceValues: map[string]float64{},
+	}
+}
+
+// SetPodMetric sets the metric value served for the pod.
+func (f *FakeMetricFetcher) SetPodMetric(podName string, value float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.podValues[podName] = value
+	delete(f.podErrors, podName)
+}
+
+// SetPodError makes the scrapes of the pod fail with the error.
+func (f *FakeMetricFetcher) SetPodError(podName string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.podErrors[podName] = err
+}
+
+// SetSourceMetric sets the metric value served for the endpoint of a domain metric source.
+func (f *FakeMetricFetcher) SetSourceMetric(endpoint string, value float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sourceValues[endpoint] = value
+}
+
+func (f *FakeMetricFetcher) FetchPodMetrics(ctx context.Context, pod v1.Pod, source autoscalingv1alpha1.MetricSource) (float64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if err, ok := f.podErrors[pod.Name]; ok {
+		return 0, err
+	}
+	value, ok := f.podValues[pod.Name]
+	if !ok {
+		return 0, fmt.Errorf("metrics %s not found", source.TargetMetric)
+	}
+	return value, nil
+}
+
+func (f *FakeMetricFetcher) FetchMetric(ctx context.Context, protocol autoscalingv1alpha1.ProtocolType, endpoint, path, metricName string) (float64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	value, ok := f.sourceValues[endpoint]
+	if !ok {
+		return 0, fmt.Errorf("metrics %s not found", metricName)
+	}
+	return value, nil
+}
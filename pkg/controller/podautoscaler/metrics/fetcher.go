@@ -18,13 +18,12 @@ package metrics
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
+	"time"
 
 	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	aibrixmetrics "github.com/vllm-project/aibrix/pkg/metrics"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -36,6 +35,9 @@ import (
 	"k8s.io/metrics/pkg/client/custom_metrics"
 )
 
+// PodScrapeTimeout bounds the scrape of the metrics of a single pod.
+var PodScrapeTimeout = 3 * time.Second
+
 // MetricType defines the type of metrics to be fetched.
 type MetricType string
 
@@ -63,55 +65,60 @@ func (f *abstractMetricsFetcher) FetchMetric(ctx context.Context, pod v1.Pod, me
 type RestMetricsFetcher struct {
 	// For unit test purpose only
 	test_url_setter func(string)
-	// Custom HTTP client
-	client *http.Client
+	// scraper sends the requests, with one HTTP client per TLS configuration.
+	scraper *aibrixmetrics.Scraper
 }
 
 var _ MetricFetcher = (*RestMetricsFetcher)(nil)
 
 func NewRestMetricsFetcher() *RestMetricsFetcher {
+	return NewRestMetricsFetcherWithSecrets(nil)
+}
+
+// NewRestMetricsFetcherWithSecrets returns a RestMetricsFetcher reading the bearer tokens of the Secrets the pods
+// reference in their metrics.aibrix.ai/auth-secret annotation with getSecret.
+func NewRestMetricsFetcherWithSecrets(getSecret aibrixmetrics.SecretGetter) *RestMetricsFetcher {
 	return &RestMetricsFetcher{
-		client: &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // Disable SSL verification
-			},
-		},
+		scraper: aibrixmetrics.NewScraper(getSecret),
 	}
 }
 
+// FetchPodMetrics scrapes the metric of the pod. The annotations of the pod may set the scheme, the TLS
+// verification, the bearer token and the headers of the scrape, see aibrixmetrics.ScrapeConfigForPod.
 func (f *RestMetricsFetcher) FetchPodMetrics(ctx context.Context, pod v1.Pod, source autoscalingv1alpha1.MetricSource) (float64, error) {
+	config, err := aibrixmetrics.ScrapeConfigForPod(&pod, string(source.ProtocolType))
+	if err != nil {
+		return 0.0, fmt.Errorf("failed to scrape pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	// the certificates of the pods were never verified before they could set a CA bundle, it stays so for the pods
+	// which don't opt in.
+	if _, ok := pod.Annotations[aibrixmetrics.ScrapeInsecureSkipVerifyAnnotation]; !ok && config.CAFile == "" {
+		config.InsecureSkipVerify = true
+	}
 	// Use /metrics to fetch pod's endpoint
-	return f.FetchMetric(ctx, source.ProtocolType, fmt.Sprintf("%s:%s", pod.Status.PodIP, source.Port), source.Path, source.TargetMetric)
+	return f.fetch(ctx, config, fmt.Sprintf("%s:%s", pod.Status.PodIP, source.Port), source.Path, source.TargetMetric)
 }
 
 func (f *RestMetricsFetcher) FetchMetric(ctx context.Context, protocol autoscalingv1alpha1.ProtocolType, endpoint, path, metricName string) (float64, error) {
-	// Use http to fetch endpoint
-	url := fmt.Sprintf("%s://%s/%s", protocol, endpoint, strings.TrimLeft(path, "/"))
+	return f.fetch(ctx, aibrixmetrics.ScrapeConfig{Scheme: string(protocol), InsecureSkipVerify: true}, endpoint, path, metricName)
+}
+
+func (f *RestMetricsFetcher) fetch(ctx context.Context, config aibrixmetrics.ScrapeConfig, endpoint, path, metricName string) (float64, error) {
+	url := f._get_url(autoscalingv1alpha1.ProtocolType(config.Scheme), endpoint, path)
 	if f.test_url_setter != nil {
 		f.test_url_setter(url)
 		return 0.0, nil
 	}
 
-	// Create request with context, so that the request will be canceled if the context is canceled
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return 0.0, fmt.Errorf("failed to create request to source %s: %v", url, err)
+	scraper := f.scraper
+	if scraper == nil {
+		scraper = defaultScraper
 	}
-
-	// Send the request using the default client
-	resp, err := f.client.Do(req)
+	// The request is canceled if the context is canceled. An endpoint rejecting the credentials returns an
+	// aibrixmetrics.ScrapeAuthError, and is not scraped again within aibrixmetrics.ScrapeAuthFailureBackoff.
+	body, err := scraper.Get(ctx, config, endpoint, path)
 	if err != nil {
-		return 0.0, fmt.Errorf("failed to fetch metrics from source %s: %v", url, err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			// Handle the error here. For example, log it or take appropriate corrective action.
-			klog.ErrorS(err, "error closing response body")
-		}
-	}()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0.0, fmt.Errorf("failed to read response from source %s: %v", url, err)
+		return 0.0, err
 	}
 
 	metricValue, err := ParseMetricFromBody(body, metricName)
@@ -124,6 +131,9 @@ func (f *RestMetricsFetcher) FetchMetric(ctx context.Context, protocol autoscali
 	return metricValue, nil
 }
 
+// defaultScraper scrapes the metrics of the fetchers built without a scraper.
+var defaultScraper = aibrixmetrics.NewScraper(nil)
+
 func (f *RestMetricsFetcher) _get_url(protocol autoscalingv1alpha1.ProtocolType, endpoint, path string) string {
 	return fmt.Sprintf("%s://%s/%s", protocol, endpoint, strings.TrimLeft(path, "/"))
 }
@@ -38,12 +38,34 @@ type NamespaceNameMetric struct {
 
 // NewNamespaceNameMetric creates a NamespaceNameMetric based on the PodAutoscaler's metrics source.
 // For consistency, it will return the corresponding MetricSource.
-// Currently, it supports only a single metric source. In the future, this could be extended to handle multiple metric sources.
+// It supports only a single metric source, NewNamespaceNameMetrics returns the keys of all the sources.
 func NewNamespaceNameMetric(pa *autoscalingv1alpha1.PodAutoscaler) (NamespaceNameMetric, autoscalingv1alpha1.MetricSource, error) {
 	if len(pa.Spec.MetricsSources) != 1 {
 		return NamespaceNameMetric{}, autoscalingv1alpha1.MetricSource{}, fmt.Errorf("metrics sources must be 1, but got %d", len(pa.Spec.MetricsSources))
 	}
 	metricSource := pa.Spec.MetricsSources[0]
+	return newNamespaceNameMetric(pa, metricSource), metricSource, nil
+}
+
+// NewNamespaceNameMetrics creates a NamespaceNameMetric for each metrics source of the PodAutoscaler, in the order
+// of the sources. The metric of each source is scaled separately, so the target metrics must be unique.
+func NewNamespaceNameMetrics(pa *autoscalingv1alpha1.PodAutoscaler) ([]NamespaceNameMetric, error) {
+	if len(pa.Spec.MetricsSources) == 0 {
+		return nil, fmt.Errorf("no metrics sources")
+	}
+	metricKeys := make([]NamespaceNameMetric, 0, len(pa.Spec.MetricsSources))
+	seen := make(map[string]struct{}, len(pa.Spec.MetricsSources))
+	for _, metricSource := range pa.Spec.MetricsSources {
+		if _, ok := seen[metricSource.TargetMetric]; ok {
+			return nil, fmt.Errorf("duplicate target metric %s in metrics sources", metricSource.TargetMetric)
+		}
+		seen[metricSource.TargetMetric] = struct{}{}
+		metricKeys = append(metricKeys, newNamespaceNameMetric(pa, metricSource))
+	}
+	return metricKeys, nil
+}
+
+func newNamespaceNameMetric(pa *autoscalingv1alpha1.PodAutoscaler, metricSource autoscalingv1alpha1.MetricSource) NamespaceNameMetric {
 	return NamespaceNameMetric{
 		NamespacedName: types.NamespacedName{
 			Namespace: pa.Namespace,
@@ -52,7 +74,7 @@ func NewNamespaceNameMetric(pa *autoscalingv1alpha1.PodAutoscaler) (NamespaceNam
 		MetricName:  metricSource.TargetMetric,
 		PaNamespace: pa.Namespace,
 		PaName:      pa.Name,
-	}, metricSource, nil
+	}
 }
 
 // PodMetric contains pod metric value (the metric values are expected to be the metric as a milli-value)
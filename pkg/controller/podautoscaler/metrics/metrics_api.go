@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"k8s.io/metrics/pkg/client/custom_metrics"
+	"k8s.io/metrics/pkg/client/external_metrics"
+)
+
+// podGroupKind is the GroupKind of the pods in the custom metrics API.
+var podGroupKind = schema.GroupKind{Group: "", Kind: "Pod"}
+
+// MetricsAPIClient queries the metrics served by the Kubernetes custom metrics API (custom.metrics.k8s.io) and
+// external metrics API (external.metrics.k8s.io), e.g. by prometheus-adapter, instead of scraping the pods.
+type MetricsAPIClient struct {
+	customMetricsClient   custom_metrics.CustomMetricsClient
+	externalMetricsClient external_metrics.ExternalMetricsClient
+}
+
+func NewMetricsAPIClient(customMetricsClient custom_metrics.CustomMetricsClient, externalMetricsClient external_metrics.ExternalMetricsClient) *MetricsAPIClient {
+	return &MetricsAPIClient{
+		customMetricsClient:   customMetricsClient,
+		externalMetricsClient: externalMetricsClient,
+	}
+}
+
+// GetPodMetrics returns the custom metric of each pod matching the selector.
+func (c *MetricsAPIClient) GetPodMetrics(ctx context.Context, namespace string, selector labels.Selector, metricName string) ([]float64, error) {
+	if c.customMetricsClient == nil {
+		return nil, fmt.Errorf("custom metrics API client is not configured")
+	}
+	metricList, err := c.customMetricsClient.NamespacedMetrics(namespace).GetForObjects(podGroupKind, selector, metricName, labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch custom metric %s of the pods: %w", metricName, err)
+	}
+	if len(metricList.Items) == 0 {
+		return nil, fmt.Errorf("no custom metric %s returned for the pods matching %s", metricName, selector)
+	}
+
+	metricValues := make([]float64, 0, len(metricList.Items))
+	for _, item := range metricList.Items {
+		metricValues = append(metricValues, quantityToFloat(item.Value))
+	}
+	klog.V(4).InfoS("Fetched custom pod metrics", "namespace", namespace, "selector", selector, "metric", metricName, "metricValues", metricValues)
+	return metricValues, nil
+}
+
+// GetObjectMetric returns the custom metric describing an object, e.g. the scale target of a PodAutoscaler.
+func (c *MetricsAPIClient) GetObjectMetric(ctx context.Context, namespace string, groupKind schema.GroupKind, name, metricName string) (float64, error) {
+	if c.customMetricsClient == nil {
+		return 0, fmt.Errorf("custom metrics API client is not configured")
+	}
+	metricValue, err := c.customMetricsClient.NamespacedMetrics(namespace).GetForObject(groupKind, name, metricName, labels.Everything())
+	if err != nil {
+		return 0, fmt.Errorf("unable to fetch custom metric %s of %s %s/%s: %w", metricName, groupKind, namespace, name, err)
+	}
+	value := quantityToFloat(metricValue.Value)
+	klog.V(4).InfoS("Fetched custom object metric", "object", groupKind, "namespace", namespace, "name", name, "metric", metricName, "metricValue", value)
+	return value, nil
+}
+
+// GetExternalMetric returns the sum of the external metric series matching the selector.
+func (c *MetricsAPIClient) GetExternalMetric(ctx context.Context, namespace string, metricName string, selector labels.Selector) (float64, error) {
+	if c.externalMetricsClient == nil {
+		return 0, fmt.Errorf("external metrics API client is not configured")
+	}
+	metricList, err := c.externalMetricsClient.NamespacedMetrics(namespace).List(metricName, selector)
+	if err != nil {
+		return 0, fmt.Errorf("unable to fetch external metric %s: %w", metricName, err)
+	}
+	if len(metricList.Items) == 0 {
+		return 0, fmt.Errorf("no external metric %s returned for selector %s", metricName, selector)
+	}
+
+	var sum float64
+	for _, item := range metricList.Items {
+		sum += quantityToFloat(item.Value)
+	}
+	klog.V(4).InfoS("Fetched external metric", "namespace", namespace, "metric", metricName, "series", len(metricList.Items), "metricValue", sum)
+	return sum, nil
+}
+
+// quantityToFloat converts the quantity of a metric, the fractional metrics are served as milli-values.
+func quantityToFloat(quantity resource.Quantity) float64 {
+	return float64(quantity.MilliValue()) / 1000
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	cmapi "k8s.io/metrics/pkg/apis/custom_metrics/v1beta2"
+	emapi "k8s.io/metrics/pkg/apis/external_metrics/v1beta1"
+	"k8s.io/metrics/pkg/client/custom_metrics"
+	"k8s.io/metrics/pkg/client/external_metrics"
+)
+
+// fakeCustomMetrics serves the custom metrics of the objects of a namespace, keyed by kind and name.
+type fakeCustomMetrics struct {
+	namespace string
+	values    map[string]map[string]string // kind: name: metric value
+	err       error
+}
+
+func (f *fakeCustomMetrics) RootScopedMetrics() custom_metrics.MetricsInterface {
+	return f
+}
+
+func (f *fakeCustomMetrics) NamespacedMetrics(namespace string) custom_metrics.MetricsInterface {
+	f.namespace = namespace
+	return f
+}
+
+func (f *fakeCustomMetrics) GetForObject(groupKind schema.GroupKind, name string, metricName string, metricSelector labels.Selector) (*cmapi.MetricValue, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	value, ok := f.values[groupKind.Kind][name]
+	if !ok {
+		return nil, errors.New("the server could not find the metric")
+	}
+	return &cmapi.MetricValue{Value: resource.MustParse(value)}, nil
+}
+
+func (f *fakeCustomMetrics) GetForObjects(groupKind schema.GroupKind, selector labels.Selector, metricName string, metricSelector labels.Selector) (*cmapi.MetricValueList, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	list := &cmapi.MetricValueList{}
+	for _, value := range f.values[groupKind.Kind] {
+		list.Items = append(list.Items, cmapi.MetricValue{Value: resource.MustParse(value)})
+	}
+	return list, nil
+}
+
+// fakeExternalMetrics serves the series of an external metric.
+type fakeExternalMetrics struct {
+	values []string
+	err    error
+}
+
+func (f *fakeExternalMetrics) NamespacedMetrics(namespace string) external_metrics.MetricsInterface {
+	return f
+}
+
+func (f *fakeExternalMetrics) List(metricName string, metricSelector labels.Selector) (*emapi.ExternalMetricValueList, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	list := &emapi.ExternalMetricValueList{}
+	for _, value := range f.values {
+		list.Items = append(list.Items, emapi.ExternalMetricValue{MetricName: metricName, Value: resource.MustParse(value)})
+	}
+	return list, nil
+}
+
+var _ = Describe("MetricsAPIClient", func() {
+	var (
+		custom   *fakeCustomMetrics
+		external *fakeExternalMetrics
+		client   *MetricsAPIClient
+	)
+
+	BeforeEach(func() {
+		custom = &fakeCustomMetrics{values: map[string]map[string]string{
+			"Pod":        {"pod-0": "500m", "pod-1": "2"},
+			"Deployment": {"llama-7b": "12"},
+		}}
+		external = &fakeExternalMetrics{values: []string{"3", "1500m"}}
+		client = NewMetricsAPIClient(custom, external)
+	})
+
+	It("should return the custom metric of each pod", func() {
+		values, err := client.GetPodMetrics(context.Background(), "default", labels.Everything(), "num_requests_running")
+		Expect(err).To(BeNil())
+		Expect(values).To(ConsistOf(0.5, 2.0))
+		Expect(custom.namespace).To(Equal("default"))
+	})
+
+	It("should return the custom metric of the scale target", func() {
+		value, err := client.GetObjectMetric(context.Background(), "default",
+			schema.GroupKind{Group: "apps", Kind: "Deployment"}, "llama-7b", "num_requests_running")
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal(12.0))
+	})
+
+	It("should sum the series of an external metric", func() {
+		value, err := client.GetExternalMetric(context.Background(), "default", "queue_length", labels.Everything())
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal(4.5))
+	})
+
+	It("should fail when the metrics API fails or returns no metric", func() {
+		custom.err = errors.New("the server is currently unable to handle the request")
+		_, err := client.GetPodMetrics(context.Background(), "default", labels.Everything(), "num_requests_running")
+		Expect(err).To(MatchError(ContainSubstring("num_requests_running")))
+		_, err = client.GetObjectMetric(context.Background(), "default",
+			schema.GroupKind{Group: "apps", Kind: "Deployment"}, "llama-7b", "num_requests_running")
+		Expect(err).To(MatchError(ContainSubstring("unable to handle the request")))
+
+		external.values = nil
+		_, err = client.GetExternalMetric(context.Background(), "default", "queue_length", labels.Everything())
+		Expect(err).To(MatchError(ContainSubstring("no external metric queue_length")))
+	})
+})
@@ -0,0 +1,214 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	aibrixmetrics "github.com/vllm-project/aibrix/pkg/metrics"
+)
+
+var _ = Describe("ParseMetricFromBody", func() {
+	body := []byte(`# HELP test_metric A test metric.
+# TYPE test_metric gauge
+test_metric_total 100
+test_metric{model="llama-7b",path="/v1 chat"} 2.5 1395066363000
+other_metric 7
+`)
+
+	It("should extract the requested series", func() {
+		value, err := ParseMetricFromBody(body, "test_metric")
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal(2.5))
+
+		value, err = ParseMetricFromBody(body, "test_metric_total")
+		Expect(err).To(BeNil())
+		Expect(value).To(Equal(100.0))
+	})
+
+	It("should name the missing metric", func() {
+		_, err := ParseMetricFromBody(body, "missing_metric")
+		Expect(err).To(MatchError(ContainSubstring("missing_metric")))
+	})
+})
+
+var _ = Describe("GetPodContainerMetric", func() {
+	var (
+		server *httptest.Server
+		source autoscalingv1alpha1.MetricSource
+		ready  time.Time
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			fmt.Fprintf(w, "test_metric 2.5\n")
+		}))
+		u, err := url.Parse(server.URL)
+		Expect(err).To(BeNil())
+		source = autoscalingv1alpha1.MetricSource{
+			MetricSourceType: autoscalingv1alpha1.POD,
+			ProtocolType:     autoscalingv1alpha1.HTTP,
+			Path:             "metrics",
+			Port:             u.Port(),
+			TargetMetric:     "test_metric",
+		}
+		ready = time.Now().Add(-time.Hour)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should return the metric of the pod as a milli-value", func() {
+		before := time.Now()
+		info, timestamp, err := GetPodContainerMetric(context.Background(), NewRestMetricsFetcher(),
+			newScrapeTestPod("pod-0", "127.0.0.1", true, ready), source)
+		Expect(err).To(BeNil())
+		Expect(info).To(HaveKey("pod-0"))
+		Expect(info["pod-0"].Value).To(Equal(int64(2500)))
+		Expect(info["pod-0"].MetricsName).To(Equal("test_metric"))
+		Expect(info["pod-0"].Timestamp).To(Equal(timestamp))
+		Expect(timestamp).NotTo(BeTemporally("<", before))
+	})
+
+	It("should fail for a pod without an IP", func() {
+		_, _, err := GetPodContainerMetric(context.Background(), NewRestMetricsFetcher(),
+			newScrapeTestPod("pod-0", "", false, ready), source)
+		Expect(err).To(MatchError(ContainSubstring("pod-0 has no IP")))
+	})
+
+	It("should name the missing metric", func() {
+		source.TargetMetric = "missing_metric"
+		_, _, err := GetPodContainerMetric(context.Background(), NewRestMetricsFetcher(),
+			newScrapeTestPod("pod-0", "127.0.0.1", true, ready), source)
+		Expect(err).To(MatchError(ContainSubstring("missing_metric")))
+	})
+
+	It("should not let a dead pod block the scrape of the others", func() {
+		// a pod which accepts the connection but never answers, on the port of the metrics server.
+		dead, err := net.Listen("tcp", net.JoinHostPort("127.0.0.4", source.Port))
+		if err != nil {
+			Skip(fmt.Sprintf("can not listen on 127.0.0.4: %v", err))
+		}
+		hanging := &httptest.Server{Listener: dead, Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			<-req.Context().Done()
+		})}}
+		hanging.Start()
+		defer hanging.Close()
+
+		timeout := PodScrapeTimeout
+		PodScrapeTimeout = 200 * time.Millisecond
+		defer func() { PodScrapeTimeout = timeout }()
+
+		start := time.Now()
+		_, _, err = GetPodContainerMetric(context.Background(), NewRestMetricsFetcher(),
+			newScrapeTestPod("dead", "127.0.0.4", true, ready), source)
+		Expect(classifyScrapeError(err)).To(Equal(failReasonTimeout))
+		Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+
+		pods := []corev1.Pod{newScrapeTestPod("dead", "127.0.0.4", true, ready),
+			newScrapeTestPod("alive", "127.0.0.1", true, ready)}
+		start = time.Now()
+		_, summary, err := GetMetricsFromPods(context.Background(), NewRestMetricsFetcher(), pods, source, time.Now())
+		Expect(err).NotTo(BeNil())
+		Expect(summary.Scraped).To(Equal(1))
+		Expect(summary.Failed).To(Equal(map[string]int{failReasonTimeout: 1}))
+		Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+	})
+})
+
+var _ = Describe("FakeMetricFetcher", func() {
+	It("should serve the preset metrics of the pods", func() {
+		fetcher := NewFakeMetricFetcher()
+		fetcher.SetPodMetric("pod-0", 3)
+		fetcher.SetPodError("pod-1", errors.New("connection refused"))
+		source := autoscalingv1alpha1.MetricSource{TargetMetric: "test_metric"}
+		ready := time.Now().Add(-time.Hour)
+
+		info, _, err := GetPodContainerMetric(context.Background(), fetcher, newScrapeTestPod("pod-0", "10.0.0.1", true, ready), source)
+		Expect(err).To(BeNil())
+		Expect(info["pod-0"].Value).To(Equal(int64(3000)))
+
+		_, _, err = GetPodContainerMetric(context.Background(), fetcher, newScrapeTestPod("pod-1", "10.0.0.2", true, ready), source)
+		Expect(err).To(MatchError("connection refused"))
+
+		_, _, err = GetPodContainerMetric(context.Background(), fetcher, newScrapeTestPod("pod-2", "10.0.0.3", true, ready), source)
+		Expect(err).To(MatchError(ContainSubstring("test_metric")))
+	})
+})
+
+var _ = Describe("RestMetricsFetcher with the scrape annotations", func() {
+	It("should send the bearer token of the pod and classify its rejection", func() {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests.Add(1)
+			if req.Header.Get("Authorization") != "Bearer engine-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, "test_metric 2.5\n")
+		}))
+		defer server.Close()
+		u, err := url.Parse(server.URL)
+		Expect(err).To(BeNil())
+		source := autoscalingv1alpha1.MetricSource{
+			MetricSourceType: autoscalingv1alpha1.POD,
+			ProtocolType:     autoscalingv1alpha1.HTTP,
+			Path:             "metrics",
+			Port:             u.Port(),
+			TargetMetric:     "test_metric",
+		}
+		dir, err := os.MkdirTemp("", "scrape-token")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		tokenFile := filepath.Join(dir, "token")
+		Expect(os.WriteFile(tokenFile, []byte("engine-token"), 0o600)).To(Succeed())
+
+		fetcher := NewRestMetricsFetcher()
+		ready := time.Now().Add(-time.Hour)
+		pod := newScrapeTestPod("pod-0", "127.0.0.1", true, ready)
+		pod.Annotations = map[string]string{aibrixmetrics.ScrapeTokenFileAnnotation: tokenFile}
+		info, _, err := GetPodContainerMetric(context.Background(), fetcher, pod, source)
+		Expect(err).To(BeNil())
+		Expect(info["pod-0"].Value).To(Equal(int64(2500)))
+
+		// the pod without the token is rejected, and not scraped again within the back-off.
+		pods := []corev1.Pod{newScrapeTestPod("pod-1", "127.0.0.1", true, ready)}
+		pods[0].Annotations = map[string]string{aibrixmetrics.ScrapeHeadersAnnotation: `{"X-Tenant": "a"}`}
+		for i := 0; i < 2; i++ {
+			_, summary, err := GetMetricsFromPods(context.Background(), fetcher, pods, source, time.Now())
+			Expect(err).NotTo(BeNil())
+			Expect(summary.Failed).To(Equal(map[string]int{failReasonUnauthorized: 1}))
+		}
+		Expect(requests.Load()).To(Equal(int32(2)))
+	})
+})
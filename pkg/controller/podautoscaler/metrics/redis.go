@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultRedisKeyPattern is the key of the requests of a model pending at the gateway, which counts them while
+	// they wait in its admission queue.
+	DefaultRedisKeyPattern = "pending:{model}"
+	// redisKeyModelPlaceholder is replaced by the model of the scale target in the key pattern.
+	redisKeyModelPlaceholder = "{model}"
+)
+
+// redisGetter is the subset of the Redis client the metrics are read with.
+type redisGetter interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+}
+
+// RedisMetricsClient reads the metrics stored in Redis, e.g. the requests of a model queued at the gateway, which
+// reflect the demand that has not reached any pod yet.
+type RedisMetricsClient struct {
+	client redisGetter
+}
+
+func NewRedisMetricsClient(client redis.UniversalClient) *RedisMetricsClient {
+	return &RedisMetricsClient{client: client}
+}
+
+// RedisMetricKey returns the key of the pattern for the model, the default pattern if the pattern is empty.
+func RedisMetricKey(pattern, model string) (string, error) {
+	if pattern == "" {
+		pattern = DefaultRedisKeyPattern
+	}
+	if !strings.Contains(pattern, redisKeyModelPlaceholder) {
+		return pattern, nil
+	}
+	if model == "" {
+		return "", fmt.Errorf("the key pattern %s requires the model of the scale target", pattern)
+	}
+	return strings.ReplaceAll(pattern, redisKeyModelPlaceholder, model), nil
+}
+
+// GetMetricValue returns the value of the key, 0 if the key does not exist. A negative counter, e.g. decremented
+// after a reset of Redis, is read as 0.
+func (c *RedisMetricsClient) GetMetricValue(ctx context.Context, key string) (float64, error) {
+	raw, err := c.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to read metric %s from redis: %w", key, err)
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("metric %s in redis is not a number: %q", key, raw)
+	}
+	if value < 0 {
+		return 0, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis serves the values of its keys, or fails every read with err.
+type fakeRedis struct {
+	values map[string]string
+	err    error
+}
+
+func (f *fakeRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	if f.err != nil {
+		return redis.NewStringResult("", f.err)
+	}
+	value, ok := f.values[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(value, nil)
+}
+
+var _ = Describe("RedisMetricsClient", func() {
+	It("builds the key of the model", func() {
+		key, err := RedisMetricKey("", "llama")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal("pending:llama"))
+
+		key, err = RedisMetricKey("queue:{model}:depth", "llama")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal("queue:llama:depth"))
+
+		key, err = RedisMetricKey("pending:all", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal("pending:all"))
+
+		_, err = RedisMetricKey("", "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads the value of the key", func() {
+		client := &RedisMetricsClient{client: &fakeRedis{values: map[string]string{
+			"pending:llama":   "12",
+			"pending:mistral": "-2",
+			"pending:broken":  "many",
+		}}}
+		ctx := context.Background()
+
+		value, err := client.GetMetricValue(ctx, "pending:llama")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(12.0))
+
+		// a missing key means no request is pending.
+		value, err = client.GetMetricValue(ctx, "pending:qwen")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(0.0))
+
+		value, err = client.GetMetricValue(ctx, "pending:mistral")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(0.0))
+
+		_, err = client.GetMetricValue(ctx, "pending:broken")
+		Expect(err).To(MatchError(ContainSubstring("is not a number")))
+	})
+
+	It("fails when redis is unavailable", func() {
+		client := &RedisMetricsClient{client: &fakeRedis{err: errors.New("connection refused")}}
+		_, err := client.GetMetricValue(context.Background(), "pending:llama")
+		Expect(err).To(MatchError(ContainSubstring("connection refused")))
+	})
+})
@@ -0,0 +1,234 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	aibrixmetrics "github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	scrapeResultSuccess = "success"
+	scrapeResultSkipped = "skipped"
+	scrapeResultFailed  = "failed"
+
+	// reasons of the skipped scrapes, no request is sent to these pods.
+	skipReasonUnready   = "unready"
+	skipReasonWarmingUp = "warming_up"
+
+	// reasons of the failed scrapes.
+	failReasonConnectionRefused = "connection_refused"
+	failReasonTimeout           = "timeout"
+	failReasonError             = "error"
+	// failReasonUnauthorized is a pod rejecting the credentials of the scrape, it is not scraped again before
+	// aibrixmetrics.ScrapeAuthFailureBackoff.
+	failReasonUnauthorized = "unauthorized"
+)
+
+var (
+	// ScrapeGracePeriod is how long after a pod became ready its metrics are not scraped, the engine may still be
+	// loading the model. The pods within the grace period are counted as pending capacity.
+	ScrapeGracePeriod = 30 * time.Second
+	// scrapeSummaryInterval is the minimum interval between two identical scrape summaries of a metrics client.
+	scrapeSummaryInterval = time.Minute
+
+	podScrapes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aibrix_podautoscaler_pod_scrapes_total",
+		Help: "Number of pod metric scrapes by result, success, skipped or failed, and reason.",
+	}, []string{"result", "reason"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(podScrapes)
+}
+
+// ScrapeSummary counts the pods of a metric fan-out by outcome.
+type ScrapeSummary struct {
+	Scraped int
+	// Skipped and Failed count the pods by reason.
+	Skipped map[string]int
+	Failed  map[string]int
+	// FirstError is the error of the first failed scrape.
+	FirstError error
+}
+
+func (s *ScrapeSummary) skip(reason string) {
+	if s.Skipped == nil {
+		s.Skipped = map[string]int{}
+	}
+	s.Skipped[reason]++
+	podScrapes.WithLabelValues(scrapeResultSkipped, reason).Inc()
+}
+
+func (s *ScrapeSummary) fail(reason string, err error) {
+	if s.Failed == nil {
+		s.Failed = map[string]int{}
+	}
+	s.Failed[reason]++
+	if s.FirstError == nil {
+		s.FirstError = err
+	}
+	podScrapes.WithLabelValues(scrapeResultFailed, reason).Inc()
+}
+
+// String returns the summary as one line, e.g. "scraped 2 pods, skipped 18 pods: 12 unready, 6 warming up".
+func (s ScrapeSummary) String() string {
+	parts := []string{fmt.Sprintf("scraped %d pods", s.Scraped)}
+	if n, reasons := summarizeReasons(s.Skipped); n > 0 {
+		parts = append(parts, fmt.Sprintf("skipped %d pods: %s", n, reasons))
+	}
+	if n, reasons := summarizeReasons(s.Failed); n > 0 {
+		parts = append(parts, fmt.Sprintf("failed %d pods: %s", n, reasons))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// summarizeReasons returns the total count and the counts by reason, the most frequent first.
+func summarizeReasons(counts map[string]int) (int, string) {
+	reasons := make([]string, 0, len(counts))
+	total := 0
+	for reason, count := range counts {
+		reasons = append(reasons, reason)
+		total += count
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if counts[reasons[i]] != counts[reasons[j]] {
+			return counts[reasons[i]] > counts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	for i, reason := range reasons {
+		reasons[i] = fmt.Sprintf("%d %s", counts[reason], strings.ReplaceAll(reason, "_", " "))
+	}
+	return total, strings.Join(reasons, ", ")
+}
+
+// skipScrapeReason returns why the pod is not scraped, empty if it is.
+func skipScrapeReason(pod *corev1.Pod, now time.Time) string {
+	if pod.Status.PodIP == "" || utils.IsPodTerminating(pod) || !utils.IsPodReady(pod) {
+		return skipReasonUnready
+	}
+	if ready := utils.GetPodReadyCondition(pod.Status); now.Sub(ready.LastTransitionTime.Time) < ScrapeGracePeriod {
+		return skipReasonWarmingUp
+	}
+	return ""
+}
+
+func classifyScrapeError(err error) string {
+	var netErr net.Error
+	var authErr *aibrixmetrics.ScrapeAuthError
+	switch {
+	case errors.As(err, &authErr):
+		return failReasonUnauthorized
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return failReasonConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return failReasonTimeout
+	default:
+		return failReasonError
+	}
+}
+
+// PartialMetricsError reports the pods whose scrape failed while the metrics of the other pods were collected.
+// The metrics of the failed pods are missing from the sum, so it understates the load of the target: a scale up
+// computed from it is applied, as if the missing pods had no load, but a scale down is not.
+type PartialMetricsError struct {
+	Summary ScrapeSummary
+}
+
+func (e *PartialMetricsError) Error() string {
+	return fmt.Sprintf("failed to scrape pod metrics (%s): %v", e.Summary, e.Summary.FirstError)
+}
+
+func (e *PartialMetricsError) Unwrap() error {
+	return e.Summary.FirstError
+}
+
+// GetMetricsFromPods scrapes the metric of the pods. The pods which are not ready or within the scrape grace
+// period are skipped without sending them a request. If some scrapes fail, the metrics of the other pods are
+// returned with a PartialMetricsError. It fails if there are pods and none of them could be scraped.
+func GetMetricsFromPods(ctx context.Context, fetcher MetricFetcher, pods []corev1.Pod, source autoscalingv1alpha1.MetricSource, now time.Time) ([]float64, ScrapeSummary, error) {
+	var summary ScrapeSummary
+	metrics := make([]float64, 0, len(pods))
+	for i := range pods {
+		if reason := skipScrapeReason(&pods[i], now); reason != "" {
+			summary.skip(reason)
+			continue
+		}
+		// TODO: Let's optimize the performance for multi-metrics later.
+		metric, err := fetchPodMetric(ctx, fetcher, pods[i], source)
+		if err != nil {
+			summary.fail(classifyScrapeError(err), err)
+			continue
+		}
+		summary.Scraped++
+		podScrapes.WithLabelValues(scrapeResultSuccess, "").Inc()
+		metrics = append(metrics, metric)
+	}
+
+	if len(pods) > 0 && summary.Scraped == 0 {
+		if summary.FirstError != nil {
+			return nil, summary, fmt.Errorf("no pod metrics available (%s): %w", summary, summary.FirstError)
+		}
+		return nil, summary, fmt.Errorf("no pod metrics available (%s)", summary)
+	}
+	if summary.FirstError != nil {
+		return metrics, summary, &PartialMetricsError{Summary: summary}
+	}
+	return metrics, summary, nil
+}
+
+// scrapeLogger logs one summary line per metric fan-out instead of an error per pod. An unchanged summary is
+// only logged again after scrapeSummaryInterval, so a long startup storm does not flood the log.
+type scrapeLogger struct {
+	mu       sync.Mutex
+	last     string
+	lastTime time.Time
+}
+
+func (l *scrapeLogger) log(source autoscalingv1alpha1.MetricSource, summary ScrapeSummary, now time.Time) {
+	line := summary.String()
+	if len(summary.Skipped) == 0 && len(summary.Failed) == 0 {
+		klog.V(4).InfoS("Scraped pod metrics", "metric", source.TargetMetric, "summary", line)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if line == l.last && now.Sub(l.lastTime) < scrapeSummaryInterval {
+		klog.V(4).InfoS("Scraped pod metrics", "metric", source.TargetMetric, "summary", line)
+		return
+	}
+	l.last, l.lastTime = line, now
+	klog.InfoS("Scraped pod metrics", "metric", source.TargetMetric, "summary", line, "firstError", summary.FirstError)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+func newScrapeTestPod(name, ip string, ready bool, readySince time.Time) corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status: corev1.PodStatus{
+			PodIP: ip,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: status, LastTransitionTime: metav1.NewTime(readySince)},
+			},
+		},
+	}
+}
+
+func scrapeCount(result, reason string) float64 {
+	return testutil.ToFloat64(podScrapes.WithLabelValues(result, reason))
+}
+
+var _ = Describe("GetMetricsFromPods", func() {
+	var (
+		requests atomic.Int64
+		server   *httptest.Server
+		source   autoscalingv1alpha1.MetricSource
+		now      time.Time
+		settled  time.Time
+	)
+
+	BeforeEach(func() {
+		requests.Store(0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests.Add(1)
+			fmt.Fprintf(w, "test_metric 8\n")
+		}))
+		u, err := url.Parse(server.URL)
+		Expect(err).To(BeNil())
+		source = autoscalingv1alpha1.MetricSource{
+			MetricSourceType: autoscalingv1alpha1.POD,
+			ProtocolType:     autoscalingv1alpha1.HTTP,
+			Path:             "metrics",
+			Port:             u.Port(),
+			TargetMetric:     "test_metric",
+		}
+		now = time.Now()
+		settled = now.Add(-time.Hour)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	// startupStorm returns the pods of a target right after a scale up from 2 to 20 replicas.
+	startupStorm := func(refused int) []corev1.Pod {
+		var pods []corev1.Pod
+		for i := 0; i < 2; i++ {
+			pods = append(pods, newScrapeTestPod(fmt.Sprintf("settled-%d", i), "127.0.0.1", true, settled))
+		}
+		for i := 0; i < 12; i++ {
+			ip := ""
+			if i%2 == 0 {
+				ip = "127.0.0.1"
+			}
+			pods = append(pods, newScrapeTestPod(fmt.Sprintf("starting-%d", i), ip, false, now))
+		}
+		for i := 0; i < 6-refused; i++ {
+			pods = append(pods, newScrapeTestPod(fmt.Sprintf("warming-%d", i), "127.0.0.1", true, now.Add(-5*time.Second)))
+		}
+		// nothing listens on the port of the metrics server at this address.
+		for i := 0; i < refused; i++ {
+			pods = append(pods, newScrapeTestPod(fmt.Sprintf("refused-%d", i), "127.0.0.2", true, settled))
+		}
+		return pods
+	}
+
+	It("should only scrape the pods which are ready and out of the grace period", func() {
+		skippedUnready := scrapeCount(scrapeResultSkipped, skipReasonUnready)
+		skippedWarmingUp := scrapeCount(scrapeResultSkipped, skipReasonWarmingUp)
+		succeeded := scrapeCount(scrapeResultSuccess, "")
+
+		metrics, summary, err := GetMetricsFromPods(context.Background(), NewRestMetricsFetcher(), startupStorm(0), source, now)
+		Expect(err).To(BeNil())
+		Expect(metrics).To(Equal([]float64{8, 8}))
+		Expect(requests.Load()).To(Equal(int64(2)))
+		Expect(summary.String()).To(Equal("scraped 2 pods, skipped 18 pods: 12 unready, 6 warming up"))
+
+		Expect(scrapeCount(scrapeResultSkipped, skipReasonUnready) - skippedUnready).To(Equal(12.0))
+		Expect(scrapeCount(scrapeResultSkipped, skipReasonWarmingUp) - skippedWarmingUp).To(Equal(6.0))
+		Expect(scrapeCount(scrapeResultSuccess, "") - succeeded).To(Equal(2.0))
+	})
+
+	It("should aggregate the failed scrapes into one error", func() {
+		refused := scrapeCount(scrapeResultFailed, failReasonConnectionRefused)
+
+		metrics, summary, err := GetMetricsFromPods(context.Background(), NewRestMetricsFetcher(), startupStorm(2), source, now)
+		// the metrics of the scraped pods are returned with the error.
+		Expect(metrics).To(Equal([]float64{8, 8}))
+		var partial *PartialMetricsError
+		Expect(errors.As(err, &partial)).To(BeTrue())
+		Expect(err).To(MatchError(syscall.ECONNREFUSED))
+		Expect(err.Error()).To(ContainSubstring("scraped 2 pods, skipped 16 pods: 12 unready, 4 warming up, failed 2 pods: 2 connection refused"))
+		Expect(summary.Failed).To(Equal(map[string]int{failReasonConnectionRefused: 2}))
+		Expect(scrapeCount(scrapeResultFailed, failReasonConnectionRefused) - refused).To(Equal(2.0))
+	})
+
+	It("should fail when no pod could be scraped", func() {
+		pods := []corev1.Pod{
+			newScrapeTestPod("warming-0", "127.0.0.1", true, now),
+			newScrapeTestPod("starting-0", "", false, now),
+		}
+		_, summary, err := GetMetricsFromPods(context.Background(), NewRestMetricsFetcher(), pods, source, now)
+		Expect(err).To(MatchError(ContainSubstring("no pod metrics available")))
+		Expect(summary.Scraped).To(Equal(0))
+		Expect(requests.Load()).To(Equal(int64(0)))
+
+		metrics, _, err := GetMetricsFromPods(context.Background(), NewRestMetricsFetcher(), nil, source, now)
+		Expect(err).To(BeNil())
+		Expect(metrics).To(BeEmpty())
+	})
+})
+
+var _ = Describe("scrapeLogger", func() {
+	It("should log an unchanged summary once per interval", func() {
+		var l scrapeLogger
+		storm := ScrapeSummary{Scraped: 2, Skipped: map[string]int{skipReasonUnready: 18}}
+		now := time.Now()
+
+		l.log(autoscalingv1alpha1.MetricSource{}, storm, now)
+		Expect(l.last).To(Equal("scraped 2 pods, skipped 18 pods: 18 unready"))
+		Expect(l.lastTime).To(Equal(now))
+
+		l.log(autoscalingv1alpha1.MetricSource{}, storm, now.Add(10*time.Second))
+		Expect(l.lastTime).To(Equal(now), "the unchanged summary is suppressed")
+
+		settling := ScrapeSummary{Scraped: 10, Skipped: map[string]int{skipReasonWarmingUp: 10}}
+		l.log(autoscalingv1alpha1.MetricSource{}, settling, now.Add(20*time.Second))
+		Expect(l.last).To(Equal("scraped 10 pods, skipped 10 pods: 10 warming up"))
+
+		l.log(autoscalingv1alpha1.MetricSource{}, settling, now.Add(20*time.Second+scrapeSummaryInterval))
+		Expect(l.lastTime).To(Equal(now.Add(20*time.Second + scrapeSummaryInterval)))
+	})
+})
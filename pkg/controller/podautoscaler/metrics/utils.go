@@ -19,38 +19,22 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/url"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	aibrixmetrics "github.com/vllm-project/aibrix/pkg/metrics"
 
 	corev1 "k8s.io/api/core/v1"
 )
 
+// ParseMetricFromBody parses the metric from the Prometheus text exposition of a pod, summing its series, e.g. the
+// series of the models a pod serves.
 func ParseMetricFromBody(body []byte, metricName string) (float64, error) {
-	lines := strings.Split(string(body), "\n")
-
-	for _, line := range lines {
-		if !strings.HasPrefix(line, "#") && strings.Contains(line, metricName) {
-			// format is `http_requests_total 1234.56`
-			parts := strings.Fields(line)
-			if len(parts) < 2 {
-				return 0, fmt.Errorf("unexpected format for metric %s", metricName)
-			}
-
-			// parse to float64
-			value, err := strconv.ParseFloat(parts[len(parts)-1], 64)
-			if err != nil {
-				return 0, fmt.Errorf("failed to parse metric value for %s: %v", metricName, err)
-			}
-
-			return value, nil
-		}
-	}
-	return 0, fmt.Errorf("metrics %s not found", metricName)
+	return aibrixmetrics.ParseMetricFromBody(body, metricName)
 }
 
 // GetResourceUtilizationRatio takes in a set of metrics, a set of matching requests,
@@ -98,28 +82,34 @@ func GetMetricUsageRatio(metrics PodMetricsInfo, targetUsage int64) (usageRatio
 	return float64(currentUsage) / float64(targetUsage), currentUsage
 }
 
+// fetchPodMetric scrapes the metric of the pod within PodScrapeTimeout, so that a pod which does not answer does not
+// block the scrape of the others.
+func fetchPodMetric(ctx context.Context, fetcher MetricFetcher, pod corev1.Pod, source autoscalingv1alpha1.MetricSource) (float64, error) {
+	if pod.Status.PodIP == "" {
+		return 0, fmt.Errorf("pod %s/%s has no IP", pod.Namespace, pod.Name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, PodScrapeTimeout)
+	defer cancel()
+	return fetcher.FetchPodMetrics(ctx, pod, source)
+}
+
+// GetPodContainerMetric scrapes the metric of the pod and returns it as a milli-value keyed by the pod name.
 func GetPodContainerMetric(ctx context.Context, fetcher MetricFetcher, pod corev1.Pod, source autoscalingv1alpha1.MetricSource) (PodMetricsInfo, time.Time, error) {
-	_, err := fetcher.FetchPodMetrics(ctx, pod, source)
+	value, err := fetchPodMetric(ctx, fetcher, pod, source)
 	currentTimestamp := time.Now()
 	if err != nil {
 		return nil, currentTimestamp, err
 	}
 
-	// TODO(jiaxin.shan): convert this raw metric to PodMetrics
-	return nil, currentTimestamp, nil
-}
-
-func GetMetricsFromPods(ctx context.Context, fetcher MetricFetcher, pods []corev1.Pod, source autoscalingv1alpha1.MetricSource) ([]float64, error) {
-	metrics := make([]float64, 0, len(pods))
-	for _, pod := range pods {
-		// TODO: Let's optimize the performance for multi-metrics later.
-		metric, err := fetcher.FetchPodMetrics(ctx, pod, source)
-		if err != nil {
-			return nil, err
-		}
-		metrics = append(metrics, metric)
+	metric := PodMetric{
+		Timestamp:   currentTimestamp,
+		Value:       int64(math.Round(value * 1000)),
+		MetricsName: source.TargetMetric,
+	}
+	if port, err := strconv.ParseInt(source.Port, 10, 32); err == nil {
+		metric.containerPort = int32(port)
 	}
-	return metrics, nil
+	return PodMetricsInfo{pod.Name: metric}, currentTimestamp, nil
 }
 
 func GetMetricFromSource(ctx context.Context, fetcher MetricFetcher, source autoscalingv1alpha1.MetricSource) (float64, error) {
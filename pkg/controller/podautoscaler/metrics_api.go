@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/metrics/pkg/client/custom_metrics"
+	"k8s.io/metrics/pkg/client/external_metrics"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+// newMetricsAPIClient creates the client of the custom and external metrics APIs. The APIs are only queried by the
+// PodAutoscalers declaring such a metric source, so the cluster does not need to serve them otherwise.
+func newMetricsAPIClient(config *rest.Config, mapper apimeta.RESTMapper) (*metrics.MetricsAPIClient, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	customMetricsClient := custom_metrics.NewForConfig(config, mapper, custom_metrics.NewAvailableAPIsGetter(discoveryClient))
+	externalMetricsClient, err := external_metrics.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create external metrics client: %w", err)
+	}
+	return metrics.NewMetricsAPIClient(customMetricsClient, externalMetricsClient), nil
+}
+
+// isMetricsAPISource reports whether the metrics of the source are served by the Kubernetes metrics APIs.
+func isMetricsAPISource(sourceType autoscalingv1alpha1.MetricSourceType) bool {
+	switch sourceType {
+	case autoscalingv1alpha1.CUSTOM, autoscalingv1alpha1.OBJECT, autoscalingv1alpha1.EXTERNAL:
+		return true
+	}
+	return false
+}
+
+// getMetricsFromMetricsAPI returns the metric values of the source from the Kubernetes metrics APIs: the metric of
+// each pod of the target for a custom source, the metric describing the scale target for an object source, and the
+// metric of the namespace for an external source.
+func (r *PodAutoscalerReconciler) getMetricsFromMetricsAPI(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler, podSelector labels.Selector, source autoscalingv1alpha1.MetricSource) ([]float64, error) {
+	if r.metricsAPIClient == nil {
+		return nil, fmt.Errorf("the metrics APIs are not configured for metric source %s", source.MetricSourceType)
+	}
+
+	switch source.MetricSourceType {
+	case autoscalingv1alpha1.CUSTOM:
+		return r.metricsAPIClient.GetPodMetrics(ctx, pa.Namespace, podSelector, source.TargetMetric)
+	case autoscalingv1alpha1.OBJECT:
+		targetRef := pa.Spec.ScaleTargetRef
+		groupKind := schema.FromAPIVersionAndKind(targetRef.APIVersion, targetRef.Kind).GroupKind()
+		value, err := r.metricsAPIClient.GetObjectMetric(ctx, pa.Namespace, groupKind, targetRef.Name, source.TargetMetric)
+		if err != nil {
+			return nil, err
+		}
+		return []float64{value}, nil
+	case autoscalingv1alpha1.EXTERNAL:
+		value, err := r.metricsAPIClient.GetExternalMetric(ctx, pa.Namespace, source.TargetMetric, labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		return []float64{value}, nil
+	default:
+		return nil, fmt.Errorf("unsupported metric source type: %s", source.MetricSourceType)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"strings"
+	"testing"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func TestReconcileMetricsAPIUnavailable(t *testing.T) {
+	testCases := []struct {
+		name            string
+		sourceType      autoscalingv1alpha1.MetricSourceType
+		apiClient       *metrics.MetricsAPIClient
+		expectedMessage string
+	}{
+		{
+			name:            "custom metrics API not configured",
+			sourceType:      autoscalingv1alpha1.CUSTOM,
+			apiClient:       metrics.NewMetricsAPIClient(nil, nil),
+			expectedMessage: "custom metrics API client is not configured",
+		},
+		{
+			name:            "custom metrics API of an object not configured",
+			sourceType:      autoscalingv1alpha1.OBJECT,
+			apiClient:       metrics.NewMetricsAPIClient(nil, nil),
+			expectedMessage: "custom metrics API client is not configured",
+		},
+		{
+			name:            "external metrics API not configured",
+			sourceType:      autoscalingv1alpha1.EXTERNAL,
+			apiClient:       metrics.NewMetricsAPIClient(nil, nil),
+			expectedMessage: "external metrics API client is not configured",
+		},
+		{
+			name:            "metrics APIs not configured",
+			sourceType:      autoscalingv1alpha1.EXTERNAL,
+			expectedMessage: "the metrics APIs are not configured for metric source external",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := newTestAPAObjects(2, "8000", nil)
+			pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+			pa.Spec.MetricsSources[0].MetricSourceType = tc.sourceType
+			r, recorder := newTestReconciler(t, objs...)
+			r.metricsAPIClient = tc.apiClient
+
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("expected unavailable metrics not to fail the reconcile, got %v", err)
+			}
+			if replicas := getTestDeploymentReplicas(t, r); replicas != 2 {
+				t.Errorf("expected the replicas to be held at 2, got %d", replicas)
+			}
+			if count := countEvents(recorder, "FailedGetMetrics"); count != 1 {
+				t.Errorf("expected one FailedGetMetrics event, got %d", count)
+			}
+			cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.ScalingActive)
+			if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "FailedGetMetrics" {
+				t.Fatalf("expected ScalingActive=False with reason FailedGetMetrics, got %+v", cond)
+			}
+			if !strings.Contains(cond.Message, tc.expectedMessage) {
+				t.Errorf("expected the condition to report %q, got %q", tc.expectedMessage, cond.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+// metricTarget is a metric source of a PodAutoscaler. Each metric source has its own scaler, keyed by the metric.
+type metricTarget struct {
+	key    metrics.NamespaceNameMetric
+	source autoscalingv1alpha1.MetricSource
+}
+
+// getMetricTargets returns the metric targets of the PodAutoscaler, in the order of its metric sources.
+func getMetricTargets(pa *autoscalingv1alpha1.PodAutoscaler) ([]metricTarget, error) {
+	metricKeys, err := metrics.NewNamespaceNameMetrics(pa)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]metricTarget, 0, len(metricKeys))
+	for i, metricKey := range metricKeys {
+		targets = append(targets, metricTarget{key: metricKey, source: pa.Spec.MetricsSources[i]})
+	}
+	return targets, nil
+}
+
+// forMetricSource returns a copy of the PodAutoscaler holding only the given metric source. The scaling context of
+// the scaler of a metric, e.g. its target value, is built from the copy.
+func forMetricSource(pa autoscalingv1alpha1.PodAutoscaler, source autoscalingv1alpha1.MetricSource) autoscalingv1alpha1.PodAutoscaler {
+	pa.Spec.MetricsSources = []autoscalingv1alpha1.MetricSource{source}
+	return pa
+}
+
+// updateMetricsForTargets updates the metrics of each metric target and returns the targets whose metrics were
+// updated. Like the HPA, the target is still scaled on the other metrics when some fail, a warning event is emitted
+// for each failed one and the MetricsDegraded condition lists them. The metrics are only unavailable when none of
+// them could be updated, in which case the ScalingActive condition is set to false and the error is returned. The partial metrics error reports a pod
+// source whose metrics are missing for some pods.
+func (r *PodAutoscalerReconciler) updateMetricsForTargets(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured, targets []metricTarget, currentReplicas int) ([]metricTarget, *metrics.PartialMetricsError, error) {
+	var validTargets []metricTarget
+	var partialMetrics *metrics.PartialMetricsError
+	var errs []error
+	var reasons []string
+	for _, target := range targets {
+		start := time.Now()
+		err := r.updateMetricsForScale(ctx, forMetricSource(*pa, target.source), scale, target.key, target.source, currentReplicas)
+		podAutoscalerMetricScrapeDuration.WithLabelValues(string(target.source.MetricSourceType)).Observe(time.Since(start).Seconds())
+		if deadlineExceeded(ctx) {
+			return nil, nil, ctx.Err()
+		}
+		var partial *metrics.PartialMetricsError
+		if errors.As(err, &partial) {
+			if partialMetrics == nil {
+				partialMetrics = partial
+			}
+			err = nil
+		}
+		if err != nil {
+			reason := "FailedUpdateMetrics"
+			if isMetricsAPISource(target.source.MetricSourceType) {
+				reason = "FailedGetMetrics"
+			} else if target.source.MetricSourceType == autoscalingv1alpha1.REDIS {
+				reason = "FailedGetRedisMetrics"
+			}
+			if len(targets) > 1 {
+				err = fmt.Errorf("metric %s: %v", target.key.MetricName, err)
+			}
+			errs = append(errs, err)
+			reasons = append(reasons, reason)
+			continue
+		}
+		validTargets = append(validTargets, target)
+	}
+
+	if len(validTargets) == 0 {
+		metricsErr := fmt.Errorf("failed to update metrics for scale target reference: %v", errors.Join(errs...))
+		r.setMetricsUnavailable(pa, reasons[0], metricsErr)
+		return nil, nil, metricsErr
+	}
+	for i, err := range errs {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, reasons[i],
+			"failed to update metrics, scaling on the other metrics: %v", err)
+	}
+	if len(errs) > 0 {
+		setCondition(pa, autoscalingv1alpha1.MetricsDegraded, metav1.ConditionTrue, reasons[0],
+			"the target is scaled on %d of %d metrics, failed to update: %v", len(validTargets), len(targets), errors.Join(errs...))
+	} else if apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.MetricsDegraded) != nil {
+		setCondition(pa, autoscalingv1alpha1.MetricsDegraded, metav1.ConditionFalse, "AllMetricsAvailable",
+			"the metrics of all the %d metric sources were updated", len(targets))
+	}
+	return validTargets, partialMetrics, nil
+}
+
+// newMetricStatus returns the status of the metric of the target evaluated by the scaling algorithm at now, over the
+// ready pods of the target.
+func newMetricStatus(target metricTarget, scaleResult scaler.ScaleResult, readyPods int64, now time.Time) autoscalingv1alpha1.MetricStatus {
+	evaluated := metav1.NewTime(now)
+	status := autoscalingv1alpha1.MetricStatus{
+		Name:               target.key.MetricName,
+		CurrentValue:       formatMetricValue(scaleResult.ObservedValue),
+		DesiredReplicas:    scaleResult.DesiredPodCount,
+		TargetValue:        target.source.TargetValue,
+		ReadyPods:          int32(readyPods),
+		LastEvaluationTime: &evaluated,
+	}
+	if readyPods > 0 {
+		status.AverageValue = formatMetricValue(scaleResult.ObservedValue / float64(readyPods))
+	}
+	return status
+}
+
+func formatMetricValue(value float64) string {
+	return resource.NewMilliQuantity(int64(value*1000), resource.DecimalSI).String()
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+// newTestMultiMetricAPAObjects creates the APA test objects with a second, domain metric source targeting 2.
+func newTestMultiMetricAPAObjects() []client.Object {
+	objs := newTestAPAObjects(2, "8000", nil)
+	pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+	pa.Spec.MetricsSources = append(pa.Spec.MetricsSources, autoscalingv1alpha1.MetricSource{
+		MetricSourceType: autoscalingv1alpha1.DOMAIN,
+		ProtocolType:     autoscalingv1alpha1.HTTP,
+		Endpoint:         "queue.example.com",
+		Path:             "metrics",
+		TargetMetric:     "queue_length",
+		TargetValue:      "2",
+	})
+	return objs
+}
+
+func TestReconcileMultipleMetrics(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		podMetric            float64
+		sourceMetric         float64
+		expectedReplicas     int32
+		expectedMetrics      []autoscalingv1alpha1.MetricStatus
+		expectedActiveMetric string
+		expectedFailedEvents int
+	}{
+		{
+			// test_metric is at its target, queue_length is twice its target and wins.
+			name:             "highest recommendation wins",
+			podMetric:        4,
+			sourceMetric:     8,
+			expectedReplicas: 4,
+			expectedMetrics: []autoscalingv1alpha1.MetricStatus{
+				{Name: "test_metric", CurrentValue: "8", DesiredReplicas: 2, AverageValue: "4", TargetValue: "4", ReadyPods: 2},
+				{Name: "queue_length", CurrentValue: "8", DesiredReplicas: 4, AverageValue: "4", TargetValue: "2", ReadyPods: 2},
+			},
+			expectedActiveMetric: "queue_length",
+		},
+		{
+			// the target is scaled on test_metric while queue_length is unavailable.
+			name:             "scales on the available metric",
+			podMetric:        16,
+			expectedReplicas: 4,
+			expectedMetrics: []autoscalingv1alpha1.MetricStatus{
+				{Name: "test_metric", CurrentValue: "32", DesiredReplicas: 4, AverageValue: "16", TargetValue: "4", ReadyPods: 2},
+			},
+			expectedActiveMetric: "test_metric",
+			expectedFailedEvents: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, recorder := newTestReconciler(t, newTestMultiMetricAPAObjects()...)
+			fetcher := metrics.NewFakeMetricFetcher()
+			fetcher.SetPodMetric("test-pod-0", tc.podMetric)
+			fetcher.SetPodMetric("test-pod-1", tc.podMetric)
+			if tc.sourceMetric != 0 {
+				fetcher.SetSourceMetric("queue.example.com", tc.sourceMetric)
+			}
+			r.metricFetcher = fetcher
+
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			if count := countEvents(recorder, "FailedUpdateMetrics"); count != tc.expectedFailedEvents {
+				t.Errorf("expected %d FailedUpdateMetrics events, got %d", tc.expectedFailedEvents, count)
+			}
+			pa := getTestPodAutoscaler(t, r)
+			for i := range pa.Status.CurrentMetrics {
+				if pa.Status.CurrentMetrics[i].LastEvaluationTime == nil {
+					t.Errorf("expected the evaluation time of metric %s", pa.Status.CurrentMetrics[i].Name)
+				}
+				pa.Status.CurrentMetrics[i].LastEvaluationTime = nil
+			}
+			if !reflect.DeepEqual(pa.Status.CurrentMetrics, tc.expectedMetrics) {
+				t.Errorf("expected current metrics %+v, got %+v", tc.expectedMetrics, pa.Status.CurrentMetrics)
+			}
+			cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ScalingActive)
+			if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, tc.expectedActiveMetric) {
+				t.Errorf("expected ScalingActive=True naming %s, got %+v", tc.expectedActiveMetric, cond)
+			}
+		})
+	}
+}
+
+func TestReconcileDuplicateMetrics(t *testing.T) {
+	objs := newTestMultiMetricAPAObjects()
+	pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+	pa.Spec.MetricsSources[1].TargetMetric = pa.Spec.MetricsSources[0].TargetMetric
+	r, recorder := newTestReconciler(t, objs...)
+
+	if err := reconcileTestPodAutoscaler(t, r); err == nil {
+		t.Fatalf("expected duplicate target metrics to fail the reconcile")
+	}
+	if count := countEvents(recorder, "FailedGetMetricKey"); count != 1 {
+		t.Errorf("expected one FailedGetMetricKey event, got %d", count)
+	}
+}
+
+func TestReconcileAPACurrentMetrics(t *testing.T) {
+	var metric atomic.Int64
+	metric.Store(4)
+	r, _ := newTestReconciler(t, newTestAPAObjects(2, newTestMetricsServer(t, &metric), nil)...)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	pa := getTestPodAutoscaler(t, r)
+	if len(pa.Status.CurrentMetrics) != 1 {
+		t.Fatalf("expected the status of test_metric, got %+v", pa.Status.CurrentMetrics)
+	}
+	current := pa.Status.CurrentMetrics[0]
+	if current.AverageValue != "4" || current.TargetValue != "4" || current.ReadyPods != 2 || current.LastEvaluationTime == nil {
+		t.Errorf("expected test_metric averaging 4 over 2 pods against the target 4, got %+v", current)
+	}
+
+	// the metrics evaluated to the same values again do not write the status.
+	time.Sleep(1100 * time.Millisecond)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if resourceVersion := getTestPodAutoscaler(t, r).ResourceVersion; resourceVersion != pa.ResourceVersion {
+		t.Errorf("expected the status not to be written, the resource version changed from %s to %s", pa.ResourceVersion, resourceVersion)
+	}
+
+	// the last evaluated metrics are kept while the metrics are unavailable.
+	metric.Store(-1)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if metrics := getTestPodAutoscaler(t, r).Status.CurrentMetrics; !reflect.DeepEqual(metrics, pa.Status.CurrentMetrics) {
+		t.Errorf("expected the metrics %+v to be kept, got %+v", pa.Status.CurrentMetrics, metrics)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+// checkNoReadyPods evaluates the NoReadyPodsPolicy of a target which has replicas but no ready pods, in which
+// case there are no metrics to drive the scaling algorithm. It returns whether the policy takes over the scaling
+// decision and the replicas it proposes. The policy only applies once the target has had no ready pods for the
+// grace period, until then the current replicas are held so that brief readiness flaps do not resize the target.
+func (r *PodAutoscalerReconciler) checkNoReadyPods(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured, currentReplicas, minReplicas int32, now time.Time) (bool, int32, error) {
+	if currentReplicas == 0 {
+		if apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.NoReadyPods) != nil {
+			setCondition(pa, autoscalingv1alpha1.NoReadyPods, metav1.ConditionFalse, "NoReplicas", "the target has no replicas")
+		}
+		return false, 0, nil
+	}
+
+	labelsSelector, err := getScalePodSelector(scale)
+	if err != nil {
+		return false, 0, err
+	}
+	readyPods, err := scaler.GetReadyPodsCount(ctx, r.Client, pa.Namespace, labelsSelector)
+	if err != nil {
+		return false, 0, fmt.Errorf("error getting ready pods count: %w", err)
+	}
+	if readyPods > 0 {
+		if apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.NoReadyPods) != nil {
+			setCondition(pa, autoscalingv1alpha1.NoReadyPods, metav1.ConditionFalse, "ReadyPodsAvailable", "the target has %d ready pods", readyPods)
+		}
+		return false, 0, nil
+	}
+
+	policy := getNoReadyPodsPolicy(pa)
+	gracePeriod := getNoReadyPodsGracePeriod(pa)
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.NoReadyPods)
+	if cond == nil || cond.Status != metav1.ConditionTrue {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, "NoReadyPods",
+			"none of the %d replicas of the target is ready, the %s policy applies after %v", currentReplicas, policy, gracePeriod)
+		setCondition(pa, autoscalingv1alpha1.NoReadyPods, metav1.ConditionTrue, "WaitingForGracePeriod",
+			"none of the %d replicas of the target is ready, holding the current replicas for %v", currentReplicas, gracePeriod)
+		cond = apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.NoReadyPods)
+		// the grace period is measured against now, so the transition is stamped from the same clock.
+		cond.LastTransitionTime = metav1.NewTime(now)
+	}
+
+	desiredReplicas := currentReplicas
+	if now.Sub(cond.LastTransitionTime.Time) >= gracePeriod {
+		switch policy {
+		case autoscalingv1alpha1.NoReadyPodsScaleToMin:
+			desiredReplicas = minReplicas
+		case autoscalingv1alpha1.NoReadyPodsScaleToMax:
+			desiredReplicas = pa.Spec.MaxReplicas
+		}
+		setCondition(pa, autoscalingv1alpha1.NoReadyPods, metav1.ConditionTrue, string(policy),
+			"none of the %d replicas of the target is ready, the %s policy applies", currentReplicas, policy)
+	}
+
+	// keep the replicas within the <min, max> range. A target with no ready pods is never scaled to zero,
+	// since it would stop reporting whether its pods recover.
+	if desiredReplicas < minReplicas {
+		desiredReplicas = minReplicas
+	}
+	if desiredReplicas < 1 {
+		desiredReplicas = 1
+	}
+	if desiredReplicas > pa.Spec.MaxReplicas {
+		desiredReplicas = pa.Spec.MaxReplicas
+	}
+	return true, desiredReplicas, nil
+}
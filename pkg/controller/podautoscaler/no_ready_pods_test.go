@@ -0,0 +1,235 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	testingclock "k8s.io/utils/clock/testing"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+// expireNoReadyPodsGracePeriod moves the NoReadyPods transition of the test PodAutoscaler back in time.
+func expireNoReadyPodsGracePeriod(t *testing.T, r *PodAutoscalerReconciler, d time.Duration) {
+	t.Helper()
+	pa := getTestPodAutoscaler(t, r)
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.NoReadyPods)
+	if cond == nil {
+		t.Fatalf("expected the NoReadyPods condition to be set")
+	}
+	cond.LastTransitionTime = metav1.NewTime(cond.LastTransitionTime.Add(-d))
+	if err := r.Status().Update(context.Background(), pa); err != nil {
+		t.Fatalf("failed to update PodAutoscaler status: %v", err)
+	}
+}
+
+func TestReconcileNoReadyPodsPolicy(t *testing.T) {
+	minReplicas := int32(2)
+	testCases := []struct {
+		name             string
+		policy           string
+		expectedReplicas int32
+		expectedReason   string
+	}{
+		{
+			name:             "default policy holds the replicas",
+			expectedReplicas: 4,
+			expectedReason:   "Hold",
+		},
+		{
+			name:             "hold",
+			policy:           "Hold",
+			expectedReplicas: 4,
+			expectedReason:   "Hold",
+		},
+		{
+			name:             "scale to min",
+			policy:           "ScaleToMin",
+			expectedReplicas: 2,
+			expectedReason:   "ScaleToMin",
+		},
+		{
+			name:             "scale to max",
+			policy:           "ScaleToMax",
+			expectedReplicas: 10,
+			expectedReason:   "ScaleToMax",
+		},
+		{
+			name:             "invalid policy falls back to hold",
+			policy:           "ScaleToInfinity",
+			expectedReplicas: 4,
+			expectedReason:   "Hold",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{scalingcontext.NoReadyPodsGracePeriodLabel: "1m"}
+			if tc.policy != "" {
+				annotations[scalingcontext.NoReadyPodsPolicyLabel] = tc.policy
+			}
+			r, recorder := newTestReconciler(t, newTestDeployment(4), newTestPodAutoscaler(&minReplicas, 10, annotations),
+				newTestPod("test-pod-1", false), newTestPod("test-pod-2", false))
+
+			// within the grace period the replicas are held.
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			if replicas := getTestDeploymentReplicas(t, r); replicas != 4 {
+				t.Errorf("expected the deployment to stay at 4 replicas during the grace period, got %d", replicas)
+			}
+			cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.NoReadyPods)
+			if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "WaitingForGracePeriod" {
+				t.Fatalf("expected NoReadyPods=True with reason WaitingForGracePeriod, got %+v", cond)
+			}
+
+			expireNoReadyPodsGracePeriod(t, r, 2*time.Minute)
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			cond = apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.NoReadyPods)
+			if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != tc.expectedReason {
+				t.Fatalf("expected NoReadyPods=True with reason %s, got %+v", tc.expectedReason, cond)
+			}
+
+			// the metrics of unready pods are never fetched, so no misleading metric failures are reported.
+			events := []string{}
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			for _, e := range events {
+				if strings.Contains(e, "FailedUpdateMetrics") || strings.Contains(e, "FailedComputeMetricsReplicas") {
+					t.Errorf("unexpected metrics failure event: %s", e)
+				}
+			}
+			noReadyPodsEvents := 0
+			for _, e := range events {
+				if strings.Contains(e, "NoReadyPods") {
+					noReadyPodsEvents++
+				}
+			}
+			if noReadyPodsEvents != 1 {
+				t.Errorf("expected exactly one NoReadyPods event, got %d", noReadyPodsEvents)
+			}
+		})
+	}
+}
+
+func TestCheckNoReadyPodsHysteresis(t *testing.T) {
+	annotations := map[string]string{
+		scalingcontext.NoReadyPodsPolicyLabel:      "ScaleToMax",
+		scalingcontext.NoReadyPodsGracePeriodLabel: "1m",
+	}
+	pod := newTestPod("test-pod-1", false)
+	r, _ := newTestReconciler(t, newTestDeployment(4), pod)
+	pa := newTestPodAutoscaler(nil, 10, annotations)
+
+	scale := &unstructured.Unstructured{}
+	scale.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testDeployName}, scale); err != nil {
+		t.Fatalf("failed to get scale: %v", err)
+	}
+	setPodReady := func(ready bool) {
+		t.Helper()
+		pod.Status.Conditions = newTestPod(pod.Name, ready).Status.Conditions
+		if err := r.Status().Update(context.Background(), pod); err != nil {
+			t.Fatalf("failed to update pod: %v", err)
+		}
+	}
+	check := func(now time.Time) (bool, int32) {
+		t.Helper()
+		noReadyPods, replicas, err := r.checkNoReadyPods(context.Background(), pa, scale, 4, 1, now)
+		if err != nil {
+			t.Fatalf("checkNoReadyPods failed: %v", err)
+		}
+		return noReadyPods, replicas
+	}
+
+	start := time.Now()
+	if noReadyPods, replicas := check(start); !noReadyPods || replicas != 4 {
+		t.Fatalf("expected the replicas to be held, got noReadyPods=%v replicas=%d", noReadyPods, replicas)
+	}
+	// the target has had no ready pods for 50s.
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.NoReadyPods)
+	cond.LastTransitionTime = metav1.NewTime(cond.LastTransitionTime.Add(-50 * time.Second))
+
+	// a brief readiness flap resets the grace period.
+	setPodReady(true)
+	if noReadyPods, _ := check(start); noReadyPods {
+		t.Fatalf("expected the policy not to apply with a ready pod")
+	}
+	if cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.NoReadyPods); cond == nil || cond.Status != metav1.ConditionFalse {
+		t.Fatalf("expected NoReadyPods=False, got %+v", cond)
+	}
+	setPodReady(false)
+	if noReadyPods, replicas := check(start.Add(30 * time.Second)); !noReadyPods || replicas != 4 {
+		t.Fatalf("expected the replicas to be held after the flap, got noReadyPods=%v replicas=%d", noReadyPods, replicas)
+	}
+
+	if noReadyPods, replicas := check(start.Add(2 * time.Minute)); !noReadyPods || replicas != 10 {
+		t.Fatalf("expected the ScaleToMax policy to apply after the grace period, got noReadyPods=%v replicas=%d", noReadyPods, replicas)
+	}
+}
+
+// TestReconcileNoReadyPodsGracePeriodClock checks the grace period is measured with the clock of the reconciler,
+// which is far from the wall time here.
+func TestReconcileNoReadyPodsGracePeriodClock(t *testing.T) {
+	annotations := map[string]string{
+		scalingcontext.NoReadyPodsPolicyLabel:      "ScaleToMax",
+		scalingcontext.NoReadyPodsGracePeriodLabel: "1m",
+	}
+	r, _ := newTestReconciler(t, newTestDeployment(4), newTestPodAutoscaler(nil, 10, annotations), newTestPod("test-pod-1", false))
+	clock := testingclock.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	r.clock = clock
+
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.NoReadyPods)
+	if cond == nil || !cond.LastTransitionTime.Time.Equal(clock.Now()) {
+		t.Fatalf("expected the NoReadyPods transition at %v, got %+v", clock.Now(), cond)
+	}
+
+	clock.Step(30 * time.Second)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 4 {
+		t.Errorf("expected the deployment to stay at 4 replicas during the grace period, got %d", replicas)
+	}
+
+	clock.Step(time.Minute)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 10 {
+		t.Errorf("expected the ScaleToMax policy to apply once the grace period passed on the clock, got %d replicas", replicas)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+const (
+	// DefaultOrphanSweepInterval is how often the generated resources are swept for orphans after the startup sweep.
+	DefaultOrphanSweepInterval = time.Hour
+
+	// orphanSweepQPS and orphanSweepBurst bound the writes of a sweep, a sweep after an upgrade may find many
+	// orphans at once.
+	orphanSweepQPS   = 1
+	orphanSweepBurst = 5
+)
+
+var orphanedResourcesCleaned = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_orphaned_resources_cleaned_total",
+	Help: "Number of orphaned resources generated by the PodAutoscaler controller that were deleted or adopted, by kind.",
+}, []string{"kind"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(orphanedResourcesCleaned)
+}
+
+// orphanSweeper deletes or adopts the HPAs generated by the PodAutoscaler controller which no PodAutoscaler
+// controls any more, e.g. after the owner reference was lost in a CRD upgrade or the PodAutoscaler was recreated.
+// The reconcile only looks at the HPA of the PodAutoscalers it is asked about, the sweep lists all of them.
+type orphanSweeper struct {
+	r        *PodAutoscalerReconciler
+	interval time.Duration
+	// dryRun only logs the orphans the sweep would delete or adopt.
+	dryRun  bool
+	limiter flowcontrol.RateLimiter
+}
+
+var _ manager.LeaderElectionRunnable = &orphanSweeper{}
+
+func newOrphanSweeper(r *PodAutoscalerReconciler, interval time.Duration, dryRun bool) *orphanSweeper {
+	if interval <= 0 {
+		interval = DefaultOrphanSweepInterval
+	}
+	return &orphanSweeper{
+		r:        r,
+		interval: interval,
+		dryRun:   dryRun,
+		limiter:  flowcontrol.NewTokenBucketRateLimiter(orphanSweepQPS, orphanSweepBurst),
+	}
+}
+
+// NeedLeaderElection only runs the sweep on the leader, the other replicas would race it over the same orphans.
+func (s *orphanSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Start sweeps once the manager starts and then every interval until ctx is done.
+func (s *orphanSweeper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.sweep(ctx); err != nil {
+			klog.ErrorS(err, "Failed to sweep the orphaned resources of pod autoscalers")
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// sweep deletes or adopts every orphaned HPA labeled as generated by the PodAutoscaler controller.
+func (s *orphanSweeper) sweep(ctx context.Context) error {
+	hpaList := &autoscalingv2.HorizontalPodAutoscalerList{}
+	if err := s.r.List(ctx, hpaList, client.MatchingLabels{HPAManagedByLabelKey: HPAManagedByLabelValue}); err != nil {
+		return fmt.Errorf("failed to list the generated HPAs: %v", err)
+	}
+	var errs []error
+	for i := range hpaList.Items {
+		if err := s.sweepHPA(ctx, &hpaList.Items[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to sweep %d orphaned HPAs, first error: %v", len(errs), errs[0])
+	}
+	return nil
+}
+
+// sweepHPA adopts the HPA if the PodAutoscaler it was generated for still uses the HPA strategy, and deletes it
+// otherwise. The PodAutoscaler is the controller owner of the HPA, or the one the name of the HPA derives from if
+// the owner reference was lost.
+func (s *orphanSweeper) sweepHPA(ctx context.Context, hpa *autoscalingv2.HorizontalPodAutoscaler) error {
+	paName := strings.TrimSuffix(hpa.Name, "-hpa")
+	owner := metav1.GetControllerOf(hpa)
+	if owner != nil {
+		if owner.Kind != "PodAutoscaler" || !strings.HasPrefix(owner.APIVersion, autoscalingv1alpha1.GroupVersion.Group+"/") {
+			// the HPA was taken over by another controller.
+			return nil
+		}
+		paName = owner.Name
+	}
+
+	pa := &autoscalingv1alpha1.PodAutoscaler{}
+	err := s.r.Get(ctx, types.NamespacedName{Namespace: hpa.Namespace, Name: paName}, pa)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get the PodAutoscaler of HPA %s/%s: %v", hpa.Namespace, hpa.Name, err)
+	}
+	if err == nil && pa.Spec.ScalingStrategy == autoscalingv1alpha1.HPA && hpaName(pa) == hpa.Name {
+		if owner != nil && owner.UID == pa.UID {
+			return nil
+		}
+		return s.adoptHPA(ctx, pa, hpa)
+	}
+	// the PodAutoscaler was deleted, or it switched to another scaling strategy and the reconcile missed it.
+	return s.deleteHPA(ctx, hpa, paName, err == nil)
+}
+
+func (s *orphanSweeper) adoptHPA(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, hpa *autoscalingv2.HorizontalPodAutoscaler) error {
+	if s.dryRun {
+		klog.InfoS("Dry run: would adopt the orphaned HPA", "HPA", klog.KObj(hpa), "PodAutoscaler", klog.KObj(pa))
+		return nil
+	}
+	if err := controllerutil.SetControllerReference(pa, hpa, s.r.Scheme); err != nil {
+		return fmt.Errorf("failed to adopt HPA %s/%s: %v", hpa.Namespace, hpa.Name, err)
+	}
+	if err := s.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	if err := s.r.Update(ctx, hpa); err != nil {
+		return fmt.Errorf("failed to adopt HPA %s/%s: %v", hpa.Namespace, hpa.Name, err)
+	}
+	orphanedResourcesCleaned.WithLabelValues("HorizontalPodAutoscaler").Inc()
+	klog.InfoS("Adopted the orphaned HPA", "HPA", klog.KObj(hpa), "PodAutoscaler", klog.KObj(pa))
+	return nil
+}
+
+func (s *orphanSweeper) deleteHPA(ctx context.Context, hpa *autoscalingv2.HorizontalPodAutoscaler, paName string, paExists bool) error {
+	if s.dryRun {
+		klog.InfoS("Dry run: would delete the orphaned HPA", "HPA", klog.KObj(hpa), "podAutoscaler", paName, "podAutoscalerExists", paExists)
+		return nil
+	}
+	if err := s.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	// the precondition keeps an HPA adopted or recreated since the list.
+	precondition := client.Preconditions{UID: &hpa.UID, ResourceVersion: &hpa.ResourceVersion}
+	if err := s.r.Delete(ctx, hpa, precondition); err != nil {
+		if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete HPA %s/%s: %v", hpa.Namespace, hpa.Name, err)
+	}
+	orphanedResourcesCleaned.WithLabelValues("HorizontalPodAutoscaler").Inc()
+	klog.InfoS("Deleted the orphaned HPA", "HPA", klog.KObj(hpa), "podAutoscaler", paName, "podAutoscalerExists", paExists)
+	return nil
+}
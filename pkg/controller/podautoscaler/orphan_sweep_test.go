@@ -0,0 +1,142 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+func newTestOrphanHPA(name string, owner *autoscalingv1alpha1.PodAutoscaler) *autoscalingv2.HorizontalPodAutoscaler {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{HPAManagedByLabelKey: HPAManagedByLabelValue},
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{MaxReplicas: 10},
+	}
+	if owner != nil {
+		hpa.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(owner, autoscalingv1alpha1.GroupVersion.WithKind("PodAutoscaler"))}
+	}
+	return hpa
+}
+
+func TestSweepOrphanedHPAs(t *testing.T) {
+	// the PodAutoscaler under test uses the HPA strategy, its HPA lost the owner reference.
+	pa := newTestHPAPodAutoscaler()
+	adopted := newTestOrphanHPA(hpaName(pa), nil)
+	// a deleted PodAutoscaler whose HPA was not garbage collected.
+	deletedPA := newTestHPAPodAutoscaler()
+	deletedPA.Name, deletedPA.UID = "deleted-pa", "deleted-pa-uid"
+	deleted := newTestOrphanHPA(hpaName(deletedPA), deletedPA)
+	// a PodAutoscaler recreated with another strategy, its HPA still refers to the previous object.
+	kpa := newTestPodAutoscaler(nil, 10, nil)
+	kpa.Name, kpa.UID = "kpa-pa", "kpa-pa-uid"
+	previousKPA := kpa.DeepCopy()
+	previousKPA.UID = "previous-kpa-pa-uid"
+	switched := newTestOrphanHPA(hpaName(kpa), previousKPA)
+	// an HPA controlled by its PodAutoscaler.
+	ownedPA := newTestHPAPodAutoscaler()
+	ownedPA.Name, ownedPA.UID = "owned-pa", "owned-pa-uid"
+	owned := newTestOrphanHPA(hpaName(ownedPA), ownedPA)
+	// HPAs which were not generated by the controller or were taken over by another one.
+	unmanaged := newTestOrphanHPA("unmanaged-hpa", nil)
+	unmanaged.Labels = nil
+	takenOver := newTestOrphanHPA("taken-over-hpa", nil)
+	controller := true
+	takenOver.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "apps/v1", Kind: "Deployment", Name: testDeployName, UID: "deployment-uid", Controller: &controller,
+	}}
+
+	newSweeper := func(dryRun bool) (*orphanSweeper, *PodAutoscalerReconciler) {
+		r, _ := newTestReconciler(t, pa, kpa, ownedPA, adopted, deleted, switched, owned, unmanaged, takenOver)
+		sweeper := newOrphanSweeper(r, 0, dryRun)
+		sweeper.limiter = flowcontrol.NewFakeAlwaysRateLimiter()
+		return sweeper, r
+	}
+	hpaExists := func(r *PodAutoscalerReconciler, name string) bool {
+		err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: name}, &autoscalingv2.HorizontalPodAutoscaler{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatalf("failed to get HPA %s: %v", name, err)
+		}
+		return err == nil
+	}
+	all := []string{adopted.Name, deleted.Name, switched.Name, owned.Name, unmanaged.Name, takenOver.Name}
+
+	testCases := []struct {
+		name            string
+		dryRun          bool
+		expectedDeleted []string
+		expectAdopted   bool
+		expectedCleaned float64
+	}{
+		{
+			// a dry run only logs.
+			name:   "dry run",
+			dryRun: true,
+		},
+		{
+			name:            "sweep",
+			expectedDeleted: []string{deleted.Name, switched.Name},
+			expectAdopted:   true,
+			expectedCleaned: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := testutil.ToFloat64(orphanedResourcesCleaned.WithLabelValues("HorizontalPodAutoscaler"))
+			sweeper, r := newSweeper(tc.dryRun)
+			if err := sweeper.sweep(context.Background()); err != nil {
+				t.Fatalf("sweep failed: %v", err)
+			}
+			for _, name := range all {
+				if exists, expected := hpaExists(r, name), !slices.Contains(tc.expectedDeleted, name); exists != expected {
+					t.Errorf("expected HPA %s to exist %t, got %t", name, expected, exists)
+				}
+			}
+			hpa, err := getTestHPA(r)
+			if err != nil {
+				t.Fatalf("failed to get HPA: %v", err)
+			}
+			if adopted := metav1.IsControlledBy(hpa, pa); adopted != tc.expectAdopted {
+				t.Errorf("expected the orphaned HPA to be adopted by its PodAutoscaler %t, got %+v", tc.expectAdopted, hpa.OwnerReferences)
+			}
+			if delta := testutil.ToFloat64(orphanedResourcesCleaned.WithLabelValues("HorizontalPodAutoscaler")) - before; delta != tc.expectedCleaned {
+				t.Errorf("expected %v cleaned HPAs, got %v", tc.expectedCleaned, delta)
+			}
+
+			// a second sweep finds no orphans.
+			if err := sweeper.sweep(context.Background()); err != nil {
+				t.Fatalf("sweep failed: %v", err)
+			}
+			if delta := testutil.ToFloat64(orphanedResourcesCleaned.WithLabelValues("HorizontalPodAutoscaler")) - before; delta != tc.expectedCleaned {
+				t.Errorf("expected the second sweep not to clean anything, got %v cleaned HPAs", delta)
+			}
+		})
+	}
+}
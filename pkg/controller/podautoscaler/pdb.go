@@ -0,0 +1,149 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	podutil "github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// pdbMinReplicas returns the replicas the PodDisruptionBudget requires out of the current replicas of the target.
+// The percentages are scaled against the current replicas and rounded up, as the disruption controller does:
+// a minAvailable percentage requires that share of the current replicas, while maxUnavailable only lets that
+// many replicas go at once.
+func pdbMinReplicas(pdb *policyv1.PodDisruptionBudget, currentReplicas int32) (int32, error) {
+	switch {
+	case pdb.Spec.MinAvailable != nil:
+		minAvailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, int(currentReplicas), true)
+		if err != nil {
+			return 0, fmt.Errorf("invalid minAvailable of PodDisruptionBudget %s: %v", pdb.Name, err)
+		}
+		return int32(minAvailable), nil
+	case pdb.Spec.MaxUnavailable != nil:
+		maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(currentReplicas), true)
+		if err != nil {
+			return 0, fmt.Errorf("invalid maxUnavailable of PodDisruptionBudget %s: %v", pdb.Name, err)
+		}
+		return currentReplicas - int32(maxUnavailable), nil
+	}
+	return 0, nil
+}
+
+// pdbSelectsPods returns whether the PodDisruptionBudget selects any of the pods. As in policy/v1, an empty
+// selector selects all the pods of the namespace and a nil one selects none.
+func pdbSelectsPods(pdb *policyv1.PodDisruptionBudget, pods []corev1.Pod) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid selector of PodDisruptionBudget %s: %v", pdb.Name, err)
+	}
+	for i := range pods {
+		if selector.Matches(labels.Set(pods[i].Labels)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getPDBFloor returns the highest replicas required by the PodDisruptionBudgets selecting the pods of the target,
+// and the name of the PodDisruptionBudget requiring them. It returns 0 if no PodDisruptionBudget selects the pods.
+// The PodDisruptionBudgets and the pods are read from the cache of the manager.
+func (r *PodAutoscalerReconciler) getPDBFloor(ctx context.Context, namespace string, scale *unstructured.Unstructured, currentReplicas int32) (int32, string, error) {
+	pdbList := &policyv1.PodDisruptionBudgetList{}
+	if err := r.List(ctx, pdbList, client.InNamespace(namespace)); err != nil {
+		return 0, "", fmt.Errorf("failed to list PodDisruptionBudgets: %v", err)
+	}
+	if len(pdbList.Items) == 0 {
+		return 0, "", nil
+	}
+
+	selector, err := getScalePodSelector(scale)
+	if err != nil {
+		return 0, "", err
+	}
+	podList, err := podutil.GetPodListByLabelSelector(ctx, r.Client, namespace, selector)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to list the pods of the target: %v", err)
+	}
+
+	floor, floorPDB := int32(0), ""
+	for i := range pdbList.Items {
+		pdb := &pdbList.Items[i]
+		selected, err := pdbSelectsPods(pdb, podList.Items)
+		if err != nil {
+			return 0, "", err
+		}
+		if !selected {
+			continue
+		}
+		minReplicas, err := pdbMinReplicas(pdb, currentReplicas)
+		if err != nil {
+			return 0, "", err
+		}
+		if minReplicas > floor {
+			floor, floorPDB = minReplicas, pdb.Name
+		}
+	}
+	return floor, floorPDB, nil
+}
+
+// constrainScaleDownByPDB clamps a scale down of the target to the replicas required by the PodDisruptionBudgets
+// selecting its pods, so that the deletion of the pods does not hang half-applied. The PDBConstrained condition
+// records whether the last scale down was clamped. The scale down is applied as is if the PodDisruptionBudgets
+// can not be evaluated.
+func (r *PodAutoscalerReconciler) constrainScaleDownByPDB(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured, currentReplicas, desiredReplicas int32) int32 {
+	if desiredReplicas >= currentReplicas {
+		return desiredReplicas
+	}
+	floor, pdbName, err := r.getPDBFloor(ctx, pa.Namespace, scale, currentReplicas)
+	if err != nil {
+		klog.ErrorS(err, "Failed to evaluate the PodDisruptionBudgets of the target", "PodAutoscaler", klog.KObj(pa))
+		return desiredReplicas
+	}
+	if floor > currentReplicas {
+		floor = currentReplicas
+	}
+	if desiredReplicas >= floor {
+		if apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.PDBConstrained) != nil {
+			setCondition(pa, autoscalingv1alpha1.PDBConstrained, metav1.ConditionFalse, "ScaleDownAllowed",
+				"the scale down to %d replicas is allowed by the PodDisruptionBudgets of the target", desiredReplicas)
+		}
+		return desiredReplicas
+	}
+
+	if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.PDBConstrained) {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, "PDBConstrained",
+			"scale down to %d replicas clamped to %d replicas required by PodDisruptionBudget %s", desiredReplicas, floor, pdbName)
+	}
+	setCondition(pa, autoscalingv1alpha1.PDBConstrained, metav1.ConditionTrue, "PodDisruptionBudget",
+		"the scale down to %d replicas is clamped to %d replicas required by PodDisruptionBudget %s", desiredReplicas, floor, pdbName)
+	klog.InfoS("Scale down clamped by PodDisruptionBudget", "PodAutoscaler", klog.KObj(pa),
+		"podDisruptionBudget", pdbName, "recommendedReplicas", desiredReplicas, "adjustedTo", floor)
+	return floor
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"testing"
+
+	policyv1 "k8s.io/api/policy/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func newTestPDB(name string, selector map[string]string, minAvailable intstr.IntOrString) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector:     &metav1.LabelSelector{MatchLabels: selector},
+		},
+	}
+}
+
+func TestReconcilePDBConstrainedScaleDown(t *testing.T) {
+	targetPods := map[string]string{"app": testDeployName}
+	testCases := []struct {
+		name             string
+		pdb              *policyv1.PodDisruptionBudget
+		expectedReplicas int32
+		// expectedConstrained is set if the PDBConstrained condition is expected to be true.
+		expectedConstrained bool
+	}{
+		{
+			name:             "no PodDisruptionBudget",
+			expectedReplicas: 2,
+		},
+		{
+			name:             "PodDisruptionBudget of other pods",
+			pdb:              newTestPDB("other-pdb", map[string]string{"app": "other"}, intstr.FromInt32(5)),
+			expectedReplicas: 2,
+		},
+		{
+			name:                "absolute minAvailable",
+			pdb:                 newTestPDB("test-pdb", targetPods, intstr.FromInt32(4)),
+			expectedReplicas:    4,
+			expectedConstrained: true,
+		},
+		{
+			// 50% of the 5 current replicas, rounded up.
+			name:                "percentage minAvailable",
+			pdb:                 newTestPDB("test-pdb", targetPods, intstr.FromString("50%")),
+			expectedReplicas:    3,
+			expectedConstrained: true,
+		},
+		{
+			name:             "minAvailable below the desired replicas",
+			pdb:              newTestPDB("test-pdb", targetPods, intstr.FromInt32(1)),
+			expectedReplicas: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the target has more replicas than its max, it is scaled down to the max.
+			objs := newTestAPAObjects(5, "8000", nil)
+			objs[1].(*autoscalingv1alpha1.PodAutoscaler).Spec.MaxReplicas = 2
+			pdbName := "none"
+			if tc.pdb != nil {
+				objs = append(objs, tc.pdb)
+				pdbName = tc.pdb.Name
+			}
+			r, recorder := newTestReconciler(t, objs...)
+			r.metricFetcher = metrics.NewFakeMetricFetcher()
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			constrained := apimeta.IsStatusConditionTrue(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.PDBConstrained)
+			if constrained != tc.expectedConstrained {
+				t.Errorf("expected PDBConstrained=%t, got %t", tc.expectedConstrained, constrained)
+			}
+			expectedEvents := 0
+			if tc.expectedConstrained {
+				expectedEvents = 1
+			}
+			if count := countEvents(recorder, "PodDisruptionBudget "+pdbName); count != expectedEvents {
+				t.Errorf("expected %d PDBConstrained events naming the PodDisruptionBudget, got %d", expectedEvents, count)
+			}
+		})
+	}
+}
@@ -18,24 +18,30 @@ package podautoscaler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
 	orchestrationv1alpha1 "github.com/vllm-project/aibrix/api/orchestration/v1alpha1"
 	"github.com/vllm-project/aibrix/pkg/config"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
 	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
 
 	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+	"github.com/vllm-project/aibrix/pkg/controller/util/events"
 	podutil "github.com/vllm-project/aibrix/pkg/utils"
 	podutils "github.com/vllm-project/aibrix/pkg/utils"
 
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
-	apiequality "k8s.io/apimachinery/pkg/api/equality"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -45,17 +51,15 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	"sigs.k8s.io/controller-runtime/pkg/source"
-)
-
-var (
-	DefaultRequeueDuration = 10 * time.Second
 )
 
 // Add creates a new PodAutoscaler Controller and adds it to the Manager with default RBAC.
@@ -73,65 +77,106 @@ func newReconciler(mgr manager.Manager, runtimeConfig config.RuntimeConfig) (rec
 	// Instantiate a new PodAutoscalerReconciler with the given manager's client and scheme
 	reconciler := &PodAutoscalerReconciler{
 		Client:         mgr.GetClient(),
+		apiReader:      mgr.GetAPIReader(),
 		Scheme:         mgr.GetScheme(),
-		EventRecorder:  mgr.GetEventRecorderFor("PodAutoscaler"),
+		EventRecorder:  events.NewRateLimitedRecorder(mgr.GetEventRecorderFor("PodAutoscaler"), runtimeConfig.EventRateLimitInterval),
 		Mapper:         mgr.GetRESTMapper(),
 		resyncInterval: 10 * time.Second, // TODO: this should be override by an environment variable
-		eventCh:        make(chan event.GenericEvent),
 		AutoscalerMap:  make(map[metrics.NamespaceNameMetric]scaler.Scaler),
 		RuntimeConfig:  runtimeConfig,
+		clock:          runtimeConfig.Clock,
+
+		reconcileTimeout: runtimeConfig.PodAutoscalerReconcileTimeout,
+	}
+
+	metricsAPIClient, err := newMetricsAPIClient(mgr.GetConfig(), mgr.GetRESTMapper())
+	if err != nil {
+		return nil, err
+	}
+	reconciler.metricsAPIClient = metricsAPIClient
+	// the scrapes of all the scalers share the HTTP clients and the bearer tokens of the fetcher.
+	reconciler.metricFetcher = metrics.NewRestMetricsFetcherWithSecrets(func(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+		secret := &corev1.Secret{}
+		if err := reconciler.apiReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			return nil, err
+		}
+		return secret, nil
+	})
+	if runtimeConfig.RecommendationRedisAddr != "" {
+		if reconciler.redisClient, err = newRedisClient(runtimeConfig.RecommendationRedisAddr); err != nil {
+			return nil, fmt.Errorf("invalid redis of the recommendation sinks: %w", err)
+		}
+	}
+	if runtimeConfig.MetricsRedisAddr != "" {
+		metricsRedisClient, err := newRedisClient(runtimeConfig.MetricsRedisAddr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis of the redis metric sources: %w", err)
+		}
+		reconciler.redisMetrics = metrics.NewRedisMetricsClient(metricsRedisClient)
 	}
 
 	return reconciler, nil
 }
 
-// for hpa related changes, let's make sure we only enqueue related PodAutoscaler objects
-func filterHPAObject(ctx context.Context, object client.Object) []reconcile.Request {
-	hpa, ok := object.(*autoscalingv2.HorizontalPodAutoscaler)
-	if !ok {
-		klog.Warningf("unexpected object type: %T, %s:%s, HPA object is expected here.", object, object.GetNamespace(), object.GetName())
-		return nil
+// newRedisClient returns a client of the Redis at the comma-separated addresses, with the mode, the credentials and
+// the TLS settings of the REDIS_* environment variables, e.g. to reach a Redis cluster with authentication.
+func newRedisClient(addrs string) (redis.UniversalClient, error) {
+	redisConfig, err := podutils.LoadRedisConfig()
+	if err != nil {
+		return nil, err
 	}
-
-	// Iterate through ownerReferences to find the PodAutoscaler.
-	// if found, enqueue the managing PodAutoscaler object
-	for _, ownerRef := range hpa.OwnerReferences {
-		if ownerRef.Kind == "PodAutoScaler" && ownerRef.Controller != nil && *ownerRef.Controller {
-			// Enqueue the managing PodAutoscaler object
-			return []reconcile.Request{
-				{
-					NamespacedName: types.NamespacedName{
-						Namespace: hpa.Namespace,
-						Name:      ownerRef.Name,
-					},
-				},
-			}
+	redisConfig.Addrs = nil
+	for _, addr := range strings.Split(addrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			redisConfig.Addrs = append(redisConfig.Addrs, addr)
 		}
 	}
-
-	// no managed pod autoscaler found, no need to enqueue original object.
-	return []reconcile.Request{}
+	return podutils.NewRedisClient(redisConfig)
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
-	// Build raw source for periodical requeue events from event channel
 	reconciler := r.(*PodAutoscalerReconciler)
-	src := source.Channel(reconciler.eventCh, &handler.EnqueueRequestForObject{})
+	maxConcurrentReconciles := reconciler.RuntimeConfig.PodAutoscalerMaxConcurrentReconciles
+	if maxConcurrentReconciles <= 0 {
+		maxConcurrentReconciles = DefaultMaxConcurrentReconciles
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &autoscalingv1alpha1.PodAutoscaler{}, scaleTargetField, indexScaleTarget); err != nil {
+		return err
+	}
 
 	// Create a new controller managed by AIBrix manager, watching for changes to PodAutoscaler objects
-	// and HorizontalPodAutoscaler objects.
-	err := ctrl.NewControllerManagedBy(mgr).
-		For(&autoscalingv1alpha1.PodAutoscaler{}).
-		Watches(&autoscalingv2.HorizontalPodAutoscaler{}, handler.EnqueueRequestsFromMapFunc(filterHPAObject)).
-		WatchesRawSource(src).
+	// and HorizontalPodAutoscaler objects. The metrics of KPA and APA PodAutoscalers are re-evaluated by
+	// requeueing them after every reconcile, the replica changes of their scale targets enqueue them at once.
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&autoscalingv1alpha1.PodAutoscaler{}, builder.WithPredicates(podAutoscalerPredicate())).
+		Watches(&autoscalingv2.HorizontalPodAutoscaler{},
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &autoscalingv1alpha1.PodAutoscaler{}, handler.OnlyControllerOwner()),
+			builder.WithPredicates(hpaPredicate()))
+	for gk, target := range watchedScaleTargets {
+		b = b.Watches(target, handler.EnqueueRequestsFromMapFunc(lookupPodAutoscalersForTarget(mgr.GetClient(), gk)),
+			builder.WithPredicates(scaleTargetReplicasChanged()))
+	}
+	err := b.
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: maxConcurrentReconciles,
+			RateLimiter:             newReconcileRateLimiter(),
+		}).
 		Complete(r)
+	if err != nil {
+		return err
+	}
+	// the leader sweeps the HPAs which lost their PodAutoscaler at startup and then periodically.
+	if err := mgr.Add(newOrphanSweeper(reconciler, reconciler.RuntimeConfig.OrphanSweepInterval, reconciler.RuntimeConfig.OrphanSweepDryRun)); err != nil {
+		return err
+	}
 
 	klog.InfoS("Added AIBrix pod-autoscaler-controller successfully")
 
 	errChan := make(chan error)
 	go reconciler.Run(context.Background(), errChan)
-	klog.InfoS("Run pod-autoscaler-controller periodical syncs successfully")
+	klog.InfoS("Run pod-autoscaler-controller janitor successfully")
 
 	go func() {
 		for err := range errChan {
@@ -152,15 +197,55 @@ type PodAutoscalerReconciler struct {
 	Mapper         apimeta.RESTMapper
 	AutoscalerMap  map[metrics.NamespaceNameMetric]scaler.Scaler // AutoscalerMap maps each NamespaceNameMetric to its corresponding scaler instance.
 	resyncInterval time.Duration
-	eventCh        chan event.GenericEvent
 	RuntimeConfig  config.RuntimeConfig
+
+	// reconcileTimeout is the deadline of the outbound calls of a single reconcile, DefaultReconcileTimeout if not
+	// set.
+	reconcileTimeout time.Duration
+
+	// recommendations keeps the desired replicas recommended to each KPA PodAutoscaler within its scale down
+	// stabilization window.
+	recommendations map[types.NamespacedName]*timedMaxWindow
+	// scaleDecisions keeps the decision history of each KPA or APA PodAutoscaler, whose decisions are evaluated
+	// one stable window after they scaled the target.
+	scaleDecisions map[types.NamespacedName]*scaleDecisionHistory
+
+	// apiReader reads the replica quota ConfigMaps from the API server, the client if not set.
+	apiReader client.Reader
+
+	// metricFetcher fetches the metrics of the new scalers, a RestMetricsFetcher scraping the pods if not set.
+	metricFetcher metrics.MetricFetcher
+	// clock times the metric samples and the scaling decisions, the real clock if not set.
+	clock clock.PassiveClock
+	// metricsAPIClient queries the Kubernetes custom and external metrics APIs.
+	metricsAPIClient *metrics.MetricsAPIClient
+	// redisMetrics reads the metrics of the redis sources, nil if no Redis is configured.
+	redisMetrics redisMetricReader
+
+	// httpClient posts the recommendations of the Webhook sinks, a client with recommendationWebhookTimeout if
+	// not set.
+	httpClient *http.Client
+	// redisClient appends the recommendations of the RedisStream sinks, nil if no Redis is configured.
+	redisClient redis.UniversalClient
+
+	// stateMu guards the per-object in-memory state, AutoscalerMap, recommendations, scaleDecisions and
+	// metricsFailures, which the janitor prunes concurrently with the reconciles.
+	stateMu sync.Mutex
+	// metricsFailures counts the consecutive reconciles of each KPA or APA PodAutoscaler which held its replicas
+	// for unavailable metrics, the retries back off with the count.
+	metricsFailures map[types.NamespacedName]int
+	// missingSince records when the janitor first found the in-memory state of a PodAutoscaler without the object,
+	// it is only accessed by the janitor.
+	missingSince map[types.NamespacedName]time.Time
 }
 
 func (r *PodAutoscalerReconciler) deleteStaleScalerInCache(request types.NamespacedName) {
 	// When deleting, we only have access to the Namespace and Name, not other attributes in pa_types.
 	// We should scan `AutoscalerMap` and remove the matched objects.
-	// Note that due to the OwnerRef, the created HPA object will automatically be removed when AIBrix-HPA is deleted.
-	// Therefore, manual deletion of the HPA is not necessary.
+	// Note that due to the controller OwnerRef, the created HPA object is garbage collected when the PodAutoscaler
+	// is deleted. Therefore, manual deletion of the HPA is not necessary.
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
 	for namespaceNameMetric := range r.AutoscalerMap {
 		if namespaceNameMetric.PaNamespace == request.Namespace && namespaceNameMetric.PaName == request.Name {
 			// remove matched entry from the map
@@ -170,13 +255,25 @@ func (r *PodAutoscalerReconciler) deleteStaleScalerInCache(request types.Namespa
 			delete(r.AutoscalerMap, namespaceNameMetric)
 		}
 	}
+	delete(r.recommendations, request)
+	delete(r.scaleDecisions, request)
+	delete(r.metricsFailures, request)
+	scalingPredictionBias.DeleteLabelValues(request.Namespace, request.Name)
+	deletePodAutoscalerMetrics(request)
 }
 
 //+kubebuilder:rbac:groups=autoscaling.aibrix.ai,resources=podautoscalers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=autoscaling.aibrix.ai,resources=podautoscalers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=autoscaling.aibrix.ai,resources=podautoscalers/finalizers,verbs=update
 //+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=replicasets;statefulsets,verbs=get;patch
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=list;watch
+//+kubebuilder:rbac:groups=apps,resources=deployments/scale;replicasets/scale;statefulsets/scale,verbs=get;update;patch
+//+kubebuilder:rbac:groups=orchestration.aibrix.ai,resources=rayclusterfleets/scale,verbs=get;update;patch
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch;update
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get
 
 // Reconcile is part of the main Kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state as specified by
@@ -185,15 +282,12 @@ func (r *PodAutoscalerReconciler) deleteStaleScalerInCache(request types.Namespa
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.17.3/pkg/reconcile
 func (r *PodAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	// Implement a timeout for the reconciliation process.
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-
 	klog.V(4).InfoS("Reconciling PodAutoscaler", "obj", req.NamespacedName)
+	start := r.now()
 
 	var pa autoscalingv1alpha1.PodAutoscaler
 	if err := r.Get(ctx, req.NamespacedName, &pa); err != nil {
-		if errors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) {
 			r.deleteStaleScalerInCache(req.NamespacedName)
 			// Object might have been deleted after reconcile request, clean it and return.
 			klog.Infof("PodAutoscaler resource not found. Clean scaler object in memory since object %s must have been deleted", req.NamespacedName)
@@ -202,41 +296,48 @@ func (r *PodAutoscalerReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		klog.ErrorS(err, "Failed to get PodAutoscaler")
 		return ctrl.Result{}, err
 	}
+	defer func() { recordReconcileDuration(pa.Spec.ScalingStrategy, start, r.now()) }()
 
 	if !checkValidAutoscalingStrategy(pa.Spec.ScalingStrategy) {
-		// TODO: update status or conditions
-		// this is unrecoverable unless user make changes.
-		return ctrl.Result{}, nil
+		// this is unrecoverable unless user make changes, so the PodAutoscaler is not requeued.
+		return ctrl.Result{}, r.setInvalidStrategy(ctx, &pa)
+	}
+	// the strategy was fixed after it was reported invalid.
+	paStatusOriginal := pa.Status.DeepCopy()
+	if apimeta.RemoveStatusCondition(&pa.Status.Conditions, autoscalingv1alpha1.InvalidStrategy) {
+		if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, &pa); err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
-	switch pa.Spec.ScalingStrategy {
-	case autoscalingv1alpha1.HPA:
-		return r.reconcileHPA(ctx, pa)
-	case autoscalingv1alpha1.KPA, autoscalingv1alpha1.APA:
-		return r.reconcileCustomPA(ctx, pa)
+	// the PodAutoscaler is reconciled again with the configuration imported from an HPA.
+	if imported, err := r.importFromHPA(ctx, &pa); imported || err != nil {
+		return ctrl.Result{Requeue: imported}, err
 	}
+	// the PodAutoscalers created before the defaulting webhook, or without it, are reconciled with the same defaults.
+	scaler.SetDefaults(&pa)
 
-	newStatus := computeStatus(ctx, pa)
-	if err := r.updateStatusIfNeeded(ctx, newStatus, &pa); err != nil {
+	if pa.Spec.ScalingStrategy == autoscalingv1alpha1.HPA {
+		hpaCtx, cancel := context.WithTimeout(ctx, r.getReconcileTimeout())
+		defer cancel()
+		return r.reconcileHPA(hpaCtx, pa)
+	}
+	// the HPA generated before a switch of the scaling strategy would fight over the scale target.
+	if err := r.deleteOwnedHPA(ctx, &pa); err != nil {
 		return ctrl.Result{}, err
 	}
-
-	return ctrl.Result{}, nil
+	return r.reconcileCustomPA(ctx, pa)
 }
 
 func (r *PodAutoscalerReconciler) Run(ctx context.Context, errChan chan<- error) {
 	ticker := time.NewTicker(r.resyncInterval)
 	defer ticker.Stop()
-	defer close(r.eventCh)
 
 	for {
 		select {
 		case <-ticker.C:
-			klog.V(4).Info("enqueue all autoscalers")
-			// periodically sync all autoscaling objects
-			if err := r.enqueuePodAutoscalers(ctx); err != nil {
-				klog.ErrorS(err, "Failed to enqueue pod autoscalers")
-				errChan <- err
+			if err := r.collectGarbage(ctx, r.now()); err != nil {
+				klog.ErrorS(err, "Failed to collect the in-memory state of deleted pod autoscalers")
 			}
 		case <-ctx.Done():
 			klog.Info("context done, stopping running the loop")
@@ -246,50 +347,32 @@ func (r *PodAutoscalerReconciler) Run(ctx context.Context, errChan chan<- error)
 	}
 }
 
-func (r *PodAutoscalerReconciler) enqueuePodAutoscalers(ctx context.Context) error {
-	podAutoscalerLists := &autoscalingv1alpha1.PodAutoscalerList{}
-	if err := r.List(ctx, podAutoscalerLists); err != nil {
-		return err
+func (r *PodAutoscalerReconciler) reconcileHPA(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler) (ctrl.Result, error) {
+	// Generate a corresponding HorizontalPodAutoscaler
+	hpa, err := makeHPA(&pa)
+	if err != nil {
+		// this is unrecoverable unless user make changes, the HPA is left as is rather than scaling on other metrics.
+		return ctrl.Result{}, r.setInvalidHPAMetrics(ctx, &pa, err)
 	}
-	for _, pa := range podAutoscalerLists.Items {
-		// Let's operate the queue and just enqueue the object, that should be ok.
-		e := event.GenericEvent{
-			Object: &pa,
+	// the metric sources were fixed after they were reported invalid.
+	paStatusOriginal := pa.Status.DeepCopy()
+	if apimeta.RemoveStatusCondition(&pa.Status.Conditions, autoscalingv1alpha1.InvalidHPAMetrics) {
+		if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, &pa); err != nil {
+			return ctrl.Result{}, err
 		}
-		r.eventCh <- e
 	}
-
-	return nil
-}
-
-// checkValidAutoscalingStrategy checks if a string is in a list of valid strategies
-func checkValidAutoscalingStrategy(strategy autoscalingv1alpha1.ScalingStrategyType) bool {
-	validStrategies := []autoscalingv1alpha1.ScalingStrategyType{autoscalingv1alpha1.HPA, autoscalingv1alpha1.APA, autoscalingv1alpha1.KPA}
-	for _, v := range validStrategies {
-		if v == strategy {
-			return true
-		}
+	if err := controllerutil.SetControllerReference(&pa, hpa, r.Scheme); err != nil {
+		klog.ErrorS(err, "Failed to set the owner reference of the HPA", "PodAutoscaler", klog.KObj(&pa))
+		return ctrl.Result{}, err
 	}
-	return false
-}
-
-func computeStatus(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler) *autoscalingv1alpha1.PodAutoscalerStatus {
-	// take condition into consideration
-	// TODO: not implemented
-	return nil
-}
-
-func (r *PodAutoscalerReconciler) reconcileHPA(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler) (ctrl.Result, error) {
-	// Generate a corresponding HorizontalPodAutoscaler
-	hpa := makeHPA(&pa)
 	hpaName := types.NamespacedName{
 		Name:      hpa.Name,
 		Namespace: hpa.Namespace,
 	}
 
 	existingHPA := &autoscalingv2.HorizontalPodAutoscaler{}
-	err := r.Get(ctx, hpaName, existingHPA)
-	if err != nil && errors.IsNotFound(err) {
+	err = r.Get(ctx, hpaName, existingHPA)
+	if err != nil && apierrors.IsNotFound(err) {
 		// HPA does not exist, create a new one.
 		klog.InfoS("Creating a new HPA", "HPA", hpaName)
 		if err = r.Create(ctx, hpa); err != nil {
@@ -301,10 +384,33 @@ func (r *PodAutoscalerReconciler) reconcileHPA(ctx context.Context, pa autoscali
 		klog.ErrorS(err, "Failed to get HPA", "HPA", hpaName)
 		return ctrl.Result{}, err
 	} else {
-		// Update the existing HPA if it already exists.
-		klog.V(4).InfoS("Updating existing HPA to desired state", "HPA", hpaName)
+		if !metav1.IsControlledBy(existingHPA, &pa) {
+			// Only adopt an HPA with the expected name if it was generated by aibrix and has no other controller,
+			// an HPA created by the user is left untouched.
+			if !isManagedHPA(existingHPA) || metav1.GetControllerOf(existingHPA) != nil {
+				klog.InfoS("HPA is not managed by the PodAutoscaler, skip updating it", "HPA", hpaName, "PodAutoscaler", klog.KObj(&pa))
+				r.EventRecorder.Eventf(&pa, corev1.EventTypeWarning, "HPAConflict",
+					"HPA %s already exists and is not managed by aibrix, it will not be overwritten", hpaName.Name)
+				return ctrl.Result{}, nil
+			}
+			klog.InfoS("Adopting existing HPA", "HPA", hpaName, "PodAutoscaler", klog.KObj(&pa))
+		}
+
+		// Update the existing HPA only if it drifted from the desired state, the fields owned by others are kept.
+		adopt := !metav1.IsControlledBy(existingHPA, &pa)
+		if adopt {
+			if err := controllerutil.SetControllerReference(&pa, existingHPA, r.Scheme); err != nil {
+				klog.ErrorS(err, "Failed to set the owner reference of the HPA", "PodAutoscaler", klog.KObj(&pa))
+				return ctrl.Result{}, err
+			}
+		}
+		if changed := mergeHPA(existingHPA, hpa); !changed && !adopt {
+			klog.V(5).InfoS("HPA is up to date", "HPA", hpaName)
+			return ctrl.Result{}, r.observeHPAGeneration(ctx, &pa)
+		}
 
-		err = r.Update(ctx, hpa)
+		klog.V(4).InfoS("Updating existing HPA to desired state", "HPA", hpaName)
+		err = r.Update(ctx, existingHPA)
 		if err != nil {
 			klog.ErrorS(err, "Failed to update HPA")
 			return ctrl.Result{}, err
@@ -313,7 +419,59 @@ func (r *PodAutoscalerReconciler) reconcileHPA(ctx context.Context, pa autoscali
 
 	// TODO: add status update. Currently, actualScale and desireScale are not synced from HPA object yet.
 	// Return with no error and no requeue needed.
-	return ctrl.Result{}, nil
+	return ctrl.Result{}, r.observeHPAGeneration(ctx, &pa)
+}
+
+// setInvalidHPAMetrics reports the metric sources or scaling annotations the HPA cannot represent with the
+// InvalidHPAMetrics condition and an event, emitted once per generation of the PodAutoscaler.
+func (r *PodAutoscalerReconciler) setInvalidHPAMetrics(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, err error) error {
+	klog.ErrorS(err, "Failed to translate the PodAutoscaler into an HPA", "PodAutoscaler", klog.KObj(pa))
+	message := fmt.Sprintf("the PodAutoscaler cannot be translated into an HPA: %v", strings.ReplaceAll(err.Error(), "\n", "; "))
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.InvalidHPAMetrics)
+	if cond != nil && cond.Status == metav1.ConditionTrue && cond.ObservedGeneration == pa.Generation && cond.Message == message {
+		return nil
+	}
+	paStatusOriginal := pa.Status.DeepCopy()
+	r.EventRecorder.Event(pa, corev1.EventTypeWarning, "InvalidHPAMetrics", message)
+	apimeta.SetStatusCondition(&pa.Status.Conditions, metav1.Condition{
+		Type:               autoscalingv1alpha1.InvalidHPAMetrics,
+		Status:             metav1.ConditionTrue,
+		Reason:             "UntranslatableMetrics",
+		Message:            message,
+		ObservedGeneration: pa.Generation,
+	})
+	return r.updateStatusIfNeeded(ctx, paStatusOriginal, pa)
+}
+
+// observeHPAGeneration records that the HPA generated from the PA reflects its current spec.
+func (r *PodAutoscalerReconciler) observeHPAGeneration(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) error {
+	paStatusOriginal := pa.Status.DeepCopy()
+	observeGeneration(pa)
+	return r.updateStatusIfNeeded(ctx, paStatusOriginal, pa)
+}
+
+// deleteOwnedHPA deletes the HPA the PodAutoscaler generated while its scaling strategy was HPA.
+func (r *PodAutoscalerReconciler) deleteOwnedHPA(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) error {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: pa.Namespace, Name: hpaName(pa)}, hpa); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		klog.ErrorS(err, "Failed to get HPA", "PodAutoscaler", klog.KObj(pa))
+		return err
+	}
+	if !metav1.IsControlledBy(hpa, pa) {
+		return nil
+	}
+
+	klog.InfoS("Deleting the HPA of the PodAutoscaler after its scaling strategy changed", "HPA", klog.KObj(hpa), "strategy", pa.Spec.ScalingStrategy)
+	if err := r.Delete(ctx, hpa); err != nil && !apierrors.IsNotFound(err) {
+		klog.ErrorS(err, "Failed to delete HPA", "HPA", klog.KObj(hpa))
+		return err
+	}
+	r.EventRecorder.Eventf(pa, corev1.EventTypeNormal, "HPADeleted",
+		"Deleted HPA %s after the scaling strategy changed to %s", hpa.Name, pa.Spec.ScalingStrategy)
+	return nil
 }
 
 // reconcileCustomPA handles the reconciliation logic for custom PodAutoscaler (PA) types.
@@ -330,20 +488,35 @@ func (r *PodAutoscalerReconciler) reconcileHPA(ctx context.Context, pa autoscali
 // This function serves as a unified entry point for the reconciliation process of custom PA types,
 // while allowing for customization in the specific stages mentioned above.
 func (r *PodAutoscalerReconciler) reconcileCustomPA(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler) (ctrl.Result, error) {
+	// the deadline bounds the calls to the API server and to the pods, the status is written without it, so that a
+	// scaled target is always recorded.
+	callCtx, cancel := context.WithTimeout(ctx, r.getReconcileTimeout())
+	defer cancel()
+
 	paStatusOriginal := pa.Status.DeepCopy()
 	paType := pa.Spec.ScalingStrategy
+	r.checkAnnotations(&pa)
 	scaleReference := fmt.Sprintf("%s/%s/%s", pa.Spec.ScaleTargetRef.Kind, pa.Namespace, pa.Spec.ScaleTargetRef.Name)
-	metricKey, metricSource, err := metrics.NewNamespaceNameMetric(&pa)
+	metricTargets, err := getMetricTargets(&pa)
 	if err != nil {
 		r.EventRecorder.Event(&pa, corev1.EventTypeWarning, "FailedGetMetricKey", err.Error())
 		return ctrl.Result{}, err
 	}
+	r.deleteRemovedMetricScalers(&pa, metricTargets)
+	// the scaling parameters are validated before any scaler is updated with them.
+	if paType == autoscalingv1alpha1.KPA {
+		if _, err := scaler.NewKpaScalingContextByPa(&pa); err != nil {
+			// this is unrecoverable unless user make changes, the target keeps its replicas until then.
+			return ctrl.Result{}, r.setConfigInvalid(ctx, paStatusOriginal, &pa, err)
+		}
+	}
+	apimeta.RemoveStatusCondition(&pa.Status.Conditions, autoscalingv1alpha1.ConfigInvalid)
 
 	targetGV, err := schema.ParseGroupVersion(pa.Spec.ScaleTargetRef.APIVersion)
 	if err != nil {
 		r.EventRecorder.Event(&pa, corev1.EventTypeWarning, "FailedGetScale", err.Error())
-		// TODO: convert conditionType to type instead of using string
-		setCondition(&pa, "AbleToScale", metav1.ConditionFalse, "FailedGetScale", "the %s controller was unable to get the target's current scale: %v", paType, err)
+		podAutoscalerErrors.WithLabelValues(stageGetScale).Inc()
+		setCondition(&pa, autoscalingv1alpha1.AbleToScale, metav1.ConditionFalse, "FailedGetScale", "the %s controller was unable to get the target's current scale: %v", paType, err)
 		if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, &pa); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -357,86 +530,139 @@ func (r *PodAutoscalerReconciler) reconcileCustomPA(ctx context.Context, pa auto
 	mappings, err := r.Mapper.RESTMappings(targetGK)
 	if err != nil {
 		r.EventRecorder.Event(&pa, corev1.EventTypeWarning, "FailedGetScale", err.Error())
-		setCondition(&pa, "AbleToScale", metav1.ConditionFalse, "FailedGetScale", "the %s controller was unable to get the target's current scale: %v", paType, err)
+		podAutoscalerErrors.WithLabelValues(stageGetScale).Inc()
+		setCondition(&pa, autoscalingv1alpha1.AbleToScale, metav1.ConditionFalse, "FailedGetScale", "the %s controller was unable to get the target's current scale: %v", paType, err)
 		if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, &pa); err != nil {
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{}, fmt.Errorf("unable to determine resource for scale target reference: %v", err)
 	}
 
-	// TODO: retrieval targetGR for future scale update
-	scale, targetGR, err := r.scaleForResourceMappings(ctx, pa.Namespace, pa.Spec.ScaleTargetRef.Name, mappings)
+	scale, target, targetGR, err := r.scaleForResourceMappings(callCtx, pa.Namespace, pa.Spec.ScaleTargetRef.Name, mappings)
+	if deadlineExceeded(callCtx) {
+		return r.handleReconcileTimeout(ctx, paStatusOriginal, &pa, phaseScaleLookup)
+	}
 	if err != nil {
 		r.EventRecorder.Event(&pa, corev1.EventTypeWarning, "FailedGetScale", err.Error())
-		setCondition(&pa, "AbleToScale", metav1.ConditionFalse, "FailedGetScale", "the %s controller was unable to get the target's current scale: %v", paType, err)
+		podAutoscalerErrors.WithLabelValues(stageGetScale).Inc()
+		setCondition(&pa, autoscalingv1alpha1.AbleToScale, metav1.ConditionFalse, "FailedGetScale", "the %s controller was unable to get the target's current scale: %v", paType, err)
 		if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, &pa); err != nil {
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{}, fmt.Errorf("failed to query scale subresource for %s: %v", scaleReference, err)
 	}
 
-	setCondition(&pa, "AbleToScale", metav1.ConditionTrue, "SucceededGetScale", "the %s controller was able to get the target's current scale", paType)
+	setCondition(&pa, autoscalingv1alpha1.AbleToScale, metav1.ConditionTrue, "SucceededGetScale", "the %s controller was able to get the target's current scale", paType)
 
 	// current scale's replica count
 	currentReplicasInt64, found, err := unstructured.NestedInt64(scale.Object, "spec", "replicas")
 	if !found {
 		r.EventRecorder.Eventf(&pa, corev1.EventTypeWarning, "ReplicasNotFound", "The 'replicas' field is missing from the scale object")
+		podAutoscalerErrors.WithLabelValues(stageGetScale).Inc()
 		return ctrl.Result{}, fmt.Errorf("the 'replicas' field was not found in the scale object")
 	}
 	if err != nil {
 		r.EventRecorder.Eventf(&pa, corev1.EventTypeWarning, "FailedGetScale", "Error retrieving 'replicas' from scale: %v", err)
+		podAutoscalerErrors.WithLabelValues(stageGetScale).Inc()
 		return ctrl.Result{}, fmt.Errorf("failed to get 'replicas' from scale: %v", err)
 	}
 	currentReplicas := int32(currentReplicasInt64)
 
-	// Update the scale required metrics periodically
-	err = r.updateMetricsForScale(ctx, pa, scale, metricKey, metricSource, int(currentReplicas))
+	minReplicas := getMinReplicas(&pa)
+
+	// Evaluate the no ready pods policy before fetching metrics, which are unavailable when no pod is ready.
+	noReadyPods, noReadyPodsReplicas, err := r.checkNoReadyPods(callCtx, &pa, target, currentReplicas, minReplicas, r.now())
+	if deadlineExceeded(callCtx) {
+		return r.handleReconcileTimeout(ctx, paStatusOriginal, &pa, phaseMetricFetch)
+	}
 	if err != nil {
-		r.EventRecorder.Event(&pa, corev1.EventTypeWarning, "FailedUpdateMetrics", err.Error())
-		return ctrl.Result{}, fmt.Errorf("failed to update metrics for scale target reference: %v", err)
+		r.EventRecorder.Event(&pa, corev1.EventTypeWarning, "FailedGetReadyPods", err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to get ready pods for %s: %v", scaleReference, err)
+	}
+
+	var metricsErr error
+	// validTargets are the metric targets whose metrics were updated, the target is scaled on them.
+	var validTargets []metricTarget
+	// partialMetrics is set when the metrics of some pods are missing, see metrics.PartialMetricsError.
+	var partialMetrics *metrics.PartialMetricsError
+	if !noReadyPods {
+		// Update the scale required metrics periodically
+		validTargets, partialMetrics, metricsErr = r.updateMetricsForTargets(callCtx, &pa, target, metricTargets, int(currentReplicas))
+		if deadlineExceeded(callCtx) {
+			return r.handleReconcileTimeout(ctx, paStatusOriginal, &pa, phaseMetricFetch)
+		}
 	}
 
 	// desired replica count
 	desiredReplicas := int32(0)
 	rescaleReason := ""
-	var minReplicas int32
-	// minReplica is optional
-	if pa.Spec.MinReplicas != nil {
-		minReplicas = *pa.Spec.MinReplicas
-	} else {
-		minReplicas = 1
-	}
+	var metricStatuses []autoscalingv1alpha1.MetricStatus
+	// explanation is how the decision was made, nil while scaling is disabled.
+	var explanation *decisionExplanation
 
 	// check if rescale is needed by checking the replica settings
 	rescale := true
-	if currentReplicas == int32(0) && minReplicas != 0 {
-		// if the replica is 0, then we should not enable autoscaling
+	if r.checkScalingDisabled(&pa, currentReplicas, minReplicas) {
+		// if the replica is 0 and scale-to-zero is not enabled, then we should not enable autoscaling
 		desiredReplicas = 0
 		rescale = false
+	} else if noReadyPods {
+		desiredReplicas = noReadyPodsReplicas
+		rescaleReason = fmt.Sprintf("no ready pods, %s policy", getNoReadyPodsPolicy(&pa))
+		explanation = explainReason(rescaleReason, desiredReplicas)
+		rescale = desiredReplicas != currentReplicas
 	} else if currentReplicas > pa.Spec.MaxReplicas {
 		desiredReplicas = pa.Spec.MaxReplicas
+		explanation = explainReason(fmt.Sprintf("%d replicas above maxReplicas", currentReplicas), desiredReplicas)
 	} else if currentReplicas < minReplicas {
 		desiredReplicas = minReplicas
+		explanation = explainReason(fmt.Sprintf("%d replicas below minReplicas", currentReplicas), desiredReplicas)
+	} else if currentReplicas == 0 && paType == autoscalingv1alpha1.KPA {
+		desiredReplicas, rescaleReason = r.computeReplicasFromZero(callCtx, &pa, target, validTargets, metricsErr)
+		if deadlineExceeded(callCtx) {
+			return r.handleReconcileTimeout(ctx, paStatusOriginal, &pa, phaseMetricFetch)
+		}
+		if rescaleReason != "" {
+			explanation = explainReason(rescaleReason, desiredReplicas)
+		} else {
+			explanation = explainReason("no traffic observed", desiredReplicas)
+		}
+		rescale = desiredReplicas != currentReplicas
+	} else if metricsErr != nil {
+		return r.holdForUnavailableMetrics(ctx, paStatusOriginal, &pa, currentReplicas)
 	} else {
-		// if the currentReplicas is within the range, we should
-		// computeReplicasForMetrics gives
-		// TODO: check why it return the metrics name here?
-		metricDesiredReplicas, metricName, metricTimestamp, err := r.computeReplicasForMetrics(ctx, pa, scale, metricKey)
+		// if the currentReplicas is within the range, computeReplicasForMetrics gives the replicas recommended by
+		// the metric demanding the most, and the name of that metric.
+		scaleResult, metricName, statuses, metricTimestamp, err := r.computeReplicasForMetrics(callCtx, pa, target, validTargets)
+		if deadlineExceeded(callCtx) {
+			return r.handleReconcileTimeout(ctx, paStatusOriginal, &pa, phaseMetricFetch)
+		}
+		if !scaleResult.ScaleValid {
+			r.setMetricsUnavailable(&pa, "FailedComputeMetricsReplicas",
+				fmt.Errorf("failed to compute desired number of replicas based on listed metrics for %s: %v", scaleReference, err))
+			return r.holdForUnavailableMetrics(ctx, paStatusOriginal, &pa, currentReplicas)
+		}
 		if err != nil {
-			r.setCurrentReplicasAndMetricsInStatus(&pa, currentReplicas)
-			if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, &pa); err != nil {
-				return ctrl.Result{}, fmt.Errorf("failed to update the resource status")
-			}
-			r.EventRecorder.Event(&pa, corev1.EventTypeWarning, "FailedComputeMetricsReplicas", err.Error())
-			return ctrl.Result{}, fmt.Errorf("failed to compute desired number of replicas based on listed metrics for %s: %v", scaleReference, err)
+			// the target is scaled on the other metrics.
+			r.EventRecorder.Eventf(&pa, corev1.EventTypeWarning, "FailedComputeMetricsReplicas",
+				"failed to compute desired number of replicas based on some metrics for %s: %v", scaleReference, err)
 		}
-
+		metricStatuses = statuses
+		r.evaluateScaleDecision(&pa, metricName, scaleResult.Explanation, currentReplicas, r.now())
+		setCondition(&pa, autoscalingv1alpha1.ScalingActive, metav1.ConditionTrue, "ValidMetricFound",
+			"the %s controller was able to compute the desired replicas from metric %s", paType, metricName)
+		setPanickingCondition(&pa, scaleResult.InPanicMode)
+		r.reportRateLimit(&pa, scaleResult)
+
+		metricDesiredReplicas := scaleResult.DesiredPodCount
 		klog.V(4).InfoS("Proposing desired replicas",
 			"desiredReplicas", metricDesiredReplicas,
 			"metric", metricName,
 			"timestamp", metricTimestamp,
 			"scaleTarget", scaleReference)
 
+		explanation = explainMetric(metricName, scaleResult)
+
 		rescaleMetric := ""
 		if metricDesiredReplicas > desiredReplicas {
 			desiredReplicas = metricDesiredReplicas
@@ -450,41 +676,88 @@ func (r *PodAutoscalerReconciler) reconcileCustomPA(ctx context.Context, pa auto
 		}
 
 		// adjust desired metrics within the <min, max> range
+		limitedReplicas := r.limitReplicas(&pa, desiredReplicas, minReplicas)
 		if desiredReplicas > pa.Spec.MaxReplicas {
-			klog.V(2).InfoS("Scaling adjustment: Algorithm recommended scaling to a target that exceeded the maximum limit.",
-				"recommendedReplicas", desiredReplicas, "adjustedTo", pa.Spec.MaxReplicas)
-			desiredReplicas = pa.Spec.MaxReplicas
-		} else if desiredReplicas < minReplicas {
-			klog.V(2).InfoS("Scaling adjustment: Algorithm recommended scaling to a target that fell below the minimum limit.",
-				"recommendedReplicas", desiredReplicas, "adjustedTo", minReplicas)
-			desiredReplicas = minReplicas
+			explanation.Adjust("maxReplicas", desiredReplicas, limitedReplicas)
+		} else {
+			explanation.Adjust("minReplicas", desiredReplicas, limitedReplicas)
+		}
+		desiredReplicas = limitedReplicas
+
+		if paType == autoscalingv1alpha1.KPA {
+			stabilizedReplicas := r.stabilizeRecommendation(&pa, currentReplicas, desiredReplicas, r.now())
+			explanation.Keep("stabilization", desiredReplicas, stabilizedReplicas)
+			desiredReplicas = stabilizedReplicas
+			if minReplicas == 0 {
+				retainedReplicas := retainBeforeScaleToZero(&pa, metricName, scaleResult.ObservedValue, desiredReplicas, r.now())
+				explanation.Keep("scale-to-zero retention", desiredReplicas, retainedReplicas)
+				desiredReplicas = retainedReplicas
+			}
+			r.persistScalingState(&pa)
 		}
+		// the sum of the metrics understates the load while the metrics of some pods are missing.
+		if partialMetrics != nil && desiredReplicas < currentReplicas {
+			klog.InfoS("Holding the scale down while the metrics of some pods are missing", "PodAutoscaler", klog.KObj(&pa),
+				"recommendedReplicas", desiredReplicas, "currentReplicas", currentReplicas, "scrape", partialMetrics.Summary.String())
+			explanation.Keep("partial metrics", desiredReplicas, currentReplicas)
+			desiredReplicas = currentReplicas
+		}
+		rescale = desiredReplicas != currentReplicas
+	}
 
+	if rescale && desiredReplicas < currentReplicas {
+		constrainedReplicas := r.constrainScaleDownByPDB(callCtx, &pa, target, currentReplicas, desiredReplicas)
+		if explanation != nil {
+			explanation.Adjust("PodDisruptionBudget", desiredReplicas, constrainedReplicas)
+		}
+		desiredReplicas = constrainedReplicas
+		rescale = desiredReplicas != currentReplicas
+	}
+	// the scale ups are held within the replica quotas of the namespace and of the cluster.
+	if quotaReplicas := r.constrainScaleUpByQuota(callCtx, &pa, currentReplicas, desiredReplicas); quotaReplicas != desiredReplicas {
+		if explanation != nil {
+			explanation.Adjust("replica quota", desiredReplicas, quotaReplicas)
+		}
+		desiredReplicas = quotaReplicas
 		rescale = desiredReplicas != currentReplicas
 	}
 
+	decision := explanation.String()
+	if explanation != nil {
+		klog.V(2).InfoS("Scaling decision", "PodAutoscaler", klog.KObj(&pa),
+			"currentReplicas", currentReplicas, "desiredReplicas", desiredReplicas, "decision", decision)
+	}
+
 	r.EventRecorder.Eventf(&pa, corev1.EventTypeNormal, "AlgorithmRun",
 		"%s algorithm run. currentReplicas: %d, desiredReplicas: %d, rescale: %t",
 		pa.Spec.ScalingStrategy, currentReplicas, desiredReplicas, rescale)
 
+	if rescale && getActuationMode(&pa) == autoscalingv1alpha1.ActuationModeExternal {
+		// the recommendation is applied by an external actuator, the target keeps its replicas.
+		r.publishRecommendation(callCtx, &pa, currentReplicas, desiredReplicas, rescaleReason)
+		rescale = false
+	}
+
 	if rescale {
-		if err := r.updateScale(ctx, pa.Namespace, targetGR, scale, desiredReplicas); err != nil {
+		if err := r.updateScale(callCtx, pa.Namespace, targetGR, target, desiredReplicas, getActuationMode(&pa)); err != nil {
+			if deadlineExceeded(callCtx) {
+				r.setCurrentReplicasAndMetricsInStatus(&pa, currentReplicas, metricStatuses)
+				return r.handleReconcileTimeout(ctx, paStatusOriginal, &pa, phaseActuation)
+			}
 			r.EventRecorder.Eventf(&pa, corev1.EventTypeWarning, "FailedRescale", "New size: %d; reason: %s; error: %v", desiredReplicas, rescaleReason, err)
-			setCondition(&pa, "AbleToScale", metav1.ConditionFalse, "FailedUpdateScale", "the %s controller was unable to update the target scale: %v", paType, err)
-			r.setCurrentReplicasAndMetricsInStatus(&pa, currentReplicas)
+			podAutoscalerErrors.WithLabelValues(stageUpdateScale).Inc()
+			setCondition(&pa, autoscalingv1alpha1.AbleToScale, metav1.ConditionFalse, "FailedUpdateScale", "the %s controller was unable to update the target scale: %v", paType, err)
+			r.setCurrentReplicasAndMetricsInStatus(&pa, currentReplicas, metricStatuses)
 			if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, &pa); err != nil {
 				utilruntime.HandleError(err)
 			}
 			return ctrl.Result{}, fmt.Errorf("failed to rescale %s: %v", scaleReference, err)
 		}
 
-		// TODO: seems not resolved yet?
-		// which way to go?. not sure the best practice in controller-runtime
-		//if err := r.Client.SubResource("scale").Update(ctx, scale); err != nil {
-		//	return ctrl.Result{}, fmt.Errorf("failed to rescale %s: %v", scaleReference, err)
-		//}
-
-		r.EventRecorder.Eventf(&pa, corev1.EventTypeNormal, "SuccessfulRescale", "New size: %d; reason: %s", desiredReplicas, rescaleReason)
+		r.EventRecorder.Eventf(&pa, corev1.EventTypeNormal, "SuccessfulRescale", "New size: %d; reason: %s; decision: %s", desiredReplicas, rescaleReason, decision)
+		recordRescale(&pa, currentReplicas, desiredReplicas)
+		setCondition(&pa, autoscalingv1alpha1.AbleToScale, metav1.ConditionTrue, "SucceededRescale", "the %s controller was able to update the target scale to %d", paType, desiredReplicas)
+		r.recordScaleDecision(&pa, explanation, desiredReplicas, r.now())
 
 		klog.InfoS("Successfully rescaled",
 			"PodAutoscaler", klog.KObj(&pa),
@@ -493,44 +766,55 @@ func (r *PodAutoscalerReconciler) reconcileCustomPA(ctx context.Context, pa auto
 			"reason", rescaleReason)
 	}
 
+	r.setStatus(&pa, currentReplicas, desiredReplicas, metricStatuses, rescale)
+	if explanation != nil {
+		setLastDecision(&pa, desiredReplicas, decision, r.now())
+	}
+	clearReconcileTimeout(&pa)
+	observeGeneration(&pa)
 	if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, &pa); err != nil {
 		// we can overwrite retErr in this case because it's an internal error.
 		return ctrl.Result{}, err
 	}
+	r.resetMetricsFailures(&pa)
 
-	return ctrl.Result{}, nil
+	// the metrics change without watch events, the PodAutoscaler is re-evaluated after its sync period.
+	return ctrl.Result{RequeueAfter: r.getSyncPeriod(&pa)}, nil
 }
 
-// scaleForResourceMappings attempts to fetch the scale for the resource with the given name and namespace,
-// trying each RESTMapping in turn until a working one is found.  If none work, the first error is returned.
-// It returns both the scale, as well as the group-resource from the working mapping.
-func (r *PodAutoscalerReconciler) scaleForResourceMappings(ctx context.Context, namespace, name string, mappings []*apimeta.RESTMapping) (*unstructured.Unstructured, schema.GroupResource, error) {
+// scaleForResourceMappings attempts to fetch the scale subresource of the resource with the given name and namespace,
+// trying each RESTMapping in turn until a working one is found. If none work, the first error is returned.
+// It returns the Scale, the target object whose selector picks the pods, and the group-resource from the working
+// mapping. The replicas are read from the Scale, which the built-in workloads, e.g. Deployments and StatefulSets,
+// and the custom resources declaring the scale subresource implement alike, wherever their replicas field lives.
+// The pod selector of the Scale is recorded on the target, see getScalePodSelector.
+func (r *PodAutoscalerReconciler) scaleForResourceMappings(ctx context.Context, namespace, name string, mappings []*apimeta.RESTMapping) (*unstructured.Unstructured, *unstructured.Unstructured, schema.GroupResource, error) {
 	var firstErr error
-	for i, mapping := range mappings {
-		targetGR := mapping.Resource.GroupResource()
-
-		gvk := schema.GroupVersionKind{
-			Group:   mapping.GroupVersionKind.Group,
-			Version: mapping.GroupVersionKind.Version,
-			Kind:    mapping.GroupVersionKind.Kind,
+	for _, mapping := range mappings {
+		target := &unstructured.Unstructured{}
+		target.SetGroupVersionKind(mapping.GroupVersionKind)
+		target.SetNamespace(namespace)
+		target.SetName(name)
+
+		scale := newScaleSubresource(target)
+		err := r.SubResource("scale").Get(ctx, target, scale)
+		if apierrors.IsNotFound(err) {
+			// a target which exists without the subresource can't be scaled through any mapping.
+			if getErr := r.Get(ctx, client.ObjectKeyFromObject(target), target); getErr == nil {
+				return nil, nil, schema.GroupResource{}, fmt.Errorf("%s %s does not implement the scale subresource, "+
+					"only the resources implementing it can be autoscaled: %w", mapping.GroupVersionKind.GroupKind(), name, err)
+			}
 		}
-		scale := &unstructured.Unstructured{}
-		scale.SetGroupVersionKind(gvk)
-		scale.SetNamespace(namespace)
-		scale.SetName(name)
-
-		err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, scale)
 		if err == nil {
-			return scale, targetGR, nil
+			err = r.Get(ctx, client.ObjectKeyFromObject(target), target)
 		}
-
-		if firstErr == nil {
-			firstErr = err
+		if err == nil {
+			recordScaleSelector(target, scale)
+			return scale, target, mapping.Resource.GroupResource(), nil
 		}
 
-		// if this is the first error, remember it,
-		// then go on and try other mappings until we find a good one
-		if i == 0 {
+		// remember the first error, then go on and try other mappings until we find a good one
+		if firstErr == nil {
 			firstErr = err
 		}
 	}
@@ -540,163 +824,131 @@ func (r *PodAutoscalerReconciler) scaleForResourceMappings(ctx context.Context,
 		firstErr = fmt.Errorf("unrecognized resource")
 	}
 
-	return nil, schema.GroupResource{}, firstErr
-}
-
-func (r *PodAutoscalerReconciler) updateScale(ctx context.Context, namespace string, targetGR schema.GroupResource, scale *unstructured.Unstructured, replicas int32) error {
-	err := unstructured.SetNestedField(scale.Object, int64(replicas), "spec", "replicas")
-	if err != nil {
-		return err
-	}
-
-	// Update scale object
-	err = r.Update(ctx, scale)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return nil, nil, schema.GroupResource{}, firstErr
 }
 
 // setCondition sets the specific condition type on the given PA to the specified value with the given reason
-// and message.  The message and args are treated like a format string.  The condition will be added if it is
-// not present.
+// and message, observed at the generation of the PA.  The message and args are treated like a format string.
+// The condition will be added if it is not present.
 func setCondition(pa *autoscalingv1alpha1.PodAutoscaler, conditionType string, status metav1.ConditionStatus, reason, message string, args ...interface{}) {
-	pa.Status.Conditions = podutils.SetConditionInList(pa.Status.Conditions, conditionType, status, reason, message, args...)
-}
-
-// setCurrentReplicasAndMetricsInStatus sets the current replica count and metrics in the status of the PA.
-func (r *PodAutoscalerReconciler) setCurrentReplicasAndMetricsInStatus(pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas int32) {
-	r.setStatus(pa, currentReplicas, pa.Status.DesiredScale, false)
-}
-
-// setStatus recreates the status of the given PA, updating the current and
-// desired replicas, as well as the metric statuses
-func (r *PodAutoscalerReconciler) setStatus(pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas, desiredReplicas int32, rescale bool) {
-	pa.Status = autoscalingv1alpha1.PodAutoscalerStatus{
-		ActualScale:   currentReplicas,
-		DesiredScale:  desiredReplicas,
-		LastScaleTime: pa.Status.LastScaleTime,
-		Conditions:    pa.Status.Conditions,
-	}
-
-	if rescale {
-		now := metav1.NewTime(time.Now())
-		pa.Status.LastScaleTime = &now
-	}
-}
-
-func (r *PodAutoscalerReconciler) updateStatusIfNeeded(ctx context.Context, oldStatus *autoscalingv1alpha1.PodAutoscalerStatus, newPA *autoscalingv1alpha1.PodAutoscaler) error {
-	// skip status update if the status is not exact same
-	if apiequality.Semantic.DeepEqual(oldStatus, newPA.Status) {
-		return nil
-	}
-	return r.updateStatus(ctx, newPA)
-}
-
-// updateStatus actually does the update request for the status of the given PA
-func (r *PodAutoscalerReconciler) updateStatus(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) error {
-	if err := r.Status().Update(ctx, pa); err != nil {
-		r.EventRecorder.Event(pa, corev1.EventTypeWarning, "FailedUpdateStatus", err.Error())
-		return fmt.Errorf("failed to update status for %s: %v", pa.Name, err)
-	}
-	logger := klog.FromContext(ctx)
-	logger.V(4).Info("Successfully updated status", "PodAutoscaler", klog.KObj(pa))
-	return nil
+	pa.Status.Conditions = podutils.SetConditionInList(pa.Status.Conditions, conditionType, status, pa.Generation, reason, message, args...)
 }
 
 // computeReplicasForMetrics computes the desired number of replicas for the metric specifications listed in the pod autoscaler,
-// returning the maximum of the computed replica counts, a description of the associated metric, and the statuses of
-// all metrics computed.
-// It may return both valid metricDesiredReplicas and an error,
+// returning the scale result holding the computed replica count and the observed metric value, a description of the
+// associated metric, and the statuses of all metrics computed.
+// Like the HPA, the highest replica count recommended by the metrics wins, the metric description names the metric
+// which recommended it.
+// It may return both a valid scale result and an error,
 // when some metrics still work and PA should perform scaling based on them.
-// If PodAutoscaler cannot do anything due to error, it returns -1 in metricDesiredReplicas as a failure signal.
-func (r *PodAutoscalerReconciler) computeReplicasForMetrics(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured, metricKey metrics.NamespaceNameMetric) (replicas int32, relatedMetrics string, timestamp time.Time, err error) {
+// If PodAutoscaler cannot do anything due to error, the scale result is not valid.
+func (r *PodAutoscalerReconciler) computeReplicasForMetrics(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured, targets []metricTarget) (result scaler.ScaleResult, relatedMetrics string, statuses []autoscalingv1alpha1.MetricStatus, timestamp time.Time, err error) {
 	logger := klog.FromContext(ctx)
-	currentTimestamp := time.Now()
+	currentTimestamp := r.now()
 
-	// Retrieve the selector string from the Scale object's Status,
-	// and convert *metav1.LabelSelector object to labels.Selector structure
-	labelsSelector, err := extractLabelSelector(scale)
+	labelsSelector, err := getScalePodSelector(scale)
 	if err != nil {
-		return 0, "", currentTimestamp, err
-	}
-
-	// Append ray head worker requirement for label selector
-	if scale.GetAPIVersion() == orchestrationv1alpha1.GroupVersion.String() && scale.GetKind() == "RayClusterFleet" {
-		newRequirement, err := labels.NewRequirement("ray.io/node-type", selection.Equals, []string{"head"})
-		if err != nil {
-			klog.ErrorS(err, "Failed to add new requirements ray.io/node-type: head to label selector")
-			return 0, "", currentTimestamp, err
-		}
-		labelsSelector = labelsSelector.Add(*newRequirement)
+		return scaler.ScaleResult{}, "", nil, currentTimestamp, err
 	}
 
 	originalReadyPodsCount, err := scaler.GetReadyPodsCount(ctx, r.Client, pa.Namespace, labelsSelector)
 
 	if err != nil {
-		return 0, "", currentTimestamp, fmt.Errorf("error getting ready pods count: %w", err)
-	}
-
-	// TODO UpdateScalingContext (in updateScalerSpec) is duplicate invoked in computeReplicasForMetrics and updateMetricsForScale
-	err = r.updateScalerSpec(ctx, pa, metricKey)
-	if err != nil {
-		klog.ErrorS(err, "Failed to update scaler spec from pa_types")
-		return 0, "", currentTimestamp, fmt.Errorf("error update scaler spec: %w", err)
+		return scaler.ScaleResult{}, "", nil, currentTimestamp, fmt.Errorf("error getting ready pods count: %w", err)
 	}
 
 	logger.V(4).Info("Obtained selector and get ReadyPodsCount", "selector", labelsSelector, "originalReadyPodsCount", originalReadyPodsCount)
 
-	// Calculate the desired number of pods using the autoscaler logic.
-	autoScaler, ok := r.AutoscalerMap[metricKey]
-	if !ok {
-		return 0, "", currentTimestamp, fmt.Errorf("unsupported scaling strategy: %s", pa.Spec.ScalingStrategy)
-	}
-	scaleResult := autoScaler.Scale(int(originalReadyPodsCount), metricKey, currentTimestamp)
-	if scaleResult.ScaleValid {
-		logger.V(4).Info("Successfully called Scale Algorithm", "scaleResult", scaleResult)
-		return scaleResult.DesiredPodCount, metricKey.MetricName, currentTimestamp, nil
+	var errs []error
+	panicking := false
+	for _, target := range targets {
+		// TODO UpdateScalingContext (in updateScalerSpec) is duplicate invoked in computeReplicasForMetrics and updateMetricsForScale
+		err = r.updateScalerSpec(ctx, forMetricSource(pa, target.source), target.key)
+		if err != nil {
+			klog.ErrorS(err, "Failed to update scaler spec from pa_types", "metric", target.key.MetricName)
+			errs = append(errs, fmt.Errorf("error update scaler spec of metric %s: %w", target.key.MetricName, err))
+			continue
+		}
+
+		// Calculate the desired number of pods using the autoscaler logic.
+		autoScaler, ok := r.getScaler(target.key)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unsupported scaling strategy: %s", pa.Spec.ScalingStrategy))
+			continue
+		}
+		scaleResult := autoScaler.Scale(int(originalReadyPodsCount), target.key, currentTimestamp)
+		if !scaleResult.ScaleValid {
+			errs = append(errs, fmt.Errorf("can not calculate metric %s for scale %s: %s", target.key.MetricName, pa.Spec.ScaleTargetRef.Name, scaleResult.Reason))
+			continue
+		}
+		logger.V(4).Info("Successfully called Scale Algorithm", "metric", target.key.MetricName, "scaleResult", scaleResult)
+		statuses = append(statuses, newMetricStatus(target, scaleResult, originalReadyPodsCount, currentTimestamp))
+		recordMetricValues(&pa, target.key.MetricName, scaleResult)
+		panicking = panicking || scaleResult.InPanicMode
+
+		// on a tie, the metric observing traffic wins, which keeps a scale-to-zero target active.
+		if !result.ScaleValid || scaleResult.DesiredPodCount > result.DesiredPodCount ||
+			(scaleResult.DesiredPodCount == result.DesiredPodCount && result.ObservedValue <= 0 && scaleResult.ObservedValue > 0) {
+			result = scaleResult
+			relatedMetrics = target.key.MetricName
+		}
 	}
 
-	return 0, "", currentTimestamp, fmt.Errorf("can not calculate metrics for scale %s", pa.Spec.ScaleTargetRef.Name)
+	if len(targets) == 0 {
+		errs = append(errs, fmt.Errorf("no metrics to calculate for scale %s", pa.Spec.ScaleTargetRef.Name))
+	}
+	// the target panics if the scaler of any of its metrics does.
+	result.InPanicMode = panicking
+	return result, relatedMetrics, statuses, currentTimestamp, errors.Join(errs...)
 }
 
 // refer to knative-serving.
 // In pkg/reconciler/autoscaling/kpa/kpa.go:198, kpa maintains a list of deciders into multi-scaler, each of them corresponds to a pa (PodAutoscaler).
 // We create or update the scaler instance according to the pa passed in
 func (r *PodAutoscalerReconciler) updateScalerSpec(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler, metricKey metrics.NamespaceNameMetric) error {
-	autoScaler, ok := r.AutoscalerMap[metricKey]
+	autoScaler, ok := r.getScaler(metricKey)
 	if !ok {
 		return fmt.Errorf("unsupported scaling strategy: %s", pa.Spec.ScalingStrategy)
 	}
 	return autoScaler.UpdateScalingContext(pa)
 }
 
+func (r *PodAutoscalerReconciler) getMetricFetcher() metrics.MetricFetcher {
+	if r.metricFetcher == nil {
+		return metrics.NewRestMetricsFetcher()
+	}
+	return r.metricFetcher
+}
+
+// now returns the time of the metric samples and of the scaling decisions.
+func (r *PodAutoscalerReconciler) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
 // updateMetricsForScale: we pass into the currentReplicas to construct autoScaler, as KNative implementation
 func (r *PodAutoscalerReconciler) updateMetricsForScale(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured, metricKey metrics.NamespaceNameMetric, metricSource autoscalingv1alpha1.MetricSource, currentReplicas int) (err error) {
-	currentTimestamp := time.Now()
+	currentTimestamp := r.now()
 	var autoScaler scaler.Scaler
 	// it's similar to knative: pkg/autoscaler/scaling/multiscaler.go: func (m *MultiScaler) Create
-	autoScaler, exists := r.AutoscalerMap[metricKey]
+	autoScaler, exists := r.getScaler(metricKey)
 	if !exists {
 		klog.InfoS("Scaler not found, creating new scaler", "metricKey", metricKey, "type", pa.Spec.ScalingStrategy)
 
-		switch pa.Spec.ScalingStrategy {
-		case autoscalingv1alpha1.KPA:
-			// initialize all kinds of autoscalers, such as KPA and APA.
-			// TODO Currently, we initialize kpa with default config and allocate window with default length.
-			//  We then reallocate window according to pa until UpdateScalingContext.
-			//  it's not wrong, but we allocate window twice, to be optimized.
-			autoScaler, err = scaler.NewKpaAutoscaler(currentReplicas, &pa, time.Now())
-		case autoscalingv1alpha1.APA:
-			autoScaler, err = scaler.NewApaAutoscaler(currentReplicas, &pa)
-		default:
-			return fmt.Errorf("unsupported scaling strategy: %s", pa.Spec.ScalingStrategy)
-		}
+		// initialize the autoscaler of the strategy, such as KPA and APA.
+		autoScaler, err = scaler.NewScaler(pa.Spec.ScalingStrategy, scaler.ScalerSpec{
+			PodAutoscaler:  &pa,
+			ReadyPodsCount: currentReplicas,
+			MetricFetcher:  r.getMetricFetcher(),
+			Now:            r.now(),
+		})
 		if err != nil {
 			return err
 		}
-		r.AutoscalerMap[metricKey] = autoScaler
+		restoreScalerState(&pa, autoScaler, currentTimestamp)
+		r.setScaler(metricKey, autoScaler)
 		klog.InfoS("New scaler added to AutoscalerMap", "metricKey", metricKey, "type", pa.Spec.ScalingStrategy, "spec", pa.Spec)
 	} else {
 		err := autoScaler.UpdateScalingContext(pa)
@@ -706,39 +958,87 @@ func (r *PodAutoscalerReconciler) updateMetricsForScale(ctx context.Context, pa
 		}
 	}
 
-	// Retrieve the selector string from the Scale object's Status,
-	// and convert *metav1.LabelSelector object to labels.Selector structure
-	labelsSelector, err := extractLabelSelector(scale)
+	labelsSelector, err := getScalePodSelector(scale)
 	if err != nil {
 		return err
 	}
 
-	// Append ray head worker requirement for label selector
-	if scale.GetAPIVersion() == orchestrationv1alpha1.GroupVersion.String() && scale.GetKind() == "RayClusterFleet" {
-		newRequirement, err := labels.NewRequirement("ray.io/node-type", selection.Equals, []string{"head"})
+	// The metrics come from the pod prometheus endpoints, a domain, the Kubernetes custom and external metrics APIs, or
+	// Redis.
+	switch metricSource.MetricSourceType {
+	case autoscalingv1alpha1.POD:
+		// Get pod list managed by scaleTargetRef
+		podList, err := podutil.GetPodListByLabelSelector(ctx, r.Client, pa.Namespace, labelsSelector)
 		if err != nil {
-			klog.ErrorS(err, "Failed to add new requirements ray.io/node-type: head to label selector")
+			klog.ErrorS(err, "failed to get pod list by label selector")
 			return err
 		}
-		labelsSelector = labelsSelector.Add(*newRequirement)
-	}
-
-	// Get pod list managed by scaleTargetRef
-	podList, err := podutil.GetPodListByLabelSelector(ctx, r.Client, pa.Namespace, labelsSelector)
-	if err != nil {
-		klog.ErrorS(err, "failed to get pod list by label selector")
-		return err
-	}
-
-	// TODO: do we need to indicate the metrics source.
-	// Technically, the metrics could come from Kubernetes metrics API (resource or custom), pod prometheus endpoint or ai runtime
-
-	switch metricSource.MetricSourceType {
-	case autoscalingv1alpha1.POD:
 		return autoScaler.UpdateScaleTargetMetrics(ctx, metricKey, metricSource, podList.Items, currentTimestamp)
 	case autoscalingv1alpha1.DOMAIN:
 		return autoScaler.UpdateSourceMetrics(ctx, metricKey, metricSource, currentTimestamp)
+	case autoscalingv1alpha1.CUSTOM, autoscalingv1alpha1.OBJECT, autoscalingv1alpha1.EXTERNAL:
+		metricValues, err := r.getMetricsFromMetricsAPI(ctx, pa, labelsSelector, metricSource)
+		if err != nil {
+			return err
+		}
+		return autoScaler.UpdateMetrics(metricKey, currentTimestamp, metricValues...)
+	case autoscalingv1alpha1.REDIS:
+		value, err := r.getMetricFromRedis(ctx, pa, scale, metricSource)
+		if err != nil {
+			return err
+		}
+		return autoScaler.UpdateMetrics(metricKey, currentTimestamp, value)
 	default:
 		return fmt.Errorf("unsupported protocol type: %v", metricSource.ProtocolType)
 	}
 }
+
+// scaleSelectorAnnotation records the pod selector of the Scale on the target read by scaleForResourceMappings. The
+// annotation only lives in memory, the target itself is never written back.
+const scaleSelectorAnnotation = scalingcontext.AutoscalingLabelPrefix + "scale-selector"
+
+// recordScaleSelector records the selector of the Scale status on the target, if the target reports one.
+func recordScaleSelector(target, scale *unstructured.Unstructured) {
+	selector, _, _ := unstructured.NestedString(scale.Object, "status", "selector")
+	if selector == "" {
+		return
+	}
+	annotations := target.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[scaleSelectorAnnotation] = selector
+	target.SetAnnotations(annotations)
+}
+
+// getScalePodSelector returns the selector of the pods managed by the scale target. The selector of the Scale
+// status is preferred, so that the custom resources are supported wherever their selector lives, the spec.selector
+// of the target is used otherwise. Only the head pods of a RayClusterFleet are selected, since the workers do not
+// serve requests.
+func getScalePodSelector(scale *unstructured.Unstructured) (labels.Selector, error) {
+	var labelsSelector labels.Selector
+	if selector, ok := scale.GetAnnotations()[scaleSelectorAnnotation]; ok {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the selector %q of the scale of %s %s: %v", selector, scale.GetKind(), scale.GetName(), err)
+		}
+		labelsSelector = parsed
+	} else {
+		extracted, err := extractLabelSelector(scale)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s reports no pod selector in its scale subresource: %v", scale.GetKind(), scale.GetName(), err)
+		}
+		labelsSelector = extracted
+	}
+
+	// Append ray head worker requirement for label selector
+	if scale.GetAPIVersion() == orchestrationv1alpha1.GroupVersion.String() && scale.GetKind() == "RayClusterFleet" {
+		newRequirement, err := labels.NewRequirement("ray.io/node-type", selection.Equals, []string{"head"})
+		if err != nil {
+			klog.ErrorS(err, "Failed to add new requirements ray.io/node-type: head to label selector")
+			return nil, err
+		}
+		labelsSelector = labelsSelector.Add(*newRequirement)
+	}
+	return labelsSelector, nil
+}
@@ -18,24 +18,271 @@ package podautoscaler
 
 import (
 	"context"
-
-	corev1 "k8s.io/api/core/v1"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"k8s.io/apimachinery/pkg/api/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-
 	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
 )
 
 func IntToPtr(num int32) *int32 {
 	return &num
 }
 
+const (
+	testNamespace  = "default"
+	testPaName     = "test-pa"
+	testDeployName = "test-deployment"
+)
+
+// newTestDeployment creates a deployment managed by the PodAutoscaler under test.
+func newTestDeployment(replicas int32) *appsv1.Deployment {
+	labels := map[string]string{"app": testDeployName}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testDeployName,
+			Namespace: testNamespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "main", Image: "main"}},
+				},
+			},
+		},
+	}
+}
+
+// newTestPodAutoscaler creates a KPA PodAutoscaler targeting the test deployment.
+func newTestPodAutoscaler(minReplicas *int32, maxReplicas int32, annotations map[string]string) *autoscalingv1alpha1.PodAutoscaler {
+	return &autoscalingv1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        testPaName,
+			Namespace:   testNamespace,
+			Annotations: annotations,
+		},
+		Spec: autoscalingv1alpha1.PodAutoscalerSpec{
+			ScaleTargetRef: corev1.ObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       testDeployName,
+			},
+			MinReplicas: minReplicas,
+			MaxReplicas: maxReplicas,
+			MetricsSources: []autoscalingv1alpha1.MetricSource{
+				{
+					MetricSourceType: autoscalingv1alpha1.POD,
+					ProtocolType:     autoscalingv1alpha1.HTTP,
+					Path:             "metrics",
+					Port:             "8000",
+					TargetMetric:     "test_metric",
+					TargetValue:      "1",
+				},
+			},
+			ScalingStrategy: autoscalingv1alpha1.KPA,
+		},
+	}
+}
+
+// newTestReconciler builds a PodAutoscalerReconciler backed by a fake client holding the given objects.
+func newTestReconciler(t *testing.T, objs ...client.Object) (*PodAutoscalerReconciler, *record.FakeRecorder) {
+	t.Helper()
+	return newTestReconcilerWithInterceptor(t, interceptor.Funcs{}, objs...)
+}
+
+// getTestScaleSubresource emulates the scale subresource of the API server for the unstructured targets, which
+// the fake client does not support.
+func getTestScaleSubresource(ctx context.Context, c client.Client, subResourceName string, obj, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	target, isUnstructured := obj.(*unstructured.Unstructured)
+	scale, isScale := subResource.(*unstructured.Unstructured)
+	if subResourceName != "scale" || !isUnstructured || !isScale {
+		return c.SubResource(subResourceName).Get(ctx, obj, subResource, opts...)
+	}
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(target.GroupVersionKind())
+	if err := c.Get(ctx, client.ObjectKeyFromObject(target), current); err != nil {
+		return err
+	}
+	replicas, _, _ := unstructured.NestedInt64(current.Object, "spec", "replicas")
+	scale.SetResourceVersion(current.GetResourceVersion())
+	return unstructured.SetNestedField(scale.Object, replicas, "spec", "replicas")
+}
+
+// updateTestScaleSubresource emulates the scale subresource of the API server for the unstructured targets: the
+// spec.replicas of the target is set from the Scale body, guarded by its resource version.
+func updateTestScaleSubresource(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	target, isUnstructured := obj.(*unstructured.Unstructured)
+	if subResourceName != "scale" || !isUnstructured {
+		return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+	}
+	updateOptions := client.SubResourceUpdateOptions{}
+	updateOptions.ApplyOptions(opts)
+	scale, ok := updateOptions.SubResourceBody.(*unstructured.Unstructured)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a Scale body, got %T", updateOptions.SubResourceBody))
+	}
+	if scale.GetKind() != "Scale" {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a Scale body, got %s", scale.GetKind()))
+	}
+	replicas, found, err := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+	if err != nil || !found {
+		return apierrors.NewBadRequest("the Scale body has no spec.replicas")
+	}
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(target.GroupVersionKind())
+	if err := c.Get(ctx, client.ObjectKeyFromObject(target), current); err != nil {
+		return err
+	}
+	if rv := scale.GetResourceVersion(); rv != "" && rv != current.GetResourceVersion() {
+		return apierrors.NewConflict(schema.GroupResource{Group: target.GroupVersionKind().Group, Resource: "scale"}, target.GetName(), errors.New("the object has been modified"))
+	}
+	if err := unstructured.SetNestedField(current.Object, replicas, "spec", "replicas"); err != nil {
+		return err
+	}
+	return c.Update(ctx, current)
+}
+
+// newTestReconcilerWithInterceptor creates a reconciler whose client calls go through the given interceptor, e.g. to inject errors.
+// The scale subresource is emulated unless the interceptor overrides it.
+func newTestReconcilerWithInterceptor(t *testing.T, funcs interceptor.Funcs, objs ...client.Object) (*PodAutoscalerReconciler, *record.FakeRecorder) {
+	t.Helper()
+	if funcs.SubResourceGet == nil {
+		funcs.SubResourceGet = getTestScaleSubresource
+	}
+	if funcs.SubResourceUpdate == nil {
+		funcs.SubResourceUpdate = updateTestScaleSubresource
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add client-go scheme: %v", err)
+	}
+	if err := autoscalingv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add autoscaling scheme: %v", err)
+	}
+
+	mapper := apimeta.NewDefaultRESTMapper([]schema.GroupVersion{appsv1.SchemeGroupVersion})
+	mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), apimeta.RESTScopeNamespace)
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&autoscalingv1alpha1.PodAutoscaler{}).
+		WithIndex(&autoscalingv1alpha1.PodAutoscaler{}, scaleTargetField, indexScaleTarget).
+		WithInterceptorFuncs(funcs).
+		Build()
+	recorder := record.NewFakeRecorder(100)
+
+	return &PodAutoscalerReconciler{
+		Client:        fakeClient,
+		Scheme:        scheme,
+		EventRecorder: recorder,
+		Mapper:        mapper,
+		AutoscalerMap: make(map[metrics.NamespaceNameMetric]scaler.Scaler),
+	}, recorder
+}
+
+func reconcileTestPodAutoscaler(t *testing.T, r *PodAutoscalerReconciler) error {
+	t.Helper()
+	_, err := r.Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: testPaName},
+	})
+	return err
+}
+
+func getTestPodAutoscaler(t *testing.T, r *PodAutoscalerReconciler) *autoscalingv1alpha1.PodAutoscaler {
+	t.Helper()
+	pa := &autoscalingv1alpha1.PodAutoscaler{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testPaName}, pa); err != nil {
+		t.Fatalf("failed to get PodAutoscaler: %v", err)
+	}
+	return pa
+}
+
+func getTestDeploymentReplicas(t *testing.T, r *PodAutoscalerReconciler) int32 {
+	t.Helper()
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testDeployName}, deploy); err != nil {
+		t.Fatalf("failed to get Deployment: %v", err)
+	}
+	return *deploy.Spec.Replicas
+}
+
+// countEvents drains the fake recorder and returns the number of events containing the given reason.
+func countEvents(recorder *record.FakeRecorder, reason string) int {
+	count := 0
+	for {
+		select {
+		case e := <-recorder.Events:
+			if strings.Contains(e, reason) {
+				count++
+			}
+		default:
+			return count
+		}
+	}
+}
+
+// newTestPod creates a pod of the test deployment in the given readiness state.
+func newTestPod(name string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{"app": testDeployName},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+// newTestAPAObjects creates the test deployment with ready pods scraped from the metrics server, and an APA
+// PodAutoscaler targeting 4 per pod.
+func newTestAPAObjects(replicas int32, port string, annotations map[string]string) []client.Object {
+	pa := newTestPodAutoscaler(nil, 10, annotations)
+	pa.Spec.ScalingStrategy = autoscalingv1alpha1.APA
+	pa.Spec.MetricsSources[0].Port = port
+	pa.Spec.MetricsSources[0].TargetValue = "4"
+
+	objs := []client.Object{newTestDeployment(replicas), pa}
+	for i := int32(0); i < replicas; i++ {
+		pod := newTestPod(fmt.Sprintf("test-pod-%d", i), true)
+		pod.Status.PodIP = "127.0.0.1"
+		objs = append(objs, pod)
+	}
+	return objs
+}
+
 var _ = Describe("PodAutoscaler Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
@@ -51,7 +298,7 @@ var _ = Describe("PodAutoscaler Controller", func() {
 		BeforeEach(func() {
 			By("creating the custom resource for the Kind PodAutoscaler")
 			err := k8sClient.Get(ctx, typeNamespacedName, podautoscaler)
-			if err != nil && errors.IsNotFound(err) {
+			if err != nil && apierrors.IsNotFound(err) {
 				resource := &autoscalingv1alpha1.PodAutoscaler{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      resourceName,
@@ -106,3 +353,92 @@ var _ = Describe("PodAutoscaler Controller", func() {
 		})
 	})
 })
+
+func TestReconcileRescaleStatus(t *testing.T) {
+	minReplicas := int32(3)
+	testCases := []struct {
+		name              string
+		failSubResource   string
+		expectedReplicas  int32
+		expectedCondition metav1.ConditionStatus
+		expectedReason    string
+		expectedDesired   int32
+		expectedScaleTime bool
+		expectedEvent     string
+	}{
+		{
+			name:              "success",
+			expectedReplicas:  3,
+			expectedCondition: metav1.ConditionTrue,
+			expectedReason:    "SucceededRescale",
+			expectedDesired:   3,
+			expectedScaleTime: true,
+			expectedEvent:     "SuccessfulRescale",
+		},
+		{
+			// the desired scale of the failed rescale is not persisted.
+			name:              "actuation failure",
+			failSubResource:   "scale",
+			expectedReplicas:  1,
+			expectedCondition: metav1.ConditionFalse,
+			expectedReason:    "FailedUpdateScale",
+			expectedEvent:     "FailedRescale",
+		},
+		{
+			// the target is scaled, the status is written again by the next reconcile.
+			name:             "status write failure",
+			failSubResource:  "status",
+			expectedReplicas: 3,
+			expectedEvent:    "FailedUpdateStatus",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			funcs := interceptor.Funcs{
+				SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+					if subResourceName == tc.failSubResource {
+						return apierrors.NewInternalError(errors.New("etcd unavailable"))
+					}
+					return updateTestScaleSubresource(ctx, c, subResourceName, obj, opts...)
+				},
+				SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+					if subResourceName == tc.failSubResource {
+						return apierrors.NewInternalError(errors.New("etcd unavailable"))
+					}
+					return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+				},
+			}
+			r, recorder := newTestReconcilerWithInterceptor(t, funcs, newTestDeployment(1), newTestPodAutoscaler(&minReplicas, 10, nil))
+			err := reconcileTestPodAutoscaler(t, r)
+			if (tc.failSubResource != "") != (err != nil) {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			if count := countEvents(recorder, tc.expectedEvent); count != 1 {
+				t.Errorf("expected one %s event, got %d", tc.expectedEvent, count)
+			}
+			pa := getTestPodAutoscaler(t, r)
+			cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.AbleToScale)
+			if tc.expectedReason == "" {
+				if cond != nil {
+					t.Errorf("expected no persisted AbleToScale condition, got %+v", cond)
+				}
+			} else if cond == nil || cond.Status != tc.expectedCondition || cond.Reason != tc.expectedReason {
+				t.Errorf("expected AbleToScale to be %s with reason %s, got %+v", tc.expectedCondition, tc.expectedReason, cond)
+			}
+			if tc.expectedReason != "" && pa.Status.ActualScale != 1 {
+				t.Errorf("expected the actual scale 1 observed before the rescale, got %d", pa.Status.ActualScale)
+			}
+			if pa.Status.DesiredScale != tc.expectedDesired {
+				t.Errorf("expected the desired scale %d, got %d", tc.expectedDesired, pa.Status.DesiredScale)
+			}
+			if (pa.Status.LastScaleTime != nil) != tc.expectedScaleTime {
+				t.Errorf("expected a scale time %t, got %v", tc.expectedScaleTime, pa.Status.LastScaleTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// podAutoscalerPredicate skips the status updates written by the reconciler itself, they don't bump the
+// generation. The scaling parameters live in annotations, so annotation changes are still reconciled.
+// Metric-based scaling is driven by the periodical requeue events, which are not filtered.
+func podAutoscalerPredicate() predicate.Predicate {
+	return predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		predicate.AnnotationChangedPredicate{},
+	)
+}
+
+// hpaPredicate only enqueues the managing PodAutoscaler when the HPA fields mirrored into its status change,
+// e.g. the frequent updates of the current metrics and last scale time are skipped.
+func hpaPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldHPA, ok := e.ObjectOld.(*autoscalingv2.HorizontalPodAutoscaler)
+			if !ok {
+				return true
+			}
+			newHPA, ok := e.ObjectNew.(*autoscalingv2.HorizontalPodAutoscaler)
+			if !ok {
+				return true
+			}
+			return oldHPA.Generation != newHPA.Generation ||
+				oldHPA.Status.CurrentReplicas != newHPA.Status.CurrentReplicas ||
+				oldHPA.Status.DesiredReplicas != newHPA.Status.DesiredReplicas ||
+				!apiequality.Semantic.DeepEqual(oldHPA.Status.Conditions, newHPA.Status.Conditions)
+		},
+	}
+}
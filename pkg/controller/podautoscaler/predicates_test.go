@@ -0,0 +1,104 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func TestPodAutoscalerPredicateSkipsStatusUpdates(t *testing.T) {
+	pa := newTestPodAutoscaler(nil, 10, nil)
+	pa.Generation = 1
+	r, _ := newTestReconciler(t, newTestDeployment(0), pa)
+	p := podAutoscalerPredicate()
+
+	before := getTestPodAutoscaler(t, r)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	after := getTestPodAutoscaler(t, r)
+	if apiequality.Semantic.DeepEqual(before.Status, after.Status) {
+		t.Fatalf("expected the reconcile to write the status")
+	}
+	if p.Update(event.UpdateEvent{ObjectOld: before, ObjectNew: after}) {
+		t.Errorf("expected the status write of the reconciler not to enqueue another reconcile")
+	}
+
+	annotated := after.DeepCopy()
+	annotated.Annotations = map[string]string{scalingcontext.ScaleToZeroLabel: "true"}
+	if !p.Update(event.UpdateEvent{ObjectOld: after, ObjectNew: annotated}) {
+		t.Errorf("expected an annotation change to be reconciled")
+	}
+	changed := after.DeepCopy()
+	changed.Spec.MaxReplicas = 20
+	changed.Generation++
+	if !p.Update(event.UpdateEvent{ObjectOld: after, ObjectNew: changed}) {
+		t.Errorf("expected a spec change to be reconciled")
+	}
+
+	// the requeue after the sync period bypasses the predicates and keeps driving the metric-based scaling.
+	result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: testPaName}})
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if result.RequeueAfter != DefaultSyncPeriod {
+		t.Errorf("expected a requeue after %v, got %v", DefaultSyncPeriod, result.RequeueAfter)
+	}
+}
+
+func TestHPAPredicate(t *testing.T) {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: testPaName, Namespace: testNamespace, Generation: 1},
+		Status:     autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: 2, DesiredReplicas: 2},
+	}
+	p := hpaPredicate()
+
+	metricsOnly := hpa.DeepCopy()
+	metricsOnly.Status.LastScaleTime = &metav1.Time{Time: time.Now()}
+	metricsOnly.Status.CurrentMetrics = []autoscalingv2.MetricStatus{{Type: autoscalingv2.ResourceMetricSourceType}}
+	if p.Update(event.UpdateEvent{ObjectOld: hpa, ObjectNew: metricsOnly}) {
+		t.Errorf("expected a metrics only update to be skipped")
+	}
+
+	scaled := hpa.DeepCopy()
+	scaled.Status.DesiredReplicas = 3
+	if !p.Update(event.UpdateEvent{ObjectOld: hpa, ObjectNew: scaled}) {
+		t.Errorf("expected a desired replicas change to be reconciled")
+	}
+	limited := hpa.DeepCopy()
+	limited.Status.Conditions = []autoscalingv2.HorizontalPodAutoscalerCondition{
+		{Type: autoscalingv2.ScalingLimited, Status: corev1.ConditionTrue},
+	}
+	if !p.Update(event.UpdateEvent{ObjectOld: hpa, ObjectNew: limited}) {
+		t.Errorf("expected a condition change to be reconciled")
+	}
+	if !p.Create(event.CreateEvent{Object: hpa}) || !p.Delete(event.DeleteEvent{Object: hpa}) {
+		t.Errorf("expected create and delete events to be reconciled")
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+const (
+	// ReplicaQuotaConfigMapName names the ConfigMap capping the total replicas of the PodAutoscalers of its
+	// namespace, with the cap in its maxReplicas key.
+	ReplicaQuotaConfigMapName = "aibrix-replica-quota"
+	// replicaQuotaMaxReplicasKey is the key of the replica quota ConfigMap holding the cap.
+	replicaQuotaMaxReplicasKey = "maxReplicas"
+	// clusterReplicaQuotaName names the cluster replica quota in the QuotaLimited condition.
+	clusterReplicaQuotaName = "cluster"
+)
+
+// replicaQuota caps the total replicas of the PodAutoscalers of a namespace, or of the cluster.
+type replicaQuota struct {
+	// name is reported in the QuotaLimited condition.
+	name string
+	// namespace is the namespace of the PodAutoscalers sharing the quota, empty for the cluster.
+	namespace   string
+	maxReplicas int32
+}
+
+// getReplicaQuotas returns the replica quotas the PodAutoscalers of the namespace are subject to: the quota of the
+// namespace, set by its replica quota ConfigMap, and the quota of the cluster, set by the runtime config. The
+// ConfigMap is read from the API server, so that the ConfigMaps of the cluster are not cached.
+func (r *PodAutoscalerReconciler) getReplicaQuotas(ctx context.Context, namespace string) ([]replicaQuota, error) {
+	var quotas []replicaQuota
+	reader := r.apiReader
+	if reader == nil {
+		reader = r.Client
+	}
+	cm := &corev1.ConfigMap{}
+	err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ReplicaQuotaConfigMapName}, cm)
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to get the replica quota ConfigMap: %v", err)
+	default:
+		maxReplicas, err := strconv.ParseInt(cm.Data[replicaQuotaMaxReplicasKey], 10, 32)
+		if err != nil || maxReplicas < 0 {
+			return nil, fmt.Errorf("invalid %s %q of the replica quota ConfigMap %s/%s", replicaQuotaMaxReplicasKey,
+				cm.Data[replicaQuotaMaxReplicasKey], namespace, ReplicaQuotaConfigMapName)
+		}
+		quotas = append(quotas, replicaQuota{
+			name:        fmt.Sprintf("ConfigMap %s/%s", namespace, ReplicaQuotaConfigMapName),
+			namespace:   namespace,
+			maxReplicas: int32(maxReplicas),
+		})
+	}
+	if r.RuntimeConfig.PodAutoscalerClusterMaxReplicas > 0 {
+		quotas = append(quotas, replicaQuota{name: clusterReplicaQuotaName, maxReplicas: r.RuntimeConfig.PodAutoscalerClusterMaxReplicas})
+	}
+	return quotas, nil
+}
+
+// getQuotaUsedReplicas returns the replicas of the quota used by the PodAutoscalers other than pa. A PodAutoscaler
+// uses the larger of its desired and actual replicas, so that a target still scaling up or down is not undercounted.
+// The PodAutoscalers are listed from the cache of the manager, indexed by namespace.
+func (r *PodAutoscalerReconciler) getQuotaUsedReplicas(ctx context.Context, quota replicaQuota, pa *autoscalingv1alpha1.PodAutoscaler) (int32, error) {
+	paList := &autoscalingv1alpha1.PodAutoscalerList{}
+	var opts []client.ListOption
+	if quota.namespace != "" {
+		opts = append(opts, client.InNamespace(quota.namespace))
+	}
+	if err := r.List(ctx, paList, opts...); err != nil {
+		return 0, fmt.Errorf("failed to list the PodAutoscalers of the replica quota %s: %v", quota.name, err)
+	}
+	used := int32(0)
+	for i := range paList.Items {
+		other := &paList.Items[i]
+		if other.Namespace == pa.Namespace && other.Name == pa.Name {
+			continue
+		}
+		used += max(other.Status.DesiredScale, other.Status.ActualScale)
+	}
+	return used, nil
+}
+
+// constrainScaleUpByQuota clamps a scale up of the target so that the total replicas of the PodAutoscalers sharing a
+// replica quota stay within it, the quota never scales a target down. The QuotaLimited condition records whether the
+// last scale up was clamped and by which quota. The scale up is applied as is if the quotas can not be evaluated.
+// The reconciles of the PodAutoscalers sharing a quota only see the replicas the others last reported, concurrent
+// scale ups may overshoot the quota until the next scale downs free it up.
+func (r *PodAutoscalerReconciler) constrainScaleUpByQuota(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas, desiredReplicas int32) int32 {
+	if desiredReplicas <= currentReplicas {
+		if apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.QuotaLimited) {
+			setCondition(pa, autoscalingv1alpha1.QuotaLimited, metav1.ConditionFalse, "NoScaleUp",
+				"the desired count of %d replicas does not scale the target up", desiredReplicas)
+		}
+		return desiredReplicas
+	}
+	quotas, err := r.getReplicaQuotas(ctx, pa.Namespace)
+	if err != nil {
+		klog.ErrorS(err, "Failed to evaluate the replica quotas of the PodAutoscaler", "PodAutoscaler", klog.KObj(pa))
+		r.EventRecorder.Event(pa, corev1.EventTypeWarning, "FailedGetReplicaQuota", err.Error())
+		return desiredReplicas
+	}
+
+	allowedReplicas, limitingQuota, limitingUsed := desiredReplicas, replicaQuota{}, int32(0)
+	for _, quota := range quotas {
+		used, err := r.getQuotaUsedReplicas(ctx, quota, pa)
+		if err != nil {
+			klog.ErrorS(err, "Failed to evaluate the replica quotas of the PodAutoscaler", "PodAutoscaler", klog.KObj(pa))
+			return desiredReplicas
+		}
+		if headroom := quota.maxReplicas - used; headroom < allowedReplicas {
+			allowedReplicas, limitingQuota, limitingUsed = headroom, quota, used
+		}
+	}
+	// a quota already exhausted holds the current replicas.
+	allowedReplicas = max(allowedReplicas, currentReplicas)
+	if allowedReplicas >= desiredReplicas {
+		if apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.QuotaLimited) != nil {
+			setCondition(pa, autoscalingv1alpha1.QuotaLimited, metav1.ConditionFalse, "WithinQuota",
+				"the scale up to %d replicas is within the replica quotas", desiredReplicas)
+		}
+		return desiredReplicas
+	}
+
+	if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.QuotaLimited) {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, "QuotaLimited",
+			"scale up to %d replicas clamped to %d replicas by the replica quota %s", desiredReplicas, allowedReplicas, limitingQuota.name)
+	}
+	setCondition(pa, autoscalingv1alpha1.QuotaLimited, metav1.ConditionTrue, "QuotaExceeded",
+		"the scale up to %d replicas is clamped to %d replicas by the replica quota %s of %d replicas, %d of which are used by the other PodAutoscalers",
+		desiredReplicas, allowedReplicas, limitingQuota.name, limitingQuota.maxReplicas, limitingUsed)
+	klog.InfoS("Scale up clamped by replica quota", "PodAutoscaler", klog.KObj(pa), "quota", limitingQuota.name,
+		"recommendedReplicas", desiredReplicas, "adjustedTo", allowedReplicas)
+	return allowedReplicas
+}
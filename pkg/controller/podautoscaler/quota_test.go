@@ -0,0 +1,197 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+// newTestReplicaQuota creates the replica quota ConfigMap of the namespace.
+func newTestReplicaQuota(namespace, maxReplicas string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ReplicaQuotaConfigMapName, Namespace: namespace},
+		Data:       map[string]string{"maxReplicas": maxReplicas},
+	}
+}
+
+// newTestQuotaPodAutoscaler creates a PodAutoscaler sharing the replica quotas, which last reported the given
+// replicas.
+func newTestQuotaPodAutoscaler(namespace, name string, desiredScale, actualScale int32) *autoscalingv1alpha1.PodAutoscaler {
+	pa := newTestPodAutoscaler(nil, 10, nil)
+	pa.Namespace, pa.Name = namespace, name
+	pa.Spec.ScaleTargetRef.Name = name
+	pa.Status = autoscalingv1alpha1.PodAutoscalerStatus{DesiredScale: desiredScale, ActualScale: actualScale}
+	return pa
+}
+
+func TestReconcileQuotaLimited(t *testing.T) {
+	testCases := []struct {
+		name               string
+		objs               []client.Object
+		clusterMaxReplicas int32
+		expectedReplicas   int32
+		// expectedQuota is the quota named by the QuotaLimited condition, empty if the scale up is not clamped.
+		expectedQuota string
+	}{
+		{
+			name:             "no quota",
+			expectedReplicas: 5,
+		},
+		{
+			name: "namespace quota",
+			objs: []client.Object{newTestReplicaQuota(testNamespace, "6"),
+				newTestQuotaPodAutoscaler(testNamespace, "other-pa", 2, 1)},
+			expectedReplicas: 4,
+			expectedQuota:    "ConfigMap default/aibrix-replica-quota",
+		},
+		{
+			name: "quota of another namespace",
+			objs: []client.Object{newTestReplicaQuota("team-b", "6"),
+				newTestQuotaPodAutoscaler("team-b", "other-pa", 6, 6)},
+			expectedReplicas: 5,
+		},
+		{
+			// the PodAutoscalers of the other namespaces use 4 of the 6 replicas, the current 3 are kept.
+			name:               "exhausted cluster quota",
+			objs:               []client.Object{newTestQuotaPodAutoscaler("team-b", "other-pa", 1, 4)},
+			clusterMaxReplicas: 6,
+			expectedReplicas:   3,
+			expectedQuota:      "cluster",
+		},
+		{
+			name:             "quota with headroom",
+			objs:             []client.Object{newTestReplicaQuota(testNamespace, "64")},
+			expectedReplicas: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := newTestAPAObjects(3, "8000", nil)
+			objs[1].(*autoscalingv1alpha1.PodAutoscaler).Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+			r, recorder := newTestReconciler(t, append(objs, tc.objs...)...)
+			r.RuntimeConfig.PodAutoscalerClusterMaxReplicas = tc.clusterMaxReplicas
+			fetcher := metrics.NewFakeMetricFetcher()
+			// the 20 requests of the 3 pods need 5 pods at the target of 4.
+			for i, metric := range []float64{8, 8, 4} {
+				fetcher.SetPodMetric(fmt.Sprintf("test-pod-%d", i), metric)
+			}
+			r.metricFetcher = fetcher
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.QuotaLimited)
+			if tc.expectedQuota == "" {
+				if cond != nil {
+					t.Errorf("expected no QuotaLimited condition, got %+v", cond)
+				}
+				if count := countEvents(recorder, "QuotaLimited"); count != 0 {
+					t.Errorf("expected no QuotaLimited event, got %d", count)
+				}
+				return
+			}
+			if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, "replica quota "+tc.expectedQuota+" ") {
+				t.Errorf("expected QuotaLimited=True naming the quota %s, got %+v", tc.expectedQuota, cond)
+			}
+			if count := countEvents(recorder, "QuotaLimited"); count != 1 {
+				t.Errorf("expected 1 QuotaLimited event, got %d", count)
+			}
+		})
+	}
+}
+
+// TestConstrainScaleUpByQuotaContention checks the PodAutoscalers sharing a quota split its headroom in the order
+// they scale up, each one seeing the replicas the others reported.
+func TestConstrainScaleUpByQuotaContention(t *testing.T) {
+	paA := newTestQuotaPodAutoscaler(testNamespace, "pa-a", 2, 2)
+	paB := newTestQuotaPodAutoscaler(testNamespace, "pa-b", 2, 2)
+	paC := newTestQuotaPodAutoscaler(testNamespace, "pa-c", 1, 1)
+	r, _ := newTestReconciler(t, newTestReplicaQuota(testNamespace, "10"), paA, paB, paC)
+	ctx := context.Background()
+
+	// decide constrains the decision of the PodAutoscaler and reports it, as the reconcile does once the target is
+	// scaled.
+	decide := func(pa *autoscalingv1alpha1.PodAutoscaler, desiredReplicas int32) int32 {
+		t.Helper()
+		current := &autoscalingv1alpha1.PodAutoscaler{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(pa), current); err != nil {
+			t.Fatalf("failed to get PodAutoscaler: %v", err)
+		}
+		replicas := r.constrainScaleUpByQuota(ctx, current, current.Status.DesiredScale, desiredReplicas)
+		r.setStatus(current, current.Status.DesiredScale, replicas, nil, true)
+		if err := r.Status().Update(ctx, current); err != nil {
+			t.Fatalf("failed to update the status: %v", err)
+		}
+		return replicas
+	}
+
+	// pa-a takes the 5 free replicas it asks for, out of the 10 - 2 - 1.
+	if replicas := decide(paA, 7); replicas != 7 {
+		t.Errorf("expected pa-a to scale up to 7 replicas, got %d", replicas)
+	}
+	// pa-b only gets the remaining 10 - 7 - 1.
+	if replicas := decide(paB, 6); replicas != 2 {
+		t.Errorf("expected pa-b to be held at 2 replicas, got %d", replicas)
+	}
+	if replicas := decide(paC, 3); replicas != 1 {
+		t.Errorf("expected pa-c to be held at 1 replica, got %d", replicas)
+	}
+
+	// the replicas freed by the scale down of pa-a are taken by the next scale up, once pa-a reports them released.
+	if replicas := decide(paA, 4); replicas != 4 {
+		t.Errorf("expected pa-a to scale down to 4 replicas, got %d", replicas)
+	}
+	if replicas := decide(paB, 6); replicas != 2 {
+		t.Errorf("expected pa-b to be held at 2 replicas while pa-a scales down, got %d", replicas)
+	}
+	decide(paA, 4)
+	if replicas := decide(paB, 6); replicas != 5 {
+		t.Errorf("expected pa-b to scale up to 5 replicas, got %d", replicas)
+	}
+	pa := &autoscalingv1alpha1.PodAutoscaler{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(paB), pa); err != nil {
+		t.Fatalf("failed to get PodAutoscaler: %v", err)
+	}
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.QuotaLimited)
+	if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, "clamped to 5 replicas") {
+		t.Errorf("expected QuotaLimited=True clamping pa-b to 5 replicas, got %+v", cond)
+	}
+
+	// a quota which can not be read leaves the scale up as is.
+	r, recorder := newTestReconciler(t, newTestReplicaQuota(testNamespace, "ten"), newTestQuotaPodAutoscaler(testNamespace, "pa-a", 2, 2))
+	if replicas := r.constrainScaleUpByQuota(ctx, newTestQuotaPodAutoscaler(testNamespace, "pa-a", 2, 2), 2, 6); replicas != 6 {
+		t.Errorf("expected the scale up to 6 replicas with an invalid quota, got %d", replicas)
+	}
+	if count := countEvents(recorder, "FailedGetReplicaQuota"); count != 1 {
+		t.Errorf("expected 1 FailedGetReplicaQuota event, got %d", count)
+	}
+}
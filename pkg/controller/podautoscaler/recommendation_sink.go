@@ -0,0 +1,311 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+const (
+	// recommendationSignatureHeader carries the hex encoded HMAC-SHA256 of the body posted by the Webhook sink,
+	// prefixed with "sha256=".
+	recommendationSignatureHeader = "X-Aibrix-Signature"
+	// recommendationWebhookSecretKey is the key of the HMAC key in the Secret of the Webhook sink.
+	recommendationWebhookSecretKey = "hmac-key"
+	// defaultRecommendationRedisStream is the Redis stream of the RedisStream sink if the PodAutoscaler does not
+	// name one.
+	defaultRecommendationRedisStream = "aibrix:podautoscaler-recommendations"
+	// recommendationWebhookTimeout bounds a single post of the Webhook sink.
+	recommendationWebhookTimeout = 10 * time.Second
+)
+
+// recommendationPublishBackoff paces the attempts to publish a recommendation to a sink which failed transiently.
+var recommendationPublishBackoff = wait.Backoff{
+	Steps:    3,
+	Duration: 200 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+}
+
+// objectRef identifies the PodAutoscaler and the scale target of a recommendation.
+type objectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+// recommendation is a scaling decision of a PodAutoscaler in the External actuation mode.
+type recommendation struct {
+	PodAutoscaler objectRef `json:"podAutoscaler"`
+	Target        objectRef `json:"target"`
+	From          int32     `json:"from"`
+	To            int32     `json:"to"`
+	Reason        string    `json:"reason"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func newRecommendation(pa *autoscalingv1alpha1.PodAutoscaler, from, to int32, reason string, now time.Time) recommendation {
+	ref := pa.Spec.ScaleTargetRef
+	return recommendation{
+		PodAutoscaler: objectRef{
+			APIVersion: autoscalingv1alpha1.GroupVersion.String(),
+			Kind:       "PodAutoscaler",
+			Namespace:  pa.Namespace,
+			Name:       pa.Name,
+		},
+		Target:    objectRef{APIVersion: ref.APIVersion, Kind: ref.Kind, Namespace: pa.Namespace, Name: ref.Name},
+		From:      from,
+		To:        to,
+		Reason:    reason,
+		Timestamp: now.UTC(),
+	}
+}
+
+// recommendationSink publishes the recommendations of a PodAutoscaler.
+type recommendationSink interface {
+	publish(ctx context.Context, rec recommendation) error
+}
+
+// permanentPublishError is a failure retrying the publish can not resolve, e.g. a request the webhook rejects.
+type permanentPublishError struct {
+	err error
+}
+
+func (e *permanentPublishError) Error() string {
+	return e.err.Error()
+}
+
+func isRetryablePublishError(err error) bool {
+	var permanent *permanentPublishError
+	return !errors.As(err, &permanent)
+}
+
+// getRecommendationSink returns the sink selected by the PodAutoscaler, defaulting to Annotation.
+func getRecommendationSink(pa *autoscalingv1alpha1.PodAutoscaler) autoscalingv1alpha1.RecommendationSink {
+	value, ok := pa.Annotations[scalingcontext.RecommendationSinkLabel]
+	if !ok {
+		return autoscalingv1alpha1.RecommendationSinkAnnotation
+	}
+	return autoscalingv1alpha1.RecommendationSink(value)
+}
+
+// newRecommendationSink builds the sink of the PodAutoscaler from its annotations.
+func (r *PodAutoscalerReconciler) newRecommendationSink(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) (recommendationSink, error) {
+	switch sink := getRecommendationSink(pa); sink {
+	case autoscalingv1alpha1.RecommendationSinkAnnotation:
+		return &annotationSink{client: r.Client, pa: pa}, nil
+	case autoscalingv1alpha1.RecommendationSinkWebhook:
+		url := pa.Annotations[scalingcontext.RecommendationWebhookURLLabel]
+		if url == "" {
+			return nil, fmt.Errorf("the %s sink requires the %s annotation", sink, scalingcontext.RecommendationWebhookURLLabel)
+		}
+		key, err := r.getRecommendationWebhookKey(ctx, pa)
+		if err != nil {
+			return nil, err
+		}
+		httpClient := r.httpClient
+		if httpClient == nil {
+			httpClient = &http.Client{Timeout: recommendationWebhookTimeout}
+		}
+		return &webhookSink{client: httpClient, url: url, key: key}, nil
+	case autoscalingv1alpha1.RecommendationSinkRedisStream:
+		if r.redisClient == nil {
+			return nil, fmt.Errorf("the %s sink requires the controller to be started with --recommendation-redis-addr", sink)
+		}
+		stream := pa.Annotations[scalingcontext.RecommendationRedisStreamLabel]
+		if stream == "" {
+			stream = defaultRecommendationRedisStream
+		}
+		return &redisStreamSink{client: r.redisClient, stream: stream}, nil
+	default:
+		return nil, fmt.Errorf("unsupported recommendation sink %q, supported: %s, %s, %s", sink,
+			autoscalingv1alpha1.RecommendationSinkAnnotation, autoscalingv1alpha1.RecommendationSinkWebhook,
+			autoscalingv1alpha1.RecommendationSinkRedisStream)
+	}
+}
+
+// getRecommendationWebhookKey reads the HMAC key of the Webhook sink, the recommendations are never posted
+// unsigned.
+func (r *PodAutoscalerReconciler) getRecommendationWebhookKey(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) ([]byte, error) {
+	name := pa.Annotations[scalingcontext.RecommendationWebhookSecretLabel]
+	if name == "" {
+		return nil, fmt.Errorf("the %s sink requires the %s annotation", autoscalingv1alpha1.RecommendationSinkWebhook,
+			scalingcontext.RecommendationWebhookSecretLabel)
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: pa.Namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get the secret %s of the recommendation webhook: %w", name, err)
+	}
+	key := secret.Data[recommendationWebhookSecretKey]
+	if len(key) == 0 {
+		return nil, fmt.Errorf("the secret %s of the recommendation webhook has no %s", name, recommendationWebhookSecretKey)
+	}
+	return key, nil
+}
+
+// publishRecommendation publishes the recommendation to the sink of the PodAutoscaler instead of scaling the target,
+// and records the outcome in the RecommendationPublished condition. Transient failures are retried with backoff,
+// a recommendation which could not be published is published again on the next sync.
+func (r *PodAutoscalerReconciler) publishRecommendation(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, from, to int32, reason string) {
+	sink := getRecommendationSink(pa)
+	publisher, err := r.newRecommendationSink(ctx, pa)
+	if err == nil {
+		rec := newRecommendation(pa, from, to, reason, r.now())
+		err = retry.OnError(recommendationPublishBackoff, isRetryablePublishError, func() error {
+			return publisher.publish(ctx, rec)
+		})
+	}
+	if err != nil {
+		klog.ErrorS(err, "Failed to publish the recommendation", "PodAutoscaler", klog.KObj(pa), "sink", sink, "from", from, "to", to)
+		r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, "FailedPublishRecommendation", "New size: %d; sink: %s; error: %v", to, sink, err)
+		setCondition(pa, autoscalingv1alpha1.RecommendationPublished, metav1.ConditionFalse, "FailedPublish",
+			"the recommendation of %d replicas could not be published to the %s sink: %v", to, sink, err)
+		return
+	}
+
+	if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.RecommendationPublished) {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeNormal, "RecommendationPublished", "New size: %d; sink: %s; reason: %s", to, sink, reason)
+	}
+	setCondition(pa, autoscalingv1alpha1.RecommendationPublished, metav1.ConditionTrue, "Published",
+		"the recommendation of %d replicas was published to the %s sink", to, sink)
+	klog.InfoS("Published the recommendation", "PodAutoscaler", klog.KObj(pa), "sink", sink, "from", from, "to", to, "reason", reason)
+}
+
+// annotationSink writes the last recommendation to the RecommendationLabel annotation of the PodAutoscaler.
+type annotationSink struct {
+	client client.Client
+	// pa is the PodAutoscaler being reconciled, its annotations and resource version follow the patch so that its
+	// status can still be updated.
+	pa *autoscalingv1alpha1.PodAutoscaler
+}
+
+func (s *annotationSink) publish(ctx context.Context, rec recommendation) error {
+	pa := &autoscalingv1alpha1.PodAutoscaler{}
+	if err := s.client.Get(ctx, client.ObjectKeyFromObject(s.pa), pa); err != nil {
+		return err
+	}
+	// the annotation changes trigger a reconcile, an unchanged recommendation is not written again.
+	var last recommendation
+	if err := json.Unmarshal([]byte(pa.Annotations[scalingcontext.RecommendationLabel]), &last); err == nil &&
+		last.From == rec.From && last.To == rec.To {
+		return nil
+	}
+
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return &permanentPublishError{err: err}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{scalingcontext.RecommendationLabel: string(value)},
+		},
+	})
+	if err != nil {
+		return &permanentPublishError{err: err}
+	}
+	if err := s.client.Patch(ctx, pa, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return err
+	}
+	s.pa.Annotations = pa.Annotations
+	s.pa.ResourceVersion = pa.ResourceVersion
+	return nil
+}
+
+// webhookSink posts the recommendations as JSON, signed with the HMAC-SHA256 of the body in the
+// recommendationSignatureHeader so that the receiver can verify they come from the controller.
+type webhookSink struct {
+	client *http.Client
+	url    string
+	key    []byte
+}
+
+// signRecommendation returns the signature of the body the Webhook sink sends in the recommendationSignatureHeader.
+func signRecommendation(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookSink) publish(ctx context.Context, rec recommendation) error {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return &permanentPublishError{err: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentPublishError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(recommendationSignatureHeader, signRecommendation(s.key, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		return fmt.Errorf("recommendation webhook %s returned %s", s.url, resp.Status)
+	default:
+		return &permanentPublishError{err: fmt.Errorf("recommendation webhook %s rejected the recommendation: %s", s.url, resp.Status)}
+	}
+}
+
+// redisStreamSink appends the recommendations to a Redis stream, under the "recommendation" field.
+type redisStreamSink struct {
+	client redis.UniversalClient
+	stream string
+}
+
+func (s *redisStreamSink) publish(ctx context.Context, rec recommendation) error {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return &permanentPublishError{err: err}
+	}
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		Values: map[string]interface{}{"recommendation": string(value)},
+	}).Err()
+}
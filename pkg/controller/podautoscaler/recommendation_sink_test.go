@@ -0,0 +1,314 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func TestNewRedisClient(t *testing.T) {
+	testCases := []struct {
+		name      string
+		env       map[string]string
+		addrs     string
+		expectErr bool
+		check     func(t *testing.T, client redis.UniversalClient)
+	}{
+		{
+			name:  "standalone with the credentials of the environment",
+			env:   map[string]string{"REDIS_USERNAME": "aibrix", "REDIS_PASSWORD": "secret", "REDIS_DB": "2"},
+			addrs: "redis:6379",
+			check: func(t *testing.T, client redis.UniversalClient) {
+				standalone, ok := client.(*redis.Client)
+				if !ok {
+					t.Fatalf("expected a standalone client, got %T", client)
+				}
+				options := standalone.Options()
+				if options.Addr != "redis:6379" || options.Username != "aibrix" || options.Password != "secret" || options.DB != 2 {
+					t.Errorf("unexpected options: addr %s, username %s, password %s, db %d", options.Addr, options.Username, options.Password, options.DB)
+				}
+			},
+		},
+		{
+			name:  "cluster of the comma-separated addresses",
+			env:   map[string]string{"REDIS_MODE": "cluster", "REDIS_PASSWORD": "secret"},
+			addrs: "redis-0:6379, redis-1:6379",
+			check: func(t *testing.T, client redis.UniversalClient) {
+				cluster, ok := client.(*redis.ClusterClient)
+				if !ok {
+					t.Fatalf("expected a cluster client, got %T", client)
+				}
+				options := cluster.Options()
+				if len(options.Addrs) != 2 || options.Addrs[0] != "redis-0:6379" || options.Addrs[1] != "redis-1:6379" || options.Password != "secret" {
+					t.Errorf("unexpected options: addrs %v, password %s", options.Addrs, options.Password)
+				}
+			},
+		},
+		{
+			name:  "the address overrides the one of the environment",
+			env:   map[string]string{"REDIS_HOST": "gateway-redis", "REDIS_PORT": "6380"},
+			addrs: "redis:6379",
+			check: func(t *testing.T, client redis.UniversalClient) {
+				if addr := client.(*redis.Client).Options().Addr; addr != "redis:6379" {
+					t.Errorf("expected the address redis:6379, got %s", addr)
+				}
+			},
+		},
+		{
+			name:      "sentinel without a master name",
+			env:       map[string]string{"REDIS_MODE": "sentinel"},
+			addrs:     "sentinel:26379",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			client, err := newRedisClient(tc.addrs)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got a %T", client)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer client.Close()
+			tc.check(t, client)
+		})
+	}
+}
+
+// newTestRecommendationWebhook starts a receiver which rejects the recommendations not signed with the key, and
+// answers the others with the statuses in order, then with 200.
+func newTestRecommendationWebhook(t *testing.T, key string, statuses ...int) (*httptest.Server, *[]recommendation) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []recommendation
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("failed to read the recommendation: %v", err)
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(body)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if signature := req.Header.Get(recommendationSignatureHeader); !hmac.Equal([]byte(signature), []byte(expected)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var rec recommendation
+		if err := json.Unmarshal(body, &rec); err != nil {
+			t.Errorf("failed to decode the recommendation: %v", err)
+		}
+		received = append(received, rec)
+		if len(received) <= len(statuses) {
+			w.WriteHeader(statuses[len(received)-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func setTestRecommendationPublishBackoff(t *testing.T) {
+	t.Helper()
+	backoff := recommendationPublishBackoff
+	recommendationPublishBackoff.Duration = time.Millisecond
+	t.Cleanup(func() { recommendationPublishBackoff = backoff })
+}
+
+func TestWebhookSinkPublish(t *testing.T) {
+	setTestRecommendationPublishBackoff(t)
+	pa := newTestPodAutoscaler(nil, 10, nil)
+	rec := newRecommendation(pa, 3, 5, "qps above target", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	testCases := []struct {
+		name             string
+		receiverKey      string
+		statuses         []int
+		expectedAttempts int
+		expectErr        bool
+		expectPermanent  bool
+	}{
+		{name: "published", expectedAttempts: 1},
+		{name: "transient failures are retried", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, expectedAttempts: 3},
+		{name: "retries exhausted", statuses: []int{500, 502, 503}, expectedAttempts: 3, expectErr: true},
+		{name: "rejected recommendations are not retried", statuses: []int{http.StatusBadRequest}, expectedAttempts: 1, expectErr: true, expectPermanent: true},
+		// a receiver with another key rejects the signature.
+		{name: "signature rejected", receiverKey: "other-key", expectErr: true, expectPermanent: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			receiverKey := tc.receiverKey
+			if receiverKey == "" {
+				receiverKey = "test-key"
+			}
+			server, received := newTestRecommendationWebhook(t, receiverKey, tc.statuses...)
+			sink := &webhookSink{client: server.Client(), url: server.URL, key: []byte("test-key")}
+			err := retry.OnError(recommendationPublishBackoff, isRetryablePublishError, func() error {
+				return sink.publish(context.Background(), rec)
+			})
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if tc.expectPermanent && isRetryablePublishError(err) {
+				t.Errorf("expected a permanent error, got %v", err)
+			}
+			if len(*received) != tc.expectedAttempts {
+				t.Fatalf("expected %d attempts, got %d", tc.expectedAttempts, len(*received))
+			}
+			if len(*received) > 0 && !reflect.DeepEqual((*received)[0], rec) {
+				t.Errorf("expected the recommendation %+v, got %+v", rec, (*received)[0])
+			}
+		})
+	}
+}
+
+func TestReconcileExternalActuation(t *testing.T) {
+	setTestRecommendationPublishBackoff(t)
+	server, received := newTestRecommendationWebhook(t, "test-key")
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "recommendation-webhook"},
+		Data:       map[string][]byte{recommendationWebhookSecretKey: []byte("test-key")},
+	}
+	testCases := []struct {
+		name           string
+		annotations    map[string]string
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "annotation sink",
+			annotations:    map[string]string{},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "Published",
+		},
+		{
+			name: "webhook sink",
+			annotations: map[string]string{
+				scalingcontext.RecommendationSinkLabel:          string(autoscalingv1alpha1.RecommendationSinkWebhook),
+				scalingcontext.RecommendationWebhookURLLabel:    server.URL,
+				scalingcontext.RecommendationWebhookSecretLabel: secret.Name,
+			},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "Published",
+		},
+		{
+			name: "webhook sink without secret",
+			annotations: map[string]string{
+				scalingcontext.RecommendationSinkLabel:          string(autoscalingv1alpha1.RecommendationSinkWebhook),
+				scalingcontext.RecommendationWebhookURLLabel:    server.URL,
+				scalingcontext.RecommendationWebhookSecretLabel: "missing",
+			},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "FailedPublish",
+		},
+		{
+			name:           "redis stream sink without redis",
+			annotations:    map[string]string{scalingcontext.RecommendationSinkLabel: string(autoscalingv1alpha1.RecommendationSinkRedisStream)},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "FailedPublish",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			*received = nil
+			tc.annotations[scalingcontext.ActuationModeLabel] = string(autoscalingv1alpha1.ActuationModeExternal)
+			objs := append(newTestAPAObjects(3, "8000", tc.annotations), secret)
+			r, recorder := newTestReconciler(t, objs...)
+			r.httpClient = server.Client()
+			fetcher := metrics.NewFakeMetricFetcher()
+			// the 20 requests of the 3 pods need 5 pods at the target of 4.
+			for i, metric := range []float64{8, 8, 4} {
+				fetcher.SetPodMetric(fmt.Sprintf("test-pod-%d", i), metric)
+			}
+			r.metricFetcher = fetcher
+			// the recommendation is published on every sync, the event only when the condition turns true.
+			for i := 0; i < 2; i++ {
+				if err := reconcileTestPodAutoscaler(t, r); err != nil {
+					t.Fatalf("reconcile failed: %v", err)
+				}
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != 3 {
+				t.Errorf("expected the target to keep 3 replicas, got %d", replicas)
+			}
+			pa := getTestPodAutoscaler(t, r)
+			if pa.Status.DesiredScale != 5 || pa.Status.LastScaleTime != nil {
+				t.Errorf("expected the desired scale 5 without a scale time, got %d at %v", pa.Status.DesiredScale, pa.Status.LastScaleTime)
+			}
+			cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.RecommendationPublished)
+			if cond == nil || cond.Status != tc.expectedStatus || cond.Reason != tc.expectedReason {
+				t.Fatalf("expected RecommendationPublished to be %s with reason %s, got %+v", tc.expectedStatus, tc.expectedReason, cond)
+			}
+			if tc.expectedStatus == metav1.ConditionFalse {
+				if count := countEvents(recorder, "FailedPublishRecommendation"); count != 2 {
+					t.Errorf("expected two FailedPublishRecommendation events, got %d", count)
+				}
+				return
+			}
+			if count := countEvents(recorder, "RecommendationPublished"); count != 1 {
+				t.Errorf("expected one RecommendationPublished event, got %d", count)
+			}
+
+			var rec recommendation
+			switch getRecommendationSink(pa) {
+			case autoscalingv1alpha1.RecommendationSinkAnnotation:
+				if err := json.Unmarshal([]byte(pa.Annotations[scalingcontext.RecommendationLabel]), &rec); err != nil {
+					t.Fatalf("failed to decode the recommendation annotation: %v", err)
+				}
+			case autoscalingv1alpha1.RecommendationSinkWebhook:
+				if len(*received) != 2 {
+					t.Fatalf("expected two recommendations, got %d", len(*received))
+				}
+				rec = (*received)[0]
+			}
+			if rec.From != 3 || rec.To != 5 || rec.Target.Name != testDeployName || rec.PodAutoscaler.Name != testPaName {
+				t.Errorf("unexpected recommendation %+v", rec)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+// Phases of the reconcile of a KPA or APA PodAutoscaler, the deadline of the reconcile is attributed to the phase
+// in progress when it expires.
+const (
+	phaseScaleLookup = "scale_lookup"
+	phaseMetricFetch = "metric_fetch"
+	phaseActuation   = "actuation"
+)
+
+var (
+	// DefaultReconcileTimeout is the deadline of the calls of a single reconcile to the API server and to the pods,
+	// the status update is not bounded by it. It is overridden by --podautoscaler-reconcile-timeout.
+	DefaultReconcileTimeout = 10 * time.Second
+	// ReconcileTimeoutRequeueDuration is how soon a PodAutoscaler whose reconcile timed out is reconciled again.
+	ReconcileTimeoutRequeueDuration = 2 * time.Second
+	// timeoutStatusUpdateDuration bounds the status update reporting the timeout, the reconcile deadline has expired.
+	timeoutStatusUpdateDuration = 2 * time.Second
+
+	reconcileDeadlineExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aibrix_podautoscaler_reconcile_deadline_exceeded_total",
+		Help: "Number of PodAutoscaler reconciles that exceeded their deadline, by the phase in progress.",
+	}, []string{"phase"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(reconcileDeadlineExceeded)
+}
+
+func (r *PodAutoscalerReconciler) getReconcileTimeout() time.Duration {
+	if r.reconcileTimeout > 0 {
+		return r.reconcileTimeout
+	}
+	return DefaultReconcileTimeout
+}
+
+// deadlineExceeded reports whether the reconcile deadline has expired. It is checked at the phase boundaries and
+// when a phase fails, so the timeout is attributed to the phase that used up the time.
+func deadlineExceeded(ctx context.Context) bool {
+	return ctx.Err() == context.DeadlineExceeded
+}
+
+// handleReconcileTimeout records the phase the reconcile deadline expired in and requeues the PodAutoscaler sooner
+// than the periodical sync. The warning event is only emitted when the condition becomes true.
+func (r *PodAutoscalerReconciler) handleReconcileTimeout(ctx context.Context, paStatusOriginal *autoscalingv1alpha1.PodAutoscalerStatus, pa *autoscalingv1alpha1.PodAutoscaler, phase string) (ctrl.Result, error) {
+	reconcileDeadlineExceeded.WithLabelValues(phase).Inc()
+	klog.InfoS("PodAutoscaler reconcile deadline exceeded", "PodAutoscaler", klog.KObj(pa), "phase", phase, "timeout", r.getReconcileTimeout())
+
+	if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ReconcileTimeout) {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, "ReconcileTimeout",
+			"the reconcile deadline of %v was exceeded during the %s phase", r.getReconcileTimeout(), phase)
+	}
+	setCondition(pa, autoscalingv1alpha1.ReconcileTimeout, metav1.ConditionTrue, "DeadlineExceeded",
+		"the reconcile deadline of %v was exceeded during the %s phase", r.getReconcileTimeout(), phase)
+
+	// the deadline of the reconcile has expired, the status is written with a deadline of its own.
+	statusCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeoutStatusUpdateDuration)
+	defer cancel()
+	if err := r.updateStatusIfNeeded(statusCtx, paStatusOriginal, pa); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: ReconcileTimeoutRequeueDuration}, nil
+}
+
+// clearReconcileTimeout sets the ReconcileTimeout condition to false once a reconcile completes in time.
+func clearReconcileTimeout(pa *autoscalingv1alpha1.PodAutoscaler) {
+	if apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ReconcileTimeout) {
+		setCondition(pa, autoscalingv1alpha1.ReconcileTimeout, metav1.ConditionFalse, "ReconcileCompleted",
+			"the reconcile completed within its deadline")
+	}
+}
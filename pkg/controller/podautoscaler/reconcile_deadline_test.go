@@ -0,0 +1,187 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+func TestReconcileWritesStatusAfterScalingPastDeadline(t *testing.T) {
+	minReplicas := int32(3)
+	funcs := interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			if err := updateTestScaleSubresource(ctx, c, subResourceName, obj, opts...); err != nil {
+				return err
+			}
+			if subResourceName == "scale" {
+				// the target is scaled, but the answer arrives after the deadline.
+				<-ctx.Done()
+			}
+			return nil
+		},
+	}
+	r, _ := newTestReconcilerWithInterceptor(t, funcs, newTestDeployment(1), newTestPodAutoscaler(&minReplicas, 10, nil))
+	r.reconcileTimeout = 50 * time.Millisecond
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 3 {
+		t.Errorf("expected 3 replicas, got %d", replicas)
+	}
+	pa := getTestPodAutoscaler(t, r)
+	if pa.Status.DesiredScale != 3 {
+		t.Errorf("expected the status to record the scale to 3 replicas, got %d", pa.Status.DesiredScale)
+	}
+	if apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ReconcileTimeout) {
+		t.Errorf("expected no ReconcileTimeout once the target is scaled")
+	}
+}
+
+// blockUntilDeadline stands in for a slow API server call that does not answer before the reconcile deadline.
+func blockUntilDeadline(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// expectReconcileTimeout checks that the reconcile timed out in the given phase and was requeued early.
+func expectReconcileTimeout(t *testing.T, r *PodAutoscalerReconciler, recorder *record.FakeRecorder, phase string) {
+	t.Helper()
+	before := testutil.ToFloat64(reconcileDeadlineExceeded.WithLabelValues(phase))
+	result, err := r.Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: testPaName},
+	})
+	if err != nil {
+		t.Fatalf("expected the timeout not to fail the reconcile, got %v", err)
+	}
+	if result.RequeueAfter != ReconcileTimeoutRequeueDuration {
+		t.Errorf("expected a requeue after %v, got %v", ReconcileTimeoutRequeueDuration, result.RequeueAfter)
+	}
+	if delta := testutil.ToFloat64(reconcileDeadlineExceeded.WithLabelValues(phase)) - before; delta != 1 {
+		t.Errorf("expected the %s deadline counter to increase by 1, got %v", phase, delta)
+	}
+	cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.ReconcileTimeout)
+	if cond == nil || cond.Status != metav1.ConditionTrue || !strings.Contains(cond.Message, phase) {
+		t.Fatalf("expected ReconcileTimeout=True reporting the %s phase, got %+v", phase, cond)
+	}
+	if count := countEvents(recorder, "ReconcileTimeout"); count != 1 {
+		t.Errorf("expected one ReconcileTimeout event, got %d", count)
+	}
+}
+
+func TestReconcileDeadlineExceeded(t *testing.T) {
+	minReplicas := int32(3)
+	testCases := []struct {
+		name  string
+		phase string
+		funcs interceptor.Funcs
+	}{
+		{
+			name:  "slow scale lookup",
+			phase: phaseScaleLookup,
+			funcs: interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					if _, ok := obj.(*unstructured.Unstructured); ok {
+						return blockUntilDeadline(ctx)
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			},
+		},
+		{
+			name:  "slow actuation",
+			phase: phaseActuation,
+			funcs: interceptor.Funcs{
+				SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+					if subResourceName == "scale" {
+						return blockUntilDeadline(ctx)
+					}
+					return updateTestScaleSubresource(ctx, c, subResourceName, obj, opts...)
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, recorder := newTestReconcilerWithInterceptor(t, tc.funcs, newTestDeployment(1), newTestPodAutoscaler(&minReplicas, 10, nil))
+			r.reconcileTimeout = 50 * time.Millisecond
+			expectReconcileTimeout(t, r, recorder, tc.phase)
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != 1 {
+				t.Errorf("expected the deployment to keep 1 replica, got %d", replicas)
+			}
+		})
+	}
+}
+
+func TestReconcileDeadlineExceededDuringMetricFetch(t *testing.T) {
+	var delay atomic.Int64
+	delay.Store(int64(time.Second))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-time.After(time.Duration(delay.Load())):
+			fmt.Fprintf(w, "test_metric 16\n")
+		case <-req.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server url: %v", err)
+	}
+
+	r, recorder := newTestReconciler(t, newTestAPAObjects(2, u.Port(), nil)...)
+	r.reconcileTimeout = 100 * time.Millisecond
+	expectReconcileTimeout(t, r, recorder, phaseMetricFetch)
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 2 {
+		t.Errorf("expected the replicas to be held at 2, got %d", replicas)
+	}
+
+	// the metrics answer in time again.
+	delay.Store(0)
+	r.reconcileTimeout = 0
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 4 {
+		t.Errorf("expected 4 replicas, got %d", replicas)
+	}
+	if !apimeta.IsStatusConditionFalse(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.ReconcileTimeout) {
+		t.Errorf("expected ReconcileTimeout to be false once the reconcile completes in time")
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+// modelIdentifier is the label naming the model served by the scale target.
+const modelIdentifier = "model.aibrix.ai/name"
+
+// redisMetricReader reads the metrics of the redis sources.
+type redisMetricReader interface {
+	GetMetricValue(ctx context.Context, key string) (float64, error)
+}
+
+// scaleTargetModel returns the model of the scale target, named by the model label of the PodAutoscaler or else of
+// the target, empty if neither names one.
+func scaleTargetModel(pa autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured) string {
+	if model := pa.Labels[modelIdentifier]; model != "" {
+		return model
+	}
+	return scale.GetLabels()[modelIdentifier]
+}
+
+// getMetricFromRedis returns the metric of the redis source, read from the key of its pattern for the model of the
+// scale target, e.g. the requests of the model pending at the gateway.
+func (r *PodAutoscalerReconciler) getMetricFromRedis(ctx context.Context, pa autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured, source autoscalingv1alpha1.MetricSource) (float64, error) {
+	if r.redisMetrics == nil {
+		return 0, fmt.Errorf("the %s metric sources require the controller to be started with --metrics-redis-addr", source.MetricSourceType)
+	}
+	key, err := metrics.RedisMetricKey(source.KeyPattern, scaleTargetModel(pa, scale))
+	if err != nil {
+		return 0, fmt.Errorf("%w, set the %s label of the PodAutoscaler", err, modelIdentifier)
+	}
+	return r.redisMetrics.GetMetricValue(ctx, key)
+}
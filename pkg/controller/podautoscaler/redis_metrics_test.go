@@ -0,0 +1,196 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+// fakeRedisMetrics serves the metrics of the redis sources, or fails every read with err.
+type fakeRedisMetrics struct {
+	values map[string]float64
+	err    error
+}
+
+func (f *fakeRedisMetrics) GetMetricValue(ctx context.Context, key string) (float64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	return f.values[key], nil
+}
+
+// newTestRedisMetricAPAObjects creates the APA test objects of the llama model with a second, redis metric source
+// targeting 2 pending requests per pod.
+func newTestRedisMetricAPAObjects() []client.Object {
+	objs := newTestAPAObjects(2, "8000", nil)
+	pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+	pa.Labels = map[string]string{modelIdentifier: "llama"}
+	pa.Spec.MetricsSources = append(pa.Spec.MetricsSources, autoscalingv1alpha1.MetricSource{
+		MetricSourceType: autoscalingv1alpha1.REDIS,
+		TargetMetric:     "pending_requests",
+		TargetValue:      "2",
+	})
+	return objs
+}
+func TestReconcileRedisMetric(t *testing.T) {
+	tests := []struct {
+		name                 string
+		podMetric            float64
+		redisErr             error
+		expectedReplicas     int32
+		expectedFailedEvents int
+	}{
+		{
+			// the pods are at their target, the requests pending at the gateway demand twice the pods.
+			name:             "scales on the pending requests",
+			podMetric:        4,
+			expectedReplicas: 4,
+		},
+		{
+			// the target falls back to the pod metrics while Redis is unavailable.
+			name:                 "falls back to the pod metrics",
+			podMetric:            16,
+			redisErr:             errors.New("connection refused"),
+			expectedReplicas:     4,
+			expectedFailedEvents: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, recorder := newTestReconciler(t, newTestRedisMetricAPAObjects()...)
+			fetcher := metrics.NewFakeMetricFetcher()
+			fetcher.SetPodMetric("test-pod-0", tt.podMetric)
+			fetcher.SetPodMetric("test-pod-1", tt.podMetric)
+			r.metricFetcher = fetcher
+			redisMetrics := &fakeRedisMetrics{values: map[string]float64{"pending:llama": 8}, err: tt.redisErr}
+			r.redisMetrics = redisMetrics
+
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tt.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tt.expectedReplicas, replicas)
+			}
+			if count := countEvents(recorder, "FailedGetRedisMetrics"); count != tt.expectedFailedEvents {
+				t.Errorf("expected %d FailedGetRedisMetrics events, got %d", tt.expectedFailedEvents, count)
+			}
+			pa := getTestPodAutoscaler(t, r)
+			if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ScalingActive) {
+				t.Errorf("expected ScalingActive=True while the pod metrics are available")
+			}
+			cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.MetricsDegraded)
+			if tt.redisErr == nil {
+				if len(pa.Status.CurrentMetrics) != 2 || pa.Status.CurrentMetrics[1].Name != "pending_requests" ||
+					pa.Status.CurrentMetrics[1].DesiredReplicas != tt.expectedReplicas {
+					t.Errorf("expected pending_requests to recommend %d replicas, got %+v", tt.expectedReplicas, pa.Status.CurrentMetrics)
+				}
+				if cond != nil {
+					t.Errorf("expected no MetricsDegraded condition while all the metrics are available, got %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "FailedGetRedisMetrics" ||
+				!strings.Contains(cond.Message, tt.redisErr.Error()) {
+				t.Fatalf("expected MetricsDegraded=True reporting the redis error, got %+v", cond)
+			}
+
+			// the condition clears once Redis is back.
+			redisMetrics.err = nil
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			if apimeta.IsStatusConditionTrue(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.MetricsDegraded) {
+				t.Errorf("expected MetricsDegraded=False once redis is available")
+			}
+		})
+	}
+}
+
+func TestGetMetricFromRedis(t *testing.T) {
+	redisMetrics := &fakeRedisMetrics{values: map[string]float64{"pending:llama": 3, "queue:mistral": 5}}
+	tests := []struct {
+		name          string
+		redisMetrics  redisMetricReader
+		paModel       string
+		targetModel   string
+		keyPattern    string
+		expectedValue float64
+		expectedErr   string
+	}{
+		{
+			name:        "no redis address",
+			targetModel: "llama",
+			expectedErr: "--metrics-redis-addr",
+		},
+		{
+			name:         "target without model",
+			redisMetrics: redisMetrics,
+			expectedErr:  modelIdentifier,
+		},
+		{
+			// the model of the target is used when the PodAutoscaler names none.
+			name:          "model of the target",
+			redisMetrics:  redisMetrics,
+			targetModel:   "llama",
+			expectedValue: 3,
+		},
+		{
+			name:          "model of the PodAutoscaler with a key pattern",
+			redisMetrics:  redisMetrics,
+			paModel:       "mistral",
+			targetModel:   "llama",
+			keyPattern:    "queue:{model}",
+			expectedValue: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &PodAutoscalerReconciler{redisMetrics: tt.redisMetrics}
+			pa := autoscalingv1alpha1.PodAutoscaler{}
+			if tt.paModel != "" {
+				pa.Labels = map[string]string{modelIdentifier: tt.paModel}
+			}
+			scale := &unstructured.Unstructured{}
+			if tt.targetModel != "" {
+				scale.SetLabels(map[string]string{modelIdentifier: tt.targetModel})
+			}
+			source := autoscalingv1alpha1.MetricSource{MetricSourceType: autoscalingv1alpha1.REDIS, KeyPattern: tt.keyPattern}
+
+			value, err := r.getMetricFromRedis(context.Background(), pa, scale, source)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil || value != tt.expectedValue {
+				t.Errorf("expected %v, got %v, %v", tt.expectedValue, value, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// newTestScalableCRD returns a CRD of the ModelServer kind, declaring the scale subresource if scalable.
+func newTestScalableCRD(scalable bool) *apiextensionsv1.CustomResourceDefinition {
+	group, plural := "scalable.example.com", "modelservers"
+	if !scalable {
+		group = "unscalable.example.com"
+	}
+	version := apiextensionsv1.CustomResourceDefinitionVersion{
+		Name:    "v1",
+		Served:  true,
+		Storage: true,
+		Schema: &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"spec": {
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"replicas": {Type: "integer"},
+						"selector": {Type: "object", XPreserveUnknownFields: ptr.To(true)},
+					},
+				},
+				"status": {
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"replicas": {Type: "integer"},
+						"selector": {Type: "string"},
+					},
+				},
+			},
+		}},
+	}
+	if scalable {
+		version.Subresources = &apiextensionsv1.CustomResourceSubresources{
+			Scale: &apiextensionsv1.CustomResourceSubresourceScale{
+				SpecReplicasPath:   ".spec.replicas",
+				StatusReplicasPath: ".status.replicas",
+				LabelSelectorPath:  ptr.To(".status.selector"),
+			},
+		}
+	}
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: plural + "." + group},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:   plural,
+				Singular: "modelserver",
+				Kind:     "ModelServer",
+				ListKind: "ModelServerList",
+			},
+			Scope:    apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{version},
+		},
+	}
+}
+
+func nestedReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(found).To(BeTrue())
+	return replicas
+}
+
+var _ = Describe("Scale subresource of the scale target", func() {
+	const namespace = "default"
+	ctx := context.Background()
+	var r *PodAutoscalerReconciler
+
+	BeforeEach(func() {
+		httpClient, err := rest.HTTPClientFor(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		mapper, err := apiutil.NewDynamicRESTMapper(cfg, httpClient)
+		Expect(err).NotTo(HaveOccurred())
+		r = &PodAutoscalerReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Mapper: mapper}
+	})
+
+	// expectScale reads the scale of the target through its mappings, and then scales it to the replicas.
+	expectScale := func(gk schema.GroupKind, name string, currentReplicas, replicas int64, expectedGR schema.GroupResource) {
+		mappings, err := r.Mapper.RESTMappings(gk)
+		Expect(err).NotTo(HaveOccurred())
+		scale, target, targetGR, err := r.scaleForResourceMappings(ctx, namespace, name, mappings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(targetGR).To(Equal(expectedGR))
+		Expect(scale.GetKind()).To(Equal("Scale"))
+		Expect(nestedReplicas(scale)).To(Equal(currentReplicas))
+		selector, err := getScalePodSelector(target)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selector.String()).To(Equal("app=" + name))
+
+		Expect(r.updateScaleSubresource(ctx, targetGR, target, int32(replicas))).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, target)).To(Succeed())
+		Expect(nestedReplicas(target)).To(Equal(replicas))
+	}
+
+	It("scales a Deployment", func() {
+		labels := map[string]string{"app": "scale-deployment"}
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "scale-deployment"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.To(int32(3)),
+				Selector: &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "engine", Image: "vllm/vllm-openai"}}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, ctx, deployment)
+
+		expectScale(schema.GroupKind{Group: "apps", Kind: "Deployment"}, deployment.Name, 3, 5,
+			schema.GroupResource{Group: "apps", Resource: "deployments"})
+	})
+
+	It("scales a StatefulSet", func() {
+		labels := map[string]string{"app": "scale-statefulset"}
+		statefulSet := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "scale-statefulset"},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:    ptr.To(int32(2)),
+				ServiceName: "scale-statefulset",
+				Selector:    &metav1.LabelSelector{MatchLabels: labels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "engine", Image: "vllm/vllm-openai"}}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, statefulSet)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, ctx, statefulSet)
+
+		expectScale(schema.GroupKind{Group: "apps", Kind: "StatefulSet"}, statefulSet.Name, 2, 6,
+			schema.GroupResource{Group: "apps", Resource: "statefulsets"})
+	})
+
+	It("scales a custom resource declaring the scale subresource", func() {
+		crd := newTestScalableCRD(true)
+		_, err := envtest.InstallCRDs(cfg, envtest.CRDInstallOptions{CRDs: []*apiextensionsv1.CustomResourceDefinition{crd}})
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(envtest.UninstallCRDs, cfg, envtest.CRDInstallOptions{CRDs: []*apiextensionsv1.CustomResourceDefinition{crd}})
+
+		server := newTestScalableResource(2)
+		server.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: "v1", Kind: "ModelServer"})
+		server.SetNamespace(namespace)
+		server.SetName("scale-modelserver")
+		// the pods are selected by the selector of the scale subresource, the resource has no spec.selector.
+		unstructured.RemoveNestedField(server.Object, "spec", "selector")
+		Expect(unstructured.SetNestedField(server.Object, "app=scale-modelserver", "status", "selector")).To(Succeed())
+		Expect(k8sClient.Create(ctx, server)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, ctx, server)
+
+		expectScale(schema.GroupKind{Group: crd.Spec.Group, Kind: "ModelServer"}, server.GetName(), 2, 4,
+			schema.GroupResource{Group: crd.Spec.Group, Resource: "modelservers"})
+	})
+
+	It("fails for a custom resource without the scale subresource", func() {
+		crd := newTestScalableCRD(false)
+		_, err := envtest.InstallCRDs(cfg, envtest.CRDInstallOptions{CRDs: []*apiextensionsv1.CustomResourceDefinition{crd}})
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(envtest.UninstallCRDs, cfg, envtest.CRDInstallOptions{CRDs: []*apiextensionsv1.CustomResourceDefinition{crd}})
+
+		server := newTestScalableResource(2)
+		server.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: "v1", Kind: "ModelServer"})
+		server.SetNamespace(namespace)
+		server.SetName("unscalable-modelserver")
+		Expect(k8sClient.Create(ctx, server)).To(Succeed())
+		DeferCleanup(k8sClient.Delete, ctx, server)
+
+		mappings, err := r.Mapper.RESTMappings(schema.GroupKind{Group: crd.Spec.Group, Kind: "ModelServer"})
+		Expect(err).NotTo(HaveOccurred())
+		_, _, _, err = r.scaleForResourceMappings(ctx, namespace, server.GetName(), mappings)
+		Expect(err).To(MatchError(ContainSubstring("ModelServer.unscalable.example.com unscalable-modelserver does not implement the scale subresource")))
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// a missing target is reported as such.
+		_, _, _, err = r.scaleForResourceMappings(ctx, namespace, "missing-modelserver", mappings)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(err).NotTo(MatchError(ContainSubstring("does not implement the scale subresource")))
+	})
+})
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+// defaultScaleToZeroRetentionPeriod is how long a scale-to-zero KPA target keeps one replica after its traffic stopped.
+const defaultScaleToZeroRetentionPeriod = 5 * time.Minute
+
+// getScaleToZeroRetentionPeriod returns the scale-to-zero retention period of the PodAutoscaler.
+func getScaleToZeroRetentionPeriod(pa *autoscalingv1alpha1.PodAutoscaler) time.Duration {
+	value, ok := pa.Annotations[scalingcontext.ScaleToZeroRetentionPeriodLabel]
+	if !ok {
+		return defaultScaleToZeroRetentionPeriod
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period < 0 {
+		klog.InfoS("Invalid scale-to-zero retention period, falling back to default", "PodAutoscaler", klog.KObj(pa), "period", value)
+		return defaultScaleToZeroRetentionPeriod
+	}
+	return period
+}
+
+// isActivationRequested reports whether the activation of the target was requested after it became inactive.
+// Requests older than the last transition of the Active condition have already been served.
+func isActivationRequested(pa *autoscalingv1alpha1.PodAutoscaler) bool {
+	value, ok := pa.Annotations[scalingcontext.ActivationRequestedAtLabel]
+	if !ok {
+		return false
+	}
+	requestedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		klog.InfoS("Invalid activation request timestamp, ignoring", "PodAutoscaler", klog.KObj(pa), "requestedAt", value)
+		return false
+	}
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.Active)
+	if cond == nil || cond.Status != metav1.ConditionFalse {
+		return true
+	}
+	return requestedAt.After(cond.LastTransitionTime.Time)
+}
+
+// computeReplicasFromZero returns the desired replicas of a scale-to-zero KPA target without replicas, and the reason
+// to rescale. The target has no pods to report its metrics, so it is activated by an activation request, or by a
+// metric above zero from a source that does not depend on its pods, e.g. a domain metric.
+func (r *PodAutoscalerReconciler) computeReplicasFromZero(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler, scale *unstructured.Unstructured, targets []metricTarget, metricsErr error) (int32, string) {
+	if isActivationRequested(pa) {
+		setCondition(pa, autoscalingv1alpha1.Active, metav1.ConditionTrue, "ActivationRequested",
+			"the activation of the target was requested at %s", pa.Annotations[scalingcontext.ActivationRequestedAtLabel])
+		return 1, "activation requested"
+	}
+
+	if metricsErr == nil {
+		scaleResult, metricName, _, _, _ := r.computeReplicasForMetrics(ctx, *pa, scale, targets)
+		if scaleResult.ScaleValid && scaleResult.ObservedValue > 0 {
+			setCondition(pa, autoscalingv1alpha1.Active, metav1.ConditionTrue, "TrafficObserved",
+				"the target observed traffic on metric %s", metricName)
+			desiredReplicas := scaleResult.DesiredPodCount
+			if desiredReplicas < 1 {
+				desiredReplicas = 1
+			}
+			if desiredReplicas > pa.Spec.MaxReplicas {
+				desiredReplicas = pa.Spec.MaxReplicas
+			}
+			return desiredReplicas, fmt.Sprintf("%s above zero", metricName)
+		}
+	}
+
+	setCondition(pa, autoscalingv1alpha1.Active, metav1.ConditionFalse, "ScaledToZero",
+		"the target is scaled to zero until it observes traffic or its activation is requested")
+	return 0, ""
+}
+
+// retainBeforeScaleToZero keeps the Active condition of a scale-to-zero KPA target in sync with the observed metric,
+// and holds one replica until the target has observed no traffic for the retention period.
+func retainBeforeScaleToZero(pa *autoscalingv1alpha1.PodAutoscaler, metricName string, observedValue float64, desiredReplicas int32, now time.Time) int32 {
+	if observedValue > 0 {
+		setCondition(pa, autoscalingv1alpha1.Active, metav1.ConditionTrue, "TrafficObserved",
+			"the target observed traffic on metric %s", metricName)
+		if desiredReplicas < 1 {
+			return 1
+		}
+		return desiredReplicas
+	}
+
+	retention := getScaleToZeroRetentionPeriod(pa)
+	setCondition(pa, autoscalingv1alpha1.Active, metav1.ConditionFalse, "NoTraffic",
+		"the target observed no traffic, it is scaled to zero after the %v retention period", retention)
+	if desiredReplicas > 0 {
+		return desiredReplicas
+	}
+	lastActive := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.Active).LastTransitionTime.Time
+	if idle := now.Sub(lastActive); idle < retention {
+		klog.V(4).InfoS("Retaining one replica before scaling to zero", "PodAutoscaler", klog.KObj(pa),
+			"idle", idle, "retention", retention)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func TestReconcileKPAActivationFromZero(t *testing.T) {
+	inactiveSince := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	testCases := []struct {
+		name             string
+		requestedAt      string
+		expectedReplicas int32
+		expectedActive   metav1.ConditionStatus
+	}{
+		{name: "no activation request", expectedReplicas: 0, expectedActive: metav1.ConditionFalse},
+		{name: "activation requested after the target became inactive", requestedAt: inactiveSince.Add(time.Minute).Format(time.RFC3339),
+			expectedReplicas: 1, expectedActive: metav1.ConditionTrue},
+		{name: "activation already served", requestedAt: inactiveSince.Add(-time.Minute).Format(time.RFC3339),
+			expectedReplicas: 0, expectedActive: metav1.ConditionFalse},
+		{name: "invalid activation request", requestedAt: "now", expectedReplicas: 0, expectedActive: metav1.ConditionFalse},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{scalingcontext.ScaleToZeroLabel: "true"}
+			if tc.requestedAt != "" {
+				annotations[scalingcontext.ActivationRequestedAtLabel] = tc.requestedAt
+			}
+			pa := newTestPodAutoscaler(nil, 10, annotations)
+			pa.Status.Conditions = []metav1.Condition{{
+				Type:               autoscalingv1alpha1.Active,
+				Status:             metav1.ConditionFalse,
+				Reason:             "NoTraffic",
+				LastTransitionTime: metav1.NewTime(inactiveSince),
+			}}
+			r, _ := newTestReconciler(t, newTestDeployment(0), pa)
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.Active)
+			if cond == nil || cond.Status != tc.expectedActive {
+				t.Fatalf("expected Active=%s, got %+v", tc.expectedActive, cond)
+			}
+			if tc.expectedActive == metav1.ConditionFalse && !cond.LastTransitionTime.Time.Equal(inactiveSince) {
+				t.Errorf("expected the last active time %v to be kept, got %v", inactiveSince, cond.LastTransitionTime)
+			}
+		})
+	}
+}
+
+func TestRetainBeforeScaleToZero(t *testing.T) {
+	annotations := map[string]string{scalingcontext.ScaleToZeroRetentionPeriodLabel: "10m"}
+	pa := newTestPodAutoscaler(nil, 10, annotations)
+
+	// traffic keeps the target active and at least one replica.
+	now := time.Now()
+	if got := retainBeforeScaleToZero(pa, "test_metric", 0.5, 0, now); got != 1 {
+		t.Errorf("expected 1 replica while the target observes traffic, got %d", got)
+	}
+	if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.Active) {
+		t.Fatalf("expected Active to be true")
+	}
+
+	// the traffic stops, the target becomes inactive and is retained at one replica.
+	if got := retainBeforeScaleToZero(pa, "test_metric", 0, 0, now); got != 1 {
+		t.Errorf("expected 1 replica within the retention period, got %d", got)
+	}
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.Active)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "NoTraffic" {
+		t.Fatalf("expected Active=False with reason NoTraffic, got %+v", cond)
+	}
+	lastActive := cond.LastTransitionTime.Time
+	if got := retainBeforeScaleToZero(pa, "test_metric", 0, 0, lastActive.Add(9*time.Minute)); got != 1 {
+		t.Errorf("expected 1 replica within the retention period, got %d", got)
+	}
+	// a recommendation above zero is kept, e.g. while the scale down is stabilized.
+	if got := retainBeforeScaleToZero(pa, "test_metric", 0, 2, lastActive.Add(11*time.Minute)); got != 2 {
+		t.Errorf("expected the recommendation of 2 replicas to be kept, got %d", got)
+	}
+	if got := retainBeforeScaleToZero(pa, "test_metric", 0, 0, lastActive.Add(11*time.Minute)); got != 0 {
+		t.Errorf("expected to scale to zero after the retention period, got %d", got)
+	}
+	if !apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.Active).LastTransitionTime.Time.Equal(lastActive) {
+		t.Errorf("expected the last active time to be kept while the target is inactive")
+	}
+
+	// the retention period is disabled with 0s.
+	pa.Annotations[scalingcontext.ScaleToZeroRetentionPeriodLabel] = "0s"
+	if got := retainBeforeScaleToZero(pa, "test_metric", 0, 0, lastActive); got != 0 {
+		t.Errorf("expected to scale to zero without a retention period, got %d", got)
+	}
+}
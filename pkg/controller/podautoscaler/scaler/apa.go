@@ -18,6 +18,9 @@ package scaler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"sync"
 	"time"
@@ -29,7 +32,6 @@ import (
 	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
 	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
 	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
-	"github.com/vllm-project/aibrix/pkg/utils"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
@@ -42,6 +44,13 @@ const (
 	windowLabel                   = APALabelPrefix + "window"
 )
 
+// APAAnnotations are the annotations read by the APA scaling context.
+var APAAnnotations = []string{
+	upFluctuationToleranceLabel,
+	downFluctuationToleranceLabel,
+	windowLabel,
+}
+
 // ApaScalingContext defines parameters for scaling decisions.
 type ApaScalingContext struct {
 	scalingcontext.BaseScalingContext
@@ -93,12 +102,16 @@ var _ Scaler = (*ApaAutoscaler)(nil)
 
 // NewApaAutoscaler Initialize ApaAutoscaler
 func NewApaAutoscaler(readyPodsCount int, pa *autoscalingv1alpha1.PodAutoscaler) (*ApaAutoscaler, error) {
+	return NewApaAutoscalerWithFetcher(readyPodsCount, pa, metrics.NewRestMetricsFetcher())
+}
+
+// NewApaAutoscalerWithFetcher initializes an ApaAutoscaler which fetches the metrics with the given fetcher.
+func NewApaAutoscalerWithFetcher(readyPodsCount int, pa *autoscalingv1alpha1.PodAutoscaler, metricsFetcher metrics.MetricFetcher) (*ApaAutoscaler, error) {
 	spec, err := NewApaScalingContextByPa(pa)
 	if err != nil {
 		return nil, err
 	}
 
-	metricsFetcher := metrics.NewRestMetricsFetcher()
 	metricsClient := metrics.NewAPAMetricsClient(metricsFetcher, spec.Window)
 	scalingAlgorithm := algorithm.ApaScalingAlgorithm{}
 
@@ -159,12 +172,12 @@ func (a *ApaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 	observedValue, err := apaMetricsClient.GetMetricValue(metricKey, now)
 	if err != nil {
 		klog.Errorf("Failed to get stable and panic metrics for %s: %v", metricKey, err)
-		return ScaleResult{}
+		return ScaleResult{Reason: fmt.Sprintf("failed to get metric value: %v", err)}
 	}
 
 	if originalReadyPodsCount == 0 {
 		klog.Errorf("Unexpected pod count for %s: %d", metricKey, originalReadyPodsCount)
-		return ScaleResult{}
+		return ScaleResult{Reason: fmt.Sprintf("unexpected ready pod count: %d", originalReadyPodsCount)}
 	}
 
 	currentUsePerPod := observedValue / float64(originalReadyPodsCount)
@@ -176,13 +189,34 @@ func (a *ApaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 		DesiredPodCount:     desiredPodCount,
 		ExcessBurstCapacity: 0,
 		ScaleValid:          true,
+		ObservedValue:       observedValue,
+		StableValue:         observedValue,
+		Explanation:         explainApaScale(spec, observedValue, currentUsePerPod, desiredPodCount),
+	}
+}
+
+// explainApaScale explains the pod count the APA algorithm computed, which only follows the metric outside of the
+// fluctuation tolerances and within the scale rate limits.
+func explainApaScale(spec *ApaScalingContext, observedValue, currentUsePerPod float64, desiredPodCount int32) ScaleExplanation {
+	explanation := ScaleExplanation{Value: observedValue, Window: spec.Window, TargetValue: spec.TargetValue,
+		RawPodCount: podCount(math.Ceil(observedValue / spec.TargetValue))}
+	ratio := currentUsePerPod / spec.TargetValue
+	switch {
+	case ratio > 1+spec.UpFluctuationTolerance:
+		explanation.Adjust(rateRule("maxScaleUp", spec.MaxScaleUpRate), explanation.RawPodCount, desiredPodCount)
+	case ratio < 1-spec.DownFluctuationTolerance:
+		explanation.Adjust(rateRule("maxScaleDown", spec.MaxScaleDownRate), explanation.RawPodCount, desiredPodCount)
+	default:
+		explanation.Keep("tolerance", explanation.RawPodCount, desiredPodCount)
 	}
+	return explanation
 }
 
 func (a *ApaAutoscaler) UpdateScaleTargetMetrics(ctx context.Context, metricKey metrics.NamespaceNameMetric, source autoscalingv1alpha1.MetricSource, pods []v1.Pod, now time.Time) error {
-	activePods := utils.FilterActivePods(pods)
-	metricValues, err := a.metricClient.GetMetricsFromPods(ctx, activePods, source)
-	if err != nil {
+	// the pods which are not ready to serve are skipped by the scrape.
+	metricValues, err := a.metricClient.GetMetricsFromPods(ctx, pods, source)
+	var partial *metrics.PartialMetricsError
+	if err != nil && !errors.As(err, &partial) {
 		return err
 	}
 
@@ -191,6 +225,10 @@ func (a *ApaAutoscaler) UpdateScaleTargetMetrics(ctx context.Context, metricKey
 		return err
 	}
 
+	// the metrics of the scraped pods are recorded, the caller decides how to scale with the missing ones.
+	if partial != nil {
+		return partial
+	}
 	return nil
 }
 
@@ -203,21 +241,28 @@ func (a *ApaAutoscaler) UpdateSourceMetrics(ctx context.Context, metricKey metri
 	return a.metricClient.UpdateMetrics(now, metricKey, metricValue)
 }
 
+func (a *ApaAutoscaler) UpdateMetrics(metricKey metrics.NamespaceNameMetric, now time.Time, metricValues ...float64) error {
+	return a.metricClient.UpdateMetrics(now, metricKey, metricValues...)
+}
+
 func (a *ApaAutoscaler) UpdateScalingContext(pa autoscalingv1alpha1.PodAutoscaler) error {
 	a.specMux.Lock()
 	defer a.specMux.Unlock()
 
-	// update context and check configuration restraint.
-	// N.B. for now, we forbid update the config related to the stateful attribute, like window length.
+	// update context, the metric window is resized in place.
 	updatedSpec, err := NewApaScalingContextByPa(&pa)
 	if err != nil {
 		return err
 	}
-	// check apa spec: panic window, stable window and delaywindow
 	rawSpec := a.scalingContext
 	if updatedSpec.Window != rawSpec.Window {
-		klog.Warningf("For APA, updating the Window (%v) is not allowed. Keep the original value (%v)", updatedSpec.Window, rawSpec.Window)
-		updatedSpec.Window = rawSpec.Window
+		if client, ok := a.metricClient.(*metrics.APAMetricsClient); ok {
+			klog.InfoS("Resize APA metric window", "window", updatedSpec.Window)
+			client.ResizeWindow(updatedSpec.Window)
+		} else {
+			klog.Warningf("For APA, the metric client can not resize its window. Keep the original value (%v)", rawSpec.Window)
+			updatedSpec.Window = rawSpec.Window
+		}
 	}
 	a.scalingContext = updatedSpec
 	return nil
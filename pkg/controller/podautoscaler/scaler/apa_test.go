@@ -250,3 +250,97 @@ func TestApaScale2(t *testing.T) {
 	}
 
 }
+
+func TestApaScaleInvalidReason(t *testing.T) {
+	pa := &autoscalingv1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test_ns", Name: "test_llm_for_pa"},
+		Spec: autoscalingv1alpha1.PodAutoscalerSpec{
+			ScaleTargetRef: corev1.ObjectReference{Kind: "Deployment", Name: "example-deployment"},
+			MaxReplicas:    5,
+			MetricsSources: []autoscalingv1alpha1.MetricSource{
+				{
+					MetricSourceType: autoscalingv1alpha1.POD,
+					ProtocolType:     autoscalingv1alpha1.HTTP,
+					Path:             "metrics",
+					Port:             "8000",
+					TargetMetric:     "ttot",
+					TargetValue:      "50",
+				},
+			},
+			ScalingStrategy: "APA",
+		},
+	}
+	autoScaler, err := NewApaAutoscaler(0, pa)
+	if err != nil {
+		t.Fatalf("NewApaAutoscaler() failed: %v", err)
+	}
+	metricKey, _, err := metrics.NewNamespaceNameMetric(pa)
+	if err != nil {
+		t.Fatalf("NewNamespaceNameMetric() failed: %v", err)
+	}
+
+	now := time.Unix(int64(10000), 0)
+	if err := autoScaler.UpdateMetrics(metricKey, now, 100); err != nil {
+		t.Fatalf("UpdateMetrics() failed: %v", err)
+	}
+	result := autoScaler.Scale(0, metricKey, now)
+	if result.ScaleValid {
+		t.Fatalf("expected an invalid scale result without ready pods, got %+v", result)
+	}
+	if result.Reason != "unexpected ready pod count: 0" {
+		t.Errorf("unexpected reason of the invalid scale result: %q", result.Reason)
+	}
+}
+
+func TestApaResizeWindow(t *testing.T) {
+	pa := &autoscalingv1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "test_ns",
+			Name:        "test_llm_for_pa",
+			Annotations: map[string]string{"apa.autoscaling.aibrix.ai/window": "30s"},
+		},
+		Spec: autoscalingv1alpha1.PodAutoscalerSpec{
+			ScaleTargetRef: corev1.ObjectReference{Kind: "Deployment", Name: "example-deployment"},
+			MaxReplicas:    5,
+			MetricsSources: []autoscalingv1alpha1.MetricSource{
+				{
+					MetricSourceType: autoscalingv1alpha1.POD,
+					ProtocolType:     autoscalingv1alpha1.HTTP,
+					Path:             "metrics",
+					Port:             "8000",
+					TargetMetric:     "ttot",
+					TargetValue:      "50",
+				},
+			},
+			ScalingStrategy: "APA",
+		},
+	}
+	autoScaler, err := NewApaAutoscaler(1, pa)
+	if err != nil {
+		t.Fatalf("NewApaAutoscaler() failed: %v", err)
+	}
+	metricKey, _, err := metrics.NewNamespaceNameMetric(pa)
+	if err != nil {
+		t.Fatalf("NewNamespaceNameMetric() failed: %v", err)
+	}
+
+	now := time.Unix(int64(10000), 0)
+	_ = autoScaler.UpdateMetrics(metricKey, now.Add(-20*time.Second), 100)
+	_ = autoScaler.UpdateMetrics(metricKey, now, 10)
+	apaMetricsClient := autoScaler.metricClient.(*metrics.APAMetricsClient)
+	if value, err := apaMetricsClient.GetMetricValue(metricKey, now); err != nil || value != 55 {
+		t.Fatalf("expected the average of the 30s window = 55, got %v err: %v", value, err)
+	}
+
+	// the window annotation changes: the window is resized, keeping the samples within the new duration.
+	pa.Annotations["apa.autoscaling.aibrix.ai/window"] = "10s"
+	if err := autoScaler.UpdateScalingContext(*pa); err != nil {
+		t.Fatalf("UpdateScalingContext() failed: %v", err)
+	}
+	if window := autoScaler.GetScalingContext().(*ApaScalingContext).Window; window != 10*time.Second {
+		t.Errorf("expected Window = 10s, got %v", window)
+	}
+	if value, err := apaMetricsClient.GetMetricValue(metricKey, now); err != nil || value != 10 {
+		t.Errorf("expected the average of the 10s window = 10, got %v err: %v", value, err)
+	}
+}
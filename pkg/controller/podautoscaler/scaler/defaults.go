@@ -0,0 +1,92 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaler
+
+import (
+	"strconv"
+
+	"k8s.io/utils/ptr"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+// DefaultMinReplicas is the lower bound of the replicas of a PodAutoscaler which neither sets MinReplicas nor opts in
+// to scale-to-zero.
+const DefaultMinReplicas int32 = 1
+
+// defaultAnnotations are the tuning parameters of each strategy written on the PodAutoscalers which do not set them,
+// they are the defaults of the scaling contexts so that a defaulted PodAutoscaler scales as it did before.
+var defaultAnnotations = map[autoscalingv1alpha1.ScalingStrategyType]map[string]string{
+	autoscalingv1alpha1.KPA: {
+		stableWindowLabel:   "60s",
+		panicThresholdLabel: "2.0",
+	},
+	autoscalingv1alpha1.APA: {
+		upFluctuationToleranceLabel:   "0.1",
+		downFluctuationToleranceLabel: "0.2",
+		windowLabel:                   "60s",
+	},
+}
+
+// SetDefaults fills the MinReplicas and the tuning parameters of the strategy the PodAutoscaler does not set, the
+// values it sets are kept.
+func SetDefaults(pa *autoscalingv1alpha1.PodAutoscaler) {
+	if pa.Spec.MinReplicas == nil {
+		pa.Spec.MinReplicas = ptr.To(DefaultMinReplicasOf(pa))
+	}
+	SetTuningDefaults(pa)
+}
+
+// DefaultMinReplicasOf returns the MinReplicas of a PodAutoscaler which does not set it: 0 if it opts in to
+// scale-to-zero, DefaultMinReplicas otherwise.
+func DefaultMinReplicasOf(pa *autoscalingv1alpha1.PodAutoscaler) int32 {
+	if IsScaleToZeroEnabled(pa) {
+		return 0
+	}
+	return DefaultMinReplicas
+}
+
+// IsScaleToZeroEnabled checks whether the PodAutoscaler opts in to scale-to-zero via annotation.
+func IsScaleToZeroEnabled(pa *autoscalingv1alpha1.PodAutoscaler) bool {
+	value, ok := pa.Annotations[scalingcontext.ScaleToZeroLabel]
+	if !ok {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false
+	}
+	return enabled
+}
+
+// SetTuningDefaults fills the tuning parameters of the strategy the PodAutoscaler does not set, the annotations of
+// the strategies without defaults are left as is.
+func SetTuningDefaults(pa *autoscalingv1alpha1.PodAutoscaler) {
+	defaults := defaultAnnotations[pa.Spec.ScalingStrategy]
+	if len(defaults) == 0 {
+		return
+	}
+	if pa.Annotations == nil {
+		pa.Annotations = make(map[string]string, len(defaults))
+	}
+	for key, value := range defaults {
+		if _, ok := pa.Annotations[key]; !ok {
+			pa.Annotations[key] = value
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaler
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func newDefaultsTestPodAutoscaler(strategy autoscalingv1alpha1.ScalingStrategyType, annotations map[string]string) *autoscalingv1alpha1.PodAutoscaler {
+	return &autoscalingv1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "default", Annotations: annotations},
+		Spec: autoscalingv1alpha1.PodAutoscalerSpec{
+			ScalingStrategy: strategy,
+			MetricsSources: []autoscalingv1alpha1.MetricSource{{
+				MetricSourceType: autoscalingv1alpha1.POD,
+				TargetMetric:     "kv_cache_usage_perc",
+				TargetValue:      "50",
+			}},
+		},
+	}
+}
+
+// TestSetDefaultsScalesAsBefore checks the defaulted tuning parameters are the defaults of the scaling contexts, so
+// that a PodAutoscaler scales the same whether it was defaulted or not.
+func TestSetDefaultsScalesAsBefore(t *testing.T) {
+	kpa := newDefaultsTestPodAutoscaler(autoscalingv1alpha1.KPA, nil)
+	SetDefaults(kpa)
+	defaulted, err := NewKpaScalingContextByPa(kpa)
+	if err != nil {
+		t.Fatalf("invalid defaults: %v", err)
+	}
+	undefaulted, _ := NewKpaScalingContextByPa(newDefaultsTestPodAutoscaler(autoscalingv1alpha1.KPA, nil))
+	if !reflect.DeepEqual(defaulted, undefaulted) {
+		t.Errorf("expected the KPA defaults %+v, got %+v", undefaulted, defaulted)
+	}
+
+	apa := newDefaultsTestPodAutoscaler(autoscalingv1alpha1.APA, nil)
+	SetDefaults(apa)
+	apaDefaulted, err := NewApaScalingContextByPa(apa)
+	if err != nil {
+		t.Fatalf("invalid defaults: %v", err)
+	}
+	apaUndefaulted, _ := NewApaScalingContextByPa(newDefaultsTestPodAutoscaler(autoscalingv1alpha1.APA, nil))
+	if !reflect.DeepEqual(apaDefaulted, apaUndefaulted) {
+		t.Errorf("expected the APA defaults %+v, got %+v", apaUndefaulted, apaDefaulted)
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	testCases := []struct {
+		name                string
+		strategy            autoscalingv1alpha1.ScalingStrategyType
+		minReplicas         *int32
+		annotations         map[string]string
+		expectedMinReplicas int32
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:                "kpa",
+			strategy:            autoscalingv1alpha1.KPA,
+			expectedMinReplicas: 1,
+			expectedAnnotations: map[string]string{stableWindowLabel: "60s", panicThresholdLabel: "2.0"},
+		},
+		{
+			name:                "apa",
+			strategy:            autoscalingv1alpha1.APA,
+			expectedMinReplicas: 1,
+			expectedAnnotations: map[string]string{upFluctuationToleranceLabel: "0.1", downFluctuationToleranceLabel: "0.2", windowLabel: "60s"},
+		},
+		{
+			name:                "hpa",
+			strategy:            autoscalingv1alpha1.HPA,
+			expectedMinReplicas: 1,
+		},
+		{
+			name:                "values set are kept",
+			strategy:            autoscalingv1alpha1.KPA,
+			minReplicas:         ptrInt32(3),
+			annotations:         map[string]string{stableWindowLabel: "120s"},
+			expectedMinReplicas: 3,
+			expectedAnnotations: map[string]string{stableWindowLabel: "120s", panicThresholdLabel: "2.0"},
+		},
+		{
+			name:                "zero min replicas is kept",
+			strategy:            autoscalingv1alpha1.KPA,
+			minReplicas:         ptrInt32(0),
+			annotations:         map[string]string{panicThresholdLabel: "3"},
+			expectedMinReplicas: 0,
+			expectedAnnotations: map[string]string{stableWindowLabel: "60s", panicThresholdLabel: "3"},
+		},
+		{
+			name:                "scale-to-zero defaults min replicas to zero",
+			strategy:            autoscalingv1alpha1.HPA,
+			annotations:         map[string]string{scalingcontext.ScaleToZeroLabel: "true"},
+			expectedMinReplicas: 0,
+			expectedAnnotations: map[string]string{scalingcontext.ScaleToZeroLabel: "true"},
+		},
+		{
+			name:                "scale-to-zero keeps the min replicas set",
+			strategy:            autoscalingv1alpha1.HPA,
+			minReplicas:         ptrInt32(2),
+			annotations:         map[string]string{scalingcontext.ScaleToZeroLabel: "true"},
+			expectedMinReplicas: 2,
+			expectedAnnotations: map[string]string{scalingcontext.ScaleToZeroLabel: "true"},
+		},
+		{
+			name:                "disabled scale-to-zero",
+			strategy:            autoscalingv1alpha1.HPA,
+			annotations:         map[string]string{scalingcontext.ScaleToZeroLabel: "false"},
+			expectedMinReplicas: 1,
+			expectedAnnotations: map[string]string{scalingcontext.ScaleToZeroLabel: "false"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pa := newDefaultsTestPodAutoscaler(tc.strategy, tc.annotations)
+			pa.Spec.MinReplicas = tc.minReplicas
+			SetDefaults(pa)
+			if pa.Spec.MinReplicas == nil || *pa.Spec.MinReplicas != tc.expectedMinReplicas {
+				t.Errorf("expected %d min replicas, got %v", tc.expectedMinReplicas, pa.Spec.MinReplicas)
+			}
+			if len(pa.Annotations) != len(tc.expectedAnnotations) || (len(tc.expectedAnnotations) > 0 && !reflect.DeepEqual(pa.Annotations, tc.expectedAnnotations)) {
+				t.Errorf("expected the annotations %v, got %v", tc.expectedAnnotations, pa.Annotations)
+			}
+		})
+	}
+}
+
+func ptrInt32(v int32) *int32 {
+	return &v
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaler
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// AdjustmentKind tells how a step of a scaling decision changed the recommended pod count.
+type AdjustmentKind string
+
+const (
+	// AdjustmentCapped lowers the pod count to the limit of the step.
+	AdjustmentCapped AdjustmentKind = "capped"
+	// AdjustmentRaised raises the pod count to the limit of the step.
+	AdjustmentRaised AdjustmentKind = "raised"
+	// AdjustmentKept holds a pod count the metric no longer asks for, e.g. during a scale down delay.
+	AdjustmentKept AdjustmentKind = "kept"
+)
+
+// ScaleAdjustment is a step of a scaling decision which changed the recommended pod count.
+type ScaleAdjustment struct {
+	// Rule names the rule of the step, e.g. "maxScaleUp 2x".
+	Rule string
+	// Kind tells how the step changed the pod count.
+	Kind AdjustmentKind
+	// PodCount is the pod count after the step.
+	PodCount int32
+}
+
+// ScaleExplanation records how a scaling decision was made: the metric value compared with the target, the raw pod
+// count they give, and the steps which adjusted the raw pod count, in the order they were applied.
+type ScaleExplanation struct {
+	// Value is the metric value the raw pod count was computed from.
+	Value float64
+	// Window is the time range Value is averaged over.
+	Window time.Duration
+	// TargetValue is the target value of the metric per pod.
+	TargetValue float64
+	// RawPodCount is the pod count the metric asks for before any rule is applied.
+	RawPodCount int32
+	// Adjustments are the steps which changed the pod count after RawPodCount.
+	Adjustments []ScaleAdjustment
+}
+
+// Adjust records a step which capped or raised the pod count from one value to another, a step leaving the pod count
+// unchanged is not recorded.
+func (e *ScaleExplanation) Adjust(rule string, from, to int32) {
+	switch {
+	case to < from:
+		e.Adjustments = append(e.Adjustments, ScaleAdjustment{Rule: rule, Kind: AdjustmentCapped, PodCount: to})
+	case to > from:
+		e.Adjustments = append(e.Adjustments, ScaleAdjustment{Rule: rule, Kind: AdjustmentRaised, PodCount: to})
+	}
+}
+
+// Keep records a step which held a previous pod count rather than the one the steps before asked for.
+func (e *ScaleExplanation) Keep(rule string, from, to int32) {
+	if to != from {
+		e.Adjustments = append(e.Adjustments, ScaleAdjustment{Rule: rule, Kind: AdjustmentKept, PodCount: to})
+	}
+}
+
+// PodCount returns the pod count after the last step.
+func (e *ScaleExplanation) PodCount() int32 {
+	if len(e.Adjustments) == 0 {
+		return e.RawPodCount
+	}
+	return e.Adjustments[len(e.Adjustments)-1].PodCount
+}
+
+// rateRule names the rule of a scale rate limit, e.g. "maxScaleUp 2x".
+func rateRule(name string, rate float64) string {
+	return name + " " + strconv.FormatFloat(rate, 'f', -1, 64) + "x"
+}
+
+// podCount converts a pod count computed in floating point, which may be infinite for a zero rate, to an int32.
+func podCount(count float64) int32 {
+	if count >= math.MaxInt32 {
+		return math.MaxInt32
+	}
+	if count <= 0 || math.IsNaN(count) {
+		return 0
+	}
+	return int32(count)
+}
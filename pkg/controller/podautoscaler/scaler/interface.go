@@ -71,6 +71,10 @@ type Scaler interface {
 	// This method ensures that the autoscaler has up-to-date metrics before making any scaling decisions.
 	UpdateSourceMetrics(ctx context.Context, metricKey metrics.NamespaceNameMetric, source autoscalingv1alpha1.MetricSource, now time.Time) error
 
+	// UpdateMetrics stores the metric values fetched by the caller, e.g. from the Kubernetes custom or external
+	// metrics APIs, for later use during scaling decisions. The values are summed as for the pods of the target.
+	UpdateMetrics(metricKey metrics.NamespaceNameMetric, now time.Time, metricValues ...float64) error
+
 	// Scale calculates the necessary scaling action based on observed metrics
 	// and the current time. This is the core logic of the autoscaler.
 	//
@@ -122,4 +126,46 @@ type ScaleResult struct {
 	// ScaleValid specifies whether this scale result is valid, i.e. whether
 	// Autoscaler had all the necessary information to compute a suggestion.
 	ScaleValid bool
+	// Reason explains why the scale result is not valid, empty for a valid one.
+	Reason string
+	// InPanicMode reports whether the suggestion was computed in panic mode, only KPA panics.
+	InPanicMode bool
+	// ObservedValue is the metric value the suggestion was computed from, zero when the target saw no load.
+	ObservedValue float64
+	// StableValue and PanicValue are the metric values observed over the stable and the panic windows, only KPA
+	// observes a panic window.
+	StableValue float64
+	PanicValue  float64
+	// Explanation records how the suggestion was computed, for the explanation of the scaling decision.
+	Explanation ScaleExplanation
+	// RecommendedPodCount is the pod count the metric asked for before the scale rate limits were applied.
+	RecommendedPodCount int32
+	// RateLimit names the scale rate limit which truncated RecommendedPodCount, e.g. "maxScaleUp 2x", empty when
+	// the suggestion is within the rate limits.
+	RateLimit string
+}
+
+// PanicState is the panic mode state of a scaler.
+type PanicState struct {
+	// PanicTime is when the panic threshold was last exceeded, zero out of panic mode.
+	PanicTime time.Time
+	// MaxPanicPods is the highest pod count recommended since the panic mode began, which is held until it exits.
+	MaxPanicPods int32
+}
+
+// Recommendation is a pod count recommended for the scale target.
+type Recommendation struct {
+	Time     time.Time
+	PodCount int32
+}
+
+// RestorableScaler is implemented by the scalers whose state is persisted in the status of the PodAutoscaler, so
+// that a restart of the controller neither drops the burst protection of the panic mode nor the delay of the scale
+// downs.
+type RestorableScaler interface {
+	// PanicState returns the current panic state, the zero state out of panic mode.
+	PanicState() PanicState
+	// RestoreState restores the persisted state into a new scaler: the panic state, unless zero, replaces the one
+	// the scaler was created in, and the recommendations are recorded as if the scaler had made them.
+	RestoreState(state PanicState, recommendations []Recommendation)
 }
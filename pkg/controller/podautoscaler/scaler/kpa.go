@@ -31,7 +31,6 @@ import (
 	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
 	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
 	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
-	"github.com/vllm-project/aibrix/pkg/utils"
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/aggregation"
@@ -61,21 +60,45 @@ If the metric no longer exceeds the panic threshold, exit the panic mode.
 */
 
 const (
-	KPALabelPrefix           = "kpa." + scalingcontext.AutoscalingLabelPrefix
-	targetBurstCapacityLabel = KPALabelPrefix + "target-burst-capacity"
-	activationScaleLabel     = KPALabelPrefix + "activation-scale"
-	panicThresholdLabel      = KPALabelPrefix + "panic-threshold"
-	stableWindowLabel        = KPALabelPrefix + "stable-window"
-	panicWindowLabel         = KPALabelPrefix + "panic-window"
-	scaleDownDelayLabel      = KPALabelPrefix + "scale-down-delay"
+	KPALabelPrefix             = "kpa." + scalingcontext.AutoscalingLabelPrefix
+	targetBurstCapacityLabel   = KPALabelPrefix + "target-burst-capacity"
+	activationScaleLabel       = KPALabelPrefix + "activation-scale"
+	panicThresholdLabel        = KPALabelPrefix + "panic-threshold"
+	stableWindowLabel          = KPALabelPrefix + "stable-window"
+	stableWindowUpLabel        = KPALabelPrefix + "stable-window-up"
+	stableWindowDownLabel      = KPALabelPrefix + "stable-window-down"
+	panicWindowLabel           = KPALabelPrefix + "panic-window"
+	panicWindowPercentageLabel = KPALabelPrefix + "panic-window-percentage"
+	scaleDownDelayLabel        = KPALabelPrefix + "scale-down-delay"
+	toleranceLabel             = KPALabelPrefix + "tolerance"
 )
 
-// KpaScalingContext defines parameters for scaling decisions.
-type KpaScalingContext struct {
-	scalingcontext.BaseScalingContext
+// The bounds of the KPA annotations.
+const (
+	maxKpaWindow         = time.Hour
+	maxKpaPanicThreshold = 10.0
+)
 
+// KPAAnnotations are the annotations read by the KPA scaling context.
+var KPAAnnotations = []string{
+	targetBurstCapacityLabel,
+	activationScaleLabel,
+	panicThresholdLabel,
+	stableWindowLabel,
+	stableWindowUpLabel,
+	stableWindowDownLabel,
+	panicWindowLabel,
+	panicWindowPercentageLabel,
+	scaleDownDelayLabel,
+	toleranceLabel,
+}
+
+// KpaConfig holds the parameters of the KPA scaling algorithm which are tuned per PodAutoscaler with the KPA
+// annotations, so that e.g. a large model scales conservatively and a small one aggressively.
+type KpaConfig struct {
 	// The burst capacity that user wants to maintain without queuing at the POD level.
 	// Note, that queueing still might happen due to the non-ideal load balancing.
+	// 0 disables the burst capacity, and -1 makes it unlimited.
 	TargetBurstCapacity float64
 	// ActivationScale is the minimum, non-zero value that a service should scale to.
 	// For example, if ActivationScale = 2, when a service scaled from zero it would
@@ -92,19 +115,25 @@ type KpaScalingContext struct {
 	PanicThreshold float64
 	// StableWindow is needed to determine when to exit panic mode.
 	StableWindow time.Duration
-	// PanicWindow is needed to determine when to exit panic mode.
+	// StableWindowUp and StableWindowDown are the windows the stable metric is averaged over when the
+	// recommendation increases and decreases the replicas respectively, so that a target can scale up on a short
+	// window and down on a long one. They default to the StableWindow when unset.
+	StableWindowUp   time.Duration
+	StableWindowDown time.Duration
+	// PanicWindow is needed to determine when to exit panic mode. It is either set as a duration, or as a
+	// percentage of the StableWindow.
 	PanicWindow time.Duration
 	// ScaleDownDelay is the time that must pass at reduced concurrency before a
 	// scale-down decision is applied.
 	ScaleDownDelay time.Duration
+	// Tolerance is the relative deviation of the stable metric from its target within which the ready pods are
+	// kept, e.g. 0.1 ignores the loads within 10% of the target. 0 scales on any deviation.
+	Tolerance float64
 }
 
-var _ scalingcontext.ScalingContext = (*KpaScalingContext)(nil)
-
-// NewKpaScalingContext references KPA and sets up a default configuration.
-func NewKpaScalingContext() *KpaScalingContext {
-	return &KpaScalingContext{
-		BaseScalingContext:  *scalingcontext.NewBaseScalingContext(),
+// DefaultKpaConfig returns the configuration of a PodAutoscaler without KPA annotations.
+func DefaultKpaConfig() KpaConfig {
+	return KpaConfig{
 		TargetBurstCapacity: 2.0,              // Target burst capacity to handle sudden spikes
 		ActivationScale:     1,                // Initial scaling factor upon activation
 		PanicThreshold:      2.0,              // Panic threshold set at 200% to trigger rapid scaling
@@ -114,65 +143,204 @@ func NewKpaScalingContext() *KpaScalingContext {
 	}
 }
 
-// NewKpaScalingContextByPa initializes KpaScalingContext by passed-in PodAutoscaler description
-func NewKpaScalingContextByPa(pa *autoscalingv1alpha1.PodAutoscaler) (*KpaScalingContext, error) {
-	res := NewKpaScalingContext()
-	err := res.UpdateByPaTypes(pa)
-	if err != nil {
-		return nil, err
+// ParseKpaConfig parses the KPA annotations of a PodAutoscaler over the defaults. Every invalid or out of range
+// value is reported, so that a misconfigured PodAutoscaler is not scaled with parameters other than the intended ones.
+func ParseKpaConfig(annotations map[string]string) (KpaConfig, error) {
+	config := DefaultKpaConfig()
+	var errs []error
+	invalid := func(key, value, reason string) {
+		errs = append(errs, fmt.Errorf("invalid %s %q: %s", key, value, reason))
 	}
-	return res, nil
-}
-
-func (k *KpaScalingContext) UpdateByPaTypes(pa *autoscalingv1alpha1.PodAutoscaler) error {
-	err := k.BaseScalingContext.UpdateByPaTypes(pa)
-	if err != nil {
-		return err
+	parseWindow := func(key, value string) (time.Duration, bool) {
+		v, err := time.ParseDuration(value)
+		if err != nil || v <= 0 || v > maxKpaWindow {
+			invalid(key, value, fmt.Sprintf("it must be a positive duration of at most %v", maxKpaWindow))
+			return 0, false
+		}
+		return v, true
 	}
-	for key, value := range pa.Annotations {
+
+	panicWindowPercentage := 0.0
+	for _, key := range KPAAnnotations {
+		value, ok := annotations[key]
+		if !ok {
+			continue
+		}
 		switch key {
 		case targetBurstCapacityLabel:
 			v, err := strconv.ParseFloat(value, 64)
-			if err != nil {
-				return err
+			if err != nil || (v < 0 && v != -1) || math.IsInf(v, 0) || math.IsNaN(v) {
+				invalid(key, value, "it must be a non-negative number, or -1 for an unlimited burst capacity")
+				continue
 			}
-			k.TargetBurstCapacity = v
+			config.TargetBurstCapacity = v
 		case activationScaleLabel:
 			v, err := strconv.ParseInt(value, 10, 32)
-			if err != nil {
-				return err
+			if err != nil || v < 1 {
+				invalid(key, value, "it must be a whole number of at least 1")
+				continue
 			}
-			k.ActivationScale = int32(v)
+			config.ActivationScale = int32(v)
 		case panicThresholdLabel:
 			v, err := strconv.ParseFloat(value, 64)
-			if err != nil {
-				return err
+			if err != nil || v < 1 || v > maxKpaPanicThreshold || math.IsNaN(v) {
+				invalid(key, value, fmt.Sprintf("it must be a factor between 1 and %v", maxKpaPanicThreshold))
+				continue
 			}
-			k.PanicThreshold = v
+			config.PanicThreshold = v
 		case stableWindowLabel:
-			v, err := time.ParseDuration(value)
-			if err != nil {
-				return err
+			if v, ok := parseWindow(key, value); ok {
+				config.StableWindow = v
+			}
+		case stableWindowUpLabel:
+			if v, ok := parseWindow(key, value); ok {
+				config.StableWindowUp = v
+			}
+		case stableWindowDownLabel:
+			if v, ok := parseWindow(key, value); ok {
+				config.StableWindowDown = v
 			}
-			k.StableWindow = v
 		case panicWindowLabel:
-			v, err := time.ParseDuration(value)
-			if err != nil {
-				return err
+			if _, ok := annotations[panicWindowPercentageLabel]; ok {
+				invalid(key, value, fmt.Sprintf("it cannot be set with %s", panicWindowPercentageLabel))
+				continue
+			}
+			if v, ok := parseWindow(key, value); ok {
+				config.PanicWindow = v
 			}
-			k.PanicWindow = v
+		case panicWindowPercentageLabel:
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || v <= 0 || v > 100 || math.IsNaN(v) {
+				invalid(key, value, "it must be a percentage of the stable window greater than 0 and at most 100")
+				continue
+			}
+			panicWindowPercentage = v
 		case scaleDownDelayLabel:
 			v, err := time.ParseDuration(value)
-			if err != nil {
-				return err
+			if err != nil || v < 0 || v > maxKpaWindow {
+				invalid(key, value, fmt.Sprintf("it must be a non-negative duration of at most %v", maxKpaWindow))
+				continue
+			}
+			config.ScaleDownDelay = v
+		case toleranceLabel:
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || v < 0 || v >= 1 || math.IsNaN(v) {
+				invalid(key, value, "it must be a fraction of the target of at least 0 and less than 1")
+				continue
 			}
-			k.ScaleDownDelay = v
+			config.Tolerance = v
 		}
 	}
 
+	if panicWindowPercentage > 0 {
+		config.PanicWindow = time.Duration(float64(config.StableWindow) * panicWindowPercentage / 100)
+	}
+	if len(errs) == 0 && config.PanicWindow > config.StableWindow {
+		errs = append(errs, fmt.Errorf("the panic window %v must not be longer than the stable window %v", config.PanicWindow, config.StableWindow))
+	}
+	return config, errors.Join(errs...)
+}
+
+// KpaScalingContext defines parameters for scaling decisions.
+type KpaScalingContext struct {
+	scalingcontext.BaseScalingContext
+	KpaConfig
+}
+
+var _ scalingcontext.ScalingContext = (*KpaScalingContext)(nil)
+
+// NewKpaScalingContext references KPA and sets up a default configuration.
+func NewKpaScalingContext() *KpaScalingContext {
+	return &KpaScalingContext{
+		BaseScalingContext: *scalingcontext.NewBaseScalingContext(),
+		KpaConfig:          DefaultKpaConfig(),
+	}
+}
+
+// NewKpaScalingContextByPa initializes KpaScalingContext by passed-in PodAutoscaler description
+func NewKpaScalingContextByPa(pa *autoscalingv1alpha1.PodAutoscaler) (*KpaScalingContext, error) {
+	res := NewKpaScalingContext()
+	err := res.UpdateByPaTypes(pa)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (k *KpaScalingContext) UpdateByPaTypes(pa *autoscalingv1alpha1.PodAutoscaler) error {
+	err := k.BaseScalingContext.UpdateByPaTypes(pa)
+	if err != nil {
+		return err
+	}
+	config, err := ParseKpaConfig(pa.Annotations)
+	if err != nil {
+		return err
+	}
+	k.KpaConfig = config
 	return nil
 }
 
+// stableWindows returns the windows the stable metric is averaged over for a scale up and a scale down.
+func (k *KpaScalingContext) stableWindows() (up, down time.Duration) {
+	up, down = k.StableWindow, k.StableWindow
+	if k.StableWindowUp > 0 {
+		up = k.StableWindowUp
+	}
+	if k.StableWindowDown > 0 {
+		down = k.StableWindowDown
+	}
+	return up, down
+}
+
+// withinTolerance returns whether the stable metric value of the ready pods deviates from their target by no more
+// than the tolerance, in which case the ready pods are kept.
+func (k *KpaScalingContext) withinTolerance(value, readyPodsCount float64) bool {
+	if k.Tolerance <= 0 || readyPodsCount <= 0 {
+		return false
+	}
+	return math.Abs(value/(readyPodsCount*k.TargetValue)-1) <= k.Tolerance
+}
+
+// stableHistory returns the time range of the stable metric samples the autoscaler keeps, which covers the stable
+// window and both directional windows.
+func (k *KpaScalingContext) stableHistory() time.Duration {
+	up, down := k.stableWindows()
+	history := k.StableWindow
+	if up > history {
+		history = up
+	}
+	if down > history {
+		history = down
+	}
+	return history
+}
+
+// warnShortStableWindowDown warns of a scale-down window shorter than the scale-up one, which scales the target
+// down on a shorter history of the load than it scales it up.
+func (k *KpaScalingContext) warnShortStableWindowDown(pa *autoscalingv1alpha1.PodAutoscaler) {
+	if up, down := k.stableWindows(); down < up {
+		klog.InfoS("The scale-down stable window is shorter than the scale-up one, a longer scale-down window is recommended",
+			"PodAutoscaler", klog.KObj(pa), "stableWindowUp", up, "stableWindowDown", down)
+	}
+}
+
+// stableAndPanicMetricClient is the metric client KPA needs to observe the stable and panic window values.
+type stableAndPanicMetricClient interface {
+	metrics.MetricClient
+	StableAndPanicMetrics(metricKey metrics.NamespaceNameMetric, now time.Time) (float64, float64, error)
+}
+
+// directionalStableMetricClient is the metric client of KPA which can average the stable metric over the scale-up
+// and the scale-down windows.
+type directionalStableMetricClient interface {
+	DirectionalStableMetrics(metricKey metrics.NamespaceNameMetric, now time.Time, upDuration, downDuration time.Duration) (float64, float64, error)
+}
+
+// windowResizer is the metric client of KPA which can resize its stable and panic windows.
+type windowResizer interface {
+	ResizeWindows(stableDuration, panicDuration time.Duration)
+}
+
 type KpaAutoscaler struct {
 	specMux      sync.RWMutex
 	metricClient metrics.MetricClient
@@ -187,23 +355,22 @@ type KpaAutoscaler struct {
 }
 
 var _ Scaler = (*KpaAutoscaler)(nil)
+var _ RestorableScaler = (*KpaAutoscaler)(nil)
 
 // NewKpaAutoscaler Initialize KpaAutoscaler: Referenced from `knative/pkg/autoscaler/scaling/autoscaler.go newAutoscaler`
 func NewKpaAutoscaler(readyPodsCount int, pa *autoscalingv1alpha1.PodAutoscaler, now time.Time) (*KpaAutoscaler, error) {
+	return NewKpaAutoscalerWithFetcher(readyPodsCount, pa, now, metrics.NewRestMetricsFetcher())
+}
+
+// NewKpaAutoscalerWithFetcher initializes a KpaAutoscaler which fetches the metrics with the given fetcher.
+func NewKpaAutoscalerWithFetcher(readyPodsCount int, pa *autoscalingv1alpha1.PodAutoscaler, now time.Time, metricsFetcher metrics.MetricFetcher) (*KpaAutoscaler, error) {
 	spec, err := NewKpaScalingContextByPa(pa)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create a new delay window based on the ScaleDownDelay specified in the spec
-	if spec.ScaleDownDelay < 0 {
-		return nil, errors.New("ScaleDownDelay must be positive")
-	}
-	var delayWindow *aggregation.TimeWindow
-	// If specify ScaleDownDelay, KpaAutoscaler.delayWindow will be initialized
-	if spec.ScaleDownDelay > 0 {
-		delayWindow = aggregation.NewTimeWindow(spec.ScaleDownDelay, 1*time.Second)
-	}
+	delayWindow := newDelayWindow(spec.ScaleDownDelay)
 
 	// As KNative stated:
 	//   We always start in the panic mode, if the deployment is scaled up over 1 pod.
@@ -219,9 +386,8 @@ func NewKpaAutoscaler(readyPodsCount int, pa *autoscalingv1alpha1.PodAutoscaler,
 		panicTime = time.Time{} // Zero value for time if not in panic mode
 	}
 
-	// TODO missing MetricClient
-	metricsFetcher := metrics.NewRestMetricsFetcher()
-	metricsClient := metrics.NewKPAMetricsClient(metricsFetcher, spec.StableWindow, spec.PanicWindow)
+	spec.warnShortStableWindowDown(pa)
+	metricsClient := metrics.NewKPAMetricsClient(metricsFetcher, spec.stableHistory(), spec.PanicWindow)
 
 	scalingAlgorithm := algorithm.KpaScalingAlgorithm{}
 
@@ -250,11 +416,15 @@ func (k *KpaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 		klog.Error("Failed to convert ScalingContext to KpaScalingContext")
 	}
 
-	kpaMetricsClient := k.metricClient.(*metrics.KPAMetricsClient)
+	kpaMetricsClient, ok := k.metricClient.(stableAndPanicMetricClient)
+	if !ok {
+		klog.Errorf("Metric client of %s does not provide stable and panic metrics", metricKey)
+		return ScaleResult{Reason: "the metric client does not provide stable and panic metrics"}
+	}
 	observedStableValue, observedPanicValue, err := kpaMetricsClient.StableAndPanicMetrics(metricKey, now)
 	if err != nil {
 		klog.Errorf("Failed to get stable and panic metrics for %s: %v", metricKey, err)
-		return ScaleResult{}
+		return ScaleResult{Reason: fmt.Sprintf("failed to get stable and panic metrics: %v", err)}
 	}
 
 	// Old logic:
@@ -271,21 +441,60 @@ func (k *KpaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 	// 1. readyPodsCount now reflects real pods count.
 	// 2. maxScaleUp is at lease to 1 to ensure 0 to 1 activation.
 	// 3. maxScaleDown will remain 0 in case spec.MaxScaleDownRate == 0
-	readyPodsCount := math.Max(0, float64(originalReadyPodsCount))           // A little sanitizing.
-	maxScaleUp := math.Max(1, math.Ceil(spec.MaxScaleUpRate*readyPodsCount)) // Keep scale up non zero
-	maxScaleDown := math.Floor(readyPodsCount / spec.MaxScaleDownRate)       // Make scale down zero-able
-
-	dspc := math.Ceil(observedStableValue / spec.TargetValue)
+	// 4. maxScaleUp always allows one more pod than ready, so that a rate of 1 does not pin 0 or 1 pods.
+	readyPodsCount := math.Max(0, float64(originalReadyPodsCount))                          // A little sanitizing.
+	maxScaleUp := math.Max(readyPodsCount+1, math.Ceil(spec.MaxScaleUpRate*readyPodsCount)) // Keep scale up non zero
+	maxScaleDown := math.Floor(readyPodsCount / spec.MaxScaleDownRate)                      // Make scale down zero-able
+
+	// the stable metric averaged over the scale-up window drives a scale up, and the one averaged over the
+	// scale-down window a scale down. While neither asks for its direction the target keeps its ready pods.
+	upStableValue, downStableValue := observedStableValue, observedStableValue
+	stableWindowUp, stableWindowDown := spec.stableWindows()
+	if directionalClient, ok := k.metricClient.(directionalStableMetricClient); ok {
+		upStableValue, downStableValue, err = directionalClient.DirectionalStableMetrics(metricKey, now, stableWindowUp, stableWindowDown)
+		if err != nil {
+			klog.Errorf("Failed to get directional stable metrics for %s: %v", metricKey, err)
+			return ScaleResult{Reason: fmt.Sprintf("failed to get directional stable metrics: %v", err)}
+		}
+	}
+	dspcUp := math.Ceil(upStableValue / spec.TargetValue)
+	dspcDown := math.Ceil(downStableValue / spec.TargetValue)
+	dspc := readyPodsCount
+	explanation := ScaleExplanation{Value: observedStableValue, Window: spec.StableWindow, TargetValue: spec.TargetValue,
+		RawPodCount: podCount(math.Ceil(observedStableValue / spec.TargetValue))}
+	if dspcUp > readyPodsCount && !spec.withinTolerance(upStableValue, readyPodsCount) {
+		dspc = dspcUp
+		explanation.Value, explanation.Window, explanation.RawPodCount = upStableValue, stableWindowUp, podCount(dspcUp)
+	} else if dspcDown < readyPodsCount && !spec.withinTolerance(downStableValue, readyPodsCount) {
+		dspc = dspcDown
+		explanation.Value, explanation.Window, explanation.RawPodCount = downStableValue, stableWindowDown, podCount(dspcDown)
+	} else if dspcUp > readyPodsCount || dspcDown < readyPodsCount {
+		// the load is within the tolerance of the target.
+		explanation.Keep("tolerance", explanation.RawPodCount, podCount(dspc))
+	} else {
+		// neither directional window asks for its direction.
+		explanation.Keep("directional windows", explanation.RawPodCount, podCount(dspc))
+	}
 	dppc := math.Ceil(observedPanicValue / spec.TargetValue)
 
-	// We want to keep desired pod count in the  [maxScaleDown, maxScaleUp] range.
+	// We want to keep desired pod count in the  [maxScaleDown, maxScaleUp] range. The panic mode never scales down,
+	// so the panic pod count is only kept under maxScaleUp: a burst can not grow the target faster than a steady load.
 	desiredStablePodCount := int32(math.Min(math.Max(dspc, maxScaleDown), maxScaleUp))
-	desiredPanicPodCount := int32(math.Min(math.Max(dppc, maxScaleDown), maxScaleUp))
+	desiredPanicPodCount := int32(math.Min(dppc, maxScaleUp))
+	stableRateLimit := ""
+	if dspc > maxScaleUp {
+		stableRateLimit = rateRule("maxScaleUp", spec.MaxScaleUpRate)
+	} else if dspc < maxScaleDown {
+		stableRateLimit = rateRule("maxScaleDown", spec.MaxScaleDownRate)
+	}
+	explanation.Adjust(stableRateLimit, podCount(dspc), desiredStablePodCount)
+	recommendedPodCount, rateLimit := podCount(dspc), stableRateLimit
 
 	//	If ActivationScale > 1, then adjust the desired pod counts
 	if k.scalingContext.ActivationScale > 1 {
 		// ActivationScale only makes sense if activated (desired > 0)
 		if k.scalingContext.ActivationScale > desiredStablePodCount && desiredStablePodCount > 0 {
+			explanation.Adjust("activationScale", desiredStablePodCount, k.scalingContext.ActivationScale)
 			desiredStablePodCount = k.scalingContext.ActivationScale
 		}
 		if k.scalingContext.ActivationScale > desiredPanicPodCount && desiredPanicPodCount > 0 {
@@ -299,8 +508,9 @@ func (k *KpaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 	klog.V(4).InfoS("--- KPA Details", "readyPodsCount", readyPodsCount,
 		"MaxScaleUpRate", spec.MaxScaleUpRate, "MaxScaleDownRate", spec.MaxScaleDownRate,
 		"TargetValue", spec.TargetValue, "PanicThreshold", spec.PanicThreshold,
-		"StableWindow", spec.StableWindow, "PanicWindow", spec.PanicWindow, "ScaleDownDelay", spec.ScaleDownDelay,
-		"dppc", dppc, "dspc", dspc, "desiredStablePodCount", desiredStablePodCount,
+		"StableWindow", spec.StableWindow, "StableWindowUp", stableWindowUp, "StableWindowDown", stableWindowDown,
+		"PanicWindow", spec.PanicWindow, "ScaleDownDelay", spec.ScaleDownDelay, "Tolerance", spec.Tolerance,
+		"dppc", dppc, "dspc", dspc, "dspcUp", dspcUp, "dspcDown", dspcDown, "desiredStablePodCount", desiredStablePodCount,
 		"PanicThreshold", spec.PanicThreshold, "isOverPanicThreshold", isOverPanicThreshold,
 	)
 
@@ -329,7 +539,15 @@ func (k *KpaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 		// so pick the larger of the two.
 		klog.InfoS("Operating in panic mode.", "desiredPodCount", desiredPodCount, "desiredPanicPodCount", desiredPanicPodCount)
 		if desiredPodCount < desiredPanicPodCount {
+			explanation.Adjust("panic window", desiredPodCount, desiredPanicPodCount)
 			desiredPodCount = desiredPanicPodCount
+			recommendedPodCount, rateLimit = podCount(dppc), ""
+			if dppc > maxScaleUp {
+				rateLimit = rateRule("maxScaleUp", spec.MaxScaleUpRate)
+			}
+		} else if dspc < maxScaleDown {
+			// the panic mode holds the pods itself, the scale down rate does not limit it.
+			rateLimit = ""
 		}
 		// We do not scale down while in panic mode. Only increases will be applied.
 		if desiredPodCount > k.maxPanicPods {
@@ -338,10 +556,15 @@ func (k *KpaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 		} else if desiredPodCount < k.maxPanicPods {
 			klog.InfoS("Skipping pod count decrease", "current", k.maxPanicPods, "desired", desiredPodCount)
 		}
+		explanation.Keep("panic mode", desiredPodCount, k.maxPanicPods)
 		desiredPodCount = k.maxPanicPods
 	} else {
 		klog.V(4).InfoS("Operating in stable mode.", "desiredPodCount", desiredPodCount)
 	}
+	if rateLimit != "" {
+		klog.V(2).InfoS("Scale rate limit applied", "metric", metricKey, "rateLimit", rateLimit,
+			"recommendedPodCount", recommendedPodCount, "readyPodsCount", originalReadyPodsCount, "adjustedTo", desiredPodCount)
+	}
 
 	// Delay scale down decisions, if a ScaleDownDelay was specified.
 	// We only do this if there's a non-nil delayWindow because although a
@@ -357,13 +580,14 @@ func (k *KpaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 		delayedPodCount, err := k.delayWindow.Max()
 		if err != nil {
 			klog.ErrorS(err, "Failed to get delayed pod count")
-			return ScaleResult{}
+			return ScaleResult{Reason: fmt.Sprintf("failed to get delayed pod count: %v", err)}
 		}
 		if int32(delayedPodCount) != desiredPodCount {
 			klog.InfoS(
 				fmt.Sprintf("Delaying scale to %d, staying at %d", int(desiredPodCount), int(delayedPodCount)),
 				"desiredPodCount", desiredPodCount, "delayedPodCount", delayedPodCount,
 			)
+			explanation.Keep("scaleDownDelay", desiredPodCount, int32(delayedPodCount))
 			desiredPodCount = int32(delayedPodCount)
 		}
 	} else {
@@ -390,13 +614,21 @@ func (k *KpaAutoscaler) Scale(originalReadyPodsCount int, metricKey metrics.Name
 		DesiredPodCount:     desiredPodCount,
 		ExcessBurstCapacity: int32(excessBCF),
 		ScaleValid:          true,
+		ObservedValue:       observedPanicValue,
+		StableValue:         observedStableValue,
+		PanicValue:          observedPanicValue,
+		InPanicMode:         k.InPanicMode(),
+		Explanation:         explanation,
+		RecommendedPodCount: recommendedPodCount,
+		RateLimit:           rateLimit,
 	}
 }
 
 func (k *KpaAutoscaler) UpdateScaleTargetMetrics(ctx context.Context, metricKey metrics.NamespaceNameMetric, source autoscalingv1alpha1.MetricSource, pods []v1.Pod, now time.Time) error {
-	activePods := utils.FilterActivePods(pods)
-	metricValues, err := k.metricClient.GetMetricsFromPods(ctx, activePods, source)
-	if err != nil {
+	// the pods which are not ready to serve are skipped by the scrape.
+	metricValues, err := k.metricClient.GetMetricsFromPods(ctx, pods, source)
+	var partial *metrics.PartialMetricsError
+	if err != nil && !errors.As(err, &partial) {
 		return err
 	}
 
@@ -405,6 +637,10 @@ func (k *KpaAutoscaler) UpdateScaleTargetMetrics(ctx context.Context, metricKey
 		return err
 	}
 
+	// the metrics of the scraped pods are recorded, the caller decides how to scale with the missing ones.
+	if partial != nil {
+		return partial
+	}
 	return nil
 }
 
@@ -417,33 +653,55 @@ func (k *KpaAutoscaler) UpdateSourceMetrics(ctx context.Context, metricKey metri
 	return k.metricClient.UpdateMetrics(now, metricKey, metricValue)
 }
 
+func (k *KpaAutoscaler) UpdateMetrics(metricKey metrics.NamespaceNameMetric, now time.Time, metricValues ...float64) error {
+	return k.metricClient.UpdateMetrics(now, metricKey, metricValues...)
+}
+
 func (k *KpaAutoscaler) UpdateScalingContext(pa autoscalingv1alpha1.PodAutoscaler) error {
 	k.specMux.Lock()
 	defer k.specMux.Unlock()
 	// update context and check configuration restraint.
-	// N.B. for now, we forbid update the config related to the stateful attribute, like window length.
+	// N.B. the stable and panic windows are resized in place, the delay window is recreated.
 	updatedSpec, err := NewKpaScalingContextByPa(&pa)
 	if err != nil {
 		return err
 	}
-	// check kpa spec: panic window, stable window and delaywindow
+	// check kpa spec: panic window, stable window and delay window
 	rawSpec := k.scalingContext
-	if updatedSpec.PanicWindow != rawSpec.PanicWindow {
-		klog.Warningf("For KPA, updating the PanicWindow (%v) is not allowed. Keep the original value (%v)", updatedSpec.PanicWindow, rawSpec.PanicWindow)
-		updatedSpec.PanicWindow = rawSpec.PanicWindow
+	if updatedSpec.PanicWindow != rawSpec.PanicWindow || updatedSpec.stableHistory() != rawSpec.stableHistory() {
+		client, ok := k.metricClient.(windowResizer)
+		if ok {
+			klog.InfoS("Resize KPA metric windows", "stableWindow", updatedSpec.stableHistory(), "panicWindow", updatedSpec.PanicWindow)
+			client.ResizeWindows(updatedSpec.stableHistory(), updatedSpec.PanicWindow)
+		} else {
+			klog.Warningf("For KPA, the metric client can not resize its windows. Keep the original StableWindow (%v) and PanicWindow (%v)", rawSpec.StableWindow, rawSpec.PanicWindow)
+			updatedSpec.PanicWindow = rawSpec.PanicWindow
+			updatedSpec.StableWindow = rawSpec.StableWindow
+			updatedSpec.StableWindowUp = rawSpec.StableWindowUp
+			updatedSpec.StableWindowDown = rawSpec.StableWindowDown
+		}
 	}
-	if updatedSpec.StableWindow != rawSpec.StableWindow {
-		klog.Warningf("For KPA, updating the StableWindow (%v) is not allowed. Keep the original value (%v)", updatedSpec.StableWindow, rawSpec.StableWindow)
-		updatedSpec.StableWindow = rawSpec.StableWindow
+	up, down := updatedSpec.stableWindows()
+	if rawUp, rawDown := rawSpec.stableWindows(); up != rawUp || down != rawDown {
+		updatedSpec.warnShortStableWindowDown(&pa)
 	}
 	if updatedSpec.ScaleDownDelay != rawSpec.ScaleDownDelay {
-		klog.Warningf("For KPA, updating the ScaleDownDelay (%v) is not allowed. Keep the original value (%v)", updatedSpec.ScaleDownDelay, rawSpec.ScaleDownDelay)
-		updatedSpec.ScaleDownDelay = rawSpec.ScaleDownDelay
+		// the recommendations delayed so far are dropped, the new delay applies from now on.
+		klog.InfoS("Update KPA scale down delay", "scaleDownDelay", updatedSpec.ScaleDownDelay, "previous", rawSpec.ScaleDownDelay)
+		k.delayWindow = newDelayWindow(updatedSpec.ScaleDownDelay)
 	}
 	k.scalingContext = updatedSpec
 	return nil
 }
 
+// newDelayWindow returns the window a scale down is delayed over, nil without a ScaleDownDelay.
+func newDelayWindow(scaleDownDelay time.Duration) *aggregation.TimeWindow {
+	if scaleDownDelay <= 0 {
+		return nil
+	}
+	return aggregation.NewTimeWindow(scaleDownDelay, 1*time.Second)
+}
+
 func (k *KpaAutoscaler) GetScalingContext() scalingcontext.ScalingContext {
 	k.specMux.Lock()
 	defer k.specMux.Unlock()
@@ -457,3 +715,32 @@ func (k *KpaAutoscaler) InPanicMode() bool {
 
 	return !k.panicTime.IsZero()
 }
+
+// PanicState implements RestorableScaler in KpaAutoscaler.
+func (k *KpaAutoscaler) PanicState() PanicState {
+	k.specMux.Lock()
+	defer k.specMux.Unlock()
+	if k.panicTime.IsZero() {
+		return PanicState{}
+	}
+	return PanicState{PanicTime: k.panicTime, MaxPanicPods: k.maxPanicPods}
+}
+
+// RestoreState implements RestorableScaler in KpaAutoscaler. The restored panic mode exits as it would have without
+// the restart, one stable window after the panic threshold was last exceeded, and still holds the pods the KPA
+// started holding. The recommendations delay the scale downs as the ones recorded before the restart did.
+func (k *KpaAutoscaler) RestoreState(state PanicState, recommendations []Recommendation) {
+	k.specMux.Lock()
+	defer k.specMux.Unlock()
+	if !state.PanicTime.IsZero() {
+		k.panicTime = state.PanicTime
+		if state.MaxPanicPods > k.maxPanicPods {
+			k.maxPanicPods = state.MaxPanicPods
+		}
+	}
+	if k.delayWindow != nil {
+		for _, recommendation := range recommendations {
+			k.delayWindow.Record(recommendation.Time, float64(recommendation.PodCount))
+		}
+	}
+}
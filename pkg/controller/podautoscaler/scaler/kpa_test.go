@@ -19,6 +19,8 @@ package scaler
 import (
 	"flag"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -49,12 +51,14 @@ func TestKpaScale(t *testing.T) {
 			TargetValue:      10,
 			TotalValue:       500,
 		},
-		TargetBurstCapacity: 2.0,
-		ActivationScale:     2,
-		PanicThreshold:      2.0,
-		StableWindow:        60 * time.Second,
-		PanicWindow:         10 * time.Second,
-		ScaleDownDelay:      30 * time.Minute,
+		KpaConfig: KpaConfig{
+			TargetBurstCapacity: 2.0,
+			ActivationScale:     2,
+			PanicThreshold:      2.0,
+			StableWindow:        60 * time.Second,
+			PanicWindow:         10 * time.Second,
+			ScaleDownDelay:      30 * time.Minute,
+		},
 	}
 	metricsFetcher := metrics.NewRestMetricsFetcher()
 	kpaMetricsClient := metrics.NewKPAMetricsClient(metricsFetcher, spec.StableWindow, spec.PanicWindow)
@@ -358,3 +362,454 @@ func TestKpaScale2(t *testing.T) {
 	}
 
 }
+
+func newTestKpaPodAutoscaler(annotations map[string]string) *v1alpha1.PodAutoscaler {
+	return &v1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+		Spec: v1alpha1.PodAutoscalerSpec{
+			MetricsSources: []v1alpha1.MetricSource{{
+				MetricSourceType: v1alpha1.POD,
+				ProtocolType:     v1alpha1.HTTP,
+				TargetMetric:     "ttot",
+				TargetValue:      "10",
+			}},
+		},
+	}
+}
+
+// newDirectionalKpa returns a KPA out of panic mode and without scale down delay, which averages the stable metric
+// over the given scale-up and scale-down windows.
+func newDirectionalKpa(t *testing.T, up, down string) *KpaAutoscaler {
+	pa := newTestKpaPodAutoscaler(map[string]string{
+		stableWindowUpLabel:   up,
+		stableWindowDownLabel: down,
+	})
+	spec := NewKpaScalingContext()
+	if err := spec.UpdateByPaTypes(pa); err != nil {
+		t.Fatalf("UpdateByPaTypes() failed: %v", err)
+	}
+	spec.MaxScaleUpRate = 2
+	spec.MaxScaleDownRate = 2
+	spec.PanicThreshold = 1000
+	return &KpaAutoscaler{
+		metricClient:   metrics.NewKPAMetricsClient(metrics.NewRestMetricsFetcher(), spec.stableHistory(), spec.PanicWindow),
+		algorithm:      &algorithm.KpaScalingAlgorithm{},
+		scalingContext: spec,
+	}
+}
+
+// replayTrace records a sample per second, the value of each phase for its duration in seconds, and returns the
+// time of the last sample.
+func replayTrace(kpa *KpaAutoscaler, start time.Time, phases ...[2]float64) time.Time {
+	now := start
+	for _, phase := range phases {
+		for i := 0; i < int(phase[1]); i++ {
+			_ = kpa.UpdateMetrics(metrics.NamespaceNameMetric{}, now, phase[0])
+			now = now.Add(time.Second)
+		}
+	}
+	return now.Add(-time.Second)
+}
+
+func TestKpaDirectionalStableWindows(t *testing.T) {
+	start := time.Unix(1000, 0)
+	testCases := []struct {
+		name                string
+		up, down            string
+		readyPods           int
+		trace               [][2]float64
+		expectedDesiredPods int32
+	}{
+		{
+			// the ramp of the last 10s averages 95 on the scale-up window.
+			name: "fast scale up", up: "10s", down: "120s", readyPods: 5,
+			trace:               [][2]float64{{50, 120}, {100, 10}},
+			expectedDesiredPods: 10,
+		},
+		{
+			// the ramp is diluted to an average of 54 over a single 120s window.
+			name: "single window scale up", up: "120s", down: "120s", readyPods: 5,
+			trace:               [][2]float64{{50, 120}, {100, 10}},
+			expectedDesiredPods: 6,
+		},
+		{
+			// the decay of the last 30s averages 80 on the scale-down window.
+			name: "slow scale down", up: "10s", down: "120s", readyPods: 10,
+			trace:               [][2]float64{{100, 120}, {20, 30}},
+			expectedDesiredPods: 9,
+		},
+		{
+			// the decay averages 20 over a single 10s window, bounded by the max scale down rate.
+			name: "single window scale down", up: "10s", down: "10s", readyPods: 10,
+			trace:               [][2]float64{{100, 120}, {20, 30}},
+			expectedDesiredPods: 5,
+		},
+		{
+			// the scale-up window asks for 4 pods and the scale-down one for 8, neither changes the 5 ready pods.
+			name: "conflicting windows hold", up: "10s", down: "120s", readyPods: 5,
+			trace:               [][2]float64{{80, 110}, {30, 10}},
+			expectedDesiredPods: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kpa := newDirectionalKpa(t, tc.up, tc.down)
+			now := replayTrace(kpa, start, tc.trace...)
+			result := kpa.Scale(tc.readyPods, metrics.NamespaceNameMetric{}, now)
+			if !result.ScaleValid || result.DesiredPodCount != tc.expectedDesiredPods {
+				t.Errorf("expected %d desired pods, got %+v", tc.expectedDesiredPods, result)
+			}
+		})
+	}
+}
+
+func TestKpaStableWindowsDefaults(t *testing.T) {
+	spec := NewKpaScalingContext()
+	pa := newTestKpaPodAutoscaler(map[string]string{
+		stableWindowLabel:   "30s",
+		stableWindowUpLabel: "90s",
+	})
+	if err := spec.UpdateByPaTypes(pa); err != nil {
+		t.Fatalf("UpdateByPaTypes() failed: %v", err)
+	}
+	// the unset scale-down window defaults to the stable window, the samples cover the longest window.
+	if up, down := spec.stableWindows(); up != 90*time.Second || down != 30*time.Second {
+		t.Errorf("expected windows of 90s up and 30s down, got %v and %v", up, down)
+	}
+	if history := spec.stableHistory(); history != 90*time.Second {
+		t.Errorf("expected a stable history of 90s, got %v", history)
+	}
+
+	for _, value := range []string{"0s", "-10s", "fast"} {
+		pa.Annotations[stableWindowDownLabel] = value
+		if err := NewKpaScalingContext().UpdateByPaTypes(pa); err == nil {
+			t.Errorf("expected stable-window-down %q to be rejected", value)
+		}
+	}
+}
+
+// TestKpaScaleExplanation tests that the explanation of the KPA suggestion records the metric value compared with the
+// target, the raw pod count, and the rules which adjusted it in the order they were applied.
+func TestKpaScaleExplanation(t *testing.T) {
+	readyPodCount := 4
+	now := time.Now()
+	testCases := []struct {
+		name             string
+		value            float64
+		delayedPodCount  float64
+		expectedPodCount int32
+		expected         ScaleExplanation
+	}{
+		{
+			// 100 is 10 pods at the target of 10, the 4 ready pods can only double.
+			name:             "capped by the scale up rate",
+			value:            100,
+			expectedPodCount: 8,
+			expected: ScaleExplanation{Value: 100, Window: 60 * time.Second, TargetValue: 10, RawPodCount: 10,
+				Adjustments: []ScaleAdjustment{{Rule: "maxScaleUp 2x", Kind: AdjustmentCapped, PodCount: 8}}},
+		},
+		{
+			name:             "held by the scale down delay",
+			value:            20,
+			delayedPodCount:  6,
+			expectedPodCount: 6,
+			expected: ScaleExplanation{Value: 20, Window: 60 * time.Second, TargetValue: 10, RawPodCount: 2,
+				Adjustments: []ScaleAdjustment{{Rule: "scaleDownDelay", Kind: AdjustmentKept, PodCount: 6}}},
+		},
+		{
+			name:             "within the rate limits",
+			value:            30,
+			expectedPodCount: 3,
+			expected:         ScaleExplanation{Value: 30, Window: 60 * time.Second, TargetValue: 10, RawPodCount: 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := KpaScalingContext{
+				BaseScalingContext: scalingcontext.BaseScalingContext{
+					MaxScaleUpRate:   2,
+					MaxScaleDownRate: 2,
+					TargetValue:      10,
+					TotalValue:       500,
+				},
+				KpaConfig: KpaConfig{
+					ActivationScale: 1,
+					PanicThreshold:  2.0,
+					StableWindow:    60 * time.Second,
+					PanicWindow:     10 * time.Second,
+					ScaleDownDelay:  30 * time.Minute,
+				},
+			}
+			kpaMetricsClient := metrics.NewKPAMetricsClient(metrics.NewRestMetricsFetcher(), spec.StableWindow, spec.PanicWindow)
+			_ = kpaMetricsClient.UpdateMetricIntoWindow(now, tc.value)
+			delayWindow := aggregation.NewTimeWindow(spec.ScaleDownDelay, time.Second)
+			if tc.delayedPodCount > 0 {
+				delayWindow.Record(now.Add(-time.Minute), tc.delayedPodCount)
+			}
+			kpaScaler := KpaAutoscaler{
+				metricClient:   kpaMetricsClient,
+				maxPanicPods:   int32(readyPodCount),
+				delayWindow:    delayWindow,
+				algorithm:      &algorithm.KpaScalingAlgorithm{},
+				scalingContext: &spec,
+			}
+
+			result := kpaScaler.Scale(readyPodCount, metrics.NamespaceNameMetric{MetricName: "ttot"}, now)
+			if result.DesiredPodCount != tc.expectedPodCount {
+				t.Errorf("expected %d pods, got %d", tc.expectedPodCount, result.DesiredPodCount)
+			}
+			if !reflect.DeepEqual(result.Explanation, tc.expected) {
+				t.Errorf("expected the explanation %+v, got %+v", tc.expected, result.Explanation)
+			}
+			if podCount := result.Explanation.PodCount(); podCount != result.DesiredPodCount {
+				t.Errorf("expected the explanation to end at %d pods, got %d", result.DesiredPodCount, podCount)
+			}
+		})
+	}
+}
+
+// TestKpaScaleRateLimits tests that the scale rate limits truncate the recommendation of the metric, still let the
+// target grow from 0 or 1 pod, and that the panic mode bypasses the scale down limit but not the scale up one.
+func TestKpaScaleRateLimits(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name                string
+		readyPods           int
+		value               float64
+		maxScaleUpRate      float64
+		panicking           bool
+		expectedPodCount    int32
+		expectedRecommended int32
+		expectedRateLimit   string
+	}{
+		{
+			// a drop to 2 pods at the target of 10 can only halve the 20 ready pods.
+			name: "scale down truncated", readyPods: 20, value: 20, maxScaleUpRate: 2,
+			expectedPodCount: 10, expectedRecommended: 2, expectedRateLimit: "maxScaleDown 2x",
+		},
+		{
+			name: "within the rate limits", readyPods: 4, value: 60, maxScaleUpRate: 2,
+			expectedPodCount: 6, expectedRecommended: 6,
+		},
+		{
+			name: "scale up from zero", readyPods: 0, value: 50, maxScaleUpRate: 2,
+			expectedPodCount: 1, expectedRecommended: 5, expectedRateLimit: "maxScaleUp 2x",
+		},
+		{
+			// a rate of 1 still grows the target by one pod.
+			name: "scale up from one", readyPods: 1, value: 50, maxScaleUpRate: 1,
+			expectedPodCount: 2, expectedRecommended: 5, expectedRateLimit: "maxScaleUp 1x",
+		},
+		{
+			name: "panic mode truncates a scale up", readyPods: 4, value: 100, maxScaleUpRate: 2, panicking: true,
+			expectedPodCount: 8, expectedRecommended: 10, expectedRateLimit: "maxScaleUp 2x",
+		},
+		{
+			// the panic mode holds the 20 ready pods, the scale down rate does not apply.
+			name: "panic mode bypasses the scale down limit", readyPods: 20, value: 20, maxScaleUpRate: 2, panicking: true,
+			expectedPodCount: 20, expectedRecommended: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := KpaScalingContext{
+				BaseScalingContext: scalingcontext.BaseScalingContext{
+					MaxScaleUpRate:   tc.maxScaleUpRate,
+					MaxScaleDownRate: 2,
+					TargetValue:      10,
+					TotalValue:       500,
+				},
+				KpaConfig: KpaConfig{
+					ActivationScale: 1,
+					PanicThreshold:  2.0,
+					StableWindow:    60 * time.Second,
+					PanicWindow:     10 * time.Second,
+				},
+			}
+			kpaMetricsClient := metrics.NewKPAMetricsClient(metrics.NewRestMetricsFetcher(), spec.StableWindow, spec.PanicWindow)
+			_ = kpaMetricsClient.UpdateMetricIntoWindow(now, tc.value)
+			kpaScaler := KpaAutoscaler{
+				metricClient:   kpaMetricsClient,
+				algorithm:      &algorithm.KpaScalingAlgorithm{},
+				scalingContext: &spec,
+			}
+			if tc.panicking {
+				kpaScaler.panicTime = now
+				kpaScaler.maxPanicPods = int32(tc.readyPods)
+			}
+
+			result := kpaScaler.Scale(tc.readyPods, metrics.NamespaceNameMetric{MetricName: "ttot"}, now)
+			if result.DesiredPodCount != tc.expectedPodCount {
+				t.Errorf("expected %d pods, got %d", tc.expectedPodCount, result.DesiredPodCount)
+			}
+			if result.RecommendedPodCount != tc.expectedRecommended || result.RateLimit != tc.expectedRateLimit {
+				t.Errorf("expected %d recommended pods limited by %q, got %d limited by %q",
+					tc.expectedRecommended, tc.expectedRateLimit, result.RecommendedPodCount, result.RateLimit)
+			}
+		})
+	}
+}
+
+func TestKpaScaleRatesValidation(t *testing.T) {
+	for _, value := range []string{"0.5", "0", "-2", "Inf", "NaN", "double"} {
+		for _, key := range []string{scalingcontext.MaxScaleUpRateLabel, scalingcontext.MaxScaleDownRateLabel} {
+			if err := NewKpaScalingContext().UpdateByPaTypes(newTestKpaPodAutoscaler(map[string]string{key: value})); err == nil {
+				t.Errorf("expected %s %q to be rejected", key, value)
+			}
+		}
+	}
+	spec := NewKpaScalingContext()
+	if err := spec.UpdateByPaTypes(newTestKpaPodAutoscaler(map[string]string{scalingcontext.MaxScaleUpRateLabel: "1"})); err != nil {
+		t.Fatalf("UpdateByPaTypes() failed: %v", err)
+	}
+	if spec.MaxScaleUpRate != 1 {
+		t.Errorf("expected a max scale up rate of 1, got %v", spec.MaxScaleUpRate)
+	}
+}
+
+func TestParseKpaConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    func(config *KpaConfig)
+		expectErr   string
+	}{
+		{
+			name:     "defaults",
+			expected: func(config *KpaConfig) {},
+		},
+		{
+			name: "tuned",
+			annotations: map[string]string{
+				stableWindowLabel:          "2m",
+				panicWindowPercentageLabel: "25",
+				panicThresholdLabel:        "1.5",
+				targetBurstCapacityLabel:   "-1",
+				scaleDownDelayLabel:        "0s",
+				toleranceLabel:             "0.1",
+			},
+			expected: func(config *KpaConfig) {
+				config.StableWindow = 2 * time.Minute
+				config.PanicWindow = 30 * time.Second
+				config.PanicThreshold = 1.5
+				config.TargetBurstCapacity = -1
+				config.ScaleDownDelay = 0
+				config.Tolerance = 0.1
+			},
+		},
+		{name: "unparseable duration", annotations: map[string]string{stableWindowLabel: "1 minute"}, expectErr: stableWindowLabel},
+		{name: "zero window", annotations: map[string]string{panicWindowLabel: "0s"}, expectErr: panicWindowLabel},
+		{name: "window beyond an hour", annotations: map[string]string{stableWindowUpLabel: "2h"}, expectErr: stableWindowUpLabel},
+		{name: "negative delay", annotations: map[string]string{scaleDownDelayLabel: "-1m"}, expectErr: scaleDownDelayLabel},
+		{name: "panic threshold below 1", annotations: map[string]string{panicThresholdLabel: "0.5"}, expectErr: panicThresholdLabel},
+		{name: "panic threshold above 10", annotations: map[string]string{panicThresholdLabel: "11"}, expectErr: panicThresholdLabel},
+		{name: "NaN panic threshold", annotations: map[string]string{panicThresholdLabel: "NaN"}, expectErr: panicThresholdLabel},
+		{name: "negative burst capacity", annotations: map[string]string{targetBurstCapacityLabel: "-2"}, expectErr: targetBurstCapacityLabel},
+		{name: "zero activation scale", annotations: map[string]string{activationScaleLabel: "0"}, expectErr: activationScaleLabel},
+		{name: "tolerance of 1", annotations: map[string]string{toleranceLabel: "1"}, expectErr: toleranceLabel},
+		{name: "negative tolerance", annotations: map[string]string{toleranceLabel: "-0.1"}, expectErr: toleranceLabel},
+		{name: "panic window percentage above 100", annotations: map[string]string{panicWindowPercentageLabel: "150"}, expectErr: panicWindowPercentageLabel},
+		{
+			name:        "panic window and percentage",
+			annotations: map[string]string{panicWindowLabel: "10s", panicWindowPercentageLabel: "10"},
+			expectErr:   "cannot be set with",
+		},
+		{
+			name:        "panic window longer than the stable window",
+			annotations: map[string]string{stableWindowLabel: "30s", panicWindowLabel: "1m"},
+			expectErr:   "must not be longer than the stable window",
+		},
+		{
+			// every invalid annotation is reported.
+			name:        "several invalid annotations",
+			annotations: map[string]string{stableWindowLabel: "-1s", toleranceLabel: "2"},
+			expectErr:   toleranceLabel,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := ParseKpaConfig(tc.annotations)
+			if tc.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+					t.Fatalf("expected an error containing %q, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKpaConfig() failed: %v", err)
+			}
+			expected := DefaultKpaConfig()
+			tc.expected(&expected)
+			if config != expected {
+				t.Errorf("expected config %+v, got %+v", expected, config)
+			}
+		})
+	}
+}
+
+func TestKpaScaleTolerance(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		name             string
+		value            float64
+		tolerance        float64
+		expectedPodCount int32
+	}{
+		{name: "scale up without tolerance", value: 54, expectedPodCount: 6},
+		{name: "scale up within the tolerance", value: 54, tolerance: 0.1, expectedPodCount: 5},
+		{name: "scale up beyond the tolerance", value: 60, tolerance: 0.1, expectedPodCount: 6},
+		{name: "scale down within the tolerance", value: 39, tolerance: 0.25, expectedPodCount: 5},
+		{name: "scale down beyond the tolerance", value: 30, tolerance: 0.25, expectedPodCount: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pa := newTestKpaPodAutoscaler(map[string]string{
+				toleranceLabel:      fmt.Sprint(tc.tolerance),
+				panicThresholdLabel: "10",
+				scaleDownDelayLabel: "0s",
+			})
+			kpa, err := NewKpaAutoscaler(0, pa, now)
+			if err != nil {
+				t.Fatalf("NewKpaAutoscaler() failed: %v", err)
+			}
+			_ = kpa.UpdateMetrics(metrics.NamespaceNameMetric{}, now, tc.value)
+			result := kpa.Scale(5, metrics.NamespaceNameMetric{}, now)
+			if !result.ScaleValid || result.DesiredPodCount != tc.expectedPodCount {
+				t.Errorf("expected %d desired pods, got %+v", tc.expectedPodCount, result)
+			}
+		})
+	}
+}
+
+func TestKpaUpdateScalingContextAppliesAnnotationChanges(t *testing.T) {
+	now := time.Now()
+	pa := newTestKpaPodAutoscaler(map[string]string{scaleDownDelayLabel: "5m", panicThresholdLabel: "2"})
+	kpa, err := NewKpaAutoscaler(0, pa, now)
+	if err != nil {
+		t.Fatalf("NewKpaAutoscaler() failed: %v", err)
+	}
+
+	pa.Annotations = map[string]string{scaleDownDelayLabel: "0s", panicThresholdLabel: "3", toleranceLabel: "0.2"}
+	if err := kpa.UpdateScalingContext(*pa); err != nil {
+		t.Fatalf("UpdateScalingContext() failed: %v", err)
+	}
+	spec := kpa.GetScalingContext().(*KpaScalingContext)
+	if spec.PanicThreshold != 3 || spec.Tolerance != 0.2 || spec.ScaleDownDelay != 0 {
+		t.Errorf("expected the updated annotations to apply, got %+v", spec.KpaConfig)
+	}
+	if kpa.delayWindow != nil {
+		t.Errorf("expected no delay window without a scale down delay, got %s", kpa.delayWindow)
+	}
+
+	// an invalid annotation is rejected and the last valid configuration is kept.
+	pa.Annotations[panicThresholdLabel] = "20"
+	if err := kpa.UpdateScalingContext(*pa); err == nil {
+		t.Fatalf("expected the out of range panic threshold to be rejected")
+	}
+	if spec := kpa.GetScalingContext().(*KpaScalingContext); spec.PanicThreshold != 3 {
+		t.Errorf("expected the panic threshold of 3 to be kept, got %v", spec.PanicThreshold)
+	}
+}
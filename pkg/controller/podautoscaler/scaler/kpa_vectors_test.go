@@ -0,0 +1,151 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+// kpaVector is a reference trace of the KPA algorithm, the expected values are hand-computed
+// following the knative-serving autoscaler (pkg/autoscaler/scaling/autoscaler.go).
+type kpaVector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Spec        struct {
+		TargetValue string            `json:"targetValue"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"spec"`
+	// InitialReadyPods is the ready pods count the autoscaler is created with.
+	InitialReadyPods int             `json:"initialReadyPods"`
+	Steps            []kpaVectorStep `json:"steps"`
+}
+
+type kpaVectorStep struct {
+	// Offset is the time of the step relative to the creation of the autoscaler, e.g. "1500ms".
+	Offset                      string  `json:"offset"`
+	ReadyPods                   int     `json:"readyPods"`
+	Stable                      float64 `json:"stable"`
+	Panic                       float64 `json:"panic"`
+	ExpectedDesiredPods         int32   `json:"expectedDesiredPods"`
+	ExpectedPanic               bool    `json:"expectedPanic"`
+	ExpectedExcessBurstCapacity *int32  `json:"expectedExcessBurstCapacity,omitempty"`
+}
+
+// vectorMetricClient replays the observed stable and panic values of a vector step.
+type vectorMetricClient struct {
+	metrics.MetricClient
+	stableValue, panicValue float64
+}
+
+func (c *vectorMetricClient) StableAndPanicMetrics(metricKey metrics.NamespaceNameMetric, now time.Time) (float64, float64, error) {
+	return c.stableValue, c.panicValue, nil
+}
+
+func loadKpaVectors(t *testing.T) []kpaVector {
+	data, err := os.ReadFile(filepath.Join("testdata", "kpa_vectors.json"))
+	if err != nil {
+		t.Fatalf("failed to read kpa vectors: %v", err)
+	}
+	var vectors []kpaVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("failed to parse kpa vectors: %v", err)
+	}
+	return vectors
+}
+
+func newVectorPodAutoscaler(vector kpaVector) *v1alpha1.PodAutoscaler {
+	return &v1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "test_ns",
+			Annotations: vector.Spec.Annotations,
+		},
+		Spec: v1alpha1.PodAutoscalerSpec{
+			ScaleTargetRef: corev1.ObjectReference{
+				Kind: "Deployment",
+				Name: vector.Name,
+			},
+			MetricsSources: []v1alpha1.MetricSource{
+				{
+					MetricSourceType: v1alpha1.POD,
+					ProtocolType:     v1alpha1.HTTP,
+					TargetMetric:     "ttot",
+					TargetValue:      vector.Spec.TargetValue,
+				},
+			},
+			ScalingStrategy: v1alpha1.KPA,
+		},
+	}
+}
+
+// TestKpaVectors replays the reference traces in testdata/kpa_vectors.json through KpaAutoscaler.Scale
+// and reports the first divergent step of every vector.
+func TestKpaVectors(t *testing.T) {
+	// Align the start to a second, the delay window buckets decisions by second.
+	start := time.Unix(1700000000, 0)
+
+	for _, vector := range loadKpaVectors(t) {
+		t.Run(vector.Name, func(t *testing.T) {
+			pa := newVectorPodAutoscaler(vector)
+			metricKey, _, err := metrics.NewNamespaceNameMetric(pa)
+			if err != nil {
+				t.Fatalf("NewNamespaceNameMetric() failed: %v", err)
+			}
+			kpaScaler, err := NewKpaAutoscaler(vector.InitialReadyPods, pa, start)
+			if err != nil {
+				t.Fatalf("NewKpaAutoscaler() failed: %v", err)
+			}
+			client := &vectorMetricClient{}
+			kpaScaler.metricClient = client
+
+			for i, step := range vector.Steps {
+				offset, err := time.ParseDuration(step.Offset)
+				if err != nil {
+					t.Fatalf("step %d: invalid offset %q: %v", i, step.Offset, err)
+				}
+				client.stableValue, client.panicValue = step.Stable, step.Panic
+
+				result := kpaScaler.Scale(step.ReadyPods, metricKey, start.Add(offset))
+				inPanic := kpaScaler.InPanicMode()
+
+				diverged := !result.ScaleValid || result.DesiredPodCount != step.ExpectedDesiredPods || inPanic != step.ExpectedPanic ||
+					(step.ExpectedExcessBurstCapacity != nil && result.ExcessBurstCapacity != *step.ExpectedExcessBurstCapacity)
+				if diverged {
+					t.Fatalf("vector %q (%s) diverged at step %d/%d\n"+
+						"  spec: targetValue=%s annotations=%v initialReadyPods=%d\n"+
+						"  step: %+v\n"+
+						"  expected: desiredPods=%d panic=%v\n"+
+						"  got: %+v panic=%v maxPanicPods=%d",
+						vector.Name, vector.Description, i, len(vector.Steps),
+						vector.Spec.TargetValue, vector.Spec.Annotations, vector.InitialReadyPods,
+						step,
+						step.ExpectedDesiredPods, step.ExpectedPanic,
+						result, inPanic, kpaScaler.maxPanicPods)
+				}
+			}
+		})
+	}
+}
@@ -5,7 +5,7 @@ Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
 You may obtain a copy of the License at
 
-	http://www.apache.org/licenses/LICENSE-2.0
+    http://www.apache.org/licenses/LICENSE-2.0
 
 Unless required by applicable law or agreed to in writing, software
 distributed under the License is distributed on an "AS IS" BASIS,
@@ -18,29 +18,98 @@ package scaler
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
 )
 
+// ScalerSpec holds what a scaling strategy builds the scaler of a metric of a PodAutoscaler from.
+type ScalerSpec struct {
+	// PodAutoscaler holds only the metric source the scaler is built for.
+	PodAutoscaler *autoscalingv1alpha1.PodAutoscaler
+	// ReadyPodsCount is the current number of ready pods of the scale target.
+	ReadyPodsCount int
+	// MetricFetcher fetches the metrics of the pods and of the other metric sources.
+	MetricFetcher metrics.MetricFetcher
+	// Now is the time the scaler is created at.
+	Now time.Time
+}
+
+// StrategyFactory builds the scaler of a scaling strategy.
+type StrategyFactory func(spec ScalerSpec) (Scaler, error)
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]StrategyFactory{}
+)
+
+func init() {
+	RegisterStrategy(string(autoscalingv1alpha1.KPA), func(spec ScalerSpec) (Scaler, error) {
+		// TODO Currently, we initialize kpa with default config and allocate window with default length.
+		//  We then reallocate window according to pa until UpdateScalingContext.
+		//  it's not wrong, but we allocate window twice, to be optimized.
+		return NewKpaAutoscalerWithFetcher(spec.ReadyPodsCount, spec.PodAutoscaler, spec.Now, spec.MetricFetcher)
+	})
+	RegisterStrategy(string(autoscalingv1alpha1.APA), func(spec ScalerSpec) (Scaler, error) {
+		return NewApaAutoscalerWithFetcher(spec.ReadyPodsCount, spec.PodAutoscaler, spec.MetricFetcher)
+	})
+}
+
+// RegisterStrategy registers the factory of a scaling strategy under the name a PodAutoscaler selects it by in
+// its ScalingStrategy, so that a new algorithm does not need changes to the controller. It panics if the name is
+// empty or already registered, HPA is reserved for the HorizontalPodAutoscaler managed by the controller.
+func RegisterStrategy(name string, factory func(spec ScalerSpec) (Scaler, error)) {
+	if name == "" || name == string(autoscalingv1alpha1.HPA) {
+		panic(fmt.Sprintf("invalid scaling strategy name: %q", name))
+	}
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	if _, ok := strategies[name]; ok {
+		panic(fmt.Sprintf("scaling strategy %s is already registered", name))
+	}
+	strategies[name] = factory
+}
+
+// IsRegisteredStrategy reports whether a scaler can be built for the scaling strategy.
+func IsRegisteredStrategy(strategy autoscalingv1alpha1.ScalingStrategyType) bool {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	_, ok := strategies[string(strategy)]
+	return ok
+}
+
+// RegisteredStrategies returns the names of the registered scaling strategies in order.
+func RegisteredStrategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewScaler builds the scaler of the registered scaling strategy.
+func NewScaler(strategy autoscalingv1alpha1.ScalingStrategyType, spec ScalerSpec) (Scaler, error) {
+	strategiesMu.RLock()
+	factory, ok := strategies[string(strategy)]
+	strategiesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported scaling strategy: %s", strategy)
+	}
+	if spec.MetricFetcher == nil {
+		spec.MetricFetcher = metrics.NewRestMetricsFetcher()
+	}
+	return factory(spec)
+}
+
 // NewAutoscalerFactory creates an Autoscaler based on the given ScalingStrategy
 func NewAutoscalerFactory(strategy autoscalingv1alpha1.ScalingStrategyType) (Scaler, error) {
 	// after update, the XpaAutoscaler must be associated with an instantiated PA, rather than an empty scaler that awaits filling.
 	// But NewAutoscalerFactory doesn't be used, so we temporarily pass into nil
-	switch strategy {
-	case autoscalingv1alpha1.KPA:
-		autoscaler, err := NewKpaAutoscaler(0, nil, time.Now())
-		if err != nil {
-			return nil, err
-		}
-		return autoscaler, nil
-	case autoscalingv1alpha1.APA:
-		autoscaler, err := NewApaAutoscaler(0, nil)
-		if err != nil {
-			return nil, err
-		}
-		return autoscaler, nil
-	default:
-		return nil, fmt.Errorf("unsupported scaling strategy: %s", strategy)
-	}
+	return NewScaler(strategy, ScalerSpec{Now: time.Now()})
 }
@@ -0,0 +1,51 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaler
+
+import (
+	"testing"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+func TestRegisteredStrategies(t *testing.T) {
+	for _, strategy := range []autoscalingv1alpha1.ScalingStrategyType{autoscalingv1alpha1.KPA, autoscalingv1alpha1.APA} {
+		if !IsRegisteredStrategy(strategy) {
+			t.Errorf("expected %s to be registered", strategy)
+		}
+	}
+	if IsRegisteredStrategy(autoscalingv1alpha1.HPA) {
+		t.Errorf("expected HPA not to be registered, it is managed by the controller")
+	}
+	if _, err := NewScaler("Unknown", ScalerSpec{}); err == nil {
+		t.Errorf("expected an error for an unknown strategy")
+	}
+}
+
+func TestRegisterStrategyPanics(t *testing.T) {
+	factory := func(spec ScalerSpec) (Scaler, error) { return nil, nil }
+	for _, name := range []string{"", string(autoscalingv1alpha1.HPA), string(autoscalingv1alpha1.KPA)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %q to panic", name)
+				}
+			}()
+			RegisterStrategy(name, factory)
+		}()
+	}
+}
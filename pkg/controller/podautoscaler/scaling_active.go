@@ -0,0 +1,52 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+// setMetricsUnavailable sets the ScalingActive condition to false. The warning event is only emitted when the
+// metrics become unavailable, not on every sync until they are back.
+func (r *PodAutoscalerReconciler) setMetricsUnavailable(pa *autoscalingv1alpha1.PodAutoscaler, reason string, err error) {
+	if !apimeta.IsStatusConditionFalse(pa.Status.Conditions, autoscalingv1alpha1.ScalingActive) {
+		r.EventRecorder.Event(pa, corev1.EventTypeWarning, reason, err.Error())
+	}
+	setCondition(pa, autoscalingv1alpha1.ScalingActive, metav1.ConditionFalse, reason,
+		"the %s controller was unable to get the metrics of the target, holding the current replicas: %v", pa.Spec.ScalingStrategy, err)
+}
+
+// holdForUnavailableMetrics keeps the current replicas of the target until its metrics are available again. The
+// metrics are retried after the metrics backoff of the PodAutoscaler rather than the error backoff of the rate
+// limiter, which is left to the errors of the API server.
+func (r *PodAutoscalerReconciler) holdForUnavailableMetrics(ctx context.Context, paStatusOriginal *autoscalingv1alpha1.PodAutoscalerStatus, pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas int32) (ctrl.Result, error) {
+	podAutoscalerErrors.WithLabelValues(stageFetchMetrics).Inc()
+	r.setCurrentReplicasAndMetricsInStatus(pa, currentReplicas, nil)
+	clearReconcileTimeout(pa)
+	observeGeneration(pa)
+	if err := r.updateStatusIfNeeded(ctx, paStatusOriginal, pa); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.recordMetricsFailure(pa)}, nil
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+// newTestMetricsServer serves the test metric of every pod, a negative value fails the scrape.
+func newTestMetricsServer(t *testing.T, value *atomic.Int64) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if v := value.Load(); v >= 0 {
+			fmt.Fprintf(w, "test_metric %d\n", v)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server url: %v", err)
+	}
+	return u.Port()
+}
+
+func TestReconcileAPA(t *testing.T) {
+	tolerance := map[string]string{
+		"apa.autoscaling.aibrix.ai/up-fluctuation-tolerance":   "0.5",
+		"apa.autoscaling.aibrix.ai/down-fluctuation-tolerance": "0.5",
+	}
+	testCases := []struct {
+		name             string
+		replicas         int32
+		metric           int64
+		annotations      map[string]string
+		expectedReplicas int32
+	}{
+		{
+			// 16 per pod is 4 times the target, the scale up is bound by the max scale up rate of 2.
+			name:             "scale up",
+			replicas:         2,
+			metric:           16,
+			expectedReplicas: 4,
+		},
+		{
+			name:             "scale down",
+			replicas:         2,
+			metric:           1,
+			expectedReplicas: 1,
+		},
+		{
+			name:             "at the up tolerance boundary",
+			replicas:         2,
+			metric:           6,
+			annotations:      tolerance,
+			expectedReplicas: 2,
+		},
+		{
+			name:             "at the down tolerance boundary",
+			replicas:         2,
+			metric:           2,
+			annotations:      tolerance,
+			expectedReplicas: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var metric atomic.Int64
+			metric.Store(tc.metric)
+			r, _ := newTestReconciler(t, newTestAPAObjects(tc.replicas, newTestMetricsServer(t, &metric), tc.annotations)...)
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			pa := getTestPodAutoscaler(t, r)
+			if pa.Status.ActualScale != tc.replicas || pa.Status.DesiredScale != tc.expectedReplicas {
+				t.Errorf("expected actual scale %d and desired scale %d, got %d and %d",
+					tc.replicas, tc.expectedReplicas, pa.Status.ActualScale, pa.Status.DesiredScale)
+			}
+			if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ScalingActive) {
+				t.Errorf("expected ScalingActive to be true")
+			}
+		})
+	}
+}
+
+func TestReconcileAPAMetricsUnavailable(t *testing.T) {
+	var metric atomic.Int64
+	metric.Store(-1)
+	r, recorder := newTestReconciler(t, newTestAPAObjects(2, newTestMetricsServer(t, &metric), nil)...)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: testPaName}}
+
+	// the retries back off from the sync period.
+	for i, backoff := range []time.Duration{DefaultSyncPeriod, 2 * DefaultSyncPeriod, 4 * DefaultSyncPeriod} {
+		result, err := r.Reconcile(context.Background(), request)
+		if err != nil {
+			t.Fatalf("expected unavailable metrics not to fail the reconcile, got %v", err)
+		}
+		if result.RequeueAfter != backoff {
+			t.Errorf("expected retry #%d after %v, got %v", i, backoff, result.RequeueAfter)
+		}
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 2 {
+		t.Errorf("expected the replicas to be held at 2, got %d", replicas)
+	}
+	if count := countEvents(recorder, "FailedUpdateMetrics"); count != 1 {
+		t.Errorf("expected one FailedUpdateMetrics event, got %d", count)
+	}
+	cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.ScalingActive)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "FailedUpdateMetrics" {
+		t.Fatalf("expected ScalingActive=False with reason FailedUpdateMetrics, got %+v", cond)
+	}
+
+	// the metrics are back.
+	metric.Store(16)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 4 {
+		t.Errorf("expected 4 replicas, got %d", replicas)
+	}
+	if !apimeta.IsStatusConditionTrue(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.ScalingActive) {
+		t.Errorf("expected ScalingActive to be true")
+	}
+
+	// the backoff was reset by the successful reconcile.
+	metric.Store(-1)
+	result, err := r.Reconcile(context.Background(), request)
+	if err != nil {
+		t.Fatalf("expected unavailable metrics not to fail the reconcile, got %v", err)
+	}
+	if result.RequeueAfter != DefaultSyncPeriod {
+		t.Errorf("expected a requeue after %v, got %v", DefaultSyncPeriod, result.RequeueAfter)
+	}
+}
+
+func TestReconcileAPAWithMetricFetcher(t *testing.T) {
+	errUnreachable := errors.New("connection refused")
+	testCases := []struct {
+		name                 string
+		podMetrics           map[string]float64
+		podErrors            map[string]error
+		expectedReplicas     int32
+		expectedFailedEvents int
+	}{
+		{
+			// a scale down is held while the metric of a pod is missing.
+			name:             "missing pod metric holds a scale down",
+			podMetrics:       map[string]float64{"test-pod-0": 1},
+			podErrors:        map[string]error{"test-pod-1": errUnreachable},
+			expectedReplicas: 2,
+		},
+		{
+			// the missing metric counts as zero. 16 is 4 times the target of the 2 pods, the scale up is bound by
+			// the max scale up rate of 2.
+			name:             "missing pod metric counts as zero in a scale up",
+			podMetrics:       map[string]float64{"test-pod-0": 16},
+			podErrors:        map[string]error{"test-pod-1": errUnreachable},
+			expectedReplicas: 4,
+		},
+		{
+			name:                 "metrics of all pods missing",
+			podErrors:            map[string]error{"test-pod-0": errUnreachable, "test-pod-1": errUnreachable},
+			expectedReplicas:     2,
+			expectedFailedEvents: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, recorder := newTestReconciler(t, newTestAPAObjects(2, "8000", nil)...)
+			fetcher := metrics.NewFakeMetricFetcher()
+			for pod, value := range tc.podMetrics {
+				fetcher.SetPodMetric(pod, value)
+			}
+			for pod, err := range tc.podErrors {
+				fetcher.SetPodError(pod, err)
+			}
+			r.metricFetcher = fetcher
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			if count := countEvents(recorder, "FailedUpdateMetrics"); count != tc.expectedFailedEvents {
+				t.Errorf("expected %d FailedUpdateMetrics events, got %d", tc.expectedFailedEvents, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+// checkScalingDisabled reports whether autoscaling is disabled for the target and keeps the ScalingDisabled condition
+// in sync. A target that has been scaled to zero replicas is treated as manually disabled unless scale-to-zero is enabled,
+// in which case zero replicas is a valid state managed by the autoscaler.
+// The warning event is only emitted when the PA transitions into the disabled state, to avoid flooding on every sync.
+func (r *PodAutoscalerReconciler) checkScalingDisabled(pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas, minReplicas int32) bool {
+	if currentReplicas != 0 {
+		setCondition(pa, autoscalingv1alpha1.ScalingDisabled, metav1.ConditionFalse, "ScalingEnabled", "the target has %d replicas and is managed by the %s controller", currentReplicas, pa.Spec.ScalingStrategy)
+		return false
+	}
+	if minReplicas == 0 {
+		setCondition(pa, autoscalingv1alpha1.ScalingDisabled, metav1.ConditionFalse, "ScaleToZero", "the target has zero replicas, which is a managed state since scale-to-zero is enabled")
+		return false
+	}
+
+	if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ScalingDisabled) {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, "ScalingDisabled",
+			"The target has been scaled to zero replicas while minReplicas is %d, autoscaling is disabled until the target is scaled up manually or the %s annotation is set",
+			minReplicas, scalingcontext.ScaleToZeroLabel)
+	}
+	setCondition(pa, autoscalingv1alpha1.ScalingDisabled, metav1.ConditionTrue, "ZeroReplicas",
+		"the target has been scaled to zero replicas, manual intervention is required to resume autoscaling, or enable scale-to-zero by setting the %s annotation to true",
+		scalingcontext.ScaleToZeroLabel)
+	return true
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func TestReconcileZeroReplicasDisablesScaling(t *testing.T) {
+	r, recorder := newTestReconciler(t, newTestDeployment(0), newTestPodAutoscaler(nil, 10, nil))
+
+	for i := 0; i < 2; i++ {
+		if err := reconcileTestPodAutoscaler(t, r); err != nil {
+			t.Fatalf("reconcile #%d failed: %v", i, err)
+		}
+	}
+
+	pa := getTestPodAutoscaler(t, r)
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ScalingDisabled)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "ZeroReplicas" {
+		t.Fatalf("expected ScalingDisabled=True with reason ZeroReplicas, got %+v", cond)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 0 {
+		t.Errorf("expected the deployment to stay at 0 replicas, got %d", replicas)
+	}
+	if count := countEvents(recorder, "ScalingDisabled"); count != 1 {
+		t.Errorf("expected exactly one ScalingDisabled event, got %d", count)
+	}
+}
+
+func TestReconcileZeroReplicasWithScaleToZero(t *testing.T) {
+	annotations := map[string]string{scalingcontext.ScaleToZeroLabel: "true"}
+	r, recorder := newTestReconciler(t, newTestDeployment(0), newTestPodAutoscaler(nil, 10, annotations))
+
+	// there are no metrics for the scaler yet, so the algorithm can not make a decision.
+	_ = reconcileTestPodAutoscaler(t, r)
+
+	pa := getTestPodAutoscaler(t, r)
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ScalingDisabled)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "ScaleToZero" {
+		t.Fatalf("expected ScalingDisabled=False with reason ScaleToZero, got %+v", cond)
+	}
+	if count := countEvents(recorder, "ScalingDisabled"); count != 0 {
+		t.Errorf("expected no ScalingDisabled event, got %d", count)
+	}
+}
+
+func TestReconcileResumesAfterManualScaleUp(t *testing.T) {
+	r, _ := newTestReconciler(t, newTestDeployment(0), newTestPodAutoscaler(nil, 10, nil))
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testDeployName}, deploy); err != nil {
+		t.Fatalf("failed to get Deployment: %v", err)
+	}
+	replicas := int32(2)
+	deploy.Spec.Replicas = &replicas
+	if err := r.Update(context.Background(), deploy); err != nil {
+		t.Fatalf("failed to scale up Deployment: %v", err)
+	}
+
+	// there are no metrics for the scaler yet, so the algorithm can not make a decision.
+	_ = reconcileTestPodAutoscaler(t, r)
+
+	pa := getTestPodAutoscaler(t, r)
+	if apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ScalingDisabled) {
+		t.Errorf("expected ScalingDisabled to be cleared after the target was scaled up manually")
+	}
+}
+
+func TestReconcileReplicasOutOfRange(t *testing.T) {
+	minReplicas := int32(3)
+	testCases := []struct {
+		name             string
+		currentReplicas  int32
+		expectedReplicas int32
+	}{
+		{
+			name:             "current replicas above maxReplicas",
+			currentReplicas:  12,
+			expectedReplicas: 10,
+		},
+		{
+			name:             "current replicas below minReplicas",
+			currentReplicas:  1,
+			expectedReplicas: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, recorder := newTestReconciler(t, newTestDeployment(tc.currentReplicas), newTestPodAutoscaler(&minReplicas, 10, nil))
+			if err := reconcileTestPodAutoscaler(t, r); err != nil {
+				t.Fatalf("reconcile failed: %v", err)
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			if count := countEvents(recorder, "SuccessfulRescale"); count != 1 {
+				t.Errorf("expected one SuccessfulRescale event, got %d", count)
+			}
+			pa := getTestPodAutoscaler(t, r)
+			if apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.ScalingDisabled) {
+				t.Errorf("expected ScalingDisabled not to be true")
+			}
+		})
+	}
+}
+
+func TestReconcileAPAZeroReplicas(t *testing.T) {
+	var metric atomic.Int64
+	r, _ := newTestReconciler(t, newTestAPAObjects(0, newTestMetricsServer(t, &metric), nil)...)
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 0 {
+		t.Errorf("expected the deployment to stay at 0 replicas, got %d", replicas)
+	}
+	if !apimeta.IsStatusConditionTrue(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.ScalingDisabled) {
+		t.Errorf("expected ScalingDisabled to be true")
+	}
+}
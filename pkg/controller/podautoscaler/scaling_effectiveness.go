@@ -0,0 +1,211 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+const (
+	// defaultIneffectiveScalingThreshold is the mean relative error of the metric per replica above which the
+	// scaling is reported ineffective, e.g. the metric is 50% above or below its target on average.
+	defaultIneffectiveScalingThreshold = 0.5
+	// defaultIneffectiveScalingDecisions is how many of the last scaling decisions the mean error is computed over.
+	defaultIneffectiveScalingDecisions = 5
+)
+
+var scalingPredictionBias = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "aibrix_podautoscaler_scaling_prediction_bias",
+	Help: "Mean relative error of the metric per replica against its target value one stable window after the last scaling decisions of a PodAutoscaler, positive when under-provisioned.",
+}, []string{"namespace", "name"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(scalingPredictionBias)
+}
+
+// pendingScaleDecision is a scaling decision made on a metric, which predicted that the metric per replica meets its
+// target value once the target runs the decided replicas. It is evaluated when the metric has been averaged over a
+// stable window since.
+type pendingScaleDecision struct {
+	metric      string
+	targetValue float64
+	replicas    int32
+	evaluateAt  time.Time
+}
+
+// scaleDecisionHistory is the decision history of a PodAutoscaler: the decision waiting for its evaluation, and the
+// relative errors of the last evaluated decisions, oldest first.
+type scaleDecisionHistory struct {
+	pending *pendingScaleDecision
+	errors  []float64
+}
+
+// getIneffectiveScalingThreshold returns the mean relative error above which the scaling of the PodAutoscaler is
+// reported ineffective.
+func getIneffectiveScalingThreshold(pa *autoscalingv1alpha1.PodAutoscaler) float64 {
+	value, ok := pa.Annotations[scalingcontext.IneffectiveScalingThresholdLabel]
+	if !ok {
+		return defaultIneffectiveScalingThreshold
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold <= 0 {
+		klog.InfoS("Invalid ineffective scaling threshold, falling back to default", "PodAutoscaler", klog.KObj(pa), "threshold", value)
+		return defaultIneffectiveScalingThreshold
+	}
+	return threshold
+}
+
+// getIneffectiveScalingDecisions returns how many of the last scaling decisions of the PodAutoscaler the mean error
+// is computed over.
+func getIneffectiveScalingDecisions(pa *autoscalingv1alpha1.PodAutoscaler) int {
+	value, ok := pa.Annotations[scalingcontext.IneffectiveScalingDecisionsLabel]
+	if !ok {
+		return defaultIneffectiveScalingDecisions
+	}
+	decisions, err := strconv.Atoi(value)
+	if err != nil || decisions <= 0 {
+		klog.InfoS("Invalid ineffective scaling decisions, falling back to default", "PodAutoscaler", klog.KObj(pa), "decisions", value)
+		return defaultIneffectiveScalingDecisions
+	}
+	return decisions
+}
+
+// recordScaleDecision records the decision which scaled the target to the given replicas, to be evaluated one
+// stable window of its metric later. A decision still waiting for its evaluation is superseded, since the new
+// replicas change the metric per replica it predicted. A decision not made on a metric, e.g. a clamp to the replicas
+// range, predicts nothing and only supersedes the pending one.
+func (r *PodAutoscalerReconciler) recordScaleDecision(pa *autoscalingv1alpha1.PodAutoscaler, explanation *decisionExplanation, replicas int32, now time.Time) {
+	var decision *pendingScaleDecision
+	if explanation != nil && explanation.metric != "" && explanation.TargetValue > 0 && replicas > 0 {
+		window := explanation.Window
+		if window <= 0 {
+			window = r.getSyncPeriod(pa)
+		}
+		decision = &pendingScaleDecision{
+			metric:      explanation.metric,
+			targetValue: explanation.TargetValue,
+			replicas:    replicas,
+			evaluateAt:  now.Add(window),
+		}
+	}
+
+	key := types.NamespacedName{Namespace: pa.Namespace, Name: pa.Name}
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	history, ok := r.scaleDecisions[key]
+	if !ok {
+		if decision == nil {
+			return
+		}
+		if r.scaleDecisions == nil {
+			r.scaleDecisions = make(map[types.NamespacedName]*scaleDecisionHistory)
+		}
+		history = &scaleDecisionHistory{}
+		r.scaleDecisions[key] = history
+	}
+	if history.pending != nil {
+		klog.V(4).InfoS("Scaling decision superseded before its evaluation", "PodAutoscaler", klog.KObj(pa),
+			"metric", history.pending.metric, "replicas", history.pending.replicas)
+	}
+	history.pending = decision
+}
+
+// evaluateScaleDecision evaluates the pending decision of the PodAutoscaler once it is due, on the reconcile which
+// computed the given recommendation of the metric currently driving the target. The relative error of the metric
+// per replica against the target value the decision predicted joins the last evaluated ones, and the
+// AutoscalingIneffective condition reports whether their mean is beyond the threshold. A decision is dropped without
+// evaluation when the target no longer runs its replicas, or when another metric drives the target, whose replicas
+// are then sized for the other metric.
+func (r *PodAutoscalerReconciler) evaluateScaleDecision(pa *autoscalingv1alpha1.PodAutoscaler, metric string, recommendation scaler.ScaleExplanation, currentReplicas int32, now time.Time) {
+	decisions := getIneffectiveScalingDecisions(pa)
+	key := types.NamespacedName{Namespace: pa.Namespace, Name: pa.Name}
+
+	r.stateMu.Lock()
+	history, ok := r.scaleDecisions[key]
+	if !ok || history.pending == nil || now.Before(history.pending.evaluateAt) {
+		r.stateMu.Unlock()
+		return
+	}
+	decision := history.pending
+	history.pending = nil
+	if decision.metric != metric || decision.replicas != currentReplicas || recommendation.TargetValue == 0 {
+		r.stateMu.Unlock()
+		klog.V(4).InfoS("Scaling decision dropped without evaluation", "PodAutoscaler", klog.KObj(pa),
+			"metric", decision.metric, "drivingMetric", metric, "replicas", decision.replicas, "currentReplicas", currentReplicas)
+		return
+	}
+	relativeError := recommendation.Value/(float64(currentReplicas)*decision.targetValue) - 1
+	history.errors = append(history.errors, relativeError)
+	if len(history.errors) > decisions {
+		history.errors = history.errors[len(history.errors)-decisions:]
+	}
+	errs := append([]float64(nil), history.errors...)
+	r.stateMu.Unlock()
+
+	var sum float64
+	for _, e := range errs {
+		sum += e
+	}
+	bias := sum / float64(len(errs))
+	scalingPredictionBias.WithLabelValues(pa.Namespace, pa.Name).Set(bias)
+	klog.V(4).InfoS("Evaluated scaling decision", "PodAutoscaler", klog.KObj(pa), "metric", metric,
+		"replicas", currentReplicas, "value", recommendation.Value, "targetValue", decision.targetValue,
+		"relativeError", relativeError, "bias", bias, "decisions", len(errs))
+
+	if len(errs) < decisions {
+		return
+	}
+	r.setAutoscalingIneffective(pa, bias, decisions)
+}
+
+// setAutoscalingIneffective reports the mean relative error of the last decisions with the AutoscalingIneffective
+// condition, with an event when the scaling becomes ineffective.
+func (r *PodAutoscalerReconciler) setAutoscalingIneffective(pa *autoscalingv1alpha1.PodAutoscaler, bias float64, decisions int) {
+	threshold := getIneffectiveScalingThreshold(pa)
+	if math.Abs(bias) <= threshold {
+		setCondition(pa, autoscalingv1alpha1.AutoscalingIneffective, metav1.ConditionFalse, "PredictionsOnTarget",
+			"the metric per replica was %.0f%% off its target on average after the last %d scaling decisions, within the threshold of %.0f%%",
+			math.Abs(bias)*100, decisions, threshold*100)
+		return
+	}
+
+	reason, direction := "UnderProvisioned", "above"
+	if bias < 0 {
+		reason, direction = "OverProvisioned", "below"
+	}
+	message := "the metric per replica was %.0f%% %s its target on average one stable window after the last %d scaling decisions, " +
+		"review the target value, the stable window and the scale rate limits"
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.AutoscalingIneffective)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != reason {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeWarning, "AutoscalingIneffective", message, math.Abs(bias)*100, direction, decisions)
+	}
+	setCondition(pa, autoscalingv1alpha1.AutoscalingIneffective, metav1.ConditionTrue, reason, message, math.Abs(bias)*100, direction, decisions)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+func TestScaleDecisionEvaluation(t *testing.T) {
+	annotations := map[string]string{
+		scalingcontext.IneffectiveScalingThresholdLabel: "0.5",
+		scalingcontext.IneffectiveScalingDecisionsLabel: "3",
+	}
+	pa := newTestPodAutoscaler(nil, 10, annotations)
+	r, recorder := newTestReconciler(t)
+	now := time.Now()
+
+	// each decision scales the target to 4 replicas for a load of 40 at the target of 10 per replica.
+	decide := func() {
+		t.Helper()
+		explanation := &decisionExplanation{metric: "test_metric",
+			ScaleExplanation: scaler.ScaleExplanation{Value: 40, Window: time.Minute, TargetValue: 10, RawPodCount: 4}}
+		r.recordScaleDecision(pa, explanation, 4, now)
+	}
+	evaluate := func(value float64) {
+		t.Helper()
+		now = now.Add(time.Minute)
+		r.evaluateScaleDecision(pa, "test_metric", scaler.ScaleExplanation{Value: value, TargetValue: 10}, 4, now)
+	}
+	ineffective := func() *metav1.Condition {
+		return apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.AutoscalingIneffective)
+	}
+
+	// the load keeps doubling after the decisions, the target is systematically under-provisioned.
+	for i := 0; i < 2; i++ {
+		decide()
+		evaluate(80)
+		if cond := ineffective(); cond != nil {
+			t.Fatalf("decision #%d: expected no condition before 3 decisions are evaluated, got %+v", i, cond)
+		}
+	}
+	decide()
+	evaluate(80)
+	cond := ineffective()
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "UnderProvisioned" {
+		t.Fatalf("expected AutoscalingIneffective=True with reason UnderProvisioned, got %+v", cond)
+	}
+	if bias := testutil.ToFloat64(scalingPredictionBias.WithLabelValues(testNamespace, testPaName)); bias != 1 {
+		t.Errorf("expected a bias of 1, got %v", bias)
+	}
+	if count := countEvents(recorder, "AutoscalingIneffective"); count != 1 {
+		t.Errorf("expected 1 AutoscalingIneffective event, got %d", count)
+	}
+
+	// a decision which is not due yet is not evaluated, and a decision superseded before it is due never is.
+	decide()
+	r.evaluateScaleDecision(pa, "test_metric", scaler.ScaleExplanation{Value: 40, TargetValue: 10}, 4, now.Add(30*time.Second))
+	decide()
+	evaluate(40)
+	// a decision whose replicas were changed by someone else is dropped.
+	decide()
+	now = now.Add(time.Minute)
+	r.evaluateScaleDecision(pa, "test_metric", scaler.ScaleExplanation{Value: 40, TargetValue: 10}, 6, now)
+	if errs := r.scaleDecisions[types.NamespacedName{Namespace: testNamespace, Name: testPaName}].errors; len(errs) != 3 || errs[2] != 0 {
+		t.Fatalf("expected only the due decision to be evaluated, got errors %v", errs)
+	}
+	if cond := ineffective(); cond.Status != metav1.ConditionTrue {
+		t.Fatalf("expected AutoscalingIneffective to stay true while the mean error is 0.67, got %+v", cond)
+	}
+
+	// the decisions meet their target again, the condition clears once the mean error is within the threshold.
+	decide()
+	evaluate(40)
+	cond = ineffective()
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "PredictionsOnTarget" {
+		t.Fatalf("expected AutoscalingIneffective=False with reason PredictionsOnTarget, got %+v", cond)
+	}
+	if bias := testutil.ToFloat64(scalingPredictionBias.WithLabelValues(testNamespace, testPaName)); math.Abs(bias-1.0/3) > 1e-9 {
+		t.Errorf("expected a bias of 0.33, got %v", bias)
+	}
+
+	// the history and the gauge are dropped with the PodAutoscaler.
+	r.deleteStaleScalerInCache(types.NamespacedName{Namespace: testNamespace, Name: testPaName})
+	if _, ok := r.scaleDecisions[types.NamespacedName{Namespace: testNamespace, Name: testPaName}]; ok {
+		t.Errorf("expected the decision history of the deleted PodAutoscaler to be removed")
+	}
+	if count := testutil.CollectAndCount(scalingPredictionBias); count != 0 {
+		t.Errorf("expected the gauge of the deleted PodAutoscaler to be removed, got %d series", count)
+	}
+}
+
+func TestScaleDecisionOverProvisioned(t *testing.T) {
+	pa := newTestPodAutoscaler(nil, 10, map[string]string{scalingcontext.IneffectiveScalingDecisionsLabel: "2"})
+	r, _ := newTestReconciler(t)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		r.recordScaleDecision(pa, &decisionExplanation{metric: "test_metric",
+			ScaleExplanation: scaler.ScaleExplanation{Value: 40, Window: time.Minute, TargetValue: 10}}, 4, now)
+		now = now.Add(time.Minute)
+		// a recommendation of another metric drives the target, the decision does not apply to it.
+		r.evaluateScaleDecision(pa, "other_metric", scaler.ScaleExplanation{Value: 10, TargetValue: 10}, 4, now)
+	}
+	if cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.AutoscalingIneffective); cond != nil {
+		t.Fatalf("expected no condition for the decisions of another metric, got %+v", cond)
+	}
+
+	for i := 0; i < 2; i++ {
+		r.recordScaleDecision(pa, &decisionExplanation{metric: "test_metric",
+			ScaleExplanation: scaler.ScaleExplanation{Value: 40, Window: time.Minute, TargetValue: 10}}, 4, now)
+		// a clamp to the replicas range does not predict the metric and supersedes the decision.
+		r.recordScaleDecision(pa, explainReason("3 replicas below minReplicas", 4), 4, now)
+		now = now.Add(time.Minute)
+		r.evaluateScaleDecision(pa, "test_metric", scaler.ScaleExplanation{Value: 10, TargetValue: 10}, 4, now)
+	}
+	if cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.AutoscalingIneffective); cond != nil {
+		t.Fatalf("expected no condition for superseded decisions, got %+v", cond)
+	}
+
+	// the load drops to a quarter after the decisions.
+	for i := 0; i < 2; i++ {
+		r.recordScaleDecision(pa, &decisionExplanation{metric: "test_metric",
+			ScaleExplanation: scaler.ScaleExplanation{Value: 40, Window: time.Minute, TargetValue: 10}}, 4, now)
+		now = now.Add(time.Minute)
+		r.evaluateScaleDecision(pa, "test_metric", scaler.ScaleExplanation{Value: 10, TargetValue: 10}, 4, now)
+	}
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.AutoscalingIneffective)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != "OverProvisioned" {
+		t.Fatalf("expected AutoscalingIneffective=True with reason OverProvisioned, got %+v", cond)
+	}
+	r.deleteStaleScalerInCache(types.NamespacedName{Namespace: testNamespace, Name: testPaName})
+}
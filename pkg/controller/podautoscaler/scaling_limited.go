@@ -0,0 +1,54 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+// limitReplicas clamps the replicas recommended by the metrics to the <min, max> range of the PA and reports the
+// clamp in the ScalingLimited condition, with an event when the target starts being limited.
+func (r *PodAutoscalerReconciler) limitReplicas(pa *autoscalingv1alpha1.PodAutoscaler, recommendedReplicas, minReplicas int32) int32 {
+	var limitedReplicas int32
+	var reason, bound string
+	switch {
+	case recommendedReplicas > pa.Spec.MaxReplicas:
+		limitedReplicas, reason, bound = pa.Spec.MaxReplicas, "TooManyReplicas", "maximum"
+	case recommendedReplicas < minReplicas:
+		limitedReplicas, reason, bound = minReplicas, "TooFewReplicas", "minimum"
+	default:
+		setCondition(pa, autoscalingv1alpha1.ScalingLimited, metav1.ConditionFalse, "DesiredWithinRange",
+			"the desired count of %d replicas is within the acceptable range", recommendedReplicas)
+		return recommendedReplicas
+	}
+
+	klog.V(2).InfoS("Scaling adjustment: Algorithm recommended scaling to a target outside of the replica limits.",
+		"recommendedReplicas", recommendedReplicas, "adjustedTo", limitedReplicas)
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.ScalingLimited)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != reason {
+		r.EventRecorder.Eventf(pa, corev1.EventTypeNormal, "ScalingLimited",
+			"recommended %d replicas clamped to the %s of %d replicas", recommendedReplicas, bound, limitedReplicas)
+	}
+	setCondition(pa, autoscalingv1alpha1.ScalingLimited, metav1.ConditionTrue, reason,
+		"the desired replica count is clamped to the %s of %d replicas, the metrics recommend %d replicas", bound, limitedReplicas, recommendedReplicas)
+	return limitedReplicas
+}
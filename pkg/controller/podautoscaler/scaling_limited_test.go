@@ -0,0 +1,147 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+func TestReconcileScalingLimited(t *testing.T) {
+	minReplicas := int32(1)
+	minReplicasOfThree := int32(3)
+	testCases := []struct {
+		name        string
+		replicas    int32
+		minReplicas *int32
+		maxReplicas int32
+		metrics     []float64
+		// stable starts the scaler out of the panic mode, which a scaler of more than one ready pod starts in and
+		// which holds the replicas.
+		stable           bool
+		expectedReplicas int32
+		expectedStatus   metav1.ConditionStatus
+		expectedReason   string
+		expectedMessage  string
+	}{
+		{
+			// the 20 requests of the 3 pods need 5 pods at the target of 4.
+			name:             "clamped to max",
+			replicas:         3,
+			maxReplicas:      4,
+			metrics:          []float64{8, 8, 4},
+			expectedReplicas: 4,
+			expectedStatus:   metav1.ConditionTrue,
+			expectedReason:   "TooManyReplicas",
+			expectedMessage:  "recommend 5 replicas",
+		},
+		{
+			// a single ready pod does not start in panic mode, which would hold its replicas.
+			name:             "clamped to min",
+			replicas:         1,
+			minReplicas:      &minReplicas,
+			maxReplicas:      10,
+			metrics:          []float64{0},
+			expectedReplicas: 1,
+			expectedStatus:   metav1.ConditionTrue,
+			expectedReason:   "TooFewReplicas",
+			expectedMessage:  "recommend 0 replicas",
+		},
+		{
+			// the 4 requests of the 4 pods need a pod at the target of 4, the scale down rate of 2 halves the 4 pods.
+			name:             "clamped to min from several pods",
+			replicas:         4,
+			minReplicas:      &minReplicasOfThree,
+			maxReplicas:      10,
+			metrics:          []float64{1, 1, 1, 1},
+			stable:           true,
+			expectedReplicas: 3,
+			expectedStatus:   metav1.ConditionTrue,
+			expectedReason:   "TooFewReplicas",
+			expectedMessage:  "recommend 2 replicas",
+		},
+		{
+			name:             "within range",
+			replicas:         3,
+			maxReplicas:      10,
+			metrics:          []float64{8, 8, 4},
+			expectedReplicas: 5,
+			expectedStatus:   metav1.ConditionFalse,
+			expectedReason:   "DesiredWithinRange",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objs := newTestAPAObjects(tc.replicas, "8000", nil)
+			pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+			pa.Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+			pa.Spec.MinReplicas = tc.minReplicas
+			pa.Spec.MaxReplicas = tc.maxReplicas
+			r, recorder := newTestReconciler(t, objs...)
+			fetcher := metrics.NewFakeMetricFetcher()
+			for i, metric := range tc.metrics {
+				fetcher.SetPodMetric(fmt.Sprintf("test-pod-%d", i), metric)
+			}
+			r.metricFetcher = fetcher
+			if tc.stable {
+				autoScaler, err := scaler.NewKpaAutoscalerWithFetcher(1, pa, time.Now(), fetcher)
+				if err != nil {
+					t.Fatalf("failed to create scaler: %v", err)
+				}
+				metricKey, _, err := metrics.NewNamespaceNameMetric(pa)
+				if err != nil {
+					t.Fatalf("failed to create metric key: %v", err)
+				}
+				r.setScaler(metricKey, autoScaler)
+			}
+			// the event is only recorded once while the target stays limited.
+			for i := 0; i < 2; i++ {
+				if err := reconcileTestPodAutoscaler(t, r); err != nil {
+					t.Fatalf("reconcile failed: %v", err)
+				}
+			}
+
+			if replicas := getTestDeploymentReplicas(t, r); replicas != tc.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", tc.expectedReplicas, replicas)
+			}
+			cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.ScalingLimited)
+			if cond == nil || cond.Status != tc.expectedStatus || cond.Reason != tc.expectedReason {
+				t.Fatalf("expected ScalingLimited to be %s with reason %s, got %+v", tc.expectedStatus, tc.expectedReason, cond)
+			}
+			if !strings.Contains(cond.Message, tc.expectedMessage) {
+				t.Errorf("expected the message to contain %q, got %q", tc.expectedMessage, cond.Message)
+			}
+			expectedEvents := 0
+			if tc.expectedStatus == metav1.ConditionTrue {
+				expectedEvents = 1
+			}
+			if count := countEvents(recorder, "ScalingLimited"); count != expectedEvents {
+				t.Errorf("expected %d ScalingLimited events, got %d", expectedEvents, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+const (
+	// maxPersistedRecommendations caps the recommendations kept in the scaling state of a PodAutoscaler, so that
+	// the status stays small whatever the number of distinct recommendations within the stabilization window.
+	maxPersistedRecommendations = 10
+	// scalingStateRefreshInterval is how far the times of the persisted scaling state may lag behind the in-memory
+	// one. The panic time and the latest recommendation advance on every sync, they are only written once they
+	// moved by the interval, so that a restart at worst ends the panic mode and the stabilization this much early.
+	scalingStateRefreshInterval = 30 * time.Second
+	// maxScalingStateClockSkew tolerates the times of a state persisted by a controller whose clock ran ahead of
+	// this one, e.g. before a leader election failover.
+	maxScalingStateClockSkew = time.Minute
+)
+
+// compactRecommendations returns the recommendations, oldest first, which bound a scale down after the latest of
+// them: a recommendation followed by a higher or equal one never raises the highest recommendation of a window
+// again, it is dropped. Beyond maxPersistedRecommendations the oldest are merged, the merged recommendation keeps
+// the higher replicas of the older one and the time of the newer one, so that the stabilization is never shortened.
+func compactRecommendations(recommendations []timestampedRecommendation) []timestampedRecommendation {
+	var compacted []timestampedRecommendation
+	for _, rec := range recommendations {
+		for len(compacted) > 0 && compacted[len(compacted)-1].replicas <= rec.replicas {
+			compacted = compacted[:len(compacted)-1]
+		}
+		compacted = append(compacted, rec)
+	}
+	for len(compacted) > maxPersistedRecommendations {
+		compacted[1].replicas = compacted[0].replicas
+		compacted = compacted[1:]
+	}
+	return compacted
+}
+
+// persistScalingState sets the scaling state of the KPA PodAutoscaler from its in-memory state: the panic state of
+// its scalers and the recommendations within its stabilization window. The state is written along with the status,
+// it is kept as is while it only differs by times within scalingStateRefreshInterval.
+func (r *PodAutoscalerReconciler) persistScalingState(pa *autoscalingv1alpha1.PodAutoscaler) {
+	state := r.currentScalingState(pa)
+	if scalingStateEquivalent(pa.Status.ScalingState, state) {
+		return
+	}
+	pa.Status.ScalingState = state
+}
+
+// currentScalingState returns the in-memory scaling state of the PodAutoscaler, nil if it neither panics nor has
+// recommendations. The PodAutoscaler panics since the latest panic of the scalers of its metrics, and holds the
+// highest of their panic pods.
+func (r *PodAutoscalerReconciler) currentScalingState(pa *autoscalingv1alpha1.PodAutoscaler) *autoscalingv1alpha1.ScalingState {
+	key := types.NamespacedName{Namespace: pa.Namespace, Name: pa.Name}
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+
+	state := &autoscalingv1alpha1.ScalingState{}
+	for metricKey, autoScaler := range r.AutoscalerMap {
+		if metricKey.PaNamespace != pa.Namespace || metricKey.PaName != pa.Name {
+			continue
+		}
+		restorable, ok := autoScaler.(scaler.RestorableScaler)
+		if !ok {
+			continue
+		}
+		panicState := restorable.PanicState()
+		if panicState.PanicTime.IsZero() {
+			continue
+		}
+		if state.PanicTime == nil || panicState.PanicTime.After(state.PanicTime.Time) {
+			panicTime := metav1.NewTime(panicState.PanicTime)
+			state.PanicTime = &panicTime
+		}
+		if panicState.MaxPanicPods > state.MaxPanicReplicas {
+			state.MaxPanicReplicas = panicState.MaxPanicPods
+		}
+	}
+	if window, ok := r.recommendations[key]; ok {
+		for _, rec := range compactRecommendations(window.recommendations) {
+			state.Recommendations = append(state.Recommendations, autoscalingv1alpha1.ScalingRecommendation{
+				Time:     metav1.NewTime(rec.timestamp),
+				Replicas: rec.replicas,
+			})
+		}
+	}
+	if state.PanicTime == nil && len(state.Recommendations) == 0 {
+		return nil
+	}
+	return state
+}
+
+// scalingStateEquivalent reports whether the persisted scaling state only differs from the current one by times
+// within scalingStateRefreshInterval.
+func scalingStateEquivalent(persisted, current *autoscalingv1alpha1.ScalingState) bool {
+	if persisted == nil || current == nil {
+		return persisted == current
+	}
+	if persisted.MaxPanicReplicas != current.MaxPanicReplicas || (persisted.PanicTime == nil) != (current.PanicTime == nil) {
+		return false
+	}
+	if persisted.PanicTime != nil && !withinRefreshInterval(persisted.PanicTime.Time, current.PanicTime.Time) {
+		return false
+	}
+	if len(persisted.Recommendations) != len(current.Recommendations) {
+		return false
+	}
+	for i, rec := range persisted.Recommendations {
+		if rec.Replicas != current.Recommendations[i].Replicas || !withinRefreshInterval(rec.Time.Time, current.Recommendations[i].Time.Time) {
+			return false
+		}
+	}
+	return true
+}
+
+func withinRefreshInterval(persisted, current time.Time) bool {
+	diff := current.Sub(persisted)
+	return diff > -scalingStateRefreshInterval && diff < scalingStateRefreshInterval
+}
+
+// restoredScalingState returns the scaling state persisted in the status of the PodAutoscaler, with the times
+// ahead of now by less than maxScalingStateClockSkew moved back to now. It returns nil if there is none, or if the
+// state is invalid, in which case the PodAutoscaler starts cold as if it had no state.
+func restoredScalingState(pa *autoscalingv1alpha1.PodAutoscaler, now time.Time) *autoscalingv1alpha1.ScalingState {
+	if pa.Status.ScalingState == nil {
+		return nil
+	}
+	state := pa.Status.ScalingState.DeepCopy()
+	if err := validateScalingState(state, now); err != nil {
+		klog.InfoS("Ignoring the invalid scaling state, starting cold", "PodAutoscaler", klog.KObj(pa), "error", err)
+		return nil
+	}
+	if state.PanicTime != nil && state.PanicTime.After(now) {
+		state.PanicTime = &metav1.Time{Time: now}
+	}
+	for i := range state.Recommendations {
+		if state.Recommendations[i].Time.After(now) {
+			state.Recommendations[i].Time = metav1.NewTime(now)
+		}
+	}
+	return state
+}
+
+// validateScalingState checks that the scaling state is one the controller could have written.
+func validateScalingState(state *autoscalingv1alpha1.ScalingState, now time.Time) error {
+	latest := now.Add(maxScalingStateClockSkew)
+	if state.PanicTime != nil && state.PanicTime.After(latest) {
+		return fmt.Errorf("panic time %s is in the future", state.PanicTime.UTC().Format(time.RFC3339))
+	}
+	if state.MaxPanicReplicas < 0 {
+		return fmt.Errorf("negative max panic replicas %d", state.MaxPanicReplicas)
+	}
+	if len(state.Recommendations) > maxPersistedRecommendations {
+		return fmt.Errorf("%d recommendations, at most %d are kept", len(state.Recommendations), maxPersistedRecommendations)
+	}
+	for i, rec := range state.Recommendations {
+		if rec.Replicas < 0 {
+			return fmt.Errorf("negative replicas %d recommended", rec.Replicas)
+		}
+		if rec.Time.After(latest) {
+			return fmt.Errorf("recommendation time %s is in the future", rec.Time.UTC().Format(time.RFC3339))
+		}
+		if i > 0 && rec.Time.Before(&state.Recommendations[i-1].Time) {
+			return fmt.Errorf("recommendations are not ordered by time")
+		}
+	}
+	return nil
+}
+
+// restoreScalerState restores the persisted scaling state of the PodAutoscaler into its new scaler, which is first
+// created after the restart of the controller. The scaler of a PodAutoscaler which was not panicking keeps the
+// panic mode it starts in, its metric windows are empty after the restart.
+func restoreScalerState(pa *autoscalingv1alpha1.PodAutoscaler, autoScaler scaler.Scaler, now time.Time) {
+	restorable, ok := autoScaler.(scaler.RestorableScaler)
+	if !ok {
+		return
+	}
+	state := restoredScalingState(pa, now)
+	if state == nil {
+		return
+	}
+	var panicState scaler.PanicState
+	if state.PanicTime != nil {
+		panicState = scaler.PanicState{PanicTime: state.PanicTime.Time, MaxPanicPods: state.MaxPanicReplicas}
+	}
+	recommendations := make([]scaler.Recommendation, 0, len(state.Recommendations))
+	for _, rec := range state.Recommendations {
+		recommendations = append(recommendations, scaler.Recommendation{Time: rec.Time.Time, PodCount: rec.Replicas})
+	}
+	restorable.RestoreState(panicState, recommendations)
+	klog.InfoS("Restored the scaling state", "PodAutoscaler", klog.KObj(pa),
+		"panicTime", state.PanicTime, "maxPanicReplicas", state.MaxPanicReplicas, "recommendations", len(recommendations))
+}
+
+// restoreRecommendations records the persisted recommendations of the PodAutoscaler into its new stabilization
+// window.
+func restoreRecommendations(pa *autoscalingv1alpha1.PodAutoscaler, window *timedMaxWindow, now time.Time) {
+	state := restoredScalingState(pa, now)
+	if state == nil {
+		return
+	}
+	for _, rec := range state.Recommendations {
+		window.recommendations = append(window.recommendations, timestampedRecommendation{replicas: rec.Replicas, timestamp: rec.Time.Time})
+	}
+	window.prune(now)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testingclock "k8s.io/utils/clock/testing"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+func TestReconcileRestoresPanicStateAfterRestart(t *testing.T) {
+	// the scale down delay and the stabilization are disabled, only the panic mode holds the replicas.
+	annotations := map[string]string{
+		scalingcontext.MaxScaleUpRateLabel:               "10",
+		scalingcontext.ScaleDownStabilizationWindowLabel: "0s",
+		"kpa.autoscaling.aibrix.ai/scale-down-delay":     "0s",
+	}
+	objs := newTestAPAObjects(2, "8000", annotations)
+	objs[1].(*autoscalingv1alpha1.PodAutoscaler).Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+	r, _ := newTestReconciler(t, objs...)
+	start := time.Now()
+	clock := testingclock.NewFakeClock(start)
+	r.clock = clock
+	fetcher := metrics.NewFakeMetricFetcher()
+	// the 32 requests of the 2 pods need 8 pods at the target of 4, 4 times the ready pods.
+	fetcher.SetPodMetric("test-pod-0", 16)
+	fetcher.SetPodMetric("test-pod-1", 16)
+	r.metricFetcher = fetcher
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 8 {
+		t.Fatalf("expected the burst to scale up to 8 replicas, got %d", replicas)
+	}
+	state := getTestPodAutoscaler(t, r).Status.ScalingState
+	if state == nil || state.PanicTime == nil || state.MaxPanicReplicas != 8 {
+		t.Fatalf("expected the panic state to be persisted, got %+v", state)
+	}
+
+	// the controller restarts without any in-memory state once the burst is over.
+	restarted := &PodAutoscalerReconciler{
+		Client:        r.Client,
+		Scheme:        r.Scheme,
+		EventRecorder: r.EventRecorder,
+		Mapper:        r.Mapper,
+		AutoscalerMap: make(map[metrics.NamespaceNameMetric]scaler.Scaler),
+		clock:         clock,
+		metricFetcher: fetcher,
+	}
+	fetcher.SetPodMetric("test-pod-0", 1)
+	fetcher.SetPodMetric("test-pod-1", 1)
+	for _, elapsed := range []time.Duration{10 * time.Second, 30 * time.Second, 55 * time.Second} {
+		clock.SetTime(start.Add(elapsed))
+		if err := reconcileTestPodAutoscaler(t, restarted); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		if replicas := getTestDeploymentReplicas(t, restarted); replicas != 8 {
+			t.Fatalf("%v after the burst: expected the restored panic mode to hold 8 replicas, got %d", elapsed, replicas)
+		}
+	}
+
+	// the panic mode exits one stable window after the burst, rather than one after the restart.
+	clock.SetTime(start.Add(61 * time.Second))
+	if err := reconcileTestPodAutoscaler(t, restarted); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, restarted); replicas >= 8 {
+		t.Errorf("expected a scale down once the panic window expired, got %d replicas", replicas)
+	}
+	if state := getTestPodAutoscaler(t, restarted).Status.ScalingState; state != nil {
+		t.Errorf("expected no scaling state out of panic mode without stabilization, got %+v", state)
+	}
+}
+
+func TestRestoreScalingState(t *testing.T) {
+	now := time.Now()
+	at := func(elapsed time.Duration) metav1.Time {
+		return metav1.NewTime(now.Add(elapsed))
+	}
+
+	// only the recommendations which bound a later scale down are persisted.
+	var recommendations []timestampedRecommendation
+	for i, replicas := range []int32{3, 8, 5, 6, 2} {
+		recommendations = append(recommendations, timestampedRecommendation{replicas: replicas, timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	compacted := compactRecommendations(recommendations)
+	if len(compacted) != 3 || compacted[0].replicas != 8 || compacted[1].replicas != 6 || compacted[2].replicas != 2 {
+		t.Errorf("expected the recommendations 8, 6 and 2 to be kept, got %+v", compacted)
+	}
+	// beyond the cap the oldest are merged, keeping the higher replicas.
+	recommendations = nil
+	for i := 0; i < maxPersistedRecommendations+5; i++ {
+		recommendations = append(recommendations, timestampedRecommendation{replicas: int32(100 - i), timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	compacted = compactRecommendations(recommendations)
+	if len(compacted) != maxPersistedRecommendations || compacted[0].replicas != 100 || !compacted[0].timestamp.Equal(now.Add(5*time.Second)) {
+		t.Errorf("expected %d recommendations starting with 100 replicas at 5s, got %+v", maxPersistedRecommendations, compacted)
+	}
+
+	// the stabilization window of a PodAutoscaler first seen after a restart starts from its persisted state.
+	annotations := map[string]string{scalingcontext.ScaleDownStabilizationWindowLabel: "300s"}
+	pa := newTestPodAutoscaler(nil, 10, annotations)
+	pa.Status.ScalingState = &autoscalingv1alpha1.ScalingState{
+		Recommendations: []autoscalingv1alpha1.ScalingRecommendation{
+			{Time: at(-400 * time.Second), Replicas: 9},
+			{Time: at(-100 * time.Second), Replicas: 8},
+		},
+	}
+	r, _ := newTestReconciler(t)
+	if got := r.stabilizeRecommendation(pa, 8, 2, now); got != 8 {
+		t.Errorf("expected the restored recommendation of 8 replicas to hold the scale down, got %d", got)
+	}
+
+	testCases := []struct {
+		name  string
+		state autoscalingv1alpha1.ScalingState
+		valid bool
+	}{
+		{name: "panicking", state: autoscalingv1alpha1.ScalingState{PanicTime: &metav1.Time{Time: now}, MaxPanicReplicas: 4}, valid: true},
+		{name: "clock skew", state: autoscalingv1alpha1.ScalingState{PanicTime: &metav1.Time{Time: now.Add(30 * time.Second)}}, valid: true},
+		{name: "future panic", state: autoscalingv1alpha1.ScalingState{PanicTime: &metav1.Time{Time: now.Add(time.Hour)}}},
+		{name: "negative panic replicas", state: autoscalingv1alpha1.ScalingState{MaxPanicReplicas: -1}},
+		{name: "negative replicas", state: autoscalingv1alpha1.ScalingState{Recommendations: []autoscalingv1alpha1.ScalingRecommendation{{Time: at(0), Replicas: -2}}}},
+		{name: "unordered", state: autoscalingv1alpha1.ScalingState{Recommendations: []autoscalingv1alpha1.ScalingRecommendation{{Time: at(0), Replicas: 2}, {Time: at(-time.Second), Replicas: 1}}}},
+		{name: "oversized", state: autoscalingv1alpha1.ScalingState{Recommendations: make([]autoscalingv1alpha1.ScalingRecommendation, maxPersistedRecommendations+1)}},
+	}
+	for _, tc := range testCases {
+		pa.Status.ScalingState = tc.state.DeepCopy()
+		restored := restoredScalingState(pa, now)
+		if !tc.valid {
+			// the PodAutoscaler starts cold.
+			if restored != nil {
+				t.Errorf("%s: expected the invalid state to be ignored, got %+v", tc.name, restored)
+			}
+			continue
+		}
+		if restored == nil {
+			t.Errorf("%s: expected the state to be restored", tc.name)
+			continue
+		}
+		if restored.PanicTime != nil && restored.PanicTime.After(now) {
+			t.Errorf("%s: expected the panic time to be moved back to now, got %v", tc.name, restored.PanicTime)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+// defaultScaleDownStabilizationWindow is how far back the KPA recommendations are considered when scaling down.
+const defaultScaleDownStabilizationWindow = 300 * time.Second
+
+// timestampedRecommendation is a desired replica count recommended by the scaling algorithm.
+type timestampedRecommendation struct {
+	replicas  int32
+	timestamp time.Time
+}
+
+// timedMaxWindow keeps the recommendations of a PodAutoscaler over a trailing window and reports the highest of
+// them. The recommendations which fall out of the window are pruned as they are recorded and read.
+type timedMaxWindow struct {
+	window          time.Duration
+	recommendations []timestampedRecommendation
+}
+
+// newTimedMaxWindow returns an empty window of the given length.
+func newTimedMaxWindow(window time.Duration) *timedMaxWindow {
+	return &timedMaxWindow{window: window}
+}
+
+// Record adds the recommendation made at now.
+func (w *timedMaxWindow) Record(now time.Time, replicas int32) {
+	w.prune(now)
+	w.recommendations = append(w.recommendations, timestampedRecommendation{replicas: replicas, timestamp: now})
+}
+
+// Max returns the highest recommendation within the window ending at now, false if there is none.
+func (w *timedMaxWindow) Max(now time.Time) (int32, bool) {
+	w.prune(now)
+	if len(w.recommendations) == 0 {
+		return 0, false
+	}
+	max := w.recommendations[0].replicas
+	for _, rec := range w.recommendations[1:] {
+		if rec.replicas > max {
+			max = rec.replicas
+		}
+	}
+	return max, true
+}
+
+// prune drops the recommendations made before the window ending at now.
+func (w *timedMaxWindow) prune(now time.Time) {
+	cutoff := now.Add(-w.window)
+	kept := w.recommendations[:0]
+	for _, rec := range w.recommendations {
+		if rec.timestamp.After(cutoff) {
+			kept = append(kept, rec)
+		}
+	}
+	w.recommendations = kept
+}
+
+// getScaleDownStabilizationWindow returns the scale down stabilization window of the PodAutoscaler.
+func getScaleDownStabilizationWindow(pa *autoscalingv1alpha1.PodAutoscaler) time.Duration {
+	value, ok := pa.Annotations[scalingcontext.ScaleDownStabilizationWindowLabel]
+	if !ok {
+		return defaultScaleDownStabilizationWindow
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window < 0 {
+		klog.InfoS("Invalid scale down stabilization window, falling back to default", "PodAutoscaler", klog.KObj(pa), "window", value)
+		return defaultScaleDownStabilizationWindow
+	}
+	return window
+}
+
+// stabilizeRecommendation records the recommendation of the scaling algorithm and returns the desired replicas.
+// Scale ups are applied immediately, while a scale down only goes as low as the highest recommendation within
+// the stabilization window, so that bursty traffic does not make the replicas flap.
+func (r *PodAutoscalerReconciler) stabilizeRecommendation(pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas, desiredReplicas int32, now time.Time) int32 {
+	key := types.NamespacedName{Namespace: pa.Namespace, Name: pa.Name}
+	window := getScaleDownStabilizationWindow(pa)
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	if window == 0 {
+		delete(r.recommendations, key)
+		return desiredReplicas
+	}
+
+	if r.recommendations == nil {
+		r.recommendations = make(map[types.NamespacedName]*timedMaxWindow)
+	}
+	// the window of a PodAutoscaler first seen since the restart of the controller starts from the recommendations
+	// persisted in its status, the window of a new one starts empty and its first recommendation applies directly.
+	recommendations, ok := r.recommendations[key]
+	if !ok {
+		recommendations = newTimedMaxWindow(window)
+		restoreRecommendations(pa, recommendations, now)
+		r.recommendations[key] = recommendations
+	}
+	recommendations.window = window
+	recommendations.Record(now, desiredReplicas)
+
+	if desiredReplicas >= currentReplicas {
+		return desiredReplicas
+	}
+	stabilized, _ := recommendations.Max(now)
+	if stabilized > currentReplicas {
+		stabilized = currentReplicas
+	}
+	if stabilized != desiredReplicas {
+		klog.V(4).InfoS("Scale down stabilized", "PodAutoscaler", klog.KObj(pa),
+			"recommendedReplicas", desiredReplicas, "stabilizedReplicas", stabilized, "window", window)
+	}
+	return stabilized
+}
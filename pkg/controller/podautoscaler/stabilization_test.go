@@ -0,0 +1,161 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func TestKPAScaleDownStabilization(t *testing.T) {
+	annotations := map[string]string{scalingcontext.ScaleDownStabilizationWindowLabel: "300s"}
+	pa := newTestPodAutoscaler(nil, 10, annotations)
+	r, _ := newTestReconciler(t)
+	now := time.Now()
+
+	steps := []struct {
+		elapsed  time.Duration
+		current  int32
+		desired  int32
+		expected int32
+	}{
+		// scale ups are applied immediately.
+		{elapsed: 0, current: 5, desired: 8, expected: 8},
+		// the scale down is held by the recommendation of 8 replicas within the window.
+		{elapsed: 10 * time.Second, current: 8, desired: 3, expected: 8},
+		{elapsed: 200 * time.Second, current: 8, desired: 4, expected: 8},
+		// the recommendations of 8 and 3 replicas are out of the window.
+		{elapsed: 320 * time.Second, current: 8, desired: 4, expected: 4},
+	}
+	for i, step := range steps {
+		if got := r.stabilizeRecommendation(pa, step.current, step.desired, now.Add(step.elapsed)); got != step.expected {
+			t.Errorf("step #%d: expected %d replicas, got %d", i, step.expected, got)
+		}
+	}
+
+	// the history is dropped with the PodAutoscaler.
+	r.deleteStaleScalerInCache(types.NamespacedName{Namespace: testNamespace, Name: testPaName})
+	if _, ok := r.recommendations[types.NamespacedName{Namespace: testNamespace, Name: testPaName}]; ok {
+		t.Errorf("expected the recommendations of the deleted PodAutoscaler to be removed")
+	}
+}
+
+func TestGetScaleDownStabilizationWindow(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: defaultScaleDownStabilizationWindow},
+		{value: "90s", expected: 90 * time.Second},
+		{value: "0s", expected: 0},
+		{value: "-1s", expected: defaultScaleDownStabilizationWindow},
+		{value: "soon", expected: defaultScaleDownStabilizationWindow},
+	}
+	for _, tc := range testCases {
+		var annotations map[string]string
+		if tc.value != "" {
+			annotations = map[string]string{scalingcontext.ScaleDownStabilizationWindowLabel: tc.value}
+		}
+		if got := getScaleDownStabilizationWindow(newTestPodAutoscaler(nil, 10, annotations)); got != tc.expected {
+			t.Errorf("%q: expected window %v, got %v", tc.value, tc.expected, got)
+		}
+	}
+}
+
+func TestTimedMaxWindow(t *testing.T) {
+	w := newTimedMaxWindow(time.Minute)
+	now := time.Now()
+	if _, ok := w.Max(now); ok {
+		t.Fatalf("expected an empty window to have no max")
+	}
+	w.Record(now, 5)
+	w.Record(now.Add(20*time.Second), 2)
+	w.Record(now.Add(40*time.Second), 3)
+	if max, _ := w.Max(now.Add(40 * time.Second)); max != 5 {
+		t.Errorf("expected the max of 5 within the window, got %d", max)
+	}
+	// the recommendation of 5 falls out of the window and is pruned.
+	if max, _ := w.Max(now.Add(70 * time.Second)); max != 3 {
+		t.Errorf("expected the max of 3 once 5 is out of the window, got %d", max)
+	}
+	if len(w.recommendations) != 2 {
+		t.Errorf("expected 2 recommendations to be kept, got %d", len(w.recommendations))
+	}
+	if _, ok := w.Max(now.Add(2 * time.Minute)); ok {
+		t.Errorf("expected all the recommendations to be pruned")
+	}
+}
+
+func TestKPAScaleDownDelayIgnoresShortDips(t *testing.T) {
+	type step struct {
+		elapsed  time.Duration
+		desired  int32
+		expected int32
+	}
+	testCases := []struct {
+		name  string
+		delay string
+		steps []step
+		// expectNoState is set if no recommendation is expected to be kept.
+		expectNoState bool
+	}{
+		{
+			name:  "a dip of a single recommendation does not scale down",
+			delay: "60s",
+			steps: []step{{0, 6, 6}, {15 * time.Second, 2, 6}, {30 * time.Second, 6, 6}},
+		},
+		{
+			name:  "a drop sustained over the delay scales down",
+			delay: "60s",
+			steps: []step{{0, 6, 6}, {15 * time.Second, 2, 6}, {30 * time.Second, 6, 6}, {45 * time.Second, 2, 6},
+				{60 * time.Second, 2, 6}, {75 * time.Second, 2, 6}, {90 * time.Second, 2, 2}, {2 * time.Minute, 2, 2}},
+		},
+		{
+			name:          "a delay of 0 scales down immediately",
+			delay:         "0s",
+			steps:         []step{{0, 2, 2}},
+			expectNoState: true,
+		},
+		{
+			// e.g. after a restart the empty window lets the first recommendation apply.
+			name:  "the first recommendation applies",
+			delay: "60s",
+			steps: []step{{0, 2, 2}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pa := newTestPodAutoscaler(nil, 10, map[string]string{scalingcontext.ScaleDownStabilizationWindowLabel: tc.delay})
+			r, _ := newTestReconciler(t)
+			now := time.Now()
+			current := int32(6)
+			for i, step := range tc.steps {
+				current = r.stabilizeRecommendation(pa, current, step.desired, now.Add(step.elapsed))
+				if current != step.expected {
+					t.Errorf("step #%d: expected %d replicas, got %d", i, step.expected, current)
+				}
+			}
+			if tc.expectNoState && len(r.recommendations) != 0 {
+				t.Errorf("expected no recommendations to be kept, got %d", len(r.recommendations))
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	podutils "github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// setCurrentReplicasAndMetricsInStatus sets the current replica count and metrics in the status of the PA.
+func (r *PodAutoscalerReconciler) setCurrentReplicasAndMetricsInStatus(pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas int32, metricStatuses []autoscalingv1alpha1.MetricStatus) {
+	r.setStatus(pa, currentReplicas, pa.Status.DesiredScale, metricStatuses, false)
+}
+
+// setStatus recreates the status of the given PA, updating the current and
+// desired replicas, as well as the metric statuses. The metric statuses of the last evaluation are kept if the
+// metrics were not evaluated, e.g. while they are unavailable, their evaluation time tells how old they are.
+func (r *PodAutoscalerReconciler) setStatus(pa *autoscalingv1alpha1.PodAutoscaler, currentReplicas, desiredReplicas int32, metricStatuses []autoscalingv1alpha1.MetricStatus, rescale bool) {
+	if metricStatuses == nil {
+		metricStatuses = pa.Status.CurrentMetrics
+	}
+	pa.Status = autoscalingv1alpha1.PodAutoscalerStatus{
+		ActualScale:    currentReplicas,
+		DesiredScale:   desiredReplicas,
+		LastScaleTime:  pa.Status.LastScaleTime,
+		Conditions:     pa.Status.Conditions,
+		CurrentMetrics: metricStatuses,
+		LastDecision:   pa.Status.LastDecision,
+		ScalingState:   pa.Status.ScalingState,
+		// the generation is only observed once the reconcile succeeds.
+		ObservedGeneration: pa.Status.ObservedGeneration,
+	}
+	recordReplicas(pa, currentReplicas, desiredReplicas)
+
+	if rescale {
+		now := metav1.NewTime(r.now())
+		pa.Status.LastScaleTime = &now
+	}
+}
+
+func (r *PodAutoscalerReconciler) updateStatusIfNeeded(ctx context.Context, oldStatus *autoscalingv1alpha1.PodAutoscalerStatus, newPA *autoscalingv1alpha1.PodAutoscaler) error {
+	// skip status update if the status is not exact same
+	if !statusChanged(oldStatus, &newPA.Status) {
+		return nil
+	}
+	original := newPA.DeepCopy()
+	original.Status = *oldStatus.DeepCopy()
+	return r.updateStatus(ctx, original, newPA)
+}
+
+// statusChanged reports whether the status differs from the old one, the evaluation times of the current metrics
+// aside, so that the metrics evaluated to the same values on every sync do not write the status.
+func statusChanged(oldStatus, newStatus *autoscalingv1alpha1.PodAutoscalerStatus) bool {
+	status := *newStatus
+	if newStatus.CurrentMetrics != nil {
+		status.CurrentMetrics = make([]autoscalingv1alpha1.MetricStatus, len(newStatus.CurrentMetrics))
+		for i, metric := range newStatus.CurrentMetrics {
+			if i < len(oldStatus.CurrentMetrics) {
+				metric.LastEvaluationTime = oldStatus.CurrentMetrics[i].LastEvaluationTime
+			}
+			status.CurrentMetrics[i] = metric
+		}
+	}
+	return !apiequality.Semantic.DeepEqual(*oldStatus, status)
+}
+
+// updateStatus patches the status of the given PA with the changes since original, the PA as it was read. The patch
+// is guarded by the resource version it is computed from: on a conflict the latest PA is read again and the changes
+// are applied to its status, so that the fields written by others since are kept rather than overwritten with the
+// values of the stale copy.
+func (r *PodAutoscalerReconciler) updateStatus(ctx context.Context, original, pa *autoscalingv1alpha1.PodAutoscaler) error {
+	base := original
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		desired := base.DeepCopy()
+		mergeStatusChanges(&original.Status, &pa.Status, &desired.Status)
+		if apiequality.Semantic.DeepEqual(base.Status, desired.Status) {
+			return nil
+		}
+		err := r.Status().Patch(ctx, desired, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+		if apierrors.IsConflict(err) {
+			latest := &autoscalingv1alpha1.PodAutoscaler{}
+			if getErr := r.Get(ctx, client.ObjectKeyFromObject(pa), latest); getErr != nil {
+				return getErr
+			}
+			base = latest
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		pa.ResourceVersion = desired.ResourceVersion
+		pa.Status = desired.Status
+		return nil
+	})
+	if err != nil {
+		r.EventRecorder.Event(pa, corev1.EventTypeWarning, "FailedUpdateStatus", err.Error())
+		podAutoscalerErrors.WithLabelValues(stageUpdateStatus).Inc()
+		return fmt.Errorf("failed to update status for %s: %v", pa.Name, err)
+	}
+	logger := klog.FromContext(ctx)
+	logger.V(4).Info("Successfully updated status", "PodAutoscaler", klog.KObj(pa))
+	return nil
+}
+
+// mergeStatusChanges applies the changes of the status from original to updated onto latest. The conditions are
+// merged by type, so that the conditions the reconcile did not touch keep their latest value.
+func mergeStatusChanges(original, updated, latest *autoscalingv1alpha1.PodAutoscalerStatus) {
+	if original.ObservedGeneration != updated.ObservedGeneration {
+		latest.ObservedGeneration = updated.ObservedGeneration
+	}
+	if !apiequality.Semantic.DeepEqual(original.LastScaleTime, updated.LastScaleTime) {
+		latest.LastScaleTime = updated.LastScaleTime.DeepCopy()
+	}
+	if original.DesiredScale != updated.DesiredScale {
+		latest.DesiredScale = updated.DesiredScale
+	}
+	if original.ActualScale != updated.ActualScale {
+		latest.ActualScale = updated.ActualScale
+	}
+	if !apiequality.Semantic.DeepEqual(original.CurrentMetrics, updated.CurrentMetrics) {
+		latest.CurrentMetrics = append([]autoscalingv1alpha1.MetricStatus(nil), updated.CurrentMetrics...)
+	}
+	if !apiequality.Semantic.DeepEqual(original.LastDecision, updated.LastDecision) {
+		latest.LastDecision = updated.LastDecision.DeepCopy()
+	}
+	if !apiequality.Semantic.DeepEqual(original.ScalingState, updated.ScalingState) {
+		latest.ScalingState = updated.ScalingState.DeepCopy()
+	}
+
+	for _, condition := range updated.Conditions {
+		if previous := apimeta.FindStatusCondition(original.Conditions, condition.Type); previous != nil && apiequality.Semantic.DeepEqual(*previous, condition) {
+			continue
+		}
+		if current := apimeta.FindStatusCondition(latest.Conditions, condition.Type); current != nil {
+			*current = condition
+		} else {
+			latest.Conditions = append(latest.Conditions, condition)
+		}
+	}
+	for _, condition := range original.Conditions {
+		if apimeta.FindStatusCondition(updated.Conditions, condition.Type) == nil {
+			apimeta.RemoveStatusCondition(&latest.Conditions, condition.Type)
+		}
+	}
+}
+
+// observeGeneration records that the status of the PA reflects its current spec, once its conditions are pruned.
+func observeGeneration(pa *autoscalingv1alpha1.PodAutoscaler) {
+	pruneConditions(pa)
+	pa.Status.ObservedGeneration = pa.Generation
+}
+
+// podAutoscalerConditionTypes are the condition types the controller sets on a PodAutoscaler.
+var podAutoscalerConditionTypes = map[string]struct{}{
+	autoscalingv1alpha1.AbleToScale:             {},
+	autoscalingv1alpha1.ScalingActive:           {},
+	autoscalingv1alpha1.ScalingDisabled:         {},
+	autoscalingv1alpha1.NoReadyPods:             {},
+	autoscalingv1alpha1.DeprecatedConfiguration: {},
+	autoscalingv1alpha1.ReconcileTimeout:        {},
+	autoscalingv1alpha1.Active:                  {},
+	autoscalingv1alpha1.Panicking:               {},
+	autoscalingv1alpha1.InvalidStrategy:         {},
+	autoscalingv1alpha1.InvalidHPAMetrics:       {},
+	autoscalingv1alpha1.ConfigInvalid:           {},
+	autoscalingv1alpha1.PDBConstrained:          {},
+	autoscalingv1alpha1.ScalingLimited:          {},
+	autoscalingv1alpha1.RateLimited:             {},
+	autoscalingv1alpha1.QuotaLimited:            {},
+	autoscalingv1alpha1.RecommendationPublished: {},
+	autoscalingv1alpha1.ImportCompleted:         {},
+	autoscalingv1alpha1.AutoscalingIneffective:  {},
+	autoscalingv1alpha1.MetricsDegraded:         {},
+}
+
+// kpaConditionTypes are the condition types only a KPA PodAutoscaler has, they are stale once the strategy changes.
+var kpaConditionTypes = []string{autoscalingv1alpha1.Active, autoscalingv1alpha1.Panicking, autoscalingv1alpha1.ConfigInvalid}
+
+// pruneConditions removes the stale conditions of the PA: the types the controller no longer sets, and those which
+// do not apply to its scaling strategy.
+func pruneConditions(pa *autoscalingv1alpha1.PodAutoscaler) {
+	pa.Status.Conditions, _ = podutils.PruneConditions(pa.Status.Conditions, podAutoscalerConditionTypes)
+	if pa.Spec.ScalingStrategy != autoscalingv1alpha1.KPA {
+		for _, conditionType := range kpaConditionTypes {
+			pa.Status.Conditions, _ = podutils.RemoveCondition(pa.Status.Conditions, conditionType)
+		}
+	}
+	if pa.Spec.ScalingStrategy != autoscalingv1alpha1.HPA {
+		pa.Status.Conditions, _ = podutils.RemoveCondition(pa.Status.Conditions, autoscalingv1alpha1.InvalidHPAMetrics)
+	}
+}
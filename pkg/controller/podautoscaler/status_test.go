@@ -0,0 +1,218 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func TestReconcileStatusPatchConflicts(t *testing.T) {
+	minReplicas := int32(3)
+	testCases := []struct {
+		name      string
+		conflicts int
+		expectErr bool
+	}{
+		{
+			name:      "conflicts within the retry budget",
+			conflicts: 2,
+		},
+		{
+			name:      "conflicts exhausting the retry budget",
+			conflicts: 100,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var patches int
+			funcs := interceptor.Funcs{
+				SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+					if subResourceName != "status" {
+						return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+					}
+					patches++
+					if patch.Type() != types.MergePatchType {
+						t.Errorf("expected a merge patch, got %s", patch.Type())
+					}
+					if patches <= tc.conflicts {
+						return apierrors.NewConflict(autoscalingv1alpha1.Resource("podautoscalers"), obj.GetName(), errors.New("the object has been modified"))
+					}
+					return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+				},
+			}
+			r, recorder := newTestReconcilerWithInterceptor(t, funcs, newTestDeployment(1), newTestPodAutoscaler(&minReplicas, 10, nil))
+			err := reconcileTestPodAutoscaler(t, r)
+			if tc.expectErr != (err != nil) {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+
+			// the target is scaled whether or not its status could be written.
+			if replicas := getTestDeploymentReplicas(t, r); replicas != minReplicas {
+				t.Errorf("expected %d replicas, got %d", minReplicas, replicas)
+			}
+			pa := getTestPodAutoscaler(t, r)
+			if tc.expectErr {
+				if patches >= tc.conflicts {
+					t.Errorf("expected the conflicting status patch to be retried a bounded number of times, got %d attempts", patches)
+				}
+				if count := countEvents(recorder, "FailedUpdateStatus"); count != 1 {
+					t.Errorf("expected one FailedUpdateStatus event, got %d", count)
+				}
+				if pa.Status.DesiredScale != 0 {
+					t.Errorf("expected no persisted desired scale, got %d", pa.Status.DesiredScale)
+				}
+				return
+			}
+			if patches != tc.conflicts+1 {
+				t.Errorf("expected %d status patches, got %d", tc.conflicts+1, patches)
+			}
+			if pa.Status.DesiredScale != minReplicas {
+				t.Errorf("expected the desired scale %d, got %d", minReplicas, pa.Status.DesiredScale)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusRebuildsFromLatestOnConflict(t *testing.T) {
+	var patches, conflicts int
+	funcs := interceptor.Funcs{
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			patches++
+			err := c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			if apierrors.IsConflict(err) {
+				conflicts++
+			}
+			return err
+		},
+	}
+	pa := newTestPodAutoscaler(nil, 10, nil)
+	pa.Status.ActualScale = 1
+	pa.Status.Conditions = []metav1.Condition{
+		{Type: autoscalingv1alpha1.AbleToScale, Status: metav1.ConditionFalse, Reason: "FailedGetScale", LastTransitionTime: metav1.Now()},
+		{Type: autoscalingv1alpha1.ScalingLimited, Status: metav1.ConditionTrue, Reason: "TooFewReplicas", LastTransitionTime: metav1.Now()},
+	}
+	r, _ := newTestReconcilerWithInterceptor(t, funcs, newTestDeployment(1), pa)
+	original := getTestPodAutoscaler(t, r)
+
+	// another writer updates the status after the reconcile read the PodAutoscaler.
+	concurrent := original.DeepCopy()
+	concurrent.Status.ActualScale = 2
+	apimeta.SetStatusCondition(&concurrent.Status.Conditions, metav1.Condition{Type: autoscalingv1alpha1.ScalingActive, Status: metav1.ConditionTrue, Reason: "ValidMetricFound"})
+	if err := r.Status().Update(context.Background(), concurrent); err != nil {
+		t.Fatalf("failed to update the status concurrently: %v", err)
+	}
+
+	// the reconcile scales the target and clears the ScalingLimited condition.
+	updated := original.DeepCopy()
+	updated.Status.DesiredScale = 3
+	apimeta.SetStatusCondition(&updated.Status.Conditions, metav1.Condition{Type: autoscalingv1alpha1.AbleToScale, Status: metav1.ConditionTrue, Reason: "SucceededRescale"})
+	apimeta.RemoveStatusCondition(&updated.Status.Conditions, autoscalingv1alpha1.ScalingLimited)
+	if err := r.updateStatus(context.Background(), original, updated); err != nil {
+		t.Fatalf("updateStatus failed: %v", err)
+	}
+	if patches != 2 || conflicts != 1 {
+		t.Fatalf("expected the conflicting patch to be retried once, got %d patches and %d conflicts", patches, conflicts)
+	}
+
+	got := getTestPodAutoscaler(t, r)
+	if got.Status.DesiredScale != 3 {
+		t.Errorf("expected the desired scale 3, got %d", got.Status.DesiredScale)
+	}
+	// the actual scale the reconcile did not change is not reverted to its stale value.
+	if got.Status.ActualScale != 2 {
+		t.Errorf("expected the actual scale 2 written concurrently, got %d", got.Status.ActualScale)
+	}
+	if cond := apimeta.FindStatusCondition(got.Status.Conditions, autoscalingv1alpha1.AbleToScale); cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("expected AbleToScale to be true, got %+v", cond)
+	}
+	if cond := apimeta.FindStatusCondition(got.Status.Conditions, autoscalingv1alpha1.ScalingActive); cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("expected the ScalingActive condition written concurrently to be kept, got %+v", cond)
+	}
+	if cond := apimeta.FindStatusCondition(got.Status.Conditions, autoscalingv1alpha1.ScalingLimited); cond != nil {
+		t.Errorf("expected ScalingLimited to be removed, got %+v", cond)
+	}
+}
+
+func TestReconcileObservesGenerationAndPrunesConditions(t *testing.T) {
+	objs := newTestAPAObjects(3, "8000", nil)
+	pa := objs[1].(*autoscalingv1alpha1.PodAutoscaler)
+	pa.Generation = 2
+	transition := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	pa.Status.Conditions = []metav1.Condition{
+		{Type: autoscalingv1alpha1.AbleToScale, Status: metav1.ConditionTrue, Reason: "SucceededRescale", LastTransitionTime: transition, ObservedGeneration: 1},
+		// a condition of a previous version of the controller, and one left by the KPA strategy.
+		{Type: "ScalingUnbounded", Status: metav1.ConditionTrue, Reason: "Legacy", LastTransitionTime: transition},
+		{Type: autoscalingv1alpha1.Panicking, Status: metav1.ConditionFalse, Reason: "StableMode", LastTransitionTime: transition},
+	}
+	var statusPatches int
+	funcs := interceptor.Funcs{
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			if subResourceName == "status" {
+				statusPatches++
+			}
+			return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+		},
+	}
+	r, _ := newTestReconcilerWithInterceptor(t, funcs, objs...)
+	fetcher := metrics.NewFakeMetricFetcher()
+	for i := 0; i < 3; i++ {
+		fetcher.SetPodMetric(fmt.Sprintf("test-pod-%d", i), 4)
+	}
+	r.metricFetcher = fetcher
+
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	got := getTestPodAutoscaler(t, r)
+	if got.Status.ObservedGeneration != 2 {
+		t.Errorf("expected the generation 2 to be observed, got %d", got.Status.ObservedGeneration)
+	}
+	for _, conditionType := range []string{"ScalingUnbounded", autoscalingv1alpha1.Panicking} {
+		if cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionType); cond != nil {
+			t.Errorf("expected the stale %s condition to be pruned, got %+v", conditionType, cond)
+		}
+	}
+	cond := apimeta.FindStatusCondition(got.Status.Conditions, autoscalingv1alpha1.AbleToScale)
+	if cond == nil || cond.ObservedGeneration != 2 || !cond.LastTransitionTime.Equal(&transition) {
+		t.Errorf("expected AbleToScale observed at generation 2 without a transition, got %+v", cond)
+	}
+
+	// the status is not written again while nothing changes.
+	patches := statusPatches
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if statusPatches != patches {
+		t.Errorf("expected no status write for an unchanged status, got %d", statusPatches-patches)
+	}
+}
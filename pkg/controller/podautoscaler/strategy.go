@@ -0,0 +1,56 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+// checkValidAutoscalingStrategy checks if the strategy is HPA or a strategy registered in the scaler package.
+func checkValidAutoscalingStrategy(strategy autoscalingv1alpha1.ScalingStrategyType) bool {
+	return strategy == autoscalingv1alpha1.HPA || scaler.IsRegisteredStrategy(strategy)
+}
+
+// setInvalidStrategy reports an unknown scaling strategy with the InvalidStrategy condition and an event, emitted
+// once per generation of the PodAutoscaler. The target is left as is until the strategy is fixed.
+func (r *PodAutoscalerReconciler) setInvalidStrategy(ctx context.Context, pa *autoscalingv1alpha1.PodAutoscaler) error {
+	cond := apimeta.FindStatusCondition(pa.Status.Conditions, autoscalingv1alpha1.InvalidStrategy)
+	if cond != nil && cond.Status == metav1.ConditionTrue && cond.ObservedGeneration == pa.Generation {
+		return nil
+	}
+	paStatusOriginal := pa.Status.DeepCopy()
+	message := fmt.Sprintf("unknown scaling strategy %s, valid strategies are HPA and %s",
+		pa.Spec.ScalingStrategy, strings.Join(scaler.RegisteredStrategies(), ", "))
+	r.EventRecorder.Event(pa, corev1.EventTypeWarning, "InvalidStrategy", message)
+	apimeta.SetStatusCondition(&pa.Status.Conditions, metav1.Condition{
+		Type:               autoscalingv1alpha1.InvalidStrategy,
+		Status:             metav1.ConditionTrue,
+		Reason:             "UnknownScalingStrategy",
+		Message:            message,
+		ObservedGeneration: pa.Generation,
+	})
+	return r.updateStatusIfNeeded(ctx, paStatusOriginal, pa)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+// fixedScaler is a toy scaling strategy which always recommends the same replicas.
+type fixedScaler struct {
+	replicas int32
+}
+
+func (f *fixedScaler) UpdateScaleTargetMetrics(context.Context, metrics.NamespaceNameMetric, autoscalingv1alpha1.MetricSource, []corev1.Pod, time.Time) error {
+	return nil
+}
+
+func (f *fixedScaler) UpdateSourceMetrics(context.Context, metrics.NamespaceNameMetric, autoscalingv1alpha1.MetricSource, time.Time) error {
+	return nil
+}
+
+func (f *fixedScaler) UpdateMetrics(metrics.NamespaceNameMetric, time.Time, ...float64) error {
+	return nil
+}
+
+func (f *fixedScaler) Scale(int, metrics.NamespaceNameMetric, time.Time) scaler.ScaleResult {
+	return scaler.ScaleResult{DesiredPodCount: f.replicas, ScaleValid: true, ObservedValue: 1}
+}
+
+func (f *fixedScaler) UpdateScalingContext(autoscalingv1alpha1.PodAutoscaler) error {
+	return nil
+}
+
+func (f *fixedScaler) GetScalingContext() scalingcontext.ScalingContext {
+	return scalingcontext.NewBaseScalingContext()
+}
+
+const fixedStrategy autoscalingv1alpha1.ScalingStrategyType = "Fixed"
+
+var registerFixedStrategy sync.Once
+
+func TestReconcileRegisteredStrategy(t *testing.T) {
+	registerFixedStrategy.Do(func() {
+		scaler.RegisterStrategy(string(fixedStrategy), func(spec scaler.ScalerSpec) (scaler.Scaler, error) {
+			return &fixedScaler{replicas: 3}, nil
+		})
+	})
+
+	// an unknown strategy is reported once and the target is left as is.
+	objs := newTestAPAObjects(2, "8000", nil)
+	objs[1].(*autoscalingv1alpha1.PodAutoscaler).Spec.ScalingStrategy = "Unknown"
+	r, recorder := newTestReconciler(t, objs...)
+	for i := 0; i < 2; i++ {
+		if err := reconcileTestPodAutoscaler(t, r); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+	}
+	if count := countEvents(recorder, "InvalidStrategy"); count != 1 {
+		t.Errorf("expected one InvalidStrategy event, got %d", count)
+	}
+	pa := getTestPodAutoscaler(t, r)
+	if !apimeta.IsStatusConditionTrue(pa.Status.Conditions, autoscalingv1alpha1.InvalidStrategy) {
+		t.Fatalf("expected InvalidStrategy to be true, got %+v", pa.Status.Conditions)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 2 {
+		t.Errorf("expected the replicas to be kept at 2, got %d", replicas)
+	}
+
+	// the registered strategy scales the target without changes to the controller.
+	pa.Spec.ScalingStrategy = fixedStrategy
+	if err := r.Update(context.Background(), pa); err != nil {
+		t.Fatalf("failed to update PodAutoscaler: %v", err)
+	}
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 3 {
+		t.Errorf("expected 3 replicas, got %d", replicas)
+	}
+	if cond := apimeta.FindStatusCondition(getTestPodAutoscaler(t, r).Status.Conditions, autoscalingv1alpha1.InvalidStrategy); cond != nil {
+		t.Errorf("expected the InvalidStrategy condition to be removed, got %+v", cond)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+const (
+	// DefaultSyncPeriod is how often a KPA or APA PodAutoscaler re-evaluates its metrics. The metrics change
+	// without watch events, the PodAutoscaler is requeued after every reconcile instead.
+	DefaultSyncPeriod = 15 * time.Second
+	// DefaultMaxConcurrentReconciles is the number of PodAutoscalers reconciled concurrently.
+	DefaultMaxConcurrentReconciles = 1
+
+	// errorBackoffBase and errorBackoffMax bound the exponential backoff of a PodAutoscaler whose reconcile
+	// failed, the backoff is reset by the next successful reconcile.
+	errorBackoffBase = time.Second
+	errorBackoffMax  = 5 * time.Minute
+)
+
+// getSyncPeriod returns the sync period of the PodAutoscaler, its annotation overrides the sync period of the
+// controller.
+func (r *PodAutoscalerReconciler) getSyncPeriod(pa *autoscalingv1alpha1.PodAutoscaler) time.Duration {
+	syncPeriod := r.RuntimeConfig.PodAutoscalerSyncPeriod
+	if syncPeriod <= 0 {
+		syncPeriod = DefaultSyncPeriod
+	}
+	value, ok := pa.Annotations[scalingcontext.SyncPeriodLabel]
+	if !ok {
+		return syncPeriod
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period <= 0 {
+		klog.InfoS("Invalid sync period, falling back to default", "PodAutoscaler", klog.KObj(pa), "syncPeriod", value)
+		return syncPeriod
+	}
+	return period
+}
+
+// newReconcileRateLimiter backs off the PodAutoscalers whose reconcile failed exponentially, the default rate
+// limiter of the controller retries after a few milliseconds.
+func newReconcileRateLimiter() workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](errorBackoffBase, errorBackoffMax)
+}
+
+// recordMetricsFailure counts a reconcile of the PodAutoscaler which held its replicas for unavailable metrics, and
+// returns how long it waits before fetching them again. The first retry waits for the sync period, every consecutive
+// failure doubles it, up to errorBackoffMax or the sync period if it is longer.
+func (r *PodAutoscalerReconciler) recordMetricsFailure(pa *autoscalingv1alpha1.PodAutoscaler) time.Duration {
+	key := types.NamespacedName{Namespace: pa.Namespace, Name: pa.Name}
+	r.stateMu.Lock()
+	if r.metricsFailures == nil {
+		r.metricsFailures = make(map[types.NamespacedName]int)
+	}
+	r.metricsFailures[key]++
+	failures := r.metricsFailures[key]
+	r.stateMu.Unlock()
+
+	syncPeriod := r.getSyncPeriod(pa)
+	maxBackoff := max(errorBackoffMax, syncPeriod)
+	backoff := syncPeriod
+	for i := 1; i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+	klog.V(4).InfoS("Backing off the metrics of the PodAutoscaler", "PodAutoscaler", klog.KObj(pa),
+		"failures", failures, "backoff", backoff)
+	return backoff
+}
+
+// resetMetricsFailures resets the metrics backoff of the PodAutoscaler after a successful reconcile.
+func (r *PodAutoscalerReconciler) resetMetricsFailures(pa *autoscalingv1alpha1.PodAutoscaler) {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	delete(r.metricsFailures, types.NamespacedName{Namespace: pa.Namespace, Name: pa.Name})
+}
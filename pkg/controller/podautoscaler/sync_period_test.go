@@ -0,0 +1,123 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func TestGetSyncPeriod(t *testing.T) {
+	testCases := []struct {
+		value    string
+		global   time.Duration
+		expected time.Duration
+	}{
+		{expected: DefaultSyncPeriod},
+		{global: 20 * time.Second, expected: 20 * time.Second},
+		{value: "30s", global: 20 * time.Second, expected: 30 * time.Second},
+		{value: "0s", expected: DefaultSyncPeriod},
+		{value: "soon", global: 20 * time.Second, expected: 20 * time.Second},
+	}
+	for _, tc := range testCases {
+		r := &PodAutoscalerReconciler{}
+		r.RuntimeConfig.PodAutoscalerSyncPeriod = tc.global
+		var annotations map[string]string
+		if tc.value != "" {
+			annotations = map[string]string{scalingcontext.SyncPeriodLabel: tc.value}
+		}
+		if got := r.getSyncPeriod(newTestPodAutoscaler(nil, 10, annotations)); got != tc.expected {
+			t.Errorf("%q with sync period %v: expected %v, got %v", tc.value, tc.global, tc.expected, got)
+		}
+	}
+}
+
+func TestReconcileKPARequeuesOnMetricDrift(t *testing.T) {
+	objs := newTestAPAObjects(3, "8000", map[string]string{scalingcontext.SyncPeriodLabel: "30s"})
+	objs[1].(*autoscalingv1alpha1.PodAutoscaler).Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+	r, _ := newTestReconciler(t, objs...)
+	fetcher := metrics.NewFakeMetricFetcher()
+	r.metricFetcher = fetcher
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: testPaName}}
+	reconcileOnRequeue := func() {
+		t.Helper()
+		result, err := r.Reconcile(context.Background(), request)
+		if err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		if result.RequeueAfter != 30*time.Second {
+			t.Errorf("expected a requeue after the sync period of 30s, got %v", result.RequeueAfter)
+		}
+	}
+
+	// the 20 requests of the 3 pods need 5 pods at the target of 4.
+	fetcher.SetPodMetric("test-pod-0", 8)
+	fetcher.SetPodMetric("test-pod-1", 8)
+	fetcher.SetPodMetric("test-pod-2", 4)
+	reconcileOnRequeue()
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 5 {
+		t.Fatalf("expected the target to be scaled from 3 to 5 replicas, got %d", replicas)
+	}
+
+	// the load grows without any object edit, the requeued reconcile scales the target further.
+	for i := 0; i < 3; i++ {
+		fetcher.SetPodMetric(fmt.Sprintf("test-pod-%d", i), 16)
+	}
+	reconcileOnRequeue()
+	if replicas := getTestDeploymentReplicas(t, r); replicas <= 5 || replicas > 10 {
+		t.Errorf("expected the target to be scaled above 5 replicas within the max of 10, got %d", replicas)
+	}
+}
+
+func TestRecordMetricsFailure(t *testing.T) {
+	pa := &autoscalingv1alpha1.PodAutoscaler{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testPaName}}
+	r := &PodAutoscalerReconciler{}
+	r.RuntimeConfig.PodAutoscalerSyncPeriod = time.Minute
+
+	var backoffs []time.Duration
+	for i := 0; i < 8; i++ {
+		backoffs = append(backoffs, r.recordMetricsFailure(pa))
+	}
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, errorBackoffMax, errorBackoffMax,
+		errorBackoffMax, errorBackoffMax, errorBackoffMax}
+	if !reflect.DeepEqual(backoffs, expected) {
+		t.Errorf("expected the backoffs %v, got %v", expected, backoffs)
+	}
+
+	// a sync period longer than the cap is not shortened.
+	pa.Annotations = map[string]string{scalingcontext.SyncPeriodLabel: "10m"}
+	if backoff := r.recordMetricsFailure(pa); backoff != 10*time.Minute {
+		t.Errorf("expected the backoff to stay at the sync period of 10m, got %v", backoff)
+	}
+
+	r.resetMetricsFailures(pa)
+	pa.Annotations = nil
+	if backoff := r.recordMetricsFailure(pa); backoff != time.Minute {
+		t.Errorf("expected the reset backoff to start at the sync period again, got %v", backoff)
+	}
+}
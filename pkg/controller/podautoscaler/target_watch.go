@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+)
+
+// scaleTargetField indexes the PodAutoscalers by the group, kind and name of their scale target, so that the
+// changes of a workload only enqueue the PodAutoscalers targeting it. The index follows the PodAutoscalers as they
+// are created, retargeted and deleted.
+const scaleTargetField = "spec.scaleTargetRef"
+
+// watchedScaleTargets are the kinds of the scale targets whose replica changes are watched, so that a target scaled
+// out of policy, e.g. by hand or by another controller, is corrected without waiting for the next requeue.
+var watchedScaleTargets = map[schema.GroupKind]client.Object{
+	{Group: appsv1.GroupName, Kind: "Deployment"}:  &appsv1.Deployment{},
+	{Group: appsv1.GroupName, Kind: "StatefulSet"}: &appsv1.StatefulSet{},
+}
+
+// scaleTargetKey returns the key of the scale target in the scaleTargetField index.
+func scaleTargetKey(gk schema.GroupKind, name string) string {
+	return gk.Group + "/" + gk.Kind + "/" + name
+}
+
+// indexScaleTarget returns the scaleTargetField index key of the PodAutoscaler.
+func indexScaleTarget(obj client.Object) []string {
+	pa, ok := obj.(*autoscalingv1alpha1.PodAutoscaler)
+	if !ok || pa.Spec.ScaleTargetRef.Name == "" {
+		return nil
+	}
+	ref := pa.Spec.ScaleTargetRef
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil
+	}
+	return []string{scaleTargetKey(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, ref.Name)}
+}
+
+// lookupPodAutoscalersForTarget enqueues the PodAutoscalers of the namespace targeting the workload of the kind.
+func lookupPodAutoscalersForTarget(c client.Reader, gk schema.GroupKind) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		pas := &autoscalingv1alpha1.PodAutoscalerList{}
+		if err := c.List(ctx, pas, client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{scaleTargetField: scaleTargetKey(gk, obj.GetName())}); err != nil {
+			klog.ErrorS(err, "failed to list the PodAutoscalers of the scale target", "kind", gk.Kind, "target", klog.KObj(obj))
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(pas.Items))
+		for _, pa := range pas.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pa.Namespace, Name: pa.Name}})
+		}
+		return requests
+	}
+}
+
+// scaleTargetReplicasChanged only passes the updates of a scale target changing its desired replicas, the frequent
+// status updates of the workload are skipped. The creations and deletions of a target are passed.
+func scaleTargetReplicasChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldReplicas, ok := specReplicas(e.ObjectOld)
+			if !ok {
+				return true
+			}
+			newReplicas, ok := specReplicas(e.ObjectNew)
+			if !ok {
+				return true
+			}
+			return oldReplicas != newReplicas
+		},
+		GenericFunc: func(event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+// specReplicas returns the desired replicas of the watched scale target, 1 if unset as defaulted by the API server.
+func specReplicas(obj client.Object) (int32, bool) {
+	var replicas *int32
+	switch target := obj.(type) {
+	case *appsv1.Deployment:
+		replicas = target.Spec.Replicas
+	case *appsv1.StatefulSet:
+		replicas = target.Spec.Replicas
+	default:
+		return 0, false
+	}
+	if replicas == nil {
+		return 1, true
+	}
+	return *replicas, true
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/metrics"
+)
+
+func TestReconcileCorrectsManuallyScaledTarget(t *testing.T) {
+	objs := newTestAPAObjects(5, "8000", map[string]string{scalingcontext.MaxScaleUpRateLabel: "10"})
+	objs[1].(*autoscalingv1alpha1.PodAutoscaler).Spec.ScalingStrategy = autoscalingv1alpha1.KPA
+	otherTarget := newTestPodAutoscaler(nil, 10, nil)
+	otherTarget.Name = "other-pa"
+	otherTarget.Spec.ScaleTargetRef.Name = "other-deployment"
+	otherKind := newTestPodAutoscaler(nil, 10, nil)
+	otherKind.Name = "statefulset-pa"
+	otherKind.Spec.ScaleTargetRef.Kind = "StatefulSet"
+	r, _ := newTestReconciler(t, append(objs, otherTarget, otherKind)...)
+	fetcher := metrics.NewFakeMetricFetcher()
+	// the 20 requests of the 5 pods need 5 pods at the target of 4.
+	for i := 0; i < 5; i++ {
+		fetcher.SetPodMetric(fmt.Sprintf("test-pod-%d", i), 4)
+	}
+	r.metricFetcher = fetcher
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 5 {
+		t.Fatalf("expected 5 replicas, got %d", replicas)
+	}
+
+	// the Deployment is scaled down by hand.
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: testDeployName}, deploy); err != nil {
+		t.Fatalf("failed to get Deployment: %v", err)
+	}
+	scaled := deploy.DeepCopy()
+	one := int32(1)
+	scaled.Spec.Replicas = &one
+	if err := r.Update(context.Background(), scaled); err != nil {
+		t.Fatalf("failed to scale down Deployment: %v", err)
+	}
+
+	// the replica change enqueues the PodAutoscaler of the Deployment only, the status updates are skipped.
+	p := scaleTargetReplicasChanged()
+	if !p.Update(event.UpdateEvent{ObjectOld: deploy, ObjectNew: scaled}) {
+		t.Errorf("expected the replica change of the target to be watched")
+	}
+	statusOnly := deploy.DeepCopy()
+	statusOnly.Status.ReadyReplicas = 4
+	if p.Update(event.UpdateEvent{ObjectOld: deploy, ObjectNew: statusOnly}) {
+		t.Errorf("expected the status update of the target to be skipped")
+	}
+	lookup := lookupPodAutoscalersForTarget(r.Client, schema.GroupKind{Group: appsv1.GroupName, Kind: "Deployment"})
+	requests := lookup(context.Background(), scaled)
+	if len(requests) != 1 || requests[0].Name != testPaName || requests[0].Namespace != testNamespace {
+		t.Fatalf("expected only %s to be enqueued, got %v", testPaName, requests)
+	}
+	unrelated := newTestDeployment(3)
+	unrelated.Name = "unrelated"
+	if requests := lookup(context.Background(), unrelated); len(requests) != 0 {
+		t.Errorf("expected no PodAutoscaler to be enqueued for an unrelated workload, got %v", requests)
+	}
+
+	// the enqueued reconcile corrects the drift.
+	if err := reconcileTestPodAutoscaler(t, r); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if replicas := getTestDeploymentReplicas(t, r); replicas != 5 {
+		t.Errorf("expected the replicas to be corrected back to 5, got %d", replicas)
+	}
+
+	// a retargeted PodAutoscaler is enqueued by its new target.
+	retargeted := &autoscalingv1alpha1.PodAutoscaler{}
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: "other-pa"}, retargeted); err != nil {
+		t.Fatalf("failed to get PodAutoscaler: %v", err)
+	}
+	retargeted.Spec.ScaleTargetRef.Name = "unrelated"
+	if err := r.Update(context.Background(), retargeted); err != nil {
+		t.Fatalf("failed to retarget PodAutoscaler: %v", err)
+	}
+	if requests := lookup(context.Background(), unrelated); len(requests) != 1 || requests[0].Name != "other-pa" {
+		t.Errorf("expected other-pa to be enqueued by its new target, got %v", requests)
+	}
+}
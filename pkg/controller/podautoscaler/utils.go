@@ -18,13 +18,22 @@ package podautoscaler
 
 import (
 	"fmt"
+	"time"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
 )
 
+// defaultNoReadyPodsGracePeriod is how long a target must have no ready pods before its NoReadyPodsPolicy applies.
+const defaultNoReadyPodsGracePeriod = time.Minute
+
 // extractLabelSelector extracts a LabelSelector from the given scale object.
 func extractLabelSelector(scale *unstructured.Unstructured) (labels.Selector, error) {
 	// Retrieve the selector string from the Scale object's 'spec' field.
@@ -50,3 +59,57 @@ func extractLabelSelector(scale *unstructured.Unstructured) (labels.Selector, er
 
 	return labelsSelector, nil
 }
+
+// getMinReplicas returns the lower bound of replicas managed by the autoscaler. A PodAutoscaler which does not set
+// MinReplicas falls back to 0 if it opts in to scale-to-zero, an explicit MinReplicas is kept even then.
+func getMinReplicas(pa *autoscalingv1alpha1.PodAutoscaler) int32 {
+	if pa.Spec.MinReplicas != nil {
+		return *pa.Spec.MinReplicas
+	}
+	return scaler.DefaultMinReplicasOf(pa)
+}
+
+// getNoReadyPodsPolicy returns the policy applied when the target has no ready pods, defaulting to Hold.
+func getNoReadyPodsPolicy(pa *autoscalingv1alpha1.PodAutoscaler) autoscalingv1alpha1.NoReadyPodsPolicy {
+	value, ok := pa.Annotations[scalingcontext.NoReadyPodsPolicyLabel]
+	if !ok {
+		return autoscalingv1alpha1.NoReadyPodsHold
+	}
+	switch policy := autoscalingv1alpha1.NoReadyPodsPolicy(value); policy {
+	case autoscalingv1alpha1.NoReadyPodsHold, autoscalingv1alpha1.NoReadyPodsScaleToMin, autoscalingv1alpha1.NoReadyPodsScaleToMax:
+		return policy
+	default:
+		klog.InfoS("Invalid no ready pods policy, falling back to Hold", "PodAutoscaler", klog.KObj(pa), "policy", value)
+		return autoscalingv1alpha1.NoReadyPodsHold
+	}
+}
+
+// getNoReadyPodsGracePeriod returns how long the target must have no ready pods before the policy applies.
+func getNoReadyPodsGracePeriod(pa *autoscalingv1alpha1.PodAutoscaler) time.Duration {
+	value, ok := pa.Annotations[scalingcontext.NoReadyPodsGracePeriodLabel]
+	if !ok {
+		return defaultNoReadyPodsGracePeriod
+	}
+	gracePeriod, err := time.ParseDuration(value)
+	if err != nil || gracePeriod < 0 {
+		klog.InfoS("Invalid no ready pods grace period, falling back to default", "PodAutoscaler", klog.KObj(pa), "gracePeriod", value)
+		return defaultNoReadyPodsGracePeriod
+	}
+	return gracePeriod
+}
+
+// getActuationMode returns how the desired replicas are applied to the target, defaulting to ScaleSubresource.
+func getActuationMode(pa *autoscalingv1alpha1.PodAutoscaler) autoscalingv1alpha1.ActuationMode {
+	value, ok := pa.Annotations[scalingcontext.ActuationModeLabel]
+	if !ok {
+		return autoscalingv1alpha1.ActuationModeScaleSubresource
+	}
+	switch mode := autoscalingv1alpha1.ActuationMode(value); mode {
+	case autoscalingv1alpha1.ActuationModeScaleSubresource, autoscalingv1alpha1.ActuationModeDirectPatch,
+		autoscalingv1alpha1.ActuationModeExternal:
+		return mode
+	default:
+		klog.InfoS("Invalid actuation mode, falling back to ScaleSubresource", "PodAutoscaler", klog.KObj(pa), "mode", value)
+		return autoscalingv1alpha1.ActuationModeScaleSubresource
+	}
+}
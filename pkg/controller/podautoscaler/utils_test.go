@@ -0,0 +1,55 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podautoscaler
+
+import (
+	"testing"
+
+	"k8s.io/utils/ptr"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func TestGetMinReplicas(t *testing.T) {
+	testCases := []struct {
+		name        string
+		minReplicas *int32
+		scaleToZero string
+		expected    int32
+	}{
+		{name: "unset", expected: 1},
+		{name: "set", minReplicas: ptr.To(int32(3)), expected: 3},
+		{name: "zero", minReplicas: ptr.To(int32(0)), expected: 0},
+		{name: "unset with scale-to-zero", scaleToZero: "true", expected: 0},
+		{name: "set with scale-to-zero", minReplicas: ptr.To(int32(2)), scaleToZero: "true", expected: 2},
+		{name: "unset with scale-to-zero disabled", scaleToZero: "false", expected: 1},
+		{name: "unset with an invalid scale-to-zero", scaleToZero: "yes please", expected: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pa := &autoscalingv1alpha1.PodAutoscaler{Spec: autoscalingv1alpha1.PodAutoscalerSpec{MinReplicas: tc.minReplicas}}
+			if tc.scaleToZero != "" {
+				pa.Annotations = map[string]string{scalingcontext.ScaleToZeroLabel: tc.scaleToZero}
+			}
+			if got := getMinReplicas(pa); got != tc.expected {
+				t.Errorf("expected %d min replicas, got %d", tc.expected, got)
+			}
+		})
+	}
+}
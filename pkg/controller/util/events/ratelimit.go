@@ -0,0 +1,159 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// DefaultRateLimitInterval is how often an identical warning event of an object is emitted at most.
+const DefaultRateLimitInterval = 5 * time.Minute
+
+// eventKey identifies the identical events of an object: the message template is the format of Eventf and
+// AnnotatedEventf, and the message of Event.
+type eventKey struct {
+	object   string
+	reason   string
+	template string
+}
+
+type eventEntry struct {
+	lastEmitted time.Time
+	lastSeen    time.Time
+	suppressed  int
+}
+
+// RateLimitedRecorder wraps an EventRecorder so that an object stuck in a failure loop does not emit a warning event
+// on every reconcile. Identical warning events of an object are emitted at most once per interval, the next one
+// emitted after the interval reports how many were suppressed. Normal events are not limited.
+type RateLimitedRecorder struct {
+	recorder record.EventRecorder
+	interval time.Duration
+	clock    clock.PassiveClock
+
+	mu        sync.Mutex
+	entries   map[eventKey]*eventEntry
+	lastSweep time.Time
+}
+
+var _ record.EventRecorder = (*RateLimitedRecorder)(nil)
+
+// NewRateLimitedRecorder returns the recorder limiting the identical warning events of an object to one per
+// interval. The recorder is returned as is if the interval is not positive.
+func NewRateLimitedRecorder(recorder record.EventRecorder, interval time.Duration) record.EventRecorder {
+	if interval <= 0 {
+		return recorder
+	}
+	return NewRateLimitedRecorderWithClock(recorder, interval, clock.RealClock{})
+}
+
+// NewRateLimitedRecorderWithClock is NewRateLimitedRecorder with the given clock, for the tests.
+func NewRateLimitedRecorderWithClock(recorder record.EventRecorder, interval time.Duration, clk clock.PassiveClock) *RateLimitedRecorder {
+	return &RateLimitedRecorder{
+		recorder: recorder,
+		interval: interval,
+		clock:    clk,
+		entries:  make(map[eventKey]*eventEntry),
+	}
+}
+
+func (r *RateLimitedRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if suffix, ok := r.allow(object, eventtype, reason, message); ok {
+		r.recorder.Event(object, eventtype, reason, message+suffix)
+	}
+}
+
+func (r *RateLimitedRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if suffix, ok := r.allow(object, eventtype, reason, messageFmt); ok {
+		r.recorder.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...)+suffix)
+	}
+}
+
+func (r *RateLimitedRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if suffix, ok := r.allow(object, eventtype, reason, messageFmt); ok {
+		r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", fmt.Sprintf(messageFmt, args...)+suffix)
+	}
+}
+
+// allow reports whether the event is emitted, and the suffix reporting the identical events suppressed since the
+// last one was emitted.
+func (r *RateLimitedRecorder) allow(object runtime.Object, eventtype, reason, template string) (string, bool) {
+	if eventtype != corev1.EventTypeWarning {
+		return "", true
+	}
+	key := eventKey{object: objectKey(object), reason: reason, template: template}
+	now := r.clock.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(now)
+	entry, ok := r.entries[key]
+	if !ok {
+		r.entries[key] = &eventEntry{lastEmitted: now, lastSeen: now}
+		return "", true
+	}
+	entry.lastSeen = now
+	if now.Sub(entry.lastEmitted) < r.interval {
+		entry.suppressed++
+		klog.V(4).InfoS("Suppressed a repeated event", "object", key.object, "reason", reason, "suppressed", entry.suppressed)
+		return "", false
+	}
+
+	suffix := ""
+	if entry.suppressed > 0 {
+		suffix = fmt.Sprintf(" (%d similar events suppressed in the last %v)", entry.suppressed, now.Sub(entry.lastEmitted).Round(time.Second))
+	}
+	entry.lastEmitted = now
+	entry.suppressed = 0
+	return suffix, true
+}
+
+// sweep drops the entries whose event has not occurred for an interval, e.g. of the deleted objects or of the failures
+// which have been resolved, so that the entries do not grow with the churn of the objects. The next occurrence of
+// such an event is emitted anyway. It runs at most once per interval.
+func (r *RateLimitedRecorder) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.interval {
+		return
+	}
+	r.lastSweep = now
+	for key, entry := range r.entries {
+		if now.Sub(entry.lastSeen) >= r.interval {
+			delete(r.entries, key)
+		}
+	}
+}
+
+// objectKey identifies the object of an event by its UID, or by its namespace and name if it has no UID.
+func objectKey(object runtime.Object) string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return fmt.Sprintf("%T", object)
+	}
+	if uid := accessor.GetUID(); uid != "" {
+		return string(uid)
+	}
+	return fmt.Sprintf("%T/%s/%s", object, accessor.GetNamespace(), accessor.GetName())
+}
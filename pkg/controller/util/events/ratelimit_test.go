@@ -0,0 +1,113 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestRateLimitedRecorder(t *testing.T) {
+	fake := record.NewFakeRecorder(100)
+	clock := testingclock.NewFakePassiveClock(time.Now())
+	recorder := NewRateLimitedRecorderWithClock(fake, 5*time.Minute, clock)
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-1", UID: "uid-1"}}
+	other := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-2", UID: "uid-2"}}
+
+	// the identical warnings of an object are emitted once per interval, the arguments of the template may differ.
+	for i := 0; i < 30; i++ {
+		recorder.Eventf(pod, v1.EventTypeWarning, "FailedGetScale", "failed to get scale: attempt %d", i)
+		clock.SetTime(clock.Now().Add(10 * time.Second))
+	}
+	assert.Equal(t, []string{"Warning FailedGetScale failed to get scale: attempt 0"}, drainEvents(fake))
+
+	// the other objects, reasons, templates and normal events are not limited.
+	recorder.Eventf(other, v1.EventTypeWarning, "FailedGetScale", "failed to get scale: attempt %d", 0)
+	recorder.Event(pod, v1.EventTypeWarning, "FailedRescale", "conflict")
+	recorder.Eventf(pod, v1.EventTypeWarning, "FailedGetScale", "replicas not found")
+	recorder.Event(pod, v1.EventTypeNormal, "AlgorithmRun", "rescale: false")
+	recorder.Event(pod, v1.EventTypeNormal, "AlgorithmRun", "rescale: false")
+	assert.Len(t, drainEvents(fake), 5)
+
+	// the next warning after the interval reports the suppressed ones.
+	recorder.Eventf(pod, v1.EventTypeWarning, "FailedGetScale", "failed to get scale: attempt %d", 30)
+	assert.Equal(t, []string{"Warning FailedGetScale failed to get scale: attempt 30 (29 similar events suppressed in the last 5m0s)"}, drainEvents(fake))
+	recorder.Eventf(pod, v1.EventTypeWarning, "FailedGetScale", "failed to get scale: attempt %d", 31)
+	assert.Empty(t, drainEvents(fake))
+}
+
+func TestRateLimitedRecorderRepeatedFailure(t *testing.T) {
+	fake := record.NewFakeRecorder(100)
+	clock := testingclock.NewFakePassiveClock(time.Now())
+	recorder := NewRateLimitedRecorderWithClock(fake, 5*time.Minute, clock)
+	pa := &v1.ObjectReference{Kind: "PodAutoscaler", Namespace: "default", Name: "test-pa", UID: "uid-1"}
+	err := fmt.Errorf("no matches for kind %q in version %q", "Deployment", "apps/v1/invalid")
+
+	// the object fails on every sync, the warning is emitted once per interval.
+	for i := 0; i < 30; i++ {
+		recorder.Eventf(pa, v1.EventTypeWarning, "FailedGetScale", "the KPA controller was unable to get the target's current scale: %v", err)
+		clock.SetTime(clock.Now().Add(10 * time.Second))
+	}
+	assert.Len(t, drainEvents(fake), 1)
+
+	recorder.Eventf(pa, v1.EventTypeWarning, "FailedGetScale", "the KPA controller was unable to get the target's current scale: %v", err)
+	events := drainEvents(fake)
+	assert.Len(t, events, 1)
+	assert.Contains(t, events[0], "(29 similar events suppressed in the last 5m0s)")
+}
+
+func TestRateLimitedRecorderSweep(t *testing.T) {
+	fake := record.NewFakeRecorder(100)
+	clock := testingclock.NewFakePassiveClock(time.Now())
+	recorder := NewRateLimitedRecorderWithClock(fake, time.Minute, clock)
+	for i := 0; i < 10; i++ {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("pod-%d", i)}}
+		recorder.Event(pod, v1.EventTypeWarning, "FailedCreate", "quota exceeded")
+	}
+	assert.Len(t, recorder.entries, 10)
+
+	// the entries of the events which stopped occurring are dropped.
+	clock.SetTime(clock.Now().Add(time.Minute))
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-0"}}
+	recorder.Event(pod, v1.EventTypeWarning, "FailedCreate", "quota exceeded")
+	assert.Len(t, recorder.entries, 1)
+	assert.Len(t, drainEvents(fake), 11)
+}
+
+func TestNewRateLimitedRecorderDisabled(t *testing.T) {
+	fake := record.NewFakeRecorder(100)
+	assert.Same(t, fake, NewRateLimitedRecorder(fake, 0))
+}
@@ -27,6 +27,7 @@ const (
 	ModelAdapterController         = "model-adapter-controller"
 	ModelRouteController           = "model-route-controller"
 	KVCacheController              = "kv-cache-controller"
+	ModelController                = "model-controller"
 )
 
 var (
@@ -39,6 +40,7 @@ var (
 
 	ValidControllers = []string{
 		PodAutoscalerController, DistributedInferenceController, ModelAdapterController, ModelRouteController, KVCacheController,
+		ModelController,
 	}
 )
 
@@ -112,4 +114,5 @@ func EnableAllControllers() {
 	EnabledControllers[DistributedInferenceController] = true
 	EnabledControllers[ModelRouteController] = true
 	EnabledControllers[KVCacheController] = true
+	EnabledControllers[ModelController] = true
 }
@@ -25,33 +25,39 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/redis/go-redis/v9"
 	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway"
 	"github.com/vllm-project/aibrix/pkg/utils"
 	"k8s.io/klog/v2"
 )
 
 type httpServer struct {
-	redisClient *redis.Client
-	cache       *cache.Cache
+	redisClient   redis.UniversalClient
+	cache         *cache.Cache
+	modelMetadata *modelMetadataIndex
 }
 
-func NewHTTPServer(addr string, redis *redis.Client) *http.Server {
+func NewHTTPServer(addr string, redis redis.UniversalClient, stopCh <-chan struct{}) *http.Server {
 	c, err := cache.GetCache()
 	if err != nil {
 		panic(err)
 	}
 
 	server := &httpServer{
-		redisClient: redis,
-		cache:       c,
+		redisClient:   redis,
+		cache:         c,
+		modelMetadata: newModelMetadataIndex(c, gateway.NewModelDeprecationReader(c, redis, stopCh), loadThresholdsFromEnv()),
 	}
+	server.modelMetadata.start(stopCh)
 	r := mux.NewRouter()
 	// User related handlers
 	r.HandleFunc("/CreateUser", server.createUser).Methods("POST")
 	r.HandleFunc("/ReadUser", server.readUser).Methods("POST")
 	r.HandleFunc("/UpdateUser", server.updateUser).Methods("POST")
 	r.HandleFunc("/DeleteUser", server.deleteUser).Methods("POST")
+	r.HandleFunc("/ListUsers", server.listUsers).Methods("GET")
 	// OpenAI API related handlers
 	r.HandleFunc("/v1/models", server.models).Methods("GET")
+	r.HandleFunc("/v1/models/{model:.+}/metadata", server.modelMetadata.serve).Methods("GET")
 
 	return &http.Server{
 		Addr:    addr,
@@ -87,17 +93,17 @@ func (s *httpServer) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if utils.CheckUser(r.Context(), u, s.redisClient) {
+	user, err := utils.CreateUser(r.Context(), u, s.redisClient)
+	if errors.Is(err, utils.ErrUserAlreadyExists) {
 		fmt.Fprintf(w, "User: %+v exists", u.Name)
 		return
 	}
-
-	if err := utils.SetUser(r.Context(), u, s.redisClient); err != nil {
+	if err != nil {
 		http.Error(w, fmt.Sprintf("error occurred on creating user: %+v", err), http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprintf(w, "Created User: %+v", u)
+	fmt.Fprintf(w, "Created User: %+v", user)
 }
 
 func (s *httpServer) readUser(w http.ResponseWriter, r *http.Request) {
@@ -115,11 +121,15 @@ func (s *httpServer) readUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := utils.GetUser(r.Context(), u, s.redisClient)
-	if err != nil {
+	user, err := utils.GetUser(r.Context(), u.Name, s.redisClient)
+	if errors.Is(err, utils.ErrUserNotFound) {
 		fmt.Fprint(w, "user does not exists")
 		return
 	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error occurred on reading user: %+v", err), http.StatusInternalServerError)
+		return
+	}
 
 	fmt.Fprintf(w, "User: %+v", user)
 }
@@ -139,17 +149,17 @@ func (s *httpServer) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !utils.CheckUser(r.Context(), u, s.redisClient) {
+	user, err := utils.UpdateUser(r.Context(), u, s.redisClient)
+	if errors.Is(err, utils.ErrUserNotFound) {
 		fmt.Fprintf(w, "User: %+v does not exists", u.Name)
 		return
 	}
-
-	if err := utils.SetUser(r.Context(), u, s.redisClient); err != nil {
+	if err != nil {
 		http.Error(w, fmt.Sprintf("error occurred on updating user: %+v", err), http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprintf(w, "Updated User: %+v", u)
+	fmt.Fprintf(w, "Updated User: %+v", user)
 }
 
 func (s *httpServer) deleteUser(w http.ResponseWriter, r *http.Request) {
@@ -167,15 +177,31 @@ func (s *httpServer) deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !utils.CheckUser(r.Context(), u, s.redisClient) {
+	err = utils.DeleteUser(r.Context(), u.Name, s.redisClient)
+	if errors.Is(err, utils.ErrUserNotFound) {
 		fmt.Fprintf(w, "User: %+v does not exists", u.Name)
 		return
 	}
-
-	if err := utils.DelUser(r.Context(), u, s.redisClient); err != nil {
+	if err != nil {
 		http.Error(w, fmt.Sprintf("error occurred on deleting user: %+v", err), http.StatusInternalServerError)
 		return
 	}
 
 	fmt.Fprintf(w, "Deleted User: %+v", u)
 }
+
+// listUsers returns the users as a JSON array ordered by name.
+func (s *httpServer) listUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := utils.ListUsers(r.Context(), s.redisClient)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error occurred on listing users: %+v", err), http.StatusInternalServerError)
+		return
+	}
+	jsonBytes, err := json.Marshal(users)
+	if err != nil {
+		http.Error(w, "error in processing user list", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, "%s", string(jsonBytes))
+}
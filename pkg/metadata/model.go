@@ -16,6 +16,8 @@ limitations under the License.
 
 package metadata
 
+import "github.com/vllm-project/aibrix/pkg/plugins/gateway"
+
 // ModelInfo represents the information about a single model
 type ModelInfo struct {
 	ID      string `json:"id"`
@@ -51,3 +53,42 @@ func BuildModelsResponse(modelNames []string) ModelListResponse {
 
 	return response
 }
+
+const (
+	// ModelTypeBase and ModelTypeAdapter are the types of the models in their metadata.
+	ModelTypeBase    = "base"
+	ModelTypeAdapter = "adapter"
+
+	// MaxOutputTokensFixed bounds the output tokens of a request by MaxOutputTokens, MaxOutputTokensContextRemaining
+	// by the context length left after the prompt, and MaxOutputTokensUnknown is reported when no limit is known.
+	MaxOutputTokensFixed            = "fixed"
+	MaxOutputTokensContextRemaining = "context_remaining"
+	MaxOutputTokensUnknown          = "unknown"
+
+	// LoadClassGreen, LoadClassYellow and LoadClassRed are the load classes of a model, from idle to saturated.
+	LoadClassGreen  = "green"
+	LoadClassYellow = "yellow"
+	LoadClassRed    = "red"
+)
+
+// TokenizerInfo identifies the tokenizer of a model, so that clients count the tokens of their prompts.
+type TokenizerInfo struct {
+	// Family is the tokenizer family, e.g. llama or tiktoken.
+	Family string `json:"family,omitempty"`
+	// Vocab identifies the vocabulary, e.g. the Huggingface repository of the tokenizer.
+	Vocab string `json:"vocab,omitempty"`
+}
+
+// ModelMetadata is the metadata of a model clients budget their prompts with.
+type ModelMetadata struct {
+	ID                    string                         `json:"id"`
+	Object                string                         `json:"object"`
+	Type                  string                         `json:"type"`
+	BaseModel             string                         `json:"base_model,omitempty"`
+	MaxContextLength      int                            `json:"max_context_length,omitempty"`
+	MaxOutputTokens       int                            `json:"max_output_tokens,omitempty"`
+	MaxOutputTokensPolicy string                         `json:"max_output_tokens_policy"`
+	Tokenizer             *TokenizerInfo                 `json:"tokenizer,omitempty"`
+	Deprecation           gateway.ModelDeprecationStatus `json:"deprecation"`
+	LoadClass             string                         `json:"load_class"`
+}
@@ -0,0 +1,336 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// annotations of the base model pods, or of a ModelAdapter overriding those of its base model, describing the
+	// limits and the tokenizer of the model.
+	maxContextLengthAnnotation = "model.aibrix.ai/max-context-length"
+	maxOutputTokensAnnotation  = "model.aibrix.ai/max-output-tokens"
+	tokenizerFamilyAnnotation  = "model.aibrix.ai/tokenizer-family"
+	tokenizerAnnotation        = "model.aibrix.ai/tokenizer"
+
+	// modelIdentifier labels the base model pods with the model their metrics are reported under.
+	modelIdentifier = "model.aibrix.ai/name"
+
+	// modelMetadataRefreshInterval is how often the metadata of the models are rebuilt, the clients cache them as
+	// long.
+	modelMetadataRefreshInterval = 5 * time.Second
+
+	defaultLoadYellowThreshold = 0.7
+	defaultLoadRedThreshold    = 0.9
+)
+
+// modelMetadataCache is the subset of the cache the metadata of the models are built from.
+type modelMetadataCache interface {
+	GetModels() []string
+	GetModelAdapter(modelName string) (*modelv1alpha1.ModelAdapter, bool)
+	GetPodsForModel(modelName string) (map[string]*v1.Pod, error)
+	GetPodSnapshot(podName string) cache.PodSnapshot
+}
+
+// modelDeprecationSource returns the deprecation status of the models.
+type modelDeprecationSource interface {
+	Status(model string, now time.Time) gateway.ModelDeprecationStatus
+}
+
+// loadThresholds classify the saturation of the pods of a model, the mean usage of their KV cache.
+type loadThresholds struct {
+	yellow float64
+	red    float64
+}
+
+func loadThresholdsFromEnv() loadThresholds {
+	t := loadThresholds{
+		yellow: loadFractionEnv("AIBRIX_MODEL_LOAD_YELLOW_THRESHOLD", defaultLoadYellowThreshold),
+		red:    loadFractionEnv("AIBRIX_MODEL_LOAD_RED_THRESHOLD", defaultLoadRedThreshold),
+	}
+	if t.yellow > t.red {
+		klog.Infof("model load yellow threshold %v is above the red threshold %v, falling back to defaults", t.yellow, t.red)
+		return loadThresholds{yellow: defaultLoadYellowThreshold, red: defaultLoadRedThreshold}
+	}
+	return t
+}
+
+func loadFractionEnv(key string, defaultValue float64) float64 {
+	value := utils.LoadEnv(key, "")
+	if value != "" {
+		floatValue, err := strconv.ParseFloat(value, 64)
+		if err != nil || floatValue < 0 || floatValue > 1 {
+			klog.Infof("invalid %s: %s, falling back to default", key, value)
+		} else {
+			klog.Infof("using %s env value: %v", key, floatValue)
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// classify returns the load class of a model whose routable pods are saturated as given, a model without routable
+// pods is red.
+func (t loadThresholds) classify(saturation float64, routablePods int) string {
+	switch {
+	case routablePods == 0 || saturation >= t.red:
+		return LoadClassRed
+	case saturation >= t.yellow:
+		return LoadClassYellow
+	default:
+		return LoadClassGreen
+	}
+}
+
+// modelMetadataEntry is the encoded metadata of a model and its entity tag.
+type modelMetadataEntry struct {
+	body []byte
+	etag string
+}
+
+// modelMetadataIndex maintains the metadata of the models, which are rebuilt every refresh interval so that their
+// requests are served without scanning the pods.
+type modelMetadataIndex struct {
+	cache        modelMetadataCache
+	deprecations modelDeprecationSource
+	thresholds   loadThresholds
+
+	mu      sync.RWMutex
+	entries map[string]modelMetadataEntry
+}
+
+func newModelMetadataIndex(c modelMetadataCache, deprecations modelDeprecationSource, thresholds loadThresholds) *modelMetadataIndex {
+	return &modelMetadataIndex{
+		cache:        c,
+		deprecations: deprecations,
+		thresholds:   thresholds,
+		entries:      map[string]modelMetadataEntry{},
+	}
+}
+
+// start refreshes the metadata every refresh interval until stopCh is closed.
+func (i *modelMetadataIndex) start(stopCh <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(modelMetadataRefreshInterval)
+		defer ticker.Stop()
+		for {
+			i.refresh(time.Now())
+			select {
+			case <-ticker.C:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// refresh rebuilds the metadata of the models served at now.
+func (i *modelMetadataIndex) refresh(now time.Time) {
+	models := i.cache.GetModels()
+	entries := make(map[string]modelMetadataEntry, len(models))
+	for _, model := range models {
+		body, err := json.Marshal(i.build(model, now))
+		if err != nil {
+			klog.ErrorS(err, "failed to encode model metadata", "model", model)
+			continue
+		}
+		sum := sha256.Sum256(body)
+		entries[model] = modelMetadataEntry{body: body, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+	}
+
+	i.mu.Lock()
+	i.entries = entries
+	i.mu.Unlock()
+}
+
+// build assembles the metadata of the model. A model adapter reports the limits and the tokenizer of its base model
+// unless its own annotations override them.
+func (i *modelMetadataIndex) build(model string, now time.Time) ModelMetadata {
+	metadata := ModelMetadata{
+		ID:          model,
+		Object:      "model.metadata",
+		Type:        ModelTypeBase,
+		Deprecation: i.deprecations.Status(model, now),
+	}
+
+	var adapterAnnotations map[string]string
+	pods := i.podsForModel(model)
+	if adapter, ok := i.cache.GetModelAdapter(model); ok {
+		metadata.Type = ModelTypeAdapter
+		adapterAnnotations = adapter.Annotations
+		if adapter.Spec.BaseModel != nil {
+			metadata.BaseModel = *adapter.Spec.BaseModel
+			// a model adapter no pod loaded yet is served by the pods of its base model.
+			if len(pods) == 0 {
+				pods = i.podsForModel(metadata.BaseModel)
+			}
+		}
+	}
+	annotations := []map[string]string{adapterAnnotations}
+	for _, pod := range pods {
+		annotations = append(annotations, pod.Annotations)
+	}
+
+	metadata.MaxContextLength = annotatedLimit(annotations, maxContextLengthAnnotation)
+	metadata.MaxOutputTokens = annotatedLimit(annotations, maxOutputTokensAnnotation)
+	switch {
+	case metadata.MaxOutputTokens > 0:
+		metadata.MaxOutputTokensPolicy = MaxOutputTokensFixed
+	case metadata.MaxContextLength > 0:
+		metadata.MaxOutputTokensPolicy = MaxOutputTokensContextRemaining
+	default:
+		metadata.MaxOutputTokensPolicy = MaxOutputTokensUnknown
+	}
+	family, vocab := firstAnnotation(annotations, tokenizerFamilyAnnotation), firstAnnotation(annotations, tokenizerAnnotation)
+	if family != "" || vocab != "" {
+		metadata.Tokenizer = &TokenizerInfo{Family: family, Vocab: vocab}
+	}
+
+	var routable []*v1.Pod
+	for _, pod := range pods {
+		if pod.Status.PodIP != "" && !utils.IsPodTerminating(pod) && utils.IsPodReady(pod) {
+			routable = append(routable, pod)
+		}
+	}
+	metadata.LoadClass = i.thresholds.classify(i.saturation(routable), len(routable))
+	return metadata
+}
+
+// podsForModel returns the pods of the model sorted by name, so that the first annotated one wins consistently.
+func (i *modelMetadataIndex) podsForModel(model string) []*v1.Pod {
+	podsMap, err := i.cache.GetPodsForModel(model)
+	if err != nil {
+		return nil
+	}
+	pods := make([]*v1.Pod, 0, len(podsMap))
+	for _, pod := range podsMap {
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(a, b int) bool { return pods[a].Name < pods[b].Name })
+	return pods
+}
+
+// saturation returns the mean usage of the KV cache of the pods reporting it, 0 if none does.
+func (i *modelMetadataIndex) saturation(pods []*v1.Pod) float64 {
+	var sum float64
+	var count int
+	for _, pod := range pods {
+		value, err := i.cache.GetPodSnapshot(pod.Name).PodModelMetric(pod.Labels[modelIdentifier], metrics.GPUCacheUsagePerc)
+		if err != nil {
+			continue
+		}
+		sum += value.GetSimpleValue()
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// annotatedLimit returns the limit set by the annotation of the model adapter, the first annotations, or else the
+// smallest one of the pods so that the clients budget for the most constrained pod. It is 0 if none is set.
+func annotatedLimit(annotations []map[string]string, key string) int {
+	result := 0
+	for idx, values := range annotations {
+		value, err := strconv.Atoi(strings.TrimSpace(values[key]))
+		if err != nil || value <= 0 {
+			continue
+		}
+		if idx == 0 {
+			return value
+		}
+		if result == 0 || value < result {
+			result = value
+		}
+	}
+	return result
+}
+
+// firstAnnotation returns the first non-empty value of the annotation.
+func firstAnnotation(annotations []map[string]string, key string) string {
+	for _, values := range annotations {
+		if value := strings.TrimSpace(values[key]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// serve returns the metadata of the model, or not modified when the client holds its current entity tag.
+func (i *modelMetadataIndex) serve(w http.ResponseWriter, r *http.Request) {
+	model := mux.Vars(r)["model"]
+	i.mu.RLock()
+	entry, ok := i.entries[model]
+	i.mu.RUnlock()
+	if !ok {
+		writeModelNotFound(w, model)
+		return
+	}
+
+	w.Header().Set("ETag", entry.etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(modelMetadataRefreshInterval.Seconds())))
+	if etagMatches(r.Header.Get("If-None-Match"), entry.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(entry.body)
+}
+
+// etagMatches returns whether the If-None-Match header lists the entity tag, weak tags match their strong tag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeModelNotFound(w http.ResponseWriter, model string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"message": fmt.Sprintf("model %s does not exist", model),
+			"type":    "invalid_request_error",
+			"code":    "model_not_found",
+		},
+	})
+}
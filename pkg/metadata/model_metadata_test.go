@@ -0,0 +1,230 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway"
+)
+
+type fakeModelMetadataCache struct {
+	pods         map[string][]*v1.Pod
+	adapters     map[string]*modelv1alpha1.ModelAdapter
+	cacheUsage   map[string]float64
+	podsRequests int
+}
+
+func (c *fakeModelMetadataCache) GetModels() []string {
+	var models []string
+	for model := range c.pods {
+		models = append(models, model)
+	}
+	for model := range c.adapters {
+		if _, ok := c.pods[model]; !ok {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+func (c *fakeModelMetadataCache) GetModelAdapter(modelName string) (*modelv1alpha1.ModelAdapter, bool) {
+	adapter, ok := c.adapters[modelName]
+	return adapter, ok
+}
+
+func (c *fakeModelMetadataCache) GetPodsForModel(modelName string) (map[string]*v1.Pod, error) {
+	c.podsRequests++
+	pods, ok := c.pods[modelName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", cache.ErrModelNotFound, modelName)
+	}
+	podsMap := make(map[string]*v1.Pod, len(pods))
+	for _, pod := range pods {
+		podsMap[pod.Name] = pod
+	}
+	return podsMap, nil
+}
+
+func (c *fakeModelMetadataCache) GetPodSnapshot(podName string) cache.PodSnapshot {
+	usage, ok := c.cacheUsage[podName]
+	if !ok {
+		return cache.NewPodSnapshot(podName, nil, nil)
+	}
+	return cache.NewPodSnapshot(podName, nil, map[string]map[string]metrics.MetricValue{
+		"llama-2-7b": {metrics.GPUCacheUsagePerc: &metrics.SimpleMetricValue{Value: usage}},
+	})
+}
+
+type fakeDeprecationSource map[string]gateway.ModelDeprecationStatus
+
+func (s fakeDeprecationSource) Status(model string, now time.Time) gateway.ModelDeprecationStatus {
+	if status, ok := s[model]; ok {
+		return status
+	}
+	return gateway.ModelDeprecationStatus{Status: gateway.ModelStatusActive}
+}
+
+func newModelMetadataPod(name string, annotations map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{modelIdentifier: "llama-2-7b"}, Annotations: annotations},
+		Status: v1.PodStatus{
+			PodIP:      "10.0.0.1",
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func newTestModelMetadataIndex() (*modelMetadataIndex, *fakeModelMetadataCache) {
+	c := &fakeModelMetadataCache{
+		pods: map[string][]*v1.Pod{
+			"llama-2-7b": {
+				newModelMetadataPod("pod-1", map[string]string{
+					maxContextLengthAnnotation: "4096",
+					tokenizerFamilyAnnotation:  "llama",
+					tokenizerAnnotation:        "meta-llama/Llama-2-7b-hf",
+				}),
+				newModelMetadataPod("pod-2", map[string]string{maxContextLengthAnnotation: "2048"}),
+			},
+		},
+		adapters: map[string]*modelv1alpha1.ModelAdapter{
+			"sql-lora": {
+				ObjectMeta: metav1.ObjectMeta{Name: "sql-lora", Annotations: map[string]string{maxOutputTokensAnnotation: "512"}},
+				Spec:       modelv1alpha1.ModelAdapterSpec{BaseModel: ptr.To("llama-2-7b")},
+			},
+		},
+		cacheUsage: map[string]float64{"pod-1": 0.6, "pod-2": 0.9},
+	}
+	deprecations := fakeDeprecationSource{"sql-lora": {Status: gateway.ModelStatusDeprecated, RemovalDate: "2025-03-01", Replacement: "sql-lora-v2"}}
+	index := newModelMetadataIndex(c, deprecations, loadThresholds{yellow: 0.7, red: 0.9})
+	index.refresh(time.Now())
+	return index, c
+}
+
+func serveModelMetadata(index *modelMetadataIndex, model string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/models/"+model+"/metadata", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req = mux.SetURLVars(req, map[string]string{"model": model})
+	rec := httptest.NewRecorder()
+	index.serve(rec, req)
+	return rec
+}
+
+func TestModelMetadataBaseModel(t *testing.T) {
+	index, _ := newTestModelMetadataIndex()
+
+	rec := serveModelMetadata(index, "llama-2-7b", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "max-age=5", rec.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+
+	var metadata ModelMetadata
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &metadata))
+	assert.Equal(t, ModelMetadata{
+		ID:                    "llama-2-7b",
+		Object:                "model.metadata",
+		Type:                  ModelTypeBase,
+		MaxContextLength:      2048,
+		MaxOutputTokensPolicy: MaxOutputTokensContextRemaining,
+		Tokenizer:             &TokenizerInfo{Family: "llama", Vocab: "meta-llama/Llama-2-7b-hf"},
+		Deprecation:           gateway.ModelDeprecationStatus{Status: gateway.ModelStatusActive},
+		// the mean usage of the KV cache of the pods is 0.75.
+		LoadClass: LoadClassYellow,
+	}, metadata)
+}
+
+func TestModelMetadataAdapter(t *testing.T) {
+	index, _ := newTestModelMetadataIndex()
+
+	rec := serveModelMetadata(index, "sql-lora", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// the adapter no pod loaded yet reports the limits, the tokenizer and the load of its base model.
+	var metadata ModelMetadata
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &metadata))
+	assert.Equal(t, ModelTypeAdapter, metadata.Type)
+	assert.Equal(t, "llama-2-7b", metadata.BaseModel)
+	assert.Equal(t, 2048, metadata.MaxContextLength)
+	assert.Equal(t, 512, metadata.MaxOutputTokens)
+	assert.Equal(t, MaxOutputTokensFixed, metadata.MaxOutputTokensPolicy)
+	assert.Equal(t, "llama", metadata.Tokenizer.Family)
+	assert.Equal(t, gateway.ModelStatusDeprecated, metadata.Deprecation.Status)
+	assert.Equal(t, "sql-lora-v2", metadata.Deprecation.Replacement)
+	assert.Equal(t, LoadClassYellow, metadata.LoadClass)
+}
+
+func TestModelMetadataUnknownModel(t *testing.T) {
+	index, _ := newTestModelMetadataIndex()
+
+	rec := serveModelMetadata(index, "mistral-7b", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "model_not_found")
+	assert.Empty(t, rec.Header().Get("ETag"))
+}
+
+func TestModelMetadataETagRevalidation(t *testing.T) {
+	index, c := newTestModelMetadataIndex()
+	etag := serveModelMetadata(index, "llama-2-7b", nil).Header().Get("ETag")
+
+	// the requests are served from the maintained metadata, without reading the pods.
+	podsRequests := c.podsRequests
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		rec := serveModelMetadata(index, "llama-2-7b", map[string]string{"If-None-Match": ifNoneMatch})
+		assert.Equal(t, http.StatusNotModified, rec.Code, ifNoneMatch)
+		assert.Empty(t, rec.Body.Bytes())
+		assert.Equal(t, etag, rec.Header().Get("ETag"))
+	}
+	assert.Equal(t, podsRequests, c.podsRequests)
+
+	// the entity tag is stable while the metadata are unchanged.
+	index.refresh(time.Now())
+	assert.Equal(t, etag, serveModelMetadata(index, "llama-2-7b", nil).Header().Get("ETag"))
+
+	// a change of the load class changes the entity tag.
+	c.cacheUsage["pod-1"] = 0.95
+	index.refresh(time.Now())
+	rec := serveModelMetadata(index, "llama-2-7b", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), `"load_class":"red"`)
+}
+
+func TestLoadThresholdsClassify(t *testing.T) {
+	thresholds := loadThresholds{yellow: 0.5, red: 0.8}
+	assert.Equal(t, LoadClassGreen, thresholds.classify(0.2, 1))
+	assert.Equal(t, LoadClassYellow, thresholds.classify(0.5, 1))
+	assert.Equal(t, LoadClassRed, thresholds.classify(0.8, 1))
+	// a model without routable pods can not serve its requests.
+	assert.Equal(t, LoadClassRed, thresholds.classify(0, 0))
+}
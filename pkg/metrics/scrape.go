@@ -0,0 +1,342 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The annotations of a pod configuring how its metrics are scraped, e.g. when the engine sits behind an auth proxy.
+// They are usually set on the pod template of the Deployment, so that all its pods share the configuration.
+const (
+	// ScrapeSchemeAnnotation is the scheme of the metrics endpoint, http or https.
+	ScrapeSchemeAnnotation = "metrics.aibrix.ai/scheme"
+	// ScrapeCAFileAnnotation is the path of the CA bundle verifying the certificate of the endpoint, mounted in
+	// the scraping component.
+	ScrapeCAFileAnnotation = "metrics.aibrix.ai/ca-file"
+	// ScrapeInsecureSkipVerifyAnnotation skips the verification of the certificate of the endpoint when "true".
+	ScrapeInsecureSkipVerifyAnnotation = "metrics.aibrix.ai/insecure-skip-verify"
+	// ScrapeAuthSecretAnnotation is the Secret of the bearer token, in the namespace of the pod, as name or
+	// name/key. The key defaults to token.
+	ScrapeAuthSecretAnnotation = "metrics.aibrix.ai/auth-secret"
+	// ScrapeTokenFileAnnotation is the path of the file of the bearer token, mounted in the scraping component.
+	ScrapeTokenFileAnnotation = "metrics.aibrix.ai/token-file"
+	// ScrapeHeadersAnnotation is a JSON object of the headers added to the scrape requests.
+	ScrapeHeadersAnnotation = "metrics.aibrix.ai/headers"
+
+	defaultAuthSecretKey = "token"
+)
+
+var (
+	// ScrapeTokenRefreshInterval is how long a bearer token is used before it is read again from its Secret or
+	// file, so that a rotated token is picked up.
+	ScrapeTokenRefreshInterval = time.Minute
+	// ScrapeAuthFailureBackoff is how long an endpoint rejecting the credentials is not scraped again, retrying
+	// with the same credentials would be rejected again.
+	ScrapeAuthFailureBackoff = time.Minute
+)
+
+// ScrapeConfig is how the metrics endpoint of a pod is scraped.
+type ScrapeConfig struct {
+	// Scheme is http or https, http if empty.
+	Scheme             string
+	CAFile             string
+	InsecureSkipVerify bool
+	// AuthSecret and AuthSecretKey locate the bearer token in a Secret, AuthSecret is empty without one.
+	AuthSecret    types.NamespacedName
+	AuthSecretKey string
+	// TokenFile is the file of the bearer token, empty without one.
+	TokenFile string
+	Headers   map[string]string
+}
+
+// ScrapeConfigForPod returns the scrape configuration of the pod from its annotations, scheme being the scheme when
+// the pod doesn't set one.
+func ScrapeConfigForPod(pod *v1.Pod, scheme string) (ScrapeConfig, error) {
+	annotations := pod.Annotations
+	config := ScrapeConfig{
+		Scheme:    scheme,
+		CAFile:    annotations[ScrapeCAFileAnnotation],
+		TokenFile: annotations[ScrapeTokenFileAnnotation],
+	}
+	if value, ok := annotations[ScrapeSchemeAnnotation]; ok {
+		config.Scheme = value
+	}
+	if config.Scheme == "" {
+		config.Scheme = "http"
+	}
+	if config.Scheme != "http" && config.Scheme != "https" {
+		return ScrapeConfig{}, fmt.Errorf("invalid %s annotation %q, expected http or https", ScrapeSchemeAnnotation, config.Scheme)
+	}
+	if value, ok := annotations[ScrapeInsecureSkipVerifyAnnotation]; ok {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return ScrapeConfig{}, fmt.Errorf("invalid %s annotation %q: %w", ScrapeInsecureSkipVerifyAnnotation, value, err)
+		}
+		config.InsecureSkipVerify = insecure
+	}
+	if value := annotations[ScrapeAuthSecretAnnotation]; value != "" {
+		name, key, found := strings.Cut(value, "/")
+		if !found {
+			key = defaultAuthSecretKey
+		}
+		if name == "" || key == "" {
+			return ScrapeConfig{}, fmt.Errorf("invalid %s annotation %q, expected name or name/key", ScrapeAuthSecretAnnotation, value)
+		}
+		config.AuthSecret = types.NamespacedName{Namespace: pod.Namespace, Name: name}
+		config.AuthSecretKey = key
+	}
+	if config.AuthSecret.Name != "" && config.TokenFile != "" {
+		return ScrapeConfig{}, fmt.Errorf("the %s and %s annotations are exclusive", ScrapeAuthSecretAnnotation, ScrapeTokenFileAnnotation)
+	}
+	if value := annotations[ScrapeHeadersAnnotation]; value != "" {
+		if err := json.Unmarshal([]byte(value), &config.Headers); err != nil {
+			return ScrapeConfig{}, fmt.Errorf("invalid %s annotation: %w", ScrapeHeadersAnnotation, err)
+		}
+	}
+	return config, nil
+}
+
+// ScrapeAuthError is returned for an endpoint which rejected the credentials of the scrape, or which is in the
+// back-off of such a rejection. The scrape succeeds again once the credentials are fixed, unlike the connection
+// failures of a pod which is starting or overloaded.
+type ScrapeAuthError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is when the endpoint is scraped again.
+	RetryAfter time.Time
+}
+
+func (e *ScrapeAuthError) Error() string {
+	return fmt.Sprintf("metrics endpoint %s rejected the credentials with status %d, not retried until %s",
+		e.URL, e.StatusCode, e.RetryAfter.Format(time.RFC3339))
+}
+
+// SecretGetter reads the Secrets of the bearer tokens.
+type SecretGetter func(ctx context.Context, namespace, name string) (*v1.Secret, error)
+
+// transportKey identifies the TLS configurations of the scrape clients.
+type transportKey struct {
+	caFile             string
+	insecureSkipVerify bool
+}
+
+type scrapeToken struct {
+	value  string
+	readAt time.Time
+}
+
+// Scraper sends the scrape requests to the metrics endpoints of the pods. It keeps one HTTP client per TLS
+// configuration, so that the connections to the pods are reused across scrapes.
+type Scraper struct {
+	getSecret SecretGetter
+	now       func() time.Time
+
+	mu      sync.Mutex
+	clients map[transportKey]*http.Client
+	// tokens are by Secret namespace/name/key or by file path.
+	tokens map[string]scrapeToken
+	// authFailures are the rejections of the endpoints in their back-off, by URL.
+	authFailures map[string]*ScrapeAuthError
+}
+
+// NewScraper returns a Scraper reading the bearer tokens of the Secrets with getSecret, which may be nil if no pod
+// references a Secret.
+func NewScraper(getSecret SecretGetter) *Scraper {
+	return &Scraper{
+		getSecret:    getSecret,
+		now:          time.Now,
+		clients:      map[transportKey]*http.Client{},
+		tokens:       map[string]scrapeToken{},
+		authFailures: map[string]*ScrapeAuthError{},
+	}
+}
+
+// Get scrapes the path of the endpoint, host:port, with the configuration and returns the body of the response.
+func (s *Scraper) Get(ctx context.Context, config ScrapeConfig, endpoint, path string) ([]byte, error) {
+	scheme := config.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	url := fmt.Sprintf("%s://%s/%s", scheme, endpoint, strings.TrimLeft(path, "/"))
+	if err := s.authBackoff(url); err != nil {
+		return nil, err
+	}
+
+	client, err := s.client(config)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request to source %s: %v", url, err)
+	}
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+	token, err := s.token(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the bearer token of source %s: %w", url, err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metrics from source %s: %w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, s.recordAuthFailure(url, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch metrics from source %s: status %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from source %s: %v", url, err)
+	}
+	return body, nil
+}
+
+// authBackoff returns the rejection of the URL if it is still in its back-off.
+func (s *Scraper) authBackoff(url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failure, ok := s.authFailures[url]
+	if !ok {
+		return nil
+	}
+	if !s.now().Before(failure.RetryAfter) {
+		delete(s.authFailures, url)
+		return nil
+	}
+	return failure
+}
+
+func (s *Scraper) recordAuthFailure(url string, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	// the back-offs of the endpoints which were not scraped again since, e.g. of deleted pods, are dropped.
+	for failedURL, failure := range s.authFailures {
+		if !now.Before(failure.RetryAfter) {
+			delete(s.authFailures, failedURL)
+		}
+	}
+	failure := &ScrapeAuthError{URL: url, StatusCode: statusCode, RetryAfter: now.Add(ScrapeAuthFailureBackoff)}
+	s.authFailures[url] = failure
+	// the token may have been rotated in the meantime, it is read again for the next scrape.
+	s.tokens = map[string]scrapeToken{}
+	return failure
+}
+
+// client returns the HTTP client of the TLS configuration, built on its first use.
+func (s *Scraper) client(config ScrapeConfig) (*http.Client, error) {
+	key := transportKey{}
+	if config.Scheme == "https" {
+		key = transportKey{caFile: config.CAFile, insecureSkipVerify: config.InsecureSkipVerify}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if client, ok := s.clients[key]; ok {
+		return client, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Scheme == "https" {
+		tlsConfig := &tls.Config{InsecureSkipVerify: key.insecureSkipVerify} // #nosec G402 -- opted in by the pod
+		if key.caFile != "" {
+			pem, err := os.ReadFile(key.caFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the CA bundle %s: %w", key.caFile, err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in the CA bundle %s", key.caFile)
+			}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	client := &http.Client{Transport: transport}
+	s.clients[key] = client
+	return client, nil
+}
+
+// token returns the bearer token of the configuration, empty without one.
+func (s *Scraper) token(ctx context.Context, config ScrapeConfig) (string, error) {
+	var key string
+	switch {
+	case config.AuthSecret.Name != "":
+		key = config.AuthSecret.String() + "/" + config.AuthSecretKey
+	case config.TokenFile != "":
+		key = config.TokenFile
+	default:
+		return "", nil
+	}
+
+	s.mu.Lock()
+	cached, ok := s.tokens[key]
+	s.mu.Unlock()
+	if ok && s.now().Sub(cached.readAt) < ScrapeTokenRefreshInterval {
+		return cached.value, nil
+	}
+
+	var value string
+	if config.TokenFile != "" {
+		data, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		value = strings.TrimSpace(string(data))
+	} else {
+		if s.getSecret == nil {
+			return "", fmt.Errorf("secret %s can not be read by this scraper", config.AuthSecret)
+		}
+		secret, err := s.getSecret(ctx, config.AuthSecret.Namespace, config.AuthSecret.Name)
+		if err != nil {
+			return "", err
+		}
+		data, ok := secret.Data[config.AuthSecretKey]
+		if !ok {
+			return "", fmt.Errorf("secret %s has no key %s", config.AuthSecret, config.AuthSecretKey)
+		}
+		value = strings.TrimSpace(string(data))
+	}
+
+	s.mu.Lock()
+	s.tokens[key] = scrapeToken{value: value, readAt: s.now()}
+	s.mu.Unlock()
+	return value, nil
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestScrapeConfigForPod(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    ScrapeConfig
+		expectErr   bool
+	}{
+		{
+			name:     "default scheme",
+			expected: ScrapeConfig{Scheme: "http"},
+		},
+		{
+			name: "https with a CA bundle and a secret",
+			annotations: map[string]string{
+				ScrapeSchemeAnnotation:     "https",
+				ScrapeCAFileAnnotation:     "/etc/aibrix/ca.crt",
+				ScrapeAuthSecretAnnotation: "engine-auth",
+				ScrapeHeadersAnnotation:    `{"X-Tenant": "a"}`,
+			},
+			expected: ScrapeConfig{
+				Scheme:        "https",
+				CAFile:        "/etc/aibrix/ca.crt",
+				AuthSecret:    types.NamespacedName{Namespace: "default", Name: "engine-auth"},
+				AuthSecretKey: "token",
+				Headers:       map[string]string{"X-Tenant": "a"},
+			},
+		},
+		{
+			name: "secret key and insecure",
+			annotations: map[string]string{
+				ScrapeSchemeAnnotation:             "https",
+				ScrapeInsecureSkipVerifyAnnotation: "true",
+				ScrapeAuthSecretAnnotation:         "engine-auth/bearer",
+			},
+			expected: ScrapeConfig{
+				Scheme:             "https",
+				InsecureSkipVerify: true,
+				AuthSecret:         types.NamespacedName{Namespace: "default", Name: "engine-auth"},
+				AuthSecretKey:      "bearer",
+			},
+		},
+		{
+			name:        "token file",
+			annotations: map[string]string{ScrapeTokenFileAnnotation: "/var/run/secrets/engine/token"},
+			expected:    ScrapeConfig{Scheme: "http", TokenFile: "/var/run/secrets/engine/token"},
+		},
+		{
+			name:        "invalid scheme",
+			annotations: map[string]string{ScrapeSchemeAnnotation: "grpc"},
+			expectErr:   true,
+		},
+		{
+			name:        "invalid headers",
+			annotations: map[string]string{ScrapeHeadersAnnotation: "X-Tenant: a"},
+			expectErr:   true,
+		},
+		{
+			name: "secret and token file",
+			annotations: map[string]string{
+				ScrapeAuthSecretAnnotation: "engine-auth",
+				ScrapeTokenFileAnnotation:  "/var/run/secrets/engine/token",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "p1", Annotations: tc.annotations}}
+			config, err := ScrapeConfigForPod(pod, "")
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, config)
+		})
+	}
+}
+
+func TestScraperGet(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" || r.Header.Get("X-Tenant") != "a" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintln(w, "vllm:num_requests_running 3")
+	}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	var secretReads atomic.Int32
+	scraper := NewScraper(func(ctx context.Context, namespace, name string) (*v1.Secret, error) {
+		secretReads.Add(1)
+		if namespace != "default" || name != "engine-auth" {
+			return nil, errors.New("not found")
+		}
+		return &v1.Secret{Data: map[string][]byte{"token": []byte("secret-token\n")}}, nil
+	})
+	config := ScrapeConfig{
+		Scheme:        "https",
+		CAFile:        caFile,
+		AuthSecret:    types.NamespacedName{Namespace: "default", Name: "engine-auth"},
+		AuthSecretKey: "token",
+		Headers:       map[string]string{"X-Tenant": "a"},
+	}
+	endpoint := server.Listener.Addr().String()
+
+	for i := 0; i < 2; i++ {
+		body, err := scraper.Get(context.Background(), config, endpoint, "/metrics")
+		assert.NoError(t, err)
+		assert.Equal(t, "vllm:num_requests_running 3\n", string(body))
+	}
+	// the client of the configuration and the token are reused.
+	assert.Len(t, scraper.clients, 1)
+	assert.Equal(t, int32(1), secretReads.Load())
+
+	// the certificate of the server is not trusted without the CA bundle.
+	config.CAFile = ""
+	_, err := scraper.Get(context.Background(), config, endpoint, "/metrics")
+	assert.Error(t, err)
+	var authErr *ScrapeAuthError
+	assert.False(t, errors.As(err, &authErr))
+}
+
+func TestScraperAuthFailureBackoff(t *testing.T) {
+	var requests atomic.Int32
+	var authorized atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !authorized.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintln(w, "vllm:num_requests_running 3")
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	scraper := NewScraper(nil)
+	scraper.now = func() time.Time { return now }
+	endpoint := server.Listener.Addr().String()
+
+	_, err := scraper.Get(context.Background(), ScrapeConfig{}, endpoint, "metrics")
+	var authErr *ScrapeAuthError
+	assert.True(t, errors.As(err, &authErr))
+	assert.Equal(t, http.StatusForbidden, authErr.StatusCode)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// the endpoint is not scraped again within the back-off.
+	authorized.Store(true)
+	now = now.Add(ScrapeAuthFailureBackoff / 2)
+	_, err = scraper.Get(context.Background(), ScrapeConfig{}, endpoint, "metrics")
+	assert.True(t, errors.As(err, &authErr))
+	assert.Equal(t, int32(1), requests.Load())
+
+	now = now.Add(ScrapeAuthFailureBackoff)
+	body, err := scraper.Get(context.Background(), ScrapeConfig{}, endpoint, "metrics")
+	assert.NoError(t, err)
+	assert.Equal(t, "vllm:num_requests_running 3\n", string(body))
+	assert.Equal(t, int32(2), requests.Load())
+}
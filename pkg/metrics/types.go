@@ -262,3 +262,43 @@ func (l *LabelValueMetricValue) GetPrometheusResult() *model.Value {
 func (l *LabelValueMetricValue) GetLabelValue() string {
 	return l.Value
 }
+
+// SumMetricValues aggregates the values of a metric across its series, e.g. across the models of a pod: the simple
+// values are summed and the histograms are merged bucket by bucket. The Prometheus results and the label values can't
+// be aggregated.
+func SumMetricValues(values []MetricValue) (MetricValue, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no metric value to aggregate")
+	}
+	switch values[0].(type) {
+	case *SimpleMetricValue:
+		sum := &SimpleMetricValue{}
+		for _, value := range values {
+			simple, ok := value.(*SimpleMetricValue)
+			if !ok {
+				return nil, fmt.Errorf("can not aggregate a simple metric value with a %T", value)
+			}
+			sum.Value += simple.Value
+		}
+		return sum, nil
+	case *HistogramMetricValue:
+		sum := &HistogramMetricValue{Buckets: map[string]float64{}}
+		for _, value := range values {
+			histogram, ok := value.(*HistogramMetricValue)
+			if !ok {
+				return nil, fmt.Errorf("can not aggregate a histogram metric value with a %T", value)
+			}
+			sum.Sum += histogram.Sum
+			sum.Count += histogram.Count
+			for bound, count := range histogram.Buckets {
+				sum.Buckets[bound] += count
+			}
+		}
+		return sum, nil
+	default:
+		if len(values) == 1 {
+			return values[0], nil
+		}
+		return nil, fmt.Errorf("can not aggregate the %T metric values", values[0])
+	}
+}
@@ -163,3 +163,28 @@ func TestMetric(t *testing.T) {
 		assert.Equal(t, "A test metric", metric.Description)
 	})
 }
+
+func TestSumMetricValues(t *testing.T) {
+	sum, err := SumMetricValues([]MetricValue{&SimpleMetricValue{Value: 2}, &SimpleMetricValue{Value: 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, sum.GetSimpleValue())
+
+	sum, err = SumMetricValues([]MetricValue{
+		&HistogramMetricValue{Sum: 4, Count: 2, Buckets: map[string]float64{"1": 1, "+Inf": 2}},
+		&HistogramMetricValue{Sum: 2, Count: 2, Buckets: map[string]float64{"1": 2, "+Inf": 2}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &HistogramMetricValue{Sum: 6, Count: 4, Buckets: map[string]float64{"1": 3, "+Inf": 4}}, sum)
+
+	label := &LabelValueMetricValue{Value: "llama"}
+	sum, err = SumMetricValues([]MetricValue{label})
+	assert.NoError(t, err)
+	assert.Equal(t, label, sum)
+
+	_, err = SumMetricValues([]MetricValue{label, &LabelValueMetricValue{Value: "mistral"}})
+	assert.Error(t, err)
+	_, err = SumMetricValues([]MetricValue{&SimpleMetricValue{Value: 2}, &HistogramMetricValue{}})
+	assert.Error(t, err)
+	_, err = SumMetricValues(nil)
+	assert.Error(t, err)
+}
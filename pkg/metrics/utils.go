@@ -17,6 +17,7 @@ limitations under the License.
 package metrics
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -30,85 +31,167 @@ import (
 	"github.com/prometheus/common/expfmt"
 )
 
-// ParseHistogramFromBody parses a histogram metric from the Prometheus response body.
+// ParseHistogramFromBody parses a histogram metric from the Prometheus response body, merging its series.
 func ParseHistogramFromBody(body []byte, metricName string) (*HistogramMetricValue, error) {
-	lines := strings.Split(string(body), "\n")
-	histogram := &HistogramMetricValue{
-		Buckets: make(map[string]float64),
+	return ParseHistogramSeriesFromBody(body, metricName, nil)
+}
+
+// ParseHistogramSeriesFromBody parses a histogram metric from the Prometheus response body, merging the series whose
+// labels match the matchers, e.g. {"model_name": "llama-3-8b"}. The buckets are keyed by their le label.
+func ParseHistogramSeriesFromBody(body []byte, metricName string, matchers map[string]string) (*HistogramMetricValue, error) {
+	families, err := ParseMetricFamilies(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics %s: %w", metricName, err)
+	}
+	family, ok := families[metricName]
+	if !ok {
+		// a histogram without a TYPE line is parsed into untyped _sum, _count and _bucket families.
+		return parseUntypedHistogram(families, metricName, matchers)
+	}
+	if family.GetType() != dto.MetricType_HISTOGRAM {
+		return nil, fmt.Errorf("metrics %s is a %s, not a histogram", metricName, family.GetType())
 	}
+
+	histogram := &HistogramMetricValue{Buckets: map[string]float64{}}
 	found := false
+	for _, metric := range family.GetMetric() {
+		if !matchLabels(metric, matchers) {
+			continue
+		}
+		found = true
+		histogram.Sum += metric.GetHistogram().GetSampleSum()
+		histogram.Count += float64(metric.GetHistogram().GetSampleCount())
+		for _, bucket := range metric.GetHistogram().GetBucket() {
+			histogram.Buckets[bucketBoundary(bucket.GetUpperBound())] += float64(bucket.GetCumulativeCount())
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("metrics %s not found for labels %v", metricName, matchers)
+	}
+	return histogram, nil
+}
 
-	for _, line := range lines {
-		if strings.Contains(line, metricName+"_sum") {
-			value, err := extractMetricValue(line)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse sum for metric %s: %w", metricName, err)
-			}
-			histogram.Sum = value
-			found = true
-		} else if strings.Contains(line, metricName+"_count") {
-			value, err := extractMetricValue(line)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse count for metric %s: %w", metricName, err)
-			}
-			histogram.Count = value
+// parseUntypedHistogram merges the untyped _sum, _count and _bucket series of the histogram whose labels match the
+// matchers.
+func parseUntypedHistogram(families map[string]*dto.MetricFamily, metricName string, matchers map[string]string) (*HistogramMetricValue, error) {
+	histogram := &HistogramMetricValue{Buckets: map[string]float64{}}
+	found := false
+	for _, metric := range families[metricName+"_sum"].GetMetric() {
+		if matchLabels(metric, matchers) {
+			histogram.Sum += metric.GetUntyped().GetValue()
 			found = true
-		} else if strings.Contains(line, metricName+"_bucket") {
-			bucketBoundary := extractBucketBoundary(line)
-			if bucketBoundary == "" {
-				return nil, fmt.Errorf("failed to extract bucket boundary for metric %s", metricName)
-			}
-			value, err := extractMetricValue(line)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse bucket value for %s: %w", metricName, err)
-			}
-			histogram.Buckets[bucketBoundary] = value
+		}
+	}
+	for _, metric := range families[metricName+"_count"].GetMetric() {
+		if matchLabels(metric, matchers) {
+			histogram.Count += metric.GetUntyped().GetValue()
 			found = true
 		}
 	}
-
+	for _, metric := range families[metricName+"_bucket"].GetMetric() {
+		if !matchLabels(metric, matchers) {
+			continue
+		}
+		le, err := GetLabelValueForKey(metric, "le")
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract bucket boundary for metric %s", metricName)
+		}
+		bound, err := strconv.ParseFloat(le, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket boundary %q for metric %s", le, metricName)
+		}
+		histogram.Buckets[bucketBoundary(bound)] += metric.GetUntyped().GetValue()
+		found = true
+	}
 	if !found {
-		return nil, fmt.Errorf("metrics %s not found", metricName)
+		return nil, fmt.Errorf("metrics %s not found for labels %v", metricName, matchers)
 	}
 	return histogram, nil
 }
 
-// extractBucketBoundary extracts the `le` label from a bucket line.
-func extractBucketBoundary(line string) string {
-	startIndex := strings.Index(line, `le="`)
-	if startIndex == -1 {
-		return ""
+// bucketBoundary formats the upper bound of a histogram bucket as its le label, e.g. "0.1" or "+Inf", to key the buckets
+// of every parsed histogram the same way.
+func bucketBoundary(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
+// ParseMetricFromBody parses a simple metric from the Prometheus response body, summing its series.
+func ParseMetricFromBody(body []byte, metricName string) (float64, error) {
+	return ParseMetricSeriesFromBody(body, metricName, nil)
+}
+
+// ParseMetricSeriesFromBody parses a simple metric from the Prometheus response body, summing the series whose labels
+// match the matchers, e.g. {"model_name": "llama-3-8b"} for the series of a model when a pod serves several models.
+// The _sum and _count of a histogram or a summary are metrics of their own, so that the latency averages can be
+// computed from them.
+func ParseMetricSeriesFromBody(body []byte, metricName string, matchers map[string]string) (float64, error) {
+	families, err := ParseMetricFamilies(body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse metric value for %s: %w", metricName, err)
+	}
+
+	var sample func(*dto.Metric) (float64, error)
+	family, ok := families[metricName]
+	if ok {
+		sample = func(metric *dto.Metric) (float64, error) {
+			return GetCounterGaugeValue(metric, family.GetType())
+		}
+	} else if base, found := strings.CutSuffix(metricName, "_sum"); found && hasSampleSumAndCount(families[base]) {
+		// the _sum and _count series are parsed into the family of their histogram or summary.
+		family = families[base]
+		sample = sampleSum
+	} else if base, found := strings.CutSuffix(metricName, "_count"); found && hasSampleSumAndCount(families[base]) {
+		family = families[base]
+		sample = sampleCount
+	} else {
+		return 0, fmt.Errorf("metrics %s not found", metricName)
 	}
-	startIndex += len(`le="`)
-	endIndex := strings.Index(line[startIndex:], `"`)
-	if endIndex == -1 {
-		return ""
+
+	var total float64
+	found := false
+	for _, metric := range family.GetMetric() {
+		if !matchLabels(metric, matchers) {
+			continue
+		}
+		value, err := sample(metric)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse metric value for %s: %w", metricName, err)
+		}
+		total += value
+		found = true
+	}
+	if !found {
+		return 0, fmt.Errorf("metrics %s not found for labels %v", metricName, matchers)
 	}
-	return line[startIndex : startIndex+endIndex]
+	return total, nil
 }
 
-// extractMetricValue extracts the metric value from a Prometheus metric line.
-func extractMetricValue(line string) (float64, error) {
-	parts := strings.Fields(line)
-	if len(parts) < 2 {
-		return 0, fmt.Errorf("unexpected format: %s", line)
+func hasSampleSumAndCount(family *dto.MetricFamily) bool {
+	return family != nil && (family.GetType() == dto.MetricType_HISTOGRAM || family.GetType() == dto.MetricType_SUMMARY)
+}
+
+func sampleSum(metric *dto.Metric) (float64, error) {
+	if metric.GetHistogram() != nil {
+		return metric.GetHistogram().GetSampleSum(), nil
 	}
-	return strconv.ParseFloat(parts[len(parts)-1], 64)
+	return metric.GetSummary().GetSampleSum(), nil
 }
 
-// ParseMetricFromBody parses a simple metric from the Prometheus response body.
-func ParseMetricFromBody(body []byte, metricName string) (float64, error) {
-	lines := strings.Split(string(body), "\n")
-	for _, line := range lines {
-		if !strings.HasPrefix(line, "#") && strings.Contains(line, metricName) {
-			value, err := extractMetricValue(line)
-			if err != nil {
-				return 0, fmt.Errorf("failed to parse metric value for %s: %w", metricName, err)
-			}
-			return value, nil
+func sampleCount(metric *dto.Metric) (float64, error) {
+	if metric.GetHistogram() != nil {
+		return float64(metric.GetHistogram().GetSampleCount()), nil
+	}
+	return float64(metric.GetSummary().GetSampleCount()), nil
+}
+
+// matchLabels returns whether the series has all the labels of the matchers, with their values.
+func matchLabels(metric *dto.Metric, matchers map[string]string) bool {
+	for name, value := range matchers {
+		if labelValue, err := GetLabelValueForKey(metric, name); err != nil || labelValue != value {
+			return false
 		}
 	}
-	return 0, fmt.Errorf("metrics %s not found", metricName)
+	return true
 }
 
 // BuildQuery dynamically injects labels into a PromQL query template.
@@ -177,6 +260,9 @@ func GetCounterGaugeValue(metric *dto.Metric, metricType dto.MetricType) (float6
 		return metric.GetCounter().GetValue(), nil
 	} else if metricType == dto.MetricType_GAUGE {
 		return metric.GetGauge().GetValue(), nil
+	} else if metricType == dto.MetricType_UNTYPED {
+		// the metrics without a TYPE comment, e.g. of the engines which only print the samples.
+		return metric.GetUntyped().GetValue(), nil
 	}
 	return 0, fmt.Errorf("Metric type not supported: %v", metricType)
 }
@@ -193,8 +279,7 @@ func GetHistogramValue(metric *dto.Metric) (*HistogramMetricValue, error) {
 	histogram.Sum = histogramMetric.GetSampleSum()
 	histogram.Count = float64(histogramMetric.GetSampleCount())
 	for _, bucket := range histogramMetric.GetBucket() {
-		bound := fmt.Sprintf("%f", bucket.GetUpperBound())
-		histogram.Buckets[bound] = float64(bucket.GetCumulativeCount())
+		histogram.Buckets[bucketBoundary(bucket.GetUpperBound())] = float64(bucket.GetCumulativeCount())
 	}
 	return histogram, nil
 }
@@ -217,3 +302,13 @@ func ParseMetricsURL(url string) (map[string]*dto.MetricFamily, error) {
 	}
 	return allMetrics, nil
 }
+
+// ParseMetricFamilies parses the metric families of a scrape response body.
+func ParseMetricFamilies(body []byte) (map[string]*dto.MetricFamily, error) {
+	var parser expfmt.TextParser
+	allMetrics, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return make(map[string]*dto.MetricFamily), fmt.Errorf("error parsing metric families: %v", err)
+	}
+	return allMetrics, nil
+}
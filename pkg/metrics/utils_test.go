@@ -53,6 +53,25 @@ vllm:time_per_output_token_seconds_bucket{le="+Inf",model_name="Qwen/Qwen2.5-1.5
 	})
 }
 
+func TestExtractBucketBoundary(t *testing.T) {
+	line := `vllm:time_per_output_token_seconds_bucket{le="0.1",model_name="Qwen/Qwen2.5-1.5B-Instruct"} 29.0
+`
+
+	t.Run("Extract bucket boundary with model labels", func(t *testing.T) {
+		histogram, err := ParseHistogramFromBody([]byte(line), "vllm:time_per_output_token_seconds")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]float64{"0.1": 29.0}, histogram.Buckets)
+	})
+
+	t.Run("Extract bucket boundary of a typed histogram", func(t *testing.T) {
+		families, err := ParseMetricFamilies([]byte("# TYPE vllm:time_per_output_token_seconds histogram\n" + line))
+		assert.NoError(t, err)
+		histogram, err := GetHistogramValue(families["vllm:time_per_output_token_seconds"].GetMetric()[0])
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]float64{"0.1": 29.0}, histogram.Buckets)
+	})
+}
+
 func TestParseMetricFromBody(t *testing.T) {
 	body := []byte(`
 # HELP vllm:num_requests_waiting Number of requests waiting to be processed.
@@ -67,12 +86,58 @@ vllm:num_requests_waiting{model_name="Qwen/Qwen2.5-1.5B-Instruct"} 2
 	})
 }
 
-func TestExtractBucketBoundary(t *testing.T) {
-	line := `vllm:time_per_output_token_seconds_bucket{le="0.1",model_name="Qwen/Qwen2.5-1.5B-Instruct"} 29.0`
+func TestParseMetricSeriesFromBody(t *testing.T) {
+	body := []byte(`
+# HELP vllm:num_requests_running Number of requests running.
+# TYPE vllm:num_requests_running gauge
+vllm:num_requests_running{model_name="llama-3-8b"} 3
+vllm:num_requests_running{model_name="llama-3-8b-lora"} 2
+# TYPE vllm:e2e_request_latency_seconds histogram
+vllm:e2e_request_latency_seconds_bucket{le="1.0",model_name="llama-3-8b"} 1
+vllm:e2e_request_latency_seconds_bucket{le="+Inf",model_name="llama-3-8b"} 2
+vllm:e2e_request_latency_seconds_sum{model_name="llama-3-8b"} 4.5
+vllm:e2e_request_latency_seconds_count{model_name="llama-3-8b"} 2
+vllm:e2e_request_latency_seconds_bucket{le="1.0",model_name="llama-3-8b-lora"} 3
+vllm:e2e_request_latency_seconds_bucket{le="+Inf",model_name="llama-3-8b-lora"} 3
+vllm:e2e_request_latency_seconds_sum{model_name="llama-3-8b-lora"} 1.5
+vllm:e2e_request_latency_seconds_count{model_name="llama-3-8b-lora"} 3
+`)
 
-	t.Run("Extract bucket boundary with model labels", func(t *testing.T) {
-		boundary := extractBucketBoundary(line)
-		assert.Equal(t, "0.1", boundary)
+	testCases := []struct {
+		name       string
+		metricName string
+		matchers   map[string]string
+		expected   float64
+		expectErr  bool
+	}{
+		{name: "series of the model", metricName: "vllm:num_requests_running", matchers: map[string]string{"model_name": "llama-3-8b-lora"}, expected: 2},
+		{name: "sum of the series", metricName: "vllm:num_requests_running", expected: 5},
+		{name: "histogram sum", metricName: "vllm:e2e_request_latency_seconds_sum", matchers: map[string]string{"model_name": "llama-3-8b"}, expected: 4.5},
+		{name: "histogram count", metricName: "vllm:e2e_request_latency_seconds_count", expected: 5},
+		{name: "unknown model", metricName: "vllm:num_requests_running", matchers: map[string]string{"model_name": "mistral"}, expectErr: true},
+		{name: "unknown metric", metricName: "vllm:num_requests_waiting", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := ParseMetricSeriesFromBody(body, tc.metricName, tc.matchers)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+
+	t.Run("Parse histogram of a model", func(t *testing.T) {
+		histogram, err := ParseHistogramSeriesFromBody(body, "vllm:e2e_request_latency_seconds", map[string]string{"model_name": "llama-3-8b"})
+		assert.NoError(t, err)
+		assert.Equal(t, &HistogramMetricValue{Sum: 4.5, Count: 2, Buckets: map[string]float64{"1": 1, "+Inf": 2}}, histogram)
+		assert.Equal(t, 2.25, histogram.GetMean())
+
+		histogram, err = ParseHistogramFromBody(body, "vllm:e2e_request_latency_seconds")
+		assert.NoError(t, err)
+		assert.Equal(t, &HistogramMetricValue{Sum: 6, Count: 5, Buckets: map[string]float64{"1": 4, "+Inf": 5}}, histogram)
 	})
 }
 
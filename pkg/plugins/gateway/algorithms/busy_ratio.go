@@ -0,0 +1,210 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	RouterBusyRatio Algorithms = "busy-ratio"
+)
+
+func init() {
+	registerCacheRouter(RouterBusyRatio, NewBusyRatioRouter)
+}
+
+// The default weights of the requests of a pod in its busy score. A waiting request is queued behind the running ones,
+// and a swapped one must also copy its KV cache back to the GPU before it runs again.
+const (
+	defaultBusyRatioRunningWeight = 1
+	defaultBusyRatioWaitingWeight = 2
+	defaultBusyRatioSwappedWeight = 3
+)
+
+var defaultBusyRatioWeights = busyRatioWeights{
+	running: getBusyRatioWeight("AIBRIX_BUSY_RATIO_RUNNING_WEIGHT", defaultBusyRatioRunningWeight),
+	waiting: getBusyRatioWeight("AIBRIX_BUSY_RATIO_WAITING_WEIGHT", defaultBusyRatioWaitingWeight),
+	swapped: getBusyRatioWeight("AIBRIX_BUSY_RATIO_SWAPPED_WEIGHT", defaultBusyRatioSwappedWeight),
+}
+
+func getBusyRatioWeight(name string, defaultWeight float64) float64 {
+	value := utils.LoadEnv(name, "")
+	if value != "" {
+		floatValue, err := strconv.ParseFloat(value, 64)
+		if err != nil || floatValue < 0 {
+			klog.Infof("invalid %s: %s, falling back to default", name, value)
+		} else {
+			klog.Infof("using %s env value for busy ratio weight: %v", name, floatValue)
+			return floatValue
+		}
+	}
+	klog.Infof("using default %s: %v", name, defaultWeight)
+	return defaultWeight
+}
+
+// busyRatioWeights weigh the running, waiting and swapped requests of a pod in its busy score.
+type busyRatioWeights struct {
+	running float64
+	waiting float64
+	swapped float64
+}
+
+// podBusyLoad is the number of running, waiting and swapped requests a pod reports. The metrics the pod didn't report
+// count as zero and are listed in missing.
+type podBusyLoad struct {
+	running float64
+	waiting float64
+	swapped float64
+	missing []string
+}
+
+// observedCapacity returns the number of requests the pod runs at once, known when the pod queues requests: an
+// engine only queues the requests beyond its batch, so its running requests are then its capacity.
+func (l podBusyLoad) observedCapacity() (float64, bool) {
+	if l.waiting <= 0 || l.running <= 0 {
+		return 0, false
+	}
+	return l.running, true
+}
+
+// busyScore returns the weighted requests of the pod per unit of capacity, or the weighted requests when the capacity
+// is unknown, i.e. not positive. penalized is set when the pod didn't report some of the metrics, whose requests the
+// score then misses.
+func busyScore(load podBusyLoad, weights busyRatioWeights, capacity float64) (score float64, penalized bool) {
+	score = weights.running*load.running + weights.waiting*load.waiting + weights.swapped*load.swapped
+	if capacity > 0 {
+		score /= capacity
+	}
+	return score, len(load.missing) > 0
+}
+
+// busyRatioRouter routes the request to the ready pod with the lowest busy score, see busyScore. The capacity of a pod
+// which isn't queuing requests is unknown, it is assumed to be the largest capacity observed among the pods of the
+// model, which usually run with the same engine configuration. The pods with a complete score are preferred to the
+// penalized ones, and the ties are broken randomly.
+type busyRatioRouter struct {
+	cache   podMetricCache
+	weights busyRatioWeights
+	rand    func(int) int
+}
+
+func NewBusyRatioRouter(c *cache.Cache) (Router, error) {
+	return busyRatioRouter{
+		cache:   c,
+		weights: defaultBusyRatioWeights,
+		rand:    rand.Intn,
+	}, nil
+}
+
+func (r busyRatioRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no pods to forward request")
+	}
+
+	// the routable pods have an IP and a port.
+	readyPods := filterRoutablePods(pods)
+	if len(readyPods) == 0 {
+		return "", ErrNoReadyPods
+	}
+	randomFn := r.rand
+	if randomFn == nil {
+		randomFn = rand.Intn
+	}
+
+	var measured []*v1.Pod
+	loads := make(map[string]podBusyLoad, len(readyPods))
+	var modelCapacity float64
+	for _, pod := range readyPods {
+		load := r.getBusyLoad(pod, model)
+		if len(load.missing) == 3 {
+			klog.V(4).InfoS("skipping pod without request metrics", "pod", pod.Name, "model", model)
+			continue
+		}
+		measured = append(measured, pod)
+		loads[pod.Name] = load
+		if capacity, ok := load.observedCapacity(); ok && capacity > modelCapacity {
+			modelCapacity = capacity
+		}
+	}
+	// Use fallback if no valid metrics
+	if len(measured) == 0 {
+		targetPod, err := selectFallbackPod(RouterBusyRatio, pods, randomFn)
+		if err != nil {
+			return "", err
+		}
+		return getPodAddress(targetPod)
+	}
+
+	var candidates []*v1.Pod
+	var minScore float64
+	minPenalized := true
+	for _, pod := range measured {
+		load := loads[pod.Name]
+		capacity, ok := load.observedCapacity()
+		if !ok {
+			capacity = modelCapacity
+		}
+		score, penalized := busyScore(load, r.weights, capacity)
+		klog.V(4).InfoS("busy score", "pod", pod.Name, "running", load.running, "waiting", load.waiting,
+			"swapped", load.swapped, "capacity", capacity, "score", score, "missingMetrics", load.missing)
+
+		better := len(candidates) == 0 || (!penalized && minPenalized) || (penalized == minPenalized && score < minScore)
+		if better {
+			minScore, minPenalized = score, penalized
+			candidates = candidates[:0]
+		}
+		if penalized == minPenalized && score == minScore {
+			candidates = append(candidates, pod)
+		}
+	}
+	targetPod := candidates[randomFn(len(candidates))]
+
+	klog.V(4).Infof("targetPod: %v", targetPod.Name)
+	return getPodAddress(targetPod)
+}
+
+// getBusyLoad returns the requests the pod reports for the model, from one snapshot of its metrics.
+func (r busyRatioRouter) getBusyLoad(pod *v1.Pod, model string) podBusyLoad {
+	snapshot := r.cache.GetPodSnapshot(pod.Name)
+	var load podBusyLoad
+	for _, m := range []struct {
+		name  string
+		value *float64
+	}{
+		{metrics.NumRequestsRunning, &load.running},
+		{metrics.NumRequestsWaiting, &load.waiting},
+		{metrics.NumRequestsSwapped, &load.swapped},
+	} {
+		value, err := snapshot.PodModelMetric(model, m.name)
+		if err != nil {
+			load.missing = append(load.missing, m.name)
+			continue
+		}
+		*m.value = value.GetSimpleValue()
+	}
+	return load
+}
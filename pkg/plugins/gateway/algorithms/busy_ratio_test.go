@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestBusyScore(t *testing.T) {
+	defaultWeights := busyRatioWeights{running: 1, waiting: 2, swapped: 3}
+	testCases := []struct {
+		name              string
+		load              podBusyLoad
+		weights           busyRatioWeights
+		capacity          float64
+		expectedScore     float64
+		expectedPenalized bool
+	}{
+		{
+			name:          "weighted requests",
+			load:          podBusyLoad{running: 4, waiting: 1, swapped: 1},
+			weights:       defaultWeights,
+			expectedScore: 9,
+		},
+		{
+			name:          "normalized by the capacity",
+			load:          podBusyLoad{running: 4, waiting: 1, swapped: 1},
+			weights:       defaultWeights,
+			capacity:      3,
+			expectedScore: 3,
+		},
+		{
+			name:          "unknown capacity",
+			load:          podBusyLoad{running: 4},
+			weights:       defaultWeights,
+			capacity:      0,
+			expectedScore: 4,
+		},
+		{
+			name:          "custom weights",
+			load:          podBusyLoad{running: 4, waiting: 1, swapped: 1},
+			weights:       busyRatioWeights{running: 0.5, waiting: 4, swapped: 0},
+			capacity:      2,
+			expectedScore: 3,
+		},
+		{
+			name:              "missing metrics count as zero",
+			load:              podBusyLoad{running: 2, waiting: 1, missing: []string{metrics.NumRequestsSwapped}},
+			weights:           defaultWeights,
+			expectedScore:     4,
+			expectedPenalized: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			score, penalized := busyScore(tc.load, tc.weights, tc.capacity)
+			assert.InDelta(t, tc.expectedScore, score, 1e-9)
+			assert.Equal(t, tc.expectedPenalized, penalized)
+		})
+	}
+}
+
+func TestBusyRatioRoute(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1":    newReadyPod("p1", "10.0.0.1"),
+		"p2":    newReadyPod("p2", "10.0.0.2"),
+		"p3":    newReadyPod("p3", "10.0.0.3"),
+		"no-ip": newReadyPod("no-ip", ""),
+	}
+	testCases := []struct {
+		name     string
+		cache    fakePodMetricCache
+		expected []string
+		// random is set when the routes pick among the expected pods in the random order of the pod map.
+		random bool
+	}{
+		{
+			name: "lowest busy score",
+			cache: fakePodMetricCache{
+				"p1":    {metrics.NumRequestsRunning: 6, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 0},
+				"p2":    {metrics.NumRequestsRunning: 2, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 1},
+				"p3":    {metrics.NumRequestsRunning: 8, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 0},
+				"no-ip": {metrics.NumRequestsRunning: 0, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 0},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			// p1 queues behind 4 running requests, and p2 runs 6 requests without queuing: assuming the capacity of
+			// p1, p2 scores 6/4 against (4+2*2)/4 for p1. p3 runs less without its capacity, but swaps 2 requests.
+			name: "normalized by the observed capacity",
+			cache: fakePodMetricCache{
+				"p1": {metrics.NumRequestsRunning: 4, metrics.NumRequestsWaiting: 2, metrics.NumRequestsSwapped: 0},
+				"p2": {metrics.NumRequestsRunning: 6, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 0},
+				"p3": {metrics.NumRequestsRunning: 1, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 2},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			name: "ties",
+			cache: fakePodMetricCache{
+				"p1": {metrics.NumRequestsRunning: 3, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 0},
+				"p2": {metrics.NumRequestsRunning: 1, metrics.NumRequestsWaiting: 1, metrics.NumRequestsSwapped: 0},
+				"p3": {metrics.NumRequestsRunning: 9, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 0},
+			},
+			expected: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name: "pods with missing metrics are penalized",
+			cache: fakePodMetricCache{
+				"p1": {metrics.NumRequestsRunning: 0},
+				"p2": {metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 0},
+				"p3": {metrics.NumRequestsRunning: 9, metrics.NumRequestsWaiting: 3, metrics.NumRequestsSwapped: 1},
+			},
+			expected: []string{"10.0.0.3"},
+		},
+		{
+			name: "penalized pods are routed without complete metrics",
+			cache: fakePodMetricCache{
+				"p1": {metrics.NumRequestsRunning: 2},
+				"p2": {metrics.NumRequestsRunning: 1, metrics.NumRequestsWaiting: 0},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			name:     "random fallback without metrics",
+			cache:    fakePodMetricCache{"p1": {metrics.GPUCacheUsagePerc: 0}},
+			expected: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			random:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chosen := map[string]bool{}
+			for i := 0; i < 3; i++ {
+				choice := i
+				r := busyRatioRouter{
+					cache:   tc.cache,
+					weights: busyRatioWeights{running: 1, waiting: 2, swapped: 3},
+					rand:    func(n int) int { return choice % n },
+				}
+				target, err := r.Route(context.TODO(), pods, "m1", "")
+				assert.NoError(t, err)
+				chosen[target] = true
+			}
+			expected := map[string]bool{}
+			for _, ip := range tc.expected {
+				expected[ip+":8000"] = true
+			}
+			if tc.random {
+				for target := range chosen {
+					assert.Contains(t, expected, target)
+				}
+				return
+			}
+			assert.Equal(t, expected, chosen)
+		})
+	}
+}
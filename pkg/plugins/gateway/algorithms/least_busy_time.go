@@ -32,27 +32,21 @@ var (
 )
 
 func init() {
-	router, err := NewLeastBusyTimeRouter()
-	Register(RouterLeastBusyTime, func() (Router, error) { return router, err })
+	registerCacheRouter(RouterLeastBusyTime, NewLeastBusyTimeRouter)
 }
 
 type leastBusyTimeRouter struct {
 	cache *cache.Cache
 }
 
-func NewLeastBusyTimeRouter() (Router, error) {
-	c, err := cache.GetCache()
-	if err != nil {
-		return nil, err
-	}
-
+func NewLeastBusyTimeRouter(c *cache.Cache) (Router, error) {
 	return leastBusyTimeRouter{
 		cache: c,
 	}, nil
 }
 
 func (r leastBusyTimeRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
-	var targetPodIP string
+	var targetPod *v1.Pod
 	minBusyTimeRatio := math.MaxFloat64 // <= 1 in general
 
 	if len(pods) == 0 {
@@ -60,11 +54,12 @@ func (r leastBusyTimeRouter) Route(ctx context.Context, pods map[string]*v1.Pod,
 	}
 
 	for _, pod := range pods {
-		if pod.Status.PodIP == "" {
+		// the pods without a resolvable port were already logged by the cache.
+		if _, err := getPodPort(pod); !IsPodRoutable(pod) || err != nil {
 			continue
 		}
 
-		busyTimeRatio, err := r.cache.GetPodMetric(pod.Name, "gpu_busy_time_ratio") // todo: replace mock
+		busyTimeRatio, err := r.cache.GetPodSnapshot(pod.Name).PodMetric("gpu_busy_time_ratio") // todo: replace mock
 		if err != nil {
 			klog.Error(err)
 			continue
@@ -74,23 +69,22 @@ func (r leastBusyTimeRouter) Route(ctx context.Context, pods map[string]*v1.Pod,
 
 		if busyTimeRatioValue < minBusyTimeRatio {
 			minBusyTimeRatio = busyTimeRatioValue
-			targetPodIP = pod.Status.PodIP
+			targetPod = pod
 		}
 	}
 
 	// Use fallback if no valid metrics
-	if targetPodIP == "" {
-		klog.Warning("No pods with valid metrics found; selecting a pod randomly as fallback")
+	if targetPod == nil {
 		var err error
-		targetPodIP, err = selectRandomPod(pods, rand.Intn)
+		targetPod, err = selectFallbackPod(RouterLeastBusyTime, pods, rand.Intn)
 		if err != nil {
 			return "", err
 		}
 	}
 
-	if targetPodIP == "" {
+	if targetPod == nil {
 		return "", fmt.Errorf("no available pods for request routing")
 	}
 
-	return targetPodIP + ":" + podMetricPort, nil
+	return getPodAddress(targetPod)
 }
@@ -19,11 +19,12 @@ package routingalgorithms
 import (
 	"context"
 	"fmt"
-	"math"
 	"math/rand"
+	"strconv"
 
 	"github.com/vllm-project/aibrix/pkg/cache"
 	metrics "github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 )
@@ -33,76 +34,145 @@ var (
 )
 
 func init() {
-	router, err := NewLeastKvCacheRouter()
-	Register(RouterLeastKvCache, func() (Router, error) { return router, err })
+	registerCacheRouter(RouterLeastKvCache, NewLeastKvCacheRouter)
 }
 
-type leastKvCacheRouter struct {
-	cache *cache.Cache
+// defaultKvCacheHighWatermark is the GPU KV cache usage from which a pod is saturated: the requests routed to it
+// would preempt its running sequences.
+const defaultKvCacheHighWatermark = 0.95
+
+var kvCacheHighWatermark = getKvCacheHighWatermark()
+
+func getKvCacheHighWatermark() float64 {
+	value := utils.LoadEnv("AIBRIX_KV_CACHE_HIGH_WATERMARK", "")
+	if value != "" {
+		floatValue, err := strconv.ParseFloat(value, 64)
+		if err != nil || floatValue <= 0 || floatValue > 1 {
+			klog.Infof("invalid AIBRIX_KV_CACHE_HIGH_WATERMARK: %s, valid value between 0 and 1, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_KV_CACHE_HIGH_WATERMARK env value for kv cache high watermark: %v", floatValue)
+			return floatValue
+		}
+	}
+	klog.Infof("using default kv cache high watermark: %v", defaultKvCacheHighWatermark)
+	return defaultKvCacheHighWatermark
 }
 
-func NewLeastKvCacheRouter() (Router, error) {
-	c, err := cache.GetCache()
-	if err != nil {
-		return nil, err
-	}
+// leastKvCacheRouter routes the request to the ready pod with the lowest GPU KV cache usage, the CPU KV cache usage
+// breaks the ties and the remaining ties are broken randomly. The pods whose GPU KV cache usage reaches the high
+// watermark are saturated and only receive requests when no other pod can.
+type leastKvCacheRouter struct {
+	cache         podMetricCache
+	highWatermark float64
+	rand          func(int) int
+}
 
+func NewLeastKvCacheRouter(c *cache.Cache) (Router, error) {
 	return leastKvCacheRouter{
-		cache: c,
+		cache:         c,
+		highWatermark: kvCacheHighWatermark,
+		rand:          rand.Intn,
 	}, nil
 }
 
-func (r leastKvCacheRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
-	var targetPodIP string
-	minKvCache := math.MaxFloat64
+// kvCacheUsage is the KV cache usage of a pod, as fractions of its GPU and CPU KV cache.
+type kvCacheUsage struct {
+	gpu float64
+	cpu float64
+}
+
+func (u kvCacheUsage) less(other kvCacheUsage) bool {
+	return u.gpu < other.gpu || (u.gpu == other.gpu && u.cpu < other.cpu)
+}
 
+func (r leastKvCacheRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
 	if len(pods) == 0 {
 		return "", fmt.Errorf("no pods to forward request")
 	}
 
-	for _, pod := range pods {
-		if pod.Status.PodIP == "" {
-			continue
-		}
+	// the routable pods have an IP and a port.
+	readyPods := filterRoutablePods(pods)
+	if len(readyPods) == 0 {
+		return "", ErrNoReadyPods
+	}
+	randomFn := r.rand
+	if randomFn == nil {
+		randomFn = rand.Intn
+	}
 
-		// Due to metric refactor (pull/543) to better support lora and multi models,
-		// we change to use PodModelMetrics instead of PodMetrics in some scenarios.
-		// This works but doesn't look very promising, we can revisit this part later.
-		gpuCache, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.GPUCacheUsagePerc)
+	var available, saturated []*v1.Pod
+	unmeasured := map[string]*v1.Pod{}
+	usages := make(map[string]kvCacheUsage, len(readyPods))
+	for _, pod := range readyPods {
+		usage, err := r.getKvCacheUsage(pod, model)
 		if err != nil {
-			klog.Error(err)
+			klog.V(4).InfoS("skipping pod without kv cache metrics", "pod", pod.Name, "model", model, "err", err)
+			unmeasured[pod.Name] = pod
 			continue
 		}
-		cpuCache, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.CPUCacheUsagePerc)
-		if err != nil {
-			klog.Error(err)
-			continue
-		}
-		totalCache := gpuCache.GetSimpleValue() + cpuCache.GetSimpleValue()
-
-		klog.V(4).Infof("pod: %v, podIP: %v, gpuCache: %v, cpuCache: %v, kaCache: %v",
-			pod.Name, pod.Status.PodIP, gpuCache.GetSimpleValue(), cpuCache.GetSimpleValue(), totalCache)
-
-		if totalCache <= minKvCache {
-			minKvCache = totalCache
-			targetPodIP = pod.Status.PodIP
+		usages[pod.Name] = usage
+		if usage.gpu >= r.highWatermark {
+			saturated = append(saturated, pod)
+		} else {
+			available = append(available, pod)
 		}
 	}
 
-	// Use fallback if no valid metrics
-	if targetPodIP == "" {
-		klog.Warning("No pods with valid metrics found; selecting a pod randomly as fallback")
+	var targetPod *v1.Pod
+	switch {
+	case len(available) > 0:
+		targetPod = selectLeastKvCachePod(available, usages, randomFn)
+	case len(unmeasured) > 0:
+		// the pods without metrics may have room left, unlike the saturated ones.
 		var err error
-		targetPodIP, err = selectRandomPod(pods, rand.Intn)
+		targetPod, err = selectFallbackPod(RouterLeastKvCache, unmeasured, randomFn)
 		if err != nil {
 			return "", err
 		}
+	default:
+		// every pod is saturated, the least saturated one preempts the fewest sequences.
+		targetPod = selectLeastKvCachePod(saturated, usages, randomFn)
+		klog.V(4).InfoS("all pods reached the kv cache high watermark", "model", model, "highWatermark", r.highWatermark)
 	}
 
-	if targetPodIP == "" {
-		return "", fmt.Errorf("no pods to forward request")
+	klog.V(4).Infof("targetPod: %v", targetPod.Name)
+	return getPodAddress(targetPod)
+}
+
+// selectLeastKvCachePod returns the pod with the lowest KV cache usage, the ties are broken randomly.
+func selectLeastKvCachePod(pods []*v1.Pod, usages map[string]kvCacheUsage, randomFn func(int) int) *v1.Pod {
+	candidates := []*v1.Pod{pods[0]}
+	minUsage := usages[pods[0].Name]
+	for _, pod := range pods[1:] {
+		usage := usages[pod.Name]
+		if usage.less(minUsage) {
+			minUsage = usage
+			candidates = candidates[:0]
+		}
+		if usage == minUsage {
+			candidates = append(candidates, pod)
+		}
+	}
+	return candidates[randomFn(len(candidates))]
+}
+
+// getKvCacheUsage returns the KV cache usage of the pod. The GPU KV cache usage is required, the CPU one is not
+// reported by every engine and counts as empty when it is missing.
+func (r leastKvCacheRouter) getKvCacheUsage(pod *v1.Pod, model string) (kvCacheUsage, error) {
+	// Due to metric refactor (pull/543) to better support lora and multi models,
+	// we change to use PodModelMetrics instead of PodMetrics in some scenarios.
+	// This works but doesn't look very promising, we can revisit this part later.
+	snapshot := r.cache.GetPodSnapshot(pod.Name)
+	gpuCache, err := snapshot.PodModelMetric(model, metrics.GPUCacheUsagePerc)
+	if err != nil {
+		return kvCacheUsage{}, err
+	}
+	usage := kvCacheUsage{gpu: gpuCache.GetSimpleValue()}
+	if cpuCache, err := snapshot.PodModelMetric(model, metrics.CPUCacheUsagePerc); err == nil {
+		usage.cpu = cpuCache.GetSimpleValue()
 	}
 
-	klog.V(4).Infof("targetPodIP: %v", targetPodIP)
-	return targetPodIP + ":" + podMetricPort, nil
+	klog.V(4).Infof("pod: %v, podIP: %v, gpuCache: %v, cpuCache: %v",
+		pod.Name, pod.Status.PodIP, usage.gpu, usage.cpu)
+	return usage, nil
 }
@@ -0,0 +1,130 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestLeastKvCacheRoute(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1":    newReadyPod("p1", "10.0.0.1"),
+		"p2":    newReadyPod("p2", "10.0.0.2"),
+		"p3":    newReadyPod("p3", "10.0.0.3"),
+		"no-ip": newReadyPod("no-ip", ""),
+	}
+	testCases := []struct {
+		name     string
+		cache    fakePodMetricCache
+		expected []string
+		// random is set when the routes pick among the expected pods in the random order of the pod map.
+		random bool
+	}{
+		{
+			name: "lowest gpu kv cache usage",
+			cache: fakePodMetricCache{
+				"p1":    {metrics.GPUCacheUsagePerc: 0.5, metrics.CPUCacheUsagePerc: 0},
+				"p2":    {metrics.GPUCacheUsagePerc: 0.3, metrics.CPUCacheUsagePerc: 0.9},
+				"p3":    {metrics.GPUCacheUsagePerc: 0.4, metrics.CPUCacheUsagePerc: 0},
+				"no-ip": {metrics.GPUCacheUsagePerc: 0, metrics.CPUCacheUsagePerc: 0},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			name: "cpu kv cache usage breaks the ties",
+			cache: fakePodMetricCache{
+				"p1": {metrics.GPUCacheUsagePerc: 0.3, metrics.CPUCacheUsagePerc: 0.2},
+				"p2": {metrics.GPUCacheUsagePerc: 0.3, metrics.CPUCacheUsagePerc: 0.1},
+				"p3": {metrics.GPUCacheUsagePerc: 0.3},
+			},
+			expected: []string{"10.0.0.3"},
+		},
+		{
+			name: "ties",
+			cache: fakePodMetricCache{
+				"p1": {metrics.GPUCacheUsagePerc: 0.3, metrics.CPUCacheUsagePerc: 0.1},
+				"p2": {metrics.GPUCacheUsagePerc: 0.3, metrics.CPUCacheUsagePerc: 0.1},
+				"p3": {metrics.GPUCacheUsagePerc: 0.6, metrics.CPUCacheUsagePerc: 0},
+			},
+			expected: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name: "saturated pods are skipped",
+			cache: fakePodMetricCache{
+				"p1": {metrics.GPUCacheUsagePerc: 0.95},
+				"p2": {metrics.GPUCacheUsagePerc: 0.99},
+				"p3": {metrics.GPUCacheUsagePerc: 0.9},
+			},
+			expected: []string{"10.0.0.3"},
+		},
+		{
+			name: "pods without metrics are preferred to saturated pods",
+			cache: fakePodMetricCache{
+				"p1": {metrics.GPUCacheUsagePerc: 0.97},
+				"p2": {metrics.GPUCacheUsagePerc: 0.98},
+				"p3": {metrics.CPUCacheUsagePerc: 0},
+			},
+			expected: []string{"10.0.0.3"},
+		},
+		{
+			name: "least saturated pod when all pods are saturated",
+			cache: fakePodMetricCache{
+				"p1": {metrics.GPUCacheUsagePerc: 0.99},
+				"p2": {metrics.GPUCacheUsagePerc: 0.96},
+				"p3": {metrics.GPUCacheUsagePerc: 1},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			name: "random fallback without metrics",
+			cache: fakePodMetricCache{
+				"p1": {metrics.CPUCacheUsagePerc: 0},
+			},
+			expected: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			random:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chosen := map[string]bool{}
+			for i := 0; i < 3; i++ {
+				choice := i
+				r := leastKvCacheRouter{cache: tc.cache, highWatermark: defaultKvCacheHighWatermark, rand: func(n int) int { return choice % n }}
+				target, err := r.Route(context.TODO(), pods, "m1", "")
+				assert.NoError(t, err)
+				chosen[target] = true
+			}
+			expected := map[string]bool{}
+			for _, ip := range tc.expected {
+				expected[ip+":8000"] = true
+			}
+			if tc.random {
+				for target := range chosen {
+					assert.Contains(t, expected, target)
+				}
+				return
+			}
+			assert.Equal(t, expected, chosen)
+		})
+	}
+}
@@ -33,27 +33,21 @@ var (
 )
 
 func init() {
-	router, err := NewLeastExpectedLatencyRouter()
-	Register(RouterLeastLatency, func() (Router, error) { return router, err })
+	registerCacheRouter(RouterLeastLatency, NewLeastExpectedLatencyRouter)
 }
 
 type leastExpectedLatencyRouter struct {
 	cache *cache.Cache
 }
 
-func NewLeastExpectedLatencyRouter() (Router, error) {
-	c, err := cache.GetCache()
-	if err != nil {
-		return nil, err
-	}
-
+func NewLeastExpectedLatencyRouter(c *cache.Cache) (Router, error) {
 	return leastExpectedLatencyRouter{
 		cache: c,
 	}, nil
 }
 
 func (r leastExpectedLatencyRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
-	var targetPodIP string
+	var targetPod *v1.Pod
 	minExpectedLatency := math.MaxFloat64
 
 	if len(pods) == 0 {
@@ -65,12 +59,13 @@ func (r leastExpectedLatencyRouter) Route(ctx context.Context, pods map[string]*
 	cntPromt := 0
 	cntGeneration := 0
 	for _, pod := range pods {
-		avgPromptTokens, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.AvgPromptToksPerReq)
+		snapshot := r.cache.GetPodSnapshot(pod.Name)
+		avgPromptTokens, err := snapshot.PodModelMetric(model, metrics.AvgPromptToksPerReq)
 		if err != nil {
 			klog.Error(err)
 			continue
 		}
-		avgGenerationTokens, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.AvgGenerationToksPerReq)
+		avgGenerationTokens, err := snapshot.PodModelMetric(model, metrics.AvgGenerationToksPerReq)
 		if err != nil {
 			klog.Error(err)
 			continue
@@ -94,24 +89,27 @@ func (r leastExpectedLatencyRouter) Route(ctx context.Context, pods map[string]*
 	}
 
 	for _, pod := range pods {
-		if pod.Status.PodIP == "" {
+		// the pods without a resolvable port were already logged by the cache.
+		if _, err := getPodPort(pod); !IsPodRoutable(pod) || err != nil {
 			continue
 		}
 
+		snapshot := r.cache.GetPodSnapshot(pod.Name)
+
 		// expected queuing latency
-		queuingLatency, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.RequestQueueTimeSeconds)
+		queuingLatency, err := snapshot.PodModelMetric(model, metrics.RequestQueueTimeSeconds)
 		if err != nil {
 			klog.Error(err)
 			continue
 		}
 
 		// expected prefill latency
-		avgPromptTokens, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.AvgPromptToksPerReq)
+		avgPromptTokens, err := snapshot.PodModelMetric(model, metrics.AvgPromptToksPerReq)
 		if err != nil {
 			klog.Error(err)
 			continue
 		}
-		PrefillTime, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.RequestPrefillTimeSeconds)
+		PrefillTime, err := snapshot.PodModelMetric(model, metrics.RequestPrefillTimeSeconds)
 		if err != nil {
 			klog.Error(err)
 			continue
@@ -119,12 +117,12 @@ func (r leastExpectedLatencyRouter) Route(ctx context.Context, pods map[string]*
 		prefillLatency := PrefillTime.GetHistogramValue().GetMean() / avgPromptTokens.GetSimpleValue() * guessPromptTokens
 
 		// expected decode latency
-		avgGenerationTokens, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.AvgGenerationToksPerReq)
+		avgGenerationTokens, err := snapshot.PodModelMetric(model, metrics.AvgGenerationToksPerReq)
 		if err != nil {
 			klog.Error(err)
 			continue
 		}
-		DecodeTime, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.RequestDecodeTimeSeconds)
+		DecodeTime, err := snapshot.PodModelMetric(model, metrics.RequestDecodeTimeSeconds)
 		if err != nil {
 			klog.Error(err)
 			continue
@@ -137,23 +135,22 @@ func (r leastExpectedLatencyRouter) Route(ctx context.Context, pods map[string]*
 
 		if totalExpectedLatency <= minExpectedLatency {
 			minExpectedLatency = totalExpectedLatency
-			targetPodIP = pod.Status.PodIP
+			targetPod = pod
 		}
 	}
 
 	// Use fallback if no valid metrics
-	if targetPodIP == "" {
-		klog.Warning("No pods with valid metrics found; selecting a pod randomly as fallback")
+	if targetPod == nil {
 		var err error
-		targetPodIP, err = selectRandomPod(pods, rand.Intn)
+		targetPod, err = selectFallbackPod(RouterLeastLatency, pods, rand.Intn)
 		if err != nil {
 			return "", err
 		}
 	}
 
-	if targetPodIP == "" {
+	if targetPod == nil {
 		return "", fmt.Errorf("no pods to forward request")
 	}
 
-	return targetPodIP + ":" + podMetricPort, nil
+	return getPodAddress(targetPod)
 }
@@ -21,10 +21,10 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
 
 	"github.com/vllm-project/aibrix/pkg/cache"
 	"github.com/vllm-project/aibrix/pkg/metrics"
-	"github.com/vllm-project/aibrix/pkg/utils"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 )
@@ -34,80 +34,156 @@ var (
 )
 
 func init() {
-	router, err := NewLeastRequestRouter()
-	Register(RouterLeastRequest, func() (Router, error) { return router, err })
+	registerCacheRouter(RouterLeastRequest, NewLeastRequestRouter)
 }
 
-type leastRequestRouter struct {
-	cache *cache.Cache
+// leastRequestWaitingWeight weighs the waiting and swapped requests of a pod against its running ones, a waiting
+// request is queued behind the running ones and delays the requests routed to the pod.
+const leastRequestWaitingWeight = 2
+
+// podMetricCache is the subset of the cache the least-request router reads. The metrics of a pod are read from one
+// snapshot per routing decision, so that they come from the same scrape.
+type podMetricCache interface {
+	GetPodSnapshot(podName string) cache.PodSnapshot
 }
 
-func NewLeastRequestRouter() (Router, error) {
-	c, err := cache.GetCache()
-	if err != nil {
-		return nil, err
-	}
+// leastRequestRouter routes the request to the ready pod with the least outstanding requests: its running
+// requests plus its waiting and swapped ones, weighted by leastRequestWaitingWeight. The ties are broken randomly.
+type leastRequestRouter struct {
+	cache podMetricCache
+	rand  func(int) int
+}
 
+func NewLeastRequestRouter(c *cache.Cache) (Router, error) {
 	return leastRequestRouter{
 		cache: c,
+		rand:  rand.Intn,
 	}, nil
 }
 
 func (r leastRequestRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
-	var targetPodIP string
-	minCount := math.MaxFloat64
-
 	if len(pods) == 0 {
 		return "", fmt.Errorf("no pods to forward request")
 	}
 
-	readyPods := utils.FilterReadyPods(pods)
+	// the routable pods have an IP and a port.
+	readyPods := filterRoutablePods(pods)
 	if len(readyPods) == 0 {
-		return "", fmt.Errorf("no ready pods available for fallback")
+		return "", ErrNoReadyPods
+	}
+	randomFn := r.rand
+	if randomFn == nil {
+		randomFn = rand.Intn
 	}
 
-	for _, pod := range readyPods {
-		runningReq, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.NumRequestsRunning)
+	targetPod := r.selectPod(readyPods, model)
+	// Use fallback if no valid metrics
+	if targetPod == nil {
+		targetPod, err := selectFallbackPod(RouterLeastRequest, pods, randomFn)
 		if err != nil {
-			klog.Error(err)
-			continue
+			return "", err
 		}
-		waitingReq, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.NumRequestsWaiting)
+		return getPodAddress(targetPod)
+	}
+
+	return getPodAddress(targetPod)
+}
+
+// RouteN returns up to n ready pods by their outstanding requests, the least loaded first and the ties in random
+// order. The pods which do not report their requests come last, in random order.
+func (r leastRequestRouter) RouteN(ctx context.Context, pods map[string]*v1.Pod, model, message string, n int) ([]string, error) {
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no pods to forward request")
+	}
+	readyPods := filterRoutablePods(pods)
+	if len(readyPods) == 0 {
+		return nil, ErrNoReadyPods
+	}
+	randomFn := r.rand
+	if randomFn == nil {
+		randomFn = rand.Intn
+	}
+	for i := len(readyPods) - 1; i > 0; i-- {
+		j := randomFn(i + 1)
+		readyPods[i], readyPods[j] = readyPods[j], readyPods[i]
+	}
+
+	requests := make(map[string]float64, len(readyPods))
+	for _, pod := range readyPods {
+		totalReq, err := r.getOutstandingRequests(pod, model)
 		if err != nil {
-			klog.Error(err)
-			continue
+			totalReq = math.Inf(1)
 		}
-		swappedReq, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.NumRequestsSwapped)
+		requests[pod.Name] = totalReq
+	}
+	sort.SliceStable(readyPods, func(i, j int) bool {
+		return requests[readyPods[i].Name] < requests[readyPods[j].Name]
+	})
+
+	var targets []string
+	for _, pod := range readyPods {
+		if len(targets) == n {
+			break
+		}
+		address, err := getPodAddress(pod)
 		if err != nil {
-			klog.Error(err)
 			continue
 		}
+		targets = append(targets, address)
+	}
+	return targets, nil
+}
 
-		totalReq := runningReq.GetSimpleValue() + waitingReq.GetSimpleValue() + swappedReq.GetSimpleValue()
-		klog.V(4).Infof("pod: %v, podIP: %v, runningReq: %v, waitingReq: %v, swappedReq: %v, totalReq: %v",
-			pod.Name, pod.Status.PodIP, runningReq, waitingReq, swappedReq, totalReq)
-
-		if totalReq <= minCount {
-			minCount = totalReq
-			targetPodIP = pod.Status.PodIP
-		}
+// selectPod returns the pod with the least outstanding requests, nil if none of the pods reports its requests.
+func (r leastRequestRouter) selectPod(readyPods []*v1.Pod, model string) *v1.Pod {
+	randomFn := r.rand
+	if randomFn == nil {
+		randomFn = rand.Intn
 	}
 
-	// Use fallback if no valid metrics
-	if targetPodIP == "" {
-		klog.Warning("No pods with valid metrics found; selecting a pod randomly as fallback")
-		var err error
-		targetPodIP, err = selectRandomPod(pods, rand.Intn)
+	var candidates []*v1.Pod
+	minCount := math.MaxFloat64
+	for _, pod := range readyPods {
+		totalReq, err := r.getOutstandingRequests(pod, model)
 		if err != nil {
-			return "", err
+			klog.V(4).InfoS("skipping pod without request metrics", "pod", pod.Name, "model", model, "err", err)
+			continue
+		}
+		if totalReq < minCount {
+			minCount = totalReq
+			candidates = candidates[:0]
+		}
+		if totalReq == minCount {
+			candidates = append(candidates, pod)
 		}
 	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[randomFn(len(candidates))]
+}
 
-	if targetPodIP == "" {
-		return "", fmt.Errorf("no pods to forward request")
+// getOutstandingRequests returns the weighted outstanding requests of the pod. The running and waiting requests
+// are required, the swapped ones are not reported by every engine.
+func (r leastRequestRouter) getOutstandingRequests(pod *v1.Pod, model string) (float64, error) {
+	snapshot := r.cache.GetPodSnapshot(pod.Name)
+	runningReq, err := snapshot.PodModelMetric(model, metrics.NumRequestsRunning)
+	if err != nil {
+		return 0, err
+	}
+	waitingReq, err := snapshot.PodModelMetric(model, metrics.NumRequestsWaiting)
+	if err != nil {
+		return 0, err
+	}
+	queuedReq := waitingReq.GetSimpleValue()
+	if swappedReq, err := snapshot.PodModelMetric(model, metrics.NumRequestsSwapped); err == nil {
+		queuedReq += swappedReq.GetSimpleValue()
 	}
 
-	return targetPodIP + ":" + podMetricPort, nil
+	totalReq := runningReq.GetSimpleValue() + leastRequestWaitingWeight*queuedReq
+	klog.V(4).Infof("pod: %v, podIP: %v, runningReq: %v, queuedReq: %v, totalReq: %v",
+		pod.Name, pod.Status.PodIP, runningReq.GetSimpleValue(), queuedReq, totalReq)
+	return totalReq, nil
 }
 
 func (r *leastRequestRouter) SubscribedMetrics() []string {
@@ -0,0 +1,164 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakePodMetricCache serves the metrics of the pods for the model m1.
+type fakePodMetricCache map[string]map[string]float64
+
+func (c fakePodMetricCache) GetPodSnapshot(podName string) cache.PodSnapshot {
+	values, ok := c[podName]
+	if !ok {
+		return cache.NewPodSnapshot(podName, nil, nil)
+	}
+	modelMetrics := map[string]metrics.MetricValue{}
+	for metricName, value := range values {
+		modelMetrics[metricName] = &metrics.SimpleMetricValue{Value: value}
+	}
+	return cache.NewPodSnapshot(podName, map[string]metrics.MetricValue{}, map[string]map[string]metrics.MetricValue{"m1": modelMetrics})
+}
+
+func newReadyPod(name, ip string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			PodIP:      ip,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func TestLeastRequestRoute(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1":    newReadyPod("p1", "10.0.0.1"),
+		"p2":    newReadyPod("p2", "10.0.0.2"),
+		"p3":    newReadyPod("p3", "10.0.0.3"),
+		"no-ip": newReadyPod("no-ip", ""),
+	}
+	testCases := []struct {
+		name     string
+		cache    fakePodMetricCache
+		expected []string
+		// random is set when the routes pick among the expected pods in the random order of the pod map.
+		random bool
+	}{
+		{
+			name: "least outstanding requests",
+			cache: fakePodMetricCache{
+				"p1":    {metrics.NumRequestsRunning: 5, metrics.NumRequestsWaiting: 1},
+				"p2":    {metrics.NumRequestsRunning: 2, metrics.NumRequestsWaiting: 0},
+				"p3":    {metrics.NumRequestsRunning: 8, metrics.NumRequestsWaiting: 0},
+				"no-ip": {metrics.NumRequestsRunning: 0, metrics.NumRequestsWaiting: 0},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			// 4 running and 1 waiting weigh 6, more than the 5 running of p2.
+			name: "waiting requests weigh more",
+			cache: fakePodMetricCache{
+				"p1": {metrics.NumRequestsRunning: 4, metrics.NumRequestsWaiting: 1},
+				"p2": {metrics.NumRequestsRunning: 5, metrics.NumRequestsWaiting: 0},
+				"p3": {metrics.NumRequestsRunning: 3, metrics.NumRequestsWaiting: 0, metrics.NumRequestsSwapped: 2},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			name: "ties",
+			cache: fakePodMetricCache{
+				"p1": {metrics.NumRequestsRunning: 3, metrics.NumRequestsWaiting: 0},
+				"p2": {metrics.NumRequestsRunning: 1, metrics.NumRequestsWaiting: 1},
+				"p3": {metrics.NumRequestsRunning: 9, metrics.NumRequestsWaiting: 0},
+			},
+			expected: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name: "pods with missing metrics are skipped",
+			cache: fakePodMetricCache{
+				"p1": {metrics.NumRequestsRunning: 0},
+				"p2": {metrics.NumRequestsWaiting: 0},
+				"p3": {metrics.NumRequestsRunning: 9, metrics.NumRequestsWaiting: 3},
+			},
+			expected: []string{"10.0.0.3"},
+		},
+		{
+			name: "random fallback without metrics",
+			cache: fakePodMetricCache{
+				"p1": {metrics.NumRequestsRunning: 0},
+			},
+			expected: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			random:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chosen := map[string]bool{}
+			for i := 0; i < 3; i++ {
+				choice := i
+				r := leastRequestRouter{cache: tc.cache, rand: func(n int) int { return choice % n }}
+				target, err := r.Route(context.TODO(), pods, "m1", "")
+				assert.NoError(t, err)
+				chosen[target] = true
+			}
+			expected := map[string]bool{}
+			for _, ip := range tc.expected {
+				expected[ip+":8000"] = true
+			}
+			if tc.random {
+				for target := range chosen {
+					assert.Contains(t, expected, target)
+				}
+				return
+			}
+			assert.Equal(t, expected, chosen)
+		})
+	}
+}
+
+func TestLeastRequestRouteN(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1": newReadyPod("p1", "10.0.0.1"),
+		"p2": newReadyPod("p2", "10.0.0.2"),
+		"p3": newReadyPod("p3", "10.0.0.3"),
+		"p4": newReadyPod("p4", "10.0.0.4"),
+	}
+	r := leastRequestRouter{cache: fakePodMetricCache{
+		"p1": {metrics.NumRequestsRunning: 5, metrics.NumRequestsWaiting: 1},
+		"p2": {metrics.NumRequestsRunning: 2, metrics.NumRequestsWaiting: 0},
+		"p3": {metrics.NumRequestsRunning: 4, metrics.NumRequestsWaiting: 0},
+	}, rand: func(n int) int { return n - 1 }}
+
+	// the pod without metrics comes last.
+	targets, err := RouteN(context.TODO(), r, pods, "m1", "", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:8000", "10.0.0.3:8000", "10.0.0.1:8000", "10.0.0.4:8000"}, targets)
+
+	targets, err = RouteN(context.TODO(), r, pods, "m1", "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:8000", "10.0.0.3:8000"}, targets)
+}
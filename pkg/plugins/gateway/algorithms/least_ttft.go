@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	RouterLeastTTFT Algorithms = "least-ttft"
+)
+
+func init() {
+	registerCacheRouter(RouterLeastTTFT, NewLeastTTFTRouter)
+}
+
+// defaultLeastTTFTMinSamples is the number of requests a pod serves between its last two scrapes for their average
+// time to first token to be compared with the other pods.
+const defaultLeastTTFTMinSamples = 1
+
+var leastTTFTMinSamples = getLeastTTFTMinSamples()
+
+func getLeastTTFTMinSamples() int {
+	value := utils.LoadEnv("AIBRIX_LEAST_TTFT_MIN_SAMPLES", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_LEAST_TTFT_MIN_SAMPLES: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_LEAST_TTFT_MIN_SAMPLES env value for least ttft min samples: %d", intValue)
+			return intValue
+		}
+	}
+	klog.Infof("using default least ttft min samples: %d", defaultLeastTTFTMinSamples)
+	return defaultLeastTTFTMinSamples
+}
+
+// leastTTFTRouter routes the request to the ready pod with the lowest recent time to first token: the average time
+// to first token of the requests the pod served between its last two scrapes, from the deltas of the histogram kept
+// by the cache. The average of a pod which served fewer than minSamples requests in between is not meaningful, e.g.
+// a pod idle or just restarted, so the pods are then compared by their waiting requests instead. The ties are broken
+// randomly.
+type leastTTFTRouter struct {
+	cache      podMetricCache
+	minSamples int
+	rand       func(int) int
+}
+
+func NewLeastTTFTRouter(c *cache.Cache) (Router, error) {
+	return leastTTFTRouter{
+		cache:      c,
+		minSamples: leastTTFTMinSamples,
+		rand:       rand.Intn,
+	}, nil
+}
+
+func (r leastTTFTRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no pods to forward request")
+	}
+
+	// the routable pods have an IP and a port.
+	readyPods := filterRoutablePods(pods)
+	if len(readyPods) == 0 {
+		return "", ErrNoReadyPods
+	}
+	randomFn := r.rand
+	if randomFn == nil {
+		randomFn = rand.Intn
+	}
+
+	// each pod is read from a single snapshot, so that its ttft and its waiting requests are consistent.
+	ttfts := make(map[string]float64, len(readyPods))
+	waiting := make(map[string]float64, len(readyPods))
+	byTTFT := true
+	for _, pod := range readyPods {
+		snapshot := r.cache.GetPodSnapshot(pod.Name)
+		if waitingReq, err := snapshot.PodModelMetric(model, metrics.NumRequestsWaiting); err != nil {
+			klog.V(4).InfoS("skipping pod without request metrics", "pod", pod.Name, "model", model, "err", err)
+		} else {
+			waiting[pod.Name] = waitingReq.GetSimpleValue()
+		}
+		if !byTTFT {
+			continue
+		}
+		delta, err := snapshot.PodModelHistogramDelta(model, metrics.TimeToFirstTokenSeconds)
+		if err != nil || delta.Count < float64(r.minSamples) {
+			klog.V(4).InfoS("comparing the waiting requests of the pods, a pod served too few requests for its ttft",
+				"pod", pod.Name, "model", model, "samples", delta.Count, "minSamples", r.minSamples, "err", err)
+			byTTFT = false
+			continue
+		}
+		ttfts[pod.Name] = delta.Mean()
+	}
+
+	values := ttfts
+	if !byTTFT {
+		values = waiting
+	}
+
+	targetPod := selectLeastValuePod(readyPods, values, randomFn)
+	// Use fallback if no valid metrics
+	if targetPod == nil {
+		var err error
+		targetPod, err = selectFallbackPod(RouterLeastTTFT, pods, randomFn)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	klog.V(4).InfoS("least ttft target pod", "pod", targetPod.Name, "model", model, "byTTFT", byTTFT, "value", values[targetPod.Name])
+	return getPodAddress(targetPod)
+}
+
+// selectLeastValuePod returns the pod with the lowest value, the ties broken by randomFn. The pods without a value
+// are skipped, nil if none has one.
+func selectLeastValuePod(pods []*v1.Pod, values map[string]float64, randomFn func(int) int) *v1.Pod {
+	var candidates []*v1.Pod
+	var minValue float64
+	for _, pod := range pods {
+		value, ok := values[pod.Name]
+		if !ok {
+			continue
+		}
+		if len(candidates) == 0 || value < minValue {
+			minValue = value
+			candidates = candidates[:0]
+		}
+		if value == minValue {
+			candidates = append(candidates, pod)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[randomFn(len(candidates))]
+}
+
+func (r *leastTTFTRouter) SubscribedMetrics() []string {
+	return []string{
+		metrics.TimeToFirstTokenSeconds,
+		metrics.NumRequestsWaiting,
+	}
+}
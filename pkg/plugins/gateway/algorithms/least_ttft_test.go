@@ -0,0 +1,193 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+)
+
+// fakeTTFTCache serves the metrics of the pods and the deltas of their time to first token histogram for the model
+// m1, a pod without delta was scraped once or its counters were just reset.
+type fakeTTFTCache struct {
+	metrics fakePodMetricCache
+	deltas  map[string]cache.HistogramDelta
+	// reads counts the snapshots read per pod, if set.
+	reads map[string]int
+}
+
+func (c fakeTTFTCache) GetPodSnapshot(podName string) cache.PodSnapshot {
+	if c.reads != nil {
+		c.reads[podName]++
+	}
+	snapshot := c.metrics.GetPodSnapshot(podName)
+	if delta, ok := c.deltas[podName]; ok {
+		snapshot = snapshot.WithHistogramDelta("m1", metrics.TimeToFirstTokenSeconds, delta)
+	}
+	return snapshot
+}
+
+func TestLeastTTFTRoute(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1":    newReadyPod("p1", "10.0.0.1"),
+		"p2":    newReadyPod("p2", "10.0.0.2"),
+		"p3":    newReadyPod("p3", "10.0.0.3"),
+		"no-ip": newReadyPod("no-ip", ""),
+	}
+	waiting := fakePodMetricCache{
+		"p1": {metrics.NumRequestsWaiting: 4},
+		"p2": {metrics.NumRequestsWaiting: 6},
+		"p3": {metrics.NumRequestsWaiting: 2},
+	}
+	testCases := []struct {
+		name       string
+		cache      fakeTTFTCache
+		minSamples int
+		expected   []string
+		// random is set when the routes pick among the expected pods in the random order of the pod map.
+		random bool
+	}{
+		{
+			name: "lowest recent ttft",
+			cache: fakeTTFTCache{metrics: waiting, deltas: map[string]cache.HistogramDelta{
+				"p1": {Sum: 3, Count: 3},
+				"p2": {Sum: 1, Count: 2},
+				"p3": {Sum: 8, Count: 4},
+			}},
+			minSamples: 2,
+			expected:   []string{"10.0.0.2"},
+		},
+		{
+			name: "ties",
+			cache: fakeTTFTCache{metrics: waiting, deltas: map[string]cache.HistogramDelta{
+				"p1": {Sum: 2, Count: 4},
+				"p2": {Sum: 1, Count: 2},
+				"p3": {Sum: 8, Count: 4},
+			}},
+			minSamples: 2,
+			expected:   []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name: "waiting requests when a pod served too few requests",
+			cache: fakeTTFTCache{metrics: waiting, deltas: map[string]cache.HistogramDelta{
+				"p1": {Sum: 3, Count: 3},
+				"p2": {Sum: 1, Count: 2},
+				"p3": {Sum: 8, Count: 1},
+			}},
+			minSamples: 2,
+			expected:   []string{"10.0.0.3"},
+		},
+		{
+			name: "waiting requests when a pod idled between the scrapes",
+			cache: fakeTTFTCache{metrics: waiting, deltas: map[string]cache.HistogramDelta{
+				"p1": {Sum: 0, Count: 0},
+				"p2": {Sum: 1, Count: 2},
+				"p3": {Sum: 8, Count: 4},
+			}},
+			minSamples: 1,
+			expected:   []string{"10.0.0.3"},
+		},
+		{
+			name: "waiting requests when the counters of a pod were reset",
+			cache: fakeTTFTCache{metrics: fakePodMetricCache{
+				"p1": {metrics.NumRequestsWaiting: 0},
+				"p2": {metrics.NumRequestsWaiting: 6},
+				"p3": {metrics.NumRequestsWaiting: 2},
+			}, deltas: map[string]cache.HistogramDelta{
+				"p2": {Sum: 1, Count: 2},
+				"p3": {Sum: 8, Count: 4},
+			}},
+			minSamples: 1,
+			expected:   []string{"10.0.0.1"},
+		},
+		{
+			name: "pods without waiting requests are skipped",
+			cache: fakeTTFTCache{metrics: fakePodMetricCache{
+				"p2": {metrics.NumRequestsWaiting: 6},
+				"p3": {metrics.NumRequestsWaiting: 2},
+			}},
+			minSamples: 1,
+			expected:   []string{"10.0.0.3"},
+		},
+		{
+			name:       "random fallback without metrics",
+			cache:      fakeTTFTCache{},
+			minSamples: 1,
+			expected:   []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			random:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chosen := map[string]bool{}
+			for i := 0; i < 3; i++ {
+				choice := i
+				r := leastTTFTRouter{cache: tc.cache, minSamples: tc.minSamples, rand: func(n int) int { return choice % n }}
+				target, err := r.Route(context.TODO(), pods, "m1", "")
+				assert.NoError(t, err)
+				chosen[target] = true
+			}
+			expected := map[string]bool{}
+			for _, ip := range tc.expected {
+				expected[ip+":8000"] = true
+			}
+			if tc.random {
+				for target := range chosen {
+					assert.Contains(t, expected, target)
+				}
+				return
+			}
+			assert.Equal(t, expected, chosen)
+		})
+	}
+}
+
+func TestLeastTTFTRouteReadsOneSnapshotPerPod(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1": newReadyPod("p1", "10.0.0.1"),
+		"p2": newReadyPod("p2", "10.0.0.2"),
+	}
+	// p2 served too few requests, the pods are compared by their waiting requests read from the same snapshots.
+	c := fakeTTFTCache{
+		metrics: fakePodMetricCache{
+			"p1": {metrics.NumRequestsWaiting: 4},
+			"p2": {metrics.NumRequestsWaiting: 2},
+		},
+		deltas: map[string]cache.HistogramDelta{"p1": {Sum: 1, Count: 10}, "p2": {Sum: 1, Count: 1}},
+		reads:  map[string]int{},
+	}
+	r := leastTTFTRouter{cache: c, minSamples: 5, rand: func(n int) int { return 0 }}
+	target, err := r.Route(context.TODO(), pods, "m1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2:8000", target)
+	assert.Equal(t, map[string]int{"p1": 1, "p2": 1}, c.reads)
+}
+
+func TestLeastTTFTRouteWithoutReadyPods(t *testing.T) {
+	r := leastTTFTRouter{cache: fakeTTFTCache{}, minSamples: 1}
+
+	_, err := r.Route(context.TODO(), map[string]*v1.Pod{}, "m1", "")
+	assert.Error(t, err)
+	_, err = r.Route(context.TODO(), map[string]*v1.Pod{"no-ip": newReadyPod("no-ip", "")}, "m1", "")
+	assert.ErrorIs(t, err, ErrNoReadyPods)
+}
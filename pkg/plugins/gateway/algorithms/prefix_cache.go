@@ -22,6 +22,8 @@ import (
 	"math/rand"
 	"strconv"
 
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
 	"github.com/vllm-project/aibrix/pkg/plugins/gateway/prefixcacheindexer"
 	"github.com/vllm-project/aibrix/pkg/utils"
 	v1 "k8s.io/api/core/v1"
@@ -33,16 +35,17 @@ var (
 )
 
 func init() {
-	router, err := NewPrefixCacheRouter()
-	Register(RouterPrefixCache, func() (Router, error) { return router, err })
+	registerCacheRouter(RouterPrefixCache, newPrefixCacheRouterWithCache)
 }
 
 const (
 	defaultPrefixCacheMatchThresholdPercent = 50
+	defaultPrefixCachePodQueueThreshold     = 8
 )
 
 var (
 	prefixCacheMatchThresholdPercent = getPrefixCacheMatchThresholdPercent()
+	prefixCachePodQueueThreshold     = getPrefixCachePodQueueThreshold()
 )
 
 func getPrefixCacheMatchThresholdPercent() int {
@@ -60,25 +63,72 @@ func getPrefixCacheMatchThresholdPercent() int {
 	return defaultPrefixCacheMatchThresholdPercent
 }
 
+// getPrefixCachePodQueueThreshold returns the number of waiting requests from which a pod is overloaded, and the
+// requests matching its cached prefixes are routed to the least loaded pod instead.
+func getPrefixCachePodQueueThreshold() int {
+	value := utils.LoadEnv("AIBRIX_PREFIX_CACHE_POD_QUEUE_THRESHOLD", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_PREFIX_CACHE_POD_QUEUE_THRESHOLD: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_PREFIX_CACHE_POD_QUEUE_THRESHOLD env value for prefix cache pod queue threshold: %d", intValue)
+			return intValue
+		}
+	}
+	klog.Infof("using default prefix cache pod queue threshold: %d", defaultPrefixCachePodQueueThreshold)
+	return defaultPrefixCachePodQueueThreshold
+}
+
+// prefixCacheRouter routes the requests sharing a prompt prefix to the pods which served the prefix, so that the
+// engines reuse its KV cache. The pods which are overloaded or gone are skipped, and the requests without a
+// matching prefix are routed to the least loaded pod, which then serves their prefix.
 type prefixCacheRouter struct {
 	prefixCacheIndexer prefixcacheindexer.PrefixCacheIndexer
+	// cache reads the load of the pods, the gateway cache if nil.
+	cache podMetricCache
+	rand  func(int) int
 }
 
+// NewPrefixCacheRouter returns the prefix-cache router reading the load of the pods from the cache passed to Init.
+//
+// Deprecated: the registry builds the router with the cache of the gateway.
 func NewPrefixCacheRouter() (Router, error) {
 	return prefixCacheRouter{
 		prefixCacheIndexer: prefixcacheindexer.NewPrefixHashTable(),
+		rand:               rand.Intn,
 	}, nil
 }
 
+func newPrefixCacheRouterWithCache(c *cache.Cache) (Router, error) {
+	return prefixCacheRouter{
+		prefixCacheIndexer: prefixcacheindexer.NewPrefixHashTable(),
+		cache:              c,
+		rand:               rand.Intn,
+	}, nil
+}
+
+// podMetrics returns the cache to read the load of the pods from, nil until the gateway cache is initialized.
+func (p prefixCacheRouter) podMetrics() podMetricCache {
+	if p.cache != nil {
+		return p.cache
+	}
+	if c := routers.getCache(); c != nil {
+		return c
+	}
+	return nil
+}
+
 func (p prefixCacheRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
-	readyPods := utils.FilterReadyPods(pods)
-	if len(readyPods) == 0 {
+	if len(pods) == 0 {
 		return "", fmt.Errorf("no pods to forward request")
 	}
+	readyPods := filterRoutablePods(pods)
+	if len(readyPods) == 0 {
+		return "", ErrNoReadyPods
+	}
 	if len(readyPods) == 1 {
-		for _, pod := range pods {
-			return getPodAddress(pod.Status.PodIP)
-		}
+		return getPodAddress(readyPods[0])
 	}
 
 	tokens, err := utils.TokenizeInputText(message)
@@ -86,13 +136,19 @@ func (p prefixCacheRouter) Route(ctx context.Context, pods map[string]*v1.Pod, m
 		return "", err
 	}
 
+	podMetrics := p.podMetrics()
 	var targetPod *v1.Pod
+	// the indexer only matches the ready pods, the prefixes of the deleted pods are never routed to.
 	matchedTokens, unMatchedTokens, matchedPods := p.prefixCacheIndexer.MatchPrefix(tokens, model, readyPods)
-	if len(matchedTokens)*100/len(tokens) > prefixCacheMatchThresholdPercent {
-		targetPod = matchedPods[rand.Intn(len(matchedPods))]
-	} else {
-		// TODO: add better load balanced algorithms as fallback
-		targetPod = readyPods[rand.Intn(len(readyPods))]
+	if len(tokens) > 0 && len(matchedTokens)*100/len(tokens) > prefixCacheMatchThresholdPercent {
+		if availablePods := filterOverloadedPods(podMetrics, matchedPods, model); len(availablePods) > 0 {
+			targetPod = p.selectLeastLoadedPod(podMetrics, availablePods, model)
+		}
+	}
+	if targetPod == nil {
+		targetPod = p.selectLeastLoadedPod(podMetrics, readyPods, model)
+		// the target pod does not cache the matched prefix yet.
+		unMatchedTokens = tokens
 	}
 	if len(unMatchedTokens) > 0 {
 		p.prefixCacheIndexer.AddPrefix(unMatchedTokens, model, targetPod.Name)
@@ -112,5 +168,37 @@ func (p prefixCacheRouter) Route(ctx context.Context, pods map[string]*v1.Pod, m
 		"ready_pods", readyPodNames,
 		"target_pod", targetPod.Status.PodIP)
 
-	return getPodAddress(targetPod.Status.PodIP)
+	return getPodAddress(targetPod)
+}
+
+// selectLeastLoadedPod returns the pod with the least outstanding requests, a random pod without request metrics.
+func (p prefixCacheRouter) selectLeastLoadedPod(podMetrics podMetricCache, pods []*v1.Pod, model string) *v1.Pod {
+	randomFn := p.rand
+	if randomFn == nil {
+		randomFn = rand.Intn
+	}
+	if podMetrics != nil {
+		if pod := (leastRequestRouter{cache: podMetrics, rand: randomFn}).selectPod(pods, model); pod != nil {
+			return pod
+		}
+	}
+	return pods[randomFn(len(pods))]
+}
+
+// filterOverloadedPods drops the pods with prefixCachePodQueueThreshold or more waiting requests, the pods without
+// the metric are kept.
+func filterOverloadedPods(podMetrics podMetricCache, pods []*v1.Pod, model string) []*v1.Pod {
+	if podMetrics == nil {
+		return pods
+	}
+	var availablePods []*v1.Pod
+	for _, pod := range pods {
+		waitingReq, err := podMetrics.GetPodSnapshot(pod.Name).PodModelMetric(model, metrics.NumRequestsWaiting)
+		if err == nil && waitingReq.GetSimpleValue() >= float64(prefixCachePodQueueThreshold) {
+			klog.V(4).InfoS("skipping overloaded pod caching the prefix", "pod", pod.Name, "model", model, "waitingRequests", waitingReq.GetSimpleValue())
+			continue
+		}
+		availablePods = append(availablePods, pod)
+	}
+	return availablePods
 }
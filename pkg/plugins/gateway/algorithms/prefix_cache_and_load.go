@@ -35,8 +35,7 @@ var (
 )
 
 func init() {
-	router, err := NewPrefixCacheAndLoadRouter()
-	Register(RouterPrefixCacheAndLoad, func() (Router, error) { return router, err })
+	RegisterRouter(string(RouterPrefixCacheAndLoad), NewPrefixCacheAndLoadRouter)
 }
 
 const (
@@ -449,13 +448,16 @@ func (p *prefixCacheAndLoadRouter) updatePodSet(readyPods []*v1.Pod) {
 }
 
 func (p *prefixCacheAndLoadRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
-	readyPods := utils.FilterReadyPods(pods)
-	if len(readyPods) == 0 {
+	if len(pods) == 0 {
 		return "", fmt.Errorf("no pods to forward request")
 	}
+	readyPods := filterRoutablePods(pods)
+	if len(readyPods) == 0 {
+		return "", ErrNoReadyPods
+	}
 	if len(readyPods) == 1 {
 		for _, pod := range readyPods {
-			return getPodAddress(pod.Status.PodIP)
+			return getPodAddress(pod)
 		}
 	}
 
@@ -582,7 +584,7 @@ func (p *prefixCacheAndLoadRouter) Route(ctx context.Context, pods map[string]*v
 
 	klog.InfoS("target_pod_name", targetPod.Name, "target_pod_ip", targetPod.Status.PodIP)
 	p.cache.PrettyPrint()
-	return getPodAddress(targetPod.Status.PodIP)
+	return getPodAddress(targetPod)
 }
 
 // Compute the load in a pod fo a specific model based on the sliding window histogram
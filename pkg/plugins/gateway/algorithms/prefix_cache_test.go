@@ -21,6 +21,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/prefixcacheindexer"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -32,6 +34,7 @@ func Test_PrefixCache(t *testing.T) {
 		"p1": {
 			ObjectMeta: metav1.ObjectMeta{Name: "p1"},
 			Status: v1.PodStatus{
+				Phase: v1.PodRunning,
 				PodIP: "1.1.1.1",
 				Conditions: []v1.PodCondition{
 					{
@@ -43,6 +46,7 @@ func Test_PrefixCache(t *testing.T) {
 		"p2": {
 			ObjectMeta: metav1.ObjectMeta{Name: "p2"},
 			Status: v1.PodStatus{
+				Phase: v1.PodRunning,
 				PodIP: "2.2.2.2",
 				Conditions: []v1.PodCondition{
 					{
@@ -61,3 +65,45 @@ func Test_PrefixCache(t *testing.T) {
 
 	assert.Equal(t, targetPod, targetPod2)
 }
+
+func TestPrefixCacheRouteLoadAware(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1": newReadyPod("p1", "10.0.0.1"),
+		"p2": newReadyPod("p2", "10.0.0.2"),
+		"p3": newReadyPod("p3", "10.0.0.3"),
+	}
+	metricCache := fakePodMetricCache{
+		"p1": {metrics.NumRequestsRunning: 0, metrics.NumRequestsWaiting: 0},
+		"p2": {metrics.NumRequestsRunning: 5, metrics.NumRequestsWaiting: 0},
+		"p3": {metrics.NumRequestsRunning: 7, metrics.NumRequestsWaiting: 0},
+	}
+	router := prefixCacheRouter{
+		prefixCacheIndexer: prefixcacheindexer.NewPrefixHashTable(),
+		cache:              metricCache,
+		rand:               func(n int) int { return 0 },
+	}
+	message := `[{"role": "system", "content": "You are a helpful assistant answering questions about the aibrix gateway."}]`
+	route := func(pods map[string]*v1.Pod) string {
+		target, err := router.Route(context.Background(), pods, "m1", message)
+		assert.NoError(t, err)
+		return target
+	}
+
+	// a new prefix goes to the least loaded pod.
+	assert.Equal(t, "10.0.0.1:8000", route(pods))
+	// the pod caching the prefix keeps it while it is not overloaded.
+	metricCache["p1"][metrics.NumRequestsRunning] = 9
+	assert.Equal(t, "10.0.0.1:8000", route(pods))
+	// once overloaded, the least loaded pod serves the prefix as well.
+	metricCache["p1"][metrics.NumRequestsWaiting] = float64(prefixCachePodQueueThreshold)
+	assert.Equal(t, "10.0.0.2:8000", route(pods))
+	metricCache["p2"][metrics.NumRequestsRunning] = 20
+	assert.Equal(t, "10.0.0.2:8000", route(pods))
+
+	// the prefixes of the deleted pods are not routed to.
+	delete(pods, "p2")
+	metricCache["p1"][metrics.NumRequestsWaiting] = 0
+	assert.Equal(t, "10.0.0.1:8000", route(pods))
+	delete(pods, "p1")
+	assert.Equal(t, "10.0.0.3:8000", route(pods))
+}
@@ -29,8 +29,7 @@ var (
 )
 
 func init() {
-	router, err := NewRandomRouter()
-	Register(RouterRandom, func() (Router, error) { return router, err })
+	RegisterRouter(string(RouterRandom), NewRandomRouter)
 }
 
 type randomRouter struct {
@@ -41,22 +40,22 @@ func NewRandomRouter() (Router, error) {
 }
 
 func (r randomRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
-	var targetPodIP string
+	var targetPod *v1.Pod
 	if len(pods) == 0 {
 		return "", fmt.Errorf("no pods to forward request")
 	}
 
 	var err error
-	targetPodIP, err = selectRandomPod(pods, rand.Intn)
+	targetPod, err = selectRandomPod(pods, rand.Intn)
 	if err != nil {
 		return "", err
 	}
 
-	if targetPodIP == "" {
+	if targetPod == nil {
 		return "", fmt.Errorf("no pods to forward request")
 	}
 
-	return targetPodIP + ":" + podMetricPort, nil
+	return getPodAddress(targetPod)
 }
 
 func (r *randomRouter) SubscribedMetrics() []string {
@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+var (
+	RouterRoundRobin Algorithms = "round-robin"
+)
+
+func init() {
+	RegisterRouter(string(RouterRoundRobin), NewRoundRobinRouter)
+}
+
+// roundRobinRouter routes the requests of a model to its routable pods in turn, in the order of the pod names. The
+// turn of a model is a counter taken modulo the current number of pods, so a pod joining or leaving the model only
+// shifts the turn, and every pod still gets one request out of each round. It reads no metrics, which makes it a
+// baseline for the benchmarks.
+type roundRobinRouter struct {
+	// turns holds the *atomic.Uint64 counter of each model.
+	turns sync.Map
+}
+
+func NewRoundRobinRouter() (Router, error) {
+	return &roundRobinRouter{}, nil
+}
+
+func (r *roundRobinRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no pods to forward request")
+	}
+	routablePods := filterRoutablePods(pods)
+	if len(routablePods) == 0 {
+		return "", ErrNoReadyPods
+	}
+	turn := r.nextTurn(model)
+	return getPodAddress(routablePods[turn%uint64(len(routablePods))])
+}
+
+// nextTurn returns the turn of the request of the model and advances it.
+func (r *roundRobinRouter) nextTurn(model string) uint64 {
+	counter, ok := r.turns.Load(model)
+	if !ok {
+		counter, _ = r.turns.LoadOrStore(model, &atomic.Uint64{})
+	}
+	return counter.(*atomic.Uint64).Add(1) - 1
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// routeRoundRobin routes n requests of the model and counts the requests of each address.
+func routeRoundRobin(t *testing.T, r Router, pods map[string]*v1.Pod, model string, n int) map[string]int {
+	t.Helper()
+	counts := map[string]int{}
+	for i := 0; i < n; i++ {
+		address, err := r.Route(context.TODO(), pods, model, "")
+		assert.NoError(t, err)
+		counts[address]++
+	}
+	return counts
+}
+
+func TestRoundRobinRoute(t *testing.T) {
+	r, err := NewRoundRobinRouter()
+	assert.NoError(t, err)
+
+	terminating := newReadyPod("p4", "10.0.0.4")
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	pods := map[string]*v1.Pod{
+		"p1": newReadyPod("p1", "10.0.0.1"),
+		"p2": newReadyPod("p2", "10.0.0.2"),
+		"p3": newReadyPod("p3", ""),
+		"p4": terminating,
+	}
+
+	// the pods take turns in the order of their names, the pods without an IP and the terminating ones are skipped.
+	for _, expected := range []string{"10.0.0.1:8000", "10.0.0.2:8000", "10.0.0.1:8000", "10.0.0.2:8000"} {
+		address, err := r.Route(context.TODO(), pods, "m1", "")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, address)
+	}
+
+	// the turns are kept per model.
+	address, err := r.Route(context.TODO(), pods, "m2", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:8000", address)
+
+	// a pod joining the model gets its share of every round.
+	pods["p5"] = newReadyPod("p5", "10.0.0.5")
+	assert.Equal(t, map[string]int{"10.0.0.1:8000": 10, "10.0.0.2:8000": 10, "10.0.0.5:8000": 10},
+		routeRoundRobin(t, r, pods, "m1", 30))
+
+	// a pod leaving the model neither fails the routing nor skews it.
+	delete(pods, "p1")
+	assert.Equal(t, map[string]int{"10.0.0.2:8000": 10, "10.0.0.5:8000": 10}, routeRoundRobin(t, r, pods, "m1", 20))
+
+	_, err = r.Route(context.TODO(), map[string]*v1.Pod{"p3": newReadyPod("p3", "")}, "m1", "")
+	assert.Error(t, err)
+}
+
+func TestRoundRobinRouteConcurrently(t *testing.T) {
+	r, err := NewRoundRobinRouter()
+	assert.NoError(t, err)
+	pods := map[string]*v1.Pod{
+		"p1": newReadyPod("p1", "10.0.0.1"),
+		"p2": newReadyPod("p2", "10.0.0.2"),
+		"p3": newReadyPod("p3", "10.0.0.3"),
+	}
+
+	var mu sync.Mutex
+	counts := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			address, err := r.Route(context.TODO(), pods, "m1", "")
+			assert.NoError(t, err)
+			mu.Lock()
+			counts[address]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]int{"10.0.0.1:8000": 10, "10.0.0.2:8000": 10, "10.0.0.3:8000": 10}, counts)
+}
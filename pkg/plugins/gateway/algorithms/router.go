@@ -18,8 +18,17 @@ package routingalgorithms
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
 )
 
 type Algorithms string
@@ -30,26 +39,272 @@ type Router interface {
 	Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error)
 }
 
+// RankingRouter is implemented by the routers ranking the pods, so that a request which can not reach its target
+// pod fails over to the next best one.
+type RankingRouter interface {
+	Router
+	// RouteN returns up to n target pods, the best first.
+	RouteN(ctx context.Context, pods map[string]*v1.Pod, model, message string, n int) ([]string, error)
+}
+
+// RouteN returns up to n target pods of the router, the best first. The target pods of a router which does not
+// rank the pods are routed one at a time, each one without the pods routed before it.
+func RouteN(ctx context.Context, router Router, pods map[string]*v1.Pod, model, message string, n int) ([]string, error) {
+	if ranking, ok := router.(RankingRouter); ok {
+		return ranking.RouteN(ctx, pods, model, message, n)
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no pods to forward request")
+	}
+	remaining := make(map[string]*v1.Pod, len(pods))
+	for name, pod := range pods {
+		remaining[name] = pod
+	}
+	var targets []string
+	for len(targets) < n && len(remaining) > 0 {
+		target, err := router.Route(ctx, remaining, model, message)
+		if err != nil {
+			if len(targets) > 0 {
+				break
+			}
+			return nil, err
+		}
+		targets = append(targets, target)
+		// a target which is none of the pods, e.g. a peer cluster, is the last one.
+		name, ok := podOfTarget(remaining, target)
+		if !ok {
+			break
+		}
+		delete(remaining, name)
+	}
+	return targets, nil
+}
+
+// podOfTarget returns the name of the pod of the target address.
+func podOfTarget(pods map[string]*v1.Pod, target string) (string, bool) {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		host = target
+	}
+	for name, pod := range pods {
+		if pod.Status.PodIP == host {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// routerConstructor builds the router of a routing strategy with the cache of the gateway.
+type routerConstructor func(c *cache.Cache) (Router, error)
+
+// routerRetryInterval is the interval the construction of a router which failed, e.g. because the cache was not
+// initialized yet, is retried at. The strategy fails its requests in between.
+const routerRetryInterval = time.Second
+
+// routerFailure is the last failed construction of the router of a strategy.
+type routerFailure struct {
+	err error
+	at  time.Time
+}
+
+// registry maps the routing strategies to the constructors of their routers, and to the routers once built. The
+// routers are built by Init, or on their first selection for the strategies registered after it and those whose
+// construction failed, e.g. because the cache was not initialized yet.
+type registry struct {
+	mu              sync.RWMutex
+	cache           *cache.Cache
+	defaultStrategy Algorithms
+	constructors    map[Algorithms]routerConstructor
+	routers         map[Algorithms]Router
+	failures        map[Algorithms]routerFailure
+	now             func() time.Time
+}
+
+var routers = &registry{
+	constructors: map[Algorithms]routerConstructor{},
+	routers:      map[Algorithms]Router{},
+}
+
+// UnknownStrategyError reports a routing strategy no router is registered for.
+type UnknownStrategyError struct {
+	Strategy Algorithms
+}
+
+func (e *UnknownStrategyError) Error() string {
+	return fmt.Sprintf("unknown routing strategy %q, the strategies are %s", e.Strategy, strings.Join(Strategies(), ", "))
+}
+
+// RegisterRouter registers the constructor of the router of a routing strategy, e.g. of an out of tree algorithm,
+// under name. The routers reading the cache are registered with the cache of the gateway instead, see
+// registerCacheRouter. Registering a name twice panics.
+func RegisterRouter(name string, ctor func() (Router, error)) {
+	routers.register(Algorithms(name), func(*cache.Cache) (Router, error) { return ctor() })
+}
+
+// registerCacheRouter registers the constructor of a router reading the cache, it is given the cache passed to Init.
+func registerCacheRouter(name Algorithms, ctor func(c *cache.Cache) (Router, error)) {
+	routers.register(name, func(c *cache.Cache) (Router, error) {
+		if c == nil {
+			return nil, fmt.Errorf("routing strategy %s requires the cache, the cache is not initialized", name)
+		}
+		return ctor(c)
+	})
+}
+
+// getCache returns the cache passed to Init, or else the global cache once it is initialized.
+func (r *registry) getCache() *cache.Cache {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cacheOrGlobal()
+}
+
+// cacheOrGlobal returns the cache passed to Init, or else the global cache once it is initialized, nil otherwise.
+// The global cache keeps the routers used without Init working during their deprecation period, pass the cache to
+// Init instead. The caller holds the lock.
+func (r *registry) cacheOrGlobal() *cache.Cache {
+	if r.cache != nil {
+		return r.cache
+	}
+	c, err := cache.GetCache()
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+func (r *registry) register(name Algorithms, ctor routerConstructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.constructors[name]; ok {
+		panic(fmt.Sprintf("routing strategy %s is registered twice", name))
+	}
+	r.constructors[name] = ctor
+}
+
+// Init builds the routers of all the registered strategies with the cache of the gateway, and validates the
+// default strategy of the requests without a routing-strategy header, so that a misconfigured gateway fails to start
+// rather than on its requests. An empty default leaves the routing to envoy. The routers which fail to build, e.g.
+// while the cache is nil because it has not synced yet, are retried on their selection, see Ready.
+func Init(c *cache.Cache, defaultStrategy Algorithms) error {
+	if defaultStrategy != "" && !Validate(defaultStrategy) {
+		return fmt.Errorf("invalid default routing strategy: %w", &UnknownStrategyError{Strategy: defaultStrategy})
+	}
+	routers.init(c, defaultStrategy)
+	return nil
+}
+
+func (r *registry) init(c *cache.Cache, defaultStrategy Algorithms) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = c
+	r.defaultStrategy = defaultStrategy
+	for name := range r.constructors {
+		if _, err := r.build(name); err != nil {
+			klog.InfoS("deferring the construction of the router", "strategy", name, "err", err)
+		}
+	}
+}
+
+// SetCache passes the cache to the routers once it is initialized after Init, the routers which failed to build
+// without it are built on their next selection.
+func SetCache(c *cache.Cache) {
+	routers.setCache(c)
+}
+
+func (r *registry) setCache(c *cache.Cache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = c
+	r.failures = nil
+}
+
+// Ready returns whether the router of the default strategy is built, building it if needed, so that the gateway
+// is only ready once it can route its requests.
+func Ready() error {
+	strategy := DefaultStrategy()
+	if strategy == "" {
+		return nil
+	}
+	_, err := routers.get(strategy)
+	return err
+}
+
+// DefaultStrategy returns the routing strategy of the requests without a routing-strategy header, empty if they are
+// routed by envoy.
+func DefaultStrategy() Algorithms {
+	routers.mu.RLock()
+	defer routers.mu.RUnlock()
+	return routers.defaultStrategy
+}
+
 // Validate validates if user provided routing routers is supported by gateway
 func Validate(algorithms Algorithms) bool {
-	_, ok := routerStores[algorithms]
+	routers.mu.RLock()
+	defer routers.mu.RUnlock()
+	_, ok := routers.constructors[algorithms]
 	return ok
 }
 
-// Select the user provided routing routers is supported by gateway
-func Select(algorithms Algorithms) routerFunc {
-	if !Validate(algorithms) {
-		algorithms = RouterRandom
+// Strategies returns the sorted names of the registered routing strategies.
+func Strategies() []string {
+	routers.mu.RLock()
+	defer routers.mu.RUnlock()
+	names := make([]string, 0, len(routers.constructors))
+	for name := range routers.constructors {
+		names = append(names, string(name))
 	}
-	return routerRegistry[algorithms]
+	sort.Strings(names)
+	return names
+}
+
+// Select returns the router of the routing strategy, an UnknownStrategyError if none is registered.
+func Select(algorithms Algorithms) (Router, error) {
+	return routers.get(algorithms)
 }
 
-func Register(algorithms Algorithms, router routerFunc) {
-	routerRegistry[algorithms] = router
-	routerStores[algorithms] = struct{}{}
+func (r *registry) get(name Algorithms) (Router, error) {
+	r.mu.RLock()
+	router, ok := r.routers[name]
+	r.mu.RUnlock()
+	if ok {
+		return router, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if router, ok := r.routers[name]; ok {
+		return router, nil
+	}
+	if _, ok := r.constructors[name]; !ok {
+		return nil, &UnknownStrategyError{Strategy: name}
+	}
+	// the requests do not rebuild a router which just failed to build.
+	if failure, ok := r.failures[name]; ok && r.clock().Sub(failure.at) < routerRetryInterval {
+		return nil, failure.err
+	}
+	return r.build(name)
 }
 
-var routerRegistry = map[Algorithms]routerFunc{}
-var routerStores = map[Algorithms]any{}
+// build builds the router of the registered strategy with the cache, and records its failure to be retried after
+// routerRetryInterval. The caller holds the lock.
+func (r *registry) build(name Algorithms) (Router, error) {
+	router, err := r.constructors[name](r.cacheOrGlobal())
+	if err != nil {
+		err = fmt.Errorf("failed to build the router of routing strategy %s: %w", name, err)
+		if r.failures == nil {
+			r.failures = map[Algorithms]routerFailure{}
+		}
+		r.failures[name] = routerFailure{err: err, at: r.clock()}
+		return nil, err
+	}
+	delete(r.failures, name)
+	r.routers[name] = router
+	return router, nil
+}
 
-type routerFunc func() (Router, error)
+func (r *registry) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
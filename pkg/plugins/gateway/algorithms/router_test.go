@@ -18,6 +18,7 @@ package routingalgorithms
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"testing"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/vllm-project/aibrix/pkg/cache"
 	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -92,7 +94,7 @@ func TestWithNoIPPods(t *testing.T) {
 func TestWithIPPods(t *testing.T) {
 	// two case:
 	// case 1: pod ready
-	// case 2: pod ready & terminating -> we can send request at this moment.
+	// case 2: pod ready & terminating -> no request is routed to it.
 	c := cache.Cache{
 		Pods: map[string]*v1.Pod{
 			"p1": {
@@ -100,6 +102,7 @@ func TestWithIPPods(t *testing.T) {
 					Name: "p1",
 				},
 				Status: v1.PodStatus{
+					Phase: v1.PodRunning,
 					PodIP: "0.0.0.0",
 					Conditions: []v1.PodCondition{
 						{
@@ -115,6 +118,7 @@ func TestWithIPPods(t *testing.T) {
 					DeletionTimestamp: &metav1.Time{Time: time.Now()},
 				},
 				Status: v1.PodStatus{
+					Phase: v1.PodRunning,
 					PodIP: "1.0.0.0",
 					Conditions: []v1.PodCondition{
 						{
@@ -146,22 +150,22 @@ func TestWithIPPods(t *testing.T) {
 
 	r1 := randomRouter{}
 	targetPodIP, err := r1.Route(context.TODO(), c.Pods, model, "")
-	assert.NotEmpty(t, targetPodIP, "targetPodIP is not empty")
 	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:8000", targetPodIP)
 
 	r2 := leastRequestRouter{
 		cache: &c,
 	}
 	targetPodIP, err = r2.Route(context.TODO(), c.Pods, model, "")
-	assert.NotEmpty(t, targetPodIP, "targetPodIP is not empty")
 	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:8000", targetPodIP)
 
 	r3 := throughputRouter{
 		cache: &c,
 	}
 	targetPodIP, err = r3.Route(context.TODO(), c.Pods, model, "")
-	assert.NotEmpty(t, targetPodIP, "targetPodIP is not empty")
 	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:8000", targetPodIP)
 }
 
 // TestSelectRandomPod tests the selectRandomPod function.
@@ -176,6 +180,7 @@ func TestSelectRandomPod(t *testing.T) {
 			pods: map[string]*v1.Pod{
 				"pod1": {
 					Status: v1.PodStatus{
+						Phase: v1.PodRunning,
 						PodIP: "10.0.0.1",
 						Conditions: []v1.PodCondition{
 							{
@@ -193,6 +198,7 @@ func TestSelectRandomPod(t *testing.T) {
 			pods: map[string]*v1.Pod{
 				"pod1": {
 					Status: v1.PodStatus{
+						Phase: v1.PodRunning,
 						PodIP: "10.0.0.1",
 						Conditions: []v1.PodCondition{
 							{
@@ -204,6 +210,7 @@ func TestSelectRandomPod(t *testing.T) {
 				},
 				"pod2": {
 					Status: v1.PodStatus{
+						Phase: v1.PodRunning,
 						PodIP: "10.0.0.2",
 						Conditions: []v1.PodCondition{
 							{
@@ -215,6 +222,7 @@ func TestSelectRandomPod(t *testing.T) {
 				},
 				"pod3": {
 					Status: v1.PodStatus{
+						Phase: v1.PodRunning,
 						PodIP: "10.0.0.3",
 						Conditions: []v1.PodCondition{
 							{
@@ -248,7 +256,7 @@ func TestSelectRandomPod(t *testing.T) {
 			// Create a new random generator with a fixed seed for consistent test results
 			// Seed randomness for consistent results in tests
 			r := rand.New(rand.NewSource(42))
-			podIP, err := selectRandomPod(tt.pods, r.Intn)
+			pod, err := selectRandomPod(tt.pods, r.Intn)
 			if tt.expectErr {
 				if err == nil {
 					t.Errorf("expected an error but got none")
@@ -257,18 +265,194 @@ func TestSelectRandomPod(t *testing.T) {
 				if err != nil {
 					t.Errorf("unexpected error: %v", err)
 				}
-				// Verify that the returned pod IP exists in the input map
+				// Verify that the returned pod exists in the input map
 				found := false
-				for _, pod := range tt.pods {
-					if pod.Status.PodIP == podIP {
+				for _, p := range tt.pods {
+					if p == pod {
 						found = true
 						break
 					}
 				}
 				if !found {
-					t.Errorf("returned pod IP %v is not in the input pods", podIP)
+					t.Errorf("returned pod %v is not in the input pods", pod.Name)
 				}
 			}
 		})
 	}
 }
+
+func TestRouteWithPodPorts(t *testing.T) {
+	// the pods of two deployments serving on different container ports, and a pod with an invalid port label.
+	p1 := newReadyPod("p1", "10.0.0.1")
+	p1.Spec.Containers = []v1.Container{{Name: "vllm", Ports: []v1.ContainerPort{{Name: "serving", ContainerPort: 8080}}}}
+	p2 := newReadyPod("p2", "10.0.0.2")
+	p2.Annotations = map[string]string{utils.PodPortIdentifier: "9000"}
+	p3 := newReadyPod("p3", "10.0.0.3")
+	p3.Labels = map[string]string{utils.PodPortIdentifier: "serving"}
+	pods := map[string]*v1.Pod{"p1": p1, "p2": p2, "p3": p3}
+
+	chosen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		target, err := randomRouter{}.Route(context.TODO(), pods, "m1", "")
+		assert.NoError(t, err)
+		chosen[target] = true
+	}
+	assert.Equal(t, map[string]bool{"10.0.0.1:8080": true, "10.0.0.2:9000": true}, chosen)
+
+	_, err := randomRouter{}.Route(context.TODO(), map[string]*v1.Pod{"p3": p3}, "m1", "")
+	assert.Error(t, err)
+}
+
+func TestRouteN(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1": newReadyPod("p1", "10.0.0.1"),
+		"p2": newReadyPod("p2", "10.0.0.2"),
+		"p3": newReadyPod("p3", "10.0.0.3"),
+	}
+
+	// a router which does not rank the pods routes each target without the previous ones.
+	targets, err := RouteN(context.TODO(), randomRouter{}, pods, "m1", "", 5)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"10.0.0.1:8000", "10.0.0.2:8000", "10.0.0.3:8000"}, targets)
+	assert.Len(t, pods, 3)
+
+	targets, err = RouteN(context.TODO(), randomRouter{}, pods, "m1", "", 1)
+	assert.NoError(t, err)
+	assert.Len(t, targets, 1)
+
+	_, err = RouteN(context.TODO(), randomRouter{}, map[string]*v1.Pod{}, "m1", "", 2)
+	assert.Error(t, err)
+}
+
+func TestRouterRegistry(t *testing.T) {
+	assert.Contains(t, Strategies(), string(RouterRandom))
+	assert.Contains(t, Strategies(), string(RouterLeastRequest))
+
+	router, err := Select(RouterRandom)
+	assert.NoError(t, err)
+	assert.IsType(t, randomRouter{}, router)
+
+	_, err = Select("rrandom")
+	var unknown *UnknownStrategyError
+	assert.ErrorAs(t, err, &unknown)
+	assert.Equal(t, Algorithms("rrandom"), unknown.Strategy)
+
+	// the routers reading the cache fail to build without it, instead of panicking.
+	_, err = Select(RouterLeastRequest)
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { RegisterRouter(string(RouterRandom), NewRandomRouter) })
+}
+
+func TestRouterRegistryInit(t *testing.T) {
+	r := &registry{constructors: map[Algorithms]routerConstructor{}, routers: map[Algorithms]Router{}}
+	built := 0
+	r.register("counting", func(*cache.Cache) (Router, error) {
+		built++
+		return randomRouter{}, nil
+	})
+	r.init(&cache.Cache{}, "counting")
+	assert.Equal(t, 1, built)
+
+	// the routers built by init are reused.
+	_, err := r.get("counting")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, built)
+	assert.Equal(t, Algorithms("counting"), r.defaultStrategy)
+
+	err = Init(&cache.Cache{}, "rrandom")
+	var unknown *UnknownStrategyError
+	assert.ErrorAs(t, err, &unknown)
+}
+
+func TestRouterRegistryLateCache(t *testing.T) {
+	now := time.Now()
+	r := &registry{constructors: map[Algorithms]routerConstructor{}, routers: map[Algorithms]Router{}, now: func() time.Time { return now }}
+	built := 0
+	r.register("cached", func(c *cache.Cache) (Router, error) {
+		built++
+		if c == nil {
+			return nil, errors.New("cache is not initialized")
+		}
+		return leastRequestRouter{cache: c}, nil
+	})
+
+	// the router is not built without the cache, which does not fail the initialization.
+	r.init(nil, "cached")
+	_, err := r.get("cached")
+	assert.ErrorContains(t, err, "cache is not initialized")
+	assert.Equal(t, 1, built)
+
+	// the construction is retried once the retry interval elapsed.
+	now = now.Add(routerRetryInterval)
+	_, err = r.get("cached")
+	assert.Error(t, err)
+	assert.Equal(t, 2, built)
+
+	// the router is built on its next selection once the cache is passed.
+	r.setCache(&cache.Cache{})
+	router, err := r.get("cached")
+	assert.NoError(t, err)
+	assert.IsType(t, leastRequestRouter{}, router)
+	_, err = r.get("cached")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, built)
+}
+
+// newUnroutablePods returns a pod not ready, a terminating pod and a pending pod.
+func newUnroutablePods() map[string]*v1.Pod {
+	notReady := newReadyPod("not-ready", "10.0.0.1")
+	notReady.Status.Conditions[0].Status = v1.ConditionFalse
+	terminating := newReadyPod("terminating", "10.0.0.2")
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	pending := newReadyPod("pending", "10.0.0.3")
+	pending.Status.Phase = v1.PodPending
+	return map[string]*v1.Pod{"not-ready": notReady, "terminating": terminating, "pending": pending}
+}
+
+func TestIsPodRoutable(t *testing.T) {
+	assert.True(t, IsPodRoutable(newReadyPod("p1", "10.0.0.1")))
+	assert.False(t, IsPodRoutable(newReadyPod("no-ip", "")))
+	for name, pod := range newUnroutablePods() {
+		assert.False(t, IsPodRoutable(pod), name)
+	}
+
+	pods := newUnroutablePods()
+	pods["p1"] = newReadyPod("p1", "10.0.0.1")
+	routablePods := FilterRoutablePods(pods)
+	assert.Len(t, routablePods, 1)
+	assert.Contains(t, routablePods, "p1")
+}
+
+func TestRouteWithoutReadyPods(t *testing.T) {
+	c := &cache.Cache{}
+	roundRobin, err := NewRoundRobinRouter()
+	assert.NoError(t, err)
+	prefixCache, err := NewPrefixCacheRouter()
+	assert.NoError(t, err)
+	routers := map[string]Router{
+		"random":        randomRouter{},
+		"least-request": leastRequestRouter{cache: c},
+		"throughput":    throughputRouter{cache: c},
+		"round-robin":   roundRobin,
+		"prefix-cache":  prefixCache,
+	}
+
+	for name, r := range routers {
+		// the pods which are not ready, terminating or pending are never routed to.
+		pods := newUnroutablePods()
+		pods["p1"] = newReadyPod("p1", "10.0.0.4")
+		for i := 0; i < 10; i++ {
+			target, err := r.Route(context.TODO(), pods, "m1", "")
+			assert.NoError(t, err, name)
+			assert.Equal(t, "10.0.0.4:8000", target, name)
+		}
+
+		// the model has pods but none is ready, which differs from a model without pods.
+		_, err := r.Route(context.TODO(), newUnroutablePods(), "m1", "")
+		assert.ErrorIs(t, err, ErrNoReadyPods, name)
+		_, err = r.Route(context.TODO(), map[string]*v1.Pod{}, "m1", "")
+		assert.Error(t, err, name)
+		assert.NotErrorIs(t, err, ErrNoReadyPods, name)
+	}
+}
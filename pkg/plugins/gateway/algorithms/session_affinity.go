@@ -0,0 +1,257 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vllm-project/aibrix/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	RouterSessionAffinity Algorithms = "session-affinity"
+)
+
+func init() {
+	RegisterRouter(string(RouterSessionAffinity), NewSessionAffinityRouter)
+}
+
+const (
+	defaultSessionAffinityHeader = "x-session-id"
+	defaultSessionAffinityTTL    = 30 * time.Minute
+	// sessionAffinityKeyPrefix prefixes the Redis keys of the sessions, the value is the name of the pod.
+	sessionAffinityKeyPrefix = "session:"
+)
+
+var (
+	sessionAffinityHeader = getSessionAffinityHeader()
+	sessionAffinityTTL    = getSessionAffinityTTL()
+	sessionAffinityInner  = getSessionAffinityInnerRouter()
+)
+
+// getSessionAffinityHeader returns the request header carrying the session, the OpenAI user field of the request
+// identifies the session if the header is not set.
+func getSessionAffinityHeader() string {
+	value := utils.LoadEnv("AIBRIX_SESSION_AFFINITY_HEADER", "")
+	if value == "" {
+		klog.Infof("using default session affinity header: %s", defaultSessionAffinityHeader)
+		return defaultSessionAffinityHeader
+	}
+	klog.Infof("using AIBRIX_SESSION_AFFINITY_HEADER env value for session affinity header: %s", value)
+	return strings.ToLower(value)
+}
+
+// getSessionAffinityTTL returns how long a session keeps its pod after its last request.
+func getSessionAffinityTTL() time.Duration {
+	value := utils.LoadEnv("AIBRIX_SESSION_AFFINITY_TTL", "")
+	if value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			klog.Infof("invalid AIBRIX_SESSION_AFFINITY_TTL: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_SESSION_AFFINITY_TTL env value for session affinity ttl: %v", ttl)
+			return ttl
+		}
+	}
+	klog.Infof("using default session affinity ttl: %v", defaultSessionAffinityTTL)
+	return defaultSessionAffinityTTL
+}
+
+// getSessionAffinityInnerRouter returns the router assigning a pod to the new sessions.
+func getSessionAffinityInnerRouter() Algorithms {
+	value := Algorithms(utils.LoadEnv("AIBRIX_SESSION_AFFINITY_ROUTER", ""))
+	if value == "" || value == RouterSessionAffinity {
+		if value != "" {
+			klog.Infof("invalid AIBRIX_SESSION_AFFINITY_ROUTER: %s, falling back to default", value)
+		}
+		klog.Infof("using default session affinity router: %s", RouterLeastRequest)
+		return RouterLeastRequest
+	}
+	klog.Infof("using AIBRIX_SESSION_AFFINITY_ROUTER env value for session affinity router: %s", value)
+	return value
+}
+
+type sessionInfoKey struct{}
+
+// sessionInfo is what the gateway knows about the session of a request.
+type sessionInfo struct {
+	headers map[string]string
+	user    string
+}
+
+// WithSessionInfo returns a context carrying the lower-cased headers and the OpenAI user field of the request, the
+// session-affinity router reads the session of the request from them.
+func WithSessionInfo(ctx context.Context, headers map[string]string, user string) context.Context {
+	return context.WithValue(ctx, sessionInfoKey{}, sessionInfo{headers: headers, user: user})
+}
+
+// getSessionKey returns the session of the request, empty if the request does not belong to a session.
+func getSessionKey(ctx context.Context, header string) string {
+	info, _ := ctx.Value(sessionInfoKey{}).(sessionInfo)
+	if value := info.headers[header]; value != "" {
+		return value
+	}
+	return info.user
+}
+
+// sessionStore keeps the pods assigned to the sessions.
+type sessionStore interface {
+	// get returns the pod of the session, empty if the session has none.
+	get(ctx context.Context, session string) (string, error)
+	// set assigns the pod to the session for the ttl.
+	set(ctx context.Context, session, pod string, ttl time.Duration) error
+}
+
+type redisSessionStore struct {
+	client redis.UniversalClient
+}
+
+func (s redisSessionStore) get(ctx context.Context, session string) (string, error) {
+	pod, err := s.client.Get(ctx, sessionAffinityKeyPrefix+session).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return pod, err
+}
+
+func (s redisSessionStore) set(ctx context.Context, session, pod string, ttl time.Duration) error {
+	return s.client.Set(ctx, sessionAffinityKeyPrefix+session, pod, ttl).Err()
+}
+
+// unavailableSessionStore fails every session when Redis is misconfigured.
+type unavailableSessionStore struct {
+	err error
+}
+
+func (s unavailableSessionStore) get(ctx context.Context, session string) (string, error) {
+	return "", s.err
+}
+
+func (s unavailableSessionStore) set(ctx context.Context, session, pod string, ttl time.Duration) error {
+	return s.err
+}
+
+// sessionAffinityRouter routes the requests of a session to the pod serving its previous requests, so that the
+// KV cache of the conversation stays warm. The new sessions, and the sessions whose pod is gone, are assigned a pod
+// by the inner router. The requests are routed without affinity while Redis is unavailable.
+type sessionAffinityRouter struct {
+	header string
+	ttl    time.Duration
+	inner  Algorithms
+
+	storeOnce sync.Once
+	store     sessionStore
+}
+
+func NewSessionAffinityRouter() (Router, error) {
+	return &sessionAffinityRouter{
+		header: sessionAffinityHeader,
+		ttl:    sessionAffinityTTL,
+		inner:  sessionAffinityInner,
+	}, nil
+}
+
+// getStore creates the Redis client on the first request. The client connects lazily, so the requests are routed
+// without affinity until Redis answers.
+func (r *sessionAffinityRouter) getStore() sessionStore {
+	r.storeOnce.Do(func() {
+		if r.store != nil {
+			return
+		}
+		config, err := utils.LoadRedisConfig()
+		var client redis.UniversalClient
+		if err == nil {
+			client, err = utils.NewRedisClient(config)
+		}
+		if err != nil {
+			klog.ErrorS(err, "invalid redis configuration, routing without session affinity")
+			r.store = unavailableSessionStore{err: err}
+			return
+		}
+		r.store = redisSessionStore{client: client}
+	})
+	return r.store
+}
+
+func (r *sessionAffinityRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
+	session := getSessionKey(ctx, r.header)
+	if session == "" {
+		return r.route(ctx, pods, model, message)
+	}
+
+	store := r.getStore()
+	podName, err := store.get(ctx, session)
+	if err != nil {
+		klog.Warningf("failed to get the pod of session %s, routing without session affinity: %v", session, err)
+		return r.route(ctx, pods, model, message)
+	}
+	if pod, ok := pods[podName]; ok && IsPodRoutable(pod) {
+		if address, err := getPodAddress(pod); err == nil {
+			// the ttl is refreshed by every request of the session.
+			if err := store.set(ctx, session, podName, r.ttl); err != nil {
+				klog.Warningf("failed to refresh session %s: %v", session, err)
+			}
+			klog.V(4).InfoS("routing request to the pod of its session", "session", session, "pod", podName, "model", model)
+			return address, nil
+		}
+	}
+
+	target, err := r.route(ctx, pods, model, message)
+	if err != nil {
+		return "", err
+	}
+	if pod := getPodByAddress(pods, target); pod != nil {
+		if err := store.set(ctx, session, pod.Name, r.ttl); err != nil {
+			klog.Warningf("failed to assign pod %s to session %s: %v", pod.Name, session, err)
+		}
+	}
+	return target, nil
+}
+
+func (r *sessionAffinityRouter) route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
+	router, err := Select(r.inner)
+	if err != nil {
+		return "", err
+	}
+	return router.Route(ctx, pods, model, message)
+}
+
+// getPodByAddress returns the pod serving the address a router returned, nil if none does.
+func getPodByAddress(pods map[string]*v1.Pod, address string) *v1.Pod {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	for _, pod := range pods {
+		if pod.Status.PodIP == host {
+			return pod
+		}
+	}
+	return nil
+}
+
+func (r *sessionAffinityRouter) SubscribedMetrics() []string {
+	return []string{}
+}
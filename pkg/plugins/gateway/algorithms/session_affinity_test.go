@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+// fakeSessionStore keeps the sessions in memory, every call fails with err if set.
+type fakeSessionStore struct {
+	sessions map[string]string
+	ttls     map[string]time.Duration
+	err      error
+}
+
+func newFakeSessionStore() *fakeSessionStore {
+	return &fakeSessionStore{sessions: map[string]string{}, ttls: map[string]time.Duration{}}
+}
+
+func (s *fakeSessionStore) get(ctx context.Context, session string) (string, error) {
+	return s.sessions[session], s.err
+}
+
+func (s *fakeSessionStore) set(ctx context.Context, session, pod string, ttl time.Duration) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sessions[session] = pod
+	s.ttls[session] = ttl
+	return nil
+}
+
+func newTestSessionAffinityRouter(store sessionStore) *sessionAffinityRouter {
+	r := &sessionAffinityRouter{header: defaultSessionAffinityHeader, ttl: time.Minute, inner: RouterRandom, store: store}
+	r.storeOnce.Do(func() {})
+	return r
+}
+
+func TestSessionAffinityRoute(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1": newReadyPod("p1", "10.0.0.1"),
+		"p2": newReadyPod("p2", "10.0.0.2"),
+		"p3": newReadyPod("p3", "10.0.0.3"),
+	}
+	store := newFakeSessionStore()
+	r := newTestSessionAffinityRouter(store)
+
+	// the first request of the session is assigned a pod, the next ones stick to it.
+	ctx := WithSessionInfo(context.Background(), map[string]string{"x-session-id": "s1"}, "alice")
+	first, err := r.Route(ctx, pods, "m1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, first, pods[store.sessions["s1"]].Status.PodIP+":8000")
+	assert.Equal(t, time.Minute, store.ttls["s1"])
+	for i := 0; i < 10; i++ {
+		target, err := r.Route(ctx, pods, "m1", "")
+		assert.NoError(t, err)
+		assert.Equal(t, first, target)
+	}
+
+	// the user field identifies the session without the header.
+	ctx = WithSessionInfo(context.Background(), map[string]string{}, "bob")
+	_, err = r.Route(ctx, pods, "m1", "")
+	assert.NoError(t, err)
+	assert.Contains(t, pods, store.sessions["bob"])
+
+	// a session whose pod is gone is assigned a new one.
+	store.sessions["s2"] = "deleted"
+	ctx = WithSessionInfo(context.Background(), map[string]string{"x-session-id": "s2"}, "")
+	target, err := r.Route(ctx, pods, "m1", "")
+	assert.NoError(t, err)
+	if assert.Contains(t, pods, store.sessions["s2"]) {
+		assert.Equal(t, pods[store.sessions["s2"]].Status.PodIP+":8000", target)
+	}
+
+	// the requests without a session are not recorded.
+	sessions := len(store.sessions)
+	_, err = r.Route(context.Background(), pods, "m1", "")
+	assert.NoError(t, err)
+	assert.Len(t, store.sessions, sessions)
+}
+
+func TestSessionAffinityRouteWithoutRedis(t *testing.T) {
+	pods := map[string]*v1.Pod{"p1": newReadyPod("p1", "10.0.0.1")}
+	store := newFakeSessionStore()
+	store.err = errors.New("connection refused")
+	r := newTestSessionAffinityRouter(store)
+
+	ctx := WithSessionInfo(context.Background(), map[string]string{"x-session-id": "s1"}, "")
+	target, err := r.Route(ctx, pods, "m1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:8000", target)
+}
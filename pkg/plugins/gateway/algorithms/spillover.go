@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const defaultSpilloverMaxInFlightPerPod = 64
+
+var (
+	RouterSpillover Algorithms = "spillover"
+
+	spilloverMaxInFlightPerPod = getSpilloverMaxInFlightPerPod()
+)
+
+func init() {
+	registerCacheRouter(RouterSpillover, NewSpilloverRouter)
+}
+
+func getSpilloverMaxInFlightPerPod() float64 {
+	value := utils.LoadEnv("AIBRIX_SPILLOVER_MAX_INFLIGHT_PER_POD", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_SPILLOVER_MAX_INFLIGHT_PER_POD: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_SPILLOVER_MAX_INFLIGHT_PER_POD env value for spillover threshold: %d", intValue)
+			return float64(intValue)
+		}
+	}
+	klog.Infof("using default spillover threshold: %d", defaultSpilloverMaxInFlightPerPod)
+	return defaultSpilloverMaxInFlightPerPod
+}
+
+// SpilloverError signals that the request should be sent to a remote cluster instead of a local pod.
+type SpilloverError struct {
+	Cluster  string
+	Endpoint string
+}
+
+func (e *SpilloverError) Error() string {
+	return fmt.Sprintf("local capacity exhausted, spill over to cluster %s (%s)", e.Cluster, e.Endpoint)
+}
+
+// spilloverRouter routes to the least loaded local pod, unless the local capacity of the model is exhausted
+// and a federated peer reports headroom for it.
+type spilloverRouter struct {
+	cache *cache.Cache
+	local Router
+}
+
+func NewSpilloverRouter(c *cache.Cache) (Router, error) {
+	local, err := NewLeastRequestRouter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return spilloverRouter{
+		cache: c,
+		local: local,
+	}, nil
+}
+
+func (r spilloverRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
+	readyPods := filterRoutablePods(pods)
+	inFlight := float64(r.cache.GetPendingRequestCount(model))
+	if len(readyPods) > 0 && inFlight/float64(len(readyPods)) < spilloverMaxInFlightPerPod {
+		return r.local.Route(ctx, pods, model, message)
+	}
+
+	if remote, ok := selectSpilloverTarget(r.cache.GetRemoteModelLoads(model), spilloverMaxInFlightPerPod); ok {
+		klog.V(4).InfoS("spilling over request", "model", model, "localReadyPods", len(readyPods), "localInFlight", inFlight,
+			"cluster", remote.Cluster, "remoteReadyPods", remote.RoutablePods, "remoteInFlight", remote.InFlight)
+		return "", &SpilloverError{Cluster: remote.Cluster, Endpoint: remote.Endpoint}
+	}
+
+	// No remote headroom, keep the request local if possible.
+	return r.local.Route(ctx, pods, model, message)
+}
+
+// selectSpilloverTarget returns the remote with the lowest in-flight requests per pod below the threshold.
+func selectSpilloverTarget(remotes []cache.RemoteModelLoad, maxInFlightPerPod float64) (cache.RemoteModelLoad, bool) {
+	var target cache.RemoteModelLoad
+	found := false
+	minLoad := maxInFlightPerPod
+	for _, remote := range remotes {
+		if remote.RoutablePods == 0 || remote.Endpoint == "" {
+			continue
+		}
+		load := float64(remote.InFlight) / float64(remote.RoutablePods)
+		if load < minLoad {
+			minLoad = load
+			target = remote
+			found = true
+		}
+	}
+	return target, found
+}
+
+func (r *spilloverRouter) SubscribedMetrics() []string {
+	return []string{}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vllm-project/aibrix/pkg/cache"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectSpilloverTarget(t *testing.T) {
+	remotes := []cache.RemoteModelLoad{
+		{Cluster: "no-pods", Endpoint: "http://a", ModelLoadSummary: cache.ModelLoadSummary{RoutablePods: 0}},
+		{Cluster: "busy", Endpoint: "http://b", ModelLoadSummary: cache.ModelLoadSummary{RoutablePods: 2, InFlight: 20}},
+		{Cluster: "idle", Endpoint: "http://c", ModelLoadSummary: cache.ModelLoadSummary{RoutablePods: 2, InFlight: 2}},
+		{Cluster: "no-endpoint", ModelLoadSummary: cache.ModelLoadSummary{RoutablePods: 10}},
+	}
+
+	target, ok := selectSpilloverTarget(remotes, 16)
+	assert.True(t, ok)
+	assert.Equal(t, "idle", target.Cluster)
+
+	_, ok = selectSpilloverTarget(remotes[:2], 10)
+	assert.False(t, ok, "no remote has headroom")
+}
+
+func TestSpilloverRoute(t *testing.T) {
+	c := &cache.Cache{}
+	err := c.StoreRemoteLoadSummary("http://peer-admin", cache.LoadSummary{
+		Version:   cache.LoadSummaryVersion,
+		Cluster:   "remote",
+		Endpoint:  "http://remote-gateway",
+		Timestamp: time.Now(),
+		Models:    map[string]cache.ModelLoadSummary{"m1": {RoutablePods: 1}},
+	})
+	assert.NoError(t, err)
+
+	r := spilloverRouter{cache: c, local: randomRouter{}}
+
+	// no local capacity, remote has headroom.
+	_, err = r.Route(context.TODO(), map[string]*v1.Pod{}, "m1", "")
+	var spilloverErr *SpilloverError
+	assert.True(t, errors.As(err, &spilloverErr))
+	assert.Equal(t, "http://remote-gateway", spilloverErr.Endpoint)
+
+	// local capacity is preferred.
+	pods := map[string]*v1.Pod{
+		"p1": {
+			ObjectMeta: metav1.ObjectMeta{Name: "p1"},
+			Status: v1.PodStatus{
+				Phase:      v1.PodRunning,
+				PodIP:      "0.0.0.0",
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		},
+	}
+	targetPodIP, err := r.Route(context.TODO(), pods, "m1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:8000", targetPodIP)
+
+	// remote model is unknown, fall back to local routing.
+	_, err = r.Route(context.TODO(), map[string]*v1.Pod{}, "m2", "")
+	assert.False(t, errors.As(err, &spilloverErr))
+}
@@ -22,9 +22,9 @@ import (
 	"math"
 	"math/rand"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/vllm-project/aibrix/pkg/cache"
 	"github.com/vllm-project/aibrix/pkg/metrics"
-	"github.com/vllm-project/aibrix/pkg/utils"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 )
@@ -34,76 +34,93 @@ var (
 )
 
 func init() {
-	router, err := NewThroughputRouter()
-	Register(RouterThroughput, func() (Router, error) { return router, err })
+	registerCacheRouter(RouterThroughput, NewThroughputRouter)
 }
 
-type throughputRouter struct {
-	cache *cache.Cache
+// routingMetricsFallbacks counts the requests routed without metrics because none of the ready pods reported them
+// yet, e.g. right after the pods start, by router.
+var routingMetricsFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_gateway_routing_metrics_fallback_total",
+	Help: "Number of requests the gateway routed to a pod without metrics because no ready pod reported them, by router.",
+}, []string{"router"})
+
+func init() {
+	prometheus.MustRegister(routingMetricsFallbacks)
 }
 
-func NewThroughputRouter() (Router, error) {
-	c, err := cache.GetCache()
-	if err != nil {
-		return nil, err
-	}
+// throughputRouter routes the request to the ready pod with the lowest token throughput. The pods missing one of
+// the throughput metrics are only routed to if no pod reports both.
+type throughputRouter struct {
+	cache podMetricCache
+	rand  func(int) int
+}
 
+func NewThroughputRouter(c *cache.Cache) (Router, error) {
 	return throughputRouter{
 		cache: c,
+		rand:  rand.Intn,
 	}, nil
 }
 
 func (r throughputRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
-	var targetPodIP string
+	var targetPod *v1.Pod
 	minCount := math.MaxFloat64
 
 	if len(pods) == 0 {
 		return "", fmt.Errorf("no pods to forward request")
 	}
 
-	readyPods := utils.FilterReadyPods(pods)
+	readyPods := filterRoutablePods(pods)
 	if len(readyPods) == 0 {
-		return "", fmt.Errorf("no ready pods available for fallback")
+		return "", ErrNoReadyPods
 	}
 
+	var metricLessPods []*v1.Pod
 	for _, pod := range readyPods {
-		promptThroughput, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.AvgPromptThroughputToksPerS)
-		if err != nil {
-			klog.Error(err)
-			continue
-		}
-		generationThroughput, err := r.cache.GetPodModelMetric(pod.Name, model, metrics.AvgGenerationThroughputToksPerS)
+		totalThroughput, err := r.getThroughput(pod, model)
 		if err != nil {
-			klog.Error(err)
+			klog.V(4).InfoS("pod without throughput metrics", "pod", pod.Name, "model", model, "err", err)
+			metricLessPods = append(metricLessPods, pod)
 			continue
 		}
 
-		// processing prompt tokens is twice as expensive than generation tokens
-		totalThroughput := 2*promptThroughput.GetSimpleValue() + generationThroughput.GetSimpleValue()
-		klog.V(4).Infof("pod: %v, podIP: %v, promptThroughput: %v, generationThroughput: %v, totalThroughput: %v",
-			pod.Name, pod.Status.PodIP, promptThroughput, generationThroughput, totalThroughput)
-
 		if totalThroughput <= minCount {
 			minCount = totalThroughput
-			targetPodIP = pod.Status.PodIP
+			targetPod = pod
 		}
 	}
 
-	// Use fallback if no valid metrics
-	if targetPodIP == "" {
-		klog.Warning("No pods with valid metrics found; selecting a pod randomly as fallback")
-		var err error
-		targetPodIP, err = selectRandomPod(pods, rand.Intn)
-		if err != nil {
-			return "", err
+	// the metrics of the pods are not scraped yet, e.g. right after they start.
+	if targetPod == nil {
+		randomFn := r.rand
+		if randomFn == nil {
+			randomFn = rand.Intn
 		}
+		targetPod = metricLessPods[randomFn(len(metricLessPods))]
+		routingMetricsFallbacks.WithLabelValues(string(RouterThroughput)).Inc()
+		klog.V(4).InfoS("no pods with throughput metrics, selecting a pod without metrics randomly", "model", model, "pod", targetPod.Name)
 	}
 
-	if targetPodIP == "" {
-		return "", fmt.Errorf("no pods to forward request")
+	return getPodAddress(targetPod)
+}
+
+// getThroughput returns the weighted token throughput of the pod, an error if either throughput is missing.
+func (r throughputRouter) getThroughput(pod *v1.Pod, model string) (float64, error) {
+	snapshot := r.cache.GetPodSnapshot(pod.Name)
+	promptThroughput, err := snapshot.PodModelMetric(model, metrics.AvgPromptThroughputToksPerS)
+	if err != nil {
+		return 0, err
+	}
+	generationThroughput, err := snapshot.PodModelMetric(model, metrics.AvgGenerationThroughputToksPerS)
+	if err != nil {
+		return 0, err
 	}
 
-	return targetPodIP + ":" + podMetricPort, nil
+	// processing prompt tokens is twice as expensive than generation tokens
+	totalThroughput := 2*promptThroughput.GetSimpleValue() + generationThroughput.GetSimpleValue()
+	klog.V(4).Infof("pod: %v, podIP: %v, promptThroughput: %v, generationThroughput: %v, totalThroughput: %v",
+		pod.Name, pod.Status.PodIP, promptThroughput, generationThroughput, totalThroughput)
+	return totalThroughput, nil
 }
 
 func (r *throughputRouter) SubscribedMetrics() []string {
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routingalgorithms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestThroughputRoute(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"p1":    newReadyPod("p1", "10.0.0.1"),
+		"p2":    newReadyPod("p2", "10.0.0.2"),
+		"p3":    newReadyPod("p3", "10.0.0.3"),
+		"no-ip": newReadyPod("no-ip", ""),
+	}
+	testCases := []struct {
+		name     string
+		cache    fakePodMetricCache
+		expected []string
+		fallback bool
+	}{
+		{
+			name: "lowest throughput",
+			cache: fakePodMetricCache{
+				"p1": {metrics.AvgPromptThroughputToksPerS: 100, metrics.AvgGenerationThroughputToksPerS: 50},
+				"p2": {metrics.AvgPromptThroughputToksPerS: 10, metrics.AvgGenerationThroughputToksPerS: 200},
+				"p3": {metrics.AvgPromptThroughputToksPerS: 80, metrics.AvgGenerationThroughputToksPerS: 80},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			name: "pods with partial metrics are only routed to without complete metrics",
+			cache: fakePodMetricCache{
+				"p1": {metrics.AvgPromptThroughputToksPerS: 0},
+				"p2": {metrics.AvgPromptThroughputToksPerS: 100, metrics.AvgGenerationThroughputToksPerS: 100},
+				"p3": {metrics.AvgGenerationThroughputToksPerS: 0},
+			},
+			expected: []string{"10.0.0.2"},
+		},
+		{
+			name:     "ready pods without metrics",
+			cache:    fakePodMetricCache{"p1": {metrics.AvgPromptThroughputToksPerS: 0}},
+			expected: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			fallback: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fallbacks := testutil.ToFloat64(routingMetricsFallbacks.WithLabelValues(string(RouterThroughput)))
+			chosen := map[string]bool{}
+			for i := 0; i < 3; i++ {
+				choice := i
+				r := throughputRouter{cache: tc.cache, rand: func(n int) int { return choice % n }}
+				target, err := r.Route(context.TODO(), pods, "m1", "")
+				assert.NoError(t, err)
+				chosen[target] = true
+			}
+
+			expected := map[string]bool{}
+			for _, ip := range tc.expected {
+				expected[ip+":8000"] = true
+			}
+			assert.Equal(t, expected, chosen)
+			expectedFallbacks := fallbacks
+			if tc.fallback {
+				expectedFallbacks += 3
+			}
+			assert.Equal(t, expectedFallbacks, testutil.ToFloat64(routingMetricsFallbacks.WithLabelValues(string(RouterThroughput))))
+		})
+	}
+}
@@ -17,28 +17,100 @@ limitations under the License.
 package routingalgorithms
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
 
 	"github.com/vllm-project/aibrix/pkg/utils"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
 )
 
-const podMetricPort = "8000"
+// getPodPort returns the port of the model server of the pod, resolved by the cache once per pod update, or from
+// the pod spec if the cache doesn't track the pod.
+func getPodPort(pod *v1.Pod) (int32, error) {
+	if c := routers.getCache(); c != nil {
+		if port, ok := c.GetPodPort(pod.Name); ok {
+			return port, nil
+		}
+	}
+	return utils.GetPodPort(pod)
+}
 
-func getPodAddress(podIP string) (string, error) {
-	if podIP == "" {
+func getPodAddress(pod *v1.Pod) (string, error) {
+	if pod == nil || pod.Status.PodIP == "" {
 		return "", fmt.Errorf("no pods to forward request")
 	}
-	return fmt.Sprintf("%v:%v", podIP, podMetricPort), nil
+	port, err := getPodPort(pod)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port))), nil
+}
+
+// ErrNoReadyPods is returned by the routers when none of the pods of the model is routable, e.g. while all of them
+// start or drain during a rollout, so that the client is told to retry rather than failing the routing.
+var ErrNoReadyPods = errors.New("no ready pods available")
+
+// IsPodRoutable returns whether requests may be routed to the pod: it is running and ready, it is not terminating,
+// and it has an IP.
+func IsPodRoutable(pod *v1.Pod) bool {
+	return pod != nil && pod.Status.PodIP != "" && pod.Status.Phase == v1.PodRunning &&
+		!utils.IsPodTerminating(pod) && utils.IsPodReady(pod)
+}
+
+// FilterRoutablePods returns the routable pods of the map. The gateway filters the pods of a request once before
+// routing it, the routers still skip the pods which are not routable.
+func FilterRoutablePods(pods map[string]*v1.Pod) map[string]*v1.Pod {
+	routablePods := make(map[string]*v1.Pod, len(pods))
+	for name, pod := range pods {
+		if IsPodRoutable(pod) {
+			routablePods[name] = pod
+		}
+	}
+	return routablePods
+}
+
+// filterRoutablePods returns the routable pods with a resolvable port of the model server, sorted by name so that
+// the routing does not depend on the order of the pod map.
+func filterRoutablePods(pods map[string]*v1.Pod) []*v1.Pod {
+	var routablePods []*v1.Pod
+	for _, pod := range pods {
+		if !IsPodRoutable(pod) {
+			continue
+		}
+		if _, err := getPodPort(pod); err != nil {
+			klog.V(4).InfoS("skipping pod without a resolvable port", "pod", pod.Name, "err", err)
+			continue
+		}
+		routablePods = append(routablePods, pod)
+	}
+	sort.Slice(routablePods, func(i, j int) bool {
+		return routablePods[i].Name < routablePods[j].Name
+	})
+	return routablePods
+}
+
+// selectRandomPod selects a random pod from the provided pod map.
+// It returns an error if no routable pods are available.
+func selectRandomPod(pods map[string]*v1.Pod, randomFn func(int) int) (*v1.Pod, error) {
+	routablePods := filterRoutablePods(pods)
+	if len(routablePods) == 0 {
+		return nil, ErrNoReadyPods
+	}
+	return routablePods[randomFn(len(routablePods))], nil
 }
 
-// selectRandomPodWithRand selects a random pod from the provided pod map.
-// It returns an error if no ready pods are available.
-func selectRandomPod(pods map[string]*v1.Pod, randomFn func(int) int) (string, error) {
-	readyPods := utils.FilterReadyPods(pods)
-	if len(readyPods) == 0 {
-		return "", fmt.Errorf("no ready pods available for fallback")
+// selectFallbackPod selects a random routable pod for a metric-based router none of whose pods reported the metrics
+// it routes on, e.g. right after the pods start, and counts the fallback of the router.
+func selectFallbackPod(router Algorithms, pods map[string]*v1.Pod, randomFn func(int) int) (*v1.Pod, error) {
+	targetPod, err := selectRandomPod(pods, randomFn)
+	if err != nil {
+		return nil, err
 	}
-	randomPod := readyPods[randomFn(len(readyPods))]
-	return randomPod.Status.PodIP, nil
+	routingMetricsFallbacks.WithLabelValues(string(router)).Inc()
+	klog.V(4).InfoS("no pods with valid metrics, selecting a pod randomly as fallback", "router", router, "pod", targetPod.Name)
+	return targetPod, nil
 }
@@ -0,0 +1,337 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bodypatch applies patches to the raw JSON body of a request. Only the bytes of the patched values
+// change, the key order, the formatting and the fields unknown to the gateway are preserved verbatim.
+package bodypatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Op is the operation of a patch.
+type Op string
+
+const (
+	// OpSet sets the value at the path, the missing objects along the path are created.
+	OpSet Op = "set"
+	// OpReplace replaces the value at the path, which must exist.
+	OpReplace Op = "replace"
+	// OpDelete deletes the value at the path, if it exists.
+	OpDelete Op = "delete"
+)
+
+var (
+	ErrInvalidJSON  = errors.New("invalid JSON body")
+	ErrEmptyPath    = errors.New("empty path")
+	ErrNotFound     = errors.New("path not found")
+	ErrNotContainer = errors.New("path traverses a value which is neither an object nor an array")
+)
+
+// Patch is an operation on the value at a path. The elements of the path are object keys, or indexes within
+// arrays. When an object has duplicate keys, the last one is patched like it is the one decoded by the engines.
+type Patch struct {
+	Op    Op
+	Path  []string
+	Value interface{}
+}
+
+// Set returns a patch setting the value at the path.
+func Set(path []string, value interface{}) Patch {
+	return Patch{Op: OpSet, Path: path, Value: value}
+}
+
+// Replace returns a patch replacing the existing value at the path.
+func Replace(path []string, value interface{}) Patch {
+	return Patch{Op: OpReplace, Path: path, Value: value}
+}
+
+// Delete returns a patch deleting the value at the path.
+func Delete(path ...string) Patch {
+	return Patch{Op: OpDelete, Path: path}
+}
+
+// Apply applies the patches in order and returns the patched body, the body itself is not modified.
+func Apply(body []byte, patches ...Patch) ([]byte, error) {
+	if !json.Valid(body) {
+		return nil, ErrInvalidJSON
+	}
+	patched := body
+	for _, p := range patches {
+		var err error
+		if patched, err = apply(patched, p); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", p.Op, strings.Join(p.Path, "."), err)
+		}
+	}
+	return patched, nil
+}
+
+func apply(body []byte, p Patch) ([]byte, error) {
+	if len(p.Path) == 0 {
+		return nil, ErrEmptyPath
+	}
+	switch p.Op {
+	case OpSet, OpReplace, OpDelete:
+		return applyAt(body, rootValue(body), p.Path, p)
+	default:
+		return nil, fmt.Errorf("unknown operation %q", p.Op)
+	}
+}
+
+// span is the [start, end) range of a token in the body.
+type span struct {
+	start, end int
+}
+
+// item is a member of an object or an element of an array. The item span of a member starts at its key.
+type item struct {
+	key   string
+	item  span
+	value span
+}
+
+func applyAt(body []byte, value span, path []string, p Patch) ([]byte, error) {
+	var items []item
+	var closing int
+	var found = -1
+	switch body[value.start] {
+	case '{':
+		items, closing = members(body, value.start)
+		found = lastMember(items, path[0])
+	case '[':
+		items, closing = elements(body, value.start)
+		if index, err := strconv.Atoi(path[0]); err == nil && index >= 0 && index < len(items) {
+			found = index
+		}
+	default:
+		if p.Op == OpDelete {
+			return body, nil
+		}
+		return nil, ErrNotContainer
+	}
+
+	if found < 0 {
+		switch {
+		case p.Op == OpDelete:
+			return body, nil
+		case p.Op == OpReplace || body[value.start] == '[':
+			return nil, ErrNotFound
+		}
+		return insertMember(body, items, closing, path, p.Value)
+	}
+	if len(path) > 1 {
+		return applyAt(body, items[found].value, path[1:], p)
+	}
+	if p.Op == OpDelete {
+		body = deleteItem(body, items, found)
+		if body[value.start] == '{' {
+			// every duplicate of the key is deleted, the object is scanned again after each deletion.
+			for items, _ = members(body, value.start); lastMember(items, path[0]) >= 0; items, _ = members(body, value.start) {
+				body = deleteItem(body, items, lastMember(items, path[0]))
+			}
+		}
+		return body, nil
+	}
+	encoded, err := encode(p.Value)
+	if err != nil {
+		return nil, err
+	}
+	return splice(body, items[found].value.start, items[found].value.end, encoded), nil
+}
+
+// lastMember returns the index of the last member with the key, -1 if there is none.
+func lastMember(items []item, key string) int {
+	found := -1
+	for i := range items {
+		if items[i].key == key {
+			found = i
+		}
+	}
+	return found
+}
+
+// insertMember adds the value at the path to the object after its last member, nesting it in new objects for
+// the remainder of the path.
+func insertMember(body []byte, items []item, closing int, path []string, value interface{}) ([]byte, error) {
+	for i := len(path) - 1; i > 0; i-- {
+		value = map[string]interface{}{path[i]: value}
+	}
+	key, err := encode(path[0])
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	member := make([]byte, 0, len(key)+len(encoded)+2)
+	at := closing
+	if len(items) > 0 {
+		member = append(member, ',')
+		at = items[len(items)-1].value.end
+	}
+	member = append(member, key...)
+	member = append(member, ':')
+	member = append(member, encoded...)
+	return splice(body, at, at, member), nil
+}
+
+// deleteItem removes the item together with the comma separating it from its neighbour.
+func deleteItem(body []byte, items []item, i int) []byte {
+	switch {
+	case i > 0:
+		return splice(body, items[i-1].item.end, items[i].item.end, nil)
+	case len(items) > 1:
+		return splice(body, items[0].item.start, items[1].item.start, nil)
+	default:
+		return splice(body, items[0].item.start, items[0].item.end, nil)
+	}
+}
+
+func encode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// the engines receive the characters as they are, like the other values of the body.
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func splice(body []byte, start, end int, insert []byte) []byte {
+	patched := make([]byte, 0, len(body)-(end-start)+len(insert))
+	patched = append(patched, body[:start]...)
+	patched = append(patched, insert...)
+	return append(patched, body[end:]...)
+}
+
+// The scanning functions below expect a body validated by json.Valid.
+
+func rootValue(body []byte) span {
+	start := skipSpace(body, 0)
+	return span{start: start, end: valueEnd(body, start)}
+}
+
+// members returns the members of the object starting at i, and the index of its closing brace.
+func members(body []byte, i int) ([]item, int) {
+	var items []item
+	j := skipSpace(body, i+1)
+	if body[j] == '}' {
+		return nil, j
+	}
+	for {
+		keyEnd := stringEnd(body, j)
+		key := decodeKey(body[j:keyEnd])
+		valueStart := skipSpace(body, skipSpace(body, keyEnd)+1)
+		end := valueEnd(body, valueStart)
+		items = append(items, item{key: key, item: span{start: j, end: end}, value: span{start: valueStart, end: end}})
+		j = skipSpace(body, end)
+		if body[j] == '}' {
+			return items, j
+		}
+		j = skipSpace(body, j+1)
+	}
+}
+
+// elements returns the elements of the array starting at i, and the index of its closing bracket.
+func elements(body []byte, i int) ([]item, int) {
+	var items []item
+	j := skipSpace(body, i+1)
+	if body[j] == ']' {
+		return nil, j
+	}
+	for {
+		end := valueEnd(body, j)
+		items = append(items, item{item: span{start: j, end: end}, value: span{start: j, end: end}})
+		j = skipSpace(body, end)
+		if body[j] == ']' {
+			return items, j
+		}
+		j = skipSpace(body, j+1)
+	}
+}
+
+func decodeKey(quoted []byte) string {
+	if bytes.IndexByte(quoted, '\\') < 0 && utf8.Valid(quoted) {
+		return string(quoted[1 : len(quoted)-1])
+	}
+	var key string
+	_ = json.Unmarshal(quoted, &key)
+	return key
+}
+
+func skipSpace(body []byte, i int) int {
+	for i < len(body) && isSpace(body[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// stringEnd returns the index after the closing quote of the string starting at i.
+func stringEnd(body []byte, i int) int {
+	for j := i + 1; j < len(body); j++ {
+		switch body[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(body)
+}
+
+// valueEnd returns the index after the value starting at i.
+func valueEnd(body []byte, i int) int {
+	switch body[i] {
+	case '"':
+		return stringEnd(body, i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(body); j++ {
+			switch body[j] {
+			case '"':
+				j = stringEnd(body, j) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(body)
+	default:
+		// numbers and literals end at the next delimiter.
+		j := i
+		for j < len(body) && !isSpace(body[j]) && body[j] != ',' && body[j] != '}' && body[j] != ']' {
+			j++
+		}
+		return j
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bodypatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nestedBody = `{
+  "model": "llama-7b",
+  "messages": [{"role": "user", "content": "say \"hi\" <b>"}, {"role": "assistant", "content": "hi"}],
+  "sampling": {"top_p": 0.9, "penalties": {"presence": 1e-3, "frequency": -0.5}},
+  "unknown_engine_field": [true, null, {"nested": {}}],
+  "stream": false
+}`
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		patches  []Patch
+		expected string
+		err      error
+	}{
+		{
+			name:     "replace keeps the key order and the formatting",
+			body:     `{"stream": true,  "model":"llama-7b" , "n":1}`,
+			patches:  []Patch{Replace([]string{"model"}, "lora-1-5f3a9c1e")},
+			expected: `{"stream": true,  "model":"lora-1-5f3a9c1e" , "n":1}`,
+		},
+		{
+			name:     "set appends a missing member",
+			body:     `{"model": "llama-7b"}`,
+			patches:  []Patch{Set([]string{"priority"}, 1)},
+			expected: `{"model": "llama-7b","priority":1}`,
+		},
+		{
+			name:     "set creates the missing objects along the path",
+			body:     `{ }`,
+			patches:  []Patch{Set([]string{"stream_options", "include_usage"}, true)},
+			expected: `{ "stream_options":{"include_usage":true}}`,
+		},
+		{
+			name:     "set within an array",
+			body:     nestedBody,
+			patches:  []Patch{Set([]string{"messages", "1", "content"}, "<b>")},
+			expected: bytesReplace(nestedBody, `"content": "hi"`, `"content": "<b>"`),
+		},
+		{
+			name:     "set deeply nested",
+			body:     nestedBody,
+			patches:  []Patch{Set([]string{"sampling", "penalties", "presence"}, 0)},
+			expected: bytesReplace(nestedBody, `"presence": 1e-3`, `"presence": 0`),
+		},
+		{
+			name:     "delete the first member",
+			body:     `{"a": 1, "b": 2}`,
+			patches:  []Patch{Delete("a")},
+			expected: `{"b": 2}`,
+		},
+		{
+			name:     "delete the last member",
+			body:     `{"a": 1, "b": 2}`,
+			patches:  []Patch{Delete("b")},
+			expected: `{"a": 1}`,
+		},
+		{
+			name:     "delete the only member",
+			body:     `{"a": {"b": [1]}}`,
+			patches:  []Patch{Delete("a", "b")},
+			expected: `{"a": {}}`,
+		},
+		{
+			name:     "delete an array element",
+			body:     `[1, 2, 3]`,
+			patches:  []Patch{Delete("1")},
+			expected: `[1, 3]`,
+		},
+		{
+			name:     "delete every duplicate",
+			body:     `{"max_tokens": 1, "n": 1, "max_tokens": 2}`,
+			patches:  []Patch{Delete("max_tokens")},
+			expected: `{"n": 1}`,
+		},
+		{
+			name:     "replace the last duplicate",
+			body:     `{"model": "a", "model": "b"}`,
+			patches:  []Patch{Replace([]string{"model"}, "c")},
+			expected: `{"model": "a", "model": "c"}`,
+		},
+		{
+			name:     "escaped keys",
+			body:     `{"mo\u0064el": "llama-7b"}`,
+			patches:  []Patch{Replace([]string{"model"}, "lora-1")},
+			expected: `{"mo\u0064el": "lora-1"}`,
+		},
+		{
+			name:     "delete a missing path is a no-op",
+			body:     nestedBody,
+			patches:  []Patch{Delete("sampling", "missing"), Delete("model", "name"), Delete("messages", "7")},
+			expected: nestedBody,
+		},
+		{
+			name:    "replace a missing path",
+			body:    `{"model": "llama-7b"}`,
+			patches: []Patch{Replace([]string{"max_tokens"}, 16)},
+			err:     ErrNotFound,
+		},
+		{
+			name:    "set through a scalar",
+			body:    `{"model": "llama-7b"}`,
+			patches: []Patch{Set([]string{"model", "name"}, "lora-1")},
+			err:     ErrNotContainer,
+		},
+		{
+			name:    "set an index out of range",
+			body:    `{"messages": []}`,
+			patches: []Patch{Set([]string{"messages", "0"}, "hi")},
+			err:     ErrNotFound,
+		},
+		{
+			name:    "empty path",
+			body:    `{}`,
+			patches: []Patch{Set(nil, 1)},
+			err:     ErrEmptyPath,
+		},
+		{
+			name:    "invalid body",
+			body:    `{"model": }`,
+			patches: []Patch{Delete("model")},
+			err:     ErrInvalidJSON,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := []byte(tc.body)
+			patched, err := Apply(body, tc.patches...)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(patched))
+			assert.Equal(t, tc.body, string(body), "the body must not be modified")
+		})
+	}
+}
+
+func bytesReplace(s, old, new string) string {
+	return string(bytes.Replace([]byte(s), []byte(old), []byte(new), 1))
+}
+
+// FuzzApplyRoundTrip checks that a member set on a body and deleted again gives back the exact bytes, and that
+// the applied patches are seen by a JSON decoder.
+func FuzzApplyRoundTrip(f *testing.F) {
+	f.Add(nestedBody, "include_usage", "true")
+	f.Add(`{}`, "priority", "1")
+	f.Add(`{"a":{"b":{"c":[{"d":{"e":[1,2,{"f":"g"}]}}]}}}`, "h", `{"i":[null]}`)
+	f.Add(`{"k\"ey": "v\\", "x" : [ ] }`, "k\"ey", `"w"`)
+	f.Add(` { "model" : "llama-7b" } `, "model", `"lora-1"`)
+
+	f.Fuzz(func(t *testing.T, body, key, value string) {
+		var decoded map[string]interface{}
+		if json.Unmarshal([]byte(body), &decoded) != nil || decoded == nil {
+			return
+		}
+		// JSON keys are unicode, an invalid key can not be set as is.
+		if !utf8.ValidString(key) {
+			return
+		}
+		var patchValue interface{}
+		if json.Unmarshal([]byte(value), &patchValue) != nil {
+			return
+		}
+
+		// the patched member is visible through the nested objects created along the path.
+		path := []string{"aibrix_fuzz", key, "value"}
+		patched, err := Apply([]byte(body), Set(path, patchValue))
+		if _, exists := decoded["aibrix_fuzz"]; exists {
+			return
+		}
+		if err != nil {
+			t.Fatalf("failed to set %v: %v", path, err)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(patched, &got); err != nil {
+			t.Fatalf("patched body %q is invalid: %v", patched, err)
+		}
+		nested, _ := got["aibrix_fuzz"].(map[string]interface{})
+		inner, _ := nested[key].(map[string]interface{})
+		assert.Equal(t, patchValue, inner["value"])
+		delete(got, "aibrix_fuzz")
+		assert.Equal(t, decoded, got, "the other members must be unchanged")
+
+		// deleting the inserted member restores the original bytes.
+		restored, err := Apply(patched, Delete("aibrix_fuzz"))
+		if err != nil {
+			t.Fatalf("failed to delete the inserted member: %v", err)
+		}
+		assert.Equal(t, body, string(restored))
+
+		// replacing an existing member only rewrites the bytes of its value.
+		if _, exists := decoded[key]; !exists {
+			return
+		}
+		items, _ := members([]byte(body), rootValue([]byte(body)).start)
+		var target item
+		for _, it := range items {
+			if it.key == key {
+				target = it
+			}
+		}
+		replaced, err := Apply([]byte(body), Replace([]string{key}, patchValue))
+		if err != nil {
+			t.Fatalf("failed to replace %q: %v", key, err)
+		}
+		encoded, _ := encode(patchValue)
+		assert.Equal(t, body[:target.value.start]+string(encoded)+body[target.value.end:], string(replaced))
+	})
+}
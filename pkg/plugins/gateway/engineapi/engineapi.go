@@ -0,0 +1,217 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package engineapi translates the OpenAI requests routed to the pods of an inference engine which does not serve
+// the OpenAI API into the native API of the engine, and the responses of the engine back into OpenAI responses.
+package engineapi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Endpoint is the OpenAI endpoint of a request.
+type Endpoint string
+
+const (
+	ChatCompletions Endpoint = "/v1/chat/completions"
+	Completions     Endpoint = "/v1/completions"
+)
+
+var ErrUnsupported = errors.New("unsupported by the engine API")
+
+// EndpointOf returns the OpenAI endpoint of the request path, the query is ignored.
+func EndpointOf(path string) (Endpoint, bool) {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	switch Endpoint(path) {
+	case ChatCompletions, Completions:
+		return Endpoint(path), true
+	}
+	return "", false
+}
+
+// Request is an OpenAI request routed to a pod of the engine.
+type Request struct {
+	ID       string
+	Endpoint Endpoint
+	// Model is the model served by the pod, reported in the translated responses.
+	Model  string
+	Stream bool
+	// PromptTokens is the estimate of the prompt tokens, reported when the engine does not count them.
+	PromptTokens int64
+	// Body is the parsed JSON body of the request.
+	Body    map[string]interface{}
+	Created time.Time
+}
+
+// includeUsage tells whether the client asked for the usage of the stream.
+func (r Request) includeUsage() bool {
+	options, _ := r.Body["stream_options"].(map[string]interface{})
+	includeUsage, _ := options["include_usage"].(bool)
+	return includeUsage
+}
+
+// Translator translates the requests of an engine API.
+type Translator interface {
+	// TranslateRequest returns the path and the body of the request in the API of the engine.
+	TranslateRequest(req Request) (string, []byte, error)
+	// NewResponseTranslator returns the translator of the response to the request.
+	NewResponseTranslator(req Request) ResponseTranslator
+}
+
+// ResponseTranslator translates the successful response of the engine to a request into an OpenAI response, with
+// the usage counted by the engine.
+type ResponseTranslator interface {
+	// TranslateBody translates the whole body of a non-streaming response.
+	TranslateBody(body []byte) ([]byte, error)
+	// TranslateStream translates a chunk of a streaming response into server-sent events. An event split across
+	// chunks is translated with the chunk completing it, the last chunk terminates the stream with [DONE].
+	TranslateStream(chunk []byte, endOfStream bool) ([]byte, error)
+}
+
+var translators = map[string]Translator{
+	"tgi": tgiTranslator{},
+}
+
+// Get returns the translator of the engine API.
+func Get(api string) (Translator, bool) {
+	translator, ok := translators[api]
+	return translator, ok
+}
+
+// Names returns the names of the engine APIs with a translator.
+func Names() []string {
+	names := make([]string, 0, len(translators))
+	for name := range translators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// usage is the usage of an OpenAI response.
+type usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+func newUsage(promptTokens, completionTokens int64) *usage {
+	return &usage{PromptTokens: promptTokens, CompletionTokens: completionTokens, TotalTokens: promptTokens + completionTokens}
+}
+
+// completion is an OpenAI completion or chat completion, or a chunk of their streams.
+type completion struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []interface{} `json:"choices"`
+	Usage   *usage        `json:"usage,omitempty"`
+}
+
+type completionChoice struct {
+	Index        int         `json:"index"`
+	Text         string      `json:"text"`
+	Logprobs     interface{} `json:"logprobs"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+type chatChoice struct {
+	Index        int         `json:"index"`
+	Message      chatMessage `json:"message"`
+	Logprobs     interface{} `json:"logprobs"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+type chatChunkChoice struct {
+	Index        int         `json:"index"`
+	Delta        chatMessage `json:"delta"`
+	Logprobs     interface{} `json:"logprobs"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+// completionID returns the id of the OpenAI response to the request.
+func completionID(req Request) string {
+	if req.Endpoint == ChatCompletions {
+		return "chatcmpl-" + req.ID
+	}
+	return "cmpl-" + req.ID
+}
+
+// sseBuffer splits a stream of server-sent events into the data of its events.
+type sseBuffer struct {
+	pending []byte
+}
+
+// events returns the data of the events completed by the chunk, and of the pending event at the end of the stream.
+func (b *sseBuffer) events(chunk []byte, endOfStream bool) []string {
+	b.pending = append(b.pending, chunk...)
+	text := strings.ReplaceAll(string(b.pending), "\r\n", "\n")
+	blocks := strings.Split(text, "\n\n")
+	// the last block is incomplete until the stream ends.
+	last := blocks[len(blocks)-1]
+	blocks = blocks[:len(blocks)-1]
+	b.pending = []byte(last)
+	if endOfStream {
+		blocks = append(blocks, last)
+		b.pending = nil
+	}
+
+	var events []string
+	for _, block := range blocks {
+		var data []string
+		for _, line := range strings.Split(block, "\n") {
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(value, " "))
+			}
+		}
+		if len(data) > 0 {
+			events = append(events, strings.Join(data, "\n"))
+		}
+	}
+	return events
+}
+
+// appendEvent appends the server-sent event of the data.
+func appendEvent(out []byte, data []byte) []byte {
+	out = append(out, "data: "...)
+	out = append(out, data...)
+	return append(out, "\n\n"...)
+}
+
+// errorData returns the OpenAI error event of an error reported by the engine.
+func errorData(message, errorType string) map[string]interface{} {
+	if errorType == "" {
+		errorType = "engine_error"
+	}
+	return map[string]interface{}{"error": map[string]interface{}{"message": message, "type": errorType}}
+}
+
+// invalidField returns the error of a field of the request with a value the engine API does not support.
+func invalidField(field string, value interface{}) error {
+	return fmt.Errorf("%w: %s %v", ErrUnsupported, field, value)
+}
@@ -0,0 +1,339 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engineapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	tgiGeneratePath       = "/generate"
+	tgiGenerateStreamPath = "/generate_stream"
+
+	// defaultCompletionMaxTokens is the max_tokens of the OpenAI completions which do not set it.
+	defaultCompletionMaxTokens = 16
+)
+
+// tgiTranslator translates the requests into the native generate API of text-generation-inference. The API takes
+// a raw prompt, the messages of a chat are rendered as "role: content" lines followed by the assistant turn.
+type tgiTranslator struct{}
+
+type tgiParameters struct {
+	MaxNewTokens     *int64   `json:"max_new_tokens,omitempty"`
+	DoSample         bool     `json:"do_sample"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	// Details reports the finish reason and the generated tokens, DecoderInputDetails the prompt tokens. TGI rejects
+	// the decoder input details of a stream.
+	Details             bool `json:"details"`
+	DecoderInputDetails bool `json:"decoder_input_details,omitempty"`
+}
+
+type tgiRequest struct {
+	Inputs     string        `json:"inputs"`
+	Parameters tgiParameters `json:"parameters"`
+}
+
+type tgiToken struct {
+	ID      int64  `json:"id"`
+	Text    string `json:"text"`
+	Special bool   `json:"special"`
+}
+
+type tgiDetails struct {
+	FinishReason    string `json:"finish_reason"`
+	GeneratedTokens int64  `json:"generated_tokens"`
+	// InputLength is the number of prompt tokens of a stream, reported by TGI 2.1 and later.
+	InputLength int64      `json:"input_length"`
+	Prefill     []tgiToken `json:"prefill"`
+}
+
+type tgiResponse struct {
+	GeneratedText string      `json:"generated_text"`
+	Details       *tgiDetails `json:"details"`
+}
+
+type tgiStreamEvent struct {
+	Token     tgiToken    `json:"token"`
+	Details   *tgiDetails `json:"details"`
+	Error     string      `json:"error"`
+	ErrorType string      `json:"error_type"`
+}
+
+func (tgiTranslator) TranslateRequest(req Request) (string, []byte, error) {
+	var translated tgiRequest
+	var err error
+	switch req.Endpoint {
+	case ChatCompletions:
+		translated.Inputs, err = tgiChatInputs(req.Body["messages"])
+	case Completions:
+		translated.Inputs, err = tgiCompletionInputs(req.Body["prompt"])
+	default:
+		err = fmt.Errorf("%w: endpoint %s", ErrUnsupported, req.Endpoint)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if translated.Parameters, err = tgiRequestParameters(req); err != nil {
+		return "", nil, err
+	}
+
+	body, err := json.Marshal(translated)
+	if err != nil {
+		return "", nil, err
+	}
+	if req.Stream {
+		return tgiGenerateStreamPath, body, nil
+	}
+	return tgiGeneratePath, body, nil
+}
+
+func tgiRequestParameters(req Request) (tgiParameters, error) {
+	parameters := tgiParameters{Details: true, DecoderInputDetails: !req.Stream}
+	if n, ok := req.Body["n"].(float64); ok && n != 1 {
+		return parameters, invalidField("n", n)
+	}
+
+	maxTokens, ok := req.Body["max_tokens"].(float64)
+	if value, set := req.Body["max_completion_tokens"].(float64); set && req.Endpoint == ChatCompletions {
+		maxTokens, ok = value, true
+	}
+	if !ok && req.Endpoint == Completions {
+		maxTokens, ok = defaultCompletionMaxTokens, true
+	}
+	if ok {
+		value := int64(maxTokens)
+		parameters.MaxNewTokens = &value
+	}
+
+	// the OpenAI API samples at a temperature of 1 unless set, TGI decodes greedily unless sampling.
+	parameters.DoSample = true
+	if temperature, ok := req.Body["temperature"].(float64); ok {
+		if temperature <= 0 {
+			parameters.DoSample = false
+		} else {
+			parameters.Temperature = &temperature
+		}
+	}
+	// TGI takes a top_p strictly between 0 and 1, 1 is the default of both APIs.
+	if topP, ok := req.Body["top_p"].(float64); ok && topP > 0 && topP < 1 {
+		parameters.TopP = &topP
+	}
+	if penalty, ok := req.Body["frequency_penalty"].(float64); ok && penalty != 0 {
+		parameters.FrequencyPenalty = &penalty
+	}
+	if seed, ok := req.Body["seed"].(float64); ok {
+		value := int64(seed)
+		parameters.Seed = &value
+	}
+
+	switch stop := req.Body["stop"].(type) {
+	case nil:
+	case string:
+		parameters.Stop = []string{stop}
+	case []interface{}:
+		for _, value := range stop {
+			sequence, ok := value.(string)
+			if !ok {
+				return parameters, invalidField("stop", stop)
+			}
+			parameters.Stop = append(parameters.Stop, sequence)
+		}
+	default:
+		return parameters, invalidField("stop", stop)
+	}
+	return parameters, nil
+}
+
+// tgiCompletionInputs returns the prompt of a completion, a single string.
+func tgiCompletionInputs(prompt interface{}) (string, error) {
+	switch value := prompt.(type) {
+	case string:
+		return value, nil
+	case []interface{}:
+		if len(value) == 1 {
+			if text, ok := value[0].(string); ok {
+				return text, nil
+			}
+		}
+	}
+	return "", invalidField("prompt", prompt)
+}
+
+// tgiChatInputs renders the messages of a chat, the content of a message is a string or text parts.
+func tgiChatInputs(messages interface{}) (string, error) {
+	list, ok := messages.([]interface{})
+	if !ok || len(list) == 0 {
+		return "", invalidField("messages", messages)
+	}
+	var inputs strings.Builder
+	for _, value := range list {
+		message, ok := value.(map[string]interface{})
+		if !ok {
+			return "", invalidField("message", value)
+		}
+		role, _ := message["role"].(string)
+		var content string
+		switch parts := message["content"].(type) {
+		case string:
+			content = parts
+		case []interface{}:
+			for _, part := range parts {
+				part, _ := part.(map[string]interface{})
+				text, ok := part["text"].(string)
+				if !ok || part["type"] != "text" {
+					return "", invalidField("content part", part["type"])
+				}
+				content += text
+			}
+		case nil:
+		default:
+			return "", invalidField("content", parts)
+		}
+		fmt.Fprintf(&inputs, "%s: %s\n", role, content)
+	}
+	inputs.WriteString("assistant:")
+	return inputs.String(), nil
+}
+
+func (tgiTranslator) NewResponseTranslator(req Request) ResponseTranslator {
+	return &tgiResponseTranslator{req: req}
+}
+
+type tgiResponseTranslator struct {
+	req Request
+
+	sse sseBuffer
+	// roleSent tells whether the role of the assistant was streamed, with the first chunk of a chat.
+	roleSent bool
+}
+
+// promptTokens returns the prompt tokens counted by TGI, or the estimate if it did not count them.
+func (t *tgiResponseTranslator) promptTokens(details *tgiDetails) int64 {
+	if len(details.Prefill) > 0 {
+		return int64(len(details.Prefill))
+	}
+	if details.InputLength > 0 {
+		return details.InputLength
+	}
+	return t.req.PromptTokens
+}
+
+// tgiFinishReason maps the finish reason of TGI to the OpenAI one.
+func tgiFinishReason(reason string) string {
+	if reason == "length" {
+		return "length"
+	}
+	// eos_token and stop_sequence.
+	return "stop"
+}
+
+func (t *tgiResponseTranslator) TranslateBody(body []byte) ([]byte, error) {
+	var res tgiResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("invalid tgi response: %w", err)
+	}
+	if res.Details == nil {
+		return nil, fmt.Errorf("invalid tgi response: no details")
+	}
+
+	finishReason := tgiFinishReason(res.Details.FinishReason)
+	translated := completion{
+		ID:      completionID(t.req),
+		Object:  "text_completion",
+		Created: t.req.Created.Unix(),
+		Model:   t.req.Model,
+		Choices: []interface{}{completionChoice{Text: res.GeneratedText, FinishReason: &finishReason}},
+		Usage:   newUsage(t.promptTokens(res.Details), res.Details.GeneratedTokens),
+	}
+	if t.req.Endpoint == ChatCompletions {
+		translated.Object = "chat.completion"
+		translated.Choices = []interface{}{chatChoice{
+			Message:      chatMessage{Role: "assistant", Content: res.GeneratedText},
+			FinishReason: &finishReason,
+		}}
+	}
+	return json.Marshal(translated)
+}
+
+func (t *tgiResponseTranslator) TranslateStream(chunk []byte, endOfStream bool) ([]byte, error) {
+	var out []byte
+	for _, data := range t.sse.events(chunk, endOfStream) {
+		var event tgiStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("invalid tgi stream event: %w", err)
+		}
+		if event.Error != "" {
+			errorEvent, _ := json.Marshal(errorData(event.Error, event.ErrorType))
+			out = appendEvent(out, errorEvent)
+			continue
+		}
+
+		var text string
+		if !event.Token.Special {
+			text = event.Token.Text
+		}
+		if text != "" || (t.req.Endpoint == ChatCompletions && !t.roleSent) {
+			out = appendEvent(out, t.chunk(text, nil, nil))
+		}
+		if event.Details != nil {
+			finishReason := tgiFinishReason(event.Details.FinishReason)
+			out = appendEvent(out, t.chunk("", &finishReason, nil))
+			if t.req.includeUsage() {
+				out = appendEvent(out, t.chunk("", nil, newUsage(t.promptTokens(event.Details), event.Details.GeneratedTokens)))
+			}
+		}
+	}
+	if endOfStream {
+		out = appendEvent(out, []byte("[DONE]"))
+	}
+	return out, nil
+}
+
+// chunk returns an OpenAI chunk of the stream, the usage chunk has no choice.
+func (t *tgiResponseTranslator) chunk(text string, finishReason *string, usage *usage) []byte {
+	translated := completion{
+		ID:      completionID(t.req),
+		Object:  "text_completion",
+		Created: t.req.Created.Unix(),
+		Model:   t.req.Model,
+		Choices: []interface{}{},
+		Usage:   usage,
+	}
+	if t.req.Endpoint == ChatCompletions {
+		translated.Object = "chat.completion.chunk"
+	}
+	if usage == nil {
+		if t.req.Endpoint == ChatCompletions {
+			delta := chatMessage{Content: text}
+			if !t.roleSent {
+				delta.Role = "assistant"
+				t.roleSent = true
+			}
+			translated.Choices = []interface{}{chatChunkChoice{Delta: delta, FinishReason: finishReason}}
+		} else {
+			translated.Choices = []interface{}{completionChoice{Text: text, FinishReason: finishReason}}
+		}
+	}
+	data, _ := json.Marshal(translated)
+	return data
+}
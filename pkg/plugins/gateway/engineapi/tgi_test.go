@@ -0,0 +1,164 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engineapi
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The fixtures are the OpenAI requests of the clients, the requests and the responses recorded from TGI, and the
+// OpenAI responses they translate into.
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", "tgi", name))
+	require.NoError(t, err)
+	return data
+}
+
+func newFixtureRequest(t *testing.T, name string, endpoint Endpoint) Request {
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(readFixture(t, name+".request.json"), &body))
+	stream, _ := body["stream"].(bool)
+	return Request{
+		ID:           "r1",
+		Endpoint:     endpoint,
+		Model:        "llama-2-7b",
+		Stream:       stream,
+		PromptTokens: 5,
+		Body:         body,
+		Created:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestTGIRequestFixtures(t *testing.T) {
+	translator, ok := Get("tgi")
+	require.True(t, ok)
+
+	for _, tc := range []struct {
+		name     string
+		endpoint Endpoint
+		path     string
+	}{
+		{name: "chat", endpoint: ChatCompletions, path: "/generate"},
+		{name: "chat_stream", endpoint: ChatCompletions, path: "/generate_stream"},
+		{name: "completion_stream", endpoint: Completions, path: "/generate_stream"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path, body, err := translator.TranslateRequest(newFixtureRequest(t, tc.name, tc.endpoint))
+			require.NoError(t, err)
+			assert.Equal(t, tc.path, path)
+			assert.JSONEq(t, string(readFixture(t, tc.name+".generate.json")), string(body))
+		})
+	}
+}
+
+func TestTGIRequestUnsupported(t *testing.T) {
+	for name, body := range map[string]map[string]interface{}{
+		"n":        {"messages": []interface{}{map[string]interface{}{"role": "user", "content": "hi"}}, "n": 2.0},
+		"image":    {"messages": []interface{}{map[string]interface{}{"role": "user", "content": []interface{}{map[string]interface{}{"type": "image_url"}}}}},
+		"batch":    {"prompt": []interface{}{"a", "b"}},
+		"stop":     {"prompt": "a", "stop": 1.0},
+		"messages": {"prompt": "a"},
+	} {
+		endpoint := Completions
+		if _, ok := body["prompt"]; !ok || name == "messages" {
+			endpoint = ChatCompletions
+		}
+		_, _, err := tgiTranslator{}.TranslateRequest(Request{Endpoint: endpoint, Body: body})
+		assert.True(t, errors.Is(err, ErrUnsupported), name)
+	}
+
+	_, _, err := tgiTranslator{}.TranslateRequest(Request{Endpoint: "/v1/embeddings", Body: map[string]interface{}{}})
+	assert.True(t, errors.Is(err, ErrUnsupported))
+}
+
+func TestTGIResponseFixture(t *testing.T) {
+	req := newFixtureRequest(t, "chat", ChatCompletions)
+	translated, err := tgiTranslator{}.NewResponseTranslator(req).TranslateBody(readFixture(t, "chat.generate.response.json"))
+	require.NoError(t, err)
+	// the prompt tokens are the prefill tokens counted by TGI, not the estimate.
+	assert.JSONEq(t, string(readFixture(t, "chat.response.json")), string(translated))
+
+	// a completion without the prefill tokens reports the estimate.
+	req.Endpoint = Completions
+	translated, err = tgiTranslator{}.NewResponseTranslator(req).TranslateBody(
+		[]byte(`{"generated_text":" Paris.","details":{"finish_reason":"stop_sequence","generated_tokens":2,"seed":null,"prefill":[],"tokens":[]}}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"cmpl-r1","object":"text_completion","created":1735689600,"model":"llama-2-7b",
+		"choices":[{"index":0,"text":" Paris.","logprobs":null,"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`, string(translated))
+
+	_, err = tgiTranslator{}.NewResponseTranslator(req).TranslateBody([]byte(`{"generated_text":" Paris."}`))
+	assert.Error(t, err)
+}
+
+func TestTGIStreamFixtures(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		endpoint Endpoint
+	}{
+		// TGI 2.1 reports the prompt tokens of the stream.
+		{name: "chat_stream", endpoint: ChatCompletions},
+		// the usage is only streamed if the client asked for it.
+		{name: "completion_stream", endpoint: Completions},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newFixtureRequest(t, tc.name, tc.endpoint)
+			recorded := readFixture(t, tc.name+".generate_stream.response.txt")
+			expected := string(readFixture(t, tc.name+".response.txt"))
+
+			translated, err := tgiTranslator{}.NewResponseTranslator(req).TranslateStream(recorded, true)
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(translated))
+
+			// the events split across the chunks of the response are translated once complete.
+			for _, size := range []int{1, 7, 64} {
+				translator := tgiTranslator{}.NewResponseTranslator(req)
+				var out []byte
+				for start := 0; start < len(recorded); start += size {
+					end := min(start+size, len(recorded))
+					chunk, err := translator.TranslateStream(recorded[start:end], false)
+					require.NoError(t, err)
+					out = append(out, chunk...)
+				}
+				chunk, err := translator.TranslateStream(nil, true)
+				require.NoError(t, err)
+				out = append(out, chunk...)
+				assert.Equal(t, expected, string(out), "chunk size %d", size)
+			}
+		})
+	}
+}
+
+func TestTGIStreamError(t *testing.T) {
+	req := newFixtureRequest(t, "chat_stream", ChatCompletions)
+	translator := tgiTranslator{}.NewResponseTranslator(req)
+	translated, err := translator.TranslateStream([]byte("data:{\"error\":\"Request failed during generation: Server error: CUDA out of memory\",\"error_type\":\"generation\"}\n\n"), true)
+	require.NoError(t, err)
+	assert.Equal(t, "data: {\"error\":{\"message\":\"Request failed during generation: Server error: CUDA out of memory\",\"type\":\"generation\"}}\n\ndata: [DONE]\n\n", string(translated))
+
+	_, err = tgiTranslator{}.NewResponseTranslator(req).TranslateStream([]byte("data:{\"index\":\n\n"), false)
+	assert.Error(t, err)
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/google/uuid"
@@ -35,45 +36,144 @@ import (
 	"github.com/vllm-project/aibrix/pkg/cache"
 	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
 	"github.com/vllm-project/aibrix/pkg/plugins/gateway/ratelimiter"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/tokenizer"
 	"github.com/vllm-project/aibrix/pkg/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	healthPb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type Server struct {
-	redisClient         *redis.Client
-	ratelimiter         ratelimiter.RateLimiter
+	redisClient         redis.UniversalClient
+	ratelimiter         ratelimiter.SlidingWindowRateLimiter
+	rateLimits          rateLimitConfig
+	users               *utils.UserCache
+	accounting          *accountingPipeline
+	usage               *usageMeter
 	client              kubernetes.Interface
 	requestCountTracker map[string]int
 	cache               *cache.Cache
+	defaultModel        string
+	timeouts            *timeoutResolver
+	admission           *admissionController
+	admissionQueue      *admissionQueue
+	heartbeats          *queueHeartbeats
+	failover            *failoverProxy
+	policies            *policyCache
+	engineHints         *engineHintResolver
+	engineAPIs          *engineAPIResolver
+	headroom            *headroomResolver
+	tenants             *tenantPools
+	adapterVersions     *adapterVersionRouter
+	loraFallback        *loraFallback
+	deprecations        *modelDeprecations
+	responseHeaders     *responseHeaderConfig
+	middlewares         *middlewareConfig
+	trustedHops         int
+	tokenizers          *tokenizer.Registry
+	batches             *batchRunner
+	observability       *observability
+	stopCh              chan struct{}
+	tracer              trace.Tracer
 }
 
-func NewServer(redisClient *redis.Client, client kubernetes.Interface) *Server {
-	c, err := cache.GetCache()
-	if err != nil {
-		panic(err)
+// NewServer returns the gateway server reading the pods and their metrics from the cache, the global cache if c is
+// nil.
+func NewServer(redisClient redis.UniversalClient, client kubernetes.Interface, c *cache.Cache) *Server {
+	if c == nil {
+		var err error
+		if c, err = cache.GetCache(); err != nil {
+			panic(err)
+		}
 	}
 	r := ratelimiter.NewRedisAccountRateLimiter("aibrix", redisClient, 1*time.Minute)
+	accounting := newAccountingPipelineFromEnv(r)
+	accounting.start()
+	usage := newUsageMeterFromEnv(redisClient)
+	usage.start()
+	stopCh := make(chan struct{})
+	middlewares := newMiddlewareConfig(redisClient)
+	middlewares.start(stopCh)
+	deprecations := newModelDeprecations(c, redisClient)
+	deprecations.start(stopCh)
+	registerPodMetricStaleness(c)
+	observability := newObservabilityFromEnv()
+	observability.start(stopCh)
 
-	return &Server{
+	s := &Server{
 		redisClient:         redisClient,
 		ratelimiter:         r,
+		rateLimits:          newRateLimitConfigFromEnv(),
+		users:               utils.NewUserCache(redisClient, time.Duration(loadPositiveIntEnv(EnvUserCacheTTLSeconds, DefaultUserCacheTTLSeconds))*time.Second),
+		accounting:          accounting,
+		usage:               usage,
 		client:              client,
 		requestCountTracker: map[string]int{},
 		cache:               c,
+		defaultModel:        utils.LoadEnv(EnvDefaultModel, ""),
+		timeouts:            newTimeoutResolverFromEnv(),
+		admission:           newAdmissionControllerFromEnv(),
+		admissionQueue:      newAdmissionQueueFromEnv(newPendingRequests(redisClient)),
+		policies:            newPolicyCache(),
+		engineHints:         newEngineHintResolverFromEnv(),
+		engineAPIs:          newEngineAPIResolverFromEnv(),
+		headroom:            newHeadroomResolverFromEnv(c),
+		tenants:             newTenantPoolsFromEnv(),
+		adapterVersions:     newAdapterVersionRouter(c),
+		loraFallback:        newLoraFallback(c, client),
+		deprecations:        deprecations,
+		responseHeaders:     newResponseHeaderConfigFromEnv(),
+		middlewares:         middlewares,
+		trustedHops:         loadPositiveIntEnv(EnvXFFTrustedHops, 0),
+		tokenizers:          tokenizer.LoadRegistry(),
+		observability:       observability,
+		stopCh:              stopCh,
+		tracer:              otel.Tracer(tracerName),
 	}
+	s.batches = newBatchRunner(newRedisBatchStore(redisClient), c, s, newBatchLimitsFromEnv())
+	return s
+}
+
+// Shutdown stops the reload of the middleware policies and the model deprecations, fails the batches executing on
+// the server and flushes the accounting and usage records buffered by the server, within the deadline of ctx.
+func (s *Server) Shutdown(ctx context.Context) error {
+	close(s.stopCh)
+	if err := s.batches.shutdown(ctx); err != nil {
+		klog.ErrorS(err, "failed to stop the batches")
+	}
+	if err := s.usage.shutdown(ctx); err != nil {
+		klog.ErrorS(err, "failed to flush the usage records")
+	}
+	return s.accounting.shutdown(ctx)
 }
 
 func (s *Server) Process(srv extProcPb.ExternalProcessor_ProcessServer) error {
-	var user utils.User
-	var rpm, traceTerm int64
-	var respErrorCode int
-	var model, routingStrategy, targetPodIP string
-	var stream, isRespError bool
+	var traceTerm int64
+	var respErrorCode, statusCode int
+	var model, routingStrategy, targetPodIP, requestPath, priority, queueMode string
+	var stream, isRespError, streamTerminated bool
+	var deadline *streamDeadline
+	var budget requestBudget
+	var tracing *requestTracing
+	account := &requestAccount{}
 	ctx := srv.Context()
 	requestID := uuid.New().String()
 	completed := false
+	start := time.Now()
+	reqLog := s.observability.startRequest()
+	defer func() {
+		s.observability.finishRequest(requestID, reqLog, requestSummary{
+			model: model, targetPod: targetPodIP, statusCode: statusCode, stream: stream, latency: time.Since(start)})
+	}()
+	defer func() { tracing.end() }()
+	// release the request from the concurrency limit of its pod if the stream ends before the response does.
+	defer s.cache.DonePodRequest(requestID, false)
+	defer s.heartbeats.forget(requestID)
+	defer s.failover.forget(requestID)
+	// account the estimated usage of a stream the client disconnected from, or which was terminated, before its end.
+	defer func() { s.accountStreamEstimate(requestID, account, model, usageEstimatedInterrupted) }()
 
-	klog.InfoS("Processing request", "requestID", requestID)
+	klog.V(4).InfoS("Processing request", "requestID", requestID)
 
 	for {
 		select {
@@ -94,25 +194,96 @@ func (s *Server) Process(srv extProcPb.ExternalProcessor_ProcessServer) error {
 		switch v := req.Request.(type) {
 
 		case *extProcPb.ProcessingRequest_RequestHeaders:
-			resp, user, rpm, routingStrategy = s.HandleRequestHeaders(ctx, requestID, req)
+			arrival := time.Now()
+			tracing = newRequestTracing(ctx, s.tracer, requestID, v.RequestHeaders.Headers.Headers)
+			resp, account, routingStrategy = s.HandleRequestHeaders(ctx, requestID, req)
+			account.logSampled = reqLog.sampled
+			tracing.recordResponse(resp)
+			requestPath = getRequestPath(v.RequestHeaders.Headers.Headers)
+			priority = getRequestPriority(v.RequestHeaders.Headers.Headers)
+			queueMode = getQueueMode(v.RequestHeaders.Headers.Headers)
+			budget = getRequestBudget(v.RequestHeaders.Headers.Headers, arrival)
+			if isBatchPath(requestPath) && resp.GetImmediateResponse() == nil {
+				if batchResp := s.HandleBatchHeaders(ctx, requestID, getRequestMethod(v.RequestHeaders.Headers.Headers), requestPath, account); batchResp != nil {
+					resp = batchResp
+					tracing.recordResponse(resp)
+				}
+			}
 
 		case *extProcPb.ProcessingRequest_RequestBody:
-			resp, model, targetPodIP, stream, traceTerm = s.HandleRequestBody(ctx, requestID, req, user, routingStrategy)
+			if tracing == nil {
+				tracing = newRequestTracing(ctx, s.tracer, requestID, nil)
+			}
+			if isBatchPath(requestPath) {
+				resp = s.HandleBatchBody(ctx, requestID, req, account, routingStrategy)
+				tracing.setUser(account.user)
+				tracing.recordResponse(resp)
+				break
+			}
+			resp, model, targetPodIP, stream, traceTerm = s.HandleRequestBody(tracing.ctx, requestID, req, account, routingStrategy, requestPath, priority, queueMode, budget)
+			tracing.setUser(account.user)
+			tracing.setRouting(model, routingStrategy, targetPodIP)
+			tracing.recordResponse(resp)
+			if mutation := resp.GetRequestBody().GetResponse().GetHeaderMutation(); mutation != nil {
+				mutation.SetHeaders = append(mutation.SetHeaders, tracing.startUpstream(targetPodIP)...)
+			}
+			if stream {
+				deadline = newStreamDeadline(s.timeouts.resolve(requestPath, model), time.Now())
+			}
 
 		case *extProcPb.ProcessingRequest_ResponseHeaders:
-			resp, isRespError, respErrorCode = s.HandleResponseHeaders(ctx, requestID, req, targetPodIP)
+			resp, isRespError, respErrorCode = s.HandleResponseHeaders(ctx, requestID, req, account, targetPodIP)
+			// the notice is added to the body of a successful non-streaming response.
+			account.deprecation.mutateResponseHeaders(resp.GetResponseHeaders().GetResponse().GetHeaderMutation(), !stream && !isRespError)
+			// the body of a successful non-streaming response of an engine API is replaced by its translation.
+			if account.engineAPI != nil && !stream && !isRespError {
+				removeContentLength(resp.GetResponseHeaders().GetResponse().GetHeaderMutation())
+			}
+			tracing.observeResponseHeaders(isRespError, respErrorCode)
+			statusCode = http.StatusOK
+			if isRespError {
+				statusCode = respErrorCode
+				s.cache.DonePodRequest(requestID, isOverloadStatus(respErrorCode))
+			}
 
 		case *extProcPb.ProcessingRequest_ResponseBody:
 			respBody := req.Request.(*extProcPb.ProcessingRequest_ResponseBody)
-			if isRespError {
+			if account.queueHeartbeat && !respBody.ResponseBody.EndOfStream && isSSECommentOnly(respBody.ResponseBody.GetBody()) {
+				// the heartbeats of the admission queue are passed through, they are not the first token of the pod.
+				resp = generatePassthroughBodyResponse()
+				break
+			}
+			tracing.observeResponseBody()
+			s.cache.ObservePodFirstToken(requestID)
+			if streamTerminated {
+				// Drop the remaining chunks of a stream which was already terminated with an error event.
+				resp = generateStreamBodyResponse(nil)
+			} else if err := s.checkStreamDeadline(deadline); err != nil {
+				klog.ErrorS(err, "terminating stream", "requestID", requestID, "model", model, "targetPodIP", targetPodIP)
+				resp = generateStreamTimeoutResponse(err)
+				streamTerminated = true
+				statusCode = http.StatusGatewayTimeout
+				s.cache.DonePodRequest(requestID, true)
+				if !completed {
+					completed = true
+					s.cache.DoneRequestTrace(requestID, model, 0, 0, traceTerm)
+				}
+			} else if isRespError {
 				klog.ErrorS(errors.New("request end"), string(respBody.ResponseBody.GetBody()), "requestID", requestID)
 				generateErrorResponse(envoyTypePb.StatusCode(respErrorCode), nil, string(respBody.ResponseBody.GetBody()))
 			} else {
-				resp, completed = s.HandleResponseBody(ctx, requestID, req, user, rpm, model, targetPodIP, stream, traceTerm, completed)
+				resp, completed = s.HandleResponseBody(ctx, requestID, req, account, model, targetPodIP, stream, traceTerm, completed)
+			}
+			if completed {
+				s.cache.DonePodRequest(requestID, false)
+				tracing.end()
 			}
 		default:
 			klog.Infof("Unknown Request type %+v\n", v)
 		}
+		if code := immediateStatusCode(resp); code != 0 {
+			statusCode = code
+		}
 
 		if err := srv.Send(resp); err != nil {
 			klog.Infof("send error %v", err)
@@ -120,21 +291,61 @@ func (s *Server) Process(srv extProcPb.ExternalProcessor_ProcessServer) error {
 	}
 }
 
-func (s *Server) selectTargetPod(ctx context.Context, routingStrategy routing.Algorithms, pods map[string]*v1.Pod, model, message string) (string, error) {
-	router, err := routing.Select(routingStrategy)()
+// isOverloadStatus returns whether the upstream status code tells the pod is overloaded, client errors are not
+// held against the concurrency limit of the pod.
+func isOverloadStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// checkStreamDeadline records the arrival of a response chunk, it is a no-op for non-streaming requests.
+func (s *Server) checkStreamDeadline(deadline *streamDeadline) error {
+	if deadline == nil {
+		return nil
+	}
+	return deadline.observe(time.Now())
+}
+
+func (s *Server) selectTargetPod(ctx context.Context, routingStrategy routing.Algorithms, pods map[string]*v1.Pod, model, message, priority string) (string, error) {
+	router, err := routing.Select(routingStrategy)
 	if err != nil {
 		return "", err
 	}
-	return router.Route(ctx, pods, model, message)
+	return s.headroom.decorate(router, model, priority).Route(ctx, pods, model, message)
+}
+
+// selectTargetPods returns up to n target pods of the routing strategy, the best first.
+func (s *Server) selectTargetPods(ctx context.Context, routingStrategy routing.Algorithms, pods map[string]*v1.Pod, model, message, priority string, n int) ([]string, error) {
+	router, err := routing.Select(routingStrategy)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := routing.RouteN(ctx, s.headroom.decorate(router, model, priority), pods, model, message, n)
+	if err != nil {
+		return nil, err
+	}
+	for i, target := range targets {
+		targets[i] = resolveTargetPort(s.cache, model, pods, target)
+	}
+	return targets, nil
 }
 
-func NewHealthCheckServer() *HealthServer {
-	return &HealthServer{}
+// NewHealthCheckServer returns a health server reporting the gateway as serving while all the checks pass, e.g.
+// while Redis answers, so that the readiness probe takes the gateway out of rotation until its dependencies are back.
+func NewHealthCheckServer(checks ...func(ctx context.Context) error) *HealthServer {
+	return &HealthServer{checks: checks}
 }
 
-type HealthServer struct{}
+type HealthServer struct {
+	checks []func(ctx context.Context) error
+}
 
 func (s *HealthServer) Check(ctx context.Context, in *healthPb.HealthCheckRequest) (*healthPb.HealthCheckResponse, error) {
+	for _, check := range s.checks {
+		if err := check(ctx); err != nil {
+			klog.V(4).Infof("health check failed: %v", err)
+			return &healthPb.HealthCheckResponse{Status: healthPb.HealthCheckResponse_NOT_SERVING}, nil
+		}
+	}
 	return &healthPb.HealthCheckResponse{Status: healthPb.HealthCheckResponse_SERVING}, nil
 }
 
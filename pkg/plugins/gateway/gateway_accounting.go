@@ -0,0 +1,194 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/ratelimiter"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	defaultAccountingBufferSize    = 10000
+	defaultAccountingBatchSize     = 100
+	defaultAccountingFlushInterval = 100 * time.Millisecond
+
+	// accountingFlushTimeout bounds a background flush, so that an unreachable Redis does not stall the flusher
+	// while the buffer fills up.
+	accountingFlushTimeout = 5 * time.Second
+)
+
+var accountingRecordsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_gateway_accounting_records_dropped_total",
+	Help: "Number of accounting records the gateway dropped before writing them to Redis, by reason.",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(accountingRecordsDropped)
+}
+
+// accountingPipeline writes the accounting records of the requests, e.g. the tokens counted against the TPM of a
+// user, to Redis off the request path. The request path appends the records to a bounded ring buffer, which drops
+// its oldest record when full, and a background flusher writes them in batches every flush interval or as soon as
+// a batch is buffered. The counters a request reads back to be admitted are incremented synchronously instead.
+type accountingPipeline struct {
+	sink ratelimiter.BatchRateLimiter
+
+	mu      sync.Mutex
+	records []ratelimiter.Increment
+	// head is the index of the oldest record of the ring buffer, and size the number of records buffered.
+	head int
+	size int
+
+	batchSize     int
+	flushInterval time.Duration
+	// flushCh wakes the flusher up once a batch is buffered.
+	flushCh  chan struct{}
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func newAccountingPipeline(sink ratelimiter.BatchRateLimiter, bufferSize, batchSize int, flushInterval time.Duration) *accountingPipeline {
+	if batchSize > bufferSize {
+		batchSize = bufferSize
+	}
+	return &accountingPipeline{
+		sink:          sink,
+		records:       make([]ratelimiter.Increment, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		flushCh:       make(chan struct{}, 1),
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+}
+
+func newAccountingPipelineFromEnv(sink ratelimiter.BatchRateLimiter) *accountingPipeline {
+	return newAccountingPipeline(sink,
+		loadPositiveIntEnv("AIBRIX_ACCOUNTING_BUFFER_SIZE", defaultAccountingBufferSize),
+		loadPositiveIntEnv("AIBRIX_ACCOUNTING_BATCH_SIZE", defaultAccountingBatchSize),
+		loadDurationMsEnv("AIBRIX_ACCOUNTING_FLUSH_INTERVAL_MS", defaultAccountingFlushInterval))
+}
+
+func loadPositiveIntEnv(key string, defaultValue int) int {
+	value := utils.LoadEnv(key, "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 {
+			klog.Infof("invalid %s: %s, falling back to default", key, value)
+		} else {
+			klog.Infof("using %s env value: %d", key, intValue)
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// record buffers the increment of the counter of the key, it never blocks on Redis.
+func (p *accountingPipeline) record(key string, val int64) {
+	p.mu.Lock()
+	if p.size == len(p.records) {
+		// drop the oldest record, the newest ones count against the current window.
+		p.head = (p.head + 1) % len(p.records)
+		p.size--
+		accountingRecordsDropped.WithLabelValues("buffer_full").Inc()
+	}
+	p.records[(p.head+p.size)%len(p.records)] = ratelimiter.Increment{Key: key, Val: val, At: time.Now()}
+	p.size++
+	full := p.size >= p.batchSize
+	p.mu.Unlock()
+
+	if full {
+		select {
+		case p.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// take removes up to a batch of the oldest records from the buffer.
+func (p *accountingPipeline) take() []ratelimiter.Increment {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.size
+	if n > p.batchSize {
+		n = p.batchSize
+	}
+	batch := make([]ratelimiter.Increment, n)
+	for i := range batch {
+		batch[i] = p.records[(p.head+i)%len(p.records)]
+	}
+	p.head = (p.head + n) % len(p.records)
+	p.size -= n
+	return batch
+}
+
+// flush writes the buffered records in batches until the buffer is empty. A batch Redis failed to write is
+// dropped, retrying it would hold back the newer records.
+func (p *accountingPipeline) flush(ctx context.Context) error {
+	for {
+		batch := p.take()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := p.sink.IncrBatch(ctx, batch); err != nil {
+			accountingRecordsDropped.WithLabelValues("flush_failed").Add(float64(len(batch)))
+			return err
+		}
+	}
+}
+
+// start runs the flusher until shutdown.
+func (p *accountingPipeline) start() {
+	go func() {
+		defer close(p.doneCh)
+		ticker := time.NewTicker(p.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-p.flushCh:
+			case <-p.stopCh:
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), accountingFlushTimeout)
+			if err := p.flush(ctx); err != nil {
+				klog.ErrorS(err, "failed to flush the accounting records")
+			}
+			cancel()
+		}
+	}()
+}
+
+// shutdown stops the flusher and flushes the remaining records, within the deadline of ctx.
+func (p *accountingPipeline) shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+	select {
+	case <-p.doneCh:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.flush(ctx)
+}
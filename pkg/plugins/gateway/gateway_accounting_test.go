@@ -0,0 +1,178 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/ratelimiter"
+)
+
+// fakeBatchRateLimiter keeps the counters in memory, every call costs a round trip of latency.
+type fakeBatchRateLimiter struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	latency  time.Duration
+	err      error
+}
+
+func newFakeBatchRateLimiter(latency time.Duration) *fakeBatchRateLimiter {
+	return &fakeBatchRateLimiter{counters: map[string]int64{}, latency: latency}
+}
+
+func (f *fakeBatchRateLimiter) Get(ctx context.Context, key string) (int64, error) {
+	time.Sleep(f.latency)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counters[key], nil
+}
+
+func (f *fakeBatchRateLimiter) GetLimit(ctx context.Context, key string) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeBatchRateLimiter) Incr(ctx context.Context, key string, val int64) (int64, error) {
+	time.Sleep(f.latency)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counters[key] += val
+	return f.counters[key], nil
+}
+
+func (f *fakeBatchRateLimiter) IncrBatch(ctx context.Context, increments []ratelimiter.Increment) error {
+	time.Sleep(f.latency)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	for _, incr := range increments {
+		f.counters[incr.Key] += incr.Val
+	}
+	return nil
+}
+
+func (f *fakeBatchRateLimiter) Reserve(ctx context.Context, key string, val, limit int64) (ratelimiter.Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return ratelimiter.Reservation{}, f.err
+	}
+	used := f.counters[key]
+	if used+val > limit {
+		return ratelimiter.Reservation{Used: used, Reset: time.Minute}, nil
+	}
+	f.counters[key] += val
+	return ratelimiter.Reservation{Allowed: true, Used: used + val, Reset: time.Minute}, nil
+}
+
+func (f *fakeBatchRateLimiter) get(key string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counters[key]
+}
+
+func TestAccountingPipelineDropsOldest(t *testing.T) {
+	p := newAccountingPipeline(newFakeBatchRateLimiter(0), 3, 10, time.Hour)
+	dropped := testutil.ToFloat64(accountingRecordsDropped.WithLabelValues("buffer_full"))
+	for i := int64(1); i <= 5; i++ {
+		p.record("user_TPM_CURRENT", i)
+	}
+
+	batch := p.take()
+	if assert.Len(t, batch, 3) {
+		assert.Equal(t, []int64{3, 4, 5}, []int64{batch[0].Val, batch[1].Val, batch[2].Val})
+	}
+	assert.Empty(t, p.take())
+	assert.Equal(t, dropped+2, testutil.ToFloat64(accountingRecordsDropped.WithLabelValues("buffer_full")))
+}
+
+func TestAccountingPipelineFlushesBatches(t *testing.T) {
+	sink := newFakeBatchRateLimiter(0)
+	p := newAccountingPipeline(sink, 100, 2, time.Hour)
+	p.start()
+	// a full batch is flushed without waiting for the flush interval.
+	p.record("alice_TPM_CURRENT", 10)
+	p.record("bob_TPM_CURRENT", 20)
+	assert.Eventually(t, func() bool {
+		return sink.get("alice_TPM_CURRENT") == 10 && sink.get("bob_TPM_CURRENT") == 20
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, p.shutdown(context.Background()))
+
+	p = newAccountingPipeline(sink, 100, 100, 10*time.Millisecond)
+	p.start()
+	p.record("alice_TPM_CURRENT", 5)
+	assert.Eventually(t, func() bool { return sink.get("alice_TPM_CURRENT") == 15 }, time.Second, time.Millisecond)
+	assert.NoError(t, p.shutdown(context.Background()))
+}
+
+func TestAccountingPipelineShutdownFlush(t *testing.T) {
+	sink := newFakeBatchRateLimiter(0)
+	p := newAccountingPipeline(sink, 100, 2, time.Hour)
+	p.start()
+	p.record("alice_TPM_CURRENT", 1)
+	p.record("alice_TPM_CURRENT", 2)
+	p.record("alice_TPM_CURRENT", 4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, p.shutdown(ctx))
+	assert.Equal(t, int64(7), sink.get("alice_TPM_CURRENT"))
+	assert.NoError(t, p.shutdown(ctx))
+
+	// the records Redis failed to write are dropped and counted.
+	sink.err = errors.New("connection refused")
+	p = newAccountingPipeline(sink, 100, 100, time.Hour)
+	p.start()
+	p.record("alice_TPM_CURRENT", 8)
+	dropped := testutil.ToFloat64(accountingRecordsDropped.WithLabelValues("flush_failed"))
+	assert.Error(t, p.shutdown(ctx))
+	assert.Equal(t, dropped+1, testutil.ToFloat64(accountingRecordsDropped.WithLabelValues("flush_failed")))
+	assert.Equal(t, int64(7), sink.get("alice_TPM_CURRENT"))
+}
+
+// BenchmarkTPMAccounting compares the time the request path spends counting the tokens of a request against a
+// Redis with a round trip of 200us, synchronously and through the accounting pipeline.
+func BenchmarkTPMAccounting(b *testing.B) {
+	b.Run("sync", func(b *testing.B) {
+		sink := newFakeBatchRateLimiter(200 * time.Microsecond)
+		for i := 0; i < b.N; i++ {
+			if _, err := sink.Incr(context.Background(), "user_TPM_CURRENT", 100); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("async", func(b *testing.B) {
+		sink := newFakeBatchRateLimiter(200 * time.Microsecond)
+		p := newAccountingPipeline(sink, defaultAccountingBufferSize, defaultAccountingBatchSize, defaultAccountingFlushInterval)
+		p.start()
+		for i := 0; i < b.N; i++ {
+			p.record("user_TPM_CURRENT", 100)
+		}
+		b.StopTimer()
+		if err := p.shutdown(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	defaultAdmissionSaturationThreshold = 0.8
+	// shedding is disabled unless a fraction is configured.
+	defaultAdmissionShedFraction = 0.0
+
+	priorityHigh = "high"
+	priorityLow  = "low"
+)
+
+// admissionCache is the subset of the cache the admission controller reads.
+type admissionCache interface {
+	GetModelAutoscalerState(modelName string) (cache.ModelAutoscalerState, bool)
+	GetPodsForModel(modelName string) (map[string]*v1.Pod, error)
+	GetPodModelMetric(podName, modelName string, metricName string) (metrics.MetricValue, error)
+	EstimateRetryAfter(modelName string, now time.Time) (time.Duration, bool)
+}
+
+// admissionController sheds low priority requests of a model whose autoscaler can not add more replicas
+// while most of its pods are already at capacity, so that clients back off before the pods are overloaded.
+type admissionController struct {
+	// saturationThreshold is the fraction of the ready pods at capacity above which the model is saturated.
+	saturationThreshold float64
+	// shedFraction is the fraction of the low priority requests rejected while the model is saturated.
+	shedFraction float64
+	rand         func() float64
+}
+
+func newAdmissionControllerFromEnv() *admissionController {
+	return &admissionController{
+		saturationThreshold: loadFractionEnv("AIBRIX_ADMISSION_SATURATION_THRESHOLD", defaultAdmissionSaturationThreshold),
+		shedFraction:        loadFractionEnv("AIBRIX_ADMISSION_SHED_FRACTION", defaultAdmissionShedFraction),
+		rand:                rand.Float64,
+	}
+}
+
+func loadFractionEnv(key string, defaultValue float64) float64 {
+	value := utils.LoadEnv(key, "")
+	if value != "" {
+		floatValue, err := strconv.ParseFloat(value, 64)
+		if err != nil || floatValue < 0 || floatValue > 1 {
+			klog.Infof("invalid %s: %s, falling back to default", key, value)
+		} else {
+			klog.Infof("using %s env value: %v", key, floatValue)
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// admit returns whether the request is admitted, and otherwise the duration after which the client should retry.
+func (a *admissionController) admit(c admissionCache, model, priority string) (bool, time.Duration) {
+	if a == nil || a.shedFraction <= 0 || priority == priorityHigh {
+		return true, 0
+	}
+
+	state, ok := c.GetModelAutoscalerState(model)
+	if !ok || !state.AtMaxScale() {
+		return true, 0
+	}
+	if saturation := getSaturation(c, model); saturation <= a.saturationThreshold {
+		return true, 0
+	}
+	if a.rand() >= a.shedFraction {
+		return true, 0
+	}
+	// prefer the observed wake-up latency of the model over the stabilization window of its autoscaler.
+	if retryAfter, ok := c.EstimateRetryAfter(model, time.Now()); ok {
+		return false, retryAfter
+	}
+	return false, state.StabilizationWindow
+}
+
+// getSaturation returns the fraction of the ready pods of the model which have requests waiting.
+func getSaturation(c admissionCache, model string) float64 {
+	pods, err := c.GetPodsForModel(model)
+	if err != nil {
+		return 0
+	}
+	readyPods := utils.FilterReadyPods(pods)
+	if len(readyPods) == 0 {
+		return 0
+	}
+
+	atCapacity := 0
+	for _, pod := range readyPods {
+		waiting, err := c.GetPodModelMetric(pod.Name, model, metrics.NumRequestsWaiting)
+		if err != nil {
+			continue
+		}
+		if waiting.GetSimpleValue() > 0 {
+			atCapacity++
+		}
+	}
+	return float64(atCapacity) / float64(len(readyPods))
+}
+
+// getRequestPriority returns the priority of the request, requests without a priority are low priority.
+func getRequestPriority(headers []*configPb.HeaderValue) string {
+	for _, header := range headers {
+		if strings.ToLower(header.Key) == HeaderRequestPriority {
+			if strings.ToLower(string(header.RawValue)) == priorityHigh {
+				return priorityHigh
+			}
+			return priorityLow
+		}
+	}
+	return priorityLow
+}
+
+// generateAdmissionRejectedResponse asks the client to retry once the autoscaler had time to react.
+func generateAdmissionRejectedResponse(model string, retryAfter time.Duration) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(envoyTypePb.StatusCode_TooManyRequests,
+		[]*configPb.HeaderValueOption{
+			{Header: &configPb.HeaderValue{Key: HeaderErrorAdmissionRejected, RawValue: []byte(model)}},
+			retryAfterHeader(retryAfter),
+		},
+		"model "+model+" is saturated at its maximum scale, retry later")
+}
+
+// retryAfterHeader returns the Retry-After header in whole seconds, rounded up to at least one second.
+func retryAfterHeader(retryAfter time.Duration) *configPb.HeaderValueOption {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &configPb.HeaderValueOption{Header: &configPb.HeaderValue{Key: HeaderRetryAfter, RawValue: []byte(strconv.FormatInt(seconds, 10))}}
+}
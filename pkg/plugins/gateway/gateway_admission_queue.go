@@ -0,0 +1,269 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// Queue modes a client selects with the x-aibrix-queue header when its request is not admitted.
+const (
+	// queueModeStatus rejects the request with its position in the admission queue of the model and the estimated
+	// wait, so that the client can decide when to retry.
+	queueModeStatus = "status"
+	// queueModeWait holds the request in the admission queue of the model until it is admitted, the client
+	// disconnects or its deadline expires.
+	queueModeWait = "wait"
+
+	defaultAdmissionQueuePollInterval = time.Second
+	defaultAdmissionQueueMaxWait      = 5 * time.Minute
+)
+
+var errAdmissionQueueTimeout = errors.New("request was not admitted before the deadline")
+
+// queueTicket is a request waiting in the admission queue of a model.
+type queueTicket struct {
+	model string
+	// position is the 1-based position of the request in the queue, kept up to date as the requests ahead leave.
+	position int
+}
+
+// admissionQueue holds the requests of the clients willing to wait for the admission of a saturated model. The
+// requests are admitted in FIFO order: only the head of the queue of a model asks the admission controller again,
+// every poll interval.
+type admissionQueue struct {
+	mu      sync.Mutex
+	waiting map[string][]*queueTicket
+
+	pollInterval time.Duration
+	// maxWait bounds the wait of the requests without a deadline.
+	maxWait time.Duration
+	// pending counts the waiting requests of each model in Redis for the autoscaler, nil disables the count.
+	pending *pendingRequests
+}
+
+func newAdmissionQueue(pollInterval, maxWait time.Duration) *admissionQueue {
+	return &admissionQueue{
+		waiting:      map[string][]*queueTicket{},
+		pollInterval: pollInterval,
+		maxWait:      maxWait,
+	}
+}
+
+func newAdmissionQueueFromEnv(pending *pendingRequests) *admissionQueue {
+	q := newAdmissionQueue(
+		loadDurationMsEnv("AIBRIX_ADMISSION_QUEUE_POLL_INTERVAL_MS", defaultAdmissionQueuePollInterval),
+		loadDurationMsEnv("AIBRIX_ADMISSION_QUEUE_MAX_WAIT_MS", defaultAdmissionQueueMaxWait))
+	q.pending = pending
+	return q
+}
+
+func loadDurationMsEnv(key string, defaultValue time.Duration) time.Duration {
+	value := utils.LoadEnv(key, "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 {
+			klog.Infof("invalid %s: %s, falling back to default", key, value)
+		} else {
+			klog.Infof("using %s env value: %d ms", key, intValue)
+			return time.Duration(intValue) * time.Millisecond
+		}
+	}
+	return defaultValue
+}
+
+// enqueue appends a request to the queue of the model.
+func (q *admissionQueue) enqueue(model string) *queueTicket {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ticket := &queueTicket{model: model, position: len(q.waiting[model]) + 1}
+	q.waiting[model] = append(q.waiting[model], ticket)
+	return ticket
+}
+
+// leave removes a request from the queue of its model and moves the requests behind it up. The cost is paid
+// once per request leaving, so that the position of a request is read in constant time.
+func (q *admissionQueue) leave(ticket *queueTicket) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	waiting := q.waiting[ticket.model]
+	index := ticket.position - 1
+	if index < 0 || index >= len(waiting) || waiting[index] != ticket {
+		return
+	}
+	for _, behind := range waiting[index+1:] {
+		behind.position--
+	}
+	waiting = append(waiting[:index], waiting[index+1:]...)
+	ticket.position = 0
+	if len(waiting) == 0 {
+		delete(q.waiting, ticket.model)
+		return
+	}
+	q.waiting[ticket.model] = waiting
+}
+
+// position returns the 1-based position of the request in the queue of its model, 0 once it left.
+func (q *admissionQueue) position(ticket *queueTicket) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return ticket.position
+}
+
+// length returns the number of requests waiting for the admission of the model.
+func (q *admissionQueue) length(model string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.waiting[model])
+}
+
+// estimatedWait estimates the wait of the request at the given position: the head waits until the model is
+// expected to have capacity again, each request ahead is admitted at the earliest one poll interval later.
+func (q *admissionQueue) estimatedWait(position int, retryAfter time.Duration) time.Duration {
+	if position < 1 {
+		position = 1
+	}
+	return retryAfter + time.Duration(position-1)*q.pollInterval
+}
+
+// wait holds the request in the queue of the model until it is admitted. It returns ctx.Err() if the client
+// disconnects, and errAdmissionQueueTimeout once the deadline, or the max wait without a deadline, expires.
+func (q *admissionQueue) wait(ctx context.Context, a *admissionController, c admissionCache, requestID, model, priority string, deadline time.Time) error {
+	return q.waitWithProgress(ctx, a, c, requestID, model, priority, deadline, nil)
+}
+
+// waitWithProgress is wait, calling progress with the position of the request every poll interval until it is
+// admitted, if progress is not nil.
+func (q *admissionQueue) waitWithProgress(ctx context.Context, a *admissionController, c admissionCache, requestID, model, priority string, deadline time.Time, progress func(position int)) error {
+	ticket := q.enqueue(model)
+	defer q.leave(ticket)
+	q.pending.add(model, 1)
+	defer q.pending.add(model, -1)
+
+	maxWait := q.maxWait
+	if !deadline.IsZero() {
+		maxWait = time.Until(deadline)
+	}
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		position := q.position(ticket)
+		if position == 1 {
+			if admitted, _ := a.admit(c, model, priority); admitted {
+				return nil
+			}
+		}
+		klog.V(4).InfoS("request waiting for admission", "requestID", requestID, "model", model, "position", position)
+		if progress != nil {
+			progress(position)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return errAdmissionQueueTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// getQueueMode returns the queue mode the client selected, empty if it did not select a known one.
+func getQueueMode(headers []*configPb.HeaderValue) string {
+	for _, header := range headers {
+		if strings.ToLower(header.Key) != HeaderQueue {
+			continue
+		}
+		switch mode := strings.ToLower(string(header.RawValue)); mode {
+		case queueModeStatus, queueModeWait:
+			return mode
+		}
+	}
+	return ""
+}
+
+// queueStatusHeaders returns the position of the request in the admission queue and its estimated wait in whole
+// seconds, rounded up.
+func queueStatusHeaders(position int, estimatedWait time.Duration) []*configPb.HeaderValueOption {
+	return []*configPb.HeaderValueOption{
+		{Header: &configPb.HeaderValue{Key: HeaderQueuePosition, RawValue: []byte(strconv.Itoa(position))}},
+		{Header: &configPb.HeaderValue{Key: HeaderEstimatedWait, RawValue: []byte(strconv.FormatInt(int64(math.Ceil(estimatedWait.Seconds())), 10))}},
+	}
+}
+
+// generateQueueStatusResponse rejects the request like generateAdmissionRejectedResponse, with the position the
+// request would take in the admission queue and its estimated wait.
+func generateQueueStatusResponse(model string, position int, retryAfter, estimatedWait time.Duration) *extProcPb.ProcessingResponse {
+	resp := generateAdmissionRejectedResponse(model, retryAfter)
+	immediate := resp.GetImmediateResponse()
+	immediate.Headers.SetHeaders = append(immediate.Headers.SetHeaders, queueStatusHeaders(position, estimatedWait)...)
+	return resp
+}
+
+// handleAdmissionRejected returns the response to a request the admission controller rejected, depending on the
+// queue mode selected by the client. It returns nil if the request waited in the admission queue and was admitted.
+// The wait respects the deadline of the request and ends when the client disconnects.
+func (s *Server) handleAdmissionRejected(ctx context.Context, requestID, model, priority, queueMode string, budget requestBudget, retryAfter time.Duration) *extProcPb.ProcessingResponse {
+	if s.admissionQueue == nil {
+		queueMode = ""
+	}
+	switch queueMode {
+	case queueModeStatus:
+		position := s.admissionQueue.length(model) + 1
+		estimatedWait := s.admissionQueue.estimatedWait(position, retryAfter)
+		klog.InfoS("request rejected by admission", "requestID", requestID, "model", model, "priority", priority, "queuePosition", position, "estimatedWait", estimatedWait)
+		return generateQueueStatusResponse(model, position, retryAfter, estimatedWait)
+	case queueModeWait:
+		start := time.Now()
+		var deadline time.Time
+		if remaining, ok := budget.remaining(start); ok {
+			deadline = start.Add(remaining)
+		}
+		err := s.admissionQueue.wait(ctx, s.admission, s.cache, requestID, model, priority, deadline)
+		if err == nil {
+			klog.InfoS("request admitted after waiting in the admission queue", "requestID", requestID, "model", model, "waited", time.Since(start))
+			return nil
+		}
+		klog.InfoS("request left the admission queue", "requestID", requestID, "model", model, "waited", time.Since(start), "reason", err)
+		if errors.Is(err, errAdmissionQueueTimeout) && !deadline.IsZero() {
+			remaining, _ := budget.remaining(time.Now())
+			return generateDeadlineExceededResponse(remaining)
+		}
+		return generateAdmissionRejectedResponse(model, retryAfter)
+	}
+	klog.InfoS("request rejected by admission", "requestID", requestID, "model", model, "priority", priority, "retryAfter", retryAfter)
+	return generateAdmissionRejectedResponse(model, retryAfter)
+}
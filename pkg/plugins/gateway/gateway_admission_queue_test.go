@@ -0,0 +1,209 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+)
+
+// newSaturatedAdmission returns a saturated model whose admission controller sheds the low priority requests
+// until the returned flag is set.
+func newSaturatedAdmission() (*admissionController, *fakeAdmissionCache, *atomic.Bool) {
+	var capacity atomic.Bool
+	a := &admissionController{
+		saturationThreshold: 0.75,
+		shedFraction:        1,
+		rand: func() float64 {
+			if capacity.Load() {
+				return 1
+			}
+			return 0
+		},
+	}
+	c := &fakeAdmissionCache{
+		state: &cache.ModelAutoscalerState{DesiredScale: 4, MaxReplicas: 4, StabilizationWindow: 30 * time.Second},
+		pods:  4, atCapacity: 4,
+	}
+	return a, c, &capacity
+}
+
+func TestAdmissionQueuePositions(t *testing.T) {
+	q := newAdmissionQueue(time.Second, time.Minute)
+	first := q.enqueue("llama-7b")
+	second := q.enqueue("llama-7b")
+	third := q.enqueue("llama-7b")
+	other := q.enqueue("llama-13b")
+	assert.Equal(t, 1, q.position(first))
+	assert.Equal(t, 3, q.position(third))
+	assert.Equal(t, 1, q.position(other))
+	assert.Equal(t, 3, q.length("llama-7b"))
+
+	// a request leaving from the middle moves the ones behind it up.
+	q.leave(second)
+	assert.Equal(t, 0, q.position(second))
+	assert.Equal(t, 1, q.position(first))
+	assert.Equal(t, 2, q.position(third))
+
+	q.leave(first)
+	q.leave(first)
+	assert.Equal(t, 1, q.position(third))
+	q.leave(third)
+	assert.Equal(t, 0, q.length("llama-7b"))
+	assert.Equal(t, 1, q.length("llama-13b"))
+
+	assert.Equal(t, 12*time.Second, q.estimatedWait(1, 12*time.Second))
+	assert.Equal(t, 14*time.Second, q.estimatedWait(3, 12*time.Second))
+}
+
+func TestAdmissionQueueWait(t *testing.T) {
+	a, c, capacity := newSaturatedAdmission()
+	q := newAdmissionQueue(10*time.Millisecond, time.Minute)
+
+	done := make(chan error, 2)
+	go func() { done <- q.wait(context.Background(), a, c, "req-1", "llama-7b", priorityLow, time.Time{}) }()
+	assert.Eventually(t, func() bool { return q.length("llama-7b") == 1 }, time.Second, time.Millisecond)
+	go func() { done <- q.wait(context.Background(), a, c, "req-2", "llama-7b", priorityLow, time.Time{}) }()
+	assert.Eventually(t, func() bool { return q.length("llama-7b") == 2 }, time.Second, time.Millisecond)
+
+	// both requests are admitted in order once the model has capacity again.
+	capacity.Store(true)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("the queued request was not admitted")
+		}
+	}
+	assert.Equal(t, 0, q.length("llama-7b"))
+}
+
+func TestAdmissionQueueWaitDeadline(t *testing.T) {
+	a, c, _ := newSaturatedAdmission()
+	q := newAdmissionQueue(10*time.Millisecond, time.Minute)
+
+	err := q.wait(context.Background(), a, c, "req-1", "llama-7b", priorityLow, time.Now().Add(50*time.Millisecond))
+	assert.ErrorIs(t, err, errAdmissionQueueTimeout)
+	assert.Equal(t, 0, q.length("llama-7b"))
+
+	// the max wait bounds the requests without a deadline.
+	q = newAdmissionQueue(10*time.Millisecond, 50*time.Millisecond)
+	err = q.wait(context.Background(), a, c, "req-2", "llama-7b", priorityLow, time.Time{})
+	assert.ErrorIs(t, err, errAdmissionQueueTimeout)
+}
+
+func TestAdmissionQueueClientDisconnect(t *testing.T) {
+	a, c, _ := newSaturatedAdmission()
+	q := newAdmissionQueue(10*time.Millisecond, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() { done <- q.wait(ctx, a, c, "req-1", "llama-7b", priorityLow, time.Time{}) }()
+	assert.Eventually(t, func() bool { return q.length("llama-7b") == 1 }, time.Second, time.Millisecond)
+	behind := q.enqueue("llama-7b")
+	assert.Equal(t, 2, q.position(behind))
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("the request of the disconnected client was still queued")
+	}
+	assert.Equal(t, 1, q.position(behind))
+}
+
+func TestGenerateQueueStatusResponse(t *testing.T) {
+	resp := generateQueueStatusResponse("llama-7b", 3, 12*time.Second, 14500*time.Millisecond)
+	immediate := resp.GetImmediateResponse()
+	assert.Equal(t, envoyTypePb.StatusCode_TooManyRequests, immediate.GetStatus().GetCode())
+
+	headers := map[string]string{}
+	for _, header := range immediate.GetHeaders().GetSetHeaders() {
+		headers[header.Header.Key] = string(header.Header.RawValue)
+	}
+	assert.Equal(t, "3", headers[HeaderQueuePosition])
+	assert.Equal(t, "15", headers[HeaderEstimatedWait])
+	assert.Equal(t, "12", headers[HeaderRetryAfter])
+	assert.Equal(t, "llama-7b", headers[HeaderErrorAdmissionRejected])
+}
+
+func TestGetQueueMode(t *testing.T) {
+	assert.Equal(t, "", getQueueMode(nil))
+	assert.Equal(t, queueModeWait, getQueueMode([]*configPb.HeaderValue{{Key: "X-Aibrix-Queue", RawValue: []byte("WAIT")}}))
+	assert.Equal(t, queueModeStatus, getQueueMode([]*configPb.HeaderValue{{Key: HeaderQueue, RawValue: []byte("status")}}))
+	assert.Equal(t, "", getQueueMode([]*configPb.HeaderValue{{Key: HeaderQueue, RawValue: []byte("forever")}}))
+}
+
+// fakePendingCounter keeps the counters in memory.
+type fakePendingCounter struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func (f *fakePendingCounter) IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] += value
+	return redis.NewIntResult(f.values[key], nil)
+}
+
+func (f *fakePendingCounter) get(key string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.values[key]
+}
+
+func TestAdmissionQueuePendingRequests(t *testing.T) {
+	a, c, capacity := newSaturatedAdmission()
+	counter := &fakePendingCounter{values: map[string]int64{}}
+	q := newAdmissionQueue(10*time.Millisecond, time.Minute)
+	q.pending = &pendingRequests{client: counter}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 2)
+	go func() { done <- q.wait(ctx, a, c, "req-1", "llama-7b", priorityLow, time.Time{}) }()
+	go func() { done <- q.wait(context.Background(), a, c, "req-2", "llama-7b", priorityLow, time.Time{}) }()
+	assert.Eventually(t, func() bool { return counter.get("pending:llama-7b") == 2 }, time.Second, time.Millisecond)
+
+	// the requests are no longer pending once they leave the queue, admitted or not.
+	cancel()
+	assert.Eventually(t, func() bool { return counter.get("pending:llama-7b") == 1 }, time.Second, time.Millisecond)
+	capacity.Store(true)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the queued request did not leave the queue")
+		}
+	}
+	assert.Equal(t, int64(0), counter.get("pending:llama-7b"))
+
+	// the requests are not counted without Redis.
+	assert.Nil(t, newPendingRequests(nil))
+}
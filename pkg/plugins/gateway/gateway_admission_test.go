@@ -0,0 +1,184 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+)
+
+// fakeAdmissionCache serves a model with the given autoscaler state and ready pods, the first
+// atCapacity pods having requests waiting.
+type fakeAdmissionCache struct {
+	state      *cache.ModelAutoscalerState
+	pods       int
+	atCapacity int
+	// retryAfter is the wake-up estimate of the model, nil if there is no history.
+	retryAfter *time.Duration
+}
+
+func (c *fakeAdmissionCache) GetModelAutoscalerState(modelName string) (cache.ModelAutoscalerState, bool) {
+	if c.state == nil {
+		return cache.ModelAutoscalerState{}, false
+	}
+	return *c.state, true
+}
+
+func (c *fakeAdmissionCache) GetPodsForModel(modelName string) (map[string]*v1.Pod, error) {
+	pods := map[string]*v1.Pod{}
+	for i := 0; i < c.pods; i++ {
+		name := fmt.Sprintf("pod-%d", i)
+		pods[name] = &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1.PodStatus{
+				PodIP:      fmt.Sprintf("10.0.0.%d", i+1),
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		}
+	}
+	return pods, nil
+}
+
+func (c *fakeAdmissionCache) GetPodModelMetric(podName, modelName string, metricName string) (metrics.MetricValue, error) {
+	var index int
+	if _, err := fmt.Sscanf(podName, "pod-%d", &index); err != nil {
+		return nil, err
+	}
+	waiting := 0.0
+	if index < c.atCapacity {
+		waiting = 3
+	}
+	return &metrics.SimpleMetricValue{Value: waiting}, nil
+}
+
+func (c *fakeAdmissionCache) EstimateRetryAfter(modelName string, now time.Time) (time.Duration, bool) {
+	if c.retryAfter == nil {
+		return 0, false
+	}
+	return *c.retryAfter, true
+}
+
+func TestAdmissionController(t *testing.T) {
+	atMax := &cache.ModelAutoscalerState{DesiredScale: 4, MaxReplicas: 4, StabilizationWindow: 30 * time.Second}
+	belowMax := &cache.ModelAutoscalerState{DesiredScale: 3, MaxReplicas: 4, StabilizationWindow: 30 * time.Second}
+	estimate := 12 * time.Second
+
+	var tests = []struct {
+		cache      *fakeAdmissionCache
+		priority   string
+		roll       float64
+		admitted   bool
+		retryAfter time.Duration
+		message    string
+	}{
+		{
+			cache:      &fakeAdmissionCache{state: atMax, pods: 4, atCapacity: 4},
+			priority:   priorityLow,
+			roll:       0.1,
+			admitted:   false,
+			retryAfter: 30 * time.Second,
+			message:    "saturated at max scale sheds low priority",
+		},
+		{
+			cache:      &fakeAdmissionCache{state: atMax, pods: 4, atCapacity: 4, retryAfter: &estimate},
+			priority:   priorityLow,
+			roll:       0.1,
+			admitted:   false,
+			retryAfter: estimate,
+			message:    "the wake-up estimate is preferred over the stabilization window",
+		},
+		{
+			cache:    &fakeAdmissionCache{state: atMax, pods: 4, atCapacity: 4},
+			priority: priorityLow,
+			roll:     0.6,
+			admitted: true,
+			message:  "requests beyond the shed fraction are admitted",
+		},
+		{
+			cache:    &fakeAdmissionCache{state: atMax, pods: 4, atCapacity: 4},
+			priority: priorityHigh,
+			roll:     0.1,
+			admitted: true,
+			message:  "high priority is never shed",
+		},
+		{
+			cache:    &fakeAdmissionCache{state: belowMax, pods: 4, atCapacity: 4},
+			priority: priorityLow,
+			roll:     0.1,
+			admitted: true,
+			message:  "autoscaler can still add replicas",
+		},
+		{
+			cache:    &fakeAdmissionCache{state: atMax, pods: 4, atCapacity: 3},
+			priority: priorityLow,
+			roll:     0.1,
+			admitted: true,
+			message:  "saturation at the threshold is admitted",
+		},
+		{
+			cache:    &fakeAdmissionCache{pods: 4, atCapacity: 4},
+			priority: priorityLow,
+			roll:     0.1,
+			admitted: true,
+			message:  "model without autoscaler",
+		},
+	}
+
+	for _, tt := range tests {
+		a := &admissionController{
+			saturationThreshold: 0.75,
+			shedFraction:        0.5,
+			rand:                func() float64 { return tt.roll },
+		}
+		admitted, retryAfter := a.admit(tt.cache, "llama-7b", tt.priority)
+		assert.Equal(t, tt.admitted, admitted, tt.message)
+		assert.Equal(t, tt.retryAfter, retryAfter, tt.message)
+	}
+
+	disabled := &admissionController{saturationThreshold: 0.75, rand: func() float64 { return 0 }}
+	admitted, _ := disabled.admit(&fakeAdmissionCache{state: atMax, pods: 4, atCapacity: 4}, "llama-7b", priorityLow)
+	assert.True(t, admitted, "shedding is disabled without a shed fraction")
+}
+
+func TestGetRequestPriority(t *testing.T) {
+	assert.Equal(t, priorityLow, getRequestPriority(nil))
+	assert.Equal(t, priorityHigh, getRequestPriority([]*configPb.HeaderValue{{Key: "X-Request-Priority", RawValue: []byte("HIGH")}}))
+	assert.Equal(t, priorityLow, getRequestPriority([]*configPb.HeaderValue{{Key: HeaderRequestPriority, RawValue: []byte("batch")}}))
+}
+
+func TestGenerateAdmissionRejectedResponse(t *testing.T) {
+	resp := generateAdmissionRejectedResponse("llama-7b", 1500*time.Millisecond)
+	immediate := resp.GetImmediateResponse()
+	assert.Equal(t, envoyTypePb.StatusCode_TooManyRequests, immediate.GetStatus().GetCode())
+
+	headers := map[string]string{}
+	for _, header := range immediate.GetHeaders().GetSetHeaders() {
+		headers[header.Header.Key] = string(header.Header.RawValue)
+	}
+	assert.Equal(t, "2", headers[HeaderRetryAfter])
+	assert.Equal(t, "llama-7b", headers[HeaderErrorAdmissionRejected])
+}
@@ -0,0 +1,452 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+)
+
+const (
+	batchPath = "/v1/batches"
+
+	// States of a batch, a batch is validated, then executed until it reaches one of the terminal states.
+	batchStatusValidating = "validating"
+	batchStatusInProgress = "in_progress"
+	batchStatusCancelling = "cancelling"
+	batchStatusCompleted  = "completed"
+	batchStatusFailed     = "failed"
+	batchStatusExpired    = "expired"
+	batchStatusCancelled  = "cancelled"
+
+	defaultBatchMaxRequests    = 1000
+	defaultBatchMaxBodyBytes   = 4 << 20
+	defaultBatchMaxConcurrency = 4
+	defaultBatchResultTTL      = 24 * time.Hour
+	// maxBatchCompletionWindow is the longest completion window a batch may ask for, and the window of the batches
+	// which do not ask for one.
+	maxBatchCompletionWindow = 24 * time.Hour
+
+	batchKeyPrefix = "aibrix:batch:"
+)
+
+// batchEndpoints are the endpoints the requests of a batch may target.
+var batchEndpoints = map[string]struct{}{
+	"/v1/chat/completions": {},
+	"/v1/completions":      {},
+	"/v1/embeddings":       {},
+}
+
+var (
+	errBatchNotFound = errors.New("batch not found")
+	errBatchTerminal = errors.New("batch already finished")
+	errBatchTooLarge = errors.New("batch exceeds the max body size")
+)
+
+// batchValidationError rejects a batch submission which is malformed or exceeds the limits.
+type batchValidationError struct {
+	message string
+}
+
+func (e *batchValidationError) Error() string {
+	return e.message
+}
+
+func newBatchValidationError(format string, args ...interface{}) error {
+	return &batchValidationError{message: fmt.Sprintf(format, args...)}
+}
+
+// batchLimits bounds the batches a gateway accepts and executes.
+type batchLimits struct {
+	maxRequests  int
+	maxBodyBytes int
+	// maxConcurrency caps the requests of all the batches executing at once.
+	maxConcurrency int
+	// resultTTL is the time the finished batches and their results are kept for.
+	resultTTL time.Duration
+}
+
+func newBatchLimitsFromEnv() batchLimits {
+	return batchLimits{
+		maxRequests:    loadPositiveIntEnv("AIBRIX_BATCH_MAX_REQUESTS", defaultBatchMaxRequests),
+		maxBodyBytes:   loadPositiveIntEnv("AIBRIX_BATCH_MAX_BODY_BYTES", defaultBatchMaxBodyBytes),
+		maxConcurrency: loadPositiveIntEnv("AIBRIX_BATCH_MAX_CONCURRENCY", defaultBatchMaxConcurrency),
+		resultTTL:      loadDurationMsEnv("AIBRIX_BATCH_RESULT_TTL_MS", defaultBatchResultTTL),
+	}
+}
+
+// batchSubmission is the body of a batch submission, the requests are inlined instead of uploaded as a file.
+type batchSubmission struct {
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata"`
+	Requests         []batchRequest    `json:"requests"`
+}
+
+type batchRequest struct {
+	CustomID string          `json:"custom_id"`
+	Body     json.RawMessage `json:"body"`
+
+	model string
+}
+
+type batchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type batchUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type batchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Line is the 1-based index of the request the error is about.
+	Line int `json:"line,omitempty"`
+}
+
+// batchJob is a batch as it is persisted in Redis and served to the user who submitted it, its fields follow the
+// batch object of the OpenAI API.
+type batchJob struct {
+	ID               string             `json:"id"`
+	Object           string             `json:"object"`
+	Endpoint         string             `json:"endpoint"`
+	CompletionWindow string             `json:"completion_window"`
+	Status           string             `json:"status"`
+	Errors           []batchError       `json:"errors,omitempty"`
+	RequestCounts    batchRequestCounts `json:"request_counts"`
+	Usage            batchUsage         `json:"usage"`
+	Metadata         map[string]string  `json:"metadata,omitempty"`
+	CreatedAt        int64              `json:"created_at"`
+	InProgressAt     int64              `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64              `json:"expires_at"`
+	CompletedAt      int64              `json:"completed_at,omitempty"`
+	FailedAt         int64              `json:"failed_at,omitempty"`
+	ExpiredAt        int64              `json:"expired_at,omitempty"`
+	CancellingAt     int64              `json:"cancelling_at,omitempty"`
+	CancelledAt      int64              `json:"cancelled_at,omitempty"`
+	// User is the user the batch is accounted to, only the user can read and cancel it.
+	User string `json:"user,omitempty"`
+}
+
+func (j *batchJob) isTerminal() bool {
+	switch j.Status {
+	case batchStatusCompleted, batchStatusFailed, batchStatusExpired, batchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// batchResult is the outcome of a request of a batch, either the response of the engine or the error which
+// prevented the request from being served.
+type batchResult struct {
+	ID       string         `json:"id"`
+	CustomID string         `json:"custom_id"`
+	Response *batchResponse `json:"response"`
+	Error    *batchError    `json:"error"`
+}
+
+type batchResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// parseBatchSubmission validates the shape and the bounds of a batch submission, and returns its completion window.
+// The models and the user policy are validated asynchronously, while the batch is validating.
+func parseBatchSubmission(body []byte, limits batchLimits) (*batchSubmission, time.Duration, error) {
+	if len(body) > limits.maxBodyBytes {
+		return nil, 0, errBatchTooLarge
+	}
+	var submission batchSubmission
+	if err := json.Unmarshal(body, &submission); err != nil {
+		return nil, 0, newBatchValidationError("invalid batch: %v", err)
+	}
+	if _, ok := batchEndpoints[submission.Endpoint]; !ok {
+		return nil, 0, newBatchValidationError("unsupported endpoint %q", submission.Endpoint)
+	}
+
+	window := maxBatchCompletionWindow
+	if submission.CompletionWindow != "" {
+		var err error
+		window, err = time.ParseDuration(submission.CompletionWindow)
+		if err != nil || window <= 0 || window > maxBatchCompletionWindow {
+			return nil, 0, newBatchValidationError("invalid completion window %q, it must be at most %v", submission.CompletionWindow, maxBatchCompletionWindow)
+		}
+	} else {
+		submission.CompletionWindow = "24h"
+	}
+
+	if len(submission.Requests) == 0 {
+		return nil, 0, newBatchValidationError("batch has no requests")
+	}
+	if len(submission.Requests) > limits.maxRequests {
+		return nil, 0, newBatchValidationError("batch has %d requests, at most %d are allowed", len(submission.Requests), limits.maxRequests)
+	}
+	customIDs := make(map[string]struct{}, len(submission.Requests))
+	for i := range submission.Requests {
+		request := &submission.Requests[i]
+		if request.CustomID == "" {
+			return nil, 0, newBatchValidationError("request %d has no custom_id", i+1)
+		}
+		if _, ok := customIDs[request.CustomID]; ok {
+			return nil, 0, newBatchValidationError("request %d has the duplicated custom_id %q", i+1, request.CustomID)
+		}
+		customIDs[request.CustomID] = struct{}{}
+
+		var jsonMap map[string]interface{}
+		if err := json.Unmarshal(request.Body, &jsonMap); err != nil || jsonMap == nil {
+			return nil, 0, newBatchValidationError("request %d has no valid body", i+1)
+		}
+		if request.model, _ = jsonMap["model"].(string); request.model == "" {
+			return nil, 0, newBatchValidationError("request %d has no model", i+1)
+		}
+		if stream, _ := jsonMap["stream"].(bool); stream {
+			return nil, 0, newBatchValidationError("request %d is streaming, batch requests can not stream", i+1)
+		}
+	}
+	return &submission, window, nil
+}
+
+// batchStore persists the batches and their results, the entries expire after the TTL they were last saved with.
+type batchStore interface {
+	saveJob(ctx context.Context, job *batchJob, ttl time.Duration) error
+	getJob(ctx context.Context, id string) (*batchJob, error)
+	saveResult(ctx context.Context, id string, result *batchResult, ttl time.Duration) error
+	getResults(ctx context.Context, id string) ([]*batchResult, error)
+	// requestCancel flags the batch as cancelled for the gateway executing it, which may be another one.
+	requestCancel(ctx context.Context, id string, ttl time.Duration) error
+	cancelRequested(ctx context.Context, id string) (bool, error)
+}
+
+// redisBatchStore keeps a batch as a JSON string, and its results in a hash keyed by custom_id.
+type redisBatchStore struct {
+	client redis.UniversalClient
+}
+
+func newRedisBatchStore(client redis.UniversalClient) *redisBatchStore {
+	return &redisBatchStore{client: client}
+}
+
+func batchJobKey(id string) string {
+	return batchKeyPrefix + id
+}
+
+func batchResultsKey(id string) string {
+	return batchKeyPrefix + id + ":results"
+}
+
+func batchCancelKey(id string) string {
+	return batchKeyPrefix + id + ":cancel"
+}
+
+func (s *redisBatchStore) saveJob(ctx context.Context, job *batchJob, ttl time.Duration) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, batchJobKey(job.ID), data, ttl)
+		pipe.Expire(ctx, batchResultsKey(job.ID), ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *redisBatchStore) getJob(ctx context.Context, id string) (*batchJob, error) {
+	data, err := s.client.Get(ctx, batchJobKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, errBatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := &batchJob{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *redisBatchStore) saveResult(ctx context.Context, id string, result *batchResult, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, batchResultsKey(id), result.CustomID, data)
+		pipe.Expire(ctx, batchResultsKey(id), ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *redisBatchStore) getResults(ctx context.Context, id string) ([]*batchResult, error) {
+	fields, err := s.client.HGetAll(ctx, batchResultsKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*batchResult, 0, len(fields))
+	for _, data := range fields {
+		result := &batchResult{}
+		if err := json.Unmarshal([]byte(data), result); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CustomID < results[j].CustomID
+	})
+	return results, nil
+}
+
+func (s *redisBatchStore) requestCancel(ctx context.Context, id string, ttl time.Duration) error {
+	return s.client.Set(ctx, batchCancelKey(id), "true", ttl).Err()
+}
+
+func (s *redisBatchStore) cancelRequested(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, batchCancelKey(id)).Result()
+	return n > 0, err
+}
+
+// isBatchPath returns whether the request targets the batch API.
+func isBatchPath(path string) bool {
+	path, _, _ = strings.Cut(path, "?")
+	return path == batchPath || strings.HasPrefix(path, batchPath+"/")
+}
+
+// getRequestMethod returns the :method pseudo header of the request.
+func getRequestMethod(headers []*configPb.HeaderValue) string {
+	for _, header := range headers {
+		if header.Key == ":method" {
+			return string(header.RawValue)
+		}
+	}
+	return ""
+}
+
+// HandleBatchHeaders serves the requests of the batch API which have no body: the retrieval of the status and of
+// the results of a batch, and its cancellation. It returns nil for the submission of a batch, which is served once
+// its body is received.
+func (s *Server) HandleBatchHeaders(ctx context.Context, requestID, method, path string, account *requestAccount) *extProcPb.ProcessingResponse {
+	path, _, _ = strings.Cut(path, "?")
+	if path == batchPath && method == http.MethodPost {
+		return nil
+	}
+	if errRes := authenticate(s, ctx, requestID, account); errRes != nil {
+		return errRes
+	}
+
+	id, action, _ := strings.Cut(strings.TrimPrefix(path, batchPath+"/"), "/")
+	switch {
+	case path == batchPath || id == "":
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_MethodNotAllowed, "batches are submitted with POST "+batchPath)
+	case method == http.MethodGet && action == "":
+		job, err := s.batches.get(ctx, id, account.user)
+		return generateBatchResponse(requestID, job, err)
+	case method == http.MethodGet && action == "results":
+		results, err := s.batches.results(ctx, id, account.user)
+		return generateBatchResponse(requestID, map[string]interface{}{"object": "list", "data": results}, err)
+	case method == http.MethodPost && action == "cancel":
+		job, err := s.batches.cancel(ctx, id, account.user)
+		return generateBatchResponse(requestID, job, err)
+	default:
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_NotFound, fmt.Sprintf("unknown batch endpoint %s %s", method, path))
+	}
+}
+
+// HandleBatchBody serves the submission of a batch, the batch executes in the background once it is persisted.
+func (s *Server) HandleBatchBody(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest, account *requestAccount, routingStrategy string) *extProcPb.ProcessingResponse {
+	if errRes := authenticate(s, ctx, requestID, account); errRes != nil {
+		return errRes
+	}
+	body := req.Request.(*extProcPb.ProcessingRequest_RequestBody)
+	submission, window, err := parseBatchSubmission(body.RequestBody.GetBody(), s.batches.limits)
+	if err != nil {
+		return generateBatchResponse(requestID, nil, err)
+	}
+	job, err := s.batches.submit(ctx, account.user, routingStrategy, submission, window)
+	if err == nil {
+		klog.InfoS("batch submitted", "requestID", requestID, "batch", job.ID, "user", job.User, "requests", job.RequestCounts.Total)
+	}
+	return generateBatchResponse(requestID, job, err)
+}
+
+// generateBatchResponse answers a request of the batch API with the JSON of the value, or with the error.
+func generateBatchResponse(requestID string, value interface{}, err error) *extProcPb.ProcessingResponse {
+	var validationErr *batchValidationError
+	switch {
+	case err == nil:
+	case errors.Is(err, errBatchNotFound):
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_NotFound, err.Error())
+	case errors.Is(err, errBatchTerminal):
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_Conflict, err.Error())
+	case errors.Is(err, errBatchShutdown):
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_ServiceUnavailable, err.Error())
+	case errors.Is(err, errBatchTooLarge):
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_PayloadTooLarge, err.Error())
+	case errors.As(err, &validationErr):
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_BadRequest, err.Error())
+	default:
+		klog.ErrorS(err, "batch request failed", "requestID", requestID)
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_InternalServerError, err.Error())
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		klog.ErrorS(err, "failed to marshal batch response", "requestID", requestID)
+		return generateBatchErrorResponse(envoyTypePb.StatusCode_InternalServerError, err.Error())
+	}
+	return &extProcPb.ProcessingResponse{
+		Response: &extProcPb.ProcessingResponse_ImmediateResponse{
+			ImmediateResponse: &extProcPb.ImmediateResponse{
+				Status: &envoyTypePb.HttpStatus{
+					Code: envoyTypePb.StatusCode_OK,
+				},
+				Headers: &extProcPb.HeaderMutation{
+					SetHeaders: []*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+						Key: "Content-Type", Value: "application/json"}}},
+				},
+				Body: string(data),
+			},
+		},
+	}
+}
+
+func generateBatchErrorResponse(statusCode envoyTypePb.StatusCode, message string) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(statusCode,
+		[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+			Key: HeaderErrorBatch, RawValue: []byte("true")}}},
+		message)
+}
@@ -0,0 +1,539 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/bodypatch"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/engineapi"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/tokenizer"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// batchStoreTimeout bounds a write of the state of a batch, which is also written once the batch is cancelled.
+	batchStoreTimeout = 5 * time.Second
+	// maxBatchResponseBytes bounds the response of a request of a batch kept as its result.
+	maxBatchResponseBytes = 10 << 20
+)
+
+var (
+	errBatchCancelled = errors.New("batch was cancelled")
+	errBatchExpired   = errors.New("batch did not complete within its completion window")
+	errBatchShutdown  = errors.New("gateway shut down before the batch completed")
+)
+
+// batchCache is the subset of the cache the batch runner reads to execute the requests of the batches.
+type batchCache interface {
+	tenantCache
+	admissionCache
+	endpointPortCache
+	CheckModelExists(modelName string) bool
+	RecordRouting(modelName, podName, algorithm string)
+	AddPodRequest(requestID, podName string)
+	DonePodRequest(requestID string, failed bool)
+}
+
+// batchRoute selects the target pod of a request among the pods of its model.
+type batchRoute func(ctx context.Context, routingStrategy routing.Algorithms, pods map[string]*v1.Pod, model, message, priority string) (string, error)
+
+// activeBatch is a batch executing on this gateway.
+type activeBatch struct {
+	mu     sync.Mutex
+	job    batchJob
+	cancel context.CancelCauseFunc
+}
+
+// batchRunner executes the batches in the background. The requests go through the routing pipeline of the
+// interactive requests at low priority: they wait in the admission queue of a saturated model instead of being
+// shed, and at most maxConcurrency of them are in flight across all the batches. A batch executes on the gateway
+// it was submitted to, it fails if the gateway shuts down before it completes.
+type batchRunner struct {
+	store           batchStore
+	cache           batchCache
+	policies        *policyCache
+	admission       *admissionController
+	admissionQueue  *admissionQueue
+	tenants         *tenantPools
+	adapterVersions *adapterVersionRouter
+	loraFallback    *loraFallback
+	engineAPIs      *engineAPIResolver
+	tokenizers      *tokenizer.Registry
+	timeouts        *timeoutResolver
+	accounting      *accountingPipeline
+	usage           *usageMeter
+	route           batchRoute
+	client          *http.Client
+	limits          batchLimits
+
+	// slots holds a token for every request in flight.
+	slots chan struct{}
+
+	mu      sync.Mutex
+	active  map[string]*activeBatch
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newBatchRunner(store batchStore, c batchCache, s *Server, limits batchLimits) *batchRunner {
+	return &batchRunner{
+		store:           store,
+		cache:           c,
+		policies:        s.policies,
+		admission:       s.admission,
+		admissionQueue:  s.admissionQueue,
+		tenants:         s.tenants,
+		adapterVersions: s.adapterVersions,
+		loraFallback:    s.loraFallback,
+		engineAPIs:      s.engineAPIs,
+		tokenizers:      s.tokenizers,
+		timeouts:        s.timeouts,
+		accounting:      s.accounting,
+		usage:           s.usage,
+		route:           s.selectTargetPod,
+		client:          &http.Client{},
+		limits:          limits,
+		slots:           make(chan struct{}, limits.maxConcurrency),
+		active:          map[string]*activeBatch{},
+	}
+}
+
+// submit persists a new batch of the user and starts its execution.
+func (r *batchRunner) submit(ctx context.Context, user utils.User, routingStrategy string, submission *batchSubmission, window time.Duration) (*batchJob, error) {
+	now := time.Now()
+	job := batchJob{
+		ID:               "batch_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Object:           "batch",
+		Endpoint:         submission.Endpoint,
+		CompletionWindow: submission.CompletionWindow,
+		Status:           batchStatusValidating,
+		RequestCounts:    batchRequestCounts{Total: len(submission.Requests)},
+		Metadata:         submission.Metadata,
+		CreatedAt:        now.Unix(),
+		ExpiresAt:        now.Add(window).Unix(),
+		User:             user.Name,
+	}
+	if routingStrategy == "" {
+		routingStrategy = string(routing.RouterRandom)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return nil, errBatchShutdown
+	}
+	// the batch and its results are kept for the result TTL after the end of its completion window at the latest.
+	if err := r.store.saveJob(ctx, &job, window+r.limits.resultTTL); err != nil {
+		return nil, fmt.Errorf("failed to persist batch: %w", err)
+	}
+
+	batchCtx, cancel := context.WithCancelCause(context.Background())
+	batchCtx, stop := context.WithDeadlineCause(batchCtx, now.Add(window), errBatchExpired)
+	batch := &activeBatch{job: job, cancel: cancel}
+	r.active[job.ID] = batch
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer stop()
+		r.run(batchCtx, batch, user, routingStrategy, submission.Requests)
+		r.mu.Lock()
+		delete(r.active, job.ID)
+		r.mu.Unlock()
+	}()
+	return &job, nil
+}
+
+// run validates the requests of the batch and executes them, then records the terminal state of the batch.
+func (r *batchRunner) run(ctx context.Context, batch *activeBatch, user utils.User, routingStrategy string, requests []batchRequest) {
+	if errs := r.validate(user, batch.job.Endpoint, requests); len(errs) > 0 {
+		r.update(batch, func(job *batchJob) {
+			job.Status = batchStatusFailed
+			job.Errors = errs
+			job.FailedAt = time.Now().Unix()
+		})
+		return
+	}
+	r.update(batch, func(job *batchJob) {
+		job.Status = batchStatusInProgress
+		job.InProgressAt = time.Now().Unix()
+	})
+
+	var wg sync.WaitGroup
+	for i := range requests {
+		if !r.acquire(ctx) || r.cancelledRemotely(ctx, batch) {
+			break
+		}
+		wg.Add(1)
+		go func(request batchRequest) {
+			defer wg.Done()
+			defer r.release()
+			r.record(batch, user, request.model, r.execute(ctx, batch.job.ID, batch.job.Endpoint, user, routingStrategy, request))
+		}(requests[i])
+	}
+	wg.Wait()
+
+	cause := context.Cause(ctx)
+	var finished batchJob
+	r.update(batch, func(job *batchJob) {
+		now := time.Now().Unix()
+		switch {
+		case job.Status == batchStatusCancelling || errors.Is(cause, errBatchCancelled):
+			job.Status = batchStatusCancelled
+			job.CancelledAt = now
+		case errors.Is(cause, errBatchExpired):
+			job.Status = batchStatusExpired
+			job.ExpiredAt = now
+		case errors.Is(cause, errBatchShutdown):
+			job.Status = batchStatusFailed
+			job.Errors = append(job.Errors, batchError{Code: "shutdown", Message: cause.Error()})
+			job.FailedAt = now
+		default:
+			job.Status = batchStatusCompleted
+			job.CompletedAt = now
+		}
+		finished = *job
+	})
+	klog.InfoS("batch finished", "batch", finished.ID, "user", user.Name, "status", finished.Status,
+		"completed", finished.RequestCounts.Completed, "failed", finished.RequestCounts.Failed)
+}
+
+// validate checks the models of the requests exist and the policy of the user allows the requests.
+func (r *batchRunner) validate(user utils.User, endpoint string, requests []batchRequest) []batchError {
+	var errs []batchError
+	policy := r.policies.get(user)
+	for i, request := range requests {
+		if !r.cache.CheckModelExists(request.model) {
+			errs = append(errs, batchError{Code: "model_not_found", Message: fmt.Sprintf("model %s does not exist", request.model), Line: i + 1})
+			continue
+		}
+		var jsonMap map[string]interface{}
+		if err := json.Unmarshal(request.Body, &jsonMap); err != nil {
+			errs = append(errs, batchError{Code: "invalid_request", Message: err.Error(), Line: i + 1})
+			continue
+		}
+		if violation := policy.check(request.model, endpoint, jsonMap); violation != nil {
+			errs = append(errs, batchError{Code: violation.rule, Message: violation.message, Line: i + 1})
+		}
+	}
+	return errs
+}
+
+// acquire waits for a slot to execute a request, it returns false if the batch stopped in the meantime.
+func (r *batchRunner) acquire(ctx context.Context) bool {
+	select {
+	case r.slots <- struct{}{}:
+		if ctx.Err() != nil {
+			r.release()
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *batchRunner) release() {
+	<-r.slots
+}
+
+// cancelledRemotely stops the batch if it was cancelled through another gateway, before its next request executes.
+func (r *batchRunner) cancelledRemotely(ctx context.Context, batch *activeBatch) bool {
+	if cancelled, err := r.store.cancelRequested(ctx, batch.job.ID); err != nil || !cancelled {
+		return false
+	}
+	r.update(batch, func(job *batchJob) {
+		job.Status = batchStatusCancelling
+		job.CancellingAt = time.Now().Unix()
+	})
+	batch.cancel(errBatchCancelled)
+	r.release()
+	return true
+}
+
+// execute sends a request of the batch to the pod the routing pipeline selects and returns its result.
+func (r *batchRunner) execute(ctx context.Context, id, endpoint string, user utils.User, routingStrategy string, request batchRequest) *batchResult {
+	requestID := uuid.New().String()
+	result := &batchResult{ID: requestID, CustomID: request.CustomID}
+	statusCode, body, err := r.dispatch(ctx, requestID, endpoint, user, routingStrategy, request)
+	if err != nil {
+		klog.ErrorS(err, "batch request failed", "batch", id, "requestID", requestID, "customID", request.CustomID, "model", request.model)
+		result.Error = &batchError{Code: "request_failed", Message: err.Error()}
+		return result
+	}
+	if !json.Valid(body) {
+		// keep the responses which are not JSON, e.g. an error page of a proxy, as a JSON string.
+		body, _ = json.Marshal(string(body))
+	}
+	result.Response = &batchResponse{StatusCode: statusCode, RequestID: requestID, Body: body}
+	return result
+}
+
+func (r *batchRunner) dispatch(ctx context.Context, requestID, endpoint string, user utils.User, routingStrategy string, request batchRequest) (int, []byte, error) {
+	model := request.model
+	if admitted, _ := r.admission.admit(r.cache, model, priorityLow); !admitted {
+		if err := r.admissionQueue.wait(ctx, r.admission, r.cache, requestID, model, priorityLow, time.Time{}); err != nil {
+			return 0, nil, fmt.Errorf("request was not admitted: %w", err)
+		}
+	}
+
+	// the requests of a batch are served like the interactive requests: by the new artifact of a model adapter
+	// rolling out, and by the pods of its base model while no ready pod loaded it.
+	servedModel := r.adapterVersions.pick(model)
+	pods, _, err := r.tenants.selectPods(r.cache, servedModel, user.Tenant)
+	if err != nil || len(utils.FilterReadyPods(pods)) == 0 {
+		if baseModel, ok := r.loraFallback.baseModel(requestID, model); ok {
+			pods, _, err = r.tenants.selectPods(r.cache, baseModel, user.Tenant)
+		}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(utils.FilterReadyPods(pods)) == 0 {
+		return 0, nil, fmt.Errorf("no ready pod available for model %s", model)
+	}
+	var jsonMap map[string]interface{}
+	if err := json.Unmarshal(request.Body, &jsonMap); err != nil {
+		return 0, nil, err
+	}
+	// the embeddings requests have no prompt, they are routed without one.
+	message, _ := getRequestMessage(jsonMap)
+	targetPodIP, err := r.route(ctx, routing.Algorithms(routingStrategy), pods, servedModel, message, priorityLow)
+	if err != nil || targetPodIP == "" {
+		return 0, nil, fmt.Errorf("failed to select target pod: %v", err)
+	}
+	targetPodIP = resolveTargetPort(r.cache, servedModel, pods, targetPodIP)
+
+	failed := true
+	pod := getServingPod(pods, targetPodIP)
+	if pod != nil {
+		r.cache.RecordRouting(servedModel, pod.Name, routingStrategy)
+		r.cache.AddPodRequest(requestID, pod.Name)
+		defer func() { r.cache.DonePodRequest(requestID, failed) }()
+	}
+
+	// a pod serving the native API of its engine gets the request translated, like an interactive request.
+	path, body := endpoint, request.Body
+	var responseTranslator engineapi.ResponseTranslator
+	if translator := r.engineAPIs.translator(pod); translator != nil {
+		req := engineapi.Request{
+			ID:           requestID,
+			Endpoint:     engineapi.Endpoint(endpoint),
+			Model:        servedModel,
+			PromptTokens: int64(r.tokenizers.ForModel(model).CountTokens(message)),
+			Body:         jsonMap,
+			Created:      time.Now(),
+		}
+		if path, body, err = translator.TranslateRequest(req); err != nil {
+			return 0, nil, fmt.Errorf("failed to translate request into the engine API: %w", err)
+		}
+		responseTranslator = translator.NewResponseTranslator(req)
+	} else if servedModel != model {
+		if body, err = bodypatch.Apply(body, bodypatch.Replace([]string{"model"}, servedModel)); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if timeout := r.timeouts.resolve(endpoint, model).MaxDuration; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+targetPodIP+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxBatchResponseBytes))
+	if err != nil {
+		return 0, nil, err
+	}
+	failed = isOverloadStatus(resp.StatusCode)
+	// the errors of the engine are kept as they are, like the errors of the interactive requests.
+	if responseTranslator != nil && resp.StatusCode == http.StatusOK {
+		if respBody, err = responseTranslator.TranslateBody(respBody); err != nil {
+			return 0, nil, fmt.Errorf("failed to translate response from the engine API: %w", err)
+		}
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// record persists the result of a request to the model and accounts its tokens to the user of the batch.
+func (r *batchRunner) record(batch *activeBatch, user utils.User, model string, result *batchResult) {
+	var usage batchUsage
+	succeeded := result.Response != nil && result.Response.StatusCode == http.StatusOK
+	if succeeded {
+		var body struct {
+			Model string     `json:"model"`
+			Usage batchUsage `json:"usage"`
+		}
+		if err := json.Unmarshal(result.Response.Body, &body); err == nil {
+			usage = body.Usage
+			if body.Model != "" {
+				model = body.Model
+			}
+		}
+	}
+	if user.Name != "" {
+		r.accounting.record(fmt.Sprintf("%v_RPM_CURRENT", user.Name), 1)
+		if usage.TotalTokens != 0 {
+			r.accounting.record(fmt.Sprintf("%v_TPM_CURRENT", user.Name), usage.TotalTokens)
+			r.usage.record(user.Name, model, usage.PromptTokens, usage.CompletionTokens, time.Now())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchStoreTimeout)
+	defer cancel()
+	if err := r.store.saveResult(ctx, batch.job.ID, result, r.activeTTL(batch)); err != nil {
+		klog.ErrorS(err, "failed to persist batch result", "batch", batch.job.ID, "customID", result.CustomID)
+	}
+	r.update(batch, func(job *batchJob) {
+		if succeeded {
+			job.RequestCounts.Completed++
+		} else {
+			job.RequestCounts.Failed++
+		}
+		job.Usage.PromptTokens += usage.PromptTokens
+		job.Usage.CompletionTokens += usage.CompletionTokens
+		job.Usage.TotalTokens += usage.TotalTokens
+	})
+}
+
+// activeTTL is the TTL of a batch until it finishes, long enough to outlive its completion window.
+func (r *batchRunner) activeTTL(batch *activeBatch) time.Duration {
+	return time.Until(time.Unix(batch.job.ExpiresAt, 0)) + r.limits.resultTTL
+}
+
+// update applies the change to the batch and persists it. The changes of a batch are persisted in order, and a
+// finished batch expires after the result TTL.
+func (r *batchRunner) update(batch *activeBatch, change func(job *batchJob)) {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+
+	change(&batch.job)
+	ttl := r.activeTTL(batch)
+	if batch.job.isTerminal() {
+		ttl = r.limits.resultTTL
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), batchStoreTimeout)
+	defer cancel()
+	if err := r.store.saveJob(ctx, &batch.job, ttl); err != nil {
+		klog.ErrorS(err, "failed to persist batch", "batch", batch.job.ID, "status", batch.job.Status)
+	}
+}
+
+// get returns the batch of the user.
+func (r *batchRunner) get(ctx context.Context, id string, user utils.User) (*batchJob, error) {
+	job, err := r.store.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// the batches of other users are not disclosed.
+	if job.User != user.Name {
+		return nil, errBatchNotFound
+	}
+	return job, nil
+}
+
+// results returns the results of the requests of the batch of the user executed so far, ordered by custom_id.
+func (r *batchRunner) results(ctx context.Context, id string, user utils.User) ([]*batchResult, error) {
+	if _, err := r.get(ctx, id, user); err != nil {
+		return nil, err
+	}
+	return r.store.getResults(ctx, id)
+}
+
+// cancel stops the execution of the batch of the user, the requests in flight are aborted.
+func (r *batchRunner) cancel(ctx context.Context, id string, user utils.User) (*batchJob, error) {
+	job, err := r.get(ctx, id, user)
+	if err != nil {
+		return nil, err
+	}
+	if job.isTerminal() {
+		return nil, fmt.Errorf("%w: batch %s is %s", errBatchTerminal, id, job.Status)
+	}
+
+	r.mu.Lock()
+	batch, ok := r.active[id]
+	r.mu.Unlock()
+	if !ok {
+		// the batch executes on another gateway, which stops it before its next request.
+		ttl := time.Until(time.Unix(job.ExpiresAt, 0)) + r.limits.resultTTL
+		if err := r.store.requestCancel(ctx, id, ttl); err != nil {
+			return nil, err
+		}
+		job.Status = batchStatusCancelling
+		job.CancellingAt = time.Now().Unix()
+		if err := r.store.saveJob(ctx, job, ttl); err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+
+	var cancelling batchJob
+	r.update(batch, func(job *batchJob) {
+		if !job.isTerminal() {
+			job.Status = batchStatusCancelling
+			job.CancellingAt = time.Now().Unix()
+		}
+		cancelling = *job
+	})
+	batch.cancel(errBatchCancelled)
+	return &cancelling, nil
+}
+
+// shutdown fails the batches executing on the gateway, and waits for them to record their state within the
+// deadline of ctx.
+func (r *batchRunner) shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.stopped = true
+	for _, batch := range r.active {
+		batch.cancel(errBatchShutdown)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,570 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/tokenizer"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// fakeBatchStore keeps the batches in memory, with the TTL they were last saved with.
+type fakeBatchStore struct {
+	mu      sync.Mutex
+	jobs    map[string]batchJob
+	ttls    map[string]time.Duration
+	results map[string]map[string]batchResult
+	cancels map[string]bool
+}
+
+func newFakeBatchStore() *fakeBatchStore {
+	return &fakeBatchStore{
+		jobs:    map[string]batchJob{},
+		ttls:    map[string]time.Duration{},
+		results: map[string]map[string]batchResult{},
+		cancels: map[string]bool{},
+	}
+}
+
+func (f *fakeBatchStore) saveJob(ctx context.Context, job *batchJob, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs[job.ID] = *job
+	f.ttls[job.ID] = ttl
+	return nil
+}
+
+func (f *fakeBatchStore) getJob(ctx context.Context, id string) (*batchJob, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[id]
+	if !ok {
+		return nil, errBatchNotFound
+	}
+	return &job, nil
+}
+
+func (f *fakeBatchStore) saveResult(ctx context.Context, id string, result *batchResult, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.results[id] == nil {
+		f.results[id] = map[string]batchResult{}
+	}
+	f.results[id][result.CustomID] = *result
+	return nil
+}
+
+func (f *fakeBatchStore) getResults(ctx context.Context, id string) ([]*batchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	results := []*batchResult{}
+	for _, result := range f.results[id] {
+		result := result
+		results = append(results, &result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CustomID < results[j].CustomID
+	})
+	return results, nil
+}
+
+func (f *fakeBatchStore) requestCancel(ctx context.Context, id string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancels[id] = true
+	return nil
+}
+
+func (f *fakeBatchStore) cancelRequested(ctx context.Context, id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cancels[id], nil
+}
+
+func (f *fakeBatchStore) job(id string) (batchJob, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.jobs[id], f.ttls[id]
+}
+
+// fakeBatchCache serves the model llama from a single pod, the model server of the upstream.
+type fakeBatchCache struct {
+	pod *v1.Pod
+
+	mu       sync.Mutex
+	inFlight map[string]string
+	routed   int
+}
+
+func newFakeBatchCache(t *testing.T, upstream *httptest.Server) *fakeBatchCache {
+	host, port, err := net.SplitHostPort(upstream.Listener.Addr().String())
+	assert.NoError(t, err)
+	return &fakeBatchCache{
+		pod: &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "llama-a", Labels: map[string]string{utils.PodPortIdentifier: port}},
+			Status: v1.PodStatus{
+				Phase:      v1.PodRunning,
+				PodIP:      host,
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		},
+		inFlight: map[string]string{},
+	}
+}
+
+func (c *fakeBatchCache) CheckModelExists(modelName string) bool {
+	return modelName == "llama"
+}
+
+func (c *fakeBatchCache) GetPodsForModel(modelName string) (map[string]*v1.Pod, error) {
+	if modelName != "llama" {
+		return nil, fmt.Errorf("%w: %s", cache.ErrModelNotFound, modelName)
+	}
+	return map[string]*v1.Pod{c.pod.Name: c.pod}, nil
+}
+
+func (c *fakeBatchCache) GetTenantPodsForModel(modelName, tenant string) (cache.TenantPods, error) {
+	pods, err := c.GetPodsForModel(modelName)
+	return cache.TenantPods{Shared: pods}, err
+}
+
+func (c *fakeBatchCache) GetPodModelMetric(podName, modelName string, metricName string) (metrics.MetricValue, error) {
+	return &metrics.SimpleMetricValue{Value: 0}, nil
+}
+
+func (c *fakeBatchCache) GetModelAutoscalerState(modelName string) (cache.ModelAutoscalerState, bool) {
+	return cache.ModelAutoscalerState{}, false
+}
+
+func (c *fakeBatchCache) EstimateRetryAfter(modelName string, now time.Time) (time.Duration, bool) {
+	return 0, false
+}
+
+func (c *fakeBatchCache) GetEndpointPort(modelName, podName string) (int32, bool) {
+	return 0, false
+}
+
+func (c *fakeBatchCache) RecordRouting(modelName, podName, algorithm string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routed++
+}
+
+func (c *fakeBatchCache) AddPodRequest(requestID, podName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight[requestID] = podName
+}
+
+func (c *fakeBatchCache) DonePodRequest(requestID string, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inFlight, requestID)
+}
+
+// fakeBatchUpstream is a model server answering the chat completions with 5 tokens of usage, and the prompts
+// asking for it with an error. It tracks the most requests it served at once.
+type fakeBatchUpstream struct {
+	*httptest.Server
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+	// block holds the requests until it is closed, or the request is aborted.
+	block chan struct{}
+}
+
+func newFakeBatchUpstream(block bool) *fakeBatchUpstream {
+	u := &fakeBatchUpstream{}
+	if block {
+		u.block = make(chan struct{})
+	}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server notices the request is aborted once its body is read.
+		body, _ := io.ReadAll(r.Body)
+		inFlight := u.inFlight.Add(1)
+		defer u.inFlight.Add(-1)
+		for {
+			peak := u.maxInFlight.Load()
+			if inFlight <= peak || u.maxInFlight.CompareAndSwap(peak, inFlight) {
+				break
+			}
+		}
+		if u.block != nil {
+			select {
+			case <-u.block:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(string(body), "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error": {"message": "engine error"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id": "chatcmpl-1", "choices": [], "usage": {"prompt_tokens": 3, "completion_tokens": 2, "total_tokens": 5}}`)
+	}))
+	return u
+}
+
+func newTestBatchServer(t *testing.T, upstream *fakeBatchUpstream, limits batchLimits) (*Server, *fakeBatchStore, *fakeBatchCache) {
+	store := newFakeBatchStore()
+	c := newFakeBatchCache(t, upstream.Server)
+	s := &Server{
+		policies:   newPolicyCache(),
+		timeouts:   newTimeoutResolverFromEnv(),
+		accounting: newAccountingPipeline(newFakeBatchRateLimiter(0), 100, 100, time.Hour),
+		usage:      newUsageMeter(nil, 100, time.Hour),
+	}
+	s.batches = newBatchRunner(store, c, s, limits)
+	return s, store, c
+}
+
+func newTestBatchLimits(maxConcurrency int) batchLimits {
+	return batchLimits{maxRequests: 10, maxBodyBytes: 1 << 20, maxConcurrency: maxConcurrency, resultTTL: time.Hour}
+}
+
+func newBatchSubmissionBody(prompts ...string) []byte {
+	requests := make([]map[string]interface{}, 0, len(prompts))
+	for i, prompt := range prompts {
+		requests = append(requests, map[string]interface{}{
+			"custom_id": fmt.Sprintf("request-%d", i+1),
+			"body": map[string]interface{}{
+				"model":    "llama",
+				"messages": []map[string]string{{"role": "user", "content": prompt}},
+			},
+		})
+	}
+	body, _ := json.Marshal(map[string]interface{}{"endpoint": "/v1/chat/completions", "requests": requests})
+	return body
+}
+
+func newBatchBodyRequest(body []byte) *extProcPb.ProcessingRequest {
+	return &extProcPb.ProcessingRequest{
+		Request: &extProcPb.ProcessingRequest_RequestBody{
+			RequestBody: &extProcPb.HttpBody{Body: body},
+		},
+	}
+}
+
+// decodeBatchResponse returns the status code of the immediate response of the batch API, and decodes its body.
+func decodeBatchResponse(t *testing.T, resp *extProcPb.ProcessingResponse, value interface{}) envoyTypePb.StatusCode {
+	t.Helper()
+	immediate := resp.GetImmediateResponse()
+	if !assert.NotNil(t, immediate) {
+		return 0
+	}
+	if value != nil {
+		assert.NoError(t, json.Unmarshal([]byte(immediate.Body), value))
+	}
+	return immediate.Status.Code
+}
+
+func waitForBatchStatus(t *testing.T, store *fakeBatchStore, id, status string) batchJob {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		job, _ := store.job(id)
+		return job.Status == status
+	}, 5*time.Second, 5*time.Millisecond)
+	job, _ := store.job(id)
+	return job
+}
+
+func TestBatchEndToEnd(t *testing.T) {
+	upstream := newFakeBatchUpstream(false)
+	defer upstream.Close()
+	s, store, c := newTestBatchServer(t, upstream, newTestBatchLimits(2))
+	ctx := context.Background()
+	account := &requestAccount{}
+
+	var job batchJob
+	resp := s.HandleBatchBody(ctx, "r1", newBatchBodyRequest(newBatchSubmissionBody("a", "b", "fail", "c", "d")), account, "")
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &job))
+	assert.Equal(t, batchStatusValidating, job.Status)
+	assert.Equal(t, 5, job.RequestCounts.Total)
+
+	finished := waitForBatchStatus(t, store, job.ID, batchStatusCompleted)
+	assert.Equal(t, batchRequestCounts{Total: 5, Completed: 4, Failed: 1}, finished.RequestCounts)
+	assert.Equal(t, batchUsage{PromptTokens: 12, CompletionTokens: 8, TotalTokens: 20}, finished.Usage)
+	assert.NotZero(t, finished.InProgressAt)
+	assert.NotZero(t, finished.CompletedAt)
+	assert.LessOrEqual(t, upstream.maxInFlight.Load(), int32(2))
+	assert.Equal(t, 5, c.routed)
+	assert.Empty(t, c.inFlight)
+	// a finished batch expires after the result TTL.
+	_, ttl := store.job(job.ID)
+	assert.Equal(t, time.Hour, ttl)
+
+	var status batchJob
+	resp = s.HandleBatchHeaders(ctx, "r2", http.MethodGet, batchPath+"/"+job.ID, account)
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &status))
+	assert.Equal(t, batchStatusCompleted, status.Status)
+
+	var results struct {
+		Data []batchResult `json:"data"`
+	}
+	resp = s.HandleBatchHeaders(ctx, "r3", http.MethodGet, batchPath+"/"+job.ID+"/results", account)
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &results))
+	if assert.Len(t, results.Data, 5) {
+		assert.Equal(t, "request-1", results.Data[0].CustomID)
+		assert.Equal(t, http.StatusOK, results.Data[0].Response.StatusCode)
+		assert.Contains(t, string(results.Data[0].Response.Body), "chatcmpl-1")
+		assert.Equal(t, "request-3", results.Data[2].CustomID)
+		assert.Equal(t, http.StatusInternalServerError, results.Data[2].Response.StatusCode)
+	}
+
+	// the submission of the batch is served with its body.
+	assert.Nil(t, s.HandleBatchHeaders(ctx, "r4", http.MethodPost, batchPath, account))
+	resp = s.HandleBatchHeaders(ctx, "r5", http.MethodGet, batchPath+"/batch_unknown", account)
+	assert.Equal(t, envoyTypePb.StatusCode_NotFound, decodeBatchResponse(t, resp, nil))
+	resp = s.HandleBatchHeaders(ctx, "r6", http.MethodPost, batchPath+"/"+job.ID+"/cancel", account)
+	assert.Equal(t, envoyTypePb.StatusCode_Conflict, decodeBatchResponse(t, resp, nil))
+}
+
+func TestBatchAccounting(t *testing.T) {
+	upstream := newFakeBatchUpstream(false)
+	defer upstream.Close()
+	s, store, _ := newTestBatchServer(t, upstream, newTestBatchLimits(2))
+	ctx := context.Background()
+
+	submission, window, err := parseBatchSubmission(newBatchSubmissionBody("a", "b"), s.batches.limits)
+	assert.NoError(t, err)
+	job, err := s.batches.submit(ctx, utils.User{Name: "alice"}, "", submission, window)
+	assert.NoError(t, err)
+	waitForBatchStatus(t, store, job.ID, batchStatusCompleted)
+
+	counters := map[string]int64{}
+	for _, record := range s.accounting.take() {
+		counters[record.Key] += record.Val
+	}
+	assert.Equal(t, map[string]int64{"alice_RPM_CURRENT": 2, "alice_TPM_CURRENT": 10}, counters)
+	// the usage is metered to the model of the requests, the responses do not name one.
+	assert.Len(t, s.usage.pending, 1)
+	for key, record := range s.usage.pending {
+		assert.Equal(t, "alice", key.user)
+		assert.Equal(t, utils.UsageRecord{User: "alice", Model: "llama", PromptTokens: 6, CompletionTokens: 4, Requests: 2, At: key.hour}, *record)
+	}
+
+	// the batches of a user are not disclosed to the other users.
+	_, err = s.batches.get(ctx, job.ID, utils.User{Name: "bob"})
+	assert.ErrorIs(t, err, errBatchNotFound)
+	_, err = s.batches.results(ctx, job.ID, utils.User{})
+	assert.ErrorIs(t, err, errBatchNotFound)
+	_, err = s.batches.cancel(ctx, job.ID, utils.User{Name: "bob"})
+	assert.ErrorIs(t, err, errBatchNotFound)
+}
+
+func TestBatchEngineAPI(t *testing.T) {
+	// a TGI pod serves the requests of the batch on its native API.
+	var path atomic.Value
+	upstream := &fakeBatchUpstream{Server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path.Store(r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"generated_text":" Hi","details":{"finish_reason":"eos_token","generated_tokens":2,"seed":null,"prefill":[{"id":1,"text":"<s>"},{"id":6324,"text":"Hi"}],"tokens":[]}}`)
+	}))}
+	defer upstream.Close()
+	s, store, c := newTestBatchServer(t, upstream, newTestBatchLimits(1))
+	c.pod.Labels[engineLabel] = "tgi"
+	engines, err := parseEngineAPIs(`{"tgi": "tgi"}`)
+	assert.NoError(t, err)
+	s.batches.engineAPIs = &engineAPIResolver{engines: engines}
+	s.batches.tokenizers = tokenizer.NewRegistry(nil, 0)
+
+	var job batchJob
+	resp := s.HandleBatchBody(context.Background(), "r1", newBatchBodyRequest(newBatchSubmissionBody("Hi")), &requestAccount{}, "")
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &job))
+	finished := waitForBatchStatus(t, store, job.ID, batchStatusCompleted)
+	assert.Equal(t, batchRequestCounts{Total: 1, Completed: 1}, finished.RequestCounts)
+	assert.Equal(t, batchUsage{PromptTokens: 2, CompletionTokens: 2, TotalTokens: 4}, finished.Usage)
+	assert.Equal(t, "/generate", path.Load())
+
+	// the result is the OpenAI response translated from the one of TGI.
+	results, err := store.getResults(context.Background(), job.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, http.StatusOK, results[0].Response.StatusCode)
+		assert.Contains(t, string(results[0].Response.Body), `"object":"chat.completion"`)
+		assert.Contains(t, string(results[0].Response.Body), `"content":" Hi"`)
+	}
+}
+
+func TestBatchValidation(t *testing.T) {
+	limits := newTestBatchLimits(1)
+	limits.maxRequests = 2
+	testCases := []struct {
+		name     string
+		body     string
+		expected envoyTypePb.StatusCode
+	}{
+		{
+			name:     "malformed",
+			body:     `{"endpoint": `,
+			expected: envoyTypePb.StatusCode_BadRequest,
+		},
+		{
+			name:     "unsupported endpoint",
+			body:     `{"endpoint": "/v1/images", "requests": [{"custom_id": "1", "body": {"model": "llama"}}]}`,
+			expected: envoyTypePb.StatusCode_BadRequest,
+		},
+		{
+			name:     "no requests",
+			body:     `{"endpoint": "/v1/completions", "requests": []}`,
+			expected: envoyTypePb.StatusCode_BadRequest,
+		},
+		{
+			name:     "too many requests",
+			body:     string(newBatchSubmissionBody("a", "b", "c")),
+			expected: envoyTypePb.StatusCode_BadRequest,
+		},
+		{
+			name:     "duplicated custom_id",
+			body:     `{"endpoint": "/v1/completions", "requests": [{"custom_id": "1", "body": {"model": "llama"}}, {"custom_id": "1", "body": {"model": "llama"}}]}`,
+			expected: envoyTypePb.StatusCode_BadRequest,
+		},
+		{
+			name:     "no model",
+			body:     `{"endpoint": "/v1/completions", "requests": [{"custom_id": "1", "body": {"prompt": "a"}}]}`,
+			expected: envoyTypePb.StatusCode_BadRequest,
+		},
+		{
+			name:     "streaming",
+			body:     `{"endpoint": "/v1/completions", "requests": [{"custom_id": "1", "body": {"model": "llama", "stream": true}}]}`,
+			expected: envoyTypePb.StatusCode_BadRequest,
+		},
+		{
+			name:     "completion window too long",
+			body:     `{"endpoint": "/v1/completions", "completion_window": "48h", "requests": [{"custom_id": "1", "body": {"model": "llama"}}]}`,
+			expected: envoyTypePb.StatusCode_BadRequest,
+		},
+		{
+			name:     "too large",
+			body:     `{"endpoint": "/v1/completions", "metadata": {"note": "` + strings.Repeat("a", limits.maxBodyBytes) + `"}}`,
+			expected: envoyTypePb.StatusCode_PayloadTooLarge,
+		},
+	}
+
+	upstream := newFakeBatchUpstream(false)
+	defer upstream.Close()
+	s, store, _ := newTestBatchServer(t, upstream, limits)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := s.HandleBatchBody(context.Background(), "r1", newBatchBodyRequest([]byte(tc.body)), &requestAccount{}, "")
+			assert.Equal(t, tc.expected, decodeBatchResponse(t, resp, nil))
+			assert.Equal(t, HeaderErrorBatch, resp.GetImmediateResponse().Headers.SetHeaders[0].Header.Key)
+		})
+	}
+
+	// the models are validated asynchronously, a batch with an unknown model fails.
+	var job batchJob
+	body := `{"endpoint": "/v1/completions", "requests": [{"custom_id": "1", "body": {"model": "llama", "prompt": "a"}}, {"custom_id": "2", "body": {"model": "mistral", "prompt": "a"}}]}`
+	resp := s.HandleBatchBody(context.Background(), "r2", newBatchBodyRequest([]byte(body)), &requestAccount{}, "")
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &job))
+	failed := waitForBatchStatus(t, store, job.ID, batchStatusFailed)
+	assert.Equal(t, []batchError{{Code: "model_not_found", Message: "model mistral does not exist", Line: 2}}, failed.Errors)
+	assert.Zero(t, upstream.maxInFlight.Load())
+}
+
+func TestBatchCancel(t *testing.T) {
+	ctx := context.Background()
+	account := &requestAccount{}
+
+	// the batch is cancelled on the gateway executing it, the request in flight is aborted.
+	upstream := newFakeBatchUpstream(true)
+	defer upstream.Close()
+	s, store, c := newTestBatchServer(t, upstream, newTestBatchLimits(1))
+	var job batchJob
+	resp := s.HandleBatchBody(ctx, "r1", newBatchBodyRequest(newBatchSubmissionBody("a", "b", "c")), account, "")
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &job))
+	assert.Eventually(t, func() bool { return upstream.inFlight.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	var cancelling batchJob
+	resp = s.HandleBatchHeaders(ctx, "r2", http.MethodPost, batchPath+"/"+job.ID+"/cancel", account)
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &cancelling))
+	assert.Equal(t, batchStatusCancelling, cancelling.Status)
+	cancelled := waitForBatchStatus(t, store, job.ID, batchStatusCancelled)
+	assert.Equal(t, batchRequestCounts{Total: 3, Failed: 1}, cancelled.RequestCounts)
+	assert.NotZero(t, cancelled.CancelledAt)
+	assert.Empty(t, c.inFlight)
+
+	// the batch is cancelled through another gateway, the gateway executing it stops before the next request.
+	upstream = newFakeBatchUpstream(true)
+	defer upstream.Close()
+	s, store, _ = newTestBatchServer(t, upstream, newTestBatchLimits(1))
+	resp = s.HandleBatchBody(ctx, "r3", newBatchBodyRequest(newBatchSubmissionBody("a", "b", "c")), account, "")
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &job))
+	assert.Eventually(t, func() bool { return upstream.inFlight.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+	other := &batchRunner{store: store, limits: s.batches.limits, active: map[string]*activeBatch{}}
+	cancelling2, err := other.cancel(ctx, job.ID, utils.User{})
+	assert.NoError(t, err)
+	assert.Equal(t, batchStatusCancelling, cancelling2.Status)
+	close(upstream.block)
+	cancelled = waitForBatchStatus(t, store, job.ID, batchStatusCancelled)
+	assert.Equal(t, batchRequestCounts{Total: 3, Completed: 1}, cancelled.RequestCounts)
+}
+
+func TestBatchShutdown(t *testing.T) {
+	upstream := newFakeBatchUpstream(true)
+	defer upstream.Close()
+	s, store, _ := newTestBatchServer(t, upstream, newTestBatchLimits(1))
+	ctx := context.Background()
+
+	var job batchJob
+	resp := s.HandleBatchBody(ctx, "r1", newBatchBodyRequest(newBatchSubmissionBody("a", "b")), &requestAccount{}, "")
+	assert.Equal(t, envoyTypePb.StatusCode_OK, decodeBatchResponse(t, resp, &job))
+	assert.Eventually(t, func() bool { return upstream.inFlight.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, s.batches.shutdown(ctx))
+	failed, _ := store.job(job.ID)
+	assert.Equal(t, batchStatusFailed, failed.Status)
+	assert.Equal(t, "shutdown", failed.Errors[0].Code)
+
+	// no batch is accepted once the gateway shuts down.
+	resp = s.HandleBatchBody(ctx, "r2", newBatchBodyRequest(newBatchSubmissionBody("a")), &requestAccount{}, "")
+	assert.Equal(t, envoyTypePb.StatusCode_ServiceUnavailable, decodeBatchResponse(t, resp, nil))
+}
+
+func TestIsBatchPath(t *testing.T) {
+	assert.True(t, isBatchPath("/v1/batches"))
+	assert.True(t, isBatchPath("/v1/batches?limit=10"))
+	assert.True(t, isBatchPath("/v1/batches/batch_1/results"))
+	assert.False(t, isBatchPath("/v1/batchesx"))
+	assert.False(t, isBatchPath("/v1/chat/completions"))
+	assert.Equal(t, http.MethodGet, getRequestMethod([]*configPb.HeaderValue{{Key: ":method", RawValue: []byte("GET")}}))
+}
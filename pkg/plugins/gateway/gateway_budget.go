@@ -0,0 +1,237 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// engineLabel and engineVersionLabel identify the inference engine serving the model in a pod, e.g. "vllm" and "0.6.4".
+	engineLabel        = "model.aibrix.ai/engine"
+	engineVersionLabel = "model.aibrix.ai/engine-version"
+)
+
+// requestBudget is the time the client is willing to wait for a request, measured from its arrival at the gateway.
+type requestBudget struct {
+	arrival time.Time
+	// timeout is zero if the client did not set a deadline.
+	timeout time.Duration
+}
+
+// getRequestBudget reads the client deadline of the request from the x-request-timeout-ms header.
+func getRequestBudget(headers []*configPb.HeaderValue, arrival time.Time) requestBudget {
+	budget := requestBudget{arrival: arrival}
+	for _, header := range headers {
+		if strings.ToLower(header.Key) != HeaderRequestTimeout {
+			continue
+		}
+		value := string(header.RawValue)
+		timeoutMs, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || timeoutMs <= 0 {
+			klog.InfoS("ignoring invalid request timeout", "value", value)
+			return budget
+		}
+		budget.timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+	return budget
+}
+
+// remaining returns the budget left at now, and false if the client did not set a deadline. The time spent in
+// the gateway never counts as negative, e.g. when the wall clock stepped back between arrival and now.
+func (b requestBudget) remaining(now time.Time) (time.Duration, bool) {
+	if b.timeout <= 0 {
+		return 0, false
+	}
+	elapsed := now.Sub(b.arrival)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return b.timeout - elapsed, true
+}
+
+// engineHints describes the request fields an inference engine reads scheduling hints from.
+type engineHints struct {
+	// MinVersion is the first engine version supporting the fields, pods of older or unlabeled versions get no hints.
+	MinVersion string `json:"minVersion,omitempty"`
+	// PriorityField receives the value of Priorities for the priority class of the request.
+	PriorityField string         `json:"priorityField,omitempty"`
+	Priorities    map[string]int `json:"priorities,omitempty"`
+	// TimeoutField receives the remaining budget of the request in seconds.
+	TimeoutField string `json:"timeoutField,omitempty"`
+}
+
+// engineHintResolver computes the scheduling hints of a request per engine.
+type engineHintResolver struct {
+	engines map[string]engineHints
+}
+
+// newEngineHintResolverFromEnv reads the hints per engine from AIBRIX_GATEWAY_ENGINE_HINTS, e.g.
+// {"vllm": {"minVersion": "0.6.3", "priorityField": "priority", "priorities": {"high": 0, "low": 1}}}.
+// No hints are injected unless configured, since engines reject fields they are not configured for.
+func newEngineHintResolverFromEnv() *engineHintResolver {
+	r := &engineHintResolver{engines: map[string]engineHints{}}
+	value, exists := utils.CheckEnvExists(EnvEngineHints)
+	if !exists {
+		return r
+	}
+	engines, err := parseEngineHints(value)
+	if err != nil {
+		klog.ErrorS(err, "invalid engine hints, ignoring", "env", EnvEngineHints)
+		return r
+	}
+	r.engines = engines
+	return r
+}
+
+func parseEngineHints(value string) (map[string]engineHints, error) {
+	var engines map[string]engineHints
+	if err := json.Unmarshal([]byte(value), &engines); err != nil {
+		return nil, err
+	}
+	for engine, hints := range engines {
+		if hints.MinVersion != "" {
+			if _, err := parseVersion(hints.MinVersion); err != nil {
+				return nil, fmt.Errorf("engine %s: %v", engine, err)
+			}
+		}
+		if hints.PriorityField != "" && len(hints.Priorities) == 0 {
+			return nil, fmt.Errorf("engine %s: priority field %s without priorities", engine, hints.PriorityField)
+		}
+	}
+	return engines, nil
+}
+
+// fields returns the hints to inject into a request served by the pod, nil if the engine of the pod is not
+// configured or its version does not support them.
+func (r *engineHintResolver) fields(pod *v1.Pod, priority string, budget requestBudget, now time.Time) map[string]interface{} {
+	if r == nil || pod == nil {
+		return nil
+	}
+	hints, ok := r.engines[pod.Labels[engineLabel]]
+	if !ok {
+		return nil
+	}
+	if hints.MinVersion != "" {
+		version, err := parseVersion(pod.Labels[engineVersionLabel])
+		if err != nil || compareVersions(version, hints.MinVersion) < 0 {
+			return nil
+		}
+	}
+
+	fields := map[string]interface{}{}
+	if hints.PriorityField != "" {
+		if value, ok := hints.Priorities[priority]; ok {
+			fields[hints.PriorityField] = value
+		}
+	}
+	if remaining, ok := budget.remaining(now); ok && hints.TimeoutField != "" {
+		fields[hints.TimeoutField] = remaining.Seconds()
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// parseVersion validates a version like "0.6.4" or "v0.7.0rc1" and returns it without the "v" prefix.
+func parseVersion(version string) (string, error) {
+	version = strings.TrimPrefix(version, "v")
+	if version == "" || version[0] < '0' || version[0] > '9' {
+		return "", fmt.Errorf("invalid version %q", version)
+	}
+	return version, nil
+}
+
+// compareVersions compares the numeric major, minor and patch components of two versions, suffixes like "rc1"
+// are ignored.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < 3; i++ {
+		av, bv := versionComponent(as, i), versionComponent(bs, i)
+		if av != bv {
+			if av < bv {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionComponent(components []string, i int) int {
+	if i >= len(components) {
+		return 0
+	}
+	digits := components[i]
+	for j, c := range digits {
+		if c < '0' || c > '9' {
+			digits = digits[:j]
+			break
+		}
+	}
+	value, _ := strconv.Atoi(digits)
+	return value
+}
+
+// getServingPod returns the pod serving the request, the target pod if the request was routed and otherwise any
+// pod of the model as long as all of them run the same engine version.
+func getServingPod(pods map[string]*v1.Pod, targetPodIP string) *v1.Pod {
+	if targetPodIP != "" {
+		host, _, err := net.SplitHostPort(targetPodIP)
+		if err != nil {
+			host = targetPodIP
+		}
+		for _, pod := range pods {
+			if pod.Status.PodIP == host {
+				return pod
+			}
+		}
+		return nil
+	}
+
+	var serving *v1.Pod
+	for _, pod := range pods {
+		if serving != nil && (pod.Labels[engineLabel] != serving.Labels[engineLabel] ||
+			pod.Labels[engineVersionLabel] != serving.Labels[engineVersionLabel]) {
+			return nil
+		}
+		serving = pod
+	}
+	return serving
+}
+
+// generateDeadlineExceededResponse rejects a request whose client deadline passed before it reached an engine.
+func generateDeadlineExceededResponse(remaining time.Duration) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(envoyTypePb.StatusCode_GatewayTimeout,
+		[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+			Key: HeaderErrorDeadlineExceeded, RawValue: []byte("true")}}},
+		fmt.Sprintf("request deadline exceeded by %v before reaching the engine", -remaining))
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newEnginePod(ip, engine, version string) *v1.Pod {
+	labels := map[string]string{}
+	if engine != "" {
+		labels[engineLabel] = engine
+	}
+	if version != "" {
+		labels[engineVersionLabel] = version
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-" + ip, Labels: labels},
+		Status:     v1.PodStatus{PodIP: ip},
+	}
+}
+
+func TestRequestBudget(t *testing.T) {
+	arrival := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeoutHeader := func(value string) []*configPb.HeaderValue {
+		return []*configPb.HeaderValue{{Key: "X-Request-Timeout-Ms", RawValue: []byte(value)}}
+	}
+
+	budget := getRequestBudget(timeoutHeader("2500"), arrival)
+	remaining, ok := budget.remaining(arrival.Add(1 * time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, remaining)
+	remaining, _ = budget.remaining(arrival.Add(3 * time.Second))
+	assert.Equal(t, -500*time.Millisecond, remaining, "the deadline passed while the request was in the gateway")
+
+	// the wall clock stepped back between arrival and now, the gateway time never adds budget.
+	remaining, _ = budget.remaining(arrival.Add(-time.Minute))
+	assert.Equal(t, 2500*time.Millisecond, remaining)
+	// the monotonic clock reading of time.Now is used for the elapsed time.
+	now := time.Now()
+	remaining, _ = getRequestBudget(timeoutHeader("1000"), now).remaining(now.Add(250 * time.Millisecond))
+	assert.Equal(t, 750*time.Millisecond, remaining)
+
+	for _, headers := range [][]*configPb.HeaderValue{nil, timeoutHeader("0"), timeoutHeader("-10"), timeoutHeader("1s")} {
+		_, ok := getRequestBudget(headers, arrival).remaining(arrival)
+		assert.False(t, ok, "%v", headers)
+	}
+}
+
+func TestEngineHints(t *testing.T) {
+	engines, err := parseEngineHints(`{
+		"vllm": {"minVersion": "0.6.3", "priorityField": "priority", "priorities": {"high": 0, "low": 10}, "timeoutField": "timeout"},
+		"sglang": {"timeoutField": "request_timeout"}
+	}`)
+	assert.NoError(t, err)
+	r := &engineHintResolver{engines: engines}
+	arrival := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	budget := requestBudget{arrival: arrival, timeout: 30 * time.Second}
+	now := arrival.Add(5 * time.Second)
+
+	assert.Equal(t, map[string]interface{}{"priority": 10, "timeout": 25.0},
+		r.fields(newEnginePod("10.0.0.1", "vllm", "0.6.4"), priorityLow, budget, now))
+	assert.Equal(t, map[string]interface{}{"priority": 0},
+		r.fields(newEnginePod("10.0.0.1", "vllm", "v0.7.0rc1"), priorityHigh, requestBudget{}, now), "no deadline, only the priority")
+	assert.Equal(t, map[string]interface{}{"request_timeout": 25.0},
+		r.fields(newEnginePod("10.0.0.1", "sglang", ""), priorityLow, budget, now), "the field names are per engine")
+
+	assert.Nil(t, r.fields(newEnginePod("10.0.0.1", "vllm", "0.5.5"), priorityLow, budget, now), "unsupported version")
+	assert.Nil(t, r.fields(newEnginePod("10.0.0.1", "vllm", ""), priorityLow, budget, now), "unknown version")
+	assert.Nil(t, r.fields(newEnginePod("10.0.0.1", "", ""), priorityLow, budget, now), "unknown engine")
+	assert.Nil(t, r.fields(newEnginePod("10.0.0.1", "sglang", ""), priorityLow, requestBudget{}, now), "nothing to inject")
+	assert.Nil(t, newEngineHintResolverFromEnv().fields(newEnginePod("10.0.0.1", "vllm", "0.6.4"), priorityLow, budget, now),
+		"no hints unless configured")
+
+	_, err = parseEngineHints(`{"vllm": {"minVersion": "latest"}}`)
+	assert.Error(t, err)
+	_, err = parseEngineHints(`{"vllm": {"priorityField": "priority"}}`)
+	assert.Error(t, err)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("0.6.3", "0.6.3"))
+	assert.Equal(t, 0, compareVersions("0.6", "0.6.0"))
+	assert.Equal(t, 1, compareVersions("0.10.0", "0.9.2"))
+	assert.Equal(t, -1, compareVersions("0.6.2", "0.6.3"))
+	assert.Equal(t, 0, compareVersions("0.7.0rc1", "0.7.0"))
+}
+
+func TestGetServingPod(t *testing.T) {
+	pods := map[string]*v1.Pod{
+		"a": newEnginePod("10.0.0.1", "vllm", "0.6.4"),
+		"b": newEnginePod("10.0.0.2", "vllm", "0.6.4"),
+	}
+	assert.Equal(t, pods["b"], getServingPod(pods, "10.0.0.2:8000"))
+	assert.Nil(t, getServingPod(pods, "10.0.0.3:8000"))
+	assert.NotNil(t, getServingPod(pods, ""), "all pods run the same engine version")
+
+	pods["c"] = newEnginePod("10.0.0.3", "vllm", "0.5.5")
+	assert.Nil(t, getServingPod(pods, ""), "pods run different engine versions")
+}
+
+func TestGenerateDeadlineExceededResponse(t *testing.T) {
+	immediate := generateDeadlineExceededResponse(-200 * time.Millisecond).GetImmediateResponse()
+	assert.Equal(t, envoyTypePb.StatusCode_GatewayTimeout, immediate.GetStatus().GetCode())
+	assert.Equal(t, HeaderErrorDeadlineExceeded, immediate.GetHeaders().GetSetHeaders()[0].GetHeader().GetKey())
+}
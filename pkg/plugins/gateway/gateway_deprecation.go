@@ -0,0 +1,454 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/bodypatch"
+)
+
+const (
+	// annotations of a ModelAdapter deprecating its model, the dates are RFC 3339 timestamps or dates.
+	deprecationDateAnnotation = "model.aibrix.ai/deprecation-date"
+	removalDateAnnotation     = "model.aibrix.ai/removal-date"
+	replacementAnnotation     = "model.aibrix.ai/replacement"
+	autoMigrateAnnotation     = "model.aibrix.ai/auto-migrate"
+
+	// modelDeprecationKey is the Redis hash of the model deprecations, the fields are the model names and the values
+	// the JSON encoded deprecation of the model. The annotations of a ModelAdapter take precedence over it.
+	modelDeprecationKey = "aibrix-model-deprecations"
+	// deprecatedUsageKeyPrefix prefixes the Redis hash counting the requests of each user to a deprecated model.
+	deprecatedUsageKeyPrefix = "aibrix-deprecated-usage:"
+
+	// modelDeprecationReloadInterval is how often the model deprecations are read from Redis.
+	modelDeprecationReloadInterval = 10 * time.Second
+	// deprecatedUsageTimeout bounds the increment of the deprecated usage of a user on the request path.
+	deprecatedUsageTimeout = 100 * time.Millisecond
+
+	// deprecationNoticeField is the field of a non-streaming response body the deprecation notice is added to.
+	deprecationNoticeField = "deprecation_notice"
+)
+
+// deprecationPhase is the phase of the deprecation of a model at a point in time.
+type deprecationPhase int
+
+const (
+	// deprecationActive is the phase of a model which is not deprecated yet.
+	deprecationActive deprecationPhase = iota
+	// deprecationSoft is the grace period between the deprecation and the removal of a model, its requests are
+	// served with a warning.
+	deprecationSoft
+	// deprecationRemoved is the phase of a removed model, its requests are rejected or migrated to the replacement.
+	deprecationRemoved
+)
+
+// modelDeprecation is the deprecation of a model.
+type modelDeprecation struct {
+	// DeprecationDate starts the soft window, a deprecation without it is in the soft window until its removal.
+	DeprecationDate time.Time
+	// RemovalDate ends the soft window, the model is never removed without it.
+	RemovalDate time.Time
+	// Replacement is the model the clients should use instead.
+	Replacement string
+	// AutoMigrate serves the requests of the removed model with the replacement rather than rejecting them.
+	AutoMigrate bool
+}
+
+// modelDeprecationSpec is the JSON encoding of a model deprecation stored in Redis, e.g.
+// {"deprecationDate": "2025-01-01", "removalDate": "2025-03-01", "replacement": "llama-3-8b", "autoMigrate": true}.
+type modelDeprecationSpec struct {
+	DeprecationDate string `json:"deprecationDate,omitempty"`
+	RemovalDate     string `json:"removalDate,omitempty"`
+	Replacement     string `json:"replacement,omitempty"`
+	AutoMigrate     bool   `json:"autoMigrate,omitempty"`
+}
+
+func parseModelDeprecation(spec modelDeprecationSpec) (modelDeprecation, error) {
+	var d modelDeprecation
+	var err error
+	if d.DeprecationDate, err = parseDeprecationDate(spec.DeprecationDate); err != nil {
+		return d, fmt.Errorf("invalid deprecation date: %w", err)
+	}
+	if d.RemovalDate, err = parseDeprecationDate(spec.RemovalDate); err != nil {
+		return d, fmt.Errorf("invalid removal date: %w", err)
+	}
+	d.Replacement = strings.TrimSpace(spec.Replacement)
+	d.AutoMigrate = spec.AutoMigrate
+	return d, d.validate()
+}
+
+// parseDeprecationDate parses an RFC 3339 timestamp or a date, which starts at midnight UTC. An empty value is the
+// zero time.
+func parseDeprecationDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func (d modelDeprecation) validate() error {
+	if d.DeprecationDate.IsZero() && d.RemovalDate.IsZero() {
+		return fmt.Errorf("deprecation date or removal date is required")
+	}
+	if !d.DeprecationDate.IsZero() && !d.RemovalDate.IsZero() && d.RemovalDate.Before(d.DeprecationDate) {
+		return fmt.Errorf("removal date %s is before deprecation date %s", formatDeprecationDate(d.RemovalDate), formatDeprecationDate(d.DeprecationDate))
+	}
+	if d.AutoMigrate && d.Replacement == "" {
+		return fmt.Errorf("auto migrate requires a replacement")
+	}
+	return nil
+}
+
+// phase returns the phase of the deprecation at now.
+func (d modelDeprecation) phase(now time.Time) deprecationPhase {
+	switch {
+	case !d.RemovalDate.IsZero() && !now.Before(d.RemovalDate):
+		return deprecationRemoved
+	case d.DeprecationDate.IsZero() || !now.Before(d.DeprecationDate):
+		return deprecationSoft
+	default:
+		return deprecationActive
+	}
+}
+
+// formatDeprecationDate formats a date starting at midnight UTC as a date, any other time as an RFC 3339 timestamp.
+func formatDeprecationDate(t time.Time) string {
+	if t.Equal(t.UTC().Truncate(24 * time.Hour)) {
+		return t.UTC().Format(time.DateOnly)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// replacementHint points the clients of a deprecated model to its replacement, if any.
+func (d modelDeprecation) replacementHint() string {
+	if d.Replacement == "" {
+		return ""
+	}
+	return fmt.Sprintf(", use %s instead", d.Replacement)
+}
+
+// deprecationNotice warns the client of a request to a deprecated model, it is added to the non-streaming responses
+// of the model.
+type deprecationNotice struct {
+	Model           string `json:"model"`
+	DeprecationDate string `json:"deprecation_date,omitempty"`
+	RemovalDate     string `json:"removal_date,omitempty"`
+	Replacement     string `json:"replacement,omitempty"`
+	Message         string `json:"message"`
+}
+
+func newDeprecationNotice(model string, d modelDeprecation, now time.Time) *deprecationNotice {
+	notice := &deprecationNotice{Model: model, Replacement: d.Replacement}
+	if !d.DeprecationDate.IsZero() {
+		notice.DeprecationDate = formatDeprecationDate(d.DeprecationDate)
+	}
+	if !d.RemovalDate.IsZero() {
+		notice.RemovalDate = formatDeprecationDate(d.RemovalDate)
+	}
+	switch {
+	case d.phase(now) == deprecationRemoved:
+		notice.Message = fmt.Sprintf("model %s was removed on %s, the request was served by %s", model, notice.RemovalDate, d.Replacement)
+	case notice.RemovalDate != "":
+		notice.Message = fmt.Sprintf("model %s is deprecated and will be removed on %s%s", model, notice.RemovalDate, d.replacementHint())
+	default:
+		notice.Message = fmt.Sprintf("model %s is deprecated%s", model, d.replacementHint())
+	}
+	return notice
+}
+
+var warningTextEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// warningHeader formats the notice as a "299 Miscellaneous Persistent Warning" of RFC 7234.
+func (n *deprecationNotice) warningHeader() *configPb.HeaderValueOption {
+	return &configPb.HeaderValueOption{
+		Header: &configPb.HeaderValue{
+			Key:      HeaderWarning,
+			RawValue: []byte(`299 - "` + warningTextEscaper.Replace(n.Message) + `"`),
+		},
+	}
+}
+
+// mutateResponseHeaders adds the warning to the response headers of a request to a deprecated model. The notice is
+// added to the body of a non-streaming response, whose length changes. It is a no-op without a notice.
+func (n *deprecationNotice) mutateResponseHeaders(mutation *extProcPb.HeaderMutation, patchBody bool) {
+	if n == nil || mutation == nil {
+		return
+	}
+	mutation.SetHeaders = append(mutation.SetHeaders, n.warningHeader())
+	if patchBody {
+		removeContentLength(mutation)
+	}
+}
+
+// modelDeprecationCache is the subset of the cache the model deprecations are read from.
+type modelDeprecationCache interface {
+	GetModelAdapter(modelName string) (*modelv1alpha1.ModelAdapter, bool)
+}
+
+// modelDeprecations resolves the deprecation of the models from the annotations of their ModelAdapter, or from
+// Redis for the models without a ModelAdapter. The Redis deprecations are reloaded so that they can be updated
+// without restarting the gateway.
+type modelDeprecations struct {
+	cache modelDeprecationCache
+
+	mu           sync.RWMutex
+	deprecations map[string]modelDeprecation
+
+	load func(ctx context.Context) (map[string]string, error)
+	// incr counts a request of the user to the deprecated model.
+	incr func(ctx context.Context, model, user string) error
+}
+
+func newModelDeprecations(c modelDeprecationCache, redisClient redis.UniversalClient) *modelDeprecations {
+	return &modelDeprecations{
+		cache:        c,
+		deprecations: map[string]modelDeprecation{},
+		load: func(ctx context.Context) (map[string]string, error) {
+			return redisClient.HGetAll(ctx, modelDeprecationKey).Result()
+		},
+		incr: func(ctx context.Context, model, user string) error {
+			return redisClient.HIncrBy(ctx, deprecatedUsageKeyPrefix+model, user, 1).Err()
+		},
+	}
+}
+
+// reload reads the deprecations of the models, an invalid deprecation keeps the current one of its model.
+func (d *modelDeprecations) reload(ctx context.Context) {
+	raw, err := d.load(ctx)
+	if err != nil {
+		klog.ErrorS(err, "failed to load model deprecations, keeping the current ones")
+		return
+	}
+
+	d.mu.RLock()
+	current := d.deprecations
+	d.mu.RUnlock()
+
+	deprecations := make(map[string]modelDeprecation, len(raw))
+	for model, value := range raw {
+		var spec modelDeprecationSpec
+		err := json.Unmarshal([]byte(value), &spec)
+		var deprecation modelDeprecation
+		if err == nil {
+			deprecation, err = parseModelDeprecation(spec)
+		}
+		if err != nil {
+			klog.ErrorS(err, "invalid model deprecation, keeping the current one", "model", model)
+			if deprecation, ok := current[model]; ok {
+				deprecations[model] = deprecation
+			}
+			continue
+		}
+		deprecations[model] = deprecation
+	}
+
+	d.mu.Lock()
+	d.deprecations = deprecations
+	d.mu.Unlock()
+}
+
+// start reloads the deprecations every reload interval until stopCh is closed.
+func (d *modelDeprecations) start(stopCh <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(modelDeprecationReloadInterval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), modelDeprecationReloadInterval)
+			d.reload(ctx)
+			cancel()
+			select {
+			case <-ticker.C:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// get returns the deprecation of the model, false if the model is not deprecated. The annotations of the
+// ModelAdapter of the model take precedence over Redis, invalid annotations are ignored.
+func (d *modelDeprecations) get(model string) (modelDeprecation, bool) {
+	if d == nil {
+		return modelDeprecation{}, false
+	}
+	if adapter, ok := d.cache.GetModelAdapter(model); ok {
+		if spec, ok := deprecationAnnotations(adapter.Annotations); ok {
+			deprecation, err := parseModelDeprecation(spec)
+			if err == nil {
+				return deprecation, true
+			}
+			klog.ErrorS(err, "invalid model deprecation annotations, ignoring them", "model", model)
+		}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	deprecation, ok := d.deprecations[model]
+	return deprecation, ok
+}
+
+// deprecationAnnotations reads the deprecation of a ModelAdapter from its annotations, false if it has none.
+func deprecationAnnotations(annotations map[string]string) (modelDeprecationSpec, bool) {
+	spec := modelDeprecationSpec{
+		DeprecationDate: annotations[deprecationDateAnnotation],
+		RemovalDate:     annotations[removalDateAnnotation],
+		Replacement:     annotations[replacementAnnotation],
+	}
+	autoMigrate, found := annotations[autoMigrateAnnotation]
+	spec.AutoMigrate, _ = strconv.ParseBool(autoMigrate)
+	return spec, found || spec != (modelDeprecationSpec{})
+}
+
+// route returns the model serving the requests of the model, which is the replacement of a removed model that auto
+// migrates. It returns a response rejecting the requests of a removed model which does not.
+func (d *modelDeprecations) route(requestID, model string, now time.Time) (string, *extProcPb.ProcessingResponse) {
+	deprecation, ok := d.get(model)
+	if !ok || deprecation.phase(now) != deprecationRemoved {
+		return model, nil
+	}
+	if !deprecation.AutoMigrate {
+		klog.InfoS("request to a removed model", "requestID", requestID, "model", model, "replacement", deprecation.Replacement)
+		return model, generateModelRemovedResponse(model, deprecation)
+	}
+	klog.InfoS("migrating request of a removed model to its replacement", "requestID", requestID, "model", model, "replacement", deprecation.Replacement)
+	return deprecation.Replacement, nil
+}
+
+// warn returns the notice of a request of the user to a deprecated model, in its soft window or migrated to its
+// replacement, and counts the request against the deprecated usage of the user. It returns nil if the model is
+// not deprecated.
+func (d *modelDeprecations) warn(ctx context.Context, requestID, model, user string, now time.Time) *deprecationNotice {
+	deprecation, ok := d.get(model)
+	if !ok || deprecation.phase(now) == deprecationActive {
+		return nil
+	}
+	// the requests of anonymous users can not be traced back to a client.
+	if user != "" {
+		ctx, cancel := context.WithTimeout(ctx, deprecatedUsageTimeout)
+		defer cancel()
+		if err := d.incr(ctx, model, user); err != nil {
+			klog.ErrorS(err, "failed to count deprecated model usage", "requestID", requestID, "model", model, "user", user)
+		}
+	}
+	return newDeprecationNotice(model, deprecation, now)
+}
+
+// generateModelRemovedResponse rejects the request to a removed model, pointing the client to the replacement.
+func generateModelRemovedResponse(model string, d modelDeprecation) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(envoyTypePb.StatusCode_Gone,
+		[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+			Key: HeaderErrorModelRemoved, RawValue: []byte(model)}}},
+		fmt.Sprintf("model %s was removed on %s%s", model, formatDeprecationDate(d.RemovalDate), d.replacementHint()))
+}
+
+// patchBody adds the notice to the body of a non-streaming response, the body is returned unchanged if it is not a
+// JSON object.
+func (n *deprecationNotice) patchBody(requestID string, body []byte) []byte {
+	patched, err := bodypatch.Apply(body, bodypatch.Set([]string{deprecationNoticeField}, n))
+	if err != nil {
+		klog.ErrorS(err, "failed to add the deprecation notice to the response", "requestID", requestID, "model", n.Model)
+		return body
+	}
+	return patched
+}
+
+// generateClearedBodyResponse clears a chunk of a non-streaming response whose body is patched, the whole body is
+// sent with the last chunk.
+func generateClearedBodyResponse() *extProcPb.ProcessingResponse {
+	return &extProcPb.ProcessingResponse{
+		Response: &extProcPb.ProcessingResponse_ResponseBody{
+			ResponseBody: &extProcPb.BodyResponse{
+				Response: &extProcPb.CommonResponse{
+					BodyMutation: &extProcPb.BodyMutation{
+						Mutation: &extProcPb.BodyMutation_ClearBody{ClearBody: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+const (
+	// ModelStatusActive, ModelStatusDeprecated and ModelStatusRemoved are the deprecation statuses of a model.
+	ModelStatusActive     = "active"
+	ModelStatusDeprecated = "deprecated"
+	ModelStatusRemoved    = "removed"
+)
+
+// ModelDeprecationStatus is the deprecation of a model at a point in time, as exposed to the clients.
+type ModelDeprecationStatus struct {
+	Status          string `json:"status"`
+	DeprecationDate string `json:"deprecation_date,omitempty"`
+	RemovalDate     string `json:"removal_date,omitempty"`
+	Replacement     string `json:"replacement,omitempty"`
+}
+
+// ModelDeprecationReader reads the deprecations of the models as the gateway enforces them, for the components
+// exposing them out of the request path.
+type ModelDeprecationReader struct {
+	deprecations *modelDeprecations
+}
+
+// NewModelDeprecationReader returns a reader of the deprecations of the models, whose Redis deprecations are
+// reloaded until stopCh is closed.
+func NewModelDeprecationReader(c modelDeprecationCache, redisClient redis.UniversalClient, stopCh <-chan struct{}) *ModelDeprecationReader {
+	deprecations := newModelDeprecations(c, redisClient)
+	deprecations.start(stopCh)
+	return &ModelDeprecationReader{deprecations: deprecations}
+}
+
+// Status returns the deprecation status of the model at now.
+func (r *ModelDeprecationReader) Status(model string, now time.Time) ModelDeprecationStatus {
+	deprecation, ok := r.deprecations.get(model)
+	if !ok {
+		return ModelDeprecationStatus{Status: ModelStatusActive}
+	}
+	status := ModelDeprecationStatus{Replacement: deprecation.Replacement}
+	switch deprecation.phase(now) {
+	case deprecationRemoved:
+		status.Status = ModelStatusRemoved
+	case deprecationSoft:
+		status.Status = ModelStatusDeprecated
+	default:
+		status.Status = ModelStatusActive
+	}
+	if !deprecation.DeprecationDate.IsZero() {
+		status.DeprecationDate = formatDeprecationDate(deprecation.DeprecationDate)
+	}
+	if !deprecation.RemovalDate.IsZero() {
+		status.RemovalDate = formatDeprecationDate(deprecation.RemovalDate)
+	}
+	return status
+}
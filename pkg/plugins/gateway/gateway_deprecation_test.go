@@ -0,0 +1,302 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+var (
+	testDeprecationDate = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	testRemovalDate     = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	testBeforeDeprecation = testDeprecationDate.Add(-time.Hour)
+	testSoftWindow        = testDeprecationDate.Add(24 * time.Hour)
+	testAfterRemoval      = testRemovalDate.Add(time.Hour)
+)
+
+// newTestModelDeprecations returns the deprecations of the model adapters and of the Redis hash, recording the
+// deprecated usage counted per model and user.
+func newTestModelDeprecations(adapters map[string]*modelv1alpha1.ModelAdapter, raw map[string]string) (*modelDeprecations, map[string]map[string]int) {
+	usage := map[string]map[string]int{}
+	d := &modelDeprecations{
+		cache:        fakeLoraFallbackCache(adapters),
+		deprecations: map[string]modelDeprecation{},
+		load: func(ctx context.Context) (map[string]string, error) {
+			return raw, nil
+		},
+		incr: func(ctx context.Context, model, user string) error {
+			if usage[model] == nil {
+				usage[model] = map[string]int{}
+			}
+			usage[model][user]++
+			return nil
+		},
+	}
+	d.reload(context.Background())
+	return d, usage
+}
+
+func newTestDeprecatedAdapter(name string, annotations map[string]string) *modelv1alpha1.ModelAdapter {
+	return &modelv1alpha1.ModelAdapter{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations}}
+}
+
+func TestParseModelDeprecation(t *testing.T) {
+	d, err := parseModelDeprecation(modelDeprecationSpec{DeprecationDate: "2025-01-01", RemovalDate: "2025-03-01T00:00:00Z", Replacement: " llama-3-8b "})
+	require.NoError(t, err)
+	assert.Equal(t, modelDeprecation{DeprecationDate: testDeprecationDate, RemovalDate: testRemovalDate, Replacement: "llama-3-8b"}, d)
+
+	assert.Equal(t, deprecationActive, d.phase(testBeforeDeprecation))
+	assert.Equal(t, deprecationSoft, d.phase(testDeprecationDate))
+	assert.Equal(t, deprecationSoft, d.phase(testSoftWindow))
+	assert.Equal(t, deprecationRemoved, d.phase(testRemovalDate))
+
+	// a deprecation without a deprecation date is in the soft window until its removal.
+	d, err = parseModelDeprecation(modelDeprecationSpec{RemovalDate: "2025-03-01"})
+	require.NoError(t, err)
+	assert.Equal(t, deprecationSoft, d.phase(testBeforeDeprecation))
+
+	for name, spec := range map[string]modelDeprecationSpec{
+		"no dates":           {Replacement: "llama-3-8b"},
+		"invalid date":       {DeprecationDate: "next week"},
+		"removal before":     {DeprecationDate: "2025-03-01", RemovalDate: "2025-01-01"},
+		"no replacement":     {RemovalDate: "2025-03-01", AutoMigrate: true},
+		"invalid removal at": {RemovalDate: "2025-13-01"},
+	} {
+		_, err := parseModelDeprecation(spec)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestModelDeprecationsGet(t *testing.T) {
+	d, _ := newTestModelDeprecations(map[string]*modelv1alpha1.ModelAdapter{
+		"lora-sql": newTestDeprecatedAdapter("lora-sql", map[string]string{
+			deprecationDateAnnotation: "2025-01-01",
+			removalDateAnnotation:     "2025-03-01",
+			replacementAnnotation:     "lora-sql-v2",
+			autoMigrateAnnotation:     "true",
+		}),
+		"lora-chat":    newTestDeprecatedAdapter("lora-chat", nil),
+		"lora-invalid": newTestDeprecatedAdapter("lora-invalid", map[string]string{removalDateAnnotation: "soon"}),
+	}, map[string]string{
+		"lora-sql":     `{"removalDate": "2026-01-01"}`,
+		"lora-invalid": `{"removalDate": "2025-03-01", "replacement": "llama-3-8b"}`,
+		"llama-2-7b":   `{"deprecationDate": "2025-01-01", "replacement": "llama-3-8b"}`,
+		"llama-2-13b":  `{"autoMigrate": true}`,
+	})
+
+	// the annotations of a model adapter take precedence over Redis.
+	deprecation, ok := d.get("lora-sql")
+	assert.True(t, ok)
+	assert.Equal(t, modelDeprecation{DeprecationDate: testDeprecationDate, RemovalDate: testRemovalDate, Replacement: "lora-sql-v2", AutoMigrate: true}, deprecation)
+
+	// invalid annotations are ignored.
+	deprecation, ok = d.get("lora-invalid")
+	assert.True(t, ok)
+	assert.Equal(t, "llama-3-8b", deprecation.Replacement)
+
+	deprecation, ok = d.get("llama-2-7b")
+	assert.True(t, ok)
+	assert.Equal(t, modelDeprecation{DeprecationDate: testDeprecationDate, Replacement: "llama-3-8b"}, deprecation)
+
+	for _, model := range []string{"lora-chat", "llama-2-13b", "llama-3-8b"} {
+		_, ok = d.get(model)
+		assert.False(t, ok, model)
+	}
+
+	var disabled *modelDeprecations
+	_, ok = disabled.get("llama-2-7b")
+	assert.False(t, ok)
+}
+
+func TestModelDeprecationsReload(t *testing.T) {
+	raw := map[string]string{"llama-2-7b": `{"removalDate": "2025-03-01"}`}
+	d, _ := newTestModelDeprecations(nil, raw)
+
+	// an invalid deprecation keeps the current one, a failed load keeps them all.
+	raw["llama-2-7b"] = `{"removalDate": "soon"}`
+	d.reload(context.Background())
+	deprecation, ok := d.get("llama-2-7b")
+	assert.True(t, ok)
+	assert.Equal(t, testRemovalDate, deprecation.RemovalDate)
+
+	d.load = func(ctx context.Context) (map[string]string, error) { return nil, errors.New("redis down") }
+	d.reload(context.Background())
+	_, ok = d.get("llama-2-7b")
+	assert.True(t, ok)
+}
+
+func TestModelDeprecationReaderStatus(t *testing.T) {
+	d, _ := newTestModelDeprecations(nil, map[string]string{
+		"llama-2-7b": `{"deprecationDate": "2025-01-01", "removalDate": "2025-03-01", "replacement": "llama-3-8b"}`,
+	})
+	r := &ModelDeprecationReader{deprecations: d}
+
+	assert.Equal(t, ModelDeprecationStatus{Status: ModelStatusActive}, r.Status("llama-3-8b", testSoftWindow))
+	expected := ModelDeprecationStatus{DeprecationDate: "2025-01-01", RemovalDate: "2025-03-01", Replacement: "llama-3-8b"}
+	for now, status := range map[time.Time]string{
+		testBeforeDeprecation: ModelStatusActive,
+		testSoftWindow:        ModelStatusDeprecated,
+		testAfterRemoval:      ModelStatusRemoved,
+	} {
+		expected.Status = status
+		assert.Equal(t, expected, r.Status("llama-2-7b", now))
+	}
+}
+
+func TestModelDeprecationBeforeDeprecation(t *testing.T) {
+	d, usage := newTestModelDeprecations(nil, map[string]string{
+		"llama-2-7b": `{"deprecationDate": "2025-01-01", "removalDate": "2025-03-01", "replacement": "llama-3-8b"}`,
+	})
+
+	model, errRes := d.route("r1", "llama-2-7b", testBeforeDeprecation)
+	assert.Nil(t, errRes)
+	assert.Equal(t, "llama-2-7b", model)
+	assert.Nil(t, d.warn(context.Background(), "r1", "llama-2-7b", "alice", testBeforeDeprecation))
+	assert.Empty(t, usage)
+}
+
+func TestModelDeprecationSoftWindow(t *testing.T) {
+	d, usage := newTestModelDeprecations(nil, map[string]string{
+		"llama-2-7b": `{"deprecationDate": "2025-01-01", "removalDate": "2025-03-01", "replacement": "llama-3-8b", "autoMigrate": true}`,
+	})
+
+	// the requests are still served by the deprecated model.
+	model, errRes := d.route("r1", "llama-2-7b", testSoftWindow)
+	assert.Nil(t, errRes)
+	assert.Equal(t, "llama-2-7b", model)
+
+	notice := d.warn(context.Background(), "r1", "llama-2-7b", "alice", testSoftWindow)
+	require.NotNil(t, notice)
+	assert.Equal(t, &deprecationNotice{
+		Model:           "llama-2-7b",
+		DeprecationDate: "2025-01-01",
+		RemovalDate:     "2025-03-01",
+		Replacement:     "llama-3-8b",
+		Message:         "model llama-2-7b is deprecated and will be removed on 2025-03-01, use llama-3-8b instead",
+	}, notice)
+	// the requests of anonymous users are not counted.
+	assert.NotNil(t, d.warn(context.Background(), "r2", "llama-2-7b", "", testSoftWindow))
+	d.warn(context.Background(), "r3", "llama-2-7b", "alice", testSoftWindow)
+	assert.Equal(t, map[string]map[string]int{"llama-2-7b": {"alice": 2}}, usage)
+
+	// the warning is added to the response headers, and the length of a patched body is dropped.
+	mutation := &extProcPb.HeaderMutation{SetHeaders: []*configPb.HeaderValueOption{
+		{Header: &configPb.HeaderValue{Key: "Content-Length", RawValue: []byte("42")}},
+		{Header: &configPb.HeaderValue{Key: "content-type", RawValue: []byte("application/json")}},
+	}}
+	notice.mutateResponseHeaders(mutation, true)
+	assert.Equal(t, []string{"content-length"}, mutation.RemoveHeaders)
+	if assert.Len(t, mutation.SetHeaders, 2) {
+		assert.Equal(t, "content-type", mutation.SetHeaders[0].Header.Key)
+		assert.Equal(t, HeaderWarning, mutation.SetHeaders[1].Header.Key)
+		assert.Equal(t, `299 - "model llama-2-7b is deprecated and will be removed on 2025-03-01, use llama-3-8b instead"`,
+			string(mutation.SetHeaders[1].Header.RawValue))
+	}
+	streamMutation := &extProcPb.HeaderMutation{}
+	notice.mutateResponseHeaders(streamMutation, false)
+	assert.Len(t, streamMutation.SetHeaders, 1)
+	assert.Empty(t, streamMutation.RemoveHeaders)
+
+	var none *deprecationNotice
+	none.mutateResponseHeaders(streamMutation, true)
+	assert.Len(t, streamMutation.SetHeaders, 1)
+}
+
+func TestModelDeprecationNoticeInResponseBody(t *testing.T) {
+	s := &Server{}
+	notice := &deprecationNotice{Model: "llama-2-7b", RemovalDate: "2025-03-01", Message: "model llama-2-7b is deprecated and will be removed on 2025-03-01"}
+	account := &requestAccount{deprecation: notice}
+	newBody := func(body string, endOfStream bool) *extProcPb.ProcessingRequest {
+		return &extProcPb.ProcessingRequest{Request: &extProcPb.ProcessingRequest_ResponseBody{
+			ResponseBody: &extProcPb.HttpBody{Body: []byte(body), EndOfStream: endOfStream},
+		}}
+	}
+
+	// the chunks before the last one are held back, the last one sends the whole body with the notice.
+	resp, complete := s.HandleResponseBody(context.Background(), "r1", newBody(`{"id": "1", "model": "llama-2-7b", `, false), account, "llama-2-7b", "", false, 0, false)
+	assert.False(t, complete)
+	assert.True(t, resp.GetResponseBody().GetResponse().GetBodyMutation().GetClearBody())
+
+	resp, _ = s.HandleResponseBody(context.Background(), "r1", newBody(`"choices": []}`, true), account, "llama-2-7b", "", false, 0, false)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody(), &body))
+	assert.Equal(t, "1", body["id"])
+	assert.Equal(t, map[string]interface{}{
+		"model":        "llama-2-7b",
+		"removal_date": "2025-03-01",
+		"message":      "model llama-2-7b is deprecated and will be removed on 2025-03-01",
+	}, body[deprecationNoticeField])
+
+	// the responses of the models which are not deprecated are passed through.
+	resp, _ = s.HandleResponseBody(context.Background(), "r2", newBody(`{"id": "2", "model": "llama-3-8b", "choices": []}`, true), &requestAccount{}, "llama-3-8b", "", false, 0, false)
+	assert.Nil(t, resp.GetResponseBody().GetResponse().GetBodyMutation())
+}
+
+func TestModelDeprecationRemoved(t *testing.T) {
+	d, usage := newTestModelDeprecations(nil, map[string]string{
+		"llama-2-7b": `{"deprecationDate": "2025-01-01", "removalDate": "2025-03-01", "replacement": "llama-3-8b"}`,
+	})
+
+	model, errRes := d.route("r1", "llama-2-7b", testAfterRemoval)
+	assert.Equal(t, "llama-2-7b", model)
+	require.NotNil(t, errRes)
+	immediate := errRes.GetImmediateResponse()
+	assert.Equal(t, envoyTypePb.StatusCode_Gone, immediate.GetStatus().GetCode())
+	assert.Equal(t, HeaderErrorModelRemoved, immediate.GetHeaders().GetSetHeaders()[0].Header.Key)
+	assert.JSONEq(t, `{"error": {"code": 410, "message": "model llama-2-7b was removed on 2025-03-01, use llama-3-8b instead"}}`, immediate.GetBody())
+	assert.Empty(t, usage)
+}
+
+func TestModelDeprecationAutoMigrate(t *testing.T) {
+	d, usage := newTestModelDeprecations(map[string]*modelv1alpha1.ModelAdapter{
+		"lora-sql": newTestDeprecatedAdapter("lora-sql", map[string]string{
+			removalDateAnnotation: "2025-03-01",
+			replacementAnnotation: "lora-sql-v2",
+			autoMigrateAnnotation: "true",
+		}),
+	}, nil)
+
+	// the requests of the removed model are rewritten to the replacement.
+	model, errRes := d.route("r1", "lora-sql", testAfterRemoval)
+	assert.Nil(t, errRes)
+	assert.Equal(t, "lora-sql-v2", model)
+
+	// the client is told its request was migrated, and the request counts against its deprecated usage.
+	notice := d.warn(context.Background(), "r1", "lora-sql", "alice", testAfterRemoval)
+	require.NotNil(t, notice)
+	assert.Equal(t, "model lora-sql was removed on 2025-03-01, the request was served by lora-sql-v2", notice.Message)
+	assert.Equal(t, map[string]map[string]int{"lora-sql": {"alice": 1}}, usage)
+
+	// the replacement itself is not deprecated.
+	model, errRes = d.route("r2", "lora-sql-v2", testAfterRemoval)
+	assert.Nil(t, errRes)
+	assert.Equal(t, "lora-sql-v2", model)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/engineapi"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// engineAPIResolver selects the translator of the requests served by the pods of an engine which does not serve
+// the OpenAI API.
+type engineAPIResolver struct {
+	engines map[string]engineapi.Translator
+}
+
+// newEngineAPIResolverFromEnv reads the native API served per engine from AIBRIX_GATEWAY_ENGINE_APIS, e.g.
+// {"tgi": "tgi"}. The engines serve the OpenAI API unless configured, like TGI does on /v1/chat/completions.
+func newEngineAPIResolverFromEnv() *engineAPIResolver {
+	r := &engineAPIResolver{engines: map[string]engineapi.Translator{}}
+	value, exists := utils.CheckEnvExists(EnvEngineAPIs)
+	if !exists {
+		return r
+	}
+	engines, err := parseEngineAPIs(value)
+	if err != nil {
+		klog.ErrorS(err, "invalid engine APIs, ignoring", "env", EnvEngineAPIs)
+		return r
+	}
+	r.engines = engines
+	return r
+}
+
+func parseEngineAPIs(value string) (map[string]engineapi.Translator, error) {
+	var apis map[string]string
+	if err := json.Unmarshal([]byte(value), &apis); err != nil {
+		return nil, err
+	}
+	engines := map[string]engineapi.Translator{}
+	for engine, api := range apis {
+		translator, ok := engineapi.Get(api)
+		if !ok {
+			return nil, fmt.Errorf("engine %s: unknown API %q, expected one of %s", engine, api, strings.Join(engineapi.Names(), ", "))
+		}
+		engines[engine] = translator
+	}
+	return engines, nil
+}
+
+// translator returns the translator of the requests served by the pod, nil if its engine serves the OpenAI API.
+func (r *engineAPIResolver) translator(pod *v1.Pod) engineapi.Translator {
+	if r == nil || pod == nil {
+		return nil
+	}
+	return r.engines[pod.Labels[engineLabel]]
+}
+
+// translateRequestBody translates the request routed to a pod serving the native API of its engine, it returns the
+// mutation of the path and of the body, or the response rejecting a request the API can not serve. The translator
+// of the response is kept on the account.
+func translateRequestBody(translator engineapi.Translator, req engineapi.Request, account *requestAccount) (*configPb.HeaderValueOption, *extProcPb.BodyMutation, *extProcPb.ProcessingResponse) {
+	path, body, err := translator.TranslateRequest(req)
+	if err != nil {
+		klog.ErrorS(err, "failed to translate request into the engine API", "requestID", req.ID, "model", req.Model)
+		return nil, nil, generateErrorResponse(envoyTypePb.StatusCode_BadRequest,
+			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+				Key: HeaderErrorEngineAPI, RawValue: []byte("true")}}},
+			err.Error())
+	}
+	account.engineAPI = translator.NewResponseTranslator(req)
+	return &configPb.HeaderValueOption{Header: &configPb.HeaderValue{Key: ":path", RawValue: []byte(path)}},
+		&extProcPb.BodyMutation{Mutation: &extProcPb.BodyMutation_Body{Body: body}}, nil
+}
+
+// removeContentLength removes the content length of a response whose body is replaced.
+func removeContentLength(mutation *extProcPb.HeaderMutation) {
+	if mutation == nil {
+		return
+	}
+	headers := mutation.SetHeaders[:0]
+	for _, header := range mutation.SetHeaders {
+		if !strings.EqualFold(header.GetHeader().GetKey(), "content-length") {
+			headers = append(headers, header)
+		}
+	}
+	mutation.SetHeaders = headers
+	mutation.RemoveHeaders = append(mutation.RemoveHeaders, "content-length")
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/engineapi"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/tokenizer"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+func TestEngineAPIs(t *testing.T) {
+	engines, err := parseEngineAPIs(`{"tgi": "tgi"}`)
+	require.NoError(t, err)
+	r := &engineAPIResolver{engines: engines}
+
+	assert.NotNil(t, r.translator(newEnginePod("10.0.0.1", "tgi", "")))
+	assert.Nil(t, r.translator(newEnginePod("10.0.0.1", "vllm", "")), "the OpenAI API")
+	assert.Nil(t, r.translator(nil))
+	assert.Nil(t, newEngineAPIResolverFromEnv().translator(newEnginePod("10.0.0.1", "tgi", "")),
+		"the OpenAI API unless configured")
+
+	_, err = parseEngineAPIs(`{"tgi": "triton"}`)
+	assert.ErrorContains(t, err, "unknown API")
+	_, err = parseEngineAPIs(`["tgi"]`)
+	assert.Error(t, err)
+}
+
+func newTGIRequest(t *testing.T, endpoint engineapi.Endpoint, body string) engineapi.Request {
+	var jsonMap map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &jsonMap))
+	stream, _ := jsonMap["stream"].(bool)
+	return engineapi.Request{
+		ID:       "r1",
+		Endpoint: endpoint,
+		Model:    "llama-2-7b",
+		Stream:   stream,
+		Body:     jsonMap,
+		Created:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestEngineAPIRequestBody(t *testing.T) {
+	translator, _ := engineapi.Get("tgi")
+
+	account := &requestAccount{}
+	pathHeader, bodyMutation, errRes := translateRequestBody(translator,
+		newTGIRequest(t, engineapi.Completions, `{"model": "llama-2-7b", "prompt": "Hello", "max_tokens": 8, "stream": true}`), account)
+	require.Nil(t, errRes)
+	assert.Equal(t, &configPb.HeaderValueOption{Header: &configPb.HeaderValue{Key: ":path", RawValue: []byte("/generate_stream")}}, pathHeader)
+	assert.JSONEq(t, `{"inputs": "Hello", "parameters": {"max_new_tokens": 8, "do_sample": true, "details": true}}`,
+		string(bodyMutation.GetBody()))
+	assert.NotNil(t, account.engineAPI)
+
+	// the requests the engine API can not serve are rejected, the routing is not retried.
+	account = &requestAccount{}
+	_, _, errRes = translateRequestBody(translator,
+		newTGIRequest(t, engineapi.Completions, `{"model": "llama-2-7b", "prompt": ["Hello", "Bye"]}`), account)
+	require.NotNil(t, errRes)
+	assert.Equal(t, envoyTypePb.StatusCode_BadRequest, errRes.GetImmediateResponse().GetStatus().GetCode())
+	assert.Equal(t, HeaderErrorEngineAPI, errRes.GetImmediateResponse().GetHeaders().GetSetHeaders()[0].GetHeader().GetKey())
+	assert.Nil(t, account.engineAPI)
+}
+
+func TestEngineAPIResponseBody(t *testing.T) {
+	translator, _ := engineapi.Get("tgi")
+	m := newUsageMeter(nil, 100, time.Hour)
+	s := &Server{usage: m, tokenizers: tokenizer.NewRegistry(nil, 0)}
+	newChunk := func(body string, endOfStream bool) *extProcPb.ProcessingRequest {
+		return &extProcPb.ProcessingRequest{Request: &extProcPb.ProcessingRequest_ResponseBody{
+			ResponseBody: &extProcPb.HttpBody{Body: []byte(body), EndOfStream: endOfStream},
+		}}
+	}
+
+	// the chunks of a non-streaming response are held back, the last one sends the translated body.
+	account := &requestAccount{username: "alice",
+		engineAPI: translator.NewResponseTranslator(newTGIRequest(t, engineapi.ChatCompletions, `{"messages": []}`))}
+	resp, _ := s.HandleResponseBody(context.Background(), "r1", newChunk(`{"generated_text":" Hi","details":`, false),
+		account, "llama-2-7b", "", false, 0, true)
+	assert.True(t, resp.GetResponseBody().GetResponse().GetBodyMutation().GetClearBody())
+	resp, _ = s.HandleResponseBody(context.Background(), "r1",
+		newChunk(`{"finish_reason":"eos_token","generated_tokens":2,"seed":null,"prefill":[{"id":1,"text":"<s>"},{"id":6324,"text":"Hi"}],"tokens":[]}}`, true),
+		account, "llama-2-7b", "", false, 0, true)
+	assert.JSONEq(t, `{"id":"chatcmpl-r1","object":"chat.completion","created":1735689600,"model":"llama-2-7b",
+		"choices":[{"index":0,"message":{"role":"assistant","content":" Hi"},"logprobs":null,"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":2,"completion_tokens":2,"total_tokens":4}}`,
+		string(resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody()))
+
+	// the frames of a stream are translated as they arrive, the usage is the one counted by TGI.
+	account = &requestAccount{username: "bob",
+		engineAPI: translator.NewResponseTranslator(newTGIRequest(t, engineapi.Completions,
+			`{"prompt": "Hi", "stream": true, "stream_options": {"include_usage": true}}`))}
+	resp, _ = s.HandleResponseBody(context.Background(), "r2",
+		newChunk("data:{\"index\":1,\"token\":{\"id\":15043,\"text\":\" Hello\",\"special\":false},\"generated_text\":null,\"details\":null}\n\ndata:{\"index\":2,", false),
+		account, "llama-2-7b", "", true, 0, true)
+	assert.Equal(t, "data: {\"id\":\"cmpl-r1\",\"object\":\"text_completion\",\"created\":1735689600,\"model\":\"llama-2-7b\",\"choices\":[{\"index\":0,\"text\":\" Hello\",\"logprobs\":null,\"finish_reason\":null}]}\n\n",
+		string(resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody()))
+	resp, _ = s.HandleResponseBody(context.Background(), "r2",
+		newChunk("\"token\":{\"id\":2,\"text\":\"</s>\",\"special\":true},\"generated_text\":\" Hello\",\"details\":{\"finish_reason\":\"eos_token\",\"generated_tokens\":2,\"seed\":null,\"input_length\":3}}\n\n", true),
+		account, "llama-2-7b", "", true, 0, true)
+	assert.Contains(t, string(resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody()), "data: [DONE]\n\n")
+
+	assert.Len(t, m.pending, 2)
+	for key, record := range m.pending {
+		if key.user == "bob" {
+			assert.Equal(t, utils.UsageRecord{User: "bob", Model: "llama-2-7b", PromptTokens: 3, CompletionTokens: 2, Requests: 1, At: key.hour}, *record)
+		}
+	}
+}
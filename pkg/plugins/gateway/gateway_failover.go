@@ -0,0 +1,268 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+)
+
+const (
+	defaultFailoverMaxAttempts    = 3
+	defaultFailoverConnectTimeout = 2 * time.Second
+)
+
+var failoverRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_gateway_failover_retries_total",
+	Help: "Number of requests the gateway retried on another pod, by model and by the pod which could not be reached.",
+}, []string{"model", "pod"})
+
+func init() {
+	prometheus.MustRegister(failoverRetries)
+}
+
+// failoverCache is the subset of the cache the failover proxy reports the connections to the pods to.
+type failoverCache interface {
+	RecordPodConnectFailure(podName string) bool
+	RecordPodConnectSuccess(podName string)
+}
+
+// failoverTicket is a request handed over to the failover proxy.
+type failoverTicket struct {
+	model string
+	// upstream is the address of the pod the request was routed to, and pods the pods it was routed among.
+	upstream string
+	pods     map[string]*v1.Pod
+	// route returns up to n targets among the pods, the best first, for the request to fail over to.
+	route func(ctx context.Context, pods map[string]*v1.Pod, n int) ([]string, error)
+}
+
+// failoverProxy passes the routed requests on to their pods, and retries a request on the next best pod when its
+// pod can not be reached, e.g. because its container was just killed or its IP is not routable yet. The ext_proc
+// stream can not send a request twice, so Envoy routes the requests to the proxy instead of their pods. A request
+// is only retried when the connection to its pod failed or was reset before the pod answered, never once the
+// response started. The pods failing to connect are reported to the cache, which quarantines them.
+type failoverProxy struct {
+	// address is where Envoy reaches the proxy, e.g. the pod IP and the failover port of the gateway plugin.
+	address     string
+	maxAttempts int
+
+	cache  failoverCache
+	client *http.Client
+
+	mu      sync.Mutex
+	tickets map[string]*failoverTicket
+}
+
+func newFailoverProxy(address string, maxAttempts int, connectTimeout time.Duration, c failoverCache) *failoverProxy {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.DisableCompression = true
+	return &failoverProxy{
+		address:     address,
+		maxAttempts: maxAttempts,
+		cache:       c,
+		client:      &http.Client{Transport: transport},
+		tickets:     map[string]*failoverTicket{},
+	}
+}
+
+// EnableFailover lets the routed requests fail over to the next best pod of their model behind the failover proxy
+// reached by Envoy at address. It returns the handler of the proxy.
+func (s *Server) EnableFailover(address string) http.Handler {
+	s.failover = newFailoverProxy(address,
+		loadPositiveIntEnv("AIBRIX_GATEWAY_FAILOVER_MAX_ATTEMPTS", defaultFailoverMaxAttempts),
+		loadDurationMsEnv("AIBRIX_GATEWAY_FAILOVER_CONNECT_TIMEOUT_MS", defaultFailoverConnectTimeout),
+		s.cache)
+	return s.failover
+}
+
+// enabled returns whether the requests are handed over to the proxy, always false while the proxy is disabled.
+func (p *failoverProxy) enabled() bool {
+	return p != nil && p.maxAttempts > 1
+}
+
+// handOver registers the request with the proxy and returns the routing headers sending it to the proxy rather
+// than to the pod it was routed to.
+func (p *failoverProxy) handOver(requestID string, ticket *failoverTicket, headers []*configPb.HeaderValueOption) []*configPb.HeaderValueOption {
+	p.mu.Lock()
+	p.tickets[requestID] = ticket
+	p.mu.Unlock()
+
+	for _, header := range headers {
+		if header.Header.Key == HeaderTargetPod {
+			header.Header.RawValue = []byte(p.address)
+		}
+	}
+	return append(headers, &configPb.HeaderValueOption{
+		Header: &configPb.HeaderValue{Key: HeaderFailoverTicket, RawValue: []byte(requestID)},
+	})
+}
+
+// take removes the ticket of the request, nil if the request was not handed over or was already taken.
+func (p *failoverProxy) take(requestID string) *failoverTicket {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ticket := p.tickets[requestID]
+	delete(p.tickets, requestID)
+	return ticket
+}
+
+// forget drops the ticket of a request which ended before reaching the proxy.
+func (p *failoverProxy) forget(requestID string) {
+	if p != nil {
+		p.take(requestID)
+	}
+}
+
+func (p *failoverProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get(HeaderFailoverTicket)
+	ticket := p.take(requestID)
+	if ticket == nil {
+		http.Error(w, "unknown failover ticket", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read the request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	targets := []string{ticket.upstream}
+	var lastErr error
+	for attempt := 0; attempt < p.maxAttempts; attempt++ {
+		if attempt == len(targets) {
+			// the targets tried so far all failed, the request is routed again among the other pods.
+			more, err := ticket.route(ctx, podsWithoutTargets(ticket.pods, targets), p.maxAttempts-attempt)
+			if err != nil || len(more) == 0 {
+				break
+			}
+			targets = append(targets, more...)
+		}
+		target := targets[attempt]
+		podName := target
+		if pod := getServingPod(ticket.pods, target); pod != nil {
+			podName = pod.Name
+		}
+
+		resp, err := p.send(r, body, target)
+		if err == nil {
+			p.cache.RecordPodConnectSuccess(podName)
+			if attempt > 0 {
+				klog.InfoS("request failed over", "requestID", requestID, "model", ticket.model, "targetPodIP", target, "retries", attempt)
+			}
+			p.pass(w, resp, requestID, target)
+			return
+		}
+		if ctx.Err() != nil {
+			// the client disconnected, there is no one to answer.
+			return
+		}
+		lastErr = err
+		if !isRetryableUpstreamError(err) {
+			break
+		}
+		failoverRetries.WithLabelValues(ticket.model, podName).Inc()
+		quarantined := p.cache.RecordPodConnectFailure(podName)
+		klog.InfoS("failed to reach the pod, failing over", "requestID", requestID, "model", ticket.model,
+			"targetPodIP", target, "attempt", attempt+1, "quarantined", quarantined, "err", err)
+	}
+	klog.ErrorS(lastErr, "failed to forward the request", "requestID", requestID, "model", ticket.model, "targets", targets)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadGateway)
+	_, _ = w.Write([]byte(generateErrorMessage("error on forwarding the request to the engine", http.StatusBadGateway)))
+}
+
+// send sends the request to the target, the response is not read.
+func (p *failoverProxy) send(r *http.Request, body []byte, target string) (*http.Response, error) {
+	upstream, err := http.NewRequestWithContext(r.Context(), r.Method, "http://"+target+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	upstream.Header = r.Header.Clone()
+	upstream.Header.Del(HeaderFailoverTicket)
+	return p.client.Do(upstream)
+}
+
+// pass passes the response of the pod through, flushing every read so that the streams are not delayed.
+func (p *failoverProxy) pass(w http.ResponseWriter, resp *http.Response, requestID, target string) {
+	defer resp.Body.Close()
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				klog.ErrorS(err, "failed to read the response of the pod", "requestID", requestID, "targetPodIP", target)
+			}
+			return
+		}
+	}
+}
+
+// isRetryableUpstreamError returns whether the request did not reach the pod, or the pod reset the connection
+// before answering: the connection was refused, reset, or could not be established in time.
+func isRetryableUpstreamError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EHOSTUNREACH) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// podsWithoutTargets returns the pods which are none of the targets.
+func podsWithoutTargets(pods map[string]*v1.Pod, targets []string) map[string]*v1.Pod {
+	res := make(map[string]*v1.Pod, len(pods))
+	for name, pod := range pods {
+		res[name] = pod
+	}
+	for _, target := range targets {
+		if pod := getServingPod(res, target); pod != nil {
+			delete(res, pod.Name)
+		}
+	}
+	return res
+}
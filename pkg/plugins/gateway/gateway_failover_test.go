@@ -0,0 +1,159 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeFailoverCache records the connections to the pods.
+type fakeFailoverCache struct {
+	mu        sync.Mutex
+	failures  map[string]int
+	successes map[string]int
+}
+
+func (c *fakeFailoverCache) RecordPodConnectFailure(podName string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[podName]++
+	return false
+}
+
+func (c *fakeFailoverCache) RecordPodConnectSuccess(podName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.successes[podName]++
+}
+
+// newTestFailoverPods returns a pod refusing the connections, and a pod served by the upstream handler.
+func newTestFailoverPods(t *testing.T, upstream http.Handler) (map[string]*v1.Pod, string, string) {
+	// nothing listens on the port of the closed listener, on another loopback address than the live pod.
+	lis, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("no second loopback address: %v", err)
+	}
+	dead := lis.Addr().String()
+	assert.NoError(t, lis.Close())
+	live := httptest.NewServer(upstream)
+	t.Cleanup(live.Close)
+	pods := map[string]*v1.Pod{
+		"dead": {ObjectMeta: metav1.ObjectMeta{Name: "dead"}, Status: v1.PodStatus{PodIP: "127.0.0.2"}},
+		"live": {ObjectMeta: metav1.ObjectMeta{Name: "live"}, Status: v1.PodStatus{PodIP: "127.0.0.1"}},
+	}
+	return pods, dead, strings.TrimPrefix(live.URL, "http://")
+}
+
+func postToFailoverProxy(t *testing.T, p *failoverProxy, ticket string) *http.Response {
+	t.Helper()
+	proxy := httptest.NewServer(p)
+	t.Cleanup(proxy.Close)
+	req, err := http.NewRequest(http.MethodPost, proxy.URL+"/v1/chat/completions", strings.NewReader(`{"model":"llama-7b"}`))
+	assert.NoError(t, err)
+	req.Header.Set(HeaderFailoverTicket, ticket)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestFailoverToTheNextPod(t *testing.T) {
+	var forwarded *http.Request
+	pods, dead, live := newTestFailoverPods(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	c := &fakeFailoverCache{failures: map[string]int{}, successes: map[string]int{}}
+	p := newFailoverProxy("10.0.0.1:8083", 3, time.Second, c)
+	var routed []map[string]*v1.Pod
+	p.tickets["req-1"] = &failoverTicket{model: "llama-7b", upstream: dead, pods: pods,
+		route: func(ctx context.Context, pods map[string]*v1.Pod, n int) ([]string, error) {
+			routed = append(routed, pods)
+			return []string{live}, nil
+		}}
+	before := testutil.ToFloat64(failoverRetries.WithLabelValues("llama-7b", "dead"))
+
+	resp := postToFailoverProxy(t, p, "req-1")
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"id":"1"}`, string(body))
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Empty(t, forwarded.Header.Get(HeaderFailoverTicket))
+
+	// the request is routed again without the unreachable pod, which is reported to the cache.
+	assert.Len(t, routed, 1)
+	assert.NotContains(t, routed[0], "dead")
+	assert.Equal(t, map[string]int{"dead": 1}, c.failures)
+	assert.Equal(t, map[string]int{"live": 1}, c.successes)
+	assert.Equal(t, before+1, testutil.ToFloat64(failoverRetries.WithLabelValues("llama-7b", "dead")))
+	assert.Empty(t, p.tickets)
+}
+
+func TestFailoverGivesUp(t *testing.T) {
+	pods, dead, live := newTestFailoverPods(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("overloaded"))
+	}))
+	c := &fakeFailoverCache{failures: map[string]int{}, successes: map[string]int{}}
+	p := newFailoverProxy("10.0.0.1:8083", 3, time.Second, c)
+
+	// an answer of the pod is passed through, even an error, the request is not retried once the pod answered.
+	p.tickets["req-1"] = &failoverTicket{model: "llama-7b", upstream: live, pods: pods,
+		route: func(ctx context.Context, pods map[string]*v1.Pod, n int) ([]string, error) {
+			t.Fatalf("unexpected failover")
+			return nil, nil
+		}}
+	resp := postToFailoverProxy(t, p, "req-1")
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "overloaded", string(body))
+
+	// no other pod to fail over to.
+	p.tickets["req-2"] = &failoverTicket{model: "llama-7b", upstream: dead, pods: pods,
+		route: func(ctx context.Context, pods map[string]*v1.Pod, n int) ([]string, error) {
+			return nil, errors.New("no pods to forward request")
+		}}
+	resp = postToFailoverProxy(t, p, "req-2")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, map[string]int{"dead": 1}, c.failures)
+
+	// an unknown ticket is not forwarded.
+	resp = postToFailoverProxy(t, p, "req-3")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestIsRetryableUpstreamError(t *testing.T) {
+	_, err := (&net.Dialer{}).Dial("tcp", "127.0.0.1:1")
+	assert.True(t, isRetryableUpstreamError(err))
+	assert.False(t, isRetryableUpstreamError(context.Canceled))
+	assert.False(t, isRetryableUpstreamError(io.ErrUnexpectedEOF))
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// headroomPolicy is the warm pool of a model, request slots kept free across its pods for high priority requests.
+type headroomPolicy struct {
+	// SlotsPerPod is the number of requests a pod serves concurrently without queueing.
+	SlotsPerPod int `json:"slotsPerPod"`
+	// SpareSlots is the number of free slots low priority requests leave across all pods of the model.
+	SpareSlots int `json:"spareSlots"`
+}
+
+// headroomCache is the subset of the cache the headroom router reads.
+type headroomCache interface {
+	GetPodModelMetric(podName, modelName string, metricName string) (metrics.MetricValue, error)
+}
+
+// headroomResolver holds the headroom policies per model.
+type headroomResolver struct {
+	cache    headroomCache
+	policies map[string]headroomPolicy
+}
+
+// newHeadroomResolverFromEnv reads the headroom policies per model from AIBRIX_GATEWAY_HEADROOM, e.g.
+// {"llama-7b": {"slotsPerPod": 8, "spareSlots": 4}}. Models without a policy are routed unchanged.
+func newHeadroomResolverFromEnv(c headroomCache) *headroomResolver {
+	r := &headroomResolver{cache: c, policies: map[string]headroomPolicy{}}
+	value, exists := utils.CheckEnvExists(EnvHeadroom)
+	if !exists {
+		return r
+	}
+	policies, err := parseHeadroomPolicies(value)
+	if err != nil {
+		klog.ErrorS(err, "invalid headroom policies, ignoring", "env", EnvHeadroom)
+		return r
+	}
+	r.policies = policies
+	return r
+}
+
+func parseHeadroomPolicies(value string) (map[string]headroomPolicy, error) {
+	var policies map[string]headroomPolicy
+	if err := json.Unmarshal([]byte(value), &policies); err != nil {
+		return nil, err
+	}
+	for model, policy := range policies {
+		if policy.SlotsPerPod <= 0 || policy.SpareSlots < 0 {
+			return nil, fmt.Errorf("model %s: slotsPerPod must be positive and spareSlots not negative", model)
+		}
+	}
+	return policies, nil
+}
+
+// decorate wraps the router of a request with the headroom policy of its model, if any.
+func (r *headroomResolver) decorate(router routing.Router, model, priority string) routing.Router {
+	if r == nil {
+		return router
+	}
+	policy, ok := r.policies[model]
+	if !ok || policy.SpareSlots == 0 {
+		return router
+	}
+	return headroomRouter{router: router, cache: r.cache, policy: policy, priority: priority}
+}
+
+// headroomRouter decorates a router with a headroom penalty for low priority requests. The warm pool is the free
+// slots of the pods with the most free slots, just enough of them to hold SpareSlots. Taking a slot of the warm
+// pool is penalized if it would leave less than SpareSlots free, so the decorated router picks among the other
+// pods: low priority requests pack the pods outside of the warm pool and then queue on them, while high priority
+// requests ignore the penalty and keep finding free slots.
+type headroomRouter struct {
+	router   routing.Router
+	cache    headroomCache
+	policy   headroomPolicy
+	priority string
+}
+
+func (r headroomRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
+	if r.priority == priorityHigh {
+		return r.router.Route(ctx, pods, model, message)
+	}
+
+	readyPods := utils.FilterReadyPods(pods)
+	spare := make(map[string]int, len(readyPods))
+	for _, pod := range readyPods {
+		spare[pod.Name] = r.spareSlots(pod, model)
+	}
+	sort.Slice(readyPods, func(i, j int) bool {
+		if spare[readyPods[i].Name] != spare[readyPods[j].Name] {
+			return spare[readyPods[i].Name] > spare[readyPods[j].Name]
+		}
+		return readyPods[i].Name < readyPods[j].Name
+	})
+
+	// the warm pool holds the free slots of the first pods.
+	poolSlots, poolPods := 0, 0
+	for _, pod := range readyPods {
+		if poolSlots >= r.policy.SpareSlots || spare[pod.Name] == 0 {
+			break
+		}
+		poolSlots += spare[pod.Name]
+		poolPods++
+	}
+	if poolSlots-1 >= r.policy.SpareSlots {
+		// the warm pool has a slot to spare, no choice is penalized.
+		return r.router.Route(ctx, pods, model, message)
+	}
+
+	candidates := make(map[string]*v1.Pod, len(readyPods)-poolPods)
+	for _, pod := range readyPods[poolPods:] {
+		candidates[pod.Name] = pod
+	}
+	// every pod has free slots of the warm pool, the penalty is the same for all of them.
+	if len(candidates) == 0 {
+		return r.router.Route(ctx, pods, model, message)
+	}
+
+	klog.V(4).InfoS("keeping headroom for high priority requests", "model", model, "warmPoolSlots", poolSlots,
+		"targetSpareSlots", r.policy.SpareSlots, "candidates", len(candidates), "readyPods", len(readyPods))
+	return r.router.Route(ctx, candidates, model, message)
+}
+
+// spareSlots returns the free slots of the pod. A pod without metrics, e.g. one that just became ready, is
+// assumed to be idle.
+func (r headroomRouter) spareSlots(pod *v1.Pod, model string) int {
+	load := 0.0
+	for _, metricName := range []string{metrics.NumRequestsRunning, metrics.NumRequestsWaiting} {
+		value, err := r.cache.GetPodModelMetric(pod.Name, model, metricName)
+		if err != nil {
+			return r.policy.SlotsPerPod
+		}
+		load += value.GetSimpleValue()
+	}
+	if spare := r.policy.SlotsPerPod - int(load); spare > 0 {
+		return spare
+	}
+	return 0
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
+)
+
+// fakeFleet simulates the in-flight requests of the ready pods of a model.
+type fakeFleet struct {
+	pods  map[string]*v1.Pod
+	load  map[string]int
+	slots int
+}
+
+func newFakeFleet(pods, slots int) *fakeFleet {
+	f := &fakeFleet{pods: map[string]*v1.Pod{}, load: map[string]int{}, slots: slots}
+	for i := 0; i < pods; i++ {
+		name := fmt.Sprintf("pod-%d", i)
+		f.pods[name] = &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1.PodStatus{
+				Phase:      v1.PodRunning,
+				PodIP:      fmt.Sprintf("10.0.0.%d", i),
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		}
+	}
+	return f
+}
+
+func (f *fakeFleet) GetPodModelMetric(podName, modelName string, metricName string) (metrics.MetricValue, error) {
+	if metricName != metrics.NumRequestsRunning {
+		return &metrics.SimpleMetricValue{Value: 0}, nil
+	}
+	return &metrics.SimpleMetricValue{Value: float64(f.load[podName])}, nil
+}
+
+func (f *fakeFleet) spareSlots() int {
+	spare := 0
+	for name := range f.pods {
+		if free := f.slots - f.load[name]; free > 0 {
+			spare += free
+		}
+	}
+	return spare
+}
+
+// freePods returns the number of pods with free slots.
+func (f *fakeFleet) freePods() int {
+	free := 0
+	for name := range f.pods {
+		if f.load[name] < f.slots {
+			free++
+		}
+	}
+	return free
+}
+
+// route sends a request through the router and returns the pod it landed on.
+func (f *fakeFleet) route(t *testing.T, router routing.Router) string {
+	t.Helper()
+	target, err := router.Route(context.Background(), f.pods, "llama-7b", "")
+	assert.NoError(t, err)
+	for name, pod := range f.pods {
+		if strings.HasPrefix(target, pod.Status.PodIP+":") {
+			f.load[name]++
+			return name
+		}
+	}
+	t.Fatalf("unknown target %s", target)
+	return ""
+}
+
+// leastLoadedRouter stands in for the decorated router, it picks the pod with the fewest in-flight requests.
+type leastLoadedRouter struct {
+	fleet *fakeFleet
+}
+
+func (r leastLoadedRouter) Route(ctx context.Context, pods map[string]*v1.Pod, model, message string) (string, error) {
+	names := make([]string, 0, len(pods))
+	for name := range pods {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no pods to forward request")
+	}
+	sort.Strings(names)
+	target := names[0]
+	for _, name := range names[1:] {
+		if r.fleet.load[name] < r.fleet.load[target] {
+			target = name
+		}
+	}
+	return pods[target].Status.PodIP + ":8000", nil
+}
+
+func TestHeadroomRouterReservesSpareSlots(t *testing.T) {
+	fleet := newFakeFleet(4, 8)
+	resolver := &headroomResolver{cache: fleet, policies: map[string]headroomPolicy{"llama-7b": {SlotsPerPod: 8, SpareSlots: 6}}}
+	inner := leastLoadedRouter{fleet: fleet}
+
+	for i := 0; i < 40; i++ {
+		fleet.route(t, resolver.decorate(inner, "llama-7b", priorityLow))
+	}
+	assert.Equal(t, 6, fleet.spareSlots(), "low priority requests leave the warm pool free")
+	assert.Less(t, fleet.freePods(), 4, "the warm pool is kept on some pods")
+
+	for i := 0; i < 6; i++ {
+		spare := fleet.spareSlots()
+		name := fleet.route(t, resolver.decorate(inner, "llama-7b", priorityHigh))
+		assert.LessOrEqual(t, fleet.load[name], 8, "high priority request %d found a free slot", i)
+		assert.Equal(t, spare-1, fleet.spareSlots())
+	}
+
+	// without the decorator the fleet is packed to capacity.
+	packed := newFakeFleet(4, 8)
+	for i := 0; i < 40; i++ {
+		packed.route(t, leastLoadedRouter{fleet: packed})
+	}
+	assert.Equal(t, 0, packed.spareSlots())
+}
+
+// simulateMixedPriorityLoad runs a fleet of 4 pods with 8 slots each under more load than it can serve, and returns
+// the fraction of the high priority requests that found a free slot.
+func simulateMixedPriorityLoad(t *testing.T, policies map[string]headroomPolicy) float64 {
+	fleet := newFakeFleet(4, 8)
+	resolver := &headroomResolver{cache: fleet, policies: policies}
+	inner := leastLoadedRouter{fleet: fleet}
+	rnd := rand.New(rand.NewSource(1))
+
+	highServedFree, highArrivals := 0, 0
+	for step := 0; step < 5000; step++ {
+		if rnd.Float64() < 0.45 {
+			// a random running request completes, and a request queued on its pod takes the slot.
+			running := []string{}
+			for i := 0; i < 4; i++ {
+				name := fmt.Sprintf("pod-%d", i)
+				for j := 0; j < fleet.load[name] && j < fleet.slots; j++ {
+					running = append(running, name)
+				}
+			}
+			if len(running) > 0 {
+				fleet.load[running[rnd.Intn(len(running))]]--
+			}
+			continue
+		}
+
+		spare, busyPods := fleet.spareSlots(), 4-fleet.freePods()
+		if rnd.Float64() < 0.1 {
+			highArrivals++
+			if name := fleet.route(t, resolver.decorate(inner, "llama-7b", priorityHigh)); fleet.load[name] <= 8 {
+				highServedFree++
+			}
+			continue
+		}
+
+		fleet.route(t, resolver.decorate(inner, "llama-7b", priorityLow))
+		policy, ok := policies["llama-7b"]
+		if !ok {
+			continue
+		}
+		if spare > policy.SpareSlots {
+			assert.Equal(t, spare-1, fleet.spareSlots(), "step %d: low priority requests use the slots above the warm pool", step)
+		} else if busyPods > 0 {
+			assert.Equal(t, spare, fleet.spareSlots(), "step %d: low priority requests queue on busy pods instead of the warm pool", step)
+		}
+	}
+
+	assert.Greater(t, highArrivals, 100)
+	return float64(highServedFree) / float64(highArrivals)
+}
+
+func TestHeadroomRouterMixedPriorityLoad(t *testing.T) {
+	// the warm pool is sized for the high priority load, a tenth of the requests.
+	withHeadroom := simulateMixedPriorityLoad(t, map[string]headroomPolicy{"llama-7b": {SlotsPerPod: 8, SpareSlots: 8}})
+	withoutHeadroom := simulateMixedPriorityLoad(t, nil)
+
+	assert.Greater(t, withHeadroom, 0.95, "high priority requests find the warm pool")
+	assert.Less(t, withoutHeadroom, 0.5, "high priority requests queue behind low priority requests")
+}
+
+func TestHeadroomResolver(t *testing.T) {
+	fleet := newFakeFleet(2, 4)
+	inner := leastLoadedRouter{fleet: fleet}
+	resolver := &headroomResolver{cache: fleet, policies: map[string]headroomPolicy{
+		"llama-7b":   {SlotsPerPod: 4, SpareSlots: 2},
+		"no-reserve": {SlotsPerPod: 4},
+	}}
+
+	assert.IsType(t, headroomRouter{}, resolver.decorate(inner, "llama-7b", priorityLow))
+	assert.Equal(t, inner, resolver.decorate(inner, "other", priorityLow), "models without a policy are routed unchanged")
+	assert.Equal(t, inner, resolver.decorate(inner, "no-reserve", priorityLow))
+	var disabled *headroomResolver
+	assert.Equal(t, inner, disabled.decorate(inner, "llama-7b", priorityLow))
+
+	policies, err := parseHeadroomPolicies(`{"llama-7b": {"slotsPerPod": 8, "spareSlots": 4}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, headroomPolicy{SlotsPerPod: 8, SpareSlots: 4}, policies["llama-7b"])
+	_, err = parseHeadroomPolicies(`{"llama-7b": {"spareSlots": 4}}`)
+	assert.Error(t, err)
+	_, err = parseHeadroomPolicies(`not json`)
+	assert.Error(t, err)
+}
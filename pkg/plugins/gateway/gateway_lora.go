@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/util/events"
+)
+
+const (
+	// reasonAdapterNotLoaded is the reason of the warning event of a model adapter no ready pod loaded.
+	reasonAdapterNotLoaded = "AdapterNotLoaded"
+	// adapterEventInterval limits the warning events of a model adapter to one per interval, every request of
+	// the model adapter falls back while it is not loaded.
+	adapterEventInterval = time.Minute
+)
+
+// loraFallbackCache is the subset of the cache the LoRA fallback reads.
+type loraFallbackCache interface {
+	GetModelAdapter(modelName string) (*modelv1alpha1.ModelAdapter, bool)
+}
+
+// loraFallback routes the requests of a model adapter no ready pod loaded to the pods of its base model, and
+// reports the model adapter with a warning event so that it gets loaded. The requests of a loaded model adapter
+// are only routed to the pods which loaded it, the cache maps the model adapter to the instances of its status.
+type loraFallback struct {
+	cache    loraFallbackCache
+	recorder record.EventRecorder
+}
+
+func newLoraFallback(c loraFallbackCache, client kubernetes.Interface) *loraFallback {
+	f := &loraFallback{cache: c}
+	if client != nil {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+		recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "aibrix-gateway-plugins"})
+		f.recorder = events.NewRateLimitedRecorder(recorder, adapterEventInterval)
+	}
+	return f
+}
+
+// baseModel returns the base model whose pods serve the requests of the model, false if the model is not a model
+// adapter with a base model.
+func (f *loraFallback) baseModel(requestID, model string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	adapter, ok := f.cache.GetModelAdapter(model)
+	if !ok || adapter.Spec.BaseModel == nil || *adapter.Spec.BaseModel == "" {
+		return "", false
+	}
+	baseModel := *adapter.Spec.BaseModel
+	klog.InfoS("no ready pod loaded the model adapter, routing to the pods of its base model", "requestID", requestID, "model", model, "baseModel", baseModel)
+	if f.recorder != nil {
+		f.recorder.Eventf(adapter, v1.EventTypeWarning, reasonAdapterNotLoaded,
+			"No ready pod loaded the adapter, its requests are routed to the pods of base model %s", baseModel)
+	}
+	return baseModel, true
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+type fakeLoraFallbackCache map[string]*modelv1alpha1.ModelAdapter
+
+func (c fakeLoraFallbackCache) GetModelAdapter(modelName string) (*modelv1alpha1.ModelAdapter, bool) {
+	model, ok := c[modelName]
+	return model, ok
+}
+
+func newTestLoraAdapter(name, baseModel string) *modelv1alpha1.ModelAdapter {
+	adapter := &modelv1alpha1.ModelAdapter{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	if baseModel != "" {
+		adapter.Spec.BaseModel = &baseModel
+	}
+	return adapter
+}
+
+func TestLoraFallbackBaseModel(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	f := &loraFallback{
+		cache: fakeLoraFallbackCache{
+			"lora-sql":  newTestLoraAdapter("lora-sql", "llama-7b"),
+			"lora-chat": newTestLoraAdapter("lora-chat", ""),
+		},
+		recorder: recorder,
+	}
+
+	baseModel, ok := f.baseModel("r1", "lora-sql")
+	assert.True(t, ok)
+	assert.Equal(t, "llama-7b", baseModel)
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Contains(t, <-recorder.Events, reasonAdapterNotLoaded)
+	}
+
+	// the models which are not model adapters, or have no base model, do not fall back.
+	_, ok = f.baseModel("r2", "lora-chat")
+	assert.False(t, ok)
+	_, ok = f.baseModel("r3", "llama-7b")
+	assert.False(t, ok)
+	assert.Empty(t, recorder.Events)
+
+	var disabled *loraFallback
+	_, ok = disabled.baseModel("r4", "lora-sql")
+	assert.False(t, ok)
+}
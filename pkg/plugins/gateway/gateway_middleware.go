@@ -0,0 +1,408 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/engineapi"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	middlewareAuth       = "auth"
+	middlewareRateLimit  = "ratelimit"
+	middlewareAccounting = "accounting"
+
+	// middlewarePolicyKey is the Redis hash of the middleware policies, the fields are the model names and the
+	// values the JSON encoded middlewarePolicy of the model.
+	middlewarePolicyKey = "aibrix-middleware-policies"
+
+	// middlewarePolicyReloadInterval is how often the middleware policies are read from Redis.
+	middlewarePolicyReloadInterval = 10 * time.Second
+
+	// extProcAttributesKey is the key of the attributes the ext_proc filter sends, and sourceAddressAttribute the
+	// attribute of the address Envoy received the request from.
+	extProcAttributesKey   = "envoy.filters.http.ext_proc"
+	sourceAddressAttribute = "source.address"
+)
+
+var (
+	// defaultMiddlewares run for the models without a policy, and the requests no rule of the policy matches.
+	defaultMiddlewares = []string{middlewareAuth, middlewareRateLimit, middlewareAccounting}
+
+	defaultMiddlewareChain = mustCompileMiddlewareChain(defaultMiddlewares)
+)
+
+// middlewarePolicy selects the middlewares that run for the requests of a model, e.g. to let the batch pipelines
+// inside the cluster skip auth and ratelimit. The first rule matching the request applies, the requests no rule
+// matches run the default middlewares. Routing always runs.
+type middlewarePolicy struct {
+	Rules []middlewareRule `json:"rules"`
+}
+
+type middlewareRule struct {
+	// SourceCIDRs match the client address of the request, any address if empty. A rule without auth must set
+	// them, so that only the internal traffic can skip auth.
+	SourceCIDRs []string `json:"sourceCIDRs,omitempty"`
+	// Headers match the requests with all of the headers set to the values, header names are case-insensitive.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Middlewares run in order, one of "auth", "ratelimit" or "accounting".
+	Middlewares []string `json:"middlewares"`
+}
+
+// requestAccount is the identity of a request, and what the middlewares of its model recorded about it.
+type requestAccount struct {
+	username string
+	clientIP net.IP
+	headers  map[string]string
+
+	user       utils.User
+	rpm, tpm   int64
+	accounting bool
+	// promptTokens is the estimate of the prompt tokens of the request, and reservedTokens the tokens counted
+	// against the TPM of the user on admission, corrected from the usage of the response.
+	promptTokens   int64
+	reservedTokens int64
+	// rateLimits is the usage of the limits of the user the request was admitted with, nil if they were not checked.
+	rateLimits *rateLimitStatus
+	// tenantPool is the pool of the pods of the tenant of the user the request is routed to.
+	tenantPool string
+	// deprecation warns the client of a request to a deprecated model, nil if the model is not deprecated.
+	deprecation *deprecationNotice
+	// logSampled tells whether the lifecycle of the request is logged, see observability.
+	logSampled bool
+	// responseModel is the model of the streamed response, and streamedTokens the estimate of the tokens streamed
+	// so far, to meter the usage of a stream which does not report it.
+	responseModel  string
+	streamedTokens int64
+	// streamTail is the incomplete event at the end of the last chunk of the streamed response, streamStarted tells
+	// the streamed response started, and usageRecorded that the usage of the response was accounted.
+	streamTail    []byte
+	streamStarted bool
+	usageRecorded bool
+	// engineAPI translates the response of a pod serving the native API of its engine, nil for the OpenAI API.
+	engineAPI engineapi.ResponseTranslator
+	// queueHeartbeat tells the request waits in the admission queue behind the heartbeat proxy, the response then
+	// starts with the heartbeat comments.
+	queueHeartbeat bool
+}
+
+// requestMiddleware runs on the request once its model is known, it returns a response to reject the request.
+type requestMiddleware func(s *Server, ctx context.Context, requestID string, account *requestAccount) *extProcPb.ProcessingResponse
+
+// middlewareChain is the compiled list of middlewares of a rule, accounting runs on the response.
+type middlewareChain struct {
+	names      []string
+	request    []requestMiddleware
+	auth       bool
+	accounting bool
+}
+
+type compiledMiddlewareRule struct {
+	sources []*net.IPNet
+	headers map[string]string
+	chain   *middlewareChain
+}
+
+func compileMiddlewareChain(names []string) (*middlewareChain, error) {
+	chain := &middlewareChain{names: names}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("duplicated middleware %q", name)
+		}
+		seen[name] = true
+		switch name {
+		case middlewareAuth:
+			chain.auth = true
+			chain.request = append(chain.request, authenticate)
+		case middlewareRateLimit:
+			// the limits are the ones of the authenticated user.
+			if !chain.auth {
+				return nil, fmt.Errorf("middleware %q requires %q to run before it", middlewareRateLimit, middlewareAuth)
+			}
+			chain.request = append(chain.request, rateLimit)
+		case middlewareAccounting:
+			chain.accounting = true
+		default:
+			return nil, fmt.Errorf("unknown middleware %q, supported: %s", name, strings.Join(defaultMiddlewares, ", "))
+		}
+	}
+	return chain, nil
+}
+
+func mustCompileMiddlewareChain(names []string) *middlewareChain {
+	chain, err := compileMiddlewareChain(names)
+	if err != nil {
+		panic(err)
+	}
+	return chain
+}
+
+func compileMiddlewarePolicy(policy middlewarePolicy) ([]compiledMiddlewareRule, error) {
+	rules := make([]compiledMiddlewareRule, 0, len(policy.Rules))
+	for i, rule := range policy.Rules {
+		chain, err := compileMiddlewareChain(rule.Middlewares)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		compiled := compiledMiddlewareRule{chain: chain, headers: map[string]string{}}
+		for _, cidr := range rule.SourceCIDRs {
+			_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i, err)
+			}
+			compiled.sources = append(compiled.sources, ipNet)
+		}
+		if !chain.auth && len(compiled.sources) == 0 {
+			return nil, fmt.Errorf("rule %d: disabling %q requires source CIDRs", i, middlewareAuth)
+		}
+		for key, value := range rule.Headers {
+			compiled.headers[strings.ToLower(key)] = value
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+func (r *compiledMiddlewareRule) matches(clientIP net.IP, headers map[string]string) bool {
+	if len(r.sources) > 0 {
+		if clientIP == nil || !containsIP(r.sources, clientIP) {
+			return false
+		}
+	}
+	for key, value := range r.headers {
+		if headers[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// middlewareConfig holds the compiled middleware policies of the models, which are reloaded from Redis so that
+// they can be updated without restarting the gateway.
+type middlewareConfig struct {
+	mu       sync.RWMutex
+	policies map[string][]compiledMiddlewareRule
+	load     func(ctx context.Context) (map[string]string, error)
+}
+
+func newMiddlewareConfig(redisClient redis.UniversalClient) *middlewareConfig {
+	return &middlewareConfig{
+		policies: map[string][]compiledMiddlewareRule{},
+		load: func(ctx context.Context) (map[string]string, error) {
+			return redisClient.HGetAll(ctx, middlewarePolicyKey).Result()
+		},
+	}
+}
+
+// reload reads and compiles the policies of the models, an invalid policy keeps the current one of its model.
+func (c *middlewareConfig) reload(ctx context.Context) {
+	raw, err := c.load(ctx)
+	if err != nil {
+		klog.ErrorS(err, "failed to load middleware policies, keeping the current ones")
+		return
+	}
+
+	c.mu.RLock()
+	current := c.policies
+	c.mu.RUnlock()
+
+	policies := make(map[string][]compiledMiddlewareRule, len(raw))
+	for model, value := range raw {
+		var policy middlewarePolicy
+		err := json.Unmarshal([]byte(value), &policy)
+		var rules []compiledMiddlewareRule
+		if err == nil {
+			rules, err = compileMiddlewarePolicy(policy)
+		}
+		if err != nil {
+			klog.ErrorS(err, "invalid middleware policy, keeping the current one", "model", model)
+			if rules, ok := current[model]; ok {
+				policies[model] = rules
+			}
+			continue
+		}
+		policies[model] = rules
+	}
+
+	c.mu.Lock()
+	c.policies = policies
+	c.mu.Unlock()
+}
+
+// start reloads the policies every reload interval until stopCh is closed.
+func (c *middlewareConfig) start(stopCh <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(middlewarePolicyReloadInterval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), middlewarePolicyReloadInterval)
+			c.reload(ctx)
+			cancel()
+			select {
+			case <-ticker.C:
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// resolve returns the middleware chain of the request, the default chain if no rule of the model matches it.
+func (c *middlewareConfig) resolve(model string, clientIP net.IP, headers map[string]string) *middlewareChain {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := range c.policies[model] {
+		if rule := &c.policies[model][i]; rule.matches(clientIP, headers) {
+			return rule.chain
+		}
+	}
+	return defaultMiddlewareChain
+}
+
+// newRequestAccount reads the identity of the request from its headers, and its client address from the peer
+// address Envoy received it from and x-forwarded-for.
+func newRequestAccount(headers *extProcPb.HttpHeaders, trustedHops int) *requestAccount {
+	account := &requestAccount{headers: make(map[string]string, len(headers.GetHeaders().GetHeaders()))}
+	for _, header := range headers.GetHeaders().GetHeaders() {
+		key := strings.ToLower(header.Key)
+		value := string(header.RawValue)
+		account.headers[key] = value
+		if key == "user" {
+			account.username = value
+		}
+	}
+	account.clientIP = getClientIP(getPeerAddress(headers), account.headers, trustedHops)
+	return account
+}
+
+// getPeerAddress returns the address Envoy received the request from, the source.address attribute the ext_proc
+// filter sends when configured to. It is empty if the attribute is not sent.
+func getPeerAddress(headers *extProcPb.HttpHeaders) string {
+	attributes := headers.GetAttributes()[extProcAttributesKey]
+	address := attributes.GetFields()[sourceAddressAttribute].GetStringValue()
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// getClientIP returns the client address of the request, the address trustedHops proxies before Envoy in the chain
+// of x-forwarded-for completed with the peer address. The entries before it are set by the client, and can not be
+// trusted to allow a request to skip auth. Envoy appends the peer address to x-forwarded-for with
+// use_remote_address, without the peer address attribute the last entry stands for it.
+func getClientIP(peer string, headers map[string]string, trustedHops int) net.IP {
+	var chain []string
+	if forwardedFor := headers["x-forwarded-for"]; forwardedFor != "" {
+		for _, address := range strings.Split(forwardedFor, ",") {
+			chain = append(chain, strings.TrimSpace(address))
+		}
+	}
+	if peer != "" && (len(chain) == 0 || chain[len(chain)-1] != peer) {
+		chain = append(chain, peer)
+	}
+	i := len(chain) - 1 - trustedHops
+	if i < 0 {
+		return nil
+	}
+	return net.ParseIP(chain[i])
+}
+
+// runMiddlewares runs the middlewares of the model that apply to the request.
+func (s *Server) runMiddlewares(ctx context.Context, requestID, model string, account *requestAccount) *extProcPb.ProcessingResponse {
+	chain := s.middlewares.resolve(model, account.clientIP, account.headers)
+	klog.V(4).InfoS("running middlewares", "requestID", requestID, "model", model, "middlewares", chain.names)
+	// without auth, the request is accounted to the user it names.
+	account.user = utils.User{Name: account.username}
+	account.accounting = chain.accounting
+	for _, middleware := range chain.request {
+		if errRes := middleware(s, ctx, requestID, account); errRes != nil {
+			return errRes
+		}
+	}
+	return nil
+}
+
+func authenticate(s *Server, ctx context.Context, requestID string, account *requestAccount) *extProcPb.ProcessingResponse {
+	if account.username == "" {
+		return nil
+	}
+	user, err := s.users.Get(ctx, account.username)
+	switch {
+	case err == nil && !user.IsEnabled():
+		klog.InfoS("rejecting request of disabled user", "requestID", requestID, "username", account.username)
+		return generateErrorResponse(
+			envoyTypePb.StatusCode_Forbidden,
+			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+				Key: HeaderErrorUser, RawValue: []byte("true"),
+			}}},
+			fmt.Sprintf("user %s is disabled", account.username))
+	case err == nil:
+		account.user = user
+	case errors.Is(err, utils.ErrUserNotFound):
+		// the unknown users are either rejected, or limited by the default limits.
+		if !s.rateLimits.allowUnknownUsers {
+			klog.InfoS("rejecting request of unknown user", "requestID", requestID, "username", account.username)
+			return generateErrorResponse(
+				envoyTypePb.StatusCode_Unauthorized,
+				[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+					Key: HeaderErrorUser, RawValue: []byte("true"),
+				}}},
+				fmt.Sprintf("user %s does not exist", account.username))
+		}
+	default:
+		// the requests are admitted with the default limits while Redis is unavailable, rather than rejected.
+		klog.Warningf("failed to get user %s, admitting request %s with the default limits: %v", account.username, requestID, err)
+		rateLimitFailOpen.WithLabelValues("user").Inc()
+	}
+	return nil
+}
+
+func rateLimit(s *Server, ctx context.Context, requestID string, account *requestAccount) *extProcPb.ProcessingResponse {
+	if account.user.Name == "" {
+		return nil
+	}
+	if errRes := s.checkLimits(ctx, requestID, account); errRes != nil {
+		klog.InfoS("request exceeds the limits of its user", "requestID", requestID, "username", account.user.Name)
+		return errRes
+	}
+	return nil
+}
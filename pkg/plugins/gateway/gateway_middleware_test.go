@@ -0,0 +1,234 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+func TestCompileMiddlewareChain(t *testing.T) {
+	testCases := []struct {
+		name       string
+		names      []string
+		requests   int
+		auth       bool
+		accounting bool
+		err        bool
+	}{
+		{name: "default", names: defaultMiddlewares, requests: 2, auth: true, accounting: true},
+		{name: "accounting only", names: []string{middlewareAccounting}, requests: 0, accounting: true},
+		{name: "auth without ratelimit", names: []string{middlewareAccounting, middlewareAuth}, requests: 1, auth: true, accounting: true},
+		{name: "empty", names: nil},
+		{name: "unknown middleware", names: []string{middlewareAuth, "audit"}, err: true},
+		{name: "duplicated middleware", names: []string{middlewareAuth, middlewareAuth}, err: true},
+		{name: "ratelimit without auth", names: []string{middlewareRateLimit, middlewareAccounting}, err: true},
+		{name: "ratelimit before auth", names: []string{middlewareRateLimit, middlewareAuth}, err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chain, err := compileMiddlewareChain(tc.names)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Len(t, chain.request, tc.requests)
+				assert.Equal(t, tc.auth, chain.auth)
+				assert.Equal(t, tc.accounting, chain.accounting)
+			}
+		})
+	}
+}
+
+func TestCompileMiddlewarePolicy(t *testing.T) {
+	_, err := compileMiddlewarePolicy(middlewarePolicy{Rules: []middlewareRule{
+		{Headers: map[string]string{"x-batch": "true"}, Middlewares: []string{middlewareAccounting}},
+	}})
+	assert.Error(t, err, "auth can only be disabled for source CIDRs")
+
+	_, err = compileMiddlewarePolicy(middlewarePolicy{Rules: []middlewareRule{
+		{SourceCIDRs: []string{"10.0.0.0/33"}, Middlewares: []string{middlewareAccounting}},
+	}})
+	assert.Error(t, err)
+
+	rules, err := compileMiddlewarePolicy(middlewarePolicy{Rules: []middlewareRule{
+		{SourceCIDRs: []string{"10.0.0.0/8", " fd00::/8"}, Headers: map[string]string{"X-Batch": "true"}, Middlewares: []string{middlewareAccounting}},
+		{Middlewares: []string{middlewareAuth, middlewareAccounting}},
+	}})
+	if assert.NoError(t, err) && assert.Len(t, rules, 2) {
+		assert.Len(t, rules[0].sources, 2)
+		assert.Equal(t, map[string]string{"x-batch": "true"}, rules[0].headers)
+	}
+}
+
+func TestMiddlewareConfigResolve(t *testing.T) {
+	c := &middlewareConfig{load: func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{
+			"llama": `{"rules": [
+				{"sourceCIDRs": ["10.0.0.0/8"], "headers": {"x-pipeline": "batch"}, "middlewares": ["accounting"]},
+				{"sourceCIDRs": ["192.168.0.0/16"], "middlewares": ["auth", "accounting"]}
+			]}`,
+			"mistral": `{"rules": [{"middlewares": ["accounting"]}]}`,
+		}, nil
+	}}
+	c.reload(context.Background())
+
+	batch := map[string]string{"x-pipeline": "batch"}
+	testCases := []struct {
+		name     string
+		model    string
+		clientIP string
+		headers  map[string]string
+		expected []string
+	}{
+		{name: "internal batch traffic", model: "llama", clientIP: "10.1.2.3", headers: batch, expected: []string{middlewareAccounting}},
+		{name: "external address", model: "llama", clientIP: "203.0.113.7", headers: batch, expected: defaultMiddlewares},
+		{name: "no client address", model: "llama", headers: batch, expected: defaultMiddlewares},
+		{name: "header mismatch", model: "llama", clientIP: "10.1.2.3", expected: defaultMiddlewares},
+		{name: "second rule", model: "llama", clientIP: "192.168.1.1", expected: []string{middlewareAuth, middlewareAccounting}},
+		{name: "model without policy", model: "qwen", clientIP: "10.1.2.3", headers: batch, expected: defaultMiddlewares},
+		{name: "invalid policy", model: "mistral", clientIP: "10.1.2.3", headers: batch, expected: defaultMiddlewares},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chain := c.resolve(tc.model, net.ParseIP(tc.clientIP), tc.headers)
+			assert.Equal(t, tc.expected, chain.names)
+		})
+	}
+
+	// an invalid update or a failed load keeps the compiled policy of the model.
+	c.load = func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"llama": `{"rules": [{"middlewares": ["audit"]}]}`}, nil
+	}
+	c.reload(context.Background())
+	assert.Equal(t, []string{middlewareAccounting}, c.resolve("llama", net.ParseIP("10.1.2.3"), batch).names)
+	c.load = func(ctx context.Context) (map[string]string, error) {
+		return nil, errors.New("connection refused")
+	}
+	c.reload(context.Background())
+	assert.Equal(t, []string{middlewareAccounting}, c.resolve("llama", net.ParseIP("10.1.2.3"), batch).names)
+}
+
+func newTestHttpHeaders(peer string, headers ...*configPb.HeaderValue) *extProcPb.HttpHeaders {
+	h := &extProcPb.HttpHeaders{Headers: &configPb.HeaderMap{Headers: headers}}
+	if peer != "" {
+		h.Attributes = map[string]*structpb.Struct{extProcAttributesKey: {Fields: map[string]*structpb.Value{
+			sourceAddressAttribute: structpb.NewStringValue(peer),
+		}}}
+	}
+	return h
+}
+
+func TestNewRequestAccount(t *testing.T) {
+	account := newRequestAccount(newTestHttpHeaders("",
+		&configPb.HeaderValue{Key: "User", RawValue: []byte("alice")},
+		&configPb.HeaderValue{Key: "X-Pipeline", RawValue: []byte("batch")},
+		// the client sets the first entry, Envoy appends the address it received the request from.
+		&configPb.HeaderValue{Key: "x-forwarded-for", RawValue: []byte("10.1.2.3, 203.0.113.7")},
+	), 0)
+	assert.Equal(t, "alice", account.username)
+	assert.Equal(t, "batch", account.headers["x-pipeline"])
+	assert.Equal(t, "203.0.113.7", account.clientIP.String())
+
+	account = newRequestAccount(newTestHttpHeaders("", &configPb.HeaderValue{Key: "x-forwarded-for", RawValue: []byte("unknown")}), 0)
+	assert.Nil(t, account.clientIP)
+}
+
+func TestGetClientIP(t *testing.T) {
+	testCases := []struct {
+		name        string
+		peer        string
+		forwarded   string
+		trustedHops int
+		expected    string
+	}{
+		{name: "peer address", peer: "203.0.113.7:52344", expected: "203.0.113.7"},
+		{name: "peer address appended by Envoy", peer: "203.0.113.7:52344", forwarded: "10.1.2.3, 203.0.113.7", expected: "203.0.113.7"},
+		// Envoy did not append the peer address, the entries of x-forwarded-for are all set by the client.
+		{name: "spoofed x-forwarded-for", peer: "203.0.113.7:52344", forwarded: "10.1.2.3", expected: "203.0.113.7"},
+		{name: "last entry without the peer address", forwarded: "10.1.2.3, 203.0.113.7", expected: "203.0.113.7"},
+		{name: "trusted proxy", peer: "192.0.2.1:443", forwarded: "10.1.2.3, 203.0.113.7", trustedHops: 1, expected: "203.0.113.7"},
+		{name: "trusted proxy appended by Envoy", peer: "192.0.2.1:443", forwarded: "10.1.2.3, 203.0.113.7, 192.0.2.1", trustedHops: 1, expected: "203.0.113.7"},
+		{name: "more trusted hops than addresses", peer: "192.0.2.1:443", trustedHops: 1},
+		{name: "no address"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var headers []*configPb.HeaderValue
+			if tc.forwarded != "" {
+				headers = append(headers, &configPb.HeaderValue{Key: "X-Forwarded-For", RawValue: []byte(tc.forwarded)})
+			}
+			account := newRequestAccount(newTestHttpHeaders(tc.peer, headers...), tc.trustedHops)
+			if tc.expected == "" {
+				assert.Nil(t, account.clientIP)
+				return
+			}
+			assert.Equal(t, tc.expected, account.clientIP.String())
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	disabled := false
+	_, err := utils.CreateUser(ctx, utils.User{Name: "alice", Rpm: 10, Tpm: 100}, client)
+	require.NoError(t, err)
+	_, err = utils.CreateUser(ctx, utils.User{Name: "bob", Enabled: &disabled}, client)
+	require.NoError(t, err)
+	s := &Server{users: utils.NewUserCache(client, time.Minute)}
+
+	account := &requestAccount{username: "alice"}
+	assert.Nil(t, authenticate(s, ctx, "r1", account))
+	assert.Equal(t, int64(10), account.user.Rpm)
+	assert.Equal(t, int64(100), account.user.Tpm)
+
+	resp := authenticate(s, ctx, "r2", &requestAccount{username: "bob"})
+	assert.Equal(t, envoyTypePb.StatusCode_Forbidden, resp.GetImmediateResponse().GetStatus().GetCode())
+
+	resp = authenticate(s, ctx, "r3", &requestAccount{username: "carol"})
+	assert.Equal(t, envoyTypePb.StatusCode_Unauthorized, resp.GetImmediateResponse().GetStatus().GetCode())
+	s.rateLimits.allowUnknownUsers = true
+	account = &requestAccount{username: "carol", user: utils.User{Name: "carol"}}
+	assert.Nil(t, authenticate(s, ctx, "r4", account))
+	assert.Equal(t, "carol", account.user.Name)
+
+	// the requests are admitted with the default limits while Redis is unavailable.
+	s.rateLimits.allowUnknownUsers = false
+	server.SetError("unavailable")
+	assert.Nil(t, authenticate(s, ctx, "r5", &requestAccount{username: "dave"}))
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// errRequestNotObject is returned for a request body which is not a JSON object.
+	errRequestNotObject = errors.New("request body is not a JSON object")
+	// errModelNotString is returned for a request body whose model field is not a string.
+	errModelNotString = errors.New("model of the request is not a string")
+	// errDuplicateModel is returned for a request body with several model fields.
+	errDuplicateModel = errors.New("request body has several model fields")
+)
+
+// readRequestModel returns the model named by the model field of the OpenAI request body, empty if the body has
+// no model field. The other members of the body are skipped without keeping them, so that the requests of unknown models
+// are rejected before their prompts, which may be large, are decoded. A body with several model fields is rejected,
+// the engines serve the last one while the checks of the gateway would apply to another.
+func readRequestModel(body []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	if tok != json.Delim('{') {
+		return "", errRequestNotObject
+	}
+	model, found := "", false
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return "", err
+		}
+		if tok != "model" {
+			if err := skipValue(dec); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if found {
+			return "", errDuplicateModel
+		}
+		tok, err = dec.Token()
+		if err != nil {
+			return "", err
+		}
+		var ok bool
+		if model, ok = tok.(string); !ok {
+			return "", fmt.Errorf("%w: %v", errModelNotString, tok)
+		}
+		found = true
+	}
+	if _, err = dec.Token(); err != nil {
+		return "", err
+	}
+	return model, nil
+}
+
+// skipValue skips the next value of the decoder, including the members or elements of an object or array.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRequestModel(t *testing.T) {
+	testCases := []struct {
+		name          string
+		body          string
+		expectedModel string
+		expectedErr   error
+		expectErr     bool
+	}{
+		{
+			name:          "chat completion",
+			body:          `{"messages": [{"role": "user", "content": "{\"model\": \"other\"}"}], "model": "llama-7b", "stream": true}`,
+			expectedModel: "llama-7b",
+		},
+		{
+			name:          "completion",
+			body:          `{"model": "lora-1", "prompt": "Say this is a test!"}`,
+			expectedModel: "lora-1",
+		},
+		{
+			name:          "embeddings",
+			body:          `{"input": ["a", "b"], "encoding_format": "float", "model": "bge-small"}`,
+			expectedModel: "bge-small",
+		},
+		{
+			name:          "nested model fields are not the model of the request",
+			body:          `{"tools": [{"function": {"model": "other"}}], "metadata": {"model": "other"}}`,
+			expectedModel: "",
+		},
+		{
+			name:        "model is not a string",
+			body:        `{"model": 7}`,
+			expectedErr: errModelNotString,
+		},
+		{
+			name:        "duplicate model fields",
+			body:        `{"model": "allowed", "prompt": "Say this is a test!", "model": "restricted"}`,
+			expectedErr: errDuplicateModel,
+		},
+		{
+			name:          "nested model fields do not duplicate the model",
+			body:          `{"model": "llama-7b", "metadata": {"model": "other"}}`,
+			expectedModel: "llama-7b",
+		},
+		{
+			name:        "not an object",
+			body:        `["llama-7b"]`,
+			expectedErr: errRequestNotObject,
+		},
+		{
+			name:      "invalid body",
+			body:      `{"messages": [}`,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			model, err := readRequestModel([]byte(tc.body))
+			switch {
+			case tc.expectedErr != nil:
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected error %v", err)
+			case tc.expectErr:
+				assert.Error(t, err)
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedModel, model)
+			}
+		})
+	}
+}
+
+func TestReadRequestModelSkipsThePrompt(t *testing.T) {
+	// a large prompt after the model is skipped, a model field after it is still found.
+	body := `{"model": "llama-7b", "prompt": "` + strings.Repeat("a", 1<<20) + `", "model": "other"}`
+	_, err := readRequestModel([]byte(body))
+	assert.True(t, errors.Is(err, errDuplicateModel), "unexpected error %v", err)
+}
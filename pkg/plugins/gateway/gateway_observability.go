@@ -0,0 +1,399 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"runtime/metrics"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	EnvAccessLogSampleRate      = "AIBRIX_GATEWAY_ACCESS_LOG_SAMPLE_RATE"
+	EnvAccessLogSlowThresholdMs = "AIBRIX_GATEWAY_ACCESS_LOG_SLOW_THRESHOLD_MS"
+	EnvMetricsPreaggregation    = "AIBRIX_GATEWAY_METRICS_PREAGGREGATION"
+	EnvObservabilityCPUBudget   = "AIBRIX_GATEWAY_OBSERVABILITY_CPU_BUDGET"
+
+	defaultAccessLogSampleRate    = 1.0
+	defaultAccessLogSlowThreshold = 30 * time.Second
+
+	// minAccessLogSampleRate is the floor of the sample rate reduced by the observability budget.
+	minAccessLogSampleRate = 0.001
+	// observabilityBudgetRecovery is the fraction of the CPU budget below which the reduced sample rate recovers,
+	// so that the rate does not flap around the budget.
+	observabilityBudgetRecovery = 0.8
+	// observabilityInterval is the period of the CPU budget checks and of the publication of the pre-aggregated
+	// histograms.
+	observabilityInterval = time.Second
+
+	// the decisions of the access log of a finished request, the failed and slow requests are always logged.
+	accessLogSampled = "sampled"
+	accessLogFailed  = "error"
+	accessLogSlow    = "slow"
+	accessLogDropped = "dropped"
+)
+
+// requestDurationBuckets are the buckets of the request durations, from a short completion to a long stream.
+var requestDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+var (
+	accessLogDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aibrix_gateway_access_log_decisions_total",
+		Help: "Number of finished requests by access log decision: sampled, error and slow requests are logged, dropped ones are not.",
+	}, []string{"decision"})
+	accessLogSampleRate = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "aibrix_gateway_access_log_sample_rate",
+		Help: "Sample rate of the access logs of the successful requests, lowered by the observability budget under CPU pressure.",
+	})
+
+	requestDurationDesc = prometheus.NewDesc(
+		"aibrix_gateway_request_duration_seconds",
+		"Duration of the requests from their headers to their last response chunk, by model and status.",
+		[]string{"model", "status"}, nil,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(accessLogDecisions, accessLogSampleRate)
+}
+
+// requestLog is the sampling decision of the logs of a request, taken when the request starts. The logs of the
+// request lifecycle are written for the sampled requests only, the access log of a failed or slow request is
+// written regardless once it finishes.
+type requestLog struct {
+	sampled bool
+	// rate is the sample rate the decision was taken with, the sampled logs stand for 1/rate requests.
+	rate float64
+}
+
+// requestSummary describes a finished request in its access log.
+type requestSummary struct {
+	model      string
+	targetPod  string
+	statusCode int
+	stream     bool
+	latency    time.Duration
+}
+
+// observability samples the access logs of the requests and records their durations. The sample rate is lowered
+// while the gateway spends more CPU than its observability budget.
+type observability struct {
+	baseRate      float64
+	slowThreshold time.Duration
+	// cpuBudget is the fraction of the CPU time of GOMAXPROCS above which the sample rate is lowered, 0 disables it.
+	cpuBudget float64
+	// rate holds the float64 bits of the current sample rate.
+	rate atomic.Uint64
+
+	durations durationRecorder
+	// preaggregated is the recorder of the durations when they are pre-aggregated, nil otherwise.
+	preaggregated *preaggregatedHistogram
+
+	rand func() float64
+	// cpu returns the cumulative busy and total CPU time of the process.
+	cpu                 func() (busy, total float64)
+	lastBusy, lastTotal float64
+}
+
+func newObservabilityFromEnv() *observability {
+	preaggregate := false
+	if value, exists := utils.CheckEnvExists(EnvMetricsPreaggregation); exists {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			klog.Infof("invalid %s: %s, falling back to default", EnvMetricsPreaggregation, value)
+		} else {
+			preaggregate = enabled
+		}
+	}
+	o := newObservability(
+		loadFractionEnv(EnvAccessLogSampleRate, defaultAccessLogSampleRate),
+		loadDurationMsEnv(EnvAccessLogSlowThresholdMs, defaultAccessLogSlowThreshold),
+		loadFractionEnv(EnvObservabilityCPUBudget, 0),
+		preaggregate)
+	if err := prometheus.Register(o.durations); err != nil {
+		klog.ErrorS(err, "failed to register the request duration histogram")
+	}
+	return o
+}
+
+func newObservability(rate float64, slowThreshold time.Duration, cpuBudget float64, preaggregate bool) *observability {
+	o := &observability{
+		baseRate:      rate,
+		slowThreshold: slowThreshold,
+		cpuBudget:     cpuBudget,
+		rand:          rand.Float64,
+		cpu:           readRuntimeCPU,
+	}
+	o.setSampleRate(rate)
+	if preaggregate {
+		o.preaggregated = newPreaggregatedHistogram(requestDurationDesc, requestDurationBuckets)
+		o.durations = o.preaggregated
+	} else {
+		o.durations = newHistogramRecorder()
+	}
+	return o
+}
+
+// start checks the CPU budget and publishes the pre-aggregated histograms every interval until stopCh is closed.
+func (o *observability) start(stopCh <-chan struct{}) {
+	if o.cpuBudget <= 0 && o.preaggregated == nil {
+		return
+	}
+	o.lastBusy, o.lastTotal = o.cpu()
+	go func() {
+		ticker := time.NewTicker(observabilityInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if o.cpuBudget > 0 {
+					o.adjustToBudget()
+				}
+				if o.preaggregated != nil {
+					o.preaggregated.publish()
+				}
+			}
+		}
+	}()
+}
+
+func (o *observability) sampleRate() float64 {
+	return math.Float64frombits(o.rate.Load())
+}
+
+func (o *observability) setSampleRate(rate float64) {
+	o.rate.Store(math.Float64bits(rate))
+	accessLogSampleRate.Set(rate)
+}
+
+// adjustToBudget halves the sample rate while the CPU utilization of the gateway exceeds its budget, and doubles it
+// back up to the configured rate once the utilization is well below the budget. The runtime refreshes its CPU
+// estimates at each garbage collection, so a gateway without any since the last check is considered idle.
+func (o *observability) adjustToBudget() {
+	busy, total := o.cpu()
+	utilization := 0.0
+	if total > o.lastTotal {
+		utilization = (busy - o.lastBusy) / (total - o.lastTotal)
+	}
+	o.lastBusy, o.lastTotal = busy, total
+
+	rate := o.sampleRate()
+	switch {
+	case utilization > o.cpuBudget:
+		rate = math.Max(rate/2, math.Min(minAccessLogSampleRate, o.baseRate))
+	case utilization < o.cpuBudget*observabilityBudgetRecovery:
+		rate = math.Min(rate*2, o.baseRate)
+	default:
+		return
+	}
+	if rate != o.sampleRate() {
+		klog.InfoS("adjusting the access log sample rate to the observability budget", "cpuUtilization", utilization, "budget", o.cpuBudget, "sampleRate", rate)
+		o.setSampleRate(rate)
+	}
+}
+
+// startRequest takes the sampling decision of the logs of a request.
+func (o *observability) startRequest() requestLog {
+	if o == nil {
+		return requestLog{sampled: true, rate: 1}
+	}
+	rate := o.sampleRate()
+	return requestLog{sampled: rate >= 1 || o.rand() < rate, rate: rate}
+}
+
+// accessLogDecision returns whether the access log of a finished request is written, and why. A request without a
+// status never got a response, e.g. the client went away, and is logged as failed.
+func (o *observability) accessLogDecision(log requestLog, summary requestSummary) string {
+	switch {
+	case summary.statusCode == 0 || summary.statusCode >= http.StatusBadRequest:
+		return accessLogFailed
+	case summary.latency >= o.slowThreshold:
+		return accessLogSlow
+	case log.sampled:
+		return accessLogSampled
+	}
+	return accessLogDropped
+}
+
+// finishRequest records the duration of a finished request and writes its access log if it is sampled, failed or
+// slow. The access log is tagged with the decision and the sample rate, the logs of the sampled requests stand for
+// 1/sampleRate requests while the failed and slow ones are all logged.
+func (o *observability) finishRequest(requestID string, log requestLog, summary requestSummary) {
+	if o == nil {
+		return
+	}
+	status := "success"
+	if summary.statusCode == 0 || summary.statusCode >= http.StatusBadRequest {
+		status = "error"
+	}
+	o.durations.observe(summary.model, status, summary.latency)
+
+	decision := o.accessLogDecision(log, summary)
+	accessLogDecisions.WithLabelValues(decision).Inc()
+	if decision == accessLogDropped {
+		return
+	}
+	rate := log.rate
+	if decision != accessLogSampled {
+		rate = 1
+	}
+	klog.InfoS("request finished", "requestID", requestID, "model", summary.model, "targetPod", summary.targetPod,
+		"statusCode", summary.statusCode, "stream", summary.stream, "latency", summary.latency,
+		"sampleDecision", decision, "sampleRate", rate)
+}
+
+// immediateStatusCode returns the status code of the response the gateway answered the request with itself, 0 if
+// the request goes on to the engine.
+func immediateStatusCode(resp *extProcPb.ProcessingResponse) int {
+	if immediate := resp.GetImmediateResponse(); immediate != nil {
+		return int(immediate.GetStatus().GetCode())
+	}
+	return 0
+}
+
+// readRuntimeCPU returns the CPU time the Go code and runtime of the gateway were busy, and the CPU time available
+// to them as defined by GOMAXPROCS, as estimated by the runtime.
+func readRuntimeCPU() (busy, total float64) {
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+	}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindFloat64 || samples[1].Value.Kind() != metrics.KindFloat64 {
+		return 0, 0
+	}
+	idle, total := samples[0].Value.Float64(), samples[1].Value.Float64()
+	return total - idle, total
+}
+
+// durationRecorder records the durations of the requests as the aibrix_gateway_request_duration_seconds histogram.
+type durationRecorder interface {
+	prometheus.Collector
+	observe(model, status string, d time.Duration)
+}
+
+// histogramRecorder records each duration in a prometheus histogram.
+type histogramRecorder struct {
+	*prometheus.HistogramVec
+}
+
+func newHistogramRecorder() *histogramRecorder {
+	return &histogramRecorder{prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aibrix_gateway_request_duration_seconds",
+		Help:    "Duration of the requests from their headers to their last response chunk, by model and status.",
+		Buckets: requestDurationBuckets,
+	}, []string{"model", "status"})}
+}
+
+func (h *histogramRecorder) observe(model, status string, d time.Duration) {
+	h.WithLabelValues(model, status).Observe(d.Seconds())
+}
+
+// preaggregatedHistogram counts the durations in integer counters, without the label hashing and the floating point
+// sum of a prometheus histogram, and publishes the histograms once per interval. A scrape sees the durations of
+// the requests finished before the last publication.
+type preaggregatedHistogram struct {
+	desc    *prometheus.Desc
+	buckets []float64
+
+	mu     sync.RWMutex
+	series map[[2]string]*histogramSeries
+
+	publishedMu sync.Mutex
+	published   []prometheus.Metric
+}
+
+// histogramSeries counts the durations of a model and status, per bucket and not cumulated, the last count is the
+// +Inf bucket.
+type histogramSeries struct {
+	counts   []atomic.Uint64
+	sumNanos atomic.Int64
+}
+
+func newPreaggregatedHistogram(desc *prometheus.Desc, buckets []float64) *preaggregatedHistogram {
+	return &preaggregatedHistogram{
+		desc:    desc,
+		buckets: buckets,
+		series:  map[[2]string]*histogramSeries{},
+	}
+}
+
+func (h *preaggregatedHistogram) observe(model, status string, d time.Duration) {
+	key := [2]string{model, status}
+	h.mu.RLock()
+	series, ok := h.series[key]
+	h.mu.RUnlock()
+	if !ok {
+		h.mu.Lock()
+		if series, ok = h.series[key]; !ok {
+			series = &histogramSeries{counts: make([]atomic.Uint64, len(h.buckets)+1)}
+			h.series[key] = series
+		}
+		h.mu.Unlock()
+	}
+	series.counts[sort.SearchFloat64s(h.buckets, d.Seconds())].Add(1)
+	series.sumNanos.Add(int64(d))
+}
+
+// publish snapshots the counters into the histograms served to the scrapes.
+func (h *preaggregatedHistogram) publish() {
+	h.mu.RLock()
+	published := make([]prometheus.Metric, 0, len(h.series))
+	for key, series := range h.series {
+		buckets := make(map[float64]uint64, len(h.buckets))
+		var count uint64
+		for i, upperBound := range h.buckets {
+			count += series.counts[i].Load()
+			buckets[upperBound] = count
+		}
+		count += series.counts[len(h.buckets)].Load()
+		sum := time.Duration(series.sumNanos.Load()).Seconds()
+		published = append(published, prometheus.MustNewConstHistogram(h.desc, count, sum, buckets, key[0], key[1]))
+	}
+	h.mu.RUnlock()
+
+	h.publishedMu.Lock()
+	h.published = published
+	h.publishedMu.Unlock()
+}
+
+func (h *preaggregatedHistogram) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.desc
+}
+
+func (h *preaggregatedHistogram) Collect(ch chan<- prometheus.Metric) {
+	h.publishedMu.Lock()
+	published := h.published
+	h.publishedMu.Unlock()
+	for _, metric := range published {
+		ch <- metric
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/klog/v2"
+)
+
+func TestAccessLogDecision(t *testing.T) {
+	o := newObservability(0, time.Second, 0, false)
+	o.rand = func() float64 { return 0.5 }
+	dropped := o.startRequest()
+	assert.False(t, dropped.sampled)
+
+	tests := []struct {
+		name     string
+		log      requestLog
+		summary  requestSummary
+		expected string
+	}{
+		{name: "dropped success", log: dropped, summary: requestSummary{statusCode: http.StatusOK, latency: time.Millisecond}, expected: accessLogDropped},
+		{name: "sampled success", log: requestLog{sampled: true, rate: 0.1}, summary: requestSummary{statusCode: http.StatusOK, latency: time.Millisecond}, expected: accessLogSampled},
+		{name: "client error", log: dropped, summary: requestSummary{statusCode: http.StatusTooManyRequests}, expected: accessLogFailed},
+		{name: "server error", log: dropped, summary: requestSummary{statusCode: http.StatusGatewayTimeout}, expected: accessLogFailed},
+		{name: "no response", log: dropped, summary: requestSummary{}, expected: accessLogFailed},
+		{name: "slow success", log: dropped, summary: requestSummary{statusCode: http.StatusOK, latency: 2 * time.Second}, expected: accessLogSlow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, o.accessLogDecision(tt.log, tt.summary))
+		})
+	}
+}
+
+func TestFinishRequestCountsDecisions(t *testing.T) {
+	o := newObservability(0, time.Second, 0, false)
+	o.rand = func() float64 { return 0.5 }
+	failed := testutil.ToFloat64(accessLogDecisions.WithLabelValues(accessLogFailed))
+	slow := testutil.ToFloat64(accessLogDecisions.WithLabelValues(accessLogSlow))
+	dropped := testutil.ToFloat64(accessLogDecisions.WithLabelValues(accessLogDropped))
+
+	// the failed and slow requests are logged at a zero sample rate.
+	o.finishRequest("r1", o.startRequest(), requestSummary{model: "m", statusCode: http.StatusServiceUnavailable})
+	o.finishRequest("r2", o.startRequest(), requestSummary{model: "m", statusCode: http.StatusOK, latency: 5 * time.Second})
+	o.finishRequest("r3", o.startRequest(), requestSummary{model: "m", statusCode: http.StatusOK, latency: time.Millisecond})
+
+	assert.Equal(t, failed+1, testutil.ToFloat64(accessLogDecisions.WithLabelValues(accessLogFailed)))
+	assert.Equal(t, slow+1, testutil.ToFloat64(accessLogDecisions.WithLabelValues(accessLogSlow)))
+	assert.Equal(t, dropped+1, testutil.ToFloat64(accessLogDecisions.WithLabelValues(accessLogDropped)))
+
+	var nilObservability *observability
+	assert.True(t, nilObservability.startRequest().sampled)
+	nilObservability.finishRequest("r4", requestLog{}, requestSummary{})
+}
+
+func TestObservabilityBudget(t *testing.T) {
+	o := newObservability(0.5, time.Second, 0.6, false)
+	var busy, total float64
+	o.cpu = func() (float64, float64) { return busy, total }
+	tick := func(utilization float64) {
+		busy += utilization
+		total++
+		o.adjustToBudget()
+	}
+
+	tick(0.9)
+	assert.Equal(t, 0.25, o.sampleRate())
+	tick(0.9)
+	assert.Equal(t, 0.125, o.sampleRate())
+	// the rate holds between the recovery threshold and the budget.
+	tick(0.55)
+	assert.Equal(t, 0.125, o.sampleRate())
+	for i := 0; i < 20; i++ {
+		tick(1)
+	}
+	assert.Equal(t, minAccessLogSampleRate, o.sampleRate())
+
+	for i := 0; i < 20; i++ {
+		tick(0.1)
+	}
+	assert.Equal(t, 0.5, o.sampleRate())
+	// no garbage collection since the last check, the gateway is idle.
+	o.setSampleRate(0.25)
+	o.adjustToBudget()
+	assert.Equal(t, 0.5, o.sampleRate())
+}
+
+func TestPreaggregatedHistogram(t *testing.T) {
+	h := newPreaggregatedHistogram(requestDurationDesc, []float64{0.1, 1})
+	h.observe("m", "success", 50*time.Millisecond)
+	h.observe("m", "success", 500*time.Millisecond)
+	h.observe("m", "error", 2*time.Second)
+
+	// the observations are served once published.
+	assert.Equal(t, 0, testutil.CollectAndCount(h))
+	h.publish()
+	expected := `
+# HELP aibrix_gateway_request_duration_seconds Duration of the requests from their headers to their last response chunk, by model and status.
+# TYPE aibrix_gateway_request_duration_seconds histogram
+aibrix_gateway_request_duration_seconds_bucket{model="m",status="error",le="0.1"} 0
+aibrix_gateway_request_duration_seconds_bucket{model="m",status="error",le="1"} 0
+aibrix_gateway_request_duration_seconds_bucket{model="m",status="error",le="+Inf"} 1
+aibrix_gateway_request_duration_seconds_sum{model="m",status="error"} 2
+aibrix_gateway_request_duration_seconds_count{model="m",status="error"} 1
+aibrix_gateway_request_duration_seconds_bucket{model="m",status="success",le="0.1"} 1
+aibrix_gateway_request_duration_seconds_bucket{model="m",status="success",le="1"} 2
+aibrix_gateway_request_duration_seconds_bucket{model="m",status="success",le="+Inf"} 2
+aibrix_gateway_request_duration_seconds_sum{model="m",status="success"} 0.55
+aibrix_gateway_request_duration_seconds_count{model="m",status="success"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(h, strings.NewReader(expected)))
+}
+
+// discardLogs silences klog for the duration of a benchmark.
+func discardLogs(b *testing.B) {
+	klog.LogToStderr(false)
+	klog.SetOutput(io.Discard)
+	b.Cleanup(func() { klog.LogToStderr(true) })
+}
+
+func BenchmarkFinishRequest(b *testing.B) {
+	discardLogs(b)
+	for _, rate := range []struct {
+		name string
+		rate float64
+	}{{"full", 1}, {"sampled", 0.01}} {
+		b.Run(rate.name, func(b *testing.B) {
+			o := newObservability(rate.rate, time.Minute, 0, false)
+			summary := requestSummary{model: "m", targetPod: "10.0.0.1:8000", statusCode: http.StatusOK, latency: time.Second}
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					o.finishRequest("r", o.startRequest(), summary)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkRequestDuration(b *testing.B) {
+	for _, recorder := range []struct {
+		name     string
+		recorder durationRecorder
+	}{
+		{"histogram", newHistogramRecorder()},
+		{"preaggregated", newPreaggregatedHistogram(requestDurationDesc, requestDurationBuckets)},
+	} {
+		b.Run(recorder.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					recorder.recorder.observe("m", "success", time.Second)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// pendingRequestsKeyPrefix prefixes the model in the key counting the pending requests of the model, which the
+	// redis metric sources of the PodAutoscalers read by default.
+	pendingRequestsKeyPrefix = "pending:"
+	// pendingRequestsTimeout bounds a single update of the counter, so that an unavailable Redis does not hold the
+	// requests.
+	pendingRequestsTimeout = 500 * time.Millisecond
+)
+
+// pendingCounter is the subset of the Redis client the pending requests are counted with.
+type pendingCounter interface {
+	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
+}
+
+// pendingRequests counts in Redis the requests of each model pending at the gateways, i.e. waiting in the admission
+// queue of the model, so that the model is scaled on the demand which has not reached any of its pods yet. The
+// counter is shared by the gateway replicas, the updates Redis failed to apply are dropped and never fail a request.
+type pendingRequests struct {
+	client pendingCounter
+}
+
+// newPendingRequests returns the counter of the pending requests, nil without Redis.
+func newPendingRequests(client redis.UniversalClient) *pendingRequests {
+	if client == nil {
+		return nil
+	}
+	return &pendingRequests{client: client}
+}
+
+// add adds delta to the pending requests of the model.
+func (p *pendingRequests) add(model string, delta int64) {
+	if p == nil {
+		return
+	}
+	// the counter is updated even once the client disconnected, the request leaving the queue must be counted.
+	ctx, cancel := context.WithTimeout(context.Background(), pendingRequestsTimeout)
+	defer cancel()
+	if err := p.client.IncrBy(ctx, pendingRequestsKeyPrefix+model, delta).Err(); err != nil {
+		klog.ErrorS(err, "failed to update the pending requests", "model", model, "delta", delta)
+	}
+}
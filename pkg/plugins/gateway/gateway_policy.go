@@ -0,0 +1,196 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	featureLogprobs = "logprobs"
+	featureTools    = "tools"
+
+	// rules reported in the policy violation header.
+	policyRuleModelNotAllowed    = "model_not_allowed"
+	policyRuleMaxTokensExceeded  = "max_tokens_exceeded"
+	policyRuleEndpointNotAllowed = "endpoint_not_allowed"
+	policyRuleFeatureNotAllowed  = "%s_not_allowed"
+)
+
+// compiledPolicy is a user policy prepared for the evaluation of every request of the user.
+type compiledPolicy struct {
+	models         []*regexp.Regexp
+	maxTokens      int64
+	endpoints      map[string]struct{}
+	deniedFeatures []string
+}
+
+// policyViolation names the rule of the policy the request violates.
+type policyViolation struct {
+	rule    string
+	message string
+}
+
+func compilePolicy(policy utils.UserPolicy) *compiledPolicy {
+	compiled := &compiledPolicy{
+		maxTokens: policy.MaxTokens,
+		endpoints: map[string]struct{}{},
+	}
+	for _, pattern := range policy.AllowedModels {
+		compiled.models = append(compiled.models, compileGlob(pattern))
+	}
+	for _, endpoint := range policy.AllowedEndpoints {
+		compiled.endpoints[endpoint] = struct{}{}
+	}
+	for _, feature := range policy.DeniedFeatures {
+		compiled.deniedFeatures = append(compiled.deniedFeatures, strings.ToLower(feature))
+	}
+	return compiled
+}
+
+// compileGlob compiles a glob pattern where "*" matches any sequence of characters and "?" any single character.
+func compileGlob(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// check returns the first rule of the policy the request violates, a nil policy allows every request.
+func (p *compiledPolicy) check(model, requestPath string, jsonMap map[string]interface{}) *policyViolation {
+	if p == nil {
+		return nil
+	}
+
+	if len(p.models) > 0 && !p.allowsModel(model) {
+		return &policyViolation{rule: policyRuleModelNotAllowed, message: fmt.Sprintf("model %s is not allowed", model)}
+	}
+	if len(p.endpoints) > 0 {
+		path, _, _ := strings.Cut(requestPath, "?")
+		if _, ok := p.endpoints[path]; !ok {
+			return &policyViolation{rule: policyRuleEndpointNotAllowed, message: fmt.Sprintf("endpoint %s is not allowed", path)}
+		}
+	}
+	if p.maxTokens > 0 {
+		for _, key := range []string{"max_tokens", "max_completion_tokens"} {
+			if maxTokens, ok := jsonMap[key].(float64); ok && int64(maxTokens) > p.maxTokens {
+				return &policyViolation{rule: policyRuleMaxTokensExceeded,
+					message: fmt.Sprintf("%s %d exceeds the limit %d", key, int64(maxTokens), p.maxTokens)}
+			}
+		}
+	}
+	for _, feature := range p.deniedFeatures {
+		if usesFeature(feature, jsonMap) {
+			return &policyViolation{rule: fmt.Sprintf(policyRuleFeatureNotAllowed, feature),
+				message: fmt.Sprintf("%s is not allowed", feature)}
+		}
+	}
+	return nil
+}
+
+func (p *compiledPolicy) allowsModel(model string) bool {
+	for _, pattern := range p.models {
+		if pattern.MatchString(model) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesFeature returns whether the request body enables the feature.
+func usesFeature(feature string, jsonMap map[string]interface{}) bool {
+	switch feature {
+	case featureLogprobs:
+		// chat completions enable logprobs with a bool, completions with the number of logprobs.
+		switch logprobs := jsonMap["logprobs"].(type) {
+		case bool:
+			return logprobs
+		case float64:
+			return true
+		}
+		_, ok := jsonMap["top_logprobs"]
+		return ok
+	case featureTools:
+		tools, _ := jsonMap["tools"].([]interface{})
+		functions, _ := jsonMap["functions"].([]interface{})
+		return len(tools) > 0 || len(functions) > 0
+	}
+	return false
+}
+
+type policyCacheEntry struct {
+	policy   utils.UserPolicy
+	compiled *compiledPolicy
+}
+
+// policyCache keeps the compiled policy of every user. Users are read on every request, so an updated policy
+// takes effect with the next request of the user and is only compiled once.
+type policyCache struct {
+	mu      sync.RWMutex
+	entries map[string]policyCacheEntry
+}
+
+func newPolicyCache() *policyCache {
+	return &policyCache{entries: map[string]policyCacheEntry{}}
+}
+
+// get returns the compiled policy of the user, nil if the user has no policy.
+func (c *policyCache) get(user utils.User) *compiledPolicy {
+	if c == nil || user.Policy == nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[user.Name]
+	c.mu.RUnlock()
+	if ok && reflect.DeepEqual(entry.policy, *user.Policy) {
+		return entry.compiled
+	}
+
+	entry = policyCacheEntry{policy: *user.Policy, compiled: compilePolicy(*user.Policy)}
+	c.mu.Lock()
+	c.entries[user.Name] = entry
+	c.mu.Unlock()
+	return entry.compiled
+}
+
+func generatePolicyViolationResponse(violation *policyViolation) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(envoyTypePb.StatusCode_Forbidden,
+		[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+			Key: HeaderErrorPolicyViolation, RawValue: []byte(violation.rule)}}},
+		violation.message)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"testing"
+
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy := compilePolicy(utils.UserPolicy{
+		AllowedModels:    []string{"llama-7b", "llama-7b-lora-*", "mistral-?b"},
+		MaxTokens:        1024,
+		AllowedEndpoints: []string{"/v1/chat/completions"},
+		DeniedFeatures:   []string{"Logprobs", "tools"},
+	})
+
+	var tests = []struct {
+		model string
+		path  string
+		body  string
+		rule  string
+	}{
+		{model: "llama-7b", path: "/v1/chat/completions", body: `{"max_tokens": 1024}`},
+		{model: "llama-7b-lora-sql", path: "/v1/chat/completions", body: `{}`},
+		{model: "mistral-7b", path: "/v1/chat/completions?debug=1", body: `{}`},
+		{model: "llama-70b", path: "/v1/chat/completions", body: `{}`, rule: policyRuleModelNotAllowed},
+		{model: "llama-7b-lora", path: "/v1/chat/completions", body: `{}`, rule: policyRuleModelNotAllowed},
+		{model: "mistral-70b", path: "/v1/chat/completions", body: `{}`, rule: policyRuleModelNotAllowed},
+		{model: "llama-7b", path: "/v1/completions", body: `{}`, rule: policyRuleEndpointNotAllowed},
+		{model: "llama-7b", path: "/v1/chat/completions", body: `{"max_tokens": 4096}`, rule: policyRuleMaxTokensExceeded},
+		{model: "llama-7b", path: "/v1/chat/completions", body: `{"max_completion_tokens": 2048}`, rule: policyRuleMaxTokensExceeded},
+		{model: "llama-7b", path: "/v1/chat/completions", body: `{"logprobs": false}`},
+		{model: "llama-7b", path: "/v1/chat/completions", body: `{"logprobs": true}`, rule: "logprobs_not_allowed"},
+		{model: "llama-7b", path: "/v1/chat/completions", body: `{"logprobs": 5}`, rule: "logprobs_not_allowed"},
+		{model: "llama-7b", path: "/v1/chat/completions", body: `{"tools": []}`},
+		{model: "llama-7b", path: "/v1/chat/completions", body: `{"tools": [{"type": "function"}]}`, rule: "tools_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		var jsonMap map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(tt.body), &jsonMap))
+		violation := policy.check(tt.model, tt.path, jsonMap)
+		if tt.rule == "" {
+			assert.Nil(t, violation, "%s %s %s", tt.model, tt.path, tt.body)
+			continue
+		}
+		if assert.NotNil(t, violation, "%s %s %s", tt.model, tt.path, tt.body) {
+			assert.Equal(t, tt.rule, violation.rule)
+		}
+	}
+
+	var noPolicy *compiledPolicy
+	assert.Nil(t, noPolicy.check("llama-70b", "/v1/completions", map[string]interface{}{"max_tokens": 1e6}))
+}
+
+func TestPolicyCacheReload(t *testing.T) {
+	c := newPolicyCache()
+	assert.Nil(t, c.get(utils.User{Name: "alice"}))
+
+	user := utils.User{Name: "alice", Policy: &utils.UserPolicy{AllowedModels: []string{"llama-*"}}}
+	compiled := c.get(user)
+	assert.Nil(t, compiled.check("llama-7b", "", nil))
+	reread := utils.User{Name: "alice", Policy: &utils.UserPolicy{AllowedModels: []string{"llama-*"}}}
+	assert.Same(t, compiled, c.get(reread), "an unchanged policy is not compiled again")
+
+	updated := utils.User{Name: "alice", Policy: &utils.UserPolicy{AllowedModels: []string{"mistral-*"}}}
+	violation := c.get(updated).check("llama-7b", "", nil)
+	if assert.NotNil(t, violation, "an updated policy takes effect with the next request") {
+		assert.Equal(t, policyRuleModelNotAllowed, violation.rule)
+	}
+}
+
+func TestGeneratePolicyViolationResponse(t *testing.T) {
+	resp := generatePolicyViolationResponse(&policyViolation{rule: policyRuleModelNotAllowed, message: "model llama-70b is not allowed"})
+	immediate := resp.GetImmediateResponse()
+	assert.Equal(t, envoyTypePb.StatusCode_Forbidden, immediate.GetStatus().GetCode())
+
+	headers := map[string]string{}
+	for _, header := range immediate.GetHeaders().GetSetHeaders() {
+		headers[header.Header.Key] = string(header.Header.RawValue)
+	}
+	assert.Equal(t, policyRuleModelNotAllowed, headers[HeaderErrorPolicyViolation])
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultQueueHeartbeatInterval = 5 * time.Second
+
+	// maxUpstreamErrorBytes bounds the body of an upstream error reported in the SSE error event.
+	maxUpstreamErrorBytes = 64 << 10
+)
+
+// heartbeatTicket is a streaming request handed over to the heartbeat proxy to wait for its admission.
+type heartbeatTicket struct {
+	model    string
+	priority string
+	// deadline is the client deadline of the request, zero without one.
+	deadline time.Time
+	// upstream is the address of the pod the request was routed to.
+	upstream string
+}
+
+// queueHeartbeats is the heartbeat proxy of the streaming requests waiting in the admission queue. The ext_proc
+// stream can not answer the client before the pod does, so a request opting into the heartbeats is routed by Envoy
+// to the proxy instead of its pod. The proxy starts the SSE response right away, holds the request in the admission
+// queue with a comment line reporting its position every interval, and then passes the request and the response of
+// the pod through. The heartbeats are SSE comments, never data events, and are not followed by a blank line, so that
+// a client never sees an empty event.
+type queueHeartbeats struct {
+	// address is where Envoy reaches the proxy, e.g. the pod IP and the heartbeat port of the gateway plugin.
+	address  string
+	interval time.Duration
+
+	queue     *admissionQueue
+	admission *admissionController
+	cache     admissionCache
+	client    *http.Client
+
+	mu      sync.Mutex
+	tickets map[string]*heartbeatTicket
+}
+
+func newQueueHeartbeats(address string, interval time.Duration, queue *admissionQueue, admission *admissionController, c admissionCache) *queueHeartbeats {
+	return &queueHeartbeats{
+		address:   address,
+		interval:  interval,
+		queue:     queue,
+		admission: admission,
+		cache:     c,
+		client:    &http.Client{},
+		tickets:   map[string]*heartbeatTicket{},
+	}
+}
+
+// EnableQueueHeartbeats lets the streaming requests opting in with the x-aibrix-queue-heartbeat header wait in the
+// admission queue behind the heartbeat proxy reached by Envoy at address. It returns the handler of the proxy.
+func (s *Server) EnableQueueHeartbeats(address string) http.Handler {
+	s.heartbeats = newQueueHeartbeats(address,
+		loadDurationMsEnv("AIBRIX_QUEUE_HEARTBEAT_INTERVAL_MS", defaultQueueHeartbeatInterval),
+		s.admissionQueue, s.admission, s.cache)
+	return s.heartbeats
+}
+
+// accepts returns whether the request opted into the heartbeats, always false while the proxy is disabled.
+func (h *queueHeartbeats) accepts(headers map[string]string) bool {
+	return h != nil && h.queue != nil && strings.EqualFold(headers[HeaderQueueHeartbeat], "true")
+}
+
+// handOver registers the request with the proxy and returns the routing headers sending it to the proxy rather
+// than to the pod it was routed to.
+func (h *queueHeartbeats) handOver(requestID string, ticket *heartbeatTicket, headers []*configPb.HeaderValueOption) []*configPb.HeaderValueOption {
+	h.mu.Lock()
+	h.tickets[requestID] = ticket
+	h.mu.Unlock()
+
+	for _, header := range headers {
+		if header.Header.Key == HeaderTargetPod {
+			header.Header.RawValue = []byte(h.address)
+		}
+	}
+	return append(headers, &configPb.HeaderValueOption{
+		Header: &configPb.HeaderValue{Key: HeaderQueueTicket, RawValue: []byte(requestID)},
+	})
+}
+
+// take removes the ticket of the request, nil if the request was not handed over or was already taken.
+func (h *queueHeartbeats) take(requestID string) *heartbeatTicket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ticket := h.tickets[requestID]
+	delete(h.tickets, requestID)
+	return ticket
+}
+
+// forget drops the ticket of a request which ended before reaching the proxy.
+func (h *queueHeartbeats) forget(requestID string) {
+	if h != nil {
+		h.take(requestID)
+	}
+}
+
+func (h *queueHeartbeats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get(HeaderQueueTicket)
+	ticket := h.take(requestID)
+	if ticket == nil {
+		http.Error(w, "unknown queue ticket", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	// the server closes the request body once the response is flushed, the body is read before the heartbeats.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read the request body", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	stream := &heartbeatStream{w: w, flusher: flusher}
+
+	ctx := r.Context()
+	start := time.Now()
+	lastPosition, lastBeat := 0, time.Time{}
+	err = h.queue.waitWithProgress(ctx, h.admission, h.cache, requestID, ticket.model, ticket.priority, ticket.deadline, func(position int) {
+		if position != lastPosition || time.Since(lastBeat) >= h.interval {
+			stream.comment(fmt.Sprintf("queued position=%d", position))
+			lastPosition, lastBeat = position, time.Now()
+		}
+	})
+	if err != nil {
+		klog.InfoS("request left the admission queue", "requestID", requestID, "model", ticket.model, "waited", time.Since(start), "reason", err)
+		switch {
+		case ctx.Err() != nil:
+			// the client disconnected, there is no one to answer.
+		case errors.Is(err, errAdmissionQueueTimeout) && !ticket.deadline.IsZero():
+			stream.errorEvent("request deadline exceeded before reaching the engine", http.StatusGatewayTimeout)
+		default:
+			stream.errorEvent(fmt.Sprintf("model %s is saturated at its maximum scale, retry later", ticket.model), http.StatusTooManyRequests)
+		}
+		return
+	}
+	klog.InfoS("request admitted after waiting in the admission queue", "requestID", requestID, "model", ticket.model, "waited", time.Since(start))
+	stream.comment("admitted")
+	h.forward(stream, r, body, requestID, ticket)
+}
+
+// forward passes the request on to its pod, with a comment every interval until the pod answers, and then passes
+// the response of the pod through. An error of the pod ends the stream with an SSE error event, the status of the
+// response being already sent.
+func (h *queueHeartbeats) forward(stream *heartbeatStream, r *http.Request, body []byte, requestID string, ticket *heartbeatTicket) {
+	upstream, err := http.NewRequestWithContext(r.Context(), r.Method, "http://"+ticket.upstream+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		stream.errorEvent(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	upstream.Header = r.Header.Clone()
+	upstream.Header.Del(HeaderQueueTicket)
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := h.client.Do(upstream)
+		done <- result{resp, err}
+	}()
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	var res result
+	for waiting := true; waiting; {
+		select {
+		case res = <-done:
+			waiting = false
+		case <-ticker.C:
+			stream.comment("processing")
+		}
+	}
+	if res.err != nil {
+		if r.Context().Err() == nil {
+			klog.ErrorS(res.err, "failed to forward the queued request", "requestID", requestID, "targetPodIP", ticket.upstream)
+			stream.errorEvent("error on forwarding the request to the engine", http.StatusBadGateway)
+		}
+		return
+	}
+	defer res.resp.Body.Close()
+	if res.resp.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(io.LimitReader(res.resp.Body, maxUpstreamErrorBytes))
+		stream.errorEvent(string(bytes.TrimSpace(errBody)), res.resp.StatusCode)
+		return
+	}
+
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := res.resp.Body.Read(buf)
+		if n > 0 {
+			stream.write(buf[:n])
+		}
+		if err != nil {
+			if err != io.EOF && r.Context().Err() == nil {
+				klog.ErrorS(err, "failed to read the response of the queued request", "requestID", requestID, "targetPodIP", ticket.upstream)
+			}
+			return
+		}
+	}
+}
+
+// heartbeatStream writes the SSE response of a request waiting behind the heartbeat proxy, flushing every write.
+type heartbeatStream struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (s *heartbeatStream) write(data []byte) {
+	if _, err := s.w.Write(data); err != nil {
+		// the client disconnected, the context of the request ends the wait.
+		return
+	}
+	s.flusher.Flush()
+}
+
+// comment writes a comment line, without the blank line which would dispatch an empty event.
+func (s *heartbeatStream) comment(text string) {
+	s.write([]byte(": " + text + "\n"))
+}
+
+// errorEvent ends the stream with an SSE error event, like a stream terminated by the gateway.
+func (s *heartbeatStream) errorEvent(message string, code int) {
+	s.write([]byte(fmt.Sprintf("data: %s\n\ndata: [DONE]\n\n", generateErrorMessage(message, code))))
+}
+
+// isSSECommentOnly returns whether a chunk of a stream only holds the comment lines of the heartbeats.
+func isSSECommentOnly(chunk []byte) bool {
+	if len(chunk) == 0 {
+		return false
+	}
+	for _, line := range bytes.Split(bytes.TrimSuffix(chunk, []byte("\n")), []byte("\n")) {
+		if len(line) == 0 || line[0] != ':' {
+			return false
+		}
+	}
+	return true
+}
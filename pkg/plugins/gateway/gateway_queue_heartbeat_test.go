@@ -0,0 +1,277 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/packages/ssestream"
+	"github.com/stretchr/testify/assert"
+)
+
+// strictSSE is what a strict SDK reads from a stream: the comment lines, and the data events, each of which must be
+// a JSON object or the [DONE] marker. parseStrictSSE fails on any other field, and on a blank line dispatching an
+// event without data, which the SDKs choking on the comments read as an empty JSON document.
+type strictSSE struct {
+	comments []string
+	events   []string
+}
+
+func parseStrictSSE(t *testing.T, body []byte) strictSSE {
+	t.Helper()
+	var parsed strictSSE
+	var data []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) == 0 {
+				t.Fatalf("blank line dispatching an event without data in %q", body)
+			}
+			event := strings.Join(data, "\n")
+			if event != "[DONE]" && !json.Valid([]byte(event)) {
+				t.Fatalf("event %q is not JSON", event)
+			}
+			parsed.events = append(parsed.events, event)
+			data = nil
+		case strings.HasPrefix(line, ":"):
+			parsed.comments = append(parsed.comments, strings.TrimPrefix(line, ": "))
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		default:
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+	if len(data) > 0 {
+		t.Fatalf("unterminated event %q", data)
+	}
+	return parsed
+}
+
+// newTestQueueHeartbeats returns the heartbeat proxy of a saturated model, forwarding to the upstream server, and
+// the flag giving the model capacity again.
+func newTestQueueHeartbeats(t *testing.T, upstream http.Handler) (*queueHeartbeats, *httptest.Server, func(bool)) {
+	a, c, capacity := newSaturatedAdmission()
+	pod := httptest.NewServer(upstream)
+	t.Cleanup(pod.Close)
+	h := newQueueHeartbeats("10.0.0.1:8082", 10*time.Millisecond, newAdmissionQueue(5*time.Millisecond, time.Minute), a, c)
+	proxy := httptest.NewServer(h)
+	t.Cleanup(proxy.Close)
+	h.tickets["req-1"] = &heartbeatTicket{model: "llama-7b", priority: priorityLow, upstream: strings.TrimPrefix(pod.URL, "http://")}
+	return h, proxy, capacity.Store
+}
+
+func postToProxy(ctx context.Context, t *testing.T, proxy *httptest.Server, ticket string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, proxy.URL+"/v1/chat/completions", strings.NewReader(`{"model":"llama-7b","stream":true}`))
+	assert.NoError(t, err)
+	req.Header.Set(HeaderQueueTicket, ticket)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestQueueHeartbeatStream(t *testing.T) {
+	chunk := `{"id":"1","object":"chat.completion.chunk","model":"llama-7b","choices":[{"index":0,"delta":{"content":"Hi"}}]}`
+	var forwarded *http.Request
+	var forwardedBody []byte
+	h, proxy, setCapacity := newTestQueueHeartbeats(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r
+		forwardedBody, _ = io.ReadAll(r.Body)
+		time.Sleep(30 * time.Millisecond)
+		fmt.Fprintf(w, "data: %s\n\n", chunk)
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	ahead := h.queue.enqueue("llama-7b")
+
+	done := make(chan *http.Response, 1)
+	go func() { done <- postToProxy(context.Background(), t, proxy, "req-1") }()
+	// the request waits behind the one ahead, then at the head of the queue until the model has capacity.
+	assert.Eventually(t, func() bool { return h.queue.length("llama-7b") == 2 }, time.Second, time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	h.queue.leave(ahead)
+	time.Sleep(30 * time.Millisecond)
+	setCapacity(true)
+
+	var resp *http.Response
+	select {
+	case resp = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queued request was not answered")
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	// the heartbeats are comments only, the events are the ones of the pod.
+	parsed := parseStrictSSE(t, body)
+	assert.Equal(t, []string{chunk, "[DONE]"}, parsed.events)
+	assert.Equal(t, "queued position=2", parsed.comments[0])
+	assert.Contains(t, parsed.comments, "queued position=1")
+	assert.Contains(t, parsed.comments, "admitted")
+	assert.Contains(t, parsed.comments, "processing")
+	assert.True(t, strings.HasPrefix(string(body), ": queued position=2\n"))
+
+	// the SDK of the gateway reads the same chunks.
+	stream := ssestream.NewStream[openai.ChatCompletionChunk](ssestream.NewDecoder(&http.Response{Body: io.NopCloser(bytes.NewReader(body))}), nil)
+	var contents []string
+	for stream.Next() {
+		for _, choice := range stream.Current().Choices {
+			contents = append(contents, choice.Delta.Content)
+		}
+	}
+	assert.NoError(t, stream.Err())
+	assert.Equal(t, []string{"Hi"}, contents)
+
+	// the request reaches the pod unchanged, without the ticket.
+	assert.Equal(t, "/v1/chat/completions", forwarded.URL.Path)
+	assert.Equal(t, `{"model":"llama-7b","stream":true}`, string(forwardedBody))
+	assert.Empty(t, forwarded.Header.Get(HeaderQueueTicket))
+	assert.Equal(t, 0, h.queue.length("llama-7b"))
+}
+
+func TestQueueHeartbeatUnknownTicket(t *testing.T) {
+	_, proxy, _ := newTestQueueHeartbeats(t, http.NotFoundHandler())
+	resp := postToProxy(context.Background(), t, proxy, "req-2")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// a ticket is only taken once.
+	resp = postToProxy(context.Background(), t, proxy, "req-1")
+	resp.Body.Close()
+	resp = postToProxy(context.Background(), t, proxy, "req-1")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestQueueHeartbeatClientDisconnect(t *testing.T) {
+	h, proxy, _ := newTestQueueHeartbeats(t, http.NotFoundHandler())
+	ctx, cancel := context.WithCancel(context.Background())
+	resp := postToProxy(ctx, t, proxy, "req-1")
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, ": queued position=1\n", line)
+	assert.Equal(t, 1, h.queue.length("llama-7b"))
+
+	// the request of the disconnected client leaves the queue.
+	cancel()
+	assert.Eventually(t, func() bool { return h.queue.length("llama-7b") == 0 }, time.Second, time.Millisecond)
+}
+
+func TestQueueHeartbeatErrors(t *testing.T) {
+	testCases := []struct {
+		name         string
+		upstream     http.HandlerFunc
+		capacity     bool
+		deadline     time.Duration
+		expectedCode int
+	}{
+		{
+			name: "pod error",
+			upstream: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":"overloaded"}`, http.StatusServiceUnavailable)
+			},
+			capacity:     true,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "deadline exceeded in the queue",
+			deadline:     30 * time.Millisecond,
+			expectedCode: http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, proxy, setCapacity := newTestQueueHeartbeats(t, tc.upstream)
+			setCapacity(tc.capacity)
+			if tc.deadline > 0 {
+				h.tickets["req-1"].deadline = time.Now().Add(tc.deadline)
+			}
+			resp := postToProxy(context.Background(), t, proxy, "req-1")
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+
+			// the stream ends with an error event, the comments before it never dispatch an event.
+			parsed := parseStrictSSE(t, body)
+			if assert.Len(t, parsed.events, 2) {
+				var event struct {
+					Error struct {
+						Code int `json:"code"`
+					} `json:"error"`
+				}
+				assert.NoError(t, json.Unmarshal([]byte(parsed.events[0]), &event))
+				assert.Equal(t, tc.expectedCode, event.Error.Code)
+				assert.Equal(t, "[DONE]", parsed.events[1])
+			}
+		})
+	}
+}
+
+func TestQueueHeartbeatHandOver(t *testing.T) {
+	var disabled *queueHeartbeats
+	assert.False(t, disabled.accepts(map[string]string{HeaderQueueHeartbeat: "true"}))
+	disabled.forget("req-1")
+
+	h := newQueueHeartbeats("10.0.0.1:8082", time.Second, newAdmissionQueue(time.Second, time.Minute), nil, nil)
+	assert.True(t, h.accepts(map[string]string{HeaderQueueHeartbeat: "True"}))
+	assert.False(t, h.accepts(map[string]string{}))
+
+	headers := h.handOver("req-1", &heartbeatTicket{model: "llama-7b", upstream: "10.0.0.2:8000"}, []*configPb.HeaderValueOption{
+		{Header: &configPb.HeaderValue{Key: HeaderRoutingStrategy, RawValue: []byte("least-request")}},
+		{Header: &configPb.HeaderValue{Key: HeaderTargetPod, RawValue: []byte("10.0.0.2:8000")}},
+	})
+	values := map[string]string{}
+	for _, header := range headers {
+		values[header.Header.Key] = string(header.Header.RawValue)
+	}
+	assert.Equal(t, map[string]string{
+		HeaderRoutingStrategy: "least-request",
+		HeaderTargetPod:       "10.0.0.1:8082",
+		HeaderQueueTicket:     "req-1",
+	}, values)
+
+	h.forget("req-1")
+	assert.Nil(t, h.take("req-1"))
+}
+
+func TestIsSSECommentOnly(t *testing.T) {
+	assert.True(t, isSSECommentOnly([]byte(": queued position=1\n")))
+	assert.True(t, isSSECommentOnly([]byte(": queued position=1\n: admitted\n")))
+	assert.False(t, isSSECommentOnly([]byte(": admitted\ndata: {}\n\n")))
+	assert.False(t, isSSECommentOnly([]byte("data: [DONE]\n\n")))
+	assert.False(t, isSSECommentOnly(nil))
+}
@@ -19,85 +19,164 @@ package gateway
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
 
 	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/ratelimiter"
 	"github.com/vllm-project/aibrix/pkg/utils"
 )
 
-func (s *Server) checkLimits(ctx context.Context, user utils.User) (int64, *extProcPb.ProcessingResponse, error) {
-	if user.Rpm == 0 {
-		user.Rpm = int64(DefaultRPM)
-	}
-	if user.Tpm == 0 {
-		user.Tpm = user.Rpm * int64(DefaultTPMMultiplier)
-	}
-
-	code, err := s.checkRPM(ctx, user.Name, user.Rpm)
-	if err != nil {
-		return 0, generateErrorResponse(
-			code,
-			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-				Key: HeaderErrorRPMExceeded, RawValue: []byte("true"),
-			}}},
-			err.Error()), err
-	}
+const (
+	// unknownUsersReject rejects the requests of the users unknown to Redis.
+	unknownUsersReject = "reject"
+	// unknownUsersAllow admits the requests of the users unknown to Redis within the default limits.
+	unknownUsersAllow = "allow"
+)
 
-	rpm, code, err := s.incrRPM(ctx, user.Name)
-	if err != nil {
-		return 0, generateErrorResponse(
-			code,
-			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-				Key: HeaderErrorIncrRPM, RawValue: []byte("true"),
-			}}},
-			err.Error()), err
-	}
+var rateLimitFailOpen = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_gateway_ratelimit_fail_open_total",
+	Help: "Number of requests the gateway admitted without checking a limit of their user, Redis being unavailable, by check.",
+}, []string{"check"})
 
-	code, err = s.checkTPM(ctx, user.Name, user.Tpm)
-	if err != nil {
-		return 0, generateErrorResponse(
-			code,
-			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-				Key: HeaderErrorTPMExceeded, RawValue: []byte("true"),
-			}}},
-			err.Error()), err
-	}
+func init() {
+	prometheus.MustRegister(rateLimitFailOpen)
+}
 
-	return rpm, nil, nil
+// rateLimitConfig holds the limits of the users which do not set theirs, and how the unknown users are handled.
+type rateLimitConfig struct {
+	allowUnknownUsers bool
+	defaultRPM        int64
+	defaultTPM        int64
 }
 
-func (s *Server) checkRPM(ctx context.Context, username string, rpmLimit int64) (envoyTypePb.StatusCode, error) {
-	rpmCurrent, err := s.ratelimiter.Get(ctx, fmt.Sprintf("%v_RPM_CURRENT", username))
-	if err != nil {
-		return envoyTypePb.StatusCode_InternalServerError, fmt.Errorf("fail to get RPM for user: %v", username)
+func newRateLimitConfigFromEnv() rateLimitConfig {
+	config := rateLimitConfig{defaultRPM: int64(loadPositiveIntEnv(EnvDefaultRPM, DefaultRPM))}
+	config.defaultTPM = int64(loadPositiveIntEnv(EnvDefaultTPM, int(config.defaultRPM)*DefaultTPMMultiplier))
+	switch policy := utils.LoadEnv(EnvUnknownUsers, unknownUsersReject); policy {
+	case unknownUsersAllow:
+		config.allowUnknownUsers = true
+	case unknownUsersReject:
+	default:
+		klog.Infof("invalid %s: %s, falling back to %s", EnvUnknownUsers, policy, unknownUsersReject)
 	}
+	return config
+}
 
-	if rpmCurrent >= rpmLimit {
-		return envoyTypePb.StatusCode_TooManyRequests, fmt.Errorf("user: %v has exceeded RPM: %v", username, rpmLimit)
+// limits returns the RPM and the TPM of the user. A user without RPM has the default one, and a user without TPM
+// has a multiple of its RPM, or the default TPM if it has no RPM either.
+func (c rateLimitConfig) limits(user utils.User) (int64, int64) {
+	rpm, tpm := user.Rpm, user.Tpm
+	if rpm == 0 {
+		rpm = c.defaultRPM
 	}
+	if tpm == 0 {
+		if user.Rpm != 0 {
+			tpm = user.Rpm * int64(DefaultTPMMultiplier)
+		} else {
+			tpm = c.defaultTPM
+		}
+	}
+	return rpm, tpm
+}
 
-	return envoyTypePb.StatusCode_OK, nil
+// rateLimitStatus is the usage of the limits of the user a request was admitted or rejected with.
+type rateLimitStatus struct {
+	rpmLimit int64
+	tpmLimit int64
+	requests ratelimiter.Reservation
+	tokens   ratelimiter.Reservation
 }
 
-func (s *Server) incrRPM(ctx context.Context, username string) (int64, envoyTypePb.StatusCode, error) {
-	rpm, err := s.ratelimiter.Incr(ctx, fmt.Sprintf("%v_RPM_CURRENT", username), 1)
-	if err != nil {
-		return rpm, envoyTypePb.StatusCode_InternalServerError, fmt.Errorf("fail to increment RPM for user: %v", username)
+// headers returns the x-ratelimit headers of the status, in the format of the OpenAI API.
+func (st *rateLimitStatus) headers() []*configPb.HeaderValueOption {
+	header := func(key, value string) *configPb.HeaderValueOption {
+		return &configPb.HeaderValueOption{Header: &configPb.HeaderValue{Key: key, RawValue: []byte(value)}}
+	}
+	headers := []*configPb.HeaderValueOption{
+		header(HeaderRateLimitLimitRequests, strconv.FormatInt(st.rpmLimit, 10)),
+		header(HeaderRateLimitRemainingRequests, strconv.FormatInt(max(st.rpmLimit-st.requests.Used, 0), 10)),
+		header(HeaderRateLimitResetRequests, formatRateLimitReset(st.requests.Reset)),
 	}
+	// the tokens of a request over the RPM are not checked.
+	if st.tokens != (ratelimiter.Reservation{}) {
+		headers = append(headers,
+			header(HeaderRateLimitLimitTokens, strconv.FormatInt(st.tpmLimit, 10)),
+			header(HeaderRateLimitRemainingTokens, strconv.FormatInt(max(st.tpmLimit-st.tokens.Used, 0), 10)),
+			header(HeaderRateLimitResetTokens, formatRateLimitReset(st.tokens.Reset)))
+	}
+	return headers
+}
 
-	return rpm, envoyTypePb.StatusCode_OK, nil
+// formatRateLimitReset formats the reset duration rounded up to the second, e.g. "1s" or "6m0s".
+func formatRateLimitReset(reset time.Duration) string {
+	if reset <= 0 {
+		return "0s"
+	}
+	return (reset + time.Second - 1).Truncate(time.Second).String()
 }
 
-func (s *Server) checkTPM(ctx context.Context, username string, tpmLimit int64) (envoyTypePb.StatusCode, error) {
-	tpmCurrent, err := s.ratelimiter.Get(ctx, fmt.Sprintf("%v_TPM_CURRENT", username))
+// checkLimits counts the request against the RPM of the user, and its prompt token estimate against the TPM of the
+// user, and returns a response to reject the request over one of the limits. The check and the count are atomic,
+// so the concurrent requests of a user can not overshoot its limits. The requests are admitted without checking
+// the limits while Redis is unavailable.
+func (s *Server) checkLimits(ctx context.Context, requestID string, account *requestAccount) *extProcPb.ProcessingResponse {
+	username := account.user.Name
+	rpmLimit, tpmLimit := s.rateLimits.limits(account.user)
+	status := &rateLimitStatus{rpmLimit: rpmLimit, tpmLimit: tpmLimit}
+
+	var err error
+	status.requests, err = s.ratelimiter.Reserve(ctx, fmt.Sprintf("%v_RPM_CURRENT", username), 1, rpmLimit)
 	if err != nil {
-		return envoyTypePb.StatusCode_InternalServerError, fmt.Errorf("fail to get TPM for user: %v", username)
+		klog.Warningf("failed to check the RPM of user %s, admitting request %s: %v", username, requestID, err)
+		rateLimitFailOpen.WithLabelValues("rpm").Inc()
+		return nil
 	}
+	if !status.requests.Allowed {
+		return generateRateLimitedResponse(HeaderErrorRPMExceeded, status, status.requests.Reset,
+			fmt.Sprintf("user: %v has exceeded RPM: %v", username, rpmLimit))
+	}
+	account.rpm = status.requests.Used
 
-	if tpmCurrent >= tpmLimit {
-		return envoyTypePb.StatusCode_TooManyRequests, fmt.Errorf("user: %v has exceeded TPM: %v", username, tpmLimit)
+	status.tokens, err = s.ratelimiter.Reserve(ctx, fmt.Sprintf("%v_TPM_CURRENT", username), account.promptTokens, tpmLimit)
+	if err != nil {
+		klog.Warningf("failed to check the TPM of user %s, admitting request %s: %v", username, requestID, err)
+		rateLimitFailOpen.WithLabelValues("tpm").Inc()
+		return nil
 	}
+	if !status.tokens.Allowed {
+		// the rejected request does not count against the RPM.
+		s.accounting.record(fmt.Sprintf("%v_RPM_CURRENT", username), -1)
+		return generateRateLimitedResponse(HeaderErrorTPMExceeded, status, status.tokens.Reset,
+			fmt.Sprintf("user: %v has exceeded TPM: %v", username, tpmLimit))
+	}
+	account.tpm = status.tokens.Used
+	account.reservedTokens = account.promptTokens
+	account.rateLimits = status
+	return nil
+}
 
-	return envoyTypePb.StatusCode_OK, nil
+// generateRateLimitedResponse rejects a request over a limit of its user, asking the client to retry once the
+// limit resets.
+func generateRateLimitedResponse(errorHeader string, status *rateLimitStatus, retryAfter time.Duration, message string) *extProcPb.ProcessingResponse {
+	headers := append([]*configPb.HeaderValueOption{
+		{Header: &configPb.HeaderValue{Key: errorHeader, RawValue: []byte("true")}},
+		retryAfterHeader(retryAfter),
+	}, status.headers()...)
+	return generateErrorResponse(envoyTypePb.StatusCode_TooManyRequests, headers, message)
+}
+
+// incrTPM corrects the prompt token estimate counted against the TPM of the user on admission with the tokens of
+// the usage of the response. The TPM is only read back by the next requests, so the correction is buffered and
+// written asynchronously, and the returned TPM is the one the request was admitted with, corrected.
+func (s *Server) incrTPM(account *requestAccount, tokens int64) int64 {
+	if delta := tokens - account.reservedTokens; delta != 0 {
+		s.accounting.record(fmt.Sprintf("%v_TPM_CURRENT", account.user.Name), delta)
+	}
+	return account.tpm + tokens - account.reservedTokens
 }
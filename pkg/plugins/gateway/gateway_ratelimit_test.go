@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+func newTestRateLimitServer() (*Server, *fakeBatchRateLimiter) {
+	limiter := newFakeBatchRateLimiter(0)
+	return &Server{
+		ratelimiter: limiter,
+		rateLimits:  rateLimitConfig{defaultRPM: 2, defaultTPM: 100},
+		accounting:  newAccountingPipeline(limiter, 100, 100, time.Hour),
+	}, limiter
+}
+
+func rateLimitHeaders(resp *extProcPb.ProcessingResponse) map[string]string {
+	headers := map[string]string{}
+	for _, header := range resp.GetImmediateResponse().GetHeaders().GetSetHeaders() {
+		headers[header.Header.Key] = string(header.Header.RawValue)
+	}
+	return headers
+}
+
+func TestRateLimitConfigLimits(t *testing.T) {
+	config := rateLimitConfig{defaultRPM: 100, defaultTPM: 1000}
+	rpm, tpm := config.limits(utils.User{Name: "alice"})
+	assert.Equal(t, int64(100), rpm)
+	assert.Equal(t, int64(1000), tpm)
+
+	rpm, tpm = config.limits(utils.User{Name: "bob", Rpm: 10})
+	assert.Equal(t, int64(10), rpm)
+	assert.Equal(t, int64(10*DefaultTPMMultiplier), tpm)
+
+	rpm, tpm = config.limits(utils.User{Name: "carol", Rpm: 10, Tpm: 50})
+	assert.Equal(t, int64(10), rpm)
+	assert.Equal(t, int64(50), tpm)
+}
+
+func TestCheckLimitsRPM(t *testing.T) {
+	s, limiter := newTestRateLimitServer()
+	ctx := context.Background()
+
+	for i := 1; i <= 2; i++ {
+		account := &requestAccount{user: utils.User{Name: "alice"}, promptTokens: 30}
+		assert.Nil(t, s.checkLimits(ctx, "r", account))
+		assert.Equal(t, int64(i), account.rpm)
+		assert.Equal(t, int64(30*i), account.tpm)
+		assert.Equal(t, int64(30), account.reservedTokens)
+		if assert.NotNil(t, account.rateLimits) {
+			headers := map[string]string{}
+			for _, header := range account.rateLimits.headers() {
+				headers[header.Header.Key] = string(header.Header.RawValue)
+			}
+			assert.Equal(t, "2", headers[HeaderRateLimitLimitRequests])
+			assert.Equal(t, "100", headers[HeaderRateLimitLimitTokens])
+			assert.Equal(t, "1m0s", headers[HeaderRateLimitResetTokens])
+		}
+	}
+
+	account := &requestAccount{user: utils.User{Name: "alice"}, promptTokens: 30}
+	resp := s.checkLimits(ctx, "r", account)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, envoyTypePb.StatusCode_TooManyRequests, resp.GetImmediateResponse().GetStatus().GetCode())
+		headers := rateLimitHeaders(resp)
+		assert.Equal(t, "true", headers[HeaderErrorRPMExceeded])
+		assert.Equal(t, "0", headers[HeaderRateLimitRemainingRequests])
+		assert.NotContains(t, headers, HeaderRateLimitRemainingTokens)
+		assert.Equal(t, "60", headers[HeaderRetryAfter])
+	}
+	// the tokens of the rejected request are not counted.
+	assert.Equal(t, int64(60), limiter.get("alice_TPM_CURRENT"))
+}
+
+func TestCheckLimitsTPM(t *testing.T) {
+	s, limiter := newTestRateLimitServer()
+	ctx := context.Background()
+
+	assert.Nil(t, s.checkLimits(ctx, "r1", &requestAccount{user: utils.User{Name: "bob", Rpm: 10, Tpm: 100}, promptTokens: 60}))
+	resp := s.checkLimits(ctx, "r2", &requestAccount{user: utils.User{Name: "bob", Rpm: 10, Tpm: 100}, promptTokens: 60})
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, envoyTypePb.StatusCode_TooManyRequests, resp.GetImmediateResponse().GetStatus().GetCode())
+		headers := rateLimitHeaders(resp)
+		assert.Equal(t, "true", headers[HeaderErrorTPMExceeded])
+		assert.Equal(t, "8", headers[HeaderRateLimitRemainingRequests])
+		assert.Equal(t, "40", headers[HeaderRateLimitRemainingTokens])
+	}
+	// the request rejected over the TPM gives its request back to the RPM.
+	assert.Equal(t, int64(2), limiter.get("bob_RPM_CURRENT"))
+	for _, record := range s.accounting.take() {
+		limiter.counters[record.Key] += record.Val
+	}
+	assert.Equal(t, int64(1), limiter.get("bob_RPM_CURRENT"))
+	assert.Equal(t, int64(60), limiter.get("bob_TPM_CURRENT"))
+}
+
+func TestCheckLimitsFailOpen(t *testing.T) {
+	s, limiter := newTestRateLimitServer()
+	limiter.err = errors.New("connection refused")
+
+	failOpen := testutil.ToFloat64(rateLimitFailOpen.WithLabelValues("rpm"))
+	account := &requestAccount{user: utils.User{Name: "alice"}, promptTokens: 30}
+	assert.Nil(t, s.checkLimits(context.Background(), "r", account))
+	assert.Nil(t, account.rateLimits)
+	assert.Equal(t, failOpen+1, testutil.ToFloat64(rateLimitFailOpen.WithLabelValues("rpm")))
+}
+
+func TestIncrTPMCorrectsEstimate(t *testing.T) {
+	s, _ := newTestRateLimitServer()
+	account := &requestAccount{user: utils.User{Name: "alice"}, tpm: 80, reservedTokens: 30}
+
+	// the usage of the response replaces the prompt token estimate counted on admission.
+	assert.Equal(t, int64(100), s.incrTPM(account, 50))
+	records := s.accounting.take()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "alice_TPM_CURRENT", records[0].Key)
+		assert.Equal(t, int64(20), records[0].Val)
+	}
+}
+
+func TestFormatRateLimitReset(t *testing.T) {
+	assert.Equal(t, "0s", formatRateLimitReset(0))
+	assert.Equal(t, "1s", formatRateLimitReset(200*time.Millisecond))
+	assert.Equal(t, "6m0s", formatRateLimitReset(6*time.Minute))
+}
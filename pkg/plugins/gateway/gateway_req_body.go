@@ -18,64 +18,123 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 
 	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/vllm-project/aibrix/pkg/cache"
 	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
-	"github.com/vllm-project/aibrix/pkg/utils"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/bodypatch"
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/engineapi"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (s *Server) HandleRequestBody(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest, user utils.User, routingStrategy string) (*extProcPb.ProcessingResponse, string, string, bool, int64) {
-	klog.InfoS("-- In RequestBody processing ...", "requestID", requestID)
-	var model, targetPodIP string
+func (s *Server) HandleRequestBody(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest, account *requestAccount, routingStrategy, requestPath, priority, queueMode string, budget requestBudget) (*extProcPb.ProcessingResponse, string, string, bool, int64) {
+	klog.V(4).InfoS("-- In RequestBody processing ...", "requestID", requestID)
+	var targetPodIP string
 	var ok, stream bool
 	var term int64 // Identify the trace window
 
-	var jsonMap map[string]interface{}
-
 	body := req.Request.(*extProcPb.ProcessingRequest_RequestBody)
-	if err := json.Unmarshal(body.RequestBody.GetBody(), &jsonMap); err != nil {
-		klog.ErrorS(err, "error to unmarshal response", "requestID", requestID, "requestBody", string(body.RequestBody.GetBody()))
-		return generateErrorResponse(envoyTypePb.StatusCode_InternalServerError,
-			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-				Key: HeaderErrorRequestBodyProcessing, RawValue: []byte("true")}}},
-			"error processing request body"), model, targetPodIP, stream, term
+	parsed, errRes := s.parseRequestBody(ctx, requestID, routingStrategy, body.RequestBody.GetBody())
+	model, requestedModel, defaultedModel, jsonMap := parsed.model, parsed.requestedModel, parsed.defaultedModel, parsed.jsonMap
+	if errRes != nil {
+		return errRes, model, targetPodIP, stream, term
 	}
 
-	if model, ok = jsonMap["model"].(string); !ok || model == "" {
-		klog.ErrorS(nil, "model error in request", "requestID", requestID, "jsonMap", jsonMap)
-		return generateErrorResponse(envoyTypePb.StatusCode_InternalServerError,
-			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-				Key: HeaderErrorNoModelInRequest, RawValue: []byte(model)}}},
-			"no model in request body"), model, targetPodIP, stream, term
+	// the user is named by the user header, or else by the user field of the request.
+	if account.username == "" {
+		account.username, _ = jsonMap["user"].(string)
+	}
+	// the estimate counts the prompt with its JSON encoding, the engine reports the exact count in the usage.
+	if message, errRes := getRequestMessage(jsonMap); errRes == nil {
+		account.promptTokens = int64(s.tokenizers.ForModel(model).CountTokens(message))
 	}
 
-	// early reject the request if model doesn't exist.
-	if !s.cache.CheckModelExists(model) {
-		klog.ErrorS(nil, "model doesn't exist in cache, probably wrong model name", "requestID", requestID, "model", model)
-		return generateErrorResponse(envoyTypePb.StatusCode_BadRequest,
-			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-				Key: HeaderErrorNoModelBackends, RawValue: []byte(model)}}},
-			fmt.Sprintf("model %s does not exist", model)), model, targetPodIP, stream, term
+	authCtx, authSpan := s.tracer.Start(ctx, spanAuthRateLimit)
+	errRes = s.runMiddlewares(authCtx, requestID, model, account)
+	authSpan.End()
+	if errRes != nil {
+		return errRes, model, targetPodIP, stream, term
 	}
 
-	// early reject if no pods are ready to accept request for a model
-	pods, err := s.cache.GetPodsForModel(model)
-	if len(pods) == 0 || len(utils.FilterReadyPods(pods)) == 0 || err != nil {
+	// warn the clients still using a deprecated model, and count their requests to find them.
+	account.deprecation = s.deprecations.warn(ctx, requestID, requestedModel, account.user.Name, time.Now())
+
+	// reject the request if the policy of the user doesn't allow it.
+	if violation := s.policies.get(account.user).check(model, requestPath, jsonMap); violation != nil {
+		klog.InfoS("request violates user policy", "requestID", requestID, "user", account.user.Name, "model", model, "rule", violation.rule)
+		return generatePolicyViolationResponse(violation), model, targetPodIP, stream, term
+	}
+
+	// reject the request if the client deadline already passed, the engine could not answer in time.
+	if remaining, ok := budget.remaining(time.Now()); ok && remaining <= 0 {
+		klog.InfoS("request deadline exceeded", "requestID", requestID, "model", model, "remaining", remaining)
+		return generateDeadlineExceededResponse(remaining), model, targetPodIP, stream, term
+	}
+
+	// split the traffic of a model adapter between its artifacts while a new one rolls out.
+	servedModel := s.adapterVersions.pick(model)
+
+	// the pods reserved for a tenant only serve the requests of the tenant.
+	pods, tenantPool, err := s.tenants.selectPods(s.cache, servedModel, account.user.Tenant)
+	// a model adapter no ready pod loaded is served by the pods of its base model.
+	if err != nil || len(routing.FilterRoutablePods(pods)) == 0 {
+		if baseModel, ok := s.loraFallback.baseModel(requestID, model); ok {
+			pods, tenantPool, err = s.tenants.selectPods(s.cache, baseModel, account.user.Tenant)
+		}
+	}
+	account.tenantPool = tenantPool
+
+	// early reject if no pods are ready to accept request for a model, unless the request may spill over to a peer cluster.
+	spillover := routing.Algorithms(routingStrategy) == routing.RouterSpillover
+	routablePods := routing.FilterRoutablePods(pods)
+	if !spillover && (len(routablePods) == 0 || err != nil) {
 		klog.ErrorS(err, "no ready pod available", "requestID", requestID, "model", model)
-		return generateErrorResponse(envoyTypePb.StatusCode_ServiceUnavailable,
-			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-				Key: HeaderErrorNoModelBackends, RawValue: []byte("true")}}},
+		// the model was removed from the cache since the early check.
+		if errors.Is(err, cache.ErrModelNotFound) && !s.cache.CheckModelExists(model) {
+			return generateModelNotFoundResponse(model), model, targetPodIP, stream, term
+		}
+		// the pods of the model are starting or draining, e.g. during a rollout.
+		if err == nil && len(pods) > 0 {
+			return generateNoReadyPodsResponse(model, s.noReadyPodsRetryAfter(model)), model, targetPodIP, stream, term
+		}
+		headers := []*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+			Key: HeaderErrorNoModelBackends, RawValue: []byte("true")}}}
+		// the model is cold, tell the client when its pods are expected to be routable.
+		if retryAfter, ok := s.cache.EstimateRetryAfter(model, time.Now()); ok {
+			headers = append(headers, retryAfterHeader(retryAfter))
+		}
+		return generateErrorResponse(envoyTypePb.StatusCode_ServiceUnavailable, headers,
 			fmt.Sprintf("error on getting pods for model %s", model)), model, targetPodIP, stream, term
 	}
+	// the routers only score the running and ready pods, which are filtered once for the request.
+	pods = routablePods
+
+	// shed low priority requests while the autoscaler of the model can not add replicas.
+	if admitted, retryAfter := s.admission.admit(s.cache, model, priority); !admitted {
+		// a routed stream opting into the heartbeats waits behind the heartbeat proxy, which answers the client
+		// while it waits.
+		if wantsStream, _ := jsonMap["stream"].(bool); wantsStream && routingStrategy != "" && queueMode == queueModeWait && s.heartbeats.accepts(account.headers) {
+			account.queueHeartbeat = true
+		} else if errRes := s.handleAdmissionRejected(ctx, requestID, model, priority, queueMode, budget, retryAfter); errRes != nil {
+			return errRes, model, targetPodIP, stream, term
+		}
+	}
 
 	stream, ok = jsonMap["stream"].(bool)
 	if ok && stream {
-		if errRes := validateStreamOptions(requestID, user, jsonMap); errRes != nil {
+		if errRes := validateStreamOptions(requestID, account.user, jsonMap); errRes != nil {
 			return errRes, model, targetPodIP, stream, term
 		}
 	}
@@ -88,14 +147,30 @@ func (s *Server) HandleRequestBody(ctx context.Context, requestID string, req *e
 				RawValue: []byte(model),
 			},
 		})
-		klog.InfoS("request start", "requestID", requestID, "model", model)
+		if account.logSampled {
+			klog.InfoS("request start", "requestID", requestID, "model", model)
+		}
 	} else {
 		message, extErr := getRequestMessage(jsonMap)
 		if extErr != nil {
 			return extErr, model, targetPodIP, stream, term
 		}
 
-		targetPodIP, err = s.selectTargetPod(ctx, routing.Algorithms(routingStrategy), pods, model, message)
+		promptTokens := int(account.promptTokens)
+		routingCtx, routingSpan := s.tracer.Start(ctx, spanRouting, trace.WithAttributes(attribute.Int(attrPromptTokens, promptTokens)))
+		user, _ := jsonMap["user"].(string)
+		routingCtx = routing.WithSessionInfo(routingCtx, account.headers, user)
+		targetPodIP, err = s.selectTargetPod(routingCtx, routing.Algorithms(routingStrategy), pods, servedModel, message, priority)
+		routingSpan.End()
+		var spilloverErr *routing.SpilloverError
+		if errors.As(err, &spilloverErr) {
+			klog.InfoS("request spilled over", "requestID", requestID, "model", model, "cluster", spilloverErr.Cluster)
+			return generateSpilloverResponse(spilloverErr, requestPath), model, targetPodIP, stream, term
+		}
+		if errors.Is(err, routing.ErrNoReadyPods) {
+			klog.InfoS("no ready pod to route the request to", "requestID", requestID, "routingStrategy", routingStrategy, "model", model)
+			return generateNoReadyPodsResponse(model, s.noReadyPodsRetryAfter(model)), model, targetPodIP, stream, term
+		}
 		if targetPodIP == "" || err != nil {
 			klog.ErrorS(err, "failed to select target pod", "requestID", requestID, "routingStrategy", routingStrategy, "model", model)
 			return generateErrorResponse(
@@ -104,6 +179,7 @@ func (s *Server) HandleRequestBody(ctx context.Context, requestID string, req *e
 					Key: HeaderErrorRouting, RawValue: []byte("true")}}},
 				"error on selecting target pod"), model, targetPodIP, stream, term
 		}
+		targetPodIP = resolveTargetPort(s.cache, servedModel, pods, targetPodIP)
 
 		headers = append(headers,
 			&configPb.HeaderValueOption{
@@ -118,7 +194,82 @@ func (s *Server) HandleRequestBody(ctx context.Context, requestID string, req *e
 					RawValue: []byte(targetPodIP),
 				},
 			})
-		klog.InfoS("request start", "requestID", requestID, "model", model, "routingStrategy", routingStrategy, "targetPodIP", targetPodIP)
+		if pod := getServingPod(pods, targetPodIP); pod != nil {
+			s.cache.RecordRouting(servedModel, pod.Name, routingStrategy)
+			s.cache.AddPodRequest(requestID, pod.Name)
+		}
+		if account.queueHeartbeat {
+			now := time.Now()
+			var deadline time.Time
+			if remaining, ok := budget.remaining(now); ok {
+				deadline = now.Add(remaining)
+			}
+			headers = s.heartbeats.handOver(requestID, &heartbeatTicket{
+				model: model, priority: priority, deadline: deadline, upstream: targetPodIP}, headers)
+			klog.InfoS("request handed over to the heartbeat proxy to wait for admission", "requestID", requestID, "model", model, "targetPodIP", targetPodIP)
+		} else if s.failover.enabled() {
+			strategy := routing.Algorithms(routingStrategy)
+			headers = s.failover.handOver(requestID, &failoverTicket{
+				model: servedModel, upstream: targetPodIP, pods: pods,
+				route: func(ctx context.Context, pods map[string]*v1.Pod, n int) ([]string, error) {
+					return s.selectTargetPods(ctx, strategy, pods, servedModel, message, priority, n)
+				},
+			}, headers)
+		}
+		if account.logSampled {
+			klog.InfoS("request start", "requestID", requestID, "model", model, "servedModel", servedModel, "routingStrategy", routingStrategy, "targetPodIP", targetPodIP, "promptTokens", promptTokens)
+		}
+	}
+
+	headers = append(headers, s.timeouts.resolve(requestPath, model).envoyHeaders(stream)...)
+
+	// pass the priority and the remaining budget of the request on to the engine scheduler.
+	var patches []bodypatch.Patch
+	if fields := s.engineHints.fields(getServingPod(pods, targetPodIP), priority, budget, time.Now()); fields != nil {
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			patches = append(patches, bodypatch.Set([]string{key}, fields[key]))
+		}
+	}
+	// the engine serves the replacement of a migrated model, the new artifact of a model adapter under its
+	// versioned name, and the default model of a request without one.
+	if defaultedModel {
+		patches = append(patches, bodypatch.Set([]string{"model"}, servedModel))
+	} else if servedModel != requestedModel {
+		patches = append(patches, bodypatch.Replace([]string{"model"}, servedModel))
+	}
+	var bodyMutation *extProcPb.BodyMutation
+	// a pod serving the native API of its engine gets the request translated, the hints are OpenAI fields.
+	if translator := s.engineAPIs.translator(getServingPod(pods, targetPodIP)); targetPodIP != "" && translator != nil {
+		endpoint, ok := engineapi.EndpointOf(requestPath)
+		if !ok {
+			endpoint = engineapi.Endpoint(requestPath)
+		}
+		var pathHeader *configPb.HeaderValueOption
+		var errRes *extProcPb.ProcessingResponse
+		pathHeader, bodyMutation, errRes = translateRequestBody(translator, engineapi.Request{
+			ID:           requestID,
+			Endpoint:     endpoint,
+			Model:        servedModel,
+			Stream:       stream,
+			PromptTokens: account.promptTokens,
+			Body:         jsonMap,
+			Created:      time.Now(),
+		}, account)
+		if errRes != nil {
+			return errRes, model, targetPodIP, stream, term
+		}
+		headers = append(headers, pathHeader)
+	} else if len(patches) > 0 {
+		if mutated, err := bodypatch.Apply(body.RequestBody.GetBody(), patches...); err != nil {
+			klog.ErrorS(err, "failed to mutate request body", "requestID", requestID, "model", model)
+		} else {
+			bodyMutation = &extProcPb.BodyMutation{Mutation: &extProcPb.BodyMutation_Body{Body: mutated}}
+		}
 	}
 
 	term = s.cache.AddRequestCount(requestID, model)
@@ -130,8 +281,122 @@ func (s *Server) HandleRequestBody(ctx context.Context, requestID string, req *e
 					HeaderMutation: &extProcPb.HeaderMutation{
 						SetHeaders: headers,
 					},
+					BodyMutation: bodyMutation,
 				},
 			},
 		},
 	}, model, targetPodIP, stream, term
 }
+
+// requestBody is the model and the decoded body of a request.
+type requestBody struct {
+	// model serves the request, the default model of a request without one or the replacement of a migrated one.
+	model string
+	// requestedModel is the model the client asked for, or the default model.
+	requestedModel string
+	defaultedModel bool
+	jsonMap        map[string]interface{}
+}
+
+// parseRequestBody resolves the model of the request and decodes its body, it returns the response rejecting a
+// request to a model which can not serve it. The model is resolved before the body is decoded.
+func (s *Server) parseRequestBody(ctx context.Context, requestID, routingStrategy string, body []byte) (requestBody, *extProcPb.ProcessingResponse) {
+	_, span := s.tracer.Start(ctx, spanParseBody)
+	defer span.End()
+	model, err := readRequestModel(body)
+	parsed := requestBody{model: model}
+	if errors.Is(err, errModelNotString) {
+		klog.ErrorS(err, "model error in request", "requestID", requestID)
+		return parsed, generateErrorResponse(envoyTypePb.StatusCode_InternalServerError,
+			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+				Key: HeaderErrorNoModelInRequest, RawValue: []byte(model)}}},
+			"no model in request body")
+	}
+	if errors.Is(err, errDuplicateModel) {
+		klog.ErrorS(err, "ambiguous model in request", "requestID", requestID)
+		return parsed, generateErrorResponse(envoyTypePb.StatusCode_BadRequest,
+			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+				Key: HeaderErrorRequestBodyProcessing, RawValue: []byte("true")}}},
+			"several model fields in request body")
+	}
+	if err != nil {
+		klog.ErrorS(err, "error to read the model of the request", "requestID", requestID)
+		return parsed, generateErrorResponse(envoyTypePb.StatusCode_InternalServerError,
+			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+				Key: HeaderErrorRequestBodyProcessing, RawValue: []byte("true")}}},
+			"error processing request body")
+	}
+	// a request without a model is served by the default model of the gateway, if any.
+	parsed.defaultedModel = model == ""
+	if parsed.defaultedModel {
+		if s.defaultModel == "" {
+			klog.ErrorS(nil, "no model in request", "requestID", requestID)
+			return parsed, generateErrorResponse(envoyTypePb.StatusCode_InternalServerError,
+				[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+					Key: HeaderErrorNoModelInRequest, RawValue: []byte(model)}}},
+				"no model in request body")
+		}
+		model = s.defaultModel
+		parsed.model = model
+	}
+
+	// reject the request to a removed model, unless it migrates to its replacement.
+	parsed.requestedModel = model
+	migrated, removedRes := s.deprecations.route(requestID, model, time.Now())
+	if removedRes != nil {
+		return parsed, removedRes
+	}
+	model = migrated
+	parsed.model = model
+
+	// early reject the request if model doesn't exist, before the body is decoded.
+	if !s.cache.CheckModelExists(model) {
+		klog.ErrorS(nil, "model doesn't exist in cache, probably wrong model name", "requestID", requestID, "model", model)
+		return parsed, generateModelNotFoundResponse(model)
+	}
+
+	// fail fast if all the pods of the model are crashing, unless the request may spill over to a peer cluster.
+	if routing.Algorithms(routingStrategy) != routing.RouterSpillover {
+		if reason, down := s.cache.IsModelHardDown(model, time.Now()); down {
+			klog.InfoS("model is hard down", "requestID", requestID, "model", model, "reason", reason)
+			return parsed, generateModelHardDownResponse(model, reason)
+		}
+	}
+
+	if err := json.Unmarshal(body, &parsed.jsonMap); err != nil {
+		klog.ErrorS(err, "error to unmarshal response", "requestID", requestID, "requestBody", string(body))
+		return parsed, generateErrorResponse(envoyTypePb.StatusCode_InternalServerError,
+			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+				Key: HeaderErrorRequestBodyProcessing, RawValue: []byte("true")}}},
+			"error processing request body")
+	}
+	return parsed, nil
+}
+
+// endpointPortCache is the subset of the cache resolving the ports of the models discovered from EndpointSlices.
+type endpointPortCache interface {
+	GetEndpointPort(modelName, podName string) (int32, bool)
+}
+
+// resolveTargetPort replaces the port of the target selected by the routing algorithm with the named port of the
+// model's Service, if the model is discovered from the EndpointSlices of a Service.
+func resolveTargetPort(c endpointPortCache, model string, pods map[string]*v1.Pod, targetPodIP string) string {
+	pod := getServingPod(pods, targetPodIP)
+	if pod == nil {
+		return targetPodIP
+	}
+	port, ok := c.GetEndpointPort(model, pod.Name)
+	if !ok {
+		return targetPodIP
+	}
+	return net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port)))
+}
+
+// noReadyPodsRetryAfter returns when the pods of the model are expected to be routable again, the default delay if
+// the cache has no estimate for the model.
+func (s *Server) noReadyPodsRetryAfter(model string) time.Duration {
+	if retryAfter, ok := s.cache.EstimateRetryAfter(model, time.Now()); ok {
+		return retryAfter
+	}
+	return defaultNoReadyPodsRetryAfter
+}
@@ -18,7 +18,6 @@ package gateway
 
 import (
 	"context"
-	"strings"
 
 	"k8s.io/klog/v2"
 
@@ -26,51 +25,25 @@ import (
 	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
-	"github.com/vllm-project/aibrix/pkg/utils"
 )
 
-func (s *Server) HandleRequestHeaders(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest) (*extProcPb.ProcessingResponse, utils.User, int64, string) {
-	klog.InfoS("-- In RequestHeaders processing ...", "requestID", requestID)
-	var username string
-	var user utils.User
-	var rpm int64
-	var err error
-	var errRes *extProcPb.ProcessingResponse
+// HandleRequestHeaders validates the routing strategy and reads the identity of the request. Auth and ratelimit
+// run with the request body, once the model and so its middleware policy are known.
+func (s *Server) HandleRequestHeaders(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest) (*extProcPb.ProcessingResponse, *requestAccount, string) {
+	klog.V(4).InfoS("-- In RequestHeaders processing ...", "requestID", requestID)
 
 	h := req.Request.(*extProcPb.ProcessingRequest_RequestHeaders)
-	for _, n := range h.RequestHeaders.Headers.Headers {
-		if strings.ToLower(n.Key) == "user" {
-			username = string(n.RawValue)
-		}
-	}
+	account := newRequestAccount(h.RequestHeaders, s.trustedHops)
 
-	routingStrategy, routingStrategyEnabled := getRoutingStrategy(h.RequestHeaders.Headers.Headers)
+	routingStrategy, routingStrategyEnabled := getRoutingStrategy(h.RequestHeaders.Headers.Headers, string(routing.DefaultStrategy()))
 	if routingStrategyEnabled && !routing.Validate(routing.Algorithms(routingStrategy)) {
-		klog.ErrorS(nil, "incorrect routing strategy", "routing-strategy", routingStrategy)
+		err := &routing.UnknownStrategyError{Strategy: routing.Algorithms(routingStrategy)}
+		klog.ErrorS(err, "incorrect routing strategy", "routing-strategy", routingStrategy)
 		return generateErrorResponse(
 			envoyTypePb.StatusCode_BadRequest,
 			[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
 				Key: HeaderErrorInvalidRouting, RawValue: []byte(routingStrategy),
-			}}}, "incorrect routing strategy"), utils.User{}, rpm, routingStrategy
-	}
-
-	if username != "" {
-		user, err = utils.GetUser(ctx, utils.User{Name: username}, s.redisClient)
-		if err != nil {
-			klog.ErrorS(err, "unable to process user info", "requestID", requestID, "username", username)
-			return generateErrorResponse(
-				envoyTypePb.StatusCode_InternalServerError,
-				[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-					Key: HeaderErrorUser, RawValue: []byte("true"),
-				}}},
-				err.Error()), utils.User{}, rpm, routingStrategy
-		}
-
-		rpm, errRes, err = s.checkLimits(ctx, user)
-		if errRes != nil {
-			klog.ErrorS(err, "error on checking limits", "requestID", requestID, "username", username)
-			return errRes, utils.User{}, rpm, routingStrategy
-		}
+			}}}, err.Error()), account, routingStrategy
 	}
 
 	return &extProcPb.ProcessingResponse{
@@ -91,5 +64,5 @@ func (s *Server) HandleRequestHeaders(ctx context.Context, requestID string, req
 				},
 			},
 		},
-	}, user, rpm, routingStrategy
+	}, account, routingStrategy
 }
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"math/rand"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+)
+
+// adapterRolloutCache is the subset of the cache the adapter version router reads.
+type adapterRolloutCache interface {
+	GetModelAdapterRollout(modelName string) (cache.AdapterRollout, bool)
+}
+
+// adapterVersionRouter splits the traffic of a model adapter between its artifacts while a new one rolls out. The
+// new artifact is served under a versioned name and receives the rollout percentage of the requests, the others
+// keep going to the previous artifact under the name of the model adapter.
+type adapterVersionRouter struct {
+	cache adapterRolloutCache
+	// intn returns a number in [0, n), rand.Intn unless replaced in tests.
+	intn func(n int) int
+}
+
+func newAdapterVersionRouter(c adapterRolloutCache) *adapterVersionRouter {
+	return &adapterVersionRouter{cache: c, intn: rand.Intn}
+}
+
+// pick returns the name of the model the request is served by.
+func (r *adapterVersionRouter) pick(model string) string {
+	if r == nil {
+		return model
+	}
+	rollout, ok := r.cache.GetModelAdapterRollout(model)
+	if !ok || rollout.Version == "" {
+		return model
+	}
+	if int32(r.intn(100)) < rollout.Percent {
+		return rollout.Version
+	}
+	return model
+}
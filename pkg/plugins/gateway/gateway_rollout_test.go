@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+)
+
+type fakeAdapterRollouts map[string]cache.AdapterRollout
+
+func (f fakeAdapterRollouts) GetModelAdapterRollout(modelName string) (cache.AdapterRollout, bool) {
+	rollout, ok := f[modelName]
+	return rollout, ok
+}
+
+// countVersions routes 100 requests drawing every number in [0, 100) once, and counts the requests per served model.
+func countVersions(router *adapterVersionRouter, model string) map[string]int {
+	next := 0
+	router.intn = func(n int) int {
+		value := next % n
+		next++
+		return value
+	}
+	counts := map[string]int{}
+	for i := 0; i < 100; i++ {
+		counts[router.pick(model)]++
+	}
+	return counts
+}
+
+func TestAdapterVersionRouterShiftsTraffic(t *testing.T) {
+	rollouts := fakeAdapterRollouts{"lora-1": {Version: "lora-1-5f3a9c1e", Percent: 30}}
+	router := newAdapterVersionRouter(rollouts)
+
+	assert.Equal(t, map[string]int{"lora-1": 70, "lora-1-5f3a9c1e": 30}, countVersions(router, "lora-1"))
+
+	rollouts["lora-1"] = cache.AdapterRollout{Version: "lora-1-5f3a9c1e", Percent: 0}
+	assert.Equal(t, map[string]int{"lora-1": 100}, countVersions(router, "lora-1"), "the new artifact is loaded but serves no traffic")
+
+	rollouts["lora-1"] = cache.AdapterRollout{Version: "lora-1-5f3a9c1e", Percent: 100}
+	assert.Equal(t, map[string]int{"lora-1-5f3a9c1e": 100}, countVersions(router, "lora-1"), "the previous artifact is drained before it is replaced")
+}
+
+func TestAdapterVersionRouterWithoutRollout(t *testing.T) {
+	router := newAdapterVersionRouter(fakeAdapterRollouts{"lora-1": {Version: "lora-1-5f3a9c1e", Percent: 50}})
+	assert.Equal(t, map[string]int{"llama-7b": 100}, countVersions(router, "llama-7b"), "models without a rollout are served as requested")
+
+	var disabled *adapterVersionRouter
+	assert.Equal(t, "lora-1", disabled.pick("lora-1"))
+}
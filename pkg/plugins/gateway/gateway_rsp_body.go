@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/packages/ssestream"
@@ -31,17 +32,17 @@ import (
 	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
-	"github.com/vllm-project/aibrix/pkg/utils"
 )
 
-func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest, user utils.User, rpm int64, model string, targetPodIP string, stream bool, traceTerm int64, hasCompleted bool) (*extProcPb.ProcessingResponse, bool) {
+func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest, account *requestAccount, model string, targetPodIP string, stream bool, traceTerm int64, hasCompleted bool) (*extProcPb.ProcessingResponse, bool) {
 	b := req.Request.(*extProcPb.ProcessingRequest_ResponseBody)
-	klog.InfoS("-- In ResponseBody processing ...", "requestID", requestID, "endOfStream", b.ResponseBody.EndOfStream)
+	klog.V(4).InfoS("-- In ResponseBody processing ...", "requestID", requestID, "endOfStream", b.ResponseBody.EndOfStream)
 
 	var res openai.ChatCompletion
 	var usage openai.CompletionUsage
 	var promptTokens, completionTokens int64
 	var headers []*configPb.HeaderValueOption
+	var bodyMutation *extProcPb.BodyMutation
 	complete := hasCompleted
 
 	defer func() {
@@ -52,19 +53,51 @@ func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *
 	}()
 
 	if stream {
+		body := b.ResponseBody.GetBody()
+		if account.engineAPI != nil {
+			translated, err := account.engineAPI.TranslateStream(body, b.ResponseBody.EndOfStream)
+			if err != nil {
+				klog.ErrorS(err, "error to translate response from the engine API", "requestID", requestID, "responseBody", string(body))
+				complete = true
+				return generateErrorResponse(
+					envoyTypePb.StatusCode_InternalServerError,
+					[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+						Key: HeaderErrorEngineAPI, RawValue: []byte("true"),
+					}}},
+					err.Error()), complete
+			}
+			body = translated
+			bodyMutation = &extProcPb.BodyMutation{Mutation: &extProcPb.BodyMutation_Body{Body: translated}}
+		}
+		// the events split across the chunks are scanned once complete, the stream itself is passed on as is.
+		events, tail := splitStreamEvents(account.streamTail, body, b.ResponseBody.EndOfStream)
+		if len(tail) > maxStreamTailBytes {
+			klog.ErrorS(nil, "dropping an oversized incomplete event of the stream", "requestID", requestID, "bytes", len(tail))
+			tail = nil
+		}
+		account.streamTail = tail
+		account.streamStarted = true
 		t := &http.Response{
-			Body: io.NopCloser(bytes.NewReader(b.ResponseBody.GetBody())),
+			Body: io.NopCloser(bytes.NewReader(events)),
 		}
 		streaming := ssestream.NewStream[openai.ChatCompletionChunk](ssestream.NewDecoder(t), nil)
 		for streaming.Next() {
 			evt := streaming.Current()
+			if evt.Model != "" {
+				account.responseModel = evt.Model
+			}
 			if len(evt.Choices) == 0 {
 				// Do not overwrite model, res can be empty.
 				usage = evt.Usage
 			}
+			for _, choice := range evt.Choices {
+				if choice.Delta.Content != "" {
+					account.streamedTokens += int64(s.tokenizers.ForModel(model).CountTokens(choice.Delta.Content))
+				}
+			}
 		}
 		if err := streaming.Err(); err != nil {
-			klog.ErrorS(err, "error to unmarshal response", "requestID", requestID, "responseBody", string(b.ResponseBody.GetBody()))
+			klog.ErrorS(err, "error to unmarshal response", "requestID", requestID, "responseBody", string(body))
 			complete = true
 			return generateErrorResponse(
 				envoyTypePb.StatusCode_InternalServerError,
@@ -82,6 +115,10 @@ func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *
 		buffer.Write(b.ResponseBody.Body)
 
 		if !b.ResponseBody.EndOfStream {
+			// The body with the deprecation notice, or translated from the engine API, is sent with the last chunk.
+			if account.deprecation != nil || account.engineAPI != nil {
+				return generateClearedBodyResponse(), complete
+			}
 			// Partial data received, wait for more chunks, we just return a common response here.
 			return &extProcPb.ProcessingResponse{
 				Response: &extProcPb.ProcessingResponse_ResponseBody{
@@ -97,6 +134,21 @@ func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *
 		// Clean up the buffer after final processing
 		requestBuffers.Delete(requestID)
 
+		if account.engineAPI != nil {
+			translated, err := account.engineAPI.TranslateBody(finalBody)
+			if err != nil {
+				klog.ErrorS(err, "error to translate response from the engine API", "requestID", requestID, "responseBody", string(finalBody))
+				complete = true
+				return generateErrorResponse(
+					envoyTypePb.StatusCode_InternalServerError,
+					[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+						Key: HeaderErrorEngineAPI, RawValue: []byte("true"),
+					}}},
+					err.Error()), complete
+			}
+			finalBody = translated
+		}
+
 		if err := json.Unmarshal(finalBody, &res); err != nil {
 			klog.ErrorS(err, "error to unmarshal response", "requestID", requestID, "responseBody", string(b.ResponseBody.GetBody()))
 			complete = true
@@ -123,6 +175,14 @@ func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *
 		}
 		// Do not overwrite model, res can be empty.
 		usage = res.Usage
+		account.responseModel = res.Model
+		if account.deprecation != nil {
+			bodyMutation = &extProcPb.BodyMutation{
+				Mutation: &extProcPb.BodyMutation_Body{Body: account.deprecation.patchBody(requestID, finalBody)},
+			}
+		} else if account.engineAPI != nil {
+			bodyMutation = &extProcPb.BodyMutation{Mutation: &extProcPb.BodyMutation_Body{Body: finalBody}}
+		}
 	}
 
 	var requestEnd string
@@ -132,17 +192,7 @@ func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *
 		promptTokens = usage.PromptTokens
 		completionTokens = usage.CompletionTokens
 		// Count token per user.
-		if user.Name != "" {
-			tpm, err := s.ratelimiter.Incr(ctx, fmt.Sprintf("%v_TPM_CURRENT", user), res.Usage.TotalTokens)
-			if err != nil {
-				return generateErrorResponse(
-					envoyTypePb.StatusCode_InternalServerError,
-					[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
-						Key: HeaderErrorIncrTPM, RawValue: []byte("true"),
-					}}},
-					err.Error()), complete
-			}
-
+		if rpm, tpm, ok := s.accountUsage(account, model, usage.PromptTokens, usage.CompletionTokens); ok {
 			headers = append(headers,
 				&configPb.HeaderValueOption{
 					Header: &configPb.HeaderValue{
@@ -157,22 +207,28 @@ func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *
 					},
 				},
 			)
-			requestEnd = fmt.Sprintf(requestEnd+"rpm: %s, tpm: %s, ", rpm, tpm)
+			requestEnd = fmt.Sprintf(requestEnd+"rpm: %d, tpm: %d, ", rpm, tpm)
 		}
 
 		if targetPodIP != "" {
-			headers = append(headers,
-				&configPb.HeaderValueOption{
-					Header: &configPb.HeaderValue{
-						Key:      HeaderTargetPod,
-						RawValue: []byte(targetPodIP),
+			if s.getResponseHeaderPolicy().ExposeTargetPod {
+				headers = append(headers,
+					&configPb.HeaderValueOption{
+						Header: &configPb.HeaderValue{
+							Key:      HeaderTargetPod,
+							RawValue: []byte(targetPodIP),
+						},
 					},
-				},
-			)
+				)
+			}
 			requestEnd = fmt.Sprintf(requestEnd+"targetPod: %s", targetPodIP)
 		}
 
-		klog.Infof("request end, requestID: %s - %s", requestID, requestEnd)
+		if account.logSampled {
+			klog.Infof("request end, requestID: %s - %s", requestID, requestEnd)
+		}
+	} else if stream && b.ResponseBody.EndOfStream {
+		s.accountStreamEstimate(requestID, account, model, usageEstimatedNoUsage)
 	}
 
 	return &extProcPb.ProcessingResponse{
@@ -182,8 +238,66 @@ func (s *Server) HandleResponseBody(ctx context.Context, requestID string, req *
 					HeaderMutation: &extProcPb.HeaderMutation{
 						SetHeaders: headers,
 					},
+					BodyMutation: bodyMutation,
 				},
 			},
 		},
 	}, complete
 }
+
+// maxStreamTailBytes bounds the incomplete event kept from a chunk of a stream until the next chunk completes it.
+const maxStreamTailBytes = 64 << 10
+
+// splitStreamEvents returns the complete events of the chunk of an SSE stream, following the incomplete event at
+// the end of the previous chunk, and the incomplete event at its end. The last chunk of the stream has no
+// incomplete event.
+func splitStreamEvents(tail, chunk []byte, endOfStream bool) ([]byte, []byte) {
+	if len(tail) == 0 && endOfStream {
+		return chunk, nil
+	}
+	data := make([]byte, 0, len(tail)+len(chunk))
+	data = append(append(data, tail...), chunk...)
+	if endOfStream {
+		return data, nil
+	}
+	end := bytes.LastIndex(data, []byte("\n\n"))
+	if end >= 0 {
+		end += 2
+	}
+	if crlf := bytes.LastIndex(data, []byte("\r\n\r\n")); crlf >= 0 && crlf+4 > end {
+		end = crlf + 4
+	}
+	if end < 0 {
+		return nil, data
+	}
+	return data[:end], bytes.Clone(data[end:])
+}
+
+// accountUsage counts the usage of the response against the TPM of the user and the tokens of its tenant, and
+// meters it, once per request. It returns the RPM and TPM of the user, false if the usage does not count against
+// the TPM of a user.
+func (s *Server) accountUsage(account *requestAccount, model string, promptTokens, completionTokens int64) (int64, int64, bool) {
+	if account.usageRecorded {
+		return 0, 0, false
+	}
+	account.usageRecorded = true
+	totalTokens := promptTokens + completionTokens
+	s.recordTenantTokens(account, totalTokens)
+	s.usage.record(account.usageUser(), account.meteredModel(model), promptTokens, completionTokens, time.Now())
+	if !account.accounting || account.user.Name == "" {
+		return 0, 0, false
+	}
+	return account.rpm, s.incrTPM(account, totalTokens), true
+}
+
+// accountStreamEstimate accounts the estimated usage of a stream which ended without reporting its usage, the
+// estimate of its prompt and of the tokens of its content deltas, like the usage of the other responses.
+func (s *Server) accountStreamEstimate(requestID string, account *requestAccount, model, reason string) {
+	if account == nil || !account.streamStarted || account.usageRecorded {
+		return
+	}
+	klog.V(4).InfoS("accounting the estimated usage of a stream without usage", "requestID", requestID, "reason", reason,
+		"promptTokens", account.promptTokens, "completionTokens", account.streamedTokens)
+	usageEstimated.WithLabelValues(reason).Inc()
+	s.accountUsage(account, model, account.promptTokens, account.streamedTokens)
+}
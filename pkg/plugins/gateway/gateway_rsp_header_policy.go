@@ -0,0 +1,233 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	EnvResponseHeaderMode            = "AIBRIX_RESPONSE_HEADER_MODE"
+	EnvResponseHeaderDenylist        = "AIBRIX_RESPONSE_HEADER_DENYLIST"
+	EnvResponseHeaderAllowlist       = "AIBRIX_RESPONSE_HEADER_ALLOWLIST"
+	EnvResponseHeaderExposeTargetPod = "AIBRIX_RESPONSE_HEADER_EXPOSE_TARGET_POD"
+	// EnvResponseHeaderConfigFile is a JSON file, e.g. mounted from a ConfigMap, overriding the env configuration.
+	// It is reloaded when it changes.
+	EnvResponseHeaderConfigFile = "AIBRIX_RESPONSE_HEADER_CONFIG_FILE"
+
+	responseHeaderModeDenylist  = "denylist"
+	responseHeaderModeAllowlist = "allowlist"
+
+	// responseHeaderReloadInterval is how often the config file is checked for changes.
+	responseHeaderReloadInterval = 10 * time.Second
+)
+
+var (
+	// defaultResponseHeaderDenylist covers the headers engines commonly use for debug information and the
+	// identity of the node or pod serving the request.
+	defaultResponseHeaderDenylist = []string{"x-debug-*", "x-internal-*", "x-node-*", "x-pod-*", "x-hostname", "x-powered-by", "server"}
+
+	// protectedResponseHeaders are never stripped, the response can not be framed or streamed without them.
+	protectedResponseHeaders = map[string]struct{}{
+		"content-type":      {},
+		"content-length":    {},
+		"content-encoding":  {},
+		"transfer-encoding": {},
+		"cache-control":     {},
+		"connection":        {},
+		"date":              {},
+		"retry-after":       {},
+	}
+)
+
+// responseHeaderPolicy decides which upstream response headers are returned to the clients. Header names are
+// matched case-insensitively, a pattern ending with "*" matches the names with its prefix.
+type responseHeaderPolicy struct {
+	// Mode is denylist to strip the headers matching the denylist, or allowlist to only return the headers matching
+	// the allowlist. The denylist applies in both modes.
+	Mode            string   `json:"mode"`
+	Denylist        []string `json:"denylist"`
+	Allowlist       []string `json:"allowlist"`
+	ExposeTargetPod bool     `json:"exposeTargetPod"`
+}
+
+func (p *responseHeaderPolicy) validate() error {
+	if p.Mode != responseHeaderModeDenylist && p.Mode != responseHeaderModeAllowlist {
+		return fmt.Errorf("invalid response header mode %q, supported: %s, %s", p.Mode, responseHeaderModeDenylist, responseHeaderModeAllowlist)
+	}
+	p.Denylist = normalizeHeaderPatterns(p.Denylist)
+	p.Allowlist = normalizeHeaderPatterns(p.Allowlist)
+	return nil
+}
+
+// keep reports whether the upstream response header is returned to the client.
+func (p *responseHeaderPolicy) keep(key string) bool {
+	key = strings.ToLower(key)
+	// pseudo headers such as :status can not be removed.
+	if strings.HasPrefix(key, ":") {
+		return true
+	}
+	if _, ok := protectedResponseHeaders[key]; ok {
+		return true
+	}
+	if matchHeaderPatterns(p.Denylist, key) {
+		return false
+	}
+	if p.Mode == responseHeaderModeAllowlist {
+		return matchHeaderPatterns(p.Allowlist, key)
+	}
+	return true
+}
+
+func normalizeHeaderPatterns(patterns []string) []string {
+	res := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			res = append(res, pattern)
+		}
+	}
+	return res
+}
+
+func matchHeaderPatterns(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func splitHeaderList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func newResponseHeaderPolicyFromEnv() responseHeaderPolicy {
+	policy := responseHeaderPolicy{
+		Mode:            responseHeaderModeDenylist,
+		Denylist:        defaultResponseHeaderDenylist,
+		ExposeTargetPod: true,
+	}
+	if value, exists := utils.CheckEnvExists(EnvResponseHeaderMode); exists {
+		policy.Mode = strings.ToLower(strings.TrimSpace(value))
+	}
+	if value, exists := utils.CheckEnvExists(EnvResponseHeaderDenylist); exists {
+		policy.Denylist = splitHeaderList(value)
+	}
+	if value, exists := utils.CheckEnvExists(EnvResponseHeaderAllowlist); exists {
+		policy.Allowlist = splitHeaderList(value)
+	}
+	if value, exists := utils.CheckEnvExists(EnvResponseHeaderExposeTargetPod); exists {
+		expose, err := strconv.ParseBool(value)
+		if err != nil {
+			klog.Infof("invalid %s: %s, falling back to default", EnvResponseHeaderExposeTargetPod, value)
+		} else {
+			policy.ExposeTargetPod = expose
+		}
+	}
+	if err := policy.validate(); err != nil {
+		klog.ErrorS(err, "invalid response header policy, falling back to default", "env", EnvResponseHeaderMode)
+		policy.Mode = responseHeaderModeDenylist
+		_ = policy.validate()
+	}
+	return policy
+}
+
+// responseHeaderConfig holds the response header policy, the policy of the env is overridden by the config file,
+// which is reloaded when it changes so that the policy can be updated without restarting the gateway.
+type responseHeaderConfig struct {
+	mu        sync.Mutex
+	base      responseHeaderPolicy
+	policy    responseHeaderPolicy
+	path      string
+	modTime   time.Time
+	lastCheck time.Time
+	now       func() time.Time
+}
+
+func newResponseHeaderConfigFromEnv() *responseHeaderConfig {
+	c := &responseHeaderConfig{
+		base: newResponseHeaderPolicyFromEnv(),
+		path: utils.LoadEnv(EnvResponseHeaderConfigFile, ""),
+		now:  time.Now,
+	}
+	c.policy = c.base
+	c.reload()
+	return c
+}
+
+// get returns the current response header policy.
+func (c *responseHeaderConfig) get() responseHeaderPolicy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path != "" && c.now().Sub(c.lastCheck) >= responseHeaderReloadInterval {
+		c.reload()
+	}
+	return c.policy
+}
+
+// reload reads the config file if it changed, an invalid file keeps the current policy.
+func (c *responseHeaderConfig) reload() {
+	if c.path == "" {
+		return
+	}
+	c.lastCheck = c.now()
+	info, err := os.Stat(c.path)
+	if err != nil {
+		klog.ErrorS(err, "failed to stat response header config file, keeping the current policy", "path", c.path)
+		return
+	}
+	if info.ModTime().Equal(c.modTime) {
+		return
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		klog.ErrorS(err, "failed to read response header config file, keeping the current policy", "path", c.path)
+		return
+	}
+	// the keys missing from the file keep the values of the env.
+	policy := c.base
+	if err := json.Unmarshal(data, &policy); err != nil {
+		klog.ErrorS(err, "invalid response header config file, keeping the current policy", "path", c.path)
+		return
+	}
+	if err := policy.validate(); err != nil {
+		klog.ErrorS(err, "invalid response header config file, keeping the current policy", "path", c.path)
+		return
+	}
+	c.policy = policy
+	c.modTime = info.ModTime()
+	klog.InfoS("loaded response header policy", "path", c.path, "mode", policy.Mode, "denylist", policy.Denylist,
+		"allowlist", policy.Allowlist, "exposeTargetPod", policy.ExposeTargetPod)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseHeaderPolicyKeep(t *testing.T) {
+	denylist := responseHeaderPolicy{Mode: responseHeaderModeDenylist, Denylist: []string{"X-Debug-*", "server"}}
+	assert.NoError(t, denylist.validate())
+	allowlist := responseHeaderPolicy{Mode: responseHeaderModeAllowlist, Denylist: []string{"x-debug-*"}, Allowlist: []string{"x-ratelimit-*", "X-Debug-Id"}}
+	assert.NoError(t, allowlist.validate())
+
+	var tests = []struct {
+		policy   responseHeaderPolicy
+		key      string
+		expected bool
+		message  string
+	}{
+		{policy: denylist, key: "x-debug-trace", expected: false, message: "denylisted prefix"},
+		{policy: denylist, key: "X-DEBUG-Trace", expected: false, message: "denylist matches case-insensitively"},
+		{policy: denylist, key: "Server", expected: false, message: "denylisted name"},
+		{policy: denylist, key: "x-server-id", expected: true, message: "a name is not a prefix"},
+		{policy: denylist, key: "x-ratelimit-remaining", expected: true, message: "not denylisted"},
+		{policy: allowlist, key: "X-RateLimit-Remaining", expected: true, message: "allowlisted prefix"},
+		{policy: allowlist, key: "x-request-cost", expected: false, message: "not allowlisted"},
+		{policy: allowlist, key: "x-debug-id", expected: false, message: "the denylist applies in allowlist mode"},
+		{policy: allowlist, key: "Content-Type", expected: true, message: "protected header"},
+		{policy: allowlist, key: "cache-control", expected: true, message: "protected header of event streams"},
+		{policy: allowlist, key: ":status", expected: true, message: "pseudo header"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.policy.keep(tt.key), tt.message)
+	}
+
+	invalid := responseHeaderPolicy{Mode: "strict"}
+	assert.Error(t, invalid.validate())
+}
+
+func TestResponseHeaderPolicyFromEnv(t *testing.T) {
+	policy := newResponseHeaderPolicyFromEnv()
+	assert.Equal(t, responseHeaderModeDenylist, policy.Mode)
+	assert.True(t, policy.ExposeTargetPod)
+	assert.False(t, policy.keep("x-node-name"))
+
+	t.Setenv(EnvResponseHeaderMode, "Allowlist")
+	t.Setenv(EnvResponseHeaderAllowlist, "x-ratelimit-*, x-request-cost")
+	t.Setenv(EnvResponseHeaderDenylist, "")
+	t.Setenv(EnvResponseHeaderExposeTargetPod, "false")
+	policy = newResponseHeaderPolicyFromEnv()
+	assert.Equal(t, responseHeaderModeAllowlist, policy.Mode)
+	assert.Equal(t, []string{"x-ratelimit-*", "x-request-cost"}, policy.Allowlist)
+	assert.Empty(t, policy.Denylist)
+	assert.False(t, policy.ExposeTargetPod)
+
+	t.Setenv(EnvResponseHeaderMode, "strict")
+	policy = newResponseHeaderPolicyFromEnv()
+	assert.Equal(t, responseHeaderModeDenylist, policy.Mode, "an invalid mode falls back to the denylist")
+}
+
+func TestResponseHeaderConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "response-headers.json")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	write := func(content string, modTime time.Time) {
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	write(`{"mode": "allowlist", "allowlist": ["x-ratelimit-*"]}`, now)
+
+	c := &responseHeaderConfig{base: newResponseHeaderPolicyFromEnv(), path: path, now: func() time.Time { return now }}
+	c.policy = c.base
+	c.reload()
+	policy := c.get()
+	assert.Equal(t, responseHeaderModeAllowlist, policy.Mode)
+	assert.True(t, policy.ExposeTargetPod, "the keys missing from the file keep the env values")
+	assert.False(t, policy.keep("x-request-cost"))
+
+	// the change is picked up at the next check.
+	write(`{"mode": "denylist", "exposeTargetPod": false}`, now.Add(time.Minute))
+	assert.Equal(t, responseHeaderModeAllowlist, c.get().Mode)
+	now = now.Add(responseHeaderReloadInterval)
+	policy = c.get()
+	assert.Equal(t, responseHeaderModeDenylist, policy.Mode)
+	assert.False(t, policy.ExposeTargetPod)
+
+	// an invalid file keeps the current policy.
+	write(`{"mode": "strict"}`, now.Add(2*time.Minute))
+	now = now.Add(responseHeaderReloadInterval)
+	assert.Equal(t, responseHeaderModeDenylist, c.get().Mode)
+}
+
+func newResponseHeadersRequest(headers ...*configPb.HeaderValue) *extProcPb.ProcessingRequest {
+	return &extProcPb.ProcessingRequest{
+		Request: &extProcPb.ProcessingRequest_ResponseHeaders{
+			ResponseHeaders: &extProcPb.HttpHeaders{
+				Headers: &configPb.HeaderMap{Headers: headers},
+			},
+		},
+	}
+}
+
+func setHeaderValues(resp *extProcPb.ProcessingResponse) map[string]string {
+	values := map[string]string{}
+	for _, header := range resp.GetResponseHeaders().GetResponse().GetHeaderMutation().GetSetHeaders() {
+		values[header.Header.Key] = string(header.Header.RawValue)
+	}
+	return values
+}
+
+func TestHandleResponseHeadersPolicy(t *testing.T) {
+	upstream := []*configPb.HeaderValue{
+		{Key: ":status", RawValue: []byte("200")},
+		{Key: "X-Debug-Trace", RawValue: []byte("engine=vllm")},
+		{Key: "x-node-name", RawValue: []byte("node-1")},
+		{Key: "x-ratelimit-remaining", RawValue: []byte("10")},
+		{Key: "Target-Pod", RawValue: []byte("10.0.0.2")},
+	}
+	for _, contentType := range []string{"text/event-stream", "application/json"} {
+		headers := append([]*configPb.HeaderValue{{Key: "content-type", RawValue: []byte(contentType)}}, upstream...)
+
+		s := &Server{responseHeaders: &responseHeaderConfig{policy: newResponseHeaderPolicyFromEnv(), now: time.Now}}
+		resp, isError, _ := s.HandleResponseHeaders(context.Background(), "request-1", newResponseHeadersRequest(headers...), &requestAccount{}, "10.0.0.1")
+		assert.False(t, isError)
+		mutation := resp.GetResponseHeaders().GetResponse().GetHeaderMutation()
+		assert.ElementsMatch(t, []string{"X-Debug-Trace", "x-node-name"}, mutation.RemoveHeaders, contentType)
+		values := setHeaderValues(resp)
+		assert.Equal(t, contentType, values["content-type"])
+		assert.Equal(t, "10", values["x-ratelimit-remaining"])
+		assert.Equal(t, "request-1", values[HeaderRequestID])
+		assert.Equal(t, "10.0.0.1", values[HeaderTargetPod], "the gateway overwrites the upstream target pod")
+		assert.NotContains(t, values, "Target-Pod")
+
+		allowlist := responseHeaderPolicy{Mode: responseHeaderModeAllowlist}
+		assert.NoError(t, allowlist.validate())
+		s = &Server{responseHeaders: &responseHeaderConfig{policy: allowlist, now: time.Now}}
+		resp, _, _ = s.HandleResponseHeaders(context.Background(), "request-1", newResponseHeadersRequest(headers...), &requestAccount{}, "10.0.0.1")
+		mutation = resp.GetResponseHeaders().GetResponse().GetHeaderMutation()
+		assert.ElementsMatch(t, []string{"X-Debug-Trace", "x-node-name", "x-ratelimit-remaining", "Target-Pod"}, mutation.RemoveHeaders, contentType)
+		values = setHeaderValues(resp)
+		assert.Equal(t, contentType, values["content-type"])
+		assert.Equal(t, "200", values[":status"])
+		assert.Equal(t, "request-1", values[HeaderRequestID])
+		assert.NotContains(t, values, HeaderTargetPod, "the target pod is not exposed")
+	}
+}
@@ -19,6 +19,7 @@ package gateway
 import (
 	"context"
 	"strconv"
+	"strings"
 
 	"k8s.io/klog/v2"
 
@@ -26,17 +27,27 @@ import (
 	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 )
 
-func (s *Server) HandleResponseHeaders(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest, targetPodIP string) (*extProcPb.ProcessingResponse, bool, int) {
-	klog.InfoS("-- In ResponseHeaders processing ...", "requestID", requestID)
+func (s *Server) HandleResponseHeaders(ctx context.Context, requestID string, req *extProcPb.ProcessingRequest, account *requestAccount, targetPodIP string) (*extProcPb.ProcessingResponse, bool, int) {
+	klog.V(4).InfoS("-- In ResponseHeaders processing ...", "requestID", requestID)
 	b := req.Request.(*extProcPb.ProcessingRequest_ResponseHeaders)
 
-	headers := []*configPb.HeaderValueOption{{
-		Header: &configPb.HeaderValue{
-			Key:      HeaderWentIntoReqHeaders,
-			RawValue: []byte("true"),
+	policy := s.getResponseHeaderPolicy()
+	headers := []*configPb.HeaderValueOption{
+		{
+			Header: &configPb.HeaderValue{
+				Key:      HeaderWentIntoReqHeaders,
+				RawValue: []byte("true"),
+			},
+		},
+		{
+			Header: &configPb.HeaderValue{
+				Key:      HeaderRequestID,
+				RawValue: []byte(requestID),
+			},
 		},
-	}}
-	if targetPodIP != "" {
+	}
+	exposeTargetPod := targetPodIP != "" && policy.ExposeTargetPod
+	if exposeTargetPod {
 		headers = append(headers, &configPb.HeaderValueOption{
 			Header: &configPb.HeaderValue{
 				Key:      HeaderTargetPod,
@@ -44,9 +55,23 @@ func (s *Server) HandleResponseHeaders(ctx context.Context, requestID string, re
 			},
 		})
 	}
+	// injectedHeaders are the headers of the gateway, mapped to whether they are set on this response.
+	injectedHeaders := map[string]bool{
+		HeaderWentIntoReqHeaders: true,
+		HeaderRequestID:          true,
+		HeaderTargetPod:          exposeTargetPod,
+	}
+	// tell the client the usage of the limits of its user.
+	if account.rateLimits != nil {
+		for _, header := range account.rateLimits.headers() {
+			headers = append(headers, header)
+			injectedHeaders[header.Header.Key] = true
+		}
+	}
 
 	var isProcessingError bool
 	var processingErrorCode int
+	var removeHeaders []string
 	for _, headerValue := range b.ResponseHeaders.Headers.Headers {
 		if headerValue.Key == ":status" {
 			code, _ := strconv.Atoi(string(headerValue.RawValue))
@@ -55,6 +80,17 @@ func (s *Server) HandleResponseHeaders(ctx context.Context, requestID string, re
 				processingErrorCode = code
 			}
 		}
+		if injected, ok := injectedHeaders[strings.ToLower(headerValue.Key)]; ok {
+			// the headers injected by the gateway overwrite the upstream ones.
+			if !injected {
+				removeHeaders = append(removeHeaders, headerValue.Key)
+			}
+			continue
+		}
+		if !policy.keep(headerValue.Key) {
+			removeHeaders = append(removeHeaders, headerValue.Key)
+			continue
+		}
 		headers = append(headers, &configPb.HeaderValueOption{
 			Header: &configPb.HeaderValue{
 				Key:      headerValue.Key,
@@ -62,13 +98,17 @@ func (s *Server) HandleResponseHeaders(ctx context.Context, requestID string, re
 			},
 		})
 	}
+	if len(removeHeaders) > 0 {
+		klog.V(4).InfoS("stripping upstream response headers", "requestID", requestID, "headers", removeHeaders)
+	}
 
 	return &extProcPb.ProcessingResponse{
 		Response: &extProcPb.ProcessingResponse_ResponseHeaders{
 			ResponseHeaders: &extProcPb.HeadersResponse{
 				Response: &extProcPb.CommonResponse{
 					HeaderMutation: &extProcPb.HeaderMutation{
-						SetHeaders: headers,
+						SetHeaders:    headers,
+						RemoveHeaders: removeHeaders,
 					},
 					ClearRouteCache: true,
 				},
@@ -76,3 +116,11 @@ func (s *Server) HandleResponseHeaders(ctx context.Context, requestID string, re
 		},
 	}, isProcessingError, processingErrorCode
 }
+
+// getResponseHeaderPolicy returns the policy applied to the upstream response headers.
+func (s *Server) getResponseHeaderPolicy() responseHeaderPolicy {
+	if s.responseHeaders == nil {
+		return responseHeaderPolicy{Mode: responseHeaderModeDenylist, ExposeTargetPod: true}
+	}
+	return s.responseHeaders.get()
+}
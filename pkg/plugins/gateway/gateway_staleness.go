@@ -0,0 +1,66 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+var podMetricStalenessDesc = prometheus.NewDesc(
+	"aibrix_gateway_pod_metric_staleness_seconds",
+	"Seconds since the metrics of the pod were last scraped successfully by the gateway.",
+	[]string{"pod"}, nil,
+)
+
+// stalenessCache is the subset of the cache the staleness gauge reads.
+type stalenessCache interface {
+	GetPods() map[string]*v1.Pod
+	LastScrapeTime(podName string) (time.Time, bool)
+}
+
+// podMetricStalenessCollector exports the age of the last successful metrics scrape of each pod, the pods never
+// scraped are left out. A growing age flags a wedged pod whose metrics the routers ignore once past their TTL.
+type podMetricStalenessCollector struct {
+	cache stalenessCache
+	now   func() time.Time
+}
+
+func (c *podMetricStalenessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- podMetricStalenessDesc
+}
+
+func (c *podMetricStalenessCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	for name := range c.cache.GetPods() {
+		scrapeTime, ok := c.cache.LastScrapeTime(name)
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(podMetricStalenessDesc, prometheus.GaugeValue, now.Sub(scrapeTime).Seconds(), name)
+	}
+}
+
+// registerPodMetricStaleness registers the staleness gauge of the pod metrics of the cache.
+func registerPodMetricStaleness(c stalenessCache) {
+	if err := prometheus.Register(&podMetricStalenessCollector{cache: c, now: time.Now}); err != nil {
+		klog.ErrorS(err, "failed to register the pod metric staleness gauge")
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeStalenessCache serves the pods with their last scrape time, the pods without one were never scraped.
+type fakeStalenessCache map[string]time.Time
+
+func (c fakeStalenessCache) GetPods() map[string]*v1.Pod {
+	return map[string]*v1.Pod{
+		"p1": {ObjectMeta: metav1.ObjectMeta{Name: "p1"}},
+		"p2": {ObjectMeta: metav1.ObjectMeta{Name: "p2"}},
+		"p3": {ObjectMeta: metav1.ObjectMeta{Name: "p3"}},
+	}
+}
+
+func (c fakeStalenessCache) LastScrapeTime(podName string) (time.Time, bool) {
+	scrapeTime, ok := c[podName]
+	return scrapeTime, ok
+}
+
+func TestPodMetricStalenessCollector(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	collector := &podMetricStalenessCollector{
+		cache: fakeStalenessCache{"p1": now.Add(-500 * time.Millisecond), "p2": now.Add(-2 * time.Minute)},
+		now:   func() time.Time { return now },
+	}
+
+	expected := `
+# HELP aibrix_gateway_pod_metric_staleness_seconds Seconds since the metrics of the pod were last scraped successfully by the gateway.
+# TYPE aibrix_gateway_pod_metric_staleness_seconds gauge
+aibrix_gateway_pod_metric_staleness_seconds{pod="p1"} 0.5
+aibrix_gateway_pod_metric_staleness_seconds{pod="p2"} 120
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
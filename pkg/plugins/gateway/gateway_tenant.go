@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// pools the requests of a tenant are served by.
+const (
+	tenantPoolReserved = "reserved"
+	tenantPoolShared   = "shared"
+)
+
+var tenantTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_gateway_tenant_tokens_total",
+	Help: "Number of tokens the gateway served to the tenants, by the pool of the pods serving them.",
+}, []string{"tenant", "pool"})
+
+func init() {
+	prometheus.MustRegister(tenantTokens)
+}
+
+// tenantPolicy is the isolation of the traffic of a tenant on its reserved pods.
+type tenantPolicy struct {
+	// Overflow allows the requests of the tenant onto the shared pods while its reserved pods are saturated.
+	Overflow bool `json:"overflow"`
+}
+
+// tenantCache is the subset of the cache the tenant pools read.
+type tenantCache interface {
+	GetTenantPodsForModel(modelName, tenant string) (cache.TenantPods, error)
+	GetPodModelMetric(podName, modelName string, metricName string) (metrics.MetricValue, error)
+}
+
+// tenantPools selects the pods serving the requests of a tenant. The pods labeled tenant.aibrix.ai/reserved-for
+// only serve the requests of their tenant, which are only served by them while they are not saturated. The
+// requests of a tenant without reserved pods for the model are served by the shared pods.
+type tenantPools struct {
+	policies map[string]tenantPolicy
+}
+
+// newTenantPoolsFromEnv reads the policies per tenant from AIBRIX_GATEWAY_TENANTS, e.g. {"acme": {"overflow": true}}.
+// The tenants without a policy never overflow onto the shared pods.
+func newTenantPoolsFromEnv() *tenantPools {
+	p := &tenantPools{policies: map[string]tenantPolicy{}}
+	value, exists := utils.CheckEnvExists(EnvTenants)
+	if !exists {
+		return p
+	}
+	if err := json.Unmarshal([]byte(value), &p.policies); err != nil {
+		klog.ErrorS(err, "invalid tenant policies, ignoring", "env", EnvTenants)
+		p.policies = map[string]tenantPolicy{}
+	}
+	return p
+}
+
+// selectPods returns the routable pods of the model for the tenant, and the pool they belong to.
+func (p *tenantPools) selectPods(c tenantCache, model, tenant string) (map[string]*v1.Pod, string, error) {
+	pods, err := c.GetTenantPodsForModel(model, tenant)
+	if err != nil {
+		return nil, "", err
+	}
+	if pods.Reservations == 0 {
+		return pods.Shared, tenantPoolShared, nil
+	}
+	if !isPoolSaturated(c, model, pods.Reserved) {
+		return pods.Reserved, tenantPoolReserved, nil
+	}
+	if p != nil && p.policies[tenant].Overflow && len(utils.FilterReadyPods(pods.Shared)) > 0 {
+		klog.V(4).InfoS("reserved pods are saturated, overflowing onto the shared pods", "tenant", tenant, "model", model)
+		return pods.Shared, tenantPoolShared, nil
+	}
+	return pods.Reserved, tenantPoolReserved, nil
+}
+
+// isPoolSaturated returns whether none of the ready pods can take a request without queueing it, the pods the
+// adaptive concurrency limit filtered out are not ready to take one either.
+func isPoolSaturated(c tenantCache, model string, pods map[string]*v1.Pod) bool {
+	for _, pod := range utils.FilterReadyPods(pods) {
+		waiting, err := c.GetPodModelMetric(pod.Name, model, metrics.NumRequestsWaiting)
+		if err != nil || waiting.GetSimpleValue() == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// recordTenantTokens attributes the tokens of a request to the pool of its tenant which served it.
+func (s *Server) recordTenantTokens(account *requestAccount, tokens int64) {
+	tenant := account.user.Tenant
+	if tenant == "" || account.tenantPool == "" {
+		return
+	}
+	tenantTokens.WithLabelValues(tenant, account.tenantPool).Add(float64(tokens))
+	if account.accounting {
+		s.accounting.record(fmt.Sprintf("%v_%v_TOKENS", tenant, account.tenantPool), tokens)
+	}
+}
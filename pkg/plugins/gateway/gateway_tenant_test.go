@@ -0,0 +1,178 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/metrics"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// fakeTenantCache serves the pods reserved for acme and the shared pods, the pods of waiting having requests
+// waiting.
+type fakeTenantCache struct {
+	reserved map[string]*v1.Pod
+	shared   map[string]*v1.Pod
+	waiting  map[string]bool
+}
+
+func newTenantPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			PodIP:      "10.0.0.1",
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func newFakeTenantCache() *fakeTenantCache {
+	return &fakeTenantCache{
+		reserved: map[string]*v1.Pod{"acme-a": newTenantPod("acme-a"), "acme-b": newTenantPod("acme-b")},
+		shared:   map[string]*v1.Pod{"shared-a": newTenantPod("shared-a")},
+		waiting:  map[string]bool{},
+	}
+}
+
+func (c *fakeTenantCache) GetTenantPodsForModel(modelName, tenant string) (cache.TenantPods, error) {
+	if modelName != "m1" {
+		return cache.TenantPods{}, fmt.Errorf("%w: %s", cache.ErrModelNotFound, modelName)
+	}
+	if tenant != "acme" {
+		return cache.TenantPods{Reserved: map[string]*v1.Pod{}, Shared: c.shared}, nil
+	}
+	return cache.TenantPods{Reserved: c.reserved, Shared: c.shared, Reservations: len(c.reserved)}, nil
+}
+
+func (c *fakeTenantCache) GetPodModelMetric(podName, modelName string, metricName string) (metrics.MetricValue, error) {
+	if c.waiting[podName] {
+		return &metrics.SimpleMetricValue{Value: 2}, nil
+	}
+	return &metrics.SimpleMetricValue{Value: 0}, nil
+}
+
+func podNames(pods map[string]*v1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for name := range pods {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestTenantPoolsSelectPods(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policies map[string]tenantPolicy
+		tenant   string
+		waiting  []string
+		expected []string
+		pool     string
+	}{
+		{
+			name:     "reserved pods serve their tenant",
+			tenant:   "acme",
+			policies: map[string]tenantPolicy{"acme": {Overflow: true}},
+			waiting:  []string{"acme-a"},
+			expected: []string{"acme-a", "acme-b"},
+			pool:     tenantPoolReserved,
+		},
+		{
+			name:     "reserved only",
+			tenant:   "acme",
+			waiting:  []string{"acme-a", "acme-b"},
+			expected: []string{"acme-a", "acme-b"},
+			pool:     tenantPoolReserved,
+		},
+		{
+			name:     "overflow allowed",
+			tenant:   "acme",
+			policies: map[string]tenantPolicy{"acme": {Overflow: true}},
+			waiting:  []string{"acme-a", "acme-b"},
+			expected: []string{"shared-a"},
+			pool:     tenantPoolShared,
+		},
+		{
+			name:     "overflow onto busy shared pods",
+			tenant:   "acme",
+			policies: map[string]tenantPolicy{"acme": {Overflow: true}},
+			waiting:  []string{"acme-a", "acme-b", "shared-a"},
+			expected: []string{"shared-a"},
+			pool:     tenantPoolShared,
+		},
+		{
+			name:     "other tenants are excluded from the reserved pods",
+			tenant:   "globex",
+			policies: map[string]tenantPolicy{"globex": {Overflow: true}},
+			expected: []string{"shared-a"},
+			pool:     tenantPoolShared,
+		},
+		{
+			name:     "users without a tenant are excluded from the reserved pods",
+			expected: []string{"shared-a"},
+			pool:     tenantPoolShared,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newFakeTenantCache()
+			for _, pod := range tc.waiting {
+				c.waiting[pod] = true
+			}
+			p := &tenantPools{policies: tc.policies}
+			pods, pool, err := p.selectPods(c, "m1", tc.tenant)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, podNames(pods))
+			assert.Equal(t, tc.pool, pool)
+		})
+	}
+
+	// the shared pods only take the overflow if one of them is ready.
+	c := newFakeTenantCache()
+	c.waiting = map[string]bool{"acme-a": true, "acme-b": true}
+	c.shared = map[string]*v1.Pod{}
+	p := &tenantPools{policies: map[string]tenantPolicy{"acme": {Overflow: true}}}
+	pods, pool, err := p.selectPods(c, "m1", "acme")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"acme-a", "acme-b"}, podNames(pods))
+	assert.Equal(t, tenantPoolReserved, pool)
+
+	_, _, err = p.selectPods(c, "m2", "acme")
+	assert.Error(t, err)
+}
+
+func TestRecordTenantTokens(t *testing.T) {
+	reserved := testutil.ToFloat64(tenantTokens.WithLabelValues("acme", tenantPoolReserved))
+	shared := testutil.ToFloat64(tenantTokens.WithLabelValues("acme", tenantPoolShared))
+
+	s := &Server{}
+	s.recordTenantTokens(&requestAccount{user: utils.User{Name: "alice", Tenant: "acme"}, tenantPool: tenantPoolReserved}, 100)
+	s.recordTenantTokens(&requestAccount{user: utils.User{Name: "alice", Tenant: "acme"}, tenantPool: tenantPoolShared}, 30)
+	s.recordTenantTokens(&requestAccount{user: utils.User{Name: "bob"}, tenantPool: tenantPoolShared}, 50)
+
+	assert.Equal(t, reserved+100, testutil.ToFloat64(tenantTokens.WithLabelValues("acme", tenantPoolReserved)))
+	assert.Equal(t, shared+30, testutil.ToFloat64(tenantTokens.WithLabelValues("acme", tenantPoolShared)))
+}
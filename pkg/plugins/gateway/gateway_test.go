@@ -17,12 +17,17 @@ limitations under the License.
 package gateway
 
 import (
-	"os"
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/stretchr/testify/assert"
+	"github.com/vllm-project/aibrix/pkg/cache"
 	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
+	healthPb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func Test_ValidateRoutingStrategy(t *testing.T) {
@@ -66,61 +71,86 @@ func Test_ValidateRoutingStrategy(t *testing.T) {
 
 func TestGetRoutingStrategy(t *testing.T) {
 	var tests = []struct {
-		headers               []*configPb.HeaderValue
-		setEnvRoutingStrategy bool
-		envRoutingStrategy    string
-		expectedStrategy      string
-		expectedEnabled       bool
-		message               string
+		headers          []*configPb.HeaderValue
+		defaultStrategy  string
+		expectedStrategy string
+		expectedEnabled  bool
+		message          string
 	}{
 		{
-			headers:               []*configPb.HeaderValue{},
-			setEnvRoutingStrategy: false,
-			expectedStrategy:      "",
-			expectedEnabled:       false,
-			message:               "no routing strategy in headers or environment variable",
+			headers:          []*configPb.HeaderValue{},
+			expectedStrategy: "",
+			expectedEnabled:  false,
+			message:          "no routing strategy in headers and no default",
 		},
 		{
 			headers: []*configPb.HeaderValue{
 				{Key: "routing-strategy", RawValue: []byte("random")},
 			},
-			setEnvRoutingStrategy: false,
-			expectedStrategy:      "random",
-			expectedEnabled:       true,
-			message:               "routing strategy from headers",
+			expectedStrategy: "random",
+			expectedEnabled:  true,
+			message:          "routing strategy from headers",
 		},
 		{
-			headers:               []*configPb.HeaderValue{},
-			setEnvRoutingStrategy: true,
-			envRoutingStrategy:    "random",
-			expectedStrategy:      "random",
-			expectedEnabled:       true,
-			message:               "routing strategy from environment variable",
+			headers:          []*configPb.HeaderValue{},
+			defaultStrategy:  "random",
+			expectedStrategy: "random",
+			expectedEnabled:  true,
+			message:          "default routing strategy",
 		},
 		{
 			headers: []*configPb.HeaderValue{
 				{Key: "routing-strategy", RawValue: []byte("random")},
 			},
-			setEnvRoutingStrategy: true,
-			envRoutingStrategy:    "least-request",
-			expectedStrategy:      "random",
-			expectedEnabled:       true,
-			message:               "header routing strategy takes priority over environment variable",
+			defaultStrategy:  "least-request",
+			expectedStrategy: "random",
+			expectedEnabled:  true,
+			message:          "header routing strategy takes priority over the default",
 		},
 	}
 
 	for _, tt := range tests {
-		if tt.setEnvRoutingStrategy {
-			_ = os.Setenv("ROUTING_ALGORITHM", tt.envRoutingStrategy)
-		} else {
-			_ = os.Unsetenv("ROUTING_ALGORITHM")
-		}
-
-		routingStrategy, enabled := getRoutingStrategy(tt.headers)
+		routingStrategy, enabled := getRoutingStrategy(tt.headers, tt.defaultStrategy)
 		assert.Equal(t, tt.expectedStrategy, routingStrategy, tt.message)
 		assert.Equal(t, tt.expectedEnabled, enabled, tt.message)
+	}
+}
+
+func TestHealthCheckServer(t *testing.T) {
+	var redisErr error
+	s := NewHealthCheckServer(func(ctx context.Context) error { return redisErr })
+
+	rsp, err := s.Check(context.Background(), &healthPb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, healthPb.HealthCheckResponse_SERVING, rsp.Status)
 
-		// Cleanup environment variable for next test
-		_ = os.Unsetenv("ROUTING_ALGORITHM")
+	redisErr = errors.New("connection refused")
+	rsp, err = s.Check(context.Background(), &healthPb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, healthPb.HealthCheckResponse_NOT_SERVING, rsp.Status)
+}
+
+func TestGenerateModelHardDownResponse(t *testing.T) {
+	immediate := generateModelHardDownResponse("llama-7b", "CrashLoopBackOff").GetImmediateResponse()
+	assert.Equal(t, envoyTypePb.StatusCode_ServiceUnavailable, immediate.GetStatus().GetCode())
+	header := immediate.GetHeaders().GetSetHeaders()[0].GetHeader()
+	assert.Equal(t, HeaderErrorModelHardDown, header.GetKey())
+	assert.Equal(t, "CrashLoopBackOff", string(header.GetRawValue()))
+	assert.Contains(t, immediate.GetBody(), "all its pods are in CrashLoopBackOff")
+}
+
+func TestGenerateNoReadyPodsResponse(t *testing.T) {
+	immediate := generateNoReadyPodsResponse("llama-7b", 2500*time.Millisecond).GetImmediateResponse()
+	assert.Equal(t, envoyTypePb.StatusCode_ServiceUnavailable, immediate.GetStatus().GetCode())
+	headers := map[string]string{}
+	for _, header := range immediate.GetHeaders().GetSetHeaders() {
+		headers[header.Header.Key] = string(header.Header.RawValue)
 	}
+	assert.Equal(t, "3", headers[HeaderRetryAfter])
+	assert.Equal(t, "true", headers[HeaderErrorNoModelBackends])
+	assert.Contains(t, immediate.GetBody(), "no ready pod available for model llama-7b")
+
+	// the client retries after the default delay if the wake-up of the model can not be estimated.
+	s := &Server{cache: &cache.Cache{}}
+	assert.Equal(t, defaultNoReadyPodsRetryAfter, s.noReadyPodsRetryAfter("llama-7b"))
 }
@@ -0,0 +1,299 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// endpointClass groups OpenAI compatible endpoints with similar latency profiles.
+type endpointClass string
+
+const (
+	endpointClassChat       endpointClass = "chat"
+	endpointClassCompletion endpointClass = "completion"
+	endpointClassEmbeddings endpointClass = "embeddings"
+)
+
+var (
+	errStreamMaxDuration = errors.New("stream exceeded max duration")
+	errStreamIdle        = errors.New("stream exceeded idle timeout between chunks")
+
+	defaultUpstreamTimeouts = map[endpointClass]upstreamTimeouts{
+		endpointClassChat:       {Connect: 5 * time.Second, FirstByte: 60 * time.Second, Idle: 30 * time.Second, MaxDuration: 10 * time.Minute},
+		endpointClassCompletion: {Connect: 5 * time.Second, FirstByte: 60 * time.Second, Idle: 30 * time.Second, MaxDuration: 10 * time.Minute},
+		endpointClassEmbeddings: {Connect: 5 * time.Second, FirstByte: 10 * time.Second, MaxDuration: 30 * time.Second},
+	}
+)
+
+// upstreamTimeouts describes the timeouts applied to a single upstream request. A zero value disables the timeout.
+type upstreamTimeouts struct {
+	// Connect bounds the time to establish the connection to the target pod.
+	Connect time.Duration
+	// FirstByte bounds the time until the upstream starts responding. Exceeding it yields a retryable 504.
+	FirstByte time.Duration
+	// Idle bounds the gap between two chunks of a streaming response.
+	Idle time.Duration
+	// MaxDuration bounds the whole request, including the streaming of the response.
+	MaxDuration time.Duration
+}
+
+// parseUpstreamTimeouts parses a spec like "connect=5s,ttfb=60s,idle=30s,max=10m" on top of the given base,
+// keys which are not present keep the value of the base.
+func parseUpstreamTimeouts(spec string, base upstreamTimeouts) (upstreamTimeouts, error) {
+	res := base
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, found := strings.Cut(item, "=")
+		if !found {
+			return base, fmt.Errorf("invalid timeout %q, expected <key>=<duration>", item)
+		}
+		if err := res.set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return base, err
+		}
+	}
+	return res, res.validate()
+}
+
+func (t *upstreamTimeouts) set(key, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q for timeout %s: %v", value, key, err)
+	}
+	switch key {
+	case "connect":
+		t.Connect = d
+	case "ttfb":
+		t.FirstByte = d
+	case "idle":
+		t.Idle = d
+	case "max":
+		t.MaxDuration = d
+	default:
+		return fmt.Errorf("unknown timeout %s, supported: connect, ttfb, idle, max", key)
+	}
+	return nil
+}
+
+func (t upstreamTimeouts) validate() error {
+	for name, d := range map[string]time.Duration{"connect": t.Connect, "ttfb": t.FirstByte, "idle": t.Idle, "max": t.MaxDuration} {
+		if d < 0 {
+			return fmt.Errorf("timeout %s must not be negative, got %v", name, d)
+		}
+	}
+	if t.MaxDuration == 0 {
+		return nil
+	}
+	if t.Connect+t.FirstByte > t.MaxDuration {
+		return fmt.Errorf("connect (%v) plus ttfb (%v) timeout exceeds max duration (%v)", t.Connect, t.FirstByte, t.MaxDuration)
+	}
+	if t.Idle > t.MaxDuration {
+		return fmt.Errorf("idle timeout (%v) exceeds max duration (%v)", t.Idle, t.MaxDuration)
+	}
+	return nil
+}
+
+// envoyHeaders translates the timeouts into envoy router headers.
+// The per try timeout covers connect and first byte so that envoy answers with a retryable 504 when it fires.
+// For streaming requests the overall timeout is enforced by the gateway to be able to emit a proper SSE error event.
+func (t upstreamTimeouts) envoyHeaders(stream bool) []*configPb.HeaderValueOption {
+	requestTimeout := t.MaxDuration
+	if stream {
+		requestTimeout = 0
+	}
+	headers := []*configPb.HeaderValueOption{{
+		Header: &configPb.HeaderValue{
+			Key:      HeaderEnvoyUpstreamTimeout,
+			RawValue: []byte(strconv.FormatInt(requestTimeout.Milliseconds(), 10)),
+		},
+	}}
+	if perTry := t.Connect + t.FirstByte; t.FirstByte > 0 {
+		headers = append(headers, &configPb.HeaderValueOption{
+			Header: &configPb.HeaderValue{
+				Key:      HeaderEnvoyUpstreamPerTryTimeout,
+				RawValue: []byte(strconv.FormatInt(perTry.Milliseconds(), 10)),
+			},
+		})
+	}
+	return headers
+}
+
+// timeoutResolver resolves the upstream timeouts of a request by its endpoint class and model.
+type timeoutResolver struct {
+	classDefaults  map[endpointClass]upstreamTimeouts
+	modelOverrides map[string]map[endpointClass]upstreamTimeouts
+}
+
+// newTimeoutResolverFromEnv builds the resolver from AIBRIX_GATEWAY_<CLASS>_TIMEOUTS and AIBRIX_GATEWAY_MODEL_TIMEOUTS.
+// Invalid values are logged and fall back to the defaults.
+func newTimeoutResolverFromEnv() *timeoutResolver {
+	r := &timeoutResolver{
+		classDefaults:  map[endpointClass]upstreamTimeouts{},
+		modelOverrides: map[string]map[endpointClass]upstreamTimeouts{},
+	}
+	for class, defaults := range defaultUpstreamTimeouts {
+		r.classDefaults[class] = defaults
+		key := fmt.Sprintf("AIBRIX_GATEWAY_%s_TIMEOUTS", strings.ToUpper(string(class)))
+		value, exists := utils.CheckEnvExists(key)
+		if !exists {
+			continue
+		}
+		timeouts, err := parseUpstreamTimeouts(value, defaults)
+		if err != nil {
+			klog.ErrorS(err, "invalid upstream timeouts, falling back to default", "env", key, "value", value)
+			continue
+		}
+		r.classDefaults[class] = timeouts
+	}
+
+	if value, exists := utils.CheckEnvExists(EnvModelTimeouts); exists {
+		overrides, err := parseModelTimeouts(value, r.classDefaults)
+		if err != nil {
+			klog.ErrorS(err, "invalid per model upstream timeouts, ignoring", "env", EnvModelTimeouts)
+		} else {
+			r.modelOverrides = overrides
+		}
+	}
+	return r
+}
+
+// parseModelTimeouts parses per model overrides, e.g. {"llama-70b": {"chat": "ttfb=120s,max=30m"}}.
+func parseModelTimeouts(value string, classDefaults map[endpointClass]upstreamTimeouts) (map[string]map[endpointClass]upstreamTimeouts, error) {
+	var raw map[string]map[string]string
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, err
+	}
+	res := make(map[string]map[endpointClass]upstreamTimeouts, len(raw))
+	for model, classes := range raw {
+		res[model] = make(map[endpointClass]upstreamTimeouts, len(classes))
+		for class, spec := range classes {
+			base, ok := classDefaults[endpointClass(class)]
+			if !ok {
+				return nil, fmt.Errorf("unknown endpoint class %s for model %s", class, model)
+			}
+			timeouts, err := parseUpstreamTimeouts(spec, base)
+			if err != nil {
+				return nil, fmt.Errorf("model %s, endpoint class %s: %v", model, class, err)
+			}
+			res[model][endpointClass(class)] = timeouts
+		}
+	}
+	return res, nil
+}
+
+// resolve returns the timeouts for the request path and model, model overrides take precedence.
+func (r *timeoutResolver) resolve(path, model string) upstreamTimeouts {
+	class := getEndpointClass(path)
+	if timeouts, ok := r.modelOverrides[model][class]; ok {
+		return timeouts
+	}
+	return r.classDefaults[class]
+}
+
+// getEndpointClass maps the request path to its endpoint class, unknown paths are treated as chat.
+func getEndpointClass(path string) endpointClass {
+	path, _, _ = strings.Cut(path, "?")
+	switch {
+	case strings.HasSuffix(path, "/embeddings"):
+		return endpointClassEmbeddings
+	case strings.HasSuffix(path, "/chat/completions"):
+		return endpointClassChat
+	case strings.HasSuffix(path, "/completions"):
+		return endpointClassCompletion
+	default:
+		return endpointClassChat
+	}
+}
+
+// getRequestPath returns the :path pseudo header of the request.
+func getRequestPath(headers []*configPb.HeaderValue) string {
+	for _, header := range headers {
+		if header.Key == ":path" {
+			return string(header.RawValue)
+		}
+	}
+	return ""
+}
+
+// streamDeadline enforces the idle and max duration timeouts of a streaming response.
+type streamDeadline struct {
+	timeouts  upstreamTimeouts
+	start     time.Time
+	lastChunk time.Time
+}
+
+func newStreamDeadline(timeouts upstreamTimeouts, start time.Time) *streamDeadline {
+	return &streamDeadline{timeouts: timeouts, start: start}
+}
+
+// observe records a chunk received at now and returns an error if the stream exceeded one of its timeouts.
+func (d *streamDeadline) observe(now time.Time) error {
+	if d.timeouts.MaxDuration > 0 && now.Sub(d.start) > d.timeouts.MaxDuration {
+		return errStreamMaxDuration
+	}
+	if d.timeouts.Idle > 0 && !d.lastChunk.IsZero() && now.Sub(d.lastChunk) > d.timeouts.Idle {
+		return errStreamIdle
+	}
+	d.lastChunk = now
+	return nil
+}
+
+// generateStreamTimeoutResponse replaces the current chunk of a stream with a terminating SSE error event.
+func generateStreamTimeoutResponse(err error) *extProcPb.ProcessingResponse {
+	body := fmt.Sprintf("data: %s\n\ndata: [DONE]\n\n", generateErrorMessage(err.Error(), 504))
+	return generateStreamBodyResponse([]byte(body))
+}
+
+// generateStreamBodyResponse replaces the current chunk of a stream with the given body.
+func generateStreamBodyResponse(body []byte) *extProcPb.ProcessingResponse {
+	return &extProcPb.ProcessingResponse{
+		Response: &extProcPb.ProcessingResponse_ResponseBody{
+			ResponseBody: &extProcPb.BodyResponse{
+				Response: &extProcPb.CommonResponse{
+					BodyMutation: &extProcPb.BodyMutation{
+						Mutation: &extProcPb.BodyMutation_Body{Body: body},
+					},
+				},
+			},
+		},
+	}
+}
+
+// generatePassthroughBodyResponse lets the current chunk of a response through unchanged.
+func generatePassthroughBodyResponse() *extProcPb.ProcessingResponse {
+	return &extProcPb.ProcessingResponse{
+		Response: &extProcPb.ProcessingResponse_ResponseBody{
+			ResponseBody: &extProcPb.BodyResponse{
+				Response: &extProcPb.CommonResponse{},
+			},
+		},
+	}
+}
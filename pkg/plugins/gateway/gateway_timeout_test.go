@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUpstreamTimeouts(t *testing.T) {
+	base := defaultUpstreamTimeouts[endpointClassChat]
+	var tests = []struct {
+		spec        string
+		expected    upstreamTimeouts
+		expectedErr bool
+		message     string
+	}{
+		{
+			spec:     "",
+			expected: base,
+			message:  "empty spec keeps the base",
+		},
+		{
+			spec:     "ttfb=2m, max=30m",
+			expected: upstreamTimeouts{Connect: base.Connect, FirstByte: 2 * time.Minute, Idle: base.Idle, MaxDuration: 30 * time.Minute},
+			message:  "partial spec overrides the given keys",
+		},
+		{
+			spec:        "ttfb",
+			expectedErr: true,
+			message:     "missing duration",
+		},
+		{
+			spec:        "ttfb=soon",
+			expectedErr: true,
+			message:     "invalid duration",
+		},
+		{
+			spec:        "read=1s",
+			expectedErr: true,
+			message:     "unknown key",
+		},
+		{
+			spec:        "connect=-1s",
+			expectedErr: true,
+			message:     "negative timeout",
+		},
+		{
+			spec:        "ttfb=20m",
+			expectedErr: true,
+			message:     "ttfb exceeds max duration",
+		},
+		{
+			spec:        "idle=11m",
+			expectedErr: true,
+			message:     "idle exceeds max duration",
+		},
+		{
+			spec:     "ttfb=20m,idle=11m,max=0",
+			expected: upstreamTimeouts{Connect: base.Connect, FirstByte: 20 * time.Minute, Idle: 11 * time.Minute},
+			message:  "no max duration",
+		},
+	}
+
+	for _, tt := range tests {
+		timeouts, err := parseUpstreamTimeouts(tt.spec, base)
+		if tt.expectedErr {
+			assert.Error(t, err, tt.message)
+			continue
+		}
+		assert.NoError(t, err, tt.message)
+		assert.Equal(t, tt.expected, timeouts, tt.message)
+	}
+}
+
+func TestTimeoutResolverFromEnv(t *testing.T) {
+	t.Setenv("AIBRIX_GATEWAY_EMBEDDINGS_TIMEOUTS", "connect=500ms,ttfb=1s,max=2s")
+	t.Setenv("AIBRIX_GATEWAY_COMPLETION_TIMEOUTS", "ttfb=invalid")
+	t.Setenv(EnvModelTimeouts, `{"llama-70b": {"chat": "ttfb=2m,max=30m"}}`)
+
+	r := newTimeoutResolverFromEnv()
+
+	embeddings := r.resolve("/v1/embeddings", "llama-70b")
+	assert.Equal(t, time.Second, embeddings.FirstByte)
+	assert.Equal(t, 2*time.Second, embeddings.MaxDuration)
+
+	assert.Equal(t, defaultUpstreamTimeouts[endpointClassCompletion], r.resolve("/v1/completions", "llama-70b"),
+		"invalid env falls back to the default")
+
+	chat := r.resolve("/v1/chat/completions?foo=bar", "llama-70b")
+	assert.Equal(t, 2*time.Minute, chat.FirstByte)
+	assert.Equal(t, 30*time.Minute, chat.MaxDuration)
+	assert.Equal(t, defaultUpstreamTimeouts[endpointClassChat].Idle, chat.Idle)
+
+	assert.Equal(t, defaultUpstreamTimeouts[endpointClassChat], r.resolve("/v1/chat/completions", "llama-7b"))
+}
+
+func TestParseModelTimeouts(t *testing.T) {
+	_, err := parseModelTimeouts(`{"llama-70b": {"audio": "ttfb=1s"}}`, defaultUpstreamTimeouts)
+	assert.Error(t, err, "unknown endpoint class")
+
+	_, err = parseModelTimeouts(`{"llama-70b": {"chat": "ttfb=1h"}}`, defaultUpstreamTimeouts)
+	assert.Error(t, err, "invalid override")
+
+	_, err = parseModelTimeouts(`not json`, defaultUpstreamTimeouts)
+	assert.Error(t, err, "invalid json")
+}
+
+func TestEnvoyTimeoutHeaders(t *testing.T) {
+	timeouts := upstreamTimeouts{Connect: time.Second, FirstByte: 4 * time.Second, MaxDuration: time.Minute}
+
+	headers := timeouts.envoyHeaders(false)
+	assert.Len(t, headers, 2)
+	assert.Equal(t, HeaderEnvoyUpstreamTimeout, headers[0].Header.Key)
+	assert.Equal(t, "60000", string(headers[0].Header.RawValue))
+	assert.Equal(t, HeaderEnvoyUpstreamPerTryTimeout, headers[1].Header.Key)
+	assert.Equal(t, "5000", string(headers[1].Header.RawValue))
+
+	headers = timeouts.envoyHeaders(true)
+	assert.Equal(t, "0", string(headers[0].Header.RawValue), "max duration of streams is enforced by the gateway")
+
+	headers = upstreamTimeouts{MaxDuration: time.Minute}.envoyHeaders(false)
+	assert.Len(t, headers, 1, "no per try timeout without ttfb")
+}
+
+func TestStreamDeadline(t *testing.T) {
+	start := time.Now()
+	timeouts := upstreamTimeouts{Idle: 2 * time.Second, MaxDuration: 10 * time.Second}
+
+	// fakeUpstream yields chunks at the given offsets from the start of the request.
+	fakeUpstream := func(offsets ...time.Duration) error {
+		deadline := newStreamDeadline(timeouts, start)
+		for _, offset := range offsets {
+			if err := deadline.observe(start.Add(offset)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	assert.NoError(t, fakeUpstream(5*time.Second, 6*time.Second, 7*time.Second, 9*time.Second),
+		"slow first byte is not an idle timeout")
+	assert.Equal(t, errStreamIdle, fakeUpstream(time.Second, 2*time.Second, 5*time.Second))
+	assert.Equal(t, errStreamMaxDuration, fakeUpstream(2*time.Second, 4*time.Second, 6*time.Second, 8*time.Second, 10*time.Second, 11*time.Second))
+
+	timeouts = upstreamTimeouts{}
+	assert.NoError(t, fakeUpstream(time.Hour, 2*time.Hour), "zero timeouts are disabled")
+}
+
+func TestGenerateStreamTimeoutResponse(t *testing.T) {
+	resp := generateStreamTimeoutResponse(errStreamMaxDuration)
+	body := string(resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody())
+
+	assert.True(t, strings.HasPrefix(body, "data: {"))
+	assert.Contains(t, body, errStreamMaxDuration.Error())
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"))
+}
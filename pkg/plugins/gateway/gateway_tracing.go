@@ -0,0 +1,235 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	tracerName                = "github.com/vllm-project/aibrix/pkg/plugins/gateway"
+	defaultTracingServiceName = "aibrix-gateway-plugins"
+
+	// span names of the request lifecycle.
+	spanRequest        = "gateway.request"
+	spanAuthRateLimit  = "gateway.auth_ratelimit"
+	spanParseBody      = "gateway.parse_body"
+	spanRouting        = "gateway.routing"
+	spanUpstream       = "gateway.upstream"
+	eventFirstToken    = "first_token"
+	attrRequestID      = "aibrix.request_id"
+	attrModel          = "aibrix.model"
+	attrUserHash       = "aibrix.user_hash"
+	attrRoutingAlgo    = "aibrix.routing_strategy"
+	attrTargetPod      = "aibrix.target_pod"
+	attrPromptTokens   = "aibrix.prompt_tokens"
+	attrURLPath        = "url.path"
+	attrHTTPStatusCode = "http.response.status_code"
+)
+
+// InitTracing installs the global OpenTelemetry tracer provider exporting the gateway spans over OTLP.
+// The exporter, resource and sampler are configured by the standard OTEL_* env vars, e.g.
+// OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_SERVICE_NAME, OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG.
+// Tracing is disabled unless an OTLP endpoint is configured. The returned function flushes and stops the exporter.
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !tracingEnabled() {
+		klog.Info("no OTLP endpoint configured, tracing is disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// attributes from OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default service name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", defaultTracingServiceName)),
+		resource.WithFromEnv())
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	klog.Info("OpenTelemetry tracing is enabled")
+	return tp.Shutdown, nil
+}
+
+func tracingEnabled() bool {
+	if value, exists := utils.CheckEnvExists("OTEL_SDK_DISABLED"); exists && strings.ToLower(value) == "true" {
+		return false
+	}
+	if _, exists := utils.CheckEnvExists("OTEL_EXPORTER_OTLP_ENDPOINT"); exists {
+		return true
+	}
+	_, exists := utils.CheckEnvExists("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	return exists
+}
+
+// headerCarrier adapts the envoy headers to a propagation.TextMapCarrier.
+type headerCarrier []*configPb.HeaderValue
+
+func (c *headerCarrier) Get(key string) string {
+	for _, header := range *c {
+		if strings.EqualFold(header.Key, key) {
+			if len(header.RawValue) > 0 {
+				return string(header.RawValue)
+			}
+			return header.Value
+		}
+	}
+	return ""
+}
+
+func (c *headerCarrier) Set(key, value string) {
+	*c = append(*c, &configPb.HeaderValue{Key: key, RawValue: []byte(value)})
+}
+
+func (c *headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c))
+	for _, header := range *c {
+		keys = append(keys, header.Key)
+	}
+	return keys
+}
+
+// requestTracing holds the spans of a request across the messages of its ext_proc stream.
+type requestTracing struct {
+	tracer trace.Tracer
+	// ctx carries the root span of the request.
+	ctx        context.Context
+	root       trace.Span
+	upstream   trace.Span
+	firstToken bool
+}
+
+// newRequestTracing starts the root span of a request, continuing the trace of the incoming traceparent header.
+func newRequestTracing(ctx context.Context, tracer trace.Tracer, requestID string, headers []*configPb.HeaderValue) *requestTracing {
+	carrier := headerCarrier(headers)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, &carrier)
+	ctx, root := tracer.Start(ctx, spanRequest,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String(attrRequestID, requestID),
+			attribute.String(attrURLPath, getRequestPath(headers)),
+		))
+	return &requestTracing{tracer: tracer, ctx: ctx, root: root}
+}
+
+// startSpan starts a child span of the request.
+func (t *requestTracing) startSpan(name string) (context.Context, trace.Span) {
+	return t.tracer.Start(t.ctx, name)
+}
+
+// setUser records the user of the request, hashed so that no user names are exported.
+func (t *requestTracing) setUser(user utils.User) {
+	if user.Name == "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(user.Name))
+	t.root.SetAttributes(attribute.String(attrUserHash, hex.EncodeToString(sum[:8])))
+}
+
+func (t *requestTracing) setRouting(model, routingStrategy, targetPodIP string) {
+	t.root.SetAttributes(
+		attribute.String(attrModel, model),
+		attribute.String(attrRoutingAlgo, routingStrategy),
+		attribute.String(attrTargetPod, targetPodIP),
+	)
+}
+
+// recordResponse marks the request as failed when the gateway answers it without forwarding it upstream.
+func (t *requestTracing) recordResponse(resp *extProcPb.ProcessingResponse) {
+	immediate := resp.GetImmediateResponse()
+	if immediate == nil {
+		return
+	}
+	code := int(immediate.GetStatus().GetCode())
+	t.root.SetAttributes(attribute.Int(attrHTTPStatusCode, code))
+	if code >= 400 {
+		t.root.SetStatus(otelcodes.Error, immediate.GetBody())
+	}
+}
+
+// startUpstream starts the span of the upstream call and returns the headers propagating it to the upstream.
+func (t *requestTracing) startUpstream(targetPodIP string) []*configPb.HeaderValueOption {
+	ctx, span := t.tracer.Start(t.ctx, spanUpstream,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String(attrTargetPod, targetPodIP)))
+	t.upstream = span
+
+	var carrier headerCarrier
+	otel.GetTextMapPropagator().Inject(ctx, &carrier)
+	headers := make([]*configPb.HeaderValueOption, 0, len(carrier))
+	for _, header := range carrier {
+		headers = append(headers, &configPb.HeaderValueOption{Header: header})
+	}
+	return headers
+}
+
+// observeResponseHeaders records the status code of the upstream response.
+func (t *requestTracing) observeResponseHeaders(isRespError bool, respErrorCode int) {
+	if t == nil || t.upstream == nil {
+		return
+	}
+	if isRespError {
+		t.upstream.SetAttributes(attribute.Int(attrHTTPStatusCode, respErrorCode))
+		t.upstream.SetStatus(otelcodes.Error, "upstream error")
+		t.root.SetStatus(otelcodes.Error, "upstream error")
+		return
+	}
+	t.upstream.SetAttributes(attribute.Int(attrHTTPStatusCode, 200))
+}
+
+// observeResponseBody annotates the upstream span with the time to first token on the first response chunk.
+func (t *requestTracing) observeResponseBody() {
+	if t == nil || t.upstream == nil || t.firstToken {
+		return
+	}
+	t.firstToken = true
+	t.upstream.AddEvent(eventFirstToken)
+}
+
+// end ends the spans of the request, it is safe to call more than once.
+func (t *requestTracing) end() {
+	if t == nil {
+		return
+	}
+	if t.upstream != nil {
+		t.upstream.End()
+	}
+	t.root.End()
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	testTraceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentSpanID = "00f067aa0ba902b7"
+)
+
+func newTestTracing(t *testing.T) (*tracetest.SpanRecorder, trace.Tracer) {
+	t.Helper()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return recorder, tp.Tracer(tracerName)
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestRequestTracingSpans(t *testing.T) {
+	recorder, tracer := newTestTracing(t)
+	headers := []*configPb.HeaderValue{
+		{Key: ":path", RawValue: []byte("/v1/chat/completions")},
+		{Key: "Traceparent", RawValue: []byte("00-" + testTraceID + "-" + testParentSpanID + "-01")},
+	}
+
+	// replay the lifecycle of a request as driven by Process.
+	tracing := newRequestTracing(context.Background(), tracer, "req-1", headers)
+	_, authSpan := tracing.startSpan(spanAuthRateLimit)
+	authSpan.End()
+	tracing.setUser(utils.User{Name: "alice"})
+	_, parseSpan := tracer.Start(tracing.ctx, spanParseBody)
+	parseSpan.End()
+	_, routingSpan := tracer.Start(tracing.ctx, spanRouting)
+	routingSpan.End()
+	tracing.setRouting("llama-7b", "least-request", "10.0.0.1")
+	upstreamHeaders := tracing.startUpstream("10.0.0.1")
+	tracing.observeResponseHeaders(false, 0)
+	tracing.observeResponseBody()
+	tracing.observeResponseBody()
+	tracing.end()
+	tracing.end()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	assert.Len(t, recorder.Ended(), 5, "every span is ended exactly once")
+
+	root := spans[spanRequest]
+	if !assert.NotNil(t, root) {
+		return
+	}
+	assert.Equal(t, testTraceID, root.SpanContext().TraceID().String(), "the incoming trace is continued")
+	assert.Equal(t, testParentSpanID, root.Parent().SpanID().String())
+	assert.True(t, root.Parent().IsRemote())
+	assert.Equal(t, trace.SpanKindServer, root.SpanKind())
+
+	attrs := spanAttributes(root)
+	assert.Equal(t, "req-1", attrs[attrRequestID].AsString())
+	assert.Equal(t, "/v1/chat/completions", attrs[attrURLPath].AsString())
+	assert.Equal(t, "llama-7b", attrs[attrModel].AsString())
+	assert.Equal(t, "least-request", attrs[attrRoutingAlgo].AsString())
+	assert.Equal(t, "10.0.0.1", attrs[attrTargetPod].AsString())
+	assert.NotEmpty(t, attrs[attrUserHash].AsString())
+	assert.NotContains(t, attrs[attrUserHash].AsString(), "alice", "the user is hashed")
+
+	for _, name := range []string{spanAuthRateLimit, spanParseBody, spanRouting, spanUpstream} {
+		span := spans[name]
+		if !assert.NotNil(t, span, name) {
+			continue
+		}
+		assert.Equal(t, root.SpanContext().SpanID(), span.Parent().SpanID(), "%s is a child of the request span", name)
+	}
+
+	upstream := spans[spanUpstream]
+	assert.Equal(t, trace.SpanKindClient, upstream.SpanKind())
+	assert.Equal(t, int64(200), spanAttributes(upstream)[attrHTTPStatusCode].AsInt64())
+	if assert.Len(t, upstream.Events(), 1, "the first token is annotated once") {
+		assert.Equal(t, eventFirstToken, upstream.Events()[0].Name)
+	}
+
+	// the upstream span is propagated to the upstream.
+	if assert.Len(t, upstreamHeaders, 1) {
+		assert.Equal(t, "traceparent", upstreamHeaders[0].Header.Key)
+		assert.Equal(t, "00-"+testTraceID+"-"+upstream.SpanContext().SpanID().String()+"-01",
+			string(upstreamHeaders[0].Header.RawValue))
+	}
+}
+
+func TestRequestTracingRejectedRequest(t *testing.T) {
+	recorder, tracer := newTestTracing(t)
+
+	tracing := newRequestTracing(context.Background(), tracer, "req-1", nil)
+	tracing.recordResponse(generateErrorResponse(envoyTypePb.StatusCode_TooManyRequests, nil, "rpm exceeded"))
+	tracing.observeResponseHeaders(true, 500)
+	tracing.observeResponseBody()
+	tracing.end()
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 1) {
+		return
+	}
+	root := spans[0]
+	assert.False(t, root.Parent().IsValid(), "a request without traceparent starts a new trace")
+	assert.Equal(t, otelcodes.Error, root.Status().Code)
+	assert.Equal(t, int64(429), spanAttributes(root)[attrHTTPStatusCode].AsInt64())
+	assert.Empty(t, root.Events())
+}
+
+func TestHeaderCarrier(t *testing.T) {
+	carrier := headerCarrier{
+		{Key: "TraceParent", RawValue: []byte("raw")},
+		{Key: "tracestate", Value: "value"},
+	}
+	assert.Equal(t, "raw", carrier.Get("traceparent"))
+	assert.Equal(t, "value", carrier.Get("tracestate"))
+	assert.Equal(t, "", carrier.Get("baggage"))
+
+	carrier.Set("baggage", "k=v")
+	assert.Equal(t, []string{"TraceParent", "tracestate", "baggage"}, carrier.Keys())
+}
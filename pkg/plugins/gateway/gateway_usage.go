@@ -0,0 +1,195 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	defaultUsageFlushInterval = time.Second
+	defaultUsageMaxPending    = 10000
+)
+
+var usageRecordsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_gateway_usage_records_dropped_total",
+	Help: "Number of token usage records of the requests the gateway dropped before writing them to Redis, by reason.",
+}, []string{"reason"})
+
+// The reasons the usage of a stream is estimated by the gateway.
+const (
+	usageEstimatedNoUsage     = "no_usage"
+	usageEstimatedInterrupted = "interrupted"
+)
+
+var usageEstimated = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "aibrix_gateway_usage_estimated_total",
+	Help: "Number of streamed responses whose usage the gateway estimated from their content, by reason: no_usage when the stream did not report it, interrupted when the stream ended before its usage.",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(usageRecordsDropped, usageEstimated)
+}
+
+// usageKey is the user, the model and the hour the usage of a request is metered in.
+type usageKey struct {
+	user  string
+	model string
+	hour  time.Time
+}
+
+// usageMeter meters the token usage of the completed requests per user, model and hour for chargeback. The request
+// path adds the usage to the pending usage in memory, and a background flusher writes the pending usage to the
+// hourly and daily Redis hashes of the users every flush interval in a single pipeline, so that the requests of a
+// user to a model in an hour are written at once. The usage of a new user, model and hour is dropped while
+// maxPending of them are pending, and the usage Redis failed to write is dropped, metering never fails a request.
+type usageMeter struct {
+	sink func(ctx context.Context, records []utils.UsageRecord) error
+
+	mu         sync.Mutex
+	pending    map[usageKey]*utils.UsageRecord
+	maxPending int
+
+	flushInterval time.Duration
+	stopCh        chan struct{}
+	doneCh        chan struct{}
+	stopOnce      sync.Once
+}
+
+func newUsageMeter(sink func(ctx context.Context, records []utils.UsageRecord) error, maxPending int, flushInterval time.Duration) *usageMeter {
+	return &usageMeter{
+		sink:          sink,
+		pending:       map[usageKey]*utils.UsageRecord{},
+		maxPending:    maxPending,
+		flushInterval: flushInterval,
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+}
+
+func newUsageMeterFromEnv(redisClient redis.UniversalClient) *usageMeter {
+	sink := func(ctx context.Context, records []utils.UsageRecord) error {
+		return utils.IncrUsage(ctx, records, redisClient)
+	}
+	return newUsageMeter(sink,
+		loadPositiveIntEnv("AIBRIX_USAGE_MAX_PENDING", defaultUsageMaxPending),
+		loadDurationMsEnv("AIBRIX_USAGE_FLUSH_INTERVAL_MS", defaultUsageFlushInterval))
+}
+
+// usageUser returns the user the usage of the request is metered to, the user of the rate limits or else the user
+// named by the request.
+func (a *requestAccount) usageUser() string {
+	if a.user.Name != "" {
+		return a.user.Name
+	}
+	return a.username
+}
+
+// meteredModel returns the model the usage of the request is metered to, the model of the response or else the
+// model the request was routed to.
+func (a *requestAccount) meteredModel(model string) string {
+	if a.responseModel != "" {
+		return a.responseModel
+	}
+	return model
+}
+
+// record adds the usage of a completed request of the user to the model, it never blocks on Redis. The usage of
+// the requests without a user is not metered. It is a no-op on a nil meter.
+func (m *usageMeter) record(user, model string, promptTokens, completionTokens int64, at time.Time) {
+	if m == nil || user == "" || model == "" {
+		return
+	}
+	key := usageKey{user: user, model: model, hour: at.UTC().Truncate(time.Hour)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.pending[key]
+	if !ok {
+		if len(m.pending) >= m.maxPending {
+			usageRecordsDropped.WithLabelValues("buffer_full").Inc()
+			return
+		}
+		record = &utils.UsageRecord{User: user, Model: model, At: key.hour}
+		m.pending[key] = record
+	}
+	record.PromptTokens += promptTokens
+	record.CompletionTokens += completionTokens
+	record.Requests++
+}
+
+// flush writes the pending usage. The usage Redis failed to write is dropped, retrying it could count the usage
+// twice, since the pipeline is not atomic.
+func (m *usageMeter) flush(ctx context.Context) error {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = map[usageKey]*utils.UsageRecord{}
+	m.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	records := make([]utils.UsageRecord, 0, len(pending))
+	for _, record := range pending {
+		records = append(records, *record)
+	}
+	if err := m.sink(ctx, records); err != nil {
+		usageRecordsDropped.WithLabelValues("flush_failed").Add(float64(len(records)))
+		return err
+	}
+	return nil
+}
+
+// start runs the flusher until shutdown.
+func (m *usageMeter) start() {
+	go func() {
+		defer close(m.doneCh)
+		ticker := time.NewTicker(m.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-m.stopCh:
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), accountingFlushTimeout)
+			if err := m.flush(ctx); err != nil {
+				klog.ErrorS(err, "failed to flush the usage records")
+			}
+			cancel()
+		}
+	}()
+}
+
+// shutdown stops the flusher and flushes the pending usage, within the deadline of ctx.
+func (m *usageMeter) shutdown(ctx context.Context) error {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+	select {
+	case <-m.doneCh:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return m.flush(ctx)
+}
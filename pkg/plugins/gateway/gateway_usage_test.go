@@ -0,0 +1,228 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vllm-project/aibrix/pkg/plugins/gateway/tokenizer"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// fakeUsageSink keeps the flushed usage records in memory.
+type fakeUsageSink struct {
+	mu      sync.Mutex
+	records []utils.UsageRecord
+	err     error
+}
+
+func (f *fakeUsageSink) incr(ctx context.Context, records []utils.UsageRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.records = append(f.records, records...)
+	return nil
+}
+
+func (f *fakeUsageSink) sorted() []utils.UsageRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	records := append([]utils.UsageRecord(nil), f.records...)
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].User != records[j].User {
+			return records[i].User < records[j].User
+		}
+		return records[i].At.Before(records[j].At)
+	})
+	return records
+}
+
+func TestUsageMeterAggregatesPerHour(t *testing.T) {
+	sink := &fakeUsageSink{}
+	m := newUsageMeter(sink.incr, 100, time.Hour)
+	hour := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	m.record("alice", "llama-7b", 10, 5, hour.Add(time.Minute))
+	m.record("alice", "llama-7b", 20, 15, hour.Add(59*time.Minute))
+	m.record("alice", "llama-7b", 1, 1, hour.Add(time.Hour))
+	m.record("bob", "llama-7b", 3, 2, hour)
+	// the requests without a user are not metered.
+	m.record("", "llama-7b", 3, 2, hour)
+
+	assert.NoError(t, m.flush(context.Background()))
+	assert.Equal(t, []utils.UsageRecord{
+		{User: "alice", Model: "llama-7b", PromptTokens: 30, CompletionTokens: 20, Requests: 2, At: hour},
+		{User: "alice", Model: "llama-7b", PromptTokens: 1, CompletionTokens: 1, Requests: 1, At: hour.Add(time.Hour)},
+		{User: "bob", Model: "llama-7b", PromptTokens: 3, CompletionTokens: 2, Requests: 1, At: hour},
+	}, sink.sorted())
+	assert.Empty(t, m.pending)
+
+	var none *usageMeter
+	none.record("alice", "llama-7b", 1, 1, hour)
+}
+
+func TestUsageMeterDropsUsage(t *testing.T) {
+	sink := &fakeUsageSink{}
+	m := newUsageMeter(sink.incr, 1, time.Hour)
+	now := time.Now()
+
+	bufferFull := testutil.ToFloat64(usageRecordsDropped.WithLabelValues("buffer_full"))
+	m.record("alice", "llama-7b", 10, 5, now)
+	m.record("bob", "llama-7b", 10, 5, now)
+	// the usage of a pending user, model and hour is still counted.
+	m.record("alice", "llama-7b", 10, 5, now)
+	assert.Equal(t, bufferFull+1, testutil.ToFloat64(usageRecordsDropped.WithLabelValues("buffer_full")))
+
+	flushFailed := testutil.ToFloat64(usageRecordsDropped.WithLabelValues("flush_failed"))
+	sink.err = errors.New("connection refused")
+	assert.Error(t, m.flush(context.Background()))
+	assert.Equal(t, flushFailed+1, testutil.ToFloat64(usageRecordsDropped.WithLabelValues("flush_failed")))
+	assert.Empty(t, m.pending)
+}
+
+func TestUsageMeterShutdownFlushes(t *testing.T) {
+	sink := &fakeUsageSink{}
+	m := newUsageMeter(sink.incr, 100, time.Hour)
+	m.start()
+	m.record("alice", "llama-7b", 10, 5, time.Now())
+
+	assert.NoError(t, m.shutdown(context.Background()))
+	records := sink.sorted()
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(1), records[0].Requests)
+}
+
+func TestStreamUsageEstimate(t *testing.T) {
+	m := newUsageMeter(nil, 100, time.Hour)
+	s := &Server{usage: m, tokenizers: tokenizer.NewRegistry(nil, 0)}
+	account := &requestAccount{username: "alice", promptTokens: 7}
+	newChunk := func(body string, endOfStream bool) *extProcPb.ProcessingRequest {
+		return &extProcPb.ProcessingRequest{Request: &extProcPb.ProcessingRequest_ResponseBody{
+			ResponseBody: &extProcPb.HttpBody{Body: []byte(body), EndOfStream: endOfStream},
+		}}
+	}
+
+	// the stream does not report its usage, the prompt estimate and the streamed tokens are metered once it ends.
+	_, complete := s.HandleResponseBody(context.Background(), "r1",
+		newChunk("data: {\"id\": \"1\", \"model\": \"llama-7b-lora\", \"choices\": [{\"index\": 0, \"delta\": {\"content\": \"Hello, wo\"}}]}\n\n", false),
+		account, "llama-7b", "", true, 0, false)
+	assert.False(t, complete)
+	assert.Empty(t, m.pending)
+
+	_, complete = s.HandleResponseBody(context.Background(), "r1",
+		newChunk("data: {\"id\": \"1\", \"model\": \"llama-7b-lora\", \"choices\": [{\"index\": 0, \"delta\": {\"content\": \"rld\"}}]}\n\ndata: [DONE]\n\n", true),
+		account, "llama-7b", "", true, 0, false)
+	assert.False(t, complete)
+
+	assert.Len(t, m.pending, 1)
+	for key, record := range m.pending {
+		assert.Equal(t, utils.UsageRecord{User: "alice", Model: "llama-7b-lora", PromptTokens: 7, CompletionTokens: 4, Requests: 1, At: key.hour}, *record)
+	}
+}
+
+func TestStreamUsageAcrossChunks(t *testing.T) {
+	m := newUsageMeter(nil, 100, time.Hour)
+	s := &Server{usage: m, tokenizers: tokenizer.NewRegistry(nil, 0)}
+	account := &requestAccount{username: "alice", promptTokens: 7}
+	newChunk := func(body string, endOfStream bool) *extProcPb.ProcessingRequest {
+		return &extProcPb.ProcessingRequest{Request: &extProcPb.ProcessingRequest_ResponseBody{
+			ResponseBody: &extProcPb.HttpBody{Body: []byte(body), EndOfStream: endOfStream},
+		}}
+	}
+
+	// the usage event is split across the chunks, it is scanned once complete.
+	chunks := []string{
+		"data: {\"id\": \"1\", \"model\": \"llama-7b\", \"choices\": [{\"index\": 0, \"delta\": {\"content\": \"Hi\"}}]}\n\ndata: {\"id\": \"1\", \"model\": \"llama-7b\", \"choices\": [], ",
+		"\"usage\": {\"prompt_tokens\": 5, \"completion_tokens\": 3, \"total_tokens\": 8}}\r\n\r\n",
+		"data: [DONE]\n\n",
+	}
+	var complete bool
+	for i, chunk := range chunks {
+		_, complete = s.HandleResponseBody(context.Background(), "r1", newChunk(chunk, i == len(chunks)-1),
+			account, "llama-7b", "", true, 0, complete)
+		if i == 0 {
+			assert.False(t, complete)
+			assert.NotEmpty(t, account.streamTail)
+		}
+	}
+	assert.True(t, complete)
+	assert.Empty(t, account.streamTail)
+
+	// the usage reported by the stream is metered once, not the estimate.
+	s.accountStreamEstimate("r1", account, "llama-7b", usageEstimatedInterrupted)
+	assert.Len(t, m.pending, 1)
+	for key, record := range m.pending {
+		assert.Equal(t, utils.UsageRecord{User: "alice", Model: "llama-7b", PromptTokens: 5, CompletionTokens: 3, Requests: 1, At: key.hour}, *record)
+	}
+}
+
+func TestStreamUsageEstimateOnInterruption(t *testing.T) {
+	m := newUsageMeter(nil, 100, time.Hour)
+	s := &Server{usage: m, tokenizers: tokenizer.NewRegistry(nil, 0)}
+	account := &requestAccount{username: "alice", promptTokens: 7}
+	before := testutil.ToFloat64(usageEstimated.WithLabelValues(usageEstimatedInterrupted))
+
+	_, complete := s.HandleResponseBody(context.Background(), "r1", &extProcPb.ProcessingRequest{
+		Request: &extProcPb.ProcessingRequest_ResponseBody{ResponseBody: &extProcPb.HttpBody{
+			Body: []byte("data: {\"id\": \"1\", \"model\": \"llama-7b\", \"choices\": [{\"index\": 0, \"delta\": {\"content\": \"Hello, wo\"}}]}\n\n"),
+		}},
+	}, account, "llama-7b", "", true, 0, false)
+	assert.False(t, complete)
+	assert.Empty(t, m.pending)
+
+	// the client disconnects before the end of the stream, the estimate is metered once.
+	s.accountStreamEstimate("r1", account, "llama-7b", usageEstimatedInterrupted)
+	s.accountStreamEstimate("r1", account, "llama-7b", usageEstimatedInterrupted)
+	assert.Len(t, m.pending, 1)
+	for key, record := range m.pending {
+		assert.Equal(t, utils.UsageRecord{User: "alice", Model: "llama-7b", PromptTokens: 7, CompletionTokens: 3, Requests: 1, At: key.hour}, *record)
+	}
+	assert.Equal(t, before+1, testutil.ToFloat64(usageEstimated.WithLabelValues(usageEstimatedInterrupted)))
+
+	// a request which was not streamed has no estimate.
+	s.accountStreamEstimate("r2", &requestAccount{username: "bob"}, "llama-7b", usageEstimatedInterrupted)
+	assert.Len(t, m.pending, 1)
+}
+
+func TestSplitStreamEvents(t *testing.T) {
+	events, tail := splitStreamEvents(nil, []byte("data: 1\n\ndata: 2"), false)
+	assert.Equal(t, "data: 1\n\n", string(events))
+	assert.Equal(t, "data: 2", string(tail))
+
+	events, tail = splitStreamEvents(tail, []byte("2\r\n\r\ndata: 3"), false)
+	assert.Equal(t, "data: 22\r\n\r\n", string(events))
+	assert.Equal(t, "data: 3", string(tail))
+
+	events, tail = splitStreamEvents(tail, []byte("3"), false)
+	assert.Empty(t, events)
+	assert.Equal(t, "data: 33", string(tail))
+
+	events, tail = splitStreamEvents(tail, nil, true)
+	assert.Equal(t, "data: 33", string(events))
+	assert.Empty(t, tail)
+}
@@ -18,6 +18,7 @@ package prefixcacheindexer
 
 import (
 	"bytes"
+	"container/list"
 	"encoding/binary"
 	"math/rand"
 	"strconv"
@@ -34,6 +35,7 @@ const (
 	defaultPrefixCacheBlockSize              = 16
 	defaultPrefixCacheEvictionInternalInMS   = 50
 	defaultPrefixCacheEvictionDurationInMins = 60
+	defaultPrefixCacheMaxBlocks              = 100000
 )
 
 var (
@@ -41,6 +43,7 @@ var (
 	prefixCacheBlockSize        = getPrefixCacheBlockSize()
 	prefixCacheEvictionInterval = getPrefixCacheEvictionInterval()
 	prefixCacheEvictionDuration = getPrefixCacheEvictionDuration()
+	prefixCacheMaxBlocks        = getPrefixCacheMaxBlocks()
 )
 
 func getPrefixCacheBlockSize() int {
@@ -88,11 +91,30 @@ func getPrefixCacheEvictionDuration() time.Duration {
 	return defaultPrefixCacheEvictionDurationInMins * time.Minute
 }
 
+func getPrefixCacheMaxBlocks() int {
+	value := utils.LoadEnv("AIBRIX_PREFIX_CACHE_MAX_BLOCKS", "")
+	if value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_PREFIX_CACHE_MAX_BLOCKS: %s, falling back to default", value)
+		} else {
+			klog.Infof("using AIBRIX_PREFIX_CACHE_MAX_BLOCKS env value for prefix cache max blocks: %d", intValue)
+			return intValue
+		}
+	}
+	klog.Infof("using default prefix cache max blocks: %d", defaultPrefixCacheMaxBlocks)
+	return defaultPrefixCacheMaxBlocks
+}
+
 type PrefixHashTable struct {
-	mu     sync.RWMutex
+	mu     sync.Mutex
 	blocks map[uint64]Block
 	hash   *xxhash.Digest
 	seed   uint64
+	// lru orders the hashes of the blocks from the most to the least recently used one, the least recently used
+	// blocks are evicted once the table holds more than prefixCacheMaxBlocks.
+	lru      *list.List
+	elements map[uint64]*list.Element
 }
 
 type Block struct {
@@ -104,9 +126,11 @@ func NewPrefixHashTable() PrefixCacheIndexer {
 	r := rand.New(rand.NewSource(time.Now().Unix()))
 	seed := r.Uint64()
 	instance := &PrefixHashTable{
-		blocks: map[uint64]Block{},
-		hash:   xxhash.NewWithSeed(seed),
-		seed:   seed,
+		blocks:   map[uint64]Block{},
+		hash:     xxhash.NewWithSeed(seed),
+		seed:     seed,
+		lru:      list.New(),
+		elements: map[uint64]*list.Element{},
 	}
 
 	ticker := time.NewTicker(prefixCacheEvictionInterval)
@@ -122,8 +146,9 @@ func NewPrefixHashTable() PrefixCacheIndexer {
 // returns matchedTokens, unMatchedTokens, matchedPods
 // TODO: add an interface with multiple implementations such as hash or radix tree
 func (c *PrefixHashTable) MatchPrefix(tokens []int, model string, pods []*v1.Pod) ([]int, []int, []*v1.Pod) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	// matching updates the access time of the blocks and writes to the shared digest.
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	var block, lastMatchedBlock Block
 	var ok bool
 	var lastTokenMatchIndex int
@@ -148,6 +173,7 @@ func (c *PrefixHashTable) MatchPrefix(tokens []int, model string, pods []*v1.Pod
 		lastMatchedBlock = block
 		block.lastAccessTime = time.Now()
 		c.blocks[prefixHash] = block
+		c.touch(prefixHash)
 	}
 
 	matchedTokens := tokens[0:lastTokenMatchIndex]
@@ -195,6 +221,27 @@ func (c *PrefixHashTable) AddPrefix(unMatchedTokens []int, model, pod string) {
 
 		block.lastAccessTime = time.Now()
 		c.blocks[prefixHash] = block
+		c.touch(prefixHash)
+	}
+}
+
+// touch marks the block as the most recently used one, and evicts the least recently used blocks beyond
+// prefixCacheMaxBlocks.
+func (c *PrefixHashTable) touch(prefixHash uint64) {
+	if c.lru == nil {
+		c.lru = list.New()
+		c.elements = map[uint64]*list.Element{}
+	}
+	if element, ok := c.elements[prefixHash]; ok {
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.elements[prefixHash] = c.lru.PushFront(prefixHash)
+	for c.lru.Len() > prefixCacheMaxBlocks {
+		oldest := c.lru.Remove(c.lru.Back()).(uint64)
+		delete(c.elements, oldest)
+		delete(c.blocks, oldest)
+		klog.V(4).InfoS("prefix cache block evicted", "hash", oldest, "maxBlocks", prefixCacheMaxBlocks)
 	}
 }
 
@@ -205,6 +252,10 @@ func (c *PrefixHashTable) Evict(now time.Time) {
 	for hash, block := range c.blocks {
 		if now.Sub(block.lastAccessTime) > prefixCacheEvictionDuration {
 			delete(c.blocks, hash)
+			if element, ok := c.elements[hash]; ok {
+				c.lru.Remove(element)
+				delete(c.elements, hash)
+			}
 			klog.InfoS("prefix cache block evicted", "hash", hash)
 		}
 	}
@@ -142,3 +142,38 @@ func Test_MatchPrefix(t *testing.T) {
 		assert.Equal(t, tt.matchPods, matchPods)
 	}
 }
+
+func Test_PrefixHashTableMaxBlocks(t *testing.T) {
+	defer func(maxBlocks int) { prefixCacheMaxBlocks = maxBlocks }(prefixCacheMaxBlocks)
+	prefixCacheMaxBlocks = 2
+
+	cache := NewPrefixHashTable().(*PrefixHashTable)
+	pods := []*v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "p1"}}}
+	// prompts of a single block each.
+	prompt := func(token int) []int {
+		tokens := make([]int, prefixCacheBlockSize)
+		for i := range tokens {
+			tokens[i] = token
+		}
+		return tokens
+	}
+
+	cache.AddPrefix(prompt(1), "m1", "p1")
+	cache.AddPrefix(prompt(2), "m1", "p1")
+	// matching the first prompt makes the second one the least recently used.
+	matchedTokens, _, _ := cache.MatchPrefix(prompt(1), "m1", pods)
+	assert.Len(t, matchedTokens, prefixCacheBlockSize)
+	cache.AddPrefix(prompt(3), "m1", "p1")
+
+	assert.Len(t, cache.blocks, 2)
+	assert.Equal(t, 2, cache.lru.Len())
+	for token, matched := range map[int]int{1: prefixCacheBlockSize, 2: 0, 3: prefixCacheBlockSize} {
+		matchedTokens, _, _ := cache.MatchPrefix(prompt(token), "m1", pods)
+		assert.Len(t, matchedTokens, matched, "prompt %d", token)
+	}
+
+	cache.Evict(time.Now().Add(prefixCacheEvictionDuration + time.Minute))
+	assert.Empty(t, cache.blocks)
+	assert.Equal(t, 0, cache.lru.Len())
+	assert.Empty(t, cache.elements)
+}
@@ -31,6 +31,6 @@ type PrefixCacheIndexer interface {
 	AddPrefix(tokens []int, model, pod string)
 
 	// Evict is invoked at fixed internal to clean up expired tokens from prefix cache.
-	// TODO: Add max blocks to cache, add LRU policy along with TTL and add performance benchmark tests.
+	// TODO: add performance benchmark tests.
 	Evict(now time.Time)
 }
@@ -18,6 +18,7 @@ package ratelimiter
 
 import (
 	"context"
+	"time"
 )
 
 // RateLimiter defines an interface for rate limiting operations.
@@ -35,3 +36,39 @@ type RateLimiter interface {
 	// Returns the updated rate limit counter after the increment and an error if the operation fails.
 	Incr(ctx context.Context, key string, val int64) (int64, error)
 }
+
+// Increment is an increment of the rate limit counter of a key, counted in the window of At.
+type Increment struct {
+	Key string
+	Val int64
+	At  time.Time
+}
+
+// BatchRateLimiter is a RateLimiter which can apply many increments in one round trip, for the counters which
+// are not read back by the request incrementing them.
+type BatchRateLimiter interface {
+	RateLimiter
+
+	// IncrBatch applies the increments, each in the window of its time.
+	IncrBatch(ctx context.Context, increments []Increment) error
+}
+
+// Reservation is the outcome of reserving a value against the limit of a key.
+type Reservation struct {
+	// Allowed tells whether the value was counted, the usage staying within the limit.
+	Allowed bool
+	// Used is the usage of the sliding window, including the value if it was allowed.
+	Used int64
+	// Reset is how long until the current window ends, and the usage of the previous windows starts fading out.
+	Reset time.Duration
+}
+
+// SlidingWindowRateLimiter is a BatchRateLimiter which can atomically check and count a value against a limit,
+// for the counters which admit the request incrementing them.
+type SlidingWindowRateLimiter interface {
+	BatchRateLimiter
+
+	// Reserve counts val against the key if the usage of the sliding window including it stays within limit. The
+	// check and the increment are atomic, so that the concurrent requests can not overshoot the limit.
+	Reserve(ctx context.Context, key string, val, limit int64) (Reservation, error)
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -27,14 +28,32 @@ import (
 
 const binSize = 64
 
+// reserveScript counts ARGV[1] in the current window KEYS[1] if the usage of the sliding window stays within the
+// limit ARGV[2]. The usage is the count of the current window plus the count of the previous window KEYS[2]
+// weighted by ARGV[3], the fraction of the previous window the sliding window still covers. The counters expire
+// after ARGV[4] milliseconds, once they no longer count in the sliding window.
+var reserveScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+local used = math.floor(previous * tonumber(ARGV[3]) + current)
+local val = tonumber(ARGV[1])
+if used + val > tonumber(ARGV[2]) then
+	return {0, used}
+end
+redis.call('INCRBY', KEYS[1], val)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {1, used + val}
+`)
+
 type redisRateLimiter struct {
-	client     *redis.Client
+	client     redis.UniversalClient
 	name       string
 	windowSize time.Duration
 }
 
-// NewRedisAccountRateLimiter is a simple fixed window rate limiter
-func NewRedisAccountRateLimiter(name string, client *redis.Client, windowSize time.Duration) RateLimiter {
+// NewRedisAccountRateLimiter is a sliding window rate limiter, approximating the usage of the sliding window from
+// the counters of the current and the previous fixed windows.
+func NewRedisAccountRateLimiter(name string, client redis.UniversalClient, windowSize time.Duration) SlidingWindowRateLimiter {
 	if windowSize < time.Second {
 		windowSize = time.Second
 	}
@@ -69,15 +88,67 @@ func (rrl redisRateLimiter) Incr(ctx context.Context, key string, val int64) (in
 	return rrl.incrAndExpire(ctx, rrl.genKey(key), val)
 }
 
+// IncrBatch applies the increments in a single pipeline.
+func (rrl redisRateLimiter) IncrBatch(ctx context.Context, increments []Increment) error {
+	if len(increments) == 0 {
+		return nil
+	}
+	pipe := rrl.client.Pipeline()
+	for _, incr := range increments {
+		key := rrl.genKeyAt(incr.Key, incr.At)
+		pipe.IncrBy(ctx, key, incr.Val)
+		pipe.Expire(ctx, key, rrl.ttl())
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Reserve counts val in the current window if the usage of the sliding window stays within limit.
+func (rrl redisRateLimiter) Reserve(ctx context.Context, key string, val, limit int64) (Reservation, error) {
+	now := time.Now()
+	windowMs := rrl.windowSeconds() * 1000
+	elapsedMs := now.UnixMilli() - now.Unix()/rrl.windowSeconds()*windowMs
+	weight := 1 - float64(elapsedMs)/float64(windowMs)
+	keys := []string{rrl.genKeyAt(key, now), rrl.genKeyAt(key, now.Add(-rrl.windowSize))}
+	res, err := reserveScript.Run(ctx, rrl.client, keys, val, limit,
+		strconv.FormatFloat(weight, 'f', 6, 64), rrl.ttl().Milliseconds()).Int64Slice()
+	if err != nil {
+		return Reservation{}, err
+	}
+	if len(res) != 2 {
+		return Reservation{}, fmt.Errorf("unexpected reserve result %v", res)
+	}
+	return Reservation{
+		Allowed: res[0] == 1,
+		Used:    res[1],
+		Reset:   time.Duration(windowMs-elapsedMs) * time.Millisecond,
+	}, nil
+}
+
 func (rrl redisRateLimiter) genKey(key string) string {
-	return fmt.Sprintf("%s:%s:%d", rrl.name, key, time.Now().Unix()/int64(rrl.windowSize.Seconds())%binSize)
+	return rrl.genKeyAt(key, time.Now())
+}
+
+// genKeyAt returns the counter of the key in the window of at. The key is a hash tag, so that the counters of the
+// windows of a key are in the same slot of a Redis cluster and can be read by one script.
+func (rrl redisRateLimiter) genKeyAt(key string, at time.Time) string {
+	return fmt.Sprintf("%s:{%s}:%d", rrl.name, key, at.Unix()/rrl.windowSeconds()%binSize)
+}
+
+func (rrl redisRateLimiter) windowSeconds() int64 {
+	return int64(rrl.windowSize.Seconds())
+}
+
+// ttl keeps a counter while it counts in the sliding window, until the end of the window after its own.
+func (rrl redisRateLimiter) ttl() time.Duration {
+	return 2 * rrl.windowSize
 }
 
 func (rrl redisRateLimiter) incrAndExpire(ctx context.Context, key string, val int64) (int64, error) {
 	pipe := rrl.client.Pipeline()
 
 	incr := pipe.IncrBy(ctx, key, val)
-	pipe.Expire(ctx, key, rrl.windowSize)
+	pipe.Expire(ctx, key, rrl.ttl())
 
 	_, err := pipe.Exec(ctx)
 	if err != nil {
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tokenizer
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+// defaultMaxInputBytes bounds the bytes of a prompt encoded by a vocab, about 16k tokens.
+const defaultMaxInputBytes = 64 * 1024
+
+// Vocab is the vocab of a model family.
+type Vocab struct {
+	// Path is the tiktoken vocab file, e.g. mounted from a ConfigMap.
+	Path string `json:"path"`
+	// Pattern is the pre-tokenizer of the vocab, cl100k, o200k or a regexp. Defaults to cl100k.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Registry selects the tokenizer of a model: the vocab of its family, the longest configured prefix of its name,
+// or the heuristic if the model has no vocab or its vocab fails to load. The vocabs are loaded once, on the first
+// request of their models.
+type Registry struct {
+	families      map[string]Vocab
+	maxInputBytes int
+
+	mu         sync.Mutex
+	tokenizers map[Vocab]Tokenizer
+}
+
+func NewRegistry(families map[string]Vocab, maxInputBytes int) *Registry {
+	return &Registry{
+		families:      families,
+		maxInputBytes: maxInputBytes,
+		tokenizers:    map[Vocab]Tokenizer{},
+	}
+}
+
+// LoadRegistry returns the registry configured by AIBRIX_TOKENIZER_VOCABS, the vocabs of the model families as
+// JSON, e.g. {"llama-3": {"path": "/vocabs/llama-3.tiktoken", "pattern": "o200k"}}, and
+// AIBRIX_TOKENIZER_MAX_INPUT_BYTES.
+func LoadRegistry() *Registry {
+	families := map[string]Vocab{}
+	if value := utils.LoadEnv("AIBRIX_TOKENIZER_VOCABS", ""); value != "" {
+		if err := json.Unmarshal([]byte(value), &families); err != nil {
+			klog.Errorf("invalid AIBRIX_TOKENIZER_VOCABS, the prompt tokens of all models are estimated by the heuristic: %v", err)
+			families = map[string]Vocab{}
+		}
+	}
+
+	maxInputBytes := defaultMaxInputBytes
+	if value := utils.LoadEnv("AIBRIX_TOKENIZER_MAX_INPUT_BYTES", ""); value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err != nil || intValue <= 0 {
+			klog.Infof("invalid AIBRIX_TOKENIZER_MAX_INPUT_BYTES: %s, falling back to default", value)
+		} else {
+			maxInputBytes = intValue
+		}
+	}
+	return NewRegistry(families, maxInputBytes)
+}
+
+// ForModel returns the tokenizer of the model.
+func (r *Registry) ForModel(model string) Tokenizer {
+	family, vocab, ok := r.lookup(model)
+	if !ok {
+		return NewHeuristicTokenizer()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if tokenizer, ok := r.tokenizers[vocab]; ok {
+		return tokenizer
+	}
+	tokenizer, err := NewBPETokenizer(vocab.Path, vocab.Pattern, r.maxInputBytes)
+	if err != nil {
+		klog.ErrorS(err, "failed to load the vocab, the prompt tokens are estimated by the heuristic", "family", family, "vocab", vocab.Path)
+		tokenizer = NewHeuristicTokenizer()
+	} else {
+		klog.InfoS("loaded the vocab", "family", family, "vocab", vocab.Path)
+	}
+	// a vocab failing to load is not loaded again on every request.
+	r.tokenizers[vocab] = tokenizer
+	return tokenizer
+}
+
+func (r *Registry) lookup(model string) (string, Vocab, bool) {
+	var family string
+	var vocab Vocab
+	found := false
+	for prefix, v := range r.families {
+		if strings.HasPrefix(model, prefix) && (!found || len(prefix) > len(family)) {
+			family, vocab, found = prefix, v, true
+		}
+	}
+	return family, vocab, found
+}
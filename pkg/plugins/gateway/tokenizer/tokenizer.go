@@ -0,0 +1,151 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Tokenizer estimates the number of tokens a model reads from a prompt.
+type Tokenizer interface {
+	// CountTokens returns the estimated number of tokens of the text.
+	CountTokens(text string) int
+}
+
+// heuristicBytesPerToken is the average number of bytes of a token of English prose. The CJK text and code are
+// underestimated by 2-3x.
+const heuristicBytesPerToken = 4
+
+type heuristicTokenizer struct{}
+
+// NewHeuristicTokenizer returns the tokenizer counting a token per heuristicBytesPerToken bytes, used for the
+// models without a vocab.
+func NewHeuristicTokenizer() Tokenizer {
+	return heuristicTokenizer{}
+}
+
+func (heuristicTokenizer) CountTokens(text string) int {
+	return (len(text) + heuristicBytesPerToken - 1) / heuristicBytesPerToken
+}
+
+// patterns are the pre-tokenizer regexps of the known vocab families, a vocab may also set its own regexp.
+var patterns = map[string]string{
+	"cl100k": `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+	"o200k": strings.Join([]string{
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`\p{N}{1,3}`,
+		` ?[^\s\p{L}\p{N}]+[\r\n/]*`,
+		`\s*[\r\n]+`,
+		`\s+(?!\S)`,
+		`\s+`,
+	}, "|"),
+}
+
+const defaultPattern = "cl100k"
+
+// bpeTokenizer counts the tokens of a byte pair encoding vocab. Only the first maxInputBytes of a text are
+// encoded, the tokens of the rest are extrapolated from them.
+type bpeTokenizer struct {
+	encoder       *tiktoken.Tiktoken
+	maxInputBytes int
+}
+
+// NewBPETokenizer loads a tiktoken vocab, a base64 encoded token and its rank per line, pre-tokenized with the
+// named pattern of a known family or a regexp. The default pattern is cl100k.
+func NewBPETokenizer(vocabPath, pattern string, maxInputBytes int) (Tokenizer, error) {
+	ranks, err := loadVocab(vocabPath)
+	if err != nil {
+		return nil, err
+	}
+	if pattern == "" {
+		pattern = defaultPattern
+	}
+	if named, ok := patterns[pattern]; ok {
+		pattern = named
+	}
+	core, err := tiktoken.NewCoreBPE(ranks, map[string]int{}, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern of vocab %s: %v", vocabPath, err)
+	}
+	encoding := &tiktoken.Encoding{Name: vocabPath, PatStr: pattern, MergeableRanks: ranks, SpecialTokens: map[string]int{}}
+	return &bpeTokenizer{
+		encoder:       tiktoken.NewTiktoken(core, encoding, map[string]any{}),
+		maxInputBytes: maxInputBytes,
+	}, nil
+}
+
+func loadVocab(path string) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		encoded, rankValue, found := strings.Cut(text, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid vocab %s at line %d, expected <base64 token> <rank>", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token of vocab %s at line %d: %v", path, line, err)
+		}
+		rank, err := strconv.Atoi(rankValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank of vocab %s at line %d: %v", path, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("vocab %s is empty", path)
+	}
+	return ranks, nil
+}
+
+func (t *bpeTokenizer) CountTokens(text string) int {
+	if t.maxInputBytes <= 0 || len(text) <= t.maxInputBytes {
+		return len(t.encoder.EncodeOrdinary(text))
+	}
+	// cut the text on a rune boundary.
+	end := t.maxInputBytes
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	if end == 0 {
+		return NewHeuristicTokenizer().CountTokens(text)
+	}
+	tokens := len(t.encoder.EncodeOrdinary(text[:end]))
+	return (tokens*len(text) + end - 1) / end
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestVocab writes the cl100k vocab in the tiktoken format.
+func writeTestVocab(t *testing.T) string {
+	t.Helper()
+	ranks, err := tiktoken_loader.NewOfflineLoader().LoadTiktokenBpe("https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken")
+	require.NoError(t, err)
+	var b strings.Builder
+	for token, rank := range ranks {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+	path := filepath.Join(t.TempDir(), "cl100k.tiktoken")
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0o644))
+	return path
+}
+
+// the token counts of the fixtures by the cl100k tokenizer of OpenAI.
+var multilingualFixtures = []struct {
+	name   string
+	text   string
+	tokens int
+}{
+	{name: "english", text: "The quick brown fox jumps over the lazy dog.", tokens: 10},
+	{name: "chinese", text: "人工智能正在改变我们的生活方式。", tokens: 14},
+	{name: "japanese", text: "大規模言語モデルは、膨大なテキストデータで学習されています。", tokens: 33},
+	{name: "korean", text: "인공지능은 우리의 삶을 바꾸고 있습니다.", tokens: 21},
+	{name: "russian", text: "Искусственный интеллект меняет нашу жизнь.", tokens: 23},
+	{name: "code", text: "func add(a, b int) int {\n\treturn a + b\n}\n", tokens: 15},
+}
+
+func TestBPETokenizerAccuracy(t *testing.T) {
+	tokenizer, err := NewBPETokenizer(writeTestVocab(t), "", 0)
+	require.NoError(t, err)
+	for _, fixture := range multilingualFixtures {
+		t.Run(fixture.name, func(t *testing.T) {
+			assert.Equal(t, fixture.tokens, tokenizer.CountTokens(fixture.text))
+		})
+	}
+
+	// the heuristic underestimates the CJK text.
+	japanese := multilingualFixtures[2]
+	assert.Less(t, NewHeuristicTokenizer().CountTokens(japanese.text), japanese.tokens*3/4)
+}
+
+func TestBPETokenizerMaxInputBytes(t *testing.T) {
+	path := writeTestVocab(t)
+	unbounded, err := NewBPETokenizer(path, "", 0)
+	require.NoError(t, err)
+	bounded, err := NewBPETokenizer(path, "", 1000)
+	require.NoError(t, err)
+
+	for _, fixture := range multilingualFixtures {
+		t.Run(fixture.name, func(t *testing.T) {
+			text := strings.Repeat(fixture.text+"\n", 200)
+			expected := unbounded.CountTokens(text)
+			// the tokens past the first 1000 bytes are extrapolated within 10%.
+			assert.InDelta(t, expected, bounded.CountTokens(text), float64(expected)/10)
+		})
+	}
+}
+
+func TestRegistryForModel(t *testing.T) {
+	path := writeTestVocab(t)
+	registry := NewRegistry(map[string]Vocab{
+		"llama":   {Path: filepath.Join(t.TempDir(), "missing.tiktoken")},
+		"llama-3": {Path: path},
+		"qwen":    {Path: path, Pattern: "cl100k"},
+	}, defaultMaxInputBytes)
+	chinese := multilingualFixtures[1]
+
+	// the longest family prefix selects the vocab, the encoder is loaded once.
+	tokenizer := registry.ForModel("llama-3-8b-instruct")
+	assert.Equal(t, chinese.tokens, tokenizer.CountTokens(chinese.text))
+	assert.Same(t, tokenizer, registry.ForModel("llama-3-70b"))
+	assert.Equal(t, chinese.tokens, registry.ForModel("qwen-7b").CountTokens(chinese.text))
+
+	// the heuristic is the fallback of the models without a vocab and of the vocabs failing to load.
+	assert.Equal(t, NewHeuristicTokenizer(), registry.ForModel("llama-2-7b"))
+	assert.Equal(t, NewHeuristicTokenizer(), registry.ForModel("mistral-7b"))
+}
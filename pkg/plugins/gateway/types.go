@@ -30,10 +30,13 @@ const (
 	HeaderErrorRequestBodyProcessing = "x-error-request-body-processing"
 	HeaderErrorResponseUnmarshal     = "x-error-response-unmarshal"
 	HeaderErrorResponseUnknown       = "x-error-response-unknown"
+	HeaderErrorEngineAPI             = "x-error-engine-api"
 
 	// Model & Deployment Headers
 	HeaderErrorNoModelInRequest = "x-error-no-model-in-request"
 	HeaderErrorNoModelBackends  = "x-error-no-model-backends"
+	HeaderErrorModelRemoved     = "x-error-model-removed"
+	HeaderErrorModelHardDown    = "x-error-model-hard-down"
 
 	// Streaming Headers
 	HeaderErrorStreaming                 = "x-error-streaming"
@@ -43,22 +46,71 @@ const (
 	// Request & Target Headers
 	HeaderWentIntoReqHeaders = "x-went-into-req-headers"
 	HeaderTargetPod          = "target-pod"
+	HeaderRequestID          = "request-id"
 	HeaderRoutingStrategy    = "routing-strategy"
+	HeaderSpilloverCluster   = "x-spillover-cluster"
+	HeaderRequestPriority    = "x-request-priority"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderRequestTimeout     = "x-request-timeout-ms"
+	HeaderWarning            = "Warning"
+
+	// Admission Headers
+	HeaderErrorAdmissionRejected = "x-error-admission-rejected"
+	HeaderErrorPolicyViolation   = "x-error-policy-violation"
+	HeaderErrorDeadlineExceeded  = "x-error-deadline-exceeded"
+
+	// Admission Queue Headers
+	HeaderQueue         = "x-aibrix-queue"
+	HeaderQueuePosition = "x-aibrix-queue-position"
+	HeaderEstimatedWait = "x-aibrix-estimated-wait"
+	// HeaderQueueHeartbeat opts a streaming request waiting in the admission queue into the heartbeat comments.
+	HeaderQueueHeartbeat = "x-aibrix-queue-heartbeat"
+	// HeaderQueueTicket names the request handed over to the heartbeat proxy of the gateway plugin.
+	HeaderQueueTicket = "x-aibrix-queue-ticket"
+
+	// HeaderFailoverTicket names the request handed over to the failover proxy of the gateway plugin.
+	HeaderFailoverTicket = "x-aibrix-failover-ticket"
+
+	// Batch Headers
+	HeaderErrorBatch = "x-error-batch"
+
+	// Envoy Router Headers
+	HeaderEnvoyUpstreamTimeout       = "x-envoy-upstream-rq-timeout-ms"
+	HeaderEnvoyUpstreamPerTryTimeout = "x-envoy-upstream-rq-per-try-timeout-ms"
 
 	// RPM & TPM Update Errors
 	HeaderUpdateTPM        = "x-update-tpm"
 	HeaderUpdateRPM        = "x-update-rpm"
 	HeaderErrorRPMExceeded = "x-error-rpm-exceeded"
 	HeaderErrorTPMExceeded = "x-error-tpm-exceeded"
-	HeaderErrorIncrRPM     = "x-error-incr-rpm"
-	HeaderErrorIncrTPM     = "x-error-incr-tpm"
+
+	// Rate Limit Headers, the usage of the limits of the user
+	HeaderRateLimitLimitRequests     = "x-ratelimit-limit-requests"
+	HeaderRateLimitLimitTokens       = "x-ratelimit-limit-tokens"
+	HeaderRateLimitRemainingRequests = "x-ratelimit-remaining-requests"
+	HeaderRateLimitRemainingTokens   = "x-ratelimit-remaining-tokens"
+	HeaderRateLimitResetRequests     = "x-ratelimit-reset-requests"
+	HeaderRateLimitResetTokens       = "x-ratelimit-reset-tokens"
 
 	// Rate Limiting defaults
 	DefaultRPM           = 100
 	DefaultTPMMultiplier = 1000
+	// DefaultUserCacheTTLSeconds is how long the gateway serves a user before reading it again from Redis.
+	DefaultUserCacheTTLSeconds = 5
 
 	// Envs
-	EnvRoutingAlgorithm = "ROUTING_ALGORITHM"
+	EnvRoutingAlgorithm    = "ROUTING_ALGORITHM"
+	EnvDefaultModel        = "AIBRIX_GATEWAY_DEFAULT_MODEL"
+	EnvModelTimeouts       = "AIBRIX_GATEWAY_MODEL_TIMEOUTS"
+	EnvEngineHints         = "AIBRIX_GATEWAY_ENGINE_HINTS"
+	EnvEngineAPIs          = "AIBRIX_GATEWAY_ENGINE_APIS"
+	EnvHeadroom            = "AIBRIX_GATEWAY_HEADROOM"
+	EnvTenants             = "AIBRIX_GATEWAY_TENANTS"
+	EnvUnknownUsers        = "AIBRIX_GATEWAY_UNKNOWN_USERS"
+	EnvDefaultRPM          = "AIBRIX_GATEWAY_DEFAULT_RPM"
+	EnvDefaultTPM          = "AIBRIX_GATEWAY_DEFAULT_TPM"
+	EnvUserCacheTTLSeconds = "AIBRIX_GATEWAY_USER_CACHE_TTL_SECONDS"
+	EnvXFFTrustedHops      = "AIBRIX_GATEWAY_XFF_TRUSTED_HOPS"
 )
 
 var (
@@ -18,11 +18,14 @@ package gateway
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
+	"time"
 
 	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	envoyTypePb "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	routing "github.com/vllm-project/aibrix/pkg/plugins/gateway/algorithms"
 	"github.com/vllm-project/aibrix/pkg/utils"
 	"k8s.io/klog/v2"
 )
@@ -50,30 +53,16 @@ func validateStreamOptions(requestID string, user utils.User, jsonMap map[string
 	return nil
 }
 
-// getRoutingStrategy retrieves the routing strategy from the headers or environment variable
+// getRoutingStrategy retrieves the routing strategy from the headers, or the default strategy of the gateway.
 // It returns the routing strategy value and whether custom routing strategy is enabled.
-func getRoutingStrategy(headers []*configPb.HeaderValue) (string, bool) {
-	var routingStrategy string
-	routingStrategyEnabled := false
-
+func getRoutingStrategy(headers []*configPb.HeaderValue, defaultStrategy string) (string, bool) {
 	// Check headers for routing strategy
 	for _, header := range headers {
 		if strings.ToLower(header.Key) == HeaderRoutingStrategy {
-			routingStrategy = string(header.RawValue)
-			routingStrategyEnabled = true
-			break // Prioritize header value over environment variable
-		}
-	}
-
-	// If header not set, check environment variable
-	if !routingStrategyEnabled {
-		if value, exists := utils.CheckEnvExists(EnvRoutingAlgorithm); exists {
-			routingStrategy = value
-			routingStrategyEnabled = true
+			return string(header.RawValue), true
 		}
 	}
-
-	return routingStrategy, routingStrategyEnabled
+	return defaultStrategy, defaultStrategy != ""
 }
 
 // getRequestMessage returns input request message field which has user prompt
@@ -122,6 +111,48 @@ func generateErrorResponse(statusCode envoyTypePb.StatusCode, headers []*configP
 	}
 }
 
+// generateSpilloverResponse redirects the request to the gateway of the peer cluster with headroom.
+func generateSpilloverResponse(spillover *routing.SpilloverError, requestPath string) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(envoyTypePb.StatusCode_TemporaryRedirect,
+		[]*configPb.HeaderValueOption{
+			{Header: &configPb.HeaderValue{Key: "Location", RawValue: []byte(strings.TrimSuffix(spillover.Endpoint, "/") + requestPath)}},
+			{Header: &configPb.HeaderValue{Key: HeaderSpilloverCluster, RawValue: []byte(spillover.Cluster)}},
+		},
+		spillover.Error())
+}
+
+// generateModelNotFoundResponse rejects the request for a model no pod nor model adapter serves.
+func generateModelNotFoundResponse(model string) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(envoyTypePb.StatusCode_NotFound,
+		[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+			Key: HeaderErrorNoModelBackends, RawValue: []byte(model)}}},
+		fmt.Sprintf("model %s does not exist", model))
+}
+
+// defaultNoReadyPodsRetryAfter is the delay the client retries a request after if none of the pods of the model is
+// ready and the cache can not estimate when one will be.
+const defaultNoReadyPodsRetryAfter = 5 * time.Second
+
+// generateNoReadyPodsResponse rejects the request to a model none of whose pods is ready, e.g. while they all start
+// or drain during a rollout, and tells the client when to retry.
+func generateNoReadyPodsResponse(model string, retryAfter time.Duration) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(envoyTypePb.StatusCode_ServiceUnavailable,
+		[]*configPb.HeaderValueOption{
+			{Header: &configPb.HeaderValue{Key: HeaderErrorNoModelBackends, RawValue: []byte("true")}},
+			retryAfterHeader(retryAfter),
+		},
+		fmt.Sprintf("no ready pod available for model %s, retry later", model))
+}
+
+// generateModelHardDownResponse rejects the request to a model all the pods of which are crashing, the request
+// would wait for a pod which does not recover by itself.
+func generateModelHardDownResponse(model, reason string) *extProcPb.ProcessingResponse {
+	return generateErrorResponse(envoyTypePb.StatusCode_ServiceUnavailable,
+		[]*configPb.HeaderValueOption{{Header: &configPb.HeaderValue{
+			Key: HeaderErrorModelHardDown, RawValue: []byte(reason)}}},
+		fmt.Sprintf("model %s is unavailable, all its pods are in %s", model, reason))
+}
+
 // generateErrorMessage constructs a JSON error message
 func generateErrorMessage(message string, code int) string {
 	errorStruct := map[string]interface{}{
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetConditionInList sets the specific condition type on the given PodAutoscaler to the specified value with the given
+// reason and message, observed at the generation of the object.
+// The message and args are treated like a format string.
+// The condition will be added if it is not present. Following the metav1.Condition semantics, its lastTransitionTime
+// only changes when the condition is added or its status flips, so that setting the same condition again leaves the
+// list unchanged. The new list will be returned.
+func SetConditionInList(inputList []metav1.Condition, conditionType string, status metav1.ConditionStatus, observedGeneration int64, reason, message string, args ...interface{}) []metav1.Condition {
+	resList := inputList
+	existingCond := FindCondition(resList, conditionType)
+	if existingCond == nil {
+		resList = append(resList, metav1.Condition{
+			Type: conditionType,
+		})
+		existingCond = &resList[len(resList)-1]
+	}
+
+	if existingCond.Status != status || existingCond.LastTransitionTime.IsZero() {
+		existingCond.LastTransitionTime = metav1.Now()
+	}
+
+	existingCond.Status = status
+	existingCond.ObservedGeneration = observedGeneration
+	existingCond.Reason = reason
+	existingCond.Message = fmt.Sprintf(message, args...)
+
+	return resList
+}
+
+// FindCondition returns the condition of the given type in the list, nil if it is not present.
+func FindCondition(conditions []metav1.Condition, conditionType string) *metav1.Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// RemoveCondition removes the condition of the given type from the list, and returns the new list and whether the
+// condition was present.
+func RemoveCondition(conditions []metav1.Condition, conditionType string) ([]metav1.Condition, bool) {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return append(conditions[:i:i], conditions[i+1:]...), true
+		}
+	}
+	return conditions, false
+}
+
+// PruneConditions removes the conditions whose type is not in the known types, e.g. the types a previous version
+// of the controller set, or which no longer apply. It returns the new list and whether any condition was removed.
+func PruneConditions(conditions []metav1.Condition, known map[string]struct{}) ([]metav1.Condition, bool) {
+	pruned := conditions[:0:0]
+	for _, condition := range conditions {
+		if _, ok := known[condition.Type]; ok {
+			pruned = append(pruned, condition)
+		}
+	}
+	if len(pruned) == len(conditions) {
+		return conditions, false
+	}
+	return pruned, true
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetConditionInList(t *testing.T) {
+	conditions := SetConditionInList(nil, "AbleToScale", metav1.ConditionTrue, 1, "SucceededGetScale", "able to get the scale of %s", "llama")
+	cond := FindCondition(conditions, "AbleToScale")
+	assert.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+	assert.Equal(t, int64(1), cond.ObservedGeneration)
+	assert.Equal(t, "able to get the scale of llama", cond.Message)
+	assert.False(t, cond.LastTransitionTime.IsZero())
+
+	// the transition time is kept while the status does not flip.
+	transition := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	cond.LastTransitionTime = transition
+	conditions = SetConditionInList(conditions, "AbleToScale", metav1.ConditionTrue, 2, "SucceededRescale", "rescaled")
+	cond = FindCondition(conditions, "AbleToScale")
+	assert.Equal(t, transition, cond.LastTransitionTime)
+	assert.Equal(t, int64(2), cond.ObservedGeneration)
+	assert.Equal(t, "SucceededRescale", cond.Reason)
+
+	conditions = SetConditionInList(conditions, "AbleToScale", metav1.ConditionFalse, 2, "FailedGetScale", "not found")
+	cond = FindCondition(conditions, "AbleToScale")
+	assert.True(t, cond.LastTransitionTime.After(transition.Time))
+	assert.Len(t, conditions, 1)
+}
+
+func TestRemoveAndPruneConditions(t *testing.T) {
+	conditions := []metav1.Condition{{Type: "AbleToScale"}, {Type: "Legacy"}, {Type: "ScalingActive"}}
+
+	remaining, removed := RemoveCondition(conditions, "Legacy")
+	assert.True(t, removed)
+	assert.Equal(t, []metav1.Condition{{Type: "AbleToScale"}, {Type: "ScalingActive"}}, remaining)
+	// the input list is left as is.
+	assert.Equal(t, "Legacy", conditions[1].Type)
+	_, removed = RemoveCondition(remaining, "Legacy")
+	assert.False(t, removed)
+
+	known := map[string]struct{}{"AbleToScale": {}, "ScalingActive": {}}
+	pruned, changed := PruneConditions(conditions, known)
+	assert.True(t, changed)
+	assert.Equal(t, []metav1.Condition{{Type: "AbleToScale"}, {Type: "ScalingActive"}}, pruned)
+	_, changed = PruneConditions(pruned, known)
+	assert.False(t, changed)
+	assert.Nil(t, FindCondition(pruned, "Legacy"))
+}
@@ -19,19 +19,27 @@ package utils
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"k8s.io/klog/v2"
 
 	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	NAMESPACE = "aibrix-system"
+
+	// PodPortIdentifier is the label or annotation of a pod overriding the port of its model server.
+	PodPortIdentifier = "model.aibrix.ai/port"
+	// DefaultPodPort is the port of the model server of the pods without a port label or a named container port.
+	DefaultPodPort int32 = 8000
 )
 
+// podPortNames are the names of the container ports of the model server, by priority.
+var podPortNames = []string{"serving", "metrics"}
+
 // IsPodTerminating check if pod is in terminating status via whether the deletion timestamp is set
 func IsPodTerminating(pod *v1.Pod) bool {
 	return pod.ObjectMeta.DeletionTimestamp != nil
@@ -78,39 +86,6 @@ func GetPodConditionFromList(conditions []v1.PodCondition, conditionType v1.PodC
 	return -1, nil
 }
 
-// SetConditionInList sets the specific condition type on the given PodAutoscaler to the specified value with the given
-// reason and message.
-// The message and args are treated like a format string.
-// The condition will be added if it is not present. The new list will be returned.
-func SetConditionInList(inputList []metav1.Condition, conditionType string, status metav1.ConditionStatus, reason, message string, args ...interface{}) []metav1.Condition {
-	resList := inputList
-	var existingCond *metav1.Condition
-	for i, condition := range resList {
-		if condition.Type == conditionType {
-			// can't take a pointer to an iteration variable
-			existingCond = &resList[i]
-			break
-		}
-	}
-
-	if existingCond == nil {
-		resList = append(resList, metav1.Condition{
-			Type: conditionType,
-		})
-		existingCond = &resList[len(resList)-1]
-	}
-
-	if existingCond.Status != status {
-		existingCond.LastTransitionTime = metav1.Now()
-	}
-
-	existingCond.Status = status
-	existingCond.Reason = reason
-	existingCond.Message = fmt.Sprintf(message, args...)
-
-	return resList
-}
-
 func GetPodListByLabelSelector(ctx context.Context, podLister client.Client, namespace string, selector labels.Selector) (*v1.PodList, error) {
 	podList := &v1.PodList{}
 	err := podLister.List(ctx, podList, &client.ListOptions{
@@ -152,6 +127,33 @@ func FilterReadyPods(pods map[string]*v1.Pod) []*v1.Pod {
 	return readyPods
 }
 
+// GetPodPort returns the port of the model server of the pod: the model.aibrix.ai/port label or annotation, then
+// the container port named serving or metrics, then DefaultPodPort. An invalid port label or annotation is an error.
+func GetPodPort(pod *v1.Pod) (int32, error) {
+	value, ok := pod.Labels[PodPortIdentifier]
+	if !ok {
+		value, ok = pod.Annotations[PodPortIdentifier]
+	}
+	if ok {
+		port, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || port <= 0 || port > 65535 {
+			return 0, fmt.Errorf("invalid %s of pod %s: %q", PodPortIdentifier, pod.Name, value)
+		}
+		return int32(port), nil
+	}
+
+	for _, name := range podPortNames {
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Name == name && port.ContainerPort > 0 {
+					return port.ContainerPort, nil
+				}
+			}
+		}
+	}
+	return DefaultPodPort, nil
+}
+
 // FilterActivePods returns active pods.
 func FilterActivePods(pods []v1.Pod) []v1.Pod {
 	activeFilter := func(p v1.Pod) bool {
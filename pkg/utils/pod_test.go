@@ -0,0 +1,69 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPodPort(t *testing.T) {
+	containers := []v1.Container{
+		{Name: "sidecar", Ports: []v1.ContainerPort{{Name: "metrics", ContainerPort: 9090}}},
+		{Name: "vllm", Ports: []v1.ContainerPort{{Name: "serving", ContainerPort: 8080}}},
+	}
+	testCases := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		containers  []v1.Container
+		expected    int32
+		expectErr   bool
+	}{
+		{name: "default", expected: DefaultPodPort},
+		{name: "named serving port", containers: containers, expected: 8080},
+		{name: "named metrics port", containers: containers[:1], expected: 9090},
+		{name: "annotation", annotations: map[string]string{PodPortIdentifier: "8001"}, containers: containers, expected: 8001},
+		{
+			name:        "label over annotation",
+			labels:      map[string]string{PodPortIdentifier: "8002"},
+			annotations: map[string]string{PodPortIdentifier: "8001"},
+			expected:    8002,
+		},
+		{name: "invalid label", labels: map[string]string{PodPortIdentifier: "http"}, containers: containers, expectErr: true},
+		{name: "out of range label", labels: map[string]string{PodPortIdentifier: "70000"}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "p1", Labels: tc.labels, Annotations: tc.annotations},
+				Spec:       v1.PodSpec{Containers: tc.containers},
+			}
+			port, err := GetPodPort(pod)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, port)
+		})
+	}
+}
@@ -18,16 +18,34 @@ package utils
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"k8s.io/klog/v2"
 )
 
-var (
-	redis_host = GetEnv("REDIS_HOST", "localhost")
-	redis_port = GetEnv("REDIS_PORT", "6379")
+const (
+	// RedisModeStandalone connects to a single Redis server.
+	RedisModeStandalone = "standalone"
+	// RedisModeCluster connects to a Redis cluster through the seed addresses of its nodes.
+	RedisModeCluster = "cluster"
+	// RedisModeSentinel connects to the master monitored by the Redis sentinels.
+	RedisModeSentinel = "sentinel"
+
+	defaultRedisConnectAttempts   = 10
+	defaultRedisConnectBackoff    = 500 * time.Millisecond
+	defaultRedisMaxConnectBackoff = 10 * time.Second
+	redisDialTimeout              = 5 * time.Second
+	// redisCommandRetries is how many times a command is retried on a broken connection, the connections are
+	// redialed so that the clients reconnect lazily to a restarted Redis.
+	redisCommandRetries     = 3
+	redisHealthCheckTimeout = time.Second
 )
 
 // CheckEnvExists checks if an environment variable exists.
@@ -45,17 +63,201 @@ func GetEnv(key, defaultValue string) string {
 	return value
 }
 
-func GetRedisClient() *redis.Client {
-	// Connect to Redis
-	client := redis.NewClient(&redis.Options{
-		Addr: redis_host + ":" + redis_port,
-		DB:   0, // Default DB
-	})
-	pong, err := client.Ping(context.Background()).Result()
-	if err != nil {
-		klog.Fatalf("Error connecting to Redis: %v", err)
+// RedisConfig configures the connections to Redis.
+type RedisConfig struct {
+	// Mode is one of standalone, cluster or sentinel.
+	Mode string
+	// Addrs are the address of the standalone server, the seed addresses of the cluster nodes or the addresses of
+	// the sentinels.
+	Addrs []string
+	// MasterName is the name of the master monitored by the sentinels.
+	MasterName string
+	Username   string
+	Password   string
+	// DB is the database of the standalone server or of the sentinel master.
+	DB int
+	// TLS is the TLS configuration of the connections, nil for plain connections.
+	TLS *tls.Config
+
+	// ConnectAttempts is how many times the server is pinged on connect, backing off exponentially from
+	// ConnectBackoff to MaxConnectBackoff between the attempts.
+	ConnectAttempts   int
+	ConnectBackoff    time.Duration
+	MaxConnectBackoff time.Duration
+}
+
+// LoadRedisConfig loads the Redis configuration from the environment:
+//
+//	REDIS_MODE                      standalone (default), cluster or sentinel
+//	REDIS_HOST, REDIS_PORT          address of the standalone server, localhost:6379 by default
+//	REDIS_ADDRS                     comma separated addresses of the cluster nodes or sentinels
+//	REDIS_MASTER_NAME               master monitored by the sentinels
+//	REDIS_USERNAME, REDIS_PASSWORD  credentials
+//	REDIS_DB                        database, 0 by default
+//	REDIS_TLS_ENABLED               true to connect over TLS
+//	REDIS_TLS_CA_FILE               CA bundle verifying the server, the system roots by default
+//	REDIS_TLS_CERT_FILE, REDIS_TLS_KEY_FILE  client certificate
+//	REDIS_TLS_SERVER_NAME           server name verified, the host of the address by default
+//	REDIS_TLS_INSECURE_SKIP_VERIFY  true to skip the verification of the server
+//	REDIS_CONNECT_ATTEMPTS          pings on connect, 10 by default
+func LoadRedisConfig() (RedisConfig, error) {
+	config := RedisConfig{
+		Mode:              strings.ToLower(GetEnv("REDIS_MODE", RedisModeStandalone)),
+		MasterName:        GetEnv("REDIS_MASTER_NAME", ""),
+		Username:          GetEnv("REDIS_USERNAME", ""),
+		Password:          GetEnv("REDIS_PASSWORD", ""),
+		ConnectAttempts:   defaultRedisConnectAttempts,
+		ConnectBackoff:    defaultRedisConnectBackoff,
+		MaxConnectBackoff: defaultRedisMaxConnectBackoff,
+	}
+	for _, addr := range strings.Split(GetEnv("REDIS_ADDRS", ""), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			config.Addrs = append(config.Addrs, addr)
+		}
+	}
+	if len(config.Addrs) == 0 {
+		config.Addrs = []string{GetEnv("REDIS_HOST", "localhost") + ":" + GetEnv("REDIS_PORT", "6379")}
+	}
+
+	var err error
+	if value := GetEnv("REDIS_DB", ""); value != "" {
+		if config.DB, err = strconv.Atoi(value); err != nil || config.DB < 0 {
+			return RedisConfig{}, fmt.Errorf("invalid REDIS_DB: %s", value)
+		}
+	}
+	if value := GetEnv("REDIS_CONNECT_ATTEMPTS", ""); value != "" {
+		if config.ConnectAttempts, err = strconv.Atoi(value); err != nil || config.ConnectAttempts <= 0 {
+			return RedisConfig{}, fmt.Errorf("invalid REDIS_CONNECT_ATTEMPTS: %s", value)
+		}
+	}
+	if enabled, _ := strconv.ParseBool(GetEnv("REDIS_TLS_ENABLED", "false")); enabled {
+		if config.TLS, err = loadRedisTLSConfig(); err != nil {
+			return RedisConfig{}, err
+		}
+	}
+	return config, config.validate()
+}
+
+func loadRedisTLSConfig() (*tls.Config, error) {
+	insecure, _ := strconv.ParseBool(GetEnv("REDIS_TLS_INSECURE_SKIP_VERIFY", "false"))
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         GetEnv("REDIS_TLS_SERVER_NAME", ""),
+		InsecureSkipVerify: insecure, // #nosec G402 -- opted in for self-signed development servers
+	}
+	if caFile := GetEnv("REDIS_TLS_CA_FILE", ""); caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read REDIS_TLS_CA_FILE: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in REDIS_TLS_CA_FILE %s", caFile)
+		}
+	}
+	certFile, keyFile := GetEnv("REDIS_TLS_CERT_FILE", ""), GetEnv("REDIS_TLS_KEY_FILE", "")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the redis client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (c RedisConfig) validate() error {
+	switch c.Mode {
+	case RedisModeStandalone:
+		if len(c.Addrs) != 1 {
+			return fmt.Errorf("standalone redis expects a single address, got %d", len(c.Addrs))
+		}
+	case RedisModeCluster:
+	case RedisModeSentinel:
+		if c.MasterName == "" {
+			return fmt.Errorf("sentinel redis requires REDIS_MASTER_NAME")
+		}
+	default:
+		return fmt.Errorf("unknown REDIS_MODE %q, expected %s, %s or %s", c.Mode, RedisModeStandalone, RedisModeCluster, RedisModeSentinel)
+	}
+	if c.Mode == RedisModeCluster && c.DB != 0 {
+		return fmt.Errorf("cluster redis only supports the database 0")
+	}
+	return nil
+}
+
+// NewRedisClient returns a client of the configured Redis without connecting to it. The client dials the
+// connections on demand and redials the connections broken by a restart of Redis, so that the processes do not
+// need to be restarted with it.
+func NewRedisClient(config RedisConfig) (redis.UniversalClient, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	options := &redis.UniversalOptions{
+		Addrs:       config.Addrs,
+		MasterName:  config.MasterName,
+		Username:    config.Username,
+		Password:    config.Password,
+		DB:          config.DB,
+		TLSConfig:   config.TLS,
+		DialTimeout: redisDialTimeout,
+		MaxRetries:  redisCommandRetries,
+	}
+	switch config.Mode {
+	case RedisModeCluster:
+		return redis.NewClusterClient(options.Cluster()), nil
+	case RedisModeSentinel:
+		return redis.NewFailoverClient(options.Failover()), nil
+	default:
+		return redis.NewClient(options.Simple()), nil
+	}
+}
+
+// ConnectRedis pings Redis until it answers, backing off exponentially between the attempts. It returns the last
+// error once the attempts are exhausted or ctx is done.
+func ConnectRedis(ctx context.Context, client redis.UniversalClient, config RedisConfig) error {
+	backoff := config.ConnectBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = CheckRedisHealth(ctx, client); err == nil {
+			return nil
+		}
+		if attempt >= config.ConnectAttempts {
+			return fmt.Errorf("failed to connect to redis after %d attempts: %w", attempt, err)
+		}
+		klog.Warningf("failed to connect to redis, attempt %d/%d, retrying in %v: %v", attempt, config.ConnectAttempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to connect to redis: %w", err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, config.MaxConnectBackoff)
 	}
-	fmt.Println("Connected to Redis:", pong)
+}
 
-	return client
+// CheckRedisHealth returns an error if Redis does not answer a ping, to report the readiness of the processes
+// depending on it.
+func CheckRedisHealth(ctx context.Context, client redis.UniversalClient) error {
+	ctx, cancel := context.WithTimeout(ctx, redisHealthCheckTimeout)
+	defer cancel()
+	return client.Ping(ctx).Err()
+}
+
+// GetRedisClient returns a client of the Redis configured by the environment once it answers. The error is returned
+// to the caller when the configuration is invalid or Redis does not answer after the connect attempts.
+func GetRedisClient() (redis.UniversalClient, error) {
+	config, err := LoadRedisConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewRedisClient(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := ConnectRedis(context.Background(), client, config); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	klog.Infof("connected to redis %s in %s mode", strings.Join(config.Addrs, ","), config.Mode)
+	return client, nil
 }
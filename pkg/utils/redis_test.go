@@ -0,0 +1,203 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedisServer answers PING on a standalone address, and rejects the other commands so that the clients fall
+// back to the RESP2 protocol without client name.
+type fakeRedisServer struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+func startFakeRedisServer(t *testing.T, addr string) *fakeRedisServer {
+	listener, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	s := &fakeRedisServer{listener: listener}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(s.stop)
+	return s
+}
+
+func (s *fakeRedisServer) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		command, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		reply := "-ERR unknown command\r\n"
+		if strings.EqualFold(command, "ping") {
+			reply = "+PONG\r\n"
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// readRESPCommand reads an array of bulk strings and returns its first element.
+func readRESPCommand(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return "", err
+	}
+	var command string
+	for i := 0; i < count; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return "", err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if i == 0 {
+			command = strings.TrimSpace(arg)
+		}
+	}
+	return command, nil
+}
+
+// stop closes the listener and drops the connections, as a restart of Redis does.
+func (s *fakeRedisServer) stop() {
+	_ = s.listener.Close()
+	s.mu.Lock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func TestLoadRedisConfig(t *testing.T) {
+	t.Setenv("REDIS_HOST", "redis")
+	t.Setenv("REDIS_PASSWORD", "secret")
+	config, err := LoadRedisConfig()
+	require.NoError(t, err)
+	assert.Equal(t, RedisModeStandalone, config.Mode)
+	assert.Equal(t, []string{"redis:6379"}, config.Addrs)
+	assert.Equal(t, "secret", config.Password)
+	assert.Nil(t, config.TLS)
+
+	t.Setenv("REDIS_MODE", "cluster")
+	t.Setenv("REDIS_ADDRS", "node-0:6379, node-1:6379")
+	config, err = LoadRedisConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"node-0:6379", "node-1:6379"}, config.Addrs)
+	client, err := NewRedisClient(config)
+	require.NoError(t, err)
+	assert.IsType(t, &redis.ClusterClient{}, client)
+
+	t.Setenv("REDIS_MODE", "sentinel")
+	_, err = LoadRedisConfig()
+	assert.ErrorContains(t, err, "REDIS_MASTER_NAME")
+	t.Setenv("REDIS_MASTER_NAME", "mymaster")
+	t.Setenv("REDIS_DB", "2")
+	config, err = LoadRedisConfig()
+	require.NoError(t, err)
+	assert.Equal(t, 2, config.DB)
+
+	t.Setenv("REDIS_MODE", "replicated")
+	_, err = LoadRedisConfig()
+	assert.ErrorContains(t, err, "unknown REDIS_MODE")
+}
+
+func TestLoadRedisConfigTLS(t *testing.T) {
+	t.Setenv("REDIS_TLS_ENABLED", "true")
+	t.Setenv("REDIS_TLS_SERVER_NAME", "redis.example.com")
+	config, err := LoadRedisConfig()
+	require.NoError(t, err)
+	require.NotNil(t, config.TLS)
+	assert.Equal(t, "redis.example.com", config.TLS.ServerName)
+
+	t.Setenv("REDIS_TLS_CA_FILE", t.TempDir()+"/missing.pem")
+	_, err = LoadRedisConfig()
+	assert.ErrorContains(t, err, "REDIS_TLS_CA_FILE")
+}
+
+func TestConnectRedisReconnects(t *testing.T) {
+	server := startFakeRedisServer(t, "127.0.0.1:0")
+	addr := server.listener.Addr().String()
+	config := RedisConfig{Mode: RedisModeStandalone, Addrs: []string{addr}, ConnectAttempts: 3,
+		ConnectBackoff: 10 * time.Millisecond, MaxConnectBackoff: 20 * time.Millisecond}
+	client, err := NewRedisClient(config)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, ConnectRedis(ctx, client, config))
+
+	// the health check fails while redis is down, the client reconnects lazily once it is back.
+	server.stop()
+	assert.Error(t, CheckRedisHealth(ctx, client))
+	startFakeRedisServer(t, addr)
+	assert.NoError(t, CheckRedisHealth(ctx, client))
+}
+
+func TestConnectRedisReturnsError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	config := RedisConfig{Mode: RedisModeStandalone, Addrs: []string{addr}, ConnectAttempts: 2,
+		ConnectBackoff: time.Millisecond, MaxConnectBackoff: time.Millisecond}
+	client, err := NewRedisClient(config)
+	require.NoError(t, err)
+	defer client.Close()
+
+	err = ConnectRedis(context.Background(), client, config)
+	assert.ErrorContains(t, err, "after 2 attempts")
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// statusPatchBackoff is the budget of the retries of a conflicting status patch, the controllers requeue the object
+// once it is exhausted.
+var statusPatchBackoff = wait.Backoff{
+	Steps:    3,
+	Duration: 10 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// PatchStatus persists the status fields obj changed since original, the object as the controller read it. The
+// patch is guarded by the resource version of original, so that a status written by another actor in the meantime
+// is not overwritten: on a conflict the changes are reapplied to the latest object, unless the other actor changed
+// some of the same status fields, e.g. the conditions, in which case the conflict is returned and the controller
+// requeues the object. No request is sent when the status did not change, and the retries are bounded. Once
+// patched, obj keeps its status and takes the resource version of the persisted object.
+func PatchStatus(ctx context.Context, c client.Client, original, obj client.Object) error {
+	changes, err := statusMergePatch(original, obj)
+	if err != nil {
+		return err
+	}
+	if changes == nil {
+		return nil
+	}
+
+	base := original
+	overlapping := false
+	err = retry.OnError(statusPatchBackoff, func(err error) bool {
+		return apierrors.IsConflict(err) && !overlapping
+	}, func() error {
+		desired, err := applyStatusMergePatch(base, changes)
+		if err != nil {
+			return err
+		}
+		err = c.Status().Patch(ctx, desired, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+		if apierrors.IsConflict(err) {
+			latest := base.DeepCopyObject().(client.Object)
+			if getErr := c.Get(ctx, client.ObjectKeyFromObject(obj), latest); getErr != nil {
+				return getErr
+			}
+			written, patchErr := statusMergePatch(base, latest)
+			if patchErr != nil {
+				return patchErr
+			}
+			overlapping = statusFieldsOverlap(written, changes)
+			base = latest
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		obj.SetResourceVersion(desired.GetResourceVersion())
+		return nil
+	})
+	return err
+}
+
+// statusMergePatch returns the merge patch of the status from original to obj, nil if the status is unchanged.
+func statusMergePatch(original, obj client.Object) ([]byte, error) {
+	data, err := client.MergeFrom(original).Data(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the status patch of %s: %w", obj.GetName(), err)
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to compute the status patch of %s: %w", obj.GetName(), err)
+	}
+	status, ok := fields["status"]
+	if !ok {
+		return nil, nil
+	}
+	return json.Marshal(map[string]json.RawMessage{"status": status})
+}
+
+// applyStatusMergePatch returns a copy of base with the merge patch of the status applied.
+func applyStatusMergePatch(base client.Object, patch []byte) (client.Object, error) {
+	data, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = jsonpatch.MergePatch(data, patch); err != nil {
+		return nil, fmt.Errorf("failed to apply the status patch of %s: %w", base.GetName(), err)
+	}
+	desired := reflect.New(reflect.TypeOf(base).Elem()).Interface().(client.Object)
+	if err := json.Unmarshal(data, desired); err != nil {
+		return nil, err
+	}
+	return desired, nil
+}
+
+// statusFieldsOverlap tells whether the two merge patches of the status change some of the same status fields.
+func statusFieldsOverlap(a, b []byte) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	var fieldsA, fieldsB struct {
+		Status map[string]json.RawMessage `json:"status"`
+	}
+	if json.Unmarshal(a, &fieldsA) != nil || json.Unmarshal(b, &fieldsB) != nil {
+		return true
+	}
+	for field := range fieldsA.Status {
+		if _, ok := fieldsB.Status[field]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newStatusTestPod() *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "p1", Namespace: "default"},
+		Status:     v1.PodStatus{Phase: v1.PodPending, Reason: "Scheduling"},
+	}
+}
+
+// newStatusTestClient returns a fake client holding the pod whose status patches fail with a conflict for the given
+// number of attempts, and the counter of the attempts.
+func newStatusTestClient(conflicts int) (client.Client, *int) {
+	patches := 0
+	c := fake.NewClientBuilder().
+		WithObjects(newStatusTestPod()).
+		WithStatusSubresource(&v1.Pod{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				patches++
+				if patches <= conflicts {
+					return apierrors.NewConflict(v1.Resource("pods"), obj.GetName(), errors.New("the object has been modified"))
+				}
+				return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+	return c, &patches
+}
+
+func getStatusTestPod(t *testing.T, c client.Client) *v1.Pod {
+	t.Helper()
+	pod := &v1.Pod{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "p1"}, pod))
+	return pod
+}
+
+func TestPatchStatus(t *testing.T) {
+	ctx := context.Background()
+
+	// the status fields written by another actor since the pod was read are kept.
+	c, patches := newStatusTestClient(0)
+	pod := getStatusTestPod(t, c)
+	original := pod.DeepCopy()
+	other := pod.DeepCopy()
+	other.Status.Message = "written by another actor"
+	assert.NoError(t, c.Status().Update(ctx, other))
+	pod.Status.Phase = v1.PodRunning
+	assert.NoError(t, PatchStatus(ctx, c, original, pod))
+	assert.Equal(t, 2, *patches, "the patch guarded by the stale resource version is reapplied to the latest pod")
+	persisted := getStatusTestPod(t, c)
+	assert.Equal(t, v1.PodRunning, persisted.Status.Phase)
+	assert.Equal(t, "Scheduling", persisted.Status.Reason)
+	assert.Equal(t, "written by another actor", persisted.Status.Message)
+	assert.Equal(t, persisted.ResourceVersion, pod.ResourceVersion)
+
+	// the status fields written by another actor are not overwritten, the conflict is returned instead.
+	c, patches = newStatusTestClient(0)
+	pod = getStatusTestPod(t, c)
+	original = pod.DeepCopy()
+	other = pod.DeepCopy()
+	other.Status.Phase = v1.PodFailed
+	assert.NoError(t, c.Status().Update(ctx, other))
+	pod.Status.Phase = v1.PodRunning
+	assert.True(t, apierrors.IsConflict(PatchStatus(ctx, c, original, pod)))
+	assert.Equal(t, 1, *patches)
+	assert.Equal(t, v1.PodFailed, getStatusTestPod(t, c).Status.Phase)
+
+	// an unchanged status is not patched, whatever else changed.
+	c, patches = newStatusTestClient(0)
+	pod = getStatusTestPod(t, c)
+	original = pod.DeepCopy()
+	pod.Labels = map[string]string{"app": "vllm"}
+	assert.NoError(t, PatchStatus(ctx, c, original, pod))
+	assert.Equal(t, 0, *patches)
+}
+
+func TestPatchStatusRetriesOnConflict(t *testing.T) {
+	testCases := []struct {
+		name      string
+		conflicts int
+		expected  int
+		conflict  bool
+	}{
+		{
+			name:     "no conflict",
+			expected: 1,
+		},
+		{
+			name:      "conflicts within the budget",
+			conflicts: 2,
+			expected:  3,
+		},
+		{
+			name:      "conflicts exhausting the budget",
+			conflicts: 10,
+			expected:  statusPatchBackoff.Steps,
+			conflict:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, patches := newStatusTestClient(tc.conflicts)
+			pod := getStatusTestPod(t, c)
+			original := pod.DeepCopy()
+			pod.Status.Phase = v1.PodRunning
+
+			err := PatchStatus(ctx, c, original, pod)
+			assert.Equal(t, tc.conflict, apierrors.IsConflict(err))
+			assert.Equal(t, tc.expected, *patches)
+			if !tc.conflict {
+				assert.NoError(t, err)
+				assert.Equal(t, v1.PodRunning, getStatusTestPod(t, c).Status.Phase)
+			}
+		})
+	}
+
+	// errors other than conflicts are not retried.
+	patches := 0
+	c := fake.NewClientBuilder().
+		WithObjects(newStatusTestPod()).
+		WithStatusSubresource(&v1.Pod{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				patches++
+				return apierrors.NewInternalError(errors.New("etcd unavailable"))
+			},
+		}).
+		Build()
+	pod := getStatusTestPod(t, c)
+	original := pod.DeepCopy()
+	pod.Status.Phase = v1.PodRunning
+	assert.Error(t, PatchStatus(context.Background(), c, original, pod))
+	assert.Equal(t, 1, patches)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// the hourly usage is kept for a week, the daily usage for a year and a month.
+	usageHourlyRetention = 8 * 24 * time.Hour
+	usageDailyRetention  = 400 * 24 * time.Hour
+
+	usageHourLayout = "2006010215"
+	usageDayLayout  = "20060102"
+
+	usagePromptTokensField     = "prompt_tokens"
+	usageCompletionTokensField = "completion_tokens"
+	usageRequestsField         = "requests"
+)
+
+// UsageRecord is the token usage of requests of a user to a model, counted in the hour and the day of At.
+type UsageRecord struct {
+	User             string
+	Model            string
+	PromptTokens     int64
+	CompletionTokens int64
+	Requests         int64
+	At               time.Time
+}
+
+// ModelUsage is the token usage of a user of a model over a time range.
+type ModelUsage struct {
+	Model            string `json:"model"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Requests         int64  `json:"requests"`
+}
+
+// usageBucketKey returns the key of the hash counting the usage of the user of the model in the bucket, e.g.
+// usage:alice:llama-7b:2025010112 for an hour or usage:alice:llama-7b:20250101 for a day.
+func usageBucketKey(user, model, bucket string) string {
+	return fmt.Sprintf("usage:%s:%s:%s", user, model, bucket)
+}
+
+// usageModelsKey returns the key of the set of the models the user has a usage of.
+func usageModelsKey(user string) string {
+	return fmt.Sprintf("usage:%s:models", user)
+}
+
+// usageBuckets returns the buckets covering the time range, the day buckets for the whole UTC days of the range
+// and the hour buckets for the hours around them. The range is widened to whole hours.
+func usageBuckets(from, to time.Time) []string {
+	var buckets []string
+	for t := from.UTC().Truncate(time.Hour); t.Before(to); {
+		if t.Hour() == 0 && !t.Add(24*time.Hour).After(to) {
+			buckets = append(buckets, t.Format(usageDayLayout))
+			t = t.Add(24 * time.Hour)
+			continue
+		}
+		buckets = append(buckets, t.Format(usageHourLayout))
+		t = t.Add(time.Hour)
+	}
+	return buckets
+}
+
+// IncrUsage adds the records to the hourly and daily usage of their users in a single pipeline.
+func IncrUsage(ctx context.Context, records []UsageRecord, redisClient redis.UniversalClient) error {
+	if len(records) == 0 {
+		return nil
+	}
+	pipe := redisClient.Pipeline()
+	for _, record := range records {
+		at := record.At.UTC()
+		for _, bucket := range []struct {
+			name string
+			ttl  time.Duration
+		}{
+			{at.Format(usageHourLayout), usageHourlyRetention},
+			{at.Format(usageDayLayout), usageDailyRetention},
+		} {
+			key := usageBucketKey(record.User, record.Model, bucket.name)
+			pipe.HIncrBy(ctx, key, usagePromptTokensField, record.PromptTokens)
+			pipe.HIncrBy(ctx, key, usageCompletionTokensField, record.CompletionTokens)
+			pipe.HIncrBy(ctx, key, usageRequestsField, record.Requests)
+			pipe.Expire(ctx, key, bucket.ttl)
+		}
+		pipe.SAdd(ctx, usageModelsKey(record.User), record.Model)
+		pipe.Expire(ctx, usageModelsKey(record.User), usageDailyRetention)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetUsage returns the usage of the user per model over the time range, sorted by model. The range is widened to
+// whole hours, and the hours older than a week are only counted as part of a whole day of the range.
+func GetUsage(ctx context.Context, user string, from, to time.Time, redisClient redis.UniversalClient) ([]ModelUsage, error) {
+	models, err := redisClient.SMembers(ctx, usageModelsKey(user)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(models)
+	buckets := usageBuckets(from, to)
+
+	pipe := redisClient.Pipeline()
+	results := make([][]*redis.MapStringStringCmd, len(models))
+	for i, model := range models {
+		for _, bucket := range buckets {
+			results[i] = append(results[i], pipe.HGetAll(ctx, usageBucketKey(user, model, bucket)))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	usage := make([]ModelUsage, 0, len(models))
+	for i, model := range models {
+		modelUsage := ModelUsage{Model: model}
+		for _, cmd := range results[i] {
+			fields, err := cmd.Result()
+			if err != nil {
+				return nil, err
+			}
+			modelUsage.PromptTokens += parseUsageField(fields, usagePromptTokensField)
+			modelUsage.CompletionTokens += parseUsageField(fields, usageCompletionTokensField)
+			modelUsage.Requests += parseUsageField(fields, usageRequestsField)
+		}
+		if modelUsage.Requests != 0 || modelUsage.PromptTokens != 0 || modelUsage.CompletionTokens != 0 {
+			usage = append(usage, modelUsage)
+		}
+	}
+	return usage, nil
+}
+
+func parseUsageField(fields map[string]string, field string) int64 {
+	value, _ := strconv.ParseInt(fields[field], 10, 64)
+	return value
+}
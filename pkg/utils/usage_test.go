@@ -0,0 +1,48 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageBucketKey(t *testing.T) {
+	assert.Equal(t, "usage:alice:llama-7b:2025010112", usageBucketKey("alice", "llama-7b", "2025010112"))
+	assert.Equal(t, "usage:alice:models", usageModelsKey("alice"))
+}
+
+func TestUsageBuckets(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// the whole days are read from the day buckets, the hours around them from the hour buckets.
+	assert.Equal(t, []string{"2024123122", "2024123123", "20250101", "20250102", "2025010300"},
+		usageBuckets(day.Add(-2*time.Hour), day.Add(49*time.Hour)))
+
+	// the range is widened to whole hours.
+	assert.Equal(t, []string{"2025010112", "2025010113"},
+		usageBuckets(day.Add(12*time.Hour+30*time.Minute), day.Add(13*time.Hour+time.Minute)))
+
+	// the buckets are UTC hours and days.
+	local := time.FixedZone("UTC+8", 8*60*60)
+	assert.Equal(t, []string{"20250101"},
+		usageBuckets(time.Date(2025, 1, 1, 8, 0, 0, 0, local), time.Date(2025, 1, 2, 8, 0, 0, 0, local)))
+
+	assert.Empty(t, usageBuckets(day, day))
+}
@@ -19,57 +19,423 @@ package utils
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// userKeyPrefix prefixes the Redis hash of each user.
+	userKeyPrefix = "user:"
+	// legacyUserKeyPrefix prefixes the JSON string of the users stored before the hashes, they are still read and
+	// are moved to a hash on their next update.
+	legacyUserKeyPrefix = "aibrix-users/"
+	// maxUserTxRetries is how many times a write is retried when the user changed between its read and its write.
+	maxUserTxRetries = 10
+	// maxCachedUsers bounds the users kept by a UserCache, the cache is emptied once it is full.
+	maxCachedUsers = 10000
+
+	userFieldName      = "name"
+	userFieldRpm       = "rpm"
+	userFieldTpm       = "tpm"
+	userFieldEnabled   = "enabled"
+	userFieldTenant    = "tenant"
+	userFieldPolicy    = "policy"
+	userFieldCreatedAt = "createdAt"
+	userFieldUpdatedAt = "updatedAt"
+)
+
+var (
+	// ErrUserNotFound is returned when the user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserAlreadyExists is returned when creating a user which already exists.
+	ErrUserAlreadyExists = errors.New("user already exists")
+)
+
 type User struct {
-	Name string `json:"name" validate:"required"`
-	Rpm  int64  `json:"rpm"`
-	Tpm  int64  `json:"tpm"`
+	Name   string      `json:"name" validate:"required"`
+	Rpm    int64       `json:"rpm"`
+	Tpm    int64       `json:"tpm"`
+	Policy *UserPolicy `json:"policy,omitempty"`
+	// Tenant is the tenant of the user, whose requests are served by the pods reserved for the tenant.
+	Tenant string `json:"tenant,omitempty"`
+	// Enabled is whether the requests of the user are served, a user without the flag is enabled. An update
+	// without the flag keeps the current one.
+	Enabled *bool `json:"enabled,omitempty"`
+	// CreatedAt and UpdatedAt are set by the store, they are zero for the users stored before the hashes.
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// IsEnabled returns whether the requests of the user are served.
+func (u User) IsEnabled() bool {
+	return u.Enabled == nil || *u.Enabled
 }
 
-func CheckUser(ctx context.Context, u User, redisClient *redis.Client) bool {
-	val, err := redisClient.Exists(ctx, genKey(u.Name)).Result()
+// UserPolicy restricts the requests of a user, empty fields are not restricted.
+type UserPolicy struct {
+	// AllowedModels are glob patterns of the models and LoRA adapters the user can call, e.g. "llama-7b-lora-*".
+	AllowedModels []string `json:"allowedModels,omitempty"`
+	// MaxTokens is the largest max_tokens the user can request.
+	MaxTokens int64 `json:"maxTokens,omitempty"`
+	// AllowedEndpoints are the request paths the user can call, e.g. "/v1/chat/completions".
+	AllowedEndpoints []string `json:"allowedEndpoints,omitempty"`
+	// DeniedFeatures are the request features the user can not use, one of "logprobs" or "tools".
+	DeniedFeatures []string `json:"deniedFeatures,omitempty"`
+}
+
+func validateUser(u User) error {
+	if u.Name == "" {
+		return fmt.Errorf("user name is required")
+	}
+	if u.Rpm < 0 || u.Tpm < 0 {
+		return fmt.Errorf("rpm or tpm can not negative")
+	}
+	if u.Policy != nil && u.Policy.MaxTokens < 0 {
+		return fmt.Errorf("policy max tokens can not negative")
+	}
+	return nil
+}
+
+// CreateUser stores a new user, it returns ErrUserAlreadyExists if the user exists.
+func CreateUser(ctx context.Context, u User, redisClient redis.UniversalClient) (User, error) {
+	if err := validateUser(u); err != nil {
+		return User{}, err
+	}
+	if _, err := getLegacyUser(ctx, redisClient, u.Name); err == nil {
+		return User{}, fmt.Errorf("%w: %s", ErrUserAlreadyExists, u.Name)
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return User{}, err
+	}
+	var created User
+	err := watchUser(ctx, redisClient, u.Name, func(tx *redis.Tx) error {
+		if _, err := getUserHash(ctx, tx, u.Name); err == nil {
+			return fmt.Errorf("%w: %s", ErrUserAlreadyExists, u.Name)
+		} else if !errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		created = u
+		if created.Enabled == nil {
+			created.Enabled = boolPtr(true)
+		}
+		created.CreatedAt = time.Now().UTC()
+		created.UpdatedAt = created.CreatedAt
+		return setUser(ctx, tx, created)
+	})
 	if err != nil {
-		return false
+		return User{}, err
 	}
+	return created, nil
+}
 
-	return val != 0
+// UpdateUser replaces the limits, policy and tenant of an existing user, it returns ErrUserNotFound if the user
+// does not exist. The creation time is kept, and so is the enabled flag when the update does not set it.
+func UpdateUser(ctx context.Context, u User, redisClient redis.UniversalClient) (User, error) {
+	if err := validateUser(u); err != nil {
+		return User{}, err
+	}
+	legacy, legacyErr := getLegacyUser(ctx, redisClient, u.Name)
+	if legacyErr != nil && !errors.Is(legacyErr, ErrUserNotFound) {
+		return User{}, legacyErr
+	}
+	var updated User
+	err := watchUser(ctx, redisClient, u.Name, func(tx *redis.Tx) error {
+		current, err := getUserHash(ctx, tx, u.Name)
+		if errors.Is(err, ErrUserNotFound) && legacyErr == nil {
+			current, err = legacy, nil
+		}
+		if err != nil {
+			return err
+		}
+		updated = u
+		if updated.Enabled == nil {
+			updated.Enabled = boolPtr(current.IsEnabled())
+		}
+		updated.CreatedAt = current.CreatedAt
+		updated.UpdatedAt = time.Now().UTC()
+		return setUser(ctx, tx, updated)
+	})
+	if err != nil {
+		return User{}, err
+	}
+	// the legacy key is in another hash slot than the hash, it can not be deleted within the transaction.
+	if err := redisClient.Del(ctx, legacyUserKey(u.Name)).Err(); err != nil {
+		return User{}, err
+	}
+	return updated, nil
+}
+
+// DeleteUser deletes a user, it returns ErrUserNotFound if the user does not exist.
+func DeleteUser(ctx context.Context, name string, redisClient redis.UniversalClient) error {
+	deleted, err := redisClient.Del(ctx, userKey(name)).Result()
+	if err != nil {
+		return err
+	}
+	legacyDeleted, err := redisClient.Del(ctx, legacyUserKey(name)).Result()
+	if err != nil {
+		return err
+	}
+	if deleted+legacyDeleted == 0 {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	}
+	return nil
+}
+
+// GetUser returns a user, or ErrUserNotFound if the user does not exist.
+func GetUser(ctx context.Context, name string, redisClient redis.UniversalClient) (User, error) {
+	return getUser(ctx, redisClient, name)
 }
 
-func GetUser(ctx context.Context, u User, redisClient *redis.Client) (User, error) {
-	val, err := redisClient.Get(ctx, genKey(u.Name)).Result()
+// ListUsers returns the users ordered by name.
+func ListUsers(ctx context.Context, redisClient redis.UniversalClient) ([]User, error) {
+	names := map[string]struct{}{}
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		for _, prefix := range []string{userKeyPrefix, legacyUserKeyPrefix} {
+			iter := client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+			for iter.Next(ctx) {
+				names[strings.TrimPrefix(iter.Val(), prefix)] = struct{}{}
+			}
+			if err := iter.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var mu sync.Mutex
+	var err error
+	if cluster, ok := redisClient.(*redis.ClusterClient); ok {
+		// the keys are spread over the masters, each one is scanned.
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return scan(ctx, client)
+		})
+	} else {
+		err = scan(ctx, redisClient)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, len(names))
+	for name := range names {
+		user, err := getUser(ctx, redisClient, name)
+		if errors.Is(err, ErrUserNotFound) {
+			// deleted since the scan.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+// watchUser runs the read-modify-write of the user within a transaction on its hash, retried while the user is
+// written concurrently.
+func watchUser(ctx context.Context, redisClient redis.UniversalClient, name string, fn func(tx *redis.Tx) error) error {
+	for i := 0; i < maxUserTxRetries; i++ {
+		err := redisClient.Watch(ctx, fn, userKey(name))
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("user %s was concurrently updated %d times", name, maxUserTxRetries)
+}
+
+func getUser(ctx context.Context, redisClient redis.UniversalClient, name string) (User, error) {
+	user, err := getUserHash(ctx, redisClient, name)
+	if errors.Is(err, ErrUserNotFound) {
+		return getLegacyUser(ctx, redisClient, name)
+	}
+	return user, err
+}
+
+// getUserHash reads the hash of the user through c, e.g. the transaction watching it.
+func getUserHash(ctx context.Context, c redis.Cmdable, name string) (User, error) {
+	fields, err := c.HGetAll(ctx, userKey(name)).Result()
 	if err != nil {
 		return User{}, err
 	}
-	user := &User{}
-	err = json.Unmarshal([]byte(val), user)
+	if len(fields) == 0 {
+		return User{}, fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	}
+	return parseUser(name, fields)
+}
+
+// getLegacyUser reads the JSON string of a user stored before the hashes. The legacy key is in another hash slot
+// than the hash, it is read outside of the transactions on the hash.
+func getLegacyUser(ctx context.Context, redisClient redis.UniversalClient, name string) (User, error) {
+	val, err := redisClient.Get(ctx, legacyUserKey(name)).Result()
+	if errors.Is(err, redis.Nil) {
+		return User{}, fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	}
 	if err != nil {
 		return User{}, err
 	}
+	user := User{}
+	if err := json.Unmarshal([]byte(val), &user); err != nil {
+		return User{}, fmt.Errorf("invalid user %s: %v", name, err)
+	}
+	return user, nil
+}
 
-	return *user, nil
+// setUser writes the user within the transaction, replacing its hash.
+func setUser(ctx context.Context, tx *redis.Tx, u User) error {
+	fields := map[string]interface{}{
+		userFieldName:      u.Name,
+		userFieldRpm:       u.Rpm,
+		userFieldTpm:       u.Tpm,
+		userFieldEnabled:   strconv.FormatBool(u.IsEnabled()),
+		userFieldCreatedAt: u.CreatedAt.Format(time.RFC3339Nano),
+		userFieldUpdatedAt: u.UpdatedAt.Format(time.RFC3339Nano),
+	}
+	if u.Tenant != "" {
+		fields[userFieldTenant] = u.Tenant
+	}
+	if u.Policy != nil {
+		policy, err := json.Marshal(u.Policy)
+		if err != nil {
+			return err
+		}
+		fields[userFieldPolicy] = string(policy)
+	}
+	_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, userKey(u.Name))
+		pipe.HSet(ctx, userKey(u.Name), fields)
+		return nil
+	})
+	return err
 }
 
-func SetUser(ctx context.Context, u User, redisClient *redis.Client) error {
-	if u.Rpm < 0 || u.Tpm < 0 {
-		return fmt.Errorf("rpm or tpm can not negative")
+func parseUser(name string, fields map[string]string) (User, error) {
+	user := User{Name: name, Tenant: fields[userFieldTenant]}
+	var err error
+	if user.Rpm, err = parseUserInt(fields, userFieldRpm); err != nil {
+		return User{}, fmt.Errorf("invalid user %s: %v", name, err)
+	}
+	if user.Tpm, err = parseUserInt(fields, userFieldTpm); err != nil {
+		return User{}, fmt.Errorf("invalid user %s: %v", name, err)
+	}
+	if value, ok := fields[userFieldEnabled]; ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return User{}, fmt.Errorf("invalid user %s: invalid %s %q", name, userFieldEnabled, value)
+		}
+		user.Enabled = &enabled
+	}
+	if value, ok := fields[userFieldPolicy]; ok {
+		user.Policy = &UserPolicy{}
+		if err := json.Unmarshal([]byte(value), user.Policy); err != nil {
+			return User{}, fmt.Errorf("invalid user %s: invalid %s: %v", name, userFieldPolicy, err)
+		}
+	}
+	if user.CreatedAt, err = parseUserTime(fields, userFieldCreatedAt); err != nil {
+		return User{}, fmt.Errorf("invalid user %s: %v", name, err)
+	}
+	if user.UpdatedAt, err = parseUserTime(fields, userFieldUpdatedAt); err != nil {
+		return User{}, fmt.Errorf("invalid user %s: %v", name, err)
+	}
+	return user, nil
+}
+
+func parseUserInt(fields map[string]string, field string) (int64, error) {
+	value, ok := fields[field]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", field, value)
 	}
+	return n, nil
+}
 
-	b, err := json.Marshal(&u)
+func parseUserTime(fields map[string]string, field string) (time.Time, error) {
+	value, ok := fields[field]
+	if !ok {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
 	if err != nil {
-		return err
+		return time.Time{}, fmt.Errorf("invalid %s %q", field, value)
 	}
+	return t, nil
+}
+
+func userKey(name string) string {
+	return userKeyPrefix + name
+}
+
+func legacyUserKey(name string) string {
+	return legacyUserKeyPrefix + name
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// UserCache caches the users read on the request path for a short TTL, so that a request does not wait on Redis
+// for its user. The users which do not exist are cached too, the errors of Redis are not.
+type UserCache struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedUser
+}
+
+type cachedUser struct {
+	user      User
+	err       error
+	expiresAt time.Time
+}
 
-	return redisClient.Set(ctx, genKey(u.Name), string(b), 0).Err()
+// NewUserCache returns a cache of the users stored in Redis, an update is seen within the TTL.
+func NewUserCache(client redis.UniversalClient, ttl time.Duration) *UserCache {
+	return &UserCache{
+		client:  client,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]cachedUser{},
+	}
 }
 
-func DelUser(ctx context.Context, u User, redisClient *redis.Client) error {
-	return redisClient.Del(ctx, genKey(u.Name)).Err()
+// Get returns the user, or ErrUserNotFound if the user does not exist.
+func (c *UserCache) Get(ctx context.Context, name string) (User, error) {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.user, entry.err
+	}
+
+	user, err := GetUser(ctx, name, c.client)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return User{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedUsers {
+		c.entries = map[string]cachedUser{}
+	}
+	c.entries[name] = cachedUser{user: user, err: err, expiresAt: now.Add(c.ttl)}
+	return user, err
 }
 
-func genKey(s string) string {
-	return fmt.Sprintf("aibrix-users/%s", s)
+// Invalidate drops the cached user, so that its next Get reads it from Redis.
+func (c *UserCache) Invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
 }
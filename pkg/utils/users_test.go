@@ -0,0 +1,252 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUserRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return server, client
+}
+
+func TestUserCRUD(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestUserRedis(t)
+
+	created, err := CreateUser(ctx, User{Name: "alice", Rpm: 10, Tpm: 100, Policy: &UserPolicy{MaxTokens: 512}}, client)
+	require.NoError(t, err)
+	assert.True(t, created.IsEnabled())
+	assert.False(t, created.CreatedAt.IsZero())
+	assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+	assert.Equal(t, "10", server.HGet("user:alice", "rpm"))
+	assert.Equal(t, "true", server.HGet("user:alice", "enabled"))
+
+	_, err = CreateUser(ctx, User{Name: "alice", Rpm: 20}, client)
+	assert.ErrorIs(t, err, ErrUserAlreadyExists)
+
+	user, err := GetUser(ctx, "alice", client)
+	require.NoError(t, err)
+	assert.Equal(t, created.Rpm, user.Rpm)
+	assert.Equal(t, created.Tpm, user.Tpm)
+	assert.Equal(t, int64(512), user.Policy.MaxTokens)
+	assert.True(t, created.CreatedAt.Equal(user.CreatedAt))
+
+	disabled := false
+	updated, err := UpdateUser(ctx, User{Name: "alice", Rpm: 20, Tpm: 200, Enabled: &disabled}, client)
+	require.NoError(t, err)
+	assert.True(t, created.CreatedAt.Equal(updated.CreatedAt))
+	assert.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+	user, err = GetUser(ctx, "alice", client)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), user.Rpm)
+	assert.Equal(t, int64(200), user.Tpm)
+	assert.Nil(t, user.Policy)
+	assert.False(t, user.IsEnabled())
+
+	// an update without the enabled flag keeps it.
+	_, err = UpdateUser(ctx, User{Name: "alice", Rpm: 30}, client)
+	require.NoError(t, err)
+	user, err = GetUser(ctx, "alice", client)
+	require.NoError(t, err)
+	assert.False(t, user.IsEnabled())
+
+	_, err = UpdateUser(ctx, User{Name: "bob", Rpm: 10}, client)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = GetUser(ctx, "bob", client)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = CreateUser(ctx, User{Name: "bob"}, client)
+	require.NoError(t, err)
+	users, err := ListUsers(ctx, client)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].Name)
+	assert.Equal(t, "bob", users[1].Name)
+
+	require.NoError(t, DeleteUser(ctx, "alice", client))
+	assert.ErrorIs(t, DeleteUser(ctx, "alice", client), ErrUserNotFound)
+	_, err = GetUser(ctx, "alice", client)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUserValidation(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestUserRedis(t)
+
+	_, err := CreateUser(ctx, User{}, client)
+	assert.Error(t, err)
+	_, err = CreateUser(ctx, User{Name: "alice", Rpm: -1}, client)
+	assert.Error(t, err)
+	_, err = CreateUser(ctx, User{Name: "alice", Policy: &UserPolicy{MaxTokens: -1}}, client)
+	assert.Error(t, err)
+	_, err = UpdateUser(ctx, User{Name: "alice", Tpm: -1}, client)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestLegacyUser(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestUserRedis(t)
+	require.NoError(t, server.Set("aibrix-users/alice", `{"name":"alice","rpm":10,"tpm":100,"tenant":"acme"}`))
+
+	user, err := GetUser(ctx, "alice", client)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), user.Rpm)
+	assert.Equal(t, "acme", user.Tenant)
+	assert.True(t, user.IsEnabled())
+
+	_, err = CreateUser(ctx, User{Name: "alice"}, client)
+	assert.ErrorIs(t, err, ErrUserAlreadyExists)
+	users, err := ListUsers(ctx, client)
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	// the update moves the user to its hash.
+	_, err = UpdateUser(ctx, User{Name: "alice", Rpm: 20, Tenant: "acme"}, client)
+	require.NoError(t, err)
+	assert.False(t, server.Exists("aibrix-users/alice"))
+	user, err = GetUser(ctx, "alice", client)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), user.Rpm)
+	assert.Equal(t, "acme", user.Tenant)
+
+	require.NoError(t, server.Set("aibrix-users/bob", `{"name":"bob","rpm":10}`))
+	require.NoError(t, DeleteUser(ctx, "bob", client))
+	assert.False(t, server.Exists("aibrix-users/bob"))
+}
+
+func TestConcurrentCreateUser(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestUserRedis(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := CreateUser(ctx, User{Name: "alice", Rpm: int64(i)}, client)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrUserAlreadyExists)
+	}
+	assert.Equal(t, 1, created)
+}
+
+func TestConcurrentUpdateUser(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestUserRedis(t)
+	created, err := CreateUser(ctx, User{Name: "alice", Rpm: 1, Tpm: 10}, client)
+	require.NoError(t, err)
+
+	// two admin processes write the limits of the user, the hash always holds the limits of a single write.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 1; j <= 20; j++ {
+				rpm := int64(i*1000 + j)
+				_, err := UpdateUser(ctx, User{Name: "alice", Rpm: rpm, Tpm: rpm * 10}, client)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	user, err := GetUser(ctx, "alice", client)
+	require.NoError(t, err)
+	assert.Equal(t, user.Rpm*10, user.Tpm)
+	assert.True(t, created.CreatedAt.Equal(user.CreatedAt))
+}
+
+func TestUserCache(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestUserRedis(t)
+	_, err := CreateUser(ctx, User{Name: "alice", Rpm: 10}, client)
+	require.NoError(t, err)
+
+	now := time.Now()
+	users := NewUserCache(client, 5*time.Second)
+	users.now = func() time.Time { return now }
+
+	user, err := users.Get(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), user.Rpm)
+	_, err = users.Get(ctx, "bob")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	// the cached users are served until they expire.
+	_, err = UpdateUser(ctx, User{Name: "alice", Rpm: 20}, client)
+	require.NoError(t, err)
+	_, err = CreateUser(ctx, User{Name: "bob"}, client)
+	require.NoError(t, err)
+	user, err = users.Get(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), user.Rpm)
+	_, err = users.Get(ctx, "bob")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	now = now.Add(5 * time.Second)
+	user, err = users.Get(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), user.Rpm)
+	_, err = users.Get(ctx, "bob")
+	assert.NoError(t, err)
+
+	_, err = UpdateUser(ctx, User{Name: "alice", Rpm: 30}, client)
+	require.NoError(t, err)
+	users.Invalidate("alice")
+	user, err = users.Get(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), user.Rpm)
+
+	// the errors of Redis are not cached.
+	server.SetError("unavailable")
+	_, err = users.Get(ctx, "carol")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrUserNotFound))
+	server.SetError("")
+	_, err = users.Get(ctx, "carol")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	assert.Equal(t, fmt.Sprintf("%v: carol", ErrUserNotFound), err.Error())
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/pkoukk/tiktoken-go"
 	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
@@ -29,10 +30,24 @@ import (
 // https://cookbook.openai.com/examples/how_to_count_tokens_with_tiktoken
 const encoding = "cl100k_base"
 
+var (
+	encoderOnce sync.Once
+	encoder     *tiktoken.Tiktoken
+	encoderErr  error
+)
+
+// getEncoder returns the encoder shared by the requests, building it costs a parse of its whole vocab.
+func getEncoder() (*tiktoken.Tiktoken, error) {
+	encoderOnce.Do(func() {
+		// if you don't want download dictionary at runtime, you can use offline loader
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+		encoder, encoderErr = tiktoken.GetEncoding(encoding)
+	})
+	return encoder, encoderErr
+}
+
 func TokenizeInputText(text string) ([]int, error) {
-	// if you don't want download dictionary at runtime, you can use offline loader
-	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
-	tke, err := tiktoken.GetEncoding(encoding)
+	tke, err := getEncoder()
 	if err != nil {
 		return nil, err
 	}
@@ -43,8 +58,7 @@ func TokenizeInputText(text string) ([]int, error) {
 }
 
 func DetokenizeText(tokenIds []int) (string, error) {
-	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
-	tke, err := tiktoken.GetEncoding(encoding)
+	tke, err := getEncoder()
 	if err != nil {
 		return "", fmt.Errorf("failed to get encoding: %v", err)
 	}
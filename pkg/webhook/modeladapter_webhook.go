@@ -20,10 +20,14 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
@@ -31,17 +35,35 @@ import (
 	"github.com/vllm-project/aibrix/pkg/utils"
 )
 
-type ModelAdapterWebhook struct{}
+// ModelAdapterServedNameField indexes the model adapters by the model name they are served under.
+const ModelAdapterServedNameField = "servedName"
+
+type ModelAdapterWebhook struct {
+	// Client looks up the model adapters claiming a served name, by the ModelAdapterServedNameField index.
+	Client client.Reader
+}
 
 // SetupBackendRuntimeWebhook will setup the manager to manage the webhooks
 func SetupBackendRuntimeWebhook(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &modelapi.ModelAdapter{}, ModelAdapterServedNameField,
+		func(obj client.Object) []string {
+			return []string{servedName(obj.(*modelapi.ModelAdapter))}
+		}); err != nil {
+		return err
+	}
+	w := &ModelAdapterWebhook{Client: mgr.GetClient()}
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(&modelapi.ModelAdapter{}).
-		WithDefaulter(&ModelAdapterWebhook{}).
-		WithValidator(&ModelAdapterWebhook{}).
+		WithDefaulter(w).
+		WithValidator(w).
 		Complete()
 }
 
+// servedName returns the model name the gateway and the engines serve the model adapter under.
+func servedName(adapter *modelapi.ModelAdapter) string {
+	return adapter.Name
+}
+
 //+kubebuilder:webhook:path=/mutate-model-aibrix-ai-v1alpha1-modeladapter,mutating=true,failurePolicy=fail,sideEffects=None,groups=model.aibrix.ai,resources=modeladapters,verbs=create;update,versions=v1alpha1,name=mmodeladapter.kb.io,admissionReviewVersions=v1
 
 var _ webhook.CustomDefaulter = &ModelAdapterWebhook{}
@@ -57,32 +79,98 @@ var _ webhook.CustomValidator = &ModelAdapterWebhook{}
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (w *ModelAdapterWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	adapter := obj.(*modelapi.ModelAdapter)
+
+	allErrs := validateModelAdapter(adapter)
+	allErrs = append(allErrs, w.validateServedNameUnique(ctx, adapter)...)
+	return nil, allErrs.ToAggregate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (w *ModelAdapterWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldAdapter, adapter := oldObj.(*modelapi.ModelAdapter), newObj.(*modelapi.ModelAdapter)
+	// the adapter is unloaded and its finalizer removed regardless of its spec.
+	if adapter.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	allErrs := validateModelAdapter(adapter)
+	if servedName(adapter) != servedName(oldAdapter) {
+		allErrs = append(allErrs, w.validateServedNameUnique(ctx, adapter)...)
+	}
+	return nil, allErrs.ToAggregate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (w *ModelAdapterWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validateModelAdapter(adapter *modelapi.ModelAdapter) field.ErrorList {
 	var allErrs field.ErrorList
 	specPath := field.NewPath("spec")
 
-	adapter := obj.(*modelapi.ModelAdapter)
+	// the served name is the name of the service of the model adapter and a label value of the pods loading it.
+	for _, msg := range validation.IsDNS1035Label(servedName(adapter)) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "name"), adapter.Name, msg))
+	}
 
-	if _, err := url.ParseRequestURI(adapter.Spec.ArtifactURL); err != nil {
-		allErrs = append(allErrs, field.Invalid(specPath.Child("artifactURL"), adapter.Spec.ArtifactURL, fmt.Sprintf("artifactURL is invalid: %v", err)))
+	if adapter.Spec.BaseModel != nil {
+		for _, msg := range validation.IsValidLabelValue(*adapter.Spec.BaseModel) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("baseModel"), *adapter.Spec.BaseModel, msg))
+		}
 	}
 
+	allErrs = append(allErrs, validateArtifactURL(specPath.Child("artifactURL"), adapter.Spec.ArtifactURL)...)
+
 	if adapter.Spec.Replicas != nil && *adapter.Spec.Replicas <= 0 {
 		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), adapter.Spec.Replicas, "replicas must be greater than 0"))
 	}
 
-	if err := utils.ValidateArtifactURL(adapter.Spec.ArtifactURL); err != nil {
-		allErrs = append(allErrs, field.NotSupported(specPath.Child("artifactURL"), adapter.Spec.ArtifactURL, utils.AllowedSchemas))
+	return allErrs
+}
+
+func validateArtifactURL(fldPath *field.Path, artifactURL string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	u, err := url.ParseRequestURI(artifactURL)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath, artifactURL, fmt.Sprintf("artifactURL is invalid: %v", err)))
 	}
 
-	return nil, allErrs.ToAggregate()
-}
+	if err := utils.ValidateArtifactURL(artifactURL); err != nil {
+		allErrs = append(allErrs, field.NotSupported(fldPath, artifactURL, utils.AllowedSchemas))
+	} else if u != nil && !strings.HasPrefix(artifactURL, "/") && u.Host == "" {
+		// the bucket or the repository of a remote artifact comes right after the scheme.
+		allErrs = append(allErrs, field.Invalid(fldPath, artifactURL, "artifactURL must name a bucket or a repository after its scheme"))
+	}
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (w *ModelAdapterWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
-	return nil, nil
+	return allErrs
 }
 
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
-func (w *ModelAdapterWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
-	return nil, nil
+// validateServedNameUnique rejects a model adapter served under the name of another one. The gateway routes the
+// model adapters of every namespace by their served name, so the name is unique in the cluster.
+func (w *ModelAdapterWebhook) validateServedNameUnique(ctx context.Context, adapter *modelapi.ModelAdapter) field.ErrorList {
+	if w.Client == nil {
+		return nil
+	}
+	namePath := field.NewPath("metadata", "name")
+
+	adapters := &modelapi.ModelAdapterList{}
+	if err := w.Client.List(ctx, adapters, client.MatchingFields{ModelAdapterServedNameField: servedName(adapter)}); err != nil {
+		klog.ErrorS(err, "Failed to list the model adapters by served name", "servedName", servedName(adapter))
+		return field.ErrorList{field.InternalError(namePath, fmt.Errorf("failed to list the model adapters: %w", err))}
+	}
+	for _, other := range adapters.Items {
+		// the api server rejects a model adapter created under the name of another one in its namespace.
+		if other.Namespace == adapter.Namespace && other.Name == adapter.Name {
+			continue
+		}
+		if other.DeletionTimestamp != nil {
+			continue
+		}
+		return field.ErrorList{field.Duplicate(namePath,
+			fmt.Sprintf("%s, already served by ModelAdapter %s/%s", servedName(adapter), other.Namespace, other.Name))}
+	}
+	return nil
 }
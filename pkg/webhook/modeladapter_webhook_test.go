@@ -0,0 +1,153 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	modelapi "github.com/vllm-project/aibrix/api/model/v1alpha1"
+)
+
+func newModelAdapter(namespace, name string) *modelapi.ModelAdapter {
+	return &modelapi.ModelAdapter{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: modelapi.ModelAdapterSpec{
+			BaseModel:   ptr.To("llama-2-7b"),
+			ArtifactURL: "huggingface://yard1/llama-2-7b-sql-lora-test",
+			PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"model.aibrix.ai/name": "llama-2-7b"}},
+		},
+	}
+}
+
+func newModelAdapterWebhook(t *testing.T, adapters ...*modelapi.ModelAdapter) *ModelAdapterWebhook {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, modelapi.AddToScheme(scheme))
+	objects := make([]client.Object, 0, len(adapters))
+	for _, adapter := range adapters {
+		objects = append(objects, adapter)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+		WithIndex(&modelapi.ModelAdapter{}, ModelAdapterServedNameField, func(obj client.Object) []string {
+			return []string{servedName(obj.(*modelapi.ModelAdapter))}
+		}).
+		Build()
+	return &ModelAdapterWebhook{Client: c}
+}
+
+func TestModelAdapterValidateCreate(t *testing.T) {
+	w := newModelAdapterWebhook(t, newModelAdapter("team-a", "sql-lora"), newModelAdapter("team-a", "chat-lora"))
+
+	tests := []struct {
+		name    string
+		adapter func() *modelapi.ModelAdapter
+		errMsg  string
+	}{
+		{
+			name:    "unique served name",
+			adapter: func() *modelapi.ModelAdapter { return newModelAdapter("team-a", "code-lora") },
+		},
+		{
+			name:    "served name claimed in another namespace",
+			adapter: func() *modelapi.ModelAdapter { return newModelAdapter("team-b", "chat-lora") },
+			errMsg:  "already served by ModelAdapter team-a/chat-lora",
+		},
+		{
+			name:    "name with dots",
+			adapter: func() *modelapi.ModelAdapter { return newModelAdapter("team-a", "sql.lora") },
+			errMsg:  "metadata.name: Invalid value",
+		},
+		{
+			name:    "name too long",
+			adapter: func() *modelapi.ModelAdapter { return newModelAdapter("team-a", strings.Repeat("a", 64)) },
+			errMsg:  "must be no more than 63 characters",
+		},
+		{
+			name: "invalid base model",
+			adapter: func() *modelapi.ModelAdapter {
+				adapter := newModelAdapter("team-a", "code-lora")
+				adapter.Spec.BaseModel = ptr.To("meta/llama-2-7b")
+				return adapter
+			},
+			errMsg: "spec.baseModel: Invalid value",
+		},
+		{
+			name: "unsupported scheme",
+			adapter: func() *modelapi.ModelAdapter {
+				adapter := newModelAdapter("team-a", "code-lora")
+				adapter.Spec.ArtifactURL = "ftp://bucket/lora"
+				return adapter
+			},
+			errMsg: "spec.artifactURL: Unsupported value",
+		},
+		{
+			name: "scheme without bucket",
+			adapter: func() *modelapi.ModelAdapter {
+				adapter := newModelAdapter("team-a", "code-lora")
+				adapter.Spec.ArtifactURL = "s3://"
+				return adapter
+			},
+			errMsg: "must name a bucket or a repository",
+		},
+		{
+			name: "local path",
+			adapter: func() *modelapi.ModelAdapter {
+				adapter := newModelAdapter("team-a", "code-lora")
+				adapter.Spec.ArtifactURL = "/models/yard1/llama-2-7b-sql-lora-test"
+				return adapter
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := w.ValidateCreate(context.TODO(), tt.adapter())
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestModelAdapterValidateUpdate(t *testing.T) {
+	existing := newModelAdapter("team-a", "sql-lora")
+	w := newModelAdapterWebhook(t, existing, newModelAdapter("team-b", "sql-lora"))
+
+	// the conflicts predating the webhook don't block the updates keeping the served name.
+	updated := existing.DeepCopy()
+	updated.Spec.ArtifactURL = "huggingface://yard1/llama-2-7b-sql-lora-v2"
+	_, err := w.ValidateUpdate(context.TODO(), existing, updated)
+	assert.NoError(t, err)
+
+	updated.Spec.ArtifactURL = "sql-lora-v2"
+	_, err = w.ValidateUpdate(context.TODO(), existing, updated)
+	assert.ErrorContains(t, err, "spec.artifactURL")
+
+	// the finalizer of an adapter being deleted is removed regardless of its spec.
+	updated.DeletionTimestamp = ptr.To(metav1.Now())
+	_, err = w.ValidateUpdate(context.TODO(), existing, updated)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	autoscalingapi "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler/scaler"
+)
+
+const (
+	// managedByLabel marks the PodAutoscalers defaulted by the webhook as managed by aibrix.
+	managedByLabel      = "app.kubernetes.io/managed-by"
+	managedByLabelValue = "aibrix"
+
+	// modelIdentifier labels the PodAutoscaler of a model, by which the model controller finds it.
+	modelIdentifier = "model.aibrix.ai/name"
+)
+
+// metricPortNames are the names of the container ports the metric port of a pod metric source is derived from, in
+// the order of preference.
+var metricPortNames = []string{"metrics", "http"}
+
+type PodAutoscalerWebhook struct {
+	// Client reads the scale target the model label and the metric port of the pod metric sources are derived from.
+	Client client.Reader
+}
+
+// SetupPodAutoscalerWebhook will setup the manager to manage the webhooks
+func SetupPodAutoscalerWebhook(mgr ctrl.Manager) error {
+	// the scale targets are read uncached, so that no informer is started for each kind the PodAutoscalers scale.
+	w := &PodAutoscalerWebhook{Client: mgr.GetAPIReader()}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&autoscalingapi.PodAutoscaler{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-autoscaling-aibrix-ai-v1alpha1-podautoscaler,mutating=true,failurePolicy=fail,sideEffects=None,groups=autoscaling.aibrix.ai,resources=podautoscalers,verbs=create;update,versions=v1alpha1,name=mpodautoscaler.kb.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;replicasets,verbs=get
+
+var _ webhook.CustomDefaulter = &PodAutoscalerWebhook{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type. It fills the fields the
+// PodAutoscaler does not set on create, the values it sets are kept and the fields cleared later are not filled again.
+// On update, it only lowers the defaulted MinReplicas of a PodAutoscaler opting in to scale-to-zero.
+func (w *PodAutoscalerWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	pa := obj.(*autoscalingapi.PodAutoscaler)
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Operation != admissionv1.Create {
+		if req.Operation == admissionv1.Update {
+			defaultScaleToZeroOnUpdate(req, pa)
+		}
+		return nil
+	}
+	// the MinReplicas of a PodAutoscaler importing an HPA is left to the import, which keeps the values set.
+	if pa.Annotations[scalingcontext.ImportFromHPALabel] != "" {
+		scaler.SetTuningDefaults(pa)
+	} else {
+		scaler.SetDefaults(pa)
+	}
+	if _, ok := pa.Labels[managedByLabel]; !ok {
+		if pa.Labels == nil {
+			pa.Labels = map[string]string{}
+		}
+		pa.Labels[managedByLabel] = managedByLabelValue
+	}
+	if pa.Labels[modelIdentifier] == "" || needsMetricPort(pa) {
+		target, err := w.scaleTarget(ctx, pa)
+		if err != nil {
+			klog.ErrorS(err, "Failed to read the scale target", "PodAutoscaler", klog.KObj(pa))
+			return nil
+		}
+		defaultModelLabel(pa, target)
+		defaultMetricPorts(pa, target)
+	}
+	return nil
+}
+
+// defaultScaleToZeroOnUpdate lowers the MinReplicas of a PodAutoscaler opting in to scale-to-zero from the default
+// to 0, so that its target can scale to zero. A MinReplicas changed by the same update is kept.
+func defaultScaleToZeroOnUpdate(req admission.Request, pa *autoscalingapi.PodAutoscaler) {
+	if len(req.OldObject.Raw) == 0 || !scaler.IsScaleToZeroEnabled(pa) {
+		return
+	}
+	old := &autoscalingapi.PodAutoscaler{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		klog.ErrorS(err, "Failed to decode the PodAutoscaler before the update", "PodAutoscaler", klog.KObj(pa))
+		return
+	}
+	if scaler.IsScaleToZeroEnabled(old) || old.Spec.MinReplicas == nil || pa.Spec.MinReplicas == nil {
+		return
+	}
+	if *old.Spec.MinReplicas == scaler.DefaultMinReplicas && *pa.Spec.MinReplicas == scaler.DefaultMinReplicas {
+		pa.Spec.MinReplicas = ptr.To[int32](0)
+	}
+}
+
+// scaleTarget reads the scale target of the PodAutoscaler.
+func (w *PodAutoscalerWebhook) scaleTarget(ctx context.Context, pa *autoscalingapi.PodAutoscaler) (*unstructured.Unstructured, error) {
+	ref := pa.Spec.ScaleTargetRef
+	target := &unstructured.Unstructured{}
+	target.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+	if err := w.Client.Get(ctx, types.NamespacedName{Namespace: pa.Namespace, Name: ref.Name}, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// defaultModelLabel copies the model.aibrix.ai/name label of the scale target to a PodAutoscaler which does not set
+// it, so that the model controller finds the PodAutoscaler of a model by its label.
+func defaultModelLabel(pa *autoscalingapi.PodAutoscaler, target *unstructured.Unstructured) {
+	modelName := target.GetLabels()[modelIdentifier]
+	if modelName == "" || pa.Labels[modelIdentifier] != "" {
+		return
+	}
+	pa.Labels[modelIdentifier] = modelName
+}
+
+// needsMetricPort reports whether a pod metric source of the PodAutoscaler does not set its port.
+func needsMetricPort(pa *autoscalingapi.PodAutoscaler) bool {
+	for _, source := range pa.Spec.MetricsSources {
+		if source.MetricSourceType == autoscalingapi.POD && source.Port == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultMetricPorts derives the port of the pod metric sources which do not set it from the container ports of the
+// scale target. A target whose port is ambiguous leaves the port unset.
+func defaultMetricPorts(pa *autoscalingapi.PodAutoscaler, target *unstructured.Unstructured) {
+	if !needsMetricPort(pa) {
+		return
+	}
+	port, err := targetMetricPort(pa.Spec.ScaleTargetRef, target)
+	if err != nil {
+		klog.ErrorS(err, "Failed to derive the metric port from the scale target", "PodAutoscaler", klog.KObj(pa))
+		return
+	}
+	for i := range pa.Spec.MetricsSources {
+		source := &pa.Spec.MetricsSources[i]
+		if source.MetricSourceType == autoscalingapi.POD && source.Port == "" {
+			source.Port = port
+		}
+	}
+}
+
+// targetMetricPort returns the container port of the pod template of the scale target named by metricPortNames,
+// or its only container port.
+func targetMetricPort(ref corev1.ObjectReference, target *unstructured.Unstructured) (string, error) {
+	rawTemplate, found, err := unstructured.NestedMap(target.Object, "spec", "template")
+	if err != nil || !found {
+		return "", fmt.Errorf("%s %s has no pod template", ref.Kind, ref.Name)
+	}
+	var template corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawTemplate, &template); err != nil {
+		return "", err
+	}
+
+	var ports []corev1.ContainerPort
+	for _, container := range template.Spec.Containers {
+		ports = append(ports, container.Ports...)
+	}
+	for _, name := range metricPortNames {
+		for _, port := range ports {
+			if port.Name == name {
+				return strconv.Itoa(int(port.ContainerPort)), nil
+			}
+		}
+	}
+	if len(ports) == 1 {
+		return strconv.Itoa(int(ports[0].ContainerPort)), nil
+	}
+	return "", fmt.Errorf("%s %s declares %d container ports, none named %s", ref.Kind, ref.Name, len(ports),
+		strings.Join(metricPortNames, " or "))
+}
+
+//+kubebuilder:webhook:path=/validate-autoscaling-aibrix-ai-v1alpha1-podautoscaler,mutating=false,failurePolicy=fail,sideEffects=None,groups=autoscaling.aibrix.ai,resources=podautoscalers,verbs=create;update,versions=v1alpha1,name=vpodautoscaler.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &PodAutoscalerWebhook{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (w *PodAutoscalerWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	pa := obj.(*autoscalingapi.PodAutoscaler)
+	return scaleToZeroWarnings(pa), validatePodAutoscaler(pa).ToAggregate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (w *PodAutoscalerWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	pa := newObj.(*autoscalingapi.PodAutoscaler)
+	return scaleToZeroWarnings(pa), validatePodAutoscaler(pa).ToAggregate()
+}
+
+// scaleToZeroWarnings warns about a PodAutoscaler opting in to scale-to-zero whose explicit MinReplicas keeps its
+// target from scaling to zero.
+func scaleToZeroWarnings(pa *autoscalingapi.PodAutoscaler) admission.Warnings {
+	if !scaler.IsScaleToZeroEnabled(pa) || pa.Spec.MinReplicas == nil || *pa.Spec.MinReplicas == 0 {
+		return nil
+	}
+	return admission.Warnings{fmt.Sprintf("annotation %s enables scale-to-zero, but spec.minReplicas %d keeps the target "+
+		"from scaling to zero, unset it or set it to 0", scalingcontext.ScaleToZeroLabel, *pa.Spec.MinReplicas)}
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (w *PodAutoscalerWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validatePodAutoscaler(pa *autoscalingapi.PodAutoscaler) field.ErrorList {
+	var allErrs field.ErrorList
+	annotationsPath := field.NewPath("metadata", "annotations")
+
+	if value, ok := pa.Annotations[scalingcontext.ActuationModeLabel]; ok {
+		switch autoscalingapi.ActuationMode(value) {
+		case autoscalingapi.ActuationModeScaleSubresource:
+		case autoscalingapi.ActuationModeDirectPatch:
+			// the target kind is only known to support spec.replicas for the built-in workloads
+			ref := pa.Spec.ScaleTargetRef
+			if gv, err := schema.ParseGroupVersion(ref.APIVersion); err != nil || gv.Group != "apps" || !directPatchKinds[ref.Kind] {
+				allErrs = append(allErrs, field.Invalid(annotationsPath.Key(scalingcontext.ActuationModeLabel), value,
+					fmt.Sprintf("%s is not supported for %s %s, the target must be an apps/v1 Deployment, StatefulSet or ReplicaSet",
+						value, ref.APIVersion, ref.Kind)))
+			}
+		case autoscalingapi.ActuationModeExternal:
+			if pa.Spec.ScalingStrategy == autoscalingapi.HPA {
+				allErrs = append(allErrs, field.Invalid(annotationsPath.Key(scalingcontext.ActuationModeLabel), value,
+					fmt.Sprintf("%s is not supported for the %s strategy, the HPA scales the target itself", value, autoscalingapi.HPA)))
+			}
+			allErrs = append(allErrs, validateRecommendationSink(pa, annotationsPath)...)
+		default:
+			allErrs = append(allErrs, field.NotSupported(annotationsPath.Key(scalingcontext.ActuationModeLabel), value,
+				[]string{string(autoscalingapi.ActuationModeScaleSubresource), string(autoscalingapi.ActuationModeDirectPatch),
+					string(autoscalingapi.ActuationModeExternal)}))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRecommendationSink validates the sink a PodAutoscaler in the External actuation mode publishes to.
+func validateRecommendationSink(pa *autoscalingapi.PodAutoscaler, annotationsPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	value, ok := pa.Annotations[scalingcontext.RecommendationSinkLabel]
+	if !ok {
+		return nil
+	}
+	switch autoscalingapi.RecommendationSink(value) {
+	case autoscalingapi.RecommendationSinkAnnotation, autoscalingapi.RecommendationSinkRedisStream:
+	case autoscalingapi.RecommendationSinkWebhook:
+		if rawURL := pa.Annotations[scalingcontext.RecommendationWebhookURLLabel]; rawURL == "" {
+			allErrs = append(allErrs, field.Required(annotationsPath.Key(scalingcontext.RecommendationWebhookURLLabel),
+				fmt.Sprintf("required by the %s sink", value)))
+		} else if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(annotationsPath.Key(scalingcontext.RecommendationWebhookURLLabel), rawURL,
+				"must be an absolute http or https URL"))
+		}
+		if pa.Annotations[scalingcontext.RecommendationWebhookSecretLabel] == "" {
+			allErrs = append(allErrs, field.Required(annotationsPath.Key(scalingcontext.RecommendationWebhookSecretLabel),
+				fmt.Sprintf("the %s sink signs the recommendations with the hmac-key of the secret", value)))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(annotationsPath.Key(scalingcontext.RecommendationSinkLabel), value,
+			[]string{string(autoscalingapi.RecommendationSinkAnnotation), string(autoscalingapi.RecommendationSinkWebhook),
+				string(autoscalingapi.RecommendationSinkRedisStream)}))
+	}
+	return allErrs
+}
+
+var directPatchKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"ReplicaSet":  true,
+}
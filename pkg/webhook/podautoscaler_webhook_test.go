@@ -0,0 +1,275 @@
+/*
+Copyright 2025 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	autoscalingapi "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	scalingcontext "github.com/vllm-project/aibrix/pkg/controller/podautoscaler/common"
+)
+
+func newPodAutoscaler(strategy autoscalingapi.ScalingStrategyType) *autoscalingapi.PodAutoscaler {
+	return &autoscalingapi.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "llama-2-7b", Namespace: "default"},
+		Spec: autoscalingapi.PodAutoscalerSpec{
+			ScaleTargetRef:  corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "llama-2-7b"},
+			MaxReplicas:     10,
+			ScalingStrategy: strategy,
+			MetricsSources: []autoscalingapi.MetricSource{{
+				MetricSourceType: autoscalingapi.POD,
+				ProtocolType:     autoscalingapi.HTTP,
+				Path:             "/metrics",
+				TargetMetric:     "gpu_cache_usage_perc",
+				TargetValue:      "50",
+			}},
+		},
+	}
+}
+
+func newTargetDeployment(name string, ports ...corev1.ContainerPort) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "vllm-openai", Image: "vllm/vllm-openai", Ports: ports},
+			}}},
+		},
+	}
+}
+
+func newPodAutoscalerWebhook(t *testing.T, objects ...client.Object) *PodAutoscalerWebhook {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, appsv1.AddToScheme(scheme))
+	return &PodAutoscalerWebhook{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()}
+}
+
+func admissionContext(operation admissionv1.Operation) context.Context {
+	return admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{Operation: operation},
+	})
+}
+
+func updateContext(t *testing.T, old *autoscalingapi.PodAutoscaler) context.Context {
+	raw, err := json.Marshal(old)
+	assert.NoError(t, err)
+	return admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			OldObject: runtime.RawExtension{Raw: raw},
+		},
+	})
+}
+
+func TestPodAutoscalerDefaultMinimal(t *testing.T) {
+	w := newPodAutoscalerWebhook(t, newTargetDeployment("llama-2-7b",
+		corev1.ContainerPort{Name: "http", ContainerPort: 8000}))
+
+	pa := newPodAutoscaler(autoscalingapi.KPA)
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), pa))
+
+	expected := newPodAutoscaler(autoscalingapi.KPA)
+	expected.Labels = map[string]string{"app.kubernetes.io/managed-by": "aibrix"}
+	expected.Annotations = map[string]string{
+		"kpa.autoscaling.aibrix.ai/stable-window":   "60s",
+		"kpa.autoscaling.aibrix.ai/panic-threshold": "2.0",
+	}
+	expected.Spec.MinReplicas = ptr.To[int32](1)
+	expected.Spec.MetricsSources[0].Port = "8000"
+	assert.Equal(t, expected, pa)
+
+	// the defaulted minReplicas is persisted with the object.
+	raw, err := json.Marshal(pa)
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), `"minReplicas":1`)
+	roundTripped := &autoscalingapi.PodAutoscaler{}
+	assert.NoError(t, json.Unmarshal(raw, roundTripped))
+	assert.Equal(t, expected, roundTripped)
+
+	apa := newPodAutoscaler(autoscalingapi.APA)
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), apa))
+	assert.Equal(t, map[string]string{
+		"apa.autoscaling.aibrix.ai/up-fluctuation-tolerance":   "0.1",
+		"apa.autoscaling.aibrix.ai/down-fluctuation-tolerance": "0.2",
+		"apa.autoscaling.aibrix.ai/window":                     "60s",
+	}, apa.Annotations)
+	assert.Equal(t, ptr.To[int32](1), apa.Spec.MinReplicas)
+
+	scaleToZero := newPodAutoscaler(autoscalingapi.KPA)
+	scaleToZero.Annotations = map[string]string{scalingcontext.ScaleToZeroLabel: "true"}
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), scaleToZero))
+	assert.Equal(t, ptr.To[int32](0), scaleToZero.Spec.MinReplicas)
+}
+
+func TestPodAutoscalerDefaultScaleToZeroAfterCreate(t *testing.T) {
+	w := newPodAutoscalerWebhook(t, newTargetDeployment("llama-2-7b",
+		corev1.ContainerPort{Name: "http", ContainerPort: 8000}))
+
+	created := newPodAutoscaler(autoscalingapi.KPA)
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), created))
+	assert.Equal(t, ptr.To[int32](1), created.Spec.MinReplicas)
+
+	// the annotation added after the creation lowers the defaulted MinReplicas so the target can scale to zero.
+	pa := created.DeepCopy()
+	pa.Annotations[scalingcontext.ScaleToZeroLabel] = "true"
+	assert.NoError(t, w.Default(updateContext(t, created), pa))
+	assert.Equal(t, ptr.To[int32](0), pa.Spec.MinReplicas)
+	warnings, err := w.ValidateUpdate(updateContext(t, created), created, pa)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	// a MinReplicas changed by the same update is kept, and keeping the target from scaling to zero is warned about.
+	pa = created.DeepCopy()
+	pa.Annotations[scalingcontext.ScaleToZeroLabel] = "true"
+	pa.Spec.MinReplicas = ptr.To[int32](2)
+	assert.NoError(t, w.Default(updateContext(t, created), pa))
+	assert.Equal(t, ptr.To[int32](2), pa.Spec.MinReplicas)
+	warnings, err = w.ValidateUpdate(updateContext(t, created), created, pa)
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+
+	// a MinReplicas other than the default is not lowered.
+	scaled := created.DeepCopy()
+	scaled.Spec.MinReplicas = ptr.To[int32](3)
+	pa = scaled.DeepCopy()
+	pa.Annotations[scalingcontext.ScaleToZeroLabel] = "true"
+	assert.NoError(t, w.Default(updateContext(t, scaled), pa))
+	assert.Equal(t, ptr.To[int32](3), pa.Spec.MinReplicas)
+}
+
+func TestPodAutoscalerDefaultKeepsValues(t *testing.T) {
+	w := newPodAutoscalerWebhook(t, newTargetDeployment("llama-2-7b",
+		corev1.ContainerPort{Name: "http", ContainerPort: 8000}))
+
+	pa := newPodAutoscaler(autoscalingapi.KPA)
+	pa.Labels = map[string]string{"app.kubernetes.io/managed-by": "helm"}
+	pa.Annotations = map[string]string{"kpa.autoscaling.aibrix.ai/stable-window": "120s"}
+	pa.Spec.MinReplicas = ptr.To[int32](0)
+	pa.Spec.MetricsSources[0].Port = "9090"
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), pa))
+
+	assert.Equal(t, "helm", pa.Labels["app.kubernetes.io/managed-by"])
+	assert.Equal(t, "120s", pa.Annotations["kpa.autoscaling.aibrix.ai/stable-window"])
+	assert.Equal(t, "2.0", pa.Annotations["kpa.autoscaling.aibrix.ai/panic-threshold"])
+	assert.Equal(t, ptr.To[int32](0), pa.Spec.MinReplicas)
+	assert.Equal(t, "9090", pa.Spec.MetricsSources[0].Port)
+
+	// the MinReplicas of a PodAutoscaler importing an HPA is left to the import.
+	importing := newPodAutoscaler(autoscalingapi.KPA)
+	importing.Annotations = map[string]string{scalingcontext.ImportFromHPALabel: "llama-2-7b"}
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), importing))
+	assert.Nil(t, importing.Spec.MinReplicas)
+	assert.Equal(t, "60s", importing.Annotations["kpa.autoscaling.aibrix.ai/stable-window"])
+}
+
+func TestPodAutoscalerDefaultNotOnUpdate(t *testing.T) {
+	w := newPodAutoscalerWebhook(t)
+
+	pa := newPodAutoscaler(autoscalingapi.KPA)
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Update), pa))
+	assert.Equal(t, newPodAutoscaler(autoscalingapi.KPA), pa)
+
+	// the fields cleared by an update are not filled again.
+	assert.NoError(t, w.Default(updateContext(t, newPodAutoscaler(autoscalingapi.KPA)), pa))
+	assert.Equal(t, newPodAutoscaler(autoscalingapi.KPA), pa)
+}
+
+func TestPodAutoscalerDefaultModelLabel(t *testing.T) {
+	target := newTargetDeployment("llama-2-7b", corev1.ContainerPort{Name: "http", ContainerPort: 8000})
+	target.Labels = map[string]string{"model.aibrix.ai/name": "llama-2-7b"}
+	w := newPodAutoscalerWebhook(t, target)
+
+	pa := newPodAutoscaler(autoscalingapi.KPA)
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), pa))
+	assert.Equal(t, "llama-2-7b", pa.Labels["model.aibrix.ai/name"])
+
+	// the label set by the PodAutoscaler is kept.
+	labeled := newPodAutoscaler(autoscalingapi.KPA)
+	labeled.Labels = map[string]string{"model.aibrix.ai/name": "llama-2-7b-chat"}
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), labeled))
+	assert.Equal(t, "llama-2-7b-chat", labeled.Labels["model.aibrix.ai/name"])
+
+	// a PodAutoscaler whose target is not found is left unlabeled.
+	missing := newPodAutoscaler(autoscalingapi.KPA)
+	missing.Spec.ScaleTargetRef.Name = "mistral-7b"
+	assert.NoError(t, w.Default(admissionContext(admissionv1.Create), missing))
+	assert.NotContains(t, missing.Labels, "model.aibrix.ai/name")
+}
+
+func TestPodAutoscalerDefaultMetricPort(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   *appsv1.Deployment
+		source   autoscalingapi.MetricSourceType
+		expected string
+	}{
+		{
+			name: "metrics port preferred",
+			target: newTargetDeployment("llama-2-7b",
+				corev1.ContainerPort{Name: "http", ContainerPort: 8000},
+				corev1.ContainerPort{Name: "metrics", ContainerPort: 8080}),
+			source:   autoscalingapi.POD,
+			expected: "8080",
+		},
+		{
+			name:     "only port",
+			target:   newTargetDeployment("llama-2-7b", corev1.ContainerPort{ContainerPort: 8000}),
+			source:   autoscalingapi.POD,
+			expected: "8000",
+		},
+		{
+			name: "ambiguous ports",
+			target: newTargetDeployment("llama-2-7b",
+				corev1.ContainerPort{Name: "grpc", ContainerPort: 9000},
+				corev1.ContainerPort{Name: "admin", ContainerPort: 9001}),
+			source: autoscalingapi.POD,
+		},
+		{
+			name:   "target not found",
+			target: newTargetDeployment("mistral-7b", corev1.ContainerPort{Name: "http", ContainerPort: 8000}),
+			source: autoscalingapi.POD,
+		},
+		{
+			name:   "domain source",
+			target: newTargetDeployment("llama-2-7b", corev1.ContainerPort{Name: "http", ContainerPort: 8000}),
+			source: autoscalingapi.DOMAIN,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newPodAutoscalerWebhook(t, tt.target)
+			pa := newPodAutoscaler(autoscalingapi.KPA)
+			pa.Spec.MetricsSources[0].MetricSourceType = tt.source
+			assert.NoError(t, w.Default(admissionContext(admissionv1.Create), pa))
+			assert.Equal(t, tt.expected, pa.Spec.MetricsSources[0].Port)
+		})
+	}
+}
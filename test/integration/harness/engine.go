@@ -0,0 +1,233 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vllm-project/aibrix/pkg/controller/modeladapter"
+)
+
+// maxEngineHosts bounds the loopback addresses tried for the engines, 127.0.0.2 to 127.0.39.251.
+const maxEngineHosts = 10000
+
+// engine is the fake inference engine of a pod. It serves the metrics set by the scenario and the models of the pod,
+// and loads and unloads the lora adapters like vLLM.
+type engine struct {
+	baseModel string
+	ip        string
+	server    *http.Server
+
+	mu       sync.Mutex
+	metrics  map[string]float64
+	adapters map[string]string
+}
+
+func (e *engine) setMetric(name string, value float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metrics[name] = value
+}
+
+// serves returns whether the model is the base model of the engine or one of its loaded adapters.
+func (e *engine) serves(model string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.adapters[model]
+	return ok || model == e.baseModel
+}
+
+func (e *engine) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", e.serveMetrics)
+	mux.HandleFunc("GET "+modeladapter.ModelListPath, e.serveModels)
+	mux.HandleFunc("POST "+modeladapter.LoadLoraAdapterPath, e.loadAdapter)
+	mux.HandleFunc("POST "+modeladapter.UnloadLoraAdapterPath, e.unloadAdapter)
+	return mux
+}
+
+func (e *engine) serveMetrics(w http.ResponseWriter, _ *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make([]string, 0, len(e.metrics))
+	for name := range e.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s %g\n", name, e.metrics[name])
+	}
+}
+
+func (e *engine) serveModels(w http.ResponseWriter, _ *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	models := []map[string]string{{"id": e.baseModel, "object": "model"}}
+	for name := range e.adapters {
+		models = append(models, map[string]string{"id": name, "object": "model", "parent": e.baseModel})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": models})
+}
+
+type loraRequest struct {
+	LoraName string `json:"lora_name"`
+	LoraPath string `json:"lora_path"`
+}
+
+func (e *engine) loadAdapter(w http.ResponseWriter, r *http.Request) {
+	var req loraRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LoraName == "" {
+		http.Error(w, "invalid lora request", http.StatusBadRequest)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.adapters[req.LoraName]; ok {
+		http.Error(w, fmt.Sprintf("The lora adapter '%s' has already been loaded.", req.LoraName), http.StatusBadRequest)
+		return
+	}
+	e.adapters[req.LoraName] = req.LoraPath
+	fmt.Fprintf(w, "Success: LoRA adapter '%s' added successfully.", req.LoraName)
+}
+
+func (e *engine) unloadAdapter(w http.ResponseWriter, r *http.Request) {
+	var req loraRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.LoraName == "" {
+		http.Error(w, "invalid lora request", http.StatusBadRequest)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.adapters[req.LoraName]; !ok {
+		http.Error(w, fmt.Sprintf("The lora adapter '%s' cannot be found.", req.LoraName), http.StatusNotFound)
+		return
+	}
+	delete(e.adapters, req.LoraName)
+	fmt.Fprintf(w, "Success: LoRA adapter '%s' removed successfully.", req.LoraName)
+}
+
+// engines are the fake inference engines of the pods, by namespace/name. The controllers reach an engine on the
+// inference engine port of the pod IP, so every engine listens on a loopback address of its own.
+type engines struct {
+	mu    sync.Mutex
+	next  int
+	byPod map[string]*engine
+	// modelMetrics are the metrics set on all the pods of a model, by namespace/model, the engines of the pods
+	// started later report them too.
+	modelMetrics map[string]map[string]float64
+	// errs are the errors the engines stopped on, reported by stopAll.
+	errs []error
+}
+
+func newEngines() *engines {
+	return &engines{byPod: map[string]*engine{}, modelMetrics: map[string]map[string]float64{}}
+}
+
+// setModelMetric sets the metric on the engines of the model, running and to come.
+func (e *engines) setModelMetric(namespace, model, name string, value float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := namespace + "/" + model
+	if e.modelMetrics[key] == nil {
+		e.modelMetrics[key] = map[string]float64{}
+	}
+	e.modelMetrics[key][name] = value
+	for pod, eng := range e.byPod {
+		if ns, _, _ := strings.Cut(pod, "/"); ns == namespace && eng.baseModel == model {
+			eng.setMetric(name, value)
+		}
+	}
+}
+
+// start starts the engine of the pod serving the base model, and returns it.
+func (e *engines) start(pod, baseModel string) (*engine, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if eng, ok := e.byPod[pod]; ok {
+		return eng, nil
+	}
+	for ; e.next < maxEngineHosts; e.next++ {
+		ip := fmt.Sprintf("127.0.%d.%d", e.next/250, e.next%250+2)
+		listener, err := net.Listen("tcp", net.JoinHostPort(ip, modeladapter.DefaultInferenceEnginePort))
+		if err != nil {
+			continue
+		}
+		e.next++
+		eng := &engine{baseModel: baseModel, ip: ip, metrics: map[string]float64{}, adapters: map[string]string{}}
+		namespace, _, _ := strings.Cut(pod, "/")
+		for name, value := range e.modelMetrics[namespace+"/"+baseModel] {
+			eng.metrics[name] = value
+		}
+		eng.server = &http.Server{Handler: eng.handler()}
+		go func() {
+			if err := eng.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				e.mu.Lock()
+				e.errs = append(e.errs, fmt.Errorf("engine of pod %s stopped: %w", pod, err))
+				e.mu.Unlock()
+			}
+		}()
+		e.byPod[pod] = eng
+		return eng, nil
+	}
+	return nil, fmt.Errorf("no loopback address left to serve the engine of pod %s on port %s", pod, modeladapter.DefaultInferenceEnginePort)
+}
+
+func (e *engines) get(pod string) (*engine, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	eng, ok := e.byPod[pod]
+	return eng, ok
+}
+
+// retain stops the engines of the pods of the namespace which are not in pods.
+func (e *engines) retain(namespace string, pods map[string]bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, eng := range e.byPod {
+		if ns, _, _ := strings.Cut(key, "/"); ns == namespace && !pods[key] {
+			_ = eng.server.Shutdown(context.Background())
+			delete(e.byPod, key)
+		}
+	}
+}
+
+func (e *engines) stopAll() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, eng := range e.byPod {
+		_ = eng.server.Shutdown(context.Background())
+		delete(e.byPod, key)
+	}
+	return errors.Join(e.errs...)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package harness runs the PodAutoscaler and ModelAdapter controllers and the gateway cache together against an
+// envtest API server, so that the scenarios crossing the components can be scripted with a few calls.
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	testingclock "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	orchestrationv1alpha1 "github.com/vllm-project/aibrix/api/orchestration/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/cache"
+	"github.com/vllm-project/aibrix/pkg/config"
+	"github.com/vllm-project/aibrix/pkg/controller/modeladapter"
+	"github.com/vllm-project/aibrix/pkg/controller/podautoscaler"
+)
+
+const (
+	// syncPeriod is how often the PodAutoscalers re-evaluate their metrics, AdvanceClock lets a few of them run for
+	// every step of the clock.
+	syncPeriod = 50 * time.Millisecond
+	// clockStep is the largest step of the fake clock, finer than the scaling windows the scenarios cross.
+	clockStep = time.Second
+	// unloadGracePeriod is how long the deletion of a ModelAdapter waits for its pods to confirm the unloading.
+	unloadGracePeriod = 2 * time.Second
+)
+
+// Harness is the API server, the controllers and the gateway cache shared by the scenarios of a suite. The pods of the
+// model Deployments are simulated, each with a fake inference engine listening on a loopback address of its own.
+type Harness struct {
+	Client client.Client
+
+	env     *envtest.Environment
+	clock   *testingclock.FakeClock
+	engines *engines
+	cache   *cache.Cache
+	cancel  context.CancelFunc
+	stopCh  chan struct{}
+	done    chan error
+}
+
+// Start starts the API server with the CRDs of the repository at root, the gateway cache and the manager running
+// the controllers.
+func Start(root string) (*Harness, error) {
+	h := &Harness{
+		env: &envtest.Environment{
+			CRDDirectoryPaths:     []string{filepath.Join(root, "config", "crd", "bases")},
+			ErrorIfCRDPathMissing: true,
+			BinaryAssetsDirectory: filepath.Join(root, "bin", "k8s",
+				fmt.Sprintf("1.30.0-%s-%s", runtime.GOOS, runtime.GOARCH)),
+		},
+		clock:   testingclock.NewFakeClock(time.Now()),
+		engines: newEngines(),
+		stopCh:  make(chan struct{}),
+		done:    make(chan error, 1),
+	}
+	cfg, err := h.env.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the API server: %w", err)
+	}
+
+	scheme := k8sruntime.NewScheme()
+	for _, addToScheme := range []func(*k8sruntime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		autoscalingv1alpha1.AddToScheme,
+		modelv1alpha1.AddToScheme,
+		orchestrationv1alpha1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			return nil, errors.Join(err, h.env.Stop())
+		}
+	}
+
+	// the ModelAdapter controller schedules the adapters with the gateway cache, which must exist first.
+	h.cache = cache.NewCache(cfg, h.stopCh, nil)
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+	})
+	if err != nil {
+		return nil, errors.Join(err, h.Stop())
+	}
+	runtimeConfig := config.RuntimeConfig{
+		PodAutoscalerSyncPeriod:       syncPeriod,
+		ModelAdapterUnloadGracePeriod: unloadGracePeriod,
+		Clock:                         h.clock,
+	}
+	if err := podautoscaler.Add(mgr, runtimeConfig); err != nil {
+		return nil, errors.Join(err, h.Stop())
+	}
+	if err := modeladapter.Add(mgr, runtimeConfig); err != nil {
+		return nil, errors.Join(err, h.Stop())
+	}
+	if err := addDeploymentSimulator(mgr, h.engines); err != nil {
+		return nil, errors.Join(err, h.Stop())
+	}
+	h.Client = mgr.GetClient()
+
+	var ctx context.Context
+	ctx, h.cancel = context.WithCancel(context.Background())
+	go func() {
+		h.done <- mgr.Start(ctx)
+	}()
+	if !mgr.GetCache().WaitForCacheSync(ctx) {
+		return nil, errors.Join(errors.New("failed to sync the cache of the manager"), h.Stop())
+	}
+	return h, nil
+}
+
+// Stop stops the manager, the gateway cache, the engines and the API server.
+func (h *Harness) Stop() error {
+	var errs []error
+	if h.cancel != nil {
+		h.cancel()
+		errs = append(errs, <-h.done)
+		h.cancel = nil
+	}
+	select {
+	case <-h.stopCh:
+	default:
+		close(h.stopCh)
+	}
+	errs = append(errs, h.engines.stopAll())
+	errs = append(errs, h.env.Stop())
+	return errors.Join(errs...)
+}
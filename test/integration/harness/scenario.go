@@ -0,0 +1,270 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package harness
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	autoscalingv1alpha1 "github.com/vllm-project/aibrix/api/autoscaling/v1alpha1"
+	modelv1alpha1 "github.com/vllm-project/aibrix/api/model/v1alpha1"
+	"github.com/vllm-project/aibrix/pkg/controller/modeladapter"
+	"github.com/vllm-project/aibrix/pkg/utils"
+)
+
+const (
+	// expectTimeout bounds the wait of an expectation, the controllers converge within a few reconciles.
+	expectTimeout = 30 * time.Second
+	expectPolling = 100 * time.Millisecond
+)
+
+// Model is a model Deployment created by a scenario.
+type Model struct {
+	// Replicas is the initial number of pods of the model.
+	Replicas int32
+	// Autoscaling creates a KPA PodAutoscaler of the model, none if nil.
+	Autoscaling *Autoscaling
+}
+
+// Autoscaling is the KPA PodAutoscaler of a model, scaling on a metric of the engines of its pods.
+type Autoscaling struct {
+	MinReplicas int32
+	MaxReplicas int32
+	Metric      string
+	TargetValue string
+	// Annotations tune the scaling, e.g. kpa.autoscaling.aibrix.ai/stable-window.
+	Annotations map[string]string
+}
+
+// Scenario is a script run against the harness in a namespace of its own. Its steps fail the test through g.
+type Scenario struct {
+	Namespace string
+
+	h   *Harness
+	ctx context.Context
+	g   gomega.Gomega
+}
+
+// NewScenario creates the namespace of a scenario. Cleanup deletes it with the objects of the scenario.
+func (h *Harness) NewScenario(ctx context.Context, g gomega.Gomega) *Scenario {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "scenario-"}}
+	g.Expect(h.Client.Create(ctx, namespace)).To(gomega.Succeed())
+	return &Scenario{Namespace: namespace.Name, h: h, ctx: ctx, g: g}
+}
+
+// CreateModel creates the Deployment of the model, and its PodAutoscaler if the model autoscales. The pods of the
+// model serve it on the inference engine port, and load the adapters of the model.
+func (s *Scenario) CreateModel(name string, m Model) {
+	labels := map[string]string{
+		modeladapter.ModelIdentifierKey:              name,
+		modeladapter.ModelAdapterPodTemplateLabelKey: modeladapter.ModelAdapterPodTemplateLabelValue,
+		utils.PodPortIdentifier:                      modeladapter.DefaultInferenceEnginePort,
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: name, Labels: map[string]string{modeladapter.ModelIdentifierKey: name}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(m.Replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{modeladapter.ModelIdentifierKey: name}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "engine",
+						Image: "aibrix/fake-engine:latest",
+						Ports: []corev1.ContainerPort{{Name: "serving", ContainerPort: 8000}},
+					}},
+				},
+			},
+		},
+	}
+	s.g.Expect(s.h.Client.Create(s.ctx, deployment)).To(gomega.Succeed())
+
+	if m.Autoscaling == nil {
+		return
+	}
+	pa := &autoscalingv1alpha1.PodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: name, Annotations: m.Autoscaling.Annotations},
+		Spec: autoscalingv1alpha1.PodAutoscalerSpec{
+			ScaleTargetRef: corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: name},
+			MinReplicas:    ptr.To(m.Autoscaling.MinReplicas),
+			MaxReplicas:    m.Autoscaling.MaxReplicas,
+			MetricsSources: []autoscalingv1alpha1.MetricSource{{
+				MetricSourceType: autoscalingv1alpha1.POD,
+				ProtocolType:     autoscalingv1alpha1.HTTP,
+				Path:             "/metrics",
+				Port:             modeladapter.DefaultInferenceEnginePort,
+				TargetMetric:     m.Autoscaling.Metric,
+				TargetValue:      m.Autoscaling.TargetValue,
+			}},
+			ScalingStrategy: autoscalingv1alpha1.KPA,
+		},
+	}
+	s.g.Expect(s.h.Client.Create(s.ctx, pa)).To(gomega.Succeed())
+}
+
+// CreateModelAdapter creates a ModelAdapter of the base model, loaded on one of its pods.
+func (s *Scenario) CreateModelAdapter(name, baseModel string) {
+	adapter := &modelv1alpha1.ModelAdapter{
+		ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: name},
+		Spec: modelv1alpha1.ModelAdapterSpec{
+			BaseModel:   ptr.To(baseModel),
+			PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{modeladapter.ModelIdentifierKey: baseModel}},
+			ArtifactURL: "huggingface://aibrix/" + name,
+		},
+	}
+	s.g.Expect(s.h.Client.Create(s.ctx, adapter)).To(gomega.Succeed())
+}
+
+// Pods returns the sorted names of the running pods of the model.
+func (s *Scenario) Pods(model string) []string {
+	pods, err := s.pods(model)
+	s.g.Expect(err).NotTo(gomega.HaveOccurred())
+	return pods
+}
+
+func (s *Scenario) pods(model string) ([]string, error) {
+	podList := &corev1.PodList{}
+	if err := s.h.Client.List(s.ctx, podList, client.InNamespace(s.Namespace),
+		client.MatchingLabels{modeladapter.ModelIdentifierKey: model}); err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase == corev1.PodRunning && utils.IsPodReady(pod) && !utils.IsPodTerminating(pod) {
+			names = append(names, pod.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SetMetric sets the value of a metric reported by the engine of the pod.
+func (s *Scenario) SetMetric(pod, name string, value float64) {
+	eng, ok := s.h.engines.get(s.Namespace + "/" + pod)
+	s.g.Expect(ok).To(gomega.BeTrue(), "pod %s has no running engine", pod)
+	eng.setMetric(name, value)
+}
+
+// SetModelMetric sets the value of a metric reported by the engines of all the pods of the model, including the
+// pods created later.
+func (s *Scenario) SetModelMetric(model, name string, value float64) {
+	s.h.engines.setModelMetric(s.Namespace, model, name, value)
+}
+
+// DeletePod deletes the pod, as if its node went away. Its engine stops serving.
+func (s *Scenario) DeletePod(pod string) {
+	err := s.h.Client.Delete(s.ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: pod}})
+	s.g.Expect(err).NotTo(gomega.HaveOccurred())
+}
+
+// AdvanceClock steps the clock of the PodAutoscalers by d, a second at a time, letting them sample the metrics and
+// scale a few times at every step.
+func (s *Scenario) AdvanceClock(d time.Duration) {
+	for d > 0 {
+		step := min(d, clockStep)
+		s.h.clock.Step(step)
+		d -= step
+		time.Sleep(3 * syncPeriod)
+	}
+}
+
+// ExpectReplicas waits until the Deployment of the model asks for n replicas and n pods of the model are running.
+func (s *Scenario) ExpectReplicas(model string, n int32) {
+	s.g.Eventually(func(g gomega.Gomega) {
+		deployment := &appsv1.Deployment{}
+		g.Expect(s.h.Client.Get(s.ctx, client.ObjectKey{Namespace: s.Namespace, Name: model}, deployment)).To(gomega.Succeed())
+		g.Expect(deployment.Spec.Replicas).To(gomega.HaveValue(gomega.Equal(n)))
+		pods, err := s.pods(model)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(pods).To(gomega.HaveLen(int(n)))
+	}).WithTimeout(expectTimeout).WithPolling(expectPolling).Should(gomega.Succeed())
+}
+
+// ExpectRoutable waits until the gateway routes the model, a base model or an adapter, to exactly the pods, and the
+// engine of each of them serves the model.
+func (s *Scenario) ExpectRoutable(model string, pods ...string) {
+	expected := append([]string(nil), pods...)
+	sort.Strings(expected)
+	s.g.Eventually(func(g gomega.Gomega) {
+		g.Expect(s.routable(g, model)).To(gomega.Equal(expected))
+	}).WithTimeout(expectTimeout).WithPolling(expectPolling).Should(gomega.Succeed())
+}
+
+// ExpectRoutableReplicas waits until the gateway routes the model to n pods whose engines serve it, and returns the
+// pods.
+func (s *Scenario) ExpectRoutableReplicas(model string, n int) []string {
+	var pods []string
+	s.g.Eventually(func(g gomega.Gomega) {
+		pods = s.routable(g, model)
+		g.Expect(pods).To(gomega.HaveLen(n))
+	}).WithTimeout(expectTimeout).WithPolling(expectPolling).Should(gomega.Succeed())
+	return pods
+}
+
+// routable returns the sorted names of the pods of the scenario the gateway routes the model to, failing g unless
+// the engine of every one of them serves the model.
+func (s *Scenario) routable(g gomega.Gomega, model string) []string {
+	podsMap, err := s.h.cache.GetPodsForModel(model)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, pod := range utils.FilterReadyPods(podsMap) {
+		if pod.Namespace != s.Namespace {
+			continue
+		}
+		eng, ok := s.h.engines.get(s.Namespace + "/" + pod.Name)
+		g.Expect(ok).To(gomega.BeTrue(), "model %s is routed to pod %s without an engine", model, pod.Name)
+		g.Expect(eng.serves(model)).To(gomega.BeTrue(), "model %s is routed to pod %s not serving it", model, pod.Name)
+		names = append(names, pod.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Cleanup deletes the objects of the scenario and its namespace. The ModelAdapters go first, their finalizers unload
+// them from the engines while the pods still run.
+func (s *Scenario) Cleanup() {
+	ctx, opts := s.ctx, client.InNamespace(s.Namespace)
+	s.g.Expect(s.h.Client.DeleteAllOf(ctx, &modelv1alpha1.ModelAdapter{}, opts)).To(gomega.Succeed())
+	s.g.Eventually(func(g gomega.Gomega) {
+		adapters := &modelv1alpha1.ModelAdapterList{}
+		g.Expect(s.h.Client.List(ctx, adapters, opts)).To(gomega.Succeed())
+		g.Expect(adapters.Items).To(gomega.BeEmpty())
+	}).WithTimeout(expectTimeout).WithPolling(expectPolling).Should(gomega.Succeed())
+
+	s.g.Expect(s.h.Client.DeleteAllOf(ctx, &autoscalingv1alpha1.PodAutoscaler{}, opts)).To(gomega.Succeed())
+	s.g.Expect(s.h.Client.DeleteAllOf(ctx, &appsv1.Deployment{}, opts)).To(gomega.Succeed())
+	s.g.Eventually(func(g gomega.Gomega) {
+		pods := &corev1.PodList{}
+		g.Expect(s.h.Client.List(ctx, pods, opts)).To(gomega.Succeed())
+		g.Expect(pods.Items).To(gomega.BeEmpty())
+	}).WithTimeout(expectTimeout).WithPolling(expectPolling).Should(gomega.Succeed())
+
+	s.h.engines.retain(s.Namespace, nil)
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: s.Namespace}}
+	s.g.Expect(client.IgnoreNotFound(s.h.Client.Delete(ctx, namespace))).To(gomega.Succeed())
+}
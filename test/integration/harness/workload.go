@@ -0,0 +1,172 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/vllm-project/aibrix/pkg/controller/modeladapter"
+)
+
+// deploymentSimulator stands in for the deployment controller, the scheduler and the kubelet, which envtest doesn't
+// run. It keeps spec.replicas ready pods per Deployment, each with a running fake engine, and deletes the pods of the
+// deleted Deployments since envtest doesn't collect the garbage either.
+type deploymentSimulator struct {
+	client  client.Client
+	reader  client.Reader
+	engines *engines
+}
+
+func (s *deploymentSimulator) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	deployment := &appsv1.Deployment{}
+	err := s.reader.Get(ctx, req.NamespacedName, deployment)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	if apierrors.IsNotFound(err) || deployment.DeletionTimestamp != nil {
+		deployment = nil
+	}
+
+	podList := &corev1.PodList{}
+	if err := s.reader.List(ctx, podList, client.InNamespace(req.Namespace)); err != nil {
+		return reconcile.Result{}, err
+	}
+	var owned []*corev1.Pod
+	alive := map[string]bool{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		alive[client.ObjectKeyFromObject(pod).String()] = true
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "Deployment" && owner.Name == req.Name {
+			owned = append(owned, pod)
+		}
+	}
+	// the engines of the deleted pods stop serving, like the containers of a deleted pod.
+	defer s.engines.retain(req.Namespace, alive)
+
+	replicas := 0
+	if deployment != nil && deployment.Spec.Replicas != nil {
+		replicas = int(*deployment.Spec.Replicas)
+	}
+	// scale down removes the newest pods first.
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[j].CreationTimestamp.Before(&owned[i].CreationTimestamp)
+	})
+	for len(owned) > replicas {
+		if err := s.client.Delete(ctx, owned[0]); client.IgnoreNotFound(err) != nil {
+			return reconcile.Result{}, err
+		}
+		delete(alive, client.ObjectKeyFromObject(owned[0]).String())
+		owned = owned[1:]
+	}
+	if deployment == nil {
+		return reconcile.Result{}, nil
+	}
+
+	for len(owned) < replicas {
+		pod, err := s.createPod(ctx, deployment)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		alive[client.ObjectKeyFromObject(pod).String()] = true
+		owned = append(owned, pod)
+	}
+	for _, pod := range owned {
+		if err := s.runPod(ctx, pod); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	original := deployment.DeepCopy()
+	deployment.Status.ObservedGeneration = deployment.Generation
+	deployment.Status.Replicas = int32(replicas)
+	deployment.Status.UpdatedReplicas = int32(replicas)
+	deployment.Status.ReadyReplicas = int32(replicas)
+	deployment.Status.AvailableReplicas = int32(replicas)
+	return reconcile.Result{}, s.client.Status().Patch(ctx, deployment, client.MergeFrom(original))
+}
+
+// createPod creates a pod of the Deployment from its template. The pod is never bound to a node, so that the API
+// server deletes it right away instead of waiting for a kubelet to terminate it.
+func (s *deploymentSimulator) createPod(ctx context.Context, deployment *appsv1.Deployment) (*corev1.Pod, error) {
+	template := deployment.Spec.Template
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:    deployment.Namespace,
+			GenerateName: deployment.Name + "-",
+			Labels:       template.Labels,
+			Annotations:  template.Annotations,
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	if err := controllerutil.SetControllerReference(deployment, pod, s.client.Scheme()); err != nil {
+		return nil, err
+	}
+	if err := s.client.Create(ctx, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// runPod starts the engine of the pod and marks the pod running and ready on the IP of its engine.
+func (s *deploymentSimulator) runPod(ctx context.Context, pod *corev1.Pod) error {
+	eng, err := s.engines.start(client.ObjectKeyFromObject(pod).String(), pod.Labels[modeladapter.ModelIdentifierKey])
+	if err != nil {
+		return err
+	}
+	if pod.Status.PodIP == eng.ip && pod.Status.Phase == corev1.PodRunning {
+		return nil
+	}
+
+	original := pod.DeepCopy()
+	now := metav1.Now()
+	pod.Status = corev1.PodStatus{
+		Phase:     corev1.PodRunning,
+		PodIP:     eng.ip,
+		PodIPs:    []corev1.PodIP{{IP: eng.ip}},
+		StartTime: &now,
+		Conditions: []corev1.PodCondition{
+			{Type: corev1.PodReady, Status: corev1.ConditionTrue, LastTransitionTime: now},
+			{Type: corev1.ContainersReady, Status: corev1.ConditionTrue, LastTransitionTime: now},
+		},
+	}
+	if err := s.client.Status().Patch(ctx, pod, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to mark pod %s running: %w", pod.Name, err)
+	}
+	return nil
+}
+
+func addDeploymentSimulator(mgr ctrl.Manager, engines *engines) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("deployment-simulator").
+		For(&appsv1.Deployment{}).
+		Owns(&corev1.Pod{}).
+		Complete(&deploymentSimulator{client: mgr.GetClient(), reader: mgr.GetAPIReader(), engines: engines})
+}
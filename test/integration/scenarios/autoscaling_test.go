@@ -0,0 +1,71 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scenarios
+
+import (
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/vllm-project/aibrix/test/integration/harness"
+)
+
+var _ = ginkgo.Describe("autoscaled model", func() {
+	var s *harness.Scenario
+
+	ginkgo.BeforeEach(func() {
+		s = h.NewScenario(ctx, gomega.Default)
+	})
+
+	ginkgo.AfterEach(func() {
+		s.Cleanup()
+	})
+
+	ginkgo.It("is routed to the scaled up pods, and never to the pods scaled down", func() {
+		s.CreateModel("llama-kpa", harness.Model{
+			Replicas: 1,
+			Autoscaling: &harness.Autoscaling{
+				MinReplicas: 1,
+				MaxReplicas: 3,
+				Metric:      "vllm:num_requests_running",
+				TargetValue: "10",
+				Annotations: map[string]string{
+					"kpa.autoscaling.aibrix.ai/stable-window":               "30s",
+					"kpa.autoscaling.aibrix.ai/scale-down-delay":            "1m",
+					"autoscaling.aibrix.ai/scale-down-stabilization-window": "0s",
+				},
+			},
+		})
+		s.ExpectReplicas("llama-kpa", 1)
+		s.CreateModelAdapter("chat-lora", "llama-kpa")
+		s.ExpectRoutableReplicas("chat-lora", 1)
+
+		s.SetModelMetric("llama-kpa", "vllm:num_requests_running", 30)
+		s.AdvanceClock(10 * time.Second)
+		s.ExpectReplicas("llama-kpa", 3)
+		s.ExpectRoutableReplicas("llama-kpa", 3)
+
+		// the load is gone, the model scales back down once the scale down delay is over. The gateway must stop
+		// routing the model and its adapter to the pods scaled down.
+		s.SetModelMetric("llama-kpa", "vllm:num_requests_running", 0)
+		s.AdvanceClock(2 * time.Minute)
+		s.ExpectReplicas("llama-kpa", 1)
+		s.ExpectRoutable("llama-kpa", s.Pods("llama-kpa")...)
+		s.ExpectRoutable("chat-lora", s.Pods("llama-kpa")...)
+	})
+})
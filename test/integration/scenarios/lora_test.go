@@ -0,0 +1,51 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scenarios
+
+import (
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/vllm-project/aibrix/test/integration/harness"
+)
+
+var _ = ginkgo.Describe("model adapter on a deleted pod", func() {
+	var s *harness.Scenario
+
+	ginkgo.BeforeEach(func() {
+		s = h.NewScenario(ctx, gomega.Default)
+	})
+
+	ginkgo.AfterEach(func() {
+		s.Cleanup()
+	})
+
+	ginkgo.It("is routed only to the live pod it is reloaded on", func() {
+		s.CreateModel("llama-lora", harness.Model{Replicas: 2})
+		s.ExpectReplicas("llama-lora", 2)
+		s.CreateModelAdapter("sql-lora", "llama-lora")
+		loaded := s.ExpectRoutableReplicas("sql-lora", 1)
+
+		// the gateway must stop routing the adapter to the deleted pod, and route it to the pod it is loaded on next.
+		s.DeletePod(loaded[0])
+		s.ExpectReplicas("llama-lora", 2)
+		reloaded := s.ExpectRoutableReplicas("sql-lora", 1)
+		gomega.Expect(reloaded[0]).NotTo(gomega.Equal(loaded[0]))
+		gomega.Expect(s.Pods("llama-lora")).To(gomega.ContainElement(reloaded[0]))
+		s.ExpectRoutable("llama-lora", s.Pods("llama-lora")...)
+	})
+})
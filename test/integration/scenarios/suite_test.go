@@ -0,0 +1,63 @@
+/*
+Copyright 2024 The Aibrix Team.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scenarios
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/vllm-project/aibrix/test/integration/harness"
+)
+
+// These scenarios run the PodAutoscaler and ModelAdapter controllers and the gateway cache together, the bugs they
+// cover only show up across the components.
+
+var h *harness.Harness
+var ctx context.Context
+var cancel context.CancelFunc
+
+func TestScenarios(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Scenario Suite")
+}
+
+var _ = BeforeSuite(func() {
+	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+
+	ctx, cancel = context.WithCancel(context.TODO())
+
+	By("starting the controllers and the gateway cache against the test environment")
+	var err error
+	h, err = harness.Start(filepath.Join("..", "..", ".."))
+	Expect(err).NotTo(HaveOccurred())
+})
+
+var _ = AfterSuite(func() {
+	cancel()
+	By("tearing down the test environment")
+	if h != nil {
+		Expect(h.Stop()).To(Succeed())
+	}
+})
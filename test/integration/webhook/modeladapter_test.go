@@ -158,5 +158,21 @@ var _ = ginkgo.Describe("modelAdapter default and validation", func() {
 			},
 			failed: true,
 		}),
+		ginkgo.Entry("adapter creation with a name which is not a DNS label should be failed", &testValidatingCase{
+			adapter: func() *modelapi.ModelAdapter {
+				adapter := modelapi.ModelAdapter{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test.adapter",
+						Namespace: ns.Name,
+					},
+					Spec: modelapi.ModelAdapterSpec{
+						ArtifactURL: "s3://test-bucket/test-model",
+						PodSelector: &metav1.LabelSelector{},
+					},
+				}
+				return &adapter
+			},
+			failed: true,
+		}),
 	)
 })